@@ -0,0 +1,224 @@
+// Package alerting evaluates users' alert rules against their agents and sessions and notifies the matches
+package alerting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Engine evaluates alert rules: status rules as statuses are recorded, and rules on how long a session has been
+// running or an agent silent on every Check
+// A condition that keeps holding is notified once: a session run once per running_longer rule, and an agent once
+// per silence per agent_offline rule. What was notified is kept in memory, so a restart may notify it again.
+type Engine struct {
+	store    store.Store
+	notifier *notifier.NotificationManager
+	clock    clock.Clock
+	notified map[string]bool // rule and subject of conditions notified that held on the last check
+}
+
+// NewEngine creates an engine; n may be nil to evaluate rules without notifying
+func NewEngine(st store.Store, n *notifier.NotificationManager) *Engine {
+	return &Engine{
+		store:    st,
+		notifier: n,
+		clock:    clock.Real,
+		notified: make(map[string]bool),
+	}
+}
+
+// SetClock replaces the clock that decides how long sessions have been running and agents silent
+func (e *Engine) SetClock(c clock.Clock) {
+	e.clock = c
+}
+
+// now returns the time rules are evaluated at, in UTC
+func (e *Engine) now() time.Time {
+	return e.clock.Now().UTC()
+}
+
+// StatusRecorded notifies the status rules of the agent's owner matching a status the agent reported
+// previousStatus is the latest status of the run before it; repeating a status does not notify again.
+func (e *Engine) StatusRecorded(agent *models.Agent, status *models.AgentStatus, previousStatus string) {
+	if agent.UserID == "" || status.Status == previousStatus {
+		return
+	}
+
+	rules, err := e.store.ListAlertRulesByUser(agent.UserID)
+	if err != nil {
+		log.Printf("Failed to list alert rules: %v", err)
+		return
+	}
+
+	for _, rule := range rules {
+		if rule.Kind != models.AlertRuleStatus || rule.Status != status.Status || !matches(rule, agent.AgentID, status.SessionTopic) {
+			continue
+		}
+		e.notify(rule, &notifier.AlertData{
+			RuleName:     rule.Name,
+			Kind:         rule.Kind,
+			AgentID:      agent.AgentID,
+			AgentName:    agent.Name,
+			SessionTopic: status.SessionTopic,
+			Status:       status.Status,
+			Message:      status.Message,
+			Timestamp:    status.Timestamp,
+		})
+	}
+}
+
+// Check evaluates the running_longer and agent_offline rules of every user and escalates persisting failures
+// Check is not safe for concurrent use; it runs on a single ticker.
+func (e *Engine) Check() {
+	rules, err := e.store.ListAlertRules()
+	if err != nil {
+		log.Printf("Failed to list alert rules: %v", err)
+		return
+	}
+
+	now := e.now()
+	holding := make(map[string]bool)
+	running := make(map[string][]*models.RunningSession)
+	agents := make(map[string][]*models.Agent)
+
+	for _, rule := range rules {
+		threshold := time.Duration(rule.DurationMinutes) * time.Minute
+
+		switch rule.Kind {
+		case models.AlertRuleRunningLonger:
+			sessions, ok := running[rule.UserID]
+			if !ok {
+				sessions, err = e.store.ListRunningSessions(rule.UserID)
+				if err != nil {
+					log.Printf("Failed to list running sessions for alert rules: %v", err)
+				}
+				running[rule.UserID] = sessions
+			}
+			for _, rs := range sessions {
+				session := rs.Session
+				if now.Sub(rs.Started) < threshold || !matches(rule, session.AgentID, session.SessionTopic) {
+					continue
+				}
+				key := fmt.Sprintf("%s|%s|%s|%d", rule.ID, session.AgentID, session.SessionTopic, session.Revision)
+				holding[key] = true
+				if e.notified[key] {
+					continue
+				}
+				e.notify(rule, &notifier.AlertData{
+					RuleName:     rule.Name,
+					Kind:         rule.Kind,
+					AgentID:      session.AgentID,
+					AgentName:    rs.AgentName,
+					SessionTopic: session.SessionTopic,
+					Since:        rs.Started,
+					Timestamp:    now,
+				})
+			}
+
+		case models.AlertRuleAgentOffline:
+			owned, ok := agents[rule.UserID]
+			if !ok {
+				owned = e.store.ListAgentsByUser(rule.UserID)
+				agents[rule.UserID] = owned
+			}
+			for _, agent := range owned {
+				if agent.LastSeen.IsZero() || now.Sub(agent.LastSeen) < threshold || !matches(rule, agent.AgentID, "") {
+					continue
+				}
+				key := fmt.Sprintf("%s|%s|%d", rule.ID, agent.AgentID, agent.LastSeen.UnixNano())
+				holding[key] = true
+				if e.notified[key] {
+					continue
+				}
+				e.notify(rule, &notifier.AlertData{
+					RuleName:  rule.Name,
+					Kind:      rule.Kind,
+					AgentID:   agent.AgentID,
+					AgentName: agent.Name,
+					Since:     agent.LastSeen,
+					Timestamp: now,
+				})
+			}
+		}
+	}
+
+	// Conditions that stopped holding are forgotten, so they notify again when they next hold
+	e.notified = holding
+
+	e.escalate(now)
+}
+
+// matches reports whether a rule applies to the agent and session topic; agent_offline rules pass an empty topic
+func matches(rule *models.AlertRule, agentID, sessionTopic string) bool {
+	if rule.AgentID != "" && rule.AgentID != agentID {
+		return false
+	}
+	if rule.TopicPattern == "" {
+		return true
+	}
+
+	// Validated on write, so a compile error only happens for rows edited out of band
+	pattern, err := regexp.Compile(rule.TopicPattern)
+	if err != nil {
+		log.Printf("Skipping alert rule %s with invalid topic pattern: %v", rule.ID, err)
+		return false
+	}
+	return pattern.MatchString(sessionTopic)
+}
+
+// notify sends an alert to the rule owner's destinations, unless they muted the agent's star
+func (e *Engine) notify(rule *models.AlertRule, data *notifier.AlertData) {
+	if e.notifier == nil {
+		return
+	}
+
+	user, destinations, ok := e.destinations(rule.UserID, data.AgentID)
+	if !ok {
+		return
+	}
+	data.Mentions = notifier.MentionsFromRules(user.MentionsFor(data.AgentID, data.SessionTopic))
+	if err := e.notifier.NotifyAlert(context.Background(), data, destinations); err != nil {
+		log.Printf("Failed to queue alert notification: %v", err)
+	}
+}
+
+// destinations returns the user and their own receivers for notifications about an agent, or false when the user
+// cannot be loaded or muted the agent's star
+func (e *Engine) destinations(userID, agentID string) (*models.User, []models.NotificationDestination, bool) {
+	user, err := e.store.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Failed to load user for alert notification: %v", err)
+		return nil, nil, false
+	}
+
+	// A star's webhook URL wins over the notification settings', which wins over the profile's
+	destinations := []models.NotificationDestination{{URL: user.NotificationWebhookURL}}
+	settings, err := e.store.GetNotificationSettings(user.ID)
+	if err == nil {
+		if own, ok := settings.Destination(); ok {
+			destinations[0] = own
+		}
+	} else if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to load notification settings: %v", err)
+	}
+	if star, err := e.store.GetWatchItem(user.ID, agentID, ""); err == nil {
+		if star.MuteNotifications {
+			return nil, nil, false
+		}
+		if star.NotificationWebhookURL != "" {
+			destinations[0] = models.NotificationDestination{URL: star.NotificationWebhookURL}
+		}
+	} else if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to load watch item for notification: %v", err)
+	}
+	return user, append(destinations, user.NotificationDestinations...), true
+}
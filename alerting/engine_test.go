@@ -0,0 +1,154 @@
+package alerting
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+var testNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// receiver collects the notifications posted to it
+type receiver struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	rc.bodies = append(rc.bodies, string(body))
+	rc.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// received waits for queued notifications and returns those posted since the last call
+func (rc *receiver) received() []string {
+	time.Sleep(200 * time.Millisecond)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	bodies := rc.bodies
+	rc.bodies = nil
+	return bodies
+}
+
+// newTestStore returns a store holding user-1, whose notifications go to webhookURL, and the given rules
+func newTestStore(t *testing.T, webhookURL string, rules ...*models.AlertRule) store.Store {
+	t.Helper()
+	st := store.NewMemoryStore()
+	user := &models.User{ID: "user-1", Email: "one@example.com", PasswordHash: "x", NotificationWebhookURL: webhookURL, CreatedAt: testNow, UpdatedAt: testNow}
+	if err := st.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	for i, rule := range rules {
+		rule.UserID = "user-1"
+		rule.CreatedAt = testNow.Add(time.Duration(i) * time.Second)
+		rule.UpdatedAt = rule.CreatedAt
+		if err := st.CreateAlertRule(rule); err != nil {
+			t.Fatalf("CreateAlertRule(%s) error = %v", rule.ID, err)
+		}
+	}
+	return st
+}
+
+func TestEngine_StatusRecorded(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	st := newTestStore(t, server.URL,
+		&models.AlertRule{ID: "any-failure", Name: "Any failure", Kind: models.AlertRuleStatus, Status: "failed"},
+		&models.AlertRule{ID: "deploy-pending", Name: "Deploy pending", Kind: models.AlertRuleStatus, Status: "pending", TopicPattern: "^deploy"},
+	)
+	engine := NewEngine(st, notifier.NewNotificationManager(5*time.Second))
+	agent := &models.Agent{AgentID: "build-bot", UserID: "user-1", Name: "Builder"}
+	status := func(topic, value string) *models.AgentStatus {
+		return &models.AgentStatus{AgentID: agent.AgentID, SessionTopic: topic, Status: value, Message: "exit 1", Timestamp: testNow}
+	}
+
+	engine.StatusRecorded(agent, status("build", "failed"), "running")
+	engine.StatusRecorded(agent, status("build", "failed"), "failed")
+	engine.StatusRecorded(agent, status("build", "pending"), "running")
+	bodies := rc.received()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "Alert: Any failure") || !strings.Contains(bodies[0], "Session: build") {
+		t.Fatalf("notifications = %q, want the first failure of build only", bodies)
+	}
+
+	engine.StatusRecorded(agent, status("deploy-web", "pending"), "")
+	if bodies := rc.received(); len(bodies) != 1 || !strings.Contains(bodies[0], "Alert: Deploy pending") {
+		t.Errorf("notifications = %q, want the pending deploy", bodies)
+	}
+}
+
+func TestEngine_Check(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	st := newTestStore(t, server.URL,
+		&models.AlertRule{ID: "slow", Name: "Slow builds", Kind: models.AlertRuleRunningLonger, TopicPattern: "^build", DurationMinutes: 30},
+		&models.AlertRule{ID: "silent", Name: "Silent agent", Kind: models.AlertRuleAgentOffline, AgentID: "build-bot", DurationMinutes: 10},
+	)
+	for _, agent := range []*models.Agent{
+		{AgentID: "build-bot", UserID: "user-1", Name: "Builder", Registered: testNow, LastSeen: testNow},
+		{AgentID: "other-bot", UserID: "user-1", Registered: testNow, LastSeen: testNow.Add(-time.Hour)},
+	} {
+		if err := st.CreateOrUpdateAgent(agent); err != nil {
+			t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+		}
+	}
+	for _, topic := range []string{"build-1", "lint"} {
+		session := &models.Session{AgentID: "build-bot", SessionTopic: topic, Created: testNow, LastUpdated: testNow, TTLMinutes: 600}
+		if err := st.CreateOrUpdateSession(session); err != nil {
+			t.Fatalf("CreateOrUpdateSession() error = %v", err)
+		}
+		if err := st.AddStatus(&models.AgentStatus{AgentID: "build-bot", SessionTopic: topic, Status: "running", Timestamp: testNow}); err != nil {
+			t.Fatalf("AddStatus() error = %v", err)
+		}
+	}
+
+	fake := clock.NewFake(testNow.Add(20 * time.Minute))
+	engine := NewEngine(st, notifier.NewNotificationManager(5*time.Second))
+	engine.SetClock(fake)
+
+	// Silent for 20 minutes and running for 20: only the agent rule holds
+	engine.Check()
+	bodies := rc.received()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "Alert: Silent agent") || !strings.Contains(bodies[0], "Silent For: 20m0s") {
+		t.Fatalf("notifications = %q, want build-bot silent only", bodies)
+	}
+
+	// Conditions still holding are not notified again
+	fake.Advance(15 * time.Minute)
+	engine.Check()
+	bodies = rc.received()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "Alert: Slow builds") || !strings.Contains(bodies[0], "Session: build-1") {
+		t.Fatalf("notifications = %q, want build-1 running too long only", bodies)
+	}
+	engine.Check()
+	if bodies := rc.received(); len(bodies) != 0 {
+		t.Errorf("notifications = %q, want none for conditions already notified", bodies)
+	}
+
+	// An agent that reports again and falls silent again is notified again
+	agent, _ := st.GetAgent("build-bot")
+	agent.LastSeen = fake.Now()
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	engine.Check()
+	fake.Advance(11 * time.Minute)
+	engine.Check()
+	if bodies := rc.received(); len(bodies) != 1 || !strings.Contains(bodies[0], "Alert: Silent agent") {
+		t.Errorf("notifications = %q, want build-bot silent again", bodies)
+	}
+}
@@ -0,0 +1,91 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// escalate notifies again the failures of sessions that are still failed EscalateAfterMinutes after their owner
+// was last notified, as recorded in the store's notification marks
+// Each reminder is claimed in the store first, so replicas evaluating the same marks send it once.
+func (e *Engine) escalate(now time.Time) {
+	marks, err := e.store.ListNotificationMarks("failed")
+	if err != nil {
+		log.Printf("Failed to list notification marks: %v", err)
+		return
+	}
+
+	settings := make(map[string]*models.NotificationSettings)
+	for _, mark := range marks {
+		if mark.Escalations >= models.MaxNotificationEscalations {
+			continue
+		}
+		own, ok := settings[mark.UserID]
+		if !ok {
+			own, err = e.store.GetNotificationSettings(mark.UserID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				log.Printf("Failed to load notification settings: %v", err)
+			}
+			settings[mark.UserID] = own
+		}
+		if own == nil || own.EscalateAfterMinutes == 0 {
+			continue
+		}
+		after := time.Duration(own.EscalateAfterMinutes) * time.Minute
+		if now.Sub(mark.NotifiedAt) < after {
+			continue
+		}
+
+		// Only a failure the session still reports is escalated; a later status of any kind ends it
+		latest, err := e.store.GetLatestStatus(mark.AgentID, mark.SessionTopic)
+		if err != nil || latest == nil || latest.Status != "failed" {
+			continue
+		}
+
+		escalated := *mark
+		escalated.NotifiedAt = now
+		escalated.Escalations++
+		if err := e.store.ClaimNotificationMark(&escalated, now.Add(-after)); err != nil {
+			if !errors.Is(err, store.ErrAlreadyExists) {
+				log.Printf("Failed to record notification escalation: %v", err)
+			}
+			continue
+		}
+		e.notifyEscalation(&escalated, latest, now)
+	}
+}
+
+// notifyEscalation reminds the mark's owner that the session is still failed
+func (e *Engine) notifyEscalation(mark *models.NotificationMark, latest *models.AgentStatus, now time.Time) {
+	if e.notifier == nil {
+		return
+	}
+
+	user, destinations, ok := e.destinations(mark.UserID, mark.AgentID)
+	if !ok {
+		return
+	}
+	var agentName string
+	if agent, err := e.store.GetAgent(mark.AgentID); err == nil {
+		agentName = agent.Name
+	}
+
+	data := &notifier.EscalationData{
+		AgentID:      mark.AgentID,
+		AgentName:    agentName,
+		SessionTopic: mark.SessionTopic,
+		FailedAt:     latest.Timestamp,
+		Escalation:   mark.Escalations,
+		Timestamp:    now,
+		Mentions:     notifier.MentionsFromRules(user.MentionsFor(mark.AgentID, mark.SessionTopic)),
+	}
+	if err := e.notifier.NotifyEscalation(context.Background(), data, destinations); err != nil {
+		log.Printf("Failed to queue escalation notification: %v", err)
+	}
+}
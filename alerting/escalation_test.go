@@ -0,0 +1,68 @@
+package alerting
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+)
+
+func TestEngine_EscalatesPersistingFailures(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	st := newTestStore(t, server.URL)
+	if err := st.SaveNotificationSettings(&models.NotificationSettings{UserID: "user-1", EscalateAfterMinutes: 10, CreatedAt: testNow, UpdatedAt: testNow}); err != nil {
+		t.Fatalf("SaveNotificationSettings() error = %v", err)
+	}
+	if err := st.CreateOrUpdateAgent(&models.Agent{AgentID: "build-bot", UserID: "user-1", Name: "Builder", Registered: testNow, LastSeen: testNow}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	for _, topic := range []string{"build", "deploy"} {
+		if err := st.CreateOrUpdateSession(&models.Session{AgentID: "build-bot", SessionTopic: topic, Created: testNow, LastUpdated: testNow, TTLMinutes: 600}); err != nil {
+			t.Fatalf("CreateOrUpdateSession() error = %v", err)
+		}
+		if err := st.AddStatus(&models.AgentStatus{AgentID: "build-bot", SessionTopic: topic, Status: "failed", Timestamp: testNow}); err != nil {
+			t.Fatalf("AddStatus() error = %v", err)
+		}
+		mark := &models.NotificationMark{UserID: "user-1", AgentID: "build-bot", SessionTopic: topic, Status: "failed", NotifiedAt: testNow}
+		if err := st.ClaimNotificationMark(mark, testNow); err != nil {
+			t.Fatalf("ClaimNotificationMark() error = %v", err)
+		}
+	}
+	// deploy recovers, so only build is still failed
+	if err := st.AddStatus(&models.AgentStatus{AgentID: "build-bot", SessionTopic: "deploy", Status: "running", Timestamp: testNow.Add(time.Minute)}); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+
+	fake := clock.NewFake(testNow.Add(5 * time.Minute))
+	engine := NewEngine(st, notifier.NewNotificationManager(5*time.Second))
+	engine.SetClock(fake)
+	engine.Check()
+	if bodies := rc.received(); len(bodies) != 0 {
+		t.Fatalf("notifications before the escalation delay = %q, want none", bodies)
+	}
+
+	fake.Advance(6 * time.Minute)
+	engine.Check()
+	engine.Check()
+	bodies := rc.received()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "Session Still Failed") || !strings.Contains(bodies[0], "Session: build") ||
+		!strings.Contains(bodies[0], "Reminder: 1 of 3") {
+		t.Fatalf("notifications = %q, want one reminder for build", bodies)
+	}
+
+	// Reminders repeat every delay up to the limit
+	for i := 0; i < 5; i++ {
+		fake.Advance(10 * time.Minute)
+		engine.Check()
+	}
+	if bodies := rc.received(); len(bodies) != models.MaxNotificationEscalations-1 {
+		t.Errorf("notifications = %d, want %d more reminders", len(bodies), models.MaxNotificationEscalations-1)
+	}
+}
@@ -0,0 +1,338 @@
+// Package archive exports each completed UTC day of status history to object storage as compressed NDJSON
+// with a manifest, so history pruned from the store stays available for compliance queries and restores
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/encryption"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// WatermarkConfigKey is the system config key holding the first UTC day not yet archived, as YYYY-MM-DD
+const WatermarkConfigKey = "status_archive_through"
+
+// maxDaysPerRun bounds the days one run archives, so catching up on a long history is spread over runs
+const maxDaysPerRun = 31
+
+// ErrChecksumMismatch is returned when an archived object does not match its manifest
+var ErrChecksumMismatch = errors.New("archive object does not match its manifest")
+
+// Manifest describes the archived statuses of one day
+type Manifest struct {
+	Day        string    `json:"day"` // YYYY-MM-DD
+	Object     string    `json:"object"`
+	Statuses   int       `json:"statuses"`
+	Sessions   int       `json:"sessions"`
+	Agents     []string  `json:"agents"`
+	FirstAt    time.Time `json:"first_at"`
+	LastAt     time.Time `json:"last_at"`
+	Bytes      int       `json:"bytes"`
+	SHA256     string    `json:"sha256"`               // Of the object as stored, compressed and, when encrypted, sealed
+	Encryption string    `json:"encryption,omitempty"` // Algorithm the object is sealed with, empty when it is not encrypted
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// objectKey and manifestKey return the keys of a day's archive, e.g. statuses/2026/03/01.ndjson.gz
+func objectKey(day time.Time) string {
+	return "statuses/" + day.Format("2006/01/02") + ".ndjson.gz"
+}
+
+func manifestKey(day time.Time) string {
+	return "statuses/" + day.Format("2006/01/02") + ".manifest.json"
+}
+
+// Archiver archives the days completed since its last run and reads archived days back
+type Archiver struct {
+	store      store.Store
+	bucket     Bucket
+	passphrase string
+	clock      clock.Clock
+}
+
+// New creates an archiver writing to bucket
+func New(st store.Store, bucket Bucket) *Archiver {
+	return &Archiver{
+		store:  st,
+		bucket: bucket,
+		clock:  clock.Real,
+	}
+}
+
+// SetClock replaces the clock that decides which days are complete
+func (a *Archiver) SetClock(c clock.Clock) {
+	a.clock = c
+}
+
+// SetPassphrase encrypts the days archived from now on with passphrase, as encryption.SealExport does,
+// and decrypts the days read back; days archived without a passphrase stay readable
+func (a *Archiver) SetPassphrase(passphrase string) error {
+	if passphrase != "" {
+		if err := encryption.ValidatePassphrase(passphrase); err != nil {
+			return err
+		}
+	}
+	a.passphrase = passphrase
+	return nil
+}
+
+// ArchivedThrough returns the first day not yet archived, or the zero time before the first run
+// Statuses older than that day are archived and may be pruned without losing them.
+func (a *Archiver) ArchivedThrough() (time.Time, error) {
+	value, err := a.store.GetConfig(WatermarkConfigKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get archive watermark: %w", err)
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid archive watermark %q: %w", value, err)
+	}
+	return day, nil
+}
+
+// Run archives the completed days from the watermark on, at most maxDaysPerRun of them,
+// and returns the number of statuses archived
+// The first run starts from the day of the oldest status. The watermark advances after each day,
+// so a failed run resumes where it stopped.
+func (a *Archiver) Run(ctx context.Context) (int, error) {
+	day, err := a.ArchivedThrough()
+	if err != nil {
+		return 0, err
+	}
+	today := models.UsageDay(a.clock.Now())
+	if day.IsZero() {
+		oldest, err := a.store.ListStatusesBetween(time.Time{}, today, 1)
+		if err != nil {
+			return 0, err
+		}
+		if len(oldest) == 0 {
+			// Nothing to archive yet; later runs start from today
+			if err := a.store.SetConfig(WatermarkConfigKey, today.Format(time.DateOnly)); err != nil {
+				return 0, fmt.Errorf("failed to save archive watermark: %w", err)
+			}
+			return 0, nil
+		}
+		day = models.UsageDay(oldest[0].Timestamp)
+	}
+
+	archived := 0
+	for days := 0; day.Before(today) && days < maxDaysPerRun; days++ {
+		manifest, err := a.ArchiveDay(ctx, day)
+		if err != nil {
+			return archived, err
+		}
+		if manifest != nil {
+			archived += manifest.Statuses
+			log.Printf("Archived %d statuses of %s", manifest.Statuses, manifest.Day)
+		}
+		day = day.AddDate(0, 0, 1)
+		if err := a.store.SetConfig(WatermarkConfigKey, day.Format(time.DateOnly)); err != nil {
+			return archived, fmt.Errorf("failed to save archive watermark: %w", err)
+		}
+	}
+	return archived, nil
+}
+
+// ArchiveDay writes the statuses of a UTC day and then its manifest, returning nil when the day has none
+// A day archived again replaces its objects.
+func (a *Archiver) ArchiveDay(ctx context.Context, day time.Time) (*Manifest, error) {
+	day = models.UsageDay(day)
+	statuses, err := a.store.ListStatusesBetween(day, day.AddDate(0, 0, 1), 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(zw)
+	sessions := make(map[string]bool)
+	agents := make(map[string]bool)
+	for _, status := range statuses {
+		if err := encoder.Encode(status); err != nil {
+			return nil, fmt.Errorf("failed to encode status %d: %w", status.ID, err)
+		}
+		sessions[status.AgentID+"\x00"+status.SessionTopic] = true
+		agents[status.AgentID] = true
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	object, contentType, algorithm := buf.Bytes(), "application/gzip", ""
+	if a.passphrase != "" {
+		sealed, err := encryption.SealExport(object, contentType, encryption.ExportKey{Passphrase: a.passphrase})
+		if err != nil {
+			return nil, err
+		}
+		object, contentType, algorithm = sealed, "application/json", encryption.AlgorithmPassphrase
+	}
+
+	manifest := &Manifest{
+		Day:        day.Format(time.DateOnly),
+		Object:     objectKey(day),
+		Statuses:   len(statuses),
+		Sessions:   len(sessions),
+		Agents:     make([]string, 0, len(agents)),
+		FirstAt:    statuses[0].Timestamp.UTC(),
+		LastAt:     statuses[len(statuses)-1].Timestamp.UTC(),
+		Bytes:      len(object),
+		SHA256:     sha256Hex(object),
+		Encryption: algorithm,
+		CreatedAt:  a.clock.Now().UTC(),
+	}
+	for agentID := range agents {
+		manifest.Agents = append(manifest.Agents, agentID)
+	}
+	sort.Strings(manifest.Agents)
+
+	if err := a.bucket.Put(ctx, manifest.Object, object, contentType); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.bucket.Put(ctx, manifestKey(day), raw, "application/json"); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Manifest returns the manifest of an archived day, or ErrObjectNotFound if the day was not archived
+func (a *Archiver) Manifest(ctx context.Context, day time.Time) (*Manifest, error) {
+	raw, err := a.bucket.Get(ctx, manifestKey(models.UsageDay(day)))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// Statuses returns the archived statuses of a day, oldest first, optionally only those of one agent
+// and session topic
+// The object is checked against its manifest before it is read.
+func (a *Archiver) Statuses(ctx context.Context, day time.Time, agentID, sessionTopic string) ([]*models.AgentStatus, error) {
+	manifest, err := a.Manifest(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	data, err := a.bucket.Get(ctx, manifest.Object)
+	if err != nil {
+		return nil, err
+	}
+	if sha256Hex(data) != manifest.SHA256 {
+		return nil, ErrChecksumMismatch
+	}
+	if manifest.Encryption != "" {
+		if a.passphrase == "" {
+			return nil, errors.New("archived day is encrypted, a passphrase is required to read it")
+		}
+		if data, _, err = encryption.OpenExport(data, nil, a.passphrase); err != nil {
+			return nil, fmt.Errorf("failed to decrypt archive object: %w", err)
+		}
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive object: %w", err)
+	}
+	defer zr.Close()
+
+	statuses := make([]*models.AgentStatus, 0)
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var status models.AgentStatus
+		if err := json.Unmarshal(scanner.Bytes(), &status); err != nil {
+			return nil, fmt.Errorf("invalid archived status: %w", err)
+		}
+		if (agentID != "" && status.AgentID != agentID) || (sessionTopic != "" && status.SessionTopic != sessionTopic) {
+			continue
+		}
+		statuses = append(statuses, &status)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive object: %w", err)
+	}
+	return statuses, nil
+}
+
+// RestoreResult counts what a restore did with the archived statuses it read
+type RestoreResult struct {
+	Restored int `json:"restored"`
+	Present  int `json:"present"`  // Already in the store, with the same status and timestamp
+	Orphaned int `json:"orphaned"` // Their session no longer exists
+}
+
+// Restore adds the archived statuses of a day, optionally only those of one agent and session topic,
+// back to the store
+// Statuses still stored are skipped, so restoring twice adds nothing; restored statuses get new IDs.
+func (a *Archiver) Restore(ctx context.Context, day time.Time, agentID, sessionTopic string) (*RestoreResult, error) {
+	statuses, err := a.Statuses(ctx, day, agentID, sessionTopic)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RestoreResult{}
+	present := make(map[string]map[string]bool) // agent_id|session_topic -> status|timestamp
+	for _, status := range statuses {
+		sessionKey := status.AgentID + "|" + status.SessionTopic
+		seen, loaded := present[sessionKey]
+		if !loaded {
+			if _, err := a.store.GetSession(status.AgentID, status.SessionTopic); err != nil {
+				if !errors.Is(err, store.ErrNotFound) {
+					return nil, err
+				}
+			} else {
+				history, err := a.store.GetStatusHistory(status.AgentID, status.SessionTopic)
+				if err != nil {
+					return nil, err
+				}
+				seen = make(map[string]bool, len(history))
+				for _, stored := range history {
+					seen[statusKey(stored)] = true
+				}
+			}
+			present[sessionKey] = seen
+		}
+
+		switch {
+		case seen == nil:
+			result.Orphaned++
+		case seen[statusKey(status)]:
+			result.Present++
+		default:
+			status.ID = 0
+			if err := a.store.AddStatus(status); err != nil {
+				return nil, fmt.Errorf("failed to restore status of %s/%s: %w", status.AgentID, status.SessionTopic, err)
+			}
+			seen[statusKey(status)] = true
+			result.Restored++
+		}
+	}
+	return result, nil
+}
+
+// statusKey identifies a status by what survives archiving, since restored statuses get new IDs
+func statusKey(status *models.AgentStatus) string {
+	return status.Status + "|" + status.Timestamp.UTC().Format(time.RFC3339Nano)
+}
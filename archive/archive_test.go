@@ -0,0 +1,162 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/encryption"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// seedStore creates two sessions reporting on the first two days of March 2026
+func seedStore(t *testing.T, st store.Store, day time.Time) {
+	t.Helper()
+
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Registered: day, LastSeen: day})
+	for _, topic := range []string{"build", "deploy"} {
+		st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: topic, Created: day, LastUpdated: day})
+	}
+	for _, status := range []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "build", Status: "running", Timestamp: day.Add(time.Hour), Revision: 1},
+		{AgentID: "agent-1", SessionTopic: "build", Status: "success", Timestamp: day.Add(2 * time.Hour), Message: "done", Revision: 1},
+		{AgentID: "agent-1", SessionTopic: "deploy", Status: "failed", Timestamp: day.Add(3 * time.Hour), Revision: 1},
+		{AgentID: "agent-1", SessionTopic: "build", Status: "running", Timestamp: day.Add(30 * time.Hour), Revision: 2},
+	} {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus() error = %v", err)
+		}
+	}
+}
+
+func TestArchiver_RunAndRead(t *testing.T) {
+	st := store.NewMemoryStore()
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	seedStore(t, st, day)
+
+	bucket, err := OpenBucket(BucketConfig{URL: "file://" + t.TempDir()})
+	if err != nil {
+		t.Fatalf("OpenBucket() error = %v", err)
+	}
+	archiver := New(st, bucket)
+	fake := clock.NewFake(day.Add(36 * time.Hour))
+	archiver.SetClock(fake)
+	ctx := context.Background()
+
+	// Only the completed first day is archived
+	if archived, err := archiver.Run(ctx); err != nil || archived != 3 {
+		t.Fatalf("Run() = %d, %v, want 3 statuses", archived, err)
+	}
+	if through, _ := archiver.ArchivedThrough(); !through.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("ArchivedThrough() = %v, want %v", through, day.AddDate(0, 0, 1))
+	}
+	if _, err := archiver.Manifest(ctx, day.AddDate(0, 0, 1)); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Manifest() of today error = %v, want %v", err, ErrObjectNotFound)
+	}
+
+	manifest, err := archiver.Manifest(ctx, day)
+	if err != nil {
+		t.Fatalf("Manifest() error = %v", err)
+	}
+	if manifest.Day != "2026-03-01" || manifest.Statuses != 3 || manifest.Sessions != 2 || len(manifest.Agents) != 1 || !manifest.LastAt.Equal(day.Add(3*time.Hour)) {
+		t.Errorf("Manifest() = %+v, want 3 statuses of 2 sessions", manifest)
+	}
+
+	statuses, err := archiver.Statuses(ctx, day, "agent-1", "build")
+	if err != nil || len(statuses) != 2 || statuses[1].Message != "done" {
+		t.Fatalf("Statuses() = %+v, %v, want the 2 build statuses oldest first", statuses, err)
+	}
+
+	fake.Advance(24 * time.Hour)
+	if archived, err := archiver.Run(ctx); err != nil || archived != 1 {
+		t.Errorf("Run() on the next day = %d, %v, want 1 status", archived, err)
+	}
+
+	// A tampered object is refused
+	bucket.Put(ctx, objectKey(day), []byte("tampered"), "application/gzip")
+	if _, err := archiver.Statuses(ctx, day, "", ""); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Statuses() of a tampered object error = %v, want %v", err, ErrChecksumMismatch)
+	}
+}
+
+func TestArchiver_Restore(t *testing.T) {
+	source := store.NewMemoryStore()
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	seedStore(t, source, day)
+
+	bucket, _ := OpenBucket(BucketConfig{URL: "file://" + t.TempDir()})
+	ctx := context.Background()
+	if _, err := New(source, bucket).ArchiveDay(ctx, day); err != nil {
+		t.Fatalf("ArchiveDay() error = %v", err)
+	}
+
+	// The history was pruned and the deploy session deleted since
+	st := store.NewMemoryStore()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Registered: day, LastSeen: day})
+	st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "build", Created: day, LastUpdated: day})
+	st.AddStatus(&models.AgentStatus{AgentID: "agent-1", SessionTopic: "build", Status: "running", Timestamp: day.Add(time.Hour), Revision: 1})
+
+	archiver := New(st, bucket)
+	result, err := archiver.Restore(ctx, day, "", "")
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if *result != (RestoreResult{Restored: 1, Present: 1, Orphaned: 1}) {
+		t.Errorf("Restore() = %+v, want 1 restored, 1 present and 1 orphaned", result)
+	}
+	if history, _ := st.GetStatusHistory("agent-1", "build"); len(history) != 2 || history[0].Message != "done" {
+		t.Errorf("history after Restore() = %+v, want the restored success", history)
+	}
+
+	if result, _ := archiver.Restore(ctx, day, "agent-1", "build"); *result != (RestoreResult{Present: 2}) {
+		t.Errorf("Restore() again = %+v, want both statuses present", result)
+	}
+}
+
+func TestArchiver_EncryptedDays(t *testing.T) {
+	st := store.NewMemoryStore()
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	seedStore(t, st, day)
+
+	bucket, err := OpenBucket(BucketConfig{URL: "file://" + t.TempDir()})
+	if err != nil {
+		t.Fatalf("OpenBucket() error = %v", err)
+	}
+	archiver := New(st, bucket)
+	archiver.SetClock(clock.NewFake(day.Add(72 * time.Hour)))
+	ctx := context.Background()
+	if err := archiver.SetPassphrase("short"); err == nil {
+		t.Error("SetPassphrase() of a short passphrase succeeded, want an error")
+	}
+
+	// The first day is archived in the clear, the second encrypted
+	if _, err := archiver.ArchiveDay(ctx, day); err != nil {
+		t.Fatalf("ArchiveDay() error = %v", err)
+	}
+	if err := archiver.SetPassphrase("correct horse battery"); err != nil {
+		t.Fatalf("SetPassphrase() error = %v", err)
+	}
+	manifest, err := archiver.ArchiveDay(ctx, day.AddDate(0, 0, 1))
+	if err != nil || manifest.Encryption != encryption.AlgorithmPassphrase {
+		t.Fatalf("ArchiveDay() = %+v, %v, want an encrypted day", manifest, err)
+	}
+	object, _ := bucket.Get(ctx, manifest.Object)
+	if _, _, err := encryption.OpenExport(object, nil, "correct horse battery"); err != nil {
+		t.Errorf("OpenExport() of the archived object error = %v", err)
+	}
+
+	for _, d := range []time.Time{day, day.AddDate(0, 0, 1)} {
+		if statuses, err := archiver.Statuses(ctx, d, "", ""); err != nil || len(statuses) == 0 {
+			t.Errorf("Statuses(%s) = %d, %v, want the day's statuses", d.Format(time.DateOnly), len(statuses), err)
+		}
+	}
+
+	// Without the passphrase the encrypted day cannot be read
+	archiver.SetPassphrase("")
+	if _, err := archiver.Statuses(ctx, day.AddDate(0, 0, 1), "", ""); err == nil {
+		t.Error("Statuses() of an encrypted day without the passphrase succeeded, want an error")
+	}
+}
@@ -0,0 +1,105 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrObjectNotFound is returned when a bucket has no object under a key
+var ErrObjectNotFound = errors.New("archive object not found")
+
+// Bucket stores archive objects under slash-separated keys
+type Bucket interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns ErrObjectNotFound if the key holds no object
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// BucketConfig locates an archive bucket and holds its credentials
+type BucketConfig struct {
+	URL             string // s3://bucket/prefix, gs://bucket/prefix or file:///directory
+	Endpoint        string // S3-compatible endpoint; empty uses AWS S3 in Region for s3:// and Cloud Storage for gs://
+	Region          string
+	AccessKeyID     string // For gs:// an HMAC key of a service account
+	SecretAccessKey string
+}
+
+// OpenBucket returns the bucket cfg.URL names
+// Cloud Storage is reached through its S3-compatible XML API, so both s3:// and gs:// sign requests with
+// AWS Signature Version 4.
+func OpenBucket(cfg BucketConfig) (Bucket, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, errors.New("archive URL file:// needs a directory path")
+		}
+		return &dirBucket{dir: filepath.FromSlash(u.Path)}, nil
+	case "s3", "gs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("archive URL %s:// needs a bucket name", u.Scheme)
+		}
+		if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+			return nil, errors.New("archive access key ID and secret access key are required")
+		}
+		region := cfg.Region
+		endpoint := cfg.Endpoint
+		if u.Scheme == "gs" {
+			if region == "" {
+				region = "auto"
+			}
+			if endpoint == "" {
+				endpoint = "https://storage.googleapis.com"
+			}
+		} else {
+			if region == "" {
+				region = "us-east-1"
+			}
+			if endpoint == "" {
+				endpoint = "https://s3." + region + ".amazonaws.com"
+			}
+		}
+		return newS3Bucket(endpoint, u.Host, strings.Trim(u.Path, "/"), region, cfg.AccessKeyID, cfg.SecretAccessKey), nil
+	default:
+		return nil, errors.New("archive URL must start with s3://, gs:// or file://")
+	}
+}
+
+// dirBucket keeps objects as files under a directory, for development and on-premises volumes
+type dirBucket struct {
+	dir string
+}
+
+func (b *dirBucket) path(key string) string {
+	return filepath.Join(b.dir, filepath.FromSlash(key))
+}
+
+func (b *dirBucket) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path := b.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	// Written to a temporary file first, so readers never see a partial object
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (b *dirBucket) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(b.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
@@ -0,0 +1,159 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxObjectSize bounds the archive objects read back, guarding against a misconfigured bucket
+const maxObjectSize = 512 << 20
+
+// s3Bucket talks to an S3-compatible bucket with path-style URLs and Signature Version 4
+type s3Bucket struct {
+	endpoint        string
+	bucket          string
+	prefix          string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+	now             func() time.Time
+}
+
+func newS3Bucket(endpoint, bucket, prefix, region, accessKeyID, secretAccessKey string) *s3Bucket {
+	return &s3Bucket{
+		endpoint:        strings.TrimRight(endpoint, "/"),
+		bucket:          bucket,
+		prefix:          prefix,
+		region:          region,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		client:          &http.Client{Timeout: time.Minute},
+		now:             time.Now,
+	}
+}
+
+func (b *s3Bucket) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := b.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("put", key, resp)
+	}
+	return nil
+}
+
+func (b *s3Bucket) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(io.LimitReader(resp.Body, maxObjectSize))
+	case http.StatusNotFound:
+		return nil, ErrObjectNotFound
+	default:
+		return nil, responseError("get", key, resp)
+	}
+}
+
+// responseError describes a failed request with the start of the error document the service returned
+func responseError(op, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("failed to %s archive object %s: %s: %s", op, key, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// do sends a signed request for the object under key
+func (b *s3Bucket) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	path := "/" + b.bucket + "/" + key
+	if b.prefix != "" {
+		path = "/" + b.bucket + "/" + b.prefix + "/" + key
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.endpoint+uriEncode(path), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	b.sign(req, body)
+	return b.client.Do(req)
+}
+
+// sign adds the Signature Version 4 headers of a request without query parameters
+func (b *s3Bucket) sign(req *http.Request, body []byte) {
+	now := b.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		signedHeaders = "content-type;" + signedHeaders
+		canonicalHeaders = "content-type:" + contentType + "\n" + canonicalHeaders
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"", // No query string
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + b.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+b.secretAccessKey), date)
+	for _, part := range []string{b.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent-encodes every byte of a path but slashes and the unreserved characters,
+// as Signature Version 4 requires
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
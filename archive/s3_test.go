@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestS3Bucket(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string][]byte)
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.EscapedPath()] = body
+		case http.MethodGet:
+			body, ok := objects[r.URL.EscapedPath()]
+			if !ok {
+				http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	bucket, err := OpenBucket(BucketConfig{URL: "s3://audit/kubeagents/", Endpoint: server.URL, Region: "eu-west-1", AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatalf("OpenBucket() error = %v", err)
+	}
+	bucket.(*s3Bucket).now = func() time.Time { return time.Date(2026, 3, 2, 1, 2, 3, 0, time.UTC) }
+
+	ctx := context.Background()
+	if err := bucket.Put(ctx, "statuses/2026/03/01 a.json", []byte(`{"day":"2026-03-01"}`), "application/json"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, ok := objects["/audit/kubeagents/statuses/2026/03/01%20a.json"]; !ok {
+		t.Errorf("objects = %v, want the key under the bucket and prefix", objects)
+	}
+	if data, err := bucket.Get(ctx, "statuses/2026/03/01 a.json"); err != nil || string(data) != `{"day":"2026-03-01"}` {
+		t.Errorf("Get() = %s, %v, want the object", data, err)
+	}
+	if _, err := bucket.Get(ctx, "missing"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Get() of a missing key error = %v, want %v", err, ErrObjectNotFound)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=AKID/20260302/eu-west-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(authorizations[0], want) {
+		t.Errorf("Authorization = %q, want prefix %q", authorizations[0], want)
+	}
+}
+
+func TestOpenBucket(t *testing.T) {
+	for _, cfg := range []BucketConfig{
+		{URL: "ftp://bucket"},
+		{URL: "s3://bucket/prefix"},
+		{URL: "gs:///prefix", AccessKeyID: "id", SecretAccessKey: "secret"},
+	} {
+		if _, err := OpenBucket(cfg); err == nil {
+			t.Errorf("OpenBucket(%q) error = nil, want error", cfg.URL)
+		}
+	}
+
+	bucket, err := OpenBucket(BucketConfig{URL: "gs://audit", AccessKeyID: "id", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatalf("OpenBucket(gs://) error = %v", err)
+	}
+	if b := bucket.(*s3Bucket); b.endpoint != "https://storage.googleapis.com" || b.region != "auto" {
+		t.Errorf("OpenBucket(gs://) = %s in %s, want Cloud Storage", b.endpoint, b.region)
+	}
+}
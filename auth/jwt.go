@@ -1,10 +1,15 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
 )
 
 // AccessTokenClaims represents the claims in an access token
@@ -26,15 +31,52 @@ type JWTService struct {
 	secret             []byte
 	accessTokenExpiry  time.Duration
 	refreshTokenExpiry time.Duration
+	parser             *jwt.Parser
+	validated          *tokenCache
+	refreshHashKey     []byte
+	clock              clock.Clock
 }
 
 // NewJWTService creates a new JWT service
 func NewJWTService(secret string, accessExpiry, refreshExpiry time.Duration) *JWTService {
-	return &JWTService{
+	s := &JWTService{
 		secret:             []byte(secret),
 		accessTokenExpiry:  accessExpiry,
 		refreshTokenExpiry: refreshExpiry,
+		validated:          newTokenCache(),
+		refreshHashKey:     deriveKey(secret, "refresh-token-hash"),
+		clock:              clock.Real,
 	}
+	// Parser is safe for concurrent use; building it once keeps validation allocation-light
+	s.parser = jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithTimeFunc(func() time.Time { return s.clock.Now() }),
+	)
+	return s
+}
+
+// SetClock replaces the clock that stamps issued tokens and decides whether tokens have expired
+func (s *JWTService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// deriveKey derives a purpose-specific key from the signing secret so the secret itself is only used for JWTs
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// HashRefreshToken computes the deterministic HMAC-SHA256 hash under which a refresh token is stored and looked up
+func (s *JWTService) HashRefreshToken(token string) string {
+	mac := hmac.New(sha256.New, s.refreshHashKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// keyFunc returns the HMAC secret; the parser has already restricted the signing method to HS256
+func (s *JWTService) keyFunc(*jwt.Token) (interface{}, error) {
+	return s.secret, nil
 }
 
 // GenerateAccessToken generates a new access token for a user
@@ -46,7 +88,7 @@ func (s *JWTService) GenerateAccessToken(userID, email string) (string, error) {
 		return "", errors.New("email is required")
 	}
 
-	now := time.Now()
+	now := s.clock.Now()
 	claims := AccessTokenClaims{
 		UserID: userID,
 		Email:  email,
@@ -69,11 +111,13 @@ func (s *JWTService) GenerateRefreshToken(userID string) (string, error) {
 		return "", errors.New("user_id is required")
 	}
 
-	now := time.Now()
+	now := s.clock.Now()
 	claims := RefreshTokenClaims{
 		UserID:    userID,
 		TokenType: "refresh",
 		RegisteredClaims: jwt.RegisteredClaims{
+			// Unique ID so tokens issued in the same second still hash differently
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshTokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -87,27 +131,27 @@ func (s *JWTService) GenerateRefreshToken(userID string) (string, error) {
 }
 
 // ValidateAccessToken validates an access token and returns the claims
+// Validated tokens are cached until they expire; callers must treat the returned claims as read-only.
 func (s *JWTService) ValidateAccessToken(tokenString string) (*AccessTokenClaims, error) {
 	if tokenString == "" {
 		return nil, errors.New("token is required")
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &AccessTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return s.secret, nil
-	})
+	if claims, ok := s.validated.get(tokenString, s.clock.Now()); ok {
+		return claims, nil
+	}
 
+	claims := &AccessTokenClaims{}
+	token, err := s.parser.ParseWithClaims(tokenString, claims, s.keyFunc)
 	if err != nil {
 		return nil, err
 	}
 
-	claims, ok := token.Claims.(*AccessTokenClaims)
-	if !ok || !token.Valid {
+	if !token.Valid {
 		return nil, errors.New("invalid token")
 	}
 
+	s.validated.put(tokenString, claims)
 	return claims, nil
 }
 
@@ -117,19 +161,13 @@ func (s *JWTService) ValidateRefreshToken(tokenString string) (*RefreshTokenClai
 		return nil, errors.New("token is required")
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &RefreshTokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return s.secret, nil
-	})
-
+	claims := &RefreshTokenClaims{}
+	token, err := s.parser.ParseWithClaims(tokenString, claims, s.keyFunc)
 	if err != nil {
 		return nil, err
 	}
 
-	claims, ok := token.Claims.(*RefreshTokenClaims)
-	if !ok || !token.Valid {
+	if !token.Valid {
 		return nil, errors.New("invalid token")
 	}
 
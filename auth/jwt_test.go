@@ -3,6 +3,9 @@ package auth
 import (
 	"testing"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/kubeagents/kubeagents/clock"
 )
 
 func TestJWTService_GenerateAccessToken(t *testing.T) {
@@ -211,21 +214,34 @@ func TestJWTService_ValidateRefreshToken(t *testing.T) {
 }
 
 func TestJWTService_ExpiredToken(t *testing.T) {
-	// Create service with very short expiry
-	svc := NewJWTService("test-secret-key-at-least-32-chars", 1*time.Millisecond, 1*time.Millisecond)
+	svc := NewJWTService("test-secret-key-at-least-32-chars", time.Minute, time.Hour)
+	clk := clock.NewFake(time.Now())
+	svc.SetClock(clk)
 
 	token, err := svc.GenerateAccessToken("user-123", "test@example.com")
 	if err != nil {
 		t.Fatalf("failed to generate token: %v", err)
 	}
+	refresh, err := svc.GenerateRefreshToken("user-123")
+	if err != nil {
+		t.Fatalf("failed to generate refresh token: %v", err)
+	}
+	if _, err := svc.ValidateAccessToken(token); err != nil {
+		t.Fatalf("ValidateAccessToken() before expiry error = %v", err)
+	}
 
-	// Wait for token to expire
-	time.Sleep(10 * time.Millisecond)
-
-	_, err = svc.ValidateAccessToken(token)
-	if err == nil {
+	// Expiry follows the service's clock, for both issuing and validating
+	clk.Advance(time.Minute + time.Second)
+	if _, err := svc.ValidateAccessToken(token); err == nil {
 		t.Error("expected error for expired token")
 	}
+	if _, err := svc.ValidateRefreshToken(refresh); err != nil {
+		t.Errorf("ValidateRefreshToken() before its expiry error = %v", err)
+	}
+	clk.Advance(time.Hour)
+	if _, err := svc.ValidateRefreshToken(refresh); err == nil {
+		t.Error("expected error for expired refresh token")
+	}
 }
 
 func TestJWTService_WrongSecret(t *testing.T) {
@@ -243,3 +259,81 @@ func TestJWTService_WrongSecret(t *testing.T) {
 		t.Error("expected error for wrong secret")
 	}
 }
+
+func TestJWTService_ValidatedTokenCacheExpiry(t *testing.T) {
+	cache := newTokenCache()
+	now := time.Now()
+	claims := &AccessTokenClaims{
+		UserID: "user-123",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	}
+
+	cache.put("token", claims)
+	if got, ok := cache.get("token", now); !ok || got.UserID != "user-123" {
+		t.Fatalf("get() = %v, %v, want cached claims", got, ok)
+	}
+	if _, ok := cache.get("token", now.Add(time.Minute)); ok {
+		t.Error("get() returned claims for an expired token")
+	}
+	if _, ok := cache.get("token", now); ok {
+		t.Error("get() kept an expired entry")
+	}
+}
+
+func TestJWTService_HashRefreshToken(t *testing.T) {
+	svc1 := NewJWTService("secret-key-1-at-least-32-chars!!", 15*time.Minute, 7*24*time.Hour)
+	svc2 := NewJWTService("secret-key-2-different-secret!!!", 15*time.Minute, 7*24*time.Hour)
+
+	token, err := svc1.GenerateRefreshToken("user-123")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	// Lookup requires recomputing the same hash
+	if svc1.HashRefreshToken(token) != svc1.HashRefreshToken(token) {
+		t.Error("HashRefreshToken() is not deterministic")
+	}
+	if len(svc1.HashRefreshToken(token)) != 64 {
+		t.Errorf("HashRefreshToken() length = %d, want 64", len(svc1.HashRefreshToken(token)))
+	}
+	if svc1.HashRefreshToken(token) == svc2.HashRefreshToken(token) {
+		t.Error("HashRefreshToken() does not depend on the secret")
+	}
+}
+
+func BenchmarkJWTService_ValidateAccessToken(b *testing.B) {
+	svc := NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	token, err := svc.GenerateAccessToken("user-123", "test@example.com")
+	if err != nil {
+		b.Fatalf("failed to generate token: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := svc.ValidateAccessToken(token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJWTService_ValidateAccessTokenUncached(b *testing.B) {
+	svc := NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	token, err := svc.GenerateAccessToken("user-123", "test@example.com")
+	if err != nil {
+		b.Fatalf("failed to generate token: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		svc.validated = newTokenCache()
+		b.StartTimer()
+		if _, err := svc.ValidateAccessToken(token); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
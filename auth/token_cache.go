@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCachedTokens bounds the validated token cache; it is cleared when full
+const maxCachedTokens = 10000
+
+// tokenCache remembers validated access tokens until they expire
+// Entries are keyed by the full signed token, so a hit implies the signature was already verified.
+type tokenCache struct {
+	mu      sync.RWMutex
+	entries map[string]*AccessTokenClaims
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		entries: make(map[string]*AccessTokenClaims),
+	}
+}
+
+// get returns the cached claims for a token that has not yet expired
+func (c *tokenCache) get(token string, now time.Time) (*AccessTokenClaims, bool) {
+	c.mu.RLock()
+	claims, ok := c.entries[token]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if claims.ExpiresAt == nil || !now.Before(claims.ExpiresAt.Time) {
+		c.mu.Lock()
+		delete(c.entries, token)
+		c.mu.Unlock()
+		return nil, false
+	}
+	return claims, true
+}
+
+// put caches validated claims
+func (c *tokenCache) put(token string, claims *AccessTokenClaims) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Clearing is cheaper than tracking recency and only costs a re-validation per active token
+	if len(c.entries) >= maxCachedTokens {
+		c.entries = make(map[string]*AccessTokenClaims)
+	}
+	c.entries[token] = claims
+}
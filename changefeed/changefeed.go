@@ -0,0 +1,181 @@
+// Package changefeed pushes change hints between replicas sharing a PostgreSQL database
+// Live streams are fed by in-process brokers, so without a push a dashboard connected to one replica
+// would not see statuses or inbox items recorded by another until it read them again. Hints are best
+// effort: those sent while a replica's listener reconnects, and those too large for a notification,
+// are lost, and clients catch up on their next read.
+package changefeed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel is the PostgreSQL notification channel hints are published on
+const Channel = "kubeagents_changes"
+
+// Kinds of hints
+const (
+	KindAgentEvent = "agent_event" // An events.Event published to an agent's subscribers
+	KindInboxItem  = "inbox_item"  // A models.InboxItem recorded for a user
+)
+
+const (
+	// MaxPayloadBytes is the largest notification payload PostgreSQL accepts, less some headroom
+	MaxPayloadBytes = 7900
+	// queueSize is how many hints may wait to be sent before new ones are dropped
+	queueSize = 256
+	// sendTimeout bounds sending one hint
+	sendTimeout = 5 * time.Second
+	// reconnectDelay is how long the listener waits before reconnecting after a failure
+	reconnectDelay = 5 * time.Second
+)
+
+// hint is the payload of a notification
+type hint struct {
+	Replica string          `json:"replica"` // Sending replica, which ignores its own hints
+	Kind    string          `json:"kind"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Feed sends hints to the other replicas and delivers theirs to subscribers
+type Feed struct {
+	pool    *pgxpool.Pool
+	replica string
+	pending chan []byte
+
+	mu          sync.RWMutex
+	subscribers map[string][]func(data json.RawMessage)
+}
+
+// New creates a feed on the database behind pool; call Run to send and receive hints
+func New(pool *pgxpool.Pool) *Feed {
+	return &Feed{
+		pool:        pool,
+		replica:     uuid.New().String(),
+		pending:     make(chan []byte, queueSize),
+		subscribers: make(map[string][]func(data json.RawMessage)),
+	}
+}
+
+// Subscribe registers fn to be called with the data of every hint of a kind sent by another replica
+func (f *Feed) Subscribe(kind string, fn func(data json.RawMessage)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers[kind] = append(f.subscribers[kind], fn)
+}
+
+// Send queues a hint for the other replicas without waiting for the database
+// Hints are dropped when the queue is full or v is too large for a notification.
+func (f *Feed) Send(kind string, v any) {
+	payload, err := f.encode(kind, v)
+	if err != nil {
+		log.Printf("Dropping %s change hint: %v", kind, err)
+		return
+	}
+	select {
+	case f.pending <- payload:
+	default:
+		log.Printf("Dropping %s change hint: queue is full", kind)
+	}
+}
+
+// encode builds the notification payload of a hint
+func (f *Feed) encode(kind string, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(&hint{Replica: f.replica, Kind: kind, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > MaxPayloadBytes {
+		return nil, fmt.Errorf("payload of %d bytes exceeds %d", len(payload), MaxPayloadBytes)
+	}
+	return payload, nil
+}
+
+// deliver calls the subscribers of a notification's kind, unless this replica sent it
+func (f *Feed) deliver(payload string) {
+	var h hint
+	if err := json.Unmarshal([]byte(payload), &h); err != nil {
+		log.Printf("Ignoring malformed change hint: %v", err)
+		return
+	}
+	if h.Replica == f.replica {
+		return
+	}
+
+	f.mu.RLock()
+	subscribers := f.subscribers[h.Kind]
+	f.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(h.Data)
+	}
+}
+
+// Run sends queued hints and listens for those of other replicas until ctx is done, reconnecting after failures
+func (f *Feed) Run(ctx context.Context) {
+	go f.sendLoop(ctx)
+
+	for {
+		err := f.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Change feed listener failed, reconnecting in %v: %v", reconnectDelay, err)
+
+		select {
+		case <-time.After(reconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sendLoop publishes queued hints until ctx is done
+func (f *Feed) sendLoop(ctx context.Context) {
+	for {
+		select {
+		case payload := <-f.pending:
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			if _, err := f.pool.Exec(sendCtx, "SELECT pg_notify($1, $2)", Channel, string(payload)); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to send change hint: %v", err)
+			}
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// listen holds a dedicated connection listening on Channel and delivers its notifications
+func (f *Feed) listen(ctx context.Context) error {
+	pooled, err := f.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection keeps listening, so it never returns to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		f.deliver(notification.Payload)
+	}
+}
@@ -0,0 +1,128 @@
+package changefeed
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/inbox"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// forward hands the hints queued on from to to, as the database would
+func forward(t *testing.T, from, to *Feed) {
+	t.Helper()
+	for {
+		select {
+		case payload := <-from.pending:
+			from.deliver(string(payload)) // a replica hears its own notifications too
+			to.deliver(string(payload))
+		default:
+			return
+		}
+	}
+}
+
+func TestFeed_RelaysAgentEvents(t *testing.T) {
+	a, b := New(nil), New(nil)
+	brokerA, brokerB := events.NewBroker(), events.NewBroker()
+	a.RelayAgentEvents(brokerA)
+	b.RelayAgentEvents(brokerB)
+
+	local, unsubscribeLocal := brokerA.Subscribe("agent-001")
+	defer unsubscribeLocal()
+	remote, unsubscribeRemote := brokerB.Subscribe("agent-001")
+	defer unsubscribeRemote()
+
+	brokerA.Publish(&events.Event{Type: events.TypeStatus, AgentID: "agent-001", SessionTopic: "task-001", Status: "failed", Revision: 2})
+	forward(t, a, b)
+
+	if len(local) != 1 {
+		t.Errorf("publishing replica delivered %d events, want 1", len(local))
+	}
+	select {
+	case event := <-remote:
+		if event.SessionTopic != "task-001" || event.Status != "failed" || event.Revision != 2 {
+			t.Errorf("relayed event = %+v, want the failed status of task-001", event)
+		}
+	default:
+		t.Fatal("other replica delivered no event")
+	}
+	if len(b.pending) != 0 {
+		t.Errorf("other replica queued %d hints, want relayed events not sent on again", len(b.pending))
+	}
+}
+
+func TestFeed_RelaysInboxItems(t *testing.T) {
+	st := store.NewMemoryStore()
+	a, b := New(nil), New(nil)
+	inboxA, inboxB := inbox.New(st, 0), inbox.New(st, 0)
+	a.RelayInbox(inboxA)
+	b.RelayInbox(inboxB)
+
+	items, unsubscribe := inboxB.Subscribe("user-001")
+	defer unsubscribe()
+
+	inboxA.Publish(&models.InboxItem{UserID: "user-001", Kind: models.InboxKindFailure, AgentID: "agent-001", Message: "Session failed", DedupeKey: "failure|1"})
+	forward(t, a, b)
+
+	select {
+	case item := <-items:
+		if item.Message != "Session failed" || item.ID == "" {
+			t.Errorf("relayed item = %+v, want the stored failure", item)
+		}
+	default:
+		t.Fatal("other replica delivered no item")
+	}
+	if stored, _ := st.CountUnreadInboxItems("user-001"); stored != 1 {
+		t.Errorf("unread items = %d, want the item stored once", stored)
+	}
+}
+
+func TestFeed_DropsOversizedHints(t *testing.T) {
+	f := New(nil)
+	f.Send(KindAgentEvent, &events.Event{AgentID: "agent-001", Message: strings.Repeat("x", MaxPayloadBytes)})
+	if len(f.pending) != 0 {
+		t.Errorf("queued %d hints, want the oversized one dropped", len(f.pending))
+	}
+}
+
+// The Postgres feed is exercised against the database the store tests use, when one is configured
+func TestFeed_DeliversAcrossReplicas(t *testing.T) {
+	dsn := os.Getenv("KUBEAGENTS_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("KUBEAGENTS_TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New() error = %v", err)
+	}
+	defer pool.Close()
+
+	publisher, listener := New(pool), New(pool)
+	broker := events.NewBroker()
+	listener.RelayAgentEvents(broker)
+	received, unsubscribe := broker.Subscribe("agent-001")
+	defer unsubscribe()
+	go publisher.Run(ctx)
+	go listener.Run(ctx)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		// The listener may not be listening yet, so send until it hears one
+		publisher.Send(KindAgentEvent, &events.Event{Type: events.TypeStatus, AgentID: "agent-001", Status: "running"})
+		time.Sleep(100 * time.Millisecond)
+		if len(received) > 0 {
+			return
+		}
+	}
+	t.Fatal("listener received no event")
+}
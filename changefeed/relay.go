@@ -0,0 +1,44 @@
+package changefeed
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/inbox"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// RelayAgentEvents sends the events published to b to the other replicas and delivers theirs to b's subscribers
+func (f *Feed) RelayAgentEvents(b *events.Broker) {
+	b.SetRelay(func(event *events.Event) { f.Send(KindAgentEvent, event) })
+	f.Subscribe(KindAgentEvent, func(data json.RawMessage) {
+		var event events.Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("Ignoring malformed agent event hint: %v", err)
+			return
+		}
+		b.Deliver(&event)
+	})
+}
+
+// inboxHint carries an inbox item with its owner, which the item's JSON leaves out
+type inboxHint struct {
+	UserID string            `json:"user_id"`
+	Item   *models.InboxItem `json:"item"`
+}
+
+// RelayInbox sends the items recorded in in to the other replicas and delivers theirs to in's subscribers
+// Relayed items are only streamed, since the replica that recorded them stored them already.
+func (f *Feed) RelayInbox(in *inbox.Inbox) {
+	in.SetRelay(func(item *models.InboxItem) { f.Send(KindInboxItem, &inboxHint{UserID: item.UserID, Item: item}) })
+	f.Subscribe(KindInboxItem, func(data json.RawMessage) {
+		var hint inboxHint
+		if err := json.Unmarshal(data, &hint); err != nil || hint.Item == nil {
+			log.Printf("Ignoring malformed inbox item hint: %v", err)
+			return
+		}
+		hint.Item.UserID = hint.UserID
+		in.Deliver(hint.Item)
+	})
+}
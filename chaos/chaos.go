@@ -0,0 +1,152 @@
+// Package chaos injects latency, errors and partial failures into store calls and outbound notifications,
+// so retries, circuit breakers and dead-letter handling can be verified in staging
+// The faults only take effect in binaries built with -tags chaos; other builds compile the wrappers out.
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// The targets faults can be injected into
+const (
+	TargetStoreRead  = "store.read"
+	TargetStoreWrite = "store.write"
+	TargetNotifier   = "notifier"
+)
+
+// MaxLatency bounds the latency a fault may add to each call
+const MaxLatency = time.Minute
+
+// ErrInjected is the error of an injected failure; store calls report it as store.ErrUnavailable
+var ErrInjected = errors.New("chaos: injected fault")
+
+// storeError is what a store call fails with, so handlers treat it like an unreachable database
+var storeError = &store.Error{Kind: store.KindUnavailable, Err: ErrInjected}
+
+// Fault describes what goes wrong with the calls of one target
+// ErrorRate and PartialRate are shares of calls between 0 and 1 and together at most 1.
+type Fault struct {
+	Target    string  `json:"target"`
+	LatencyMS int     `json:"latency_ms,omitempty"` // Added before every call
+	ErrorRate float64 `json:"error_rate,omitempty"` // Calls failing without taking effect
+	// Calls that take effect but report a failure: store writes that are applied, and notifications
+	// that are delivered but answered with 503
+	PartialRate float64 `json:"partial_rate,omitempty"`
+}
+
+// Validate checks the target and the rates
+func (f Fault) Validate() error {
+	switch f.Target {
+	case TargetStoreRead:
+		if f.PartialRate != 0 {
+			return fmt.Errorf("%s: partial_rate only applies to writes and notifications", f.Target)
+		}
+	case TargetStoreWrite, TargetNotifier:
+	default:
+		return fmt.Errorf("unknown target %q: must be %s, %s or %s", f.Target, TargetStoreRead, TargetStoreWrite, TargetNotifier)
+	}
+	if f.LatencyMS < 0 || time.Duration(f.LatencyMS)*time.Millisecond > MaxLatency {
+		return fmt.Errorf("%s: latency_ms must be between 0 and %d", f.Target, MaxLatency.Milliseconds())
+	}
+	if f.ErrorRate < 0 || f.PartialRate < 0 || f.ErrorRate+f.PartialRate > 1 {
+		return fmt.Errorf("%s: error_rate and partial_rate must be at least 0 and add up to at most 1", f.Target)
+	}
+	return nil
+}
+
+// outcome is what happens to one call
+type outcome int
+
+const (
+	pass outcome = iota
+	fail
+	partial
+)
+
+// Injector holds the active faults and decides the outcome of each call
+type Injector struct {
+	mu     sync.RWMutex
+	faults map[string]Fault
+	random func() float64
+	sleep  func(time.Duration)
+}
+
+// NewInjector creates an injector without faults
+func NewInjector() *Injector {
+	return &Injector{
+		faults: make(map[string]Fault),
+		random: rand.Float64,
+		sleep:  time.Sleep,
+	}
+}
+
+// Faults returns the active faults ordered by target
+func (i *Injector) Faults() []Fault {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	faults := make([]Fault, 0, len(i.faults))
+	for _, fault := range i.faults {
+		faults = append(faults, fault)
+	}
+	sort.Slice(faults, func(a, b int) bool { return faults[a].Target < faults[b].Target })
+	return faults
+}
+
+// Set replaces the active faults, leaving them unchanged if any is invalid or a target is repeated
+func (i *Injector) Set(faults []Fault) error {
+	next := make(map[string]Fault, len(faults))
+	for _, fault := range faults {
+		if err := fault.Validate(); err != nil {
+			return err
+		}
+		if _, ok := next[fault.Target]; ok {
+			return fmt.Errorf("%s: target repeated", fault.Target)
+		}
+		next[fault.Target] = fault
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = next
+	return nil
+}
+
+// Clear removes every fault
+func (i *Injector) Clear() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = make(map[string]Fault)
+}
+
+// inject adds the target's latency and returns the outcome of the call
+func (i *Injector) inject(target string) outcome {
+	i.mu.RLock()
+	fault, ok := i.faults[target]
+	i.mu.RUnlock()
+	if !ok {
+		return pass
+	}
+
+	if fault.LatencyMS > 0 {
+		i.sleep(time.Duration(fault.LatencyMS) * time.Millisecond)
+	}
+	if fault.ErrorRate == 0 && fault.PartialRate == 0 {
+		return pass
+	}
+	switch roll := i.random(); {
+	case roll < fault.ErrorRate:
+		return fail
+	case roll < fault.ErrorRate+fault.PartialRate:
+		return partial
+	default:
+		return pass
+	}
+}
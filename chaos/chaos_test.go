@@ -0,0 +1,91 @@
+package chaos
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestInjector creates an injector whose rolls come from rolls in turn and whose latency is recorded
+func newTestInjector(rolls ...float64) (*Injector, *[]time.Duration) {
+	injector := NewInjector()
+	slept := make([]time.Duration, 0)
+	injector.sleep = func(d time.Duration) { slept = append(slept, d) }
+	injector.random = func() float64 {
+		roll := rolls[0]
+		rolls = rolls[1:]
+		return roll
+	}
+	return injector, &slept
+}
+
+func TestFault_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   Fault
+		wantErr bool
+	}{
+		{"latency only", Fault{Target: TargetStoreRead, LatencyMS: 200}, false},
+		{"errors and partial failures", Fault{Target: TargetNotifier, ErrorRate: 0.3, PartialRate: 0.7}, false},
+		{"unknown target", Fault{Target: "email"}, true},
+		{"negative latency", Fault{Target: TargetStoreWrite, LatencyMS: -1}, true},
+		{"latency over a minute", Fault{Target: TargetStoreWrite, LatencyMS: 60001}, true},
+		{"rates over 1", Fault{Target: TargetStoreWrite, ErrorRate: 0.6, PartialRate: 0.5}, true},
+		{"negative rate", Fault{Target: TargetNotifier, ErrorRate: -0.1}, true},
+		{"partial read", Fault{Target: TargetStoreRead, PartialRate: 0.1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fault.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInjector_SetAndClear(t *testing.T) {
+	injector := NewInjector()
+	faults := []Fault{{Target: TargetStoreWrite, ErrorRate: 1}, {Target: TargetNotifier, LatencyMS: 50}}
+	if err := injector.Set(faults); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := injector.Faults(); len(got) != 2 || got[0].Target != TargetNotifier || got[1].Target != TargetStoreWrite {
+		t.Errorf("Faults() = %+v, want notifier then store.write", got)
+	}
+
+	// An invalid list leaves the active faults alone
+	if err := injector.Set([]Fault{{Target: TargetStoreRead}, {Target: TargetStoreRead}}); err == nil {
+		t.Error("Set() with a repeated target error = nil")
+	}
+	if got := injector.Faults(); len(got) != 2 {
+		t.Errorf("Faults() after a rejected Set = %+v, want the 2 earlier faults", got)
+	}
+
+	injector.Clear()
+	if got := injector.Faults(); len(got) != 0 {
+		t.Errorf("Faults() after Clear = %+v, want none", got)
+	}
+}
+
+func TestInjector_Inject(t *testing.T) {
+	injector, slept := newTestInjector(0.1, 0.3, 0.9)
+	if err := injector.Set([]Fault{{Target: TargetStoreWrite, LatencyMS: 20, ErrorRate: 0.2, PartialRate: 0.2}}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	for i, want := range []outcome{fail, partial, pass} {
+		if got := injector.inject(TargetStoreWrite); got != want {
+			t.Errorf("inject() call %d = %v, want %v", i+1, got, want)
+		}
+	}
+	if len(*slept) != 3 || (*slept)[0] != 20*time.Millisecond {
+		t.Errorf("slept %v, want 20ms before each of the 3 calls", *slept)
+	}
+
+	// Targets without a fault pass untouched
+	if got := injector.inject(TargetStoreRead); got != pass {
+		t.Errorf("inject(%s) = %v, want pass", TargetStoreRead, got)
+	}
+	if len(*slept) != 3 {
+		t.Errorf("slept %v for a target without a fault", *slept)
+	}
+}
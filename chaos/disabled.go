@@ -0,0 +1,23 @@
+//go:build !chaos
+
+package chaos
+
+import (
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Enabled reports whether this build injects faults
+// This build does not (build with -tags chaos to inject them).
+const Enabled = false
+
+// WrapStore returns st, since this build does not inject faults
+func WrapStore(st store.Store, injector *Injector) store.Store {
+	return st
+}
+
+// WrapTransport returns rt, since this build does not inject faults
+func WrapTransport(rt http.RoundTripper, injector *Injector) http.RoundTripper {
+	return rt
+}
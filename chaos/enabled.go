@@ -0,0 +1,6 @@
+//go:build chaos
+
+package chaos
+
+// Enabled reports whether this build injects faults
+const Enabled = true
@@ -0,0 +1,130 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Store injects the store.read and store.write faults into the calls of the status pipeline:
+// reporting statuses and reading agents, sessions and their histories
+// Other calls go straight to the wrapped store.
+type Store struct {
+	store.Store
+	injector *Injector
+}
+
+// WrapStore returns st with the injector's store faults
+func WrapStore(st store.Store, injector *Injector) store.Store {
+	return &Store{Store: st, injector: injector}
+}
+
+// read fails the call if the injector says so
+func (s *Store) read() error {
+	if s.injector.inject(TargetStoreRead) == fail {
+		return storeError
+	}
+	return nil
+}
+
+// write runs apply unless the injector fails the call, and reports a failure after a partial one
+func (s *Store) write(apply func() error) error {
+	switch s.injector.inject(TargetStoreWrite) {
+	case fail:
+		return storeError
+	case partial:
+		if err := apply(); err != nil {
+			return err
+		}
+		return storeError
+	default:
+		return apply()
+	}
+}
+
+// CreateOrUpdateAgent writes an agent unless a store.write fault fails it
+func (s *Store) CreateOrUpdateAgent(agent *models.Agent) error {
+	return s.write(func() error { return s.Store.CreateOrUpdateAgent(agent) })
+}
+
+// CreateOrUpdateSession writes a session unless a store.write fault fails it
+func (s *Store) CreateOrUpdateSession(session *models.Session) error {
+	return s.write(func() error { return s.Store.CreateOrUpdateSession(session) })
+}
+
+// AddStatus adds a status unless a store.write fault fails it
+func (s *Store) AddStatus(status *models.AgentStatus) error {
+	return s.write(func() error { return s.Store.AddStatus(status) })
+}
+
+// AddStatusWithOutbox adds a status with its side effects unless a store.write fault fails it
+func (s *Store) AddStatusWithOutbox(status *models.AgentStatus, messages []*models.OutboxMessage) error {
+	return s.write(func() error { return s.Store.AddStatusWithOutbox(status, messages) })
+}
+
+// GetAgent reads an agent unless a store.read fault fails it
+func (s *Store) GetAgent(agentID string) (*models.Agent, error) {
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	return s.Store.GetAgent(agentID)
+}
+
+// QueryAgents reads a page of agents unless a store.read fault fails it
+func (s *Store) QueryAgents(q store.Query) ([]*models.Agent, int, error) {
+	if err := s.read(); err != nil {
+		return nil, 0, err
+	}
+	return s.Store.QueryAgents(q)
+}
+
+// GetSession reads a session unless a store.read fault fails it
+func (s *Store) GetSession(agentID, sessionTopic string) (*models.Session, error) {
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	return s.Store.GetSession(agentID, sessionTopic)
+}
+
+// QuerySessions reads a page of sessions unless a store.read fault fails it
+func (s *Store) QuerySessions(q store.Query) ([]*models.Session, int, error) {
+	if err := s.read(); err != nil {
+		return nil, 0, err
+	}
+	return s.Store.QuerySessions(q)
+}
+
+// GetStatusHistory reads a session's statuses unless a store.read fault fails it
+func (s *Store) GetStatusHistory(agentID, sessionTopic string) ([]*models.AgentStatus, error) {
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	return s.Store.GetStatusHistory(agentID, sessionTopic)
+}
+
+// GetStatusHistoryPage reads a page of statuses unless a store.read fault fails it
+func (s *Store) GetStatusHistoryPage(agentID, sessionTopic string, page store.Page) ([]*models.AgentStatus, int, error) {
+	if err := s.read(); err != nil {
+		return nil, 0, err
+	}
+	return s.Store.GetStatusHistoryPage(agentID, sessionTopic, page)
+}
+
+// GetLatestStatus reads a session's latest status unless a store.read fault fails it
+func (s *Store) GetLatestStatus(agentID, sessionTopic string) (*models.AgentStatus, error) {
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+	return s.Store.GetLatestStatus(agentID, sessionTopic)
+}
+
+// GetRunProgress reads the progress of a session run unless a store.read fault fails it
+func (s *Store) GetRunProgress(agentID, sessionTopic string, revision int) (*models.AgentStatus, time.Time, error) {
+	if err := s.read(); err != nil {
+		return nil, time.Time{}, err
+	}
+	return s.Store.GetRunProgress(agentID, sessionTopic, revision)
+}
@@ -0,0 +1,90 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestStore_InjectsFaults(t *testing.T) {
+	injector, _ := newTestInjector(0.1, 0.9, 0.1)
+	inner := store.NewMemoryStore()
+	st := WrapStore(inner, injector)
+	now := time.Now()
+	if err := st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Name: "Builder", Registered: now, LastSeen: now}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() without faults error = %v", err)
+	}
+
+	if err := injector.Set([]Fault{{Target: TargetStoreWrite, PartialRate: 0.5}, {Target: TargetStoreRead, ErrorRate: 0.5}}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// A partial failure applies the write and reports the store unavailable
+	session := &models.Session{AgentID: "agent-1", SessionTopic: "task-1", Created: now, LastUpdated: now}
+	if err := st.CreateOrUpdateSession(session); !errors.Is(err, store.ErrUnavailable) {
+		t.Errorf("CreateOrUpdateSession() error = %v, want ErrUnavailable", err)
+	}
+	if _, err := inner.GetSession("agent-1", "task-1"); err != nil {
+		t.Errorf("GetSession() after a partial failure error = %v, want the session stored", err)
+	}
+
+	if _, err := st.GetAgent("agent-1"); err != nil {
+		t.Errorf("GetAgent() on a passing roll error = %v", err)
+	}
+	if _, err := st.GetAgent("agent-1"); !errors.Is(err, store.ErrUnavailable) || !errors.Is(err, ErrInjected) {
+		t.Errorf("GetAgent() on a failing roll error = %v, want ErrUnavailable wrapping ErrInjected", err)
+	}
+}
+
+func TestTransport_InjectsFaults(t *testing.T) {
+	delivered := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	injector, _ := newTestInjector(0.1, 0.5, 0.9)
+	if err := injector.Set([]Fault{{Target: TargetNotifier, ErrorRate: 0.2, PartialRate: 0.4}}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	client := &http.Client{Transport: WrapTransport(nil, injector)}
+	post := func() (*http.Response, error) {
+		return client.Post(server.URL, "application/json", strings.NewReader(`{}`))
+	}
+
+	if _, err := post(); !errors.Is(err, ErrInjected) {
+		t.Errorf("failing roll error = %v, want ErrInjected", err)
+	}
+	if delivered != 0 {
+		t.Errorf("failed request delivered %d times, want 0", delivered)
+	}
+
+	resp, err := post()
+	if err != nil {
+		t.Fatalf("partial roll error = %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || delivered != 1 {
+		t.Errorf("partial roll status = %d with %d deliveries, want 503 after delivering it", resp.StatusCode, delivered)
+	}
+
+	resp, err = post()
+	if err != nil {
+		t.Fatalf("passing roll error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || delivered != 2 {
+		t.Errorf("passing roll status = %d with %d deliveries, want 204 after delivering it", resp.StatusCode, delivered)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Transport injects the notifier faults into outbound notification requests
+type Transport struct {
+	next     http.RoundTripper
+	injector *Injector
+}
+
+// WrapTransport returns rt with the injector's notifier faults; a nil rt wraps http.DefaultTransport
+func WrapTransport(rt http.RoundTripper, injector *Injector) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &Transport{next: rt, injector: injector}
+}
+
+// RoundTrip fails the request, or delivers it and answers 503 in place of the receiver's response,
+// if the injector says so
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch t.injector.inject(TargetNotifier) {
+	case fail:
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ErrInjected
+	case partial:
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return &http.Response{
+			Status:     "503 Service Unavailable",
+			StatusCode: http.StatusServiceUnavailable,
+			Proto:      resp.Proto,
+			ProtoMajor: resp.ProtoMajor,
+			ProtoMinor: resp.ProtoMinor,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader(ErrInjected.Error())),
+			Request:    req,
+		}, nil
+	default:
+		return t.next.RoundTrip(req)
+	}
+}
@@ -0,0 +1,121 @@
+// Package clock abstracts the current time, so tests and dry runs can control it
+// instead of waiting for real time to pass
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time and schedules calls for later
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has passed, as time.AfterFunc does
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a call scheduled with AfterFunc
+type Timer interface {
+	// Stop cancels the call, reporting false when it already ran or was stopped
+	Stop() bool
+}
+
+// Sleep pauses the calling goroutine until d has passed on c
+func Sleep(c Clock, d time.Duration) {
+	done := make(chan struct{})
+	c.AfterFunc(d, func() { close(done) })
+	<-done
+}
+
+// Real is the system clock
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Fake is a clock that stands still until it is set or advanced
+// Calls scheduled with AfterFunc run once the clock is moved past their time. It is safe for concurrent use.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a call scheduled on a fake clock
+type fakeTimer struct {
+	clock *Fake
+	at    time.Time
+	f     func()
+}
+
+// NewFake returns a clock frozen at now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the clock's time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to now, running the calls that are then due
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+	f.fire()
+}
+
+// Advance moves the clock forward by d, running the calls that are then due
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	f.fire()
+}
+
+// AfterFunc schedules fn for once the clock has moved d past its current time
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	timer := &fakeTimer{clock: f, at: f.now.Add(d), f: fn}
+	f.timers = append(f.timers, timer)
+	f.fire()
+	return timer
+}
+
+// fire starts the calls that are due, each in its own goroutine; f.mu must be held
+func (f *Fake) fire() {
+	pending := f.timers[:0]
+	for _, timer := range f.timers {
+		if timer.at.After(f.now) {
+			pending = append(pending, timer)
+			continue
+		}
+		go timer.f()
+	}
+	clear(f.timers[len(pending):])
+	f.timers = pending
+}
+
+// Stop cancels the call unless it was already started
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
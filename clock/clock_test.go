@@ -0,0 +1,88 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := NewFake(start)
+
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() = %v, want %v", got, start)
+	}
+	c.Advance(90 * time.Minute)
+	if got, want := c.Now(), start.Add(90*time.Minute); !got.Equal(want) {
+		t.Errorf("Now() after Advance() = %v, want %v", got, want)
+	}
+	c.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Errorf("Now() after Set() = %v, want %v", got, start)
+	}
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	got := Real.Now()
+	if got.Before(before) || got.After(time.Now()) {
+		t.Errorf("Real.Now() = %v, want the current time", got)
+	}
+}
+
+func TestFake_AfterFunc(t *testing.T) {
+	c := NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	fired := make(chan string, 2)
+	c.AfterFunc(time.Minute, func() { fired <- "minute" })
+	stopped := c.AfterFunc(time.Minute, func() { fired <- "stopped" })
+	c.AfterFunc(time.Hour, func() { fired <- "hour" })
+
+	c.Advance(59 * time.Second)
+	select {
+	case name := <-fired:
+		t.Fatalf("%s timer fired before its time", name)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if !stopped.Stop() {
+		t.Error("Stop() of a pending timer = false, want true")
+	}
+	c.Advance(time.Second)
+	select {
+	case name := <-fired:
+		if name != "minute" {
+			t.Errorf("fired %s timer, want the minute timer", name)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("minute timer did not fire")
+	}
+	select {
+	case name := <-fired:
+		t.Errorf("%s timer fired, want only the minute timer", name)
+	case <-time.After(10 * time.Millisecond):
+	}
+	if stopped.Stop() {
+		t.Error("Stop() of a stopped timer = true, want false")
+	}
+}
+
+func TestSleep(t *testing.T) {
+	c := NewFake(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	woke := make(chan struct{})
+	go func() {
+		Sleep(c, time.Minute)
+		close(woke)
+	}()
+
+	select {
+	case <-woke:
+		t.Fatal("Sleep() returned before the clock moved")
+	case <-time.After(10 * time.Millisecond):
+	}
+	c.Advance(time.Minute)
+	select {
+	case <-woke:
+	case <-time.After(time.Second):
+		t.Fatal("Sleep() did not return once the clock moved past its duration")
+	}
+}
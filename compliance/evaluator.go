@@ -0,0 +1,222 @@
+// Package compliance evaluates SLA definitions against session data
+package compliance
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Result summarizes an SLA for one agent over the current UTC day
+type Result struct {
+	SLAID              string  `json:"sla_id"`
+	Name               string  `json:"name"`
+	Compliant          bool    `json:"compliant"`
+	Runs               int     `json:"runs"`
+	Completed          int     `json:"completed"`
+	Failed             int     `json:"failed"`
+	FailureRate        float64 `json:"failure_rate"`
+	MaxFailureRate     float64 `json:"max_failure_rate,omitempty"`
+	DurationBreaches   int     `json:"duration_breaches"`
+	MaxDurationMinutes int     `json:"max_duration_minutes,omitempty"`
+}
+
+// Evaluator checks SLAs, records new breaches and notifies their owners
+type Evaluator struct {
+	store    store.Store
+	notifier *notifier.NotificationManager
+	clock    clock.Clock
+}
+
+// NewEvaluator creates an evaluator; n may be nil to record breaches without notifying
+func NewEvaluator(st store.Store, n *notifier.NotificationManager) *Evaluator {
+	return &Evaluator{
+		store:    st,
+		notifier: n,
+		clock:    clock.Real,
+	}
+}
+
+// SetClock replaces the clock that decides the evaluation windows
+func (e *Evaluator) SetClock(c clock.Clock) {
+	e.clock = c
+}
+
+// now returns the time SLAs are evaluated at, in UTC
+func (e *Evaluator) now() time.Time {
+	return e.clock.Now().UTC()
+}
+
+// Evaluate checks every SLA against its agents and handles newly detected breaches
+func (e *Evaluator) Evaluate() {
+	slas, err := e.store.ListSLAs()
+	if err != nil {
+		log.Printf("Failed to list SLAs: %v", err)
+		return
+	}
+
+	now := e.now()
+	for _, sla := range slas {
+		for _, agent := range e.store.ListAgentsByUser(sla.UserID) {
+			if sla.AgentID != "" && sla.AgentID != agent.AgentID {
+				continue
+			}
+			_, breaches := e.check(sla, agent.AgentID, now)
+			for _, breach := range breaches {
+				e.record(sla, agent, breach)
+			}
+		}
+	}
+}
+
+// ForAgent returns compliance of every SLA that applies to the agent
+func (e *Evaluator) ForAgent(agent *models.Agent) []*Result {
+	slas, err := e.store.ListSLAsByUser(agent.UserID)
+	if err != nil {
+		log.Printf("Failed to list SLAs: %v", err)
+		return nil
+	}
+
+	now := e.now()
+	results := make([]*Result, 0, len(slas))
+	for _, sla := range slas {
+		if sla.AgentID != "" && sla.AgentID != agent.AgentID {
+			continue
+		}
+		result, _ := e.check(sla, agent.AgentID, now)
+		results = append(results, result)
+	}
+	return results
+}
+
+// check evaluates one SLA for one agent, returning its compliance and any breaches
+func (e *Evaluator) check(sla *models.SLA, agentID string, now time.Time) (*Result, []*models.SLABreach) {
+	result := &Result{
+		SLAID:              sla.ID,
+		Name:               sla.Name,
+		MaxFailureRate:     sla.MaxFailureRate,
+		MaxDurationMinutes: sla.MaxDurationMinutes,
+	}
+
+	// Validated on write, so a compile error only happens for rows edited out of band
+	pattern, err := regexp.Compile(sla.TopicPattern)
+	if err != nil {
+		log.Printf("Skipping SLA %s with invalid topic pattern: %v", sla.ID, err)
+		result.Compliant = true
+		return result, nil
+	}
+
+	dayStart := now.Truncate(24 * time.Hour)
+	day := dayStart.Format("2006-01-02")
+
+	var breaches []*models.SLABreach
+	for _, session := range e.store.ListSessions(agentID, true) {
+		if session.LastUpdated.Before(dayStart) || !pattern.MatchString(session.SessionTopic) {
+			continue
+		}
+		result.Runs++
+
+		var status string
+		latest, err := e.store.GetLatestStatus(agentID, session.SessionTopic)
+		if err == nil && latest != nil {
+			status = latest.Status
+		}
+
+		// Finished sessions end at their final status; unfinished ones are still running unless expired
+		end := now
+		switch {
+		case status == "success" || status == "failed":
+			end = latest.Timestamp
+			result.Completed++
+			if status == "failed" {
+				result.Failed++
+			}
+		case session.Expired:
+			end = session.LastUpdated
+		}
+
+		duration := end.Sub(session.Created).Minutes()
+		if sla.MaxDurationMinutes > 0 && duration > float64(sla.MaxDurationMinutes) {
+			result.DurationBreaches++
+			breaches = append(breaches, e.newBreach(sla, agentID, models.SLABreachDuration,
+				session.SessionTopic, duration, float64(sla.MaxDurationMinutes), now))
+		}
+	}
+
+	if result.Completed > 0 {
+		result.FailureRate = float64(result.Failed) / float64(result.Completed)
+	}
+	failureBreached := sla.MaxFailureRate > 0 && result.FailureRate > sla.MaxFailureRate
+	if failureBreached {
+		breaches = append(breaches, e.newBreach(sla, agentID, models.SLABreachFailureRate,
+			day, result.FailureRate, sla.MaxFailureRate, now))
+	}
+
+	result.Compliant = result.DurationBreaches == 0 && !failureBreached
+	return result, breaches
+}
+
+// newBreach builds a breach record for the SLA
+func (e *Evaluator) newBreach(sla *models.SLA, agentID, kind, subject string, value, threshold float64, now time.Time) *models.SLABreach {
+	return &models.SLABreach{
+		ID:         uuid.New().String(),
+		SLAID:      sla.ID,
+		UserID:     sla.UserID,
+		AgentID:    agentID,
+		Kind:       kind,
+		Subject:    subject,
+		Value:      value,
+		Threshold:  threshold,
+		DetectedAt: now,
+	}
+}
+
+// record stores a breach and notifies the SLA owner the first time it is detected
+func (e *Evaluator) record(sla *models.SLA, agent *models.Agent, breach *models.SLABreach) {
+	if err := e.store.CreateSLABreach(breach); err != nil {
+		if !errors.Is(err, store.ErrAlreadyExists) {
+			log.Printf("Failed to record SLA breach: %v", err)
+		}
+		return
+	}
+
+	if e.notifier == nil {
+		return
+	}
+
+	user, err := e.store.GetUserByID(sla.UserID)
+	if err != nil {
+		log.Printf("Failed to load user for SLA notification: %v", err)
+		return
+	}
+
+	data := &notifier.SLABreachData{
+		SLAName:   sla.Name,
+		AgentID:   agent.AgentID,
+		AgentName: agent.Name,
+		Kind:      breach.Kind,
+		Subject:   breach.Subject,
+		Value:     breach.Value,
+		Threshold: breach.Threshold,
+		Timestamp: breach.DetectedAt,
+	}
+
+	// Duration breaches concern one session; failure rate breaches concern the whole agent
+	topic := ""
+	if breach.Kind == models.SLABreachDuration {
+		topic = breach.Subject
+	}
+	data.Mentions = notifier.MentionsFromRules(user.MentionsFor(agent.AgentID, topic))
+
+	if err := e.notifier.NotifySLABreach(context.Background(), data, user.NotificationWebhookURL); err != nil {
+		log.Printf("Failed to queue SLA notification: %v", err)
+	}
+}
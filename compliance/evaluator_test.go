@@ -0,0 +1,149 @@
+package compliance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+var testNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// setupStore creates a user with one agent and sessions finishing with the given results
+func setupStore(t *testing.T, webhookURL string, runs map[string]struct {
+	status   string
+	duration time.Duration
+}) store.Store {
+	t.Helper()
+	st := store.NewMemoryStore()
+
+	st.CreateUser(&models.User{
+		ID:                     "user-001",
+		Email:                  "test@example.com",
+		PasswordHash:           "dummy-hash",
+		NotificationWebhookURL: webhookURL,
+		CreatedAt:              testNow,
+		UpdatedAt:              testNow,
+	})
+	st.CreateOrUpdateAgent(&models.Agent{
+		AgentID:    "agent-001",
+		UserID:     "user-001",
+		Registered: testNow,
+		LastSeen:   testNow,
+	})
+
+	for topic, run := range runs {
+		created := testNow.Add(-3 * time.Hour)
+		st.CreateOrUpdateSession(&models.Session{
+			AgentID:      "agent-001",
+			SessionTopic: topic,
+			Created:      created,
+			LastUpdated:  created.Add(run.duration),
+		})
+		st.AddStatus(&models.AgentStatus{
+			AgentID:      "agent-001",
+			SessionTopic: topic,
+			Status:       run.status,
+			Timestamp:    created.Add(run.duration),
+		})
+	}
+	return st
+}
+
+func TestEvaluator_ForAgent(t *testing.T) {
+	st := setupStore(t, "", map[string]struct {
+		status   string
+		duration time.Duration
+	}{
+		"backup 1": {"success", 30 * time.Minute},
+		"backup 2": {"failed", 90 * time.Minute},
+		"deploy 1": {"failed", 10 * time.Minute},
+	})
+	st.CreateSLA(&models.SLA{
+		ID:                 "sla-001",
+		UserID:             "user-001",
+		Name:               "Backups",
+		TopicPattern:       "^backup",
+		MaxDurationMinutes: 60,
+		MaxFailureRate:     0.25,
+		CreatedAt:          testNow,
+		UpdatedAt:          testNow,
+	})
+
+	evaluator := NewEvaluator(st, nil)
+	evaluator.SetClock(clock.NewFake(testNow))
+
+	agent, _ := st.GetAgent("agent-001")
+	results := evaluator.ForAgent(agent)
+	if len(results) != 1 {
+		t.Fatalf("ForAgent() returned %d results, want 1", len(results))
+	}
+
+	result := results[0]
+	if result.Compliant {
+		t.Error("ForAgent() Compliant = true, want false")
+	}
+	if result.Runs != 2 || result.Failed != 1 {
+		t.Errorf("ForAgent() runs = %d, failed = %d, want 2, 1", result.Runs, result.Failed)
+	}
+	if result.FailureRate != 0.5 {
+		t.Errorf("ForAgent() FailureRate = %v, want 0.5", result.FailureRate)
+	}
+	if result.DurationBreaches != 1 {
+		t.Errorf("ForAgent() DurationBreaches = %d, want 1", result.DurationBreaches)
+	}
+}
+
+func TestEvaluator_EvaluateRecordsAndNotifiesOnce(t *testing.T) {
+	var received int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&received, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := setupStore(t, server.URL, map[string]struct {
+		status   string
+		duration time.Duration
+	}{
+		"backup 1": {"success", 90 * time.Minute},
+	})
+	st.CreateSLA(&models.SLA{
+		ID:                 "sla-001",
+		UserID:             "user-001",
+		Name:               "Backups",
+		MaxDurationMinutes: 60,
+		CreatedAt:          testNow,
+		UpdatedAt:          testNow,
+	})
+
+	manager := notifier.NewNotificationManager(5 * time.Second)
+	evaluator := NewEvaluator(st, manager)
+	evaluator.SetClock(clock.NewFake(testNow))
+
+	// A breach that persists across evaluations is only recorded and notified once
+	evaluator.Evaluate()
+	evaluator.Evaluate()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	manager.Shutdown(ctx)
+
+	breaches, _ := st.ListSLABreaches("sla-001", time.Time{})
+	if len(breaches) != 1 {
+		t.Fatalf("Evaluate() recorded %d breaches, want 1", len(breaches))
+	}
+	if breaches[0].Kind != models.SLABreachDuration || breaches[0].Subject != "backup 1" {
+		t.Errorf("Evaluate() breach = %s/%s, want duration/backup 1", breaches[0].Kind, breaches[0].Subject)
+	}
+	if got := atomic.LoadInt32(&received); got != 1 {
+		t.Errorf("Evaluate() sent %d notifications, want 1", got)
+	}
+}
@@ -36,15 +36,166 @@ type SMTPConfig struct {
 	FromEmail string
 }
 
+// AccessLogConfig holds access log configuration
+type AccessLogConfig struct {
+	Format     string  // "json" or "text"
+	SampleRate float64 // Fraction of successful requests to log (0-1]
+}
+
+// SecurityConfig holds security response header configuration
+type SecurityConfig struct {
+	HSTSMaxAge            int    // seconds, 0 disables HSTS
+	ContentSecurityPolicy string // CSP applied to API responses
+	FrameOptions          string
+}
+
+// CORSConfig holds the dashboard API CORS options besides its allowed origins
+type CORSConfig struct {
+	CredentialedOrigins []string // Origins allowed to send credentials; empty allows every allowed origin
+	MaxAge              int      // Seconds browsers may cache a preflight response
+	ExposedHeaders      []string // Response headers readable by browser clients
+}
+
+// LimitsConfig holds request timeout and concurrency limits
+type LimitsConfig struct {
+	MaxInFlightRequests int           // Requests served at once, streams excluded; 0 disables the limiter
+	MaxOpenStreams      int           // Event streams and WebSockets open at once; 0 disables the limit
+	APITimeout          time.Duration // Deadline for dashboard API requests
+	WebhookTimeout      time.Duration // Deadline for webhook ingestion requests
+	WebhookRateLimit    int           // Webhook requests per minute per API key or user; 0 disables the limit
+	WebhookRateBurst    int           // Webhook requests a caller may make at once before the rate applies
+
+	// Status reports processed for longer than the budget are accepted with 202 and finished in the background;
+	// reports then go through a queue for the defer window. A budget of 0 always processes them synchronously.
+	WebhookLatencyBudget  time.Duration
+	WebhookDeferWindow    time.Duration
+	WebhookDeferQueueSize int
+
+	// Status reports retried with the same idempotency key within this TTL get the original response; 0 ignores keys
+	WebhookIdempotencyTTL time.Duration
+}
+
+// WebhookSigningConfig holds HMAC signature verification for webhook ingestion
+type WebhookSigningConfig struct {
+	Secret    string        // Enables signature verification when set
+	Tolerance time.Duration // Freshness window for signature timestamps and nonces
+}
+
+// MTLSConfig holds the optional mutual TLS webhook listener
+type MTLSConfig struct {
+	Port         string // Enables the listener when set
+	CertFile     string // Server certificate
+	KeyFile      string // Server private key
+	ClientCAFile string // CA bundle client certificates must chain to; empty accepts any certificate with a registered fingerprint
+}
+
+// PayloadConfig holds status report field size limits
+type PayloadConfig struct {
+	MaxMessageLength int    // Deployment default for message
+	MaxContentLength int    // Deployment default for content
+	MaxMetadataBytes int    // Deployment default for metadata
+	Tiers            string // JSON per-plan overrides, see internal.NewPayloadLimitPolicy
+}
+
+// APIKeyCacheConfig holds webhook API key validation caching settings
+type APIKeyCacheConfig struct {
+	TTL                time.Duration // How long a validated key is trusted without a store lookup; 0 disables caching
+	UsageFlushInterval time.Duration // How often last_used updates are written; 0 writes on every request
+}
+
+// JanitorConfig holds background cleanup settings
+type JanitorConfig struct {
+	Interval              time.Duration // How often expired records are removed; 0 disables cleanup
+	SLABreachRetention    time.Duration // How long SLA breaches are kept; 0 keeps them forever
+	DeletedAgentRetention time.Duration // How long soft-deleted agents can be restored before they are purged; 0 keeps them forever
+	StatusRetentionDays   int           // Whole days of status history kept once rolled up and archived; 0 keeps it forever
+	AuditRetention        time.Duration // How long admin audit events are kept; 0 keeps them forever
+}
+
+// ArchiveConfig holds settings of the object storage completed days of status history are archived to
+type ArchiveConfig struct {
+	URL             string // s3://bucket/prefix, gs://bucket/prefix or file:///directory; empty disables archiving
+	Endpoint        string // S3-compatible endpoint overriding the default of the URL's scheme
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	Passphrase      string // Encrypts archived days when set
+}
+
+// HealthConfig holds health score configuration
+type HealthConfig struct {
+	Interval      time.Duration // How often health scores are recalculated; 0 disables scoring
+	Window        time.Duration // Sessions finished within this window count towards the failure rate
+	StuckAfter    time.Duration // Running sessions without an update for this long count as stuck
+	FailureWeight float64       // Weight of the failure rate component
+	OfflineWeight float64       // Weight of the offline time component
+	StuckWeight   float64       // Weight of the stuck sessions component
+}
+
+// ReplicaConfig holds settings of the secondary store writes are mirrored to
+type ReplicaConfig struct {
+	DatabaseURL string // PostgreSQL connection string of the secondary; empty disables replication
+	QueueSize   int    // Writes waiting to be mirrored before further writes are dropped
+}
+
+// OutboxConfig holds transactional outbox settings
+type OutboxConfig struct {
+	Interval    time.Duration // How often the relay polls for due messages; 0 disables the outbox
+	MaxAttempts int           // Deliveries tried before a message is dropped
+	Workers     int           // Messages a relay delivers at once
+}
+
+// UIConfig holds dashboard serving configuration
+type UIConfig struct {
+	Enabled               bool   // Serve the dashboard SPA under /
+	Dir                   string // Serve from this directory instead of the embedded files
+	ContentSecurityPolicy string // CSP applied to dashboard responses
+}
+
 // Config holds application configuration
 type Config struct {
-	Port                string
-	CORSAllowedOrigins  []string
-	NotificationTimeout time.Duration
-	Database            DatabaseConfig
-	JWT                 JWTConfig
-	SMTP                SMTPConfig
-	AppBaseURL          string
+	Port                      string
+	CORSAllowedOrigins        []string
+	WebhookCORSAllowedOrigins []string
+	CORS                      CORSConfig
+	LegacyListKeys            bool // Collection responses also carry their pre-envelope key, e.g. "agents"
+	NotificationTimeout       time.Duration
+	NotificationCoalescing    time.Duration // Transitions of one session within this window are sent as one message; 0 disables
+	NotificationDefaultFormat string        // Payload format for notification webhook URLs of unrecognised chat platforms
+	NotifierPlugins           string        // External notification channels, see notifier.ParsePlugins
+	NotificationQueue         string        // Where notifications wait for delivery: memory, or store to survive restarts
+	Database                  DatabaseConfig
+	JWT                       JWTConfig
+	SMTP                      SMTPConfig
+	AccessLog                 AccessLogConfig
+	Security                  SecurityConfig
+	Limits                    LimitsConfig
+	WebhookSigning            WebhookSigningConfig
+	MTLS                      MTLSConfig
+	Payload                   PayloadConfig
+	APIKeyCache               APIKeyCacheConfig
+	Janitor                   JanitorConfig
+	Archive                   ArchiveConfig
+	Health                    HealthConfig
+	Outbox                    OutboxConfig
+	Replica                   ReplicaConfig
+	EncryptionMasterKey       string // Base64 32-byte key; enables encryption of status message and content at rest
+	MetricsEnabled            bool   // Serve Prometheus metrics on /metrics
+	AgentMetricsEnabled       bool   // Serve agent outcome metrics in OpenMetrics format on /metrics/agents
+	UI                        UIConfig
+	SessionGroupRules         string        // JSON topic grouping rules, see internal.ParseTopicRules
+	SessionReopenGrace        time.Duration // Reports this soon after a session expired re-open it; later ones start a new revision
+	SLAEvaluationInterval     time.Duration // How often SLAs are evaluated; 0 disables evaluation
+	AlertEvaluationInterval   time.Duration // How often running_longer and agent_offline alert rules are evaluated; 0 disables them
+	AgentOfflineAfter         time.Duration // Silence after which an agent is reported offline in the inbox; 0 disables it
+	AgentHeartbeatInterval    time.Duration // Silence after which an agent's state becomes stale; 0 keeps it online until offline
+	AgentOfflineNotify        bool          // Notify an agent's owner when its state becomes offline
+	AgentDefaultKind          string        // Kind given to agents that report without one, see models.AgentKinds
+	AdminEmails               []string      // Users who may change deployment-wide settings such as the notification policy
+	BootstrapToken            string        // Bearer token of the tenant provisioning API; empty disables it
+	MeteringFlushInterval     time.Duration // How often metered usage is written to the daily usage records; 0 disables metering
+	CountReconcileInterval    time.Duration // How often users' record counts are recounted from the stored records; 0 never recounts them
+	AppBaseURL                string
 }
 
 // Load loads configuration from environment variables with defaults
@@ -59,11 +210,29 @@ func Load() *Config {
 		corsOrigins = "*"
 	}
 
-	origins := strings.Split(corsOrigins, ",")
-	for i, origin := range origins {
-		origins[i] = strings.TrimSpace(origin)
+	origins := splitList(corsOrigins)
+
+	// Webhook CORS is disabled unless explicitly configured (ingestion is server-to-server)
+	webhookOrigins := splitList(os.Getenv("WEBHOOK_CORS_ALLOWED_ORIGINS"))
+
+	corsConfig := CORSConfig{
+		CredentialedOrigins: splitList(os.Getenv("CORS_CREDENTIALED_ORIGINS")),
+		MaxAge:              getEnvAsInt("CORS_MAX_AGE", 300),
+		ExposedHeaders:      splitList(getEnv("CORS_EXPOSED_HEADERS", "Link,X-Total-Count")),
 	}
 
+	// Notification coalescing window
+	notificationCoalescing := getEnvAsDuration("NOTIFICATION_COALESCE_WINDOW", "5s")
+
+	// Notification payload format for unrecognised webhook URLs
+	notificationDefaultFormat := getEnv("NOTIFICATION_DEFAULT_FORMAT", "generic")
+
+	// External notification channels, e.g. pager=exec:/usr/local/bin/page
+	notifierPlugins := getEnv("NOTIFIER_PLUGINS", "")
+
+	// Notification queue backend
+	notificationQueue := getEnv("NOTIFICATION_QUEUE", "memory")
+
 	// Notification timeout (default 5 seconds)
 	notificationTimeout := 5 * time.Second
 	if timeoutStr := os.Getenv("NOTIFICATION_TIMEOUT_SECONDS"); timeoutStr != "" {
@@ -101,19 +270,216 @@ func Load() *Config {
 		FromEmail: getEnv("SMTP_FROM", ""),
 	}
 
+	// Access log configuration
+	accessLogConfig := AccessLogConfig{
+		Format:     getEnv("ACCESS_LOG_FORMAT", "json"),
+		SampleRate: getEnvAsFloat("ACCESS_LOG_SAMPLE_RATE", 1.0),
+	}
+
+	// Security header configuration
+	securityConfig := SecurityConfig{
+		HSTSMaxAge:            getEnvAsInt("SECURITY_HSTS_MAX_AGE", 0),
+		ContentSecurityPolicy: getEnv("SECURITY_CSP", "default-src 'none'; frame-ancestors 'none'"),
+		FrameOptions:          getEnv("SECURITY_FRAME_OPTIONS", "DENY"),
+	}
+
+	// Request limits configuration
+	limitsConfig := LimitsConfig{
+		MaxInFlightRequests: getEnvAsInt("MAX_IN_FLIGHT_REQUESTS", 1000),
+		MaxOpenStreams:      getEnvAsInt("MAX_OPEN_STREAMS", 1000),
+		APITimeout:          getEnvAsDuration("API_REQUEST_TIMEOUT", "15s"),
+		WebhookTimeout:      getEnvAsDuration("WEBHOOK_REQUEST_TIMEOUT", "5s"),
+		WebhookRateLimit:    getEnvAsInt("WEBHOOK_RATE_LIMIT", 0),
+		WebhookRateBurst:    getEnvAsInt("WEBHOOK_RATE_BURST", 20),
+
+		WebhookLatencyBudget:  getEnvAsDuration("WEBHOOK_LATENCY_BUDGET", "800ms"),
+		WebhookDeferWindow:    getEnvAsDuration("WEBHOOK_DEFER_WINDOW", "30s"),
+		WebhookDeferQueueSize: getEnvAsInt("WEBHOOK_DEFER_QUEUE_SIZE", 1000),
+
+		WebhookIdempotencyTTL: getEnvAsDuration("WEBHOOK_IDEMPOTENCY_TTL", "24h"),
+	}
+
+	// Webhook signature configuration
+	webhookSigningConfig := WebhookSigningConfig{
+		Secret:    getEnv("WEBHOOK_SIGNING_SECRET", ""),
+		Tolerance: getEnvAsDuration("WEBHOOK_SIGNATURE_TOLERANCE", "5m"),
+	}
+
+	// Mutual TLS webhook listener configuration
+	mtlsConfig := MTLSConfig{
+		Port:         getEnv("MTLS_PORT", ""),
+		CertFile:     getEnv("MTLS_CERT_FILE", ""),
+		KeyFile:      getEnv("MTLS_KEY_FILE", ""),
+		ClientCAFile: getEnv("MTLS_CLIENT_CA_FILE", ""),
+	}
+
+	// Status payload limits configuration
+	payloadConfig := PayloadConfig{
+		MaxMessageLength: getEnvAsInt("PAYLOAD_MAX_MESSAGE_LENGTH", 1000),
+		MaxContentLength: getEnvAsInt("PAYLOAD_MAX_CONTENT_LENGTH", 10000),
+		MaxMetadataBytes: getEnvAsInt("PAYLOAD_MAX_METADATA_BYTES", 4096),
+		Tiers:            getEnv("PAYLOAD_LIMIT_TIERS", ""),
+	}
+
+	// API key validation cache configuration
+	apiKeyCacheConfig := APIKeyCacheConfig{
+		TTL:                getEnvAsDuration("API_KEY_CACHE_TTL", "30s"),
+		UsageFlushInterval: getEnvAsDuration("API_KEY_USAGE_FLUSH_INTERVAL", "30s"),
+	}
+
+	// Cleanup janitor configuration
+	janitorConfig := JanitorConfig{
+		Interval:              getEnvAsDuration("JANITOR_INTERVAL", "1h"),
+		SLABreachRetention:    getEnvAsDuration("SLA_BREACH_RETENTION", "2160h"),
+		DeletedAgentRetention: getEnvAsDuration("DELETED_AGENT_RETENTION", "720h"),
+		StatusRetentionDays:   getEnvAsInt("STATUS_RETENTION_DAYS", 0),
+		AuditRetention:        getEnvAsDuration("AUDIT_RETENTION", "8760h"),
+	}
+
+	// Status history archive configuration
+	archiveConfig := ArchiveConfig{
+		URL:             getEnv("ARCHIVE_URL", ""),
+		Endpoint:        getEnv("ARCHIVE_ENDPOINT", ""),
+		Region:          getEnv("ARCHIVE_REGION", ""),
+		AccessKeyID:     getEnv("ARCHIVE_ACCESS_KEY_ID", ""),
+		SecretAccessKey: getEnv("ARCHIVE_SECRET_ACCESS_KEY", ""),
+		Passphrase:      getEnv("ARCHIVE_PASSPHRASE", ""),
+	}
+
+	// Health score configuration
+	healthConfig := HealthConfig{
+		Interval:      getEnvAsDuration("HEALTH_SCORE_INTERVAL", "1m"),
+		Window:        getEnvAsDuration("HEALTH_SCORE_WINDOW", "24h"),
+		StuckAfter:    getEnvAsDuration("HEALTH_STUCK_AFTER", "1h"),
+		FailureWeight: getEnvAsFloat("HEALTH_FAILURE_WEIGHT", 0.5),
+		OfflineWeight: getEnvAsFloat("HEALTH_OFFLINE_WEIGHT", 0.3),
+		StuckWeight:   getEnvAsFloat("HEALTH_STUCK_WEIGHT", 0.2),
+	}
+
+	// Transactional outbox configuration
+	outboxConfig := OutboxConfig{
+		Interval:    getEnvAsDuration("OUTBOX_INTERVAL", "0"),
+		MaxAttempts: getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 10),
+		Workers:     getEnvAsInt("OUTBOX_WORKERS", 4),
+	}
+	// The store queue is delivered by the outbox relay, so it needs one running
+	if notificationQueue == "store" && outboxConfig.Interval <= 0 {
+		outboxConfig.Interval = time.Second
+	}
+
+	// Secondary store replication configuration
+	replicaConfig := ReplicaConfig{
+		DatabaseURL: getEnv("REPLICA_DATABASE_URL", ""),
+		QueueSize:   getEnvAsInt("REPLICA_QUEUE_SIZE", 10000),
+	}
+
+	// Master key wrapping the per-user keys that encrypt status message and content
+	encryptionMasterKey := getEnv("ENCRYPTION_MASTER_KEY", "")
+
+	metricsEnabled := getEnvAsBool("METRICS_ENABLED", false)
+	agentMetricsEnabled := getEnvAsBool("AGENT_METRICS_ENABLED", false)
+
+	// Kept on while clients migrate to the standard collection envelope
+	legacyListKeys := getEnvAsBool("API_LEGACY_LIST_KEYS", true)
+
+	// Dashboard UI configuration
+	uiConfig := UIConfig{
+		Enabled:               getEnvAsBool("UI_ENABLED", false),
+		Dir:                   getEnv("UI_DIR", ""),
+		ContentSecurityPolicy: getEnv("UI_CSP", "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; connect-src 'self'; frame-ancestors 'none'"),
+	}
+
+	// Session topic grouping rules (JSON array of {pattern, group, category})
+	sessionGroupRules := getEnv("SESSION_GROUP_RULES", "")
+
+	// Grace period in which a report re-opens an expired session
+	sessionReopenGrace := getEnvAsDuration("SESSION_REOPEN_GRACE", "10m")
+
+	// SLA evaluation interval
+	slaEvaluationInterval := getEnvAsDuration("SLA_EVALUATION_INTERVAL", "1m")
+
+	// Alert rule evaluation interval
+	alertEvaluationInterval := getEnvAsDuration("ALERT_EVALUATION_INTERVAL", "1m")
+
+	// Inbox offline agent threshold
+	agentOfflineAfter := getEnvAsDuration("AGENT_OFFLINE_AFTER", "15m")
+
+	// Agent presence monitor
+	agentHeartbeatInterval := getEnvAsDuration("AGENT_HEARTBEAT_INTERVAL", "5m")
+	agentOfflineNotify := getEnvAsBool("AGENT_OFFLINE_NOTIFY", false)
+	agentDefaultKind := getEnv("AGENT_DEFAULT_KIND", "custom")
+
+	// Deployment admins
+	adminEmails := splitList(os.Getenv("ADMIN_EMAILS"))
+
+	// Tenant provisioning for embedding platforms
+	bootstrapToken := os.Getenv("BOOTSTRAP_TOKEN")
+
+	// Usage metering flush interval
+	meteringFlushInterval := getEnvAsDuration("METERING_FLUSH_INTERVAL", "1m")
+
+	// Record count reconciliation interval
+	countReconcileInterval := getEnvAsDuration("COUNT_RECONCILE_INTERVAL", "24h")
+
 	appBaseURL := getEnv("APP_BASE_URL", "http://localhost:5173")
 
 	return &Config{
-		Port:                port,
-		CORSAllowedOrigins:  origins,
-		NotificationTimeout: notificationTimeout,
-		Database:            dbConfig,
-		JWT:                 jwtConfig,
-		SMTP:                smtpConfig,
-		AppBaseURL:          appBaseURL,
+		Port:                      port,
+		CORSAllowedOrigins:        origins,
+		WebhookCORSAllowedOrigins: webhookOrigins,
+		CORS:                      corsConfig,
+		LegacyListKeys:            legacyListKeys,
+		NotificationTimeout:       notificationTimeout,
+		NotificationCoalescing:    notificationCoalescing,
+		NotificationDefaultFormat: notificationDefaultFormat,
+		NotifierPlugins:           notifierPlugins,
+		NotificationQueue:         notificationQueue,
+		Database:                  dbConfig,
+		JWT:                       jwtConfig,
+		SMTP:                      smtpConfig,
+		AccessLog:                 accessLogConfig,
+		Security:                  securityConfig,
+		Limits:                    limitsConfig,
+		WebhookSigning:            webhookSigningConfig,
+		MTLS:                      mtlsConfig,
+		Payload:                   payloadConfig,
+		APIKeyCache:               apiKeyCacheConfig,
+		Janitor:                   janitorConfig,
+		Archive:                   archiveConfig,
+		Health:                    healthConfig,
+		Outbox:                    outboxConfig,
+		Replica:                   replicaConfig,
+		EncryptionMasterKey:       encryptionMasterKey,
+		MetricsEnabled:            metricsEnabled,
+		AgentMetricsEnabled:       agentMetricsEnabled,
+		UI:                        uiConfig,
+		SessionGroupRules:         sessionGroupRules,
+		SessionReopenGrace:        sessionReopenGrace,
+		SLAEvaluationInterval:     slaEvaluationInterval,
+		AlertEvaluationInterval:   alertEvaluationInterval,
+		AgentOfflineAfter:         agentOfflineAfter,
+		AgentHeartbeatInterval:    agentHeartbeatInterval,
+		AgentOfflineNotify:        agentOfflineNotify,
+		AgentDefaultKind:          agentDefaultKind,
+		AdminEmails:               adminEmails,
+		BootstrapToken:            bootstrapToken,
+		MeteringFlushInterval:     meteringFlushInterval,
+		CountReconcileInterval:    countReconcileInterval,
+		AppBaseURL:                appBaseURL,
 	}
 }
 
+// splitList splits a comma-separated value, trimming whitespace and dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -130,6 +496,24 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if valueStr := os.Getenv(key); valueStr != "" {
+		if value, err := strconv.ParseFloat(valueStr, 64); err == nil && value > 0 {
+			return value
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if valueStr := os.Getenv(key); valueStr != "" {
+		if value, err := strconv.ParseBool(valueStr); err == nil {
+			return value
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key, defaultValue string) time.Duration {
 	if valueStr := os.Getenv(key); valueStr != "" {
 		if value, err := time.ParseDuration(valueStr); err == nil {
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -114,3 +115,559 @@ func TestLoad_NotificationTimeout(t *testing.T) {
 		})
 	}
 }
+
+func TestLoad_AccessLog(t *testing.T) {
+	t.Setenv("ACCESS_LOG_FORMAT", "")
+	t.Setenv("ACCESS_LOG_SAMPLE_RATE", "")
+
+	cfg := Load()
+	if cfg.AccessLog.Format != "json" {
+		t.Errorf("Load() default AccessLog.Format = %v, want json", cfg.AccessLog.Format)
+	}
+	if cfg.AccessLog.SampleRate != 1.0 {
+		t.Errorf("Load() default AccessLog.SampleRate = %v, want 1.0", cfg.AccessLog.SampleRate)
+	}
+
+	t.Setenv("ACCESS_LOG_FORMAT", "text")
+	t.Setenv("ACCESS_LOG_SAMPLE_RATE", "0.25")
+
+	cfg = Load()
+	if cfg.AccessLog.Format != "text" {
+		t.Errorf("Load() AccessLog.Format = %v, want text", cfg.AccessLog.Format)
+	}
+	if cfg.AccessLog.SampleRate != 0.25 {
+		t.Errorf("Load() AccessLog.SampleRate = %v, want 0.25", cfg.AccessLog.SampleRate)
+	}
+}
+
+func TestLoad_WebhookCORSAndSecurity(t *testing.T) {
+	t.Setenv("WEBHOOK_CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("SECURITY_HSTS_MAX_AGE", "")
+
+	cfg := Load()
+	if len(cfg.WebhookCORSAllowedOrigins) != 0 {
+		t.Errorf("Load() default WebhookCORSAllowedOrigins = %v, want empty", cfg.WebhookCORSAllowedOrigins)
+	}
+	if cfg.Security.HSTSMaxAge != 0 {
+		t.Errorf("Load() default Security.HSTSMaxAge = %v, want 0", cfg.Security.HSTSMaxAge)
+	}
+	if cfg.Security.ContentSecurityPolicy == "" {
+		t.Error("Load() default Security.ContentSecurityPolicy is empty")
+	}
+
+	t.Setenv("WEBHOOK_CORS_ALLOWED_ORIGINS", " https://ci.example.com , ")
+	t.Setenv("SECURITY_HSTS_MAX_AGE", "31536000")
+
+	cfg = Load()
+	if len(cfg.WebhookCORSAllowedOrigins) != 1 || cfg.WebhookCORSAllowedOrigins[0] != "https://ci.example.com" {
+		t.Errorf("Load() WebhookCORSAllowedOrigins = %v, want [https://ci.example.com]", cfg.WebhookCORSAllowedOrigins)
+	}
+	if cfg.Security.HSTSMaxAge != 31536000 {
+		t.Errorf("Load() Security.HSTSMaxAge = %v, want 31536000", cfg.Security.HSTSMaxAge)
+	}
+}
+
+func TestLoad_CORS(t *testing.T) {
+	t.Setenv("CORS_CREDENTIALED_ORIGINS", "")
+	t.Setenv("CORS_MAX_AGE", "")
+	t.Setenv("CORS_EXPOSED_HEADERS", "")
+
+	cfg := Load()
+	if len(cfg.CORS.CredentialedOrigins) != 0 {
+		t.Errorf("Load() default CORS.CredentialedOrigins = %v, want empty", cfg.CORS.CredentialedOrigins)
+	}
+	if cfg.CORS.MaxAge != 300 {
+		t.Errorf("Load() default CORS.MaxAge = %v, want 300", cfg.CORS.MaxAge)
+	}
+	if !reflect.DeepEqual(cfg.CORS.ExposedHeaders, []string{"Link", "X-Total-Count"}) {
+		t.Errorf("Load() default CORS.ExposedHeaders = %v, want [Link X-Total-Count]", cfg.CORS.ExposedHeaders)
+	}
+
+	t.Setenv("CORS_CREDENTIALED_ORIGINS", "https://dashboard.example.com")
+	t.Setenv("CORS_MAX_AGE", "600")
+	t.Setenv("CORS_EXPOSED_HEADERS", "Link, X-Total-Count, X-Request-ID")
+
+	cfg = Load()
+	if !reflect.DeepEqual(cfg.CORS.CredentialedOrigins, []string{"https://dashboard.example.com"}) {
+		t.Errorf("Load() CORS.CredentialedOrigins = %v, want [https://dashboard.example.com]", cfg.CORS.CredentialedOrigins)
+	}
+	if cfg.CORS.MaxAge != 600 {
+		t.Errorf("Load() CORS.MaxAge = %v, want 600", cfg.CORS.MaxAge)
+	}
+	if len(cfg.CORS.ExposedHeaders) != 3 || cfg.CORS.ExposedHeaders[2] != "X-Request-ID" {
+		t.Errorf("Load() CORS.ExposedHeaders = %v, want 3 headers ending with X-Request-ID", cfg.CORS.ExposedHeaders)
+	}
+}
+
+func TestLoad_LegacyListKeys(t *testing.T) {
+	t.Setenv("API_LEGACY_LIST_KEYS", "")
+	if cfg := Load(); !cfg.LegacyListKeys {
+		t.Error("Load() default LegacyListKeys = false, want true")
+	}
+
+	t.Setenv("API_LEGACY_LIST_KEYS", "false")
+	if cfg := Load(); cfg.LegacyListKeys {
+		t.Error("Load() LegacyListKeys = true, want false")
+	}
+}
+
+func TestLoad_Limits(t *testing.T) {
+	t.Setenv("MAX_IN_FLIGHT_REQUESTS", "")
+	t.Setenv("MAX_OPEN_STREAMS", "")
+	t.Setenv("API_REQUEST_TIMEOUT", "")
+	t.Setenv("WEBHOOK_REQUEST_TIMEOUT", "")
+	t.Setenv("WEBHOOK_RATE_LIMIT", "")
+	t.Setenv("WEBHOOK_RATE_BURST", "")
+	t.Setenv("WEBHOOK_LATENCY_BUDGET", "")
+	t.Setenv("WEBHOOK_DEFER_WINDOW", "")
+	t.Setenv("WEBHOOK_DEFER_QUEUE_SIZE", "")
+	t.Setenv("WEBHOOK_IDEMPOTENCY_TTL", "")
+
+	cfg := Load()
+	if cfg.Limits.MaxInFlightRequests != 1000 || cfg.Limits.MaxOpenStreams != 1000 {
+		t.Errorf("Load() default MaxInFlightRequests = %v, MaxOpenStreams = %v, want 1000 each", cfg.Limits.MaxInFlightRequests, cfg.Limits.MaxOpenStreams)
+	}
+	if cfg.Limits.APITimeout != 15*time.Second {
+		t.Errorf("Load() default APITimeout = %v, want 15s", cfg.Limits.APITimeout)
+	}
+	if cfg.Limits.WebhookTimeout != 5*time.Second {
+		t.Errorf("Load() default WebhookTimeout = %v, want 5s", cfg.Limits.WebhookTimeout)
+	}
+	if cfg.Limits.WebhookRateLimit != 0 || cfg.Limits.WebhookRateBurst != 20 {
+		t.Errorf("Load() default webhook rate = %d/min burst %d, want 0/min burst 20", cfg.Limits.WebhookRateLimit, cfg.Limits.WebhookRateBurst)
+	}
+	if cfg.Limits.WebhookLatencyBudget != 800*time.Millisecond || cfg.Limits.WebhookDeferWindow != 30*time.Second || cfg.Limits.WebhookDeferQueueSize != 1000 {
+		t.Errorf("Load() default latency budget = %v, defer window %v, queue %d, want 800ms, 30s, 1000",
+			cfg.Limits.WebhookLatencyBudget, cfg.Limits.WebhookDeferWindow, cfg.Limits.WebhookDeferQueueSize)
+	}
+	if cfg.Limits.WebhookIdempotencyTTL != 24*time.Hour {
+		t.Errorf("Load() default WebhookIdempotencyTTL = %v, want 24h", cfg.Limits.WebhookIdempotencyTTL)
+	}
+
+	t.Setenv("MAX_IN_FLIGHT_REQUESTS", "50")
+	t.Setenv("MAX_OPEN_STREAMS", "20")
+	t.Setenv("WEBHOOK_REQUEST_TIMEOUT", "2s")
+	t.Setenv("WEBHOOK_RATE_LIMIT", "120")
+	t.Setenv("WEBHOOK_LATENCY_BUDGET", "0")
+	t.Setenv("WEBHOOK_IDEMPOTENCY_TTL", "0")
+
+	cfg = Load()
+	if cfg.Limits.MaxInFlightRequests != 50 || cfg.Limits.MaxOpenStreams != 20 {
+		t.Errorf("Load() MaxInFlightRequests = %v, MaxOpenStreams = %v, want 50 and 20", cfg.Limits.MaxInFlightRequests, cfg.Limits.MaxOpenStreams)
+	}
+	if cfg.Limits.WebhookTimeout != 2*time.Second {
+		t.Errorf("Load() WebhookTimeout = %v, want 2s", cfg.Limits.WebhookTimeout)
+	}
+	if cfg.Limits.WebhookRateLimit != 120 {
+		t.Errorf("Load() WebhookRateLimit = %v, want 120", cfg.Limits.WebhookRateLimit)
+	}
+	if cfg.Limits.WebhookLatencyBudget != 0 {
+		t.Errorf("Load() WebhookLatencyBudget = %v, want 0", cfg.Limits.WebhookLatencyBudget)
+	}
+	if cfg.Limits.WebhookIdempotencyTTL != 0 {
+		t.Errorf("Load() WebhookIdempotencyTTL = %v, want 0", cfg.Limits.WebhookIdempotencyTTL)
+	}
+}
+
+func TestLoad_UI(t *testing.T) {
+	t.Setenv("UI_ENABLED", "")
+	t.Setenv("UI_DIR", "")
+
+	cfg := Load()
+	if cfg.UI.Enabled {
+		t.Error("Load() default UI.Enabled = true, want false")
+	}
+
+	t.Setenv("UI_ENABLED", "true")
+	t.Setenv("UI_DIR", "/srv/dashboard")
+
+	cfg = Load()
+	if !cfg.UI.Enabled {
+		t.Error("Load() UI.Enabled = false, want true")
+	}
+	if cfg.UI.Dir != "/srv/dashboard" {
+		t.Errorf("Load() UI.Dir = %v, want /srv/dashboard", cfg.UI.Dir)
+	}
+}
+
+func TestLoad_SessionGroupRules(t *testing.T) {
+	rules := `[{"pattern":"^deploy/","group":"deploy"}]`
+	t.Setenv("SESSION_GROUP_RULES", rules)
+
+	cfg := Load()
+	if cfg.SessionGroupRules != rules {
+		t.Errorf("Load() SessionGroupRules = %v, want %v", cfg.SessionGroupRules, rules)
+	}
+}
+
+func TestLoad_SLAEvaluationInterval(t *testing.T) {
+	t.Setenv("SLA_EVALUATION_INTERVAL", "")
+	if cfg := Load(); cfg.SLAEvaluationInterval != time.Minute {
+		t.Errorf("Load() default SLAEvaluationInterval = %v, want 1m", cfg.SLAEvaluationInterval)
+	}
+
+	t.Setenv("SLA_EVALUATION_INTERVAL", "30s")
+	if cfg := Load(); cfg.SLAEvaluationInterval != 30*time.Second {
+		t.Errorf("Load() SLAEvaluationInterval = %v, want 30s", cfg.SLAEvaluationInterval)
+	}
+}
+
+func TestLoad_AlertEvaluationInterval(t *testing.T) {
+	t.Setenv("ALERT_EVALUATION_INTERVAL", "")
+	if cfg := Load(); cfg.AlertEvaluationInterval != time.Minute {
+		t.Errorf("Load() default AlertEvaluationInterval = %v, want 1m", cfg.AlertEvaluationInterval)
+	}
+
+	t.Setenv("ALERT_EVALUATION_INTERVAL", "0")
+	if cfg := Load(); cfg.AlertEvaluationInterval != 0 {
+		t.Errorf("Load() AlertEvaluationInterval = %v, want 0", cfg.AlertEvaluationInterval)
+	}
+}
+
+func TestLoad_NotificationCoalesceWindow(t *testing.T) {
+	t.Setenv("NOTIFICATION_COALESCE_WINDOW", "")
+	if cfg := Load(); cfg.NotificationCoalescing != 5*time.Second {
+		t.Errorf("Load() default NotificationCoalescing = %v, want 5s", cfg.NotificationCoalescing)
+	}
+
+	t.Setenv("NOTIFICATION_COALESCE_WINDOW", "0")
+	if cfg := Load(); cfg.NotificationCoalescing != 0 {
+		t.Errorf("Load() NotificationCoalescing = %v, want 0", cfg.NotificationCoalescing)
+	}
+}
+
+func TestLoad_AgentOfflineAfter(t *testing.T) {
+	t.Setenv("AGENT_OFFLINE_AFTER", "")
+	if cfg := Load(); cfg.AgentOfflineAfter != 15*time.Minute {
+		t.Errorf("Load() default AgentOfflineAfter = %v, want 15m", cfg.AgentOfflineAfter)
+	}
+
+	t.Setenv("AGENT_OFFLINE_AFTER", "0")
+	if cfg := Load(); cfg.AgentOfflineAfter != 0 {
+		t.Errorf("Load() AgentOfflineAfter = %v, want 0", cfg.AgentOfflineAfter)
+	}
+}
+
+func TestLoad_AgentPresence(t *testing.T) {
+	t.Setenv("AGENT_HEARTBEAT_INTERVAL", "")
+	t.Setenv("AGENT_OFFLINE_NOTIFY", "")
+	if cfg := Load(); cfg.AgentHeartbeatInterval != 5*time.Minute || cfg.AgentOfflineNotify {
+		t.Errorf("Load() default presence = %v, %v, want 5m and no notifications", cfg.AgentHeartbeatInterval, cfg.AgentOfflineNotify)
+	}
+
+	t.Setenv("AGENT_HEARTBEAT_INTERVAL", "90s")
+	t.Setenv("AGENT_OFFLINE_NOTIFY", "true")
+	if cfg := Load(); cfg.AgentHeartbeatInterval != 90*time.Second || !cfg.AgentOfflineNotify {
+		t.Errorf("Load() presence = %v, %v, want 90s with notifications", cfg.AgentHeartbeatInterval, cfg.AgentOfflineNotify)
+	}
+}
+
+func TestLoad_AgentDefaultKind(t *testing.T) {
+	t.Setenv("AGENT_DEFAULT_KIND", "")
+	if cfg := Load(); cfg.AgentDefaultKind != "custom" {
+		t.Errorf("Load() default AgentDefaultKind = %q, want custom", cfg.AgentDefaultKind)
+	}
+
+	t.Setenv("AGENT_DEFAULT_KIND", "llm-agent")
+	if cfg := Load(); cfg.AgentDefaultKind != "llm-agent" {
+		t.Errorf("Load() AgentDefaultKind = %q, want llm-agent", cfg.AgentDefaultKind)
+	}
+}
+
+func TestLoad_MeteringFlushInterval(t *testing.T) {
+	t.Setenv("METERING_FLUSH_INTERVAL", "")
+	if cfg := Load(); cfg.MeteringFlushInterval != time.Minute {
+		t.Errorf("Load() default MeteringFlushInterval = %v, want 1m", cfg.MeteringFlushInterval)
+	}
+
+	t.Setenv("METERING_FLUSH_INTERVAL", "0")
+	if cfg := Load(); cfg.MeteringFlushInterval != 0 {
+		t.Errorf("Load() MeteringFlushInterval = %v, want 0", cfg.MeteringFlushInterval)
+	}
+}
+
+func TestLoad_AdminEmails(t *testing.T) {
+	t.Setenv("ADMIN_EMAILS", "")
+	if cfg := Load(); len(cfg.AdminEmails) != 0 {
+		t.Errorf("Load() default AdminEmails = %v, want none", cfg.AdminEmails)
+	}
+
+	t.Setenv("ADMIN_EMAILS", "ops@example.com, lead@example.com")
+	if cfg := Load(); !reflect.DeepEqual(cfg.AdminEmails, []string{"ops@example.com", "lead@example.com"}) {
+		t.Errorf("Load() AdminEmails = %v, want both admins", cfg.AdminEmails)
+	}
+}
+
+func TestLoad_WebhookSigning(t *testing.T) {
+	t.Setenv("WEBHOOK_SIGNING_SECRET", "")
+	t.Setenv("WEBHOOK_SIGNATURE_TOLERANCE", "")
+
+	cfg := Load()
+	if cfg.WebhookSigning.Secret != "" {
+		t.Errorf("Load() default WebhookSigning.Secret = %v, want empty", cfg.WebhookSigning.Secret)
+	}
+	if cfg.WebhookSigning.Tolerance != 5*time.Minute {
+		t.Errorf("Load() default WebhookSigning.Tolerance = %v, want 5m", cfg.WebhookSigning.Tolerance)
+	}
+
+	t.Setenv("WEBHOOK_SIGNING_SECRET", "secret")
+	t.Setenv("WEBHOOK_SIGNATURE_TOLERANCE", "1m")
+
+	cfg = Load()
+	if cfg.WebhookSigning.Secret != "secret" || cfg.WebhookSigning.Tolerance != time.Minute {
+		t.Errorf("Load() WebhookSigning = %+v, want secret/1m", cfg.WebhookSigning)
+	}
+}
+
+func TestLoad_MTLS(t *testing.T) {
+	t.Setenv("MTLS_PORT", "")
+	t.Setenv("MTLS_CLIENT_CA_FILE", "")
+
+	cfg := Load()
+	if cfg.MTLS.Port != "" {
+		t.Errorf("Load() default MTLS.Port = %v, want empty", cfg.MTLS.Port)
+	}
+
+	t.Setenv("MTLS_PORT", "8443")
+	t.Setenv("MTLS_CERT_FILE", "/certs/server.crt")
+	t.Setenv("MTLS_KEY_FILE", "/certs/server.key")
+	t.Setenv("MTLS_CLIENT_CA_FILE", "/certs/ca.crt")
+
+	cfg = Load()
+	want := MTLSConfig{Port: "8443", CertFile: "/certs/server.crt", KeyFile: "/certs/server.key", ClientCAFile: "/certs/ca.crt"}
+	if cfg.MTLS != want {
+		t.Errorf("Load() MTLS = %+v, want %+v", cfg.MTLS, want)
+	}
+}
+
+func TestLoad_Payload(t *testing.T) {
+	t.Setenv("PAYLOAD_MAX_CONTENT_LENGTH", "")
+	t.Setenv("PAYLOAD_LIMIT_TIERS", "")
+
+	cfg := Load()
+	if cfg.Payload.MaxContentLength != 10000 {
+		t.Errorf("Load() default Payload.MaxContentLength = %v, want 10000", cfg.Payload.MaxContentLength)
+	}
+
+	t.Setenv("PAYLOAD_MAX_CONTENT_LENGTH", "50000")
+	t.Setenv("PAYLOAD_LIMIT_TIERS", `{"pro":{"content":100000}}`)
+
+	cfg = Load()
+	if cfg.Payload.MaxContentLength != 50000 {
+		t.Errorf("Load() Payload.MaxContentLength = %v, want 50000", cfg.Payload.MaxContentLength)
+	}
+	if cfg.Payload.Tiers != `{"pro":{"content":100000}}` {
+		t.Errorf("Load() Payload.Tiers = %v", cfg.Payload.Tiers)
+	}
+}
+
+func TestLoad_APIKeyCache(t *testing.T) {
+	t.Setenv("API_KEY_CACHE_TTL", "")
+	t.Setenv("API_KEY_USAGE_FLUSH_INTERVAL", "")
+
+	cfg := Load()
+	if cfg.APIKeyCache.TTL != 30*time.Second {
+		t.Errorf("Load() default APIKeyCache.TTL = %v, want 30s", cfg.APIKeyCache.TTL)
+	}
+	if cfg.APIKeyCache.UsageFlushInterval != 30*time.Second {
+		t.Errorf("Load() default APIKeyCache.UsageFlushInterval = %v, want 30s", cfg.APIKeyCache.UsageFlushInterval)
+	}
+
+	t.Setenv("API_KEY_CACHE_TTL", "0")
+	t.Setenv("API_KEY_USAGE_FLUSH_INTERVAL", "2m")
+
+	cfg = Load()
+	if cfg.APIKeyCache.TTL != 0 {
+		t.Errorf("Load() APIKeyCache.TTL = %v, want 0", cfg.APIKeyCache.TTL)
+	}
+	if cfg.APIKeyCache.UsageFlushInterval != 2*time.Minute {
+		t.Errorf("Load() APIKeyCache.UsageFlushInterval = %v, want 2m", cfg.APIKeyCache.UsageFlushInterval)
+	}
+}
+
+func TestLoad_Janitor(t *testing.T) {
+	t.Setenv("JANITOR_INTERVAL", "")
+	t.Setenv("SLA_BREACH_RETENTION", "")
+	t.Setenv("DELETED_AGENT_RETENTION", "")
+	t.Setenv("STATUS_RETENTION_DAYS", "")
+	t.Setenv("AUDIT_RETENTION", "")
+	t.Setenv("METRICS_ENABLED", "")
+
+	cfg := Load()
+	if cfg.Janitor.Interval != time.Hour {
+		t.Errorf("Load() default Janitor.Interval = %v, want 1h", cfg.Janitor.Interval)
+	}
+	if cfg.Janitor.SLABreachRetention != 90*24*time.Hour {
+		t.Errorf("Load() default Janitor.SLABreachRetention = %v, want 2160h", cfg.Janitor.SLABreachRetention)
+	}
+	if cfg.Janitor.DeletedAgentRetention != 30*24*time.Hour {
+		t.Errorf("Load() default Janitor.DeletedAgentRetention = %v, want 720h", cfg.Janitor.DeletedAgentRetention)
+	}
+	if cfg.Janitor.StatusRetentionDays != 0 {
+		t.Errorf("Load() default Janitor.StatusRetentionDays = %d, want 0", cfg.Janitor.StatusRetentionDays)
+	}
+	if cfg.Janitor.AuditRetention != 365*24*time.Hour {
+		t.Errorf("Load() default Janitor.AuditRetention = %v, want 8760h", cfg.Janitor.AuditRetention)
+	}
+	if cfg.MetricsEnabled {
+		t.Error("Load() default MetricsEnabled = true, want false")
+	}
+
+	t.Setenv("JANITOR_INTERVAL", "10m")
+	t.Setenv("SLA_BREACH_RETENTION", "0")
+	t.Setenv("DELETED_AGENT_RETENTION", "168h")
+	t.Setenv("STATUS_RETENTION_DAYS", "90")
+	t.Setenv("AUDIT_RETENTION", "0")
+	t.Setenv("METRICS_ENABLED", "true")
+
+	cfg = Load()
+	if cfg.Janitor.Interval != 10*time.Minute {
+		t.Errorf("Load() Janitor.Interval = %v, want 10m", cfg.Janitor.Interval)
+	}
+	if cfg.Janitor.SLABreachRetention != 0 {
+		t.Errorf("Load() Janitor.SLABreachRetention = %v, want 0", cfg.Janitor.SLABreachRetention)
+	}
+	if cfg.Janitor.DeletedAgentRetention != 7*24*time.Hour {
+		t.Errorf("Load() Janitor.DeletedAgentRetention = %v, want 168h", cfg.Janitor.DeletedAgentRetention)
+	}
+	if cfg.Janitor.StatusRetentionDays != 90 {
+		t.Errorf("Load() Janitor.StatusRetentionDays = %d, want 90", cfg.Janitor.StatusRetentionDays)
+	}
+	if cfg.Janitor.AuditRetention != 0 {
+		t.Errorf("Load() Janitor.AuditRetention = %v, want 0", cfg.Janitor.AuditRetention)
+	}
+	if !cfg.MetricsEnabled {
+		t.Error("Load() MetricsEnabled = false, want true")
+	}
+}
+
+func TestLoad_SessionReopenGrace(t *testing.T) {
+	t.Setenv("SESSION_REOPEN_GRACE", "")
+	if cfg := Load(); cfg.SessionReopenGrace != 10*time.Minute {
+		t.Errorf("Load() default SessionReopenGrace = %v, want 10m", cfg.SessionReopenGrace)
+	}
+
+	t.Setenv("SESSION_REOPEN_GRACE", "0")
+	if cfg := Load(); cfg.SessionReopenGrace != 0 {
+		t.Errorf("Load() SessionReopenGrace = %v, want 0", cfg.SessionReopenGrace)
+	}
+}
+
+func TestLoad_Health(t *testing.T) {
+	for _, key := range []string{"HEALTH_SCORE_INTERVAL", "HEALTH_SCORE_WINDOW", "HEALTH_STUCK_AFTER", "HEALTH_FAILURE_WEIGHT", "HEALTH_OFFLINE_WEIGHT", "HEALTH_STUCK_WEIGHT"} {
+		t.Setenv(key, "")
+	}
+
+	want := HealthConfig{
+		Interval:      time.Minute,
+		Window:        24 * time.Hour,
+		StuckAfter:    time.Hour,
+		FailureWeight: 0.5,
+		OfflineWeight: 0.3,
+		StuckWeight:   0.2,
+	}
+	if cfg := Load(); cfg.Health != want {
+		t.Errorf("Load() default Health = %+v, want %+v", cfg.Health, want)
+	}
+
+	t.Setenv("HEALTH_SCORE_INTERVAL", "0")
+	t.Setenv("HEALTH_SCORE_WINDOW", "168h")
+	t.Setenv("HEALTH_STUCK_AFTER", "30m")
+	t.Setenv("HEALTH_FAILURE_WEIGHT", "2")
+	t.Setenv("HEALTH_OFFLINE_WEIGHT", "1")
+	t.Setenv("HEALTH_STUCK_WEIGHT", "-1")
+
+	want = HealthConfig{
+		Window:        168 * time.Hour,
+		StuckAfter:    30 * time.Minute,
+		FailureWeight: 2,
+		OfflineWeight: 1,
+		StuckWeight:   0.2,
+	}
+	if cfg := Load(); cfg.Health != want {
+		t.Errorf("Load() Health = %+v, want %+v", cfg.Health, want)
+	}
+}
+
+func TestLoad_Outbox(t *testing.T) {
+	t.Setenv("OUTBOX_INTERVAL", "")
+	t.Setenv("OUTBOX_MAX_ATTEMPTS", "")
+	t.Setenv("OUTBOX_WORKERS", "")
+	t.Setenv("NOTIFICATION_QUEUE", "")
+
+	want := OutboxConfig{MaxAttempts: 10, Workers: 4}
+	if cfg := Load(); cfg.Outbox != want || cfg.NotificationQueue != "memory" {
+		t.Errorf("Load() default Outbox = %+v, queue %q, want %+v and memory", cfg.Outbox, cfg.NotificationQueue, want)
+	}
+
+	// The store queue runs the relay
+	t.Setenv("NOTIFICATION_QUEUE", "store")
+	want = OutboxConfig{Interval: time.Second, MaxAttempts: 10, Workers: 4}
+	if cfg := Load(); cfg.Outbox != want {
+		t.Errorf("Load() store queue Outbox = %+v, want %+v", cfg.Outbox, want)
+	}
+
+	t.Setenv("OUTBOX_INTERVAL", "2s")
+	t.Setenv("OUTBOX_MAX_ATTEMPTS", "3")
+	t.Setenv("OUTBOX_WORKERS", "8")
+
+	want = OutboxConfig{Interval: 2 * time.Second, MaxAttempts: 3, Workers: 8}
+	if cfg := Load(); cfg.Outbox != want {
+		t.Errorf("Load() Outbox = %+v, want %+v", cfg.Outbox, want)
+	}
+}
+
+func TestLoad_Replica(t *testing.T) {
+	t.Setenv("REPLICA_DATABASE_URL", "")
+	t.Setenv("REPLICA_QUEUE_SIZE", "")
+
+	want := ReplicaConfig{QueueSize: 10000}
+	if cfg := Load(); cfg.Replica != want {
+		t.Errorf("Load() default Replica = %+v, want %+v", cfg.Replica, want)
+	}
+
+	t.Setenv("REPLICA_DATABASE_URL", "postgres://archive:5432/kubeagents")
+	t.Setenv("REPLICA_QUEUE_SIZE", "500")
+
+	want = ReplicaConfig{DatabaseURL: "postgres://archive:5432/kubeagents", QueueSize: 500}
+	if cfg := Load(); cfg.Replica != want {
+		t.Errorf("Load() Replica = %+v, want %+v", cfg.Replica, want)
+	}
+}
+
+func TestLoad_Archive(t *testing.T) {
+	t.Setenv("ARCHIVE_URL", "gs://audit/kubeagents")
+	t.Setenv("ARCHIVE_ENDPOINT", "")
+	t.Setenv("ARCHIVE_REGION", "")
+	t.Setenv("ARCHIVE_ACCESS_KEY_ID", "GOOG1EXAMPLE")
+	t.Setenv("ARCHIVE_SECRET_ACCESS_KEY", "secret")
+
+	want := ArchiveConfig{URL: "gs://audit/kubeagents", AccessKeyID: "GOOG1EXAMPLE", SecretAccessKey: "secret"}
+	if cfg := Load(); cfg.Archive != want {
+		t.Errorf("Load() Archive = %+v, want %+v", cfg.Archive, want)
+	}
+}
+
+func TestLoad_EncryptionMasterKey(t *testing.T) {
+	t.Setenv("ENCRYPTION_MASTER_KEY", "")
+	if cfg := Load(); cfg.EncryptionMasterKey != "" {
+		t.Errorf("Load() default EncryptionMasterKey = %q, want empty", cfg.EncryptionMasterKey)
+	}
+
+	t.Setenv("ENCRYPTION_MASTER_KEY", "bWFzdGVyLWtleQ==")
+	if cfg := Load(); cfg.EncryptionMasterKey != "bWFzdGVyLWtleQ==" {
+		t.Errorf("Load() EncryptionMasterKey = %q, want the configured key", cfg.EncryptionMasterKey)
+	}
+}
+
+func TestLoad_AgentMetricsEnabled(t *testing.T) {
+	t.Setenv("AGENT_METRICS_ENABLED", "")
+	if cfg := Load(); cfg.AgentMetricsEnabled {
+		t.Error("Load() default AgentMetricsEnabled = true, want false")
+	}
+
+	t.Setenv("AGENT_METRICS_ENABLED", "true")
+	if cfg := Load(); !cfg.AgentMetricsEnabled {
+		t.Error("Load() AgentMetricsEnabled = false, want true")
+	}
+}
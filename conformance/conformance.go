@@ -0,0 +1,39 @@
+// Package conformance ships the status report payloads reporter SDKs are checked against
+// payloads.json lists accepted and rejected /webhook/status bodies for every field and edge case. SDKs in other
+// languages can read the file directly, or send each payload to /webhook/validate on a running server.
+package conformance
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+//go:embed payloads.json
+var payloads []byte
+
+// Case is a single status report payload and the server's expected verdict
+type Case struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Payload     json.RawMessage `json:"payload"`
+	Accepted    bool            `json:"accepted"`
+	Error       string          `json:"error,omitempty"` // Exact error message of a rejected payload, when stable
+}
+
+// Suite is the contents of payloads.json
+type Suite struct {
+	Version          int    `json:"version"`
+	Endpoint         string `json:"endpoint"`
+	ValidateEndpoint string `json:"validate_endpoint"`
+	Cases            []Case `json:"cases"`
+}
+
+// Load parses the embedded payloads.json
+func Load() (*Suite, error) {
+	var suite Suite
+	if err := json.Unmarshal(payloads, &suite); err != nil {
+		return nil, fmt.Errorf("parse payloads.json: %w", err)
+	}
+	return &suite, nil
+}
@@ -0,0 +1,82 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/handlers"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// post sends a payload to one of the webhook handlers as an authenticated user
+func post(handler http.HandlerFunc, path string, payload []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	caller := middleware.NewRequestContext(req, "sdk-user", "sdk@example.com", nil)
+	req = req.WithContext(middleware.WithRequestContext(req.Context(), caller))
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+// The fixtures stay in sync with the server: /webhook/validate and /webhook/status agree with every verdict
+func TestSuite(t *testing.T) {
+	suite, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(suite.Cases) == 0 {
+		t.Fatal("Load() returned no cases")
+	}
+
+	st := store.NewMemoryStore()
+	now := time.Now()
+	if err := st.CreateUser(&models.User{ID: "sdk-user", Email: "sdk@example.com", PasswordHash: "x", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	handler := handlers.NewWebhookHandlerWithNotifier(st, nil)
+
+	names := make(map[string]bool)
+	for _, c := range suite.Cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if names[c.Name] {
+				t.Fatalf("duplicate case name %q", c.Name)
+			}
+			names[c.Name] = true
+
+			want := http.StatusBadRequest
+			if c.Accepted {
+				want = http.StatusOK
+			}
+			for _, endpoint := range []struct {
+				path    string
+				handler http.HandlerFunc
+			}{
+				{suite.ValidateEndpoint, handler.ServeValidate},
+				{suite.Endpoint, handler.ServeHTTP},
+			} {
+				rr := post(endpoint.handler, endpoint.path, c.Payload)
+				if rr.Code != want {
+					t.Errorf("%s status = %v, want %v: %s", endpoint.path, rr.Code, want, rr.Body.String())
+					continue
+				}
+				if c.Error == "" {
+					continue
+				}
+				var body handlers.ErrorResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decode %s error: %v", endpoint.path, err)
+				}
+				if body.Message != c.Error {
+					t.Errorf("%s error = %q, want %q", endpoint.path, body.Message, c.Error)
+				}
+			}
+		})
+	}
+}
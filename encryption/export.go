@@ -0,0 +1,176 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ExportFormat identifies encrypted exports in their envelope
+const ExportFormat = "kubeagents-encrypted-export-v1"
+
+// Algorithms encrypting exports
+const (
+	// AlgorithmSealedBox encrypts to an X25519 public key as libsodium's crypto_box_seal does,
+	// so the holder of the private key decrypts it with any libsodium binding
+	AlgorithmSealedBox = "x25519-xsalsa20-poly1305"
+	// AlgorithmPassphrase encrypts with AES-256-GCM under a key derived from a passphrase with scrypt
+	AlgorithmPassphrase = "scrypt-aes-256-gcm"
+)
+
+// MinPassphraseLength is the shortest passphrase exports are encrypted with
+const MinPassphraseLength = 12
+
+// scrypt parameters of passphrase keys, as recommended for interactive use
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptSaltLen = 16
+)
+
+// ErrExportKey is returned when an encrypted export cannot be opened with the key given
+var ErrExportKey = errors.New("wrong key or corrupted export")
+
+// ExportEnvelope is an encrypted export, kept as JSON so the header says how to decrypt it
+type ExportEnvelope struct {
+	Format      string `json:"format"` // Always ExportFormat
+	Algorithm   string `json:"algorithm"`
+	KeyID       string `json:"key_id,omitempty"` // Fingerprint of the public key, so the recipient picks its private key
+	Salt        []byte `json:"salt,omitempty"`   // scrypt salt of passphrase encryption
+	ContentType string `json:"content_type"`     // Of the decrypted export
+	Ciphertext  []byte `json:"ciphertext"`
+}
+
+// ExportKey encrypts exports to a public key or with a passphrase; the public key wins when both are set
+type ExportKey struct {
+	PublicKey  *[32]byte
+	Passphrase string
+}
+
+// IsZero reports whether the key encrypts nothing, leaving exports in the clear
+func (k ExportKey) IsZero() bool {
+	return k.PublicKey == nil && k.Passphrase == ""
+}
+
+// ParsePublicKey decodes a base64-encoded X25519 public key
+func ParsePublicKey(encoded string) (*[32]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("public key must be a base64-encoded 32-byte X25519 key")
+	}
+	var key [32]byte
+	copy(key[:], raw)
+	return &key, nil
+}
+
+// ValidatePassphrase checks a passphrase exports are encrypted with
+func ValidatePassphrase(passphrase string) error {
+	if len(passphrase) < MinPassphraseLength {
+		return fmt.Errorf("passphrase must be at least %d characters", MinPassphraseLength)
+	}
+	return nil
+}
+
+// KeyFingerprint identifies a public key: the first 8 bytes of its SHA-256, in hex
+func KeyFingerprint(publicKey *[32]byte) string {
+	sum := sha256.Sum256(publicKey[:])
+	return hex.EncodeToString(sum[:8])
+}
+
+// SealExport encrypts an export of contentType with key and returns its JSON envelope
+func SealExport(plaintext []byte, contentType string, key ExportKey) ([]byte, error) {
+	envelope := &ExportEnvelope{Format: ExportFormat, ContentType: contentType}
+	switch {
+	case key.PublicKey != nil:
+		sealed, err := box.SealAnonymous(nil, plaintext, key.PublicKey, rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt export: %w", err)
+		}
+		envelope.Algorithm = AlgorithmSealedBox
+		envelope.KeyID = KeyFingerprint(key.PublicKey)
+		envelope.Ciphertext = sealed
+	case key.Passphrase != "":
+		if err := ValidatePassphrase(key.Passphrase); err != nil {
+			return nil, err
+		}
+		salt := make([]byte, scryptSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		aead, err := passphraseAEAD(key.Passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		sealed, err := seal(aead, plaintext, []byte(ExportFormat+contentType))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt export: %w", err)
+		}
+		envelope.Algorithm = AlgorithmPassphrase
+		envelope.Salt = salt
+		envelope.Ciphertext = sealed
+	default:
+		return nil, errors.New("a public key or passphrase is required to encrypt an export")
+	}
+	return json.Marshal(envelope)
+}
+
+// OpenExport decrypts an export sealed by SealExport, returning it with its content type
+// Exports sealed to a public key need privateKey, the others passphrase.
+func OpenExport(sealed []byte, privateKey *[32]byte, passphrase string) ([]byte, string, error) {
+	var envelope ExportEnvelope
+	if err := json.Unmarshal(sealed, &envelope); err != nil || envelope.Format != ExportFormat {
+		return nil, "", errors.New("not an encrypted export")
+	}
+
+	switch envelope.Algorithm {
+	case AlgorithmSealedBox:
+		if privateKey == nil {
+			return nil, "", errors.New("export is encrypted to a public key, its private key is required")
+		}
+		var publicKey [32]byte
+		derived, err := curve25519.X25519(privateKey[:], curve25519.Basepoint)
+		if err != nil {
+			return nil, "", ErrExportKey
+		}
+		copy(publicKey[:], derived)
+		plaintext, ok := box.OpenAnonymous(nil, envelope.Ciphertext, &publicKey, privateKey)
+		if !ok {
+			return nil, "", ErrExportKey
+		}
+		return plaintext, envelope.ContentType, nil
+	case AlgorithmPassphrase:
+		if passphrase == "" {
+			return nil, "", errors.New("export is encrypted with a passphrase, the passphrase is required")
+		}
+		aead, err := passphraseAEAD(passphrase, envelope.Salt)
+		if err != nil {
+			return nil, "", err
+		}
+		plaintext, err := open(aead, envelope.Ciphertext, []byte(ExportFormat+envelope.ContentType))
+		if err != nil {
+			return nil, "", ErrExportKey
+		}
+		return plaintext, envelope.ContentType, nil
+	default:
+		return nil, "", fmt.Errorf("unknown export algorithm %q", envelope.Algorithm)
+	}
+}
+
+// passphraseAEAD derives the AES-256-GCM key of a passphrase and salt
+func passphraseAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive export key: %w", err)
+	}
+	return newAEAD(key)
+}
@@ -0,0 +1,78 @@
+package encryption
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+func TestSealExport_RoundTrip(t *testing.T) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	_, otherKey, _ := box.GenerateKey(rand.Reader)
+	plaintext := []byte(`{"version":1}`)
+
+	tests := []struct {
+		name      string
+		key       ExportKey
+		algorithm string
+		open      func(sealed []byte) ([]byte, string, error)
+		wrong     func(sealed []byte) ([]byte, string, error)
+	}{
+		{
+			name:      "public key",
+			key:       ExportKey{PublicKey: publicKey},
+			algorithm: AlgorithmSealedBox,
+			open:      func(sealed []byte) ([]byte, string, error) { return OpenExport(sealed, privateKey, "") },
+			wrong:     func(sealed []byte) ([]byte, string, error) { return OpenExport(sealed, otherKey, "") },
+		},
+		{
+			name:      "passphrase",
+			key:       ExportKey{Passphrase: "correct horse battery"},
+			algorithm: AlgorithmPassphrase,
+			open:      func(sealed []byte) ([]byte, string, error) { return OpenExport(sealed, nil, "correct horse battery") },
+			wrong:     func(sealed []byte) ([]byte, string, error) { return OpenExport(sealed, nil, "wrong horse battery") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealed, err := SealExport(plaintext, "application/json", tt.key)
+			if err != nil {
+				t.Fatalf("SealExport() error = %v", err)
+			}
+			var envelope ExportEnvelope
+			if err := json.Unmarshal(sealed, &envelope); err != nil || envelope.Format != ExportFormat || envelope.Algorithm != tt.algorithm {
+				t.Fatalf("SealExport() envelope = %+v, %v, want %s", envelope, err, tt.algorithm)
+			}
+
+			opened, contentType, err := tt.open(sealed)
+			if err != nil || string(opened) != string(plaintext) || contentType != "application/json" {
+				t.Errorf("OpenExport() = %q, %q, %v, want the export", opened, contentType, err)
+			}
+			if _, _, err := tt.wrong(sealed); !errors.Is(err, ErrExportKey) {
+				t.Errorf("OpenExport() with the wrong key error = %v, want %v", err, ErrExportKey)
+			}
+		})
+	}
+}
+
+func TestSealExport_Rejects(t *testing.T) {
+	if _, err := SealExport([]byte("x"), "text/plain", ExportKey{}); err == nil {
+		t.Error("SealExport() without a key succeeded, want an error")
+	}
+	if _, err := SealExport([]byte("x"), "text/plain", ExportKey{Passphrase: "short"}); err == nil {
+		t.Error("SealExport() with a short passphrase succeeded, want an error")
+	}
+	if _, err := ParsePublicKey("c2hvcnQ="); err == nil {
+		t.Error("ParsePublicKey() of a short key succeeded, want an error")
+	}
+	if _, _, err := OpenExport([]byte("plain"), nil, "correct horse battery"); err == nil {
+		t.Error("OpenExport() of a plain file succeeded, want an error")
+	}
+}
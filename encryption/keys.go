@@ -0,0 +1,77 @@
+// Package encryption encrypts sensitive status fields at rest with per-user data keys wrapped by a master key
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeyWrapper wraps and unwraps data keys with a master key
+// A KMS can be used by implementing it with the KMS encrypt and decrypt calls.
+type KeyWrapper interface {
+	Wrap(dataKey []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyWrapper wraps data keys with a master key held in process memory
+type LocalKeyWrapper struct {
+	aead cipher.AEAD
+}
+
+// NewLocalKeyWrapper creates a wrapper from a base64-encoded 32-byte master key
+func NewLocalKeyWrapper(encodedKey string) (*LocalKeyWrapper, error) {
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("master key must be base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, errors.New("master key must be 32 bytes")
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalKeyWrapper{aead: aead}, nil
+}
+
+// Wrap encrypts a data key
+func (w *LocalKeyWrapper) Wrap(dataKey []byte) ([]byte, error) {
+	return seal(w.aead, dataKey, nil)
+}
+
+// Unwrap decrypts a data key
+func (w *LocalKeyWrapper) Unwrap(wrapped []byte) ([]byte, error) {
+	return open(w.aead, wrapped, nil)
+}
+
+// newAEAD creates AES-256-GCM for a 32-byte key
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce, which is prepended to the result
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the result of seal
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
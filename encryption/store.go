@@ -0,0 +1,433 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// ciphertextPrefix marks an encrypted field; the owner's user ID and the sealed value follow it
+// Values without the prefix were stored before encryption was enabled and are returned as they are.
+const ciphertextPrefix = "enc:v1:"
+
+// Store encrypts the message and content of statuses with their agent owner's data key, as well as the outbox
+// payloads derived from them and the messages of inbox items, which are sealed with their recipient's key
+// Other records pass through to the wrapped store unchanged.
+type Store struct {
+	store.Store
+	wrapper KeyWrapper
+
+	mu   sync.Mutex
+	keys map[string]cipher.AEAD // user_id -> unwrapped data key
+}
+
+// NewStore wraps st so status message and content, and the records repeating them, are encrypted at rest
+func NewStore(st store.Store, wrapper KeyWrapper) *Store {
+	return &Store{
+		Store:   st,
+		wrapper: wrapper,
+		keys:    make(map[string]cipher.AEAD),
+	}
+}
+
+// AddStatus encrypts and adds a status
+func (s *Store) AddStatus(status *models.AgentStatus) error {
+	encrypted, err := s.encryptStatus(status)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.AddStatus(encrypted); err != nil {
+		return err
+	}
+	status.ID = encrypted.ID
+	return nil
+}
+
+// AddStatusWithOutbox encrypts and adds a status together with its side effects, sealing their payloads with the
+// same key as the status
+func (s *Store) AddStatusWithOutbox(status *models.AgentStatus, messages []*models.OutboxMessage) error {
+	encrypted, err := s.encryptStatus(status)
+	if err != nil {
+		return err
+	}
+	sealed := make([]*models.OutboxMessage, len(messages))
+	for i, message := range messages {
+		if sealed[i], err = s.encryptOutboxMessage(status.AgentID, message); err != nil {
+			return err
+		}
+	}
+	if err := s.Store.AddStatusWithOutbox(encrypted, sealed); err != nil {
+		return err
+	}
+	status.ID = encrypted.ID
+	for i, message := range messages {
+		message.ID, message.AvailableAt = sealed[i].ID, sealed[i].AvailableAt
+	}
+	return nil
+}
+
+// AddOutboxMessage seals a message's payload with the key of its agent's owner and records it
+func (s *Store) AddOutboxMessage(message *models.OutboxMessage) error {
+	sealed, err := s.encryptOutboxMessage(message.AgentID, message)
+	if err != nil {
+		return err
+	}
+	if err := s.Store.AddOutboxMessage(sealed); err != nil {
+		return err
+	}
+	message.ID, message.AvailableAt = sealed.ID, sealed.AvailableAt
+	return nil
+}
+
+// ClaimOutboxMessages claims due messages and opens their sealed payloads
+func (s *Store) ClaimOutboxMessages(now time.Time, lease time.Duration, limit int) ([]*models.OutboxMessage, error) {
+	messages, err := s.Store.ClaimOutboxMessages(now, lease, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		if err := s.decryptOutboxMessage(message); err != nil {
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// CreateInboxItem encrypts an item's message with its recipient's data key and creates it
+func (s *Store) CreateInboxItem(item *models.InboxItem) error {
+	encrypted := *item
+	if item.Message != "" && item.UserID != "" {
+		aead, err := s.dataKey(item.UserID, true)
+		if err != nil {
+			return err
+		}
+		if encrypted.Message, err = encryptField(aead, item.UserID, item.Message, inboxAdditionalData(item)); err != nil {
+			return err
+		}
+	}
+	return s.Store.CreateInboxItem(&encrypted)
+}
+
+// ListInboxItems returns a user's items with their messages decrypted
+func (s *Store) ListInboxItems(userID string, unreadOnly bool, limit int) ([]*models.InboxItem, error) {
+	items, err := s.Store.ListInboxItems(userID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if err := s.decryptField(&item.Message, inboxAdditionalData(item)); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// ListInboxItemsPage returns one page of a user's items with their messages decrypted
+func (s *Store) ListInboxItemsPage(userID string, unreadOnly bool, page store.Page) ([]*models.InboxItem, int, error) {
+	items, total, err := s.Store.ListInboxItemsPage(userID, unreadOnly, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, item := range items {
+		if err := s.decryptField(&item.Message, inboxAdditionalData(item)); err != nil {
+			return nil, 0, err
+		}
+	}
+	return items, total, nil
+}
+
+// GetStatusHistory returns a session's decrypted statuses
+func (s *Store) GetStatusHistory(agentID, sessionTopic string) ([]*models.AgentStatus, error) {
+	history, err := s.Store.GetStatusHistory(agentID, sessionTopic)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range history {
+		if err := s.decryptStatus(status); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+// GetStatusHistoryPage returns one page of a session's decrypted statuses
+func (s *Store) GetStatusHistoryPage(agentID, sessionTopic string, page store.Page) ([]*models.AgentStatus, int, error) {
+	history, total, err := s.Store.GetStatusHistoryPage(agentID, sessionTopic, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, status := range history {
+		if err := s.decryptStatus(status); err != nil {
+			return nil, 0, err
+		}
+	}
+	return history, total, nil
+}
+
+// ListStatusesBetween returns the decrypted statuses of every session reported in a period
+func (s *Store) ListStatusesBetween(from, to time.Time, limit int) ([]*models.AgentStatus, error) {
+	statuses, err := s.Store.ListStatusesBetween(from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if err := s.decryptStatus(status); err != nil {
+			return nil, err
+		}
+	}
+	return statuses, nil
+}
+
+// GetLatestStatus returns a session's decrypted latest status
+func (s *Store) GetLatestStatus(agentID, sessionTopic string) (*models.AgentStatus, error) {
+	status, err := s.Store.GetLatestStatus(agentID, sessionTopic)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decryptStatus(status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// GetRunProgress returns the progress of a session run with its latest status decrypted
+func (s *Store) GetRunProgress(agentID, sessionTopic string, revision int) (*models.AgentStatus, time.Time, error) {
+	latest, runningSince, err := s.Store.GetRunProgress(agentID, sessionTopic, revision)
+	if err != nil || latest == nil {
+		return latest, runningSince, err
+	}
+	if err := s.decryptStatus(latest); err != nil {
+		return nil, time.Time{}, err
+	}
+	return latest, runningSince, nil
+}
+
+// ListRunningSessions returns the user's running sessions with their latest status decrypted
+func (s *Store) ListRunningSessions(userID string) ([]*models.RunningSession, error) {
+	running, err := s.Store.ListRunningSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range running {
+		if session.Latest == nil {
+			continue
+		}
+		if err := s.decryptStatus(session.Latest); err != nil {
+			return nil, err
+		}
+	}
+	return running, nil
+}
+
+// GetOverview returns the user's overview with its recent failures decrypted
+func (s *Store) GetOverview(userID string, since time.Time, failureLimit int) (*models.Overview, error) {
+	overview, err := s.Store.GetOverview(userID, since, failureLimit)
+	if err != nil {
+		return nil, err
+	}
+	for _, failure := range overview.RecentFailures {
+		if err := s.decryptStatus(failure.Status); err != nil {
+			return nil, err
+		}
+	}
+	return overview, nil
+}
+
+// encryptStatus returns a copy of the status with message and content encrypted
+// Statuses of agents without an owner are stored as they are, since there is no data key to use.
+func (s *Store) encryptStatus(status *models.AgentStatus) (*models.AgentStatus, error) {
+	if err := status.Validate(); err != nil {
+		return nil, err
+	}
+	if status.Message == "" && status.Content == "" {
+		return status, nil
+	}
+
+	userID, aead, err := s.ownerKey(status.AgentID)
+	if err != nil || aead == nil {
+		return status, err
+	}
+
+	encrypted := *status
+	additionalData := statusAdditionalData(status)
+	if encrypted.Message, err = encryptField(aead, userID, status.Message, additionalData); err != nil {
+		return nil, err
+	}
+	if encrypted.Content, err = encryptField(aead, userID, status.Content, additionalData); err != nil {
+		return nil, err
+	}
+	return &encrypted, nil
+}
+
+// decryptStatus replaces encrypted message and content with their plaintext
+func (s *Store) decryptStatus(status *models.AgentStatus) error {
+	additionalData := statusAdditionalData(status)
+	for _, field := range []*string{&status.Message, &status.Content} {
+		if err := s.decryptField(field, additionalData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encryptOutboxMessage returns a copy of the message with its payload sealed as a JSON string, so it still fits
+// a JSON column; messages without an owned agent are stored as they are
+func (s *Store) encryptOutboxMessage(agentID string, message *models.OutboxMessage) (*models.OutboxMessage, error) {
+	if agentID == "" {
+		return message, nil
+	}
+	userID, aead, err := s.ownerKey(agentID)
+	if err != nil || aead == nil {
+		return message, err
+	}
+
+	field, err := encryptField(aead, userID, string(message.Payload), []byte(message.Kind))
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(field)
+	if err != nil {
+		return nil, err
+	}
+	sealed := *message
+	sealed.Payload = payload
+	return &sealed, nil
+}
+
+// decryptOutboxMessage replaces a sealed payload with its plaintext JSON
+func (s *Store) decryptOutboxMessage(message *models.OutboxMessage) error {
+	if !bytes.HasPrefix(message.Payload, []byte(`"`+ciphertextPrefix)) {
+		return nil
+	}
+	var field string
+	if err := json.Unmarshal(message.Payload, &field); err != nil {
+		return fmt.Errorf("malformed encrypted outbox payload: %w", err)
+	}
+	if err := s.decryptField(&field, []byte(message.Kind)); err != nil {
+		return err
+	}
+	message.Payload = json.RawMessage(field)
+	return nil
+}
+
+// ownerKey returns the owner of an agent and their data key, creating it if needed
+// It returns a nil key for agents without an owner, or that do not exist, since there is no data key to use.
+func (s *Store) ownerKey(agentID string) (string, cipher.AEAD, error) {
+	agent, err := s.Store.GetAgent(agentID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return "", nil, nil
+		}
+		return "", nil, fmt.Errorf("failed to load agent for encryption: %w", err)
+	}
+	if agent.UserID == "" {
+		return "", nil, nil
+	}
+
+	aead, err := s.dataKey(agent.UserID, true)
+	if err != nil {
+		return "", nil, err
+	}
+	return agent.UserID, aead, nil
+}
+
+// decryptField replaces an encrypted value with its plaintext; values stored in the clear are left as they are
+func (s *Store) decryptField(field *string, additionalData []byte) error {
+	if !strings.HasPrefix(*field, ciphertextPrefix) {
+		return nil
+	}
+
+	userID, encoded, ok := strings.Cut(strings.TrimPrefix(*field, ciphertextPrefix), ":")
+	if !ok {
+		return errors.New("malformed encrypted field")
+	}
+	aead, err := s.dataKey(userID, false)
+	if err != nil {
+		return err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("malformed encrypted field: %w", err)
+	}
+	plaintext, err := open(aead, sealed, additionalData)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt field: %w", err)
+	}
+	*field = string(plaintext)
+	return nil
+}
+
+// dataKey returns the user's data key, creating it when create is set and the user has none
+func (s *Store) dataKey(userID string, create bool) (cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if aead, ok := s.keys[userID]; ok {
+		return aead, nil
+	}
+
+	wrapped, err := s.Store.GetUserDataKey(userID)
+	if errors.Is(err, store.ErrNotFound) && create {
+		wrapped, err = s.createDataKey(userID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data key: %w", err)
+	}
+
+	key, err := s.wrapper.Unwrap(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	s.keys[userID] = aead
+	return aead, nil
+}
+
+// createDataKey generates and stores a wrapped data key, returning the key stored for the user
+// The returned key differs from the generated one if another replica stored a key first.
+func (s *Store) createDataKey(userID string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	wrapped, err := s.wrapper.Wrap(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return s.Store.SetUserDataKey(userID, wrapped)
+}
+
+// encryptField seals a non-empty value for its owner
+func encryptField(aead cipher.AEAD, userID, value string, additionalData []byte) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	sealed, err := seal(aead, []byte(value), additionalData)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt field: %w", err)
+	}
+	return ciphertextPrefix + userID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// inboxAdditionalData binds an inbox message to its recipient and event
+func inboxAdditionalData(item *models.InboxItem) []byte {
+	return []byte(item.UserID + "\x00" + item.DedupeKey)
+}
+
+// statusAdditionalData binds a ciphertext to its session, so it cannot be moved to another one
+func statusAdditionalData(status *models.AgentStatus) []byte {
+	return []byte(status.AgentID + "\x00" + status.SessionTopic)
+}
@@ -0,0 +1,210 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+	"github.com/kubeagents/kubeagents/store/storetest"
+)
+
+// testMasterKey is a base64-encoded 32-byte master key
+var testMasterKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+// setupEncryptedStore creates an encrypting store over a memory store with one owned and one unowned agent
+func setupEncryptedStore(t *testing.T) (*Store, *store.MemoryStore) {
+	t.Helper()
+
+	wrapper, err := NewLocalKeyWrapper(testMasterKey)
+	if err != nil {
+		t.Fatalf("NewLocalKeyWrapper() error = %v", err)
+	}
+
+	inner := store.NewMemoryStore()
+	now := time.Now()
+	if err := inner.CreateUser(&models.User{ID: "user-1", Email: "alice@example.com", PasswordHash: "hash", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	for _, agent := range []*models.Agent{
+		{AgentID: "agent-1", UserID: "user-1", Name: "Builder", Registered: now, LastSeen: now},
+		{AgentID: "legacy", Name: "Legacy", Registered: now, LastSeen: now},
+	} {
+		if err := inner.CreateOrUpdateAgent(agent); err != nil {
+			t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+		}
+		if err := inner.CreateOrUpdateSession(&models.Session{AgentID: agent.AgentID, SessionTopic: "task-1", Created: now, LastUpdated: now, TTLMinutes: 30}); err != nil {
+			t.Fatalf("CreateOrUpdateSession() error = %v", err)
+		}
+	}
+	return NewStore(inner, wrapper), inner
+}
+
+func TestStore_EncryptsMessageAndContent(t *testing.T) {
+	st, inner := setupEncryptedStore(t)
+
+	status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "failed", Timestamp: time.Now(), Message: "password rejected", Content: "token=secret"}
+	if err := st.AddStatus(status); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+	if status.Message != "password rejected" {
+		t.Errorf("AddStatus() changed the caller's status to %q", status.Message)
+	}
+
+	raw, _ := inner.GetLatestStatus("agent-1", "task-1")
+	for _, field := range []string{raw.Message, raw.Content} {
+		if !strings.HasPrefix(field, ciphertextPrefix+"user-1:") || strings.Contains(field, "secret") || strings.Contains(field, "password") {
+			t.Errorf("stored field = %q, want ciphertext", field)
+		}
+	}
+	if _, err := inner.GetUserDataKey("user-1"); err != nil {
+		t.Errorf("GetUserDataKey() error = %v, want a stored key", err)
+	}
+
+	latest, err := st.GetLatestStatus("agent-1", "task-1")
+	if err != nil {
+		t.Fatalf("GetLatestStatus() error = %v", err)
+	}
+	if latest.Message != "password rejected" || latest.Content != "token=secret" {
+		t.Errorf("GetLatestStatus() = %q, %q, want the plaintext", latest.Message, latest.Content)
+	}
+
+	history, err := st.GetStatusHistory("agent-1", "task-1")
+	if err != nil || len(history) != 1 || history[0].Content != "token=secret" {
+		t.Errorf("GetStatusHistory() = %+v, %v, want the plaintext", history, err)
+	}
+	page, total, err := st.GetStatusHistoryPage("agent-1", "task-1", store.Page{Limit: 1})
+	if err != nil || total != 1 || len(page) != 1 || page[0].Content != "token=secret" {
+		t.Errorf("GetStatusHistoryPage() = %+v, %d, %v, want the plaintext", page, total, err)
+	}
+}
+
+func TestStore_EncryptsOutboxAndInbox(t *testing.T) {
+	st, inner := setupEncryptedStore(t)
+
+	now := time.Now().UTC()
+	status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "failed", Timestamp: now, Message: "password rejected"}
+	notification := &models.OutboxMessage{Kind: models.OutboxKindNotification, Payload: json.RawMessage(`{"message":"password rejected"}`), CreatedAt: now}
+	if err := st.AddStatusWithOutbox(status, []*models.OutboxMessage{notification}); err != nil {
+		t.Fatalf("AddStatusWithOutbox() error = %v", err)
+	}
+	delivery := &models.OutboxMessage{Kind: models.OutboxKindDelivery, Payload: json.RawMessage(`{"payload":"token=secret"}`), AgentID: "agent-1", CreatedAt: now}
+	if err := st.AddOutboxMessage(delivery); err != nil {
+		t.Fatalf("AddOutboxMessage() error = %v", err)
+	}
+	if notification.ID == 0 || delivery.ID == 0 {
+		t.Errorf("outbox message IDs = %d, %d, want them set", notification.ID, delivery.ID)
+	}
+	item := &models.InboxItem{ID: "item-1", UserID: "user-1", Kind: models.InboxKindFailure, AgentID: "agent-1", Message: "password rejected", DedupeKey: "failure:1", CreatedAt: now}
+	if err := st.CreateInboxItem(item); err != nil {
+		t.Fatalf("CreateInboxItem() error = %v", err)
+	}
+
+	raw, err := inner.ClaimOutboxMessages(now, time.Minute, 10)
+	if err != nil || len(raw) != 2 {
+		t.Fatalf("ClaimOutboxMessages() = %d messages, %v, want 2", len(raw), err)
+	}
+	for _, message := range raw {
+		if !strings.HasPrefix(string(message.Payload), `"`+ciphertextPrefix+"user-1:") || strings.Contains(string(message.Payload), "secret") || strings.Contains(string(message.Payload), "password") {
+			t.Errorf("stored outbox payload = %s, want ciphertext", message.Payload)
+		}
+	}
+	rawItems, _ := inner.ListInboxItems("user-1", false, 0)
+	if len(rawItems) != 1 || !strings.HasPrefix(rawItems[0].Message, ciphertextPrefix+"user-1:") {
+		t.Errorf("stored inbox items = %+v, want a ciphertext message", rawItems)
+	}
+
+	claimed, err := st.ClaimOutboxMessages(now.Add(time.Hour), time.Minute, 10)
+	if err != nil || len(claimed) != 2 {
+		t.Fatalf("ClaimOutboxMessages() = %d messages, %v, want 2", len(claimed), err)
+	}
+	if string(claimed[0].Payload) != string(notification.Payload) || string(claimed[1].Payload) != string(delivery.Payload) {
+		t.Errorf("ClaimOutboxMessages() payloads = %s, %s, want the plaintext", claimed[0].Payload, claimed[1].Payload)
+	}
+	items, err := st.ListInboxItems("user-1", false, 0)
+	if err != nil || len(items) != 1 || items[0].Message != "password rejected" {
+		t.Errorf("ListInboxItems() = %+v, %v, want the plaintext message", items, err)
+	}
+	page, total, err := st.ListInboxItemsPage("user-1", false, store.Page{Limit: 1})
+	if err != nil || total != 1 || len(page) != 1 || page[0].Message != "password rejected" {
+		t.Errorf("ListInboxItemsPage() = %+v, %d, %v, want the plaintext message", page, total, err)
+	}
+}
+
+func TestStore_Conformance(t *testing.T) {
+	wrapper, err := NewLocalKeyWrapper(testMasterKey)
+	if err != nil {
+		t.Fatalf("NewLocalKeyWrapper() error = %v", err)
+	}
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return NewStore(store.NewMemoryStore(), wrapper)
+	})
+}
+
+func TestStore_ReadsWithNewInstance(t *testing.T) {
+	st, inner := setupEncryptedStore(t)
+
+	status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: time.Now(), Message: "halfway"}
+	if err := st.AddStatus(status); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+
+	// Another replica unwraps the stored data key with the same master key
+	wrapper, _ := NewLocalKeyWrapper(testMasterKey)
+	running, err := NewStore(inner, wrapper).ListRunningSessions("user-1")
+	if err != nil {
+		t.Fatalf("ListRunningSessions() error = %v", err)
+	}
+	if len(running) != 1 || running[0].Latest.Message != "halfway" {
+		t.Errorf("ListRunningSessions() = %+v, want the decrypted latest status", running)
+	}
+
+	other, _ := NewLocalKeyWrapper(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")))
+	if _, err := NewStore(inner, other).GetLatestStatus("agent-1", "task-1"); err == nil {
+		t.Error("GetLatestStatus() with another master key error = nil, want an error")
+	}
+}
+
+func TestStore_PlaintextPassesThrough(t *testing.T) {
+	st, inner := setupEncryptedStore(t)
+
+	// Agents without an owner have no data key
+	status := &models.AgentStatus{AgentID: "legacy", SessionTopic: "task-1", Status: "running", Timestamp: time.Now(), Message: "visible"}
+	if err := st.AddStatus(status); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+	if raw, _ := inner.GetLatestStatus("legacy", "task-1"); raw.Message != "visible" {
+		t.Errorf("stored message = %q, want plaintext for an agent without owner", raw.Message)
+	}
+
+	// Statuses stored before encryption was enabled are read as they are
+	if err := inner.AddStatus(&models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: time.Now(), Message: "old"}); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+	if latest, err := st.GetLatestStatus("agent-1", "task-1"); err != nil || latest.Message != "old" {
+		t.Errorf("GetLatestStatus() = %+v, %v, want the plaintext status", latest, err)
+	}
+}
+
+func TestNewLocalKeyWrapper(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"valid", testMasterKey, false},
+		{"not base64", "not base64!", true},
+		{"too short", base64.StdEncoding.EncodeToString([]byte("short")), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewLocalKeyWrapper(tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("NewLocalKeyWrapper() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Package events fans out agent changes to live subscribers such as dashboard streams
+// Events are delivered within one server instance, and to other replicas when a relay carries them
+// there; relayed events are best effort, so clients reconnecting should re-read the agent's sessions
+// to catch up.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before new ones are dropped for it
+const subscriberBuffer = 64
+
+// TypeStatus is the event sent when an agent's status was recorded
+const TypeStatus = "status"
+
+// Event is a change of an agent pushed to its subscribers
+type Event struct {
+	Type         string    `json:"type"`
+	AgentID      string    `json:"agent_id"`
+	SessionTopic string    `json:"session_topic"`
+	Status       string    `json:"status"`
+	FromStatus   string    `json:"from_status,omitempty"` // Previous status of the run; empty for its first status
+	Message      string    `json:"message,omitempty"`
+	Revision     int       `json:"revision"`
+	Origin       string    `json:"origin,omitempty"` // Who recorded the status, one of the models.StatusOrigin constants
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Broker fans out events of each agent to the channels subscribed to it
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *Event]struct{} // agent_id -> channels
+	relay       func(*Event)
+}
+
+// NewBroker creates a broker without subscribers
+func NewBroker() *Broker {
+	return &Broker{
+		subscribers: make(map[string]map[chan *Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving the agent's events and a function that ends the subscription
+func (b *Broker) Subscribe(agentID string) (<-chan *Event, func()) {
+	ch := make(chan *Event, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[agentID] == nil {
+		b.subscribers[agentID] = make(map[chan *Event]struct{})
+	}
+	b.subscribers[agentID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[agentID], ch)
+		if len(b.subscribers[agentID]) == 0 {
+			delete(b.subscribers, agentID)
+		}
+	}
+}
+
+// SetRelay passes every event published here to fn as well, e.g. to send it to other replicas
+// fn is called synchronously, so it must not block.
+func (b *Broker) SetRelay(fn func(*Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.relay = fn
+}
+
+// Publish sends an event to the agent's subscribers without blocking on slow ones, then to the relay
+func (b *Broker) Publish(event *Event) {
+	b.Deliver(event)
+
+	b.mu.Lock()
+	relay := b.relay
+	b.mu.Unlock()
+	if relay != nil {
+		relay(event)
+	}
+}
+
+// Deliver sends an event to the agent's subscribers only, e.g. one relayed from another replica
+func (b *Broker) Deliver(event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[event.AgentID] {
+		select {
+		case ch <- event:
+		default:
+			// The client still sees the change on its next list request
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package events
+
+import "testing"
+
+func TestBroker_PublishesToAgentSubscribers(t *testing.T) {
+	b := NewBroker()
+	ch, unsubscribe := b.Subscribe("agent-001")
+	other, unsubscribeOther := b.Subscribe("agent-002")
+	defer unsubscribeOther()
+
+	b.Publish(&Event{Type: TypeStatus, AgentID: "agent-001", Status: "running"})
+	select {
+	case event := <-ch:
+		if event.Status != "running" {
+			t.Errorf("event status = %q, want running", event.Status)
+		}
+	default:
+		t.Fatal("subscriber received no event")
+	}
+	select {
+	case event := <-other:
+		t.Errorf("other agent's subscriber received %+v", event)
+	default:
+	}
+
+	unsubscribe()
+	b.Publish(&Event{Type: TypeStatus, AgentID: "agent-001", Status: "success"})
+	if len(b.subscribers) != 1 {
+		t.Errorf("subscribers = %d agents, want only agent-002 left", len(b.subscribers))
+	}
+}
+
+func TestBroker_DropsForSlowSubscribers(t *testing.T) {
+	b := NewBroker()
+	ch, unsubscribe := b.Subscribe("agent-001")
+	defer unsubscribe()
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		b.Publish(&Event{Type: TypeStatus, AgentID: "agent-001"})
+	}
+	if len(ch) != subscriberBuffer {
+		t.Errorf("buffered events = %d, want %d", len(ch), subscriberBuffer)
+	}
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Bounds of admin queries
+const (
+	defaultAdminSearchLimit = 20
+	maxAdminSearchLimit     = 100
+	defaultAdminMetricsDays = 14
+	maxAdminMetricsDays     = 90
+	defaultAuditLimit       = 100
+)
+
+// AdminHandler gives deployment admins a view across tenants for support, and manages their users
+// Every request through Audit is recorded in the audit log.
+type AdminHandler struct {
+	store    store.Store
+	onRevoke func(keyID string)
+	clock    clock.Clock
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(st store.Store) *AdminHandler {
+	return &AdminHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps user changes
+func (h *AdminHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// bufferedResponse holds a response back until the handler decides to send it,
+// e.g. once its audit event is stored
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// sendTo writes the buffered response to w
+func (b *bufferedResponse) sendTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
+
+// Audit records every admin request in the audit log; it runs after RequireAdmin
+// The response is only sent once its event is stored, so no admin read goes unrecorded.
+func (h *AdminHandler) Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := middleware.GetRequestContext(r.Context())
+		if !ok {
+			respondError(w, http.StatusUnauthorized, "not authenticated")
+			return
+		}
+
+		buffered := &bufferedResponse{header: make(http.Header)}
+		next.ServeHTTP(buffered, r)
+		if buffered.status == 0 {
+			buffered.status = http.StatusOK
+		}
+
+		event := &models.AuditEvent{
+			ActorID:    caller.UserID,
+			ActorEmail: caller.Email,
+			Action:     r.Method + " " + r.URL.Path,
+			Target:     chi.URLParam(r, "user_id"),
+			Query:      r.URL.RawQuery,
+			StatusCode: buffered.status,
+			CreatedAt:  h.clock.Now().UTC(),
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			event.Action = r.Method + " " + rctx.RoutePattern()
+		}
+		if len(event.Query) > 2000 {
+			event.Query = event.Query[:2000]
+		}
+		if err := h.store.AddAuditEvent(event); err != nil {
+			log.Printf("Failed to record audit event: %v", err)
+			respondError(w, http.StatusInternalServerError, "failed to record audit event")
+			return
+		}
+
+		buffered.sendTo(w)
+	})
+}
+
+// AdminUserSummary describes a tenant without its notification settings or secrets
+type AdminUserSummary struct {
+	ID            string     `json:"id"`
+	Email         string     `json:"email"`
+	Name          string     `json:"name,omitempty"`
+	Plan          string     `json:"plan,omitempty"`
+	Role          string     `json:"role,omitempty"`
+	EmailVerified bool       `json:"email_verified"`
+	DisabledAt    *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	AgentCount    int        `json:"agent_count"`
+}
+
+// AdminAgentSummary describes an agent together with its owner
+type AdminAgentSummary struct {
+	*models.Agent
+	OwnerEmail string `json:"owner_email,omitempty"`
+}
+
+// summarizeUser builds the admin view of a user
+func summarizeUser(user *models.User, agentCount int) *AdminUserSummary {
+	return &AdminUserSummary{
+		ID:            user.ID,
+		Email:         user.Email,
+		Name:          user.Name,
+		Plan:          user.Plan,
+		Role:          user.Role,
+		EmailVerified: user.EmailVerified,
+		DisabledAt:    user.DisabledAt,
+		CreatedAt:     user.CreatedAt,
+		AgentCount:    agentCount,
+	}
+}
+
+// Search handles GET /api/admin/search?q=, matching users by ID, email or name and agents by ID or name
+func (h *AdminHandler) Search(w http.ResponseWriter, r *http.Request) {
+	q := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+	if len(q) < 2 || len(q) > 100 {
+		respondError(w, http.StatusBadRequest, "q must be 2-100 characters")
+		return
+	}
+	limit, err := parsePositiveInt(r.URL.Query().Get("limit"), defaultAdminSearchLimit)
+	if err != nil || limit > maxAdminSearchLimit {
+		respondError(w, http.StatusBadRequest, "limit must be 1-100")
+		return
+	}
+
+	users, err := h.store.ListUsers()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list users")
+		return
+	}
+	agents := h.store.ListAgents()
+
+	agentCounts := make(map[string]int)
+	for _, agent := range agents {
+		agentCounts[agent.UserID]++
+	}
+	emails := make(map[string]string, len(users))
+	matchedUsers := make([]*AdminUserSummary, 0)
+	for _, user := range users {
+		emails[user.ID] = user.Email
+		if len(matchedUsers) < limit && containsFold(q, user.ID, user.Email, user.Name) {
+			matchedUsers = append(matchedUsers, summarizeUser(user, agentCounts[user.ID]))
+		}
+	}
+
+	matchedAgents := make([]*AdminAgentSummary, 0)
+	for _, agent := range agents {
+		if len(matchedAgents) < limit && containsFold(q, agent.AgentID, agent.Name) {
+			matchedAgents = append(matchedAgents, &AdminAgentSummary{Agent: agent, OwnerEmail: emails[agent.UserID]})
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"users":  matchedUsers,
+		"agents": matchedAgents,
+	})
+}
+
+// containsFold reports whether any of the values contains the lowercased query
+func containsFold(query string, values ...string) bool {
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// DailyReports counts the status reports of one UTC day across tenants
+type DailyReports struct {
+	Day           time.Time `json:"day"`
+	StatusReports int64     `json:"status_reports"`
+	Tenants       int       `json:"tenants"` // Tenants that reported that day
+}
+
+// PlatformMetrics aggregates usage of the whole deployment
+type PlatformMetrics struct {
+	Tenants         int             `json:"tenants"`
+	Agents          int             `json:"agents"`
+	ActiveAgents24h int             `json:"active_agents_24h"`
+	ReportsPerDay   []*DailyReports `json:"reports_per_day"` // Oldest first, one entry per day including days without reports
+	GeneratedAt     time.Time       `json:"generated_at"`
+}
+
+// Metrics handles GET /api/admin/metrics?days=, aggregating tenants, agents and daily reports
+func (h *AdminHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	days, err := parsePositiveInt(r.URL.Query().Get("days"), defaultAdminMetricsDays)
+	if err != nil || days > maxAdminMetricsDays {
+		respondError(w, http.StatusBadRequest, "days must be 1-90")
+		return
+	}
+
+	users, err := h.store.ListUsers()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list users")
+		return
+	}
+	now := h.clock.Now().UTC()
+	metrics := &PlatformMetrics{
+		Tenants:     len(users),
+		GeneratedAt: now,
+	}
+	for _, agent := range h.store.ListAgents() {
+		metrics.Agents++
+		if now.Sub(agent.LastSeen) < 24*time.Hour {
+			metrics.ActiveAgents24h++
+		}
+	}
+
+	today := models.UsageDay(now)
+	from := today.AddDate(0, 0, -(days - 1))
+	records, err := h.store.ListUsage("", from, today.AddDate(0, 0, 1))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list usage")
+		return
+	}
+	byDay := make(map[time.Time]*DailyReports, days)
+	for day := from; !day.After(today); day = day.AddDate(0, 0, 1) {
+		entry := &DailyReports{Day: day}
+		byDay[day] = entry
+		metrics.ReportsPerDay = append(metrics.ReportsPerDay, entry)
+	}
+	for _, record := range records {
+		if entry, ok := byDay[models.UsageDay(record.Day)]; ok && record.StatusReports > 0 {
+			entry.StatusReports += record.StatusReports
+			entry.Tenants++
+		}
+	}
+
+	respondJSON(w, http.StatusOK, metrics)
+}
+
+// TenantDetail is the support view of one tenant
+type TenantDetail struct {
+	User               *AdminUserSummary     `json:"user"`
+	Agents             []*models.Agent       `json:"agents"`
+	APIKeys            int                   `json:"api_keys"`
+	ClientCertificates int                   `json:"client_certificates"`
+	Usage              []*models.UsageRecord `json:"usage"` // Last 30 days
+}
+
+// GetTenant handles GET /api/admin/tenants/{user_id}
+func (h *AdminHandler) GetTenant(w http.ResponseWriter, r *http.Request) {
+	user, err := h.store.GetUserByID(chi.URLParam(r, "user_id"))
+	if err != nil {
+		respondStoreError(w, err, "tenant not found", "failed to get tenant")
+		return
+	}
+
+	agents := h.store.ListAgentsByUser(user.ID)
+	keys, err := h.store.ListAPIKeysByUser(user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list API keys")
+		return
+	}
+	certs, err := h.store.ListClientCertificatesByUser(user.ID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list client certificates")
+		return
+	}
+	today := models.UsageDay(h.clock.Now())
+	usage, err := h.store.ListUsage(user.ID, today.AddDate(0, 0, -(defaultUsageDays-1)), today.AddDate(0, 0, 1))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list usage")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, &TenantDetail{
+		User:               summarizeUser(user, len(agents)),
+		Agents:             agents,
+		APIKeys:            len(keys),
+		ClientCertificates: len(certs),
+		Usage:              usage,
+	})
+}
+
+// ListTenantSessions handles GET /api/admin/tenants/{user_id}/agents/{agent_id}/sessions
+func (h *AdminHandler) ListTenantSessions(w http.ResponseWriter, r *http.Request) {
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Agents of other tenants are indistinguishable from missing ones
+	agent, err := h.store.GetAgent(chi.URLParam(r, "agent_id"))
+	if err != nil || agent.UserID != chi.URLParam(r, "user_id") {
+		respondError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+
+	sessions, total, err := h.store.QuerySessions(store.Query{AgentID: agent.AgentID, Page: page.store()})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+	respondPage(w, r, page, "", sessions, total, nil)
+}
+
+// ListAuditEvents handles GET /api/admin/audit?since=&limit=, newest first
+func (h *AdminHandler) ListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	limit, err := parsePositiveInt(r.URL.Query().Get("limit"), defaultAuditLimit)
+	if err != nil || limit > maxListLimit {
+		respondError(w, http.StatusBadRequest, "limit must be 1-1000")
+		return
+	}
+	var since time.Time
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		if since, err = time.Parse(time.RFC3339, raw); err != nil {
+			respondError(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+	}
+
+	events, err := h.store.ListAuditEvents(since, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list audit events")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"events": events,
+	})
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// adminRouter routes admin requests the way main does, as the admin admin-1
+func adminRouter(handler *AdminHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			caller := middleware.NewRequestContext(req, "admin-1", "admin@example.com", nil)
+			caller.Role = middleware.RoleAdmin
+			next.ServeHTTP(w, req.WithContext(middleware.WithRequestContext(req.Context(), caller)))
+		})
+	})
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(middleware.RequireAdmin, handler.Audit)
+		r.Get("/search", handler.Search)
+		r.Get("/metrics", handler.Metrics)
+		r.Get("/tenants/{user_id}", handler.GetTenant)
+		r.Get("/tenants/{user_id}/agents/{agent_id}/sessions", handler.ListTenantSessions)
+		r.Get("/audit", handler.ListAuditEvents)
+		r.Get("/users", handler.ListUsers)
+		r.Delete("/users/{user_id}", handler.DeleteUser)
+		r.Put("/users/{user_id}/role", handler.UpdateUserRole)
+		r.Post("/users/{user_id}/disable", handler.DisableUser)
+		r.Post("/users/{user_id}/enable", handler.EnableUser)
+		r.Post("/users/{user_id}/verify", handler.VerifyUser)
+		r.Post("/users/{user_id}/reset-password", handler.ResetUserPassword)
+	})
+	return r
+}
+
+// setupAdminStore creates two tenants with one agent each, one of them reporting today
+func setupAdminStore(t *testing.T) store.Store {
+	t.Helper()
+
+	st := store.NewMemoryStore()
+	now := time.Now().UTC()
+	for _, user := range []*models.User{
+		{ID: "user-1", Email: "alice@example.com", Name: "Alice", PasswordHash: "x", CreatedAt: now, UpdatedAt: now},
+		{ID: "user-2", Email: "bob@example.com", Name: "Bob", PasswordHash: "x", CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := st.CreateUser(user); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "build-bot", UserID: "user-1", Name: "Builder", Registered: now, LastSeen: now})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "deploy-bot", UserID: "user-2", Registered: now, LastSeen: now.Add(-48 * time.Hour)})
+	st.CreateOrUpdateSession(&models.Session{AgentID: "build-bot", SessionTopic: "task-1", Created: now, LastUpdated: now})
+	st.AddUsage(&models.UsageRecord{UserID: "user-1", Day: models.UsageDay(now), StatusReports: 7})
+	return st
+}
+
+func TestAdminHandler_SearchAndAudit(t *testing.T) {
+	st := setupAdminStore(t)
+	router := adminRouter(NewAdminHandler(st))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/admin/search?q=BOT", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Search() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var result struct {
+		Users  []*AdminUserSummary  `json:"users"`
+		Agents []*AdminAgentSummary `json:"agents"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode search: %v", err)
+	}
+	if len(result.Users) != 0 || len(result.Agents) != 2 {
+		t.Fatalf("Search() = %d users and %d agents, want 0 and 2", len(result.Users), len(result.Agents))
+	}
+	for _, agent := range result.Agents {
+		if agent.AgentID == "build-bot" && agent.OwnerEmail != "alice@example.com" {
+			t.Errorf("Search() build-bot owner = %q, want alice@example.com", agent.OwnerEmail)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/admin/search?q=a", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Search() with a 1-character query status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	// Both requests are audited, including the rejected one
+	events, _ := st.ListAuditEvents(time.Time{}, 0)
+	if len(events) != 2 {
+		t.Fatalf("audit events = %d, want 2", len(events))
+	}
+	if got := events[1]; got.ActorID != "admin-1" || got.ActorEmail != "admin@example.com" || got.Action != "GET /api/admin/search" || got.Query != "q=BOT" || got.StatusCode != http.StatusOK {
+		t.Errorf("first audit event = %+v, want the successful search by admin-1", got)
+	}
+	if events[0].StatusCode != http.StatusBadRequest {
+		t.Errorf("second audit event status = %d, want %d", events[0].StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestAdminHandler_Metrics(t *testing.T) {
+	router := adminRouter(NewAdminHandler(setupAdminStore(t)))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/admin/metrics?days=3", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Metrics() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var metrics PlatformMetrics
+	if err := json.Unmarshal(rr.Body.Bytes(), &metrics); err != nil {
+		t.Fatalf("decode metrics: %v", err)
+	}
+	if metrics.Tenants != 2 || metrics.Agents != 2 || metrics.ActiveAgents24h != 1 {
+		t.Errorf("Metrics() = %d tenants, %d agents, %d active, want 2, 2 and 1", metrics.Tenants, metrics.Agents, metrics.ActiveAgents24h)
+	}
+	if len(metrics.ReportsPerDay) != 3 {
+		t.Fatalf("Metrics() reports per day = %d days, want 3", len(metrics.ReportsPerDay))
+	}
+	if today := metrics.ReportsPerDay[2]; today.StatusReports != 7 || today.Tenants != 1 || metrics.ReportsPerDay[0].StatusReports != 0 {
+		t.Errorf("Metrics() reports per day = %+v, want 7 reports from 1 tenant today only", metrics.ReportsPerDay)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/admin/metrics?days=91", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Metrics() with 91 days status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAdminHandler_TenantDrillDown(t *testing.T) {
+	st := setupAdminStore(t)
+	router := adminRouter(NewAdminHandler(st))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/admin/tenants/user-1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetTenant() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var detail TenantDetail
+	if err := json.Unmarshal(rr.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("decode tenant: %v", err)
+	}
+	if detail.User.Email != "alice@example.com" || detail.User.AgentCount != 1 || len(detail.Agents) != 1 || len(detail.Usage) != 1 {
+		t.Errorf("GetTenant() = %+v, want alice with one agent and today's usage", detail)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/admin/tenants/user-1/agents/build-bot/sessions", nil))
+	var sessions struct {
+		Items []*models.Session `json:"items"`
+		Total int               `json:"total"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &sessions)
+	if rr.Code != http.StatusOK || sessions.Total != 1 || sessions.Items[0].SessionTopic != "task-1" {
+		t.Errorf("ListTenantSessions() = %v %s, want task-1", rr.Code, rr.Body.String())
+	}
+
+	for _, path := range []string{"/api/admin/tenants/missing", "/api/admin/tenants/user-2/agents/build-bot/sessions"} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("GET %s status = %v, want %v", path, rr.Code, http.StatusNotFound)
+		}
+	}
+
+	events, _ := st.ListAuditEvents(time.Time{}, 0)
+	if len(events) != 4 || events[3].Action != "GET /api/admin/tenants/{user_id}" || events[3].Target != "user-1" || events[0].Target != "user-2" {
+		t.Errorf("audit events = %d, want 4 targeting the tenants drilled into", len(events))
+	}
+}
+
+func TestAdminHandler_RequiresAdmin(t *testing.T) {
+	st := setupAdminStore(t)
+	handler := NewAdminHandler(st)
+	r := chi.NewRouter()
+	r.With(middleware.RequireAdmin, handler.Audit).Get("/api/admin/search", handler.Search)
+
+	req := httptest.NewRequest("GET", "/api/admin/search?q=alice", nil)
+	req = req.WithContext(middleware.WithRequestContext(req.Context(), middleware.NewRequestContext(req, "user-1", "alice@example.com", nil)))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Search() as a member status = %v, want %v", rr.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// temporaryPasswordLength is the length of passwords generated by ResetUserPassword
+const temporaryPasswordLength = 20
+
+// SetOnRevoke registers a callback run for each API key of a user whose credentials an admin cut off,
+// e.g. to evict it from validation caches
+func (h *AdminHandler) SetOnRevoke(fn func(keyID string)) {
+	h.onRevoke = fn
+}
+
+// ListUsers handles GET /api/admin/users, listing every user oldest first
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, total, err := h.store.ListUsersPage(page.store())
+	if err != nil {
+		respondStoreError(w, err, "user not found", "failed to list users")
+		return
+	}
+
+	userIDs := make([]string, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+	agentCounts, err := h.store.CountAgentsByUsers(userIDs)
+	if err != nil {
+		respondStoreError(w, err, "user not found", "failed to count agents")
+		return
+	}
+
+	summaries := make([]*AdminUserSummary, 0, len(users))
+	for _, user := range users {
+		summaries = append(summaries, summarizeUser(user, agentCounts[user.ID]))
+	}
+	respondPage(w, r, page, "", summaries, total, nil)
+}
+
+// adminUserUpdate loads the user named in the URL, applies update and stores it, responding with the
+// admin view of the user; update returns false after writing its own error response
+func (h *AdminHandler) adminUserUpdate(w http.ResponseWriter, r *http.Request, update func(user *models.User) bool) (*models.User, bool) {
+	user, err := h.store.GetUserByID(chi.URLParam(r, "user_id"))
+	if err != nil {
+		respondStoreError(w, err, "user not found", "failed to get user")
+		return nil, false
+	}
+	if !update(user) {
+		return nil, false
+	}
+	user.UpdatedAt = h.clock.Now().UTC()
+	if err := h.store.UpdateUser(user); err != nil {
+		respondStoreError(w, err, "user not found", "failed to update user")
+		return nil, false
+	}
+	return user, true
+}
+
+// notSelf writes a 400 and returns false when an admin targets their own account
+func notSelf(w http.ResponseWriter, r *http.Request, action string) bool {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if ok && caller.UserID == chi.URLParam(r, "user_id") {
+		respondError(w, http.StatusBadRequest, "admins cannot "+action+" their own account")
+		return false
+	}
+	return true
+}
+
+// cutOff signs a user out everywhere: their refresh tokens are revoked and their API keys evicted from
+// validation caches, so the next request checks the account again
+func (h *AdminHandler) cutOff(userID string) {
+	if err := h.store.RevokeAllUserTokens(userID); err != nil {
+		log.Printf("Failed to revoke refresh tokens of %s: %v", userID, err)
+	}
+	if h.onRevoke == nil {
+		return
+	}
+	keys, err := h.store.ListAPIKeysByUser(userID)
+	if err != nil {
+		log.Printf("Failed to list API keys of %s: %v", userID, err)
+		return
+	}
+	for _, key := range keys {
+		h.onRevoke(key.ID)
+	}
+}
+
+// UpdateUserRoleRequest is the body of PUT /api/admin/users/{user_id}/role
+type UpdateUserRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// UpdateUserRole handles PUT /api/admin/users/{user_id}/role, making a user an admin, member or viewer
+// Admins listed in ADMIN_EMAILS stay admins whatever their stored role; the last enabled admin with a
+// stored role cannot be demoted.
+func (h *AdminHandler) UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	if !notSelf(w, r, "change the role of") {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req UpdateUserRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !models.ValidUserRole(req.Role) {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("role must be one of: %s, %s, %s", models.UserRoleAdmin, models.UserRoleMember, models.UserRoleViewer))
+		return
+	}
+
+	user, ok := h.adminUserUpdate(w, r, func(user *models.User) bool {
+		if user.Role == models.UserRoleAdmin && req.Role != models.UserRoleAdmin && !h.keepsAdmin(w, user) {
+			return false
+		}
+		user.Role = req.Role
+		return true
+	})
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, summarizeUser(user, len(h.store.ListAgentsByUser(user.ID))))
+}
+
+// keepsAdmin writes an error response if target is the only enabled user with the admin role
+func (h *AdminHandler) keepsAdmin(w http.ResponseWriter, target *models.User) bool {
+	users, err := h.store.ListUsers()
+	if err != nil {
+		respondStoreError(w, err, "user not found", "failed to list users")
+		return false
+	}
+	for _, user := range users {
+		if user.Role == models.UserRoleAdmin && user.DisabledAt == nil && user.ID != target.ID {
+			return true
+		}
+	}
+	respondError(w, http.StatusConflict, "a deployment needs at least one admin")
+	return false
+}
+
+// DisableUser handles POST /api/admin/users/{user_id}/disable
+// Disabled users cannot sign in, refresh their session or authenticate with API keys, client certificates
+// or enrollment tokens, and the access tokens already issued to them are refused.
+func (h *AdminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	if !notSelf(w, r, "disable") {
+		return
+	}
+	user, ok := h.adminUserUpdate(w, r, func(user *models.User) bool {
+		if user.DisabledAt == nil {
+			now := h.clock.Now().UTC()
+			user.DisabledAt = &now
+		}
+		return true
+	})
+	if !ok {
+		return
+	}
+	h.cutOff(user.ID)
+	respondJSON(w, http.StatusOK, summarizeUser(user, len(h.store.ListAgentsByUser(user.ID))))
+}
+
+// EnableUser handles POST /api/admin/users/{user_id}/enable, letting a disabled user back in
+func (h *AdminHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.adminUserUpdate(w, r, func(user *models.User) bool {
+		user.DisabledAt = nil
+		return true
+	})
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, summarizeUser(user, len(h.store.ListAgentsByUser(user.ID))))
+}
+
+// VerifyUser handles POST /api/admin/users/{user_id}/verify, marking a user's email verified without
+// the verification email
+func (h *AdminHandler) VerifyUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.adminUserUpdate(w, r, func(user *models.User) bool {
+		user.EmailVerified = true
+		user.VerifyToken = ""
+		user.VerifyTokenExpiresAt = nil
+		return true
+	})
+	if !ok {
+		return
+	}
+	respondJSON(w, http.StatusOK, summarizeUser(user, len(h.store.ListAgentsByUser(user.ID))))
+}
+
+// ResetPasswordRequest is the optional body of POST /api/admin/users/{user_id}/reset-password
+type ResetPasswordRequest struct {
+	Password string `json:"password,omitempty"` // New password; generated when empty
+}
+
+// ResetPasswordResponse is the user after a password reset with the generated password, if any
+type ResetPasswordResponse struct {
+	User              *AdminUserSummary `json:"user"`
+	TemporaryPassword string            `json:"temporary_password,omitempty"` // Only shown in this response
+}
+
+// ResetUserPassword handles POST /api/admin/users/{user_id}/reset-password, replacing a user's password
+// and signing them out everywhere
+func (h *AdminHandler) ResetUserPassword(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req ResetPasswordRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	password, generated := req.Password, req.Password == ""
+	if generated {
+		token, err := generateToken()
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to generate password")
+			return
+		}
+		password = token[:temporaryPasswordLength]
+	}
+	if err := models.ValidatePassword(password); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to process password")
+		return
+	}
+
+	user, ok := h.adminUserUpdate(w, r, func(user *models.User) bool {
+		user.PasswordHash = passwordHash
+		return true
+	})
+	if !ok {
+		return
+	}
+	h.cutOff(user.ID)
+
+	response := ResetPasswordResponse{User: summarizeUser(user, len(h.store.ListAgentsByUser(user.ID)))}
+	if generated {
+		response.TemporaryPassword = password
+	}
+	respondJSON(w, http.StatusOK, response)
+}
+
+// DeleteUser handles DELETE /api/admin/users/{user_id}, removing a user with their agents and every
+// other record they own
+// Users who are the only owner of an organization are kept until it has another owner.
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if !notSelf(w, r, "delete") {
+		return
+	}
+	userID := chi.URLParam(r, "user_id")
+	if !h.ownsNoOrgAlone(w, userID) {
+		return
+	}
+
+	// Evict the keys while they can still be listed
+	h.cutOff(userID)
+	if err := h.store.DeleteUser(userID); err != nil {
+		respondStoreError(w, err, "user not found", "failed to delete user")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{"message": "user deleted"})
+}
+
+// ownsNoOrgAlone writes an error response if the user is the only owner of an organization,
+// which would be left without one
+func (h *AdminHandler) ownsNoOrgAlone(w http.ResponseWriter, userID string) bool {
+	memberships, err := h.store.ListMembershipsByUser(userID)
+	if err != nil {
+		respondStoreError(w, err, "user not found", "failed to list memberships")
+		return false
+	}
+	for _, m := range memberships {
+		if m.Role != models.OrgRoleOwner {
+			continue
+		}
+		other, err := hasOtherOwner(h.store, m.OrgID, userID)
+		if err != nil {
+			respondStoreError(w, err, "organization not found", "failed to list members")
+			return false
+		}
+		if !other {
+			respondError(w, http.StatusConflict, fmt.Sprintf("user is the only owner of organization %s; transfer or delete it first", m.OrgID))
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestAdminHandler_UpdateUserRole(t *testing.T) {
+	st := setupAdminStore(t)
+	router := adminRouter(NewAdminHandler(st))
+
+	update := func(userID, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("PUT", "/api/admin/users/"+userID+"/role", strings.NewReader(body)))
+		return rr
+	}
+
+	rr := update("user-2", `{"role":"viewer"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("UpdateUserRole() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var summary AdminUserSummary
+	if err := json.Unmarshal(rr.Body.Bytes(), &summary); err != nil || summary.Role != models.UserRoleViewer || summary.AgentCount != 1 {
+		t.Errorf("UpdateUserRole() = %+v, %v, want bob as a viewer with one agent", summary, err)
+	}
+	if user, _ := st.GetUserByID("user-2"); user.Role != models.UserRoleViewer {
+		t.Errorf("stored role = %q, want %q", user.Role, models.UserRoleViewer)
+	}
+
+	if rr := update("user-2", `{"role":"owner"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("UpdateUserRole() with an unknown role status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := update("missing", `{"role":"admin"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("UpdateUserRole() of a missing user status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+
+	// Role changes are audited against their target
+	events, _ := st.ListAuditEvents(time.Time{}, 0)
+	if len(events) != 3 || events[2].Target != "user-2" || events[2].Action != "PUT /api/admin/users/{user_id}/role" {
+		t.Errorf("audit events = %+v, want the three role changes, the first for user-2", events)
+	}
+
+	if rr := update("admin-1", `{"role":"viewer"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("UpdateUserRole() of oneself status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	// The last admin keeps the role until there is another
+	if rr := update("user-1", `{"role":"admin"}`); rr.Code != http.StatusOK {
+		t.Fatalf("UpdateUserRole() to admin status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	if rr := update("user-1", `{"role":"member"}`); rr.Code != http.StatusConflict {
+		t.Errorf("UpdateUserRole() demoting the last admin status = %v, want %v", rr.Code, http.StatusConflict)
+	}
+	update("user-2", `{"role":"admin"}`)
+	if rr := update("user-1", `{"role":"member"}`); rr.Code != http.StatusOK {
+		t.Errorf("UpdateUserRole() demoting one of two admins status = %v, want %v", rr.Code, http.StatusOK)
+	}
+}
+
+// adminRequest sends an admin request through router and decodes a successful JSON response into out, if given
+func adminRequest(t *testing.T, router http.Handler, method, path, body string, out interface{}) int {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+	if out != nil && rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s invalid JSON: %v", method, path, err)
+		}
+	}
+	return rr.Code
+}
+
+func TestAdminHandler_ListUsers(t *testing.T) {
+	router := adminRouter(NewAdminHandler(setupAdminStore(t)))
+
+	var page struct {
+		Items      []*AdminUserSummary `json:"items"`
+		Total      int                 `json:"total"`
+		NextCursor *string             `json:"next_cursor"`
+	}
+	if code := adminRequest(t, router, "GET", "/api/admin/users?limit=1", "", &page); code != http.StatusOK {
+		t.Fatalf("ListUsers() status = %v, want %v", code, http.StatusOK)
+	}
+	if page.Total != 2 || len(page.Items) != 1 || page.Items[0].ID != "user-1" || page.Items[0].AgentCount != 1 || page.NextCursor == nil {
+		t.Fatalf("ListUsers() = %+v, want alice first of 2 with a next cursor", page)
+	}
+
+	if code := adminRequest(t, router, "GET", "/api/admin/users?limit=1&cursor="+*page.NextCursor, "", &page); code != http.StatusOK || len(page.Items) != 1 || page.Items[0].ID != "user-2" {
+		t.Errorf("ListUsers() second page = %d %+v, want bob", code, page.Items)
+	}
+}
+
+func TestAdminHandler_DisableAndEnableUser(t *testing.T) {
+	st := setupAdminStore(t)
+	st.CreateAPIKey(&models.APIKey{ID: "key-1", UserID: "user-2", Name: "ci", KeyHash: "hash-1", KeyPrefix: "abcdefgh", CreatedAt: time.Now()})
+	st.SaveRefreshToken(&models.RefreshToken{ID: "rt-1", UserID: "user-2", TokenHash: "rt-hash", ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now()})
+	handler := NewAdminHandler(st)
+	disabledAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	handler.SetClock(clock.NewFake(disabledAt))
+	var revoked []string
+	handler.SetOnRevoke(func(keyID string) { revoked = append(revoked, keyID) })
+	router := adminRouter(handler)
+
+	var summary AdminUserSummary
+	if code := adminRequest(t, router, "POST", "/api/admin/users/user-2/disable", "", &summary); code != http.StatusOK || summary.DisabledAt == nil || !summary.DisabledAt.Equal(disabledAt) {
+		t.Fatalf("DisableUser() = %d %+v, want bob disabled at %v", code, summary, disabledAt)
+	}
+	if len(revoked) != 1 || revoked[0] != "key-1" {
+		t.Errorf("revoked keys = %v, want key-1 evicted", revoked)
+	}
+	if token, _ := st.GetRefreshToken("rt-hash"); token == nil || !token.Revoked {
+		t.Errorf("refresh token after disable = %+v, want revoked", token)
+	}
+
+	summary = AdminUserSummary{}
+	if code := adminRequest(t, router, "POST", "/api/admin/users/user-2/enable", "", &summary); code != http.StatusOK || summary.DisabledAt != nil {
+		t.Errorf("EnableUser() = %d %+v, want bob enabled", code, summary)
+	}
+	if code := adminRequest(t, router, "POST", "/api/admin/users/missing/disable", "", nil); code != http.StatusNotFound {
+		t.Errorf("DisableUser() of a missing user status = %v, want %v", code, http.StatusNotFound)
+	}
+}
+
+func TestAdminHandler_VerifyUser(t *testing.T) {
+	st := setupAdminStore(t)
+	user, _ := st.GetUserByID("user-2")
+	expires := time.Now().Add(time.Hour)
+	user.EmailVerified, user.VerifyToken, user.VerifyTokenExpiresAt = false, "verify-1", &expires
+	st.UpdateUser(user)
+	router := adminRouter(NewAdminHandler(st))
+
+	var summary AdminUserSummary
+	if code := adminRequest(t, router, "POST", "/api/admin/users/user-2/verify", "", &summary); code != http.StatusOK || !summary.EmailVerified {
+		t.Fatalf("VerifyUser() = %d %+v, want bob verified", code, summary)
+	}
+	if _, err := st.GetUserByVerifyToken("verify-1"); err == nil {
+		t.Error("GetUserByVerifyToken() after verify, want the pending token cleared")
+	}
+}
+
+func TestAdminHandler_ResetUserPassword(t *testing.T) {
+	st := setupAdminStore(t)
+	router := adminRouter(NewAdminHandler(st))
+
+	var response ResetPasswordResponse
+	if code := adminRequest(t, router, "POST", "/api/admin/users/user-2/reset-password", "", &response); code != http.StatusOK || len(response.TemporaryPassword) != temporaryPasswordLength {
+		t.Fatalf("ResetUserPassword() = %d %+v, want a generated password", code, response)
+	}
+	if user, _ := st.GetUserByID("user-2"); !auth.VerifyPassword(response.TemporaryPassword, user.PasswordHash) {
+		t.Error("stored password does not match the generated one")
+	}
+
+	response = ResetPasswordResponse{}
+	if code := adminRequest(t, router, "POST", "/api/admin/users/user-2/reset-password", `{"password":"chosen-password"}`, &response); code != http.StatusOK || response.TemporaryPassword != "" {
+		t.Errorf("ResetUserPassword() with a password = %d %+v, want no generated password", code, response)
+	}
+	if user, _ := st.GetUserByID("user-2"); !auth.VerifyPassword("chosen-password", user.PasswordHash) {
+		t.Error("stored password does not match the chosen one")
+	}
+	if code := adminRequest(t, router, "POST", "/api/admin/users/user-2/reset-password", `{"password":"short"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("ResetUserPassword() with a short password status = %v, want %v", code, http.StatusBadRequest)
+	}
+}
+
+func TestAdminHandler_DeleteUser(t *testing.T) {
+	st := setupAdminStore(t)
+	st.CreateUser(&models.User{ID: "admin-1", Email: "admin@example.com", PasswordHash: "x", CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	router := adminRouter(NewAdminHandler(st))
+
+	if code := adminRequest(t, router, "DELETE", "/api/admin/users/admin-1", "", nil); code != http.StatusBadRequest {
+		t.Errorf("DeleteUser() of oneself status = %v, want %v", code, http.StatusBadRequest)
+	}
+
+	// The only owner of an organization stays until it has another
+	st.CreateOrganization(&models.Organization{ID: "org-1", Name: "Acme", CreatedBy: "user-2", CreatedAt: time.Now()},
+		&models.Membership{OrgID: "org-1", UserID: "user-2", Role: models.OrgRoleOwner, CreatedAt: time.Now()})
+	if code := adminRequest(t, router, "DELETE", "/api/admin/users/user-2", "", nil); code != http.StatusConflict {
+		t.Errorf("DeleteUser() of an organization's only owner status = %v, want %v", code, http.StatusConflict)
+	}
+	st.SaveMembership(&models.Membership{OrgID: "org-1", UserID: "user-1", Role: models.OrgRoleOwner, CreatedAt: time.Now()})
+	if code := adminRequest(t, router, "DELETE", "/api/admin/users/user-2", "", nil); code != http.StatusOK {
+		t.Fatalf("DeleteUser() status = %v, want %v", code, http.StatusOK)
+	}
+	if _, err := st.GetUserByID("user-2"); err == nil {
+		t.Error("GetUserByID() after delete, want not found")
+	}
+	if _, err := st.GetAgent("deploy-bot"); err == nil {
+		t.Error("GetAgent() of the deleted user's agent, want not found")
+	}
+	if code := adminRequest(t, router, "DELETE", "/api/admin/users/user-2", "", nil); code != http.StatusNotFound {
+		t.Errorf("DeleteUser() again status = %v, want %v", code, http.StatusNotFound)
+	}
+}
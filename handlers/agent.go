@@ -1,29 +1,87 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"sort"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/compliance"
+	"github.com/kubeagents/kubeagents/healthscore"
+	"github.com/kubeagents/kubeagents/internal"
 	"github.com/kubeagents/kubeagents/middleware"
 	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/sessionclose"
+	"github.com/kubeagents/kubeagents/sessionhook"
 	"github.com/kubeagents/kubeagents/store"
 )
 
 // AgentHandler handles agent-related requests
 type AgentHandler struct {
-	store store.Store
+	store      store.Store
+	compliance *compliance.Evaluator
+	health     *healthscore.Scorer
+	hooks      *sessionhook.Dispatcher
+	closer     *sessionclose.Closer
+	clock      clock.Clock
+	purgeAfter time.Duration // How long deleted agents can be restored; 0 keeps them until restored
+
+	listFormat
 }
 
 // NewAgentHandler creates a new agent handler
 func NewAgentHandler(s store.Store) *AgentHandler {
 	return &AgentHandler{
 		store: s,
+		clock: clock.Real,
 	}
 }
 
+// SetClock replaces the clock that stamps annotations and measures running durations
+func (h *AgentHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// SetComplianceEvaluator enables SLA compliance in agent statistics
+func (h *AgentHandler) SetComplianceEvaluator(e *compliance.Evaluator) {
+	h.compliance = e
+}
+
+// SetDeletedAgentRetention sets how long deleted agents can be restored, reported as their purge time
+func (h *AgentHandler) SetDeletedAgentRetention(d time.Duration) {
+	h.purgeAfter = d
+}
+
+// SetHealthScorer enables health scores in agent statistics
+func (h *AgentHandler) SetHealthScorer(s *healthscore.Scorer) {
+	h.health = s
+}
+
+// SetSessionCloser closes the running sessions of deleted agents as their owners chose
+func (h *AgentHandler) SetSessionCloser(c *sessionclose.Closer) {
+	h.closer = c
+}
+
+// SetSessionHooks delivers cancelled sessions to the owner's session webhooks
+func (h *AgentHandler) SetSessionHooks(d *sessionhook.Dispatcher) {
+	h.hooks = d
+}
+
+// healthScore returns the agent's latest health score, or nil when it is not scored or scoring is disabled
+func (h *AgentHandler) healthScore(agentID string) *healthscore.AgentScore {
+	if h.health == nil {
+		return nil
+	}
+	return h.health.ForAgent(agentID)
+}
+
 // AgentWithStats represents an agent with session statistics
 type AgentWithStats struct {
 	*models.Agent
@@ -31,6 +89,10 @@ type AgentWithStats struct {
 	ActiveSessionCount int    `json:"active_session_count"`
 	LatestStatus       string `json:"latest_status,omitempty"`
 	LatestMessage      string `json:"latest_message,omitempty"`
+	Starred            bool   `json:"starred"`
+
+	SLACompliance []*compliance.Result `json:"sla_compliance,omitempty"`
+	HealthScore   *float64             `json:"health_score,omitempty"`
 }
 
 // ListAgents handles GET /api/agents
@@ -41,63 +103,144 @@ func (h *AgentHandler) ListAgents(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get authenticated user
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
 		return
 	}
 
+	fields, err := parseFields(r.URL.Query().Get("fields"), agentFields)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
 	// Get query parameters
 	statusFilter := r.URL.Query().Get("status")
 	searchQuery := r.URL.Query().Get("search")
+	stateFilter := r.URL.Query().Get("state")
+	if stateFilter != "" && !models.ValidAgentState(stateFilter) {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "state must be one of: online, stale, offline")
+		return
+	}
+	kindFilter := r.URL.Query().Get("kind")
+	if err := models.ValidateAgentKind("kind", kindFilter); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	location, err := parseLocationFilter(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	window, err := parseListWindow(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
 
-	// Get agents for the authenticated user only
-	agents := h.store.ListAgentsByUser(claims.UserID)
-
-	// Filter and search
-	var filteredAgents []*models.Agent
-	for _, agent := range agents {
-		// Apply search filter
-		if searchQuery != "" {
-			searchLower := strings.ToLower(searchQuery)
-			agentIDLower := strings.ToLower(agent.AgentID)
-			nameLower := strings.ToLower(agent.Name)
-			if !strings.Contains(agentIDLower, searchLower) && !strings.Contains(nameLower, searchLower) {
-				continue
-			}
+	// ?org_id= lists the agents shared with one of the caller's organizations instead of their own
+	orgFilter := r.URL.Query().Get("org_id")
+	if orgFilter != "" {
+		if _, err := h.store.GetMembership(orgFilter, caller.UserID); err != nil {
+			h.respondError(w, http.StatusNotFound, "not_found", "Organization not found")
+			return
 		}
+	}
 
-		// Apply status filter
-		if statusFilter != "" {
-			latestStatus, _ := h.getAgentLatestStatus(agent.AgentID)
-			if latestStatus != statusFilter {
-				continue
-			}
+	// Get agents for the authenticated user only, loading just the page unless stars reorder it or groups count all
+	query := store.Query{
+		UserID:  caller.UserID,
+		Search:  searchQuery,
+		Status:  statusFilter,
+		State:   stateFilter,
+		Kind:    kindFilter,
+		Cluster: location.cluster,
+		Region:  location.region,
+		Since:   window.since,
+		Until:   window.until,
+	}
+	if orgFilter != "" {
+		query.UserID, query.OrgID = "", orgFilter
+	}
+	watches := loadWatchSet(h.store, caller.UserID)
+	var pageAgents []*models.Agent
+	var total int
+	var extra map[string]interface{}
+	if location.groupBy == "" && !watches.anyStarred() {
+		query.Page = page.store()
+		pageAgents, total, err = h.store.QueryAgents(query)
+		if err != nil {
+			h.respondQueryError(w, err, "Failed to list agents")
+			return
 		}
+	} else {
+		filteredAgents, _, err := h.store.QueryAgents(query)
+		if err != nil {
+			h.respondQueryError(w, err, "Failed to list agents")
+			return
+		}
+
+		// Starred agents come first, otherwise keeping the store's order
+		sort.SliceStable(filteredAgents, func(i, j int) bool {
+			return watches.starred(filteredAgents[i].AgentID) && !watches.starred(filteredAgents[j].AgentID)
+		})
 
-		filteredAgents = append(filteredAgents, agent)
+		total = len(filteredAgents)
+		start, end := page.bounds(total)
+		pageAgents = filteredAgents[start:end]
+
+		// Groups count every matching agent, not just the page
+		if location.groupBy != "" {
+			extra = map[string]interface{}{"groups": location.groupAgents(filteredAgents, h.healthScore)}
+		}
 	}
 
 	// Build response with statistics
-	agentsWithStats := make([]*AgentWithStats, 0, len(filteredAgents))
-	for _, agent := range filteredAgents {
-		stats := h.calculateAgentStats(agent.AgentID)
-		agentsWithStats = append(agentsWithStats, &AgentWithStats{
-			Agent:              agent,
-			SessionCount:       stats.SessionCount,
-			ActiveSessionCount: stats.ActiveSessionCount,
-			LatestStatus:       stats.LatestStatus,
-			LatestMessage:      stats.LatestMessage,
-		})
+	agentsWithStats := make([]interface{}, 0, len(pageAgents))
+	for _, agent := range pageAgents {
+		withStats := h.buildAgentWithStats(agent, fields)
+		withStats.Starred = watches.starred(agent.AgentID)
+		agentWithStats, err := fields.project(withStats)
+		if err != nil {
+			h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to build response")
+			return
+		}
+		agentsWithStats = append(agentsWithStats, agentWithStats)
 	}
 
-	response := map[string]interface{}{
-		"agents": agentsWithStats,
+	respondPage(w, r, page, h.legacyKey("agents"), agentsWithStats, total, extra)
+}
+
+// buildAgentWithStats adds statistics to an agent, computing only what the selected fields need
+func (h *AgentHandler) buildAgentWithStats(agent *models.Agent, fields fieldSelection) *AgentWithStats {
+	agentWithStats := &AgentWithStats{
+		Agent: agent,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	if fields.has("session_count", "active_session_count", "latest_status", "latest_message") {
+		stats := h.calculateAgentStats(agent.AgentID)
+		agentWithStats.SessionCount = stats.SessionCount
+		agentWithStats.ActiveSessionCount = stats.ActiveSessionCount
+		agentWithStats.LatestStatus = stats.LatestStatus
+		agentWithStats.LatestMessage = stats.LatestMessage
+	}
+	if fields.has("sla_compliance") {
+		agentWithStats.SLACompliance = h.slaCompliance(agent)
+	}
+	if fields.has("health_score") && h.health != nil {
+		if score := h.health.ForAgent(agent.AgentID); score != nil {
+			value := score.Score
+			agentWithStats.HealthScore = &value
+		}
+	}
+
+	return agentWithStats
 }
 
 // AgentStats represents session statistics for an agent
@@ -138,31 +281,18 @@ func (h *AgentHandler) calculateAgentStats(agentID string) AgentStats {
 	return stats
 }
 
-// getAgentLatestStatus gets the latest status for an agent
-func (h *AgentHandler) getAgentLatestStatus(agentID string) (string, error) {
-	sessions := h.store.ListSessions(agentID, false)
-
-	var latestStatus *models.AgentStatus
-	for _, session := range sessions {
-		status, err := h.store.GetLatestStatus(agentID, session.SessionTopic)
-		if err != nil {
-			continue
-		}
-		if latestStatus == nil || status.Timestamp.After(latestStatus.Timestamp) {
-			latestStatus = status
-		}
-	}
-
-	if latestStatus == nil {
-		return "", nil
+// slaCompliance returns SLA compliance for an agent, or nil when SLA evaluation is not configured
+func (h *AgentHandler) slaCompliance(agent *models.Agent) []*compliance.Result {
+	if h.compliance == nil {
+		return nil
 	}
-	return latestStatus.Status, nil
+	return h.compliance.ForAgent(agent)
 }
 
 // GetAgent handles GET /api/agents/{agent_id}
 func (h *AgentHandler) GetAgent(w http.ResponseWriter, r *http.Request) {
 	// Get authenticated user
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
 		return
@@ -170,28 +300,33 @@ func (h *AgentHandler) GetAgent(w http.ResponseWriter, r *http.Request) {
 
 	agentID := chi.URLParam(r, "agent_id")
 
+	fields, err := parseFields(r.URL.Query().Get("fields"), agentFields)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
 	agent, err := h.store.GetAgent(agentID)
 	if err != nil {
 		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
 		return
 	}
 
-	// Verify the agent belongs to the authenticated user
-	if agent.UserID != claims.UserID {
+	// Verify the agent is visible to the authenticated user
+	if !canViewAgent(h.store, caller.UserID, agent) {
 		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
 		return
 	}
 
-	// Calculate statistics for the agent
-	stats := h.calculateAgentStats(agentID)
-
-	// Create response with stats
-	agentWithStats := AgentWithStats{
-		Agent:              agent,
-		SessionCount:       stats.SessionCount,
-		ActiveSessionCount: stats.ActiveSessionCount,
-		LatestStatus:       stats.LatestStatus,
-		LatestMessage:      stats.LatestMessage,
+	// Create response with the requested stats
+	withStats := h.buildAgentWithStats(agent, fields)
+	if _, err := h.store.GetWatchItem(caller.UserID, agentID, ""); err == nil {
+		withStats.Starred = true
+	}
+	agentWithStats, err := fields.project(withStats)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to build response")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -199,16 +334,223 @@ func (h *AgentHandler) GetAgent(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(agentWithStats)
 }
 
+// UpdateSamplingRequest represents a request to change an agent's heartbeat sampling
+type UpdateSamplingRequest struct {
+	HeartbeatSampleEvery int `json:"heartbeat_sample_every"`
+}
+
+// UpdateSampling handles PUT /api/agents/{agent_id}/sampling
+func (h *AgentHandler) UpdateSampling(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req UpdateSamplingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid request body")
+		return
+	}
+	if req.HeartbeatSampleEvery < 0 || req.HeartbeatSampleEvery > models.MaxHeartbeatSampleEvery {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "heartbeat_sample_every must be 0-"+strconv.Itoa(models.MaxHeartbeatSampleEvery))
+		return
+	}
+
+	agent, err := h.store.GetAgent(chi.URLParam(r, "agent_id"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+	if !canManageAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	agent.HeartbeatSampleEvery = req.HeartbeatSampleEvery
+	if err := h.store.CreateOrUpdateAgent(agent); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			h.respondError(w, http.StatusConflict, "conflict", "Agent was modified concurrently, retry the update")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to update agent")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(agent)
+}
+
+// AgentConfigResponse is the configuration delivered to an agent
+type AgentConfigResponse struct {
+	AgentID       string          `json:"agent_id"`
+	Config        json.RawMessage `json:"config"`
+	ConfigVersion int             `json:"config_version"`
+}
+
+// UpdateConfigRequest represents a request to replace an agent's configuration; a null config clears it
+type UpdateConfigRequest struct {
+	Config json.RawMessage `json:"config"`
+}
+
+// GetConfig handles GET /api/agents/{agent_id}/config
+func (h *AgentHandler) GetConfig(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agent, err := h.store.GetAgent(chi.URLParam(r, "agent_id"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+	if !canViewAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, AgentConfigResponse{
+		AgentID:       agent.AgentID,
+		Config:        agent.Config,
+		ConfigVersion: agent.ConfigVersion,
+	})
+}
+
+// UpdateConfig handles PUT /api/agents/{agent_id}/config
+// Every update bumps the config version, which the agent receives with the config in its next webhook response.
+func (h *AgentHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req UpdateConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid request body")
+		return
+	}
+	var config json.RawMessage
+	if len(req.Config) > 0 && string(req.Config) != "null" {
+		if err := models.ValidateAgentConfig(req.Config); err != nil {
+			h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, req.Config); err != nil {
+			h.respondError(w, http.StatusBadRequest, "bad_request", "config must be a JSON object")
+			return
+		}
+		config = compacted.Bytes()
+	}
+
+	agent, err := h.store.GetAgent(chi.URLParam(r, "agent_id"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+	if !canManageAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	agent.Config = config
+	agent.ConfigVersion++
+	if err := h.store.CreateOrUpdateAgent(agent); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			h.respondError(w, http.StatusConflict, "conflict", "Agent was modified concurrently, retry the update")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to update agent")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, AgentConfigResponse{
+		AgentID:       agent.AgentID,
+		Config:        agent.Config,
+		ConfigVersion: agent.ConfigVersion,
+	})
+}
+
+// CancelSession handles POST /api/agents/{agent_id}/sessions/{session_topic}/cancel
+// The session is expired at once with end reason cancelled; a later report starts a new run.
+func (h *AgentHandler) CancelSession(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agent, err := h.store.GetAgent(chi.URLParam(r, "agent_id"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+	if !canManageAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	session, err := h.store.GetSession(agent.AgentID, chi.URLParam(r, "session_topic"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Session not found")
+		return
+	}
+	if session.Expired {
+		h.respondError(w, http.StatusConflict, "conflict", "Session has already ended")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	running := session.EndReason == ""
+	session.Expired = true
+	session.ExpiredAt = &now
+	session.EndReason = models.EndReasonCancelled
+	if err := h.store.CreateOrUpdateSession(session); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			h.respondError(w, http.StatusConflict, "conflict", "Session was modified concurrently, retry the cancellation")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to cancel session")
+		return
+	}
+
+	if h.hooks != nil {
+		var final *models.AgentStatus
+		if latest, err := h.store.GetLatestStatus(agent.AgentID, session.SessionTopic); err == nil && latest.Revision == session.Revision {
+			final = latest
+		}
+		h.hooks.SessionEnded(agent, session, final)
+	}
+	// A run that had not ended yet shows in its history that it was cancelled rather than finished
+	if running {
+		cancelled := models.NewServerStatus(session, models.StatusCancelledByUser, "Session was cancelled by a user", now)
+		if err := h.store.AddStatus(cancelled); err != nil {
+			log.Printf("Failed to record cancellation of session %s of agent %s: %v", session.SessionTopic, agent.AgentID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(session)
+}
+
 // SessionWithStatus represents a session with its current status
 type SessionWithStatus struct {
 	*models.Session
 	CurrentStatus *string `json:"current_status,omitempty"`
+	Watched       bool    `json:"watched"`
 }
 
 // ListSessions handles GET /api/agents/{agent_id}/sessions
 func (h *AgentHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 	// Get authenticated user
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
 		return
@@ -216,41 +558,193 @@ func (h *AgentHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 
 	agentID := chi.URLParam(r, "agent_id")
 
-	// Check if agent exists and belongs to user
+	// Check if agent exists and is visible to the user
 	agent, err := h.store.GetAgent(agentID)
 	if err != nil {
 		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
 		return
 	}
 
-	if agent.UserID != claims.UserID {
+	if !canViewAgent(h.store, caller.UserID, agent) {
 		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
 		return
 	}
 
-	// Get expired parameter
-	includeExpired := r.URL.Query().Get("expired") != "false"
+	fields, err := parseFields(r.URL.Query().Get("fields"), sessionFields)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	window, err := parseListWindow(r)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	query := store.Query{
+		AgentID:        agentID,
+		Search:         r.URL.Query().Get("search"),
+		Status:         r.URL.Query().Get("status"),
+		Since:          window.since,
+		Until:          window.until,
+		ExcludeExpired: r.URL.Query().Get("expired") == "false",
+		// Optional grouping filters (derived from topic grouping rules)
+		Group:    r.URL.Query().Get("group"),
+		Category: r.URL.Query().Get("category"),
+	}
 
-	sessions := h.store.ListSessions(agentID, includeExpired)
+	// Load just the page unless watched sessions reorder it
+	watches := loadWatchSet(h.store, caller.UserID)
+	var sessions []*models.Session
+	var total int
+	if !watches.anyWatched(agentID) {
+		query.Page = page.store()
+		sessions, total, err = h.store.QuerySessions(query)
+		if err != nil {
+			h.respondQueryError(w, err, "Failed to list sessions")
+			return
+		}
+	} else {
+		filtered, _, err := h.store.QuerySessions(query)
+		if err != nil {
+			h.respondQueryError(w, err, "Failed to list sessions")
+			return
+		}
+
+		// Watched sessions come first, otherwise keeping the store's order
+		sort.SliceStable(filtered, func(i, j int) bool {
+			return watches.watched(agentID, filtered[i].SessionTopic) && !watches.watched(agentID, filtered[j].SessionTopic)
+		})
+
+		total = len(filtered)
+		start, end := page.bounds(total)
+		sessions = filtered[start:end]
+	}
 
 	// Enrich sessions with current status
-	sessionsWithStatus := make([]SessionWithStatus, 0, len(sessions))
+	sessionsWithStatus := make([]interface{}, 0, len(sessions))
 	for _, session := range sessions {
 		sessionWithStatus := SessionWithStatus{
 			Session: session,
+			Watched: watches.watched(agentID, session.SessionTopic),
 		}
 
 		// Get latest status for this session
-		latestStatus, err := h.store.GetLatestStatus(agentID, session.SessionTopic)
-		if err == nil && latestStatus != nil {
-			sessionWithStatus.CurrentStatus = &latestStatus.Status
+		if fields.has("current_status") {
+			latestStatus, err := h.store.GetLatestStatus(agentID, session.SessionTopic)
+			if err == nil && latestStatus != nil {
+				sessionWithStatus.CurrentStatus = &latestStatus.Status
+			}
 		}
 
-		sessionsWithStatus = append(sessionsWithStatus, sessionWithStatus)
+		projected, err := fields.project(sessionWithStatus)
+		if err != nil {
+			h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to build response")
+			return
+		}
+		sessionsWithStatus = append(sessionsWithStatus, projected)
+	}
+
+	respondPage(w, r, page, h.legacyKey("sessions"), sessionsWithStatus, total, nil)
+}
+
+// GetSession handles GET /api/agents/{agent_id}/sessions/{session_topic}
+// The details of ended runs can be revalidated with If-Modified-Since, see cacheSession.
+func (h *AgentHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agentID := chi.URLParam(r, "agent_id")
+	sessionTopic := chi.URLParam(r, "session_topic")
+
+	fields, err := parseFields(r.URL.Query().Get("fields"), sessionDetailFields)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	// Check if agent exists and is visible to the user
+	agent, err := h.store.GetAgent(agentID)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+
+	if !canViewAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	session, err := h.store.GetSession(agentID, sessionTopic)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Session not found")
+		return
+	}
+
+	var annotations []*models.StatusAnnotation
+	if fields.has("status_history") {
+		if annotations, err = h.store.ListStatusAnnotations(agentID, sessionTopic); err != nil {
+			h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to load annotations")
+			return
+		}
+	}
+	if h.cacheSession(w, r, session, annotations) {
+		return
+	}
+
+	projected, err := fields.project(session)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to build response")
+		return
 	}
 
 	response := map[string]interface{}{
-		"sessions": sessionsWithStatus,
+		"session": projected,
+	}
+
+	if fields.has("status_history") {
+		historyPage, err := parseHistoryPage(r)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+
+		// Get one page of status history, newest first, optionally of a single run
+		var history []*models.AgentStatus
+		var total int
+		if raw := r.URL.Query().Get("revision"); raw != "" {
+			revision, err := strconv.Atoi(raw)
+			if err != nil {
+				h.respondError(w, http.StatusBadRequest, "bad_request", "revision must be an integer")
+				return
+			}
+			all, _ := h.store.GetStatusHistory(agentID, sessionTopic)
+			all = filterRevision(all, revision)
+			sort.Slice(all, func(i, j int) bool {
+				return all[i].Timestamp.After(all[j].Timestamp)
+			})
+			total = len(all)
+			start, end := historyPage.bounds(total)
+			history = all[start:end]
+		} else {
+			history, total, _ = h.store.GetStatusHistoryPage(agentID, sessionTopic, historyPage.store())
+		}
+
+		response["status_history"] = annotateHistory(history, annotations)
+		response["status_history_total"] = total
+		response["status_history_next_cursor"] = nil
+		if end := min(historyPage.offset, total) + len(history); end < total {
+			response["status_history_next_cursor"] = encodeCursor(end)
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -258,10 +752,85 @@ func (h *AgentHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// GetSession handles GET /api/agents/{agent_id}/sessions/{session_topic}
-func (h *AgentHandler) GetSession(w http.ResponseWriter, r *http.Request) {
+// maxSessionMaxAge caps how long clients may reuse the details of an ended session run without revalidating
+const maxSessionMaxAge = time.Hour
+
+// cacheSession sets the caching headers of a session's details and answers 304 when the client's copy is current,
+// returning true when the response is complete
+// Details change with the session, its statuses, including those the server records, and annotations, which are
+// passed when the status history is included. Once the run has ended they rarely change again: they get a
+// Last-Modified date, and may be reused for a tenth of their age, as HTTP suggests for heuristic freshness. Running
+// sessions, and ended ones changed within the last second, which a Last-Modified date cannot tell apart, must be
+// revalidated every time.
+func (h *AgentHandler) cacheSession(w http.ResponseWriter, r *http.Request, session *models.Session, annotations []*models.StatusAnnotation) bool {
+	lastModified := session.LastUpdated
+	if session.ExpiredAt != nil && session.ExpiredAt.After(lastModified) {
+		lastModified = *session.ExpiredAt
+	}
+	if latest, err := h.store.GetLatestStatus(session.AgentID, session.SessionTopic); err == nil && latest.Timestamp.After(lastModified) {
+		lastModified = latest.Timestamp
+	}
+	for _, annotation := range annotations {
+		if annotation.CreatedAt.After(lastModified) {
+			lastModified = annotation.CreatedAt
+		}
+	}
+
+	age := h.clock.Now().Sub(lastModified)
+	ended := session.Expired || session.EndReason != ""
+	if !ended || age < time.Second {
+		w.Header().Set("Cache-Control", "private, no-cache")
+		return false
+	}
+
+	maxAge := min(age/10, maxSessionMaxAge)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	if !notModifiedSince(r, lastModified) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// parseHistoryPage reads the history_limit and history_cursor parameters paging a session's status history
+func parseHistoryPage(r *http.Request) (listPage, error) {
+	limit, err := parsePositiveInt(r.URL.Query().Get("history_limit"), 0)
+	if err != nil || limit > maxListLimit {
+		return listPage{}, fmt.Errorf("history_limit must be 1-%d", maxListLimit)
+	}
+
+	page := listPage{limit: limit}
+	if cursor := r.URL.Query().Get("history_cursor"); cursor != "" {
+		if page.offset, err = decodeCursor(cursor); err != nil {
+			return listPage{}, errors.New("invalid history_cursor")
+		}
+	}
+	return page, nil
+}
+
+// filterRevision returns the statuses reported for one revision of a session
+func filterRevision(history []*models.AgentStatus, revision int) []*models.AgentStatus {
+	filtered := make([]*models.AgentStatus, 0, len(history))
+	for _, status := range history {
+		if status.Revision == revision {
+			filtered = append(filtered, status)
+		}
+	}
+	return filtered
+}
+
+// Defaults for session run listings
+const (
+	defaultRunLimit = 20
+	maxRunLimit     = 100
+)
+
+// ListSessionRuns handles GET /api/agents/{agent_id}/sessions/{session_topic}/runs
+// Each revision of the session is one run; durations are compared across all finished runs.
+func (h *AgentHandler) ListSessionRuns(w http.ResponseWriter, r *http.Request) {
 	// Get authenticated user
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
 		return
@@ -270,14 +839,23 @@ func (h *AgentHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	agentID := chi.URLParam(r, "agent_id")
 	sessionTopic := chi.URLParam(r, "session_topic")
 
-	// Check if agent exists and belongs to user
+	limit, err := parsePositiveInt(r.URL.Query().Get("limit"), defaultRunLimit)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "limit must be a positive integer")
+		return
+	}
+	if limit > maxRunLimit {
+		limit = maxRunLimit
+	}
+
+	// Check if agent exists and is visible to the user
 	agent, err := h.store.GetAgent(agentID)
 	if err != nil {
 		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
 		return
 	}
 
-	if agent.UserID != claims.UserID {
+	if !canViewAgent(h.store, caller.UserID, agent) {
 		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
 		return
 	}
@@ -288,17 +866,23 @@ func (h *AgentHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get status history
-	history, _ := h.store.GetStatusHistory(agentID, sessionTopic)
+	history, err := h.store.GetStatusHistory(agentID, sessionTopic)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to load status history")
+		return
+	}
 
-	// Sort by timestamp descending (newest first)
-	sort.Slice(history, func(i, j int) bool {
-		return history[i].Timestamp.After(history[j].Timestamp)
-	})
+	runs := internal.BuildRuns(history)
+	comparison := internal.CompareRuns(runs)
+	if len(runs) > limit {
+		runs = runs[:limit]
+	}
 
 	response := map[string]interface{}{
-		"session":        session,
-		"status_history": history,
+		"session_topic":    session.SessionTopic,
+		"current_revision": session.Revision,
+		"runs":             runs,
+		"comparison":       comparison,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -306,10 +890,194 @@ func (h *AgentHandler) GetSession(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// defaultTimelineMinGapSeconds is the shortest silence between reports listed as a gap in session timelines
+const defaultTimelineMinGapSeconds = 300
+
+// GetSessionTimeline handles GET /api/agents/{agent_id}/sessions/{session_topic}/timeline
+// The phases of the current run, or of ?revision=N, are derived from its status history.
+func (h *AgentHandler) GetSessionTimeline(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agentID := chi.URLParam(r, "agent_id")
+	sessionTopic := chi.URLParam(r, "session_topic")
+
+	minGap, err := parsePositiveInt(r.URL.Query().Get("min_gap_seconds"), defaultTimelineMinGapSeconds)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "min_gap_seconds must be a positive integer")
+		return
+	}
+
+	// Check if agent exists and is visible to the user
+	agent, err := h.store.GetAgent(agentID)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+
+	if !canViewAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	session, err := h.store.GetSession(agentID, sessionTopic)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Session not found")
+		return
+	}
+
+	revision := session.Revision
+	if raw := r.URL.Query().Get("revision"); raw != "" {
+		if revision, err = strconv.Atoi(raw); err != nil {
+			h.respondError(w, http.StatusBadRequest, "bad_request", "revision must be an integer")
+			return
+		}
+	}
+
+	history, err := h.store.GetStatusHistory(agentID, sessionTopic)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to load status history")
+		return
+	}
+
+	timeline := internal.BuildTimeline(history, revision, time.Duration(minGap)*time.Second)
+	if timeline == nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Run not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"session_topic": session.SessionTopic,
+		"timeline":      timeline,
+	})
+}
+
+// ListRollups handles GET /api/agents/{agent_id}/rollups, listing the daily status rollups of an agent's sessions
+// from and to are inclusive dates (YYYY-MM-DD) defaulting to the last 30 days; session_topic selects one session.
+func (h *AgentHandler) ListRollups(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agentID := chi.URLParam(r, "agent_id")
+
+	from, to, err := parseUsageRange(r, h.clock.Now())
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	// Check if agent exists and is visible to the user
+	agent, err := h.store.GetAgent(agentID)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+
+	if !canViewAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	// to is inclusive for callers but exclusive in the store
+	rollups, err := h.store.ListStatusRollups(agentID, r.URL.Query().Get("session_topic"), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to list rollups")
+		return
+	}
+
+	respondList(w, r, page, "", rollups, nil)
+}
+
+// Defaults for recurring task detection
+const (
+	defaultTaskMinRuns      = 2
+	defaultTaskHistoryLimit = 10
+	maxTaskHistoryLimit     = 100
+)
+
+// ListTasks handles GET /api/agents/{agent_id}/tasks
+// Sessions whose topics normalize to the same key are reported as runs of one recurring task.
+func (h *AgentHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	// Get authenticated user
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agentID := chi.URLParam(r, "agent_id")
+
+	// Check if agent exists and is visible to the user
+	agent, err := h.store.GetAgent(agentID)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+
+	if !canViewAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	minRuns, err := parsePositiveInt(r.URL.Query().Get("min_runs"), defaultTaskMinRuns)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "min_runs must be a positive integer")
+		return
+	}
+	historyLimit, err := parsePositiveInt(r.URL.Query().Get("limit"), defaultTaskHistoryLimit)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "limit must be a positive integer")
+		return
+	}
+	if historyLimit > maxTaskHistoryLimit {
+		historyLimit = maxTaskHistoryLimit
+	}
+
+	sessions := h.store.ListSessions(agentID, true)
+
+	// Latest status of each session is its run result
+	results := make(map[string]string, len(sessions))
+	for _, session := range sessions {
+		status, err := h.store.GetLatestStatus(agentID, session.SessionTopic)
+		if err == nil && status != nil {
+			results[session.SessionTopic] = status.Status
+		}
+	}
+
+	// limit sets the history length of each task here, so every task is returned in one page
+	respondList(w, r, listPage{}, h.legacyKey("tasks"), internal.BuildTasks(sessions, results, minRuns, historyLimit), nil)
+}
+
+// parsePositiveInt parses an optional positive integer query parameter
+func parsePositiveInt(raw string, defaultValue int) (int, error) {
+	if raw == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 {
+		return 0, errors.New("must be a positive integer")
+	}
+	return value, nil
+}
+
 // GetAgentStatus handles GET /api/agents/{agent_id}/status
 func (h *AgentHandler) GetAgentStatus(w http.ResponseWriter, r *http.Request) {
 	// Get authenticated user
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
 		return
@@ -317,14 +1085,14 @@ func (h *AgentHandler) GetAgentStatus(w http.ResponseWriter, r *http.Request) {
 
 	agentID := chi.URLParam(r, "agent_id")
 
-	// Check if agent exists and belongs to user
+	// Check if agent exists and is visible to the user
 	agent, err := h.store.GetAgent(agentID)
 	if err != nil {
 		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
 		return
 	}
 
-	if agent.UserID != claims.UserID {
+	if !canViewAgent(h.store, caller.UserID, agent) {
 		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
 		return
 	}
@@ -353,7 +1121,17 @@ func (h *AgentHandler) GetAgentStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(latestStatus)
 }
 
+// respondQueryError answers a failed store query, rejecting invalid filters as bad requests
+func (h *AgentHandler) respondQueryError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, store.ErrInvalid) {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+	h.respondError(w, http.StatusInternalServerError, "internal_error", message)
+}
+
 // respondError sends an error response
+
 func (h *AgentHandler) respondError(w http.ResponseWriter, statusCode int, errorCode, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// DeletedAgent is a soft-deleted agent with the time it will be purged, if it will be
+type DeletedAgent struct {
+	*models.Agent
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+func (h *AgentHandler) deletedAgent(agent *models.Agent) *DeletedAgent {
+	deleted := &DeletedAgent{Agent: agent}
+	if h.purgeAfter > 0 && agent.DeletedAt != nil {
+		purgeAt := agent.DeletedAt.Add(h.purgeAfter)
+		deleted.PurgeAt = &purgeAt
+	}
+	return deleted
+}
+
+// DeleteAgent handles DELETE /api/agents/{agent_id}
+// The agent is soft-deleted: it disappears with its sessions and statuses, and can be restored until it is purged.
+// Its running sessions are first failed or expired if its owner chose so in session_auto_close.
+func (h *AgentHandler) DeleteAgent(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agent, err := h.store.GetAgent(chi.URLParam(r, "agent_id"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+	if agent.UserID != caller.UserID {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	// Sessions are hidden with the agent, so they are closed first
+	if h.closer != nil {
+		h.closer.AgentDeleted(agent)
+	}
+
+	now := h.clock.Now().UTC()
+	if err := h.store.DeleteAgent(agent.AgentID, now); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+			return
+		}
+		log.Printf("Failed to delete agent %s: %v", agent.AgentID, err)
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to delete agent")
+		return
+	}
+
+	agent.DeletedAt = &now
+	respondJSON(w, http.StatusOK, h.deletedAgent(agent))
+}
+
+// ListDeletedAgents handles GET /api/deleted-agents, listing the caller's deleted agents most recently deleted first
+func (h *AgentHandler) ListDeletedAgents(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	agents, err := h.store.ListDeletedAgents(caller.UserID)
+	if err != nil {
+		log.Printf("Failed to list deleted agents: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to list deleted agents")
+		return
+	}
+
+	deleted := make([]*DeletedAgent, 0, len(agents))
+	for _, agent := range agents {
+		deleted = append(deleted, h.deletedAgent(agent))
+	}
+	respondList(w, r, page, h.legacyKey("agents"), deleted, nil)
+}
+
+// RestoreAgent handles POST /api/agents/{agent_id}/restore, bringing back a deleted agent with its history
+func (h *AgentHandler) RestoreAgent(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agentID := chi.URLParam(r, "agent_id")
+	agents, err := h.store.ListDeletedAgents(caller.UserID)
+	if err != nil {
+		log.Printf("Failed to list deleted agents: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to restore agent")
+		return
+	}
+	owned := false
+	for _, agent := range agents {
+		owned = owned || agent.AgentID == agentID
+	}
+	// Other users' deleted agents are indistinguishable from missing ones
+	if !owned {
+		h.respondError(w, http.StatusNotFound, "not_found", "Deleted agent not found")
+		return
+	}
+
+	if err := h.store.RestoreAgent(agentID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "not_found", "Deleted agent not found")
+			return
+		}
+		log.Printf("Failed to restore agent %s: %v", agentID, err)
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to restore agent")
+		return
+	}
+
+	agent, err := h.store.GetAgent(agentID)
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to get restored agent")
+		return
+	}
+	respondJSON(w, http.StatusOK, agent)
+}
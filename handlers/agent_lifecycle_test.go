@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/sessionclose"
+)
+
+func TestAgentHandler_DeleteAndRestoreAgent(t *testing.T) {
+	st := testsupport.StoreWithAgents(t, 2, 1)
+	testsupport.CreateUser(t, st, testsupport.UserWithID("other-user", "other@example.com"))
+	handler := NewAgentHandler(st)
+	handler.SetDeletedAgentRetention(30 * 24 * time.Hour)
+
+	call := func(fn http.HandlerFunc, method, agentID, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/agents/"+agentID, nil)
+		req = testsupport.WithCaller(req, userID, userID+"@example.com")
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", agentID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		fn(rr, req)
+		return rr
+	}
+
+	if rr := call(handler.DeleteAgent, "DELETE", "agent-001", "other-user"); rr.Code != http.StatusForbidden {
+		t.Errorf("DeleteAgent() by another user status = %v, want %v", rr.Code, http.StatusForbidden)
+	}
+	rr := call(handler.DeleteAgent, "DELETE", "agent-001", testsupport.UserID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("DeleteAgent() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var deleted DeletedAgent
+	if err := json.Unmarshal(rr.Body.Bytes(), &deleted); err != nil || deleted.DeletedAt == nil || deleted.PurgeAt == nil ||
+		deleted.PurgeAt.Sub(*deleted.DeletedAt) != 30*24*time.Hour {
+		t.Errorf("DeleteAgent() = %s, want deleted_at and purge_at 30 days later", rr.Body.String())
+	}
+	if rr := call(handler.DeleteAgent, "DELETE", "agent-001", testsupport.UserID); rr.Code != http.StatusNotFound {
+		t.Errorf("DeleteAgent() twice status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	if rr := call(handler.GetAgent, "GET", "agent-001", testsupport.UserID); rr.Code != http.StatusNotFound {
+		t.Errorf("GetAgent() deleted status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+
+	rr = call(handler.ListDeletedAgents, "GET", "", testsupport.UserID)
+	var list struct {
+		Items []DeletedAgent `json:"items"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || len(list.Items) != 1 || list.Items[0].AgentID != "agent-001" {
+		t.Errorf("ListDeletedAgents() = %s, want agent-001", rr.Body.String())
+	}
+
+	// Reports for the deleted agent are refused rather than recreating it
+	webhook := NewWebhookHandlerWithNotifier(st, nil)
+	if rr := testsupport.PostStatus(webhook, "agent-001", "task-001", "running", time.Now(), "", ""); rr.Code != http.StatusGone {
+		t.Errorf("status report for a deleted agent status = %v, want %v", rr.Code, http.StatusGone)
+	}
+
+	if rr := call(handler.RestoreAgent, "POST", "agent-001", "other-user"); rr.Code != http.StatusNotFound {
+		t.Errorf("RestoreAgent() by another user status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	if rr := call(handler.RestoreAgent, "POST", "agent-001", testsupport.UserID); rr.Code != http.StatusOK {
+		t.Fatalf("RestoreAgent() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if history, err := st.GetStatusHistory("agent-001", "task-001"); err != nil || len(history) != 1 {
+		t.Errorf("history after restore = %d statuses, %v, want 1", len(history), err)
+	}
+	testsupport.SendStatus(t, webhook, "agent-001", "task-001", "success", time.Now(), "", "")
+}
+
+func TestAgentHandler_DeleteAgentClosesSessions(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	user, _ := st.GetUserByID(testsupport.UserID)
+	user.SessionAutoClose.OnDelete = models.SessionCloseFail
+	if err := st.UpdateUser(user); err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	handler := NewAgentHandler(st)
+	handler.SetSessionCloser(sessionclose.NewCloser(st))
+
+	req := testsupport.WithUser(httptest.NewRequest("DELETE", "/api/agents/agent-001", nil))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("agent_id", "agent-001")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	handler.DeleteAgent(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("DeleteAgent() status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+
+	// The running task-001 failed before the agent was hidden; restoring it shows the failure
+	if err := st.RestoreAgent("agent-001"); err != nil {
+		t.Fatalf("RestoreAgent() error = %v", err)
+	}
+	latest, err := st.GetLatestStatus("agent-001", "task-001")
+	if err != nil || latest.Status != "failed" {
+		t.Errorf("latest status of task-001 = %+v, %v, want failed", latest, err)
+	}
+	if session, _ := st.GetSession("agent-001", "task-001"); session.EndReason != models.EndReasonAgentDeleted {
+		t.Errorf("task-001 end reason = %q, want %q", session.EndReason, models.EndReasonAgentDeleted)
+	}
+	if latest, _ := st.GetLatestStatus("agent-001", "task-002"); latest.Status != "success" {
+		t.Errorf("latest status of finished task-002 = %s, want success", latest.Status)
+	}
+}
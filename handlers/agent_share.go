@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// ShareAgentRequest represents a request to share an agent with an organization
+type ShareAgentRequest struct {
+	OrgID string `json:"org_id"` // Empty stops sharing
+}
+
+// ShareAgent handles PUT /api/agents/{agent_id}/org, sharing an agent with an organization or unsharing it
+// Only the agent's owner may share it, with an organization they can manage agents in; the owner keeps
+// reporting for the agent either way.
+func (h *AgentHandler) ShareAgent(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req ShareAgentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid request body")
+		return
+	}
+
+	if req.OrgID != "" {
+		membership, err := h.store.GetMembership(req.OrgID, caller.UserID)
+		if err != nil {
+			h.respondError(w, http.StatusNotFound, "not_found", "Organization not found")
+			return
+		}
+		if !models.CanManageAgents(membership.Role) {
+			h.respondError(w, http.StatusForbidden, "forbidden", "Viewers cannot share agents with the organization")
+			return
+		}
+	}
+
+	agent, err := h.store.GetAgent(chi.URLParam(r, "agent_id"))
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+	if agent.UserID != caller.UserID {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Only the agent's owner can share it")
+		return
+	}
+
+	agent.OrgID = req.OrgID
+	if err := h.store.CreateOrUpdateAgent(agent); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			h.respondError(w, http.StatusConflict, "conflict", "Agent was modified concurrently, retry the update")
+			return
+		}
+		log.Printf("Failed to share agent %s: %v", agent.AgentID, err)
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to update agent")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, agent)
+}
@@ -3,94 +3,23 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
-	"github.com/kubeagents/kubeagents/auth"
-	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
 	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/store"
 )
 
-const testUserID = "test-user-123"
-const testUserEmail = "test@example.com"
-
-// setupTestStoreWithAgents creates a test store with a test user and agents
-func setupTestStoreWithAgents() store.Store {
-	st := store.NewMemoryStore()
-	now := time.Now()
-
-	// Create test user
-	user := &models.User{
-		ID:            testUserID,
-		Email:         testUserEmail,
-		Name:          "Test User",
-		PasswordHash:  "dummy-hash",
-		EmailVerified: true,
-		CreatedAt:     now,
-		UpdatedAt:     now,
-	}
-	st.CreateUser(user)
-
-	// Create agents with sessions
-	for i := 1; i <= 3; i++ {
-		agentID := fmt.Sprintf("agent-%03d", i)
-		agentName := fmt.Sprintf("Agent %d", i)
-		agent := &models.Agent{
-			AgentID:    agentID,
-			UserID:     testUserID, // Associate with test user
-			Name:       agentName,
-			Source:     "test-software",
-			Registered: now,
-			LastSeen:   now,
-		}
-		st.CreateOrUpdateAgent(agent)
-
-		// Create sessions
-		for j := 1; j <= 2; j++ {
-			sessionTopic := fmt.Sprintf("task-%03d", j)
-			session := &models.Session{
-				AgentID:      agentID,
-				SessionTopic: sessionTopic,
-				Created:      now,
-				LastUpdated:  now,
-				Expired:      false,
-			}
-			st.CreateOrUpdateSession(session)
-
-			// Add status
-			status := &models.AgentStatus{
-				AgentID:      agentID,
-				SessionTopic: sessionTopic,
-				Status:       "running",
-				Timestamp:    now,
-			}
-			st.AddStatus(status)
-		}
-	}
-
-	return st
-}
-
-// addTestUserToContext adds a test user to the request context
-func addTestUserToContext(r *http.Request) *http.Request {
-	claims := &auth.AccessTokenClaims{
-		UserID: testUserID,
-		Email:  testUserEmail,
-	}
-	ctx := context.WithValue(r.Context(), middleware.UserContextKey, claims)
-	return r.WithContext(ctx)
-}
-
 func TestAgentHandler_ListAgents(t *testing.T) {
-	st := setupTestStoreWithAgents()
+	st := testsupport.StoreWithAgents(t, 3, 2)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents", nil)
-	req = addTestUserToContext(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ListAgents(rr, req)
@@ -112,12 +41,12 @@ func TestAgentHandler_ListAgents(t *testing.T) {
 }
 
 func TestAgentHandler_ListAgentsWithStatusFilter(t *testing.T) {
-	st := setupTestStoreWithAgents()
+	st := testsupport.StoreWithAgents(t, 3, 2)
 	handler := NewAgentHandler(st)
 
 	// Test with status filter
 	req := httptest.NewRequest("GET", "/api/agents?status=running", nil)
-	req = addTestUserToContext(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ListAgents(rr, req)
@@ -139,13 +68,139 @@ func TestAgentHandler_ListAgentsWithStatusFilter(t *testing.T) {
 	}
 }
 
+func TestAgentHandler_ListAgentsWithStateFilter(t *testing.T) {
+	st := testsupport.StoreWithAgents(t, 3, 2)
+	handler := NewAgentHandler(st)
+
+	agent, _ := st.GetAgent("agent-002")
+	agent.SetState(models.AgentStateOffline, time.Now())
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+
+	req := testsupport.WithUser(httptest.NewRequest("GET", "/api/agents?state=offline", nil))
+	rr := httptest.NewRecorder()
+	handler.ListAgents(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ListAgents() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	var response struct {
+		Agents []map[string]interface{} `json:"agents"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("ListAgents() invalid JSON: %v", err)
+	}
+	if len(response.Agents) != 1 || response.Agents[0]["agent_id"] != "agent-002" || response.Agents[0]["state"] != "offline" {
+		t.Errorf("ListAgents(state=offline) = %v, want only agent-002", response.Agents)
+	}
+
+	req = testsupport.WithUser(httptest.NewRequest("GET", "/api/agents?state=asleep", nil))
+	rr = httptest.NewRecorder()
+	handler.ListAgents(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("ListAgents(state=asleep) status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAgentHandler_ListAgentsWithKindFilter(t *testing.T) {
+	st := testsupport.StoreWithAgents(t, 3, 1)
+	handler := NewAgentHandler(st)
+
+	agent, _ := st.GetAgent("agent-003")
+	agent.Kind = models.AgentKindCI
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+
+	req := testsupport.WithUser(httptest.NewRequest("GET", "/api/agents?kind=ci", nil))
+	rr := httptest.NewRecorder()
+	handler.ListAgents(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ListAgents() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	var response struct {
+		Agents []map[string]interface{} `json:"agents"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("ListAgents() invalid JSON: %v", err)
+	}
+	if len(response.Agents) != 1 || response.Agents[0]["agent_id"] != "agent-003" || response.Agents[0]["kind"] != "ci" {
+		t.Errorf("ListAgents(kind=ci) = %v, want only agent-003", response.Agents)
+	}
+
+	req = testsupport.WithUser(httptest.NewRequest("GET", "/api/agents?kind=robot", nil))
+	rr = httptest.NewRecorder()
+	handler.ListAgents(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("ListAgents(kind=robot) status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAgentHandler_ListAgentsByLocation(t *testing.T) {
+	st := testsupport.StoreWithAgents(t, 4, 0)
+	handler := NewAgentHandler(st)
+
+	for agentID, location := range map[string][2]string{
+		"agent-001": {"prod-eu-1", "eu-west-1"},
+		"agent-002": {"prod-eu-2", "eu-west-1"},
+		"agent-003": {"prod-us-1", "us-east-1"},
+	} {
+		agent, _ := st.GetAgent(agentID)
+		agent.Cluster, agent.Region = location[0], location[1]
+		agent.MarkSeen(time.Now())
+		if err := st.CreateOrUpdateAgent(agent); err != nil {
+			t.Fatalf("CreateOrUpdateAgent(%s) error = %v", agentID, err)
+		}
+	}
+
+	list := func(query string) (int, []map[string]interface{}, []AgentGroup) {
+		req := testsupport.WithUser(httptest.NewRequest("GET", "/api/agents?"+query, nil))
+		rr := httptest.NewRecorder()
+		handler.ListAgents(rr, req)
+		var response struct {
+			Items  []map[string]interface{} `json:"items"`
+			Groups []AgentGroup             `json:"groups"`
+		}
+		if rr.Code == http.StatusOK {
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("ListAgents(%s) invalid JSON: %v", query, err)
+			}
+		}
+		return rr.Code, response.Items, response.Groups
+	}
+
+	if code, items, _ := list("region=eu-west-1"); code != http.StatusOK || len(items) != 2 || items[0]["region"] != "eu-west-1" || items[1]["region"] != "eu-west-1" {
+		t.Errorf("ListAgents(region=eu-west-1) = %d %v, want agent-001 and agent-002", code, items)
+	}
+	if code, items, _ := list("region=eu-west-1&cluster=prod-eu-2"); code != http.StatusOK || len(items) != 1 || items[0]["agent_id"] != "agent-002" {
+		t.Errorf("ListAgents(cluster=prod-eu-2) = %d %v, want only agent-002", code, items)
+	}
+
+	// Groups count every agent, not just the page, and include agents without a region
+	code, items, groups := list("group_by=region&limit=1")
+	if code != http.StatusOK || len(items) != 1 || len(groups) != 3 {
+		t.Fatalf("ListAgents(group_by=region) = %d, %d items, groups %+v, want 1 item in 3 groups", code, len(items), groups)
+	}
+	if groups[0].Value != "eu-west-1" || groups[0].AgentCount != 2 || groups[0].OnlineCount != 2 || groups[1].Value != "" || groups[2].Value != "us-east-1" {
+		t.Errorf("ListAgents(group_by=region) groups = %+v, want eu-west-1 (2 online), then \"\" and us-east-1", groups)
+	}
+
+	for _, query := range []string{"group_by=zone", "cluster=prod%20eu"} {
+		if code, _, _ := list(query); code != http.StatusBadRequest {
+			t.Errorf("ListAgents(%s) status = %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+}
+
 func TestAgentHandler_ListAgentsWithSearch(t *testing.T) {
-	st := setupTestStoreWithAgents()
+	st := testsupport.StoreWithAgents(t, 3, 2)
 	handler := NewAgentHandler(st)
 
 	// Test with search parameter (search by agent ID)
 	req := httptest.NewRequest("GET", "/api/agents?search=agent-001", nil)
-	req = addTestUserToContext(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ListAgents(rr, req)
@@ -168,11 +223,11 @@ func TestAgentHandler_ListAgentsWithSearch(t *testing.T) {
 }
 
 func TestAgentHandler_ListAgentsWithSessionStatistics(t *testing.T) {
-	st := setupTestStoreWithAgents()
+	st := testsupport.StoreWithAgents(t, 3, 2)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents", nil)
-	req = addTestUserToContext(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ListAgents(rr, req)
@@ -201,11 +256,11 @@ func TestAgentHandler_ListAgentsWithSessionStatistics(t *testing.T) {
 }
 
 func TestAgentHandler_ListAgentsResponseTime(t *testing.T) {
-	st := setupTestStoreWithAgents()
+	st := testsupport.StoreWithAgents(t, 3, 2)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents", nil)
-	req = addTestUserToContext(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	start := time.Now()
@@ -217,12 +272,60 @@ func TestAgentHandler_ListAgentsResponseTime(t *testing.T) {
 	}
 }
 
+func TestAgentHandler_ListAgentsPagination(t *testing.T) {
+	st := testsupport.StoreWithAgents(t, 3, 2)
+	handler := NewAgentHandler(st)
+
+	list := func(url string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+		req := testsupport.WithUser(httptest.NewRequest("GET", url, nil))
+		rr := httptest.NewRecorder()
+		handler.ListAgents(rr, req)
+
+		var response map[string]json.RawMessage
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response
+	}
+
+	rr, response := list("/api/agents?limit=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ListAgents() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	var items []interface{}
+	var cursor string
+	json.Unmarshal(response["items"], &items)
+	json.Unmarshal(response["next_cursor"], &cursor)
+	if len(items) != 2 || string(response["total"]) != "3" || cursor == "" {
+		t.Fatalf("ListAgents() first page = %d items, total %s, cursor %q, want 2 items of 3 and a cursor", len(items), response["total"], cursor)
+	}
+	if got, want := rr.Header().Get("Link"), `</api/agents?cursor=`+cursor+`&limit=2>; rel="next"`; got != want {
+		t.Errorf("ListAgents() Link = %q, want %q", got, want)
+	}
+	if got := rr.Header().Get("X-Total-Count"); got != "3" {
+		t.Errorf("ListAgents() X-Total-Count = %q, want 3", got)
+	}
+
+	rr, response = list("/api/agents?limit=2&cursor=" + cursor)
+	json.Unmarshal(response["items"], &items)
+	if len(items) != 1 || string(response["next_cursor"]) != "null" || rr.Header().Get("Link") != "" {
+		t.Errorf("ListAgents() last page = %d items, cursor %s, Link %q, want 1 item and no next page", len(items), response["next_cursor"], rr.Header().Get("Link"))
+	}
+
+	if rr, _ := list("/api/agents?cursor=bogus"); rr.Code != http.StatusBadRequest {
+		t.Errorf("ListAgents() invalid cursor status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	handler.SetLegacyListKeys(false)
+	if _, response := list("/api/agents"); response["agents"] != nil || response["items"] == nil {
+		t.Errorf("ListAgents() without legacy keys = %v, want items only", response)
+	}
+}
+
 func TestAgentHandler_ListAgentsEmpty(t *testing.T) {
 	st := store.NewMemoryStore()
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents", nil)
-	req = addTestUserToContext(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ListAgents(rr, req)
@@ -242,3 +345,39 @@ func TestAgentHandler_ListAgentsEmpty(t *testing.T) {
 		t.Errorf("ListAgentsEmpty() agent count = %v, want 0", len(response.Agents))
 	}
 }
+
+func TestAgentHandler_CancelSession(t *testing.T) {
+	st := testsupport.StoreWithAgents(t, 3, 2)
+	handler := NewAgentHandler(st)
+
+	cancel := func(agentID string) *httptest.ResponseRecorder {
+		req := testsupport.WithUser(httptest.NewRequest("POST", "/api/agents/"+agentID+"/sessions/task-001/cancel", nil))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", agentID)
+		rctx.URLParams.Add("session_topic", "task-001")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler.CancelSession(rr, req)
+		return rr
+	}
+
+	rr := cancel("agent-001")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("CancelSession() status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+	session, _ := st.GetSession("agent-001", "task-001")
+	if !session.Expired || session.ExpiredAt == nil || session.EndReason != models.EndReasonCancelled {
+		t.Errorf("session after cancel = %+v, want expired with end reason cancelled", session)
+	}
+	latest, err := st.GetLatestStatus("agent-001", "task-001")
+	if err != nil || latest.Status != models.StatusCancelledByUser || !latest.FromServer() || latest.Revision != session.Revision {
+		t.Errorf("latest status after cancel = %+v, %v, want the cancelled_by_user server status", latest, err)
+	}
+
+	if rr := cancel("agent-001"); rr.Code != http.StatusConflict {
+		t.Errorf("CancelSession() again status = %v, want %v", rr.Code, http.StatusConflict)
+	}
+	if rr := cancel("agent-999"); rr.Code != http.StatusNotFound {
+		t.Errorf("CancelSession() unknown agent status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
@@ -3,110 +3,27 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/kubeagents/kubeagents/auth"
-	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
 	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/store"
 )
 
-const testUserIDUS3 = "test-user-123"
-const testUserEmailUS3 = "test@example.com"
-
-// addTestUserToContextUS3 adds a test user to the request context
-func addTestUserToContextUS3(r *http.Request) *http.Request {
-	claims := &auth.AccessTokenClaims{
-		UserID: testUserIDUS3,
-		Email:  testUserEmailUS3,
-	}
-	ctx := context.WithValue(r.Context(), middleware.UserContextKey, claims)
-	return r.WithContext(ctx)
-}
-
-func setupTestStoreForUS3() store.Store {
-	st := store.NewMemoryStore()
-	now := time.Now()
-
-	// Create test user
-	user := &models.User{
-		ID:            testUserIDUS3,
-		Email:         testUserEmailUS3,
-		Name:          "Test User",
-		PasswordHash:  "dummy-hash",
-		EmailVerified: true,
-		CreatedAt:     now,
-		UpdatedAt:     now,
-	}
-	st.CreateUser(user)
-
-	// Create agent
-	agent := &models.Agent{
-		AgentID:    "agent-001",
-		UserID:     testUserIDUS3, // Associate with test user
-		Name:       "Test Agent",
-		Source:     "test-software",
-		Registered: now,
-		LastSeen:   now,
-	}
-	st.CreateOrUpdateAgent(agent)
-
-	// Create multiple sessions with different statuses
-	sessions := []struct {
-		topic   string
-		status  string
-		expired bool
-	}{
-		{"task-001", "running", false},
-		{"task-002", "success", false},
-		{"task-003", "failed", true},
-	}
-
-	for i, s := range sessions {
-		session := &models.Session{
-			AgentID:      "agent-001",
-			SessionTopic: s.topic,
-			Created:      now.Add(time.Duration(i) * time.Hour),
-			LastUpdated:  now.Add(time.Duration(i) * time.Hour),
-			Expired:      s.expired,
-		}
-		st.CreateOrUpdateSession(session)
-
-		// Add status history
-		status1 := &models.AgentStatus{
-			AgentID:      "agent-001",
-			SessionTopic: s.topic,
-			Status:       "running",
-			Timestamp:    now.Add(time.Duration(i) * time.Hour),
-			Message:      "Task started",
-		}
-		st.AddStatus(status1)
-
-		if s.status != "running" {
-			status2 := &models.AgentStatus{
-				AgentID:      "agent-001",
-				SessionTopic: s.topic,
-				Status:       s.status,
-				Timestamp:    now.Add(time.Duration(i)*time.Hour + 30*time.Minute),
-				Message:      "Task " + s.status,
-			}
-			st.AddStatus(status2)
-		}
-	}
-
-	return st
-}
-
 func TestAgentHandler_GetAgent(t *testing.T) {
-	st := setupTestStoreForUS3()
+	st := testsupport.StoreWithSessionStates(t)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents/agent-001", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	// Set up chi context with route parameter
@@ -167,7 +84,7 @@ func TestAgentHandler_GetAgentNotFound(t *testing.T) {
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents/agent-999", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -190,12 +107,115 @@ func TestAgentHandler_GetAgentNotFound(t *testing.T) {
 	}
 }
 
+func TestAgentHandler_UpdateSampling(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	update := func(agentID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/agents/"+agentID+"/sampling", strings.NewReader(body))
+		req = testsupport.WithUser(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", agentID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler.UpdateSampling(rr, req)
+		return rr
+	}
+
+	if rr := update("agent-001", `{"heartbeat_sample_every": 10}`); rr.Code != http.StatusOK {
+		t.Fatalf("UpdateSampling() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if agent, _ := st.GetAgent("agent-001"); agent.HeartbeatSampleEvery != 10 {
+		t.Errorf("UpdateSampling() stored heartbeat_sample_every = %d, want 10", agent.HeartbeatSampleEvery)
+	}
+
+	if rr := update("agent-001", `{"heartbeat_sample_every": -1}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("UpdateSampling() negative status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := update("agent-999", `{"heartbeat_sample_every": 5}`); rr.Code != http.StatusNotFound {
+		t.Errorf("UpdateSampling() missing agent status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+
+	now := time.Now()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "other-agent", UserID: "other-user", Registered: now, LastSeen: now})
+	if rr := update("other-agent", `{"heartbeat_sample_every": 5}`); rr.Code != http.StatusForbidden {
+		t.Errorf("UpdateSampling() other user's agent status = %v, want %v", rr.Code, http.StatusForbidden)
+	}
+}
+
+func TestAgentHandler_UpdateConfig(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	call := func(method, agentID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/agents/"+agentID+"/config", strings.NewReader(body))
+		req = testsupport.WithUser(req)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", agentID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		if method == "GET" {
+			handler.GetConfig(rr, req)
+		} else {
+			handler.UpdateConfig(rr, req)
+		}
+		return rr
+	}
+
+	rr := call("PUT", "agent-001", `{"config": {"report_interval_seconds": 30, "log_level": "debug", "team": "infra"}}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("UpdateConfig() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	rr = call("GET", "agent-001", "")
+	var resp AgentConfigResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if want := `{"report_interval_seconds":30,"log_level":"debug","team":"infra"}`; string(resp.Config) != want || resp.ConfigVersion != 1 {
+		t.Errorf("GetConfig() = %s at version %d, want %s at version 1", resp.Config, resp.ConfigVersion, want)
+	}
+
+	// The agent receives the config with its version in the responses to its reports and keepalives
+	webhook := NewWebhookHandlerWithNotifier(st, nil)
+	rr = testsupport.PostStatus(webhook, "agent-001", "task-001", "running", time.Now(), "", "")
+	var reported SuccessResponse
+	json.Unmarshal(rr.Body.Bytes(), &reported)
+	if string(reported.Config) != string(resp.Config) || reported.ConfigVersion != 1 {
+		t.Errorf("webhook response config = %s at version %d, want the saved config at version 1", reported.Config, reported.ConfigVersion)
+	}
+	rr = sendKeepalive(webhook, `{"agent_id":"agent-001","session_topic":"task-001"}`)
+	var kept KeepaliveResponse
+	json.Unmarshal(rr.Body.Bytes(), &kept)
+	if string(kept.Config) != string(resp.Config) || kept.ConfigVersion != 1 {
+		t.Errorf("keepalive response config = %s at version %d, want the saved config at version 1", kept.Config, kept.ConfigVersion)
+	}
+
+	if rr := call("PUT", "agent-001", `{"config": null}`); rr.Code != http.StatusOK {
+		t.Fatalf("UpdateConfig() clearing status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	if agent, _ := st.GetAgent("agent-001"); agent.Config != nil || agent.ConfigVersion != 2 {
+		t.Errorf("UpdateConfig() cleared config = %s at version %d, want none at version 2", agent.Config, agent.ConfigVersion)
+	}
+
+	if rr := call("PUT", "agent-001", `{"config": {"log_level": "trace"}}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("UpdateConfig() invalid log level status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := call("PUT", "agent-999", `{"config": {}}`); rr.Code != http.StatusNotFound {
+		t.Errorf("UpdateConfig() missing agent status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	now := time.Now()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "other-agent", UserID: "other-user", Registered: now, LastSeen: now})
+	if rr := call("GET", "other-agent", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("GetConfig() other user's agent status = %v, want %v", rr.Code, http.StatusForbidden)
+	}
+}
+
 func TestAgentHandler_ListSessions(t *testing.T) {
-	st := setupTestStoreForUS3()
+	st := testsupport.StoreWithSessionStates(t)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -241,12 +261,12 @@ func TestAgentHandler_ListSessions(t *testing.T) {
 }
 
 func TestAgentHandler_ListSessionsWithExpiredFilter(t *testing.T) {
-	st := setupTestStoreForUS3()
+	st := testsupport.StoreWithSessionStates(t)
 	handler := NewAgentHandler(st)
 
 	// Test excluding expired sessions
 	req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions?expired=false", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -283,11 +303,11 @@ func TestAgentHandler_ListSessionsWithExpiredFilter(t *testing.T) {
 }
 
 func TestAgentHandler_GetSession(t *testing.T) {
-	st := setupTestStoreForUS3()
+	st := testsupport.StoreWithSessionStates(t)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions/task-001", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -319,11 +339,11 @@ func TestAgentHandler_GetSession(t *testing.T) {
 }
 
 func TestAgentHandler_GetSessionNotFound(t *testing.T) {
-	st := setupTestStoreForUS3()
+	st := testsupport.StoreWithSessionStates(t)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions/task-999", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -338,12 +358,76 @@ func TestAgentHandler_GetSessionNotFound(t *testing.T) {
 	}
 }
 
+func TestAgentHandler_GetSessionConditional(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+	handler.SetClock(clock.NewFake(time.Now().Add(5 * time.Hour)))
+
+	getSession := func(topic, ifModifiedSince string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions/"+topic, nil)
+		req = testsupport.WithUser(req)
+		if ifModifiedSince != "" {
+			req.Header.Set("If-Modified-Since", ifModifiedSince)
+		}
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", "agent-001")
+		rctx.URLParams.Add("session_topic", topic)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler.GetSession(rr, req)
+		return rr
+	}
+
+	// task-003 ended with its last status 2.5 hours ago, so it may be reused for 15 minutes
+	rr := getSession("task-003", "")
+	lastModified := rr.Header().Get("Last-Modified")
+	if rr.Code != http.StatusOK || lastModified == "" {
+		t.Fatalf("GetSession() = %v with Last-Modified %q, want %v with a date", rr.Code, lastModified, http.StatusOK)
+	}
+	if got := rr.Header().Get("Cache-Control"); got != "private, max-age=900" {
+		t.Errorf("Cache-Control = %q, want private, max-age=900", got)
+	}
+
+	rr = getSession("task-003", lastModified)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("GetSession() revalidating = %v %q, want %v without a body", rr.Code, rr.Body.String(), http.StatusNotModified)
+	}
+
+	// An annotation changes the details
+	history, err := st.GetStatusHistory("agent-001", "task-003")
+	if err != nil || len(history) == 0 {
+		t.Fatalf("GetStatusHistory() = %v, %v", history, err)
+	}
+	err = st.CreateStatusAnnotation(&models.StatusAnnotation{
+		ID:           "annotation-1",
+		StatusID:     history[len(history)-1].ID,
+		AgentID:      "agent-001",
+		SessionTopic: "task-003",
+		UserID:       testsupport.UserID,
+		Note:         "Flaky runner",
+		CreatedAt:    time.Now().Add(4 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateStatusAnnotation() error = %v", err)
+	}
+	rr = getSession("task-003", lastModified)
+	if rr.Code != http.StatusOK || rr.Header().Get("Last-Modified") == lastModified {
+		t.Errorf("GetSession() after an annotation = %v with Last-Modified %q, want %v with a later date", rr.Code, rr.Header().Get("Last-Modified"), http.StatusOK)
+	}
+
+	// A running session is revalidated every time and never answered 304
+	rr = getSession("task-001", time.Now().Add(10*time.Hour).UTC().Format(http.TimeFormat))
+	if rr.Code != http.StatusOK || rr.Header().Get("Last-Modified") != "" || rr.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("GetSession() of a running session = %v with headers %v, want %v with private, no-cache", rr.Code, rr.Header(), http.StatusOK)
+	}
+}
+
 func TestAgentHandler_GetAgentStatus(t *testing.T) {
-	st := setupTestStoreForUS3()
+	st := testsupport.StoreWithSessionStates(t)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents/agent-001/status", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -367,11 +451,11 @@ func TestAgentHandler_GetAgentStatus(t *testing.T) {
 }
 
 func TestAgentHandler_GetAgentDetailResponseTime(t *testing.T) {
-	st := setupTestStoreForUS3()
+	st := testsupport.StoreWithSessionStates(t)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents/agent-001", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -388,11 +472,11 @@ func TestAgentHandler_GetAgentDetailResponseTime(t *testing.T) {
 }
 
 func TestAgentHandler_StatusHistoryOrdering(t *testing.T) {
-	st := setupTestStoreForUS3()
+	st := testsupport.StoreWithSessionStates(t)
 	handler := NewAgentHandler(st)
 
 	req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions/task-002", nil)
-	req = addTestUserToContextUS3(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -422,3 +506,488 @@ func TestAgentHandler_StatusHistoryOrdering(t *testing.T) {
 		}
 	}
 }
+
+func TestAgentHandler_StatusHistoryPagination(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	get := func(url string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+		req := testsupport.WithUser(httptest.NewRequest("GET", url, nil))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", "agent-001")
+		rctx.URLParams.Add("session_topic", "task-002")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler.GetSession(rr, req)
+
+		var response map[string]json.RawMessage
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response
+	}
+
+	rr, response := get("/api/agents/agent-001/sessions/task-002?history_limit=1")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetSession() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var history []models.AgentStatus
+	var cursor string
+	json.Unmarshal(response["status_history"], &history)
+	json.Unmarshal(response["status_history_next_cursor"], &cursor)
+	if len(history) != 1 || history[0].Status != "success" || string(response["status_history_total"]) != "2" || cursor == "" {
+		t.Fatalf("GetSession() first history page = %+v, total %s, cursor %q, want the success status of 2 and a cursor", history, response["status_history_total"], cursor)
+	}
+
+	_, response = get("/api/agents/agent-001/sessions/task-002?history_limit=1&history_cursor=" + cursor)
+	json.Unmarshal(response["status_history"], &history)
+	if len(history) != 1 || history[0].Status != "running" || string(response["status_history_next_cursor"]) != "null" {
+		t.Errorf("GetSession() last history page = %+v, cursor %s, want the running status and no next page", history, response["status_history_next_cursor"])
+	}
+
+	for _, query := range []string{"history_limit=0", "history_limit=1001", "history_cursor=bogus"} {
+		if rr, _ := get("/api/agents/agent-001/sessions/task-002?" + query); rr.Code != http.StatusBadRequest {
+			t.Errorf("GetSession() with %s status = %v, want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestAgentHandler_ListSessionsPagination(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	list := func(url string) []string {
+		req := testsupport.WithUser(httptest.NewRequest("GET", url, nil))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", "agent-001")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler.ListSessions(rr, req)
+
+		var response struct {
+			Items []models.Session `json:"items"`
+			Total int              `json:"total"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		topics := []string{fmt.Sprint(response.Total)}
+		for _, session := range response.Items {
+			topics = append(topics, session.SessionTopic)
+		}
+		return topics
+	}
+
+	if got := strings.Join(list("/api/agents/agent-001/sessions?limit=2&cursor="+encodeCursor(1)), " "); got != "3 task-002 task-001" {
+		t.Errorf("ListSessions() page = %q, want total 3 with task-002 and task-001", got)
+	}
+
+	now := time.Now()
+	st.SaveWatchItem(&models.WatchItem{UserID: testsupport.UserID, AgentID: "agent-001", SessionTopic: "task-001", CreatedAt: now, UpdatedAt: now})
+	if got := strings.Join(list("/api/agents/agent-001/sessions?limit=2"), " "); got != "3 task-001 task-003" {
+		t.Errorf("ListSessions() page with a watched session = %q, want total 3 with task-001 first", got)
+	}
+}
+
+func TestAgentHandler_ListSessionsWithGroupFilter(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	now := time.Now()
+	for _, s := range []struct{ topic, group, category string }{
+		{"deploy/prod/1", "deploy", "prod"},
+		{"deploy/staging/1", "deploy", "staging"},
+	} {
+		st.CreateOrUpdateSession(&models.Session{
+			AgentID:      "agent-001",
+			SessionTopic: s.topic,
+			Created:      now,
+			LastUpdated:  now,
+			Group:        s.group,
+			Category:     s.category,
+		})
+	}
+
+	tests := []struct {
+		query     string
+		wantCount int
+	}{
+		{"?group=deploy", 2},
+		{"?group=deploy&category=prod", 1},
+		{"?group=missing", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions"+tt.query, nil)
+			req = testsupport.WithUser(req)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("agent_id", "agent-001")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			handler.ListSessions(rr, req)
+
+			var response struct {
+				Sessions []models.Session `json:"sessions"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("ListSessions() invalid JSON: %v", err)
+			}
+			if len(response.Sessions) != tt.wantCount {
+				t.Errorf("ListSessions(%s) count = %d, want %d", tt.query, len(response.Sessions), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestAgentHandler_ListSessionsWithQueryFilters(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	now := time.Now().UTC()
+	for i, topic := range []string{"deploy/prod/1", "deploy/staging/1"} {
+		updated := now.Add(time.Duration(i-2) * time.Hour)
+		st.CreateOrUpdateSession(&models.Session{AgentID: "agent-001", SessionTopic: topic, Created: updated, LastUpdated: updated})
+	}
+
+	tests := []struct {
+		query     string
+		wantCode  int
+		wantCount int
+	}{
+		{"?search=STAGING", http.StatusOK, 1},
+		{"?search=deploy&until=" + now.Add(-90*time.Minute).Format(time.RFC3339), http.StatusOK, 1},
+		{"?search=deploy&since=" + now.Add(-3*time.Hour).Format(time.RFC3339), http.StatusOK, 2},
+		{"?since=yesterday", http.StatusBadRequest, 0},
+		{"?status=done", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions"+tt.query, nil)
+			req = testsupport.WithUser(req)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("agent_id", "agent-001")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			handler.ListSessions(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("ListSessions(%s) status = %d, want %d; body = %s", tt.query, rr.Code, tt.wantCode, rr.Body.String())
+			}
+			var response struct {
+				Items []models.Session `json:"items"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("ListSessions() invalid JSON: %v", err)
+			}
+			if len(response.Items) != tt.wantCount {
+				t.Errorf("ListSessions(%s) count = %d, want %d", tt.query, len(response.Items), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestAgentHandler_ListTasks(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	now := time.Now()
+	for i, result := range []string{"success", "failed", "success"} {
+		topic := fmt.Sprintf("nightly backup run %d", i+1)
+		created := now.Add(time.Duration(i+3) * time.Hour)
+		st.CreateOrUpdateSession(&models.Session{
+			AgentID:      "agent-001",
+			SessionTopic: topic,
+			Created:      created,
+			LastUpdated:  created,
+		})
+		st.AddStatus(&models.AgentStatus{
+			AgentID:      "agent-001",
+			SessionTopic: topic,
+			Status:       result,
+			Timestamp:    created,
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/api/agents/agent-001/tasks", nil)
+	req = testsupport.WithUser(req)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("agent_id", "agent-001")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+
+	handler.ListTasks(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ListTasks() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	var response struct {
+		Tasks []internal.Task `json:"tasks"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("ListTasks() invalid JSON: %v", err)
+	}
+	// Fixture sessions task-001..003 form a second recurring task
+	if len(response.Tasks) != 2 {
+		t.Fatalf("ListTasks() returned %d tasks, want 2", len(response.Tasks))
+	}
+
+	// Backup runs are the most recent, so they are listed first
+	task := response.Tasks[0]
+	if task.TaskKey != "nightly backup run {n}" {
+		t.Errorf("ListTasks() task_key = %q, want %q", task.TaskKey, "nightly backup run {n}")
+	}
+	if task.RunCount != 3 || task.SuccessCount != 2 {
+		t.Errorf("ListTasks() run_count = %d, success_count = %d, want 3, 2", task.RunCount, task.SuccessCount)
+	}
+	if task.LastResult != "success" {
+		t.Errorf("ListTasks() last_result = %q, want success", task.LastResult)
+	}
+}
+
+func TestAgentHandler_ListTasksInvalidParams(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	req := httptest.NewRequest("GET", "/api/agents/agent-001/tasks?min_runs=0", nil)
+	req = testsupport.WithUser(req)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("agent_id", "agent-001")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+
+	handler.ListTasks(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("ListTasks(min_runs=0) status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAgentHandler_FieldSelection(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	withAgentID := func(r *http.Request) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", "agent-001")
+		rctx.URLParams.Add("session_topic", "task-001")
+		r = testsupport.WithUser(r)
+		return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		url        string
+		wantStatus int
+		wantKeys   []string
+	}{
+		{"agent", handler.GetAgent, "/api/agents/agent-001?fields=agent_id,session_count", http.StatusOK, []string{"agent_id", "session_count"}},
+		{"agent list", handler.ListAgents, "/api/agents?fields=name", http.StatusOK, []string{"items", "total", "next_cursor", "agents"}},
+		{"sessions", handler.ListSessions, "/api/agents/agent-001/sessions?fields=session_topic", http.StatusOK, []string{"items", "total", "next_cursor", "sessions"}},
+		{"session without history", handler.GetSession, "/api/agents/agent-001/sessions/task-001?fields=session_topic,expired", http.StatusOK, []string{"session"}},
+		{"unknown field", handler.GetAgent, "/api/agents/agent-001?fields=password_hash", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handler(rr, withAgentID(httptest.NewRequest("GET", tt.url, nil)))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantKeys == nil {
+				return
+			}
+
+			var response map[string]json.RawMessage
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if len(response) != len(tt.wantKeys) {
+				t.Errorf("response keys = %v, want %v", len(response), tt.wantKeys)
+			}
+			for _, key := range tt.wantKeys {
+				if _, ok := response[key]; !ok {
+					t.Errorf("response missing %q: %s", key, rr.Body.String())
+				}
+			}
+		})
+	}
+
+	// Nested objects are projected too
+	rr := httptest.NewRecorder()
+	handler.ListSessions(rr, withAgentID(httptest.NewRequest("GET", "/api/agents/agent-001/sessions?fields=session_topic", nil)))
+	var response struct {
+		Sessions []map[string]interface{} `json:"sessions"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &response)
+	if len(response.Sessions) == 0 {
+		t.Fatal("ListSessions() returned no sessions")
+	}
+	for _, session := range response.Sessions {
+		if len(session) != 1 || session["session_topic"] == nil {
+			t.Errorf("ListSessions() session = %v, want only session_topic", session)
+		}
+	}
+}
+
+func TestAgentHandler_ListSessionRuns(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	webhook := NewWebhookHandlerWithNotifier(st, nil)
+
+	// Two runs of the same topic: the second starts when running is reported after success
+	start := time.Now().Add(-time.Hour)
+	for _, status := range []string{"running", "success", "running"} {
+		testsupport.SendStatus(t, webhook, "agent-001", "nightly", status, start, "", "")
+	}
+
+	req := httptest.NewRequest("GET", "/api/agents/agent-001/sessions/nightly/runs", nil)
+	req = testsupport.WithUser(req)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("agent_id", "agent-001")
+	rctx.URLParams.Add("session_topic", "nightly")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+
+	NewAgentHandler(st).ListSessionRuns(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ListSessionRuns() status = %v, want %v, body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	var response struct {
+		CurrentRevision int                    `json:"current_revision"`
+		Runs            []internal.SessionRun  `json:"runs"`
+		Comparison      internal.RunComparison `json:"comparison"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("ListSessionRuns() invalid JSON: %v", err)
+	}
+	if response.CurrentRevision != 2 {
+		t.Errorf("ListSessionRuns() current_revision = %d, want 2", response.CurrentRevision)
+	}
+	if len(response.Runs) != 2 {
+		t.Fatalf("ListSessionRuns() returned %d runs, want 2", len(response.Runs))
+	}
+	if response.Runs[0].Revision != 2 || response.Runs[0].Finished != nil || response.Runs[0].Result != "running" {
+		t.Errorf("ListSessionRuns() newest run = %+v, want revision 2 in progress", response.Runs[0])
+	}
+	if response.Runs[1].Revision != 1 || response.Runs[1].Result != "success" || response.Runs[1].StatusCount != 2 {
+		t.Errorf("ListSessionRuns() oldest run = %+v, want revision 1 succeeded after 2 statuses", response.Runs[1])
+	}
+	if response.Comparison.FinishedRuns != 1 || response.Comparison.FastestRevision != 1 {
+		t.Errorf("ListSessionRuns() comparison = %+v, want 1 finished run", response.Comparison)
+	}
+}
+
+func TestAgentHandler_GetSessionTimeline(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	start := time.Now().Add(-time.Hour).UTC()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-001", UserID: testsupport.UserID, Registered: start, LastSeen: start})
+	st.CreateOrUpdateSession(&models.Session{AgentID: "agent-001", SessionTopic: "deploy", Created: start, LastUpdated: start, Revision: 1})
+	for _, report := range []struct {
+		status string
+		after  time.Duration
+	}{{"pending", 0}, {"running", 2 * time.Minute}, {"failed", 12 * time.Minute}} {
+		st.AddStatus(&models.AgentStatus{
+			AgentID:      "agent-001",
+			SessionTopic: "deploy",
+			Status:       report.status,
+			Timestamp:    start.Add(report.after),
+			Revision:     1,
+		})
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := testsupport.WithUser(httptest.NewRequest("GET", "/api/agents/agent-001/sessions/deploy/timeline"+query, nil))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", "agent-001")
+		rctx.URLParams.Add("session_topic", "deploy")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		NewAgentHandler(st).GetSessionTimeline(rr, req)
+		return rr
+	}
+
+	rr := get("")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GetSessionTimeline() status = %v, want %v, body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var response struct {
+		Timeline internal.SessionTimeline `json:"timeline"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("GetSessionTimeline() invalid JSON: %v", err)
+	}
+	timeline := response.Timeline
+	if len(timeline.Phases) != 3 || timeline.Phases[0].Phase != internal.PhaseQueued || timeline.Phases[2].Status != "failed" {
+		t.Fatalf("GetSessionTimeline() phases = %+v, want queued, running and failed", timeline.Phases)
+	}
+	if timeline.QueuedSeconds != 120 || timeline.RunningSeconds != 600 || len(timeline.Gaps) != 1 {
+		t.Errorf("GetSessionTimeline() = %v queued, %v running, %d gaps, want 120, 600 and the 10m silence", timeline.QueuedSeconds, timeline.RunningSeconds, len(timeline.Gaps))
+	}
+
+	if rr := get("?revision=5"); rr.Code != http.StatusNotFound {
+		t.Errorf("GetSessionTimeline() unknown revision status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	if rr := get("?min_gap_seconds=0"); rr.Code != http.StatusBadRequest {
+		t.Errorf("GetSessionTimeline() min_gap_seconds=0 status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAgentHandler_ListRollups(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	today := models.UsageDay(time.Now())
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-001", UserID: testsupport.UserID, Registered: today, LastSeen: today})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-002", UserID: "someone-else", Registered: today, LastSeen: today})
+	for _, topic := range []string{"build", "deploy"} {
+		st.CreateOrUpdateSession(&models.Session{AgentID: "agent-001", SessionTopic: topic, Created: today, LastUpdated: today})
+		for _, daysAgo := range []int{1, 40} {
+			day := today.AddDate(0, 0, -daysAgo)
+			st.SaveStatusRollup(&models.StatusRollup{AgentID: "agent-001", SessionTopic: topic, Day: day, Success: 1, Runs: 1, FirstAt: day, LastAt: day})
+		}
+	}
+
+	get := func(agentID, query string) *httptest.ResponseRecorder {
+		req := testsupport.WithUser(httptest.NewRequest("GET", "/api/agents/"+agentID+"/rollups"+query, nil))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("agent_id", agentID)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		NewAgentHandler(st).ListRollups(rr, req)
+		return rr
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{"", 2},
+		{"?session_topic=deploy", 1},
+		{"?from=" + today.AddDate(0, 0, -60).Format(time.DateOnly), 4},
+	} {
+		rr := get("agent-001", tt.query)
+		var response struct {
+			Items []*models.StatusRollup `json:"items"`
+			Total int                    `json:"total"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || rr.Code != http.StatusOK {
+			t.Fatalf("ListRollups(%q) = %v %s", tt.query, rr.Code, rr.Body.String())
+		}
+		if response.Total != tt.want || len(response.Items) != tt.want {
+			t.Errorf("ListRollups(%q) = %d rollups, want %d", tt.query, response.Total, tt.want)
+		}
+	}
+
+	if rr := get("agent-001", "?from=yesterday"); rr.Code != http.StatusBadRequest {
+		t.Errorf("ListRollups() with a bad date status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := get("agent-002", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("ListRollups() of another user's agent status = %v, want %v", rr.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// AlertRuleHandler handles alert rule management endpoints
+type AlertRuleHandler struct {
+	store store.Store
+	clock clock.Clock
+
+	listFormat
+}
+
+// NewAlertRuleHandler creates a new alert rule handler
+func NewAlertRuleHandler(st store.Store) *AlertRuleHandler {
+	return &AlertRuleHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps alert rules
+func (h *AlertRuleHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// AlertRuleRequest represents a request to create or replace an alert rule
+type AlertRuleRequest struct {
+	Name            string `json:"name"`
+	Kind            string `json:"kind"`
+	AgentID         string `json:"agent_id,omitempty"`
+	TopicPattern    string `json:"topic_pattern,omitempty"`
+	Status          string `json:"status,omitempty"`
+	DurationMinutes int    `json:"duration_minutes,omitempty"`
+}
+
+// List handles listing alert rules of the current user
+func (h *AlertRuleHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rules, err := h.store.ListAlertRulesByUser(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list alert rules")
+		return
+	}
+
+	respondList(w, r, page, h.legacyKey("alert_rules"), rules, nil)
+}
+
+// Create handles alert rule creation
+func (h *AlertRuleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	var req AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	rule := &models.AlertRule{
+		ID:              uuid.New().String(),
+		UserID:          caller.UserID,
+		Name:            req.Name,
+		Kind:            req.Kind,
+		AgentID:         req.AgentID,
+		TopicPattern:    req.TopicPattern,
+		Status:          req.Status,
+		DurationMinutes: req.DurationMinutes,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	if err := rule.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.CreateAlertRule(rule); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to create alert rule")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, rule)
+}
+
+// Get handles retrieving a single alert rule
+func (h *AlertRuleHandler) Get(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.loadOwnedAlertRule(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("ETag", etagFor(rule.UpdatedAt))
+	respondJSON(w, http.StatusOK, rule)
+}
+
+// Update handles replacing an alert rule
+func (h *AlertRuleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.loadOwnedAlertRule(w, r)
+	if !ok {
+		return
+	}
+
+	if !ifMatchSatisfied(r, etagFor(rule.UpdatedAt)) {
+		respondError(w, http.StatusConflict, "alert rule was modified since it was read")
+		return
+	}
+
+	var req AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	updated := *rule
+	updated.Name = req.Name
+	updated.Kind = req.Kind
+	updated.AgentID = req.AgentID
+	updated.TopicPattern = req.TopicPattern
+	updated.Status = req.Status
+	updated.DurationMinutes = req.DurationMinutes
+	updated.UpdatedAt = h.clock.Now().UTC()
+
+	if err := updated.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.UpdateAlertRule(&updated); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to update alert rule")
+		return
+	}
+
+	w.Header().Set("ETag", etagFor(updated.UpdatedAt))
+	respondJSON(w, http.StatusOK, &updated)
+}
+
+// Delete handles deleting an alert rule
+func (h *AlertRuleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.loadOwnedAlertRule(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteAlertRule(rule.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to delete alert rule")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Alert rule deleted successfully",
+	})
+}
+
+// loadOwnedAlertRule loads the alert rule named in the URL, writing an error response unless it belongs to the
+// current user
+func (h *AlertRuleHandler) loadOwnedAlertRule(w http.ResponseWriter, r *http.Request) (*models.AlertRule, bool) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return nil, false
+	}
+
+	rule, err := h.store.GetAlertRule(chi.URLParam(r, "id"))
+	if err != nil {
+		respondStoreError(w, err, "alert rule not found", "failed to get alert rule")
+		return nil, false
+	}
+
+	// Report other users' alert rules as missing, matching API key ownership checks
+	if rule.UserID != caller.UserID {
+		respondError(w, http.StatusNotFound, "alert rule not found")
+		return nil, false
+	}
+
+	return rule, true
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/alerting"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestAlertRuleHandler_Create(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAlertRuleHandler(st)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"status rule", `{"name":"Any failure","kind":"status","status":"failed"}`, http.StatusCreated},
+		{"running longer rule", `{"name":"Slow","kind":"running_longer","topic_pattern":"^build","duration_minutes":30}`, http.StatusCreated},
+		{"agent offline rule", `{"name":"Silent","kind":"agent_offline","agent_id":"agent-1","duration_minutes":10}`, http.StatusCreated},
+		{"unknown kind", `{"name":"Sometimes","kind":"sometimes"}`, http.StatusBadRequest},
+		{"missing duration", `{"name":"Slow","kind":"running_longer"}`, http.StatusBadRequest},
+		{"server status", `{"name":"Expired","kind":"status","status":"expired"}`, http.StatusBadRequest},
+		{"invalid json", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/alert-rules", bytes.NewBufferString(tt.body))
+			req = testsupport.WithUser(req)
+			rr := httptest.NewRecorder()
+
+			handler.Create(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Create() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+
+	rules, _ := st.ListAlertRulesByUser(testsupport.UserID)
+	if len(rules) != 3 {
+		t.Errorf("Create() stored %d alert rules, want 3", len(rules))
+	}
+}
+
+func TestAlertRuleHandler_OtherUsersRuleIsNotFound(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAlertRuleHandler(st)
+
+	now := time.Now()
+	st.CreateAlertRule(&models.AlertRule{
+		ID:        "rule-other",
+		UserID:    "other-user",
+		Name:      "Other",
+		Kind:      models.AlertRuleStatus,
+		Status:    "failed",
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+
+	req := withSLAID(testsupport.WithUser(httptest.NewRequest("DELETE", "/api/alert-rules/rule-other", nil)), "rule-other")
+	rr := httptest.NewRecorder()
+
+	handler.Delete(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Delete() status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	if _, err := st.GetAlertRule("rule-other"); err != nil {
+		t.Errorf("Delete() removed another user's alert rule")
+	}
+}
+
+func TestWebhookHandler_StatusAlertRules(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL))
+	now := time.Now()
+	if err := st.CreateAlertRule(&models.AlertRule{ID: "rule-1", UserID: testsupport.UserID, Name: "Pending anywhere",
+		Kind: models.AlertRuleStatus, Status: "pending", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateAlertRule() error = %v", err)
+	}
+
+	// The status handler itself notifies nothing, so every notification comes from the rule
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetAlertEngine(alerting.NewEngine(st, notifier.NewNotificationManager(5*time.Second)))
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "pending", now, "waiting for approval", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "pending", now.Add(time.Second), "still waiting", "")
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "Alert: Pending anywhere") || !strings.Contains(bodies[0], "waiting for approval") {
+		t.Errorf("notifications = %q, want the first pending status only", bodies)
+	}
+}
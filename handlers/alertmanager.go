@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/middleware"
+)
+
+// ServeAlertmanager handles POST /webhook/alertmanager requests
+// Alerts are reported as sessions of a synthetic agent, named by ?agent_id= or after the receiver.
+func (h *WebhookHandler) ServeAlertmanager(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	// Limit request body size (1MB)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var payload internal.AlertmanagerPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+		return
+	}
+
+	agentID := r.URL.Query().Get("agent_id")
+	if agentID == "" {
+		agentID = internal.AlertmanagerAgentID(payload.Receiver)
+	}
+
+	reports, err := payload.StatusReports(agentID, h.now())
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	h.reportAll(w, caller, reports, "alerts")
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestWebhookHandler_ServeAlertmanager(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := testsupport.WithUser(httptest.NewRequest("POST", path, strings.NewReader(body)))
+		rr := httptest.NewRecorder()
+		handler.ServeAlertmanager(rr, req)
+		return rr
+	}
+
+	firing := `{"version":"4","status":"firing","receiver":"ops","alerts":[
+		{"status":"firing","labels":{"alertname":"HighLatency"},"annotations":{"summary":"p99 above 1s"},"startsAt":"2024-01-15T12:00:00Z","fingerprint":"abc123"}]}`
+	if rr := post("/webhook/alertmanager", firing); rr.Code != http.StatusOK {
+		t.Fatalf("ServeAlertmanager() firing status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+
+	agent, err := st.GetAgent("alertmanager-ops")
+	if err != nil || agent.UserID != testsupport.UserID || agent.Source != "alertmanager" {
+		t.Fatalf("GetAgent(alertmanager-ops) = %+v, %v, want agent of the caller", agent, err)
+	}
+	if latest, err := st.GetLatestStatus("alertmanager-ops", "HighLatency/abc123"); err != nil || latest.Status != "running" {
+		t.Errorf("GetLatestStatus() after firing = %+v, %v, want running", latest, err)
+	}
+
+	resolved := strings.Replace(strings.Replace(firing, `"status":"firing"`, `"status":"resolved"`, -1), `"startsAt"`, `"endsAt"`, 1)
+	if rr := post("/webhook/alertmanager", resolved); rr.Code != http.StatusOK {
+		t.Fatalf("ServeAlertmanager() resolved status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+	if latest, err := st.GetLatestStatus("alertmanager-ops", "HighLatency/abc123"); err != nil || latest.Status != "success" {
+		t.Errorf("GetLatestStatus() after resolving = %+v, %v, want success", latest, err)
+	}
+
+	if rr := post("/webhook/alertmanager?agent_id=infra", firing); rr.Code != http.StatusOK {
+		t.Fatalf("ServeAlertmanager() with agent_id status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+	if _, err := st.GetSession("infra", "HighLatency/abc123"); err != nil {
+		t.Errorf("GetSession(infra) error = %v, want session of the named agent", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"invalid JSON", `{`},
+		{"unknown alert status", `{"receiver":"ops","alerts":[{"status":"pending","labels":{"alertname":"X"}}]}`},
+		{"summary too long", `{"receiver":"ops","alerts":[{"status":"firing","labels":{"alertname":"X"},"annotations":{"summary":"` + strings.Repeat("a", 2000) + `"}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := post("/webhook/alertmanager", tt.body); rr.Code != http.StatusBadRequest {
+				t.Errorf("ServeAlertmanager() status = %v, want %v", rr.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// CreateAnnotationRequest represents a post-mortem annotation on a status entry
+type CreateAnnotationRequest struct {
+	Investigator string   `json:"investigator"` // Defaults to the caller's email
+	RootCause    string   `json:"root_cause"`
+	Note         string   `json:"note"`
+	Links        []string `json:"links"`
+}
+
+// StatusWithAnnotations is a status history entry with the annotations users attached to it
+type StatusWithAnnotations struct {
+	*models.AgentStatus
+	Annotations []*models.StatusAnnotation `json:"annotations,omitempty"`
+}
+
+// CreateAnnotation handles POST /api/agents/{agent_id}/sessions/{session_topic}/statuses/{status_id}/annotations
+func (h *AgentHandler) CreateAnnotation(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	agentID := chi.URLParam(r, "agent_id")
+	sessionTopic := chi.URLParam(r, "session_topic")
+	statusID, err := strconv.ParseInt(chi.URLParam(r, "status_id"), 10, 64)
+	if err != nil || statusID <= 0 {
+		h.respondError(w, http.StatusNotFound, "not_found", "Status not found")
+		return
+	}
+
+	agent, err := h.store.GetAgent(agentID)
+	if err != nil {
+		h.respondError(w, http.StatusNotFound, "not_found", "Agent not found")
+		return
+	}
+	if !canManageAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Access denied")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req CreateAnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid request body")
+		return
+	}
+
+	investigator := strings.TrimSpace(req.Investigator)
+	if investigator == "" {
+		investigator = caller.Email
+	}
+	annotation := &models.StatusAnnotation{
+		ID:           uuid.New().String(),
+		StatusID:     statusID,
+		AgentID:      agentID,
+		SessionTopic: sessionTopic,
+		UserID:       caller.UserID,
+		Investigator: investigator,
+		RootCause:    strings.TrimSpace(req.RootCause),
+		Note:         strings.TrimSpace(req.Note),
+		Links:        req.Links,
+		CreatedAt:    h.clock.Now().UTC(),
+	}
+	if err := annotation.Validate(); err != nil {
+		respondCodedInvalid(w, err)
+		return
+	}
+
+	if err := h.store.CreateStatusAnnotation(annotation); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusNotFound, "not_found", "Status not found")
+			return
+		}
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to create annotation")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(annotation)
+}
+
+// annotateHistory attaches a session's annotations to the statuses they were made on
+func annotateHistory(history []*models.AgentStatus, annotations []*models.StatusAnnotation) []*StatusWithAnnotations {
+	byStatus := make(map[int64][]*models.StatusAnnotation, len(annotations))
+	for _, annotation := range annotations {
+		byStatus[annotation.StatusID] = append(byStatus[annotation.StatusID], annotation)
+	}
+
+	annotated := make([]*StatusWithAnnotations, 0, len(history))
+	for _, status := range history {
+		annotated = append(annotated, &StatusWithAnnotations{
+			AgentStatus: status,
+			Annotations: byStatus[status.ID],
+		})
+	}
+	return annotated
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// withSessionParams sets the agent, session and status route parameters
+func withSessionParams(r *http.Request, agentID, sessionTopic, statusID string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("agent_id", agentID)
+	rctx.URLParams.Add("session_topic", sessionTopic)
+	rctx.URLParams.Add("status_id", statusID)
+	return r.WithContext(context.WithValue(testsupport.WithUser(r).Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestAgentHandler_CreateAnnotation(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	status := &models.AgentStatus{AgentID: "agent-001", SessionTopic: "task-001", Status: "failed", Timestamp: time.Now().Add(time.Minute)}
+	if err := st.AddStatus(status); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+	statusID := strconv.FormatInt(status.ID, 10)
+
+	annotate := func(agentID, sessionTopic, statusID, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/agents/"+agentID+"/sessions/"+sessionTopic+"/statuses/"+statusID+"/annotations", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.CreateAnnotation(rr, withSessionParams(req, agentID, sessionTopic, statusID))
+		return rr
+	}
+
+	rr := annotate("agent-001", "task-001", statusID, `{"root_cause":"expired token","links":["https://example.com/incident/1"]}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("CreateAnnotation() status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+	var created models.StatusAnnotation
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if created.Investigator != testsupport.UserEmail || created.StatusID != status.ID {
+		t.Errorf("CreateAnnotation() = %+v, want the caller as investigator of status %d", created, status.ID)
+	}
+
+	tests := []struct {
+		name       string
+		agentID    string
+		topic      string
+		statusID   string
+		body       string
+		wantStatus int
+	}{
+		{"empty annotation", "agent-001", "task-001", statusID, `{"investigator":"bob"}`, http.StatusBadRequest},
+		{"invalid link", "agent-001", "task-001", statusID, `{"links":["javascript:alert(1)"]}`, http.StatusBadRequest},
+		{"status of another session", "agent-001", "task-002", statusID, `{"note":"x"}`, http.StatusNotFound},
+		{"unknown status", "agent-001", "task-001", "abc", `{"note":"x"}`, http.StatusNotFound},
+		{"unknown agent", "agent-999", "task-001", statusID, `{"note":"x"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := annotate(tt.agentID, tt.topic, tt.statusID, tt.body); rr.Code != tt.wantStatus {
+				t.Errorf("CreateAnnotation() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	// The history shows the annotation on its status
+	req := withSessionParams(httptest.NewRequest("GET", "/api/agents/agent-001/sessions/task-001", nil), "agent-001", "task-001", "")
+	rr = httptest.NewRecorder()
+	handler.GetSession(rr, req)
+
+	var response struct {
+		StatusHistory []*StatusWithAnnotations `json:"status_history"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("GetSession() invalid JSON: %v", err)
+	}
+	found := false
+	for _, entry := range response.StatusHistory {
+		want := 0
+		if entry.ID == status.ID {
+			found, want = true, 1
+		}
+		if len(entry.Annotations) != want {
+			t.Errorf("GetSession() status %d annotations = %d, want %d", entry.ID, len(entry.Annotations), want)
+		}
+	}
+	if !found {
+		t.Errorf("GetSession() history = %d entries, want the annotated status", len(response.StatusHistory))
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
 	"github.com/kubeagents/kubeagents/middleware"
 	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/store"
@@ -16,47 +17,69 @@ import (
 
 // APIKeyHandler handles API key management endpoints
 type APIKeyHandler struct {
-	store store.Store
+	store    store.Store
+	clock    clock.Clock
+	onRevoke func(keyID string)
+
+	listFormat
 }
 
 // NewAPIKeyHandler creates a new API key handler
 func NewAPIKeyHandler(st store.Store) *APIKeyHandler {
 	return &APIKeyHandler{
 		store: st,
+		clock: clock.Real,
 	}
 }
 
+// SetClock replaces the clock that timestamps API keys and decides their expiry
+func (h *APIKeyHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// SetOnRevoke registers a callback run after a key is revoked, e.g. to evict it from validation caches
+func (h *APIKeyHandler) SetOnRevoke(fn func(keyID string)) {
+	h.onRevoke = fn
+}
+
 // CreateAPIKeyRequest represents a request to create an API key
 type CreateAPIKeyRequest struct {
-	Name      string `json:"name"`
-	ExpiresIn *int   `json:"expires_in,omitempty"` // days, nil means never expires
+	Name         string `json:"name"`
+	ExpiresIn    *int   `json:"expires_in,omitempty"`    // days, nil means never expires
+	BurstCredits int    `json:"burst_credits,omitempty"` // Extra webhook requests the key may save up while quiet
+	FieldCasing  string `json:"field_casing,omitempty"`  // snake or camel field names in responses to the key
 }
 
 // CreateAPIKeyResponse represents the response when creating an API key
 // The raw key is only returned once at creation time
 type CreateAPIKeyResponse struct {
-	ID        string     `json:"id"`
-	Name      string     `json:"name"`
-	Key       string     `json:"key"`        // Raw key, only shown once
-	KeyPrefix string     `json:"key_prefix"` // First 8 chars for identification
-	ExpiresAt *time.Time `json:"expires_at"`
-	CreatedAt time.Time  `json:"created_at"`
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Key          string     `json:"key"`        // Raw key, only shown once
+	KeyPrefix    string     `json:"key_prefix"` // First 8 chars for identification
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	BurstCredits int        `json:"burst_credits,omitempty"`
+	FieldCasing  string     `json:"field_casing,omitempty"`
 }
 
 // APIKeyInfo represents API key information (without the raw key)
 type APIKeyInfo struct {
-	ID         string     `json:"id"`
-	Name       string     `json:"name"`
-	KeyPrefix  string     `json:"key_prefix"`
-	ExpiresAt  *time.Time `json:"expires_at"`
-	LastUsedAt *time.Time `json:"last_used_at"`
-	CreatedAt  time.Time  `json:"created_at"`
-	Revoked    bool       `json:"revoked"`
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	KeyPrefix    string     `json:"key_prefix"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Revoked      bool       `json:"revoked"`
+	AgentID      string     `json:"agent_id,omitempty"` // Set on agent tokens issued by enrollment
+	BurstCredits int        `json:"burst_credits,omitempty"`
+	FieldCasing  string     `json:"field_casing,omitempty"`
 }
 
 // Create handles API key creation
 func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "not authenticated")
 		return
@@ -78,38 +101,15 @@ func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate random API key (32 bytes = 256 bits)
-	rawKey, err := generateAPIKey()
+	apiKey, rawKey, err := newAPIKey(caller.UserID, req, h.clock.Now())
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to generate API key")
 		return
 	}
 
-	// Hash the key for storage using SHA256 (allows fast lookup)
-	keyHash := middleware.HashAPIKey(rawKey)
-
-	// Calculate expiration if provided
-	var expiresAt *time.Time
-	if req.ExpiresIn != nil && *req.ExpiresIn > 0 {
-		exp := time.Now().Add(time.Duration(*req.ExpiresIn) * 24 * time.Hour)
-		expiresAt = &exp
-	}
-
-	now := time.Now()
-	apiKey := &models.APIKey{
-		ID:        uuid.New().String(),
-		UserID:    claims.UserID,
-		Name:      req.Name,
-		KeyHash:   keyHash,
-		KeyPrefix: rawKey[:8],
-		ExpiresAt: expiresAt,
-		CreatedAt: now,
-		Revoked:   false,
-	}
-
 	// Validate and save
 	if err := apiKey.Validate(); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondInvalid(w, err)
 		return
 	}
 
@@ -119,25 +119,69 @@ func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return response with raw key (only shown once)
-	respondJSON(w, http.StatusCreated, CreateAPIKeyResponse{
-		ID:        apiKey.ID,
-		Name:      apiKey.Name,
-		Key:       rawKey,
-		KeyPrefix: apiKey.KeyPrefix,
-		ExpiresAt: apiKey.ExpiresAt,
-		CreatedAt: apiKey.CreatedAt,
-	})
+	respondJSON(w, http.StatusCreated, createdAPIKey(apiKey, rawKey))
+}
+
+// newAPIKey generates the key a request asks for on behalf of a user and returns it with the raw key
+// The caller validates and stores the key.
+func newAPIKey(userID string, req CreateAPIKeyRequest, now time.Time) (*models.APIKey, string, error) {
+	// Generate random API key (32 bytes = 256 bits)
+	rawKey, err := generateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Calculate expiration if provided
+	var expiresAt *time.Time
+	if req.ExpiresIn != nil && *req.ExpiresIn > 0 {
+		exp := now.Add(time.Duration(*req.ExpiresIn) * 24 * time.Hour)
+		expiresAt = &exp
+	}
+
+	// Hash the key for storage using SHA256 (allows fast lookup)
+	return &models.APIKey{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		Name:         req.Name,
+		KeyHash:      middleware.HashAPIKey(rawKey),
+		KeyPrefix:    rawKey[:8],
+		ExpiresAt:    expiresAt,
+		CreatedAt:    now,
+		Revoked:      false,
+		BurstCredits: req.BurstCredits,
+		FieldCasing:  req.FieldCasing,
+	}, rawKey, nil
+}
+
+// createdAPIKey describes a newly created key together with its raw key
+func createdAPIKey(apiKey *models.APIKey, rawKey string) CreateAPIKeyResponse {
+	return CreateAPIKeyResponse{
+		ID:           apiKey.ID,
+		Name:         apiKey.Name,
+		Key:          rawKey,
+		KeyPrefix:    apiKey.KeyPrefix,
+		ExpiresAt:    apiKey.ExpiresAt,
+		CreatedAt:    apiKey.CreatedAt,
+		BurstCredits: apiKey.BurstCredits,
+		FieldCasing:  apiKey.FieldCasing,
+	}
 }
 
 // List handles listing API keys for the current user
 func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "not authenticated")
 		return
 	}
 
-	keys, err := h.store.ListAPIKeysByUser(claims.UserID)
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	keys, err := h.store.ListAPIKeysByUser(caller.UserID)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to list API keys")
 		return
@@ -147,24 +191,25 @@ func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
 	result := make([]APIKeyInfo, 0, len(keys))
 	for _, key := range keys {
 		result = append(result, APIKeyInfo{
-			ID:         key.ID,
-			Name:       key.Name,
-			KeyPrefix:  key.KeyPrefix,
-			ExpiresAt:  key.ExpiresAt,
-			LastUsedAt: key.LastUsedAt,
-			CreatedAt:  key.CreatedAt,
-			Revoked:    key.Revoked,
+			ID:           key.ID,
+			Name:         key.Name,
+			KeyPrefix:    key.KeyPrefix,
+			ExpiresAt:    key.ExpiresAt,
+			LastUsedAt:   key.LastUsedAt,
+			CreatedAt:    key.CreatedAt,
+			Revoked:      key.Revoked,
+			AgentID:      key.AgentID,
+			BurstCredits: key.BurstCredits,
+			FieldCasing:  key.FieldCasing,
 		})
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"api_keys": result,
-	})
+	respondList(w, r, page, h.legacyKey("api_keys"), result, nil)
 }
 
 // Revoke handles revoking an API key
 func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "not authenticated")
 		return
@@ -179,16 +224,12 @@ func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
 	// Get the key to verify ownership
 	apiKey, err := h.store.GetAPIKeyByID(keyID)
 	if err != nil {
-		if err == store.ErrNotFound {
-			respondError(w, http.StatusNotFound, "API key not found")
-			return
-		}
-		respondError(w, http.StatusInternalServerError, "failed to get API key")
+		respondStoreError(w, err, "API key not found", "failed to get API key")
 		return
 	}
 
 	// Verify ownership
-	if apiKey.UserID != claims.UserID {
+	if apiKey.UserID != caller.UserID {
 		respondError(w, http.StatusNotFound, "API key not found")
 		return
 	}
@@ -198,6 +239,9 @@ func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusInternalServerError, "failed to revoke API key")
 		return
 	}
+	if h.onRevoke != nil {
+		h.onRevoke(keyID)
+	}
 
 	respondJSON(w, http.StatusOK, map[string]string{
 		"message": "API key revoked successfully",
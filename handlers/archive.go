@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/archive"
+	"github.com/kubeagents/kubeagents/clock"
+)
+
+// maxArchiveManifestDays bounds the days of one manifest listing, each of which reads the bucket
+const maxArchiveManifestDays = 31
+
+// ArchiveHandler lets deployment admins look up and restore status history archived to object storage
+type ArchiveHandler struct {
+	archiver *archive.Archiver
+	clock    clock.Clock
+}
+
+// NewArchiveHandler creates a new archive handler
+func NewArchiveHandler(archiver *archive.Archiver) *ArchiveHandler {
+	return &ArchiveHandler{
+		archiver: archiver,
+		clock:    clock.Real,
+	}
+}
+
+// SetClock replaces the clock that resolves default archive ranges
+func (h *ArchiveHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// ArchiveRestoreRequest selects the archived statuses of a day to restore; empty fields select every one
+type ArchiveRestoreRequest struct {
+	AgentID      string `json:"agent_id,omitempty"`
+	SessionTopic string `json:"session_topic,omitempty"`
+}
+
+// respondArchiveError maps archive errors to responses
+func respondArchiveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, archive.ErrObjectNotFound):
+		respondError(w, http.StatusNotFound, "day is not archived")
+	case errors.Is(err, archive.ErrChecksumMismatch):
+		respondError(w, http.StatusBadGateway, err.Error())
+	default:
+		log.Printf("Archive request failed: %v", err)
+		respondError(w, http.StatusBadGateway, "archive request failed")
+	}
+}
+
+// parseArchiveDay reads the {day} URL parameter
+func parseArchiveDay(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	day, err := time.Parse(time.DateOnly, chi.URLParam(r, "day"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "day must be a date in YYYY-MM-DD format")
+		return time.Time{}, false
+	}
+	return day, true
+}
+
+// ListManifests handles GET /api/admin/archive, listing the manifests of the archived days between
+// the inclusive from and to dates (YYYY-MM-DD), by default the last 30 days
+func (h *ArchiveHandler) ListManifests(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseUsageRange(r, h.clock.Now())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if to.Sub(from) >= maxArchiveManifestDays*24*time.Hour {
+		respondError(w, http.StatusBadRequest, "at most 31 days of manifests can be listed at a time")
+		return
+	}
+
+	manifests := make([]*archive.Manifest, 0)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		manifest, err := h.archiver.Manifest(r.Context(), day)
+		if errors.Is(err, archive.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			respondArchiveError(w, err)
+			return
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"manifests": manifests,
+	})
+}
+
+// ListStatuses handles GET /api/admin/archive/{day}/statuses, listing a day's archived statuses oldest first,
+// optionally only those of ?agent_id= and ?session_topic=
+func (h *ArchiveHandler) ListStatuses(w http.ResponseWriter, r *http.Request) {
+	day, ok := parseArchiveDay(w, r)
+	if !ok {
+		return
+	}
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	statuses, err := h.archiver.Statuses(r.Context(), day, r.URL.Query().Get("agent_id"), r.URL.Query().Get("session_topic"))
+	if err != nil {
+		respondArchiveError(w, err)
+		return
+	}
+	respondList(w, r, page, "", statuses, nil)
+}
+
+// Restore handles POST /api/admin/archive/{day}/restore, adding a day's archived statuses back to the store
+func (h *ArchiveHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	day, ok := parseArchiveDay(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req ArchiveRestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	result, err := h.archiver.Restore(r.Context(), day, req.AgentID, req.SessionTopic)
+	if err != nil {
+		respondArchiveError(w, err)
+		return
+	}
+	respondJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/archive"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestArchiveHandler(t *testing.T) {
+	st := store.NewMemoryStore()
+	day := models.UsageDay(time.Now()).AddDate(0, 0, -2)
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Registered: day, LastSeen: day})
+	st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "build", Created: day, LastUpdated: day})
+	st.AddStatus(&models.AgentStatus{AgentID: "agent-1", SessionTopic: "build", Status: "success", Timestamp: day.Add(time.Hour), Revision: 1})
+
+	bucket, _ := archive.OpenBucket(archive.BucketConfig{URL: "file://" + t.TempDir()})
+	archiver := archive.New(st, bucket)
+	if _, err := archiver.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	handler := NewArchiveHandler(archiver)
+	r := chi.NewRouter()
+	r.Get("/api/admin/archive", handler.ListManifests)
+	r.Get("/api/admin/archive/{day}/statuses", handler.ListStatuses)
+	r.Post("/api/admin/archive/{day}/restore", handler.Restore)
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(method, path, bytes.NewReader([]byte(body))))
+		return rr
+	}
+
+	rr := call("GET", "/api/admin/archive", "")
+	var listing struct {
+		Manifests []*archive.Manifest `json:"manifests"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &listing); err != nil || len(listing.Manifests) != 1 || listing.Manifests[0].Statuses != 1 {
+		t.Fatalf("ListManifests() = %v %s, want the archived day", rr.Code, rr.Body.String())
+	}
+	if rr := call("GET", "/api/admin/archive?from=2026-01-01&to=2026-03-01", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("ListManifests() of 60 days status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	path := "/api/admin/archive/" + day.Format(time.DateOnly)
+	rr = call("GET", path+"/statuses?session_topic=build", "")
+	var statuses struct {
+		Items []*models.AgentStatus `json:"items"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &statuses); err != nil || len(statuses.Items) != 1 || statuses.Items[0].Status != "success" {
+		t.Errorf("ListStatuses() = %v %s, want the archived status", rr.Code, rr.Body.String())
+	}
+	if rr := call("GET", "/api/admin/archive/"+day.AddDate(0, 0, -1).Format(time.DateOnly)+"/statuses", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("ListStatuses() of a day not archived status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	if rr := call("GET", "/api/admin/archive/yesterday/statuses", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("ListStatuses() with a bad day status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	rr = call("POST", path+"/restore", `{"agent_id":"agent-1"}`)
+	var result archive.RestoreResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil || rr.Code != http.StatusOK || result.Present != 1 {
+		t.Errorf("Restore() = %v %s, want the status already present", rr.Code, rr.Body.String())
+	}
+}
@@ -2,10 +2,10 @@ package handlers
 
 import (
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
@@ -14,7 +14,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
 	"github.com/kubeagents/kubeagents/email"
+	"github.com/kubeagents/kubeagents/encryption"
 	"github.com/kubeagents/kubeagents/middleware"
 	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/store"
@@ -25,6 +27,7 @@ type AuthHandler struct {
 	store        store.Store
 	jwtService   *auth.JWTService
 	emailService *email.EmailService
+	clock        clock.Clock
 }
 
 // NewAuthHandler creates a new auth handler
@@ -33,9 +36,15 @@ func NewAuthHandler(st store.Store, jwtService *auth.JWTService, emailService *e
 		store:        st,
 		jwtService:   jwtService,
 		emailService: emailService,
+		clock:        clock.Real,
 	}
 }
 
+// SetClock replaces the clock that timestamps accounts and expires refresh tokens and verification links
+func (h *AuthHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
 	Email    string `json:"email"`
@@ -56,7 +65,11 @@ type RefreshRequest struct {
 
 // UpdateMeRequest represents updates to the current user
 type UpdateMeRequest struct {
-	NotificationWebhookURL *string `json:"notification_webhook_url"`
+	NotificationWebhookURL   *string                           `json:"notification_webhook_url"`
+	NotificationMentions     *[]models.MentionRule             `json:"notification_mentions"`     // Replaces all rules; [] clears them
+	NotificationDestinations *[]models.NotificationDestination `json:"notification_destinations"` // Replaces all destinations; [] clears them
+	SessionAutoClose         *models.SessionAutoClose          `json:"session_auto_close"`        // Replaces both choices; {} leaves sessions untouched
+	ExportPublicKey          *string                           `json:"export_public_key"`         // "" stops encrypting exports
 }
 
 // AuthResponse represents an authentication response
@@ -70,11 +83,8 @@ type AuthResponse struct {
 // maxRequestBodySize is the maximum allowed request body size (1MB)
 const maxRequestBodySize = 1 << 20
 
-// hashRefreshToken computes SHA256 hash of a refresh token for storage
-func hashRefreshToken(token string) string {
-	h := sha256.Sum256([]byte(token))
-	return hex.EncodeToString(h[:])
-}
+// verifyTokenTTL is how long an email verification link stays valid
+const verifyTokenTTL = 24 * time.Hour
 
 // Register handles user registration
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
@@ -105,27 +115,29 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	now := time.Now()
+	now := h.clock.Now()
+	verifyExpiresAt := now.Add(verifyTokenTTL)
 	user := &models.User{
-		ID:            uuid.New().String(),
-		Email:         req.Email,
-		PasswordHash:  passwordHash,
-		Name:          req.Name,
-		EmailVerified: false,
-		VerifyToken:   verifyToken,
-		CreatedAt:     now,
-		UpdatedAt:     now,
+		ID:                   uuid.New().String(),
+		Email:                req.Email,
+		PasswordHash:         passwordHash,
+		Name:                 req.Name,
+		EmailVerified:        false,
+		VerifyToken:          verifyToken,
+		VerifyTokenExpiresAt: &verifyExpiresAt,
+		CreatedAt:            now,
+		UpdatedAt:            now,
 	}
 
 	// Validate user
 	if err := user.Validate(); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondInvalid(w, err)
 		return
 	}
 
 	// Create user
 	if err := h.store.CreateUser(user); err != nil {
-		if err == store.ErrDuplicateEmail {
+		if errors.Is(err, store.ErrDuplicateEmail) {
 			respondError(w, http.StatusConflict, "email already exists")
 			return
 		}
@@ -166,18 +178,23 @@ func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
 	// Find user by verify token
 	user, err := h.store.GetUserByVerifyToken(token)
 	if err != nil {
-		if err == store.ErrNotFound {
+		if errors.Is(err, store.ErrNotFound) {
 			respondError(w, http.StatusBadRequest, "invalid or expired token")
 			return
 		}
 		respondError(w, http.StatusInternalServerError, "failed to verify token")
 		return
 	}
+	if user.VerifyTokenExpiresAt != nil && !h.clock.Now().Before(*user.VerifyTokenExpiresAt) {
+		respondError(w, http.StatusBadRequest, "invalid or expired token")
+		return
+	}
 
 	// Update user to verified
 	user.EmailVerified = true
 	user.VerifyToken = ""
-	user.UpdatedAt = time.Now()
+	user.VerifyTokenExpiresAt = nil
+	user.UpdatedAt = h.clock.Now()
 
 	if err := h.store.UpdateUser(user); err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to verify email")
@@ -197,14 +214,14 @@ func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Save refresh token using SHA256 hash
-	refreshTokenHash := hashRefreshToken(refreshToken)
+	// Save refresh token under its HMAC-SHA256 hash
+	refreshTokenHash := h.jwtService.HashRefreshToken(refreshToken)
 	rt := &models.RefreshToken{
 		ID:        uuid.New().String(),
 		UserID:    user.ID,
 		TokenHash: refreshTokenHash,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-		CreatedAt: time.Now(),
+		ExpiresAt: h.clock.Now().Add(7 * 24 * time.Hour),
+		CreatedAt: h.clock.Now(),
 		Revoked:   false,
 	}
 	if err := h.store.SaveRefreshToken(rt); err != nil {
@@ -232,7 +249,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// Get user by email
 	user, err := h.store.GetUserByEmail(req.Email)
 	if err != nil {
-		if err == store.ErrNotFound {
+		if errors.Is(err, store.ErrNotFound) {
 			respondError(w, http.StatusUnauthorized, "invalid email or password")
 			return
 		}
@@ -251,6 +268,10 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusForbidden, "email not verified")
 		return
 	}
+	if user.DisabledAt != nil {
+		respondError(w, http.StatusForbidden, "account disabled")
+		return
+	}
 
 	// Generate tokens
 	accessToken, err := h.jwtService.GenerateAccessToken(user.ID, user.Email)
@@ -265,14 +286,14 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Save refresh token using SHA256 hash
-	refreshTokenHash := hashRefreshToken(refreshToken)
+	// Save refresh token under its HMAC-SHA256 hash
+	refreshTokenHash := h.jwtService.HashRefreshToken(refreshToken)
 	rt := &models.RefreshToken{
 		ID:        uuid.New().String(),
 		UserID:    user.ID,
 		TokenHash: refreshTokenHash,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-		CreatedAt: time.Now(),
+		ExpiresAt: h.clock.Now().Add(7 * 24 * time.Hour),
+		CreatedAt: h.clock.Now(),
 		Revoked:   false,
 	}
 	if err := h.store.SaveRefreshToken(rt); err != nil {
@@ -305,7 +326,7 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify refresh token exists in DB and is not revoked
-	tokenHash := hashRefreshToken(req.RefreshToken)
+	tokenHash := h.jwtService.HashRefreshToken(req.RefreshToken)
 	storedToken, err := h.store.GetRefreshToken(tokenHash)
 	if err != nil {
 		respondError(w, http.StatusUnauthorized, "invalid or expired refresh token")
@@ -315,14 +336,24 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusUnauthorized, "refresh token has been revoked")
 		return
 	}
-	if time.Now().After(storedToken.ExpiresAt) {
+	if h.clock.Now().After(storedToken.ExpiresAt) {
 		respondError(w, http.StatusUnauthorized, "refresh token has expired")
 		return
 	}
+	if storedToken.UserID != claims.UserID {
+		respondError(w, http.StatusUnauthorized, "invalid or expired refresh token")
+		return
+	}
 
-	// Revoke the old refresh token (token rotation)
+	// Revoke the old refresh token (token rotation); only one concurrent refresh can win
 	if err := h.store.RevokeRefreshToken(storedToken.ID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, http.StatusUnauthorized, "refresh token has been revoked")
+			return
+		}
 		log.Printf("Failed to revoke old refresh token: %v", err)
+		respondError(w, http.StatusInternalServerError, "failed to refresh session")
+		return
 	}
 
 	// Get user
@@ -331,6 +362,10 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		respondError(w, http.StatusUnauthorized, "user not found")
 		return
 	}
+	if user.DisabledAt != nil {
+		respondError(w, http.StatusForbidden, "account disabled")
+		return
+	}
 
 	// Generate new tokens
 	accessToken, err := h.jwtService.GenerateAccessToken(user.ID, user.Email)
@@ -345,14 +380,14 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Save new refresh token using SHA256 hash
-	newRefreshTokenHash := hashRefreshToken(newRefreshToken)
+	// Save new refresh token under its HMAC-SHA256 hash
+	newRefreshTokenHash := h.jwtService.HashRefreshToken(newRefreshToken)
 	rt := &models.RefreshToken{
 		ID:        uuid.New().String(),
 		UserID:    user.ID,
 		TokenHash: newRefreshTokenHash,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-		CreatedAt: time.Now(),
+		ExpiresAt: h.clock.Now().Add(7 * 24 * time.Hour),
+		CreatedAt: h.clock.Now(),
 		Revoked:   false,
 	}
 	if err := h.store.SaveRefreshToken(rt); err != nil {
@@ -370,14 +405,14 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 
 // Logout handles user logout
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "not authenticated")
 		return
 	}
 
 	// Revoke all user's refresh tokens
-	h.store.RevokeAllUserTokens(claims.UserID)
+	h.store.RevokeAllUserTokens(caller.UserID)
 
 	respondJSON(w, http.StatusOK, map[string]string{
 		"message": "logged out successfully",
@@ -386,24 +421,25 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 
 // Me returns the current user's information
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "not authenticated")
 		return
 	}
 
-	user, err := h.store.GetUserByID(claims.UserID)
+	user, err := h.store.GetUserByID(caller.UserID)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "user not found")
 		return
 	}
 
+	w.Header().Set("ETag", etagFor(user.UpdatedAt))
 	respondJSON(w, http.StatusOK, user)
 }
 
 // UpdateMe updates current user's settings
 func (h *AuthHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		respondError(w, http.StatusUnauthorized, "not authenticated")
 		return
@@ -416,27 +452,67 @@ func (h *AuthHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.store.GetUserByID(claims.UserID)
+	user, err := h.store.GetUserByID(caller.UserID)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "user not found")
 		return
 	}
 
+	if !ifMatchSatisfied(r, etagFor(user.UpdatedAt)) {
+		respondError(w, http.StatusConflict, "user was modified since it was read")
+		return
+	}
+
 	if req.NotificationWebhookURL != nil {
 		webhookURL := strings.TrimSpace(*req.NotificationWebhookURL)
 		if err := validateWebhookURL(webhookURL); err != nil {
-			respondError(w, http.StatusBadRequest, err.Error())
+			respondInvalid(w, err)
 			return
 		}
 		user.NotificationWebhookURL = webhookURL
 	}
 
-	user.UpdatedAt = time.Now()
+	if req.NotificationMentions != nil {
+		if err := validateMentionRules(*req.NotificationMentions, "notification_mentions"); err != nil {
+			respondInvalid(w, err)
+			return
+		}
+		user.NotificationMentions = *req.NotificationMentions
+	}
+
+	if req.NotificationDestinations != nil {
+		if err := validateDestinations(*req.NotificationDestinations, "notification_destinations"); err != nil {
+			respondInvalid(w, err)
+			return
+		}
+		user.NotificationDestinations = *req.NotificationDestinations
+	}
+
+	if req.SessionAutoClose != nil {
+		if err := req.SessionAutoClose.Validate(); err != nil {
+			respondInvalid(w, models.Nested("session_auto_close", "/session_auto_close", err))
+			return
+		}
+		user.SessionAutoClose = *req.SessionAutoClose
+	}
+
+	if req.ExportPublicKey != nil {
+		if *req.ExportPublicKey != "" {
+			if _, err := encryption.ParsePublicKey(*req.ExportPublicKey); err != nil {
+				respondError(w, http.StatusBadRequest, "export_public_key: "+err.Error())
+				return
+			}
+		}
+		user.ExportPublicKey = *req.ExportPublicKey
+	}
+
+	user.UpdatedAt = h.clock.Now()
 	if err := h.store.UpdateUser(user); err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to update user")
 		return
 	}
 
+	w.Header().Set("ETag", etagFor(user.UpdatedAt))
 	respondJSON(w, http.StatusOK, user)
 }
 
@@ -477,8 +553,10 @@ func (h *AuthHandler) ResendVerify(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update user
+	verifyExpiresAt := h.clock.Now().Add(verifyTokenTTL)
 	user.VerifyToken = verifyToken
-	user.UpdatedAt = time.Now()
+	user.VerifyTokenExpiresAt = &verifyExpiresAt
+	user.UpdatedAt = h.clock.Now()
 	if err := h.store.UpdateUser(user); err != nil {
 		respondError(w, http.StatusInternalServerError, "failed to update verification token")
 		return
@@ -514,10 +592,39 @@ func validateWebhookURL(raw string) error {
 
 	parsed, err := url.ParseRequestURI(raw)
 	if err != nil {
-		return errors.New("invalid notification_webhook_url")
+		return models.Invalid("notification_webhook_url", "invalid notification_webhook_url")
 	}
 	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return errors.New("notification_webhook_url must start with http or https")
+		return models.Invalid("notification_webhook_url", "notification_webhook_url must start with http or https")
+	}
+	return nil
+}
+
+// validateMentionRules checks the number of mention rules and each rule's fields; field names the list in errors
+func validateMentionRules(rules []models.MentionRule, field string) error {
+	if len(rules) > models.MaxMentionRules {
+		return &models.FieldError{Field: fieldPointer(field), Code: models.CodeInvalid,
+			Message: fmt.Sprintf("%s must have at most %d rules", field, models.MaxMentionRules)}
+	}
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return models.Nested(fmt.Sprintf("%s[%d]", field, i), fmt.Sprintf("%s/%d", fieldPointer(field), i), err)
+		}
+	}
+	return nil
+}
+
+// validateDestinations checks the number of notification destinations and each destination's fields; field names
+// the list in errors
+func validateDestinations(destinations []models.NotificationDestination, field string) error {
+	if len(destinations) > models.MaxNotificationDestinations {
+		return &models.FieldError{Field: fieldPointer(field), Code: models.CodeInvalid,
+			Message: fmt.Sprintf("%s must have at most %d destinations", field, models.MaxNotificationDestinations)}
+	}
+	for i := range destinations {
+		if err := destinations[i].Validate(); err != nil {
+			return models.Nested(fmt.Sprintf("%s[%d]", field, i), fmt.Sprintf("%s/%d", fieldPointer(field), i), err)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestAuthHandler_RefreshRotation(t *testing.T) {
+	st := store.NewMemoryStore()
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	handler := NewAuthHandler(st, jwtService, nil)
+
+	now := time.Now()
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: "hash", CreatedAt: now, UpdatedAt: now})
+
+	saveToken := func(id string, expiresAt time.Time) string {
+		token, err := jwtService.GenerateRefreshToken("user-123")
+		if err != nil {
+			t.Fatalf("GenerateRefreshToken() error = %v", err)
+		}
+		st.SaveRefreshToken(&models.RefreshToken{
+			ID:        id,
+			UserID:    "user-123",
+			TokenHash: jwtService.HashRefreshToken(token),
+			ExpiresAt: expiresAt,
+			CreatedAt: now,
+		})
+		return token
+	}
+
+	refresh := func(token string) (int, AuthResponse) {
+		body, _ := json.Marshal(RefreshRequest{RefreshToken: token})
+		rr := httptest.NewRecorder()
+		handler.Refresh(rr, httptest.NewRequest("POST", "/api/auth/refresh", bytes.NewReader(body)))
+		var response AuthResponse
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr.Code, response
+	}
+
+	token := saveToken("token-1", now.Add(time.Hour))
+	code, response := refresh(token)
+	if code != http.StatusOK {
+		t.Fatalf("Refresh() status = %v, want %v", code, http.StatusOK)
+	}
+
+	// The rotated token is stored under its hash and can be used once
+	if code, _ := refresh(response.RefreshToken); code != http.StatusOK {
+		t.Errorf("Refresh() with rotated token status = %v, want %v", code, http.StatusOK)
+	}
+	if code, _ := refresh(token); code != http.StatusUnauthorized {
+		t.Errorf("Refresh() with revoked token status = %v, want %v", code, http.StatusUnauthorized)
+	}
+
+	// The stored expiry is enforced even while the JWT itself is still valid
+	expired := saveToken("token-2", now.Add(-time.Minute))
+	if code, _ := refresh(expired); code != http.StatusUnauthorized {
+		t.Errorf("Refresh() with expired token status = %v, want %v", code, http.StatusUnauthorized)
+	}
+
+	// Expiry is judged by the handler's clock
+	fake := clock.NewFake(now)
+	handler.SetClock(fake)
+	live := saveToken("token-3", now.Add(time.Hour))
+	fake.Advance(2 * time.Hour)
+	if code, _ := refresh(live); code != http.StatusUnauthorized {
+		t.Errorf("Refresh() with a token expired by the clock status = %v, want %v", code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthHandler_LoginDisabledUser(t *testing.T) {
+	st := store.NewMemoryStore()
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	handler := NewAuthHandler(st, jwtService, nil)
+
+	now := time.Now()
+	hash, _ := auth.HashPassword("password-123")
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: hash, EmailVerified: true, DisabledAt: &now, CreatedAt: now, UpdatedAt: now})
+
+	body, _ := json.Marshal(LoginRequest{Email: "test@example.com", Password: "password-123"})
+	rr := httptest.NewRecorder()
+	handler.Login(rr, httptest.NewRequest("POST", "/api/auth/login", bytes.NewReader(body)))
+	if rr.Code != http.StatusForbidden || !bytes.Contains(rr.Body.Bytes(), []byte("account disabled")) {
+		t.Errorf("Login() of a disabled user = %v %s, want %v account disabled", rr.Code, rr.Body.String(), http.StatusForbidden)
+	}
+}
+
+func TestAuthHandler_VerifyEmailExpiredToken(t *testing.T) {
+	st := store.NewMemoryStore()
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	handler := NewAuthHandler(st, jwtService, nil)
+
+	expiredAt := time.Now().Add(-time.Minute)
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: "hash", VerifyToken: "token-123", VerifyTokenExpiresAt: &expiredAt})
+
+	rr := httptest.NewRecorder()
+	handler.VerifyEmail(rr, httptest.NewRequest("GET", "/api/auth/verify?token=token-123", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("VerifyEmail() status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if user, _ := st.GetUserByID("user-123"); user.EmailVerified {
+		t.Error("VerifyEmail() verified a user with an expired token")
+	}
+}
+
+func TestAuthHandler_UpdateMeMentions(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	handler := NewAuthHandler(st, jwtService, nil)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantMentions int
+	}{
+		{"set rules", `{"notification_mentions":[{"agent_id":"agent-001","chat_user_id":"U123"},{"topic_pattern":"^deploy","chat_user_id":"U456","name":"Ops"}]}`, http.StatusOK, 2},
+		{"missing chat user", `{"notification_mentions":[{"agent_id":"agent-001"}]}`, http.StatusBadRequest, 2},
+		{"invalid pattern", `{"notification_mentions":[{"topic_pattern":"(","chat_user_id":"U123"}]}`, http.StatusBadRequest, 2},
+		{"other settings keep rules", `{"notification_webhook_url":"https://example.com/hook"}`, http.StatusOK, 2},
+		{"clear rules", `{"notification_mentions":[]}`, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testsupport.WithUser(httptest.NewRequest("PUT", "/api/auth/me", bytes.NewBufferString(tt.body)))
+			rr := httptest.NewRecorder()
+
+			handler.UpdateMe(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("UpdateMe() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if user, _ := st.GetUserByID(testsupport.UserID); len(user.NotificationMentions) != tt.wantMentions {
+				t.Errorf("UpdateMe() stored %d mention rules, want %d", len(user.NotificationMentions), tt.wantMentions)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_UpdateMeDestinations(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	handler := NewAuthHandler(st, jwtService, nil)
+
+	tests := []struct {
+		name             string
+		body             string
+		wantStatus       int
+		wantDestinations int
+	}{
+		{"set destinations", `{"notification_destinations":[{"url":"https://hooks.slack.com/services/T/B/X"},{"url":"https://example.com/agents/{{.AgentID}}/{{.ToStatus}}","format":"generic"}]}`, http.StatusOK, 2},
+		{"unknown field", `{"notification_destinations":[{"url":"https://example.com/{{.Agent}}"}]}`, http.StatusBadRequest, 2},
+		{"unknown format", `{"notification_destinations":[{"url":"https://example.com/hook","format":"mattermost"}]}`, http.StatusBadRequest, 2},
+		{"not http", `{"notification_destinations":[{"url":"ftp://example.com/{{.AgentID}}"}]}`, http.StatusBadRequest, 2},
+		{"other settings keep destinations", `{"notification_mentions":[]}`, http.StatusOK, 2},
+		{"clear destinations", `{"notification_destinations":[]}`, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testsupport.WithUser(httptest.NewRequest("PUT", "/api/auth/me", bytes.NewBufferString(tt.body)))
+			rr := httptest.NewRecorder()
+
+			handler.UpdateMe(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("UpdateMe() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if user, _ := st.GetUserByID(testsupport.UserID); len(user.NotificationDestinations) != tt.wantDestinations {
+				t.Errorf("UpdateMe() stored %d destinations, want %d", len(user.NotificationDestinations), tt.wantDestinations)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_UpdateMeSessionAutoClose(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	handler := NewAuthHandler(st, jwtService, nil)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       models.SessionAutoClose
+	}{
+		{"set both", `{"session_auto_close":{"on_delete":"fail","on_offline":"expire"}}`, http.StatusOK, models.SessionAutoClose{OnDelete: "fail", OnOffline: "expire"}},
+		{"unknown action", `{"session_auto_close":{"on_delete":"archive"}}`, http.StatusBadRequest, models.SessionAutoClose{OnDelete: "fail", OnOffline: "expire"}},
+		{"other settings keep choices", `{"notification_mentions":[]}`, http.StatusOK, models.SessionAutoClose{OnDelete: "fail", OnOffline: "expire"}},
+		{"leave untouched", `{"session_auto_close":{}}`, http.StatusOK, models.SessionAutoClose{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testsupport.WithUser(httptest.NewRequest("PUT", "/api/auth/me", bytes.NewBufferString(tt.body)))
+			rr := httptest.NewRecorder()
+
+			handler.UpdateMe(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("UpdateMe() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if user, _ := st.GetUserByID(testsupport.UserID); user.SessionAutoClose != tt.want {
+				t.Errorf("UpdateMe() stored %+v, want %+v", user.SessionAutoClose, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_UpdateMeExportPublicKey(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	handler := NewAuthHandler(st, jwtService, nil)
+	key := "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       string
+	}{
+		{"set key", `{"export_public_key":"` + key + `"}`, http.StatusOK, key},
+		{"not base64", `{"export_public_key":"not a key"}`, http.StatusBadRequest, key},
+		{"too short", `{"export_public_key":"c2hvcnQ="}`, http.StatusBadRequest, key},
+		{"other settings keep key", `{"notification_mentions":[]}`, http.StatusOK, key},
+		{"clear key", `{"export_public_key":""}`, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testsupport.WithUser(httptest.NewRequest("PUT", "/api/auth/me", bytes.NewBufferString(tt.body)))
+			rr := httptest.NewRecorder()
+
+			handler.UpdateMe(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("UpdateMe() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if user, _ := st.GetUserByID(testsupport.UserID); user.ExportPublicKey != tt.want {
+				t.Errorf("UpdateMe() stored %q, want %q", user.ExportPublicKey, tt.want)
+			}
+		})
+	}
+}
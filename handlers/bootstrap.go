@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Bounds of a bootstrap request
+const (
+	maxBootstrapAPIKeys = 10
+	maxBootstrapAgents  = 100
+)
+
+// bootstrapActor is the audit log actor of requests authenticated with the bootstrap token
+const bootstrapActor = "bootstrap"
+
+// BootstrapHandler provisions tenants for platforms embedding kubeagents
+type BootstrapHandler struct {
+	store store.Store
+	clock clock.Clock
+}
+
+// NewBootstrapHandler creates a new bootstrap handler
+func NewBootstrapHandler(st store.Store) *BootstrapHandler {
+	return &BootstrapHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps the provisioned records
+func (h *BootstrapHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// BootstrapRequest is the body of POST /api/bootstrap
+type BootstrapRequest struct {
+	User    BootstrapUser         `json:"user"`
+	Org     *CreateOrgRequest     `json:"org,omitempty"`      // Organization owned by the user, sharing the agents
+	APIKeys []CreateAPIKeyRequest `json:"api_keys,omitempty"` // At most 10
+	Agents  []BootstrapAgent      `json:"agents,omitempty"`   // At most 100
+}
+
+// BootstrapUser describes the user to create
+type BootstrapUser struct {
+	Email    string `json:"email"`
+	Name     string `json:"name,omitempty"`
+	Password string `json:"password,omitempty"` // Generated when empty
+	Role     string `json:"role,omitempty"`     // One of the UserRole constants, member when empty
+}
+
+// BootstrapAgent describes an agent to register before it first reports
+type BootstrapAgent struct {
+	AgentID string `json:"agent_id"`
+	Name    string `json:"name,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+	Region  string `json:"region,omitempty"`
+}
+
+// BootstrapResponse holds every record provisioned and the credentials to use them, which are only shown once
+type BootstrapResponse struct {
+	User     *AdminUserSummary      `json:"user"`
+	Password string                 `json:"password,omitempty"` // The generated password, when the request set none
+	Org      *OrgWithRole           `json:"org,omitempty"`
+	APIKeys  []CreateAPIKeyResponse `json:"api_keys"`
+	Agents   []BootstrappedAgent    `json:"agents"`
+}
+
+// BootstrappedAgent is a registered agent with the agent token it reports with
+type BootstrappedAgent struct {
+	*models.Agent
+	KeyID      string `json:"key_id"`
+	AgentToken string `json:"agent_token"` // An API key that may only report for this agent
+}
+
+// bootstrapPlan is every record a bootstrap request creates, built and validated before any is written
+type bootstrapPlan struct {
+	user        *models.User
+	password    string // Set when generated
+	org         *models.Organization
+	owner       *models.Membership
+	apiKeys     []*models.APIKey
+	rawKeys     []string
+	agents      []*models.Agent
+	agentTokens []*models.APIKey
+	rawTokens   []string
+}
+
+// Create handles POST /api/bootstrap, creating a verified user with an optional organization, API keys and
+// pre-registered agents in one call; it is authenticated with the deployment's bootstrap token
+// Every field is validated before anything is written, and the records already written are removed again if a
+// later write fails, so a failed request can be retried as is.
+func (h *BootstrapHandler) Create(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req BootstrapRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	plan, err := h.plan(&req)
+	if err != nil {
+		var fieldErrs models.FieldErrors
+		if errors.As(err, &fieldErrs) {
+			respondInvalid(w, err)
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to generate credentials")
+		return
+	}
+	for _, agent := range plan.agents {
+		if _, err := h.store.GetAgent(agent.AgentID); err == nil {
+			respondError(w, http.StatusConflict, fmt.Sprintf("agent %s already exists", agent.AgentID))
+			return
+		}
+	}
+
+	if status, message := h.apply(plan); status != 0 {
+		respondError(w, status, message)
+		return
+	}
+	h.audit(plan.user.ID)
+
+	response := BootstrapResponse{
+		User:     summarizeUser(plan.user, len(plan.agents)),
+		Password: plan.password,
+		APIKeys:  make([]CreateAPIKeyResponse, len(plan.apiKeys)),
+		Agents:   make([]BootstrappedAgent, len(plan.agents)),
+	}
+	if plan.org != nil {
+		response.Org = &OrgWithRole{Organization: plan.org, Role: plan.owner.Role}
+	}
+	for i, apiKey := range plan.apiKeys {
+		response.APIKeys[i] = createdAPIKey(apiKey, plan.rawKeys[i])
+	}
+	for i, agent := range plan.agents {
+		response.Agents[i] = BootstrappedAgent{Agent: agent, KeyID: plan.agentTokens[i].ID, AgentToken: plan.rawTokens[i]}
+	}
+	respondJSON(w, http.StatusCreated, response)
+}
+
+// plan builds the records of a request, returning models.FieldErrors listing every invalid field
+func (h *BootstrapHandler) plan(req *BootstrapRequest) (*bootstrapPlan, error) {
+	now := h.clock.Now().UTC()
+	plan := &bootstrapPlan{}
+	var errs models.FieldErrors
+
+	password := req.User.Password
+	if password == "" {
+		token, err := generateToken()
+		if err != nil {
+			return nil, err
+		}
+		password = token[:temporaryPasswordLength]
+		plan.password = password
+	}
+	if err := models.ValidatePassword(password); err != nil {
+		errs.Add("", models.Nested("user", "/user", err))
+	}
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	plan.user = &models.User{
+		ID:            uuid.New().String(),
+		Email:         strings.TrimSpace(req.User.Email),
+		PasswordHash:  passwordHash,
+		Name:          req.User.Name,
+		Role:          req.User.Role,
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := plan.user.Validate(); err != nil {
+		errs.Add("", models.Nested("user", "/user", err))
+	}
+
+	if req.Org != nil {
+		plan.org = &models.Organization{ID: uuid.New().String(), Name: strings.TrimSpace(req.Org.Name), CreatedBy: plan.user.ID, CreatedAt: now}
+		plan.owner = &models.Membership{OrgID: plan.org.ID, UserID: plan.user.ID, Role: models.OrgRoleOwner, CreatedAt: now}
+		if err := plan.org.Validate(); err != nil {
+			errs.Add("", models.Nested("org", "/org", err))
+		}
+	}
+
+	if len(req.APIKeys) > maxBootstrapAPIKeys {
+		errs = append(errs, models.Length("api_keys", fmt.Sprintf("<= %d", maxBootstrapAPIKeys), "keys"))
+	}
+	for i, keyReq := range req.APIKeys {
+		apiKey, rawKey, err := newAPIKey(plan.user.ID, keyReq, now)
+		if err != nil {
+			return nil, err
+		}
+		if err := apiKey.Validate(); err != nil {
+			errs.Add("", models.Nested(fmt.Sprintf("api_keys[%d]", i), fmt.Sprintf("/api_keys/%d", i), err))
+		}
+		plan.apiKeys = append(plan.apiKeys, apiKey)
+		plan.rawKeys = append(plan.rawKeys, rawKey)
+	}
+
+	if len(req.Agents) > maxBootstrapAgents {
+		errs = append(errs, models.Length("agents", fmt.Sprintf("<= %d", maxBootstrapAgents), "agents"))
+	}
+	seen := make(map[string]bool, len(req.Agents))
+	for i, agentReq := range req.Agents {
+		label, pointer := fmt.Sprintf("agents[%d]", i), fmt.Sprintf("/agents/%d", i)
+		agent := &models.Agent{
+			AgentID:    agentReq.AgentID,
+			UserID:     plan.user.ID,
+			Name:       agentReq.Name,
+			Kind:       agentReq.Kind,
+			Cluster:    agentReq.Cluster,
+			Region:     agentReq.Region,
+			Registered: now,
+			LastSeen:   now,
+		}
+		if plan.org != nil {
+			agent.OrgID = plan.org.ID
+		}
+		if err := agent.Validate(); err != nil {
+			errs.Add("", models.Nested(label, pointer, err))
+		}
+		if seen[agent.AgentID] {
+			errs.Add("", models.Nested(label, pointer, models.Invalid("agent_id", "agent_id is listed twice")))
+		}
+		seen[agent.AgentID] = true
+
+		token, rawToken, err := newAPIKey(plan.user.ID, CreateAPIKeyRequest{Name: agent.AgentID}, now)
+		if err != nil {
+			return nil, err
+		}
+		token.AgentID = agent.AgentID
+		plan.agents = append(plan.agents, agent)
+		plan.agentTokens = append(plan.agentTokens, token)
+		plan.rawTokens = append(plan.rawTokens, rawToken)
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// apply writes the records of a plan, returning the status and message of the error response when a write
+// fails after removing what it wrote, or 0 on success
+func (h *BootstrapHandler) apply(plan *bootstrapPlan) (int, string) {
+	if err := h.store.CreateUser(plan.user); err != nil {
+		if errors.Is(err, store.ErrDuplicateEmail) {
+			return http.StatusConflict, "email already exists"
+		}
+		log.Printf("Failed to create bootstrapped user: %v", err)
+		return http.StatusInternalServerError, "failed to create user"
+	}
+
+	status, message := h.applyOwned(plan)
+	if status != 0 {
+		if plan.org != nil {
+			if err := h.store.DeleteOrganization(plan.org.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+				log.Printf("Failed to remove organization %s of a failed bootstrap: %v", plan.org.ID, err)
+			}
+		}
+		if err := h.store.DeleteUser(plan.user.ID); err != nil {
+			log.Printf("Failed to remove user %s of a failed bootstrap: %v", plan.user.ID, err)
+		}
+	}
+	return status, message
+}
+
+// applyOwned writes the records owned by the plan's user, which already exists
+func (h *BootstrapHandler) applyOwned(plan *bootstrapPlan) (int, string) {
+	if plan.org != nil {
+		if err := h.store.CreateOrganization(plan.org, plan.owner); err != nil {
+			log.Printf("Failed to create bootstrapped organization: %v", err)
+			return http.StatusInternalServerError, "failed to create organization"
+		}
+	}
+	for _, apiKey := range plan.apiKeys {
+		if err := h.store.CreateAPIKey(apiKey); err != nil {
+			log.Printf("Failed to create bootstrapped API key: %v", err)
+			return http.StatusInternalServerError, "failed to create API key"
+		}
+	}
+	for i, agent := range plan.agents {
+		if err := h.store.CreateOrUpdateAgent(agent); err != nil {
+			// Registered by someone else since it was checked
+			if errors.Is(err, store.ErrConflict) || errors.Is(err, store.ErrAgentDeleted) {
+				return http.StatusConflict, fmt.Sprintf("agent %s already exists", agent.AgentID)
+			}
+			log.Printf("Failed to register bootstrapped agent: %v", err)
+			return http.StatusInternalServerError, "failed to register agent"
+		}
+		if err := h.store.CreateAPIKey(plan.agentTokens[i]); err != nil {
+			log.Printf("Failed to create bootstrapped agent token: %v", err)
+			return http.StatusInternalServerError, "failed to create agent token"
+		}
+	}
+	return 0, ""
+}
+
+// audit records a bootstrap in the admin audit log, targeting the user it created
+func (h *BootstrapHandler) audit(userID string) {
+	event := &models.AuditEvent{
+		ActorID:    bootstrapActor,
+		Action:     "POST /api/bootstrap",
+		Target:     userID,
+		StatusCode: http.StatusCreated,
+		CreatedAt:  h.clock.Now().UTC(),
+	}
+	if err := h.store.AddAuditEvent(event); err != nil {
+		log.Printf("Failed to record audit event: %v", err)
+	}
+}
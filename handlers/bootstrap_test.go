@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// bootstrap sends a bootstrap request and returns the response
+func bootstrap(handler *BootstrapHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/bootstrap", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.Create(rr, req)
+	return rr
+}
+
+func TestBootstrapHandler_ProvisionsTenant(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewBootstrapHandler(st)
+
+	rr := bootstrap(handler, `{
+		"user": {"email": "platform-tenant@example.com", "name": "Tenant"},
+		"org": {"name": "Tenant Team"},
+		"api_keys": [{"name": "host platform"}],
+		"agents": [{"agent_id": "tenant-builder", "name": "Builder", "cluster": "prod-eu-1"}, {"agent_id": "tenant-reviewer"}]
+	}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Create() status = %v, want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var resp BootstrapResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	// The user is verified and signs in with the generated password
+	user, err := st.GetUserByEmail("platform-tenant@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail() error = %v", err)
+	}
+	if user.ID != resp.User.ID || !user.EmailVerified || resp.User.AgentCount != 2 {
+		t.Errorf("user = %+v, response user = %+v, want the verified user with 2 agents", user, resp.User)
+	}
+	if len(resp.Password) != temporaryPasswordLength || !auth.VerifyPassword(resp.Password, user.PasswordHash) {
+		t.Errorf("password = %q, want the generated password of the user", resp.Password)
+	}
+
+	if resp.Org == nil || resp.Org.Role != "owner" {
+		t.Fatalf("org = %+v, want one owned by the user", resp.Org)
+	}
+	if _, err := st.GetMembership(resp.Org.ID, user.ID); err != nil {
+		t.Errorf("GetMembership() error = %v, want the user to own the organization", err)
+	}
+
+	if len(resp.APIKeys) != 1 {
+		t.Fatalf("api_keys = %+v, want 1", resp.APIKeys)
+	}
+	if key, err := st.GetAPIKeyByHash(middleware.HashAPIKey(resp.APIKeys[0].Key)); err != nil || key.UserID != user.ID || key.AgentID != "" {
+		t.Errorf("API key = %+v, %v, want a key of the user for every agent", key, err)
+	}
+
+	if len(resp.Agents) != 2 {
+		t.Fatalf("agents = %+v, want 2", resp.Agents)
+	}
+	for _, registered := range resp.Agents {
+		agent, err := st.GetAgent(registered.AgentID)
+		if err != nil || agent.UserID != user.ID || agent.OrgID != resp.Org.ID {
+			t.Errorf("GetAgent(%s) = %+v, %v, want it owned by the user and shared with the organization", registered.AgentID, agent, err)
+		}
+		token, err := st.GetAPIKeyByHash(middleware.HashAPIKey(registered.AgentToken))
+		if err != nil || token.ID != registered.KeyID || token.AgentID != registered.AgentID {
+			t.Errorf("agent token of %s = %+v, %v, want a token for that agent only", registered.AgentID, token, err)
+		}
+	}
+	if resp.Agents[0].Cluster != "prod-eu-1" {
+		t.Errorf("agents[0].cluster = %q, want prod-eu-1", resp.Agents[0].Cluster)
+	}
+
+	events, _ := st.ListAuditEvents(time.Time{}, 10)
+	if len(events) != 1 || events[0].ActorID != bootstrapActor || events[0].Target != user.ID {
+		t.Errorf("audit events = %+v, want the bootstrap of the user", events)
+	}
+}
+
+func TestBootstrapHandler_ValidatesEveryFieldFirst(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewBootstrapHandler(st)
+
+	rr := bootstrap(handler, `{
+		"user": {"email": "not-an-email", "password": "short"},
+		"api_keys": [{"name": ""}],
+		"agents": [{"agent_id": "dup"}, {"agent_id": "dup", "kind": "robot"}]
+	}`)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Create() status = %v, want %v: %s", rr.Code, http.StatusBadRequest, rr.Body.String())
+	}
+	var problem ValidationProblem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	var fields []string
+	for _, fieldErr := range problem.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	want := "/user/password /user/email /api_keys/0/name /agents/1/kind /agents/1/agent_id"
+	if got := strings.Join(fields, " "); got != want {
+		t.Errorf("invalid fields = %s, want %s", got, want)
+	}
+	if users, _ := st.ListUsers(); len(users) != 0 {
+		t.Errorf("users = %d, want none created by an invalid request", len(users))
+	}
+}
+
+func TestBootstrapHandler_Conflicts(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	testsupport.CreateAgent(t, st, "taken-agent")
+	handler := NewBootstrapHandler(st)
+
+	rr := bootstrap(handler, `{"user": {"email": "`+testsupport.UserEmail+`"}}`)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Create() of an existing email status = %v, want %v: %s", rr.Code, http.StatusConflict, rr.Body.String())
+	}
+
+	rr = bootstrap(handler, `{"user": {"email": "new-tenant@example.com"}, "api_keys": [{"name": "host"}], "agents": [{"agent_id": "taken-agent"}]}`)
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "taken-agent") {
+		t.Errorf("Create() of an existing agent = %v %s, want %v naming the agent", rr.Code, rr.Body.String(), http.StatusConflict)
+	}
+	if _, err := st.GetUserByEmail("new-tenant@example.com"); err == nil {
+		t.Error("GetUserByEmail() found the user of a conflicting request, want nothing created")
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// ServeArgo handles POST /webhook/argo requests carrying an Argo Workflow object
+// Each workflow template gets its own agent and each workflow its own session.
+func (h *WebhookHandler) ServeArgo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	// Limit request body size (1MB)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var workflow internal.ArgoWorkflow
+	if err := json.NewDecoder(r.Body).Decode(&workflow); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+		return
+	}
+
+	report, err := workflow.StatusReport(h.now())
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	h.reportAll(w, caller, []*internal.StatusReport{report}, "workflows")
+}
+
+// ServeTekton handles POST /webhook/tekton requests carrying a Tekton PipelineRun or its CloudEvent
+// Each pipeline gets its own agent and each PipelineRun its own session.
+func (h *WebhookHandler) ServeTekton(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	// Limit request body size (1MB)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Failed to read body: "+err.Error())
+		return
+	}
+
+	run, err := internal.ParseTektonPipelineRun(body)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+		return
+	}
+
+	report, err := run.StatusReport(h.now())
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	h.reportAll(w, caller, []*internal.StatusReport{report}, "pipeline runs")
+}
+
+// reportAll validates and processes reports translated from another system's payload
+// Every report is validated first so a bad one does not leave the payload half applied.
+func (h *WebhookHandler) reportAll(w http.ResponseWriter, caller *middleware.RequestContext, reports []*internal.StatusReport, what string) {
+	limits := h.payloadLimitsFor(caller)
+	for _, report := range reports {
+		if err := report.ValidateWithLimits(limits); err != nil {
+			h.respondError(w, http.StatusBadRequest, "bad_request", fmt.Sprintf("%s: %v", report.SessionTopic, err))
+			return
+		}
+
+		// Client certificates and agent tokens may be restricted to reporting for a single agent
+		if !caller.MayReportFor(report.AgentID) {
+			h.respondError(w, http.StatusForbidden, "forbidden", "Credential is not authorized for this agent")
+			return
+		}
+	}
+
+	for _, report := range reports {
+		if err := h.processStatusReport(report, caller.UserID); err != nil {
+			if errors.Is(err, store.ErrAgentDeleted) {
+				h.respondError(w, http.StatusGone, "agent_deleted", "Agent was deleted, restore it before reporting again")
+				return
+			}
+			if errors.Is(err, store.ErrConflict) {
+				h.respondError(w, http.StatusConflict, "conflict", "Agent or session was modified concurrently, retry the report")
+				return
+			}
+			log.Printf("Error processing translated status report %s: %v", report.SessionTopic, err)
+			h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to process "+what)
+			return
+		}
+	}
+
+	h.respondSuccess(w, fmt.Sprintf("Reported %d %s", len(reports), what))
+}
+
+// ServeGitHub handles POST /webhook/github requests carrying a GitHub workflow_run event
+// Other events, such as the ping sent when a webhook is created, are acknowledged and ignored.
+func (h *WebhookHandler) ServeGitHub(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	if event := r.Header.Get("X-GitHub-Event"); event != "" && event != "workflow_run" {
+		h.respondSuccess(w, "Ignored "+event+" event")
+		return
+	}
+
+	// Limit request body size (1MB)
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+
+	var event internal.GitHubWorkflowRunEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+		return
+	}
+
+	report, err := event.StatusReport(h.now())
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	h.reportAll(w, caller, []*internal.StatusReport{report}, "workflow runs")
+}
+
+// maxLLMEvents bounds how many events one request to /webhook/llm may carry
+const maxLLMEvents = 100
+
+// ServeLLM handles POST /webhook/llm requests carrying LLM agent framework events
+// A request holds one event or an array of them, each reported as a step of its session.
+func (h *WebhookHandler) ServeLLM(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	// Limit request body size (1MB)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Failed to read body: "+err.Error())
+		return
+	}
+
+	events, err := internal.ParseLLMEvents(body)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+		return
+	}
+	if len(events) > maxLLMEvents {
+		h.respondError(w, http.StatusBadRequest, "bad_request", fmt.Sprintf("at most %d events may be sent at once", maxLLMEvents))
+		return
+	}
+
+	limits := h.payloadLimitsFor(caller)
+	now := h.now()
+	reports := make([]*internal.StatusReport, 0, len(events))
+	for _, event := range events {
+		report, err := event.StatusReport(limits, now)
+		if err != nil {
+			h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+			return
+		}
+		reports = append(reports, report)
+	}
+
+	h.reportAll(w, caller, reports, "events")
+}
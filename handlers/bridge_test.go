@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestWebhookHandler_ServeArgoAndTekton(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	tests := []struct {
+		name        string
+		serve       http.HandlerFunc
+		body        string
+		wantStatus  int
+		wantAgentID string
+		wantTopic   string
+		wantLatest  string
+	}{
+		{
+			name:        "argo workflow",
+			serve:       handler.ServeArgo,
+			body:        `{"kind":"Workflow","metadata":{"name":"build-x7k2p","namespace":"ci","labels":{"workflows.argoproj.io/workflow-template":"build"}},"status":{"phase":"Failed","message":"step exited 1"}}`,
+			wantStatus:  http.StatusOK,
+			wantAgentID: "argo-ci-build",
+			wantTopic:   "build-x7k2p",
+			wantLatest:  "failed",
+		},
+		{
+			name:        "tekton cloud event",
+			serve:       handler.ServeTekton,
+			body:        `{"pipelineRun":{"metadata":{"name":"deploy-run-1","namespace":"ci","labels":{"tekton.dev/pipeline":"deploy"}},"status":{"conditions":[{"type":"Succeeded","status":"Unknown","reason":"Running"}]}}}`,
+			wantStatus:  http.StatusOK,
+			wantAgentID: "tekton-ci-deploy",
+			wantTopic:   "deploy-run-1",
+			wantLatest:  "running",
+		},
+		{"argo invalid JSON", handler.ServeArgo, `{`, http.StatusBadRequest, "", "", ""},
+		{"argo unknown phase", handler.ServeArgo, `{"metadata":{"name":"x"},"status":{"phase":"Paused"}}`, http.StatusBadRequest, "", "", ""},
+		{"tekton task run", handler.ServeTekton, `{"kind":"TaskRun","metadata":{"name":"x"}}`, http.StatusBadRequest, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testsupport.WithUser(httptest.NewRequest("POST", "/webhook/bridge", strings.NewReader(tt.body)))
+			rr := httptest.NewRecorder()
+
+			tt.serve(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantAgentID == "" {
+				return
+			}
+
+			agent, err := st.GetAgent(tt.wantAgentID)
+			if err != nil || agent.UserID != testsupport.UserID {
+				t.Fatalf("GetAgent(%s) = %+v, %v, want agent of the caller", tt.wantAgentID, agent, err)
+			}
+			latest, err := st.GetLatestStatus(tt.wantAgentID, tt.wantTopic)
+			if err != nil || latest.Status != tt.wantLatest {
+				t.Errorf("GetLatestStatus(%s) = %+v, %v, want %s", tt.wantTopic, latest, err, tt.wantLatest)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_ServeGitHub(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	body := `{"action":"completed","workflow_run":{"id":42,"name":"CI","path":".github/workflows/ci.yml","run_number":17,
+		"status":"completed","conclusion":"failure","head_branch":"main","updated_at":"2024-01-15T11:59:00Z"},"repository":{"full_name":"acme/app"}}`
+
+	tests := []struct {
+		name       string
+		event      string
+		body       string
+		wantStatus int
+	}{
+		{"ping", "ping", `{"zen":"Keep it logically awesome."}`, http.StatusOK},
+		{"workflow run", "workflow_run", body, http.StatusOK},
+		{"missing workflow run", "workflow_run", `{"repository":{"full_name":"acme/app"}}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testsupport.WithUser(httptest.NewRequest("POST", "/webhook/github", strings.NewReader(tt.body)))
+			req.Header.Set("X-GitHub-Event", tt.event)
+			rr := httptest.NewRecorder()
+
+			handler.ServeGitHub(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("ServeGitHub() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+
+	latest, err := st.GetLatestStatus("github-acme-app-ci", "CI #17")
+	if err != nil || latest.Status != "failed" {
+		t.Errorf("GetLatestStatus() = %+v, %v, want failed", latest, err)
+	}
+	if agents := st.ListAgentsByUser(testsupport.UserID); len(agents) != 1 {
+		t.Errorf("ListAgentsByUser() = %d agents, want only the workflow's agent", len(agents))
+	}
+}
+
+func TestWebhookHandler_ServeLLM(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := testsupport.WithUser(httptest.NewRequest("POST", "/webhook/llm", strings.NewReader(body)))
+		rr := httptest.NewRecorder()
+		handler.ServeLLM(rr, req)
+		return rr
+	}
+
+	steps := `[
+		{"agent_id":"assistant","session_topic":"conversation-1","framework":"langchain","event":"on_chain_start","run_id":"r1","name":"AgentExecutor"},
+		{"agent_id":"assistant","session_topic":"conversation-1","framework":"langchain","event":"on_tool_start","run_id":"r2","parent_run_id":"r1","name":"search","input":"weather"},
+		{"agent_id":"assistant","session_topic":"conversation-1","framework":"langchain","event":"on_tool_end","run_id":"r2","parent_run_id":"r1","name":"search","output":"sunny"},
+		{"agent_id":"assistant","session_topic":"conversation-1","framework":"langchain","event":"on_chain_end","run_id":"r1","name":"AgentExecutor"}
+	]`
+	if rr := post(steps); rr.Code != http.StatusOK {
+		t.Fatalf("ServeLLM() status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+
+	history, err := st.GetStatusHistory("assistant", "conversation-1")
+	if err != nil || len(history) != 4 {
+		t.Fatalf("GetStatusHistory() = %d statuses, %v, want all 4 steps in one session", len(history), err)
+	}
+	if latest, _ := st.GetLatestStatus("assistant", "conversation-1"); latest.Status != "success" {
+		t.Errorf("GetLatestStatus() = %s, want success after the root chain ended", latest.Status)
+	}
+
+	tooMany := "[" + strings.TrimSuffix(strings.Repeat(`{"event":"step"},`, maxLLMEvents+1), ",") + "]"
+	for _, body := range []string{`{`, `{"agent_id":"assistant","session_topic":"c","event":"on_text"}`, `{"event":"step"}`, tooMany} {
+		if rr := post(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("ServeLLM(%.40s) status = %v, want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
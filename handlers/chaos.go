@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/chaos"
+)
+
+// ChaosHandler lets admins inject faults into the store and notifier of a build made with -tags chaos
+type ChaosHandler struct {
+	injector *chaos.Injector
+}
+
+// NewChaosHandler creates a handler controlling injector
+func NewChaosHandler(injector *chaos.Injector) *ChaosHandler {
+	return &ChaosHandler{injector: injector}
+}
+
+// ChaosFaults lists the active faults
+type ChaosFaults struct {
+	Faults []chaos.Fault `json:"faults"`
+}
+
+// Get handles GET /api/admin/chaos, listing the active faults
+func (h *ChaosHandler) Get(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, ChaosFaults{Faults: h.injector.Faults()})
+}
+
+// Set handles PUT /api/admin/chaos, replacing the active faults
+func (h *ChaosHandler) Set(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req ChaosFaults
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := h.injector.Set(req.Faults); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.Get(w, r)
+}
+
+// Clear handles DELETE /api/admin/chaos, removing every fault
+func (h *ChaosHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	h.injector.Clear()
+	h.Get(w, r)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/chaos"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+)
+
+func TestChaosHandler_SetAndClear(t *testing.T) {
+	handler := NewChaosHandler(chaos.NewInjector())
+	call := func(handle http.HandlerFunc, method, body string) (int, ChaosFaults) {
+		rr := httptest.NewRecorder()
+		handle(rr, testsupport.WithUser(httptest.NewRequest(method, "/api/admin/chaos", strings.NewReader(body))))
+		var faults ChaosFaults
+		json.Unmarshal(rr.Body.Bytes(), &faults)
+		return rr.Code, faults
+	}
+
+	code, faults := call(handler.Set, "PUT", `{"faults":[{"target":"store.write","latency_ms":250,"error_rate":0.1}]}`)
+	if code != http.StatusOK || len(faults.Faults) != 1 || faults.Faults[0].LatencyMS != 250 {
+		t.Fatalf("Set() = %d %+v, want the store.write fault", code, faults)
+	}
+
+	if code, _ := call(handler.Set, "PUT", `{"faults":[{"target":"store.write","error_rate":2}]}`); code != http.StatusBadRequest {
+		t.Errorf("Set() with an invalid rate status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if _, faults := call(handler.Get, "GET", ""); len(faults.Faults) != 1 {
+		t.Errorf("Get() after a rejected Set = %+v, want the earlier fault", faults)
+	}
+
+	if code, faults := call(handler.Clear, "DELETE", ""); code != http.StatusOK || len(faults.Faults) != 0 {
+		t.Errorf("Clear() = %d %+v, want no faults", code, faults)
+	}
+}
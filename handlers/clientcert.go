@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// ClientCertificateHandler handles registration of client certificates for the mTLS webhook listener
+type ClientCertificateHandler struct {
+	store store.Store
+	clock clock.Clock
+
+	listFormat
+}
+
+// NewClientCertificateHandler creates a new client certificate handler
+func NewClientCertificateHandler(st store.Store) *ClientCertificateHandler {
+	return &ClientCertificateHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps client certificates
+func (h *ClientCertificateHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// CreateClientCertificateRequest represents a request to register a client certificate
+// Either the PEM certificate or its SHA-256 fingerprint must be given.
+type CreateClientCertificateRequest struct {
+	Name        string `json:"name"`
+	Certificate string `json:"certificate,omitempty"` // PEM-encoded certificate
+	Fingerprint string `json:"fingerprint,omitempty"` // Hex SHA-256, colons and case are ignored
+	AgentID     string `json:"agent_id,omitempty"`
+}
+
+// Create handles registering a client certificate
+func (h *ClientCertificateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	var req CreateClientCertificateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	fingerprint := normalizeFingerprint(req.Fingerprint)
+	if req.Certificate != "" {
+		cert, err := parseCertificatePEM(req.Certificate)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "certificate must be a PEM-encoded X.509 certificate")
+			return
+		}
+		if fingerprint != "" && fingerprint != middleware.CertificateFingerprint(cert) {
+			respondError(w, http.StatusBadRequest, "fingerprint does not match certificate")
+			return
+		}
+		fingerprint = middleware.CertificateFingerprint(cert)
+	}
+	if fingerprint == "" {
+		respondError(w, http.StatusBadRequest, "certificate or fingerprint is required")
+		return
+	}
+
+	cert := &models.ClientCertificate{
+		ID:          uuid.New().String(),
+		UserID:      caller.UserID,
+		Name:        req.Name,
+		Fingerprint: fingerprint,
+		AgentID:     req.AgentID,
+		CreatedAt:   h.clock.Now().UTC(),
+	}
+
+	if err := cert.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.CreateClientCertificate(cert); err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			respondError(w, http.StatusConflict, "certificate is already registered")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "failed to register client certificate")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, cert)
+}
+
+// List handles listing client certificates for the current user
+func (h *ClientCertificateHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	certs, err := h.store.ListClientCertificatesByUser(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list client certificates")
+		return
+	}
+
+	respondList(w, r, page, h.legacyKey("client_certificates"), certs, nil)
+}
+
+// Delete handles removing a client certificate, which stops it authenticating immediately
+func (h *ClientCertificateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	if err := h.store.DeleteClientCertificate(caller.UserID, chi.URLParam(r, "id")); err != nil {
+		respondStoreError(w, err, "client certificate not found", "failed to delete client certificate")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "client certificate deleted successfully",
+	})
+}
+
+// normalizeFingerprint lowercases a hex fingerprint and drops colon separators
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+}
+
+// parseCertificatePEM parses the first certificate in a PEM document
+func parseCertificatePEM(data string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
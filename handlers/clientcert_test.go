@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// newTestCertificatePEM creates a self-signed certificate and returns it PEM-encoded with its fingerprint
+func newTestCertificatePEM(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})), middleware.CertificateFingerprint(cert)
+}
+
+func TestClientCertificateHandler_Create(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewClientCertificateHandler(st)
+
+	certPEM, fingerprint := newTestCertificatePEM(t)
+	colonFingerprint := strings.ToUpper(fingerprint[:2] + ":" + fingerprint[2:])
+	otherPEM, _ := newTestCertificatePEM(t)
+
+	tests := []struct {
+		name       string
+		body       map[string]string
+		wantStatus int
+	}{
+		{"missing certificate and fingerprint", map[string]string{"name": "fleet"}, http.StatusBadRequest},
+		{"invalid PEM", map[string]string{"name": "fleet", "certificate": "not a certificate"}, http.StatusBadRequest},
+		{"fingerprint mismatch", map[string]string{"name": "fleet", "certificate": otherPEM, "fingerprint": fingerprint}, http.StatusBadRequest},
+		{"missing name", map[string]string{"certificate": certPEM}, http.StatusBadRequest},
+		{"PEM certificate", map[string]string{"name": "fleet", "certificate": certPEM, "agent_id": "agent-001"}, http.StatusCreated},
+		{"already registered fingerprint", map[string]string{"name": "again", "fingerprint": colonFingerprint}, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.body)
+			req := testsupport.WithUser(httptest.NewRequest("POST", "/api/client-certificates", strings.NewReader(string(body))))
+			rr := httptest.NewRecorder()
+
+			handler.Create(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Create() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+
+	cert, err := st.GetClientCertificateByFingerprint(fingerprint)
+	if err != nil || cert.UserID != testsupport.UserID || cert.AgentID != "agent-001" {
+		t.Fatalf("GetClientCertificateByFingerprint() = %+v, %v, want certificate for agent-001", cert, err)
+	}
+
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", cert.ID)
+	req := testsupport.WithUser(httptest.NewRequest("DELETE", "/api/client-certificates/"+cert.ID, nil))
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+
+	handler.Delete(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Delete() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	if _, err := st.GetClientCertificateByFingerprint(fingerprint); err != store.ErrNotFound {
+		t.Errorf("GetClientCertificateByFingerprint() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func TestWebhookHandler_ClientCertificateAgentRestriction(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	tests := []struct {
+		name       string
+		agentID    string
+		wantStatus int
+	}{
+		{"permitted agent", "agent-001", http.StatusOK},
+		{"other agent", "agent-002", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"agent_id":"` + tt.agentID + `","session_topic":"task-001","status":"running","timestamp":"` + time.Now().Format(time.RFC3339) + `"}`
+			req := testsupport.WithUser(httptest.NewRequest("POST", "/webhook/status", strings.NewReader(body)))
+			caller, _ := middleware.GetRequestContext(req.Context())
+			caller.ScopedAgentID = "agent-001"
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("ServeHTTP() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+
+	if _, err := st.GetAgent("agent-002"); err != store.ErrNotFound {
+		t.Errorf("GetAgent(agent-002) error = %v, want %v", err, store.ErrNotFound)
+	}
+}
@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/encryption"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigHandler exports and imports a user's monitoring configuration as one document
+type ConfigHandler struct {
+	store store.Store
+	clock clock.Clock
+}
+
+// NewConfigHandler creates a new configuration handler
+func NewConfigHandler(st store.Store) *ConfigHandler {
+	return &ConfigHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps imported records
+func (h *ConfigHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// Export handles GET /api/config/export
+// The document is JSON unless ?format=yaml is given or the Accept header asks for YAML. It is encrypted
+// with the X-Export-Passphrase header, else to the account's export key when one is registered.
+func (h *ConfigHandler) Export(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	doc, err := h.export(caller.UserID)
+	if err != nil {
+		respondStoreError(w, err, "user not found", "failed to export configuration")
+		return
+	}
+	key, ok := exportKey(w, r, h.store, caller.UserID)
+	if !ok {
+		return
+	}
+
+	h.respondDocument(w, wantsYAML(r.URL.Query().Get("format"), r.Header.Get("Accept")), doc, key)
+}
+
+// Import handles PUT /api/config/export, replacing the caller's configuration with the document in the body
+// A YAML body is read when the Content-Type says so. The whole document is validated before anything is
+// changed, and the configuration it resulted in is returned in the same format.
+func (h *ConfigHandler) Import(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	asYAML := wantsYAML("", r.Header.Get("Content-Type"))
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if asYAML {
+		if body, err = yamlToJSON(body); err != nil {
+			respondError(w, http.StatusBadRequest, "invalid YAML document")
+			return
+		}
+	}
+
+	// Unknown fields are refused so a misspelled setting is not silently dropped
+	var doc models.ConfigDocument
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid configuration document: "+err.Error())
+		return
+	}
+	if err := doc.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	current, err := h.load(caller.UserID)
+	if err != nil {
+		respondStoreError(w, err, "user not found", "failed to import configuration")
+		return
+	}
+	plan, err := planImport(current, &doc, h.clock.Now().UTC())
+	if err != nil {
+		respondInvalid(w, err)
+		return
+	}
+	if err := h.apply(plan); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to import configuration")
+		return
+	}
+
+	imported, err := h.export(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to export configuration")
+		return
+	}
+	h.respondDocument(w, asYAML, imported, encryption.ExportKey{})
+}
+
+// configState is a user's configuration as stored
+type configState struct {
+	user       *models.User
+	settings   *models.NotificationSettings // nil when the user has none
+	watchItems []*models.WatchItem
+	slas       []*models.SLA
+}
+
+// load reads the user's configuration from the store
+func (h *ConfigHandler) load(userID string) (*configState, error) {
+	user, err := h.store.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	current := &configState{user: user}
+
+	if current.settings, err = h.store.GetNotificationSettings(userID); errors.Is(err, store.ErrNotFound) {
+		current.settings = nil
+	} else if err != nil {
+		return nil, err
+	}
+	if current.watchItems, err = h.store.ListWatchItems(userID); err != nil {
+		return nil, err
+	}
+	if current.slas, err = h.store.ListSLAsByUser(userID); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// export builds the user's configuration document
+func (h *ConfigHandler) export(userID string) (*models.ConfigDocument, error) {
+	current, err := h.load(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	user := current.user
+	doc := &models.ConfigDocument{
+		Version: models.ConfigDocumentVersion,
+		Notifications: models.ConfigNotifications{
+			WebhookURL:   user.NotificationWebhookURL,
+			Mentions:     append([]models.MentionRule{}, user.NotificationMentions...),
+			Destinations: append([]models.NotificationDestination{}, user.NotificationDestinations...),
+		},
+		Watchlist:        make([]models.ConfigWatchItem, 0, len(current.watchItems)),
+		SLAs:             make([]models.ConfigSLA, 0, len(current.slas)),
+		SessionAutoClose: user.SessionAutoClose,
+	}
+	if settings := current.settings; settings != nil {
+		doc.Notifications.Settings = &models.ConfigNotificationSettings{
+			WebhookURL:           settings.WebhookURL,
+			Format:               settings.Format,
+			Transitions:          settings.Transitions,
+			FirstFailureOnly:     settings.FirstFailureOnly,
+			Template:             settings.Template,
+			DedupMinutes:         settings.DedupMinutes,
+			EscalateAfterMinutes: settings.EscalateAfterMinutes,
+		}
+	}
+	for _, item := range current.watchItems {
+		doc.Watchlist = append(doc.Watchlist, models.ConfigWatchItem{
+			AgentID:                item.AgentID,
+			SessionTopic:           item.SessionTopic,
+			NotificationWebhookURL: item.NotificationWebhookURL,
+			MuteNotifications:      item.MuteNotifications,
+		})
+	}
+	for _, sla := range current.slas {
+		doc.SLAs = append(doc.SLAs, models.ConfigSLA{
+			Name:               sla.Name,
+			AgentID:            sla.AgentID,
+			TopicPattern:       sla.TopicPattern,
+			MaxDurationMinutes: sla.MaxDurationMinutes,
+			MaxFailureRate:     sla.MaxFailureRate,
+		})
+	}
+
+	return doc, nil
+}
+
+// configPlan is the validated set of writes that make a user's configuration match a document
+type configPlan struct {
+	user        *models.User
+	settings    *models.NotificationSettings // nil deletes the user's settings
+	watchItems  []*models.WatchItem
+	unwatched   []*models.WatchItem
+	createdSLAs []*models.SLA
+	updatedSLAs []*models.SLA
+	deletedSLAs []*models.SLA
+}
+
+// planImport validates a document against the user's current configuration and returns the writes importing it takes
+func planImport(current *configState, doc *models.ConfigDocument, now time.Time) (*configPlan, error) {
+	user := current.user
+	notifications := doc.Notifications
+	webhookURL := strings.TrimSpace(notifications.WebhookURL)
+	if err := validateWebhookURL(webhookURL); err != nil {
+		return nil, models.Nested("notifications.webhook_url", "/notifications/webhook_url", models.Invalid("", err.Error()))
+	}
+	if err := validateMentionRules(notifications.Mentions, "notifications.mentions"); err != nil {
+		return nil, err
+	}
+	if err := validateDestinations(notifications.Destinations, "notifications.destinations"); err != nil {
+		return nil, err
+	}
+
+	updated := *user
+	updated.NotificationWebhookURL = webhookURL
+	updated.NotificationMentions = notifications.Mentions
+	updated.NotificationDestinations = notifications.Destinations
+	updated.SessionAutoClose = doc.SessionAutoClose
+	updated.UpdatedAt = now
+	plan := &configPlan{user: &updated}
+
+	if s := notifications.Settings; s != nil {
+		plan.settings = &models.NotificationSettings{
+			UserID:               user.ID,
+			WebhookURL:           strings.TrimSpace(s.WebhookURL),
+			Format:               s.Format,
+			Transitions:          s.Transitions,
+			FirstFailureOnly:     s.FirstFailureOnly,
+			Template:             s.Template,
+			DedupMinutes:         s.DedupMinutes,
+			EscalateAfterMinutes: s.EscalateAfterMinutes,
+			CreatedAt:            now,
+			UpdatedAt:            now,
+		}
+		if err := plan.settings.Validate(); err != nil {
+			return nil, models.Nested("notifications.settings", "/notifications/settings", err)
+		}
+		if current.settings != nil {
+			plan.settings.CreatedAt = current.settings.CreatedAt
+		}
+	}
+
+	existingItems := current.watchItems
+	created := make(map[string]time.Time, len(existingItems))
+	for _, item := range existingItems {
+		created[item.AgentID+"\x00"+item.SessionTopic] = item.CreatedAt
+	}
+	for i, entry := range doc.Watchlist {
+		item := &models.WatchItem{
+			UserID:                 user.ID,
+			AgentID:                entry.AgentID,
+			SessionTopic:           entry.SessionTopic,
+			NotificationWebhookURL: strings.TrimSpace(entry.NotificationWebhookURL),
+			MuteNotifications:      entry.MuteNotifications,
+			CreatedAt:              now,
+			UpdatedAt:              now,
+		}
+		if err := item.Validate(); err != nil {
+			return nil, models.Nested(fmt.Sprintf("watchlist[%d]", i), fmt.Sprintf("/watchlist/%d", i), err)
+		}
+		if err := validateWebhookURL(item.NotificationWebhookURL); err != nil {
+			return nil, models.Nested(fmt.Sprintf("watchlist[%d]", i), fmt.Sprintf("/watchlist/%d", i), err)
+		}
+		key := item.AgentID + "\x00" + item.SessionTopic
+		if at, ok := created[key]; ok {
+			item.CreatedAt = at
+			delete(created, key)
+		}
+		plan.watchItems = append(plan.watchItems, item)
+	}
+	for _, item := range existingItems {
+		if _, ok := created[item.AgentID+"\x00"+item.SessionTopic]; ok {
+			plan.unwatched = append(plan.unwatched, item)
+		}
+	}
+
+	existingSLAs := current.slas
+	byName := make(map[string]*models.SLA, len(existingSLAs))
+	for _, sla := range existingSLAs {
+		if _, ok := byName[sla.Name]; !ok {
+			byName[sla.Name] = sla
+			continue
+		}
+		plan.deletedSLAs = append(plan.deletedSLAs, sla)
+	}
+	for i, entry := range doc.SLAs {
+		sla := &models.SLA{
+			ID:                 uuid.New().String(),
+			UserID:             user.ID,
+			Name:               entry.Name,
+			AgentID:            entry.AgentID,
+			TopicPattern:       entry.TopicPattern,
+			MaxDurationMinutes: entry.MaxDurationMinutes,
+			MaxFailureRate:     entry.MaxFailureRate,
+			CreatedAt:          now,
+			UpdatedAt:          now,
+		}
+		existing, ok := byName[sla.Name]
+		if ok {
+			sla.ID = existing.ID
+			sla.CreatedAt = existing.CreatedAt
+			delete(byName, sla.Name)
+		}
+		if err := sla.Validate(); err != nil {
+			return nil, models.Nested(fmt.Sprintf("slas[%d]", i), fmt.Sprintf("/slas/%d", i), err)
+		}
+		if ok {
+			plan.updatedSLAs = append(plan.updatedSLAs, sla)
+		} else {
+			plan.createdSLAs = append(plan.createdSLAs, sla)
+		}
+	}
+	for _, sla := range existingSLAs {
+		if byName[sla.Name] == sla {
+			plan.deletedSLAs = append(plan.deletedSLAs, sla)
+		}
+	}
+
+	return plan, nil
+}
+
+// apply makes the writes of a plan
+func (h *ConfigHandler) apply(plan *configPlan) error {
+	if err := h.store.UpdateUser(plan.user); err != nil {
+		return err
+	}
+
+	if plan.settings != nil {
+		if err := h.store.SaveNotificationSettings(plan.settings); err != nil {
+			return err
+		}
+	} else if err := h.store.DeleteNotificationSettings(plan.user.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	for _, item := range plan.watchItems {
+		if err := h.store.SaveWatchItem(item); err != nil {
+			return err
+		}
+	}
+	for _, item := range plan.unwatched {
+		if err := h.store.DeleteWatchItem(item.UserID, item.AgentID, item.SessionTopic); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+
+	for _, sla := range plan.deletedSLAs {
+		if err := h.store.DeleteSLA(sla.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	for _, sla := range plan.updatedSLAs {
+		if err := h.store.UpdateSLA(sla); err != nil {
+			return err
+		}
+	}
+	for _, sla := range plan.createdSLAs {
+		if err := h.store.CreateSLA(sla); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondDocument writes a configuration document as YAML or JSON, encrypted when key is set
+func (h *ConfigHandler) respondDocument(w http.ResponseWriter, asYAML bool, doc *models.ConfigDocument, key encryption.ExportKey) {
+	contentType, marshal := "application/json", json.Marshal
+	if asYAML {
+		contentType, marshal = "application/yaml", marshalYAML
+	}
+	body, err := marshal(doc)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to encode configuration")
+		return
+	}
+	respondExport(w, key, contentType, "", body)
+}
+
+// wantsYAML reports whether a format parameter or media type header asks for YAML
+func wantsYAML(format, mediaType string) bool {
+	if format != "" {
+		return format == "yaml"
+	}
+	return strings.Contains(mediaType, "yaml")
+}
+
+// marshalYAML renders v as YAML with the field names and order of its JSON encoding
+func marshalYAML(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is YAML, and a MapSlice keeps the keys in order
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+// yamlToJSON converts a YAML document to JSON, so it decodes with the JSON field names
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue(doc))
+}
+
+// jsonValue replaces the maps YAML decodes to, keyed by any value, with maps JSON can encode
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/encryption"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// storeWithConfig returns a store whose default test user has configuration in every section of the document
+func storeWithConfig(t *testing.T) *store.MemoryStore {
+	t.Helper()
+
+	st := testsupport.StoreWithAgents(t, 1, 1)
+	now := time.Now().UTC()
+	user, _ := st.GetUserByID(testsupport.UserID)
+	user.NotificationWebhookURL = "https://hooks.example.com/profile"
+	user.NotificationMentions = []models.MentionRule{{AgentID: "agent-001", ChatUserID: "U123"}}
+	user.NotificationDestinations = []models.NotificationDestination{{URL: "https://hooks.example.com/agents/{{.AgentID}}", Format: "generic"}}
+	user.SessionAutoClose = models.SessionAutoClose{OnDelete: models.SessionCloseFail}
+	if err := st.UpdateUser(user); err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	if err := st.SaveNotificationSettings(&models.NotificationSettings{UserID: testsupport.UserID, Format: "slack",
+		Transitions: []models.StatusTransition{{From: "*", To: "failed"}}, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("SaveNotificationSettings() error = %v", err)
+	}
+	if err := st.SaveWatchItem(&models.WatchItem{UserID: testsupport.UserID, AgentID: "agent-001", MuteNotifications: true, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("SaveWatchItem() error = %v", err)
+	}
+	if err := st.CreateSLA(&models.SLA{ID: "sla-1", UserID: testsupport.UserID, Name: "Deploys", TopicPattern: "^deploy",
+		MaxDurationMinutes: 30, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateSLA() error = %v", err)
+	}
+	return st
+}
+
+func exportConfig(t *testing.T, handler *ConfigHandler, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler.Export(rr, testsupport.WithUser(httptest.NewRequest("GET", "/api/config/export"+query, nil)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Export(%s) status = %v, body = %s", query, rr.Code, rr.Body.String())
+	}
+	return rr
+}
+
+func importConfig(handler *ConfigHandler, contentType, body string) *httptest.ResponseRecorder {
+	req := testsupport.WithUser(httptest.NewRequest("PUT", "/api/config/export", bytes.NewBufferString(body)))
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	handler.Import(rr, req)
+	return rr
+}
+
+func TestConfigHandler_RoundTrip(t *testing.T) {
+	st := storeWithConfig(t)
+	handler := NewConfigHandler(st)
+
+	var doc models.ConfigDocument
+	if err := json.Unmarshal(exportConfig(t, handler, "").Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	if doc.Version != models.ConfigDocumentVersion || doc.Notifications.WebhookURL != "https://hooks.example.com/profile" ||
+		len(doc.Notifications.Mentions) != 1 || len(doc.Notifications.Destinations) != 1 || doc.Notifications.Settings == nil ||
+		doc.Notifications.Settings.Format != "slack" || len(doc.Watchlist) != 1 || !doc.Watchlist[0].MuteNotifications ||
+		len(doc.SLAs) != 1 || doc.SLAs[0].Name != "Deploys" || doc.SessionAutoClose.OnDelete != models.SessionCloseFail {
+		t.Fatalf("Export() = %+v, want every section", doc)
+	}
+
+	rr := exportConfig(t, handler, "?format=yaml")
+	if ct := rr.Header().Get("Content-Type"); ct != "application/yaml" {
+		t.Errorf("Export(yaml) Content-Type = %q, want application/yaml", ct)
+	}
+	exported := rr.Body.String()
+	if !strings.Contains(exported, "slas:") || !strings.Contains(exported, "max_duration_minutes: 30") {
+		t.Errorf("Export(yaml) = %s, want the JSON field names", exported)
+	}
+
+	// Importing the export changes nothing and keeps the SLA, with its breaches
+	rr = importConfig(handler, "application/yaml", exported)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Import(yaml) status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+	if rr.Body.String() != exported {
+		t.Errorf("Import(yaml) = %s, want the export unchanged:\n%s", rr.Body.String(), exported)
+	}
+	if slas, _ := st.ListSLAsByUser(testsupport.UserID); len(slas) != 1 || slas[0].ID != "sla-1" {
+		t.Errorf("SLAs after import = %+v, want sla-1 kept", slas)
+	}
+}
+
+func TestConfigHandler_ImportReplaces(t *testing.T) {
+	st := storeWithConfig(t)
+	handler := NewConfigHandler(st)
+
+	body := `{
+		"version": 1,
+		"notifications": {"destinations": [{"url": "https://hooks.slack.com/services/T/B/X"}]},
+		"watchlist": [{"agent_id": "agent-001", "session_topic": "task-001"}],
+		"slas": [
+			{"name": "Deploys", "topic_pattern": "^deploy", "max_duration_minutes": 45},
+			{"name": "Failures", "max_failure_rate": 0.2}
+		]
+	}`
+	rr := importConfig(handler, "application/json", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Import() status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+
+	user, _ := st.GetUserByID(testsupport.UserID)
+	if user.NotificationWebhookURL != "" || len(user.NotificationMentions) != 0 || len(user.NotificationDestinations) != 1 ||
+		user.SessionAutoClose != (models.SessionAutoClose{}) {
+		t.Errorf("user after import = %+v, want only the destination", user)
+	}
+	if _, err := st.GetNotificationSettings(testsupport.UserID); err == nil {
+		t.Error("notification settings kept, want them deleted with the section left out")
+	}
+	items, _ := st.ListWatchItems(testsupport.UserID)
+	if len(items) != 1 || items[0].SessionTopic != "task-001" {
+		t.Errorf("watchlist after import = %+v, want only task-001", items)
+	}
+	slas, _ := st.ListSLAsByUser(testsupport.UserID)
+	got := make(map[string]*models.SLA)
+	for _, sla := range slas {
+		got[sla.Name] = sla
+	}
+	if len(slas) != 2 || got["Deploys"] == nil || got["Deploys"].ID != "sla-1" || got["Deploys"].MaxDurationMinutes != 45 || got["Failures"] == nil {
+		t.Errorf("SLAs after import = %+v, want Deploys updated in place and Failures created", slas)
+	}
+}
+
+func TestConfigHandler_ImportRejects(t *testing.T) {
+	st := storeWithConfig(t)
+	handler := NewConfigHandler(st)
+	before, _ := json.Marshal(exportDocument(t, handler))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"unknown field", "application/json", `{"version":1,"notifications":{"webhook":"https://example.com"}}`},
+		{"wrong version", "application/json", `{"version":2}`},
+		{"duplicate SLA names", "application/json", `{"version":1,"slas":[{"name":"A","max_failure_rate":0.1},{"name":"A","max_failure_rate":0.2}]}`},
+		{"invalid SLA", "application/json", `{"version":1,"slas":[{"name":"A"}]}`},
+		{"invalid transition", "application/json", `{"version":1,"notifications":{"settings":{"transitions":[{"from":"running","to":"done"}]}}}`},
+		{"invalid auto close", "application/json", `{"version":1,"session_auto_close":{"on_delete":"archive"}}`},
+		{"invalid YAML", "application/yaml", "version: [1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := importConfig(handler, tt.contentType, tt.body); rr.Code != http.StatusBadRequest {
+				t.Errorf("Import() status = %v, want %v, body = %s", rr.Code, http.StatusBadRequest, rr.Body.String())
+			}
+		})
+	}
+
+	// Nothing is changed by a refused document
+	if after, _ := json.Marshal(exportDocument(t, handler)); !reflect.DeepEqual(after, before) {
+		t.Errorf("configuration after refused imports = %s, want %s", after, before)
+	}
+}
+
+func exportDocument(t *testing.T, handler *ConfigHandler) *models.ConfigDocument {
+	t.Helper()
+	var doc models.ConfigDocument
+	if err := json.Unmarshal(exportConfig(t, handler, "").Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to decode export: %v", err)
+	}
+	return &doc
+}
+
+func TestConfigHandler_EncryptedExport(t *testing.T) {
+	st := storeWithConfig(t)
+	handler := NewConfigHandler(st)
+
+	// A passphrase header encrypts the download
+	req := testsupport.WithUser(httptest.NewRequest("GET", "/api/config/export?format=yaml", nil))
+	req.Header.Set(ExportPassphraseHeader, "correct horse battery")
+	rr := httptest.NewRecorder()
+	handler.Export(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != EncryptedExportContentType {
+		t.Fatalf("Export() with a passphrase = %v %q, want an encrypted export", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	plaintext, contentType, err := encryption.OpenExport(rr.Body.Bytes(), nil, "correct horse battery")
+	if err != nil || contentType != "application/yaml" || !strings.Contains(string(plaintext), "slas:") {
+		t.Errorf("OpenExport() = %s, %q, %v, want the YAML document", plaintext, contentType, err)
+	}
+
+	req = testsupport.WithUser(httptest.NewRequest("GET", "/api/config/export", nil))
+	req.Header.Set(ExportPassphraseHeader, "short")
+	rr = httptest.NewRecorder()
+	handler.Export(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Export() with a short passphrase status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	// Without one, exports are encrypted to the account's public key
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	user, _ := st.GetUserByID(testsupport.UserID)
+	user.ExportPublicKey = base64.StdEncoding.EncodeToString(publicKey[:])
+	if err := st.UpdateUser(user); err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	rr = exportConfig(t, handler, "")
+	plaintext, _, err = encryption.OpenExport(rr.Body.Bytes(), privateKey, "")
+	var doc models.ConfigDocument
+	if err != nil || json.Unmarshal(plaintext, &doc) != nil || len(doc.SLAs) != 1 {
+		t.Errorf("OpenExport() = %s, %v, want the JSON document", plaintext, err)
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+const (
+	// defaultEnrollmentTTL is how long an enrollment token stays redeemable when the request sets no expiry
+	defaultEnrollmentTTL = time.Hour
+	// enrollmentTokenPrefix marks enrollment tokens so they are not mistaken for API keys
+	enrollmentTokenPrefix = "kae_"
+)
+
+// EnrollmentHandler handles enrollment token management endpoints
+type EnrollmentHandler struct {
+	store store.Store
+	clock clock.Clock
+
+	listFormat
+}
+
+// NewEnrollmentHandler creates a new enrollment token handler
+func NewEnrollmentHandler(st store.Store) *EnrollmentHandler {
+	return &EnrollmentHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps enrollment tokens and decides their expiry
+func (h *EnrollmentHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// CreateEnrollmentTokenRequest represents a request to create an enrollment token
+type CreateEnrollmentTokenRequest struct {
+	Name             string `json:"name"`
+	AgentID          string `json:"agent_id,omitempty"`           // Only this agent may enroll with the token
+	ExpiresInMinutes *int   `json:"expires_in_minutes,omitempty"` // Defaults to 60, at most 7 days
+}
+
+// CreateEnrollmentTokenResponse represents the response when creating an enrollment token
+// The raw token is only returned once at creation time
+type CreateEnrollmentTokenResponse struct {
+	*models.EnrollmentToken
+	Token string `json:"token"`
+}
+
+// Create handles enrollment token creation
+func (h *EnrollmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	var req CreateEnrollmentTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ttl := defaultEnrollmentTTL
+	if req.ExpiresInMinutes != nil {
+		ttl = time.Duration(*req.ExpiresInMinutes) * time.Minute
+		if ttl <= 0 || ttl > models.MaxEnrollmentTokenTTL {
+			respondError(w, http.StatusBadRequest, "expires_in_minutes must be 1-10080")
+			return
+		}
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to generate enrollment token")
+		return
+	}
+	rawToken := enrollmentTokenPrefix + key
+
+	now := h.clock.Now().UTC()
+	token := &models.EnrollmentToken{
+		ID:          uuid.New().String(),
+		UserID:      caller.UserID,
+		Name:        req.Name,
+		TokenHash:   middleware.HashAPIKey(rawToken),
+		TokenPrefix: rawToken[:8],
+		AgentID:     req.AgentID,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	}
+
+	if err := token.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.CreateEnrollmentToken(token); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to create enrollment token")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, CreateEnrollmentTokenResponse{EnrollmentToken: token, Token: rawToken})
+}
+
+// List handles listing enrollment tokens for the current user
+func (h *EnrollmentHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tokens, err := h.store.ListEnrollmentTokensByUser(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list enrollment tokens")
+		return
+	}
+
+	respondList(w, r, page, h.legacyKey("enrollment_tokens"), tokens, nil)
+}
+
+// Delete handles removing an enrollment token; agent tokens already issued with it keep working
+func (h *EnrollmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	if err := h.store.DeleteEnrollmentToken(caller.UserID, chi.URLParam(r, "id")); err != nil {
+		respondStoreError(w, err, "enrollment token not found", "failed to delete enrollment token")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "enrollment token deleted successfully",
+	})
+}
+
+// EnrollResponse is returned when an agent exchanges its enrollment token
+// The agent token is only shown once; the agent reports with it from then on.
+type EnrollResponse struct {
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	AgentID    string `json:"agent_id"`
+	KeyID      string `json:"key_id"`
+	AgentToken string `json:"agent_token"`
+}
+
+// ServeEnroll handles POST /webhook/enroll requests
+// The agent sends its first status report authenticated with an enrollment token. The token is redeemed
+// for an agent token, an API key that may only report for this agent, and the report is then processed.
+func (h *WebhookHandler) ServeEnroll(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok || caller.EnrollmentTokenID == "" {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Enrollment token required")
+		return
+	}
+
+	statusReport, ok := h.decodeStatusReport(w, r, caller, h.payloadLimitsFor(caller))
+	if !ok {
+		return
+	}
+
+	// An enrollment token cannot claim an agent that already reports for someone else, unless it is shared
+	// with the token owner's organization and they may manage it
+	if agent, err := h.store.GetAgent(statusReport.AgentID); err == nil && !canManageAgent(h.store, caller.UserID, agent) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Credential is not authorized for this agent")
+		return
+	}
+
+	rawToken, err := generateAPIKey()
+	if err != nil {
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to generate agent token")
+		return
+	}
+	agentToken := &models.APIKey{
+		ID:        uuid.New().String(),
+		UserID:    caller.UserID,
+		Name:      statusReport.AgentID,
+		KeyHash:   middleware.HashAPIKey(rawToken),
+		KeyPrefix: rawToken[:8],
+		AgentID:   statusReport.AgentID,
+		CreatedAt: h.now(),
+	}
+	if err := h.store.RedeemEnrollmentToken(caller.EnrollmentTokenID, agentToken); err != nil {
+		if errors.Is(err, store.ErrConflict) || errors.Is(err, store.ErrNotFound) {
+			h.respondError(w, http.StatusUnauthorized, "unauthorized", "Enrollment token was already used or has expired")
+			return
+		}
+		log.Printf("Error redeeming enrollment token %s: %v", caller.EnrollmentTokenID, err)
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to enroll agent")
+		return
+	}
+
+	resp := EnrollResponse{
+		Success:    true,
+		Message:    "Agent enrolled and status reported successfully",
+		AgentID:    statusReport.AgentID,
+		KeyID:      agentToken.ID,
+		AgentToken: rawToken,
+	}
+	// The token is spent, so hand out the agent token even when the report itself fails
+	if err := h.processStatusReport(statusReport, caller.UserID); err != nil {
+		log.Printf("Error processing enrollment status report: %v", err)
+		resp.Success = false
+		resp.Message = "Agent enrolled but the status report failed, resend it with the agent token"
+	}
+	respondJSON(w, http.StatusCreated, resp)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestEnrollmentHandler_Create(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewEnrollmentHandler(st)
+
+	create := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/enrollment-tokens", bytes.NewReader([]byte(body)))
+		req = testsupport.WithUser(req)
+		rr := httptest.NewRecorder()
+		handler.Create(rr, req)
+		return rr
+	}
+
+	for _, body := range []string{`{"name":""}`, `{"name":"fleet","expires_in_minutes":0}`, `{"name":"fleet","expires_in_minutes":10081}`} {
+		if rr := create(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("Create(%s) status = %v, want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	rr := create(`{"name":"fleet","agent_id":"agent-001"}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Create() status = %v, want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var resp struct {
+		ID          string    `json:"id"`
+		Token       string    `json:"token"`
+		TokenPrefix string    `json:"token_prefix"`
+		AgentID     string    `json:"agent_id"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Token[:8] != resp.TokenPrefix || resp.AgentID != "agent-001" || time.Until(resp.ExpiresAt) > time.Hour {
+		t.Errorf("Create() = %+v, want a token for agent-001 expiring within an hour", resp)
+	}
+
+	token, err := st.GetEnrollmentTokenByHash(middleware.HashAPIKey(resp.Token))
+	if err != nil || token.ID != resp.ID || token.UserID != testsupport.UserID {
+		t.Errorf("GetEnrollmentTokenByHash() = %+v, %v, want the created token", token, err)
+	}
+}
+
+// enrollRequest posts a first status report as the holder of an enrollment token
+func enrollRequest(handler *WebhookHandler, tokenID, scopedAgentID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/webhook/enroll", bytes.NewReader([]byte(body)))
+	caller := middleware.NewRequestContext(req, testsupport.UserID, testsupport.UserEmail, nil)
+	caller.EnrollmentTokenID = tokenID
+	caller.ScopedAgentID = scopedAgentID
+	req = req.WithContext(middleware.WithRequestContext(req.Context(), caller))
+	rr := httptest.NewRecorder()
+	handler.ServeEnroll(rr, req)
+	return rr
+}
+
+func TestWebhookHandler_ServeEnroll(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	now := time.Now()
+	for _, token := range []*models.EnrollmentToken{
+		{ID: "enroll-1", Name: "fleet", TokenHash: "hash-1", TokenPrefix: "kae_1111"},
+		{ID: "enroll-2", Name: "runner", TokenHash: "hash-2", TokenPrefix: "kae_2222", AgentID: "agent-002"},
+	} {
+		token.UserID = testsupport.UserID
+		token.ExpiresAt = now.Add(time.Hour)
+		token.CreatedAt = now
+		if err := st.CreateEnrollmentToken(token); err != nil {
+			t.Fatalf("CreateEnrollmentToken() error = %v", err)
+		}
+	}
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	report := `{"agent_id":"agent-001","session_topic":"task-001","status":"running","timestamp":"2026-01-15T10:30:00Z"}`
+
+	rr := enrollRequest(handler, "enroll-1", "", report)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("ServeEnroll() status = %v, want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	var resp EnrollResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.Success || resp.AgentID != "agent-001" || resp.AgentToken == "" {
+		t.Errorf("ServeEnroll() = %+v, want a successful enrollment of agent-001", resp)
+	}
+
+	key, err := st.GetAPIKeyByHash(middleware.HashAPIKey(resp.AgentToken))
+	if err != nil || key.ID != resp.KeyID || key.AgentID != "agent-001" || key.UserID != testsupport.UserID {
+		t.Errorf("GetAPIKeyByHash() = %+v, %v, want an agent token restricted to agent-001", key, err)
+	}
+	if _, err := st.GetLatestStatus("agent-001", "task-001"); err != nil {
+		t.Errorf("GetLatestStatus() error = %v, want the first report stored", err)
+	}
+
+	if rr := enrollRequest(handler, "enroll-1", "", report); rr.Code != http.StatusUnauthorized {
+		t.Errorf("ServeEnroll() reused token status = %v, want %v", rr.Code, http.StatusUnauthorized)
+	}
+	if rr := enrollRequest(handler, "enroll-2", "agent-002", report); rr.Code != http.StatusForbidden {
+		t.Errorf("ServeEnroll() other agent status = %v, want %v", rr.Code, http.StatusForbidden)
+	}
+	if token, _ := st.GetEnrollmentTokenByHash("hash-2"); token.UsedAt != nil {
+		t.Error("ServeEnroll() redeemed a token restricted to another agent")
+	}
+}
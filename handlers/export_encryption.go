@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/encryption"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// ExportPassphraseHeader carries a passphrase to encrypt a download with instead of the account's export key
+const ExportPassphraseHeader = "X-Export-Passphrase"
+
+// EncryptedExportContentType is the media type of encrypted downloads, the JSON envelopes of encryption.SealExport
+const EncryptedExportContentType = "application/vnd.kubeagents.encrypted-export+json"
+
+// exportKey returns the key a download of userID is encrypted with: the request's passphrase, else the public key
+// registered on the account; a zero key leaves the download in the clear
+// It writes the error response itself and reports whether the request may proceed.
+func exportKey(w http.ResponseWriter, r *http.Request, st store.Store, userID string) (encryption.ExportKey, bool) {
+	if passphrase := r.Header.Get(ExportPassphraseHeader); passphrase != "" {
+		if err := encryption.ValidatePassphrase(passphrase); err != nil {
+			respondError(w, http.StatusBadRequest, err.Error())
+			return encryption.ExportKey{}, false
+		}
+		return encryption.ExportKey{Passphrase: passphrase}, true
+	}
+
+	user, err := st.GetUserByID(userID)
+	if err != nil {
+		respondStoreError(w, err, "user not found", "failed to load export key")
+		return encryption.ExportKey{}, false
+	}
+	if user.ExportPublicKey == "" {
+		return encryption.ExportKey{}, true
+	}
+	publicKey, err := encryption.ParsePublicKey(user.ExportPublicKey)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "invalid export key")
+		return encryption.ExportKey{}, false
+	}
+	return encryption.ExportKey{PublicKey: publicKey}, true
+}
+
+// respondExport writes a download of contentType, sealed in an envelope when key is set
+// A non-empty filename names the download, with ".enc.json" appended when it is encrypted.
+func respondExport(w http.ResponseWriter, key encryption.ExportKey, contentType, filename string, body []byte) {
+	if !key.IsZero() {
+		sealed, err := encryption.SealExport(body, contentType, key)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to encrypt export")
+			return
+		}
+		body, contentType = sealed, EncryptedExportContentType
+		if filename != "" {
+			filename += ".enc.json"
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if filename != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Fields selectable with ?fields= on agent and session endpoints
+var (
+	agentFields = []string{
+		"agent_id", "user_id", "name", "source", "kind", "registered", "last_seen", "version",
+		"heartbeat_sample_every", "session_count", "active_session_count", "latest_status", "latest_message",
+		"sla_compliance", "starred", "health_score", "state", "state_changed_at", "cluster", "region",
+	}
+	sessionFields = []string{
+		"agent_id", "session_topic", "created", "last_updated", "expired", "expired_at",
+		"ttl_minutes", "group", "category", "version", "current_status", "watched",
+	}
+	sessionDetailFields = []string{
+		"agent_id", "session_topic", "created", "last_updated", "expired", "expired_at",
+		"ttl_minutes", "group", "category", "version", "status_history",
+	}
+)
+
+// fieldSelection is the set of response fields requested with ?fields=; nil selects every field
+type fieldSelection map[string]bool
+
+// parseFields parses a comma-separated field list, rejecting names not in allowed
+func parseFields(raw string, allowed []string) (fieldSelection, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	known := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		known[name] = true
+	}
+
+	fields := make(fieldSelection)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown field: %s", name)
+		}
+		fields[name] = true
+	}
+	return fields, nil
+}
+
+// has reports whether any of the named fields is selected, so callers can skip computing the rest
+func (f fieldSelection) has(names ...string) bool {
+	if f == nil {
+		return true
+	}
+	for _, name := range names {
+		if f[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// project reduces v to the selected top-level JSON fields
+func (f fieldSelection) project(v interface{}) (interface{}, error) {
+	if f == nil {
+		return v, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var full map[string]json.RawMessage
+	if err := json.Unmarshal(data, &full); err != nil {
+		return nil, err
+	}
+	for name := range full {
+		if !f[name] {
+			delete(full, name)
+		}
+	}
+	return full, nil
+}
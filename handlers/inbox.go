@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/inbox"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Limits for listing inbox items
+const (
+	defaultInboxLimit = 50
+	maxInboxLimit     = 200
+)
+
+// InboxHandler handles the in-app notification inbox
+type InboxHandler struct {
+	store store.Store
+	inbox *inbox.Inbox
+}
+
+// NewInboxHandler creates a new inbox handler
+func NewInboxHandler(st store.Store, b *inbox.Inbox) *InboxHandler {
+	return &InboxHandler{
+		store: st,
+		inbox: b,
+	}
+}
+
+// List handles listing the current user's inbox, newest first
+func (h *InboxHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, defaultInboxLimit, maxInboxLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	items, total, err := h.store.ListInboxItemsPage(caller.UserID, unreadOnly, page.store())
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list inbox")
+		return
+	}
+
+	unread, err := h.store.CountUnreadInboxItems(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list inbox")
+		return
+	}
+
+	respondPage(w, r, page, "items", items, total, map[string]interface{}{
+		"unread_count": unread,
+	})
+}
+
+// MarkRead handles marking one inbox item as read
+func (h *InboxHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	if err := h.store.MarkInboxItemRead(caller.UserID, chi.URLParam(r, "id")); err != nil {
+		respondStoreError(w, err, "inbox item not found", "failed to mark inbox item read")
+		return
+	}
+
+	unread, err := h.store.CountUnreadInboxItems(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to count unread inbox items")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]int{
+		"unread_count": unread,
+	})
+}
+
+// MarkAllRead handles marking every inbox item as read
+func (h *InboxHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	marked, err := h.store.MarkAllInboxItemsRead(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to mark inbox items read")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]int{
+		"marked":       marked,
+		"unread_count": 0,
+	})
+}
+
+// Stream handles GET /api/inbox/stream, sending new items as server-sent events
+// The stream opens with an "unread" event carrying the unread count, then sends a
+// "notification" event per new item until the client disconnects.
+func (h *InboxHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	// Subscribe before counting so nothing published after the count is missed
+	items, unsubscribe := h.inbox.Subscribe(caller.UserID)
+	defer unsubscribe()
+
+	unread, err := h.store.CountUnreadInboxItems(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to count unread inbox items")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	writeEvent(w, "unread", map[string]int{"unread_count": unread})
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case item := <-items:
+			writeEvent(w, "notification", item)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/inbox"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestInboxHandler_ListAndMarkRead(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewInboxHandler(st, inbox.New(st, 0))
+
+	now := time.Now()
+	for i, id := range []string{"item-1", "item-2"} {
+		st.CreateInboxItem(&models.InboxItem{
+			ID:        id,
+			UserID:    testsupport.UserID,
+			Kind:      models.InboxKindFailure,
+			AgentID:   "agent-001",
+			DedupeKey: id,
+			CreatedAt: now.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	handler.List(rr, testsupport.WithUser(httptest.NewRequest("GET", "/api/inbox?limit=1", nil)))
+
+	var listed struct {
+		Items       []*models.InboxItem `json:"items"`
+		Total       int                 `json:"total"`
+		NextCursor  *string             `json:"next_cursor"`
+		UnreadCount int                 `json:"unread_count"`
+	}
+	json.NewDecoder(rr.Body).Decode(&listed)
+	if len(listed.Items) != 1 || listed.Items[0].ID != "item-2" || listed.Total != 2 || listed.UnreadCount != 2 || listed.NextCursor == nil {
+		t.Fatalf("List() = %d items (first %v) of %d, unread %d, want item-2 of 2, unread 2 and a next page", len(listed.Items), listed.Items, listed.Total, listed.UnreadCount)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.List(rr, testsupport.WithUser(httptest.NewRequest("GET", "/api/inbox?limit=1&cursor="+*listed.NextCursor, nil)))
+	listed.Items, listed.NextCursor = nil, nil
+	json.NewDecoder(rr.Body).Decode(&listed)
+	if len(listed.Items) != 1 || listed.Items[0].ID != "item-1" || listed.NextCursor != nil {
+		t.Errorf("List() second page = %v, next cursor %v, want only item-1", listed.Items, listed.NextCursor)
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{"own item", "item-1", http.StatusOK},
+		{"unknown item", "item-404", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.id)
+			req := testsupport.WithUser(httptest.NewRequest("POST", "/api/inbox/"+tt.id+"/read", nil))
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			handler.MarkRead(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("MarkRead() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	if count, _ := st.CountUnreadInboxItems(testsupport.UserID); count != 1 {
+		t.Errorf("MarkRead() left %d unread, want 1", count)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.MarkAllRead(rr, testsupport.WithUser(httptest.NewRequest("POST", "/api/inbox/read", nil)))
+	if count, _ := st.CountUnreadInboxItems(testsupport.UserID); rr.Code != http.StatusOK || count != 0 {
+		t.Errorf("MarkAllRead() status = %v, unread = %d, want 200 and 0", rr.Code, count)
+	}
+}
+
+func TestInboxHandler_Stream(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	b := inbox.New(st, 0)
+	handler := NewInboxHandler(st, b)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.Stream(w, testsupport.WithUser(r))
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET stream error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Stream() Content-Type = %q, want text/event-stream", ct)
+	}
+
+	events := bufio.NewReader(resp.Body)
+	readEvent := func() (string, string) {
+		t.Helper()
+		var event, data string
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case line == "":
+				return event, data
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	if event, data := readEvent(); event != "unread" || data != `{"unread_count":0}` {
+		t.Errorf("Stream() first event = %s %s, want unread {\"unread_count\":0}", event, data)
+	}
+
+	b.Publish(&models.InboxItem{
+		UserID:       testsupport.UserID,
+		Kind:         models.InboxKindFailure,
+		AgentID:      "agent-001",
+		SessionTopic: "task-003",
+		Message:      "Session task-003 of Test Agent failed",
+		DedupeKey:    "failure|agent-001|task-003",
+	})
+
+	event, data := readEvent()
+	if event != "notification" || !strings.Contains(data, `"session_topic":"task-003"`) {
+		t.Errorf("Stream() event = %s %s, want notification for task-003", event, data)
+	}
+}
+
+func TestWebhookHandler_FailureRecordedInInbox(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetInbox(inbox.New(st, 0))
+
+	for _, status := range []string{"running", "failed", "failed"} {
+		body := `{"agent_id":"agent-001","session_topic":"task-001","status":"` + status + `","message":"disk full","timestamp":"` + time.Now().Format(time.RFC3339) + `"}`
+		req := testsupport.WithUser(httptest.NewRequest("POST", "/webhook/status", strings.NewReader(body)))
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("ServeHTTP() status = %v, want %v, body = %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+	}
+
+	items, _ := st.ListInboxItems(testsupport.UserID, false, 0)
+	if len(items) != 1 {
+		t.Fatalf("ServeHTTP() recorded %d inbox items, want 1", len(items))
+	}
+	if items[0].Kind != models.InboxKindFailure || !strings.Contains(items[0].Message, "disk full") {
+		t.Errorf("ServeHTTP() inbox item = %+v, want failure mentioning disk full", items[0])
+	}
+}
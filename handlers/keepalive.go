@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// KeepaliveRequest extends a session's TTL without reporting a status
+type KeepaliveRequest struct {
+	AgentID      string `json:"agent_id"`
+	SessionTopic string `json:"session_topic"`
+	TTLMinutes   int    `json:"ttl_minutes,omitempty"` // Replaces the session's TTL when set
+}
+
+// KeepaliveResponse tells the agent until when the session stays open
+type KeepaliveResponse struct {
+	Success    bool      `json:"success"`
+	TTLMinutes int       `json:"ttl_minutes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+
+	// The agent's configuration, when its owner set one
+	Config        json.RawMessage `json:"config,omitempty"`
+	ConfigVersion int             `json:"config_version,omitempty"`
+}
+
+// errSessionEnded is returned by keepalive for sessions that already expired
+var errSessionEnded = errors.New("session has ended")
+
+// ServeKeepalive handles POST /webhook/keepalive requests
+// The session's last_updated and the agent's last_seen move to now, but no status is recorded,
+// so long-running agents stay alive without filling the history with heartbeats.
+func (h *WebhookHandler) ServeKeepalive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req KeepaliveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+		return
+	}
+	if req.AgentID == "" || req.SessionTopic == "" {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "agent_id and session_topic are required")
+		return
+	}
+	if req.TTLMinutes < 0 || req.TTLMinutes > 1440 {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "ttl_minutes must be 0 or 1-1440")
+		return
+	}
+
+	if !caller.MayReportFor(req.AgentID) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Credential is not authorized for this agent")
+		return
+	}
+
+	agent, session, err := h.keepalive(&req, caller.UserID, h.now())
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		h.respondError(w, http.StatusNotFound, "not_found", "Session not found, report a status to start it")
+		return
+	case errors.Is(err, errSessionEnded):
+		h.respondError(w, http.StatusConflict, "session_ended", "Session has ended, report a status to start a new run")
+		return
+	case errors.Is(err, store.ErrConflict):
+		h.respondError(w, http.StatusConflict, "conflict", "Agent or session was modified concurrently, retry the keepalive")
+		return
+	case storeErrorStatus(err) == http.StatusServiceUnavailable:
+		log.Printf("Store unavailable processing keepalive: %v", err)
+		h.respondError(w, http.StatusServiceUnavailable, "unavailable", "Store is temporarily unavailable, retry the keepalive")
+		return
+	case err != nil:
+		log.Printf("Error processing keepalive: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to extend session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(KeepaliveResponse{
+		Success:    true,
+		TTLMinutes: session.TTLMinutes,
+		ExpiresAt:  session.ExpiresAt(),
+
+		Config:        agent.Config,
+		ConfigVersion: agent.ConfigVersion,
+	})
+}
+
+// keepalive refreshes the caller's agent and its open session, re-reading them when a concurrent write wins
+func (h *WebhookHandler) keepalive(req *KeepaliveRequest, userID string, now time.Time) (*models.Agent, *models.Session, error) {
+	var agent *models.Agent
+	var err error
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		agent, err = h.store.GetAgent(req.AgentID)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Agents the caller may not report for are indistinguishable from missing ones
+		if !canManageAgent(h.store, userID, agent) {
+			return nil, nil, store.ErrNotFound
+		}
+		agent.MarkSeen(now)
+		if err = h.store.CreateOrUpdateAgent(agent); errors.Is(err, store.ErrConflict) {
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+		break
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		var session *models.Session
+		session, err = h.store.GetSession(req.AgentID, req.SessionTopic)
+		if err != nil {
+			return nil, nil, err
+		}
+		if session.Expired {
+			return nil, nil, errSessionEnded
+		}
+		session.LastUpdated = now
+		if req.TTLMinutes > 0 {
+			session.TTLMinutes = req.TTLMinutes
+		}
+		if err = h.store.CreateOrUpdateSession(session); !errors.Is(err, store.ErrConflict) {
+			return agent, session, err
+		}
+	}
+	return nil, nil, err
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func sendKeepalive(handler *WebhookHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/webhook/keepalive", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req = testsupport.WithUser(req)
+	rr := httptest.NewRecorder()
+	handler.ServeKeepalive(rr, req)
+	return rr
+}
+
+func TestWebhookHandler_Keepalive(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	st := store.NewMemoryStore()
+	st.SetClock(fake)
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetClock(fake)
+
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", start, "", "")
+
+	fake.Advance(20 * time.Minute)
+	rr := sendKeepalive(handler, `{"agent_id":"agent-001","session_topic":"task-001","ttl_minutes":60}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("keepalive status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var resp KeepaliveResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if want := fake.Now().Add(time.Hour); resp.TTLMinutes != 60 || !resp.ExpiresAt.Equal(want) {
+		t.Errorf("response = %+v, want ttl 60 expiring at %v", resp, want)
+	}
+
+	// The session outlives its original TTL without a status being recorded
+	fake.Advance(20 * time.Minute)
+	if expired := st.CheckExpiredSessions(); len(expired) != 0 {
+		t.Errorf("CheckExpiredSessions() = %d sessions, want none after the keepalive", len(expired))
+	}
+	history, _ := st.GetStatusHistory("agent-001", "task-001")
+	if len(history) != 1 {
+		t.Errorf("status history = %d records, want 1", len(history))
+	}
+	agent, _ := st.GetAgent("agent-001")
+	if want := start.Add(20 * time.Minute); !agent.LastSeen.Equal(want) {
+		t.Errorf("agent last seen = %v, want %v", agent.LastSeen, want)
+	}
+
+	fake.Advance(time.Hour)
+	st.CheckExpiredSessions()
+	rr = sendKeepalive(handler, `{"agent_id":"agent-001","session_topic":"task-001"}`)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("keepalive on an ended session status = %v, want %v", rr.Code, http.StatusConflict)
+	}
+}
+
+func TestWebhookHandler_KeepaliveRejects(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", time.Now(), "", "")
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"missing topic", `{"agent_id":"agent-001"}`, http.StatusBadRequest},
+		{"ttl too long", `{"agent_id":"agent-001","session_topic":"task-001","ttl_minutes":2000}`, http.StatusBadRequest},
+		{"unknown agent", `{"agent_id":"agent-404","session_topic":"task-001"}`, http.StatusNotFound},
+		{"unknown session", `{"agent_id":"agent-001","session_topic":"task-404"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rr := sendKeepalive(handler, tt.body); rr.Code != tt.want {
+				t.Errorf("status = %v, want %v: %s", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_KeepaliveOrgMembers(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	for orgID, role := range map[string]string{"org-members": models.OrgRoleMember, "org-viewers": models.OrgRoleViewer} {
+		testsupport.CreateOrg(t, st, orgID, map[string]string{"owner-1": models.OrgRoleOwner, testsupport.UserID: role})
+		agentID := "agent-" + orgID
+		testsupport.CreateAgent(t, st, agentID, testsupport.OwnedBy("owner-1"), testsupport.SharedWith(orgID))
+		testsupport.CreateSession(t, st, agentID, "task-001", testsupport.Status{Status: "running", At: time.Now()})
+	}
+
+	if rr := sendKeepalive(handler, `{"agent_id":"agent-org-members","session_topic":"task-001"}`); rr.Code != http.StatusOK {
+		t.Errorf("keepalive of an org member status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	if rr := sendKeepalive(handler, `{"agent_id":"agent-org-viewers","session_topic":"task-001"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("keepalive of an org viewer status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
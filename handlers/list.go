@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// maxListLimit caps the page size clients may request from collection endpoints
+const maxListLimit = 1000
+
+// listFormat is embedded by handlers whose collections predate the list envelope
+// Its zero value also returns items under each endpoint's pre-envelope key, e.g. "agents", until clients have
+// migrated to "items".
+type listFormat struct {
+	noLegacyKeys bool
+}
+
+// SetLegacyListKeys enables or disables the pre-envelope keys in the handler's collection responses
+func (f *listFormat) SetLegacyListKeys(enabled bool) {
+	f.noLegacyKeys = !enabled
+}
+
+// legacyKey returns the pre-envelope key to pass to respondList, or "" when legacy keys are disabled
+func (f *listFormat) legacyKey(key string) string {
+	if f.noLegacyKeys {
+		return ""
+	}
+	return key
+}
+
+// listPage selects one page of a collection from the limit and cursor query parameters
+type listPage struct {
+	offset int
+	limit  int // 0 returns every remaining item
+}
+
+// parseListPage reads the page parameters; defaultLimit applies when limit is omitted, 0 meaning all items
+func parseListPage(r *http.Request, defaultLimit, maxLimit int) (listPage, error) {
+	limit, err := parsePositiveInt(r.URL.Query().Get("limit"), defaultLimit)
+	if err != nil || limit > maxLimit {
+		return listPage{}, fmt.Errorf("limit must be 1-%d", maxLimit)
+	}
+
+	page := listPage{limit: limit}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if page.offset, err = decodeCursor(cursor); err != nil {
+			return listPage{}, err
+		}
+	}
+	return page, nil
+}
+
+// bounds returns the slice bounds of the page in a collection of total items
+func (p listPage) bounds(total int) (start, end int) {
+	start = min(p.offset, total)
+	end = total
+	if p.limit > 0 {
+		end = min(start+p.limit, total)
+	}
+	return start, end
+}
+
+// store returns the page as a store window, so only its items are loaded
+func (p listPage) store() store.Page {
+	return store.Page{Offset: p.offset, Limit: p.limit}
+}
+
+// listWindow limits a collection to items last updated in [since, until), from the since and until query parameters
+type listWindow struct {
+	since time.Time
+	until time.Time
+}
+
+// parseListWindow reads the window parameters, RFC3339 timestamps that are both optional
+func parseListWindow(r *http.Request) (listWindow, error) {
+	var window listWindow
+	for _, bound := range []struct {
+		name string
+		into *time.Time
+	}{{"since", &window.since}, {"until", &window.until}} {
+		raw := r.URL.Query().Get(bound.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return listWindow{}, fmt.Errorf("%s must be an RFC3339 timestamp", bound.name)
+		}
+		*bound.into = parsed
+	}
+	if !window.since.IsZero() && !window.until.IsZero() && !window.since.Before(window.until) {
+		return listWindow{}, errors.New("since must be before until")
+	}
+	return window, nil
+}
+
+// respondList writes a page of a collection in the standard envelope {"items", "total", "next_cursor"}
+// The next page is also linked in an RFC 5988 Link header. legacyKey names the endpoint's pre-envelope key,
+// which carries the same items while legacy keys are enabled; extra holds endpoint-specific fields.
+func respondList[T any](w http.ResponseWriter, r *http.Request, page listPage, legacyKey string, items []T, extra map[string]interface{}) {
+	start, end := page.bounds(len(items))
+	respondPage(w, r, page, legacyKey, items[start:end], len(items), extra)
+}
+
+// respondPage writes a page that was already selected from a collection of total items, like respondList
+func respondPage[T any](w http.ResponseWriter, r *http.Request, page listPage, legacyKey string, pageItems []T, total int, extra map[string]interface{}) {
+	if pageItems == nil {
+		pageItems = []T{}
+	}
+
+	response := map[string]interface{}{
+		"items":       pageItems,
+		"total":       total,
+		"next_cursor": nil,
+	}
+	if end := min(page.offset, total) + len(pageItems); end < total {
+		cursor := encodeCursor(end)
+		response["next_cursor"] = cursor
+
+		query := r.URL.Query()
+		query.Set("cursor", cursor)
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, query.Encode()))
+	}
+	if legacyKey != "" && legacyKey != "items" {
+		response[legacyKey] = pageItems
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	respondJSON(w, http.StatusOK, response)
+}
+
+// encodeCursor returns the opaque cursor of the item at offset
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+// decodeCursor returns the offset an opaque cursor points at
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+	return offset, nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/kubeagents/kubeagents/healthscore"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// Locations agents can be grouped by with ?group_by=
+const (
+	groupByCluster = "cluster"
+	groupByRegion  = "region"
+)
+
+// locationFilter selects agents by the cluster and region they report, from ?cluster= and ?region=
+type locationFilter struct {
+	cluster string
+	region  string
+	groupBy string // groupByCluster, groupByRegion or "" for no groups
+}
+
+// parseLocationFilter reads ?cluster=, ?region= and ?group_by= from r
+func parseLocationFilter(r *http.Request) (locationFilter, error) {
+	query := r.URL.Query()
+	filter := locationFilter{
+		cluster: query.Get("cluster"),
+		region:  query.Get("region"),
+		groupBy: query.Get("group_by"),
+	}
+	if err := models.ValidateLocationLabel("cluster", filter.cluster); err != nil {
+		return filter, err
+	}
+	if err := models.ValidateLocationLabel("region", filter.region); err != nil {
+		return filter, err
+	}
+	if filter.groupBy != "" && filter.groupBy != groupByCluster && filter.groupBy != groupByRegion {
+		return filter, fmt.Errorf("group_by must be one of: %s, %s", groupByCluster, groupByRegion)
+	}
+	return filter, nil
+}
+
+// filters reports whether the filter excludes any agents
+func (f locationFilter) filters() bool {
+	return f.cluster != "" || f.region != ""
+}
+
+// matches reports whether an agent in cluster and region passes the filter
+func (f locationFilter) matches(cluster, region string) bool {
+	return (f.cluster == "" || cluster == f.cluster) && (f.region == "" || region == f.region)
+}
+
+// key returns the group of an agent in cluster and region
+func (f locationFilter) key(cluster, region string) string {
+	if f.groupBy == groupByRegion {
+		return region
+	}
+	return cluster
+}
+
+// AgentGroup counts the agents in one cluster or region; Value is empty for agents that reported none
+// Groups of the stats API only count and score agents, so their presence counts are left out.
+type AgentGroup struct {
+	Value        string   `json:"value"`
+	AgentCount   int      `json:"agent_count"`
+	OnlineCount  int      `json:"online_count,omitempty"`
+	OfflineCount int      `json:"offline_count,omitempty"`
+	HealthScore  *float64 `json:"health_score,omitempty"` // Average score of the group's agents, when scored
+}
+
+// groupAgents counts agents per cluster or region as the filter groups them, largest group first
+// scoreOf returns an agent's health score, or nil when it is not scored; groups average the scores they have.
+func (f locationFilter) groupAgents(agents []*models.Agent, scoreOf func(agentID string) *healthscore.AgentScore) []*AgentGroup {
+	groups := make(map[string]*AgentGroup)
+	totals := make(map[string]float64)
+	scored := make(map[string]int)
+	for _, agent := range agents {
+		key := f.key(agent.Cluster, agent.Region)
+		group, ok := groups[key]
+		if !ok {
+			group = &AgentGroup{Value: key}
+			groups[key] = group
+		}
+		group.AgentCount++
+		switch agent.State {
+		case models.AgentStateOnline:
+			group.OnlineCount++
+		case models.AgentStateOffline:
+			group.OfflineCount++
+		}
+		if score := scoreOf(agent.AgentID); score != nil {
+			totals[key] += score.Score
+			scored[key]++
+		}
+	}
+	for key, count := range scored {
+		average := math.Round(totals[key]/float64(count)*10) / 10
+		groups[key].HealthScore = &average
+	}
+	return sortGroups(groups)
+}
+
+// groupScores averages scored agents per cluster or region as the filter groups them, largest group first
+func (f locationFilter) groupScores(scores []*healthscore.AgentScore) []*AgentGroup {
+	groups := make(map[string]*AgentGroup)
+	totals := make(map[string]float64)
+	for _, score := range scores {
+		key := f.key(score.Cluster, score.Region)
+		group, ok := groups[key]
+		if !ok {
+			group = &AgentGroup{Value: key}
+			groups[key] = group
+		}
+		group.AgentCount++
+		totals[key] += score.Score
+	}
+	for key, group := range groups {
+		average := math.Round(totals[key]/float64(group.AgentCount)*10) / 10
+		group.HealthScore = &average
+	}
+	return sortGroups(groups)
+}
+
+// sortGroups lists groups largest first, then by value
+func sortGroups(groups map[string]*AgentGroup) []*AgentGroup {
+	sorted := make([]*AgentGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].AgentCount != sorted[j].AgentCount {
+			return sorted[i].AgentCount > sorted[j].AgentCount
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+	return sorted
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// MetaResponse describes the deployment's taxonomies, so dashboards label and group agents consistently
+type MetaResponse struct {
+	AgentKinds       []models.AgentKindInfo `json:"agent_kinds"`
+	DefaultAgentKind string                 `json:"default_agent_kind,omitempty"` // Given to agents reporting without a kind
+}
+
+// MetaHandler serves deployment metadata
+type MetaHandler struct {
+	defaultAgentKind string
+}
+
+// NewMetaHandler creates a meta handler reporting defaultAgentKind as the kind of unclassified reports
+func NewMetaHandler(defaultAgentKind string) *MetaHandler {
+	return &MetaHandler{defaultAgentKind: defaultAgentKind}
+}
+
+// Get handles GET /api/meta
+func (h *MetaHandler) Get(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, MetaResponse{
+		AgentKinds:       models.AgentKinds,
+		DefaultAgentKind: h.defaultAgentKind,
+	})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestMetaHandler_Get(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NewMetaHandler(models.AgentKindCustom).Get(rr, httptest.NewRequest("GET", "/api/meta", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Get() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	var response MetaResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Get() invalid JSON: %v", err)
+	}
+	if len(response.AgentKinds) != len(models.AgentKinds) || response.AgentKinds[0].Kind != models.AgentKindCI || response.AgentKinds[0].Icon == "" {
+		t.Errorf("Get() agent_kinds = %+v, want the curated kinds with icons", response.AgentKinds)
+	}
+	if response.DefaultAgentKind != models.AgentKindCustom {
+		t.Errorf("Get() default_agent_kind = %q, want %q", response.DefaultAgentKind, models.AgentKindCustom)
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// NotificationSettingsHandler handles each user's own notification receiver and triggers
+type NotificationSettingsHandler struct {
+	store store.Store
+	clock clock.Clock
+}
+
+// NewNotificationSettingsHandler creates a new notification settings handler
+func NewNotificationSettingsHandler(st store.Store) *NotificationSettingsHandler {
+	return &NotificationSettingsHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps notification settings
+func (h *NotificationSettingsHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// NotificationSettingsRequest represents the notification settings a user saves
+type NotificationSettingsRequest struct {
+	WebhookURL           string                    `json:"webhook_url,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Transitions          []models.StatusTransition `json:"transitions,omitempty"`
+	FirstFailureOnly     bool                      `json:"first_failure_only,omitempty"`
+	Template             string                    `json:"template,omitempty"` // Go text/template of the message; empty uses the default
+	DedupMinutes         int                       `json:"dedup_minutes,omitempty"`
+	EscalateAfterMinutes int                       `json:"escalate_after_minutes,omitempty"`
+}
+
+// loadNotificationSettings returns a user's notification settings, or empty ones when the user saved none
+func loadNotificationSettings(st store.Store, userID string) *models.NotificationSettings {
+	settings, err := st.GetNotificationSettings(userID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to load notification settings: %v", err)
+		}
+		return &models.NotificationSettings{UserID: userID}
+	}
+	return settings
+}
+
+// Get handles GET /api/notifications/settings
+func (h *NotificationSettingsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	settings, err := h.store.GetNotificationSettings(caller.UserID)
+	if err != nil {
+		respondStoreError(w, err, "notification settings not found", "failed to get notification settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, settings)
+}
+
+// Update handles PUT /api/notifications/settings, creating or replacing the caller's settings
+func (h *NotificationSettingsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req NotificationSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	settings := &models.NotificationSettings{
+		UserID:               caller.UserID,
+		WebhookURL:           strings.TrimSpace(req.WebhookURL),
+		Format:               req.Format,
+		Transitions:          req.Transitions,
+		FirstFailureOnly:     req.FirstFailureOnly,
+		Template:             req.Template,
+		DedupMinutes:         req.DedupMinutes,
+		EscalateAfterMinutes: req.EscalateAfterMinutes,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	}
+	if err := settings.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if existing, err := h.store.GetNotificationSettings(caller.UserID); err == nil {
+		settings.CreatedAt = existing.CreatedAt
+		status = http.StatusOK
+	} else if !errors.Is(err, store.ErrNotFound) {
+		respondError(w, http.StatusInternalServerError, "failed to save notification settings")
+		return
+	}
+
+	if err := h.store.SaveNotificationSettings(settings); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to save notification settings")
+		return
+	}
+
+	respondJSON(w, status, settings)
+}
+
+// Delete handles DELETE /api/notifications/settings, returning the caller to the profile's webhook URL
+// and the default transitions
+func (h *NotificationSettingsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	if err := h.store.DeleteNotificationSettings(caller.UserID); err != nil {
+		respondStoreError(w, err, "notification settings not found", "failed to delete notification settings")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Notification settings removed successfully",
+	})
+}
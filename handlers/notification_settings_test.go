@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestNotificationSettingsHandler_CRUD(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewNotificationSettingsHandler(st)
+
+	call := func(fn http.HandlerFunc, method, body string) *httptest.ResponseRecorder {
+		req := testsupport.WithUser(httptest.NewRequest(method, "/api/notifications/settings", bytes.NewReader([]byte(body))))
+		rr := httptest.NewRecorder()
+		fn(rr, req)
+		return rr
+	}
+
+	if rr := call(handler.Get, "GET", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Get() before saving status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	for _, body := range []string{
+		`{"webhook_url":"ftp://example.com"}`,
+		`{"webhook_url":"https://example.com","format":"mattermost"}`,
+		`{"transitions":[{"from":"running","to":"done"}]}`,
+		`{"transitions":[{"from":"failed","to":"failed"}]}`,
+		`{"template":"{{.AgentName"}`,
+		`{"template":"{{.Agent}} failed"}`,
+		`{"template":"{{.Timestamp.Year.Month}}"}`,
+		`{"dedup_minutes":-1}`,
+		`{"escalate_after_minutes":1441}`,
+	} {
+		if rr := call(handler.Update, "PUT", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("Update(%s) status = %v, want %v", body, rr.Code, http.StatusBadRequest)
+		}
+	}
+
+	body := `{"webhook_url":" https://discord.com/api/webhooks/1/abc ","transitions":[{"from":"*","to":"failed"}]}`
+	if rr := call(handler.Update, "PUT", body); rr.Code != http.StatusCreated {
+		t.Fatalf("Update() status = %v, want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+	if rr := call(handler.Update, "PUT", body); rr.Code != http.StatusOK {
+		t.Errorf("Update() again status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	rr := call(handler.Get, "GET", "")
+	var settings models.NotificationSettings
+	if err := json.Unmarshal(rr.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("decode settings: %v", err)
+	}
+	if settings.WebhookURL != "https://discord.com/api/webhooks/1/abc" || len(settings.Transitions) != 1 {
+		t.Errorf("Get() = %+v, want the saved settings", settings)
+	}
+
+	if rr := call(handler.Delete, "DELETE", ""); rr.Code != http.StatusOK {
+		t.Errorf("Delete() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	if rr := call(handler.Delete, "DELETE", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Delete() twice status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestWebhookHandler_NotificationSettings(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string) // request path -> bodies
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, notifier.NewNotificationManager(5*time.Second))
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL+"/profile"))
+	now := time.Now()
+	err := st.SaveNotificationSettings(&models.NotificationSettings{
+		UserID:      testsupport.UserID,
+		WebhookURL:  server.URL + "/settings",
+		Format:      "discord",
+		Transitions: []models.StatusTransition{{From: "pending", To: "running"}, {From: "*", To: "failed"}},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		t.Fatalf("SaveNotificationSettings() error = %v", err)
+	}
+
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "pending", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now.Add(time.Second), "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "success", now.Add(2*time.Second), "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-002", "pending", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-002", "failed", now.Add(time.Second), "", "")
+
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received["/profile"]) != 0 {
+		t.Errorf("profile webhook received %d notifications, want 0", len(received["/profile"]))
+	}
+	bodies := received["/settings"]
+	if len(bodies) != 2 {
+		t.Fatalf("settings webhook received %d notifications, want pending → running and pending → failed", len(bodies))
+	}
+	for _, body := range bodies {
+		if !strings.Contains(body, `"content"`) {
+			t.Errorf("settings webhook payload = %s, want the Discord format", body)
+		}
+	}
+}
+
+func TestWebhookHandler_FirstFailureOnly(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL("https://me.example.com"))
+	now := time.Now()
+	err := st.SaveNotificationSettings(&models.NotificationSettings{UserID: testsupport.UserID, FirstFailureOnly: true, CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("SaveNotificationSettings() error = %v", err)
+	}
+	raw, _ := json.Marshal(&models.NotificationPolicy{WebhookURL: "https://org.example.com/hook"})
+	if err := st.SetConfig(NotificationPolicyConfigKey, string(raw)); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	destinations := func() []string {
+		data := &notifier.NotificationData{AgentID: "agent-001", SessionTopic: "nightly", FromStatus: "running", ToStatus: "failed"}
+		var urls []string
+		for _, destination := range handler.notificationDestinations(data, testsupport.UserID) {
+			urls = append(urls, destination.URL)
+		}
+		return urls
+	}
+
+	// Nightly runs: failed, failed, success, then a failure again
+	for i, tt := range []struct {
+		previous string
+		want     int
+	}{
+		{"", 2},
+		{"failed", 1},
+		{"success", 2},
+	} {
+		if tt.previous != "" {
+			testsupport.SendStatus(t, handler, "agent-001", "nightly", "running", now, "", "")
+			testsupport.SendStatus(t, handler, "agent-001", "nightly", tt.previous, now, "", "")
+		}
+		if got := destinations(); len(got) != tt.want {
+			t.Errorf("run %d after %q: destinations = %v, want %d", i, tt.previous, got, tt.want)
+		}
+	}
+
+	// The policy can hold back repeated failures from its own receivers as well
+	raw, _ = json.Marshal(&models.NotificationPolicy{WebhookURL: "https://org.example.com/hook", FirstFailureOnly: true})
+	st.SetConfig(NotificationPolicyConfigKey, string(raw))
+	testsupport.SendStatus(t, handler, "agent-001", "nightly", "running", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "nightly", "failed", now, "", "")
+	if got := destinations(); len(got) != 0 {
+		t.Errorf("repeated failure with a first-failure-only policy: destinations = %v, want none", got)
+	}
+}
+
+func TestWebhookHandler_NotificationDedup(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL("https://me.example.com"))
+	now := time.Now()
+	err := st.SaveNotificationSettings(&models.NotificationSettings{UserID: testsupport.UserID, DedupMinutes: 30, CreatedAt: now, UpdatedAt: now})
+	if err != nil {
+		t.Fatalf("SaveNotificationSettings() error = %v", err)
+	}
+	raw, _ := json.Marshal(&models.NotificationPolicy{WebhookURL: "https://org.example.com/hook"})
+	if err := st.SetConfig(NotificationPolicyConfigKey, string(raw)); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	destinations := func(topic, status string, at time.Time) int {
+		data := &notifier.NotificationData{AgentID: "agent-001", SessionTopic: topic, FromStatus: "running", ToStatus: status, Timestamp: at}
+		return len(handler.notificationDestinations(data, testsupport.UserID))
+	}
+
+	// The policy's receiver is not deduplicated, so a held back notification still reaches it
+	for _, tt := range []struct {
+		name   string
+		topic  string
+		status string
+		at     time.Time
+		want   int
+	}{
+		{"first failure", "nightly", "failed", now, 2},
+		{"failure within the window", "nightly", "failed", now.Add(10 * time.Minute), 1},
+		{"another status", "nightly", "success", now.Add(10 * time.Minute), 2},
+		{"another session", "weekly", "failed", now.Add(10 * time.Minute), 2},
+		{"failure after the window", "nightly", "failed", now.Add(31 * time.Minute), 2},
+	} {
+		if got := destinations(tt.topic, tt.status, tt.at); got != tt.want {
+			t.Errorf("%s: destinations = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookHandler_RecoveryNotification(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL))
+	now := time.Now()
+	// Only recoveries are chosen, so neither the failures nor the first success notify
+	err := st.SaveNotificationSettings(&models.NotificationSettings{
+		UserID:      testsupport.UserID,
+		Format:      "json",
+		Transitions: []models.StatusTransition{{From: "failed", To: "success"}},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		t.Fatalf("SaveNotificationSettings() error = %v", err)
+	}
+
+	handler := NewWebhookHandlerWithNotifier(st, notifier.NewNotificationManager(5*time.Second))
+	for _, status := range []string{"running", "success", "running", "failed", "running", "failed", "running", "success"} {
+		testsupport.SendStatus(t, handler, "agent-001", "nightly", status, now, "", "")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 {
+		t.Fatalf("webhook received %d notifications, want only the recovery", len(bodies))
+	}
+	if !strings.Contains(bodies[0], "Session Recovered") || !strings.Contains(bodies[0], "Recovered After: 2 failed run(s)") {
+		t.Errorf("recovery notification = %s, want the streak of 2 failed runs", bodies[0])
+	}
+}
+
+func TestWebhookHandler_NotificationTemplate(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]string) // request path -> last body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = string(body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, notifier.NewNotificationManager(5*time.Second))
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL+"/profile"))
+	user, _ := st.GetUserByID(testsupport.UserID)
+	user.NotificationDestinations = []models.NotificationDestination{
+		{URL: server.URL + "/extra", Format: "json"},
+		{URL: server.URL + "/own", Format: "json", Template: "own: {{.ToStatus}}"},
+	}
+	if err := st.UpdateUser(user); err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	now := time.Now()
+	if err := st.SaveNotificationSettings(&models.NotificationSettings{UserID: testsupport.UserID, Format: "json",
+		Template: "{{.AgentID}}/{{.SessionTopic}} is {{.ToStatus}} after {{.Duration}}", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("SaveNotificationSettings() error = %v", err)
+	}
+
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "success", now.Add(time.Second), "", "")
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for path, want := range map[string]string{
+		"/profile": "agent-001/task-001 is success after",
+		"/extra":   "agent-001/task-001 is success after",
+		"/own":     `"own: success"`,
+	} {
+		if !strings.Contains(received[path], want) {
+			t.Errorf("%s received %s, want the message rendered as %q", path, received[path], want)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// invitationTokenPrefix marks invitation tokens so they are not mistaken for API keys
+const invitationTokenPrefix = "kai_"
+
+// agentRole returns the role userID holds over agent: OrgRoleOwner for its owner, the user's role in the
+// organization the agent is shared with, or "" without access
+func agentRole(st store.Store, userID string, agent *models.Agent) string {
+	if agent.UserID == userID {
+		return models.OrgRoleOwner
+	}
+	if agent.OrgID == "" {
+		return ""
+	}
+	membership, err := st.GetMembership(agent.OrgID, userID)
+	if err != nil {
+		return ""
+	}
+	return membership.Role
+}
+
+// canViewAgent reports whether userID owns agent or belongs to the organization it is shared with
+func canViewAgent(st store.Store, userID string, agent *models.Agent) bool {
+	return agentRole(st, userID, agent) != ""
+}
+
+// canManageAgent reports whether userID may change agent: its owner and owners and members of its organization
+func canManageAgent(st store.Store, userID string, agent *models.Agent) bool {
+	return models.CanManageAgents(agentRole(st, userID, agent))
+}
+
+// OrgHandler handles organizations, their members and invitations
+type OrgHandler struct {
+	store store.Store
+	clock clock.Clock
+
+	listFormat
+}
+
+// NewOrgHandler creates a new organization handler
+func NewOrgHandler(st store.Store) *OrgHandler {
+	return &OrgHandler{store: st, clock: clock.Real}
+}
+
+// SetClock replaces the clock that stamps memberships and expires invitations
+func (h *OrgHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// OrgWithRole is an organization with the caller's role in it
+type OrgWithRole struct {
+	*models.Organization
+	Role string `json:"role"`
+}
+
+// CreateOrgRequest represents a request to create an organization
+type CreateOrgRequest struct {
+	Name string `json:"name"`
+}
+
+// Member is a membership with the member's email and name
+type Member struct {
+	*models.Membership
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// UpdateMemberRequest represents a request to change a member's role
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// CreateInvitationRequest represents a request to invite a user to an organization
+type CreateInvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"` // Defaults to member
+}
+
+// CreateInvitationResponse represents the response when creating an invitation
+// The raw token is only returned once; the inviter passes it on to the invitee.
+type CreateInvitationResponse struct {
+	*models.Invitation
+	Token string `json:"token"`
+}
+
+// AcceptInvitationRequest represents a request to accept an invitation
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+// member writes an error response unless the caller belongs to the organization in the URL,
+// as an owner if ownerOnly is set
+// Non-members cannot tell an organization from a missing one.
+func (h *OrgHandler) member(w http.ResponseWriter, r *http.Request, ownerOnly bool) (*middleware.RequestContext, *models.Membership, bool) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return nil, nil, false
+	}
+
+	membership, err := h.store.GetMembership(chi.URLParam(r, "org_id"), caller.UserID)
+	if err != nil {
+		respondStoreError(w, err, "organization not found", "failed to get membership")
+		return nil, nil, false
+	}
+	if ownerOnly && membership.Role != models.OrgRoleOwner {
+		respondError(w, http.StatusForbidden, "only organization owners can do this")
+		return nil, nil, false
+	}
+	return caller, membership, true
+}
+
+// Create handles POST /api/orgs, creating an organization owned by the caller
+func (h *OrgHandler) Create(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req CreateOrgRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	org := &models.Organization{
+		ID:        uuid.New().String(),
+		Name:      strings.TrimSpace(req.Name),
+		CreatedBy: caller.UserID,
+		CreatedAt: now,
+	}
+	if err := org.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+	owner := &models.Membership{OrgID: org.ID, UserID: caller.UserID, Role: models.OrgRoleOwner, CreatedAt: now}
+	if err := h.store.CreateOrganization(org, owner); err != nil {
+		respondStoreError(w, err, "organization not found", "failed to create organization")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, OrgWithRole{Organization: org, Role: owner.Role})
+}
+
+// List handles GET /api/orgs, listing the caller's organizations in the order they joined them
+func (h *OrgHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	memberships, err := h.store.ListMembershipsByUser(caller.UserID)
+	if err != nil {
+		respondStoreError(w, err, "organization not found", "failed to list organizations")
+		return
+	}
+	orgs := make([]OrgWithRole, 0, len(memberships))
+	for _, membership := range memberships {
+		org, err := h.store.GetOrganization(membership.OrgID)
+		if err != nil {
+			continue
+		}
+		orgs = append(orgs, OrgWithRole{Organization: org, Role: membership.Role})
+	}
+
+	respondList(w, r, page, h.legacyKey("organizations"), orgs, nil)
+}
+
+// Get handles GET /api/orgs/{org_id}
+func (h *OrgHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, membership, ok := h.member(w, r, false)
+	if !ok {
+		return
+	}
+
+	org, err := h.store.GetOrganization(membership.OrgID)
+	if err != nil {
+		respondStoreError(w, err, "organization not found", "failed to get organization")
+		return
+	}
+	respondJSON(w, http.StatusOK, OrgWithRole{Organization: org, Role: membership.Role})
+}
+
+// Delete handles DELETE /api/orgs/{org_id}; the organization's agents stay with their owners, unshared
+func (h *OrgHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	_, membership, ok := h.member(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteOrganization(membership.OrgID); err != nil {
+		respondStoreError(w, err, "organization not found", "failed to delete organization")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "organization deleted successfully",
+	})
+}
+
+// ListMembers handles GET /api/orgs/{org_id}/members
+func (h *OrgHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	_, membership, ok := h.member(w, r, false)
+	if !ok {
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	memberships, err := h.store.ListMemberships(membership.OrgID)
+	if err != nil {
+		respondStoreError(w, err, "organization not found", "failed to list members")
+		return
+	}
+	members := make([]Member, 0, len(memberships))
+	for _, m := range memberships {
+		member := Member{Membership: m}
+		if user, err := h.store.GetUserByID(m.UserID); err == nil {
+			member.Email = user.Email
+			member.Name = user.Name
+		}
+		members = append(members, member)
+	}
+
+	respondList(w, r, page, h.legacyKey("members"), members, nil)
+}
+
+// UpdateMember handles PUT /api/orgs/{org_id}/members/{user_id}, changing a member's role
+func (h *OrgHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	_, membership, ok := h.member(w, r, true)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if !models.ValidOrgRole(req.Role) {
+		respondError(w, http.StatusBadRequest, "role must be one of: owner, member, viewer")
+		return
+	}
+
+	target, err := h.store.GetMembership(membership.OrgID, chi.URLParam(r, "user_id"))
+	if err != nil {
+		respondStoreError(w, err, "member not found", "failed to get member")
+		return
+	}
+	if req.Role != models.OrgRoleOwner && !h.keepsOwner(w, target) {
+		return
+	}
+
+	target.Role = req.Role
+	if err := h.store.SaveMembership(target); err != nil {
+		respondStoreError(w, err, "organization not found", "failed to update member")
+		return
+	}
+	respondJSON(w, http.StatusOK, target)
+}
+
+// RemoveMember handles DELETE /api/orgs/{org_id}/members/{user_id}
+// Owners remove anyone; other members may only remove themselves, leaving the organization.
+func (h *OrgHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	caller, membership, ok := h.member(w, r, false)
+	if !ok {
+		return
+	}
+
+	userID := chi.URLParam(r, "user_id")
+	if userID != caller.UserID && membership.Role != models.OrgRoleOwner {
+		respondError(w, http.StatusForbidden, "only organization owners can do this")
+		return
+	}
+	target, err := h.store.GetMembership(membership.OrgID, userID)
+	if err != nil {
+		respondStoreError(w, err, "member not found", "failed to get member")
+		return
+	}
+	if !h.keepsOwner(w, target) {
+		return
+	}
+
+	if err := h.store.DeleteMembership(membership.OrgID, userID); err != nil {
+		respondStoreError(w, err, "member not found", "failed to remove member")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "member removed successfully",
+	})
+}
+
+// keepsOwner writes an error response if target is the organization's only owner,
+// who can neither leave nor be demoted
+func (h *OrgHandler) keepsOwner(w http.ResponseWriter, target *models.Membership) bool {
+	if target.Role != models.OrgRoleOwner {
+		return true
+	}
+	other, err := hasOtherOwner(h.store, target.OrgID, target.UserID)
+	if err != nil {
+		respondStoreError(w, err, "organization not found", "failed to list members")
+		return false
+	}
+	if !other {
+		respondError(w, http.StatusConflict, "an organization needs at least one owner")
+		return false
+	}
+	return true
+}
+
+// hasOtherOwner reports whether an organization has an owner besides userID
+func hasOtherOwner(st store.Store, orgID, userID string) (bool, error) {
+	memberships, err := st.ListMemberships(orgID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range memberships {
+		if m.Role == models.OrgRoleOwner && m.UserID != userID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CreateInvitation handles POST /api/orgs/{org_id}/invitations
+func (h *OrgHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	caller, membership, ok := h.member(w, r, true)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req CreateInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.OrgRoleMember
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to generate invitation token")
+		return
+	}
+	rawToken := invitationTokenPrefix + key
+
+	now := h.clock.Now().UTC()
+	invitation := &models.Invitation{
+		ID:          uuid.New().String(),
+		OrgID:       membership.OrgID,
+		Email:       strings.ToLower(strings.TrimSpace(req.Email)),
+		Role:        req.Role,
+		TokenHash:   middleware.HashAPIKey(rawToken),
+		TokenPrefix: rawToken[:8],
+		InvitedBy:   caller.UserID,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(models.InvitationTTL),
+	}
+	if err := invitation.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+	if err := h.store.CreateInvitation(invitation); err != nil {
+		respondStoreError(w, err, "organization not found", "failed to create invitation")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, CreateInvitationResponse{Invitation: invitation, Token: rawToken})
+}
+
+// ListInvitations handles GET /api/orgs/{org_id}/invitations, listing pending invitations newest first
+func (h *OrgHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	_, membership, ok := h.member(w, r, true)
+	if !ok {
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	invitations, err := h.store.ListInvitations(membership.OrgID)
+	if err != nil {
+		respondStoreError(w, err, "organization not found", "failed to list invitations")
+		return
+	}
+	respondList(w, r, page, h.legacyKey("invitations"), invitations, nil)
+}
+
+// DeleteInvitation handles DELETE /api/orgs/{org_id}/invitations/{invitation_id}, revoking an invitation
+func (h *OrgHandler) DeleteInvitation(w http.ResponseWriter, r *http.Request) {
+	_, membership, ok := h.member(w, r, true)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteInvitation(membership.OrgID, chi.URLParam(r, "invitation_id")); err != nil {
+		respondStoreError(w, err, "invitation not found", "failed to revoke invitation")
+		return
+	}
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "invitation revoked successfully",
+	})
+}
+
+// AcceptInvitation handles POST /api/invitations/accept, joining the caller to the inviting organization
+// The caller must be signed in with the invited email address. A member who is invited again takes the
+// invitation's role, except that owners stay owners.
+func (h *OrgHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req AcceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		respondError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	// Invitations for other addresses are indistinguishable from missing ones
+	invitation, err := h.store.GetInvitationByHash(middleware.HashAPIKey(req.Token))
+	if err != nil || !strings.EqualFold(invitation.Email, caller.Email) {
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			respondStoreError(w, err, "invitation not found", "failed to get invitation")
+			return
+		}
+		respondError(w, http.StatusNotFound, "invitation not found")
+		return
+	}
+	now := h.clock.Now().UTC()
+	if !now.Before(invitation.ExpiresAt) {
+		respondError(w, http.StatusGone, "invitation has expired")
+		return
+	}
+
+	membership := &models.Membership{OrgID: invitation.OrgID, UserID: caller.UserID, Role: invitation.Role, CreatedAt: now}
+	if existing, err := h.store.GetMembership(invitation.OrgID, caller.UserID); err == nil {
+		membership.CreatedAt = existing.CreatedAt
+		if existing.Role == models.OrgRoleOwner {
+			membership.Role = models.OrgRoleOwner
+		}
+	}
+	if err := h.store.AcceptInvitation(invitation.ID, membership); err != nil {
+		respondStoreError(w, err, "invitation not found", "failed to accept invitation")
+		return
+	}
+
+	org, err := h.store.GetOrganization(invitation.OrgID)
+	if err != nil {
+		respondStoreError(w, err, "organization not found", "failed to get organization")
+		return
+	}
+	respondJSON(w, http.StatusOK, OrgWithRole{Organization: org, Role: membership.Role})
+}
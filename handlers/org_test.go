@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// orgRouter routes organization and agent requests the way main does, as the user named by the X-Test-User
+// header: the default test user or bob
+func orgRouter(st store.Store, orgs *OrgHandler) http.Handler {
+	agents := NewAgentHandler(st)
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("X-Test-User") == "bob" {
+				req = testsupport.WithCaller(req, "user-bob", "bob@example.com")
+			} else {
+				req = testsupport.WithUser(req)
+			}
+			next.ServeHTTP(w, req)
+		})
+	})
+	r.Route("/api", func(r chi.Router) {
+		r.Post("/orgs", orgs.Create)
+		r.Get("/orgs", orgs.List)
+		r.Delete("/orgs/{org_id}", orgs.Delete)
+		r.Get("/orgs/{org_id}/members", orgs.ListMembers)
+		r.Put("/orgs/{org_id}/members/{user_id}", orgs.UpdateMember)
+		r.Delete("/orgs/{org_id}/members/{user_id}", orgs.RemoveMember)
+		r.Post("/orgs/{org_id}/invitations", orgs.CreateInvitation)
+		r.Post("/invitations/accept", orgs.AcceptInvitation)
+		r.Get("/agents", agents.ListAgents)
+		r.Get("/agents/{agent_id}", agents.GetAgent)
+		r.Put("/agents/{agent_id}/org", agents.ShareAgent)
+		r.Put("/agents/{agent_id}/sampling", agents.UpdateSampling)
+	})
+	return r
+}
+
+// orgRequest sends a request as user and decodes the JSON response into out, if given
+func orgRequest(t *testing.T, router http.Handler, user, method, path, body string, out interface{}) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("X-Test-User", user)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if out != nil && rr.Code < 300 {
+		if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s invalid JSON: %v", method, path, err)
+		}
+	}
+	return rr.Code
+}
+
+func TestOrgHandler_InviteAndShareAgents(t *testing.T) {
+	st := testsupport.StoreWithAgents(t, 2, 0)
+	testsupport.CreateUser(t, st, testsupport.UserWithID("user-bob", "bob@example.com"))
+	router := orgRouter(st, NewOrgHandler(st))
+
+	var org OrgWithRole
+	if code := orgRequest(t, router, "", "POST", "/api/orgs", `{"name":"Platform"}`, &org); code != http.StatusCreated || org.Role != "owner" {
+		t.Fatalf("Create() = %d %+v, want the caller as owner", code, org)
+	}
+	orgPath := "/api/orgs/" + org.ID
+
+	var invitation CreateInvitationResponse
+	if code := orgRequest(t, router, "", "POST", orgPath+"/invitations", `{"email":"Bob@Example.com","role":"viewer"}`, &invitation); code != http.StatusCreated || !strings.HasPrefix(invitation.Token, "kai_") {
+		t.Fatalf("CreateInvitation() = %d %+v, want a kai_ token", code, invitation)
+	}
+
+	// Only the invited address can accept, and only once
+	if code := orgRequest(t, router, "", "POST", "/api/invitations/accept", `{"token":"`+invitation.Token+`"}`, nil); code != http.StatusNotFound {
+		t.Errorf("AcceptInvitation() by another user = %d, want %d", code, http.StatusNotFound)
+	}
+	var joined OrgWithRole
+	if code := orgRequest(t, router, "bob", "POST", "/api/invitations/accept", `{"token":"`+invitation.Token+`"}`, &joined); code != http.StatusOK || joined.Role != "viewer" || joined.Name != "Platform" {
+		t.Fatalf("AcceptInvitation() = %d %+v, want Platform as viewer", code, joined)
+	}
+	if code := orgRequest(t, router, "bob", "POST", "/api/invitations/accept", `{"token":"`+invitation.Token+`"}`, nil); code != http.StatusNotFound {
+		t.Errorf("AcceptInvitation() again = %d, want %d", code, http.StatusNotFound)
+	}
+
+	// Unshared agents stay private; viewers cannot share into the organization
+	if code := orgRequest(t, router, "bob", "GET", "/api/agents/agent-001", "", nil); code != http.StatusForbidden {
+		t.Errorf("GetAgent() of an unshared agent = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := orgRequest(t, router, "", "PUT", "/api/agents/agent-001/org", `{"org_id":"`+org.ID+`"}`, nil); code != http.StatusOK {
+		t.Fatalf("ShareAgent() = %d, want %d", code, http.StatusOK)
+	}
+	if code := orgRequest(t, router, "bob", "PUT", "/api/agents/agent-001/org", `{"org_id":""}`, nil); code != http.StatusForbidden {
+		t.Errorf("ShareAgent() by a non-owner = %d, want %d", code, http.StatusForbidden)
+	}
+
+	var listed struct {
+		Items []struct {
+			AgentID string `json:"agent_id"`
+		} `json:"items"`
+	}
+	if code := orgRequest(t, router, "bob", "GET", "/api/agents?org_id="+org.ID, "", &listed); code != http.StatusOK || len(listed.Items) != 1 || listed.Items[0].AgentID != "agent-001" {
+		t.Errorf("ListAgents(org_id) = %d %+v, want agent-001", code, listed)
+	}
+	if code := orgRequest(t, router, "bob", "GET", "/api/agents?org_id=missing", "", nil); code != http.StatusNotFound {
+		t.Errorf("ListAgents() of another organization = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := orgRequest(t, router, "bob", "GET", "/api/agents/agent-001", "", nil); code != http.StatusOK {
+		t.Errorf("GetAgent() of a shared agent = %d, want %d", code, http.StatusOK)
+	}
+
+	// Viewers only read; members also manage
+	if code := orgRequest(t, router, "bob", "PUT", "/api/agents/agent-001/sampling", `{"heartbeat_sample_every":5}`, nil); code != http.StatusForbidden {
+		t.Errorf("UpdateSampling() as viewer = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := orgRequest(t, router, "bob", "PUT", orgPath+"/members/user-bob", `{"role":"owner"}`, nil); code != http.StatusForbidden {
+		t.Errorf("UpdateMember() by a viewer = %d, want %d", code, http.StatusForbidden)
+	}
+	if code := orgRequest(t, router, "", "PUT", orgPath+"/members/user-bob", `{"role":"member"}`, nil); code != http.StatusOK {
+		t.Fatalf("UpdateMember() = %d, want %d", code, http.StatusOK)
+	}
+	if code := orgRequest(t, router, "bob", "PUT", "/api/agents/agent-001/sampling", `{"heartbeat_sample_every":5}`, nil); code != http.StatusOK {
+		t.Errorf("UpdateSampling() as member = %d, want %d", code, http.StatusOK)
+	}
+
+	var members struct {
+		Items []Member `json:"items"`
+	}
+	if code := orgRequest(t, router, "bob", "GET", orgPath+"/members", "", &members); code != http.StatusOK || len(members.Items) != 2 || members.Items[1].Email != "bob@example.com" {
+		t.Errorf("ListMembers() = %d %+v, want the owner then bob", code, members.Items)
+	}
+
+	// The only owner can neither leave nor be demoted; bob can leave
+	if code := orgRequest(t, router, "", "DELETE", orgPath+"/members/"+testsupport.UserID, "", nil); code != http.StatusConflict {
+		t.Errorf("RemoveMember() of the only owner = %d, want %d", code, http.StatusConflict)
+	}
+	if code := orgRequest(t, router, "bob", "DELETE", orgPath+"/members/user-bob", "", nil); code != http.StatusOK {
+		t.Errorf("RemoveMember() of oneself = %d, want %d", code, http.StatusOK)
+	}
+	if code := orgRequest(t, router, "bob", "GET", "/api/agents/agent-001", "", nil); code != http.StatusForbidden {
+		t.Errorf("GetAgent() after leaving = %d, want %d", code, http.StatusForbidden)
+	}
+
+	// Deleting the organization unshares its agents
+	if code := orgRequest(t, router, "", "DELETE", orgPath, "", nil); code != http.StatusOK {
+		t.Fatalf("Delete() = %d, want %d", code, http.StatusOK)
+	}
+	if agent, _ := st.GetAgent("agent-001"); agent.OrgID != "" {
+		t.Errorf("agent org_id after delete = %q, want empty", agent.OrgID)
+	}
+}
+
+func TestOrgHandler_ExpiredInvitation(t *testing.T) {
+	st := testsupport.StoreWithAgents(t, 0, 0)
+	testsupport.CreateUser(t, st, testsupport.UserWithID("user-bob", "bob@example.com"))
+	orgs := NewOrgHandler(st)
+	fake := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	orgs.SetClock(fake)
+	router := orgRouter(st, orgs)
+
+	var org OrgWithRole
+	orgRequest(t, router, "", "POST", "/api/orgs", `{"name":"Platform"}`, &org)
+	var invitation CreateInvitationResponse
+	if code := orgRequest(t, router, "", "POST", "/api/orgs/"+org.ID+"/invitations", `{"email":"bob@example.com"}`, &invitation); code != http.StatusCreated || invitation.Role != "member" {
+		t.Fatalf("CreateInvitation() = %d %+v, want a member invitation", code, invitation)
+	}
+
+	fake.Advance(8 * 24 * time.Hour)
+	if code := orgRequest(t, router, "bob", "POST", "/api/invitations/accept", `{"token":"`+invitation.Token+`"}`, nil); code != http.StatusGone {
+		t.Errorf("AcceptInvitation() after expiry = %d, want %d", code, http.StatusGone)
+	}
+	if _, err := st.GetMembership(org.ID, "user-bob"); err == nil {
+		t.Error("GetMembership() after an expired invitation, want no membership")
+	}
+}
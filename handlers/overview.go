@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Defaults and bounds of the dashboard overview
+const (
+	defaultOverviewWindow = 24 * time.Hour
+	defaultOverviewLimit  = 10
+	maxOverviewLimit      = 50
+)
+
+// OverviewHandler serves the dashboard home page in one request, in place of a request per agent and list
+type OverviewHandler struct {
+	store store.Store
+	clock clock.Clock
+}
+
+// NewOverviewHandler creates a new overview handler
+func NewOverviewHandler(st store.Store) *OverviewHandler {
+	return &OverviewHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that places the default window and measures running durations
+func (h *OverviewHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// OverviewResponse is everything the dashboard home page shows
+type OverviewResponse struct {
+	Agents         OverviewAgents        `json:"agents"`
+	Sessions       OverviewSessions      `json:"sessions"`
+	Statuses       OverviewStatuses      `json:"statuses"`
+	RecentFailures []*OverviewFailure    `json:"recent_failures"`
+	Running        []*RunningSession     `json:"running"` // Longest running first
+	Notifications  OverviewNotifications `json:"notifications"`
+}
+
+// OverviewAgents counts the caller's agents by presence
+type OverviewAgents struct {
+	Total   int `json:"total"`
+	Online  int `json:"online"`
+	Stale   int `json:"stale"`
+	Offline int `json:"offline"`
+}
+
+// OverviewSessions counts the caller's sessions
+type OverviewSessions struct {
+	Active  int `json:"active"`
+	Running int `json:"running"`
+}
+
+// OverviewStatuses counts the final statuses reported in the window
+type OverviewStatuses struct {
+	Since     time.Time `json:"since"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+}
+
+// OverviewFailure is a recently failed session run
+type OverviewFailure struct {
+	AgentID      string    `json:"agent_id"`
+	AgentName    string    `json:"agent_name,omitempty"`
+	SessionTopic string    `json:"session_topic"`
+	Revision     int       `json:"revision"`
+	Message      string    `json:"message,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// OverviewNotifications is the head of the caller's inbox
+type OverviewNotifications struct {
+	Unread int                 `json:"unread"`
+	Recent []*models.InboxItem `json:"recent"` // Newest first
+}
+
+// Get handles GET /api/overview
+// ?since= (RFC3339, default 24 hours ago) sets the window of the status counts and recent failures, and
+// ?limit= (default 10, at most 50) bounds each of the recent failures, running sessions and notifications.
+func (h *OverviewHandler) Get(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	since := now.Add(-defaultOverviewWindow)
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "since must be an RFC3339 timestamp")
+			return
+		}
+		since = parsed
+	}
+
+	limit := defaultOverviewLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxOverviewLimit {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxOverviewLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	overview, err := h.store.GetOverview(caller.UserID, since, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load overview")
+		return
+	}
+	running, err := h.store.ListRunningSessions(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load overview")
+		return
+	}
+	notifications, err := h.store.ListInboxItems(caller.UserID, false, limit)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load overview")
+		return
+	}
+	unread, err := h.store.CountUnreadInboxItems(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load overview")
+		return
+	}
+
+	response := OverviewResponse{
+		Agents: OverviewAgents{
+			Total:   overview.Agents.Total,
+			Online:  overview.Agents.Online,
+			Stale:   overview.Agents.Stale,
+			Offline: overview.Agents.Offline,
+		},
+		Sessions: OverviewSessions{
+			Active:  overview.ActiveSessions,
+			Running: len(running),
+		},
+		Statuses: OverviewStatuses{
+			Since:     overview.Since,
+			Succeeded: overview.Succeeded,
+			Failed:    overview.Failed,
+		},
+		RecentFailures: make([]*OverviewFailure, 0, len(overview.RecentFailures)),
+		Running:        make([]*RunningSession, 0, limit),
+		Notifications: OverviewNotifications{
+			Unread: unread,
+			Recent: notifications,
+		},
+	}
+	for _, failure := range overview.RecentFailures {
+		response.RecentFailures = append(response.RecentFailures, &OverviewFailure{
+			AgentID:      failure.Status.AgentID,
+			AgentName:    failure.AgentName,
+			SessionTopic: failure.Status.SessionTopic,
+			Revision:     failure.Status.Revision,
+			Message:      failure.Status.Message,
+			Timestamp:    failure.Status.Timestamp,
+		})
+	}
+	for _, rs := range running {
+		if len(response.Running) == limit {
+			break
+		}
+		response.Running = append(response.Running, newRunningSession(rs, now))
+	}
+	if response.Notifications.Recent == nil {
+		response.Notifications.Recent = make([]*models.InboxItem, 0)
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestOverviewHandler_Get(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	now := time.Now().UTC()
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("item-%d", i)
+		st.CreateInboxItem(&models.InboxItem{
+			ID:        id,
+			UserID:    testsupport.UserID,
+			Kind:      models.InboxKindFailure,
+			AgentID:   "agent-001",
+			Message:   id,
+			DedupeKey: id,
+			Read:      i == 0,
+			CreatedAt: now.Add(time.Duration(i) * time.Second),
+		})
+	}
+	handler := NewOverviewHandler(st)
+
+	req := testsupport.WithUser(httptest.NewRequest("GET", "/api/overview?limit=2", nil))
+	rr := httptest.NewRecorder()
+	handler.Get(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Get() status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+
+	var response OverviewResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Agents.Total != 1 {
+		t.Errorf("agents = %+v, want one", response.Agents)
+	}
+	// task-003 failed and expired; task-001 is still running
+	if response.Sessions != (OverviewSessions{Active: 2, Running: 1}) {
+		t.Errorf("sessions = %+v, want 2 active and 1 running", response.Sessions)
+	}
+	if response.Statuses.Succeeded != 1 || response.Statuses.Failed != 1 {
+		t.Errorf("statuses = %+v, want 1 succeeded and 1 failed", response.Statuses)
+	}
+	if len(response.RecentFailures) != 1 || response.RecentFailures[0].SessionTopic != "task-003" || response.RecentFailures[0].Message != "Task failed" {
+		t.Errorf("recent failures = %+v, want task-003", response.RecentFailures)
+	}
+	if len(response.Running) != 1 || response.Running[0].SessionTopic != "task-001" {
+		t.Errorf("running = %+v, want task-001", response.Running)
+	}
+	if response.Notifications.Unread != 2 || len(response.Notifications.Recent) != 2 || response.Notifications.Recent[0].ID != "item-2" {
+		t.Errorf("notifications = %+v, want 2 unread and the newest 2", response.Notifications)
+	}
+}
+
+func TestOverviewHandler_Window(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewOverviewHandler(st)
+
+	// The fixture's final statuses are reported from 90 minutes on
+	since := time.Now().UTC().Add(2 * time.Hour).Format(time.RFC3339)
+	req := testsupport.WithUser(httptest.NewRequest("GET", "/api/overview?since="+since, nil))
+	rr := httptest.NewRecorder()
+	handler.Get(rr, req)
+
+	var response OverviewResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Statuses.Succeeded != 0 || response.Statuses.Failed != 1 || len(response.RecentFailures) != 1 {
+		t.Errorf("statuses = %+v with %d failures, want only the failure", response.Statuses, len(response.RecentFailures))
+	}
+
+	for _, query := range []string{"since=yesterday", "limit=0", "limit=51"} {
+		rr := httptest.NewRecorder()
+		handler.Get(rr, testsupport.WithUser(httptest.NewRequest("GET", "/api/overview?"+query, nil)))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Get(?%s) status = %v, want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// NotificationPolicyConfigKey is the system config key holding the JSON notification policy
+const NotificationPolicyConfigKey = "notification_policy"
+
+// PolicyHandler handles the deployment-wide notification policy
+type PolicyHandler struct {
+	store store.Store
+	clock clock.Clock
+}
+
+// NewPolicyHandler creates a new notification policy handler
+func NewPolicyHandler(st store.Store) *PolicyHandler {
+	return &PolicyHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps the notification policy
+func (h *PolicyHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// loadNotificationPolicy returns the stored notification policy, or an empty one when admins set none
+func loadNotificationPolicy(st store.Store) (*models.NotificationPolicy, error) {
+	raw, err := st.GetConfig(NotificationPolicyConfigKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return &models.NotificationPolicy{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var policy models.NotificationPolicy
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil {
+		return nil, fmt.Errorf("invalid stored notification policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Get returns the notification policy every member inherits
+func (h *PolicyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	policy, err := loadNotificationPolicy(h.store)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to load notification policy")
+		return
+	}
+	respondJSON(w, http.StatusOK, policy)
+}
+
+// Update replaces the notification policy; only admins may call it
+func (h *PolicyHandler) Update(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var policy models.NotificationPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	policy.WebhookURL = strings.TrimSpace(policy.WebhookURL)
+	if err := policy.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+	policy.UpdatedBy = caller.UserID
+	policy.UpdatedAt = h.clock.Now().UTC()
+
+	raw, err := json.Marshal(&policy)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to encode notification policy")
+		return
+	}
+	if err := h.store.SetConfig(NotificationPolicyConfigKey, string(raw)); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to save notification policy")
+		return
+	}
+	respondJSON(w, http.StatusOK, &policy)
+}
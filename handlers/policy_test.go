@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestPolicyHandler_UpdateAndGet(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewPolicyHandler(st)
+
+	rr := httptest.NewRecorder()
+	handler.Get(rr, httptest.NewRequest("GET", "/api/notification-policy", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Get() without a policy status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/notification-policy", bytes.NewReader([]byte(body)))
+		req = testsupport.WithUser(req)
+		rr := httptest.NewRecorder()
+		handler.Update(rr, req)
+		return rr
+	}
+
+	if rr := put(`{"webhook_url":"ftp://example.com"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Update() with an ftp URL status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+	if rr := put(`{"webhook_url":" https://example.com/fleet ","mentions":[{"chat_user_id":"U1"}],"allow_user_override":true}`); rr.Code != http.StatusOK {
+		t.Fatalf("Update() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.Get(rr, httptest.NewRequest("GET", "/api/notification-policy", nil))
+	var policy models.NotificationPolicy
+	if err := json.Unmarshal(rr.Body.Bytes(), &policy); err != nil {
+		t.Fatalf("decode policy: %v", err)
+	}
+	if policy.WebhookURL != "https://example.com/fleet" || !policy.AllowUserOverride || len(policy.Mentions) != 1 || policy.UpdatedBy != testsupport.UserID {
+		t.Errorf("Get() = %+v, want the saved policy", policy)
+	}
+}
+
+func TestWebhookHandler_NotificationPolicyInheritance(t *testing.T) {
+	tests := []struct {
+		name          string
+		allowOverride bool
+		userWebhook   string
+		muted         bool
+		want          []string
+	}{
+		{"inherited without own receivers", false, "", false, []string{"https://org.example.com/hook", "https://org.example.com/extra"}},
+		{"added to own receivers", false, "https://me.example.com", false, []string{"https://me.example.com", "https://org.example.com/hook", "https://org.example.com/extra"}},
+		{"replaced by own receivers", true, "https://me.example.com", false, []string{"https://me.example.com"}},
+		{"overridable but not overridden", true, "", false, []string{"https://org.example.com/hook", "https://org.example.com/extra"}},
+		{"mute cannot silence the policy", false, "https://me.example.com", true, []string{"https://org.example.com/hook", "https://org.example.com/extra"}},
+		{"mute silences an overridable policy", true, "", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemoryStore()
+			testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(tt.userWebhook))
+			raw, _ := json.Marshal(&models.NotificationPolicy{
+				WebhookURL:        "https://org.example.com/hook",
+				Destinations:      []models.NotificationDestination{{URL: "https://org.example.com/extra"}},
+				Mentions:          []models.MentionRule{{AgentID: "agent-001", ChatUserID: "U-ONCALL"}},
+				AllowUserOverride: tt.allowOverride,
+			})
+			if err := st.SetConfig(NotificationPolicyConfigKey, string(raw)); err != nil {
+				t.Fatalf("SetConfig() error = %v", err)
+			}
+			if tt.muted {
+				now := time.Now()
+				st.SaveWatchItem(&models.WatchItem{UserID: testsupport.UserID, AgentID: "agent-001", MuteNotifications: true, CreatedAt: now, UpdatedAt: now})
+			}
+
+			handler := NewWebhookHandlerWithNotifier(st, nil)
+			data := &notifier.NotificationData{AgentID: "agent-001", SessionTopic: "task-001", FromStatus: "running", ToStatus: "failed"}
+			var got []string
+			for _, destination := range handler.notificationDestinations(data, testsupport.UserID) {
+				got = append(got, destination.URL)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("destinations = %v, want %v", got, tt.want)
+			}
+			if len(tt.want) > 0 && (len(data.Mentions) != 1 || data.Mentions[0].UserID != "U-ONCALL") {
+				t.Errorf("mentions = %+v, want the policy's on-call mention", data.Mentions)
+			}
+		})
+	}
+}
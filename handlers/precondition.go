@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etagFor derives a strong ETag from a record's last modification time
+// Microsecond precision matches what PostgreSQL stores, so the tag survives a round trip.
+func etagFor(updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(updatedAt.UnixMicro(), 10) + `"`
+}
+
+// ifMatchSatisfied reports whether the request's If-Match header allows writing a record with the given ETag
+// A missing header or "*" always matches so existing clients keep working.
+func ifMatchSatisfied(r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModifiedSince reports whether a GET can be answered 304 because the representation has not changed since
+// the request's If-Modified-Since date
+// Last-Modified dates have one-second precision, so lastModified is truncated the same way. The header is
+// ignored when If-None-Match is present, as HTTP requires.
+func notModifiedSince(r *http.Request, lastModified time.Time) bool {
+	header := r.Header.Get("If-Modified-Since")
+	if header == "" || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// validationProblemType identifies problem details listing a request's invalid fields
+const validationProblemType = "urn:kubeagents:problem:validation"
+
+// ValidationProblem is an RFC 9457 problem detail answering a request whose fields failed validation
+// Error, and Message in /webhook responses, repeat the older error bodies so existing clients keep working.
+type ValidationProblem struct {
+	Type    string               `json:"type"`
+	Title   string               `json:"title"`
+	Status  int                  `json:"status"`
+	Detail  string               `json:"detail"`
+	Errors  []*models.FieldError `json:"errors"`
+	Error   string               `json:"error"`
+	Message string               `json:"message,omitempty"`
+}
+
+// newValidationProblem describes a validation error, or returns nil when it names no fields
+func newValidationProblem(err error) *ValidationProblem {
+	fieldErrs := models.FieldErrorsOf(err)
+	if len(fieldErrs) == 0 {
+		return nil
+	}
+	return &ValidationProblem{
+		Type:   validationProblemType,
+		Title:  "Request validation failed",
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+		Errors: fieldErrs,
+		Error:  err.Error(),
+	}
+}
+
+// respondProblem sends a problem detail as application/problem+json
+func respondProblem(w http.ResponseWriter, problem *ValidationProblem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// respondInvalid responds 400 to a request that failed validation
+// Field errors are listed in a problem detail; other errors get the plain error body.
+func respondInvalid(w http.ResponseWriter, err error) {
+	problem := newValidationProblem(err)
+	if problem == nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondProblem(w, problem)
+}
+
+// respondCodedInvalid responds like respondInvalid for handlers whose error bodies carry an error code and a
+// message, such as /webhook/status
+func respondCodedInvalid(w http.ResponseWriter, err error) {
+	problem := newValidationProblem(err)
+	if problem == nil {
+		respondJSON(w, http.StatusBadRequest, ErrorResponse{Error: "bad_request", Message: err.Error()})
+		return
+	}
+	problem.Error = "bad_request"
+	problem.Message = problem.Detail
+	respondProblem(w, problem)
+}
+
+// fieldPointer turns a dotted field name such as "notifications.mentions" into its JSON Pointer
+func fieldPointer(field string) string {
+	return "/" + strings.ReplaceAll(field, ".", "/")
+}
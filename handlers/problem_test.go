@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestWebhookHandler_ValidationProblem(t *testing.T) {
+	handler := NewWebhookHandlerWithNotifier(store.NewMemoryStore(), nil)
+	body := `{"session_topic":"task-001","status":"done","timestamp":"2024-01-15T12:00:00Z"}`
+	req := testsupport.WithUser(httptest.NewRequest("POST", "/webhook/status", bytes.NewReader([]byte(body))))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("status = %v, Content-Type = %q, want a 400 problem detail", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	var problem ValidationProblem
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+		t.Fatalf("decoding problem: %v", err)
+	}
+	if problem.Type != validationProblemType || problem.Status != http.StatusBadRequest || problem.Error != "bad_request" || problem.Message != problem.Detail {
+		t.Errorf("problem = %+v, want a validation problem keeping the webhook error body", problem)
+	}
+	if len(problem.Errors) != 2 || problem.Errors[0].Field != "/agent_id" || problem.Errors[1].Field != "/status" || problem.Errors[1].Code != "one_of" {
+		t.Errorf("errors = %+v, want agent_id and status", problem.Errors)
+	}
+}
+
+func TestRespondInvalid_NestedFields(t *testing.T) {
+	handler := NewNotificationSettingsHandler(store.NewMemoryStore())
+	body := `{"transitions":[{"from":"*","to":"failed"},{"from":"running","to":"done"}]}`
+	req := testsupport.WithUser(httptest.NewRequest("PUT", "/api/notifications/settings", bytes.NewReader([]byte(body))))
+	rr := httptest.NewRecorder()
+	handler.Update(rr, req)
+
+	var problem ValidationProblem
+	json.NewDecoder(rr.Body).Decode(&problem)
+	if rr.Code != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != "/transitions/1" {
+		t.Fatalf("status = %v, errors = %+v, want the second transition", rr.Code, problem.Errors)
+	}
+	if problem.Error != problem.Detail || problem.Detail == "" {
+		t.Errorf("error = %q, detail = %q, want the message in both", problem.Error, problem.Detail)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/realtime"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Reasons a WebSocket client may not follow an agent
+var (
+	errFollowNotFound = errors.New("agent not found")
+	errFollowDenied   = errors.New("access denied")
+)
+
+// RealtimeHandler streams live agent changes to the dashboard over WebSockets
+type RealtimeHandler struct {
+	store    store.Store
+	hub      *realtime.Hub
+	upgrader *realtime.Upgrader
+}
+
+// NewRealtimeHandler creates a new WebSocket handler accepting browsers on the server's own origin only
+func NewRealtimeHandler(st store.Store, hub *realtime.Hub) *RealtimeHandler {
+	return &RealtimeHandler{
+		store:    st,
+		hub:      hub,
+		upgrader: realtime.NewUpgrader(nil),
+	}
+}
+
+// SetAllowedOrigins accepts browsers on these origins too, which should be the API's CORS allow-list
+func (h *RealtimeHandler) SetAllowedOrigins(origins []string) {
+	h.upgrader = realtime.NewUpgrader(origins)
+}
+
+// ServeWS handles GET /ws, upgrading to a WebSocket that relays status events of the subscribed agents and sessions
+// Handshakes from other origins than the server's and the allowed ones are rejected with 403.
+// ?agent_id= and ?session_topic= subscribe right away; clients then send {"type":"subscribe"|"unsubscribe",
+// "agent_id":"...","session_topic":"..."} messages, where an empty session_topic covers every session of the agent.
+func (h *RealtimeHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	// Callers may only follow their own agents and those shared with their organizations
+	authorize := func(agentID string) error {
+		agent, err := h.store.GetAgent(agentID)
+		if err != nil {
+			return errFollowNotFound
+		}
+		if !canViewAgent(h.store, caller.UserID, agent) {
+			return errFollowDenied
+		}
+		return nil
+	}
+
+	var initial []realtime.Topic
+	agentID, sessionTopic := r.URL.Query().Get("agent_id"), r.URL.Query().Get("session_topic")
+	switch {
+	case agentID != "":
+		if err := authorize(agentID); err != nil {
+			status := http.StatusNotFound
+			if errors.Is(err, errFollowDenied) {
+				status = http.StatusForbidden
+			}
+			respondError(w, status, err.Error())
+			return
+		}
+		initial = append(initial, realtime.Topic{AgentID: agentID, SessionTopic: sessionTopic})
+	case sessionTopic != "":
+		respondError(w, http.StatusBadRequest, "session_topic requires agent_id")
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, realtime.MaxMessageSize)
+	if err != nil {
+		return
+	}
+	h.hub.Serve(conn, authorize, initial)
+}
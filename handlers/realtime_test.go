@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/realtime"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestRealtimeHandler_ServeWSChecksInitialTopic(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-001", UserID: testsupport.UserID, Name: "mine", Registered: now, LastSeen: now})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-002", UserID: "someone-else", Name: "theirs", Registered: now, LastSeen: now})
+	handler := NewRealtimeHandler(st, realtime.NewHub(events.NewBroker()))
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"missing agent", "?agent_id=agent-404", http.StatusNotFound},
+		{"foreign agent", "?agent_id=agent-002", http.StatusForbidden},
+		{"session without agent", "?session_topic=task-001", http.StatusBadRequest},
+		{"own agent without upgrade", "?agent_id=agent-001", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testsupport.WithUser(httptest.NewRequest("GET", "/ws"+tt.query, nil))
+			rr := httptest.NewRecorder()
+			handler.ServeWS(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("status = %v, want %v", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRealtimeHandler_ServeWSRejectsCrossOriginHandshakes(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-001", UserID: testsupport.UserID, Name: "mine", Registered: now, LastSeen: now})
+	handler := NewRealtimeHandler(st, realtime.NewHub(events.NewBroker()))
+	handler.SetAllowedOrigins([]string{"https://dashboard.example.com"})
+
+	req := testsupport.WithUser(httptest.NewRequest("GET", "/ws?agent_id=agent-001", nil))
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Origin", "https://attacker.example.net")
+	rr := httptest.NewRecorder()
+	handler.ServeWS(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("status = %v, want %v for a handshake from another origin", rr.Code, http.StatusForbidden)
+	}
+}
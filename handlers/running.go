@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// RunningSession is one row of the live board of running sessions
+type RunningSession struct {
+	AgentID        string    `json:"agent_id"`
+	AgentName      string    `json:"agent_name,omitempty"`
+	SessionTopic   string    `json:"session_topic"`
+	Group          string    `json:"group,omitempty"`
+	Category       string    `json:"category,omitempty"`
+	Revision       int       `json:"revision"`
+	Started        time.Time `json:"started"`
+	LastUpdated    time.Time `json:"last_updated"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"` // Since the first status of the current run
+	IdleSeconds    float64   `json:"idle_seconds"`    // Since the latest status
+	Message        string    `json:"message,omitempty"`
+	Progress       *float64  `json:"progress,omitempty"` // Percent complete, from the latest status's metadata
+}
+
+// ListRunning handles GET /api/running
+// It returns every running session across the caller's agents, longest running first.
+func (h *AgentHandler) ListRunning(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return
+	}
+
+	running, err := h.store.ListRunningSessions(caller.UserID)
+	if err != nil {
+		log.Printf("Failed to list running sessions: %v", err)
+		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to list running sessions")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	sessions := make([]*RunningSession, 0, len(running))
+	for _, rs := range running {
+		sessions = append(sessions, newRunningSession(rs, now))
+	}
+
+	legacyKey := h.legacyKey("sessions")
+	var extra map[string]interface{}
+	if legacyKey != "" {
+		extra = map[string]interface{}{"count": len(sessions)}
+	}
+	respondList(w, r, page, legacyKey, sessions, extra)
+}
+
+// newRunningSession returns the live board row of a running session as of now
+func newRunningSession(rs *models.RunningSession, now time.Time) *RunningSession {
+	return &RunningSession{
+		AgentID:        rs.Session.AgentID,
+		AgentName:      rs.AgentName,
+		SessionTopic:   rs.Session.SessionTopic,
+		Group:          rs.Session.Group,
+		Category:       rs.Session.Category,
+		Revision:       rs.Session.Revision,
+		Started:        rs.Started,
+		LastUpdated:    rs.Latest.Timestamp,
+		ElapsedSeconds: math.Max(0, now.Sub(rs.Started).Seconds()),
+		IdleSeconds:    math.Max(0, now.Sub(rs.Latest.Timestamp).Seconds()),
+		Message:        rs.Latest.Message,
+		Progress:       statusProgress(rs.Latest),
+	}
+}
+
+// statusProgress reads a numeric "progress" percentage from status metadata, clamped to 0-100
+func statusProgress(status *models.AgentStatus) *float64 {
+	if len(status.Metadata) == 0 {
+		return nil
+	}
+
+	var metadata struct {
+		Progress *float64 `json:"progress"`
+	}
+	if err := json.Unmarshal(status.Metadata, &metadata); err != nil || metadata.Progress == nil {
+		return nil
+	}
+
+	progress := math.Min(100, math.Max(0, *metadata.Progress))
+	return &progress
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestAgentHandler_ListRunning(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	// task-001 is the fixture's only running session; report progress on it
+	st.AddStatus(&models.AgentStatus{
+		AgentID:      "agent-001",
+		SessionTopic: "task-001",
+		Status:       "running",
+		Timestamp:    time.Now().Add(time.Minute),
+		Message:      "Indexing",
+		Metadata:     json.RawMessage(`{"progress":140}`),
+	})
+
+	req := testsupport.WithUser(httptest.NewRequest("GET", "/api/running", nil))
+	rr := httptest.NewRecorder()
+
+	handler.ListRunning(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("ListRunning() status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Sessions []*RunningSession `json:"sessions"`
+		Count    int               `json:"count"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("ListRunning() invalid JSON: %v", err)
+	}
+	if response.Count != 1 || len(response.Sessions) != 1 {
+		t.Fatalf("ListRunning() = %+v, want only task-001", response)
+	}
+
+	running := response.Sessions[0]
+	if running.SessionTopic != "task-001" || running.AgentName != "Test Agent" || running.Message != "Indexing" {
+		t.Errorf("ListRunning() session = %+v, want task-001 of Test Agent", running)
+	}
+	if running.Progress == nil || *running.Progress != 100 {
+		t.Errorf("ListRunning() progress = %v, want clamped to 100", running.Progress)
+	}
+	if !running.LastUpdated.After(running.Started) {
+		t.Errorf("ListRunning() last_updated = %v, want after started %v", running.LastUpdated, running.Started)
+	}
+}
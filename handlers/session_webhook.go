@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// SessionWebhookHandler handles the session webhook endpoints, registering automation callbacks for ended sessions
+type SessionWebhookHandler struct {
+	store store.Store
+	clock clock.Clock
+}
+
+// NewSessionWebhookHandler creates a new session webhook handler
+func NewSessionWebhookHandler(st store.Store) *SessionWebhookHandler {
+	return &SessionWebhookHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps session webhooks
+func (h *SessionWebhookHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// SessionWebhookRequest represents a request to register or replace a session webhook
+type SessionWebhookRequest struct {
+	URL      string   `json:"url"`
+	AgentID  string   `json:"agent_id,omitempty"`
+	Outcomes []string `json:"outcomes,omitempty"`
+}
+
+// SessionWebhookSecretResponse is a session webhook with its signing secret, shown only when it is generated
+type SessionWebhookSecretResponse struct {
+	*models.SessionWebhook
+	Secret string `json:"secret"`
+}
+
+// List handles listing the current user's session webhooks
+func (h *SessionWebhookHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hooks, err := h.store.ListSessionWebhooksByUser(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list session webhooks")
+		return
+	}
+
+	respondList(w, r, page, "", hooks, nil)
+}
+
+// Create handles registering a session webhook; the response carries its signing secret, which is not shown again
+func (h *SessionWebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req SessionWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	existing, err := h.store.ListSessionWebhooksByUser(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to create session webhook")
+		return
+	}
+	if len(existing) >= models.MaxSessionWebhooks {
+		respondError(w, http.StatusConflict, fmt.Sprintf("at most %d session webhooks can be registered", models.MaxSessionWebhooks))
+		return
+	}
+
+	secret, err := generateSigningSecret()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to generate signing secret")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	hook := &models.SessionWebhook{
+		ID:        uuid.New().String(),
+		UserID:    caller.UserID,
+		URL:       req.URL,
+		Secret:    secret,
+		AgentID:   req.AgentID,
+		Outcomes:  req.Outcomes,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := hook.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.CreateSessionWebhook(hook); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to create session webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, SessionWebhookSecretResponse{SessionWebhook: hook, Secret: secret})
+}
+
+// Get handles retrieving a single session webhook
+func (h *SessionWebhookHandler) Get(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, hook)
+}
+
+// Update handles replacing a session webhook's URL and filters; its secret is kept
+func (h *SessionWebhookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req SessionWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	updated := *hook
+	updated.URL = req.URL
+	updated.AgentID = req.AgentID
+	updated.Outcomes = req.Outcomes
+	updated.UpdatedAt = h.clock.Now().UTC()
+
+	if err := updated.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.UpdateSessionWebhook(&updated); err != nil {
+		respondStoreError(w, err, "session webhook not found", "failed to update session webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, &updated)
+}
+
+// RotateSecret handles replacing a session webhook's signing secret; deliveries are signed with the new one at once
+func (h *SessionWebhookHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	secret, err := generateSigningSecret()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to generate signing secret")
+		return
+	}
+
+	updated := *hook
+	updated.Secret = secret
+	updated.UpdatedAt = h.clock.Now().UTC()
+	if err := h.store.UpdateSessionWebhook(&updated); err != nil {
+		respondStoreError(w, err, "session webhook not found", "failed to rotate signing secret")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, SessionWebhookSecretResponse{SessionWebhook: &updated, Secret: secret})
+}
+
+// Delete handles removing a session webhook; deliveries still pending for it are dropped
+func (h *SessionWebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.loadOwnedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteSessionWebhook(hook.ID); err != nil {
+		respondStoreError(w, err, "session webhook not found", "failed to delete session webhook")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Session webhook deleted successfully",
+	})
+}
+
+// loadOwnedWebhook loads the session webhook named in the URL, writing an error response unless it belongs to the current user
+func (h *SessionWebhookHandler) loadOwnedWebhook(w http.ResponseWriter, r *http.Request) (*models.SessionWebhook, bool) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return nil, false
+	}
+
+	hook, err := h.store.GetSessionWebhook(chi.URLParam(r, "id"))
+	if err != nil {
+		respondStoreError(w, err, "session webhook not found", "failed to get session webhook")
+		return nil, false
+	}
+
+	// Report other users' webhooks as missing, matching SLA ownership checks
+	if hook.UserID != caller.UserID {
+		respondError(w, http.StatusNotFound, "session webhook not found")
+		return nil, false
+	}
+
+	return hook, true
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/outbox"
+	"github.com/kubeagents/kubeagents/sessionhook"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// sessionWebhookRouter routes the session webhook endpoints as the default test user, or bob when X-Test-User is set
+func sessionWebhookRouter(handler *SessionWebhookHandler) http.Handler {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("X-Test-User") == "bob" {
+				next.ServeHTTP(w, testsupport.WithCaller(req, "user-bob", "bob@example.com"))
+				return
+			}
+			next.ServeHTTP(w, testsupport.WithUser(req))
+		})
+	})
+	r.Get("/api/session-webhooks", handler.List)
+	r.Post("/api/session-webhooks", handler.Create)
+	r.Get("/api/session-webhooks/{id}", handler.Get)
+	r.Put("/api/session-webhooks/{id}", handler.Update)
+	r.Delete("/api/session-webhooks/{id}", handler.Delete)
+	r.Post("/api/session-webhooks/{id}/rotate-secret", handler.RotateSecret)
+	return r
+}
+
+// sessionWebhookRequest sends a request to the router, decoding a successful response into out
+func sessionWebhookRequest(t *testing.T, router http.Handler, user, method, path, body string, out interface{}) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if out != nil && rr.Code < 300 {
+		if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s response %s: %v", method, path, rr.Body.String(), err)
+		}
+	}
+	return rr.Code
+}
+
+func TestSessionWebhookHandler_Lifecycle(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	router := sessionWebhookRouter(NewSessionWebhookHandler(st))
+
+	for _, body := range []string{`{"url":"ftp://ci.example.com"}`, `{"url":"https://ci.example.com","outcomes":["done"]}`, `{`} {
+		if code := sessionWebhookRequest(t, router, "", "POST", "/api/session-webhooks", body, nil); code != http.StatusBadRequest {
+			t.Errorf("Create(%s) status = %v, want %v", body, code, http.StatusBadRequest)
+		}
+	}
+
+	var created SessionWebhookSecretResponse
+	body := `{"url":"https://ci.example.com/hooks","agent_id":"agent-001","outcomes":["failed"]}`
+	if code := sessionWebhookRequest(t, router, "", "POST", "/api/session-webhooks", body, &created); code != http.StatusCreated {
+		t.Fatalf("Create() status = %v, want %v", code, http.StatusCreated)
+	}
+	stored, err := st.GetSessionWebhook(created.ID)
+	if err != nil || created.Secret == "" || stored.Secret != created.Secret || stored.AgentID != "agent-001" {
+		t.Fatalf("Create() = %+v, stored %+v, %v, want the secret returned once and stored", created, stored, err)
+	}
+
+	var fetched map[string]interface{}
+	if code := sessionWebhookRequest(t, router, "", "GET", "/api/session-webhooks/"+created.ID, "", &fetched); code != http.StatusOK {
+		t.Fatalf("Get() status = %v, want %v", code, http.StatusOK)
+	}
+	if _, ok := fetched["secret"]; ok {
+		t.Errorf("Get() = %v, want the secret hidden", fetched)
+	}
+
+	var list struct {
+		Items []*models.SessionWebhook `json:"items"`
+		Total int                      `json:"total"`
+	}
+	if code := sessionWebhookRequest(t, router, "", "GET", "/api/session-webhooks", "", &list); code != http.StatusOK || list.Total != 1 {
+		t.Errorf("List() = %v, %+v, want the webhook", code, list)
+	}
+	if code := sessionWebhookRequest(t, router, "bob", "GET", "/api/session-webhooks", "", &list); code != http.StatusOK || list.Total != 0 {
+		t.Errorf("List() as bob = %v, %+v, want none", code, list)
+	}
+
+	// Other users' webhooks are indistinguishable from missing ones
+	for _, method := range []string{"GET", "PUT", "DELETE"} {
+		if code := sessionWebhookRequest(t, router, "bob", method, "/api/session-webhooks/"+created.ID, `{"url":"https://evil.example.com"}`, nil); code != http.StatusNotFound {
+			t.Errorf("%s as bob status = %v, want %v", method, code, http.StatusNotFound)
+		}
+	}
+
+	var updated models.SessionWebhook
+	if code := sessionWebhookRequest(t, router, "", "PUT", "/api/session-webhooks/"+created.ID, `{"url":"https://ci.example.com/v2"}`, &updated); code != http.StatusOK {
+		t.Fatalf("Update() status = %v, want %v", code, http.StatusOK)
+	}
+	if stored, _ := st.GetSessionWebhook(created.ID); stored.URL != "https://ci.example.com/v2" || stored.AgentID != "" || stored.Outcomes != nil || stored.Secret != created.Secret {
+		t.Errorf("Update() stored %+v, want the new URL, no filters and the same secret", stored)
+	}
+
+	var rotated SessionWebhookSecretResponse
+	if code := sessionWebhookRequest(t, router, "", "POST", "/api/session-webhooks/"+created.ID+"/rotate-secret", "", &rotated); code != http.StatusOK {
+		t.Fatalf("RotateSecret() status = %v, want %v", code, http.StatusOK)
+	}
+	if stored, _ := st.GetSessionWebhook(created.ID); rotated.Secret == created.Secret || stored.Secret != rotated.Secret {
+		t.Errorf("RotateSecret() = %s, stored %s, want a new secret", rotated.Secret, stored.Secret)
+	}
+
+	if code := sessionWebhookRequest(t, router, "", "DELETE", "/api/session-webhooks/"+created.ID, "", nil); code != http.StatusOK {
+		t.Errorf("Delete() status = %v, want %v", code, http.StatusOK)
+	}
+	if code := sessionWebhookRequest(t, router, "", "GET", "/api/session-webhooks/"+created.ID, "", nil); code != http.StatusNotFound {
+		t.Errorf("Get() after Delete() status = %v, want %v", code, http.StatusNotFound)
+	}
+}
+
+func TestSessionWebhookHandler_Limit(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	router := sessionWebhookRouter(NewSessionWebhookHandler(st))
+
+	for i := 0; i < models.MaxSessionWebhooks; i++ {
+		if code := sessionWebhookRequest(t, router, "", "POST", "/api/session-webhooks", `{"url":"https://ci.example.com"}`, nil); code != http.StatusCreated {
+			t.Fatalf("Create() #%d status = %v, want %v", i+1, code, http.StatusCreated)
+		}
+	}
+	if code := sessionWebhookRequest(t, router, "", "POST", "/api/session-webhooks", `{"url":"https://ci.example.com"}`, nil); code != http.StatusConflict {
+		t.Errorf("Create() over the limit status = %v, want %v", code, http.StatusConflict)
+	}
+}
+
+// sessionEvents collects the session events a test receiver is sent
+type sessionEvents struct {
+	mu     sync.Mutex
+	events []sessionhook.Event
+}
+
+func (e *sessionEvents) server(t *testing.T, secret string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if want := notifier.Sign(secret, r.Header.Get(notifier.TimestampHeader), body); r.Header.Get(notifier.SignatureHeader) != want {
+			t.Errorf("session event signature = %s, want %s", r.Header.Get(notifier.SignatureHeader), want)
+		}
+		var event sessionhook.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("session event %s: %v", body, err)
+		}
+		e.mu.Lock()
+		e.events = append(e.events, event)
+		e.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (e *sessionEvents) received() []sessionhook.Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]sessionhook.Event(nil), e.events...)
+}
+
+// createSessionWebhook registers a webhook of the default test user
+func createSessionWebhook(t *testing.T, st store.Store, url, secret string) {
+	t.Helper()
+
+	now := time.Now().UTC()
+	hook := &models.SessionWebhook{ID: "hook-1", UserID: testsupport.UserID, URL: url, Secret: secret, CreatedAt: now, UpdatedAt: now}
+	if err := st.CreateSessionWebhook(hook); err != nil {
+		t.Fatalf("CreateSessionWebhook() error = %v", err)
+	}
+}
+
+func TestWebhookHandler_SessionWebhooks(t *testing.T) {
+	var receiver sessionEvents
+	server := receiver.server(t, "whsec_test")
+
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	createSessionWebhook(t, st, server.URL, "whsec_test")
+	hooks := sessionhook.NewDispatcher(st, 5*time.Second)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetSessionHooks(hooks)
+
+	now := time.Now()
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "failed", now.Add(time.Minute), "Task failed", "")
+	// Repeating the final status does not end the run again
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "failed", now.Add(2*time.Minute), "Task failed", "")
+
+	if err := hooks.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	events := receiver.received()
+	if len(events) != 1 {
+		t.Fatalf("receiver got %d session events, want 1", len(events))
+	}
+	event := events[0]
+	if event.Outcome != models.SessionOutcomeFailed || event.Session == nil || event.Session.EndReason != models.EndReasonAgentReported ||
+		event.FinalStatus == nil || event.FinalStatus.Message != "Task failed" {
+		t.Errorf("session event = %+v, want the failed run with its final status", event)
+	}
+}
+
+func TestWebhookHandler_SessionWebhooksThroughOutbox(t *testing.T) {
+	var receiver sessionEvents
+	server := receiver.server(t, "whsec_test")
+
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	createSessionWebhook(t, st, server.URL, "whsec_test")
+	hooks := sessionhook.NewDispatcher(st, 5*time.Second)
+	relay := outbox.NewRelay(st, nil, nil, 3)
+	relay.SetSessionHooks(hooks)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetSessionHooks(hooks)
+	handler.SetOutbox(relay)
+
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "success", time.Now(), "", "")
+	if events := receiver.received(); len(events) != 0 {
+		t.Fatalf("receiver got %d session events before the relay ran, want 0", len(events))
+	}
+
+	if got := relay.Run(); got != 1 {
+		t.Errorf("Run() claimed %d messages, want the session webhook delivery", got)
+	}
+	if events := receiver.received(); len(events) != 1 || events[0].Outcome != models.SessionOutcomeSuccess {
+		t.Errorf("receiver got %+v, want the successful run", events)
+	}
+}
+
+func TestAgentHandler_CancelSessionDeliversSessionWebhooks(t *testing.T) {
+	var receiver sessionEvents
+	server := receiver.server(t, "whsec_test")
+
+	st := testsupport.StoreWithAgents(t, 1, 1)
+	createSessionWebhook(t, st, server.URL, "whsec_test")
+	hooks := sessionhook.NewDispatcher(st, 5*time.Second)
+	handler := NewAgentHandler(st)
+	handler.SetSessionHooks(hooks)
+
+	r := chi.NewRouter()
+	r.Post("/api/agents/{agent_id}/sessions/{session_topic}/cancel", handler.CancelSession)
+	req := testsupport.WithUser(httptest.NewRequest("POST", "/api/agents/agent-001/sessions/task-001/cancel", nil))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("CancelSession() status = %v, body = %s", rr.Code, rr.Body.String())
+	}
+
+	if err := hooks.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	events := receiver.received()
+	if len(events) != 1 || events[0].Outcome != models.SessionOutcomeCancelled || events[0].FinalStatus == nil || events[0].FinalStatus.Status != "running" {
+		t.Errorf("receiver got %+v, want the cancelled run with its latest status", events)
+	}
+}
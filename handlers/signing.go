@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// NotificationSigningConfigKey is the system config key holding the JSON notification signing secret
+const NotificationSigningConfigKey = "notification_signing_secret"
+
+// defaultSigningOverlap is how long a rotated-out secret keeps signing unless the rotation says otherwise
+const defaultSigningOverlap = 24 * time.Hour
+
+// signingSecretCacheTTL bounds how long other replicas keep signing with a secret after it was rotated or disabled
+const signingSecretCacheTTL = 30 * time.Second
+
+// loadSigningSecret returns the stored signing secret, or nil while signing is disabled
+func loadSigningSecret(st store.Store) (*models.NotificationSigningSecret, error) {
+	raw, err := st.GetConfig(NotificationSigningConfigKey)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && raw == "") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var secret models.NotificationSigningSecret
+	if err := json.Unmarshal([]byte(raw), &secret); err != nil {
+		return nil, fmt.Errorf("invalid stored signing secret: %w", err)
+	}
+	return &secret, nil
+}
+
+// SigningSecretSource supplies the notifier with the active signing secrets, caching them briefly
+type SigningSecretSource struct {
+	store store.Store
+	clock clock.Clock
+
+	mu       sync.Mutex
+	secret   *models.NotificationSigningSecret
+	loadedAt time.Time
+}
+
+// NewSigningSecretSource creates a source reading the signing secret from st
+func NewSigningSecretSource(st store.Store) *SigningSecretSource {
+	return &SigningSecretSource{store: st, clock: clock.Real}
+}
+
+// Secrets returns the secrets notifications are signed with now; when the store fails the last loaded ones are kept
+func (s *SigningSecretSource) Secrets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if s.loadedAt.IsZero() || now.Sub(s.loadedAt) >= signingSecretCacheTTL {
+		secret, err := loadSigningSecret(s.store)
+		if err != nil {
+			log.Printf("Failed to load notification signing secret: %v", err)
+		} else {
+			s.secret = secret
+		}
+		s.loadedAt = now
+	}
+	return s.secret.Active(now)
+}
+
+// set replaces the cached secret after this replica changed it
+func (s *SigningSecretSource) set(secret *models.NotificationSigningSecret) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secret = secret
+	s.loadedAt = s.clock.Now()
+}
+
+// SigningSecretView describes the signing secret without revealing it
+type SigningSecretView struct {
+	Enabled           bool       `json:"enabled"`
+	Prefix            string     `json:"prefix,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+	CreatedBy         string     `json:"created_by,omitempty"`
+	PreviousPrefix    string     `json:"previous_prefix,omitempty"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
+}
+
+// RotatedSigningSecret is the response to a rotation, the only one carrying the full new secret
+type RotatedSigningSecret struct {
+	SigningSecretView
+	Secret string `json:"secret"`
+}
+
+// RotateSigningSecretRequest is the request body of a rotation
+type RotateSigningSecretRequest struct {
+	// OverlapMinutes is how long the previous secret keeps signing; nil uses the default of a day
+	OverlapMinutes *int `json:"overlap_minutes,omitempty"`
+}
+
+// SigningHandler lets admins manage the secret outbound notifications are signed with
+// Its routes are audited like the other admin routes, so every rotation is recorded.
+type SigningHandler struct {
+	store  store.Store
+	source *SigningSecretSource
+	clock  clock.Clock
+}
+
+// NewSigningHandler creates a signing secret handler updating source on changes
+func NewSigningHandler(st store.Store, source *SigningSecretSource) *SigningHandler {
+	return &SigningHandler{store: st, source: source, clock: clock.Real}
+}
+
+// SetClock replaces the clock that stamps rotations and expires previous secrets
+func (h *SigningHandler) SetClock(c clock.Clock) {
+	h.clock = c
+	h.source.clock = c
+}
+
+// viewSigningSecret describes secret as of now, leaving out a previous secret that stopped signing
+func viewSigningSecret(secret *models.NotificationSigningSecret, now time.Time) SigningSecretView {
+	if secret == nil || secret.Secret == "" {
+		return SigningSecretView{}
+	}
+	createdAt := secret.CreatedAt
+	view := SigningSecretView{
+		Enabled:   true,
+		Prefix:    models.SigningSecretPrefix(secret.Secret),
+		CreatedAt: &createdAt,
+		CreatedBy: secret.CreatedBy,
+	}
+	if len(secret.Active(now)) > 1 {
+		view.PreviousPrefix = models.SigningSecretPrefix(secret.Previous)
+		view.PreviousExpiresAt = secret.PreviousExpiresAt
+	}
+	return view
+}
+
+// Get handles GET /api/admin/signing-secret, showing the prefixes of the secrets in use
+func (h *SigningHandler) Get(w http.ResponseWriter, r *http.Request) {
+	secret, err := loadSigningSecret(h.store)
+	if err != nil {
+		respondStoreError(w, err, "signing secret not found", "failed to load signing secret")
+		return
+	}
+	respondJSON(w, http.StatusOK, viewSigningSecret(secret, h.clock.Now().UTC()))
+}
+
+// Rotate handles POST /api/admin/signing-secret/rotate, generating a new secret and enabling signing
+// The current secret keeps signing for the overlap window; the new secret is only ever shown in this response.
+func (h *SigningHandler) Rotate(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	var req RotateSigningSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	overlap := defaultSigningOverlap
+	if req.OverlapMinutes != nil {
+		overlap = time.Duration(*req.OverlapMinutes) * time.Minute
+		if overlap < 0 || overlap > models.MaxSigningSecretOverlap {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("overlap_minutes must be 0-%d", int(models.MaxSigningSecretOverlap/time.Minute)))
+			return
+		}
+	}
+
+	current, err := loadSigningSecret(h.store)
+	if err != nil {
+		respondStoreError(w, err, "signing secret not found", "failed to load signing secret")
+		return
+	}
+	value, err := generateSigningSecret()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to generate signing secret")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	rotated := &models.NotificationSigningSecret{
+		Secret:    value,
+		CreatedAt: now,
+		CreatedBy: caller.Email,
+	}
+	if current != nil && current.Secret != "" && overlap > 0 {
+		expiresAt := now.Add(overlap)
+		rotated.Previous = current.Secret
+		rotated.PreviousExpiresAt = &expiresAt
+	}
+	if !h.save(w, rotated) {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, RotatedSigningSecret{
+		SigningSecretView: viewSigningSecret(rotated, now),
+		Secret:            value,
+	})
+}
+
+// Disable handles DELETE /api/admin/signing-secret, sending notifications unsigned from now on
+func (h *SigningHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	if !h.save(w, nil) {
+		return
+	}
+	respondJSON(w, http.StatusOK, SigningSecretView{})
+}
+
+// save stores secret, nil disabling signing, and updates this replica's source right away
+func (h *SigningHandler) save(w http.ResponseWriter, secret *models.NotificationSigningSecret) bool {
+	raw := ""
+	if secret != nil {
+		encoded, err := json.Marshal(secret)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "failed to encode signing secret")
+			return false
+		}
+		raw = string(encoded)
+	}
+	if err := h.store.SetConfig(NotificationSigningConfigKey, raw); err != nil {
+		respondStoreError(w, err, "signing secret not found", "failed to save signing secret")
+		return false
+	}
+	h.source.set(secret)
+	return true
+}
+
+// generateSigningSecret generates a random signing secret, prefixed so it is recognisable in configuration
+func generateSigningSecret() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(bytes), nil
+}
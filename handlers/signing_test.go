@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestSigningHandler_RotateAndDisable(t *testing.T) {
+	st := store.NewMemoryStore()
+	source := NewSigningSecretSource(st)
+	handler := NewSigningHandler(st, source)
+	fake := clock.NewFake(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	handler.SetClock(fake)
+	audited := NewAdminHandler(st).Audit(http.HandlerFunc(handler.Rotate))
+
+	get := func() SigningSecretView {
+		rr := httptest.NewRecorder()
+		handler.Get(rr, testsupport.WithUser(httptest.NewRequest("GET", "/api/admin/signing-secret", nil)))
+		var view SigningSecretView
+		if err := json.Unmarshal(rr.Body.Bytes(), &view); err != nil {
+			t.Fatalf("Get() invalid JSON: %v", err)
+		}
+		return view
+	}
+	rotate := func(body string) (int, RotatedSigningSecret) {
+		rr := httptest.NewRecorder()
+		audited.ServeHTTP(rr, testsupport.WithUser(httptest.NewRequest("POST", "/api/admin/signing-secret/rotate", bytes.NewReader([]byte(body)))))
+		var rotated RotatedSigningSecret
+		json.Unmarshal(rr.Body.Bytes(), &rotated)
+		return rr.Code, rotated
+	}
+
+	if view := get(); view.Enabled || len(source.Secrets()) != 0 {
+		t.Fatalf("Get() before a rotation = %+v, want signing disabled", view)
+	}
+
+	code, first := rotate("")
+	if code != http.StatusOK || !strings.HasPrefix(first.Secret, "whsec_") || first.Prefix != first.Secret[:12] || first.PreviousPrefix != "" {
+		t.Fatalf("Rotate() first = %d, %+v, want a new secret without a previous one", code, first)
+	}
+
+	code, second := rotate(`{"overlap_minutes":60}`)
+	if code != http.StatusOK || second.Secret == first.Secret || second.PreviousPrefix != first.Prefix {
+		t.Fatalf("Rotate() second = %d, %+v, want a new secret overlapping the first", code, second)
+	}
+	if secrets := source.Secrets(); len(secrets) != 2 || secrets[0] != second.Secret || secrets[1] != first.Secret {
+		t.Errorf("Secrets() during the overlap = %v, want the new and the previous secret", secrets)
+	}
+	if view := get(); !view.Enabled || view.Prefix != second.Prefix || view.PreviousExpiresAt == nil || !strings.Contains(toJSON(t, view), `"prefix"`) || strings.Contains(toJSON(t, view), second.Secret) {
+		t.Errorf("Get() = %+v, want prefixes only", view)
+	}
+
+	fake.Advance(time.Hour + signingSecretCacheTTL)
+	if secrets := source.Secrets(); len(secrets) != 1 || secrets[0] != second.Secret {
+		t.Errorf("Secrets() after the overlap = %v, want only the new secret", secrets)
+	}
+	if view := get(); view.PreviousPrefix != "" {
+		t.Errorf("Get() after the overlap previous prefix = %q, want none", view.PreviousPrefix)
+	}
+
+	if code, _ := rotate(`{"overlap_minutes":-1}`); code != http.StatusBadRequest {
+		t.Errorf("Rotate() negative overlap status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.Disable(rr, testsupport.WithUser(httptest.NewRequest("DELETE", "/api/admin/signing-secret", nil)))
+	if rr.Code != http.StatusOK || get().Enabled || len(source.Secrets()) != 0 {
+		t.Errorf("Disable() = %d, want signing disabled", rr.Code)
+	}
+
+	events, err := st.ListAuditEvents(time.Time{}, 0)
+	if err != nil || len(events) != 3 || events[0].ActorID != testsupport.UserID || !strings.HasPrefix(events[0].Action, "POST ") {
+		t.Errorf("ListAuditEvents() = %d events, %v, want every rotation recorded", len(events), err)
+	}
+}
+
+func toJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return string(raw)
+}
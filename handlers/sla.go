@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// defaultBreachWindow is how far back breaches are listed when no since parameter is given
+const defaultBreachWindow = 7 * 24 * time.Hour
+
+// SLAHandler handles SLA management endpoints
+type SLAHandler struct {
+	store store.Store
+	clock clock.Clock
+
+	listFormat
+}
+
+// NewSLAHandler creates a new SLA handler
+func NewSLAHandler(st store.Store) *SLAHandler {
+	return &SLAHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps SLAs and windows their breaches
+func (h *SLAHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// SLARequest represents a request to create or replace an SLA
+type SLARequest struct {
+	Name               string  `json:"name"`
+	AgentID            string  `json:"agent_id,omitempty"`
+	TopicPattern       string  `json:"topic_pattern,omitempty"`
+	MaxDurationMinutes int     `json:"max_duration_minutes,omitempty"`
+	MaxFailureRate     float64 `json:"max_failure_rate,omitempty"`
+}
+
+// List handles listing SLAs for the current user
+func (h *SLAHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slas, err := h.store.ListSLAsByUser(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list SLAs")
+		return
+	}
+
+	respondList(w, r, page, h.legacyKey("slas"), slas, nil)
+}
+
+// Create handles SLA creation
+func (h *SLAHandler) Create(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	var req SLARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	sla := &models.SLA{
+		ID:                 uuid.New().String(),
+		UserID:             caller.UserID,
+		Name:               req.Name,
+		AgentID:            req.AgentID,
+		TopicPattern:       req.TopicPattern,
+		MaxDurationMinutes: req.MaxDurationMinutes,
+		MaxFailureRate:     req.MaxFailureRate,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+
+	if err := sla.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.CreateSLA(sla); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to create SLA")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, sla)
+}
+
+// Get handles retrieving a single SLA
+func (h *SLAHandler) Get(w http.ResponseWriter, r *http.Request) {
+	sla, ok := h.loadOwnedSLA(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("ETag", etagFor(sla.UpdatedAt))
+	respondJSON(w, http.StatusOK, sla)
+}
+
+// Update handles replacing an SLA definition
+func (h *SLAHandler) Update(w http.ResponseWriter, r *http.Request) {
+	sla, ok := h.loadOwnedSLA(w, r)
+	if !ok {
+		return
+	}
+
+	if !ifMatchSatisfied(r, etagFor(sla.UpdatedAt)) {
+		respondError(w, http.StatusConflict, "SLA was modified since it was read")
+		return
+	}
+
+	var req SLARequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	updated := *sla
+	updated.Name = req.Name
+	updated.AgentID = req.AgentID
+	updated.TopicPattern = req.TopicPattern
+	updated.MaxDurationMinutes = req.MaxDurationMinutes
+	updated.MaxFailureRate = req.MaxFailureRate
+	updated.UpdatedAt = h.clock.Now().UTC()
+
+	if err := updated.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.UpdateSLA(&updated); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to update SLA")
+		return
+	}
+
+	w.Header().Set("ETag", etagFor(updated.UpdatedAt))
+	respondJSON(w, http.StatusOK, &updated)
+}
+
+// Delete handles deleting an SLA
+func (h *SLAHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	sla, ok := h.loadOwnedSLA(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.store.DeleteSLA(sla.ID); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to delete SLA")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "SLA deleted successfully",
+	})
+}
+
+// ListBreaches handles listing recorded breaches of an SLA
+func (h *SLAHandler) ListBreaches(w http.ResponseWriter, r *http.Request) {
+	sla, ok := h.loadOwnedSLA(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	since := h.clock.Now().UTC().Add(-defaultBreachWindow)
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "since must be an RFC3339 timestamp")
+			return
+		}
+		since = parsed
+	}
+
+	breaches, err := h.store.ListSLABreaches(sla.ID, since)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list SLA breaches")
+		return
+	}
+
+	respondList(w, r, page, h.legacyKey("breaches"), breaches, nil)
+}
+
+// loadOwnedSLA loads the SLA named in the URL, writing an error response unless it belongs to the current user
+func (h *SLAHandler) loadOwnedSLA(w http.ResponseWriter, r *http.Request) (*models.SLA, bool) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return nil, false
+	}
+
+	sla, err := h.store.GetSLA(chi.URLParam(r, "id"))
+	if err != nil {
+		respondStoreError(w, err, "SLA not found", "failed to get SLA")
+		return nil, false
+	}
+
+	// Report other users' SLAs as missing, matching API key ownership checks
+	if sla.UserID != caller.UserID {
+		respondError(w, http.StatusNotFound, "SLA not found")
+		return nil, false
+	}
+
+	return sla, true
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/compliance"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// withSLAID adds the {id} route parameter to the request
+func withSLAID(r *http.Request, id string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", id)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestSLAHandler_Create(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewSLAHandler(st)
+	createdAt := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	handler.SetClock(clock.NewFake(createdAt))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid sla", `{"name":"Backups","topic_pattern":"^backup","max_duration_minutes":60}`, http.StatusCreated},
+		{"no objectives", `{"name":"Backups"}`, http.StatusBadRequest},
+		{"invalid pattern", `{"name":"Backups","topic_pattern":"(","max_failure_rate":0.1}`, http.StatusBadRequest},
+		{"invalid json", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/slas", bytes.NewBufferString(tt.body))
+			req = testsupport.WithUser(req)
+			rr := httptest.NewRecorder()
+
+			handler.Create(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Create() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+
+	slas, _ := st.ListSLAsByUser(testsupport.UserID)
+	if len(slas) != 1 || !slas[0].CreatedAt.Equal(createdAt) {
+		t.Errorf("Create() stored %d SLAs, want 1 created at %v", len(slas), createdAt)
+	}
+}
+
+func TestSLAHandler_OtherUsersSLAIsNotFound(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewSLAHandler(st)
+
+	now := time.Now()
+	st.CreateSLA(&models.SLA{
+		ID:                 "sla-other",
+		UserID:             "other-user",
+		Name:               "Other",
+		MaxDurationMinutes: 60,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	})
+
+	req := withSLAID(testsupport.WithUser(httptest.NewRequest("DELETE", "/api/slas/sla-other", nil)), "sla-other")
+	rr := httptest.NewRecorder()
+
+	handler.Delete(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Delete() status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+	if _, err := st.GetSLA("sla-other"); err != nil {
+		t.Errorf("Delete() removed another user's SLA")
+	}
+}
+
+func TestAgentHandler_GetAgentIncludesSLACompliance(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+	handler.SetComplianceEvaluator(compliance.NewEvaluator(st, nil))
+
+	now := time.Now()
+	st.CreateSLA(&models.SLA{
+		ID:                 "sla-001",
+		UserID:             testsupport.UserID,
+		Name:               "Tasks",
+		MaxDurationMinutes: 600,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	})
+
+	req := httptest.NewRequest("GET", "/api/agents/agent-001", nil)
+	req = testsupport.WithUser(req)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("agent_id", "agent-001")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+
+	handler.GetAgent(rr, req)
+
+	var response AgentWithStats
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("GetAgent() invalid JSON: %v", err)
+	}
+	if len(response.SLACompliance) != 1 || response.SLACompliance[0].SLAID != "sla-001" {
+		t.Errorf("GetAgent() sla_compliance = %+v, want one entry for sla-001", response.SLACompliance)
+	}
+}
+
+func TestSLAHandler_UpdateIfMatch(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewSLAHandler(st)
+
+	updatedAt := time.Now().UTC().Add(-time.Hour)
+	st.CreateSLA(&models.SLA{
+		ID:                 "sla-001",
+		UserID:             testsupport.UserID,
+		Name:               "Backups",
+		MaxDurationMinutes: 60,
+		CreatedAt:          updatedAt,
+		UpdatedAt:          updatedAt,
+	})
+	current := etagFor(updatedAt)
+
+	tests := []struct {
+		name       string
+		ifMatch    string
+		wantStatus int
+	}{
+		{"stale etag", `"1"`, http.StatusConflict},
+		{"current etag", current, http.StatusOK},
+		// The previous update changed the ETag
+		{"reused etag", current, http.StatusConflict},
+		{"wildcard", "*", http.StatusOK},
+		{"no header", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := bytes.NewBufferString(`{"name":"Nightly backups","max_duration_minutes":90}`)
+			req := withSLAID(testsupport.WithUser(httptest.NewRequest("PUT", "/api/slas/sla-001", body)), "sla-001")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.Update(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Update() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if rr.Code == http.StatusOK && rr.Header().Get("ETag") == "" {
+				t.Error("Update() did not return an ETag")
+			}
+		})
+	}
+}
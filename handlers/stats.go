@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/healthscore"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// StatsHandler serves fleet-wide statistics for dashboards
+type StatsHandler struct {
+	store  store.Store
+	health *healthscore.Scorer
+}
+
+// NewStatsHandler creates a new stats handler; scorer may be nil when health scoring is disabled
+func NewStatsHandler(st store.Store, scorer *healthscore.Scorer) *StatsHandler {
+	return &StatsHandler{
+		store:  st,
+		health: scorer,
+	}
+}
+
+// Get handles returning the current user's health score with a per-agent breakdown
+// ?cluster= and ?region= narrow it to part of the fleet, and ?group_by=cluster or region adds per-location scores.
+// The maintained counts of the user's agents, sessions and statuses are included once they exist.
+func (h *StatsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	if h.health == nil {
+		respondError(w, http.StatusServiceUnavailable, "health scoring is disabled")
+		return
+	}
+
+	location, err := parseLocationFilter(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	score := h.health.ForUser(caller.UserID)
+	agents, fleetScore := score.Agents, score.Score
+	// ?cluster= and ?region= score the matching part of the fleet, the average of its agents
+	if location.filters() {
+		agents = make([]*healthscore.AgentScore, 0, len(score.Agents))
+		var total float64
+		for _, agent := range score.Agents {
+			if location.matches(agent.Cluster, agent.Region) {
+				agents = append(agents, agent)
+				total += agent.Score
+			}
+		}
+		fleetScore = 100
+		if len(agents) > 0 {
+			fleetScore = math.Round(total/float64(len(agents))*10) / 10
+		}
+	}
+
+	response := map[string]interface{}{
+		"health_score":  fleetScore,
+		"agents":        agents,
+		"calculated_at": score.CalculatedAt,
+	}
+	if location.groupBy != "" {
+		response["groups"] = location.groupScores(agents)
+	}
+	if counts := tenantCounts(h.store, caller.UserID); counts != nil {
+		response["counts"] = counts
+	}
+	respondJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/healthscore"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// newTestScorer scores the US3 fixture: one of its two finished sessions failed
+func newTestScorer(t *testing.T) (*healthscore.Scorer, *AgentHandler) {
+	t.Helper()
+	st := testsupport.StoreWithSessionStates(t)
+
+	scorer := healthscore.NewScorer(st, healthscore.Config{
+		FailureWeight: 0.5,
+		OfflineWeight: 0.3,
+		StuckWeight:   0.2,
+		Window:        24 * time.Hour,
+		OfflineAfter:  15 * time.Minute,
+		StuckAfter:    time.Hour,
+	})
+	scorer.Recalculate()
+
+	agentHandler := NewAgentHandler(st)
+	agentHandler.SetHealthScorer(scorer)
+	return scorer, agentHandler
+}
+
+func TestStatsHandler_Get(t *testing.T) {
+	scorer, _ := newTestScorer(t)
+
+	tests := []struct {
+		name       string
+		scorer     *healthscore.Scorer
+		wantStatus int
+	}{
+		{"scoring enabled", scorer, http.StatusOK},
+		{"scoring disabled", nil, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testsupport.WithUser(httptest.NewRequest("GET", "/api/stats", nil))
+			rr := httptest.NewRecorder()
+
+			NewStatsHandler(store.NewMemoryStore(), tt.scorer).Get(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Get() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				HealthScore float64                   `json:"health_score"`
+				Agents      []*healthscore.AgentScore `json:"agents"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Get() invalid JSON: %v", err)
+			}
+			if response.HealthScore != 75 || len(response.Agents) != 1 || response.Agents[0].FailureRate != 0.5 {
+				t.Errorf("Get() = %+v, want score 75 with agent-001 failing half its runs", response)
+			}
+		})
+	}
+}
+
+func TestStatsHandler_GetByLocation(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	testsupport.CreateAgent(t, st, "agent-002")
+	agent, _ := st.GetAgent("agent-001")
+	agent.Cluster, agent.Region = "prod-eu-1", "eu-west-1"
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	scorer := healthscore.NewScorer(st, healthscore.Config{FailureWeight: 1, Window: 24 * time.Hour})
+	scorer.Recalculate()
+	handler := NewStatsHandler(st, scorer)
+
+	get := func(query string) (int, map[string]interface{}) {
+		req := testsupport.WithUser(httptest.NewRequest("GET", "/api/stats?"+query, nil))
+		rr := httptest.NewRecorder()
+		handler.Get(rr, req)
+		var response map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr.Code, response
+	}
+
+	// agent-001 failed one of its two runs; agent-002 has none and scores 100
+	if code, response := get(""); code != http.StatusOK || response["health_score"] != 75.0 {
+		t.Errorf("Get() = %d %v, want the whole fleet scoring 75", code, response)
+	}
+	if code, response := get("region=eu-west-1"); code != http.StatusOK || response["health_score"] != 50.0 || len(response["agents"].([]interface{})) != 1 {
+		t.Errorf("Get(region=eu-west-1) = %d %v, want agent-001 alone scoring 50", code, response)
+	}
+	if code, response := get("region=ap-south-1"); code != http.StatusOK || response["health_score"] != 100.0 {
+		t.Errorf("Get(region=ap-south-1) = %d %v, want an empty fleet scoring 100", code, response)
+	}
+
+	code, response := get("group_by=cluster")
+	groups, _ := response["groups"].([]interface{})
+	if code != http.StatusOK || len(groups) != 2 {
+		t.Fatalf("Get(group_by=cluster) = %d %v, want 2 groups", code, response)
+	}
+	if first := groups[0].(map[string]interface{}); first["value"] != "" || first["health_score"] != 100.0 {
+		t.Errorf("Get(group_by=cluster) first group = %v, want agents without a cluster scoring 100", first)
+	}
+	if second := groups[1].(map[string]interface{}); second["value"] != "prod-eu-1" || second["agent_count"] != 1.0 || second["health_score"] != 50.0 {
+		t.Errorf("Get(group_by=cluster) second group = %v, want prod-eu-1 scoring 50", second)
+	}
+
+	if code, _ := get("group_by=zone"); code != http.StatusBadRequest {
+		t.Errorf("Get(group_by=zone) status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// Record counts appear once reconciled
+	if _, response := get(""); response["counts"] != nil {
+		t.Errorf("Get() counts = %v, want none before any are written", response["counts"])
+	}
+	if _, err := st.ReconcileTenantCounts(time.Now()); err != nil {
+		t.Fatalf("ReconcileTenantCounts() error = %v", err)
+	}
+	_, response = get("")
+	if counts, _ := response["counts"].(map[string]interface{}); counts["agents"] != 2.0 {
+		t.Errorf("Get() counts = %v, want both agents counted", response["counts"])
+	}
+}
+
+func TestAgentHandler_GetAgentIncludesHealthScore(t *testing.T) {
+	_, handler := newTestScorer(t)
+
+	req := testsupport.WithUser(httptest.NewRequest("GET", "/api/agents/agent-001?fields=agent_id,health_score", nil))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("agent_id", "agent-001")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+
+	handler.GetAgent(rr, req)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("GetAgent() invalid JSON: %v", err)
+	}
+	if response["health_score"] != 75.0 {
+		t.Errorf("GetAgent() health_score = %v, want 75, body = %s", response["health_score"], rr.Body.String())
+	}
+}
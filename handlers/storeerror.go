@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// storeErrorStatus maps the kind of a store error to the HTTP status code reported for it
+func storeErrorStatus(err error) int {
+	switch store.KindOf(err) {
+	case store.KindNotFound:
+		return http.StatusNotFound
+	case store.KindConflict:
+		return http.StatusConflict
+	case store.KindInvalid:
+		return http.StatusBadRequest
+	case store.KindUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondStoreError responds to a failed store call with the status code of its kind
+// notFound describes a missing record and failure any error whose text is not meant for clients;
+// conflicts and invalid records are described by the store error itself.
+func respondStoreError(w http.ResponseWriter, err error, notFound, failure string) {
+	code := storeErrorStatus(err)
+	switch code {
+	case http.StatusNotFound:
+		respondError(w, code, notFound)
+	case http.StatusConflict:
+		respondError(w, code, err.Error())
+	case http.StatusBadRequest:
+		respondInvalid(w, err)
+	case http.StatusServiceUnavailable:
+		log.Printf("Store unavailable: %v", err)
+		respondError(w, code, "service temporarily unavailable, retry later")
+	default:
+		log.Printf("Store error: %s: %v", failure, err)
+		respondError(w, code, failure)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestRespondStoreError(t *testing.T) {
+	tests := []struct {
+		err     error
+		code    int
+		message string
+	}{
+		{fmt.Errorf("get: %w", store.ErrNotFound), http.StatusNotFound, "thing not found"},
+		{store.ErrConflict, http.StatusConflict, "version conflict"},
+		{store.ErrDuplicateEmail, http.StatusConflict, "email already exists"},
+		{&store.Error{Kind: store.KindInvalid, Err: errors.New("name is required")}, http.StatusBadRequest, "name is required"},
+		{context.DeadlineExceeded, http.StatusServiceUnavailable, "service temporarily unavailable, retry later"},
+		{errors.New("boom"), http.StatusInternalServerError, "failed to get thing"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		respondStoreError(rr, tt.err, "thing not found", "failed to get thing")
+		var body map[string]string
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		if rr.Code != tt.code || body["error"] != tt.message {
+			t.Errorf("respondStoreError(%v) = %d %q, want %d %q", tt.err, rr.Code, body["error"], tt.code, tt.message)
+		}
+	}
+}
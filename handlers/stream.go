@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment so proxies keep the connection open
+const streamHeartbeat = 30 * time.Second
+
+// StreamHandler streams live agent changes to the dashboard
+type StreamHandler struct {
+	store  store.Store
+	events *events.Broker
+}
+
+// NewStreamHandler creates a new stream handler
+func NewStreamHandler(st store.Store, b *events.Broker) *StreamHandler {
+	return &StreamHandler{
+		store:  st,
+		events: b,
+	}
+}
+
+// AgentEvents handles GET /api/agents/{agent_id}/events, sending the agent's changes as server-sent events
+// The stream opens with a "ready" event once the subscription is live, so clients can load the agent's sessions
+// without missing a change, then sends a "status" event per recorded status until the client disconnects.
+func (h *StreamHandler) AgentEvents(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	agentID := chi.URLParam(r, "agent_id")
+	agent, err := h.store.GetAgent(agentID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "agent not found")
+		return
+	}
+	if !canViewAgent(h.store, caller.UserID, agent) {
+		respondError(w, http.StatusForbidden, "access denied")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	changes, unsubscribe := h.events.Subscribe(agentID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	writeEvent(w, "ready", map[string]string{"agent_id": agentID})
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-changes:
+			writeEvent(w, event.Type, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one server-sent event with a JSON payload
+func writeEvent(w http.ResponseWriter, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
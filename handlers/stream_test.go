@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestStreamHandler_AgentEvents(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	broker := events.NewBroker()
+	webhookHandler := NewWebhookHandlerWithNotifier(st, nil)
+	webhookHandler.SetEvents(broker)
+	handler := NewStreamHandler(st, broker)
+
+	now := time.Now()
+	testsupport.SendStatus(t, webhookHandler, "agent-001", "task-001", "running", now, "", "")
+
+	router := chi.NewRouter()
+	router.Get("/api/agents/{agent_id}/events", func(w http.ResponseWriter, r *http.Request) {
+		handler.AgentEvents(w, testsupport.WithUser(r))
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	if resp, err := http.Get(server.URL + "/api/agents/agent-404/events"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("GET unknown agent events = %v, %v, want 404", resp, err)
+	}
+
+	resp, err := http.Get(server.URL + "/api/agents/agent-001/events")
+	if err != nil {
+		t.Fatalf("GET stream error = %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("AgentEvents() Content-Type = %q, want text/event-stream", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() (string, string) {
+		t.Helper()
+		var event, data string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case line == "":
+				return event, data
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	if event, _ := readEvent(); event != "ready" {
+		t.Fatalf("AgentEvents() first event = %s, want ready", event)
+	}
+
+	testsupport.SendStatus(t, webhookHandler, "agent-001", "task-001", "success", now.Add(time.Minute), "done", "")
+
+	event, data := readEvent()
+	var got events.Event
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatalf("decode event: %v", err)
+	}
+	if event != events.TypeStatus || got.SessionTopic != "task-001" || got.FromStatus != "running" || got.Status != "success" || got.Message != "done" {
+		t.Errorf("AgentEvents() event = %s %+v, want the running -> success transition", event, got)
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Bounds of a usage export
+const (
+	defaultUsageDays = 30
+	maxUsageDays     = 366
+)
+
+// UsageHandler exports daily usage records
+type UsageHandler struct {
+	store store.Store
+	clock clock.Clock
+
+	listFormat
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(st store.Store) *UsageHandler {
+	return &UsageHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that resolves default usage ranges
+func (h *UsageHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// List handles GET /api/usage, exporting the current user's daily usage
+func (h *UsageHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	h.export(w, r, caller.UserID, caller.UserID)
+}
+
+// ListAll handles GET /api/admin/usage, exporting the daily usage of every user; only admins may call it
+func (h *UsageHandler) ListAll(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	h.export(w, r, "", caller.UserID)
+}
+
+// export writes the usage of a user, or of every user, for the requested days as JSON or CSV
+// from and to are inclusive dates (YYYY-MM-DD) defaulting to the last 30 days; format=csv selects CSV,
+// encrypted with the export key of callerID. The JSON of a single user also holds their record counts.
+func (h *UsageHandler) export(w http.ResponseWriter, r *http.Request, userID, callerID string) {
+	from, to, err := parseUsageRange(r, h.clock.Now())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		respondError(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// to is inclusive for callers but exclusive in the store
+	records, err := h.store.ListUsage(userID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list usage")
+		return
+	}
+
+	if format == "csv" {
+		key, ok := exportKey(w, r, h.store, callerID)
+		if !ok {
+			return
+		}
+		respondExport(w, key, "text/csv; charset=utf-8", "usage.csv", usageCSV(records))
+		return
+	}
+	var extra map[string]interface{}
+	if userID != "" {
+		if counts := tenantCounts(h.store, userID); counts != nil {
+			extra = map[string]interface{}{"counts": counts}
+		}
+	}
+	respondList(w, r, page, h.legacyKey("usage"), records, extra)
+}
+
+// tenantCounts returns the maintained counts of a user's records, or nil before they are first written
+// Reading the counts replaces counting the user's sessions and statuses, which is slow for large tenants.
+func tenantCounts(st store.Store, userID string) *models.TenantCounts {
+	counts, err := st.GetTenantCounts(userID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to get record counts of user %s: %v", userID, err)
+		}
+		return nil
+	}
+	return counts
+}
+
+// parseUsageRange reads the inclusive from and to dates of a usage export or rollup listing
+func parseUsageRange(r *http.Request, now time.Time) (from, to time.Time, err error) {
+	to = models.UsageDay(now)
+	if raw := r.URL.Query().Get("to"); raw != "" {
+		if to, err = time.Parse(time.DateOnly, raw); err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a date in YYYY-MM-DD format")
+		}
+	}
+	from = to.AddDate(0, 0, 1-defaultUsageDays)
+	if raw := r.URL.Query().Get("from"); raw != "" {
+		if from, err = time.Parse(time.DateOnly, raw); err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be a date in YYYY-MM-DD format")
+		}
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	if to.Sub(from) >= maxUsageDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.New("at most 366 days can be requested at a time")
+	}
+	return from, to, nil
+}
+
+// usageCSV renders usage records as CSV with a header row
+func usageCSV(records []*models.UsageRecord) []byte {
+	var buf bytes.Buffer
+	out := csv.NewWriter(&buf)
+	out.Write([]string{"user_id", "day", "status_reports", "storage_bytes", "notifications_sent"})
+	for _, record := range records {
+		out.Write([]string{
+			record.UserID,
+			record.Day.Format(time.DateOnly),
+			strconv.FormatInt(record.StatusReports, 10),
+			strconv.FormatInt(record.StorageBytes, 10),
+			strconv.FormatInt(record.NotificationsSent, 10),
+		})
+	}
+	out.Flush()
+	return buf.Bytes()
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/metering"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestUsageHandler_ExportsMeteredUsage(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	meter := metering.NewMeter(st)
+	webhookHandler := NewWebhookHandlerWithNotifier(st, nil)
+	webhookHandler.SetMeter(meter)
+
+	now := time.Now()
+	testsupport.SendStatus(t, webhookHandler, "agent-001", "task-001", "running", now, "hello", "")
+	testsupport.SendStatus(t, webhookHandler, "agent-001", "task-001", "success", now, "", "done!")
+	meter.Flush()
+
+	handler := NewUsageHandler(st)
+	get := func(query string) *httptest.ResponseRecorder {
+		req := testsupport.WithUser(httptest.NewRequest("GET", "/api/usage"+query, nil))
+		rr := httptest.NewRecorder()
+		handler.List(rr, req)
+		return rr
+	}
+
+	rr := get("")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("List() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var resp struct {
+		Items  []models.UsageRecord `json:"items"`
+		Counts *models.TenantCounts `json:"counts"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].StatusReports != 2 || resp.Items[0].StorageBytes != 10 {
+		t.Errorf("List() items = %+v, want today's 2 reports of 10 bytes", resp.Items)
+	}
+	if c := resp.Counts; c == nil || c.Agents != 1 || c.Sessions != 1 || c.Statuses != 2 {
+		t.Errorf("List() counts = %+v, want the 1 agent, 1 session and 2 statuses the reports created", c)
+	}
+
+	rr = get("?format=csv")
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	today := models.UsageDay(now).Format(time.DateOnly)
+	if len(lines) != 2 || lines[0] != "user_id,day,status_reports,storage_bytes,notifications_sent" || lines[1] != testsupport.UserID+","+today+",2,10,0" {
+		t.Errorf("List() CSV = %q, want a header and today's row", rr.Body.String())
+	}
+
+	for _, query := range []string{"?from=yesterday", "?from=2026-03-02&to=2026-03-01", "?from=2024-01-01&to=2026-01-01", "?format=xml"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("List(%s) status = %v, want %v", query, rr.Code, http.StatusBadRequest)
+		}
+	}
+}
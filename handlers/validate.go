@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/middleware"
+)
+
+// ValidateResponse echoes a status report that /webhook/status would accept
+type ValidateResponse struct {
+	Valid  bool                   `json:"valid"`
+	Report *internal.StatusReport `json:"report"`
+	Limits internal.PayloadLimits `json:"limits"` // Payload limits of the caller's plan
+}
+
+// ServeValidate handles POST /webhook/validate requests
+// The body is checked exactly like a status report, including the caller's payload limits, but nothing is
+// stored and no notification is sent. Rejected payloads get the same status code and error as /webhook/status.
+func (h *WebhookHandler) ServeValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
+		return
+	}
+
+	limits := h.payloadLimitsFor(caller)
+	statusReport, ok := h.decodeStatusReport(w, r, caller, limits)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ValidateResponse{
+		Valid:  true,
+		Report: statusReport,
+		Limits: limits,
+	})
+}
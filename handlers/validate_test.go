@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestWebhookHandler_ValidateStoresNothing(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	body := `{"agent_id":"agent-001","session_topic":"task-001","status":"running","timestamp":"2026-01-15T10:30:00Z","metadata":{"attempt":1}}`
+	req := httptest.NewRequest("POST", "/webhook/validate", bytes.NewReader([]byte(body)))
+	req = testsupport.WithUser(req)
+	rr := httptest.NewRecorder()
+	handler.ServeValidate(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+	var resp ValidateResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.Valid || resp.Report.AgentID != "agent-001" || resp.Limits != internal.DefaultPayloadLimits() {
+		t.Errorf("response = %+v, want the valid report with the default limits", resp)
+	}
+	if _, err := st.GetAgent("agent-001"); err == nil {
+		t.Error("GetAgent() found the agent, want validation to store nothing")
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// WatchlistHandler handles starring agents and watching sessions
+type WatchlistHandler struct {
+	store store.Store
+	clock clock.Clock
+}
+
+// NewWatchlistHandler creates a new watchlist handler
+func NewWatchlistHandler(st store.Store) *WatchlistHandler {
+	return &WatchlistHandler{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that timestamps watch items
+func (h *WatchlistHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// WatchRequest represents the notification overrides of a watch item
+type WatchRequest struct {
+	NotificationWebhookURL string `json:"notification_webhook_url,omitempty"`
+	MuteNotifications      bool   `json:"mute_notifications,omitempty"`
+}
+
+// List handles listing the current user's starred agents and watched sessions
+func (h *WatchlistHandler) List(w http.ResponseWriter, r *http.Request) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	page, err := parseListPage(r, 0, maxListLimit)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, err := h.store.ListWatchItems(caller.UserID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to list watchlist")
+		return
+	}
+
+	respondList(w, r, page, "items", items, nil)
+}
+
+// StarAgent handles starring an agent, replacing its notification overrides
+func (h *WatchlistHandler) StarAgent(w http.ResponseWriter, r *http.Request) {
+	h.save(w, r, "")
+}
+
+// UnstarAgent handles removing an agent's star
+func (h *WatchlistHandler) UnstarAgent(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, "")
+}
+
+// WatchSession handles watching a session, replacing its notification overrides
+func (h *WatchlistHandler) WatchSession(w http.ResponseWriter, r *http.Request) {
+	h.save(w, r, chi.URLParam(r, "session_topic"))
+}
+
+// UnwatchSession handles removing a session from the watchlist
+func (h *WatchlistHandler) UnwatchSession(w http.ResponseWriter, r *http.Request) {
+	h.remove(w, r, chi.URLParam(r, "session_topic"))
+}
+
+// save creates or replaces the watch item for the agent in the URL and sessionTopic
+func (h *WatchlistHandler) save(w http.ResponseWriter, r *http.Request, sessionTopic string) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	agentID := chi.URLParam(r, "agent_id")
+	if !h.canWatchTarget(w, caller.UserID, agentID, sessionTopic) {
+		return
+	}
+
+	// The body is optional; an empty one watches without overrides
+	var req WatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validateWebhookURL(req.NotificationWebhookURL); err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := h.clock.Now().UTC()
+	item := &models.WatchItem{
+		UserID:                 caller.UserID,
+		AgentID:                agentID,
+		SessionTopic:           sessionTopic,
+		NotificationWebhookURL: req.NotificationWebhookURL,
+		MuteNotifications:      req.MuteNotifications,
+		CreatedAt:              now,
+		UpdatedAt:              now,
+	}
+
+	status := http.StatusCreated
+	if existing, err := h.store.GetWatchItem(caller.UserID, agentID, sessionTopic); err == nil {
+		item.CreatedAt = existing.CreatedAt
+		status = http.StatusOK
+	} else if !errors.Is(err, store.ErrNotFound) {
+		respondError(w, http.StatusInternalServerError, "failed to save watch item")
+		return
+	}
+
+	if err := item.Validate(); err != nil {
+		respondInvalid(w, err)
+		return
+	}
+
+	if err := h.store.SaveWatchItem(item); err != nil {
+		respondError(w, http.StatusInternalServerError, "failed to save watch item")
+		return
+	}
+
+	respondJSON(w, status, item)
+}
+
+// remove deletes the watch item for the agent in the URL and sessionTopic
+func (h *WatchlistHandler) remove(w http.ResponseWriter, r *http.Request, sessionTopic string) {
+	caller, ok := middleware.GetRequestContext(r.Context())
+	if !ok {
+		respondError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	if err := h.store.DeleteWatchItem(caller.UserID, chi.URLParam(r, "agent_id"), sessionTopic); err != nil {
+		respondStoreError(w, err, "watch item not found", "failed to delete watch item")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Watch item removed successfully",
+	})
+}
+
+// canWatchTarget writes an error response unless the agent, and the session if given, exist and the user may
+// view the agent, as its owner or a member of the organization it is shared with
+func (h *WatchlistHandler) canWatchTarget(w http.ResponseWriter, userID, agentID, sessionTopic string) bool {
+	agent, err := h.store.GetAgent(agentID)
+	if err != nil || !canViewAgent(h.store, userID, agent) {
+		respondError(w, http.StatusNotFound, "agent not found")
+		return false
+	}
+
+	if sessionTopic != "" {
+		if _, err := h.store.GetSession(agentID, sessionTopic); err != nil {
+			respondError(w, http.StatusNotFound, "session not found")
+			return false
+		}
+	}
+
+	return true
+}
+
+// watchSet indexes a user's watch items to place watched agents and sessions first in lists
+type watchSet map[string]bool
+
+// loadWatchSet loads the user's watch items; ordering is best effort, so failures yield an empty set
+func loadWatchSet(st store.Store, userID string) watchSet {
+	items, err := st.ListWatchItems(userID)
+	if err != nil {
+		log.Printf("Failed to load watchlist: %v", err)
+		return nil
+	}
+
+	set := make(watchSet, len(items))
+	for _, item := range items {
+		set[item.AgentID+"|"+item.SessionTopic] = true
+	}
+	return set
+}
+
+// starred reports whether the agent is starred
+func (s watchSet) starred(agentID string) bool {
+	return s[agentID+"|"]
+}
+
+// watched reports whether the session is watched
+func (s watchSet) watched(agentID, sessionTopic string) bool {
+	return s[agentID+"|"+sessionTopic]
+}
+
+// anyStarred reports whether the user starred any agent
+func (s watchSet) anyStarred() bool {
+	for key := range s {
+		if strings.HasSuffix(key, "|") {
+			return true
+		}
+	}
+	return false
+}
+
+// anyWatched reports whether the user watches any of the agent's sessions
+func (s watchSet) anyWatched(agentID string) bool {
+	for key := range s {
+		if topic, ok := strings.CutPrefix(key, agentID+"|"); ok && topic != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationTarget resolves the webhook URL for a session's status notifications
+// A watched session's overrides win over its agent's star, which win over the user's URL;
+// ok is false when the notification is muted.
+func notificationTarget(st store.Store, user *models.User, agentID, sessionTopic string) (webhookURL string, ok bool) {
+	for _, topic := range []string{sessionTopic, ""} {
+		item, err := st.GetWatchItem(user.ID, agentID, topic)
+		if err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				log.Printf("Failed to load watch item for notification: %v", err)
+			}
+			continue
+		}
+		if item.MuteNotifications {
+			return "", false
+		}
+		if item.NotificationWebhookURL != "" {
+			return item.NotificationWebhookURL, true
+		}
+	}
+	return user.NotificationWebhookURL, true
+}
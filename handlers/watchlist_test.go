@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// withWatchTarget adds the {agent_id} and optional {session_topic} route parameters to the request
+func withWatchTarget(r *http.Request, agentID, sessionTopic string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("agent_id", agentID)
+	if sessionTopic != "" {
+		rctx.URLParams.Add("session_topic", sessionTopic)
+	}
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestWatchlistHandler_Save(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewWatchlistHandler(st)
+
+	now := time.Now()
+	st.CreateOrUpdateAgent(&models.Agent{
+		AgentID:    "agent-other",
+		UserID:     "other-user",
+		Name:       "Other",
+		Registered: now,
+		LastSeen:   now,
+	})
+	testsupport.CreateOrg(t, st, "org-1", map[string]string{"other-user": models.OrgRoleOwner, testsupport.UserID: models.OrgRoleViewer})
+	testsupport.CreateAgent(t, st, "agent-shared", testsupport.OwnedBy("other-user"), testsupport.SharedWith("org-1"))
+
+	tests := []struct {
+		name       string
+		agentID    string
+		topic      string
+		body       string
+		wantStatus int
+	}{
+		{"star agent", "agent-001", "", "", http.StatusCreated},
+		{"restar agent", "agent-001", "", `{"mute_notifications":true}`, http.StatusOK},
+		{"watch session", "agent-001", "task-002", `{"notification_webhook_url":"https://example.com/hook"}`, http.StatusCreated},
+		{"invalid webhook url", "agent-001", "task-001", `{"notification_webhook_url":"ftp://example.com"}`, http.StatusBadRequest},
+		{"unknown session", "agent-001", "task-404", "", http.StatusNotFound},
+		{"other user's agent", "agent-other", "", "", http.StatusNotFound},
+		{"agent shared with the user's organization", "agent-shared", "", "", http.StatusCreated},
+		{"invalid json", "agent-001", "", `{`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/api/watchlist", bytes.NewBufferString(tt.body))
+			req = withWatchTarget(testsupport.WithUser(req), tt.agentID, tt.topic)
+			rr := httptest.NewRecorder()
+
+			if tt.topic == "" {
+				handler.StarAgent(rr, req)
+			} else {
+				handler.WatchSession(rr, req)
+			}
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("save status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+
+	items, _ := st.ListWatchItems(testsupport.UserID)
+	if len(items) != 3 {
+		t.Fatalf("save stored %d items, want 3", len(items))
+	}
+	star, _ := st.GetWatchItem(testsupport.UserID, "agent-001", "")
+	if !star.MuteNotifications {
+		t.Errorf("restar MuteNotifications = false, want true")
+	}
+
+	req := withWatchTarget(testsupport.WithUser(httptest.NewRequest("DELETE", "/api/watchlist", nil)), "agent-001", "task-002")
+	rr := httptest.NewRecorder()
+	handler.UnwatchSession(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("UnwatchSession() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.UnwatchSession(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("UnwatchSession() again status = %v, want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestAgentHandler_WatchedSessionsFirst(t *testing.T) {
+	st := testsupport.StoreWithSessionStates(t)
+	handler := NewAgentHandler(st)
+
+	now := time.Now()
+	st.SaveWatchItem(&models.WatchItem{
+		UserID:       testsupport.UserID,
+		AgentID:      "agent-001",
+		SessionTopic: "task-003",
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+
+	req := withWatchTarget(testsupport.WithUser(httptest.NewRequest("GET", "/api/agents/agent-001/sessions", nil)), "agent-001", "")
+	rr := httptest.NewRecorder()
+
+	handler.ListSessions(rr, req)
+
+	var response struct {
+		Sessions []SessionWithStatus `json:"sessions"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("ListSessions() decode error = %v", err)
+	}
+	if len(response.Sessions) != 3 {
+		t.Fatalf("ListSessions() returned %d sessions, want 3", len(response.Sessions))
+	}
+	if first := response.Sessions[0]; first.SessionTopic != "task-003" || !first.Watched {
+		t.Errorf("ListSessions() first = %s (watched %v), want task-003 (watched true)", first.SessionTopic, first.Watched)
+	}
+	for _, session := range response.Sessions[1:] {
+		if session.Watched {
+			t.Errorf("ListSessions() %s watched = true, want false", session.SessionTopic)
+		}
+	}
+}
+
+func TestNotificationTarget(t *testing.T) {
+	user := &models.User{ID: testsupport.UserID, NotificationWebhookURL: "https://example.com/user"}
+
+	tests := []struct {
+		name    string
+		items   []*models.WatchItem
+		wantURL string
+		wantOK  bool
+	}{
+		{"no overrides", nil, "https://example.com/user", true},
+		{
+			"agent override",
+			[]*models.WatchItem{{AgentID: "agent-001", NotificationWebhookURL: "https://example.com/agent"}},
+			"https://example.com/agent", true,
+		},
+		{
+			"session override wins",
+			[]*models.WatchItem{
+				{AgentID: "agent-001", NotificationWebhookURL: "https://example.com/agent"},
+				{AgentID: "agent-001", SessionTopic: "task-001", NotificationWebhookURL: "https://example.com/session"},
+			},
+			"https://example.com/session", true,
+		},
+		{
+			"session without override inherits agent",
+			[]*models.WatchItem{
+				{AgentID: "agent-001", NotificationWebhookURL: "https://example.com/agent"},
+				{AgentID: "agent-001", SessionTopic: "task-001"},
+			},
+			"https://example.com/agent", true,
+		},
+		{
+			"muted agent",
+			[]*models.WatchItem{{AgentID: "agent-001", MuteNotifications: true}},
+			"", false,
+		},
+		{
+			"other session ignored",
+			[]*models.WatchItem{{AgentID: "agent-001", SessionTopic: "task-002", MuteNotifications: true}},
+			"https://example.com/user", true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := testsupport.StoreWithSessionStates(t)
+			for _, item := range tt.items {
+				item.UserID = testsupport.UserID
+				st.SaveWatchItem(item)
+			}
+
+			gotURL, gotOK := notificationTarget(st, user, "agent-001", "task-001")
+			if gotURL != tt.wantURL || gotOK != tt.wantOK {
+				t.Errorf("notificationTarget() = %q, %v, want %q, %v", gotURL, gotOK, tt.wantURL, tt.wantOK)
+			}
+		})
+	}
+}
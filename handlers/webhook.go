@@ -1,16 +1,28 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/kubeagents/kubeagents/alerting"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/inbox"
 	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/metering"
 	"github.com/kubeagents/kubeagents/middleware"
 	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/outbox"
+	"github.com/kubeagents/kubeagents/sessionhook"
 	"github.com/kubeagents/kubeagents/store"
 )
 
@@ -18,6 +30,23 @@ import (
 type WebhookHandler struct {
 	store    store.Store
 	notifier *notifier.NotificationManager
+	grouper  *internal.TopicGrouper
+	limits   *internal.PayloadLimitPolicy
+	inbox    *inbox.Inbox
+	outbox   *outbox.Relay
+	hooks    *sessionhook.Dispatcher
+	events   *events.Broker
+	meter    *metering.Meter
+	alerts   *alerting.Engine
+
+	reopenGrace    time.Duration
+	defaultKind    string
+	idempotencyTTL time.Duration
+	clock          clock.Clock
+	budget         *latencyBudget
+
+	heartbeatMu sync.Mutex
+	heartbeats  map[string]int // session run -> heartbeats dropped since the last stored one
 }
 
 // NewWebhookHandlerWithNotifier creates a new webhook handler with notifications
@@ -25,13 +54,90 @@ func NewWebhookHandlerWithNotifier(s store.Store, n *notifier.NotificationManage
 	return &WebhookHandler{
 		store:    s,
 		notifier: n,
+		clock:    clock.Real,
+	}
+}
+
+// SetClock replaces the clock that stamps statuses and decides whether expired sessions re-open
+func (h *WebhookHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+// now returns the current time in UTC
+func (h *WebhookHandler) now() time.Time {
+	return h.clock.Now().UTC()
+}
+
+// SetTopicGrouper configures the rules used to derive session group and category from topics
+func (h *WebhookHandler) SetTopicGrouper(g *internal.TopicGrouper) {
+	h.grouper = g
+}
+
+// SetPayloadLimits configures per-deployment and per-plan payload size limits
+func (h *WebhookHandler) SetPayloadLimits(p *internal.PayloadLimitPolicy) {
+	h.limits = p
+}
+
+// SetInbox records failed sessions in the owner's in-app inbox
+func (h *WebhookHandler) SetInbox(b *inbox.Inbox) {
+	h.inbox = b
+}
+
+// SetOutbox records notifications and inbox items in the same transaction as the status,
+// leaving their delivery to the relay
+func (h *WebhookHandler) SetOutbox(r *outbox.Relay) {
+	h.outbox = r
+}
+
+// SetSessionHooks delivers session runs ended by a final status to the owner's session webhooks
+func (h *WebhookHandler) SetSessionHooks(d *sessionhook.Dispatcher) {
+	h.hooks = d
+}
+
+// SetEvents publishes every recorded status to the agent's live event subscribers
+func (h *WebhookHandler) SetEvents(b *events.Broker) {
+	h.events = b
+}
+
+// SetMeter counts ingested reports, stored bytes and queued notifications toward the user's daily usage
+func (h *WebhookHandler) SetMeter(m *metering.Meter) {
+	h.meter = m
+}
+
+// SetAlertEngine evaluates the owner's status alert rules against every recorded status
+func (h *WebhookHandler) SetAlertEngine(e *alerting.Engine) {
+	h.alerts = e
+}
+
+// SetSessionReopenGrace configures how long after expiring a session is re-opened by a new report
+// Later reports start a new revision of the session; 0 always starts a new revision.
+func (h *WebhookHandler) SetSessionReopenGrace(d time.Duration) {
+	h.reopenGrace = d
+}
+
+// SetDefaultAgentKind configures the kind given to agents that report without one
+// Agents that already have a kind keep it; "" leaves them unclassified.
+func (h *WebhookHandler) SetDefaultAgentKind(kind string) {
+	h.defaultKind = kind
+}
+
+// payloadLimitsFor resolves the payload limits for the caller's plan
+func (h *WebhookHandler) payloadLimitsFor(caller *middleware.RequestContext) internal.PayloadLimits {
+	// Only look up the user when some plan overrides the deployment defaults
+	if !h.limits.HasTiers() {
+		return h.limits.For("")
 	}
+	return h.limits.For(caller.Plan())
 }
 
 // SuccessResponse represents a successful response
 type SuccessResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
+
+	// The reporting agent's configuration, when its owner set one
+	Config        json.RawMessage `json:"config,omitempty"`
+	ConfigVersion int             `json:"config_version,omitempty"`
 }
 
 // ErrorResponse represents an error response
@@ -48,174 +154,386 @@ func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get authenticated user (webhook requires authentication)
-	claims, ok := middleware.GetUserFromContext(r.Context())
+	caller, ok := middleware.GetRequestContext(r.Context())
 	if !ok {
 		h.respondError(w, http.StatusUnauthorized, "unauthorized", "Not authenticated")
 		return
 	}
 
+	statusReport, ok := h.decodeStatusReport(w, r, caller, h.payloadLimitsFor(caller))
+	if !ok {
+		return
+	}
+
+	// Retries of a report sent with an idempotency key get the original response
+	key, ok := h.idempotencyKey(w, r, statusReport)
+	if !ok {
+		return
+	}
+	if key != "" {
+		h.serveIdempotent(w, statusReport, caller.UserID, key)
+		return
+	}
+
+	h.serveStatusReport(w, statusReport, caller.UserID)
+}
+
+// serveStatusReport processes a decoded status report and writes its response
+func (h *WebhookHandler) serveStatusReport(w http.ResponseWriter, statusReport *internal.StatusReport, userID string) {
+	// Within a latency budget, slow store writes finish in the background and the report is accepted
+	if h.budget != nil {
+		h.processWithinBudget(w, statusReport, userID)
+		return
+	}
+
+	// Process status report with user context
+	if err := h.processStatusReport(statusReport, userID); err != nil {
+		h.respondReportError(w, err)
+		return
+	}
+
+	// Respond with success, delivering the agent's configuration
+	h.respondSuccessWithConfig(w, "Status reported successfully", statusReport.AgentID)
+}
+
+// respondReportError responds to a status report that could not be processed
+func (h *WebhookHandler) respondReportError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrAgentDeleted) {
+		h.respondError(w, http.StatusGone, "agent_deleted", "Agent was deleted, restore it before reporting again")
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		h.respondError(w, http.StatusConflict, "conflict", "Agent or session was modified concurrently, retry the report")
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Credential is not authorized for this agent")
+		return
+	}
+	if storeErrorStatus(err) == http.StatusServiceUnavailable {
+		log.Printf("Store unavailable processing status report: %v", err)
+		h.respondError(w, http.StatusServiceUnavailable, "unavailable", "Store is temporarily unavailable, retry the report")
+		return
+	}
+	log.Printf("Error processing status report: %v", err)
+	h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to process status report")
+}
+
+// maxPooledReportBuffer is the largest report buffer kept for reuse, so a few large reports do not pin memory
+const maxPooledReportBuffer = 64 << 10
+
+// reportBuffers holds the buffers status report bodies are read into
+// A decoded report copies what it keeps from the body, so the buffer is reused as soon as it is decoded.
+var reportBuffers = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// releaseReportBuffer returns a report buffer to the pool
+func releaseReportBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledReportBuffer {
+		return
+	}
+	buf.Reset()
+	reportBuffers.Put(buf)
+}
+
+// decodeStatusReport parses and validates a status report the way /webhook/status accepts it
+// It writes the error response itself and reports whether the request may proceed.
+func (h *WebhookHandler) decodeStatusReport(w http.ResponseWriter, r *http.Request, caller *middleware.RequestContext, limits internal.PayloadLimits) (*internal.StatusReport, bool) {
 	// Limit request body size (1MB)
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
 
 	// Parse request body
+	body := reportBuffers.Get().(*bytes.Buffer)
+	defer releaseReportBuffer(body)
 	var statusReport internal.StatusReport
-	if err := json.NewDecoder(r.Body).Decode(&statusReport); err != nil {
+	if _, err := body.ReadFrom(r.Body); err != nil {
 		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
-		return
+		return nil, false
+	}
+	if err := internal.DecodeStatusReport(body.Bytes(), &statusReport); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", "Invalid JSON: "+err.Error())
+		return nil, false
 	}
 
 	// Validate input
-	if err := statusReport.Validate(); err != nil {
-		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
-		return
+	if err := statusReport.ValidateWithLimits(limits); err != nil {
+		respondCodedInvalid(w, err)
+		return nil, false
 	}
 
-	// Process status report with user context
-	if err := h.processStatusReport(&statusReport, claims.UserID); err != nil {
-		log.Printf("Error processing status report: %v", err)
-		h.respondError(w, http.StatusInternalServerError, "internal_error", "Failed to process status report")
-		return
+	// Client certificates and agent tokens may be restricted to reporting for a single agent
+	if !caller.MayReportFor(statusReport.AgentID) {
+		h.respondError(w, http.StatusForbidden, "forbidden", "Credential is not authorized for this agent")
+		return nil, false
 	}
-
-	// Respond with success
-	h.respondSuccess(w, "Status reported successfully")
+	return &statusReport, true
 }
 
-// processStatusReport processes a status report and updates the store
-func (h *WebhookHandler) processStatusReport(sr *internal.StatusReport, userID string) error {
-	// Use UTC time to avoid timezone issues with PostgreSQL TIMESTAMP columns
-	now := time.Now().UTC()
+// maxWriteAttempts bounds how often a read-modify-write is retried after a version conflict
+const maxWriteAttempts = 5
 
-	// Get previous status for transition detection
-	var previousStatus string
-	var startTimestamp time.Time
-	history, _ := h.store.GetStatusHistory(sr.AgentID, sr.SessionTopic)
-	if len(history) > 0 {
-		// Find latest status
-		latest := history[0]
-		for _, s := range history {
-			if s.Timestamp.After(latest.Timestamp) {
-				latest = s
+// upsertAgent creates or updates the reporting agent, re-reading it when a concurrent write wins
+func (h *WebhookHandler) upsertAgent(sr *internal.StatusReport, userID string, now time.Time) (*models.Agent, error) {
+	var err error
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		agent, getErr := h.store.GetAgent(sr.AgentID)
+		if getErr != nil {
+			// Agent doesn't exist, create new one with user association
+			agent = &models.Agent{
+				AgentID:    sr.AgentID,
+				UserID:     userID, // Associate with authenticated user
+				Name:       sr.AgentName,
+				Source:     sr.AgentSource,
+				Kind:       sr.AgentKind,
+				Cluster:    sr.Cluster,
+				Region:     sr.Region,
+				Registered: now,
+			}
+			agent.MarkSeen(now)
+		} else {
+			// Agent exists: its owner and the owners and members of its organization may report for it
+			if !canManageAgent(h.store, userID, agent) {
+				return nil, store.ErrNotFound
 			}
+			// The agent keeps its owner whoever reports for it
+			if sr.AgentName != "" {
+				agent.Name = sr.AgentName
+			}
+			if sr.AgentSource != "" {
+				agent.Source = sr.AgentSource
+			}
+			if sr.AgentKind != "" {
+				agent.Kind = sr.AgentKind
+			}
+			// An agent that moved keeps reporting its new location; reports without one keep the last
+			if sr.Cluster != "" {
+				agent.Cluster = sr.Cluster
+			}
+			if sr.Region != "" {
+				agent.Region = sr.Region
+			}
+			agent.MarkSeen(now)
+		}
+		if agent.Kind == "" {
+			agent.Kind = h.defaultKind
 		}
-		previousStatus = latest.Status
 
-		// Find the "running" status timestamp for duration calculation
-		for _, s := range history {
-			if s.Status == "running" {
-				if startTimestamp.IsZero() || s.Timestamp.Before(startTimestamp) {
-					startTimestamp = s.Timestamp
-				}
-			}
+		if err = h.store.CreateOrUpdateAgent(agent); !errors.Is(err, store.ErrConflict) {
+			return agent, err
 		}
 	}
+	return nil, err
+}
 
-	// Create or update agent
-	agent, err := h.store.GetAgent(sr.AgentID)
-	if err != nil {
-		// Agent doesn't exist, create new one with user association
-		agent = &models.Agent{
-			AgentID:    sr.AgentID,
-			UserID:     userID, // Associate with authenticated user
-			Name:       sr.AgentName,
-			Source:     sr.AgentSource,
-			Registered: now,
-			LastSeen:   now,
-		}
-	} else {
-		// Agent exists, verify it belongs to the user
-		if agent.UserID != userID {
-			// Agent exists but belongs to a different user - reject
-			return store.ErrNotFound
+// upsertSession creates or updates the reported session, re-reading it when a concurrent write wins
+// When the report re-opens an expired session, reopenedFrom is the time the session had expired.
+func (h *WebhookHandler) upsertSession(sr *internal.StatusReport, now time.Time) (session *models.Session, reopenedFrom *time.Time, err error) {
+	for attempt := 0; attempt < maxWriteAttempts; attempt++ {
+		reopenedFrom = nil
+		var getErr error
+		session, getErr = h.store.GetSession(sr.AgentID, sr.SessionTopic)
+		if getErr != nil {
+			// Session doesn't exist, create new one
+			ttl := sr.TTLMinutes
+			if ttl == 0 {
+				ttl = models.DefaultSessionTTLMinutes
+			}
+
+			group, category := h.grouper.Classify(sr.SessionTopic)
+			session = &models.Session{
+				AgentID:      sr.AgentID,
+				SessionTopic: sr.SessionTopic,
+				Created:      now,
+				LastUpdated:  now,
+				Expired:      false,
+				TTLMinutes:   ttl,
+				Group:        group,
+				Category:     category,
+				Revision:     1,
+			}
+		} else {
+			switch {
+			case sr.Status == "running" && h.runFinished(session):
+				// Running again after a final status is a re-run of the task
+				startRevision(session, now)
+			case session.Expired && session.ExpiredAt != nil && now.Sub(*session.ExpiredAt) <= h.reopenGrace:
+				expiredAt := *session.ExpiredAt
+				reopenedFrom = &expiredAt
+			case session.Expired:
+				// A report long after expiry is a new run of the task, not a continuation
+				startRevision(session, now)
+			}
+			session.Expired = false
+			session.ExpiredAt = nil
+
+			// Session exists, update it
+			session.LastUpdated = now
+			if sr.TTLMinutes > 0 {
+				session.TTLMinutes = sr.TTLMinutes
+			}
+			// Classify sessions created before grouping rules were configured
+			if session.Group == "" {
+				session.Group, session.Category = h.grouper.Classify(sr.SessionTopic)
+			}
 		}
-		// Agent exists and belongs to user, update it
-		if sr.AgentName != "" {
-			agent.Name = sr.AgentName
+
+		// A final status ends the run, any other report means it is in progress again
+		session.EndReason = ""
+		if internal.IsFinalStatus(sr.Status) {
+			session.EndReason = models.EndReasonAgentReported
 		}
-		if sr.AgentSource != "" {
-			agent.Source = sr.AgentSource
+
+		if err = h.store.CreateOrUpdateSession(session); !errors.Is(err, store.ErrConflict) {
+			return session, reopenedFrom, err
 		}
-		agent.LastSeen = now
 	}
+	return nil, nil, err
+}
 
-	if err := h.store.CreateOrUpdateAgent(agent); err != nil {
+// runFinished reports whether the current revision of a session has reported a final status
+func (h *WebhookHandler) runFinished(session *models.Session) bool {
+	latest, err := h.store.GetLatestStatus(session.AgentID, session.SessionTopic)
+	return err == nil && latest.Revision == session.Revision && internal.IsFinalStatus(latest.Status)
+}
+
+// startRevision starts a new run of a session
+func startRevision(session *models.Session, now time.Time) {
+	session.Revision++
+	session.Created = now
+}
+
+// processStatusReport processes a status report and updates the store
+func (h *WebhookHandler) processStatusReport(sr *internal.StatusReport, userID string) error {
+	return h.processStatusReportAt(sr, userID, h.now())
+}
+
+// processStatusReportAt processes a status report received at now, which becomes its server-side timestamp
+func (h *WebhookHandler) processStatusReportAt(sr *internal.StatusReport, userID string, now time.Time) error {
+
+	agent, err := h.upsertAgent(sr, userID, now)
+	if err != nil {
 		return err
 	}
 
-	// Create or update session
-	session, err := h.store.GetSession(sr.AgentID, sr.SessionTopic)
+	session, reopenedFrom, err := h.upsertSession(sr, now)
 	if err != nil {
-		// Session doesn't exist, create new one
-		ttl := sr.TTLMinutes
-		if ttl == 0 {
-			ttl = 30 // default 30 minutes
-		}
-
-		session = &models.Session{
-			AgentID:      sr.AgentID,
-			SessionTopic: sr.SessionTopic,
-			Created:      now,
-			LastUpdated:  now,
-			Expired:      false,
-			TTLMinutes:   ttl,
-		}
-	} else {
-		// Session exists, update it
-		session.LastUpdated = now
-		if sr.TTLMinutes > 0 {
-			session.TTLMinutes = sr.TTLMinutes
-		}
+		return err
+	}
+	if reopenedFrom != nil {
+		log.Printf("Session %s of agent %s re-opened after expiring at %s", sr.SessionTopic, sr.AgentID, reopenedFrom.Format(time.RFC3339))
 	}
 
-	if err := h.store.CreateOrUpdateSession(session); err != nil {
+	// Get previous status for transition detection
+	// Statuses of earlier revisions belong to a previous run of the session and are ignored, as are the
+	// statuses the server recorded, so transitions are between the agent's own reports.
+	var previousStatus string
+	latest, startTimestamp, err := h.store.GetRunProgress(sr.AgentID, sr.SessionTopic, session.Revision)
+	if err != nil {
 		return err
 	}
+	if latest != nil {
+		previousStatus = latest.Status
+	}
+
+	// The session and agent were still refreshed, so a dropped heartbeat keeps the session alive
+	if !h.keepHeartbeat(agent, session, latest, sr) {
+		h.recordUsage(userID, agent, session, nil, 0)
+		return nil
+	}
 
 	// Add status to history (use server-side timestamp as authoritative time)
-	serverNow := time.Now().UTC()
 	agentStatus := &models.AgentStatus{
-		AgentID:      sr.AgentID,
-		SessionTopic: sr.SessionTopic,
-		Status:       sr.Status,
-		Timestamp:    serverNow,
-		Message:      sr.Message,
-		Content:      sr.Content,
+		AgentID:       sr.AgentID,
+		SessionTopic:  sr.SessionTopic,
+		Status:        sr.Status,
+		Timestamp:     now,
+		Message:       sr.Message,
+		Content:       sr.Content,
+		ContentFormat: sr.ContentFormat,
+		Metadata:      sr.Metadata,
+		Revision:      session.Revision,
+		Origin:        models.StatusOriginAgent,
 	}
 
-	if err := h.store.AddStatus(agentStatus); err != nil {
-		return err
+	var items []*models.InboxItem
+	if h.inbox != nil {
+		if reopenedFrom != nil {
+			items = append(items, inbox.ReopenedItem(agent, session, *reopenedFrom))
+		}
+		if sr.Status == "failed" && previousStatus != "failed" {
+			items = append(items, inbox.FailureItem(agent, agentStatus))
+		}
 	}
 
 	// Check for status transition and send notification
-	// Notify when running -> success/failed/pending
-	if h.notifier != nil && previousStatus == "running" &&
-		(sr.Status == "success" || sr.Status == "failed" || sr.Status == "pending") {
+	// Which transitions notify is decided per receiver, by default running -> success/failed/pending
+	var notification *notifier.NotificationData
+	var destinations []models.NotificationDestination
+	if h.notifier != nil && previousStatus != "" && previousStatus != sr.Status {
 
 		duration := time.Duration(0)
 		if !startTimestamp.IsZero() {
-			duration = serverNow.Sub(startTimestamp)
+			duration = now.Sub(startTimestamp)
 		}
 
-		notificationData := &notifier.NotificationData{
-			AgentID:      sr.AgentID,
-			AgentName:    agent.Name,
-			SessionTopic: sr.SessionTopic,
-			FromStatus:   previousStatus,
-			ToStatus:     sr.Status,
-			Timestamp:    serverNow,
-			Message:      sr.Message,
-			Content:      sr.Content,
-			Duration:     duration,
+		notification = &notifier.NotificationData{
+			AgentID:       sr.AgentID,
+			AgentName:     agent.Name,
+			SessionTopic:  sr.SessionTopic,
+			FromStatus:    previousStatus,
+			ToStatus:      sr.Status,
+			Timestamp:     now,
+			Message:       sr.Message,
+			Content:       sr.Content,
+			ContentFormat: sr.ContentFormat,
+			Duration:      duration,
+			EndReason:     session.EndReason,
+		}
+		if sr.Status == "success" {
+			if runs, since := h.failureStreak(sr.AgentID, sr.SessionTopic); runs > 0 {
+				notification.Recovery = &notifier.Recovery{FailedRuns: runs, Since: since, Downtime: now.Sub(since)}
+			}
 		}
+		destinations = h.notificationDestinations(notification, agent.UserID)
+	}
 
-		user, err := h.store.GetUserByID(userID)
-		if err != nil {
-			log.Printf("Failed to load user for notification: %v", err)
-			return nil
+	// A run ends once, with its first final status
+	var deliveries []*sessionhook.Delivery
+	if h.hooks != nil && internal.IsFinalStatus(sr.Status) && !internal.IsFinalStatus(previousStatus) {
+		deliveries = h.hooks.Deliveries(agent, session, agentStatus)
+	}
+
+	if h.outbox != nil {
+		if err := h.addStatusWithOutbox(agentStatus, items, notification, destinations, deliveries); err != nil {
+			return err
+		}
+		h.publishStatus(agentStatus, previousStatus)
+		h.evaluateAlerts(agent, agentStatus, previousStatus)
+		h.recordUsage(userID, agent, session, agentStatus, len(destinations))
+		return nil
+	}
+
+	if err := h.store.AddStatus(agentStatus); err != nil {
+		return err
+	}
+	h.publishStatus(agentStatus, previousStatus)
+	h.evaluateAlerts(agent, agentStatus, previousStatus)
+	h.recordUsage(userID, agent, session, agentStatus, len(destinations))
+
+	for _, item := range items {
+		if item != nil {
+			h.inbox.Publish(item)
 		}
+	}
+
+	if len(deliveries) > 0 {
+		h.hooks.Publish(deliveries)
+	}
 
+	if len(destinations) > 0 {
 		// Send notification asynchronously (non-blocking)
-		if err := h.notifier.Notify(context.Background(), notificationData, user.NotificationWebhookURL); err != nil {
+		if err := h.notifier.NotifyDestinations(context.Background(), notification, destinations); err != nil {
 			// Log error but don't fail the request
 			log.Printf("Failed to queue notification: %v", err)
 		}
@@ -224,6 +542,241 @@ func (h *WebhookHandler) processStatusReport(sr *internal.StatusReport, userID s
 	return nil
 }
 
+// publishStatus sends a recorded status to the agent's live event subscribers
+func (h *WebhookHandler) publishStatus(status *models.AgentStatus, previousStatus string) {
+	if h.events == nil {
+		return
+	}
+	h.events.Publish(&events.Event{
+		Type:         events.TypeStatus,
+		AgentID:      status.AgentID,
+		SessionTopic: status.SessionTopic,
+		Status:       status.Status,
+		FromStatus:   previousStatus,
+		Message:      status.Message,
+		Revision:     status.Revision,
+		Origin:       status.Origin,
+		Timestamp:    status.Timestamp,
+	})
+}
+
+// evaluateAlerts checks a recorded status against the owner's status alert rules
+func (h *WebhookHandler) evaluateAlerts(agent *models.Agent, status *models.AgentStatus, previousStatus string) {
+	if h.alerts == nil {
+		return
+	}
+	h.alerts.StatusRecorded(agent, status, previousStatus)
+}
+
+// recordUsage counts a report toward the user's usage and record counts; status is nil when the report was
+// not stored
+// An agent or session at version 1 was created by the report.
+func (h *WebhookHandler) recordUsage(userID string, agent *models.Agent, session *models.Session, status *models.AgentStatus, notifications int) {
+	if h.meter == nil {
+		return
+	}
+	var storedBytes int64
+	if status != nil {
+		storedBytes = int64(len(status.Message) + len(status.Content) + len(status.Metadata))
+		h.meter.RecordStored(userID, agent.Version == 1, session.Version == 1)
+	}
+	h.meter.RecordReport(userID, storedBytes)
+	h.meter.RecordNotifications(userID, notifications)
+}
+
+// maxSampledRuns bounds the heartbeat counters kept for session runs that stopped reporting
+const maxSampledRuns = 10000
+
+// keepHeartbeat reports whether a status should be stored under the agent's heartbeat sampling
+// A heartbeat is a running status repeating the message of the run's latest status, which was running too;
+// 1 of every HeartbeatSampleEvery heartbeats is stored and every other status, including transitions, always is.
+// Counters are kept per replica, so behind a load balancer a few more heartbeats may be stored.
+func (h *WebhookHandler) keepHeartbeat(agent *models.Agent, session *models.Session, latest *models.AgentStatus, sr *internal.StatusReport) bool {
+	if agent.HeartbeatSampleEvery <= 1 {
+		return true
+	}
+
+	h.heartbeatMu.Lock()
+	defer h.heartbeatMu.Unlock()
+
+	key := fmt.Sprintf("%s\x00%s\x00%d", sr.AgentID, sr.SessionTopic, session.Revision)
+	heartbeat := sr.Status == "running" && latest != nil && latest.Status == "running" && latest.Message == sr.Message
+	if !heartbeat {
+		delete(h.heartbeats, key)
+		return true
+	}
+
+	if h.heartbeats == nil || len(h.heartbeats) >= maxSampledRuns {
+		h.heartbeats = make(map[string]int)
+	}
+	h.heartbeats[key]++
+	if h.heartbeats[key] < agent.HeartbeatSampleEvery {
+		return false
+	}
+	delete(h.heartbeats, key)
+	return true
+}
+
+// notificationDestinations resolves where a status notification goes and sets its mentions
+// The notification policy's receivers and mention rules are added to the user's own, unless the policy lets
+// members override them and the user did. The user's own receivers are notified of the transitions chosen in their
+// notification settings and the policy's of the default ones; either may hold back failures continuing a failure
+// streak. It returns nothing when the user cannot be loaded or muted the session and the policy allows it.
+func (h *WebhookHandler) notificationDestinations(data *notifier.NotificationData, userID string) []models.NotificationDestination {
+	user, err := h.store.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Failed to load user for notification: %v", err)
+		return nil
+	}
+	// The settings' webhook URL replaces the profile's
+	settings := loadNotificationSettings(h.store, userID)
+	own, hasOwn := settings.Destination()
+	if hasOwn {
+		replaced := *user
+		replaced.NotificationWebhookURL = own.URL
+		user = &replaced
+	}
+	policy, err := loadNotificationPolicy(h.store)
+	if err != nil {
+		log.Printf("Failed to load notification policy: %v", err)
+		policy = &models.NotificationPolicy{}
+	}
+
+	rules := append(append([]models.MentionRule(nil), user.NotificationMentions...), policy.Mentions...)
+	merged := &models.User{NotificationMentions: rules}
+	data.Mentions = notifier.MentionsFromRules(merged.MentionsFor(data.AgentID, data.SessionTopic))
+
+	// A failure streak is only looked up for receivers that hold back repeated failures
+	repeated := false
+	if data.ToStatus == "failed" && (settings.FirstFailureOnly || policy.FirstFailureOnly) {
+		streak, _ := h.failureStreak(data.AgentID, data.SessionTopic)
+		repeated = streak > 0
+	}
+	triggered := settings.Triggers(data.FromStatus, data.ToStatus) || (data.Recovery != nil && settings.TriggersRecovery())
+
+	// The user's extra destinations receive every notification their webhook URL does,
+	// rendered with the settings' template unless they have their own
+	var destinations []models.NotificationDestination
+	webhookURL, ok := notificationTarget(h.store, user, data.AgentID, data.SessionTopic)
+	if ok && triggered && !(repeated && settings.FirstFailureOnly) && h.claimNotification(settings, data) {
+		switch {
+		case hasOwn && webhookURL == own.URL:
+			destinations = append(destinations, own)
+		case webhookURL != "":
+			destinations = append(destinations, models.NotificationDestination{URL: webhookURL})
+		}
+		destinations = append(destinations, user.NotificationDestinations...)
+		for i := range destinations {
+			if destinations[i].Template == "" {
+				destinations[i].Template = settings.Template
+			}
+		}
+	}
+
+	// Muting only silences the policy's receivers when members may override them
+	defaults := &models.NotificationSettings{}
+	if policy.HasReceivers() && !policy.Overridden(user) && (ok || !policy.AllowUserOverride) && defaults.Triggers(data.FromStatus, data.ToStatus) &&
+		!(repeated && policy.FirstFailureOnly) {
+		if policy.WebhookURL != "" && policy.WebhookURL != webhookURL {
+			destinations = append(destinations, models.NotificationDestination{URL: policy.WebhookURL})
+		}
+		destinations = append(destinations, policy.Destinations...)
+	}
+	return destinations
+}
+
+// claimNotification records that the settings' owner is notified of the session reaching its new status,
+// reporting false when the owner's dedup window holds the notification back
+// Marks are only kept when the settings need them: to dedup, or to escalate failures that persist.
+func (h *WebhookHandler) claimNotification(settings *models.NotificationSettings, data *notifier.NotificationData) bool {
+	escalates := settings.EscalateAfterMinutes > 0 && data.ToStatus == "failed"
+	if settings.DedupMinutes == 0 && !escalates {
+		return true
+	}
+
+	mark := &models.NotificationMark{
+		UserID:       settings.UserID,
+		AgentID:      data.AgentID,
+		SessionTopic: data.SessionTopic,
+		Status:       data.ToStatus,
+		NotifiedAt:   data.Timestamp,
+	}
+	cutoff := data.Timestamp.Add(-time.Duration(settings.DedupMinutes) * time.Minute)
+	err := h.store.ClaimNotificationMark(mark, cutoff)
+	if errors.Is(err, store.ErrAlreadyExists) {
+		return false
+	}
+	if err != nil {
+		log.Printf("Failed to record notification mark: %v", err)
+	}
+	return true
+}
+
+// failureStreak counts the session's latest runs that ended failed and returns when the first of them failed
+// It is called before the new status is stored, so the final statuses belong to earlier runs.
+func (h *WebhookHandler) failureStreak(agentID, sessionTopic string) (runs int, since time.Time) {
+	history, err := h.store.GetStatusHistory(agentID, sessionTopic)
+	if err != nil {
+		log.Printf("Failed to load status history for notification: %v", err)
+		return 0, time.Time{}
+	}
+
+	var finals []*models.AgentStatus
+	for _, status := range history {
+		if internal.IsFinalStatus(status.Status) {
+			finals = append(finals, status)
+		}
+	}
+	sort.Slice(finals, func(i, j int) bool {
+		return finals[i].Timestamp.After(finals[j].Timestamp)
+	})
+	for _, status := range finals {
+		if status.Status != "failed" {
+			break
+		}
+		runs++
+		since = status.Timestamp
+	}
+	return runs, since
+}
+
+// addStatusWithOutbox adds the status together with its side effects and wakes the relay to deliver them
+func (h *WebhookHandler) addStatusWithOutbox(status *models.AgentStatus, items []*models.InboxItem, notification *notifier.NotificationData, destinations []models.NotificationDestination, deliveries []*sessionhook.Delivery) error {
+	var messages []*models.OutboxMessage
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		message, err := outbox.InboxMessage(item, status.Timestamp)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	for _, destination := range destinations {
+		message, err := outbox.NotificationMessage(notification, destination, status.Timestamp)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+	for _, delivery := range deliveries {
+		message, err := outbox.SessionHookMessage(delivery, status.Timestamp)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, message)
+	}
+
+	if err := h.store.AddStatusWithOutbox(status, messages); err != nil {
+		return err
+	}
+	if len(messages) > 0 {
+		h.outbox.Wake()
+	}
+	return nil
+}
+
 // respondSuccess sends a success response
 func (h *WebhookHandler) respondSuccess(w http.ResponseWriter, message string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -234,6 +787,23 @@ func (h *WebhookHandler) respondSuccess(w http.ResponseWriter, message string) {
 	})
 }
 
+// respondSuccessWithConfig sends a success response carrying the agent's configuration
+// The lookup is best effort: the report was already stored, so a failure only omits the configuration.
+func (h *WebhookHandler) respondSuccessWithConfig(w http.ResponseWriter, message, agentID string) {
+	response := SuccessResponse{
+		Success: true,
+		Message: message,
+	}
+	if agent, err := h.store.GetAgent(agentID); err == nil {
+		response.Config = agent.Config
+		response.ConfigVersion = agent.ConfigVersion
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
 // respondError sends an error response
 func (h *WebhookHandler) respondError(w http.ResponseWriter, statusCode int, errorCode, message string) {
 	w.Header().Set("Content-Type", "application/json")
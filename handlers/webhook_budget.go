@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/metrics"
+)
+
+// Reasons a status report was accepted for background processing, as reported in metrics
+const (
+	DeferOverBudget = "over_budget" // Processing outlasted the budget and finished in the background
+	DeferQueued     = "queued"      // Reported while deferring, so queued without trying to process it
+	DeferQueueFull  = "queue_full"  // Reported while deferring with a full queue, so rejected
+)
+
+// deferredReport is a status report waiting in the deferral queue
+type deferredReport struct {
+	report     *internal.StatusReport
+	userID     string
+	receivedAt time.Time // Stamped on the report when it is processed, so queueing does not shift its timeline
+}
+
+// Errors of queueing a deferred report
+var (
+	errDeferQueueFull = errors.New("deferral queue is full")
+	errDeferDraining  = errors.New("deferral queue is draining for shutdown")
+)
+
+// latencyBudget bounds how long /webhook/status waits for the store
+// Once processing outlasts the budget, the handler defers for a while: reports are queued and answered
+// with 202 Accepted right away, so a slow store does not slow down every agent.
+type latencyBudget struct {
+	limit    time.Duration
+	deferFor time.Duration
+	queue    chan deferredReport
+	deferred *metrics.CounterVec
+
+	mu         sync.Mutex
+	deferUntil time.Time
+	draining   bool // Set once RunDeferred drains the queue, after which nothing is queued
+}
+
+// SetLatencyBudget answers status reports with 202 Accepted once processing them takes longer than budget,
+// then queues up to queueSize reports for deferFor instead of processing them while the client waits
+// Queued reports are processed by RunDeferred and stamped with the time they were received; reg may be nil.
+// The queue is held in memory, as it exists because the store is slow: reports still queued when the process
+// crashes are lost although they were answered with 202. A graceful shutdown processes them first.
+func (h *WebhookHandler) SetLatencyBudget(budget, deferFor time.Duration, queueSize int, reg *metrics.Registry) {
+	h.budget = &latencyBudget{
+		limit:    budget,
+		deferFor: deferFor,
+		queue:    make(chan deferredReport, queueSize),
+	}
+	if reg != nil {
+		h.budget.deferred = reg.NewCounterVec("kubeagents_webhook_deferred_total", "Status reports accepted for background processing, or rejected with a full queue.", "reason")
+	}
+}
+
+// RunDeferred processes queued status reports in order until ctx is done, then processes the ones still queued
+// Reports arriving while it drains the queue are refused with 503 rather than queued, so stop the HTTP servers
+// before ctx is done.
+func (h *WebhookHandler) RunDeferred(ctx context.Context) {
+	if h.budget == nil {
+		return
+	}
+	for {
+		select {
+		case deferred := <-h.budget.queue:
+			h.processDeferred(deferred)
+		case <-ctx.Done():
+			h.budget.stopQueueing()
+			for {
+				select {
+				case deferred := <-h.budget.queue:
+					h.processDeferred(deferred)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// processDeferred processes a queued report, logging failures since its client was already answered
+func (h *WebhookHandler) processDeferred(deferred deferredReport) {
+	if err := h.processStatusReportAt(deferred.report, deferred.userID, deferred.receivedAt); err != nil {
+		log.Printf("Failed to process deferred status report of %s/%s: %v", deferred.report.AgentID, deferred.report.SessionTopic, err)
+	}
+}
+
+// count adds a deferral to the metrics
+func (b *latencyBudget) count(reason string) {
+	if b.deferred != nil {
+		b.deferred.Add(reason, 1)
+	}
+}
+
+// deferring reports whether reports are being queued at now
+func (b *latencyBudget) deferring(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Before(b.deferUntil)
+}
+
+// enqueue queues a report unless the queue is full or draining
+func (b *latencyBudget) enqueue(report deferredReport) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.draining {
+		return errDeferDraining
+	}
+	select {
+	case b.queue <- report:
+		return nil
+	default:
+		return errDeferQueueFull
+	}
+}
+
+// stopQueueing refuses reports from now on, so the ones already queued are the last to drain
+func (b *latencyBudget) stopQueueing() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.draining = true
+}
+
+// startDeferring queues reports from now on for the deferral window
+func (b *latencyBudget) startDeferring(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.deferUntil = now.Add(b.deferFor)
+}
+
+// processWithinBudget processes a report while the client waits at most the budget
+func (h *WebhookHandler) processWithinBudget(w http.ResponseWriter, sr *internal.StatusReport, userID string) {
+	b := h.budget
+	receivedAt := h.now()
+	if b.deferring(receivedAt) {
+		switch err := b.enqueue(deferredReport{report: sr, userID: userID, receivedAt: receivedAt}); {
+		case err == nil:
+			b.count(DeferQueued)
+			h.respondAccepted(w)
+		case errors.Is(err, errDeferDraining):
+			w.Header().Set("Retry-After", "1")
+			h.respondError(w, http.StatusServiceUnavailable, "unavailable", "Server is shutting down, retry the report")
+		default:
+			b.count(DeferQueueFull)
+			w.Header().Set("Retry-After", "1")
+			h.respondError(w, http.StatusServiceUnavailable, "unavailable", "Status reports are queued and the queue is full, retry the report")
+		}
+		return
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- h.processStatusReportAt(sr, userID, receivedAt)
+	}()
+
+	timer := time.NewTimer(b.limit)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			h.respondReportError(w, err)
+			return
+		}
+		h.respondSuccessWithConfig(w, "Status reported successfully", sr.AgentID)
+	case <-timer.C:
+		b.startDeferring(h.clock.Now())
+		b.count(DeferOverBudget)
+		log.Printf("Status report of %s/%s exceeded the %v latency budget, deferring reports for %v", sr.AgentID, sr.SessionTopic, b.limit, b.deferFor)
+		go func() {
+			if err := <-done; err != nil {
+				log.Printf("Failed to process status report of %s/%s after its latency budget: %v", sr.AgentID, sr.SessionTopic, err)
+			}
+		}()
+		h.respondAccepted(w)
+	}
+}
+
+// respondAccepted tells the client its report will be processed in the background
+func (h *WebhookHandler) respondAccepted(w http.ResponseWriter) {
+	respondJSON(w, http.StatusAccepted, SuccessResponse{
+		Success: true,
+		Message: "Status accepted for processing",
+	})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/metrics"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// slowStore holds status writes until it is released, like a database that stopped answering in time
+type slowStore struct {
+	*store.MemoryStore
+	release chan struct{}
+}
+
+func (s *slowStore) AddStatus(status *models.AgentStatus) error {
+	<-s.release
+	return s.MemoryStore.AddStatus(status)
+}
+
+func TestWebhookHandler_LatencyBudget(t *testing.T) {
+	st := &slowStore{MemoryStore: store.NewMemoryStore(), release: make(chan struct{})}
+	reg := metrics.NewRegistry()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetLatencyBudget(50*time.Millisecond, time.Minute, 1, reg)
+	now := time.Now().UTC().Truncate(time.Second)
+	clk := clock.NewFake(now)
+	handler.SetClock(clk)
+
+	// The store is too slow: the client gets 202 within the budget and the report finishes in the background
+	start := time.Now()
+	if rr := testsupport.PostStatus(handler, "agent-001", "task-001", "running", now, "", ""); rr.Code != http.StatusAccepted {
+		t.Fatalf("ServeHTTP() over budget status = %v, want %v: %s", rr.Code, http.StatusAccepted, rr.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("ServeHTTP() over budget took %v, want < 1s", elapsed)
+	}
+
+	// While deferring, reports are queued without waiting for the store, until the queue is full
+	clk.Advance(time.Second)
+	receivedAt := clk.Now()
+	if rr := testsupport.PostStatus(handler, "agent-001", "task-001", "success", now, "", ""); rr.Code != http.StatusAccepted {
+		t.Errorf("ServeHTTP() while deferring status = %v, want %v", rr.Code, http.StatusAccepted)
+	}
+	if rr := testsupport.PostStatus(handler, "agent-001", "task-002", "running", now, "", ""); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("ServeHTTP() with a full queue status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	budget := handler.budget
+	for reason, want := range map[string]float64{DeferOverBudget: 1, DeferQueued: 1, DeferQueueFull: 1} {
+		if got := budget.deferred.Value(reason); got != want {
+			t.Errorf("deferred counter %s = %v, want %v", reason, got, want)
+		}
+	}
+
+	// Once the store answers, the queued report is processed after the one that outlasted the budget
+	close(st.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if history, _ := st.GetStatusHistory("agent-001", "task-001"); len(history) == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The queued report keeps the time it was received, not the time it was processed
+	clk.Advance(time.Minute / 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler.RunDeferred(ctx)
+	if latest, err := st.GetLatestStatus("agent-001", "task-001"); err != nil || latest.Status != "success" || !latest.Timestamp.Equal(receivedAt) {
+		t.Errorf("GetLatestStatus() after the queue drained = %+v, %v, want success received at %v", latest, err, receivedAt)
+	}
+
+	// Once drained, reports are refused rather than queued with nobody left to process them
+	rr := testsupport.PostStatus(handler, "agent-001", "task-002", "running", now, "", "")
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("ServeHTTP() after draining status = %v, Retry-After %q, want %v with Retry-After", rr.Code, rr.Header().Get("Retry-After"), http.StatusServiceUnavailable)
+	}
+}
+
+func TestWebhookHandler_LatencyBudgetMet(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetLatencyBudget(time.Second, time.Minute, 10, nil)
+
+	if rr := testsupport.PostStatus(handler, "agent-001", "task-001", "running", time.Now(), "", ""); rr.Code != http.StatusOK {
+		t.Errorf("ServeHTTP() within budget status = %v, want %v", rr.Code, http.StatusOK)
+	}
+	if handler.budget.deferring(time.Now()) {
+		t.Error("ServeHTTP() within budget started deferring")
+	}
+}
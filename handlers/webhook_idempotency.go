@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Headers of idempotent status reports
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed" // Set on responses replayed for a retried report
+)
+
+// idempotencyClaimLease frees the key of a report whose processing never finished, e.g. after a crash
+const idempotencyClaimLease = time.Minute
+
+// SetIdempotencyTTL keeps the responses of status reports sent with an idempotency key for ttl,
+// answering retries of a report with its original response instead of storing it again; 0 disables keys
+func (h *WebhookHandler) SetIdempotencyTTL(ttl time.Duration) {
+	h.idempotencyTTL = ttl
+}
+
+// idempotencyKey returns the report's idempotency key: the Idempotency-Key header, else its report_id
+// It writes the error response itself and reports whether the request may proceed.
+func (h *WebhookHandler) idempotencyKey(w http.ResponseWriter, r *http.Request, sr *internal.StatusReport) (string, bool) {
+	if h.idempotencyTTL <= 0 {
+		return "", true
+	}
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if err := models.ValidateIdempotencyKey(IdempotencyKeyHeader, key); err != nil {
+		h.respondError(w, http.StatusBadRequest, "bad_request", err.Error())
+		return "", false
+	}
+	if key == "" {
+		key = sr.ReportID
+	}
+	return key, true
+}
+
+// serveIdempotent processes a report under its idempotency key, or replays the response of the report
+// first sent with the key
+// Only successful responses are kept; a failed report releases its key so the agent can retry it.
+func (h *WebhookHandler) serveIdempotent(w http.ResponseWriter, sr *internal.StatusReport, userID, key string) {
+	fingerprint, err := reportFingerprint(sr)
+	if err != nil {
+		h.respondReportError(w, err)
+		return
+	}
+
+	now := h.now()
+	existing, err := h.store.ClaimIdempotencyKey(&models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(idempotencyClaimLease),
+	})
+	switch {
+	case errors.Is(err, store.ErrAlreadyExists):
+		h.replay(w, existing, fingerprint)
+		return
+	case err != nil:
+		h.respondReportError(w, err)
+		return
+	}
+
+	buffered := &bufferedResponse{header: make(http.Header)}
+	h.serveStatusReport(buffered, sr, userID)
+	if buffered.status >= 200 && buffered.status < 300 {
+		if err := h.store.CompleteIdempotencyKey(userID, key, buffered.status, buffered.body.Bytes(), h.now().Add(h.idempotencyTTL)); err != nil {
+			log.Printf("Failed to store response of idempotency key %q: %v", key, err)
+		}
+	} else if err := h.store.ReleaseIdempotencyKey(userID, key); err != nil {
+		log.Printf("Failed to release idempotency key %q: %v", key, err)
+	}
+	buffered.sendTo(w)
+}
+
+// replay answers a report whose idempotency key is already taken
+func (h *WebhookHandler) replay(w http.ResponseWriter, record *models.IdempotencyRecord, fingerprint string) {
+	if record.Fingerprint != fingerprint {
+		h.respondError(w, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency key was already used for a different status report")
+		return
+	}
+	if !record.Completed() {
+		w.Header().Set("Retry-After", "1")
+		h.respondError(w, http.StatusConflict, "conflict", "A status report with this idempotency key is still being processed, retry the report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Response)
+}
+
+// reportFingerprint hashes a status report, so a key sent again with another report is told apart from a retry
+func reportFingerprint(sr *internal.StatusReport) (string, error) {
+	encoded, err := json.Marshal(sr)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// postIdempotent reports a status with an idempotency key in the header, or none when key is empty
+func postIdempotent(handler http.Handler, key string, report map[string]interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(report)
+	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, testsupport.WithUser(req))
+	return rr
+}
+
+func idempotentReport(status string, ts time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"agent_id":      "agent-001",
+		"session_topic": "task-001",
+		"status":        status,
+		"timestamp":     ts.Format(time.RFC3339),
+	}
+}
+
+func TestWebhookHandler_IdempotencyKey(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetIdempotencyTTL(time.Hour)
+	ts := time.Now()
+
+	first := postIdempotent(handler, "report-1", idempotentReport("running", ts))
+	if first.Code != http.StatusOK {
+		t.Fatalf("ServeHTTP() status = %v, want %v: %s", first.Code, http.StatusOK, first.Body.String())
+	}
+	retry := postIdempotent(handler, "report-1", idempotentReport("running", ts))
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("ServeHTTP() retry = %v %q %s, want the original response replayed", retry.Code, retry.Header().Get(IdempotentReplayedHeader), retry.Body.String())
+	}
+	if history, _ := st.GetStatusHistory("agent-001", "task-001"); len(history) != 1 {
+		t.Errorf("status history = %d statuses, want the retry not stored", len(history))
+	}
+
+	// The key may not be reused for another report
+	if rr := postIdempotent(handler, "report-1", idempotentReport("success", ts)); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("ServeHTTP() reused key status = %v, want %v", rr.Code, http.StatusUnprocessableEntity)
+	}
+
+	// report_id works for agents that cannot set headers
+	withID := idempotentReport("success", ts.Add(time.Second))
+	withID["report_id"] = "report-2"
+	postIdempotent(handler, "", withID)
+	if rr := postIdempotent(handler, "", withID); rr.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("ServeHTTP() report_id retry = %v %s, want the response replayed", rr.Code, rr.Body.String())
+	}
+	if history, _ := st.GetStatusHistory("agent-001", "task-001"); len(history) != 2 {
+		t.Errorf("status history = %d statuses, want 2", len(history))
+	}
+
+	if rr := postIdempotent(handler, "bad\nkey", idempotentReport("running", ts)); rr.Code != http.StatusBadRequest {
+		t.Errorf("ServeHTTP() invalid key status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestWebhookHandler_IdempotencyKeyInProgress(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetIdempotencyTTL(time.Hour)
+	ts := time.Now()
+
+	// Another request holds the key while its report is processed
+	var report internal.StatusReport
+	body, _ := json.Marshal(idempotentReport("running", ts))
+	json.Unmarshal(body, &report)
+	fingerprint, _ := reportFingerprint(&report)
+	st.ClaimIdempotencyKey(&models.IdempotencyRecord{UserID: testsupport.UserID, Key: "report-1", Fingerprint: fingerprint, CreatedAt: ts, ExpiresAt: ts.Add(time.Minute)})
+
+	rr := postIdempotent(handler, "report-1", idempotentReport("running", ts))
+	if rr.Code != http.StatusConflict || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("ServeHTTP() in progress = %v with Retry-After %q, want %v", rr.Code, rr.Header().Get("Retry-After"), http.StatusConflict)
+	}
+}
+
+func TestWebhookHandler_IdempotencyKeyReleasedOnFailure(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetIdempotencyTTL(time.Hour)
+	ts := time.Now()
+
+	postIdempotent(handler, "", idempotentReport("running", ts))
+	st.DeleteAgent("agent-001", ts)
+	if rr := postIdempotent(handler, "report-1", idempotentReport("success", ts)); rr.Code != http.StatusGone {
+		t.Fatalf("ServeHTTP() for a deleted agent status = %v, want %v", rr.Code, http.StatusGone)
+	}
+
+	// The failed report left the key free for its retry
+	if existing, err := st.ClaimIdempotencyKey(&models.IdempotencyRecord{UserID: testsupport.UserID, Key: "report-1", CreatedAt: ts, ExpiresAt: ts.Add(time.Minute)}); err != nil {
+		t.Errorf("ClaimIdempotencyKey() after a failed report = %+v, %v, want the key released", existing, err)
+	}
+}
+
+func TestWebhookHandler_IdempotencyDisabled(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	ts := time.Now()
+
+	postIdempotent(handler, "report-1", idempotentReport("running", ts))
+	if rr := postIdempotent(handler, "report-1", idempotentReport("running", ts)); rr.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("ServeHTTP() without a TTL replayed the response")
+	}
+	if history, _ := st.GetStatusHistory("agent-001", "task-001"); len(history) != 2 {
+		t.Errorf("status history = %d statuses, want both reports stored", len(history))
+	}
+}
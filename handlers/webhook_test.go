@@ -2,54 +2,28 @@ package handlers
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
-	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/inbox"
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/internal/testsupport"
 	"github.com/kubeagents/kubeagents/middleware"
 	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/outbox"
 	"github.com/kubeagents/kubeagents/store"
 )
 
-const testUserIDWebhook = "test-user-123"
-const testUserEmailWebhook = "test@example.com"
-
-// addTestUserToContextWebhook adds a test user to the request context
-func addTestUserToContextWebhook(r *http.Request) *http.Request {
-	claims := &auth.AccessTokenClaims{
-		UserID: testUserIDWebhook,
-		Email:  testUserEmailWebhook,
-	}
-	ctx := context.WithValue(r.Context(), middleware.UserContextKey, claims)
-	return r.WithContext(ctx)
-}
-
-func createTestUserWithWebhook(t *testing.T, st store.Store, webhookURL string) {
-	t.Helper()
-
-	now := time.Now()
-	user := &models.User{
-		ID:                     testUserIDWebhook,
-		Email:                  testUserEmailWebhook,
-		PasswordHash:           "test-password-hash",
-		Name:                   "Test User",
-		NotificationWebhookURL: webhookURL,
-		EmailVerified:          true,
-		CreatedAt:              now,
-		UpdatedAt:              now,
-	}
-
-	if err := st.CreateUser(user); err != nil {
-		t.Fatalf("CreateUser() failed: %v", err)
-	}
-}
-
 func TestWebhookHandler_NewAgentAutoRegistration(t *testing.T) {
 	st := store.NewMemoryStore()
 	handler := NewWebhookHandlerWithNotifier(st, nil)
@@ -67,7 +41,7 @@ func TestWebhookHandler_NewAgentAutoRegistration(t *testing.T) {
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
-	req = addTestUserToContextWebhook(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
@@ -106,7 +80,7 @@ func TestWebhookHandler_ExistingAgentStatusUpdate(t *testing.T) {
 	body1, _ := json.Marshal(reqBody1)
 	req1 := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body1))
 	req1.Header.Set("Content-Type", "application/json")
-	req1 = addTestUserToContextWebhook(req1)
+	req1 = testsupport.WithUser(req1)
 	rr1 := httptest.NewRecorder()
 	handler.ServeHTTP(rr1, req1)
 
@@ -121,7 +95,7 @@ func TestWebhookHandler_ExistingAgentStatusUpdate(t *testing.T) {
 	body2, _ := json.Marshal(reqBody2)
 	req2 := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body2))
 	req2.Header.Set("Content-Type", "application/json")
-	req2 = addTestUserToContextWebhook(req2)
+	req2 = testsupport.WithUser(req2)
 	rr2 := httptest.NewRecorder()
 	handler.ServeHTTP(rr2, req2)
 
@@ -138,6 +112,47 @@ func TestWebhookHandler_ExistingAgentStatusUpdate(t *testing.T) {
 	}
 }
 
+func TestWebhookHandler_OrgMembersReportForSharedAgents(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	testsupport.CreateOrg(t, st, "org-1", map[string]string{"owner-1": models.OrgRoleOwner, "member-1": models.OrgRoleMember, "viewer-1": models.OrgRoleViewer})
+	testsupport.CreateAgent(t, st, "agent-shared", testsupport.OwnedBy("owner-1"), testsupport.SharedWith("org-1"))
+	testsupport.CreateAgent(t, st, "agent-private", testsupport.OwnedBy("owner-1"))
+
+	tests := []struct {
+		name    string
+		userID  string
+		agentID string
+		want    int
+	}{
+		{"org member", "member-1", "agent-shared", http.StatusOK},
+		{"org viewer", "viewer-1", "agent-shared", http.StatusForbidden},
+		{"org member on an unshared agent", "member-1", "agent-private", http.StatusForbidden},
+		{"outsider", "outsider-1", "agent-shared", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]interface{}{
+				"agent_id":      tt.agentID,
+				"session_topic": "task-001",
+				"status":        "running",
+				"timestamp":     time.Now().Format(time.RFC3339),
+			})
+			req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, testsupport.WithCaller(req, tt.userID, tt.userID+"@example.com"))
+			if rr.Code != tt.want {
+				t.Errorf("ServeHTTP() status = %v, want %v: %s", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+
+	if agent, _ := st.GetAgent("agent-shared"); agent.UserID != "owner-1" {
+		t.Errorf("agent owner after a member's report = %s, want owner-1", agent.UserID)
+	}
+}
+
 func TestWebhookHandler_SessionAutoCreation(t *testing.T) {
 	st := store.NewMemoryStore()
 	handler := NewWebhookHandlerWithNotifier(st, nil)
@@ -154,7 +169,7 @@ func TestWebhookHandler_SessionAutoCreation(t *testing.T) {
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
-	req = addTestUserToContextWebhook(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
@@ -193,7 +208,7 @@ func TestWebhookHandler_SessionUpdateOnTaskEnd(t *testing.T) {
 	body1, _ := json.Marshal(reqBody1)
 	req1 := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body1))
 	req1.Header.Set("Content-Type", "application/json")
-	req1 = addTestUserToContextWebhook(req1)
+	req1 = testsupport.WithUser(req1)
 	rr1 := httptest.NewRecorder()
 	handler.ServeHTTP(rr1, req1)
 
@@ -208,7 +223,7 @@ func TestWebhookHandler_SessionUpdateOnTaskEnd(t *testing.T) {
 	body2, _ := json.Marshal(reqBody2)
 	req2 := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body2))
 	req2.Header.Set("Content-Type", "application/json")
-	req2 = addTestUserToContextWebhook(req2)
+	req2 = testsupport.WithUser(req2)
 	rr2 := httptest.NewRecorder()
 	handler.ServeHTTP(rr2, req2)
 
@@ -260,7 +275,7 @@ func TestWebhookHandler_StatusHistoryRecording(t *testing.T) {
 		body, _ := json.Marshal(reqBody)
 		req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
 		req.Header.Set("Content-Type", "application/json")
-		req = addTestUserToContextWebhook(req)
+		req = testsupport.WithUser(req)
 		rr := httptest.NewRecorder()
 		handler.ServeHTTP(rr, req)
 	}
@@ -275,6 +290,44 @@ func TestWebhookHandler_StatusHistoryRecording(t *testing.T) {
 	}
 }
 
+func TestWebhookHandler_HeartbeatSampling(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	now := time.Now()
+	if err := st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-001", UserID: testsupport.UserID, Registered: now, LastSeen: now, HeartbeatSampleEvery: 3}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+
+	// The first running status and every third repeat of it are kept
+	for i := 0; i < 7; i++ {
+		testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "working", "")
+	}
+	// A new message and a transition are always kept
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "uploading", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "success", now, "done", "")
+
+	// History is newest first
+	history, err := st.GetStatusHistory("agent-001", "task-001")
+	if err != nil {
+		t.Fatalf("GetStatusHistory() error = %v", err)
+	}
+	var got []string
+	for _, status := range history {
+		got = append(got, status.Status+":"+status.Message)
+	}
+	want := []string{"success:done", "running:uploading", "running:working", "running:working", "running:working"}
+	if len(got) != len(want) {
+		t.Fatalf("HeartbeatSampling() history = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("HeartbeatSampling() history = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
 func TestWebhookHandler_InvalidStatusReportData(t *testing.T) {
 	st := store.NewMemoryStore()
 	handler := NewWebhookHandlerWithNotifier(st, nil)
@@ -328,7 +381,7 @@ func TestWebhookHandler_InvalidStatusReportData(t *testing.T) {
 			body, _ := json.Marshal(tt.reqBody)
 			req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
 			req.Header.Set("Content-Type", "application/json")
-			req = addTestUserToContextWebhook(req)
+			req = testsupport.WithUser(req)
 			rr := httptest.NewRecorder()
 
 			handler.ServeHTTP(rr, req)
@@ -360,7 +413,7 @@ func TestWebhookHandler_ConcurrentStatusReports(t *testing.T) {
 			body, _ := json.Marshal(reqBody)
 			req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
 			req.Header.Set("Content-Type", "application/json")
-			req = addTestUserToContextWebhook(req)
+			req = testsupport.WithUser(req)
 			rr := httptest.NewRecorder()
 			handler.ServeHTTP(rr, req)
 			done <- true
@@ -395,7 +448,7 @@ func TestWebhookHandler_ResponseTimeRequirement(t *testing.T) {
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
-	req = addTestUserToContextWebhook(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	start := time.Now()
@@ -426,7 +479,7 @@ func TestWebhookHandler_OptionalFields(t *testing.T) {
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
-	req = addTestUserToContextWebhook(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
@@ -479,7 +532,7 @@ func TestWebhookHandler_SessionExpirationTimeConfiguration(t *testing.T) {
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
-	req = addTestUserToContextWebhook(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
@@ -516,7 +569,7 @@ func TestWebhookHandler_StatusTransitionNotification_RunningToSuccess(t *testing
 	st := store.NewMemoryStore()
 	nm := notifier.NewNotificationManager(5 * time.Second)
 	handler := NewWebhookHandlerWithNotifier(st, nm)
-	createTestUserWithWebhook(t, st, server.URL)
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL))
 
 	now := time.Now()
 
@@ -531,7 +584,7 @@ func TestWebhookHandler_StatusTransitionNotification_RunningToSuccess(t *testing
 	body1, _ := json.Marshal(reqBody1)
 	req1 := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body1))
 	req1.Header.Set("Content-Type", "application/json")
-	req1 = addTestUserToContextWebhook(req1)
+	req1 = testsupport.WithUser(req1)
 	rr1 := httptest.NewRecorder()
 	handler.ServeHTTP(rr1, req1)
 
@@ -557,7 +610,7 @@ func TestWebhookHandler_StatusTransitionNotification_RunningToSuccess(t *testing
 	body2, _ := json.Marshal(reqBody2)
 	req2 := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body2))
 	req2.Header.Set("Content-Type", "application/json")
-	req2 = addTestUserToContextWebhook(req2)
+	req2 = testsupport.WithUser(req2)
 	rr2 := httptest.NewRecorder()
 
 	start := time.Now()
@@ -615,13 +668,13 @@ func TestWebhookHandler_StatusTransitionNotification_RunningToFailed(t *testing.
 	st := store.NewMemoryStore()
 	nm := notifier.NewNotificationManager(5 * time.Second)
 	handler := NewWebhookHandlerWithNotifier(st, nm)
-	createTestUserWithWebhook(t, st, server.URL)
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL))
 
 	now := time.Now()
 
 	// running → failed transition
-	sendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
-	sendStatus(t, handler, "agent-001", "task-001", "failed", now.Add(time.Minute), "Task failed", "Error: timeout")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "failed", now.Add(time.Minute), "Task failed", "Error: timeout")
 
 	time.Sleep(200 * time.Millisecond)
 
@@ -630,6 +683,90 @@ func TestWebhookHandler_StatusTransitionNotification_RunningToFailed(t *testing.
 	}
 }
 
+func TestWebhookHandler_NotificationFansOutToDestinations(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]string) // request path -> body
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		paths[r.URL.Path] = string(body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := store.NewMemoryStore()
+	nm := notifier.NewNotificationManager(5 * time.Second)
+	handler := NewWebhookHandlerWithNotifier(st, nm)
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL+"/main"))
+
+	user, _ := st.GetUserByID(testsupport.UserID)
+	user.NotificationDestinations = []models.NotificationDestination{
+		{URL: server.URL + "/agents/{{.AgentID}}/{{.ToStatus}}"},
+		{URL: server.URL + "/slack", Format: "slack"},
+	}
+	if err := st.UpdateUser(user); err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+
+	now := time.Now()
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "failed", now.Add(time.Minute), "Task failed", "")
+
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/main", "/agents/agent-001/failed", "/slack"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("no notification sent to %s, got %v", path, paths)
+		}
+	}
+	if body := paths["/slack"]; strings.Contains(body, "msg_type") || !strings.Contains(body, `"text"`) {
+		t.Errorf("slack destination payload = %s, want the Slack format", body)
+	}
+}
+
+func TestWebhookHandler_OutboxDefersSideEffects(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := store.NewMemoryStore()
+	nm := notifier.NewNotificationManager(5 * time.Second)
+	ib := inbox.New(st, 0)
+	relay := outbox.NewRelay(st, nm, ib, 3)
+	handler := NewWebhookHandlerWithNotifier(st, nm)
+	handler.SetInbox(ib)
+	handler.SetOutbox(relay)
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL))
+
+	now := time.Now()
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "failed", now.Add(time.Minute), "Task failed", "")
+
+	time.Sleep(100 * time.Millisecond)
+	if received.Load() != 0 {
+		t.Fatal("notification was sent before the relay ran")
+	}
+	if count, _ := st.CountUnreadInboxItems(testsupport.UserID); count != 0 {
+		t.Fatalf("%d inbox items were recorded before the relay ran, want 0", count)
+	}
+
+	if got := relay.Run(); got != 2 {
+		t.Errorf("Run() claimed %d messages, want a notification and an inbox item", got)
+	}
+	if received.Load() != 1 {
+		t.Errorf("relay sent %d notifications, want 1", received.Load())
+	}
+	if count, _ := st.CountUnreadInboxItems(testsupport.UserID); count != 1 {
+		t.Errorf("relay recorded %d inbox items, want 1", count)
+	}
+}
+
 func TestWebhookHandler_NoNotificationForNonRunningTransition(t *testing.T) {
 	// Transition from pending → running should NOT trigger notification
 	var notificationReceived atomic.Bool
@@ -642,13 +779,13 @@ func TestWebhookHandler_NoNotificationForNonRunningTransition(t *testing.T) {
 	st := store.NewMemoryStore()
 	nm := notifier.NewNotificationManager(5 * time.Second)
 	handler := NewWebhookHandlerWithNotifier(st, nm)
-	createTestUserWithWebhook(t, st, server.URL)
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL))
 
 	now := time.Now()
 
 	// pending → running (should not notify)
-	sendStatus(t, handler, "agent-001", "task-001", "pending", now, "", "")
-	sendStatus(t, handler, "agent-001", "task-001", "running", now.Add(time.Second), "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "pending", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now.Add(time.Second), "", "")
 
 	time.Sleep(200 * time.Millisecond)
 
@@ -657,8 +794,8 @@ func TestWebhookHandler_NoNotificationForNonRunningTransition(t *testing.T) {
 	}
 
 	// success → running (should not notify, but weird)
-	sendStatus(t, handler, "agent-002", "task-002", "success", now, "", "")
-	sendStatus(t, handler, "agent-002", "task-002", "running", now.Add(time.Second), "", "")
+	testsupport.SendStatus(t, handler, "agent-002", "task-002", "success", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-002", "task-002", "running", now.Add(time.Second), "", "")
 
 	time.Sleep(200 * time.Millisecond)
 
@@ -677,14 +814,14 @@ func TestWebhookHandler_NotificationFailureDoesNotBlockResponse(t *testing.T) {
 	st := store.NewMemoryStore()
 	nm := notifier.NewNotificationManager(5 * time.Second)
 	handler := NewWebhookHandlerWithNotifier(st, nm)
-	createTestUserWithWebhook(t, st, server.URL)
+	testsupport.CreateUser(t, st, testsupport.UserWithWebhookURL(server.URL))
 
 	now := time.Now()
 
-	sendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
 
 	start := time.Now()
-	rr := sendStatusWithResult(t, handler, "agent-001", "task-001", "success", now.Add(time.Second), "", "")
+	rr := testsupport.PostStatus(handler, "agent-001", "task-001", "success", now.Add(time.Second), "", "")
 	duration := time.Since(start)
 
 	// Should respond immediately
@@ -705,12 +842,12 @@ func TestWebhookHandler_NoNotificationWhenNotifierIsNil(t *testing.T) {
 	now := time.Now()
 
 	// running → success (no crash, no notification)
-	rr1 := sendStatusWithResult(t, handler, "agent-001", "task-001", "running", now, "", "")
+	rr1 := testsupport.PostStatus(handler, "agent-001", "task-001", "running", now, "", "")
 	if rr1.Code != http.StatusOK {
 		t.Errorf("First request status = %v, want %v", rr1.Code, http.StatusOK)
 	}
 
-	rr2 := sendStatusWithResult(t, handler, "agent-001", "task-001", "success", now.Add(time.Second), "Done", "")
+	rr2 := testsupport.PostStatus(handler, "agent-001", "task-001", "success", now.Add(time.Second), "Done", "")
 	if rr2.Code != http.StatusOK {
 		t.Errorf("Second request status = %v, want %v", rr2.Code, http.StatusOK)
 	}
@@ -718,56 +855,460 @@ func TestWebhookHandler_NoNotificationWhenNotifierIsNil(t *testing.T) {
 	// Should not crash or error
 }
 
-// Helper function to send status
-func sendStatus(t *testing.T, handler *WebhookHandler, agentID, sessionTopic, status string, timestamp time.Time, message, content string) {
-	reqBody := map[string]interface{}{
-		"agent_id":      agentID,
-		"agent_name":    "Test Agent",
-		"session_topic": sessionTopic,
-		"status":        status,
-		"timestamp":     timestamp.Format(time.RFC3339),
-	}
-	if message != "" {
-		reqBody["message"] = message
-	}
-	if content != "" {
-		reqBody["content"] = content
+func TestWebhookHandler_SessionGrouping(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	grouper, err := internal.ParseTopicRules(`[{"pattern": "^deploy/(?P<env>[^/]+)/", "group": "deploy", "category": "${env}"}]`)
+	if err != nil {
+		t.Fatalf("ParseTopicRules() error = %v", err)
 	}
+	handler.SetTopicGrouper(grouper)
 
+	reqBody := map[string]interface{}{
+		"agent_id":      "agent-001",
+		"session_topic": "deploy/prod/2024-05-01",
+		"status":        "running",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}
 	body, _ := json.Marshal(reqBody)
 	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	req = addTestUserToContextWebhook(req)
+	req = testsupport.WithUser(req)
 	rr := httptest.NewRecorder()
 	handler.ServeHTTP(rr, req)
 
 	if rr.Code != http.StatusOK {
-		t.Errorf("sendStatus() status = %v, want %v", rr.Code, http.StatusOK)
+		t.Fatalf("SessionGrouping() status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	session, err := st.GetSession("agent-001", "deploy/prod/2024-05-01")
+	if err != nil {
+		t.Fatalf("SessionGrouping() session not created: %v", err)
+	}
+	if session.Group != "deploy" {
+		t.Errorf("SessionGrouping() group = %q, want deploy", session.Group)
+	}
+	if session.Category != "prod" {
+		t.Errorf("SessionGrouping() category = %q, want prod", session.Category)
 	}
 }
 
-// Helper function to send status and return response
-func sendStatusWithResult(t *testing.T, handler *WebhookHandler, agentID, sessionTopic, status string, timestamp time.Time, message, content string) *httptest.ResponseRecorder {
-	reqBody := map[string]interface{}{
-		"agent_id":      agentID,
-		"agent_name":    "Test Agent",
-		"session_topic": sessionTopic,
-		"status":        status,
-		"timestamp":     timestamp.Format(time.RFC3339),
+func TestWebhookHandler_PayloadLimitsByPlan(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	limits, err := internal.NewPayloadLimitPolicy(internal.PayloadLimits{
+		MaxMessageLength: 1000,
+		MaxContentLength: 100,
+		MaxMetadataBytes: 4096,
+	}, `{"pro":{"content":1000}}`)
+	if err != nil {
+		t.Fatalf("NewPayloadLimitPolicy() error = %v", err)
 	}
-	if message != "" {
-		reqBody["message"] = message
+	handler.SetPayloadLimits(limits)
+
+	send := func() *httptest.ResponseRecorder {
+		reqBody := map[string]interface{}{
+			"agent_id":      "agent-001",
+			"session_topic": "task-001",
+			"status":        "running",
+			"timestamp":     time.Now().Format(time.RFC3339),
+			"content":       strings.Repeat("c", 500),
+			"metadata":      map[string]interface{}{"tokens": 42},
+		}
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
+		caller := middleware.NewRequestContext(req, testsupport.UserID, testsupport.UserEmail, st)
+		req = req.WithContext(middleware.WithRequestContext(req.Context(), caller))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
 	}
-	if content != "" {
-		reqBody["content"] = content
+
+	if rr := send(); rr.Code != http.StatusBadRequest {
+		t.Errorf("default plan status = %v, want %v", rr.Code, http.StatusBadRequest)
 	}
 
-	body, _ := json.Marshal(reqBody)
-	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	req = addTestUserToContextWebhook(req)
+	user, _ := st.GetUserByID(testsupport.UserID)
+	user.Plan = "pro"
+	st.UpdateUser(user)
+
+	if rr := send(); rr.Code != http.StatusOK {
+		t.Fatalf("pro plan status = %v, want %v, body = %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+
+	status, err := st.GetLatestStatus("agent-001", "task-001")
+	if err != nil {
+		t.Fatalf("GetLatestStatus() error = %v", err)
+	}
+	if string(status.Metadata) != `{"tokens":42}` {
+		t.Errorf("stored metadata = %s, want {\"tokens\":42}", status.Metadata)
+	}
+}
+
+// conflictingStore simulates a session that keeps changing between read and write
+type conflictingStore struct {
+	*store.MemoryStore
+	writes int32
+}
+
+func (s *conflictingStore) CreateOrUpdateSession(session *models.Session) error {
+	atomic.AddInt32(&s.writes, 1)
+	return store.ErrConflict
+}
+
+func TestWebhookHandler_VersionConflict(t *testing.T) {
+	st := &conflictingStore{MemoryStore: store.NewMemoryStore()}
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	body := `{"agent_id":"agent-001","session_topic":"task-001","status":"running","timestamp":"` + time.Now().Format(time.RFC3339) + `"}`
+	req := testsupport.WithUser(httptest.NewRequest("POST", "/webhook/status", strings.NewReader(body)))
 	rr := httptest.NewRecorder()
+
 	handler.ServeHTTP(rr, req)
 
-	return rr
+	if rr.Code != http.StatusConflict {
+		t.Errorf("ServeHTTP() status = %v, want %v", rr.Code, http.StatusConflict)
+	}
+	if writes := atomic.LoadInt32(&st.writes); writes != maxWriteAttempts {
+		t.Errorf("ServeHTTP() session writes = %d, want %d", writes, maxWriteAttempts)
+	}
+	if history, _ := st.GetStatusHistory("agent-001", "task-001"); len(history) != 0 {
+		t.Errorf("ServeHTTP() recorded %d statuses after conflict, want 0", len(history))
+	}
+}
+
+func TestWebhookHandler_SessionReopenGrace(t *testing.T) {
+	tests := []struct {
+		name         string
+		expiredAgo   time.Duration
+		wantRevision int
+		wantCreated  bool // Whether the session's created time moves to the late report
+		wantInbox    int
+	}{
+		{"within grace period re-opens", 5 * time.Minute, 1, false, 1},
+		{"after grace period starts a new revision", time.Hour, 2, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemoryStore()
+			testsupport.CreateUser(t, st)
+			handler := NewWebhookHandlerWithNotifier(st, nil)
+			handler.SetInbox(inbox.New(st, 0))
+			handler.SetSessionReopenGrace(10 * time.Minute)
+
+			testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", time.Now(), "", "")
+
+			session, _ := st.GetSession("agent-001", "task-001")
+			created := session.Created
+			expiredAt := time.Now().UTC().Add(-tt.expiredAgo)
+			session.Expired = true
+			session.ExpiredAt = &expiredAt
+			if err := st.CreateOrUpdateSession(session); err != nil {
+				t.Fatalf("CreateOrUpdateSession() error = %v", err)
+			}
+
+			testsupport.SendStatus(t, handler, "agent-001", "task-001", "success", time.Now(), "", "")
+
+			session, _ = st.GetSession("agent-001", "task-001")
+			if session.Expired || session.ExpiredAt != nil {
+				t.Errorf("session expired = %v, expired_at = %v, want active", session.Expired, session.ExpiredAt)
+			}
+			if session.Revision != tt.wantRevision {
+				t.Errorf("session revision = %d, want %d", session.Revision, tt.wantRevision)
+			}
+			if moved := !session.Created.Equal(created); moved != tt.wantCreated {
+				t.Errorf("session created moved = %v, want %v", moved, tt.wantCreated)
+			}
+
+			latest, _ := st.GetLatestStatus("agent-001", "task-001")
+			if latest.Revision != tt.wantRevision {
+				t.Errorf("latest status revision = %d, want %d", latest.Revision, tt.wantRevision)
+			}
+
+			items, _ := st.ListInboxItems(testsupport.UserID, false, 0)
+			if len(items) != tt.wantInbox {
+				t.Fatalf("inbox items = %d, want %d", len(items), tt.wantInbox)
+			}
+			if tt.wantInbox > 0 && items[0].Kind != models.InboxKindReopened {
+				t.Errorf("inbox item kind = %q, want %q", items[0].Kind, models.InboxKindReopened)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_SessionLifecycleWithFakeClock(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	st := store.NewMemoryStore()
+	st.SetClock(fake)
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetClock(fake)
+	handler.SetSessionReopenGrace(10 * time.Minute)
+
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", start, "", "")
+
+	// Sessions expire after the default 30 minute TTL without waiting for it
+	fake.Advance(31 * time.Minute)
+	if expired := st.CheckExpiredSessions(); len(expired) != 1 {
+		t.Fatalf("CheckExpiredSessions() = %d sessions, want 1", len(expired))
+	}
+
+	fake.Advance(5 * time.Minute)
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "success", fake.Now(), "", "")
+
+	session, _ := st.GetSession("agent-001", "task-001")
+	if session.Expired || session.Revision != 1 {
+		t.Errorf("session = expired %v, revision %d, want re-opened within the grace period", session.Expired, session.Revision)
+	}
+	latest, _ := st.GetLatestStatus("agent-001", "task-001")
+	if !latest.Timestamp.Equal(fake.Now()) {
+		t.Errorf("latest status timestamp = %v, want the clock's %v", latest.Timestamp, fake.Now())
+	}
+}
+
+func TestWebhookHandler_SessionEndReasons(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	st := store.NewMemoryStore()
+	st.SetClock(fake)
+	testsupport.CreateUser(t, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetClock(fake)
+
+	endReason := func() string {
+		session, err := st.GetSession("agent-001", "task-001")
+		if err != nil {
+			t.Fatalf("GetSession() error = %v", err)
+		}
+		return session.EndReason
+	}
+
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", start, "", "")
+	if got := endReason(); got != "" {
+		t.Errorf("end reason while running = %q, want empty", got)
+	}
+
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "failed", fake.Now(), "", "")
+	if got := endReason(); got != models.EndReasonAgentReported {
+		t.Errorf("end reason after failed = %q, want %q", got, models.EndReasonAgentReported)
+	}
+
+	// Expiry keeps the agent's reason, so a failure is not mistaken for a timeout
+	fake.Advance(31 * time.Minute)
+	st.CheckExpiredSessions()
+	if got := endReason(); got != models.EndReasonAgentReported {
+		t.Errorf("end reason after expiring a finished run = %q, want %q", got, models.EndReasonAgentReported)
+	}
+
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", fake.Now(), "", "")
+	if got := endReason(); got != "" {
+		t.Errorf("end reason after a new run started = %q, want empty", got)
+	}
+
+	fake.Advance(31 * time.Minute)
+	st.CheckExpiredSessions()
+	if got := endReason(); got != models.EndReasonTTLExpired {
+		t.Errorf("end reason after the agent went silent = %q, want %q", got, models.EndReasonTTLExpired)
+	}
+}
+
+func TestWebhookHandler_AgentKind(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetDefaultAgentKind(models.AgentKindCustom)
+
+	report := func(agentID, kind string) int {
+		reqBody := map[string]interface{}{
+			"agent_id":      agentID,
+			"session_topic": "task-001",
+			"status":        "running",
+			"timestamp":     time.Now().Format(time.RFC3339),
+		}
+		if kind != "" {
+			reqBody["agent_kind"] = kind
+		}
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, testsupport.WithUser(req))
+		return rr.Code
+	}
+	kindOf := func(agentID string) string {
+		agent, err := st.GetAgent(agentID)
+		if err != nil {
+			t.Fatalf("GetAgent(%s) error = %v", agentID, err)
+		}
+		return agent.Kind
+	}
+
+	if code := report("agent-001", ""); code != http.StatusOK || kindOf("agent-001") != models.AgentKindCustom {
+		t.Errorf("report without kind = %d, kind %q, want 200 and the default kind", code, kindOf("agent-001"))
+	}
+	if code := report("agent-001", models.AgentKindCron); code != http.StatusOK || kindOf("agent-001") != models.AgentKindCron {
+		t.Errorf("report with kind = %d, kind %q, want 200 and cron", code, kindOf("agent-001"))
+	}
+	if code := report("agent-001", ""); code != http.StatusOK || kindOf("agent-001") != models.AgentKindCron {
+		t.Errorf("later report without kind = %d, kind %q, want the agent to stay cron", code, kindOf("agent-001"))
+	}
+	if code := report("agent-002", "robot"); code != http.StatusBadRequest {
+		t.Errorf("report with unknown kind = %d, want 400", code)
+	}
+}
+
+func TestWebhookHandler_AgentLocation(t *testing.T) {
+	st := store.NewMemoryStore()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+
+	report := func(cluster, region string) int {
+		reqBody := map[string]interface{}{
+			"agent_id":      "agent-001",
+			"session_topic": "task-001",
+			"status":        "running",
+			"timestamp":     time.Now().Format(time.RFC3339),
+		}
+		if cluster != "" {
+			reqBody["cluster"] = cluster
+		}
+		if region != "" {
+			reqBody["region"] = region
+		}
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, testsupport.WithUser(req))
+		return rr.Code
+	}
+	locationOf := func() string {
+		agent, err := st.GetAgent("agent-001")
+		if err != nil {
+			t.Fatalf("GetAgent() error = %v", err)
+		}
+		return agent.Cluster + "/" + agent.Region
+	}
+
+	if code := report("prod-eu-1", "eu-west-1"); code != http.StatusOK || locationOf() != "prod-eu-1/eu-west-1" {
+		t.Errorf("report with location = %d, %s, want 200 and prod-eu-1/eu-west-1", code, locationOf())
+	}
+	if code := report("", ""); code != http.StatusOK || locationOf() != "prod-eu-1/eu-west-1" {
+		t.Errorf("report without location = %d, %s, want the agent to keep its location", code, locationOf())
+	}
+	if code := report("prod-us-1", ""); code != http.StatusOK || locationOf() != "prod-us-1/eu-west-1" {
+		t.Errorf("report from another cluster = %d, %s, want prod-us-1/eu-west-1", code, locationOf())
+	}
+	if code := report("prod eu", ""); code != http.StatusBadRequest {
+		t.Errorf("report with invalid cluster = %d, want 400", code)
+	}
+}
+
+func TestWebhookHandler_ServerStatusesSkippedForTransitions(t *testing.T) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(t, st)
+	broker := events.NewBroker()
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	handler.SetEvents(broker)
+
+	now := time.Now().UTC()
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "running", now, "", "")
+	session, _ := st.GetSession("agent-001", "task-001")
+	if err := st.AddStatus(models.NewServerStatus(session, models.StatusExpired, "", now.Add(time.Second))); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+
+	received, unsubscribe := broker.Subscribe("agent-001")
+	defer unsubscribe()
+	testsupport.SendStatus(t, handler, "agent-001", "task-001", "success", now.Add(time.Minute), "done", "")
+
+	event := <-received
+	if event.FromStatus != "running" || event.Origin != models.StatusOriginAgent {
+		t.Errorf("event = %+v, want a transition from the agent's running status", event)
+	}
+	history, _ := st.GetStatusHistory("agent-001", "task-001")
+	fromServer := 0
+	for _, status := range history {
+		if status.FromServer() {
+			fromServer++
+		}
+	}
+	if len(history) != 3 || fromServer != 1 {
+		t.Errorf("history has %d statuses, %d from the server, want 3 with 1 from the server", len(history), fromServer)
+	}
+}
+
+func TestWebhookHandler_DecodedReportsKeepTheirFields(t *testing.T) {
+	handler := NewWebhookHandlerWithNotifier(store.NewMemoryStore(), nil)
+	caller := middleware.NewRequestContext(httptest.NewRequest("POST", "/", nil), testsupport.UserID, testsupport.UserEmail, nil)
+	limits := handler.payloadLimitsFor(caller)
+	decode := func(body string) *internal.StatusReport {
+		t.Helper()
+		rr := httptest.NewRecorder()
+		report, ok := handler.decodeStatusReport(rr, httptest.NewRequest("POST", "/webhook/status", strings.NewReader(body)), caller, limits)
+		if !ok {
+			t.Fatalf("decodeStatusReport() rejected %s: %s", body, rr.Body.String())
+		}
+		return report
+	}
+
+	// Body buffers are reused, so a report must not point into the one it was read from
+	first := decode(`{"agent_id":"agent-001","session_topic":"task-001","status":"running","timestamp":"2026-03-01T12:00:00Z","message":"first","metadata":{"step":1}}`)
+	decode(`{"agent_id":"agent-002","session_topic":"task-002","status":"failed","timestamp":"2026-03-01T12:00:01Z","message":"second","metadata":{"step":2}}`)
+	if first.AgentID != "agent-001" || first.Message != "first" || string(first.Metadata) != `{"step":1}` {
+		t.Errorf("first report = %+v, want its own fields after the next report is decoded", first)
+	}
+}
+
+// Webhook benchmarks guard the per-report cost of /webhook/status for large fleets.
+// Target on a modern x86 core: decoding under 5µs and 6 allocations per report, and recording one in 30
+// allocations however long the session's history is.
+// Run with: ./scripts/check.sh --bench
+
+// benchmarkStatusReport is a typical report of a fleet agent, with a message, content and metadata
+var benchmarkStatusReport = []byte(`{"agent_id":"agent-001","agent_name":"Build Agent","agent_source":"ci-runner",` +
+	`"agent_kind":"ci","cluster":"prod-eu-1","region":"eu-west-1","session_topic":"pipeline-18342/test",` +
+	`"status":"running","timestamp":"2026-03-01T12:00:00Z","message":"Running integration tests (412/1280)",` +
+	`"content":"go test ./... -race -count=1\nok  \tgithub.com/example/service/api\t4.211s",` +
+	`"metadata":{"commit":"9f2c1e4","branch":"main","shard":3,"shards":8},"ttl_minutes":30}`)
+
+func BenchmarkWebhookHandler_DecodeStatusReport(b *testing.B) {
+	handler := NewWebhookHandlerWithNotifier(store.NewMemoryStore(), nil)
+	caller := middleware.NewRequestContext(httptest.NewRequest("POST", "/", nil), testsupport.UserID, testsupport.UserEmail, nil)
+	limits := handler.payloadLimitsFor(caller)
+	body := bytes.NewReader(benchmarkStatusReport)
+	req := httptest.NewRequest("POST", "/webhook/status", body)
+	rr := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkStatusReport)))
+	for i := 0; i < b.N; i++ {
+		body.Reset(benchmarkStatusReport)
+		req.Body = io.NopCloser(body)
+		if _, ok := handler.decodeStatusReport(rr, req, caller, limits); !ok {
+			b.Fatalf("decodeStatusReport() rejected the report: %s", rr.Body.String())
+		}
+	}
+}
+
+func BenchmarkWebhookHandler_ServeHTTP(b *testing.B) {
+	st := store.NewMemoryStore()
+	testsupport.CreateUser(b, st)
+	handler := NewWebhookHandlerWithNotifier(st, nil)
+	body := bytes.NewReader(benchmarkStatusReport)
+	req := testsupport.WithUser(httptest.NewRequest("POST", "/webhook/status", body))
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkStatusReport)))
+	for i := 0; i < b.N; i++ {
+		body.Reset(benchmarkStatusReport)
+		req.Body = io.NopCloser(body)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			b.Fatalf("ServeHTTP() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+		}
+	}
 }
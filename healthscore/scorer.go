@@ -0,0 +1,195 @@
+// Package healthscore computes at-a-glance health scores for agents and their owners
+package healthscore
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// offlineFalloff is how many offline thresholds of silence take the offline component to zero
+const offlineFalloff = 4
+
+// Config weights the score components and sets their thresholds
+type Config struct {
+	FailureWeight float64       // Weight of the failure rate of recently finished sessions
+	OfflineWeight float64       // Weight of how long the agent has been silent
+	StuckWeight   float64       // Weight of the share of active sessions with no recent progress
+	Window        time.Duration // Sessions finished within this window count towards the failure rate
+	OfflineAfter  time.Duration // Silence after which an agent starts losing score; 0 ignores silence
+	StuckAfter    time.Duration // Running sessions without an update for this long are stuck
+}
+
+// AgentScore is the health of one agent, from 0 (unhealthy) to 100 (healthy)
+type AgentScore struct {
+	AgentID        string  `json:"agent_id"`
+	Name           string  `json:"name,omitempty"`
+	Cluster        string  `json:"cluster,omitempty"`
+	Region         string  `json:"region,omitempty"`
+	Score          float64 `json:"score"`
+	FailureRate    float64 `json:"failure_rate"`
+	FinishedRuns   int     `json:"finished_runs"`
+	OfflineMinutes float64 `json:"offline_minutes"` // Silence beyond the offline threshold
+	ActiveSessions int     `json:"active_sessions"`
+	StuckSessions  int     `json:"stuck_sessions"`
+}
+
+// UserScore is the health of a user's fleet, the average of their agents' scores
+type UserScore struct {
+	Score        float64       `json:"score"`
+	Agents       []*AgentScore `json:"agents"`
+	CalculatedAt time.Time     `json:"calculated_at"`
+}
+
+// Scorer periodically recalculates health scores and serves them from memory
+type Scorer struct {
+	store  store.Store
+	config Config
+	clock  clock.Clock
+
+	mu           sync.RWMutex
+	agents       map[string]*AgentScore
+	users        map[string]*UserScore
+	calculatedAt time.Time
+}
+
+// NewScorer creates a scorer; scores are empty until the first Recalculate
+func NewScorer(st store.Store, config Config) *Scorer {
+	return &Scorer{
+		store:  st,
+		config: config,
+		clock:  clock.Real,
+		agents: make(map[string]*AgentScore),
+		users:  make(map[string]*UserScore),
+	}
+}
+
+// SetClock replaces the clock that decides the scoring window
+func (s *Scorer) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// now returns the time scores are calculated at, in UTC
+func (s *Scorer) now() time.Time {
+	return s.clock.Now().UTC()
+}
+
+// Recalculate scores every agent and user
+func (s *Scorer) Recalculate() {
+	now := s.now()
+
+	agents := make(map[string]*AgentScore)
+	byUser := make(map[string][]*AgentScore)
+	for _, agent := range s.store.ListAgents() {
+		score := s.scoreAgent(agent, now)
+		agents[agent.AgentID] = score
+		byUser[agent.UserID] = append(byUser[agent.UserID], score)
+	}
+
+	users := make(map[string]*UserScore, len(byUser))
+	for userID, scores := range byUser {
+		sort.Slice(scores, func(i, j int) bool {
+			return scores[i].AgentID < scores[j].AgentID
+		})
+
+		var total float64
+		for _, score := range scores {
+			total += score.Score
+		}
+		users[userID] = &UserScore{
+			Score:        round(total / float64(len(scores))),
+			Agents:       scores,
+			CalculatedAt: now,
+		}
+	}
+
+	s.mu.Lock()
+	s.agents = agents
+	s.users = users
+	s.calculatedAt = now
+	s.mu.Unlock()
+}
+
+// ForAgent returns the agent's latest score, or nil if it has not been scored yet
+func (s *Scorer) ForAgent(agentID string) *AgentScore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.agents[agentID]
+}
+
+// ForUser returns the user's latest score; a user without scored agents gets an empty fleet scoring 100
+func (s *Scorer) ForUser(userID string) *UserScore {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if score, ok := s.users[userID]; ok {
+		return score
+	}
+	return &UserScore{Score: 100, Agents: []*AgentScore{}, CalculatedAt: s.calculatedAt}
+}
+
+// scoreAgent weighs the agent's failure rate, silence and stuck sessions into one score
+func (s *Scorer) scoreAgent(agent *models.Agent, now time.Time) *AgentScore {
+	score := &AgentScore{AgentID: agent.AgentID, Name: agent.Name, Cluster: agent.Cluster, Region: agent.Region}
+
+	var failed int
+	for _, session := range s.store.ListSessions(agent.AgentID, true) {
+		latest, err := s.store.GetLatestStatus(agent.AgentID, session.SessionTopic)
+		if err != nil || latest == nil {
+			continue
+		}
+
+		if internal.IsFinalStatus(latest.Status) {
+			if now.Sub(latest.Timestamp) <= s.config.Window {
+				score.FinishedRuns++
+				if latest.Status == "failed" {
+					failed++
+				}
+			}
+			continue
+		}
+		if session.Expired {
+			continue
+		}
+		score.ActiveSessions++
+		if s.config.StuckAfter > 0 && now.Sub(session.LastUpdated) >= s.config.StuckAfter {
+			score.StuckSessions++
+		}
+	}
+
+	failureHealth, offlineHealth, stuckHealth := 1.0, 1.0, 1.0
+	if score.FinishedRuns > 0 {
+		score.FailureRate = float64(failed) / float64(score.FinishedRuns)
+		failureHealth = 1 - score.FailureRate
+	}
+	if s.config.OfflineAfter > 0 {
+		if overdue := now.Sub(agent.LastSeen) - s.config.OfflineAfter; overdue > 0 {
+			score.OfflineMinutes = math.Round(overdue.Minutes())
+			falloff := time.Duration(offlineFalloff-1) * s.config.OfflineAfter
+			offlineHealth = math.Max(0, 1-float64(overdue)/float64(falloff))
+		}
+	}
+	if score.ActiveSessions > 0 {
+		stuckHealth = 1 - float64(score.StuckSessions)/float64(score.ActiveSessions)
+	}
+
+	weights := s.config.FailureWeight + s.config.OfflineWeight + s.config.StuckWeight
+	if weights <= 0 {
+		score.Score = 100
+		return score
+	}
+	weighted := s.config.FailureWeight*failureHealth + s.config.OfflineWeight*offlineHealth + s.config.StuckWeight*stuckHealth
+	score.Score = round(100 * weighted / weights)
+	return score
+}
+
+// round rounds a score to one decimal place
+func round(score float64) float64 {
+	return math.Round(score*10) / 10
+}
@@ -0,0 +1,119 @@
+package healthscore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+var testNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+var testConfig = Config{
+	FailureWeight: 0.5,
+	OfflineWeight: 0.3,
+	StuckWeight:   0.2,
+	Window:        24 * time.Hour,
+	OfflineAfter:  15 * time.Minute,
+	StuckAfter:    time.Hour,
+}
+
+// addSession creates a session whose latest status was reported at updated
+func addSession(st store.Store, agentID, topic, status string, updated time.Time) {
+	st.CreateOrUpdateSession(&models.Session{
+		AgentID:      agentID,
+		SessionTopic: topic,
+		Created:      updated.Add(-time.Minute),
+		LastUpdated:  updated,
+		Revision:     1,
+	})
+	st.AddStatus(&models.AgentStatus{
+		AgentID:      agentID,
+		SessionTopic: topic,
+		Status:       status,
+		Timestamp:    updated,
+		Revision:     1,
+	})
+}
+
+func TestScorer_Recalculate(t *testing.T) {
+	st := store.NewMemoryStore()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "healthy", UserID: "user-001", Registered: testNow, LastSeen: testNow})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "troubled", UserID: "user-001", Registered: testNow, LastSeen: testNow.Add(-30 * time.Minute)})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "other", UserID: "user-002", Registered: testNow, LastSeen: testNow.Add(-2 * time.Hour)})
+
+	addSession(st, "healthy", "done", "success", testNow.Add(-time.Hour))
+	addSession(st, "healthy", "working", "running", testNow.Add(-time.Minute))
+
+	addSession(st, "troubled", "ok", "success", testNow.Add(-time.Hour))
+	addSession(st, "troubled", "broken", "failed", testNow.Add(-time.Hour))
+	addSession(st, "troubled", "old-failure", "failed", testNow.Add(-48*time.Hour))
+	addSession(st, "troubled", "stuck", "running", testNow.Add(-2*time.Hour))
+	addSession(st, "troubled", "moving", "running", testNow.Add(-time.Minute))
+
+	scorer := NewScorer(st, testConfig)
+	scorer.SetClock(clock.NewFake(testNow))
+
+	if score := scorer.ForAgent("healthy"); score != nil {
+		t.Fatalf("ForAgent() before Recalculate = %+v, want nil", score)
+	}
+
+	scorer.Recalculate()
+
+	healthy := scorer.ForAgent("healthy")
+	if healthy == nil || healthy.Score != 100 || healthy.FinishedRuns != 1 || healthy.ActiveSessions != 1 {
+		t.Errorf("ForAgent(healthy) = %+v, want score 100 with 1 finished and 1 active session", healthy)
+	}
+
+	// Failure 1/2, 15 minutes overdue out of a 45 minute falloff, 1 of 2 active sessions stuck
+	troubled := scorer.ForAgent("troubled")
+	want := AgentScore{AgentID: "troubled", Score: 55, FailureRate: 0.5, FinishedRuns: 2, OfflineMinutes: 15, ActiveSessions: 2, StuckSessions: 1}
+	if troubled == nil || *troubled != want {
+		t.Errorf("ForAgent(troubled) = %+v, want %+v", troubled, want)
+	}
+
+	// Offline beyond the falloff leaves only the failure and stuck components
+	if other := scorer.ForAgent("other"); other == nil || other.Score != 70 {
+		t.Errorf("ForAgent(other) = %+v, want score 70", other)
+	}
+
+	user := scorer.ForUser("user-001")
+	if user.Score != 77.5 || len(user.Agents) != 2 || user.Agents[0].AgentID != "healthy" || !user.CalculatedAt.Equal(testNow) {
+		t.Errorf("ForUser(user-001) = %+v, want score 77.5 over healthy and troubled", user)
+	}
+
+	if empty := scorer.ForUser("user-404"); empty.Score != 100 || len(empty.Agents) != 0 {
+		t.Errorf("ForUser(user-404) = %+v, want an empty fleet scoring 100", empty)
+	}
+}
+
+func TestScorer_Weights(t *testing.T) {
+	st := store.NewMemoryStore()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-001", UserID: "user-001", Registered: testNow, LastSeen: testNow})
+	addSession(st, "agent-001", "broken", "failed", testNow.Add(-time.Hour))
+
+	tests := []struct {
+		name   string
+		config Config
+		want   float64
+	}{
+		{"failure only", Config{FailureWeight: 1, Window: 24 * time.Hour}, 0},
+		{"ignoring failures", Config{OfflineWeight: 1, StuckWeight: 1, Window: 24 * time.Hour}, 100},
+		{"outside window", Config{FailureWeight: 1, Window: 30 * time.Minute}, 100},
+		{"no weights", Config{Window: 24 * time.Hour}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorer := NewScorer(st, tt.config)
+			scorer.SetClock(clock.NewFake(testNow))
+			scorer.Recalculate()
+
+			if score := scorer.ForAgent("agent-001"); score.Score != tt.want {
+				t.Errorf("ForAgent() score = %v, want %v", score.Score, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+// Package inbox records user-facing notifications and streams new ones to connected clients
+package inbox
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// subscriberBuffer is how many items a slow subscriber may fall behind before new ones are dropped for it
+const subscriberBuffer = 16
+
+// Inbox stores notifications for the dashboard and fans them out to live subscribers
+type Inbox struct {
+	store        store.Store
+	offlineAfter time.Duration
+	clock        clock.Clock
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan *models.InboxItem]struct{} // user_id -> channels
+	relay       func(*models.InboxItem)
+}
+
+// New creates an inbox; agents not seen for offlineAfter are reported offline, and 0 disables the check
+func New(st store.Store, offlineAfter time.Duration) *Inbox {
+	return &Inbox{
+		store:        st,
+		offlineAfter: offlineAfter,
+		clock:        clock.Real,
+		subscribers:  make(map[string]map[chan *models.InboxItem]struct{}),
+	}
+}
+
+// SetClock replaces the clock that stamps items and decides when agents count as offline
+func (b *Inbox) SetClock(c clock.Clock) {
+	b.clock = c
+}
+
+// now returns the time items are stamped with, in UTC
+func (b *Inbox) now() time.Time {
+	return b.clock.Now().UTC()
+}
+
+// Subscribe returns a channel receiving the user's new items and a function that ends the subscription
+func (b *Inbox) Subscribe(userID string) (<-chan *models.InboxItem, func()) {
+	ch := make(chan *models.InboxItem, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan *models.InboxItem]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[userID], ch)
+		if len(b.subscribers[userID]) == 0 {
+			delete(b.subscribers, userID)
+		}
+	}
+}
+
+// Publish stores an item and sends it to the user's subscribers and the relay
+// Items for an event that was already recorded are ignored.
+func (b *Inbox) Publish(item *models.InboxItem) {
+	if err := b.Record(item); err != nil {
+		log.Printf("Failed to record inbox item: %v", err)
+	}
+}
+
+// Record is Publish returning the store error, so the caller can retry
+func (b *Inbox) Record(item *models.InboxItem) error {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = b.now()
+	}
+
+	if err := b.store.CreateInboxItem(item); err != nil {
+		if errors.Is(err, store.ErrAlreadyExists) {
+			return nil
+		}
+		return err
+	}
+
+	b.Deliver(item)
+
+	b.mu.Lock()
+	relay := b.relay
+	b.mu.Unlock()
+	if relay != nil {
+		relay(item)
+	}
+	return nil
+}
+
+// SetRelay passes every item recorded here to fn as well, e.g. to send it to other replicas
+// fn is called synchronously, so it must not block.
+func (b *Inbox) SetRelay(fn func(*models.InboxItem)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.relay = fn
+}
+
+// Deliver sends an item to the user's subscribers without storing it, e.g. one relayed from another replica
+func (b *Inbox) Deliver(item *models.InboxItem) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[item.UserID] {
+		select {
+		case ch <- item:
+		default:
+			// The client still sees the item on its next list request
+		}
+	}
+}
+
+// SessionFailed records a session reporting the failed status
+func (b *Inbox) SessionFailed(agent *models.Agent, status *models.AgentStatus) {
+	if item := FailureItem(agent, status); item != nil {
+		b.Publish(item)
+	}
+}
+
+// FailureItem builds the item for a session reporting the failed status, or nil if the agent has no owner
+func FailureItem(agent *models.Agent, status *models.AgentStatus) *models.InboxItem {
+	if agent.UserID == "" {
+		return nil
+	}
+
+	message := fmt.Sprintf("Session %s of %s failed", status.SessionTopic, agentName(agent))
+	if status.Message != "" {
+		message += ": " + status.Message
+	}
+
+	return &models.InboxItem{
+		UserID:       agent.UserID,
+		Kind:         models.InboxKindFailure,
+		AgentID:      agent.AgentID,
+		SessionTopic: status.SessionTopic,
+		Message:      message,
+		DedupeKey:    dedupeKey(models.InboxKindFailure, agent.AgentID, status.SessionTopic, status.Timestamp),
+	}
+}
+
+// SessionsExpired records sessions that were just marked expired
+// Sessions whose run already ended, with a final status or with their agent, are skipped.
+func (b *Inbox) SessionsExpired(sessions []*models.Session) {
+	agents := make(map[string]*models.Agent)
+	for _, session := range sessions {
+		if session.EndedBeforeExpiry() {
+			continue
+		}
+		agent, ok := agents[session.AgentID]
+		if !ok {
+			loaded, err := b.store.GetAgent(session.AgentID)
+			if err != nil {
+				log.Printf("Failed to load agent for inbox item: %v", err)
+			}
+			agent = loaded
+			agents[session.AgentID] = agent
+		}
+		if agent == nil || agent.UserID == "" {
+			continue
+		}
+
+		expiredAt := b.now()
+		if session.ExpiredAt != nil {
+			expiredAt = *session.ExpiredAt
+		}
+
+		b.Publish(&models.InboxItem{
+			UserID:       agent.UserID,
+			Kind:         models.InboxKindExpiration,
+			AgentID:      agent.AgentID,
+			SessionTopic: session.SessionTopic,
+			Message:      expirationMessage(session, agent),
+			DedupeKey:    dedupeKey(models.InboxKindExpiration, agent.AgentID, session.SessionTopic, expiredAt),
+		})
+	}
+}
+
+// SessionReopened records an expired session that was re-opened by a report within the grace period
+func (b *Inbox) SessionReopened(agent *models.Agent, session *models.Session, expiredAt time.Time) {
+	if item := ReopenedItem(agent, session, expiredAt); item != nil {
+		b.Publish(item)
+	}
+}
+
+// ReopenedItem builds the item for a re-opened session, or nil if the agent has no owner
+func ReopenedItem(agent *models.Agent, session *models.Session, expiredAt time.Time) *models.InboxItem {
+	if agent.UserID == "" {
+		return nil
+	}
+
+	return &models.InboxItem{
+		UserID:       agent.UserID,
+		Kind:         models.InboxKindReopened,
+		AgentID:      agent.AgentID,
+		SessionTopic: session.SessionTopic,
+		Message:      fmt.Sprintf("Session %s of %s reported again after expiring and was re-opened", session.SessionTopic, agentName(agent)),
+		DedupeKey:    dedupeKey(models.InboxKindReopened, agent.AgentID, session.SessionTopic, expiredAt),
+	}
+}
+
+// CheckOffline records agents that have not reported for longer than the offline threshold
+// Each silence is reported once; an agent that reports again can be reported offline again later.
+func (b *Inbox) CheckOffline() {
+	if b.offlineAfter <= 0 {
+		return
+	}
+
+	cutoff := b.now().Add(-b.offlineAfter)
+	for _, agent := range b.store.ListAgents() {
+		if agent.UserID == "" || !agent.LastSeen.Before(cutoff) {
+			continue
+		}
+
+		b.Publish(&models.InboxItem{
+			UserID:    agent.UserID,
+			Kind:      models.InboxKindOffline,
+			AgentID:   agent.AgentID,
+			Message:   fmt.Sprintf("%s has not reported since %s", agentName(agent), agent.LastSeen.UTC().Format(time.RFC3339)),
+			DedupeKey: dedupeKey(models.InboxKindOffline, agent.AgentID, "", agent.LastSeen),
+		})
+	}
+}
+
+// dedupeKey identifies one occurrence of an event for an agent or session
+func dedupeKey(kind, agentID, sessionTopic string, at time.Time) string {
+	return kind + "|" + agentID + "|" + sessionTopic + "|" + strconv.FormatInt(at.UnixNano(), 10)
+}
+
+// agentName returns the agent's display name, falling back to its ID
+func agentName(agent *models.Agent) string {
+	if agent.Name != "" {
+		return agent.Name
+	}
+	return agent.AgentID
+}
+
+// expirationMessage describes why a session ended without a final status
+func expirationMessage(session *models.Session, agent *models.Agent) string {
+	switch session.EndReason {
+	case models.EndReasonCancelled:
+		return fmt.Sprintf("Session %s of %s was cancelled", session.SessionTopic, agentName(agent))
+	case models.EndReasonCleanup:
+		return fmt.Sprintf("Session %s of %s was closed by server-side cleanup", session.SessionTopic, agentName(agent))
+	default:
+		return fmt.Sprintf("Session %s of %s expired without a final status", session.SessionTopic, agentName(agent))
+	}
+}
@@ -0,0 +1,110 @@
+package inbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestInbox_PublishDeliversOnce(t *testing.T) {
+	st := store.NewMemoryStore()
+	b := New(st, 0)
+
+	items, unsubscribe := b.Subscribe("user-1")
+	defer unsubscribe()
+
+	for i := 0; i < 2; i++ {
+		b.Publish(&models.InboxItem{
+			UserID:    "user-1",
+			Kind:      models.InboxKindFailure,
+			AgentID:   "agent-1",
+			DedupeKey: "failure|agent-1",
+		})
+	}
+
+	select {
+	case item := <-items:
+		if item.ID == "" || item.CreatedAt.IsZero() {
+			t.Errorf("Publish() item = %+v, want ID and CreatedAt set", item)
+		}
+	default:
+		t.Fatal("Publish() did not deliver the item to the subscriber")
+	}
+	select {
+	case item := <-items:
+		t.Errorf("Publish() delivered duplicate item %+v", item)
+	default:
+	}
+
+	if count, _ := st.CountUnreadInboxItems("user-1"); count != 1 {
+		t.Errorf("Publish() stored %d items, want 1", count)
+	}
+}
+
+func TestInbox_SessionsExpired(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", UserID: "user-1", Name: "Builder", Registered: now, LastSeen: now})
+	st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "build-1", Created: now.Add(-time.Hour), LastUpdated: now.Add(-time.Hour), TTLMinutes: 30})
+
+	b := New(st, 0)
+	b.SessionsExpired(st.CheckExpiredSessions())
+
+	items, _ := st.ListInboxItems("user-1", false, 0)
+	if len(items) != 1 {
+		t.Fatalf("SessionsExpired() recorded %d items, want 1", len(items))
+	}
+	if items[0].Kind != models.InboxKindExpiration || items[0].SessionTopic != "build-1" {
+		t.Errorf("SessionsExpired() item = %+v, want expiration of build-1", items[0])
+	}
+}
+
+func TestInbox_SessionsExpiredByEndReason(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", UserID: "user-1", Name: "Builder", Registered: now, LastSeen: now})
+
+	b := New(st, 0)
+	b.SessionsExpired([]*models.Session{
+		{AgentID: "agent-1", SessionTopic: "finished", EndReason: models.EndReasonAgentReported, ExpiredAt: &now},
+		{AgentID: "agent-1", SessionTopic: "cancelled", EndReason: models.EndReasonCancelled, ExpiredAt: &now},
+	})
+
+	items, _ := st.ListInboxItems("user-1", false, 0)
+	if len(items) != 1 {
+		t.Fatalf("SessionsExpired() recorded %d items, want only the cancelled session", len(items))
+	}
+	if items[0].Message != "Session cancelled of Builder was cancelled" {
+		t.Errorf("SessionsExpired() message = %q, want the cancellation", items[0].Message)
+	}
+}
+
+func TestInbox_CheckOffline(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now().UTC()
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "silent", UserID: "user-1", Registered: now, LastSeen: now.Add(-time.Hour)})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "active", UserID: "user-1", Registered: now, LastSeen: now})
+
+	b := New(st, 15*time.Minute)
+	b.SetClock(clock.NewFake(now))
+	b.CheckOffline()
+	b.CheckOffline()
+
+	items, _ := st.ListInboxItems("user-1", false, 0)
+	if len(items) != 1 || items[0].AgentID != "silent" || items[0].Kind != models.InboxKindOffline {
+		t.Errorf("CheckOffline() items = %+v, want one offline item for silent", items)
+	}
+
+	// A later silence after the agent reports again is reported again
+	agent, _ := st.GetAgent("silent")
+	agent.LastSeen = now.Add(-20 * time.Minute)
+	st.CreateOrUpdateAgent(agent)
+	b.CheckOffline()
+
+	if count, _ := st.CountUnreadInboxItems("user-1"); count != 2 {
+		t.Errorf("CheckOffline() after new silence recorded %d items, want 2", count)
+	}
+}
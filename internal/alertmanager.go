@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// AlertmanagerPayload is the body Alertmanager posts to webhook receivers (payload version 4)
+type AlertmanagerPayload struct {
+	Version  string              `json:"version"`
+	GroupKey string              `json:"groupKey"`
+	Status   string              `json:"status"`
+	Receiver string              `json:"receiver"`
+	Alerts   []AlertmanagerAlert `json:"alerts"`
+}
+
+// AlertmanagerAlert is one alert of an Alertmanager notification
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// AlertmanagerAgentID is the synthetic agent alerts are reported for when no agent is given
+func AlertmanagerAgentID(receiver string) string {
+	if receiver == "" {
+		return "alertmanager"
+	}
+	return "alertmanager-" + receiver
+}
+
+// StatusReports maps each alert to a status report of the agent
+// Every alert becomes its own session, running while it fires and successful once resolved.
+func (p *AlertmanagerPayload) StatusReports(agentID string, now time.Time) ([]*StatusReport, error) {
+	reports := make([]*StatusReport, 0, len(p.Alerts))
+	for _, alert := range p.Alerts {
+		report, err := alert.statusReport(agentID, now)
+		if err != nil {
+			return nil, err
+		}
+		report.AgentName = p.Receiver
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// statusReport maps one alert to a status report
+func (a *AlertmanagerAlert) statusReport(agentID string, now time.Time) (*StatusReport, error) {
+	report := &StatusReport{
+		AgentID:      agentID,
+		AgentSource:  "alertmanager",
+		AgentKind:    models.AgentKindOperator,
+		SessionTopic: a.topic(),
+		Message:      a.Annotations["summary"],
+		Content:      a.Annotations["description"],
+	}
+	if report.Message == "" {
+		report.Message = a.Labels["alertname"]
+	}
+
+	switch a.Status {
+	case "firing":
+		report.Status = "running"
+		report.Timestamp = a.StartsAt
+	case "resolved":
+		report.Status = "success"
+		report.Timestamp = a.EndsAt
+	default:
+		return nil, errors.New("alert status must be firing or resolved")
+	}
+	if report.Timestamp.IsZero() {
+		report.Timestamp = now
+	}
+
+	metadata, err := json.Marshal(map[string]interface{}{
+		"labels":        a.Labels,
+		"fingerprint":   a.Fingerprint,
+		"generator_url": a.GeneratorURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Metadata = metadata
+
+	return report, nil
+}
+
+// topic names the alert's session after its alert name and fingerprint
+// Alertmanager versions without fingerprints get one derived from the labels.
+func (a *AlertmanagerAlert) topic() string {
+	name := a.Labels["alertname"]
+	if name == "" {
+		name = "alert"
+	}
+
+	fingerprint := a.Fingerprint
+	if fingerprint == "" {
+		names := make([]string, 0, len(a.Labels))
+		for label := range a.Labels {
+			names = append(names, label)
+		}
+		sort.Strings(names)
+
+		hash := sha256.New()
+		for _, label := range names {
+			hash.Write([]byte(label + "\x00" + a.Labels[label] + "\x00"))
+		}
+		fingerprint = hex.EncodeToString(hash.Sum(nil))[:16]
+	}
+
+	return name + "/" + fingerprint
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerPayload_StatusReports(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	started := now.Add(-10 * time.Minute)
+
+	payload := &AlertmanagerPayload{
+		Receiver: "ops",
+		Alerts: []AlertmanagerAlert{
+			{
+				Status:      "firing",
+				Labels:      map[string]string{"alertname": "HighLatency", "instance": "api-1"},
+				Annotations: map[string]string{"summary": "p99 above 1s", "description": "Latency is high"},
+				StartsAt:    started,
+				Fingerprint: "abc123",
+			},
+			{
+				Status: "resolved",
+				Labels: map[string]string{"alertname": "DiskFull", "instance": "db-1"},
+			},
+		},
+	}
+
+	reports, err := payload.StatusReports(AlertmanagerAgentID(payload.Receiver), now)
+	if err != nil {
+		t.Fatalf("StatusReports() error = %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("StatusReports() = %d reports, want 2", len(reports))
+	}
+
+	firing := reports[0]
+	if firing.AgentID != "alertmanager-ops" || firing.AgentName != "ops" || firing.AgentSource != "alertmanager" ||
+		firing.SessionTopic != "HighLatency/abc123" || firing.Status != "running" || !firing.Timestamp.Equal(started) ||
+		firing.Message != "p99 above 1s" || firing.Content != "Latency is high" {
+		t.Errorf("StatusReports() firing = %+v", firing)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(firing.Metadata, &metadata); err != nil || metadata["fingerprint"] != "abc123" {
+		t.Errorf("StatusReports() firing metadata = %s, %v", firing.Metadata, err)
+	}
+	if err := firing.Validate(); err != nil {
+		t.Errorf("Validate() firing error = %v", err)
+	}
+
+	resolved := reports[1]
+	if resolved.Status != "success" || !resolved.Timestamp.Equal(now) || resolved.Message != "DiskFull" {
+		t.Errorf("StatusReports() resolved = %+v", resolved)
+	}
+	// Without a fingerprint the topic is derived from the labels, so it is stable across notifications
+	again, _ := payload.StatusReports("agent", now)
+	if len(resolved.SessionTopic) != len("DiskFull/")+16 || again[1].SessionTopic != resolved.SessionTopic {
+		t.Errorf("StatusReports() resolved topic = %q, then %q", resolved.SessionTopic, again[1].SessionTopic)
+	}
+
+	invalid := &AlertmanagerPayload{Alerts: []AlertmanagerAlert{{Status: "pending"}}}
+	if _, err := invalid.StatusReports("agent", now); err == nil {
+		t.Error("StatusReports() with unknown alert status error = nil, want error")
+	}
+}
@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// GitHubWorkflowRunEvent is the part of a GitHub workflow_run webhook event mapped to a status report
+type GitHubWorkflowRunEvent struct {
+	Action      string `json:"action"`
+	WorkflowRun *struct {
+		ID           int64     `json:"id"`
+		Name         string    `json:"name"`
+		DisplayTitle string    `json:"display_title"`
+		Path         string    `json:"path"`
+		RunNumber    int       `json:"run_number"`
+		RunAttempt   int       `json:"run_attempt"`
+		Event        string    `json:"event"`
+		Status       string    `json:"status"`
+		Conclusion   string    `json:"conclusion"`
+		HeadBranch   string    `json:"head_branch"`
+		HeadSHA      string    `json:"head_sha"`
+		HTMLURL      string    `json:"html_url"`
+		UpdatedAt    time.Time `json:"updated_at"`
+		Actor        struct {
+			Login string `json:"login"`
+		} `json:"actor"`
+	} `json:"workflow_run"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// StatusReport maps the workflow run to a report of its workflow's agent
+// Each repository workflow is an agent and each run number a session; re-run attempts start a new revision.
+func (e *GitHubWorkflowRunEvent) StatusReport(now time.Time) (*StatusReport, error) {
+	run := e.WorkflowRun
+	if run == nil {
+		return nil, errors.New("workflow_run is required")
+	}
+	if e.Repository.FullName == "" {
+		return nil, errors.New("repository.full_name is required")
+	}
+
+	workflow := strings.TrimSuffix(strings.TrimSuffix(path.Base(run.Path), ".yml"), ".yaml")
+	if run.Path == "" {
+		workflow = run.Name
+	}
+
+	report := &StatusReport{
+		// Agent IDs appear in URL paths, so the repository's slash is replaced
+		AgentID:      truncate("github-"+strings.ReplaceAll(e.Repository.FullName, "/", "-")+"-"+workflow, 100),
+		AgentName:    truncate(e.Repository.FullName+" "+run.Name, 200),
+		AgentSource:  "github-actions",
+		AgentKind:    models.AgentKindCI,
+		SessionTopic: fmt.Sprintf("%s #%d", run.Name, run.RunNumber),
+		Content:      run.DisplayTitle,
+		Timestamp:    run.UpdatedAt,
+	}
+	if report.Timestamp.IsZero() {
+		report.Timestamp = now
+	}
+
+	switch run.Status {
+	case "queued", "requested", "waiting", "pending":
+		report.Status = "pending"
+	case "in_progress":
+		report.Status = "running"
+	case "completed":
+		switch run.Conclusion {
+		case "success", "neutral", "skipped":
+			report.Status = "success"
+		default:
+			report.Status = "failed"
+		}
+	default:
+		return nil, errors.New("unknown workflow run status: " + run.Status)
+	}
+
+	state := run.Status
+	if run.Conclusion != "" {
+		state = run.Conclusion
+	}
+	report.Message = state
+	if run.HeadBranch != "" {
+		report.Message += " on " + run.HeadBranch
+	}
+	if len(run.HeadSHA) >= 7 {
+		report.Message += " (" + run.HeadSHA[:7] + ")"
+	}
+
+	metadata, err := json.Marshal(map[string]interface{}{
+		"repository":  e.Repository.FullName,
+		"run_id":      run.ID,
+		"run_attempt": run.RunAttempt,
+		"event":       run.Event,
+		"head_branch": run.HeadBranch,
+		"head_sha":    run.HeadSHA,
+		"html_url":    run.HTMLURL,
+		"actor":       run.Actor.Login,
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Metadata = metadata
+	return report, nil
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGitHubWorkflowRunEvent_StatusReport(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		status      string
+		conclusion  string
+		wantStatus  string
+		wantMessage string
+		wantErr     bool
+	}{
+		{"queued", "queued", "", "pending", "queued on main (0123456)", false},
+		{"in progress", "in_progress", "", "running", "in_progress on main (0123456)", false},
+		{"succeeded", "completed", "success", "success", "success on main (0123456)", false},
+		{"skipped", "completed", "skipped", "success", "skipped on main (0123456)", false},
+		{"failed", "completed", "failure", "failed", "failure on main (0123456)", false},
+		{"cancelled", "completed", "cancelled", "failed", "cancelled on main (0123456)", false},
+		{"unknown status", "paused", "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"action":"completed","workflow_run":{"id":42,"name":"CI","display_title":"Fix flaky test","path":".github/workflows/ci.yml",
+				"run_number":17,"run_attempt":1,"event":"push","status":"` + tt.status + `","conclusion":"` + tt.conclusion + `",
+				"head_branch":"main","head_sha":"0123456789abcdef","html_url":"https://github.com/acme/app/actions/runs/42",
+				"updated_at":"2024-01-15T11:59:00Z","actor":{"login":"octocat"}},"repository":{"full_name":"acme/app"}}`
+			var event GitHubWorkflowRunEvent
+			if err := json.Unmarshal([]byte(body), &event); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			report, err := event.StatusReport(now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StatusReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if report.AgentID != "github-acme-app-ci" || report.AgentName != "acme/app CI" || report.AgentSource != "github-actions" ||
+				report.SessionTopic != "CI #17" || report.Content != "Fix flaky test" ||
+				!report.Timestamp.Equal(time.Date(2024, 1, 15, 11, 59, 0, 0, time.UTC)) {
+				t.Errorf("StatusReport() = %+v", report)
+			}
+			if report.Status != tt.wantStatus || report.Message != tt.wantMessage {
+				t.Errorf("StatusReport() status = %q, message = %q, want %q, %q", report.Status, report.Message, tt.wantStatus, tt.wantMessage)
+			}
+			if err := report.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+
+	var missing GitHubWorkflowRunEvent
+	json.Unmarshal([]byte(`{"action":"created","repository":{"full_name":"acme/app"}}`), &missing)
+	if _, err := missing.StatusReport(now); err == nil {
+		t.Error("StatusReport() without workflow_run error = nil, want error")
+	}
+}
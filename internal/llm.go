@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// Kinds of LLM agent events, normalized across frameworks
+const (
+	llmStepStart  = "start"
+	llmStepUpdate = "step"
+	llmStepEnd    = "end" // Finishes the session when it ends the root run
+	llmStepFinish = "finish"
+	llmStepError  = "error"
+)
+
+// llmEventKinds maps each framework's callback or message names to step kinds
+var llmEventKinds = map[string]map[string]string{
+	"langchain": {
+		"on_chain_start":      llmStepStart,
+		"on_llm_start":        llmStepStart,
+		"on_chat_model_start": llmStepStart,
+		"on_tool_start":       llmStepStart,
+		"on_retriever_start":  llmStepStart,
+		"on_chain_end":        llmStepEnd,
+		"on_llm_end":          llmStepEnd,
+		"on_tool_end":         llmStepEnd,
+		"on_retriever_end":    llmStepEnd,
+		"on_agent_action":     llmStepUpdate,
+		"on_agent_finish":     llmStepUpdate, // Followed by the end of the root chain
+		"on_chain_error":      llmStepError,
+		"on_llm_error":        llmStepError,
+		"on_tool_error":       llmStepError,
+		"on_retriever_error":  llmStepError,
+	},
+	"autogen": {
+		"TextMessage":            llmStepUpdate,
+		"ThoughtEvent":           llmStepUpdate,
+		"ToolCallRequestEvent":   llmStepUpdate,
+		"ToolCallExecutionEvent": llmStepUpdate,
+		"ToolCallSummaryMessage": llmStepUpdate,
+		"HandoffMessage":         llmStepUpdate,
+		"TaskResult":             llmStepFinish,
+		"Error":                  llmStepError,
+	},
+	"generic": {
+		llmStepStart:  llmStepStart,
+		llmStepUpdate: llmStepUpdate,
+		llmStepEnd:    llmStepEnd,
+		llmStepFinish: llmStepFinish,
+		llmStepError:  llmStepError,
+	},
+}
+
+// TokenUsage is the token count reported for an LLM call
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// LLMEvent is one callback or message from an LLM agent framework
+// Every event of a run is reported under the same session_topic, so steps are aggregated in one session.
+type LLMEvent struct {
+	AgentID      string          `json:"agent_id"`
+	AgentName    string          `json:"agent_name,omitempty"`
+	SessionTopic string          `json:"session_topic"`
+	Framework    string          `json:"framework"` // langchain, autogen or generic
+	Event        string          `json:"event"`
+	RunID        string          `json:"run_id,omitempty"`
+	ParentRunID  string          `json:"parent_run_id,omitempty"` // Empty for the root run of the session
+	Name         string          `json:"name,omitempty"`          // Chain, model or tool name
+	Input        json.RawMessage `json:"input,omitempty"`
+	Output       json.RawMessage `json:"output,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	TokenUsage   *TokenUsage     `json:"token_usage,omitempty"`
+	Timestamp    time.Time       `json:"timestamp"`
+	TTLMinutes   int             `json:"ttl_minutes,omitempty"`
+}
+
+// ParseLLMEvents decodes a single event or an array of events
+func ParseLLMEvents(data []byte) ([]*LLMEvent, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		var events []*LLMEvent
+		if err := json.Unmarshal(data, &events); err != nil {
+			return nil, err
+		}
+		return events, nil
+	}
+
+	var event LLMEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	return []*LLMEvent{&event}, nil
+}
+
+// StatusReport maps the event to a step of its session
+// The session succeeds when its root run ends and fails when the root run errors; every other step keeps it running.
+// Message and content are cut to the payload limits, since prompts and tool outputs are often large.
+func (e *LLMEvent) StatusReport(limits PayloadLimits, now time.Time) (*StatusReport, error) {
+	framework := e.Framework
+	if framework == "" {
+		framework = "generic"
+	}
+	kinds, ok := llmEventKinds[framework]
+	if !ok {
+		return nil, errors.New("framework must be one of: langchain, autogen, generic")
+	}
+	kind, ok := kinds[e.Event]
+	if !ok {
+		return nil, errors.New("unknown " + framework + " event: " + e.Event)
+	}
+
+	report := &StatusReport{
+		AgentID:      e.AgentID,
+		AgentName:    e.AgentName,
+		AgentSource:  framework,
+		AgentKind:    models.AgentKindLLMAgent,
+		SessionTopic: e.SessionTopic,
+		Status:       "running",
+		Timestamp:    e.Timestamp,
+		TTLMinutes:   e.TTLMinutes,
+	}
+	if report.Timestamp.IsZero() {
+		report.Timestamp = now
+	}
+
+	switch {
+	case kind == llmStepFinish, kind == llmStepEnd && e.ParentRunID == "":
+		report.Status = "success"
+	case kind == llmStepError && e.ParentRunID == "":
+		report.Status = "failed"
+	}
+
+	message := strings.TrimPrefix(e.Event, "on_")
+	if e.Name != "" {
+		message += ": " + e.Name
+	}
+	if e.Error != "" {
+		message += ": " + e.Error
+	}
+	report.Message = truncateUTF8(message, limits.MaxMessageLength)
+	report.Content = truncateUTF8(e.trace(), limits.MaxContentLength)
+
+	fields := map[string]interface{}{
+		"framework":     framework,
+		"event":         e.Event,
+		"step":          kind,
+		"run_id":        e.RunID,
+		"parent_run_id": e.ParentRunID,
+		"name":          e.Name,
+	}
+	if e.TokenUsage != nil {
+		fields["token_usage"] = e.TokenUsage
+	}
+	metadata, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	report.Metadata = metadata
+	return report, nil
+}
+
+// trace renders the event's input, output and error, e.g. a tool call and its result
+func (e *LLMEvent) trace() string {
+	var lines []string
+	if len(e.Input) > 0 {
+		lines = append(lines, "input: "+rawText(e.Input))
+	}
+	if len(e.Output) > 0 {
+		lines = append(lines, "output: "+rawText(e.Output))
+	}
+	if e.Error != "" {
+		lines = append(lines, "error: "+e.Error)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rawText returns a JSON string's value, or compact JSON for other values
+func rawText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
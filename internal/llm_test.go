@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLLMEvent_StatusReport(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	limits := DefaultPayloadLimits()
+
+	tests := []struct {
+		name        string
+		event       LLMEvent
+		wantStatus  string
+		wantMessage string
+		wantErr     bool
+	}{
+		{"root chain start", LLMEvent{Framework: "langchain", Event: "on_chain_start", Name: "AgentExecutor", RunID: "r1"}, "running", "chain_start: AgentExecutor", false},
+		{"tool end", LLMEvent{Framework: "langchain", Event: "on_tool_end", Name: "search", RunID: "r2", ParentRunID: "r1"}, "running", "tool_end: search", false},
+		{"nested tool error", LLMEvent{Framework: "langchain", Event: "on_tool_error", Name: "search", Error: "timeout", ParentRunID: "r1"}, "running", "tool_error: search: timeout", false},
+		{"agent finish", LLMEvent{Framework: "langchain", Event: "on_agent_finish", ParentRunID: "r1"}, "running", "agent_finish", false},
+		{"root chain end", LLMEvent{Framework: "langchain", Event: "on_chain_end", Name: "AgentExecutor", RunID: "r1"}, "success", "chain_end: AgentExecutor", false},
+		{"root chain error", LLMEvent{Framework: "langchain", Event: "on_chain_error", Error: "rate limited", RunID: "r1"}, "failed", "chain_error: rate limited", false},
+		{"autogen tool call", LLMEvent{Framework: "autogen", Event: "ToolCallRequestEvent", Name: "assistant"}, "running", "ToolCallRequestEvent: assistant", false},
+		{"autogen task result", LLMEvent{Framework: "autogen", Event: "TaskResult"}, "success", "TaskResult", false},
+		{"generic error", LLMEvent{Event: "error", Error: "crashed"}, "failed", "error: crashed", false},
+		{"unknown framework", LLMEvent{Framework: "crewai", Event: "start"}, "", "", true},
+		{"unknown event", LLMEvent{Framework: "langchain", Event: "on_text"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.event.AgentID = "assistant"
+			tt.event.SessionTopic = "conversation-1"
+
+			report, err := tt.event.StatusReport(limits, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StatusReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if report.Status != tt.wantStatus || report.Message != tt.wantMessage || !report.Timestamp.Equal(now) {
+				t.Errorf("StatusReport() = %+v, want status %q and message %q", report, tt.wantStatus, tt.wantMessage)
+			}
+			if err := report.ValidateWithLimits(limits); err != nil {
+				t.Errorf("ValidateWithLimits() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestLLMEvent_StatusReportTraceAndTokens(t *testing.T) {
+	events, err := ParseLLMEvents([]byte(`[{"agent_id":"assistant","session_topic":"conversation-1","framework":"langchain",
+		"event":"on_tool_end","name":"search","parent_run_id":"r1","input":"weather in Paris","output":{"temp_c": 21},
+		"token_usage":{"prompt_tokens":12,"completion_tokens":30,"total_tokens":42}}]`))
+	if err != nil || len(events) != 1 {
+		t.Fatalf("ParseLLMEvents() = %v, %v, want one event", events, err)
+	}
+
+	report, err := events[0].StatusReport(DefaultPayloadLimits(), time.Now())
+	if err != nil {
+		t.Fatalf("StatusReport() error = %v", err)
+	}
+	if report.Content != "input: weather in Paris\noutput: {\"temp_c\":21}" {
+		t.Errorf("StatusReport() content = %q", report.Content)
+	}
+	var metadata struct {
+		Step       string     `json:"step"`
+		TokenUsage TokenUsage `json:"token_usage"`
+	}
+	if err := json.Unmarshal(report.Metadata, &metadata); err != nil || metadata.Step != "end" || metadata.TokenUsage.TotalTokens != 42 {
+		t.Errorf("StatusReport() metadata = %s, %v", report.Metadata, err)
+	}
+
+	// Large outputs are cut to the payload limits instead of rejecting the step
+	long := &LLMEvent{AgentID: "assistant", SessionTopic: "conversation-1", Event: "step", Output: json.RawMessage(`"` + strings.Repeat("é", 10000) + `"`)}
+	report, err = long.StatusReport(DefaultPayloadLimits(), time.Now())
+	if err != nil {
+		t.Fatalf("StatusReport() error = %v", err)
+	}
+	if len(report.Content) > DefaultPayloadLimits().MaxContentLength || !strings.HasSuffix(report.Content, "é") {
+		t.Errorf("StatusReport() content is %d bytes, want at most %d ending in a whole character", len(report.Content), DefaultPayloadLimits().MaxContentLength)
+	}
+
+	if single, err := ParseLLMEvents([]byte(`{"event":"start"}`)); err != nil || len(single) != 1 {
+		t.Errorf("ParseLLMEvents() single event = %v, %v", single, err)
+	}
+}
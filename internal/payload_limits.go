@@ -0,0 +1,99 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// PayloadLimits bounds the free-form fields of a status report
+type PayloadLimits struct {
+	MaxMessageLength int `json:"message,omitempty"`
+	MaxContentLength int `json:"content,omitempty"`
+	MaxMetadataBytes int `json:"metadata,omitempty"`
+}
+
+// DefaultPayloadLimits returns the limits used when a deployment configures none
+func DefaultPayloadLimits() PayloadLimits {
+	return PayloadLimits{
+		MaxMessageLength: 1000,
+		MaxContentLength: 10000,
+		MaxMetadataBytes: 4096,
+	}
+}
+
+// validate checks the limits are positive and within what storage accepts
+func (l PayloadLimits) validate() error {
+	if l.MaxMessageLength < 1 || l.MaxMessageLength > models.MaxStatusMessageLength {
+		return fmt.Errorf("message limit must be 1-%d", models.MaxStatusMessageLength)
+	}
+	if l.MaxContentLength < 1 || l.MaxContentLength > models.MaxStatusContentLength {
+		return fmt.Errorf("content limit must be 1-%d", models.MaxStatusContentLength)
+	}
+	if l.MaxMetadataBytes < 1 || l.MaxMetadataBytes > models.MaxStatusMetadataBytes {
+		return fmt.Errorf("metadata limit must be 1-%d", models.MaxStatusMetadataBytes)
+	}
+	return nil
+}
+
+// withDefaults fills unset fields from defaults
+func (l PayloadLimits) withDefaults(defaults PayloadLimits) PayloadLimits {
+	if l.MaxMessageLength == 0 {
+		l.MaxMessageLength = defaults.MaxMessageLength
+	}
+	if l.MaxContentLength == 0 {
+		l.MaxContentLength = defaults.MaxContentLength
+	}
+	if l.MaxMetadataBytes == 0 {
+		l.MaxMetadataBytes = defaults.MaxMetadataBytes
+	}
+	return l
+}
+
+// PayloadLimitPolicy resolves payload limits for a user's plan
+type PayloadLimitPolicy struct {
+	defaults PayloadLimits
+	tiers    map[string]PayloadLimits
+}
+
+// NewPayloadLimitPolicy builds a policy from deployment defaults and per-plan tiers
+// Tiers are a JSON object keyed by plan, e.g. {"pro":{"content":100000}}; unset fields inherit the defaults.
+func NewPayloadLimitPolicy(defaults PayloadLimits, rawTiers string) (*PayloadLimitPolicy, error) {
+	if err := defaults.validate(); err != nil {
+		return nil, fmt.Errorf("invalid default payload limits: %w", err)
+	}
+
+	tiers := make(map[string]PayloadLimits)
+	if rawTiers != "" {
+		var parsed map[string]PayloadLimits
+		if err := json.Unmarshal([]byte(rawTiers), &parsed); err != nil {
+			return nil, fmt.Errorf("invalid payload limit tiers: %w", err)
+		}
+		for plan, limits := range parsed {
+			limits = limits.withDefaults(defaults)
+			if err := limits.validate(); err != nil {
+				return nil, fmt.Errorf("invalid payload limits for plan %q: %w", plan, err)
+			}
+			tiers[plan] = limits
+		}
+	}
+
+	return &PayloadLimitPolicy{defaults: defaults, tiers: tiers}, nil
+}
+
+// HasTiers reports whether any plan overrides the defaults
+func (p *PayloadLimitPolicy) HasTiers() bool {
+	return p != nil && len(p.tiers) > 0
+}
+
+// For returns the limits for a plan, falling back to the deployment defaults
+func (p *PayloadLimitPolicy) For(plan string) PayloadLimits {
+	if p == nil {
+		return DefaultPayloadLimits()
+	}
+	if limits, ok := p.tiers[plan]; ok {
+		return limits
+	}
+	return p.defaults
+}
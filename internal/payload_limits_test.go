@@ -0,0 +1,61 @@
+package internal
+
+import "testing"
+
+func TestPayloadLimitPolicy_For(t *testing.T) {
+	defaults := DefaultPayloadLimits()
+	policy, err := NewPayloadLimitPolicy(defaults, `{"pro":{"content":100000},"free":{"message":200,"content":2000}}`)
+	if err != nil {
+		t.Fatalf("NewPayloadLimitPolicy() error = %v", err)
+	}
+
+	if got := policy.For(""); got != defaults {
+		t.Errorf("For(\"\") = %+v, want defaults %+v", got, defaults)
+	}
+	if got := policy.For("unknown"); got != defaults {
+		t.Errorf("For(unknown) = %+v, want defaults %+v", got, defaults)
+	}
+
+	pro := policy.For("pro")
+	if pro.MaxContentLength != 100000 {
+		t.Errorf("For(pro) content = %d, want 100000", pro.MaxContentLength)
+	}
+	if pro.MaxMessageLength != defaults.MaxMessageLength {
+		t.Errorf("For(pro) message = %d, want inherited %d", pro.MaxMessageLength, defaults.MaxMessageLength)
+	}
+
+	if free := policy.For("free"); free.MaxMessageLength != 200 || free.MaxContentLength != 2000 {
+		t.Errorf("For(free) = %+v, want message 200, content 2000", free)
+	}
+}
+
+func TestNewPayloadLimitPolicy_Invalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults PayloadLimits
+		tiers    string
+	}{
+		{"zero defaults", PayloadLimits{}, ""},
+		{"invalid json", DefaultPayloadLimits(), `not json`},
+		{"tier above storage ceiling", DefaultPayloadLimits(), `{"pro":{"content":999999999}}`},
+		{"negative tier limit", DefaultPayloadLimits(), `{"pro":{"message":-1}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPayloadLimitPolicy(tt.defaults, tt.tiers); err == nil {
+				t.Error("NewPayloadLimitPolicy() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestPayloadLimitPolicy_NilUsesDefaults(t *testing.T) {
+	var policy *PayloadLimitPolicy
+	if policy.HasTiers() {
+		t.Error("nil HasTiers() = true, want false")
+	}
+	if got := policy.For("pro"); got != DefaultPayloadLimits() {
+		t.Errorf("nil For() = %+v, want defaults", got)
+	}
+}
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// Labels identifying the template or pipeline a Kubernetes run was created from
+const (
+	argoWorkflowTemplateLabel = "workflows.argoproj.io/workflow-template"
+	argoCronWorkflowLabel     = "workflows.argoproj.io/cron-workflow"
+	tektonPipelineLabel       = "tekton.dev/pipeline"
+)
+
+// objectMeta holds the Kubernetes object metadata the bridges use
+type objectMeta struct {
+	Name         string            `json:"name"`
+	GenerateName string            `json:"generateName"`
+	Namespace    string            `json:"namespace"`
+	UID          string            `json:"uid"`
+	Labels       map[string]string `json:"labels"`
+}
+
+// ArgoWorkflow is the part of an Argo Workflow object mapped to a status report
+type ArgoWorkflow struct {
+	Kind     string     `json:"kind"`
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		WorkflowTemplateRef struct {
+			Name string `json:"name"`
+		} `json:"workflowTemplateRef"`
+	} `json:"spec"`
+	Status struct {
+		Phase      string    `json:"phase"`
+		Message    string    `json:"message"`
+		StartedAt  time.Time `json:"startedAt"`
+		FinishedAt time.Time `json:"finishedAt"`
+	} `json:"status"`
+}
+
+// StatusReport maps the workflow to a report of its template's agent
+func (wf *ArgoWorkflow) StatusReport(now time.Time) (*StatusReport, error) {
+	if wf.Kind != "" && wf.Kind != "Workflow" {
+		return nil, errors.New("kind must be Workflow")
+	}
+	if wf.Metadata.Name == "" {
+		return nil, errors.New("metadata.name is required")
+	}
+
+	template := firstNonEmpty(
+		wf.Metadata.Labels[argoWorkflowTemplateLabel],
+		wf.Metadata.Labels[argoCronWorkflowLabel],
+		wf.Spec.WorkflowTemplateRef.Name,
+		strings.TrimSuffix(wf.Metadata.GenerateName, "-"),
+		wf.Metadata.Name,
+	)
+
+	report := &StatusReport{
+		AgentID:      pipelineAgentID("argo", wf.Metadata.Namespace, template),
+		AgentName:    template,
+		AgentSource:  "argo-workflows",
+		AgentKind:    models.AgentKindCI,
+		SessionTopic: wf.Metadata.Name,
+		Message:      wf.Status.Message,
+		Timestamp:    firstNonZero(wf.Status.FinishedAt, wf.Status.StartedAt, now),
+	}
+
+	switch wf.Status.Phase {
+	case "", "Pending":
+		report.Status = "pending"
+	case "Running":
+		report.Status = "running"
+	case "Succeeded":
+		report.Status = "success"
+	case "Failed", "Error":
+		report.Status = "failed"
+	default:
+		return nil, errors.New("unknown workflow phase: " + wf.Status.Phase)
+	}
+
+	metadata, err := pipelineMetadata(wf.Metadata, template)
+	if err != nil {
+		return nil, err
+	}
+	report.Metadata = metadata
+	return report, nil
+}
+
+// TektonPipelineRun is the part of a Tekton PipelineRun object mapped to a status report
+type TektonPipelineRun struct {
+	Kind     string     `json:"kind"`
+	Metadata objectMeta `json:"metadata"`
+	Spec     struct {
+		PipelineRef struct {
+			Name string `json:"name"`
+		} `json:"pipelineRef"`
+	} `json:"spec"`
+	Status struct {
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+		StartTime      time.Time `json:"startTime"`
+		CompletionTime time.Time `json:"completionTime"`
+	} `json:"status"`
+}
+
+// ParseTektonPipelineRun decodes a PipelineRun, either bare or wrapped in a Tekton CloudEvent body
+func ParseTektonPipelineRun(data []byte) (*TektonPipelineRun, error) {
+	var event struct {
+		PipelineRun *TektonPipelineRun `json:"pipelineRun"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil, err
+	}
+	if event.PipelineRun != nil {
+		return event.PipelineRun, nil
+	}
+
+	var run TektonPipelineRun
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// StatusReport maps the PipelineRun to a report of its pipeline's agent
+func (pr *TektonPipelineRun) StatusReport(now time.Time) (*StatusReport, error) {
+	if pr.Kind != "" && pr.Kind != "PipelineRun" {
+		return nil, errors.New("kind must be PipelineRun")
+	}
+	if pr.Metadata.Name == "" {
+		return nil, errors.New("metadata.name is required")
+	}
+
+	pipeline := firstNonEmpty(
+		pr.Metadata.Labels[tektonPipelineLabel],
+		pr.Spec.PipelineRef.Name,
+		strings.TrimSuffix(pr.Metadata.GenerateName, "-"),
+		pr.Metadata.Name,
+	)
+
+	report := &StatusReport{
+		AgentID:      pipelineAgentID("tekton", pr.Metadata.Namespace, pipeline),
+		AgentName:    pipeline,
+		AgentSource:  "tekton",
+		AgentKind:    models.AgentKindCI,
+		SessionTopic: pr.Metadata.Name,
+		Timestamp:    firstNonZero(pr.Status.CompletionTime, pr.Status.StartTime, now),
+		Status:       "pending",
+	}
+
+	// The Succeeded condition is Unknown while the run is in progress
+	for _, condition := range pr.Status.Conditions {
+		if condition.Type != "Succeeded" {
+			continue
+		}
+		report.Message = condition.Message
+		switch {
+		case condition.Status == "True":
+			report.Status = "success"
+		case condition.Status == "False":
+			report.Status = "failed"
+		case condition.Reason != "PipelineRunPending" && condition.Reason != "Pending":
+			report.Status = "running"
+		}
+	}
+
+	metadata, err := pipelineMetadata(pr.Metadata, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	report.Metadata = metadata
+	return report, nil
+}
+
+// pipelineAgentID names the agent of a template, trimmed to the agent ID length limit
+func pipelineAgentID(system, namespace, template string) string {
+	id := system + "-" + template
+	if namespace != "" {
+		id = system + "-" + namespace + "-" + template
+	}
+	return truncate(id, 100)
+}
+
+// pipelineMetadata records where a run came from in the status metadata
+func pipelineMetadata(meta objectMeta, template string) (json.RawMessage, error) {
+	return json.Marshal(map[string]string{
+		"namespace": meta.Namespace,
+		"name":      meta.Name,
+		"uid":       meta.UID,
+		"template":  template,
+	})
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// firstNonZero returns the first non-zero time
+func firstNonZero(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
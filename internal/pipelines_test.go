@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var pipelinesNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func TestArgoWorkflow_StatusReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantAgentID string
+		wantStatus  string
+		wantErr     bool
+	}{
+		{
+			name:        "from template",
+			body:        `{"kind":"Workflow","metadata":{"name":"build-x7k2p","namespace":"ci","labels":{"workflows.argoproj.io/workflow-template":"build"}},"status":{"phase":"Running","startedAt":"2024-01-15T11:00:00Z"}}`,
+			wantAgentID: "argo-ci-build",
+			wantStatus:  "running",
+		},
+		{
+			name:        "from cron workflow",
+			body:        `{"metadata":{"name":"nightly-1705300000","namespace":"ci","labels":{"workflows.argoproj.io/cron-workflow":"nightly"}},"status":{"phase":"Error"}}`,
+			wantAgentID: "argo-ci-nightly",
+			wantStatus:  "failed",
+		},
+		{
+			name:        "from generate name",
+			body:        `{"metadata":{"name":"hello-abcde","generateName":"hello-"},"status":{"phase":"Succeeded"}}`,
+			wantAgentID: "argo-hello",
+			wantStatus:  "success",
+		},
+		{
+			name:        "not yet scheduled",
+			body:        `{"metadata":{"name":"once"}}`,
+			wantAgentID: "argo-once",
+			wantStatus:  "pending",
+		},
+		{"other kind", `{"kind":"CronWorkflow","metadata":{"name":"nightly"}}`, "", "", true},
+		{"missing name", `{"status":{"phase":"Running"}}`, "", "", true},
+		{"unknown phase", `{"metadata":{"name":"x"},"status":{"phase":"Paused"}}`, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var workflow ArgoWorkflow
+			if err := json.Unmarshal([]byte(tt.body), &workflow); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			report, err := workflow.StatusReport(pipelinesNow)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StatusReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if report.AgentID != tt.wantAgentID || report.Status != tt.wantStatus || report.AgentSource != "argo-workflows" {
+				t.Errorf("StatusReport() = %+v, want agent %s with status %s", report, tt.wantAgentID, tt.wantStatus)
+			}
+			if err := report.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestTektonPipelineRun_StatusReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantAgentID string
+		wantStatus  string
+		wantMessage string
+	}{
+		{
+			name:        "cloud event in progress",
+			body:        `{"pipelineRun":{"metadata":{"name":"deploy-run-1","namespace":"ci","labels":{"tekton.dev/pipeline":"deploy"}},"status":{"conditions":[{"type":"Succeeded","status":"Unknown","reason":"Running","message":"Tasks Completed: 1"}]}}}`,
+			wantAgentID: "tekton-ci-deploy",
+			wantStatus:  "running",
+			wantMessage: "Tasks Completed: 1",
+		},
+		{
+			name:        "bare object succeeded",
+			body:        `{"kind":"PipelineRun","metadata":{"name":"deploy-run-2","namespace":"ci"},"spec":{"pipelineRef":{"name":"deploy"}},"status":{"conditions":[{"type":"Succeeded","status":"True"}],"completionTime":"2024-01-15T11:30:00Z"}}`,
+			wantAgentID: "tekton-ci-deploy",
+			wantStatus:  "success",
+		},
+		{
+			name:        "failed",
+			body:        `{"pipelineRun":{"metadata":{"name":"test-run"},"status":{"conditions":[{"type":"Succeeded","status":"False","message":"task lint failed"}]}}}`,
+			wantAgentID: "tekton-test-run",
+			wantStatus:  "failed",
+			wantMessage: "task lint failed",
+		},
+		{
+			name:        "pending",
+			body:        `{"pipelineRun":{"metadata":{"name":"queued"},"status":{"conditions":[{"type":"Succeeded","status":"Unknown","reason":"PipelineRunPending"}]}}}`,
+			wantAgentID: "tekton-queued",
+			wantStatus:  "pending",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, err := ParseTektonPipelineRun([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("ParseTektonPipelineRun() error = %v", err)
+			}
+
+			report, err := run.StatusReport(pipelinesNow)
+			if err != nil {
+				t.Fatalf("StatusReport() error = %v", err)
+			}
+			if report.AgentID != tt.wantAgentID || report.Status != tt.wantStatus || report.Message != tt.wantMessage {
+				t.Errorf("StatusReport() = %+v, want agent %s with status %s and message %q", report, tt.wantAgentID, tt.wantStatus, tt.wantMessage)
+			}
+			if err := report.Validate(); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+
+	if _, err := (&TektonPipelineRun{Kind: "TaskRun"}).StatusReport(pipelinesNow); err == nil {
+		t.Error("StatusReport() for a TaskRun error = nil, want error")
+	}
+}
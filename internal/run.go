@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// IsFinalStatus reports whether a status ends a run of a session
+func IsFinalStatus(status string) bool {
+	return status == "success" || status == "failed"
+}
+
+// endsRun reports whether a status leaves a run over: a final status, or a server status recorded as it stopped
+func endsRun(status string) bool {
+	return IsFinalStatus(status) || models.IsServerStatus(status)
+}
+
+// SessionRun is one execution of a session, made of the statuses reported for one revision
+type SessionRun struct {
+	Revision        int        `json:"revision"`
+	Started         time.Time  `json:"started"`
+	LastUpdated     time.Time  `json:"last_updated"`
+	Finished        *time.Time `json:"finished,omitempty"` // Time of the final or server status; nil while the run is in progress
+	Result          string     `json:"result,omitempty"`   // Latest status of the run
+	StatusCount     int        `json:"status_count"`
+	DurationSeconds float64    `json:"duration_seconds"` // Start to finish, or to the latest status while in progress
+}
+
+// RunComparison summarizes the durations of a session's finished runs
+type RunComparison struct {
+	FinishedRuns           int      `json:"finished_runs"`
+	AverageDurationSeconds float64  `json:"average_duration_seconds"`
+	FastestRevision        int      `json:"fastest_revision"`
+	FastestDurationSeconds float64  `json:"fastest_duration_seconds"`
+	SlowestRevision        int      `json:"slowest_revision"`
+	SlowestDurationSeconds float64  `json:"slowest_duration_seconds"`
+	LatestVsAverage        *float64 `json:"latest_vs_average,omitempty"` // Latest finished run's duration divided by the average
+}
+
+// BuildRuns groups a session's status history into runs, newest first
+func BuildRuns(history []*models.AgentStatus) []*SessionRun {
+	byRevision := make(map[int][]*models.AgentStatus)
+	for _, status := range history {
+		byRevision[status.Revision] = append(byRevision[status.Revision], status)
+	}
+
+	runs := make([]*SessionRun, 0, len(byRevision))
+	for revision, statuses := range byRevision {
+		sort.Slice(statuses, func(i, j int) bool {
+			return statuses[i].Timestamp.Before(statuses[j].Timestamp)
+		})
+
+		first, last := statuses[0], statuses[len(statuses)-1]
+		run := &SessionRun{
+			Revision:    revision,
+			Started:     first.Timestamp,
+			LastUpdated: last.Timestamp,
+			Result:      last.Status,
+			StatusCount: len(statuses),
+		}
+		if endsRun(last.Status) {
+			finished := last.Timestamp
+			run.Finished = &finished
+		}
+		run.DurationSeconds = last.Timestamp.Sub(first.Timestamp).Seconds()
+
+		runs = append(runs, run)
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Revision > runs[j].Revision
+	})
+	return runs
+}
+
+// CompareRuns compares the durations of finished runs; runs must be newest first as returned by BuildRuns
+func CompareRuns(runs []*SessionRun) RunComparison {
+	var comparison RunComparison
+	var total float64
+	var latest *SessionRun
+
+	for _, run := range runs {
+		if run.Finished == nil {
+			continue
+		}
+		if latest == nil {
+			latest = run
+		}
+
+		comparison.FinishedRuns++
+		total += run.DurationSeconds
+		first := comparison.FinishedRuns == 1
+		if first || run.DurationSeconds < comparison.FastestDurationSeconds {
+			comparison.FastestRevision = run.Revision
+			comparison.FastestDurationSeconds = run.DurationSeconds
+		}
+		if first || run.DurationSeconds > comparison.SlowestDurationSeconds {
+			comparison.SlowestRevision = run.Revision
+			comparison.SlowestDurationSeconds = run.DurationSeconds
+		}
+	}
+
+	if comparison.FinishedRuns > 0 {
+		comparison.AverageDurationSeconds = total / float64(comparison.FinishedRuns)
+	}
+	if latest != nil && comparison.AverageDurationSeconds > 0 {
+		ratio := latest.DurationSeconds / comparison.AverageDurationSeconds
+		comparison.LatestVsAverage = &ratio
+	}
+	return comparison
+}
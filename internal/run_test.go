@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestBuildRunsAndCompareRuns(t *testing.T) {
+	base := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	status := func(revision int, status string, minutes int) *models.AgentStatus {
+		return &models.AgentStatus{
+			AgentID:      "agent-001",
+			SessionTopic: "nightly backup",
+			Status:       status,
+			Timestamp:    base.Add(time.Duration(minutes) * time.Minute),
+			Revision:     revision,
+		}
+	}
+
+	// Newest first, as returned by the store
+	history := []*models.AgentStatus{
+		status(3, "running", 200),
+		status(2, "failed", 130),
+		status(2, "running", 100),
+		status(1, "success", 10),
+		status(1, "running", 0),
+	}
+
+	runs := BuildRuns(history)
+	if len(runs) != 3 {
+		t.Fatalf("BuildRuns() returned %d runs, want 3", len(runs))
+	}
+	if runs[0].Revision != 3 || runs[0].Finished != nil || runs[0].DurationSeconds != 0 {
+		t.Errorf("BuildRuns()[0] = %+v, want revision 3 in progress", runs[0])
+	}
+	if runs[1].Revision != 2 || runs[1].Result != "failed" || runs[1].DurationSeconds != 30*60 {
+		t.Errorf("BuildRuns()[1] = %+v, want revision 2 failed after 30m", runs[1])
+	}
+	if runs[2].Revision != 1 || runs[2].Finished == nil || !runs[2].Started.Equal(base) || runs[2].StatusCount != 2 {
+		t.Errorf("BuildRuns()[2] = %+v, want revision 1 finished with 2 statuses", runs[2])
+	}
+
+	comparison := CompareRuns(runs)
+	if comparison.FinishedRuns != 2 || comparison.AverageDurationSeconds != 20*60 {
+		t.Errorf("CompareRuns() finished = %d, average = %v, want 2, 1200", comparison.FinishedRuns, comparison.AverageDurationSeconds)
+	}
+	if comparison.FastestRevision != 1 || comparison.SlowestRevision != 2 {
+		t.Errorf("CompareRuns() fastest = %d, slowest = %d, want 1, 2", comparison.FastestRevision, comparison.SlowestRevision)
+	}
+	if comparison.LatestVsAverage == nil || *comparison.LatestVsAverage != 1.5 {
+		t.Errorf("CompareRuns() latest_vs_average = %v, want 1.5", comparison.LatestVsAverage)
+	}
+
+	if empty := CompareRuns(nil); empty.FinishedRuns != 0 || empty.LatestVsAverage != nil {
+		t.Errorf("CompareRuns(nil) = %+v, want no finished runs", empty)
+	}
+
+	// A run the server recorded as expired is over, though its agent never reported a final status
+	expired := BuildRuns([]*models.AgentStatus{status(4, models.StatusExpired, 260), status(4, "running", 230)})
+	if expired[0].Finished == nil || expired[0].Result != models.StatusExpired {
+		t.Errorf("BuildRuns() of an expired run = %+v, want it finished as expired", expired[0])
+	}
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// Patterns for the variable parts of a session topic, applied in order
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	datePattern   = regexp.MustCompile(`\d{4}-\d{2}-\d{2}(?:[t ]\d{2}:\d{2}(?::\d{2})?(?:\.\d+)?(?:z|[+-]\d{2}:?\d{2})?)?`)
+	hashPattern   = regexp.MustCompile(`\b[0-9a-f]{7,}\b`)
+	numberPattern = regexp.MustCompile(`\d+`)
+)
+
+// NormalizeTopic reduces a session topic to a stable task key
+// UUIDs, dates, hashes and numbers are replaced with placeholders so runs of the same job share a key.
+func NormalizeTopic(topic string) string {
+	key := strings.ToLower(strings.TrimSpace(topic))
+	key = uuidPattern.ReplaceAllString(key, "{id}")
+	key = datePattern.ReplaceAllString(key, "{date}")
+	key = hashPattern.ReplaceAllStringFunc(key, func(m string) string {
+		// Only hex runs mixing letters and digits are hashes; plain numbers are handled below
+		if strings.ContainsAny(m, "0123456789") && strings.ContainsAny(m, "abcdef") {
+			return "{hash}"
+		}
+		return m
+	})
+	key = numberPattern.ReplaceAllString(key, "{n}")
+	return strings.Join(strings.Fields(key), " ")
+}
+
+// TaskRun is a single session belonging to a recurring task
+type TaskRun struct {
+	SessionTopic string    `json:"session_topic"`
+	Started      time.Time `json:"started"`
+	LastUpdated  time.Time `json:"last_updated"`
+	Result       string    `json:"result,omitempty"`
+}
+
+// Task groups sessions whose topics normalize to the same key
+type Task struct {
+	TaskKey      string    `json:"task_key"`
+	RunCount     int       `json:"run_count"`
+	SuccessCount int       `json:"success_count"`
+	FailedCount  int       `json:"failed_count"`
+	SuccessRate  float64   `json:"success_rate"`
+	LastRun      time.Time `json:"last_run"`
+	LastResult   string    `json:"last_result,omitempty"`
+	Trend        []string  `json:"trend"` // Results of the most recent runs, oldest first
+	Runs         []TaskRun `json:"runs"`  // Most recent runs, newest first
+}
+
+// BuildTasks groups sessions into tasks with at least minRuns runs
+// results maps session topics to their latest status. historyLimit caps Trend and Runs.
+func BuildTasks(sessions []*models.Session, results map[string]string, minRuns, historyLimit int) []*Task {
+	byKey := make(map[string][]*models.Session)
+	for _, session := range sessions {
+		key := NormalizeTopic(session.SessionTopic)
+		byKey[key] = append(byKey[key], session)
+	}
+
+	tasks := make([]*Task, 0, len(byKey))
+	for key, runs := range byKey {
+		if len(runs) < minRuns {
+			continue
+		}
+
+		// Newest first
+		sort.Slice(runs, func(i, j int) bool {
+			return runs[i].Created.After(runs[j].Created)
+		})
+
+		task := &Task{
+			TaskKey:    key,
+			RunCount:   len(runs),
+			LastRun:    runs[0].Created,
+			LastResult: results[runs[0].SessionTopic],
+			Trend:      []string{},
+			Runs:       []TaskRun{},
+		}
+
+		completed := 0
+		for i, run := range runs {
+			result := results[run.SessionTopic]
+			switch result {
+			case "success":
+				task.SuccessCount++
+				completed++
+			case "failed":
+				task.FailedCount++
+				completed++
+			}
+			if i < historyLimit {
+				task.Runs = append(task.Runs, TaskRun{
+					SessionTopic: run.SessionTopic,
+					Started:      run.Created,
+					LastUpdated:  run.LastUpdated,
+					Result:       result,
+				})
+				task.Trend = append([]string{result}, task.Trend...)
+			}
+		}
+		if completed > 0 {
+			task.SuccessRate = float64(task.SuccessCount) / float64(completed)
+		}
+
+		tasks = append(tasks, task)
+	}
+
+	// Most recently run tasks first
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].LastRun.Equal(tasks[j].LastRun) {
+			return tasks[i].TaskKey < tasks[j].TaskKey
+		}
+		return tasks[i].LastRun.After(tasks[j].LastRun)
+	})
+
+	return tasks
+}
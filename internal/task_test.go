@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestNormalizeTopic(t *testing.T) {
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{"Nightly Backup 2024-05-01", "nightly backup {date}"},
+		{"nightly backup 2024-05-02T03:00:00Z", "nightly backup {date}"},
+		{"deploy build #1234", "deploy build #{n}"},
+		{"review commit 3f2a1b9c", "review commit {hash}"},
+		{"sync 550e8400-e29b-41d4-a716-446655440000", "sync {id}"},
+		{"  fix   login bug ", "fix login bug"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if got := NormalizeTopic(tt.topic); got != tt.want {
+				t.Errorf("NormalizeTopic(%q) = %q, want %q", tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildTasks(t *testing.T) {
+	base := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	session := func(topic string, day int) *models.Session {
+		created := base.AddDate(0, 0, day)
+		return &models.Session{AgentID: "agent-001", SessionTopic: topic, Created: created, LastUpdated: created}
+	}
+
+	sessions := []*models.Session{
+		session("nightly backup 2024-05-01", 0),
+		session("nightly backup 2024-05-02", 1),
+		session("nightly backup 2024-05-03", 2),
+		session("one-off migration", 1),
+	}
+	results := map[string]string{
+		"nightly backup 2024-05-01": "success",
+		"nightly backup 2024-05-02": "failed",
+		"nightly backup 2024-05-03": "success",
+		"one-off migration":         "success",
+	}
+
+	tasks := BuildTasks(sessions, results, 2, 2)
+	if len(tasks) != 1 {
+		t.Fatalf("BuildTasks() returned %d tasks, want 1", len(tasks))
+	}
+
+	task := tasks[0]
+	if task.TaskKey != "nightly backup {date}" {
+		t.Errorf("TaskKey = %q, want %q", task.TaskKey, "nightly backup {date}")
+	}
+	if task.RunCount != 3 || task.SuccessCount != 2 || task.FailedCount != 1 {
+		t.Errorf("counts = %d/%d/%d, want 3/2/1", task.RunCount, task.SuccessCount, task.FailedCount)
+	}
+	if task.LastResult != "success" {
+		t.Errorf("LastResult = %q, want success", task.LastResult)
+	}
+	if !task.LastRun.Equal(base.AddDate(0, 0, 2)) {
+		t.Errorf("LastRun = %v, want %v", task.LastRun, base.AddDate(0, 0, 2))
+	}
+	if len(task.Trend) != 2 || task.Trend[0] != "failed" || task.Trend[1] != "success" {
+		t.Errorf("Trend = %v, want [failed success]", task.Trend)
+	}
+	if len(task.Runs) != 2 || task.Runs[0].SessionTopic != "nightly backup 2024-05-03" {
+		t.Errorf("Runs = %+v, want newest two runs", task.Runs)
+	}
+
+	if tasks := BuildTasks(sessions, results, 1, 10); len(tasks) != 2 {
+		t.Errorf("BuildTasks(minRuns=1) returned %d tasks, want 2", len(tasks))
+	}
+}
@@ -0,0 +1,293 @@
+// Package testsupport builds users, agents, sessions and status histories in a store for tests,
+// and sends requests as a signed-in test user
+package testsupport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// The default test user, created by CreateUser and signed in by WithUser
+const (
+	UserID    = "test-user-123"
+	UserEmail = "test@example.com"
+)
+
+// WithUser signs r in as the default test user
+func WithUser(r *http.Request) *http.Request {
+	return WithCaller(r, UserID, UserEmail)
+}
+
+// WithCaller signs r in as the given user, the way the auth middleware does
+func WithCaller(r *http.Request, userID, email string) *http.Request {
+	caller := middleware.NewRequestContext(r, userID, email, nil)
+	return r.WithContext(middleware.WithRequestContext(r.Context(), caller))
+}
+
+// UserOption customizes a user created by CreateUser
+type UserOption func(*models.User)
+
+// UserWithID gives the user another ID and email than the default test user's
+func UserWithID(id, email string) UserOption {
+	return func(u *models.User) {
+		u.ID = id
+		u.Email = email
+	}
+}
+
+// UserWithWebhookURL sets the user's notification webhook URL
+func UserWithWebhookURL(url string) UserOption {
+	return func(u *models.User) {
+		u.NotificationWebhookURL = url
+	}
+}
+
+// CreateUser creates a verified user, the default test user unless options say otherwise
+func CreateUser(t testing.TB, st store.Store, opts ...UserOption) *models.User {
+	t.Helper()
+
+	now := time.Now()
+	user := &models.User{
+		ID:            UserID,
+		Email:         UserEmail,
+		PasswordHash:  "test-password-hash",
+		Name:          "Test User",
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	for _, opt := range opts {
+		opt(user)
+	}
+	if err := st.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser(%s) error = %v", user.ID, err)
+	}
+	return user
+}
+
+// Status is one status of a fixture history
+type Status struct {
+	Status  string
+	Message string
+	At      time.Time
+}
+
+// Statuses returns a history of the given statuses reported step apart from start
+func Statuses(start time.Time, step time.Duration, statuses ...string) []Status {
+	history := make([]Status, len(statuses))
+	for i, status := range statuses {
+		history[i] = Status{Status: status, At: start.Add(time.Duration(i) * step)}
+	}
+	return history
+}
+
+// AgentOption customizes an agent created by CreateAgent
+type AgentOption func(*agentFixture)
+
+type agentFixture struct {
+	agent    models.Agent
+	sessions int
+	statuses []string
+}
+
+// OwnedBy makes the agent belong to another user than the default test user
+func OwnedBy(userID string) AgentOption {
+	return func(f *agentFixture) {
+		f.agent.UserID = userID
+	}
+}
+
+// SharedWith shares the agent with an organization
+func SharedWith(orgID string) AgentOption {
+	return func(f *agentFixture) {
+		f.agent.OrgID = orgID
+	}
+}
+
+// AgentNamed sets the agent's display name
+func AgentNamed(name string) AgentOption {
+	return func(f *agentFixture) {
+		f.agent.Name = name
+	}
+}
+
+// AgentAt sets when the agent registered and was last seen, and when its sessions' statuses start
+func AgentAt(at time.Time) AgentOption {
+	return func(f *agentFixture) {
+		f.agent.Registered = at
+		f.agent.LastSeen = at
+	}
+}
+
+// WithSessions gives the agent n sessions, task-001 to task-n, each reporting the statuses a second apart
+func WithSessions(n int, statuses ...string) AgentOption {
+	return func(f *agentFixture) {
+		f.sessions = n
+		f.statuses = statuses
+	}
+}
+
+// CreateAgent creates an agent owned by the default test user, with the sessions options ask for
+func CreateAgent(t testing.TB, st store.Store, agentID string, opts ...AgentOption) *models.Agent {
+	t.Helper()
+
+	now := time.Now()
+	f := &agentFixture{agent: models.Agent{
+		AgentID:    agentID,
+		UserID:     UserID,
+		Name:       "Test Agent",
+		Source:     "test-software",
+		Registered: now,
+		LastSeen:   now,
+	}}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := st.CreateOrUpdateAgent(&f.agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent(%s) error = %v", agentID, err)
+	}
+	for i := 1; i <= f.sessions; i++ {
+		CreateSession(t, st, agentID, fmt.Sprintf("task-%03d", i), Statuses(f.agent.LastSeen, time.Second, f.statuses...)...)
+	}
+	return &f.agent
+}
+
+// CreateOrg creates an organization whose members hold the given roles, user ID to OrgRole
+func CreateOrg(t testing.TB, st store.Store, orgID string, roles map[string]string) {
+	t.Helper()
+
+	now := time.Now()
+	if err := st.CreateOrganization(&models.Organization{ID: orgID, Name: orgID, CreatedBy: UserID, CreatedAt: now}, nil); err != nil {
+		t.Fatalf("CreateOrganization(%s) error = %v", orgID, err)
+	}
+	for userID, role := range roles {
+		if err := st.SaveMembership(&models.Membership{OrgID: orgID, UserID: userID, Role: role, CreatedAt: now}); err != nil {
+			t.Fatalf("SaveMembership(%s, %s) error = %v", orgID, userID, err)
+		}
+	}
+}
+
+// CreateSession creates a session that started with its first status, or now without one, and records
+// the statuses in order
+func CreateSession(t testing.TB, st store.Store, agentID, sessionTopic string, history ...Status) *models.Session {
+	t.Helper()
+
+	started := time.Now()
+	if len(history) > 0 {
+		started = history[0].At
+	}
+	session := &models.Session{
+		AgentID:      agentID,
+		SessionTopic: sessionTopic,
+		Created:      started,
+		LastUpdated:  started,
+	}
+	if err := st.CreateOrUpdateSession(session); err != nil {
+		t.Fatalf("CreateOrUpdateSession(%s/%s) error = %v", agentID, sessionTopic, err)
+	}
+	for _, entry := range history {
+		err := st.AddStatus(&models.AgentStatus{
+			AgentID:      agentID,
+			SessionTopic: sessionTopic,
+			Status:       entry.Status,
+			Message:      entry.Message,
+			Timestamp:    entry.At,
+		})
+		if err != nil {
+			t.Fatalf("AddStatus(%s/%s, %s) error = %v", agentID, sessionTopic, entry.Status, err)
+		}
+	}
+	return session
+}
+
+// ExpireSession marks a session as expired
+func ExpireSession(t testing.TB, st store.Store, agentID, sessionTopic string) {
+	t.Helper()
+
+	session, err := st.GetSession(agentID, sessionTopic)
+	if err != nil {
+		t.Fatalf("GetSession(%s/%s) error = %v", agentID, sessionTopic, err)
+	}
+	session.Expired = true
+	if err := st.CreateOrUpdateSession(session); err != nil {
+		t.Fatalf("CreateOrUpdateSession(%s/%s) error = %v", agentID, sessionTopic, err)
+	}
+}
+
+// StoreWithAgents returns a memory store holding the default test user and agents agent-001 to agent-n,
+// each with sessions running sessions
+func StoreWithAgents(t testing.TB, agents, sessions int) *store.MemoryStore {
+	t.Helper()
+
+	st := store.NewMemoryStore()
+	CreateUser(t, st)
+	for i := 1; i <= agents; i++ {
+		CreateAgent(t, st, fmt.Sprintf("agent-%03d", i), AgentNamed(fmt.Sprintf("Agent %d", i)), WithSessions(sessions, "running"))
+	}
+	return st
+}
+
+// StoreWithSessionStates returns a memory store holding the default test user and agent-001 with
+// a running task-001, a successful task-002 and a failed, expired task-003, started an hour apart
+func StoreWithSessionStates(t testing.TB) *store.MemoryStore {
+	t.Helper()
+
+	st := store.NewMemoryStore()
+	CreateUser(t, st)
+	now := time.Now()
+	CreateAgent(t, st, "agent-001", AgentAt(now))
+	for i, outcome := range []string{"running", "success", "failed"} {
+		topic := fmt.Sprintf("task-%03d", i+1)
+		started := now.Add(time.Duration(i) * time.Hour)
+		history := []Status{{Status: "running", Message: "Task started", At: started}}
+		if outcome != "running" {
+			history = append(history, Status{Status: outcome, Message: "Task " + outcome, At: started.Add(30 * time.Minute)})
+		}
+		CreateSession(t, st, "agent-001", topic, history...)
+	}
+	ExpireSession(t, st, "agent-001", "task-003")
+	return st
+}
+
+// PostStatus reports a status to a webhook handler as the default test user and returns the response;
+// message and content are left out when empty
+func PostStatus(handler http.Handler, agentID, sessionTopic, status string, timestamp time.Time, message, content string) *httptest.ResponseRecorder {
+	reqBody := map[string]interface{}{
+		"agent_id":      agentID,
+		"agent_name":    "Test Agent",
+		"session_topic": sessionTopic,
+		"status":        status,
+		"timestamp":     timestamp.Format(time.RFC3339),
+	}
+	if message != "" {
+		reqBody["message"] = message
+	}
+	if content != "" {
+		reqBody["content"] = content
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, WithUser(req))
+	return rr
+}
+
+// SendStatus reports a status like PostStatus and fails the test unless it is accepted
+func SendStatus(t testing.TB, handler http.Handler, agentID, sessionTopic, status string, timestamp time.Time, message, content string) {
+	t.Helper()
+
+	if rr := PostStatus(handler, agentID, sessionTopic, status, timestamp, message, content); rr.Code != http.StatusOK {
+		t.Errorf("SendStatus() status = %v, want %v: %s", rr.Code, http.StatusOK, rr.Body.String())
+	}
+}
@@ -0,0 +1,39 @@
+package testsupport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestStoreWithAgents(t *testing.T) {
+	st := StoreWithAgents(t, 2, 3)
+	if agents := st.ListAgentsByUser(UserID); len(agents) != 2 {
+		t.Fatalf("ListAgentsByUser() = %d agents, want 2", len(agents))
+	}
+	sessions := st.ListSessions("agent-002", true)
+	if len(sessions) != 3 {
+		t.Errorf("agent-002 has %d sessions, want 3", len(sessions))
+	}
+}
+
+func TestCreateSession_History(t *testing.T) {
+	st := store.NewMemoryStore()
+	CreateUser(t, st)
+	start := time.Now().Add(-time.Hour)
+	CreateAgent(t, st, "agent-001", AgentAt(start))
+	session := CreateSession(t, st, "agent-001", "deploy", Statuses(start, time.Minute, "pending", "running", "success")...)
+	if !session.Created.Equal(start) {
+		t.Errorf("session created at %v, want the first status at %v", session.Created, start)
+	}
+	history, _ := st.GetStatusHistory("agent-001", "deploy")
+	if len(history) != 3 {
+		t.Errorf("history has %d statuses, want 3", len(history))
+	}
+
+	ExpireSession(t, st, "agent-001", "deploy")
+	if got, _ := st.GetSession("agent-001", "deploy"); !got.Expired {
+		t.Errorf("ExpireSession() left the session unexpired")
+	}
+}
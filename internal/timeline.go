@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// Phases of a session run, derived from its statuses
+const (
+	PhaseQueued   = "queued"   // pending: the run waits to start or for input
+	PhaseRunning  = "running"  // running
+	PhaseTerminal = "terminal" // success, failed or a server status: the run ended
+)
+
+// phaseOf returns the phase a status puts a run in
+func phaseOf(status string) string {
+	switch {
+	case endsRun(status):
+		return PhaseTerminal
+	case status == "running":
+		return PhaseRunning
+	default:
+		return PhaseQueued
+	}
+}
+
+// TimelinePhase is a stretch of a run spent in one phase, made of consecutive statuses of that phase
+type TimelinePhase struct {
+	Phase             string     `json:"phase"`
+	Status            string     `json:"status"` // Latest status reported in the phase
+	Started           time.Time  `json:"started"`
+	Ended             *time.Time `json:"ended,omitempty"`  // When the next phase started; nil while the run is in this phase
+	DurationSeconds   float64    `json:"duration_seconds"` // Start to end, or to the latest status while the run is in this phase
+	StatusCount       int        `json:"status_count"`
+	LongestGapSeconds float64    `json:"longest_gap_seconds"` // Longest silence between reports in the phase, including the one before the next phase
+}
+
+// TimelineGap is a silence between two consecutive reports of a run
+type TimelineGap struct {
+	Phase   string    `json:"phase"` // Phase the run was in during the silence
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Seconds float64   `json:"seconds"`
+}
+
+// SessionTimeline is one run of a session as a sequence of phases
+type SessionTimeline struct {
+	Revision       int              `json:"revision"`
+	Phases         []*TimelinePhase `json:"phases"`
+	Gaps           []*TimelineGap   `json:"gaps"` // Silences of at least the requested minimum, oldest first
+	QueuedSeconds  float64          `json:"queued_seconds"`
+	RunningSeconds float64          `json:"running_seconds"`
+	TotalSeconds   float64          `json:"total_seconds"`
+	Finished       *time.Time       `json:"finished,omitempty"` // Time of the final status; nil while the run is in progress
+	Result         string           `json:"result,omitempty"`   // Latest status of the run
+}
+
+// BuildTimeline derives the phases of one revision of a session from its status history
+// Silences between reports of at least minGap are listed as gaps. It returns nil when the revision has no statuses.
+func BuildTimeline(history []*models.AgentStatus, revision int, minGap time.Duration) *SessionTimeline {
+	statuses := filterStatusRevision(history, revision)
+	if len(statuses) == 0 {
+		return nil
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Timestamp.Before(statuses[j].Timestamp)
+	})
+
+	timeline := &SessionTimeline{
+		Revision: revision,
+		Phases:   make([]*TimelinePhase, 0),
+		Gaps:     make([]*TimelineGap, 0),
+	}
+	var current *TimelinePhase
+	for i, status := range statuses {
+		phase := phaseOf(status.Status)
+		if current == nil || current.Phase != phase {
+			if current != nil {
+				ended := status.Timestamp
+				current.Ended = &ended
+			}
+			current = &TimelinePhase{Phase: phase, Started: status.Timestamp}
+			timeline.Phases = append(timeline.Phases, current)
+		}
+		current.Status = status.Status
+		current.StatusCount++
+
+		if i == 0 {
+			continue
+		}
+		previous := statuses[i-1]
+		silence := status.Timestamp.Sub(previous.Timestamp)
+		owner := timeline.Phases[len(timeline.Phases)-1]
+		if phaseOf(previous.Status) != phase {
+			owner = timeline.Phases[len(timeline.Phases)-2]
+		}
+		owner.LongestGapSeconds = max(owner.LongestGapSeconds, silence.Seconds())
+		if minGap > 0 && silence >= minGap {
+			timeline.Gaps = append(timeline.Gaps, &TimelineGap{
+				Phase:   owner.Phase,
+				From:    previous.Timestamp,
+				To:      status.Timestamp,
+				Seconds: silence.Seconds(),
+			})
+		}
+	}
+
+	last := statuses[len(statuses)-1]
+	if current.Phase == PhaseTerminal {
+		ended := current.Started
+		current.Ended = &ended
+		finished := last.Timestamp
+		timeline.Finished = &finished
+	}
+	for _, phase := range timeline.Phases {
+		end := last.Timestamp
+		if phase.Ended != nil {
+			end = *phase.Ended
+		}
+		phase.DurationSeconds = end.Sub(phase.Started).Seconds()
+		switch phase.Phase {
+		case PhaseQueued:
+			timeline.QueuedSeconds += phase.DurationSeconds
+		case PhaseRunning:
+			timeline.RunningSeconds += phase.DurationSeconds
+		}
+	}
+	timeline.TotalSeconds = last.Timestamp.Sub(statuses[0].Timestamp).Seconds()
+	timeline.Result = last.Status
+	return timeline
+}
+
+// filterStatusRevision returns a copy of the statuses reported for one revision of a session
+func filterStatusRevision(history []*models.AgentStatus, revision int) []*models.AgentStatus {
+	statuses := make([]*models.AgentStatus, 0, len(history))
+	for _, status := range history {
+		if status.Revision == revision {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
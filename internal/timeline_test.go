@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	base := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	status := func(revision int, status string, minutes int) *models.AgentStatus {
+		return &models.AgentStatus{
+			AgentID:      "agent-001",
+			SessionTopic: "deploy",
+			Status:       status,
+			Timestamp:    base.Add(time.Duration(minutes) * time.Minute),
+			Revision:     revision,
+		}
+	}
+
+	// Newest first, as returned by the store
+	history := []*models.AgentStatus{
+		status(2, "running", 100),
+		status(1, "success", 30),
+		status(1, "running", 12),
+		status(1, "running", 5),
+		status(1, "running", 3),
+		status(1, "pending", 1),
+		status(1, "pending", 0),
+	}
+
+	timeline := BuildTimeline(history, 1, 5*time.Minute)
+	if timeline == nil || len(timeline.Phases) != 3 {
+		t.Fatalf("BuildTimeline() = %+v, want 3 phases", timeline)
+	}
+	queued, running, terminal := timeline.Phases[0], timeline.Phases[1], timeline.Phases[2]
+	if queued.Phase != PhaseQueued || queued.StatusCount != 2 || queued.DurationSeconds != 3*60 || queued.LongestGapSeconds != 2*60 {
+		t.Errorf("queued phase = %+v, want 2 statuses over 3m with a 2m gap", queued)
+	}
+	if running.Phase != PhaseRunning || !running.Ended.Equal(base.Add(30*time.Minute)) || running.DurationSeconds != 27*60 || running.LongestGapSeconds != 18*60 {
+		t.Errorf("running phase = %+v, want 27m ending at the final status with an 18m gap", running)
+	}
+	if terminal.Phase != PhaseTerminal || terminal.Status != "success" || terminal.DurationSeconds != 0 {
+		t.Errorf("terminal phase = %+v, want success", terminal)
+	}
+	if timeline.QueuedSeconds != 3*60 || timeline.RunningSeconds != 27*60 || timeline.TotalSeconds != 30*60 {
+		t.Errorf("BuildTimeline() totals = %v queued, %v running, %v total, want 180, 1620, 1800", timeline.QueuedSeconds, timeline.RunningSeconds, timeline.TotalSeconds)
+	}
+	if timeline.Finished == nil || timeline.Result != "success" {
+		t.Errorf("BuildTimeline() finished = %v with %q, want finished with success", timeline.Finished, timeline.Result)
+	}
+	if len(timeline.Gaps) != 2 || timeline.Gaps[0].Seconds != 7*60 || timeline.Gaps[1].Phase != PhaseRunning || timeline.Gaps[1].Seconds != 18*60 {
+		t.Errorf("BuildTimeline() gaps = %+v, want 7m and 18m while running", timeline.Gaps)
+	}
+
+	current := BuildTimeline(history, 2, 0)
+	if current == nil || len(current.Phases) != 1 || current.Phases[0].Ended != nil || current.Finished != nil || len(current.Gaps) != 0 {
+		t.Errorf("BuildTimeline() current run = %+v, want one open running phase", current)
+	}
+
+	if missing := BuildTimeline(history, 3, 0); missing != nil {
+		t.Errorf("BuildTimeline() unknown revision = %+v, want nil", missing)
+	}
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// TopicRule derives a session group and category from a session topic
+// Group and Category are expansion templates referencing capture groups ($1, ${name}).
+type TopicRule struct {
+	Pattern  string `json:"pattern"`
+	Group    string `json:"group"`
+	Category string `json:"category,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// TopicGrouper applies the first matching rule to a session topic
+type TopicGrouper struct {
+	rules []TopicRule
+}
+
+// NewTopicGrouper compiles the rules, returning an error for invalid patterns
+func NewTopicGrouper(rules []TopicRule) (*TopicGrouper, error) {
+	compiled := make([]TopicRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("rule %d: pattern is required", i)
+		}
+		if rule.Group == "" {
+			return nil, fmt.Errorf("rule %d: group is required", i)
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: invalid pattern: %w", i, err)
+		}
+		rule.re = re
+		compiled = append(compiled, rule)
+	}
+	return &TopicGrouper{rules: compiled}, nil
+}
+
+// ParseTopicRules parses grouping rules from a JSON array
+// Example: [{"pattern":"^deploy/(?P<env>[^/]+)/","group":"deploy","category":"${env}"}]
+func ParseTopicRules(raw string) (*TopicGrouper, error) {
+	if raw == "" {
+		return NewTopicGrouper(nil)
+	}
+	var rules []TopicRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("invalid topic grouping rules: %w", err)
+	}
+	return NewTopicGrouper(rules)
+}
+
+// Classify returns the group and category for a topic, or empty strings if no rule matches
+func (g *TopicGrouper) Classify(topic string) (group, category string) {
+	if g == nil {
+		return "", ""
+	}
+	for _, rule := range g.rules {
+		match := rule.re.FindStringSubmatchIndex(topic)
+		if match == nil {
+			continue
+		}
+		group = string(rule.re.ExpandString(nil, rule.Group, topic, match))
+		category = string(rule.re.ExpandString(nil, rule.Category, topic, match))
+		return truncate(group, 100), truncate(category, 100)
+	}
+	return "", ""
+}
+
+// truncate limits a derived value to the column size
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
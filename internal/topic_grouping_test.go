@@ -0,0 +1,73 @@
+package internal
+
+import "testing"
+
+func TestTopicGrouper_Classify(t *testing.T) {
+	grouper, err := ParseTopicRules(`[
+		{"pattern": "^deploy/(?P<env>[^/]+)/", "group": "deploy", "category": "${env}"},
+		{"pattern": "^nightly-(\\w+)", "group": "nightly-$1"}
+	]`)
+	if err != nil {
+		t.Fatalf("ParseTopicRules() error = %v", err)
+	}
+
+	tests := []struct {
+		topic        string
+		wantGroup    string
+		wantCategory string
+	}{
+		{"deploy/prod/2024-05-01", "deploy", "prod"},
+		{"deploy/staging/2024-05-02", "deploy", "staging"},
+		{"nightly-backup", "nightly-backup", ""},
+		{"ad-hoc task", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			group, category := grouper.Classify(tt.topic)
+			if group != tt.wantGroup {
+				t.Errorf("Classify(%q) group = %q, want %q", tt.topic, group, tt.wantGroup)
+			}
+			if category != tt.wantCategory {
+				t.Errorf("Classify(%q) category = %q, want %q", tt.topic, category, tt.wantCategory)
+			}
+		})
+	}
+}
+
+func TestTopicGrouper_NilIsNoop(t *testing.T) {
+	var grouper *TopicGrouper
+	if group, category := grouper.Classify("deploy/prod"); group != "" || category != "" {
+		t.Errorf("nil Classify() = (%q, %q), want empty", group, category)
+	}
+}
+
+func TestParseTopicRules_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"invalid json", `not json`},
+		{"invalid regex", `[{"pattern": "(", "group": "x"}]`},
+		{"missing pattern", `[{"group": "x"}]`},
+		{"missing group", `[{"pattern": "x"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTopicRules(tt.raw); err == nil {
+				t.Errorf("ParseTopicRules(%s) error = nil, want error", tt.raw)
+			}
+		})
+	}
+}
+
+func TestParseTopicRules_Empty(t *testing.T) {
+	grouper, err := ParseTopicRules("")
+	if err != nil {
+		t.Fatalf("ParseTopicRules(\"\") error = %v", err)
+	}
+	if group, _ := grouper.Classify("deploy/prod"); group != "" {
+		t.Errorf("empty rules Classify() group = %q, want empty", group)
+	}
+}
@@ -1,34 +1,52 @@
 package internal
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"time"
+
+	"github.com/kubeagents/kubeagents/models"
 )
 
 // StatusReport represents the incoming status report from webhook
 type StatusReport struct {
-	AgentID      string    `json:"agent_id"`
-	AgentName    string    `json:"agent_name,omitempty"`
-	AgentSource  string    `json:"agent_source,omitempty"`
-	SessionTopic string    `json:"session_topic"`
-	Status       string    `json:"status"`
-	Timestamp    time.Time `json:"timestamp"`
-	Message      string    `json:"message,omitempty"`
-	Content      string    `json:"content,omitempty"`
-	TTLMinutes   int       `json:"ttl_minutes,omitempty"`
+	AgentID       string          `json:"agent_id"`
+	AgentName     string          `json:"agent_name,omitempty"`
+	AgentSource   string          `json:"agent_source,omitempty"`
+	AgentKind     string          `json:"agent_kind,omitempty"` // One of the models.AgentKind constants
+	Cluster       string          `json:"cluster,omitempty"`    // Kubernetes cluster the agent runs in
+	Region        string          `json:"region,omitempty"`     // Region the agent runs in
+	SessionTopic  string          `json:"session_topic"`
+	Status        string          `json:"status"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Message       string          `json:"message,omitempty"`
+	Content       string          `json:"content,omitempty"`
+	ContentFormat string          `json:"content_format,omitempty"` // text or markdown; empty is text
+	Metadata      json.RawMessage `json:"metadata,omitempty"`
+	TTLMinutes    int             `json:"ttl_minutes,omitempty"`
+	ReportID      string          `json:"report_id,omitempty"` // Idempotency key when the request has no Idempotency-Key header
+}
+
+// statusReportFields is StatusReport without its UnmarshalJSON method
+type statusReportFields StatusReport
+
+// statusReportJSON is the wire form of a StatusReport, whose timestamp is parsed as RFC 3339
+type statusReportJSON struct {
+	Timestamp string `json:"timestamp"`
+	*statusReportFields
 }
 
 // UnmarshalJSON implements custom JSON unmarshaling for StatusReport
 func (sr *StatusReport) UnmarshalJSON(data []byte) error {
-	type Alias StatusReport
-	aux := &struct {
-		Timestamp string `json:"timestamp"`
-		*Alias
-	}{
-		Alias: (*Alias)(sr),
-	}
+	return DecodeStatusReport(data, sr)
+}
 
+// DecodeStatusReport decodes the JSON status report in data into sr
+// Decoding a request body with it rather than json.Unmarshal validates the body once instead of twice, as
+// json.Unmarshal checks the input before handing it to UnmarshalJSON, which decodes it again.
+func DecodeStatusReport(data []byte, sr *StatusReport) error {
+	aux := statusReportJSON{statusReportFields: (*statusReportFields)(sr)}
 	if err := json.Unmarshal(data, &aux); err != nil {
 		return err
 	}
@@ -45,51 +63,72 @@ func (sr *StatusReport) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-// Validate validates StatusReport input
+// Validate validates StatusReport input against the default payload limits
 func (sr *StatusReport) Validate() error {
-	if sr.AgentID == "" {
-		return errors.New("agent_id is required")
-	}
-	if len(sr.AgentID) > 100 {
-		return errors.New("agent_id must be 1-100 characters")
+	return sr.ValidateWithLimits(DefaultPayloadLimits())
+}
+
+// ValidateWithLimits validates StatusReport input against the given payload limits
+// Every invalid field is reported, as models.FieldErrors, rather than only the first.
+func (sr *StatusReport) ValidateWithLimits(limits PayloadLimits) error {
+	var errs models.FieldErrors
+
+	switch {
+	case sr.AgentID == "":
+		errs = append(errs, models.Required("agent_id"))
+	case len(sr.AgentID) > 100:
+		errs = append(errs, models.Length("agent_id", "1-100", "characters"))
 	}
 	if len(sr.AgentName) > 200 {
-		return errors.New("agent_name must be 0-200 characters")
+		errs = append(errs, models.Length("agent_name", "0-200", "characters"))
 	}
 	if len(sr.AgentSource) > 200 {
-		return errors.New("agent_source must be 0-200 characters")
-	}
-	if sr.SessionTopic == "" {
-		return errors.New("session_topic is required")
+		errs = append(errs, models.Length("agent_source", "0-200", "characters"))
 	}
-	if len(sr.SessionTopic) > 500 {
-		return errors.New("session_topic must be 1-500 characters")
+	errs.Add("agent_kind", models.ValidateAgentKind("agent_kind", sr.AgentKind))
+	errs.Add("cluster", models.ValidateLocationLabel("cluster", sr.Cluster))
+	errs.Add("region", models.ValidateLocationLabel("region", sr.Region))
+	switch {
+	case sr.SessionTopic == "":
+		errs = append(errs, models.Required("session_topic"))
+	case len(sr.SessionTopic) > 500:
+		errs = append(errs, models.Length("session_topic", "1-500", "characters"))
 	}
 
-	validStatuses := map[string]bool{
-		"running": true,
-		"success": true,
-		"failed":  true,
-		"pending": true,
-	}
-	if !validStatuses[sr.Status] {
-		return errors.New("status must be one of: running, success, failed, pending")
+	switch sr.Status {
+	case "running", "success", "failed", "pending":
+	default:
+		errs = append(errs, models.OneOf("status", "running", "success", "failed", "pending"))
 	}
 
 	if sr.Timestamp.IsZero() {
-		return errors.New("timestamp is required")
+		errs = append(errs, models.Required("timestamp"))
 	}
 
-	if len(sr.Message) > 1000 {
-		return errors.New("message must be 0-1000 characters")
+	if len(sr.Message) > limits.MaxMessageLength {
+		errs = append(errs, models.Length("message", fmt.Sprintf("0-%d", limits.MaxMessageLength), "characters"))
+	}
+	if len(sr.Content) > limits.MaxContentLength {
+		errs = append(errs, models.Length("content", fmt.Sprintf("0-%d", limits.MaxContentLength), "characters"))
 	}
-	if len(sr.Content) > 10000 {
-		return errors.New("content must be 0-10000 characters")
+	errs.Add("content_format", models.ValidateContentFormat("content_format", sr.ContentFormat))
+	switch {
+	case len(sr.Metadata) > limits.MaxMetadataBytes:
+		errs = append(errs, models.Length("metadata", fmt.Sprintf("0-%d", limits.MaxMetadataBytes), "bytes"))
+	case len(sr.Metadata) > 0 && !isJSONObject(sr.Metadata):
+		errs = append(errs, models.Invalid("metadata", "metadata must be a JSON object"))
 	}
 
 	if sr.TTLMinutes < 0 || (sr.TTLMinutes > 0 && (sr.TTLMinutes < 1 || sr.TTLMinutes > 1440)) {
-		return errors.New("ttl_minutes must be 0 or 1-1440")
+		errs = append(errs, models.Range("ttl_minutes", "0 or 1-1440"))
 	}
 
-	return nil
+	errs.Add("report_id", models.ValidateIdempotencyKey("report_id", sr.ReportID))
+
+	return errs.Err()
+}
+
+// isJSONObject reports whether raw holds a JSON object (as opposed to null, an array or a scalar)
+func isJSONObject(raw json.RawMessage) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{"))
 }
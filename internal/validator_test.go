@@ -1,8 +1,12 @@
 package internal
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/kubeagents/kubeagents/models"
 )
 
 func TestStatusReport_Validate(t *testing.T) {
@@ -86,3 +90,86 @@ func TestStatusReport_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestStatusReport_ValidateWithLimits(t *testing.T) {
+	base := StatusReport{
+		AgentID:      "agent-001",
+		SessionTopic: "task-001",
+		Status:       "running",
+		Timestamp:    time.Now(),
+	}
+	limits := PayloadLimits{MaxMessageLength: 10, MaxContentLength: 20, MaxMetadataBytes: 30}
+
+	tests := []struct {
+		name    string
+		modify  func(*StatusReport)
+		wantErr bool
+	}{
+		{"within limits", func(sr *StatusReport) { sr.Message = "ok"; sr.Metadata = []byte(`{"tokens":12}`) }, false},
+		{"message over limit", func(sr *StatusReport) { sr.Message = strings.Repeat("m", 11) }, true},
+		{"content over limit", func(sr *StatusReport) { sr.Content = strings.Repeat("c", 21) }, true},
+		{"metadata over limit", func(sr *StatusReport) { sr.Metadata = []byte(`{"key":"` + strings.Repeat("v", 30) + `"}`) }, true},
+		{"metadata not an object", func(sr *StatusReport) { sr.Metadata = []byte(`[1,2]`) }, true},
+		{"markdown content", func(sr *StatusReport) { sr.Content = "**ok**"; sr.ContentFormat = "markdown" }, false},
+		{"unknown content format", func(sr *StatusReport) { sr.ContentFormat = "html" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := base
+			tt.modify(&report)
+			if err := report.ValidateWithLimits(limits); (err != nil) != tt.wantErr {
+				t.Errorf("StatusReport.ValidateWithLimits() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatusReport_ValidateReportsEveryField(t *testing.T) {
+	report := StatusReport{
+		SessionTopic: "task-001",
+		Status:       "done",
+		Timestamp:    time.Now(),
+		Message:      strings.Repeat("m", 11),
+	}
+	err := report.ValidateWithLimits(PayloadLimits{MaxMessageLength: 10, MaxContentLength: 20, MaxMetadataBytes: 30})
+
+	var got []string
+	for _, fieldErr := range models.FieldErrorsOf(err) {
+		got = append(got, fieldErr.Field+" "+fieldErr.Code+" "+fieldErr.Constraint)
+	}
+	want := []string{"/agent_id required ", "/status one_of running, success, failed, pending", "/message length 0-10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("field errors = %q, want %q", got, want)
+	}
+	if err.Error() != "agent_id is required; status must be one of: running, success, failed, pending; message must be 0-10 characters" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+// benchmarkReport is a typical status report of a fleet agent, with a message, content and metadata
+var benchmarkReport = []byte(`{"agent_id":"build-agent-0042","agent_name":"Build Agent 42","agent_source":"ci-runner",` +
+	`"agent_kind":"ci","cluster":"prod-eu-1","region":"eu-west-1","session_topic":"pipeline-18342/test",` +
+	`"status":"running","timestamp":"2026-03-01T12:00:00Z","message":"Running integration tests (412/1280)",` +
+	`"content":"go test ./... -race -count=1\nok  \tgithub.com/example/service/api\t4.211s",` +
+	`"metadata":{"commit":"9f2c1e4","branch":"main","shard":3,"shards":8},"ttl_minutes":30}`)
+
+// Status report decoding benchmarks guard the per-report cost of the webhook hot path.
+// Target: no more than 4 allocations per report; time per report depends on the machine.
+// Run with: ./scripts/check.sh --bench
+
+func BenchmarkStatusReport_Decode(b *testing.B) {
+	limits := DefaultPayloadLimits()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchmarkReport)))
+	for i := 0; i < b.N; i++ {
+		var report StatusReport
+		if err := DecodeStatusReport(benchmarkReport, &report); err != nil {
+			b.Fatalf("DecodeStatusReport() error = %v", err)
+		}
+		if err := report.ValidateWithLimits(limits); err != nil {
+			b.Fatalf("ValidateWithLimits() error = %v", err)
+		}
+	}
+}
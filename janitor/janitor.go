@@ -0,0 +1,155 @@
+// Package janitor removes expired and aged-out records so auth and history tables stay bounded
+package janitor
+
+import (
+	"log"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/metrics"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Record kinds reported in logs and metrics
+const (
+	KindRefreshTokens = "refresh_tokens"
+	KindVerifyTokens  = "verify_tokens"
+	KindWebhookNonces = "webhook_nonces"
+	KindIdempotency   = "idempotency_keys"
+	KindSLABreaches   = "sla_breaches"
+	KindDeletedAgents = "deleted_agents"
+	KindStatuses      = "statuses"
+	KindMarks         = "notification_marks"
+	KindAuditEvents   = "audit_events"
+)
+
+// markRetention is how long a notification mark is kept after its last notification, past the longest dedup window
+// and escalation delay
+const markRetention = 7 * 24 * time.Hour
+
+// pruneBatch bounds the statuses removed by one delete, so pruning a long history does not hold locks for long
+const pruneBatch = 10000
+
+// Watermark returns the first UTC day whose statuses another job still needs, or the zero time before its first run
+type Watermark func() (time.Time, error)
+
+// task removes one kind of record and reports how many were removed
+type task struct {
+	kind string
+	run  func() (int, error)
+}
+
+// Janitor runs the cleanup tasks
+type Janitor struct {
+	store                 store.Store
+	breachRetention       time.Duration
+	deletedAgentRetention time.Duration
+	statusRetentionDays   int
+	statusWatermarks      []Watermark
+	auditRetention        time.Duration
+	removed               *metrics.CounterVec
+	clock                 clock.Clock
+}
+
+// New creates a janitor; a retention of 0 keeps SLA breaches or soft-deleted agents forever, and reg may be nil
+func New(st store.Store, breachRetention, deletedAgentRetention time.Duration, reg *metrics.Registry) *Janitor {
+	j := &Janitor{
+		store:                 st,
+		breachRetention:       breachRetention,
+		deletedAgentRetention: deletedAgentRetention,
+		clock:                 clock.Real,
+	}
+	if reg != nil {
+		j.removed = reg.NewCounterVec("kubeagents_janitor_removed_total", "Records removed by the cleanup janitor.", "kind")
+	}
+	return j
+}
+
+// SetClock replaces the clock that decides which SLA breaches and deleted agents are past retention
+func (j *Janitor) SetClock(c clock.Clock) {
+	j.clock = c
+}
+
+// SetStatusRetention prunes statuses older than days whole UTC days, keeping each session's latest status
+// Days are only pruned once every watermark has passed them, e.g. once they are rolled up and archived;
+// 0 days keeps status history forever.
+func (j *Janitor) SetStatusRetention(days int, watermarks ...Watermark) {
+	j.statusRetentionDays = days
+	j.statusWatermarks = watermarks
+}
+
+// SetAuditRetention purges audit events older than retention; 0 keeps the audit log forever
+func (j *Janitor) SetAuditRetention(retention time.Duration) {
+	j.auditRetention = retention
+}
+
+// pruneStatuses removes the statuses past retention in batches
+func (j *Janitor) pruneStatuses() (int, error) {
+	cutoff := models.UsageDay(j.clock.Now()).AddDate(0, 0, -j.statusRetentionDays)
+	for _, watermark := range j.statusWatermarks {
+		day, err := watermark()
+		if err != nil {
+			return 0, err
+		}
+		if day.Before(cutoff) {
+			cutoff = day
+		}
+	}
+	if cutoff.IsZero() {
+		return 0, nil
+	}
+
+	pruned := 0
+	for {
+		count, err := j.store.PruneStatuses(cutoff, pruneBatch)
+		pruned += count
+		if err != nil || count < pruneBatch {
+			return pruned, err
+		}
+	}
+}
+
+// Run performs one cleanup pass and returns the number of records removed per kind
+// A failing task is logged and does not stop the others.
+func (j *Janitor) Run() map[string]int {
+	tasks := []task{
+		{KindRefreshTokens, j.store.PurgeExpiredRefreshTokens},
+		{KindVerifyTokens, j.store.ClearExpiredVerifyTokens},
+		{KindWebhookNonces, j.store.PurgeExpiredNonces},
+		{KindIdempotency, j.store.PurgeExpiredIdempotencyKeys},
+		{KindMarks, func() (int, error) { return j.store.PurgeNotificationMarks(j.clock.Now().Add(-markRetention)) }},
+	}
+	if j.breachRetention > 0 {
+		cutoff := j.clock.Now().Add(-j.breachRetention)
+		tasks = append(tasks, task{KindSLABreaches, func() (int, error) { return j.store.PurgeSLABreaches(cutoff) }})
+	}
+	if j.deletedAgentRetention > 0 {
+		cutoff := j.clock.Now().Add(-j.deletedAgentRetention)
+		tasks = append(tasks, task{KindDeletedAgents, func() (int, error) { return j.store.PurgeDeletedAgents(cutoff) }})
+	}
+	if j.auditRetention > 0 {
+		cutoff := j.clock.Now().Add(-j.auditRetention)
+		tasks = append(tasks, task{KindAuditEvents, func() (int, error) { return j.store.PurgeAuditEvents(cutoff) }})
+	}
+	if j.statusRetentionDays > 0 {
+		tasks = append(tasks, task{KindStatuses, j.pruneStatuses})
+	}
+
+	removed := make(map[string]int, len(tasks))
+	for _, task := range tasks {
+		count, err := task.run()
+		if err != nil {
+			log.Printf("Janitor failed to clean %s: %v", task.kind, err)
+			continue
+		}
+		removed[task.kind] = count
+		if count > 0 {
+			log.Printf("Janitor removed %d %s", count, task.kind)
+		}
+		if j.removed != nil {
+			j.removed.Add(task.kind, float64(count))
+		}
+	}
+	return removed
+}
@@ -0,0 +1,153 @@
+package janitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/metrics"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestJanitor_Run(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	st.CreateUser(&models.User{ID: "user-1", Email: "one@example.com", PasswordHash: "hash", VerifyToken: "stale", VerifyTokenExpiresAt: &past})
+	st.CreateUser(&models.User{ID: "user-2", Email: "two@example.com", PasswordHash: "hash", VerifyToken: "fresh", VerifyTokenExpiresAt: &future})
+	st.SaveRefreshToken(&models.RefreshToken{ID: "expired", UserID: "user-1", TokenHash: "hash-1", ExpiresAt: past, CreatedAt: past})
+	st.SaveRefreshToken(&models.RefreshToken{ID: "live", UserID: "user-1", TokenHash: "hash-2", ExpiresAt: future, CreatedAt: now})
+	st.SaveNonce("key:1", "nonce", past)
+	st.ClaimIdempotencyKey(&models.IdempotencyRecord{UserID: "user-1", Key: "report-1", CreatedAt: past, ExpiresAt: past})
+	st.ClaimIdempotencyKey(&models.IdempotencyRecord{UserID: "user-1", Key: "report-2", CreatedAt: now, ExpiresAt: future})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-old", UserID: "user-1", Registered: now, LastSeen: now})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-new", UserID: "user-1", Registered: now, LastSeen: now})
+	st.DeleteAgent("agent-old", now.Add(-31*24*time.Hour))
+	st.DeleteAgent("agent-new", now)
+	st.CreateSLA(&models.SLA{ID: "sla-1", UserID: "user-1", Name: "SLA", MaxDurationMinutes: 60, CreatedAt: now, UpdatedAt: now})
+	for i, detectedAt := range []time.Time{now.Add(-100 * 24 * time.Hour), now} {
+		st.CreateSLABreach(&models.SLABreach{
+			ID:         string(rune('a' + i)),
+			SLAID:      "sla-1",
+			AgentID:    "agent-1",
+			Kind:       models.SLABreachDuration,
+			Subject:    detectedAt.Format(time.RFC3339),
+			DetectedAt: detectedAt,
+		})
+	}
+
+	st.ClaimNotificationMark(&models.NotificationMark{UserID: "user-1", AgentID: "agent-1", SessionTopic: "build", Status: "failed", NotifiedAt: now.Add(-8 * 24 * time.Hour)}, now)
+	st.ClaimNotificationMark(&models.NotificationMark{UserID: "user-1", AgentID: "agent-1", SessionTopic: "deploy", Status: "failed", NotifiedAt: now}, now)
+
+	reg := metrics.NewRegistry()
+	j := New(st, 90*24*time.Hour, 30*24*time.Hour, reg)
+	removed := j.Run()
+
+	want := map[string]int{
+		KindRefreshTokens: 1,
+		KindVerifyTokens:  1,
+		KindWebhookNonces: 1,
+		KindIdempotency:   1,
+		KindSLABreaches:   1,
+		KindDeletedAgents: 1,
+		KindMarks:         1,
+	}
+	for kind, count := range want {
+		if removed[kind] != count {
+			t.Errorf("Run() removed %s = %d, want %d", kind, removed[kind], count)
+		}
+	}
+
+	if user, _ := st.GetUserByID("user-2"); user.VerifyToken != "fresh" {
+		t.Error("Run() cleared an unexpired verification token")
+	}
+	if breaches, _ := st.ListSLABreaches("sla-1", time.Time{}); len(breaches) != 1 {
+		t.Errorf("Run() kept %d SLA breaches, want 1", len(breaches))
+	}
+	if deleted, _ := st.ListDeletedAgents(""); len(deleted) != 1 || deleted[0].AgentID != "agent-new" {
+		t.Errorf("Run() kept deleted agents %v, want only agent-new", deleted)
+	}
+
+	// Counters accumulate across runs
+	j.Run()
+	if got := j.removed.Value(KindRefreshTokens); got != 1 {
+		t.Errorf("removed counter = %v, want 1", got)
+	}
+}
+
+func TestJanitor_BreachRetentionDisabled(t *testing.T) {
+	st := store.NewMemoryStore()
+	removed := New(st, 0, 0, nil).Run()
+
+	if _, ok := removed[KindSLABreaches]; ok {
+		t.Error("Run() purged SLA breaches with retention disabled")
+	}
+	if _, ok := removed[KindDeletedAgents]; ok {
+		t.Error("Run() purged deleted agents with retention disabled")
+	}
+	if _, ok := removed[KindAuditEvents]; ok {
+		t.Error("Run() purged audit events with retention disabled")
+	}
+}
+
+func TestJanitor_AuditRetention(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	for _, age := range []time.Duration{400 * 24 * time.Hour, 366 * 24 * time.Hour, time.Hour} {
+		st.AddAuditEvent(&models.AuditEvent{ActorID: "admin-1", Action: "GET /api/admin/metrics", StatusCode: 200, CreatedAt: now.Add(-age)})
+	}
+
+	j := New(st, 0, 0, metrics.NewRegistry())
+	j.SetClock(clock.NewFake(now))
+	j.SetAuditRetention(365 * 24 * time.Hour)
+	if removed := j.Run(); removed[KindAuditEvents] != 2 {
+		t.Errorf("Run() purged %d audit events, want 2", removed[KindAuditEvents])
+	}
+	if events, _ := st.ListAuditEvents(time.Time{}, 0); len(events) != 1 || !events[0].CreatedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Run() kept %d audit events, want only the recent one", len(events))
+	}
+	if got := j.removed.Value(KindAuditEvents); got != 2 {
+		t.Errorf("removed counter = %v, want 2", got)
+	}
+}
+
+func TestJanitor_StatusRetention(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", UserID: "user-1", Registered: now, LastSeen: now})
+	st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "task-1", Created: now, LastUpdated: now})
+	for _, days := range []int{10, 9, 0} {
+		st.AddStatus(&models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: now.AddDate(0, 0, -days)})
+	}
+
+	var watermark time.Time
+	j := New(st, 0, 0, metrics.NewRegistry())
+	j.SetClock(clock.NewFake(now))
+	j.SetStatusRetention(7, func() (time.Time, error) { return watermark, nil })
+
+	// Nothing is pruned before the watermarked job has run
+	if removed := j.Run(); removed[KindStatuses] != 0 {
+		t.Errorf("Run() before the watermark pruned %d statuses, want 0", removed[KindStatuses])
+	}
+
+	// Days the watermarked job has not reached yet are kept past retention
+	watermark = models.UsageDay(now.AddDate(0, 0, -9))
+	if removed := j.Run(); removed[KindStatuses] != 1 {
+		t.Errorf("Run() up to the watermark pruned %d statuses, want 1", removed[KindStatuses])
+	}
+
+	// Days within retention are kept past the watermark, and so is the session's latest status
+	watermark = models.UsageDay(now)
+	if removed := j.Run(); removed[KindStatuses] != 1 {
+		t.Errorf("Run() past the watermark pruned %d statuses, want 1", removed[KindStatuses])
+	}
+	if history, _ := st.GetStatusHistory("agent-1", "task-1"); len(history) != 1 || !history[0].Timestamp.Equal(now) {
+		t.Errorf("Run() kept %d statuses, want only the latest", len(history))
+	}
+	if got := j.removed.Value(KindStatuses); got != 2 {
+		t.Errorf("removed counter = %v, want 2", got)
+	}
+}
@@ -3,9 +3,15 @@ package main
 import (
 	"context"
 	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -14,18 +20,46 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
+	"github.com/kubeagents/kubeagents/alerting"
+	"github.com/kubeagents/kubeagents/archive"
 	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/changefeed"
+	"github.com/kubeagents/kubeagents/chaos"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/compliance"
 	"github.com/kubeagents/kubeagents/config"
 	"github.com/kubeagents/kubeagents/email"
+	"github.com/kubeagents/kubeagents/encryption"
+	"github.com/kubeagents/kubeagents/events"
 	"github.com/kubeagents/kubeagents/handlers"
+	"github.com/kubeagents/kubeagents/healthscore"
+	"github.com/kubeagents/kubeagents/inbox"
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/janitor"
+	"github.com/kubeagents/kubeagents/metering"
+	"github.com/kubeagents/kubeagents/metrics"
 	authMiddleware "github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/outbox"
+	"github.com/kubeagents/kubeagents/presence"
+	"github.com/kubeagents/kubeagents/realtime"
+	"github.com/kubeagents/kubeagents/replication"
+	"github.com/kubeagents/kubeagents/revocation"
+	"github.com/kubeagents/kubeagents/rollup"
+	"github.com/kubeagents/kubeagents/selftest"
+	"github.com/kubeagents/kubeagents/sessionclose"
+	"github.com/kubeagents/kubeagents/sessionhook"
 	"github.com/kubeagents/kubeagents/store"
+	"github.com/kubeagents/kubeagents/storecopy"
+	"github.com/kubeagents/kubeagents/web"
 )
 
 const jwtSecretConfigKey = "jwt_secret"
 
+// minBootstrapTokenLength keeps the bootstrap token, which can create users, from being guessable
+const minBootstrapTokenLength = 32
+
 // initJWTSecret initializes the JWT secret from config or storage
 // If config has a secret, use it and save to storage
 // If config doesn't have a secret, try to load from storage, or generate a new one
@@ -70,7 +104,331 @@ func generateRandomSecret(length int) (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
+// limitedRouters returns the routers request/response routes and long-lived streams are registered on
+// Each has its own concurrency limit, so open event streams and WebSockets never take the slots of API requests.
+func limitedRouters(r chi.Router, requestLimiter, streamLimiter *authMiddleware.ConcurrencyLimiter) (requests, streams chi.Router) {
+	return r.With(requestLimiter.Handler), r.With(streamLimiter.Handler)
+}
+
+// webhookReportRoutes registers the routes agents report on; it runs after the caller was authenticated
+// Viewers only read, so their credentials are refused with 403 as on the API's writing routes.
+func webhookReportRoutes(r chi.Router, webhookHandler *handlers.WebhookHandler) {
+	r.Use(authMiddleware.RequireRole(authMiddleware.RoleAdmin, authMiddleware.RoleMember))
+	r.Post("/status", webhookHandler.ServeHTTP)
+	r.Post("/keepalive", webhookHandler.ServeKeepalive)
+	r.Post("/validate", webhookHandler.ServeValidate)
+	r.Post("/alertmanager", webhookHandler.ServeAlertmanager)
+	r.Post("/argo", webhookHandler.ServeArgo)
+	r.Post("/tekton", webhookHandler.ServeTekton)
+	r.Post("/github", webhookHandler.ServeGitHub)
+	r.Post("/llm", webhookHandler.ServeLLM)
+}
+
+// newAccessLogger builds the access log middleware from configuration
+func newAccessLogger(cfg config.AccessLogConfig) *authMiddleware.AccessLogger {
+	var handler slog.Handler
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(os.Stdout, nil)
+	} else {
+		handler = slog.NewJSONHandler(os.Stdout, nil)
+	}
+	return authMiddleware.NewAccessLogger(slog.New(handler), cfg.SampleRate)
+}
+
+// loadUI returns the dashboard files to serve, preferring an on-disk directory over embedded files
+func loadUI(cfg config.UIConfig) (fs.FS, bool) {
+	if cfg.Dir != "" {
+		return os.DirFS(cfg.Dir), true
+	}
+	return web.Embedded()
+}
+
+// newMTLSConfig builds the TLS settings of the mTLS webhook listener
+// Without a client CA any certificate is accepted by the handshake and authorized by its registered fingerprint.
+func newMTLSConfig(cfg config.MTLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("MTLS_CERT_FILE and MTLS_KEY_FILE are required when MTLS_PORT is set")
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAnyClientCert,
+	}
+
+	if cfg.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("client CA file %s contains no certificates", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// migrateStoreConfigKeys are the system config values copied by migrate-store
+var migrateStoreConfigKeys = []string{jwtSecretConfigKey, handlers.NotificationPolicyConfigKey, handlers.NotificationSigningConfigKey,
+	rollup.WatermarkConfigKey, archive.WatermarkConfigKey}
+
+// runMigrateStore implements `kubeagents migrate-store --from <dsn> --to <dsn>` and returns the exit code
+// Stop the servers writing to the source first: records written during the copy are not carried over.
+func runMigrateStore(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("migrate-store", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	from := flags.String("from", "", "PostgreSQL connection string of the store to copy from")
+	to := flags.String("to", "", "PostgreSQL connection string of the empty store to copy into")
+	verifyOnly := flags.Bool("verify-only", false, "Only compare the checksums of both stores")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" || *to == "" {
+		fmt.Fprintln(stderr, "migrate-store: --from and --to are required")
+		flags.Usage()
+		return 2
+	}
+
+	source := openPostgres(*from)
+	defer source.Close()
+	destination := openPostgres(*to)
+	defer destination.Close()
+
+	return migrateStore(source, destination, *verifyOnly, stdout, stderr)
+}
+
+// migrateStore copies source into destination unless verifyOnly is set, then verifies the copy
+func migrateStore(source, destination store.Store, verifyOnly bool, stdout, stderr io.Writer) int {
+	if !verifyOnly {
+		_, err := storecopy.Copy(source, destination, migrateStoreConfigKeys, func(kind string, copied int) {
+			fmt.Fprintf(stdout, "Copied %d %s\n", copied, kind)
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "migrate-store: %v\n", err)
+			return 1
+		}
+	}
+
+	checksums, err := storecopy.Verify(source, destination, migrateStoreConfigKeys)
+	if err != nil {
+		fmt.Fprintf(stderr, "migrate-store: verification failed: %v\n", err)
+		return 1
+	}
+	for _, kind := range storecopy.Kinds {
+		fmt.Fprintf(stdout, "Verified %s sha256:%s\n", kind, checksums[kind])
+	}
+	return 0
+}
+
+// runFsck implements `kubeagents fsck [--repair]` against the database configured by DB_* and returns the exit code
+func runFsck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	repair := flags.Bool("repair", false, "Delete orphaned sessions and statuses and correct expired flags")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := config.Load()
+	if cfg.Database.DBName == "" {
+		fmt.Fprintln(stderr, "fsck: DB_NAME is required, the in-memory store has nothing to check")
+		return 2
+	}
+	pgStore := openPostgres(postgresConnString(cfg.Database))
+	defer pgStore.Close()
+
+	return fsck(pgStore, *repair, stdout, stderr)
+}
+
+// fsck reports the store's integrity issues, repairing them if asked, and returns 1 if any remain
+func fsck(st store.Store, repair bool, stdout, stderr io.Writer) int {
+	issues, err := st.CheckIntegrity(repair)
+	if err != nil {
+		fmt.Fprintf(stderr, "fsck: %v\n", err)
+		return 1
+	}
+
+	repaired := 0
+	for _, issue := range issues {
+		subject := issue.AgentID
+		if issue.SessionTopic != "" {
+			subject += "/" + issue.SessionTopic
+		}
+		line := fmt.Sprintf("%s %s: %s", issue.Kind, subject, issue.Detail)
+		if issue.Repaired {
+			line += " (repaired)"
+			repaired++
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	fmt.Fprintf(stdout, "%d issues found, %d repaired\n", len(issues), repaired)
+
+	if repaired < len(issues) {
+		return 1
+	}
+	return 0
+}
+
+// runDryRun implements `kubeagents dry-run --at <time>` against the database configured by DB_* and returns the exit code
+func runDryRun(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("dry-run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	at := flags.String("at", "", "RFC 3339 time to evaluate expiration and retention at, e.g. 2026-01-02T15:04:05Z")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	frozen, err := time.Parse(time.RFC3339, *at)
+	if err != nil {
+		fmt.Fprintln(stderr, "dry-run: --at must be an RFC 3339 time")
+		return 2
+	}
+
+	cfg := config.Load()
+	if cfg.Database.DBName == "" {
+		fmt.Fprintln(stderr, "dry-run: DB_NAME is required, the in-memory store has nothing to evaluate")
+		return 2
+	}
+	pgStore := openPostgres(postgresConnString(cfg.Database))
+	defer pgStore.Close()
+
+	if err := dryRun(pgStore, clock.NewFake(frozen), cfg.Janitor.SLABreachRetention, stdout); err != nil {
+		fmt.Fprintf(stderr, "dry-run: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// dryRun reports what session expiration and SLA breach retention would change at the clock's time,
+// without changing anything
+func dryRun(st store.Store, c clock.Clock, breachRetention time.Duration, stdout io.Writer) error {
+	now := c.Now().UTC()
+
+	expiring := 0
+	for _, agent := range st.ListAgents() {
+		for _, session := range st.ListSessions(agent.AgentID, false) {
+			if expiresAt := session.ExpiresAt(); now.After(expiresAt) {
+				fmt.Fprintf(stdout, "expire %s/%s: expires at %s\n", session.AgentID, session.SessionTopic, expiresAt.UTC().Format(time.RFC3339))
+				expiring++
+			}
+		}
+	}
+
+	purging := 0
+	if breachRetention > 0 {
+		cutoff := now.Add(-breachRetention)
+		slas, err := st.ListSLAs()
+		if err != nil {
+			return err
+		}
+		for _, sla := range slas {
+			breaches, err := st.ListSLABreaches(sla.ID, time.Time{})
+			if err != nil {
+				return err
+			}
+			for _, breach := range breaches {
+				if breach.DetectedAt.Before(cutoff) {
+					purging++
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(stdout, "At %s: %d sessions would expire, %d SLA breaches would be purged\n", now.Format(time.RFC3339), expiring, purging)
+	return nil
+}
+
+// runSelftest implements `kubeagents selftest` against the store configured by DB_* and returns the exit code
+func runSelftest(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("selftest", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	notifyURL := flags.String("notify-url", "", "Webhook URL to send a test notification to; empty skips the notification")
+	notifyFormat := flags.String("notify-format", "", "Payload format of the test notification: generic, json, slack, discord, feishu or teams; empty detects it from the URL")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg := config.Load()
+	var st store.Store
+	if cfg.Database.DBName != "" {
+		pgStore := openPostgres(postgresConnString(cfg.Database))
+		defer pgStore.Close()
+		st = pgStore
+	} else {
+		fmt.Fprintln(stdout, "DB_NAME is not set, testing the in-memory store")
+		st = store.NewMemoryStore()
+	}
+	if cfg.EncryptionMasterKey != "" {
+		wrapper, err := encryption.NewLocalKeyWrapper(cfg.EncryptionMasterKey)
+		if err != nil {
+			fmt.Fprintf(stderr, "selftest: ENCRYPTION_MASTER_KEY: %v\n", err)
+			return 1
+		}
+		st = encryption.NewStore(st, wrapper)
+	}
+
+	err := selftest.Run(st, selftest.Config{
+		NotifyURL: *notifyURL,
+		Format:    *notifyFormat,
+		Timeout:   cfg.NotificationTimeout,
+	}, stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "selftest: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "selftest passed")
+	return 0
+}
+
+// postgresConnString builds the connection string of the configured database
+func postgresConnString(cfg config.DatabaseConfig) string {
+	return fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host,
+		cfg.Port,
+		cfg.User,
+		cfg.Password,
+		cfg.DBName,
+		cfg.SSLMode,
+	)
+}
+
+// openPostgres connects to a PostgreSQL store and brings its schema up to date
+func openPostgres(connString string) *store.PostgresStore {
+	pgStore, err := store.NewPostgresStore(context.Background(), connString)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	// Run database migrations (release connection immediately after)
+	conn, err := pgStore.Pool().Acquire(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to acquire database connection: %v", err)
+	}
+	defer conn.Release()
+
+	if err := store.RunMigrations(context.Background(), conn.Conn()); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+	return pgStore
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate-store":
+			os.Exit(runMigrateStore(os.Args[2:], os.Stdout, os.Stderr))
+		case "fsck":
+			os.Exit(runFsck(os.Args[2:], os.Stdout, os.Stderr))
+		case "dry-run":
+			os.Exit(runDryRun(os.Args[2:], os.Stdout, os.Stderr))
+		case "selftest":
+			os.Exit(runSelftest(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	// Load configuration
 	cfg := config.Load()
 
@@ -81,35 +439,7 @@ func main() {
 
 	if cfg.Database.DBName != "" {
 		// Use PostgreSQL
-		connString := fmt.Sprintf(
-			"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-			cfg.Database.Host,
-			cfg.Database.Port,
-			cfg.Database.User,
-			cfg.Database.Password,
-			cfg.Database.DBName,
-			cfg.Database.SSLMode,
-		)
-
-		var err error
-		pgStore, err = store.NewPostgresStore(context.Background(), connString)
-		if err != nil {
-			log.Fatalf("Failed to connect to database: %v", err)
-		}
-
-		// Run database migrations (release connection immediately after)
-		func() {
-			conn, err := pgStore.Pool().Acquire(context.Background())
-			if err != nil {
-				log.Fatalf("Failed to acquire database connection: %v", err)
-			}
-			defer conn.Release()
-
-			if err := store.RunMigrations(context.Background(), conn.Conn()); err != nil {
-				log.Fatalf("Failed to run migrations: %v", err)
-			}
-		}()
-
+		pgStore = openPostgres(postgresConnString(cfg.Database))
 		st = pgStore
 		closeDB = func() { pgStore.Close() }
 		log.Println("Using PostgreSQL storage")
@@ -119,8 +449,64 @@ func main() {
 		log.Println("Using in-memory storage")
 	}
 
+	// Mirror writes to a secondary store, below encryption so the secondary only receives ciphertext
+	var replicator *replication.Store
+	if cfg.Replica.DatabaseURL != "" {
+		replicaStore := openPostgres(cfg.Replica.DatabaseURL)
+		replicator = replication.NewStore(st, replicaStore, cfg.Replica.QueueSize)
+		st = replicator
+		primaryClose := closeDB
+		closeDB = func() {
+			if primaryClose != nil {
+				primaryClose()
+			}
+			replicaStore.Close()
+		}
+		log.Println("Replication to secondary store enabled")
+	}
+
+	// Encrypt status message and content at rest with per-user data keys
+	if cfg.EncryptionMasterKey != "" {
+		wrapper, err := encryption.NewLocalKeyWrapper(cfg.EncryptionMasterKey)
+		if err != nil {
+			log.Fatalf("Failed to load ENCRYPTION_MASTER_KEY: %v", err)
+		}
+		st = encryption.NewStore(st, wrapper)
+		log.Println("Status content encryption enabled")
+	}
+
+	// Inject faults for resilience testing; only binaries built with -tags chaos include the wrappers
+	var chaosInjector *chaos.Injector
+	if chaos.Enabled {
+		chaosInjector = chaos.NewInjector()
+		st = chaos.WrapStore(st, chaosInjector)
+		log.Println("Fault injection enabled: faults are set through /api/admin/chaos")
+	}
+
+	// Register external notification channels before any destination can select them
+	plugins, err := notifier.ParsePlugins(cfg.NotifierPlugins, cfg.NotificationTimeout)
+	if err != nil {
+		log.Fatalf("Invalid NOTIFIER_PLUGINS: %v", err)
+	}
+	for _, plugin := range plugins {
+		if err := notifier.Register(plugin); err != nil {
+			log.Fatalf("Failed to register notifier plugin: %v", err)
+		}
+		log.Printf("Notifier plugin %s registered", plugin.Format())
+	}
+
 	// Initialize notification manager
 	notificationManager := notifier.NewNotificationManager(cfg.NotificationTimeout)
+	notificationManager.SetCoalesceWindow(cfg.NotificationCoalescing)
+	notificationManager.SetSessionLinkBase(cfg.AppBaseURL)
+	if err := notificationManager.SetDefaultFormat(cfg.NotificationDefaultFormat); err != nil {
+		log.Fatalf("Invalid NOTIFICATION_DEFAULT_FORMAT: %v", err)
+	}
+	signingSecrets := handlers.NewSigningSecretSource(st)
+	notificationManager.SetSigningSecrets(signingSecrets.Secrets)
+	if chaosInjector != nil {
+		notificationManager.SetTransport(chaos.WrapTransport(nil, chaosInjector))
+	}
 
 	// Initialize JWT secret from config or storage
 	jwtSecret, err := initJWTSecret(st, cfg.JWT.Secret)
@@ -148,37 +534,241 @@ func main() {
 	}
 
 	// Initialize auth middleware (with store for API key support)
-	authMiddleware := authMiddleware.NewAuthMiddlewareWithStore(jwtService, st)
+	authMW := authMiddleware.NewAuthMiddlewareWithStore(jwtService, st)
+	authMW.SetAPIKeyCacheTTL(cfg.APIKeyCache.TTL)
+	authMW.SetAdminEmails(cfg.AdminEmails)
+
+	// Push API key revocations to every replica sharing the database, so their caches drop revoked keys at once
+	var revocations revocation.Bus = revocation.NewLocal()
+	var revocationListener *revocation.Postgres
+	if pgStore != nil {
+		revocationListener = revocation.NewPostgres(pgStore.Pool())
+		revocations = revocationListener
+	}
+	revocations.Subscribe(authMW.ForgetAPIKey)
+	if cfg.APIKeyCache.UsageFlushInterval > 0 {
+		authMW.EnableBatchedKeyUsage()
+	}
 
 	// Initialize handlers
 	healthHandler := handlers.HealthCheck
 	webhookHandler := handlers.NewWebhookHandlerWithNotifier(st, notificationManager)
+
+	topicGrouper, err := internal.ParseTopicRules(cfg.SessionGroupRules)
+	if err != nil {
+		log.Fatalf("Failed to parse SESSION_GROUP_RULES: %v", err)
+	}
+	webhookHandler.SetTopicGrouper(topicGrouper)
+	webhookHandler.SetSessionReopenGrace(cfg.SessionReopenGrace)
+	if err := models.ValidateAgentKind("AGENT_DEFAULT_KIND", cfg.AgentDefaultKind); err != nil {
+		log.Fatalf("Invalid AGENT_DEFAULT_KIND: %v", err)
+	}
+	webhookHandler.SetDefaultAgentKind(cfg.AgentDefaultKind)
+	webhookHandler.SetIdempotencyTTL(cfg.Limits.WebhookIdempotencyTTL)
+
+	payloadLimits, err := internal.NewPayloadLimitPolicy(internal.PayloadLimits{
+		MaxMessageLength: cfg.Payload.MaxMessageLength,
+		MaxContentLength: cfg.Payload.MaxContentLength,
+		MaxMetadataBytes: cfg.Payload.MaxMetadataBytes,
+	}, cfg.Payload.Tiers)
+	if err != nil {
+		log.Fatalf("Failed to configure payload limits: %v", err)
+	}
+	webhookHandler.SetPayloadLimits(payloadLimits)
+
+	notificationInbox := inbox.New(st, cfg.AgentOfflineAfter)
+	webhookHandler.SetInbox(notificationInbox)
+
+	var offlineNotifier *notifier.NotificationManager
+	if cfg.AgentOfflineNotify {
+		offlineNotifier = notificationManager
+	}
+	presenceMonitor := presence.NewMonitor(st, offlineNotifier, cfg.AgentHeartbeatInterval, cfg.AgentOfflineAfter)
+
+	agentEvents := events.NewBroker()
+	webhookHandler.SetEvents(agentEvents)
+	realtimeHub := realtime.NewHub(agentEvents)
+
+	// Push agent events and inbox items to every replica sharing the database, so streams connected to
+	// any of them see changes at once
+	var changeFeed *changefeed.Feed
+	if pgStore != nil {
+		changeFeed = changefeed.New(pgStore.Pool())
+		changeFeed.RelayAgentEvents(agentEvents)
+		changeFeed.RelayInbox(notificationInbox)
+	}
+
+	var usageMeter *metering.Meter
+	if cfg.MeteringFlushInterval > 0 {
+		usageMeter = metering.NewMeter(st)
+		webhookHandler.SetMeter(usageMeter)
+	}
+
+	sessionHooks := sessionhook.NewDispatcher(st, cfg.NotificationTimeout)
+	if chaosInjector != nil {
+		sessionHooks.SetTransport(chaos.WrapTransport(nil, chaosInjector))
+	}
+
+	sessionCloser := sessionclose.NewCloser(st)
+	sessionCloser.SetSessionHooks(sessionHooks)
+	sessionCloser.SetEvents(agentEvents)
+	presenceMonitor.SetSessionCloser(sessionCloser)
+	webhookHandler.SetSessionHooks(sessionHooks)
+
+	var outboxRelay *outbox.Relay
+	if cfg.Outbox.Interval > 0 {
+		outboxRelay = outbox.NewRelay(st, notificationManager, notificationInbox, cfg.Outbox.MaxAttempts)
+		outboxRelay.SetSessionHooks(sessionHooks)
+		outboxRelay.SetWorkers(cfg.Outbox.Workers)
+		webhookHandler.SetOutbox(outboxRelay)
+		log.Println("Transactional outbox enabled")
+	}
+	switch cfg.NotificationQueue {
+	case "memory":
+	case "store":
+		notificationManager.SetQueue(outboxRelay)
+		log.Println("Notifications queued in the store")
+	default:
+		log.Fatalf("Invalid NOTIFICATION_QUEUE %q, must be memory or store", cfg.NotificationQueue)
+	}
+
+	slaEvaluator := compliance.NewEvaluator(st, notificationManager)
+	alertEngine := alerting.NewEngine(st, notificationManager)
+	webhookHandler.SetAlertEngine(alertEngine)
+
+	metricsRegistry := metrics.NewRegistry()
+	if cfg.Limits.WebhookLatencyBudget > 0 {
+		webhookHandler.SetLatencyBudget(cfg.Limits.WebhookLatencyBudget, cfg.Limits.WebhookDeferWindow, cfg.Limits.WebhookDeferQueueSize, metricsRegistry)
+	}
+	recordJanitor := janitor.New(st, cfg.Janitor.SLABreachRetention, cfg.Janitor.DeletedAgentRetention, metricsRegistry)
+	statusRoller := rollup.New(st)
+
+	// Status history archive, when object storage is configured
+	var statusArchiver *archive.Archiver
+	if cfg.Archive.URL != "" {
+		bucket, err := archive.OpenBucket(archive.BucketConfig{
+			URL:             cfg.Archive.URL,
+			Endpoint:        cfg.Archive.Endpoint,
+			Region:          cfg.Archive.Region,
+			AccessKeyID:     cfg.Archive.AccessKeyID,
+			SecretAccessKey: cfg.Archive.SecretAccessKey,
+		})
+		if err != nil {
+			log.Fatalf("Invalid archive configuration: %v", err)
+		}
+		statusArchiver = archive.New(st, bucket)
+		if err := statusArchiver.SetPassphrase(cfg.Archive.Passphrase); err != nil {
+			log.Fatalf("Invalid ARCHIVE_PASSPHRASE: %v", err)
+		}
+	}
+
+	// Statuses are pruned only once they are summarized in rollups and, when archiving, safe in the archive
+	if cfg.Janitor.StatusRetentionDays < 0 {
+		log.Fatalf("Invalid STATUS_RETENTION_DAYS: %d", cfg.Janitor.StatusRetentionDays)
+	}
+	statusWatermarks := []janitor.Watermark{statusRoller.RolledUpThrough}
+	if statusArchiver != nil {
+		statusWatermarks = append(statusWatermarks, statusArchiver.ArchivedThrough)
+	}
+	recordJanitor.SetStatusRetention(cfg.Janitor.StatusRetentionDays, statusWatermarks...)
+	recordJanitor.SetAuditRetention(cfg.Janitor.AuditRetention)
+
+	var healthScorer *healthscore.Scorer
+	if cfg.Health.Interval > 0 {
+		healthScorer = healthscore.NewScorer(st, healthscore.Config{
+			FailureWeight: cfg.Health.FailureWeight,
+			OfflineWeight: cfg.Health.OfflineWeight,
+			StuckWeight:   cfg.Health.StuckWeight,
+			Window:        cfg.Health.Window,
+			OfflineAfter:  cfg.AgentOfflineAfter,
+			StuckAfter:    cfg.Health.StuckAfter,
+		})
+		healthScorer.Recalculate()
+	}
+
 	agentHandler := handlers.NewAgentHandler(st)
+	agentHandler.SetComplianceEvaluator(slaEvaluator)
+	agentHandler.SetDeletedAgentRetention(cfg.Janitor.DeletedAgentRetention)
+	agentHandler.SetSessionHooks(sessionHooks)
+	agentHandler.SetSessionCloser(sessionCloser)
+	if healthScorer != nil {
+		agentHandler.SetHealthScorer(healthScorer)
+	}
 	authHandler := handlers.NewAuthHandler(st, jwtService, emailService)
 	apiKeyHandler := handlers.NewAPIKeyHandler(st)
+	publishRevocation := func(keyID string) {
+		if err := revocations.Publish(context.Background(), keyID); err != nil {
+			log.Printf("Failed to publish API key revocation: %v", err)
+		}
+	}
+	apiKeyHandler.SetOnRevoke(publishRevocation)
+	slaHandler := handlers.NewSLAHandler(st)
+	alertRuleHandler := handlers.NewAlertRuleHandler(st)
+	sessionWebhookHandler := handlers.NewSessionWebhookHandler(st)
+	watchlistHandler := handlers.NewWatchlistHandler(st)
+	configHandler := handlers.NewConfigHandler(st)
+	notificationSettingsHandler := handlers.NewNotificationSettingsHandler(st)
+	inboxHandler := handlers.NewInboxHandler(st, notificationInbox)
+	statsHandler := handlers.NewStatsHandler(st, healthScorer)
+	overviewHandler := handlers.NewOverviewHandler(st)
+	clientCertHandler := handlers.NewClientCertificateHandler(st)
+	enrollmentHandler := handlers.NewEnrollmentHandler(st)
+	policyHandler := handlers.NewPolicyHandler(st)
+	streamHandler := handlers.NewStreamHandler(st, agentEvents)
+	realtimeHandler := handlers.NewRealtimeHandler(st, realtimeHub)
+	realtimeHandler.SetAllowedOrigins(cfg.CORSAllowedOrigins)
+	usageHandler := handlers.NewUsageHandler(st)
+	adminHandler := handlers.NewAdminHandler(st)
+	adminHandler.SetOnRevoke(publishRevocation)
+	signingHandler := handlers.NewSigningHandler(st, signingSecrets)
+	orgHandler := handlers.NewOrgHandler(st)
+	bootstrapHandler := handlers.NewBootstrapHandler(st)
+	for _, h := range []interface{ SetLegacyListKeys(bool) }{
+		agentHandler, apiKeyHandler, slaHandler, alertRuleHandler, clientCertHandler, enrollmentHandler, usageHandler, orgHandler,
+	} {
+		h.SetLegacyListKeys(cfg.LegacyListKeys)
+	}
 
 	// Setup router
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
+	r.Use(middleware.RequestID)
+	r.Use(newAccessLogger(cfg.AccessLog).Handler)
 	r.Use(middleware.Recoverer)
+	concurrencyLimiter := authMiddleware.NewConcurrencyLimiter(cfg.Limits.MaxInFlightRequests)
+	streamLimiter := authMiddleware.NewConcurrencyLimiter(cfg.Limits.MaxOpenStreams)
+	webhookRateLimiter := authMiddleware.NewRateLimiter(cfg.Limits.WebhookRateLimit, cfg.Limits.WebhookRateBurst)
 
-	// CORS
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   cfg.CORSAllowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
+	r.Use(authMiddleware.SecurityHeaders(authMiddleware.SecurityHeadersConfig{
+		HSTSMaxAge:            cfg.Security.HSTSMaxAge,
+		ContentSecurityPolicy: cfg.Security.ContentSecurityPolicy,
+		FrameOptions:          cfg.Security.FrameOptions,
 	}))
+	requests, streams := limitedRouters(r, concurrencyLimiter, streamLimiter)
+
+	// CORS policies are applied per route group
+	apiCORSPolicy := authMiddleware.APICORSPolicy(cfg.CORSAllowedOrigins)
+	apiCORSPolicy.CredentialedOrigins = cfg.CORS.CredentialedOrigins
+	apiCORSPolicy.MaxAge = cfg.CORS.MaxAge
+	apiCORSPolicy.ExposedHeaders = cfg.CORS.ExposedHeaders
+	apiCORS := authMiddleware.CORS(apiCORSPolicy)
+	webhookCORS := authMiddleware.CORS(authMiddleware.WebhookCORSPolicy(cfg.WebhookCORSAllowedOrigins))
 
 	// Public routes
-	r.Get("/health", healthHandler)
+	requests.Get("/health", healthHandler)
+	if cfg.MetricsEnabled {
+		requests.Handle("/metrics", metricsRegistry)
+	}
+	if cfg.AgentMetricsEnabled {
+		requests.Handle("/metrics/agents", metrics.NewAgentCollector(st))
+	}
 
 	// Auth routes (public)
-	r.Route("/api/auth", func(r chi.Router) {
+	requests.Route("/api/auth", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(authMiddleware.Timeout(cfg.Limits.APITimeout))
+		r.Use(authMiddleware.ResponseCasing)
 		r.Post("/register", authHandler.Register)
 		r.Get("/verify", authHandler.VerifyEmail)
 		r.Post("/login", authHandler.Login)
@@ -186,39 +776,283 @@ func main() {
 		r.Post("/resend-verify", authHandler.ResendVerify)
 
 		r.Group(func(r chi.Router) {
-			r.Use(authMiddleware.RequireAuth)
+			r.Use(authMW.RequireAuth)
 			r.Post("/logout", authHandler.Logout)
 			r.Get("/me", authHandler.Me)
 			r.Put("/me", authHandler.UpdateMe)
 		})
 	})
 
+	// Tenant provisioning for embedding platforms, authenticated with the bootstrap token instead of a user
+	if cfg.BootstrapToken != "" {
+		if len(cfg.BootstrapToken) < minBootstrapTokenLength {
+			log.Fatalf("BOOTSTRAP_TOKEN must be at least %d characters", minBootstrapTokenLength)
+		}
+		requests.With(authMiddleware.Timeout(cfg.Limits.APITimeout), authMiddleware.RequireBootstrapToken(cfg.BootstrapToken)).
+			Post("/api/bootstrap", bootstrapHandler.Create)
+	}
+
+	// The inbox and agent event streams and WebSockets are long-lived, so they are registered outside the API request
+	// timeout and count toward MAX_OPEN_STREAMS instead of the in-flight requests
+	streams.With(apiCORS, authMW.RequireAuth).Get("/api/inbox/stream", inboxHandler.Stream)
+	streams.With(apiCORS, authMW.RequireAuth).Get("/api/agents/{agent_id}/events", streamHandler.AgentEvents)
+	streams.With(authMiddleware.WebSocketCredential, authMW.RequireAuth).Get("/ws", realtimeHandler.ServeWS)
+
 	// Protected API routes (JWT only)
-	r.Route("/api", func(r chi.Router) {
-		r.Use(authMiddleware.RequireAuth)
+	requests.Route("/api", func(r chi.Router) {
+		r.Use(apiCORS)
+		r.Use(authMiddleware.Timeout(cfg.Limits.APITimeout))
+		r.Use(authMW.RequireAuth)
+		r.Use(authMiddleware.ResponseCasing)
+
+		// Viewers only read: routes creating credentials or changing agents need a member or admin
+		writers := authMiddleware.RequireRole(authMiddleware.RoleAdmin, authMiddleware.RoleMember)
+
+		// Agent kinds and other taxonomies shared by dashboards
+		r.Get("/meta", handlers.NewMetaHandler(cfg.AgentDefaultKind).Get)
 
 		// API Key management
 		r.Route("/apikeys", func(r chi.Router) {
 			r.Get("/", apiKeyHandler.List)
-			r.Post("/", apiKeyHandler.Create)
+			r.With(writers).Post("/", apiKeyHandler.Create)
 			r.Delete("/{id}", apiKeyHandler.Revoke)
 		})
 
+		// Enrollment tokens that new agents exchange for agent tokens
+		r.Route("/enrollment-tokens", func(r chi.Router) {
+			r.Get("/", enrollmentHandler.List)
+			r.With(writers).Post("/", enrollmentHandler.Create)
+			r.With(writers).Delete("/{id}", enrollmentHandler.Delete)
+		})
+
+		// Client certificates for the mTLS webhook listener
+		r.Route("/client-certificates", func(r chi.Router) {
+			r.Get("/", clientCertHandler.List)
+			r.With(writers).Post("/", clientCertHandler.Create)
+			r.With(writers).Delete("/{id}", clientCertHandler.Delete)
+		})
+
+		// SLA management
+		r.Route("/slas", func(r chi.Router) {
+			r.Get("/", slaHandler.List)
+			r.With(writers).Post("/", slaHandler.Create)
+			r.Get("/{id}", slaHandler.Get)
+			r.With(writers).Put("/{id}", slaHandler.Update)
+			r.With(writers).Delete("/{id}", slaHandler.Delete)
+			r.Get("/{id}/breaches", slaHandler.ListBreaches)
+		})
+
+		// Alert rules on statuses, long-running sessions and silent agents
+		r.Route("/alert-rules", func(r chi.Router) {
+			r.Get("/", alertRuleHandler.List)
+			r.With(writers).Post("/", alertRuleHandler.Create)
+			r.Get("/{id}", alertRuleHandler.Get)
+			r.With(writers).Put("/{id}", alertRuleHandler.Update)
+			r.With(writers).Delete("/{id}", alertRuleHandler.Delete)
+		})
+
+		// Automation callbacks for ended sessions
+		r.Route("/session-webhooks", func(r chi.Router) {
+			r.Get("/", sessionWebhookHandler.List)
+			r.With(writers).Post("/", sessionWebhookHandler.Create)
+			r.Get("/{id}", sessionWebhookHandler.Get)
+			r.With(writers).Put("/{id}", sessionWebhookHandler.Update)
+			r.With(writers).Delete("/{id}", sessionWebhookHandler.Delete)
+			r.With(writers).Post("/{id}/rotate-secret", sessionWebhookHandler.RotateSecret)
+		})
+
+		// Starred agents and watched sessions
+		r.Route("/watchlist", func(r chi.Router) {
+			r.Get("/", watchlistHandler.List)
+			r.Put("/agents/{agent_id}", watchlistHandler.StarAgent)
+			r.Delete("/agents/{agent_id}", watchlistHandler.UnstarAgent)
+			r.Put("/agents/{agent_id}/sessions/{session_topic}", watchlistHandler.WatchSession)
+			r.Delete("/agents/{agent_id}/sessions/{session_topic}", watchlistHandler.UnwatchSession)
+		})
+
+		// A user's monitoring configuration as one document, for keeping it in version control
+		r.Get("/config/export", configHandler.Export)
+		r.With(writers).Put("/config/export", configHandler.Import)
+
+		// Each user's own notification receiver and triggers
+		r.Route("/notifications/settings", func(r chi.Router) {
+			r.Get("/", notificationSettingsHandler.Get)
+			r.Put("/", notificationSettingsHandler.Update)
+			r.Delete("/", notificationSettingsHandler.Delete)
+		})
+
+		// In-app notification inbox
+		r.Route("/inbox", func(r chi.Router) {
+			r.Get("/", inboxHandler.List)
+			r.Post("/read", inboxHandler.MarkAllRead)
+			r.Post("/{id}/read", inboxHandler.MarkRead)
+		})
+
+		// Deployment-wide notification policy: members read it, admins change it
+		r.Get("/notification-policy", policyHandler.Get)
+		r.With(authMiddleware.RequireAdmin).Put("/notification-policy", policyHandler.Update)
+
+		// Daily usage export; admins can export every user's usage
+		r.Get("/usage", usageHandler.List)
+
+		// Cross-tenant views for support; only admins may call them and every request is audited
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(authMiddleware.RequireAdmin, adminHandler.Audit)
+			r.Get("/usage", usageHandler.ListAll)
+			r.Get("/search", adminHandler.Search)
+			r.Get("/metrics", adminHandler.Metrics)
+			r.Get("/tenants/{user_id}", adminHandler.GetTenant)
+			r.Get("/tenants/{user_id}/agents/{agent_id}/sessions", adminHandler.ListTenantSessions)
+			r.Get("/audit", adminHandler.ListAuditEvents)
+
+			// User management
+			r.Get("/users", adminHandler.ListUsers)
+			r.Delete("/users/{user_id}", adminHandler.DeleteUser)
+			r.Put("/users/{user_id}/role", adminHandler.UpdateUserRole)
+			r.Post("/users/{user_id}/disable", adminHandler.DisableUser)
+			r.Post("/users/{user_id}/enable", adminHandler.EnableUser)
+			r.Post("/users/{user_id}/verify", adminHandler.VerifyUser)
+			r.Post("/users/{user_id}/reset-password", adminHandler.ResetUserPassword)
+
+			// Secret signing outbound notifications
+			r.Get("/signing-secret", signingHandler.Get)
+			r.Post("/signing-secret/rotate", signingHandler.Rotate)
+			r.Delete("/signing-secret", signingHandler.Disable)
+
+			if statusArchiver != nil {
+				archiveHandler := handlers.NewArchiveHandler(statusArchiver)
+				r.Get("/archive", archiveHandler.ListManifests)
+				r.Get("/archive/{day}/statuses", archiveHandler.ListStatuses)
+				r.Post("/archive/{day}/restore", archiveHandler.Restore)
+			}
+
+			// Fault injection for resilience testing
+			if chaosInjector != nil {
+				chaosHandler := handlers.NewChaosHandler(chaosInjector)
+				r.Get("/chaos", chaosHandler.Get)
+				r.Put("/chaos", chaosHandler.Set)
+				r.Delete("/chaos", chaosHandler.Clear)
+			}
+		})
+
+		// Fleet health scores
+		r.Get("/stats", statsHandler.Get)
+
+		// Everything the dashboard home page shows, in one request
+		r.Get("/overview", overviewHandler.Get)
+
+		// Live board of running sessions
+		r.Get("/running", agentHandler.ListRunning)
+
+		// Soft-deleted agents, restorable until DELETED_AGENT_RETENTION has passed
+		r.Get("/deleted-agents", agentHandler.ListDeletedAgents)
+
+		// Organizations whose members share agents
+		r.Route("/orgs", func(r chi.Router) {
+			r.Get("/", orgHandler.List)
+			r.With(writers).Post("/", orgHandler.Create)
+			r.Get("/{org_id}", orgHandler.Get)
+			r.Delete("/{org_id}", orgHandler.Delete)
+			r.Get("/{org_id}/members", orgHandler.ListMembers)
+			r.Put("/{org_id}/members/{user_id}", orgHandler.UpdateMember)
+			r.Delete("/{org_id}/members/{user_id}", orgHandler.RemoveMember)
+			r.Get("/{org_id}/invitations", orgHandler.ListInvitations)
+			r.Post("/{org_id}/invitations", orgHandler.CreateInvitation)
+			r.Delete("/{org_id}/invitations/{invitation_id}", orgHandler.DeleteInvitation)
+		})
+		r.Post("/invitations/accept", orgHandler.AcceptInvitation)
+
 		r.Route("/agents", func(r chi.Router) {
 			r.Get("/", agentHandler.ListAgents)
 			r.Get("/{agent_id}", agentHandler.GetAgent)
+			r.With(writers).Delete("/{agent_id}", agentHandler.DeleteAgent)
+			r.With(writers).Post("/{agent_id}/restore", agentHandler.RestoreAgent)
+			r.With(writers).Put("/{agent_id}/sampling", agentHandler.UpdateSampling)
+			r.With(writers).Put("/{agent_id}/org", agentHandler.ShareAgent)
+			r.Get("/{agent_id}/config", agentHandler.GetConfig)
+			r.With(writers).Put("/{agent_id}/config", agentHandler.UpdateConfig)
 			r.Get("/{agent_id}/sessions", agentHandler.ListSessions)
 			r.Get("/{agent_id}/sessions/{session_topic}", agentHandler.GetSession)
+			r.Get("/{agent_id}/sessions/{session_topic}/runs", agentHandler.ListSessionRuns)
+			r.Get("/{agent_id}/sessions/{session_topic}/timeline", agentHandler.GetSessionTimeline)
+			r.With(writers).Post("/{agent_id}/sessions/{session_topic}/cancel", agentHandler.CancelSession)
+			r.With(writers).Post("/{agent_id}/sessions/{session_topic}/statuses/{status_id}/annotations", agentHandler.CreateAnnotation)
 			r.Get("/{agent_id}/status", agentHandler.GetAgentStatus)
+			r.Get("/{agent_id}/tasks", agentHandler.ListTasks)
+			r.Get("/{agent_id}/rollups", agentHandler.ListRollups)
 		})
 	})
 
 	// Webhook requires authentication (supports both JWT and API Key)
-	r.Route("/webhook", func(r chi.Router) {
-		r.Use(authMiddleware.RequireAuthOrAPIKey)
-		r.Post("/status", webhookHandler.ServeHTTP)
+	requests.Route("/webhook", func(r chi.Router) {
+		r.Use(webhookCORS)
+		r.Use(authMiddleware.Timeout(cfg.Limits.WebhookTimeout))
+
+		// webhookAuth authenticates callers, then rate limits and checks signatures per caller
+		webhookAuth := func(r chi.Router, authenticate func(http.Handler) http.Handler) {
+			r.Use(authenticate)
+			r.Use(authMiddleware.ResponseCasing)
+			r.Use(webhookRateLimiter.Handler)
+			if cfg.WebhookSigning.Secret != "" {
+				r.Use(authMiddleware.NewSignatureVerifier(cfg.WebhookSigning.Secret, cfg.WebhookSigning.Tolerance, st).Handler)
+			}
+		}
+
+		// New agents exchange an enrollment token for their agent token on the first report
+		r.Group(func(r chi.Router) {
+			webhookAuth(r, authMW.RequireEnrollmentToken)
+			r.Use(authMiddleware.RequireRole(authMiddleware.RoleAdmin, authMiddleware.RoleMember))
+			r.Post("/enroll", webhookHandler.ServeEnroll)
+		})
+
+		r.Group(func(r chi.Router) {
+			webhookAuth(r, authMW.RequireAuthOrAPIKey)
+			webhookReportRoutes(r, webhookHandler)
+		})
 	})
 
+	// Optional mTLS listener authenticating webhook ingestion by client certificate
+	var mtlsSrv *http.Server
+	if cfg.MTLS.Port != "" {
+		tlsConfig, err := newMTLSConfig(cfg.MTLS)
+		if err != nil {
+			log.Fatalf("Failed to configure mTLS listener: %v", err)
+		}
+
+		mr := chi.NewRouter()
+		mr.Use(middleware.RequestID)
+		mr.Use(newAccessLogger(cfg.AccessLog).Handler)
+		mr.Use(middleware.Recoverer)
+		mr.Use(concurrencyLimiter.Handler)
+
+		mr.Route("/webhook", func(r chi.Router) {
+			r.Use(authMiddleware.Timeout(cfg.Limits.WebhookTimeout))
+			r.Use(authMW.RequireClientCertificate)
+			r.Use(authMiddleware.ResponseCasing)
+			r.Use(webhookRateLimiter.Handler)
+			if cfg.WebhookSigning.Secret != "" {
+				r.Use(authMiddleware.NewSignatureVerifier(cfg.WebhookSigning.Secret, cfg.WebhookSigning.Tolerance, st).Handler)
+			}
+			webhookReportRoutes(r, webhookHandler)
+		})
+
+		mtlsSrv = &http.Server{
+			Addr:      ":" + cfg.MTLS.Port,
+			Handler:   mr,
+			TLSConfig: tlsConfig,
+		}
+	}
+
+	// Dashboard SPA (optional, served under / with client-side route fallback)
+	if cfg.UI.Enabled {
+		if uiFS, ok := loadUI(cfg.UI); ok {
+			requests.Handle("/*", web.NewHandler(uiFS, cfg.UI.ContentSecurityPolicy))
+			log.Println("Serving dashboard UI")
+		} else {
+			log.Println("Warning: UI_ENABLED is set but this binary has no embedded dashboard and UI_DIR is empty")
+		}
+	}
+
 	// Start background goroutine for session expiration check
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -230,13 +1064,186 @@ func main() {
 		for {
 			select {
 			case <-ticker.C:
-				st.CheckExpiredSessions()
+				expired := st.CheckExpiredSessions()
+				notificationInbox.SessionsExpired(expired)
+				sessionHooks.SessionsExpired(expired)
+				sessionCloser.SessionsExpired(expired)
+				notificationInbox.CheckOffline()
+				presenceMonitor.Check()
 			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
+	// Start background goroutine mirroring writes to the secondary store
+	replicationDone := make(chan struct{})
+	if replicator != nil {
+		go func() {
+			defer close(replicationDone)
+			replicator.Run(ctx)
+		}()
+	} else {
+		close(replicationDone)
+	}
+
+	// Start background goroutine receiving revocations from other replicas
+	if revocationListener != nil {
+		go revocationListener.Run(ctx)
+	}
+
+	// Start background goroutines exchanging change hints with other replicas
+	if changeFeed != nil {
+		go changeFeed.Run(ctx)
+	}
+
+	// Start background goroutine processing status reports deferred past the webhook latency budget
+	// It stops after the HTTP servers, so no report is queued once it drained the queue.
+	deferredCtx, cancelDeferred := context.WithCancel(context.Background())
+	deferredDone := make(chan struct{})
+	go func() {
+		defer close(deferredDone)
+		webhookHandler.RunDeferred(deferredCtx)
+	}()
+
+	// Start background goroutine delivering outbox messages
+	if outboxRelay != nil {
+		go outboxRelay.Start(ctx, cfg.Outbox.Interval)
+	}
+
+	// Start background goroutine for expired record cleanup, status rollups and archiving
+	if cfg.Janitor.Interval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.Janitor.Interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					purged := recordJanitor.Run()
+					// Counts are reconciled after retention purged statuses or agents, not only nightly
+					if usageMeter != nil && cfg.CountReconcileInterval > 0 && (purged[janitor.KindStatuses] > 0 || purged[janitor.KindDeletedAgents] > 0) {
+						usageMeter.Reconcile()
+					}
+					if _, err := statusRoller.Run(); err != nil {
+						log.Printf("Failed to roll up statuses: %v", err)
+					}
+					if statusArchiver != nil {
+						if _, err := statusArchiver.Run(ctx); err != nil {
+							log.Printf("Failed to archive statuses: %v", err)
+						}
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Start background goroutine for SLA evaluation
+	if cfg.SLAEvaluationInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.SLAEvaluationInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					slaEvaluator.Evaluate()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Start background goroutine for alert rule evaluation
+	if cfg.AlertEvaluationInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.AlertEvaluationInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					alertEngine.Check()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Start background goroutine for health score recalculation
+	if healthScorer != nil {
+		go func() {
+			ticker := time.NewTicker(cfg.Health.Interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					healthScorer.Recalculate()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Start background goroutine for batched API key last_used updates
+	if cfg.APIKeyCache.UsageFlushInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.APIKeyCache.UsageFlushInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					authMW.FlushAPIKeyUsage()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Start background goroutine writing metered usage
+	if usageMeter != nil {
+		go func() {
+			ticker := time.NewTicker(cfg.MeteringFlushInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					usageMeter.Flush()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// Start background goroutine recounting users' records, first at startup so counts exist for every user
+	if usageMeter != nil && cfg.CountReconcileInterval > 0 {
+		go func() {
+			usageMeter.Reconcile()
+
+			ticker := time.NewTicker(cfg.CountReconcileInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					usageMeter.Reconcile()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
 	// Start server
 	srv := &http.Server{
 		Addr:    ":" + cfg.Port,
@@ -251,6 +1258,15 @@ func main() {
 		}
 	}()
 
+	if mtlsSrv != nil {
+		go func() {
+			log.Printf("mTLS webhook listener starting on port %s", cfg.MTLS.Port)
+			if err := mtlsSrv.ListenAndServeTLS(cfg.MTLS.CertFile, cfg.MTLS.KeyFile); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("mTLS listener failed: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -264,12 +1280,36 @@ func main() {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdownCancel()
 
+	// Shutdown does not track hijacked WebSocket connections, so close them explicitly
+	realtimeHub.Close()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 	} else {
 		log.Println("HTTP server shutdown complete")
 	}
 
+	if mtlsSrv != nil {
+		if err := mtlsSrv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("mTLS listener shutdown error: %v", err)
+		}
+	}
+
+	// Finish the status reports still queued past the webhook latency budget
+	cancelDeferred()
+	select {
+	case <-deferredDone:
+	case <-time.After(5 * time.Second):
+		log.Println("Warning: Deferred status reports not processed before shutdown")
+	}
+
+	// Write API key usage recorded since the last flush
+	authMW.FlushAPIKeyUsage()
+
+	// Write usage metered since the last flush
+	if usageMeter != nil {
+		usageMeter.Flush()
+	}
+
 	// Shutdown notification manager (wait for pending notifications)
 	log.Println("Shutting down notification manager...")
 	notifyShutdownCtx, notifyCancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -281,6 +1321,20 @@ func main() {
 
 	log.Println("Notification manager shutdown complete")
 
+	// Wait for session webhook deliveries in progress
+	hooksShutdownCtx, hooksCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer hooksCancel()
+	if err := sessionHooks.Shutdown(hooksShutdownCtx); err != nil {
+		log.Printf("Warning: Session webhook deliveries still in progress at shutdown: %v", err)
+	}
+
+	// Give the secondary store the writes still queued for it
+	select {
+	case <-replicationDone:
+	case <-time.After(5 * time.Second):
+		log.Println("Warning: Replication queue not drained before shutdown")
+	}
+
 	// Close database connection
 	if closeDB != nil {
 		log.Println("Closing database connection...")
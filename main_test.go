@@ -1,8 +1,31 @@
 package main
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/config"
+	"github.com/kubeagents/kubeagents/handlers"
+	authMiddleware "github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/store"
 )
 
@@ -151,3 +174,261 @@ func TestInitJWTSecret_Persistence(t *testing.T) {
 		t.Errorf("initJWTSecret() not persistent: first = %v, second = %v", secret1, secret2)
 	}
 }
+
+func TestNewMTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fleet CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	caFile := filepath.Join(dir, "ca.crt")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	emptyFile := filepath.Join(dir, "empty.crt")
+	os.WriteFile(emptyFile, []byte("no certificates here"), 0600)
+
+	tests := []struct {
+		name           string
+		cfg            config.MTLSConfig
+		wantErr        bool
+		wantClientAuth tls.ClientAuthType
+	}{
+		{"missing server key pair", config.MTLSConfig{Port: "8443"}, true, 0},
+		{"fingerprint pinning only", config.MTLSConfig{Port: "8443", CertFile: "server.crt", KeyFile: "server.key"}, false, tls.RequireAnyClientCert},
+		{"client CA", config.MTLSConfig{Port: "8443", CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: caFile}, false, tls.RequireAndVerifyClientCert},
+		{"client CA without certificates", config.MTLSConfig{Port: "8443", CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: emptyFile}, true, 0},
+		{"missing client CA file", config.MTLSConfig{Port: "8443", CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: filepath.Join(dir, "missing.crt")}, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := newMTLSConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newMTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tlsConfig.ClientAuth != tt.wantClientAuth {
+				t.Errorf("newMTLSConfig() ClientAuth = %v, want %v", tlsConfig.ClientAuth, tt.wantClientAuth)
+			}
+		})
+	}
+}
+
+func TestRunMigrateStore_RequiresStores(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := runMigrateStore([]string{"--from", "host=old"}, &stdout, &stderr); code != 2 {
+		t.Errorf("runMigrateStore() = %d, want 2", code)
+	}
+	if !strings.Contains(stderr.String(), "--from and --to are required") {
+		t.Errorf("runMigrateStore() stderr = %q, want the missing flags reported", stderr.String())
+	}
+}
+
+func TestMigrateStore(t *testing.T) {
+	source := store.NewMemoryStore()
+	now := time.Now()
+	if err := source.CreateUser(&models.User{ID: "user-1", Email: "alice@example.com", PasswordHash: "hash", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := source.SetConfig(jwtSecretConfigKey, "secret"); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	destination := store.NewMemoryStore()
+
+	var stdout, stderr bytes.Buffer
+	if code := migrateStore(source, destination, false, &stdout, &stderr); code != 0 {
+		t.Fatalf("migrateStore() = %d, stderr = %q, want 0", code, stderr.String())
+	}
+	for _, line := range []string{"Copied 1 users\n", "Copied 1 config\n", "Verified users sha256:"} {
+		if !strings.Contains(stdout.String(), line) {
+			t.Errorf("migrateStore() stdout = %q, want it to contain %q", stdout.String(), line)
+		}
+	}
+	if secret, _ := destination.GetConfig(jwtSecretConfigKey); secret != "secret" {
+		t.Errorf("destination JWT secret = %q, want %q", secret, "secret")
+	}
+
+	// Copying again is refused, while verification alone passes
+	if code := migrateStore(source, destination, false, &stdout, &stderr); code != 1 {
+		t.Errorf("migrateStore() into a filled store = %d, want 1", code)
+	}
+	if code := migrateStore(source, destination, true, &stdout, &stderr); code != 0 {
+		t.Errorf("migrateStore() verify only = %d, want 0", code)
+	}
+}
+
+func TestFsck(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now()
+	if err := st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Name: "Builder", Registered: now, LastSeen: now}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	if err := st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "build-1", Created: now, LastUpdated: now, TTLMinutes: 30, Expired: true}); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := fsck(st, false, &stdout, &stderr); code != 1 {
+		t.Errorf("fsck() = %d, want 1", code)
+	}
+	want := "expired_flag agent-1/build-1: expired session has no expiry time\n" +
+		"ownerless_agent agent-1: agent has no owner\n" +
+		"2 issues found, 0 repaired\n"
+	if stdout.String() != want {
+		t.Errorf("fsck() stdout = %q, want %q", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	fsck(st, true, &stdout, &stderr)
+	if !strings.Contains(stdout.String(), "expired session has no expiry time (repaired)\n") || !strings.HasSuffix(stdout.String(), "2 issues found, 1 repaired\n") {
+		t.Errorf("fsck() repair stdout = %q, want the expired flag repaired", stdout.String())
+	}
+
+	if err := st.CreateUser(&models.User{ID: "user-1", Email: "alice@example.com", PasswordHash: "hash", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	agent, _ := st.GetAgent("agent-1")
+	agent.UserID = "user-1"
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	stdout.Reset()
+	if code := fsck(st, false, &stdout, &stderr); code != 0 || stdout.String() != "0 issues found, 0 repaired\n" {
+		t.Errorf("fsck() on a consistent store = %d, %q, want 0 with no issues", code, stdout.String())
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	st := store.NewMemoryStore()
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	if err := st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Name: "Builder", Registered: start, LastSeen: start}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	for topic, ttl := range map[string]int{"short": 10, "long": 120} {
+		if err := st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: topic, Created: start, LastUpdated: start, TTLMinutes: ttl}); err != nil {
+			t.Fatalf("CreateOrUpdateSession() error = %v", err)
+		}
+	}
+	if err := st.CreateSLA(&models.SLA{ID: "sla-1", UserID: "user-1", Name: "Builds", MaxDurationMinutes: 60, CreatedAt: start, UpdatedAt: start}); err != nil {
+		t.Fatalf("CreateSLA() error = %v", err)
+	}
+	for i, detected := range []time.Time{start.Add(-48 * time.Hour), start} {
+		breach := &models.SLABreach{ID: fmt.Sprintf("breach-%d", i), SLAID: "sla-1", UserID: "user-1", AgentID: "agent-1", Kind: models.SLABreachDuration, Subject: fmt.Sprintf("run-%d", i), DetectedAt: detected}
+		if err := st.CreateSLABreach(breach); err != nil {
+			t.Fatalf("CreateSLABreach() error = %v", err)
+		}
+	}
+
+	var stdout bytes.Buffer
+	if err := dryRun(st, clock.NewFake(start.Add(time.Hour)), 24*time.Hour, &stdout); err != nil {
+		t.Fatalf("dryRun() error = %v", err)
+	}
+	want := "expire agent-1/short: expires at 2026-03-01T09:10:00Z\n" +
+		"At 2026-03-01T10:00:00Z: 1 sessions would expire, 1 SLA breaches would be purged\n"
+	if stdout.String() != want {
+		t.Errorf("dryRun() stdout = %q, want %q", stdout.String(), want)
+	}
+	if session, _ := st.GetSession("agent-1", "short"); session.Expired {
+		t.Error("dryRun() expired the session, want the store unchanged")
+	}
+}
+
+func TestRunSelftest_MemoryStore(t *testing.T) {
+	t.Setenv("DB_NAME", "")
+	t.Setenv("ENCRYPTION_MASTER_KEY", "")
+
+	var stdout, stderr bytes.Buffer
+	if code := runSelftest(nil, &stdout, &stderr); code != 0 {
+		t.Fatalf("runSelftest() = %d, stderr = %q, want 0", code, stderr.String())
+	}
+	if !strings.HasSuffix(stdout.String(), "selftest passed\n") {
+		t.Errorf("runSelftest() stdout = %q, want it to end with the pass line", stdout.String())
+	}
+}
+
+func TestLimitedRouters_OpenStreamsDoNotBlockRequests(t *testing.T) {
+	r := chi.NewRouter()
+	requests, streams := limitedRouters(r, authMiddleware.NewConcurrencyLimiter(1), authMiddleware.NewConcurrencyLimiter(1))
+
+	opened, closed := make(chan struct{}), make(chan struct{})
+	streams.Get("/api/inbox/stream", func(w http.ResponseWriter, r *http.Request) {
+		close(opened)
+		<-closed
+	})
+	requests.Get("/api/agents", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/inbox/stream", nil))
+	}()
+	<-opened
+	defer func() {
+		close(closed)
+		<-done
+	}()
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/agents", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("API request with a stream open = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/inbox/stream", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("stream beyond the stream limit = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestWebhookReportRoutes_RefuseViewers(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now()
+	for id, role := range map[string]string{"member": "", "viewer": models.UserRoleViewer} {
+		user := &models.User{ID: id, Email: id + "@example.com", PasswordHash: "hash", Role: role, CreatedAt: now, UpdatedAt: now}
+		if err := st.CreateUser(user); err != nil {
+			t.Fatalf("CreateUser(%s) error = %v", id, err)
+		}
+	}
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+
+	r := chi.NewRouter()
+	r.Route("/webhook", func(r chi.Router) {
+		r.Use(authMiddleware.NewAuthMiddlewareWithStore(jwtService, st).RequireAuthOrAPIKey)
+		webhookReportRoutes(r, handlers.NewWebhookHandlerWithNotifier(st, nil))
+	})
+
+	tests := []struct {
+		userID  string
+		agentID string
+		want    int
+	}{
+		{"member", "agent-001", http.StatusOK},
+		{"viewer", "agent-002", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		token, _ := jwtService.GenerateAccessToken(tt.userID, tt.userID+"@example.com")
+		body := fmt.Sprintf(`{"agent_id":%q,"session_topic":"task-001","status":"running","timestamp":%q}`, tt.agentID, now.Format(time.RFC3339))
+		req := httptest.NewRequest("POST", "/webhook/status", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("report of a %s status = %v, want %v: %s", tt.userID, rr.Code, tt.want, rr.Body.String())
+		}
+	}
+	if _, err := st.GetAgent("agent-002"); err == nil {
+		t.Error("GetAgent() after a viewer's report, want the agent not registered")
+	}
+}
@@ -0,0 +1,150 @@
+// Package metering counts per-user usage and records in memory and writes them to the daily usage records and
+// the tenant counts in batches
+package metering
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Meter collects usage and the records users create until the next Flush
+// Counting in memory keeps ingestion from writing a usage row per report; usage of a replica that
+// stops without flushing is lost, and its record counts are corrected by the next Reconcile.
+type Meter struct {
+	mu      sync.Mutex
+	store   store.Store
+	pending map[string]*models.UsageRecord  // user_id|day -> counts not yet written
+	counts  map[string]*models.TenantCounts // user_id -> records created since the last write
+	clock   clock.Clock
+}
+
+// NewMeter creates a meter writing to st
+func NewMeter(st store.Store) *Meter {
+	return &Meter{
+		store:   st,
+		pending: make(map[string]*models.UsageRecord),
+		counts:  make(map[string]*models.TenantCounts),
+		clock:   clock.Real,
+	}
+}
+
+// SetClock replaces the clock that decides which day usage counts toward
+func (m *Meter) SetClock(c clock.Clock) {
+	m.clock = c
+}
+
+// RecordReport counts an ingested status report whose stored fields took storedBytes
+// Reports that were not stored, such as sampled-out heartbeats, are recorded with 0 bytes.
+func (m *Meter) RecordReport(userID string, storedBytes int64) {
+	m.add(userID, func(r *models.UsageRecord) {
+		r.StatusReports++
+		r.StorageBytes += storedBytes
+	})
+}
+
+// RecordNotifications counts notification messages queued for a user's receivers
+func (m *Meter) RecordNotifications(userID string, count int) {
+	if count <= 0 {
+		return
+	}
+	m.add(userID, func(r *models.UsageRecord) {
+		r.NotificationsSent += int64(count)
+	})
+}
+
+// RecordStored counts a stored status, and the agent and session it created when the report was their first
+func (m *Meter) RecordStored(userID string, newAgent, newSession bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts, ok := m.counts[userID]
+	if !ok {
+		counts = &models.TenantCounts{UserID: userID}
+		m.counts[userID] = counts
+	}
+	counts.Statuses++
+	if newAgent {
+		counts.Agents++
+	}
+	if newSession {
+		counts.Sessions++
+	}
+}
+
+// add applies a change to the pending counts of the user's current day
+func (m *Meter) add(userID string, change func(r *models.UsageRecord)) {
+	day := models.UsageDay(m.clock.Now())
+	key := userID + "|" + day.Format(time.DateOnly)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.pending[key]
+	if !ok {
+		record = &models.UsageRecord{UserID: userID, Day: day}
+		m.pending[key] = record
+	}
+	change(record)
+}
+
+// Flush writes the pending counts to the store
+// Counts that fail to write are kept for the next flush, unless their user was deleted.
+func (m *Meter) Flush() {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = make(map[string]*models.UsageRecord)
+	counts := m.counts
+	m.counts = make(map[string]*models.TenantCounts)
+	m.mu.Unlock()
+
+	for userID, delta := range counts {
+		err := m.store.AddTenantCounts(delta)
+		if err == nil || errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		log.Printf("Failed to write record counts of user %s: %v", userID, err)
+		m.mu.Lock()
+		if current, ok := m.counts[userID]; ok {
+			current.Agents += delta.Agents
+			current.Sessions += delta.Sessions
+			current.Statuses += delta.Statuses
+		} else {
+			m.counts[userID] = delta
+		}
+		m.mu.Unlock()
+	}
+
+	for key, record := range pending {
+		err := m.store.AddUsage(record)
+		if err == nil || errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		log.Printf("Failed to write usage of user %s: %v", record.UserID, err)
+		m.mu.Lock()
+		if current, ok := m.pending[key]; ok {
+			current.StatusReports += record.StatusReports
+			current.StorageBytes += record.StorageBytes
+			current.NotificationsSent += record.NotificationsSent
+		} else {
+			m.pending[key] = record
+		}
+		m.mu.Unlock()
+	}
+}
+
+// Reconcile flushes the pending counts and then replaces every user's record counts with a count of their stored
+// records, so deletions, retention purges and other replicas' lost counts are reflected
+// Records created while it runs may be counted twice until the next Reconcile.
+func (m *Meter) Reconcile() {
+	m.Flush()
+	reconciled, err := m.store.ReconcileTenantCounts(m.clock.Now())
+	if err != nil {
+		log.Printf("Failed to reconcile record counts: %v", err)
+		return
+	}
+	log.Printf("Reconciled the record counts of %d users", reconciled)
+}
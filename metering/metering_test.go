@@ -0,0 +1,91 @@
+package metering
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestMeter_FlushesDailyUsage(t *testing.T) {
+	start := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	st := store.NewMemoryStore()
+	now := time.Now()
+	if err := st.CreateUser(&models.User{ID: "user-1", Email: "alice@example.com", PasswordHash: "x", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	m := NewMeter(st)
+	m.SetClock(fake)
+	m.RecordReport("user-1", 120)
+	m.RecordReport("user-1", 0)
+	m.RecordNotifications("user-1", 2)
+	fake.Advance(2 * time.Hour)
+	m.RecordReport("user-1", 30)
+	m.RecordReport("deleted-user", 10)
+	m.Flush()
+
+	records, err := st.ListUsage("", start, start.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("ListUsage() error = %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("ListUsage() = %d records, want one per day", len(records))
+	}
+	if r := records[0]; r.StatusReports != 2 || r.StorageBytes != 120 || r.NotificationsSent != 2 {
+		t.Errorf("first day = %+v, want 2 reports, 120 bytes and 2 notifications", r)
+	}
+	if r := records[1]; r.StatusReports != 1 || r.StorageBytes != 30 {
+		t.Errorf("second day = %+v, want 1 report of 30 bytes", r)
+	}
+
+	// Flushed counts are not written twice, and usage of deleted users is dropped
+	m.Flush()
+	if records, _ := st.ListUsage("user-1", start, start.AddDate(0, 0, 1)); records[0].StatusReports != 2 {
+		t.Errorf("first day after a second flush = %+v, want it unchanged", records[0])
+	}
+	if len(m.pending) != 0 {
+		t.Errorf("pending = %d records, want none", len(m.pending))
+	}
+}
+
+func TestMeter_CountsRecordsUntilReconciled(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	st := store.NewMemoryStore()
+	if err := st.CreateUser(&models.User{ID: "user-1", Email: "alice@example.com", PasswordHash: "x", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	m := NewMeter(st)
+	m.SetClock(clock.NewFake(now))
+	m.RecordStored("user-1", true, true)
+	m.RecordStored("user-1", false, false)
+	m.RecordStored("user-1", false, true)
+	m.RecordStored("deleted-user", true, true)
+	m.Flush()
+
+	counts, err := st.GetTenantCounts("user-1")
+	if err != nil {
+		t.Fatalf("GetTenantCounts() error = %v", err)
+	}
+	if counts.Agents != 1 || counts.Sessions != 2 || counts.Statuses != 3 {
+		t.Errorf("counts = %+v, want 1 agent, 2 sessions and 3 statuses", counts)
+	}
+	if len(m.counts) != 0 {
+		t.Errorf("pending counts = %d users, want none", len(m.counts))
+	}
+
+	// Nothing was stored, so reconciling zeroes the counts
+	m.RecordStored("user-1", false, false)
+	m.Reconcile()
+	counts, err = st.GetTenantCounts("user-1")
+	if err != nil {
+		t.Fatalf("GetTenantCounts() error = %v", err)
+	}
+	if counts.Agents != 0 || counts.Sessions != 0 || counts.Statuses != 0 || !counts.ReconciledAt.Equal(now) {
+		t.Errorf("counts after reconciling = %+v, want zeros reconciled at %v", counts, now)
+	}
+}
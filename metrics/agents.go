@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// AgentCollector serves agent outcome metrics in the OpenMetrics text format
+// Values are computed from the store on every scrape, so they survive restarts
+// and agree with the API.
+type AgentCollector struct {
+	store store.Store
+}
+
+// NewAgentCollector creates a collector reading from the store
+func NewAgentCollector(st store.Store) *AgentCollector {
+	return &AgentCollector{store: st}
+}
+
+// agentSample holds one agent's metric values
+type agentSample struct {
+	labels          string
+	succeeded       int
+	failed          int
+	durationSeconds float64
+	activeSessions  int
+	lastSeen        float64
+}
+
+// ServeHTTP writes the metrics of every agent
+func (c *AgentCollector) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	samples := c.collect()
+
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+
+	writeFamily(w, "kubeagents_agent_runs", "counter", "Finished session runs by result.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "kubeagents_agent_runs_total{%s,result=\"success\"} %d\n", s.labels, s.succeeded)
+		fmt.Fprintf(w, "kubeagents_agent_runs_total{%s,result=\"failed\"} %d\n", s.labels, s.failed)
+	}
+
+	writeFamily(w, "kubeagents_agent_run_duration_seconds", "summary", "Duration of finished session runs.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "kubeagents_agent_run_duration_seconds_count{%s} %d\n", s.labels, s.succeeded+s.failed)
+		fmt.Fprintf(w, "kubeagents_agent_run_duration_seconds_sum{%s} %g\n", s.labels, s.durationSeconds)
+	}
+
+	writeFamily(w, "kubeagents_agent_active_sessions", "gauge", "Sessions that have not expired.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "kubeagents_agent_active_sessions{%s} %d\n", s.labels, s.activeSessions)
+	}
+
+	writeFamily(w, "kubeagents_agent_last_seen_timestamp_seconds", "gauge", "Unix time of the agent's latest report.")
+	for _, s := range samples {
+		fmt.Fprintf(w, "kubeagents_agent_last_seen_timestamp_seconds{%s} %g\n", s.labels, s.lastSeen)
+	}
+
+	fmt.Fprint(w, "# EOF\n")
+}
+
+// collect gathers the samples of every agent, sorted by agent ID
+func (c *AgentCollector) collect() []*agentSample {
+	agents := c.store.ListAgents()
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].AgentID < agents[j].AgentID
+	})
+
+	samples := make([]*agentSample, 0, len(agents))
+	for _, agent := range agents {
+		sample := &agentSample{
+			labels: fmt.Sprintf(`agent_id="%s",user_id="%s"`,
+				labelEscaper.Replace(agent.AgentID), labelEscaper.Replace(agent.UserID)),
+			lastSeen: float64(agent.LastSeen.UnixMilli()) / 1000,
+		}
+
+		for _, session := range c.store.ListSessions(agent.AgentID, true) {
+			if !session.Expired {
+				sample.activeSessions++
+			}
+
+			history, err := c.store.GetStatusHistory(agent.AgentID, session.SessionTopic)
+			if err != nil {
+				continue
+			}
+			for _, run := range internal.BuildRuns(history) {
+				if run.Finished == nil {
+					continue
+				}
+				if run.Result == "failed" {
+					sample.failed++
+				} else {
+					sample.succeeded++
+				}
+				sample.durationSeconds += run.DurationSeconds
+			}
+		}
+
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// writeFamily writes the metadata of a metric family
+func writeFamily(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+}
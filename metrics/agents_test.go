@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestAgentCollector_ServeHTTP(t *testing.T) {
+	st := store.NewMemoryStore()
+	start := time.Unix(1700000000, 0).UTC()
+
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "b-agent", UserID: "user-001", Registered: start, LastSeen: start.Add(90 * time.Second)})
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "a-agent", UserID: "user-002", Registered: start, LastSeen: start})
+
+	st.CreateOrUpdateSession(&models.Session{AgentID: "b-agent", SessionTopic: "nightly", Created: start, LastUpdated: start, Revision: 2})
+	st.CreateOrUpdateSession(&models.Session{AgentID: "b-agent", SessionTopic: "old", Created: start, LastUpdated: start, Expired: true, Revision: 1})
+	for _, status := range []*models.AgentStatus{
+		{AgentID: "b-agent", SessionTopic: "nightly", Status: "running", Timestamp: start, Revision: 1},
+		{AgentID: "b-agent", SessionTopic: "nightly", Status: "success", Timestamp: start.Add(60 * time.Second), Revision: 1},
+		{AgentID: "b-agent", SessionTopic: "nightly", Status: "running", Timestamp: start.Add(time.Hour), Revision: 2},
+		{AgentID: "b-agent", SessionTopic: "old", Status: "running", Timestamp: start, Revision: 1},
+		{AgentID: "b-agent", SessionTopic: "old", Status: "failed", Timestamp: start.Add(30 * time.Second), Revision: 1},
+	} {
+		st.AddStatus(status)
+	}
+
+	rr := httptest.NewRecorder()
+	NewAgentCollector(st).ServeHTTP(rr, httptest.NewRequest("GET", "/metrics/agents", nil))
+
+	if got := rr.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/openmetrics-text") {
+		t.Errorf("ServeHTTP() Content-Type = %q, want OpenMetrics", got)
+	}
+
+	want := strings.Join([]string{
+		"# TYPE kubeagents_agent_runs counter",
+		"# HELP kubeagents_agent_runs Finished session runs by result.",
+		`kubeagents_agent_runs_total{agent_id="a-agent",user_id="user-002",result="success"} 0`,
+		`kubeagents_agent_runs_total{agent_id="a-agent",user_id="user-002",result="failed"} 0`,
+		`kubeagents_agent_runs_total{agent_id="b-agent",user_id="user-001",result="success"} 1`,
+		`kubeagents_agent_runs_total{agent_id="b-agent",user_id="user-001",result="failed"} 1`,
+		"# TYPE kubeagents_agent_run_duration_seconds summary",
+		"# HELP kubeagents_agent_run_duration_seconds Duration of finished session runs.",
+		`kubeagents_agent_run_duration_seconds_count{agent_id="a-agent",user_id="user-002"} 0`,
+		`kubeagents_agent_run_duration_seconds_sum{agent_id="a-agent",user_id="user-002"} 0`,
+		`kubeagents_agent_run_duration_seconds_count{agent_id="b-agent",user_id="user-001"} 2`,
+		`kubeagents_agent_run_duration_seconds_sum{agent_id="b-agent",user_id="user-001"} 90`,
+		"# TYPE kubeagents_agent_active_sessions gauge",
+		"# HELP kubeagents_agent_active_sessions Sessions that have not expired.",
+		`kubeagents_agent_active_sessions{agent_id="a-agent",user_id="user-002"} 0`,
+		`kubeagents_agent_active_sessions{agent_id="b-agent",user_id="user-001"} 1`,
+		"# TYPE kubeagents_agent_last_seen_timestamp_seconds gauge",
+		"# HELP kubeagents_agent_last_seen_timestamp_seconds Unix time of the agent's latest report.",
+		`kubeagents_agent_last_seen_timestamp_seconds{agent_id="a-agent",user_id="user-002"} 1.7e+09`,
+		`kubeagents_agent_last_seen_timestamp_seconds{agent_id="b-agent",user_id="user-001"} 1.70000009e+09`,
+		"# EOF",
+		"",
+	}, "\n")
+	if rr.Body.String() != want {
+		t.Errorf("ServeHTTP() body =\n%s\nwant\n%s", rr.Body.String(), want)
+	}
+}
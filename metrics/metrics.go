@@ -0,0 +1,97 @@
+// Package metrics exposes process counters in the Prometheus text exposition format
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds the counters served on the metrics endpoint
+type Registry struct {
+	mu   sync.Mutex
+	vecs []*CounterVec
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// NewCounterVec registers a counter partitioned by a single label
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	vec := &CounterVec{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: make(map[string]float64),
+	}
+	r.vecs = append(r.vecs, vec)
+	return vec
+}
+
+// ServeHTTP writes every registered counter in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	vecs := append([]*CounterVec(nil), r.vecs...)
+	r.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, vec := range vecs {
+		vec.write(w)
+	}
+}
+
+// CounterVec is a monotonically increasing counter per label value
+type CounterVec struct {
+	name   string
+	help   string
+	label  string
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Add increases the counter for a label value; negative deltas are ignored
+func (c *CounterVec) Add(labelValue string, delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[labelValue] += delta
+}
+
+// Value returns the current counter for a label value
+func (c *CounterVec) Value(labelValue string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[labelValue]
+}
+
+// write renders the counter family sorted by label value
+func (c *CounterVec) write(w http.ResponseWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	labelValues := make([]string, 0, len(c.values))
+	for labelValue := range c.values {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+
+	fmt.Fprintf(w, "# HELP %s %s\n", c.name, c.help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", c.name)
+	for _, labelValue := range labelValues {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %g\n", c.name, c.label, labelEscaper.Replace(labelValue), c.values[labelValue])
+	}
+}
+
+// labelEscaper escapes label values as required by the text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	removed := reg.NewCounterVec("kubeagents_test_total", "Test counter.", "kind")
+	removed.Add("b", 2)
+	removed.Add("a", 1)
+	removed.Add("a", 1)
+	removed.Add("a", -5)
+	removed.Add(`quo"te`, 1)
+
+	rr := httptest.NewRecorder()
+	reg.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := strings.Join([]string{
+		"# HELP kubeagents_test_total Test counter.",
+		"# TYPE kubeagents_test_total counter",
+		`kubeagents_test_total{kind="a"} 2`,
+		`kubeagents_test_total{kind="b"} 2`,
+		`kubeagents_test_total{kind="quo\"te"} 1`,
+		"",
+	}, "\n")
+	if rr.Body.String() != want {
+		t.Errorf("ServeHTTP() body =\n%s\nwant\n%s", rr.Body.String(), want)
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// accessLogContextKey is the key used to store the mutable access log entry in request context
+const accessLogContextKey contextKey = "access_log_entry"
+
+// accessLogEntry collects request identity filled in by downstream middleware
+type accessLogEntry struct {
+	userID   string
+	apiKeyID string
+}
+
+// AccessLogger emits one structured log record per request
+type AccessLogger struct {
+	logger     *slog.Logger
+	sampleRate float64
+}
+
+// NewAccessLogger creates a new access log middleware
+// sampleRate is the fraction (0-1] of successful requests to log; errors are always logged
+func NewAccessLogger(logger *slog.Logger, sampleRate float64) *AccessLogger {
+	if sampleRate <= 0 || sampleRate > 1 {
+		sampleRate = 1
+	}
+	return &AccessLogger{
+		logger:     logger,
+		sampleRate: sampleRate,
+	}
+}
+
+// Handler is the middleware function that records access logs
+func (l *AccessLogger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		ctx := context.WithValue(r.Context(), accessLogContextKey, entry)
+
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		// Sample successful requests only, failures are always recorded
+		if status < http.StatusBadRequest && l.sampleRate < 1 && rand.Float64() >= l.sampleRate {
+			return
+		}
+
+		// Route pattern is resolved by chi after routing, read it from the shared route context
+		route := ""
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote_addr", r.RemoteAddr),
+		}
+		if entry.userID != "" {
+			attrs = append(attrs, slog.String("user_id", entry.userID))
+		}
+		if entry.apiKeyID != "" {
+			attrs = append(attrs, slog.String("api_key_id", entry.apiKeyID))
+		}
+		if reqID := chimiddleware.GetReqID(r.Context()); reqID != "" {
+			attrs = append(attrs, slog.String("request_id", reqID))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		} else if status >= http.StatusBadRequest {
+			level = slog.LevelWarn
+		}
+
+		l.logger.LogAttrs(r.Context(), level, "access", attrs...)
+	})
+}
+
+// setAccessLogIdentity records the authenticated identity on the access log entry if present
+func setAccessLogIdentity(ctx context.Context, userID, apiKeyID string) {
+	entry, ok := ctx.Value(accessLogContextKey).(*accessLogEntry)
+	if !ok {
+		return
+	}
+	entry.userID = userID
+	entry.apiKeyID = apiKeyID
+}
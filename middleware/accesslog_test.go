@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kubeagents/kubeagents/auth"
+)
+
+func newTestAccessLogger(buf *bytes.Buffer, sampleRate float64) *AccessLogger {
+	return NewAccessLogger(slog.New(slog.NewJSONHandler(buf, nil)), sampleRate)
+}
+
+func TestAccessLogger_RecordsIdentityRouteAndOutcome(t *testing.T) {
+	var buf bytes.Buffer
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	authMW := NewAuthMiddlewareWithStore(jwtService, nil)
+	token, _ := jwtService.GenerateAccessToken("user-123", "test@example.com")
+
+	r := chi.NewRouter()
+	r.Use(newTestAccessLogger(&buf, 1).Handler)
+	r.With(authMW.RequireAuth).Get("/api/agents/{agent_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("hello"))
+	})
+
+	req := httptest.NewRequest("GET", "/api/agents/agent-001", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("access log is not valid JSON: %v (%s)", err, buf.String())
+	}
+
+	if record["user_id"] != "user-123" {
+		t.Errorf("user_id = %v, want user-123", record["user_id"])
+	}
+	if record["route"] != "/api/agents/{agent_id}" {
+		t.Errorf("route = %v, want /api/agents/{agent_id}", record["route"])
+	}
+	if record["status"] != float64(http.StatusOK) {
+		t.Errorf("status = %v, want 200", record["status"])
+	}
+	if record["bytes"] != float64(5) {
+		t.Errorf("bytes = %v, want 5", record["bytes"])
+	}
+	if _, ok := record["latency_ms"]; !ok {
+		t.Error("latency_ms missing from access log")
+	}
+}
+
+func TestAccessLogger_FailuresAlwaysLoggedWhenSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewAccessLogger(slog.New(slog.NewJSONHandler(&buf, nil)), 0.000001)
+
+	handler := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	for i := 0; i < 3; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/x", nil))
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("logged %d failures, want 3", lines)
+	}
+}
+
+func TestAccessLogger_SamplesSuccessfulRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewAccessLogger(slog.New(slog.NewJSONHandler(&buf, nil)), 0.000001)
+
+	handler := logger.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 100; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/x", nil))
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines > 1 {
+		t.Errorf("logged %d successful requests with near-zero sample rate", lines)
+	}
+}
+
+func TestNewAccessLogger_InvalidSampleRateDefaultsToAll(t *testing.T) {
+	for _, rate := range []float64{0, -1, 2} {
+		l := NewAccessLogger(slog.Default(), rate)
+		if l.sampleRate != 1 {
+			t.Errorf("NewAccessLogger(%v) sampleRate = %v, want 1", rate, l.sampleRate)
+		}
+	}
+}
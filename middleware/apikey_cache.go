@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/revocation"
+)
+
+// cachedAPIKey is a validated API key together with the claims of its owner
+type cachedAPIKey struct {
+	apiKey   *models.APIKey
+	claims   *auth.AccessTokenClaims
+	cachedAt time.Time
+}
+
+// apiKeyCache holds recently validated API keys keyed by key hash
+// Entries are dropped after ttl so revocations made on other replicas take effect within ttl.
+type apiKeyCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[string]*cachedAPIKey
+	clock   clock.Clock
+}
+
+func newAPIKeyCache(ttl time.Duration, c clock.Clock) *apiKeyCache {
+	return &apiKeyCache{
+		ttl:     ttl,
+		entries: make(map[string]*cachedAPIKey),
+		clock:   c,
+	}
+}
+
+// get returns a fresh cache entry for the key hash
+func (c *apiKeyCache) get(keyHash string) (*cachedAPIKey, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[keyHash]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if c.clock.Now().Sub(entry.cachedAt) >= c.ttl {
+		c.mu.Lock()
+		delete(c.entries, keyHash)
+		c.mu.Unlock()
+		return nil, false
+	}
+	return entry, true
+}
+
+// put caches a validated key
+func (c *apiKeyCache) put(keyHash string, apiKey *models.APIKey, claims *auth.AccessTokenClaims) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[keyHash] = &cachedAPIKey{
+		apiKey:   apiKey,
+		claims:   claims,
+		cachedAt: c.clock.Now(),
+	}
+}
+
+// clear drops every cached entry
+func (c *apiKeyCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*cachedAPIKey)
+}
+
+// forget drops the cached entry for a key ID
+func (c *apiKeyCache) forget(keyID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for keyHash, entry := range c.entries {
+		if entry.apiKey.ID == keyID {
+			delete(c.entries, keyHash)
+		}
+	}
+}
+
+// apiKeyUsage collects API key IDs whose last_used timestamp is pending a write
+type apiKeyUsage struct {
+	mu      sync.Mutex
+	pending map[string]struct{}
+}
+
+// record marks a key as used
+func (u *apiKeyUsage) record(keyID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.pending[keyID] = struct{}{}
+}
+
+// drain returns and clears the pending key IDs
+func (u *apiKeyUsage) drain() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	keyIDs := make([]string, 0, len(u.pending))
+	for keyID := range u.pending {
+		keyIDs = append(keyIDs, keyID)
+	}
+	u.pending = make(map[string]struct{})
+	return keyIDs
+}
+
+// SetAPIKeyCacheTTL caches validated API keys for ttl, skipping the key and user lookups on repeat calls
+// A ttl of 0 disables caching.
+func (m *AuthMiddleware) SetAPIKeyCacheTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		m.keyCache = nil
+		return
+	}
+	m.keyCache = newAPIKeyCache(ttl, m.clock)
+}
+
+// EnableBatchedKeyUsage defers last_used updates until FlushAPIKeyUsage is called
+// Each key is written once per flush no matter how many requests used it.
+func (m *AuthMiddleware) EnableBatchedKeyUsage() {
+	m.keyUsage = &apiKeyUsage{pending: make(map[string]struct{})}
+}
+
+// FlushAPIKeyUsage writes pending last_used updates to the store
+func (m *AuthMiddleware) FlushAPIKeyUsage() {
+	if m.keyUsage == nil || m.store == nil {
+		return
+	}
+	for _, keyID := range m.keyUsage.drain() {
+		if err := m.store.UpdateAPIKeyLastUsed(keyID); err != nil {
+			log.Printf("Failed to update API key last used: %v", err)
+		}
+	}
+}
+
+// ForgetAPIKey drops a key from the validation cache, e.g. after it is revoked
+// revocation.All drops every key, for when revocations may have been missed.
+func (m *AuthMiddleware) ForgetAPIKey(keyID string) {
+	if m.keyCache == nil {
+		return
+	}
+	if keyID == revocation.All {
+		m.keyCache.clear()
+		return
+	}
+	m.keyCache.forget(keyID)
+}
+
+// recordKeyUsage updates last_used now or queues it for the next flush
+func (m *AuthMiddleware) recordKeyUsage(keyID string) {
+	if m.keyUsage != nil {
+		m.keyUsage.record(keyID)
+		return
+	}
+	// Update last used timestamp (async to not block request)
+	go m.store.UpdateAPIKeyLastUsed(keyID)
+}
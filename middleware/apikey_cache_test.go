@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/revocation"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// countingStore counts the store calls made while validating API keys
+type countingStore struct {
+	*store.MemoryStore
+	keyLookups  int32
+	lastUsedSet int32
+}
+
+func (s *countingStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	atomic.AddInt32(&s.keyLookups, 1)
+	key, err := s.MemoryStore.GetAPIKeyByHash(keyHash)
+	if err != nil {
+		return nil, err
+	}
+	// Return a copy so revocation is only observed through a fresh lookup
+	copied := *key
+	return &copied, nil
+}
+
+func (s *countingStore) UpdateAPIKeyLastUsed(keyID string) error {
+	atomic.AddInt32(&s.lastUsedSet, 1)
+	return s.MemoryStore.UpdateAPIKeyLastUsed(keyID)
+}
+
+func TestAuthMiddleware_APIKeyCache(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	st := &countingStore{MemoryStore: store.NewMemoryStore()}
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: "hash"})
+
+	rawKey := "OJBwmmPSTestApiKey1234567890ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	st.CreateAPIKey(&models.APIKey{
+		ID:        "key-123",
+		UserID:    "user-123",
+		Name:      "test-key",
+		KeyHash:   HashAPIKey(rawKey),
+		KeyPrefix: rawKey[:8],
+		CreatedAt: time.Now(),
+	})
+
+	m := NewAuthMiddlewareWithStore(jwtService, st)
+	m.SetAPIKeyCacheTTL(time.Minute)
+	m.EnableBatchedKeyUsage()
+	m.SetClock(clock.NewFake(time.Now()))
+
+	call := func() int {
+		req := httptest.NewRequest("POST", "/webhook/status", nil)
+		req.Header.Set("Authorization", "Bearer "+rawKey)
+		rr := httptest.NewRecorder()
+		m.RequireAuthOrAPIKey(okHandler).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		if code := call(); code != http.StatusOK {
+			t.Fatalf("RequireAuthOrAPIKey() status = %v, want %v", code, http.StatusOK)
+		}
+	}
+	if lookups := atomic.LoadInt32(&st.keyLookups); lookups != 1 {
+		t.Errorf("RequireAuthOrAPIKey() key lookups = %d, want 1", lookups)
+	}
+	if writes := atomic.LoadInt32(&st.lastUsedSet); writes != 0 {
+		t.Errorf("RequireAuthOrAPIKey() last_used writes before flush = %d, want 0", writes)
+	}
+
+	m.FlushAPIKeyUsage()
+	if writes := atomic.LoadInt32(&st.lastUsedSet); writes != 1 {
+		t.Errorf("FlushAPIKeyUsage() last_used writes = %d, want 1", writes)
+	}
+	if key, _ := st.GetAPIKeyByID("key-123"); key.LastUsedAt == nil {
+		t.Error("FlushAPIKeyUsage() did not set last_used_at")
+	}
+
+	// A revoked key stays cached until forgotten or the TTL passes
+	st.RevokeAPIKey("key-123")
+	if code := call(); code != http.StatusOK {
+		t.Errorf("RequireAuthOrAPIKey() cached status = %v, want %v", code, http.StatusOK)
+	}
+	m.ForgetAPIKey("key-123")
+	if code := call(); code != http.StatusUnauthorized {
+		t.Errorf("RequireAuthOrAPIKey() after ForgetAPIKey status = %v, want %v", code, http.StatusUnauthorized)
+	}
+}
+
+func TestAPIKeyCache_TTL(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	cache := newAPIKeyCache(time.Minute, clk)
+
+	cache.put("hash", &models.APIKey{ID: "key-123"}, &auth.AccessTokenClaims{UserID: "user-123"})
+	if _, ok := cache.get("hash"); !ok {
+		t.Fatal("get() missed a fresh entry")
+	}
+
+	clk.Advance(time.Minute)
+	if _, ok := cache.get("hash"); ok {
+		t.Error("get() returned an entry older than the TTL")
+	}
+}
+
+func TestAuthMiddleware_ForgetAllAPIKeys(t *testing.T) {
+	m := NewAuthMiddlewareWithStore(nil, store.NewMemoryStore())
+	m.SetAPIKeyCacheTTL(time.Minute)
+	m.keyCache.put("hash-1", &models.APIKey{ID: "key-1"}, &auth.AccessTokenClaims{UserID: "user-123"})
+	m.keyCache.put("hash-2", &models.APIKey{ID: "key-2"}, &auth.AccessTokenClaims{UserID: "user-123"})
+
+	m.ForgetAPIKey("key-1")
+	if _, ok := m.keyCache.get("hash-1"); ok {
+		t.Error("ForgetAPIKey() kept the forgotten key")
+	}
+	if _, ok := m.keyCache.get("hash-2"); !ok {
+		t.Fatal("ForgetAPIKey() dropped another key")
+	}
+
+	m.ForgetAPIKey(revocation.All)
+	if _, ok := m.keyCache.get("hash-2"); ok {
+		t.Error("ForgetAPIKey(revocation.All) kept a key")
+	}
+}
@@ -1,30 +1,31 @@
 package middleware
 
 import (
-	"context"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
 	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/store"
 )
 
 type contextKey string
 
-// UserContextKey is the key used to store user claims in request context
-const UserContextKey contextKey = "user"
-
-// APIKeyContextKey is the key used to store API key ID in request context
-const APIKeyContextKey contextKey = "api_key_id"
-
 // AuthMiddleware handles JWT and API Key authentication
 type AuthMiddleware struct {
 	jwtService *auth.JWTService
 	store      store.Store
+	keyCache   *apiKeyCache
+	keyUsage   *apiKeyUsage
+	admins     map[string]bool // Lowercased emails of deployment admins
+	clock      clock.Clock
 }
 
 // NewAuthMiddlewareWithStore creates a new authentication middleware with store for API key validation
@@ -32,29 +33,69 @@ func NewAuthMiddlewareWithStore(jwtService *auth.JWTService, st store.Store) *Au
 	return &AuthMiddleware{
 		jwtService: jwtService,
 		store:      st,
+		clock:      clock.Real,
+	}
+}
+
+// SetClock replaces the clock that decides whether API keys, enrollment tokens and client certificates are
+// valid and when cached API keys go stale
+func (m *AuthMiddleware) SetClock(c clock.Clock) {
+	m.clock = c
+	if m.keyCache != nil {
+		m.keyCache.clock = c
+	}
+}
+
+// SetAdminEmails makes the users with these emails deployment admins when they sign in with a JWT
+// API keys never carry the admin role, so automation cannot change deployment-wide settings.
+func (m *AuthMiddleware) SetAdminEmails(emails []string) {
+	m.admins = make(map[string]bool, len(emails))
+	for _, email := range emails {
+		m.admins[strings.ToLower(email)] = true
+	}
+}
+
+// userRequestContext builds the request context of a JWT-authenticated user
+func (m *AuthMiddleware) userRequestContext(r *http.Request, userID, email string) *RequestContext {
+	rc := NewRequestContext(r, userID, email, m.store)
+	if m.admins[strings.ToLower(email)] {
+		rc.Role = RoleAdmin
+	}
+	return rc
+}
+
+// tokenUserContext builds the request context of a JWT-authenticated user, writing the error response and
+// returning nil when the user was disabled or removed after the token was issued
+// The user loaded here is kept in the context, so role checks later in the request do not load it again.
+func (m *AuthMiddleware) tokenUserContext(w http.ResponseWriter, r *http.Request, claims *auth.AccessTokenClaims) *RequestContext {
+	rc := m.userRequestContext(r, claims.UserID, claims.Email)
+	if m.store == nil {
+		return rc
+	}
+	user, err := rc.User()
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		respondUnauthorized(w, "invalid or expired token")
+		return nil
+	case err != nil:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":"authentication unavailable, retry later"}`))
+		return nil
+	case user.DisabledAt != nil:
+		respondUnauthorized(w, "account disabled")
+		return nil
 	}
+	return rc
 }
 
 // RequireAuth is a middleware that requires a valid JWT token (for frontend API)
+// Tokens of users disabled since they were issued are refused.
 func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			respondUnauthorized(w, "missing authorization header")
-			return
-		}
-
-		// Check Bearer prefix
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			respondUnauthorized(w, "invalid authorization format")
-			return
-		}
-
-		tokenString := parts[1]
-		if tokenString == "" {
-			respondUnauthorized(w, "missing token")
+		tokenString, ok := bearerToken(w, r)
+		if !ok {
 			return
 		}
 
@@ -65,9 +106,13 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		// Add user claims to context
-		ctx := context.WithValue(r.Context(), UserContextKey, claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		// Add the caller to context
+		setAccessLogIdentity(r.Context(), claims.UserID, "")
+		rc := m.tokenUserContext(w, r, claims)
+		if rc == nil {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithRequestContext(r.Context(), rc)))
 	})
 }
 
@@ -75,33 +120,24 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 // This is used for webhook endpoints that can be called by external tools
 func (m *AuthMiddleware) RequireAuthOrAPIKey(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			respondUnauthorized(w, "missing authorization header")
-			return
-		}
-
-		// Check Bearer prefix
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			respondUnauthorized(w, "invalid authorization format")
+		tokenString, ok := bearerToken(w, r)
+		if !ok {
 			return
 		}
 
-		tokenString := parts[1]
-		if tokenString == "" {
-			respondUnauthorized(w, "missing token")
-			return
-		}
-
-		// First try to validate as JWT token
-		claims, err := m.jwtService.ValidateAccessToken(tokenString)
-		if err == nil {
-			// JWT token is valid
-			ctx := context.WithValue(r.Context(), UserContextKey, claims)
-			next.ServeHTTP(w, r.WithContext(ctx))
-			return
+		// First try to validate as JWT token; API keys never contain dots, so skip parsing for them
+		if looksLikeJWT(tokenString) {
+			claims, err := m.jwtService.ValidateAccessToken(tokenString)
+			if err == nil {
+				// JWT token is valid
+				setAccessLogIdentity(r.Context(), claims.UserID, "")
+				rc := m.tokenUserContext(w, r, claims)
+				if rc == nil {
+					return
+				}
+				next.ServeHTTP(w, r.WithContext(WithRequestContext(r.Context(), rc)))
+				return
+			}
 		}
 
 		// If JWT validation failed and we have a store, try API key authentication
@@ -115,6 +151,81 @@ func (m *AuthMiddleware) RequireAuthOrAPIKey(next http.Handler) http.Handler {
 	})
 }
 
+// RequireRole returns a middleware that only lets callers with one of the roles through; it runs after RequireAuth
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rc, ok := GetRequestContext(r.Context())
+			if !ok || !slices.Contains(roles, rc.UserRole()) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{"error": roleError(roles)})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// roleError describes the roles a route requires, e.g. "admin access required"
+func roleError(roles []string) string {
+	return strings.Join(roles, " or ") + " access required"
+}
+
+// RequireAdmin is a middleware that only lets deployment admins through; it runs after RequireAuth
+func RequireAdmin(next http.Handler) http.Handler {
+	return RequireRole(RoleAdmin)(next)
+}
+
+// RequireBootstrapToken returns a middleware that only lets requests bearing the deployment's bootstrap token through
+// The token is compared in constant time; it authenticates the host platform rather than a user, so no request
+// context is set.
+func RequireBootstrapToken(token string) func(http.Handler) http.Handler {
+	want := sha256.Sum256([]byte(token))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented, ok := bearerToken(w, r)
+			if !ok {
+				return
+			}
+			got := sha256.Sum256([]byte(presented))
+			if subtle.ConstantTimeCompare(got[:], want[:]) != 1 {
+				respondUnauthorized(w, "invalid bootstrap token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header, writing a 401 when it is malformed
+func bearerToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		respondUnauthorized(w, "missing authorization header")
+		return "", false
+	}
+
+	// Expect exactly one space between the scheme and the token
+	scheme, tokenString, found := strings.Cut(authHeader, " ")
+	if !found || !strings.EqualFold(scheme, "bearer") || strings.Contains(tokenString, " ") {
+		respondUnauthorized(w, "invalid authorization format")
+		return "", false
+	}
+
+	if tokenString == "" {
+		respondUnauthorized(w, "missing token")
+		return "", false
+	}
+
+	return tokenString, true
+}
+
+// looksLikeJWT reports whether a token has the three dot-separated segments of a compact JWT
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
 // validateAPIKey validates an API key and sets the context if valid
 func (m *AuthMiddleware) validateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, keyString string) bool {
 	if m.store == nil {
@@ -128,40 +239,67 @@ func (m *AuthMiddleware) validateAPIKey(w http.ResponseWriter, r *http.Request,
 
 	// Get the key prefix for quick lookup
 	keyPrefix := keyString[:8]
+	keyHash := HashAPIKey(keyString)
+
+	var apiKey *models.APIKey
+	var claims *auth.AccessTokenClaims
+	var user *models.User
+	if entry, ok := m.lookupCachedKey(keyHash); ok {
+		apiKey, claims = entry.apiKey, entry.claims
+	} else {
+		// Find API key by verifying against stored hashes
+		apiKey = m.findAPIKeyByPrefixAndVerify(keyPrefix, keyString)
+		if apiKey == nil || !apiKey.IsValid(m.clock.Now()) {
+			return false
+		}
 
-	// Find API key by verifying against stored hashes
-	apiKey := m.findAPIKeyByPrefixAndVerify(keyPrefix, keyString)
-	if apiKey == nil {
-		return false
-	}
+		// Get user info to create claims
+		var err error
+		// Keys of disabled users stop working; admins evict them from the cache when disabling
+		user, err = m.store.GetUserByID(apiKey.UserID)
+		if err != nil || user.DisabledAt != nil {
+			return false
+		}
 
-	// Check if key is valid
-	if !apiKey.IsValid() {
-		return false
+		// Create claims for the user
+		claims = &auth.AccessTokenClaims{
+			UserID: user.ID,
+			Email:  user.Email,
+		}
+		if m.keyCache != nil {
+			m.keyCache.put(keyHash, apiKey, claims)
+		}
 	}
 
-	// Get user info to create claims
-	user, err := m.store.GetUserByID(apiKey.UserID)
-	if err != nil {
+	// Keys can expire while cached
+	if !apiKey.IsValid(m.clock.Now()) {
 		return false
 	}
 
-	// Update last used timestamp (async to not block request)
-	go m.store.UpdateAPIKeyLastUsed(apiKey.ID)
-
-	// Create claims for the user
-	claims := &auth.AccessTokenClaims{
-		UserID: user.ID,
-		Email:  user.Email,
+	m.recordKeyUsage(apiKey.ID)
+
+	// Add the caller and API key ID to context
+	setAccessLogIdentity(r.Context(), claims.UserID, apiKey.ID)
+	rc := NewRequestContext(r, claims.UserID, claims.Email, m.store)
+	rc.APIKeyID = apiKey.ID
+	rc.ScopedAgentID = apiKey.AgentID
+	rc.BurstCredits = apiKey.BurstCredits
+	rc.FieldCasing = apiKey.FieldCasing
+	if user != nil {
+		rc.setUser(user)
 	}
-
-	// Add user claims and API key ID to context
-	ctx := context.WithValue(r.Context(), UserContextKey, claims)
-	ctx = context.WithValue(ctx, APIKeyContextKey, apiKey.ID)
-	next.ServeHTTP(w, r.WithContext(ctx))
+	next.ServeHTTP(w, r.WithContext(WithRequestContext(r.Context(), rc)))
 	return true
 }
 
+// lookupCachedKey returns the cached validation result for a key hash, if caching is enabled
+func (m *AuthMiddleware) lookupCachedKey(keyHash string) (*cachedAPIKey, bool) {
+	if m.keyCache == nil {
+		return nil, false
+	}
+	return m.keyCache.get(keyHash)
+}
+
 // findAPIKeyByPrefixAndVerify finds an API key by SHA256 hash lookup
 func (m *AuthMiddleware) findAPIKeyByPrefixAndVerify(prefix, rawKey string) *models.APIKey {
 	// Compute SHA256 hash of the raw key for lookup
@@ -188,12 +326,6 @@ func HashAPIKey(rawKey string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// GetUserFromContext retrieves user claims from request context
-func GetUserFromContext(ctx context.Context) (*auth.AccessTokenClaims, bool) {
-	claims, ok := ctx.Value(UserContextKey).(*auth.AccessTokenClaims)
-	return claims, ok
-}
-
 // respondUnauthorized sends a 401 response with error message
 func respondUnauthorized(w http.ResponseWriter, message string) {
 	w.Header().Set("Content-Type", "application/json")
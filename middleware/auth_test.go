@@ -3,10 +3,12 @@ package middleware
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/clock"
 	"github.com/kubeagents/kubeagents/models"
 	"github.com/kubeagents/kubeagents/store"
 )
@@ -62,9 +64,9 @@ func TestAuthMiddleware_RequireAuth(t *testing.T) {
 			// Create a test handler that checks for user in context
 			var gotUserID string
 			testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				claims, ok := GetUserFromContext(r.Context())
+				caller, ok := GetRequestContext(r.Context())
 				if ok {
-					gotUserID = claims.UserID
+					gotUserID = caller.UserID
 				}
 				w.WriteHeader(http.StatusOK)
 			})
@@ -122,15 +124,208 @@ func TestAuthMiddleware_ExpiredToken(t *testing.T) {
 	}
 }
 
-func TestGetUserFromContext_NoUser(t *testing.T) {
+func TestAuthMiddleware_RequireAdmin(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	middleware := NewAuthMiddlewareWithStore(jwtService, nil)
+	middleware.SetAdminEmails([]string{"Ops@Example.com"})
+	handler := middleware.RequireAuth(RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		email string
+		want  int
+	}{
+		{"ops@example.com", http.StatusOK},
+		{"member@example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		token, _ := jwtService.GenerateAccessToken("user-123", tt.email)
+		req := httptest.NewRequest("PUT", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s status = %v, want %v", tt.email, rr.Code, tt.want)
+		}
+	}
+}
+
+func TestAuthMiddleware_RefusesTokensOfDisabledUsers(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	st := store.NewMemoryStore()
+	now := time.Now()
+	for _, id := range []string{"active", "disabled"} {
+		user := &models.User{ID: id, Email: id + "@example.com", PasswordHash: "hash", CreatedAt: now, UpdatedAt: now}
+		if id == "disabled" {
+			user.DisabledAt = &now
+		}
+		if err := st.CreateUser(user); err != nil {
+			t.Fatalf("CreateUser(%s) error = %v", id, err)
+		}
+	}
+	m := NewAuthMiddlewareWithStore(jwtService, st)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		userID string
+		want   int
+	}{
+		{"active", http.StatusOK},
+		{"disabled", http.StatusUnauthorized},
+		{"deleted", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		token, _ := jwtService.GenerateAccessToken(tt.userID, tt.userID+"@example.com")
+		for name, handler := range map[string]http.Handler{"RequireAuth": m.RequireAuth(ok), "RequireAuthOrAPIKey": m.RequireAuthOrAPIKey(ok)} {
+			req := httptest.NewRequest("GET", "/test", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("%s() of a %s user status = %v, want %v", name, tt.userID, rr.Code, tt.want)
+			}
+		}
+	}
+}
+
+func TestAuthMiddleware_APIKeyExpiryFollowsClock(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now().UTC()
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: "hash", CreatedAt: now, UpdatedAt: now})
+
+	rawKey := "OJBwmmPSTestApiKey1234567890ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	expiresAt := now.Add(time.Hour)
+	st.CreateAPIKey(&models.APIKey{
+		ID:        "key-123",
+		UserID:    "user-123",
+		Name:      "test-key",
+		KeyHash:   HashAPIKey(rawKey),
+		KeyPrefix: rawKey[:8],
+		ExpiresAt: &expiresAt,
+		CreatedAt: now,
+	})
+
+	m := NewAuthMiddlewareWithStore(nil, st)
+	clk := clock.NewFake(now)
+	m.SetClock(clk)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	call := func() int {
+		req := httptest.NewRequest("POST", "/webhook/status", nil)
+		req.Header.Set("Authorization", "Bearer "+rawKey)
+		rr := httptest.NewRecorder()
+		m.RequireAuthOrAPIKey(ok).ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := call(); code != http.StatusOK {
+		t.Errorf("RequireAuthOrAPIKey() before expiry status = %v, want %v", code, http.StatusOK)
+	}
+	clk.Advance(time.Hour + time.Second)
+	if code := call(); code != http.StatusUnauthorized {
+		t.Errorf("RequireAuthOrAPIKey() after expiry status = %v, want %v", code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	st := store.NewMemoryStore()
+	now := time.Now()
+	for id, role := range map[string]string{"admin": models.UserRoleAdmin, "member": "", "viewer": models.UserRoleViewer} {
+		user := &models.User{ID: id, Email: id + "@example.com", PasswordHash: "hash", Role: role, CreatedAt: now, UpdatedAt: now}
+		if err := st.CreateUser(user); err != nil {
+			t.Fatalf("CreateUser(%s) error = %v", id, err)
+		}
+	}
+	handler := RequireRole(RoleAdmin, RoleMember)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		userID string
+		role   string // Role set by ADMIN_EMAILS
+		want   int
+	}{
+		{"stored admin", "admin", "", http.StatusOK},
+		{"member by default", "member", "", http.StatusOK},
+		{"viewer", "viewer", "", http.StatusForbidden},
+		{"viewer listed in ADMIN_EMAILS", "viewer", RoleAdmin, http.StatusOK},
+		{"unknown user", "missing", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/test", nil)
+			rc := NewRequestContext(req, tt.userID, tt.userID+"@example.com", st)
+			rc.Role = tt.role
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req.WithContext(WithRequestContext(req.Context(), rc)))
+			if rr.Code != tt.want {
+				t.Errorf("status = %v, want %v: %s", rr.Code, tt.want, rr.Body.String())
+			}
+			if tt.want == http.StatusForbidden && !strings.Contains(rr.Body.String(), "admin or member access required") {
+				t.Errorf("body = %s, want the required roles", rr.Body.String())
+			}
+		})
+	}
+
+	// API keys never act as admins
+	req := httptest.NewRequest("POST", "/test", nil)
+	rc := NewRequestContext(req, "admin", "admin@example.com", st)
+	rc.APIKeyID = "key-1"
+	if role := rc.UserRole(); role != RoleMember {
+		t.Errorf("UserRole() with an API key = %q, want %q", role, RoleMember)
+	}
+	rr := httptest.NewRecorder()
+	RequireAdmin(handler).ServeHTTP(rr, req.WithContext(WithRequestContext(req.Context(), rc)))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("RequireAdmin() with an admin's API key status = %v, want %v", rr.Code, http.StatusForbidden)
+	}
+}
+
+func TestRequireBootstrapToken(t *testing.T) {
+	handler := RequireBootstrapToken("bootstrap-token-0123456789abcdef")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"matching token", "Bearer bootstrap-token-0123456789abcdef", http.StatusOK},
+		{"other token", "Bearer bootstrap-token-0123456789abcdeX", http.StatusUnauthorized},
+		{"prefix of the token", "Bearer bootstrap-token", http.StatusUnauthorized},
+		{"no header", "", http.StatusUnauthorized},
+		{"basic scheme", "Basic bootstrap-token-0123456789abcdef", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/bootstrap", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("status = %v, want %v: %s", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetRequestContext_NoUser(t *testing.T) {
 	req := httptest.NewRequest("GET", "/test", nil)
-	claims, ok := GetUserFromContext(req.Context())
+	caller, ok := GetRequestContext(req.Context())
 
 	if ok {
 		t.Error("expected ok to be false")
 	}
-	if claims != nil {
-		t.Error("expected claims to be nil")
+	if caller != nil {
+		t.Error("expected caller to be nil")
 	}
 }
 
@@ -276,3 +471,57 @@ func TestHashAPIKey_NotBcrypt(t *testing.T) {
 		t.Errorf("HashAPIKey() appears to use bcrypt (starts with %q), should use SHA256", hash[0:4])
 	}
 }
+
+// Auth middleware benchmarks guard the per-request cost of authentication.
+// Target on a modern x86 core: under 2µs and 12 allocations per request for both JWT and cached API key callers.
+// Run with: ./scripts/check.sh --bench
+
+func BenchmarkAuthMiddleware_RequireAuth(b *testing.B) {
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	m := NewAuthMiddlewareWithStore(jwtService, nil)
+	token, _ := jwtService.GenerateAccessToken("user-123", "test@example.com")
+	benchmarkAuthMiddleware(b, m.RequireAuth(okHandler), "Bearer "+token)
+}
+
+func BenchmarkAuthMiddleware_RequireAuthOrAPIKey_JWT(b *testing.B) {
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	m := NewAuthMiddlewareWithStore(jwtService, store.NewMemoryStore())
+	token, _ := jwtService.GenerateAccessToken("user-123", "test@example.com")
+	benchmarkAuthMiddleware(b, m.RequireAuthOrAPIKey(okHandler), "Bearer "+token)
+}
+
+func BenchmarkAuthMiddleware_RequireAuthOrAPIKey_APIKey(b *testing.B) {
+	jwtService := auth.NewJWTService("test-secret-key-at-least-32-chars", 15*time.Minute, 7*24*time.Hour)
+	st := store.NewMemoryStore()
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: "hash"})
+	rawKey := "OJBwmmPSTestApiKey1234567890ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	st.CreateAPIKey(&models.APIKey{
+		ID:        "key-123",
+		UserID:    "user-123",
+		Name:      "bench-key",
+		KeyHash:   HashAPIKey(rawKey),
+		KeyPrefix: rawKey[:8],
+		CreatedAt: time.Now(),
+	})
+
+	m := NewAuthMiddlewareWithStore(jwtService, st)
+	m.SetAPIKeyCacheTTL(time.Minute)
+	m.EnableBatchedKeyUsage()
+	benchmarkAuthMiddleware(b, m.RequireAuthOrAPIKey(okHandler), "Bearer "+rawKey)
+}
+
+// benchmarkAuthMiddleware serves an authenticated request through handler b.N times
+func benchmarkAuthMiddleware(b *testing.B, handler http.Handler, authHeader string) {
+	req := httptest.NewRequest("POST", "/webhook/status", nil)
+	req.Header.Set("Authorization", authHeader)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			b.Fatalf("status = %v, want %v", rr.Code, http.StatusOK)
+		}
+	}
+}
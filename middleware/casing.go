@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// Accept profiles selecting the field casing of JSON responses, e.g. Accept: application/json; profile=camelCase
+const (
+	ProfileSnakeCase = "snake_case"
+	ProfileCamelCase = "camelCase"
+)
+
+// ResponseCasing rewrites the field names of JSON responses to camelCase for callers asking for it, so handlers
+// keep declaring snake_case fields only
+// A caller asks with the profile parameter of its Accept header, or by authenticating with an API key whose
+// field casing is camel; the profile wins, so profile=snake_case restores the declared names for one request.
+// Metadata reported by agents keeps its own field names, and request bodies are always read as snake_case.
+func ResponseCasing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if responseCasing(r) != models.FieldCasingCamel {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &casingWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+		cw.finish()
+	})
+}
+
+// responseCasing returns the field casing a request asked for, snake unless it asked for camel
+func responseCasing(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err != nil || (mediaType != "application/json" && mediaType != "*/*") {
+			continue
+		}
+		switch params["profile"] {
+		case ProfileCamelCase:
+			return models.FieldCasingCamel
+		case ProfileSnakeCase:
+			return models.FieldCasingSnake
+		}
+	}
+	if caller, ok := GetRequestContext(r.Context()); ok && caller.FieldCasing == models.FieldCasingCamel {
+		return models.FieldCasingCamel
+	}
+	return models.FieldCasingSnake
+}
+
+// casingWriter holds back JSON bodies until the handler is done so their field names can be rewritten
+// Other bodies are passed through as they are written.
+type casingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	isJSON      bool
+	body        bytes.Buffer
+}
+
+// WriteHeader decides from the content type whether the body is held back
+func (w *casingWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	w.isJSON = mediaType == "application/json" || mediaType == "application/problem+json"
+	if !w.isJSON {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+// Write holds back JSON and passes other bodies through
+func (w *casingWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.isJSON {
+		return w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush passes flushes of bodies that are not held back through
+func (w *casingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok && w.wroteHeader && !w.isJSON {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the wrapped writer for http.ResponseController
+func (w *casingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish sends a held back JSON body with its field names rewritten, or as it was if it is not valid JSON
+func (w *casingWriter) finish() {
+	if !w.isJSON {
+		return
+	}
+	body := w.body.Bytes()
+	if rewritten, err := camelCaseJSON(body); err == nil {
+		body = rewritten
+	}
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body)
+}
+
+// camelCaseJSON rewrites the object keys of JSON values to camelCase, keeping their order
+// The values of metadata fields are copied as they are.
+func camelCaseJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	// frame is an object or array being copied; n counts the keys and values written to it
+	type frame struct {
+		object bool
+		raw    bool
+		n      int
+	}
+	var stack []frame
+	var out bytes.Buffer
+	rawNext := false // The next value is a metadata field's
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			out.WriteByte(byte(delim))
+			continue
+		}
+
+		isKey, raw := false, false
+		if len(stack) == 0 {
+			if out.Len() > 0 {
+				out.WriteByte('\n')
+			}
+		} else {
+			top := &stack[len(stack)-1]
+			raw = top.raw
+			isKey = top.object && top.n%2 == 0
+			switch {
+			case top.n == 0:
+			case isKey || !top.object:
+				out.WriteByte(',')
+			default:
+				out.WriteByte(':')
+			}
+			top.n++
+		}
+
+		switch value := tok.(type) {
+		case json.Delim:
+			stack = append(stack, frame{object: value == '{', raw: raw || rawNext})
+			out.WriteByte(byte(value))
+		case string:
+			if isKey && !raw {
+				value = camelCase(value)
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(encoded)
+		case json.Number:
+			out.WriteString(value.String())
+		case bool:
+			out.WriteString(strconv.FormatBool(value))
+		case nil:
+			out.WriteString("null")
+		}
+
+		if isKey {
+			rawNext = !raw && tok == "metadata"
+		} else {
+			rawNext = false
+		}
+	}
+
+	if bytes.HasSuffix(data, []byte("\n")) {
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// camelCase turns a snake_case name such as status_history_next_cursor into statusHistoryNextCursor
+// Names without underscores are returned as they are.
+func camelCase(name string) string {
+	if !strings.Contains(strings.Trim(name, "_"), "_") {
+		return name
+	}
+	var b strings.Builder
+	b.Grow(len(name))
+	upper := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '_' && b.Len() > 0:
+			upper = true
+		case upper && 'a' <= c && c <= 'z':
+			b.WriteByte(c - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteByte(c)
+			upper = false
+		}
+	}
+	return b.String()
+}
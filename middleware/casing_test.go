@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// jsonHandler answers with body as contentType
+func jsonHandler(contentType, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	})
+}
+
+func TestResponseCasing_RewritesJSONFieldNames(t *testing.T) {
+	body := `{"session":{"session_topic":"build-1","last_updated":"2026-03-01T12:00:00Z","ttl_minutes":30},` +
+		`"status_history":[{"status":"failed","metadata":{"exit_code":1,"build_info":{"git_sha":"abc"}},"content_format":"text"}],` +
+		`"status_history_next_cursor":null,"_links":{"self":"/api"},"escaped":"<a_b>"}` + "\n"
+	want := `{"session":{"sessionTopic":"build-1","lastUpdated":"2026-03-01T12:00:00Z","ttlMinutes":30},` +
+		`"statusHistory":[{"status":"failed","metadata":{"exit_code":1,"build_info":{"git_sha":"abc"}},"contentFormat":"text"}],` +
+		`"statusHistoryNextCursor":null,"_links":{"self":"/api"},"escaped":"\u003ca_b\u003e"}` + "\n"
+
+	req := httptest.NewRequest("GET", "/api/agents/agent-1/sessions/build-1", nil)
+	req.Header.Set("Accept", `text/html, application/json; profile="camelCase"`)
+	rr := httptest.NewRecorder()
+	ResponseCasing(jsonHandler("application/json", body)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("status = %v, want %v", rr.Code, http.StatusCreated)
+	}
+	if got := rr.Body.String(); got != want {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+	if got := rr.Header().Get("Vary"); got != "Accept" {
+		t.Errorf("Vary = %q, want Accept", got)
+	}
+
+	// Problem details are JSON too
+	rr = httptest.NewRecorder()
+	ResponseCasing(jsonHandler("application/problem+json", `{"errors":[{"field":"/agent_id","code":"required"}],"error_code":"x"}`)).ServeHTTP(rr, req)
+	if got := rr.Body.String(); got != `{"errors":[{"field":"/agent_id","code":"required"}],"errorCode":"x"}` {
+		t.Errorf("problem body = %s, want camelCase field names and unchanged values", got)
+	}
+}
+
+func TestResponseCasing_FollowsAPIKeyUnlessProfileOverrides(t *testing.T) {
+	body := `{"user_id":"user-1"}`
+	tests := []struct {
+		name   string
+		casing string
+		accept string
+		want   string
+	}{
+		{"default", "", "", body},
+		{"camel key", models.FieldCasingCamel, "application/json", `{"userId":"user-1"}`},
+		{"camel key with snake profile", models.FieldCasingCamel, "application/json; profile=snake_case", body},
+		{"snake key", models.FieldCasingSnake, "", body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/webhook/status", nil)
+			req.Header.Set("Accept", tt.accept)
+			req = req.WithContext(WithRequestContext(req.Context(), &RequestContext{UserID: "user-1", FieldCasing: tt.casing}))
+			rr := httptest.NewRecorder()
+			ResponseCasing(jsonHandler("application/json", body)).ServeHTTP(rr, req)
+
+			if got := rr.Body.String(); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseCasing_PassesOtherContentThrough(t *testing.T) {
+	body := "agent_id,session_topic\nagent-1,build-1\n"
+	req := httptest.NewRequest("GET", "/api/export", nil)
+	req.Header.Set("Accept", "application/json; profile=camelCase")
+	rr := httptest.NewRecorder()
+	ResponseCasing(jsonHandler("text/csv", body)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated || rr.Body.String() != body {
+		t.Errorf("response = %v %q, want %v with the body unchanged", rr.Code, rr.Body.String(), http.StatusCreated)
+	}
+}
+
+func TestCamelCase(t *testing.T) {
+	tests := map[string]string{
+		"user_id":                    "userId",
+		"status_history_next_cursor": "statusHistoryNextCursor",
+		"status":                     "status",
+		"_links":                     "_links",
+		"p95_seconds":                "p95Seconds",
+		"agent-001":                  "agent-001",
+	}
+	for name, want := range tests {
+		if got := camelCase(name); got != want {
+			t.Errorf("camelCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
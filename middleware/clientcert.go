@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+)
+
+// CertificateFingerprint returns the lowercase hex SHA-256 of a certificate's DER encoding
+func CertificateFingerprint(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(hash[:])
+}
+
+// RequireClientCertificate is a middleware that authenticates the TLS client certificate by its registered fingerprint
+// It is used on the mTLS listener, where the TLS handshake has already proven possession of the certificate's key.
+func (m *AuthMiddleware) RequireClientCertificate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			respondUnauthorized(w, "client certificate required")
+			return
+		}
+
+		// Without a client CA the handshake does not check validity dates, so check them here
+		leaf := r.TLS.PeerCertificates[0]
+		now := m.clock.Now()
+		if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+			respondUnauthorized(w, "client certificate expired or not yet valid")
+			return
+		}
+
+		cert, err := m.store.GetClientCertificateByFingerprint(CertificateFingerprint(leaf))
+		if err != nil {
+			respondUnauthorized(w, "unknown client certificate")
+			return
+		}
+
+		user, err := m.store.GetUserByID(cert.UserID)
+		if err != nil || user.DisabledAt != nil {
+			respondUnauthorized(w, "unknown client certificate")
+			return
+		}
+
+		setAccessLogIdentity(r.Context(), user.ID, "")
+		rc := NewRequestContext(r, user.ID, user.Email, m.store)
+		rc.ScopedAgentID = cert.AgentID
+		rc.setUser(user)
+		next.ServeHTTP(w, r.WithContext(WithRequestContext(r.Context(), rc)))
+	})
+}
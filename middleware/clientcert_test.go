@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// newTestClientCertificate creates a self-signed client certificate valid between notBefore and notAfter
+func newTestClientCertificate(t *testing.T, notBefore, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "agent"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	return cert
+}
+
+func TestAuthMiddleware_RequireClientCertificate(t *testing.T) {
+	st := store.NewMemoryStore()
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: "hash", Name: "Test"})
+	m := NewAuthMiddlewareWithStore(nil, st)
+
+	now := time.Now()
+	registered := newTestClientCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+	restricted := newTestClientCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+	expired := newTestClientCertificate(t, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	unknown := newTestClientCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+
+	for i, cert := range []*x509.Certificate{registered, restricted, expired} {
+		agentID := ""
+		if cert == restricted {
+			agentID = "agent-001"
+		}
+		err := st.CreateClientCertificate(&models.ClientCertificate{
+			ID:          fmt.Sprintf("cert-%d", i),
+			UserID:      "user-123",
+			Name:        "fleet",
+			Fingerprint: CertificateFingerprint(cert),
+			AgentID:     agentID,
+			CreatedAt:   now,
+		})
+		if err != nil {
+			t.Fatalf("CreateClientCertificate() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		wantStatus int
+		wantAgent  string
+	}{
+		{"registered certificate", registered, http.StatusOK, ""},
+		{"agent restricted certificate", restricted, http.StatusOK, "agent-001"},
+		{"expired certificate", expired, http.StatusUnauthorized, ""},
+		{"unregistered certificate", unknown, http.StatusUnauthorized, ""},
+		{"no certificate", nil, http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUserID, gotAgent string
+			handler := m.RequireClientCertificate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if caller, ok := GetRequestContext(r.Context()); ok {
+					gotUserID, gotAgent = caller.UserID, caller.ScopedAgentID
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("POST", "/webhook/status", nil)
+			if tt.cert != nil {
+				req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.cert}}
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("RequireClientCertificate() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && (gotUserID != "user-123" || gotAgent != tt.wantAgent) {
+				t.Errorf("RequireClientCertificate() user = %q, agent = %q, want user-123 and %q", gotUserID, gotAgent, tt.wantAgent)
+			}
+		})
+	}
+}
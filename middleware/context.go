@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// RequestContextKey is the key used to store the RequestContext in request context
+const RequestContextKey contextKey = "request_context"
+
+// defaultLocale is used when a request has no usable Accept-Language header
+const defaultLocale = "en"
+
+// Roles of callers, checked by RequireRole
+const (
+	RoleAdmin  = models.UserRoleAdmin  // Deployment admins, who manage settings inherited by every member and other users
+	RoleMember = models.UserRoleMember // Users who manage their own agents and credentials
+	RoleViewer = models.UserRoleViewer // Users who only read
+)
+
+// RequestContext describes the authenticated caller of a request
+// It is built once by the authentication middleware so handlers and cross-cutting features
+// read the caller from one place instead of looking up claims, keys and users individually.
+type RequestContext struct {
+	UserID            string
+	Email             string
+	OrgID             string // Tenant organization; unset, since users may belong to several organizations
+	Role              string // RoleAdmin for admins listed in ADMIN_EMAILS, otherwise empty; see UserRole
+	APIKeyID          string // Set when the request was authenticated with an API key
+	EnrollmentTokenID string // Set when the request was authenticated with an enrollment token
+	ScopedAgentID     string // Agent the client certificate or agent token is restricted to, if any
+	BurstCredits      int    // Extra webhook requests the API key may save up while quiet, see RateLimiter
+	FieldCasing       string // Field casing the API key asked for in responses, see ResponseCasing
+	Locale            string // Preferred language from Accept-Language
+
+	store    store.Store
+	userOnce sync.Once
+	user     *models.User
+	userErr  error
+}
+
+// NewRequestContext creates the request context for an authenticated user
+// The user record is loaded from st on first use.
+func NewRequestContext(r *http.Request, userID, email string, st store.Store) *RequestContext {
+	return &RequestContext{
+		UserID: userID,
+		Email:  email,
+		Locale: parseLocale(r.Header.Get("Accept-Language")),
+		store:  st,
+	}
+}
+
+// User returns the caller's user record, loading it at most once per request
+func (rc *RequestContext) User() (*models.User, error) {
+	rc.userOnce.Do(func() {
+		if rc.user != nil {
+			return
+		}
+		if rc.store == nil {
+			rc.userErr = store.ErrNotFound
+			return
+		}
+		rc.user, rc.userErr = rc.store.GetUserByID(rc.UserID)
+	})
+	return rc.user, rc.userErr
+}
+
+// UserRole returns the caller's role: RoleAdmin for admins listed in ADMIN_EMAILS, otherwise the role
+// stored with the user, a member by default
+// API keys never act as admins, so their callers are members at most. A user that cannot be loaded has
+// no role, which RequireRole refuses.
+func (rc *RequestContext) UserRole() string {
+	if rc.Role == RoleAdmin {
+		return RoleAdmin
+	}
+	user, err := rc.User()
+	if err != nil {
+		return ""
+	}
+	switch {
+	case user.Role == "":
+		return RoleMember
+	case user.Role == RoleAdmin && rc.APIKeyID != "":
+		return RoleMember
+	default:
+		return user.Role
+	}
+}
+
+// Plan returns the caller's quota tier, or empty for the deployment defaults
+func (rc *RequestContext) Plan() string {
+	user, err := rc.User()
+	if err != nil {
+		return ""
+	}
+	return user.Plan
+}
+
+// MayReportFor reports whether the caller's credential may report for an agent
+func (rc *RequestContext) MayReportFor(agentID string) bool {
+	return rc.ScopedAgentID == "" || rc.ScopedAgentID == agentID
+}
+
+// setUser records a user record the middleware already loaded, sparing handlers a second lookup
+func (rc *RequestContext) setUser(user *models.User) {
+	rc.user = user
+}
+
+// WithRequestContext returns a copy of ctx carrying rc
+func WithRequestContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, RequestContextKey, rc)
+}
+
+// GetRequestContext retrieves the caller set by the authentication middleware
+func GetRequestContext(ctx context.Context) (*RequestContext, bool) {
+	rc, ok := ctx.Value(RequestContextKey).(*RequestContext)
+	return rc, ok
+}
+
+// parseLocale returns the first language tag of an Accept-Language header
+func parseLocale(header string) string {
+	tag, _, _ := strings.Cut(header, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "" || tag == "*" {
+		return defaultLocale
+	}
+	return tag
+}
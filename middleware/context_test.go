@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// userCountingStore counts user lookups
+type userCountingStore struct {
+	*store.MemoryStore
+	lookups int
+}
+
+func (s *userCountingStore) GetUserByID(userID string) (*models.User, error) {
+	s.lookups++
+	return s.MemoryStore.GetUserByID(userID)
+}
+
+func TestRequestContext_LoadsUserOnce(t *testing.T) {
+	st := &userCountingStore{MemoryStore: store.NewMemoryStore()}
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: "hash", Plan: "pro"})
+
+	req := httptest.NewRequest("GET", "/api/agents", nil)
+	caller := NewRequestContext(req, "user-123", "test@example.com", st)
+
+	if plan := caller.Plan(); plan != "pro" {
+		t.Errorf("Plan() = %q, want pro", plan)
+	}
+	if user, err := caller.User(); err != nil || user.ID != "user-123" {
+		t.Errorf("User() = %v, %v, want user-123", user, err)
+	}
+	if st.lookups != 1 {
+		t.Errorf("user lookups = %d, want 1", st.lookups)
+	}
+
+	anonymous := NewRequestContext(req, "user-404", "", nil)
+	if plan := anonymous.Plan(); plan != "" {
+		t.Errorf("Plan() without store = %q, want empty", plan)
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"*", "en"},
+		{"zh-CN,zh;q=0.9,en;q=0.8", "zh-CN"},
+		{"fr;q=0.7", "fr"},
+		{" de ", "de"},
+	}
+
+	for _, tt := range tests {
+		if got := parseLocale(tt.header); got != tt.want {
+			t.Errorf("parseLocale(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
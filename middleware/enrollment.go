@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// RequireEnrollmentToken is a middleware that authenticates a redeemable enrollment token
+// The handler redeems the token; until then it only proves the caller may enroll an agent for its owner.
+func (m *AuthMiddleware) RequireEnrollmentToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString, ok := bearerToken(w, r)
+		if !ok {
+			return
+		}
+
+		token, err := m.store.GetEnrollmentTokenByHash(HashAPIKey(tokenString))
+		if err != nil || !token.Redeemable(m.clock.Now()) {
+			respondUnauthorized(w, "invalid, used or expired enrollment token")
+			return
+		}
+
+		user, err := m.store.GetUserByID(token.UserID)
+		if err != nil || user.DisabledAt != nil {
+			respondUnauthorized(w, "invalid, used or expired enrollment token")
+			return
+		}
+
+		setAccessLogIdentity(r.Context(), user.ID, "")
+		rc := NewRequestContext(r, user.ID, user.Email, m.store)
+		rc.EnrollmentTokenID = token.ID
+		rc.ScopedAgentID = token.AgentID
+		rc.setUser(user)
+		next.ServeHTTP(w, r.WithContext(WithRequestContext(r.Context(), rc)))
+	})
+}
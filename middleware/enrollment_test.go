@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestAuthMiddleware_RequireEnrollmentToken(t *testing.T) {
+	st := store.NewMemoryStore()
+	st.CreateUser(&models.User{ID: "user-123", Email: "test@example.com", PasswordHash: "hash", Name: "Test"})
+	m := NewAuthMiddlewareWithStore(nil, st)
+
+	now := time.Now()
+	usedAt := now.Add(-time.Minute)
+	tokens := map[string]*models.EnrollmentToken{
+		"kae_valid-token":   {ID: "enroll-1", AgentID: "agent-001", ExpiresAt: now.Add(time.Hour)},
+		"kae_expired-token": {ID: "enroll-2", ExpiresAt: now.Add(-time.Minute)},
+		"kae_used-token":    {ID: "enroll-3", ExpiresAt: now.Add(time.Hour), UsedAt: &usedAt},
+	}
+	for raw, token := range tokens {
+		token.UserID = "user-123"
+		token.Name = "fleet"
+		token.TokenHash = HashAPIKey(raw)
+		token.TokenPrefix = raw[:8]
+		token.CreatedAt = now.Add(-time.Hour)
+		if err := st.CreateEnrollmentToken(token); err != nil {
+			t.Fatalf("CreateEnrollmentToken() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"valid token", "kae_valid-token", http.StatusOK},
+		{"expired token", "kae_expired-token", http.StatusUnauthorized},
+		{"used token", "kae_used-token", http.StatusUnauthorized},
+		{"unknown token", "kae_unknown", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var caller *RequestContext
+			handler := m.RequireEnrollmentToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				caller, _ = GetRequestContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("POST", "/webhook/enroll", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if caller == nil || caller.UserID != "user-123" || caller.EnrollmentTokenID != "enroll-1" || caller.ScopedAgentID != "agent-001" {
+				t.Errorf("caller = %+v, want the token owner restricted to agent-001", caller)
+			}
+		})
+	}
+}
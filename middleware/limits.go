@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// timeoutBody is the response body written when a request exceeds its deadline
+const timeoutBody = `{"error":"request timed out"}`
+
+// Timeout returns a middleware that aborts requests running longer than d with 503
+// The request context is cancelled at the deadline so store calls can stop early.
+// A non-positive duration disables the timeout.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.TimeoutHandler(next, d, timeoutBody)
+	}
+}
+
+// ConcurrencyLimiter caps the number of in-flight requests served at once
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter creates a limiter allowing at most max concurrent requests
+// A non-positive max disables limiting.
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	if max <= 0 {
+		return &ConcurrencyLimiter{}
+	}
+	return &ConcurrencyLimiter{
+		slots: make(chan struct{}, max),
+	}
+}
+
+// Handler rejects requests with 503 when all slots are taken instead of queueing them
+func (l *ConcurrencyLimiter) Handler(next http.Handler) http.Handler {
+	if l.slots == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.slots <- struct{}{}:
+			defer func() { <-l.slots }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"server overloaded, retry later"}`))
+		}
+	})
+}
+
+// InFlight returns the number of requests currently being served
+func (l *ConcurrencyLimiter) InFlight() int {
+	return len(l.slots)
+}
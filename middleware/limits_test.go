@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimeout_AbortsSlowRequests(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+		}
+	})
+
+	rr := httptest.NewRecorder()
+	Timeout(20*time.Millisecond)(slow).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Timeout() status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestTimeout_ZeroDisables(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Timeout(0)(okHandler).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Timeout(0) status = %v, want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestConcurrencyLimiter_RejectsWhenFull(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1)
+
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	blocking := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		blocking.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	<-entered
+
+	if limiter.InFlight() != 1 {
+		t.Errorf("InFlight() = %d, want 1", limiter.InFlight())
+	}
+
+	rr := httptest.NewRecorder()
+	blocking.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("overloaded status = %v, want %v", rr.Code, http.StatusServiceUnavailable)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("overloaded response missing Retry-After header")
+	}
+
+	close(release)
+	wg.Wait()
+
+	if limiter.InFlight() != 0 {
+		t.Errorf("InFlight() after completion = %d, want 0", limiter.InFlight())
+	}
+}
+
+func TestConcurrencyLimiter_ZeroDisables(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NewConcurrencyLimiter(0).Handler(okHandler).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("disabled limiter status = %v, want %v", rr.Code, http.StatusOK)
+	}
+}
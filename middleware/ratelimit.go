@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+)
+
+// maxBackoff caps the delay a throttled client is told to back off to between retries
+const maxBackoff = 5 * time.Minute
+
+// BackoffHint tells a throttled client how to retry, mirroring the Retry-After header
+// Clients should wait RetryAfterSeconds before the next attempt and, while still throttled,
+// double the wait up to MaxSeconds, adding up to JitterSeconds of random delay so retries spread out.
+type BackoffHint struct {
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+	Strategy          string `json:"strategy"` // Always "exponential"
+	MaxSeconds        int    `json:"max_seconds"`
+	JitterSeconds     int    `json:"jitter_seconds"`
+}
+
+// ThrottledResponse is the body of a 429 response
+type ThrottledResponse struct {
+	Error   string      `json:"error"`
+	Message string      `json:"message"`
+	Backoff BackoffHint `json:"backoff"`
+}
+
+// RespondThrottled writes a 429 response telling the client to retry after wait
+func RespondThrottled(w http.ResponseWriter, message string, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(ThrottledResponse{
+		Error:   "rate_limited",
+		Message: message,
+		Backoff: BackoffHint{
+			RetryAfterSeconds: seconds,
+			Strategy:          "exponential",
+			MaxSeconds:        int(maxBackoff.Seconds()),
+			JitterSeconds:     seconds,
+		},
+	})
+}
+
+// rateBucket is the token bucket of one caller
+type rateBucket struct {
+	tokens     float64
+	credits    float64 // Burst credits saved up while the bucket was full
+	maxCredits float64
+	updated    time.Time
+}
+
+// RateLimiter limits how many requests each authenticated caller may make
+// Each API key, or user when the request was not made with a key, has a token bucket that holds burst
+// requests and refills at perMinute requests per minute. API keys with burst credits keep refilling while
+// their bucket is full, saving up to that many extra requests they spend once the bucket runs dry, so
+// agents reporting in batches after quiet periods are not throttled like continuously noisy ones.
+// Responses carry the remaining requests and credits in X-RateLimit-Remaining and X-RateLimit-Burst-Credits.
+// It runs after authentication and lets unauthenticated requests through.
+type RateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*rateBucket
+	clock   clock.Clock
+}
+
+// NewRateLimiter creates a limiter allowing perMinute requests per minute with bursts of up to burst
+// A non-positive perMinute disables limiting; a burst below 1 allows a single request at a time.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if perMinute <= 0 {
+		return &RateLimiter{}
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*rateBucket),
+		clock:   clock.Real,
+	}
+}
+
+// SetClock replaces the clock that refills the buckets
+func (l *RateLimiter) SetClock(c clock.Clock) {
+	l.clock = c
+}
+
+// Handler rejects requests of callers over their rate with 429 and a backoff hint
+func (l *RateLimiter) Handler(next http.Handler) http.Handler {
+	if l.buckets == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := GetRequestContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := "user:" + caller.UserID
+		if caller.APIKeyID != "" {
+			key = "key:" + caller.APIKeyID
+		}
+		wait, remaining, credits := l.take(key, float64(caller.BurstCredits))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(remaining)))
+		w.Header().Set("X-RateLimit-Burst-Credits", strconv.Itoa(int(credits)))
+		if wait > 0 {
+			RespondThrottled(w, "Too many requests, retry later", wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take spends a token of key's bucket, or one of its burst credits once the bucket is empty, returning how long
+// to wait instead when neither is left, and the tokens and credits remaining
+// maxCredits is the number of credits the caller may save up.
+func (l *RateLimiter) take(key string, maxCredits float64) (wait time.Duration, tokens, credits float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		// Forget full buckets so idle callers do not accumulate; a new bucket starts full
+		l.pruneLocked(now)
+		bucket = &rateBucket{tokens: l.burst, credits: maxCredits, updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.maxCredits = maxCredits
+	// Refills beyond a full bucket are saved as credits
+	refilled := bucket.tokens + now.Sub(bucket.updated).Seconds()*l.rate
+	bucket.tokens = math.Min(l.burst, refilled)
+	bucket.credits = math.Min(maxCredits, bucket.credits+math.Max(0, refilled-l.burst))
+	bucket.updated = now
+
+	switch {
+	case bucket.tokens >= 1:
+		bucket.tokens--
+	case bucket.credits >= 1:
+		bucket.credits--
+	default:
+		return time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second)), bucket.tokens, bucket.credits
+	}
+	return 0, bucket.tokens, bucket.credits
+}
+
+// pruneLocked drops the buckets that have refilled completely, including their credits
+func (l *RateLimiter) pruneLocked(now time.Time) {
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst+bucket.maxCredits-bucket.credits {
+			delete(l.buckets, key)
+		}
+	}
+}
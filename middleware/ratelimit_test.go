@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+)
+
+func rateLimitedRequest(caller *RequestContext) *http.Request {
+	req := httptest.NewRequest("POST", "/webhook/status", nil)
+	if caller == nil {
+		return req
+	}
+	return req.WithContext(WithRequestContext(req.Context(), caller))
+}
+
+func TestRateLimiter_ThrottlesWithBackoffHint(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := NewRateLimiter(30, 2)
+	limiter.SetClock(fake)
+	handler := limiter.Handler(okHandler)
+	caller := &RequestContext{UserID: "user-1", APIKeyID: "key-1"}
+
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, rateLimitedRequest(caller))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d status = %v, want %v", i, rr.Code, http.StatusOK)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, rateLimitedRequest(caller))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("over-limit status = %v, want %v", rr.Code, http.StatusTooManyRequests)
+	}
+	// 30 per minute refills one request every 2 seconds
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	var body ThrottledResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Error != "rate_limited" || body.Backoff.RetryAfterSeconds != 2 || body.Backoff.Strategy != "exponential" || body.Backoff.MaxSeconds != 300 {
+		t.Errorf("body = %+v, want rate_limited with a 2s exponential backoff hint", body)
+	}
+
+	// Other keys of the same user have their own bucket
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, rateLimitedRequest(&RequestContext{UserID: "user-1", APIKeyID: "key-2"}))
+	if rr.Code != http.StatusOK {
+		t.Errorf("other key status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	fake.Advance(2 * time.Second)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, rateLimitedRequest(caller))
+	if rr.Code != http.StatusOK {
+		t.Errorf("status after refill = %v, want %v", rr.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimiter_ZeroDisables(t *testing.T) {
+	handler := NewRateLimiter(0, 1).Handler(okHandler)
+	for i := 0; i < 5; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, rateLimitedRequest(&RequestContext{UserID: "user-1"}))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d status = %v, want %v", i, rr.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestRateLimiter_PassesUnauthenticated(t *testing.T) {
+	handler := NewRateLimiter(1, 1).Handler(okHandler)
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, rateLimitedRequest(nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d status = %v, want %v", i, rr.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestRateLimiter_BurstCredits(t *testing.T) {
+	fake := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := NewRateLimiter(60, 2)
+	limiter.SetClock(fake)
+	handler := limiter.Handler(okHandler)
+	trusted := &RequestContext{UserID: "user-1", APIKeyID: "key-1", BurstCredits: 3}
+
+	send := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, rateLimitedRequest(trusted))
+		return rr
+	}
+
+	// A new key starts with a full bucket and all its credits
+	for i := 0; i < 5; i++ {
+		if rr := send(); rr.Code != http.StatusOK {
+			t.Fatalf("request %d status = %v, want %v", i, rr.Code, http.StatusOK)
+		}
+	}
+	rr := send()
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("X-RateLimit-Burst-Credits") != "0" {
+		t.Fatalf("request after burst and credits = %v with %s credits, want %v with 0", rr.Code, rr.Header().Get("X-RateLimit-Burst-Credits"), http.StatusTooManyRequests)
+	}
+
+	// Refilling the bucket takes 2 seconds at one request per second; the next 2 seconds are saved as credits
+	fake.Advance(4 * time.Second)
+	rr = send()
+	if rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "1" || rr.Header().Get("X-RateLimit-Burst-Credits") != "2" {
+		t.Errorf("request after a quiet period = %v with %s remaining and %s credits, want %v with 1 and 2",
+			rr.Code, rr.Header().Get("X-RateLimit-Remaining"), rr.Header().Get("X-RateLimit-Burst-Credits"), http.StatusOK)
+	}
+
+	// Credits stop accumulating at the key's allowance
+	fake.Advance(time.Hour)
+	for i := 0; i < 5; i++ {
+		if rr := send(); rr.Code != http.StatusOK {
+			t.Fatalf("request %d after an hour status = %v, want %v", i, rr.Code, http.StatusOK)
+		}
+	}
+	if rr := send(); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("request beyond the allowance status = %v, want %v", rr.Code, http.StatusTooManyRequests)
+	}
+
+	// Keys without credits are limited to the burst
+	plain := &RequestContext{UserID: "user-1", APIKeyID: "key-2"}
+	for i := 0; i < 2; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, rateLimitedRequest(plain))
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, rateLimitedRequest(plain))
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("key without credits status = %v, want %v", rr.Code, http.StatusTooManyRequests)
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/cors"
+)
+
+// CORSPolicy describes the CORS behavior for a group of routes
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int // seconds
+
+	// CredentialedOrigins limits AllowCredentials to these exact origins; other allowed origins get no credentials
+	// Empty applies AllowCredentials to every allowed origin.
+	CredentialedOrigins []string
+}
+
+// APICORSPolicy returns the CORS policy used by the dashboard-facing API
+func APICORSPolicy(origins []string) CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Export-Passphrase", "If-Modified-Since"},
+		ExposedHeaders:   []string{"Link", "X-Total-Count"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}
+}
+
+// WebhookCORSPolicy returns the CORS policy used by ingestion routes
+// Webhooks are called server-to-server, so browsers are only allowed when explicitly configured
+func WebhookCORSPolicy(origins []string) CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"POST", "OPTIONS"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type", "Idempotency-Key"},
+		AllowCredentials: false,
+		MaxAge:           300,
+	}
+}
+
+// CORS returns a middleware enforcing the policy
+// A policy without allowed origins disables CORS entirely (no headers, preflights are not answered)
+func CORS(policy CORSPolicy) func(http.Handler) http.Handler {
+	if len(policy.AllowedOrigins) == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	if !policy.AllowCredentials || len(policy.CredentialedOrigins) == 0 {
+		return cors.Handler(policy.options())
+	}
+
+	credentialed := make(map[string]bool, len(policy.CredentialedOrigins))
+	for _, origin := range policy.CredentialedOrigins {
+		credentialed[strings.ToLower(origin)] = true
+	}
+	withCredentials := policy.options()
+	withCredentials.AllowedOrigins = policy.CredentialedOrigins
+	withoutCredentials := policy.options()
+	withoutCredentials.AllowCredentials = false
+
+	return func(next http.Handler) http.Handler {
+		credentialedNext := cors.Handler(withCredentials)(next)
+		otherNext := cors.Handler(withoutCredentials)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if credentialed[strings.ToLower(r.Header.Get("Origin"))] {
+				credentialedNext.ServeHTTP(w, r)
+				return
+			}
+			otherNext.ServeHTTP(w, r)
+		})
+	}
+}
+
+// options converts the policy to go-chi/cors options
+func (p CORSPolicy) options() cors.Options {
+	return cors.Options{
+		AllowedOrigins:   p.AllowedOrigins,
+		AllowedMethods:   p.AllowedMethods,
+		AllowedHeaders:   p.AllowedHeaders,
+		ExposedHeaders:   p.ExposedHeaders,
+		AllowCredentials: p.AllowCredentials,
+		MaxAge:           p.MaxAge,
+	}
+}
+
+// SecurityHeadersConfig holds the standard security response headers
+type SecurityHeadersConfig struct {
+	HSTSMaxAge            int    // seconds, 0 disables Strict-Transport-Security
+	ContentSecurityPolicy string // empty disables Content-Security-Policy
+	FrameOptions          string // empty disables X-Frame-Options
+}
+
+// SecurityHeaders returns a middleware that sets standard security headers on every response
+func SecurityHeaders(cfg SecurityHeadersConfig) func(http.Handler) http.Handler {
+	var hsts string
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(cfg.HSTSMaxAge) + "; includeSubDomains"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("Referrer-Policy", "no-referrer")
+			if cfg.FrameOptions != "" {
+				h.Set("X-Frame-Options", cfg.FrameOptions)
+			}
+			if cfg.ContentSecurityPolicy != "" {
+				h.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			}
+			if hsts != "" {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestCORS_APIPolicyAnswersPreflight(t *testing.T) {
+	handler := CORS(APICORSPolicy([]string{"https://dashboard.example.com"}))(okHandler)
+
+	req := httptest.NewRequest("OPTIONS", "/api/agents", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://dashboard.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want dashboard origin", got)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+	}
+}
+
+func TestCORS_CredentialedOrigins(t *testing.T) {
+	policy := APICORSPolicy([]string{"*"})
+	policy.CredentialedOrigins = []string{"https://dashboard.example.com"}
+	policy.MaxAge = 600
+	handler := CORS(policy)(okHandler)
+
+	tests := []struct {
+		origin          string
+		wantCredentials string
+	}{
+		{"https://dashboard.example.com", "true"},
+		{"https://other.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", "/api/agents", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", "GET")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got == "" {
+				t.Error("Access-Control-Allow-Origin is empty, want the origin allowed")
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+			if got := rr.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+			}
+		})
+	}
+}
+
+func TestCORS_ExposesPaginationHeaders(t *testing.T) {
+	handler := CORS(APICORSPolicy([]string{"https://dashboard.example.com"}))(okHandler)
+
+	req := httptest.NewRequest("GET", "/api/agents", nil)
+	req.Header.Set("Origin", "https://dashboard.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Expose-Headers"); got != "Link, X-Total-Count" {
+		t.Errorf("Access-Control-Expose-Headers = %q, want Link, X-Total-Count", got)
+	}
+}
+
+func TestCORS_DisabledPolicyAddsNoHeaders(t *testing.T) {
+	handler := CORS(WebhookCORSPolicy(nil))(okHandler)
+
+	req := httptest.NewRequest("POST", "/webhook/status", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want empty", got)
+	}
+}
+
+func TestCORS_WebhookPolicyRejectsUnlistedOrigin(t *testing.T) {
+	handler := CORS(WebhookCORSPolicy([]string{"https://ci.example.com"}))(okHandler)
+
+	req := httptest.NewRequest("POST", "/webhook/status", nil)
+	req.Header.Set("Origin", "https://other.example.com")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want empty", got)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      SecurityHeadersConfig
+		wantHSTS string
+		wantCSP  string
+	}{
+		{
+			name:    "defaults without HSTS",
+			cfg:     SecurityHeadersConfig{ContentSecurityPolicy: "default-src 'none'", FrameOptions: "DENY"},
+			wantCSP: "default-src 'none'",
+		},
+		{
+			name:     "with HSTS",
+			cfg:      SecurityHeadersConfig{HSTSMaxAge: 31536000},
+			wantHSTS: "max-age=31536000; includeSubDomains",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			SecurityHeaders(tt.cfg)(okHandler).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+			if got := rr.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+			}
+			if got := rr.Header().Get("Strict-Transport-Security"); got != tt.wantHSTS {
+				t.Errorf("Strict-Transport-Security = %q, want %q", got, tt.wantHSTS)
+			}
+			if got := rr.Header().Get("Content-Security-Policy"); got != tt.wantCSP {
+				t.Errorf("Content-Security-Policy = %q, want %q", got, tt.wantCSP)
+			}
+		})
+	}
+}
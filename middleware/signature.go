@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Headers carrying the HMAC signature of a webhook request
+const (
+	SignatureHeader = "X-KubeAgents-Signature"
+	TimestampHeader = "X-KubeAgents-Timestamp"
+	NonceHeader     = "X-KubeAgents-Nonce"
+)
+
+// maxNonceLength bounds the nonce header to keep the nonce set small
+const maxNonceLength = 128
+
+// SignatureVerifier verifies HMAC-signed webhook requests and rejects replays
+// The signature is "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + nonce + "." + body)).
+type SignatureVerifier struct {
+	secret    []byte
+	tolerance time.Duration
+	maxBody   int64
+	nonces    store.Store
+	clock     clock.Clock
+}
+
+// NewSignatureVerifier creates a verifier; requests older or newer than tolerance are rejected
+func NewSignatureVerifier(secret string, tolerance time.Duration, st store.Store) *SignatureVerifier {
+	return &SignatureVerifier{
+		secret:    []byte(secret),
+		tolerance: tolerance,
+		maxBody:   1 << 20,
+		nonces:    st,
+		clock:     clock.Real,
+	}
+}
+
+// SetClock replaces the clock request timestamps are checked against
+func (v *SignatureVerifier) SetClock(c clock.Clock) {
+	v.clock = c
+}
+
+// Handler verifies the signature and records the nonce before calling next
+// It must run after authentication so nonces are tracked per API key or user.
+func (v *SignatureVerifier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature := r.Header.Get(SignatureHeader)
+		timestamp := r.Header.Get(TimestampHeader)
+		nonce := r.Header.Get(NonceHeader)
+		if signature == "" || timestamp == "" || nonce == "" {
+			respondUnauthorized(w, "missing request signature")
+			return
+		}
+		if len(nonce) > maxNonceLength {
+			respondUnauthorized(w, "invalid nonce")
+			return
+		}
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			respondUnauthorized(w, "invalid signature timestamp")
+			return
+		}
+		signedAt := time.Unix(unix, 0)
+		if age := v.clock.Now().Sub(signedAt); age > v.tolerance || age < -v.tolerance {
+			respondUnauthorized(w, "signature timestamp outside freshness window")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, v.maxBody))
+		if err != nil {
+			respondSignatureError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !hmac.Equal([]byte(signature), []byte(v.sign(timestamp, nonce, body))) {
+			respondUnauthorized(w, "invalid request signature")
+			return
+		}
+
+		// The nonce only needs remembering until its timestamp leaves the freshness window
+		if err := v.nonces.SaveNonce(nonceScope(r), nonce, signedAt.Add(v.tolerance)); err != nil {
+			if errors.Is(err, store.ErrAlreadyExists) {
+				respondUnauthorized(w, "replayed request")
+				return
+			}
+			respondSignatureError(w, http.StatusInternalServerError, "failed to verify request")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sign computes the expected signature header value
+func (v *SignatureVerifier) sign(timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(timestamp + "." + nonce + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// nonceScope namespaces nonces per API key, falling back to the authenticated user
+func nonceScope(r *http.Request) string {
+	rc, ok := GetRequestContext(r.Context())
+	if !ok {
+		return "anonymous"
+	}
+	if rc.APIKeyID != "" {
+		return "key:" + rc.APIKeyID
+	}
+	return "user:" + rc.UserID
+}
+
+// respondSignatureError sends a JSON error response
+func respondSignatureError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
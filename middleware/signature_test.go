@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+const testSigningSecret = "test-signing-secret"
+
+// newSignedRequest builds a webhook request signed the way clients are documented to sign
+func newSignedRequest(body, nonce string, at time.Time) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(testSigningSecret))
+	mac.Write([]byte(timestamp + "." + nonce + "." + body))
+
+	req := httptest.NewRequest("POST", "/webhook/status", bytes.NewBufferString(body))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	caller := NewRequestContext(req, "user-001", "", nil)
+	caller.APIKeyID = "key-001"
+	return req.WithContext(WithRequestContext(req.Context(), caller))
+}
+
+func TestSignatureVerifier(t *testing.T) {
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+	body := `{"agent_id":"agent-001"}`
+
+	tests := []struct {
+		name       string
+		req        func() *http.Request
+		wantStatus int
+	}{
+		{
+			name:       "valid signature",
+			req:        func() *http.Request { return newSignedRequest(body, "nonce-1", now) },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "missing headers",
+			req: func() *http.Request {
+				return httptest.NewRequest("POST", "/webhook/status", bytes.NewBufferString(body))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered body",
+			req: func() *http.Request {
+				req := newSignedRequest(body, "nonce-2", now)
+				req.Body = httptest.NewRequest("POST", "/", bytes.NewBufferString(`{"agent_id":"agent-002"}`)).Body
+				return req
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "stale timestamp",
+			req:        func() *http.Request { return newSignedRequest(body, "nonce-3", now.Add(-10*time.Minute)) },
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "future timestamp",
+			req:        func() *http.Request { return newSignedRequest(body, "nonce-4", now.Add(10*time.Minute)) },
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := NewSignatureVerifier(testSigningSecret, 5*time.Minute, store.NewMemoryStore())
+			verifier.SetClock(clock.NewFake(now))
+			rr := httptest.NewRecorder()
+			verifier.Handler(okHandler).ServeHTTP(rr, tt.req())
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Handler() status = %v, want %v, body = %s", rr.Code, tt.wantStatus, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestSignatureVerifier_RejectsReplay(t *testing.T) {
+	verifier := NewSignatureVerifier(testSigningSecret, 5*time.Minute, store.NewMemoryStore())
+	body := `{"agent_id":"agent-001"}`
+	now := time.Now()
+
+	rr := httptest.NewRecorder()
+	verifier.Handler(okHandler).ServeHTTP(rr, newSignedRequest(body, "nonce-1", now))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("first request status = %v, want %v", rr.Code, http.StatusOK)
+	}
+
+	rr = httptest.NewRecorder()
+	verifier.Handler(okHandler).ServeHTTP(rr, newSignedRequest(body, "nonce-1", now))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("replayed request status = %v, want %v", rr.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestSignatureVerifier_PreservesBody(t *testing.T) {
+	verifier := NewSignatureVerifier(testSigningSecret, 5*time.Minute, store.NewMemoryStore())
+	body := `{"agent_id":"agent-001"}`
+
+	var got string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r.Body)
+		got = buf.String()
+	})
+
+	verifier.Handler(next).ServeHTTP(httptest.NewRecorder(), newSignedRequest(body, "nonce-1", time.Now()))
+	if got != body {
+		t.Errorf("downstream body = %q, want %q", got, body)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// WebSocketTokenProtocol prefixes the WebSocket subprotocol carrying a browser's access token, base64url-encoded
+// without padding, e.g. Sec-WebSocket-Protocol: kubeagents.v1, base64url.bearer.kubeagents.ZXlKaGJH...
+const WebSocketTokenProtocol = "base64url.bearer.kubeagents."
+
+// WebSocketCredential lets WebSocket handshakes authenticate with an access token sent as a subprotocol, as
+// browsers cannot set the Authorization header on a WebSocket; it runs before RequireAuth
+// Requests that already carry an Authorization header, or are not WebSocket handshakes, pass as they are.
+func WebSocketCredential(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+			for _, protocol := range strings.Split(value, ",") {
+				encoded, ok := strings.CutPrefix(strings.TrimSpace(protocol), WebSocketTokenProtocol)
+				if !ok {
+					continue
+				}
+				token, err := base64.RawURLEncoding.DecodeString(encoded)
+				if err != nil || len(token) == 0 {
+					respondUnauthorized(w, "invalid token protocol")
+					return
+				}
+				r = r.Clone(r.Context())
+				r.Header.Set("Authorization", "Bearer "+string(token))
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebSocketCredential(t *testing.T) {
+	token := "header.payload.signature"
+	tests := []struct {
+		name       string
+		upgrade    string
+		auth       string
+		protocol   string
+		wantAuth   string
+		wantStatus int
+	}{
+		{"token protocol", "websocket", "", "kubeagents.v1, " + WebSocketTokenProtocol + base64.RawURLEncoding.EncodeToString([]byte(token)), "Bearer " + token, http.StatusOK},
+		{"authorization header wins", "websocket", "Bearer other", WebSocketTokenProtocol + "dG9rZW4", "Bearer other", http.StatusOK},
+		{"not a handshake", "", "", WebSocketTokenProtocol + "dG9rZW4", "", http.StatusOK},
+		{"no token protocol", "websocket", "", "kubeagents.v1", "", http.StatusOK},
+		{"malformed token", "websocket", "", WebSocketTokenProtocol + "not*base64", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAuth string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAuth = r.Header.Get("Authorization")
+			})
+			req := httptest.NewRequest("GET", "/ws", nil)
+			req.Header.Set("Upgrade", tt.upgrade)
+			req.Header.Set("Authorization", tt.auth)
+			req.Header.Set("Sec-WebSocket-Protocol", tt.protocol)
+			rr := httptest.NewRecorder()
+			WebSocketCredential(next).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus || gotAuth != tt.wantAuth {
+				t.Errorf("status = %v, Authorization = %q, want %v, %q", rr.Code, gotAuth, tt.wantStatus, tt.wantAuth)
+			}
+		})
+	}
+}
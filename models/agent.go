@@ -1,39 +1,145 @@
 package models
 
 import (
-	"errors"
+	"encoding/json"
+	"fmt"
 	"time"
 )
 
+// Storage ceilings for status payload fields; deployments configure lower limits at ingestion
+const (
+	MaxStatusMessageLength = 64 << 10
+	MaxStatusContentLength = 1 << 20
+	MaxStatusMetadataBytes = 64 << 10
+)
+
+// MaxHeartbeatSampleEvery is the largest heartbeat sampling interval an agent can use
+const MaxHeartbeatSampleEvery = 1000
+
 // Agent represents an external AI Agent system
 type Agent struct {
 	AgentID    string    `json:"agent_id"`
 	UserID     string    `json:"user_id,omitempty"` // Owner user ID for data isolation
 	Name       string    `json:"name,omitempty"`
 	Source     string    `json:"source,omitempty"`
+	Kind       string    `json:"kind,omitempty"`    // One of the AgentKind constants, empty when unclassified
+	OrgID      string    `json:"org_id,omitempty"`  // Organization whose members share the agent, empty when unshared
+	Cluster    string    `json:"cluster,omitempty"` // Kubernetes cluster the agent runs in, as it last reported
+	Region     string    `json:"region,omitempty"`  // Region the agent runs in, as it last reported
 	Registered time.Time `json:"registered"`
 	LastSeen   time.Time `json:"last_seen"`
+	Version    int       `json:"version"` // Incremented by the store on every write
+
+	// HeartbeatSampleEvery keeps 1 of every N repeated running statuses; 0 or 1 keeps them all
+	HeartbeatSampleEvery int `json:"heartbeat_sample_every,omitempty"`
+
+	// Config is the owner's configuration for the agent, delivered in webhook and keepalive responses
+	// ConfigVersion increases with every change, so agents apply each version once.
+	Config        json.RawMessage `json:"config,omitempty"`
+	ConfigVersion int             `json:"config_version,omitempty"`
+
+	// State is the presence the heartbeat monitor last computed from LastSeen, one of the AgentState constants
+	State          string     `json:"state,omitempty"`
+	StateChangedAt *time.Time `json:"state_changed_at,omitempty"`
+
+	// DeletedAt is set while the agent is soft-deleted: it is hidden with its sessions and statuses
+	// until it is restored or purged
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Presence states of an agent, recorded in Agent.State
+const (
+	AgentStateOnline  = "online"  // The agent reported within its heartbeat interval
+	AgentStateStale   = "stale"   // The agent missed its heartbeat interval but is not yet offline
+	AgentStateOffline = "offline" // The agent has been silent for longer than the offline threshold
+)
+
+// agentStates lists the accepted agent states
+var agentStates = map[string]bool{
+	"":                true,
+	AgentStateOnline:  true,
+	AgentStateStale:   true,
+	AgentStateOffline: true,
+}
+
+// ValidAgentState reports whether state is one of the AgentState constants
+func ValidAgentState(state string) bool {
+	return state != "" && agentStates[state]
+}
+
+// AgentStateAt computes an agent's state at now from how long it has been silent
+// staleAfter or offlineAfter of 0 disables that state.
+func AgentStateAt(lastSeen, now time.Time, staleAfter, offlineAfter time.Duration) string {
+	silence := now.Sub(lastSeen)
+	switch {
+	case offlineAfter > 0 && silence > offlineAfter:
+		return AgentStateOffline
+	case staleAfter > 0 && silence > staleAfter:
+		return AgentStateStale
+	default:
+		return AgentStateOnline
+	}
+}
+
+// SetState records a new state, stamping when it changed; setting the current state again changes nothing
+func (a *Agent) SetState(state string, at time.Time) bool {
+	if a.State == state {
+		return false
+	}
+	a.State = state
+	a.StateChangedAt = &at
+	return true
+}
+
+// MarkSeen records that the agent reported at now, bringing it back online
+func (a *Agent) MarkSeen(now time.Time) {
+	a.LastSeen = now
+	a.SetState(AgentStateOnline, now)
 }
 
 // Validate validates Agent fields
 func (a *Agent) Validate() error {
 	if a.AgentID == "" {
-		return errors.New("agent_id is required")
+		return Required("agent_id")
 	}
 	if len(a.AgentID) > 100 {
-		return errors.New("agent_id must be 1-100 characters")
+		return Length("agent_id", "1-100", "characters")
 	}
 	if len(a.Name) > 200 {
-		return errors.New("name must be 0-200 characters")
+		return Length("name", "0-200", "characters")
 	}
 	if len(a.Source) > 200 {
-		return errors.New("source must be 0-200 characters")
+		return Length("source", "0-200", "characters")
+	}
+	if err := ValidateAgentKind("kind", a.Kind); err != nil {
+		return err
+	}
+	if len(a.OrgID) > 36 {
+		return Length("org_id", "0-36", "characters")
+	}
+	if err := ValidateLocationLabel("cluster", a.Cluster); err != nil {
+		return err
+	}
+	if err := ValidateLocationLabel("region", a.Region); err != nil {
+		return err
 	}
 	if a.Registered.IsZero() {
-		return errors.New("registered time is required")
+		return Required("registered")
 	}
 	if a.LastSeen.IsZero() {
-		return errors.New("last_seen time is required")
+		return Required("last_seen")
+	}
+	if a.HeartbeatSampleEvery < 0 || a.HeartbeatSampleEvery > MaxHeartbeatSampleEvery {
+		return Range("heartbeat_sample_every", fmt.Sprintf("0-%d", MaxHeartbeatSampleEvery))
+	}
+	if len(a.Config) > MaxAgentConfigBytes {
+		return Length("config", fmt.Sprintf("0-%d", MaxAgentConfigBytes), "bytes")
+	}
+	if a.ConfigVersion < 0 {
+		return Range("config_version", ">= 0")
+	}
+	if !agentStates[a.State] {
+		return OneOf("state", "online", "stale", "offline")
 	}
 	return nil
 }
@@ -47,69 +153,217 @@ type Session struct {
 	Expired      bool       `json:"expired"`
 	ExpiredAt    *time.Time `json:"expired_at,omitempty"`
 	TTLMinutes   int        `json:"ttl_minutes,omitempty"`
+	Group        string     `json:"group,omitempty"`      // Derived from topic grouping rules
+	Category     string     `json:"category,omitempty"`   // Derived from topic grouping rules
+	Revision     int        `json:"revision"`             // Run of the session; a report after the reopen grace period starts a new one
+	EndReason    string     `json:"end_reason,omitempty"` // Why the current run ended, one of the EndReason constants; empty while it runs
+	Version      int        `json:"version"`              // Incremented by the store on every write
+}
+
+// Reasons a session run ended, recorded in Session.EndReason
+const (
+	EndReasonAgentReported = "agent_reported" // The agent reported a final status
+	EndReasonTTLExpired    = "ttl_expired"    // The agent stopped reporting before its TTL ran out
+	EndReasonCancelled     = "cancelled"      // Its owner cancelled it
+	EndReasonCleanup       = "cleanup"        // The server closed it while repairing stored data
+	EndReasonAgentDeleted  = "agent_deleted"  // Its agent was deleted and the owner chose to close its sessions
+	EndReasonAgentOffline  = "agent_offline"  // Its agent went offline and the owner chose to close its sessions
+)
+
+// EndedBeforeExpiry reports whether the current run had already ended when the session expired,
+// either with a final status from its agent or closed with its agent, so its end was announced then
+func (s *Session) EndedBeforeExpiry() bool {
+	switch s.EndReason {
+	case EndReasonAgentReported, EndReasonAgentDeleted, EndReasonAgentOffline:
+		return true
+	}
+	return false
+}
+
+// endReasons lists the accepted end reasons
+var endReasons = map[string]bool{
+	"":                     true,
+	EndReasonAgentReported: true,
+	EndReasonTTLExpired:    true,
+	EndReasonCancelled:     true,
+	EndReasonCleanup:       true,
+	EndReasonAgentDeleted:  true,
+	EndReasonAgentOffline:  true,
+}
+
+// DefaultSessionTTLMinutes is the TTL of sessions reported without one
+const DefaultSessionTTLMinutes = 30
+
+// ExpiresAt returns when the session expires unless it is updated again
+func (s *Session) ExpiresAt() time.Time {
+	ttl := s.TTLMinutes
+	if ttl == 0 {
+		ttl = DefaultSessionTTLMinutes
+	}
+	return s.LastUpdated.Add(time.Duration(ttl) * time.Minute)
+}
+
+// RunningSession is an active session whose latest status is running
+type RunningSession struct {
+	Session   *Session
+	AgentName string
+	Latest    *AgentStatus // Latest status of the current revision
+	Started   time.Time    // First status of the current revision
 }
 
 // Validate validates Session fields
 func (s *Session) Validate() error {
 	if s.AgentID == "" {
-		return errors.New("agent_id is required")
+		return Required("agent_id")
 	}
 	if s.SessionTopic == "" {
-		return errors.New("session_topic is required")
+		return Required("session_topic")
 	}
 	if len(s.SessionTopic) > 500 {
-		return errors.New("session_topic must be 1-500 characters")
+		return Length("session_topic", "1-500", "characters")
 	}
 	if s.Created.IsZero() {
-		return errors.New("created time is required")
+		return Required("created")
 	}
 	if s.LastUpdated.IsZero() {
-		return errors.New("last_updated time is required")
+		return Required("last_updated")
 	}
 	if s.LastUpdated.Before(s.Created) {
-		return errors.New("last_updated must be >= created")
+		return Invalid("last_updated", "last_updated must be >= created")
 	}
 	if s.TTLMinutes < 0 || s.TTLMinutes > 1440 {
-		return errors.New("ttl_minutes must be 0 or 1-1440")
+		return Range("ttl_minutes", "0 or 1-1440")
+	}
+	if len(s.Group) > 100 {
+		return Length("group", "0-100", "characters")
+	}
+	if len(s.Category) > 100 {
+		return Length("category", "0-100", "characters")
+	}
+	if s.Revision < 0 {
+		return Range("revision", ">= 0")
+	}
+	if !endReasons[s.EndReason] {
+		return OneOf("end_reason", "agent_reported", "ttl_expired", "cancelled", "cleanup")
 	}
 	return nil
 }
 
 // AgentStatus represents Agent status entity, recording Session status history
 type AgentStatus struct {
-	AgentID      string    `json:"agent_id"`
-	SessionTopic string    `json:"session_topic"`
-	Status       string    `json:"status"`
-	Timestamp    time.Time `json:"timestamp"`
-	Message      string    `json:"message,omitempty"`
-	Content      string    `json:"content,omitempty"`
+	ID            int64           `json:"id,omitempty"` // Set by the store when the status is added
+	AgentID       string          `json:"agent_id"`
+	SessionTopic  string          `json:"session_topic"`
+	Status        string          `json:"status"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Message       string          `json:"message,omitempty"`
+	Content       string          `json:"content,omitempty"`
+	ContentFormat string          `json:"content_format,omitempty"` // How content is written, one of the ContentFormat constants; empty is text
+	Metadata      json.RawMessage `json:"metadata,omitempty"`
+	Revision      int             `json:"revision"` // Session revision the status was reported for
+	Origin        string          `json:"origin"`   // Who recorded the status, one of the StatusOrigin constants
+}
+
+// Formats status content is written in
+const (
+	ContentFormatText     = "text"
+	ContentFormatMarkdown = "markdown" // Sanitized and cut to fit before it is embedded in notifications
+)
+
+// ValidateContentFormat checks an optional content format, naming field in its errors
+func ValidateContentFormat(field, format string) error {
+	switch format {
+	case "", ContentFormatText, ContentFormatMarkdown:
+		return nil
+	default:
+		return OneOf(field, ContentFormatText, ContentFormatMarkdown)
+	}
+}
+
+// Origins of a status in the history
+const (
+	StatusOriginAgent  = "agent"  // The agent reported it
+	StatusOriginServer = "server" // The server recorded it for an event it inferred, e.g. the session expiring
+)
+
+// Reserved statuses the server records in session histories; agents cannot report them
+const (
+	StatusExpired         = "expired"           // The session TTL ran out before the agent reported a final status
+	StatusCancelledByUser = "cancelled_by_user" // The session's owner cancelled it
+	StatusAgentOffline    = "agent_offline"     // The session was closed because its agent went offline
+	StatusAgentDeleted    = "agent_deleted"     // The session was closed because its agent was deleted
+)
+
+// agentStatuses lists the statuses agents report
+var agentStatuses = map[string]bool{"running": true, "success": true, "failed": true, "pending": true}
+
+// IsServerStatus reports whether status is one of the reserved statuses only the server records
+func IsServerStatus(status string) bool {
+	switch status {
+	case StatusExpired, StatusCancelledByUser, StatusAgentOffline, StatusAgentDeleted:
+		return true
+	}
+	return false
+}
+
+// ValidStatus reports whether status is one a session history can hold, reported by an agent or recorded by the server
+func ValidStatus(status string) bool {
+	return agentStatuses[status] || IsServerStatus(status)
+}
+
+// NewServerStatus creates a status the server records for the current run of a session
+func NewServerStatus(session *Session, status, message string, at time.Time) *AgentStatus {
+	return &AgentStatus{
+		AgentID:      session.AgentID,
+		SessionTopic: session.SessionTopic,
+		Status:       status,
+		Timestamp:    at,
+		Message:      message,
+		Revision:     session.Revision,
+		Origin:       StatusOriginServer,
+	}
+}
+
+// FromServer reports whether the server recorded the status rather than its agent
+func (as *AgentStatus) FromServer() bool {
+	return as.Origin == StatusOriginServer
 }
 
 // Validate validates AgentStatus fields
 func (as *AgentStatus) Validate() error {
 	if as.AgentID == "" {
-		return errors.New("agent_id is required")
+		return Required("agent_id")
 	}
 	if as.SessionTopic == "" {
-		return errors.New("session_topic is required")
-	}
-	validStatuses := map[string]bool{
-		"running": true,
-		"success": true,
-		"failed":  true,
-		"pending": true,
+		return Required("session_topic")
 	}
-	if !validStatuses[as.Status] {
-		return errors.New("status must be one of: running, success, failed, pending")
+	switch as.Origin {
+	case "", StatusOriginAgent:
+		if !agentStatuses[as.Status] {
+			return OneOf("status", "running", "success", "failed", "pending")
+		}
+	case StatusOriginServer:
+		// The server may also record a status agents report, e.g. failing a session closed with its agent
+		if !agentStatuses[as.Status] && !IsServerStatus(as.Status) {
+			return OneOf("status", "running", "success", "failed", "pending", "expired", "cancelled_by_user", "agent_offline", "agent_deleted")
+		}
+	default:
+		return OneOf("origin", "agent", "server")
 	}
 	if as.Timestamp.IsZero() {
-		return errors.New("timestamp is required")
+		return Required("timestamp")
+	}
+	if len(as.Message) > MaxStatusMessageLength {
+		return Length("message", fmt.Sprintf("0-%d", MaxStatusMessageLength), "characters")
+	}
+	if len(as.Content) > MaxStatusContentLength {
+		return Length("content", fmt.Sprintf("0-%d", MaxStatusContentLength), "characters")
 	}
-	if len(as.Message) > 1000 {
-		return errors.New("message must be 0-1000 characters")
+	if err := ValidateContentFormat("content_format", as.ContentFormat); err != nil {
+		return err
 	}
-	if len(as.Content) > 10000 {
-		return errors.New("content must be 0-10000 characters")
+	if len(as.Metadata) > MaxStatusMetadataBytes {
+		return Length("metadata", fmt.Sprintf("0-%d", MaxStatusMetadataBytes), "bytes")
 	}
 	return nil
 }
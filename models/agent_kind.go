@@ -0,0 +1,56 @@
+package models
+
+// Agent kinds, a curated taxonomy recorded in Agent.Kind so dashboards group agents the same way for every user
+// Source stays free-form and names the software; the kind says what sort of agent it is.
+const (
+	AgentKindCI       = "ci"        // Build and deployment pipelines
+	AgentKindCron     = "cron"      // Scheduled jobs
+	AgentKindLLMAgent = "llm-agent" // LLM-driven agents and assistants
+	AgentKindOperator = "operator"  // Controllers and operators reconciling infrastructure, and alert sources
+	AgentKindCustom   = "custom"    // Anything else
+)
+
+// AgentKindInfo describes an agent kind for display
+// Icon names an icon of the Lucide set, which the dashboard bundles.
+type AgentKindInfo struct {
+	Kind        string `json:"kind"`
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+// AgentKinds lists the agent kinds in display order
+var AgentKinds = []AgentKindInfo{
+	{Kind: AgentKindCI, Label: "CI/CD", Description: "Build and deployment pipelines", Icon: "git-branch"},
+	{Kind: AgentKindCron, Label: "Scheduled job", Description: "Jobs started on a schedule", Icon: "clock"},
+	{Kind: AgentKindLLMAgent, Label: "LLM agent", Description: "LLM-driven agents and assistants", Icon: "bot"},
+	{Kind: AgentKindOperator, Label: "Operator", Description: "Controllers, operators and alert sources", Icon: "server-cog"},
+	{Kind: AgentKindCustom, Label: "Custom", Description: "Agents of any other kind", Icon: "puzzle"},
+}
+
+// ValidAgentKind reports whether kind is one of the AgentKind constants
+func ValidAgentKind(kind string) bool {
+	for _, info := range AgentKinds {
+		if info.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// agentKinds returns the agent kinds for error messages in the order they are listed
+func agentKinds() []string {
+	kinds := make([]string, len(AgentKinds))
+	for i, info := range AgentKinds {
+		kinds[i] = info.Kind
+	}
+	return kinds
+}
+
+// ValidateAgentKind returns an error naming the accepted kinds unless kind is empty or one of them
+func ValidateAgentKind(field, kind string) error {
+	if kind == "" || ValidAgentKind(kind) {
+		return nil
+	}
+	return OneOf(field, agentKinds()...)
+}
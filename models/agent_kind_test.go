@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestValidateAgentKind(t *testing.T) {
+	for _, kind := range []string{"", AgentKindCI, AgentKindCron, AgentKindLLMAgent, AgentKindOperator, AgentKindCustom} {
+		if err := ValidateAgentKind("kind", kind); err != nil {
+			t.Errorf("ValidateAgentKind(%q) error = %v, want nil", kind, err)
+		}
+	}
+
+	err := ValidateAgentKind("agent_kind", "CI")
+	if want := "agent_kind must be one of: ci, cron, llm-agent, operator, custom"; err == nil || err.Error() != want {
+		t.Errorf("ValidateAgentKind(CI) error = %v, want %q", err, want)
+	}
+	if ValidAgentKind("") {
+		t.Error("ValidAgentKind(\"\") = true, want false")
+	}
+}
+
+func TestAgentKinds_Described(t *testing.T) {
+	for _, info := range AgentKinds {
+		if info.Label == "" || info.Description == "" || info.Icon == "" {
+			t.Errorf("AgentKinds entry %q = %+v, want label, description and icon", info.Kind, info)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MaxLocationLabelLength is the longest cluster or region name, the limit of a Kubernetes label value
+const MaxLocationLabelLength = 63
+
+// locationLabelRegex matches Kubernetes label values: alphanumerics, with '-', '_' or '.' in between
+var locationLabelRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+
+// ValidateLocationLabel returns an error unless value is empty or a valid cluster or region name
+// Names follow Kubernetes label values, so agents can report e.g. the topology.kubernetes.io/region label as is.
+func ValidateLocationLabel(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > MaxLocationLabelLength || !locationLabelRegex.MatchString(value) {
+		return Invalid(field, fmt.Sprintf("%s must be 1-%d alphanumeric characters, '-', '_' or '.', starting and ending with an alphanumeric", field, MaxLocationLabelLength))
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateLocationLabel(t *testing.T) {
+	for _, value := range []string{"", "prod-eu-1", "us-east-1", "gke_project_zone.cluster", "A1"} {
+		if err := ValidateLocationLabel("cluster", value); err != nil {
+			t.Errorf("ValidateLocationLabel(%q) error = %v, want nil", value, err)
+		}
+	}
+	for _, value := range []string{"-prod", "prod-", "prod eu", "prod/eu", strings.Repeat("a", MaxLocationLabelLength+1)} {
+		if err := ValidateLocationLabel("cluster", value); err == nil || !strings.HasPrefix(err.Error(), "cluster must be") {
+			t.Errorf("ValidateLocationLabel(%q) error = %v, want a cluster error", value, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -76,6 +78,46 @@ func TestAgent_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "heartbeat sampling too large",
+			agent: Agent{
+				AgentID:              "agent-001",
+				Registered:           time.Now(),
+				LastSeen:             time.Now(),
+				HeartbeatSampleEvery: MaxHeartbeatSampleEvery + 1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown state",
+			agent: Agent{
+				AgentID:    "agent-001",
+				Registered: time.Now(),
+				LastSeen:   time.Now(),
+				State:      "asleep",
+			},
+			wantErr: true,
+		},
+		{
+			name: "known kind",
+			agent: Agent{
+				AgentID:    "agent-001",
+				Registered: time.Now(),
+				LastSeen:   time.Now(),
+				Kind:       AgentKindLLMAgent,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown kind",
+			agent: Agent{
+				AgentID:    "agent-001",
+				Registered: time.Now(),
+				LastSeen:   time.Now(),
+				Kind:       "robot",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -88,6 +130,30 @@ func TestAgent_Validate(t *testing.T) {
 	}
 }
 
+func TestAgentStateAt(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		silence      time.Duration
+		staleAfter   time.Duration
+		offlineAfter time.Duration
+		want         string
+	}{
+		{time.Minute, 5 * time.Minute, 15 * time.Minute, AgentStateOnline},
+		{5 * time.Minute, 5 * time.Minute, 15 * time.Minute, AgentStateOnline},
+		{6 * time.Minute, 5 * time.Minute, 15 * time.Minute, AgentStateStale},
+		{16 * time.Minute, 5 * time.Minute, 15 * time.Minute, AgentStateOffline},
+		{16 * time.Minute, 0, 15 * time.Minute, AgentStateOffline},
+		{10 * time.Minute, 0, 15 * time.Minute, AgentStateOnline},
+		{time.Hour, 5 * time.Minute, 0, AgentStateStale},
+	}
+
+	for _, tt := range tests {
+		if got := AgentStateAt(now.Add(-tt.silence), now, tt.staleAfter, tt.offlineAfter); got != tt.want {
+			t.Errorf("AgentStateAt(silent %v, stale %v, offline %v) = %q, want %q", tt.silence, tt.staleAfter, tt.offlineAfter, got, tt.want)
+		}
+	}
+}
+
 func TestSession_Validate(t *testing.T) {
 	now := time.Now()
 	tests := []struct {
@@ -248,7 +314,7 @@ func TestAgentStatus_Validate(t *testing.T) {
 				SessionTopic: "task-001",
 				Status:       "running",
 				Timestamp:    now,
-				Message:      string(make([]byte, 1001)),
+				Message:      string(make([]byte, MaxStatusMessageLength+1)),
 			},
 			wantErr: true,
 		},
@@ -259,7 +325,7 @@ func TestAgentStatus_Validate(t *testing.T) {
 				SessionTopic: "task-001",
 				Status:       "running",
 				Timestamp:    now,
-				Content:      string(make([]byte, 10001)),
+				Content:      string(make([]byte, MaxStatusContentLength+1)),
 			},
 			wantErr: true,
 		},
@@ -293,6 +359,50 @@ func TestAgentStatus_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "server status reported by agent",
+			agentStatus: AgentStatus{
+				AgentID:      "agent-001",
+				SessionTopic: "task-001",
+				Status:       StatusExpired,
+				Timestamp:    now,
+				Origin:       StatusOriginAgent,
+			},
+			wantErr: true,
+		},
+		{
+			name: "server status recorded by server",
+			agentStatus: AgentStatus{
+				AgentID:      "agent-001",
+				SessionTopic: "task-001",
+				Status:       StatusCancelledByUser,
+				Timestamp:    now,
+				Origin:       StatusOriginServer,
+			},
+			wantErr: false,
+		},
+		{
+			name: "agent status recorded by server",
+			agentStatus: AgentStatus{
+				AgentID:      "agent-001",
+				SessionTopic: "task-001",
+				Status:       "failed",
+				Timestamp:    now,
+				Origin:       StatusOriginServer,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid origin",
+			agentStatus: AgentStatus{
+				AgentID:      "agent-001",
+				SessionTopic: "task-001",
+				Status:       "running",
+				Timestamp:    now,
+				Origin:       "platform",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -304,3 +414,31 @@ func TestAgentStatus_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateAgentConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{"well-known keys", `{"report_interval_seconds":30,"ttl_minutes":60,"log_level":"debug"}`, false},
+		{"custom keys pass through", `{"feature_flags":{"beta":true}}`, false},
+		{"empty object", `{}`, false},
+		{"not an object", `[1,2]`, true},
+		{"null", `null`, true},
+		{"interval out of range", `{"report_interval_seconds":0}`, true},
+		{"interval not an integer", `{"report_interval_seconds":"30s"}`, true},
+		{"ttl out of range", `{"ttl_minutes":1441}`, true},
+		{"unknown log level", `{"log_level":"trace"}`, true},
+		{"too large", `{"blob":"` + strings.Repeat("x", MaxAgentConfigBytes) + `"}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAgentConfig(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAgentConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
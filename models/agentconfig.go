@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MaxAgentConfigBytes is the largest configuration an agent can be given
+const MaxAgentConfigBytes = 16 << 10
+
+// Log levels agents accept in their configuration
+var agentLogLevels = map[string]bool{
+	"debug": true,
+	"info":  true,
+	"warn":  true,
+	"error": true,
+}
+
+// AgentConfig holds the configuration keys the server understands
+// Agents receive the whole JSON object, so other keys pass through untouched.
+type AgentConfig struct {
+	ReportIntervalSeconds *int   `json:"report_interval_seconds,omitempty"`
+	TTLMinutes            *int   `json:"ttl_minutes,omitempty"`
+	LogLevel              string `json:"log_level,omitempty"`
+}
+
+// ValidateAgentConfig checks that raw is a JSON object whose well-known keys hold valid values
+func ValidateAgentConfig(raw json.RawMessage) error {
+	if len(raw) > MaxAgentConfigBytes {
+		return Length("config", fmt.Sprintf("0-%d", MaxAgentConfigBytes), "bytes")
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+		return Invalid("config", "config must be a JSON object")
+	}
+
+	var config AgentConfig
+	if err := json.Unmarshal(raw, &config); err != nil {
+		return Invalid("config", "config report_interval_seconds and ttl_minutes must be integers and log_level a string")
+	}
+	if config.ReportIntervalSeconds != nil && (*config.ReportIntervalSeconds < 1 || *config.ReportIntervalSeconds > 86400) {
+		return Invalid("config", "config report_interval_seconds must be 1-86400")
+	}
+	if config.TTLMinutes != nil && (*config.TTLMinutes < 1 || *config.TTLMinutes > 1440) {
+		return Invalid("config", "config ttl_minutes must be 1-1440")
+	}
+	if config.LogLevel != "" && !agentLogLevels[config.LogLevel] {
+		return Invalid("config", "config log_level must be one of: debug, info, warn, error")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// Alert rule kinds
+const (
+	AlertRuleStatus        = "status"         // An agent reports Status for a session whose previous status differed
+	AlertRuleRunningLonger = "running_longer" // A session run is still running after DurationMinutes
+	AlertRuleAgentOffline  = "agent_offline"  // An agent has not reported for DurationMinutes
+)
+
+// AlertRule is a user-defined condition on their agents and sessions that sends a notification when met
+type AlertRule struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"user_id"`
+	Name            string    `json:"name"`
+	Kind            string    `json:"kind"`
+	AgentID         string    `json:"agent_id,omitempty"`         // Empty applies to all of the user's agents
+	TopicPattern    string    `json:"topic_pattern,omitempty"`    // Regular expression; empty matches every topic
+	Status          string    `json:"status,omitempty"`           // Status a status rule matches
+	DurationMinutes int       `json:"duration_minutes,omitempty"` // Threshold of running_longer and agent_offline rules
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Validate validates AlertRule fields
+func (r *AlertRule) Validate() error {
+	if r.ID == "" {
+		return Required("id")
+	}
+	if len(r.ID) > 36 {
+		return Length("id", "<= 36", "characters")
+	}
+	if r.UserID == "" {
+		return Required("user_id")
+	}
+	if r.Name == "" {
+		return Required("name")
+	}
+	if len(r.Name) > 100 {
+		return Length("name", "<= 100", "characters")
+	}
+	if len(r.AgentID) > 100 {
+		return Length("agent_id", "0-100", "characters")
+	}
+	if len(r.TopicPattern) > 500 {
+		return Length("topic_pattern", "0-500", "characters")
+	}
+	if _, err := regexp.Compile(r.TopicPattern); err != nil {
+		return Invalid("topic_pattern", "topic_pattern must be a valid regular expression")
+	}
+	if r.DurationMinutes < 0 {
+		return Range("duration_minutes", ">= 0")
+	}
+
+	switch r.Kind {
+	case AlertRuleStatus:
+		if !agentStatuses[r.Status] {
+			return OneOf("status", "running", "success", "failed", "pending")
+		}
+		if r.DurationMinutes != 0 {
+			return Invalid("duration_minutes", "duration_minutes is not used by status rules")
+		}
+	case AlertRuleRunningLonger:
+		if r.Status != "" {
+			return Invalid("status", "status is only used by status rules")
+		}
+		if r.DurationMinutes == 0 {
+			return Required("duration_minutes")
+		}
+	case AlertRuleAgentOffline:
+		if r.Status != "" {
+			return Invalid("status", "status is only used by status rules")
+		}
+		if r.TopicPattern != "" {
+			return Invalid("topic_pattern", "topic_pattern is not used by agent_offline rules")
+		}
+		if r.DurationMinutes == 0 {
+			return Required("duration_minutes")
+		}
+	default:
+		return OneOf("kind", "status", "running_longer", "agent_offline")
+	}
+	return nil
+}
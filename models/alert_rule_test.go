@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertRule_Validate(t *testing.T) {
+	valid := func() AlertRule {
+		return AlertRule{
+			ID:        "rule-001",
+			UserID:    "user-001",
+			Name:      "Any failure",
+			Kind:      AlertRuleStatus,
+			Status:    "failed",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*AlertRule)
+		wantErr bool
+	}{
+		{"valid status rule", func(r *AlertRule) {}, false},
+		{"server status", func(r *AlertRule) { r.Status = StatusExpired }, true},
+		{"running longer", func(r *AlertRule) { r.Kind, r.Status, r.DurationMinutes = AlertRuleRunningLonger, "", 30 }, false},
+		{"agent offline", func(r *AlertRule) { r.Kind, r.Status, r.DurationMinutes = AlertRuleAgentOffline, "", 10 }, false},
+		{"missing name", func(r *AlertRule) { r.Name = "" }, true},
+		{"unknown kind", func(r *AlertRule) { r.Kind = "sometimes" }, true},
+		{"unknown status", func(r *AlertRule) { r.Status = "exploded" }, true},
+		{"status with duration", func(r *AlertRule) { r.DurationMinutes = 5 }, true},
+		{"invalid topic pattern", func(r *AlertRule) { r.TopicPattern = "(" }, true},
+		{"running longer without duration", func(r *AlertRule) { r.Kind, r.Status = AlertRuleRunningLonger, "" }, true},
+		{"offline with topic pattern", func(r *AlertRule) {
+			r.Kind, r.Status, r.DurationMinutes, r.TopicPattern = AlertRuleAgentOffline, "", 10, "^build"
+		}, true},
+		{"negative duration", func(r *AlertRule) { r.Kind, r.Status, r.DurationMinutes = AlertRuleRunningLonger, "", -1 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid()
+			tt.modify(&rule)
+			if err := rule.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
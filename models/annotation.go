@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// MaxAnnotationLinks is the most links one annotation may carry
+const MaxAnnotationLinks = 10
+
+// StatusAnnotation is a user's post-mortem note on one status entry
+// Annotations are stored apart from agent-reported data, so agents cannot change them.
+type StatusAnnotation struct {
+	ID           string    `json:"id"`
+	StatusID     int64     `json:"status_id"`
+	AgentID      string    `json:"agent_id"`
+	SessionTopic string    `json:"session_topic"`
+	UserID       string    `json:"-"`                      // Author
+	Investigator string    `json:"investigator,omitempty"` // Who investigated; defaults to the author's email
+	RootCause    string    `json:"root_cause,omitempty"`
+	Note         string    `json:"note,omitempty"`
+	Links        []string  `json:"links,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Validate validates StatusAnnotation fields
+func (a *StatusAnnotation) Validate() error {
+	if a.ID == "" {
+		return Required("id")
+	}
+	if a.StatusID <= 0 {
+		return Required("status_id")
+	}
+	if a.AgentID == "" || a.SessionTopic == "" {
+		return Invalid("agent_id", "agent_id and session_topic are required")
+	}
+	if a.UserID == "" {
+		return Required("user_id")
+	}
+	if a.RootCause == "" && a.Note == "" && len(a.Links) == 0 {
+		return &FieldError{Code: CodeRequired, Message: "root_cause, note or links is required"}
+	}
+	if len(a.Investigator) > 200 {
+		return Length("investigator", "0-200", "characters")
+	}
+	if len(a.RootCause) > 2000 {
+		return Length("root_cause", "0-2000", "characters")
+	}
+	if len(a.Note) > 10000 {
+		return Length("note", "0-10000", "characters")
+	}
+	if len(a.Links) > MaxAnnotationLinks {
+		return Invalid("links", fmt.Sprintf("at most %d links are allowed", MaxAnnotationLinks))
+	}
+	for _, link := range a.Links {
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(link) > 2000 {
+			return Invalid("links", fmt.Sprintf("link %q must be an http or https URL", link))
+		}
+	}
+	if a.CreatedAt.IsZero() {
+		return Required("created_at")
+	}
+	return nil
+}
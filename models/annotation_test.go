@@ -0,0 +1,43 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatusAnnotation_Validate(t *testing.T) {
+	valid := func() StatusAnnotation {
+		return StatusAnnotation{
+			ID:           "annotation-1",
+			StatusID:     1,
+			AgentID:      "agent-001",
+			SessionTopic: "task-001",
+			UserID:       "user-1",
+			RootCause:    "expired token",
+			CreatedAt:    time.Now(),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(a *StatusAnnotation)
+		wantErr bool
+	}{
+		{"valid", func(a *StatusAnnotation) {}, false},
+		{"links only", func(a *StatusAnnotation) { a.RootCause, a.Links = "", []string{"https://example.com/run/1"} }, false},
+		{"nothing to record", func(a *StatusAnnotation) { a.RootCause = "" }, true},
+		{"missing status", func(a *StatusAnnotation) { a.StatusID = 0 }, true},
+		{"non-http link", func(a *StatusAnnotation) { a.Links = []string{"ftp://example.com/log"} }, true},
+		{"too many links", func(a *StatusAnnotation) { a.Links = make([]string, MaxAnnotationLinks+1) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annotation := valid()
+			tt.modify(&annotation)
+			if err := annotation.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
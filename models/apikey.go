@@ -1,61 +1,84 @@
 package models
 
 import (
-	"errors"
+	"fmt"
 	"time"
 )
 
 // APIKey represents a long-lived API key for external integrations
 type APIKey struct {
-	ID         string     `json:"id"`
-	UserID     string     `json:"user_id"`
-	Name       string     `json:"name"`
-	KeyHash    string     `json:"-"`          // Never expose in JSON, stored as hash
-	KeyPrefix  string     `json:"key_prefix"` // First 8 chars for identification
-	ExpiresAt  *time.Time `json:"expires_at"` // nil means never expires
-	LastUsedAt *time.Time `json:"last_used_at"`
-	CreatedAt  time.Time  `json:"created_at"`
-	Revoked    bool       `json:"revoked"`
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	Name         string     `json:"name"`
+	KeyHash      string     `json:"-"`          // Never expose in JSON, stored as hash
+	KeyPrefix    string     `json:"key_prefix"` // First 8 chars for identification
+	ExpiresAt    *time.Time `json:"expires_at"` // nil means never expires
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Revoked      bool       `json:"revoked"`
+	AgentID      string     `json:"agent_id,omitempty"`      // Restricts the key to reporting for one agent, as on agent tokens
+	BurstCredits int        `json:"burst_credits,omitempty"` // Extra webhook requests the key may save up while under the rate limit
+	FieldCasing  string     `json:"field_casing,omitempty"`  // Casing of JSON field names in responses to the key, one of the FieldCasing constants; snake when empty
 }
 
+// Casings of JSON field names in responses
+const (
+	FieldCasingSnake = "snake" // user_id, as every field is declared
+	FieldCasingCamel = "camel" // userId, for integrations expecting camelCase
+)
+
+// MaxAPIKeyBurstCredits bounds the burst credits of an API key
+const MaxAPIKeyBurstCredits = 10000
+
 // Validate validates APIKey fields
 func (k *APIKey) Validate() error {
 	if k.ID == "" {
-		return errors.New("id is required")
+		return Required("id")
 	}
 	if len(k.ID) > 36 {
-		return errors.New("id must be <= 36 characters")
+		return Length("id", "<= 36", "characters")
 	}
 	if k.UserID == "" {
-		return errors.New("user_id is required")
+		return Required("user_id")
 	}
 	if k.Name == "" {
-		return errors.New("name is required")
+		return Required("name")
 	}
 	if len(k.Name) > 100 {
-		return errors.New("name must be <= 100 characters")
+		return Length("name", "<= 100", "characters")
 	}
 	if k.KeyHash == "" {
-		return errors.New("key_hash is required")
+		return Required("key_hash")
 	}
 	if k.KeyPrefix == "" {
-		return errors.New("key_prefix is required")
+		return Required("key_prefix")
 	}
 	if len(k.KeyPrefix) != 8 {
-		return errors.New("key_prefix must be exactly 8 characters")
+		return Length("key_prefix", "exactly 8", "characters")
+	}
+	if len(k.AgentID) > 100 {
+		return Length("agent_id", "<= 100", "characters")
+	}
+	if k.BurstCredits < 0 || k.BurstCredits > MaxAPIKeyBurstCredits {
+		return Range("burst_credits", fmt.Sprintf("0-%d", MaxAPIKeyBurstCredits))
+	}
+	switch k.FieldCasing {
+	case "", FieldCasingSnake, FieldCasingCamel:
+	default:
+		return OneOf("field_casing", FieldCasingSnake, FieldCasingCamel)
 	}
 	return nil
 }
 
-// IsExpired checks if the API key has expired
-func (k *APIKey) IsExpired() bool {
+// IsExpired checks if the API key has expired at now
+func (k *APIKey) IsExpired(now time.Time) bool {
 	if k.ExpiresAt == nil {
 		return false
 	}
-	return time.Now().After(*k.ExpiresAt)
+	return now.After(*k.ExpiresAt)
 }
 
-// IsValid checks if the API key is valid (not revoked and not expired)
-func (k *APIKey) IsValid() bool {
-	return !k.Revoked && !k.IsExpired()
+// IsValid checks if the API key is valid at now (not revoked and not expired)
+func (k *APIKey) IsValid(now time.Time) bool {
+	return !k.Revoked && !k.IsExpired(now)
 }
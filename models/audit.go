@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// AuditEvent records an admin request that read or changed data across tenants
+type AuditEvent struct {
+	ID         int64     `json:"id"` // Set by the store when the event is added
+	ActorID    string    `json:"actor_id"`
+	ActorEmail string    `json:"actor_email"`
+	Action     string    `json:"action"`           // Method and route, e.g. "GET /api/admin/tenants/{user_id}"
+	Target     string    `json:"target,omitempty"` // Tenant the request drilled into, if any
+	Query      string    `json:"query,omitempty"`  // Raw query string, e.g. the search terms
+	StatusCode int       `json:"status_code"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Validate validates AuditEvent fields
+func (e *AuditEvent) Validate() error {
+	if e.ActorID == "" {
+		return Required("actor_id")
+	}
+	if e.Action == "" {
+		return Required("action")
+	}
+	if len(e.Action) > 500 {
+		return Length("action", "1-500", "characters")
+	}
+	if len(e.Target) > 100 {
+		return Length("target", "0-100", "characters")
+	}
+	if len(e.Query) > 2000 {
+		return Length("query", "0-2000", "characters")
+	}
+	if e.CreatedAt.IsZero() {
+		return Required("created_at")
+	}
+	return nil
+}
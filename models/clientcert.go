@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// ClientCertificate maps a TLS client certificate, identified by its fingerprint, to the user it authenticates
+type ClientCertificate struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"-"`
+	Name        string    `json:"name"`
+	Fingerprint string    `json:"fingerprint"`        // Lowercase hex SHA-256 of the DER-encoded certificate
+	AgentID     string    `json:"agent_id,omitempty"` // Restricts the certificate to reporting for one agent
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Validate validates ClientCertificate fields
+func (c *ClientCertificate) Validate() error {
+	if c.ID == "" {
+		return Required("id")
+	}
+	if c.UserID == "" {
+		return Required("user_id")
+	}
+	if c.Name == "" {
+		return Required("name")
+	}
+	if len(c.Name) > 100 {
+		return Length("name", "<= 100", "characters")
+	}
+	if !isSHA256Hex(c.Fingerprint) {
+		return Invalid("fingerprint", "fingerprint must be a lowercase hex SHA-256 digest")
+	}
+	if len(c.AgentID) > 100 {
+		return Length("agent_id", "<= 100", "characters")
+	}
+	return nil
+}
+
+// isSHA256Hex reports whether s is 64 lowercase hex characters
+func isSHA256Hex(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
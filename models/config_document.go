@@ -0,0 +1,80 @@
+package models
+
+import "fmt"
+
+// ConfigDocumentVersion is the version of the configuration document format written by exports
+const ConfigDocumentVersion = 1
+
+// ConfigDocument is a user's monitoring configuration as one document, exported and imported as a whole
+// so it can be kept in version control. Importing it replaces every section: a section left out is cleared.
+// Identifiers and timestamps are left out, so documents only differ where the configuration does.
+type ConfigDocument struct {
+	Version          int                 `json:"version"`
+	Notifications    ConfigNotifications `json:"notifications"`
+	Watchlist        []ConfigWatchItem   `json:"watchlist"`
+	SLAs             []ConfigSLA         `json:"slas"` // Matched to existing SLAs by name, which keeps their breaches
+	SessionAutoClose SessionAutoClose    `json:"session_auto_close"`
+}
+
+// ConfigNotifications are the notification rules and receivers of a user
+type ConfigNotifications struct {
+	WebhookURL   string                      `json:"webhook_url,omitempty"` // The profile's notification_webhook_url
+	Mentions     []MentionRule               `json:"mentions"`
+	Destinations []NotificationDestination   `json:"destinations"`
+	Settings     *ConfigNotificationSettings `json:"settings,omitempty"` // Absent when the user has no notification settings
+}
+
+// ConfigNotificationSettings are NotificationSettings without their owner and timestamps
+type ConfigNotificationSettings struct {
+	WebhookURL           string             `json:"webhook_url,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Transitions          []StatusTransition `json:"transitions,omitempty"`
+	FirstFailureOnly     bool               `json:"first_failure_only,omitempty"`
+	Template             string             `json:"template,omitempty"`
+	DedupMinutes         int                `json:"dedup_minutes,omitempty"`
+	EscalateAfterMinutes int                `json:"escalate_after_minutes,omitempty"`
+}
+
+// ConfigWatchItem is a WatchItem without its owner and timestamps
+type ConfigWatchItem struct {
+	AgentID                string `json:"agent_id"`
+	SessionTopic           string `json:"session_topic,omitempty"`
+	NotificationWebhookURL string `json:"notification_webhook_url,omitempty"`
+	MuteNotifications      bool   `json:"mute_notifications,omitempty"`
+}
+
+// ConfigSLA is an SLA without its ID, owner and timestamps
+type ConfigSLA struct {
+	Name               string  `json:"name"`
+	AgentID            string  `json:"agent_id,omitempty"`
+	TopicPattern       string  `json:"topic_pattern,omitempty"`
+	MaxDurationMinutes int     `json:"max_duration_minutes,omitempty"`
+	MaxFailureRate     float64 `json:"max_failure_rate,omitempty"`
+}
+
+// Validate checks the document's version and that its watch items and SLAs are unique;
+// each entry is validated as the record it is imported as
+func (d *ConfigDocument) Validate() error {
+	if d.Version != ConfigDocumentVersion {
+		return Range("version", fmt.Sprintf("%d", ConfigDocumentVersion))
+	}
+	watched := make(map[string]bool)
+	for i, item := range d.Watchlist {
+		key := item.AgentID + "\x00" + item.SessionTopic
+		if watched[key] {
+			return Nested(fmt.Sprintf("watchlist[%d]", i), fmt.Sprintf("/watchlist/%d", i), Invalid("session_topic", "agent_id and session_topic are already watched"))
+		}
+		watched[key] = true
+	}
+	names := make(map[string]bool)
+	for i, sla := range d.SLAs {
+		if names[sla.Name] {
+			return Nested(fmt.Sprintf("slas[%d]", i), fmt.Sprintf("/slas/%d", i), Invalid("name", fmt.Sprintf("name %q is used by another SLA", sla.Name)))
+		}
+		names[sla.Name] = true
+	}
+	if err := d.SessionAutoClose.Validate(); err != nil {
+		return Nested("session_auto_close", "/session_auto_close", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// MaxNotificationDestinations bounds how many extra notification destinations a user may configure
+const MaxNotificationDestinations = 10
+
+// notificationFormats lists the payload formats of the built-in channels; empty detects the platform from the URL
+var notificationFormats = map[string]bool{"": true, "generic": true, "json": true, "slack": true, "discord": true, "feishu": true, "teams": true}
+
+var (
+	pluginFormatsMu sync.RWMutex
+	// pluginFormats holds the formats of registered notifier plugins, whose destinations may use any URL scheme
+	pluginFormats = map[string]bool{}
+)
+
+// RegisterNotificationFormat accepts a notifier plugin's format for destinations and notification settings
+// The notifier package calls it for each plugin it registers.
+func RegisterNotificationFormat(format string) {
+	pluginFormatsMu.Lock()
+	defer pluginFormatsMu.Unlock()
+	pluginFormats[format] = true
+}
+
+// isPluginFormat reports whether format names a registered notifier plugin
+func isPluginFormat(format string) bool {
+	pluginFormatsMu.RLock()
+	defer pluginFormatsMu.RUnlock()
+	return pluginFormats[format]
+}
+
+// validNotificationFormat reports whether format is empty, built in or a registered plugin's
+func validNotificationFormat(format string) bool {
+	return notificationFormats[format] || isPluginFormat(format)
+}
+
+// errNotificationFormat lists the accepted formats, built-in ones first
+func errNotificationFormat() error {
+	formats := []string{"generic", "json", "slack", "discord", "feishu", "teams"}
+	pluginFormatsMu.RLock()
+	plugins := make([]string, 0, len(pluginFormats))
+	for format := range pluginFormats {
+		plugins = append(plugins, format)
+	}
+	pluginFormatsMu.RUnlock()
+	sort.Strings(plugins)
+	return OneOf("format", append(formats, plugins...)...)
+}
+
+// NotificationDestination is an extra receiver of a user's status notifications
+type NotificationDestination struct {
+	URL      string `json:"url"`                // May use NotificationURLFields, e.g. https://example.com/hooks/{{.AgentID}}
+	Format   string `json:"format,omitempty"`   // generic, json, slack, discord, feishu, teams or a plugin's; empty detects it from the URL
+	Template string `json:"template,omitempty"` // Message template using NotificationMessageFields; empty uses the default
+}
+
+// NotificationURLFields are the values a destination URL template may use
+// Values are path-escaped before they are inserted.
+type NotificationURLFields struct {
+	AgentID      string
+	AgentName    string
+	SessionTopic string
+	FromStatus   string
+	ToStatus     string
+}
+
+// Validate validates NotificationDestination fields
+// The URL template is rendered with sample values, so unknown fields are rejected on write rather than at send time.
+func (d *NotificationDestination) Validate() error {
+	if d.URL == "" || len(d.URL) > 2000 {
+		return Length("url", "1-2000", "characters")
+	}
+	if !validNotificationFormat(d.Format) {
+		return errNotificationFormat()
+	}
+	if err := ValidateNotificationTemplate(d.Template); err != nil {
+		return err
+	}
+	rendered, err := d.RenderURL(NotificationURLFields{
+		AgentID:      "agent",
+		AgentName:    "Agent",
+		SessionTopic: "topic",
+		FromStatus:   "running",
+		ToStatus:     "success",
+	})
+	if err != nil {
+		return Invalid("url", "url must be a valid template: "+err.Error())
+	}
+	// Plugins deliver messages themselves, so their targets need not be web addresses, e.g. pager://team-platform
+	if isPluginFormat(d.Format) {
+		if parsed, err := url.Parse(rendered); err != nil || parsed.Scheme == "" {
+			return Invalid("url", "url must be an absolute URL")
+		}
+		return nil
+	}
+	parsed, err := url.ParseRequestURI(rendered)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Invalid("url", "url must be an http or https URL")
+	}
+	return nil
+}
+
+// RenderURL fills the destination's URL template with a notification's values
+func (d *NotificationDestination) RenderURL(fields NotificationURLFields) (string, error) {
+	if !strings.Contains(d.URL, "{{") {
+		return d.URL, nil
+	}
+
+	tmpl, err := template.New("url").Option("missingkey=error").Parse(d.URL)
+	if err != nil {
+		return "", err
+	}
+
+	escaped := NotificationURLFields{
+		AgentID:      url.PathEscape(fields.AgentID),
+		AgentName:    url.PathEscape(fields.AgentName),
+		SessionTopic: url.PathEscape(fields.SessionTopic),
+		FromStatus:   url.PathEscape(fields.FromStatus),
+		ToStatus:     url.PathEscape(fields.ToStatus),
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, escaped); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,69 @@
+package models
+
+import "testing"
+
+func TestNotificationDestination_RenderURL(t *testing.T) {
+	fields := NotificationURLFields{
+		AgentID:      "builder",
+		AgentName:    "Build Bot",
+		SessionTopic: "deploy/prod",
+		FromStatus:   "running",
+		ToStatus:     "failed",
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"plain URL", "https://example.com/hook", "https://example.com/hook"},
+		{"agent in path", "https://example.com/agents/{{.AgentID}}", "https://example.com/agents/builder"},
+		{"values are escaped", "https://example.com/{{.AgentName}}/{{.SessionTopic}}?to={{.ToStatus}}", "https://example.com/Build%20Bot/deploy%2Fprod?to=failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination := &NotificationDestination{URL: tt.url}
+			got, err := destination.RenderURL(fields)
+			if err != nil {
+				t.Fatalf("RenderURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotificationDestination_Validate(t *testing.T) {
+	RegisterNotificationFormat("pager")
+
+	tests := []struct {
+		name        string
+		destination NotificationDestination
+		wantErr     bool
+	}{
+		{"plain URL", NotificationDestination{URL: "https://hooks.slack.com/services/T/B/X"}, false},
+		{"template with format", NotificationDestination{URL: "https://example.com/{{.AgentID}}", Format: "teams"}, false},
+		{"empty URL", NotificationDestination{}, true},
+		{"discord format", NotificationDestination{URL: "https://example.com/hook", Format: "discord"}, false},
+		{"unknown format", NotificationDestination{URL: "https://example.com/hook", Format: "mattermost"}, true},
+		{"unknown field", NotificationDestination{URL: "https://example.com/{{.Agent}}"}, true},
+		{"unclosed action", NotificationDestination{URL: "https://example.com/{{.AgentID"}, true},
+		{"template host", NotificationDestination{URL: "{{.AgentID}}"}, true},
+		{"not http", NotificationDestination{URL: "ftp://example.com/hook"}, true},
+		{"plugin target", NotificationDestination{URL: "pager://team/{{.AgentID}}", Format: "pager"}, false},
+		{"plugin relative target", NotificationDestination{URL: "team-platform", Format: "pager"}, true},
+		{"message template", NotificationDestination{URL: "https://example.com/hook", Template: "{{.AgentName}} took {{.Duration}}"}, false},
+		{"template unknown field", NotificationDestination{URL: "https://example.com/hook", Template: "{{.Agent}}"}, true},
+		{"template renders nothing", NotificationDestination{URL: "https://example.com/hook", Template: "{{if false}}x{{end}}"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.destination.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
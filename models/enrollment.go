@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// MaxEnrollmentTokenTTL bounds how long an enrollment token stays redeemable
+const MaxEnrollmentTokenTTL = 7 * 24 * time.Hour
+
+// EnrollmentToken is a short-lived, single-use token a new agent exchanges for its own agent token
+// Provisioning automation embeds the enrollment token instead of a user's personal API key; on its
+// first report the agent receives an API key restricted to reporting for itself.
+type EnrollmentToken struct {
+	ID            string     `json:"id"`
+	UserID        string     `json:"-"`
+	Name          string     `json:"name"`
+	TokenHash     string     `json:"-"`                  // SHA-256 of the raw token, which is only shown once
+	TokenPrefix   string     `json:"token_prefix"`       // First 8 chars for identification
+	AgentID       string     `json:"agent_id,omitempty"` // Restricts enrollment to one agent ID
+	ExpiresAt     time.Time  `json:"expires_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UsedAt        *time.Time `json:"used_at"`
+	UsedByAgentID string     `json:"used_by_agent_id,omitempty"`
+	APIKeyID      string     `json:"api_key_id,omitempty"` // Agent token issued on redemption
+}
+
+// Validate validates EnrollmentToken fields
+func (t *EnrollmentToken) Validate() error {
+	if t.ID == "" {
+		return Required("id")
+	}
+	if t.UserID == "" {
+		return Required("user_id")
+	}
+	if t.Name == "" {
+		return Required("name")
+	}
+	if len(t.Name) > 100 {
+		return Length("name", "<= 100", "characters")
+	}
+	if t.TokenHash == "" {
+		return Required("token_hash")
+	}
+	if len(t.TokenPrefix) != 8 {
+		return Length("token_prefix", "exactly 8", "characters")
+	}
+	if len(t.AgentID) > 100 {
+		return Length("agent_id", "<= 100", "characters")
+	}
+	if t.ExpiresAt.IsZero() {
+		return Required("expires_at")
+	}
+	return nil
+}
+
+// Redeemable reports whether the token can still be exchanged at now
+func (t *EnrollmentToken) Redeemable(now time.Time) bool {
+	return t.UsedAt == nil && now.Before(t.ExpiresAt)
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxIdempotencyKeyLength bounds the Idempotency-Key header and report_id field of status reports
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyRecord is a status report processed under an idempotency key, kept so a retry of the report
+// gets the original response instead of storing it again
+type IdempotencyRecord struct {
+	UserID      string    `json:"user_id"`
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"` // Hash of the report, so a key reused for another report is rejected
+	StatusCode  int       `json:"status_code"` // 0 while the report is being processed
+	Response    []byte    `json:"response,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"` // Until then the key cannot be claimed again
+}
+
+// Completed reports whether the record holds the response of a processed report
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// ValidateIdempotencyKey checks an optional idempotency key, naming field in its errors
+func ValidateIdempotencyKey(field, key string) error {
+	if len(key) > MaxIdempotencyKeyLength {
+		return Length(field, fmt.Sprintf("0-%d", MaxIdempotencyKeyLength), "characters")
+	}
+	for _, r := range key {
+		if r < 0x20 || r > 0x7e {
+			return Invalid(field, field+" must be printable ASCII")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Inbox notification kinds
+const (
+	InboxKindFailure    = "failure"
+	InboxKindExpiration = "expiration"
+	InboxKindOffline    = "offline"
+	InboxKindReopened   = "reopened"
+)
+
+// InboxItem is a user-facing notification shown in the dashboard inbox
+type InboxItem struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"-"`
+	Kind         string    `json:"kind"`
+	AgentID      string    `json:"agent_id"`
+	SessionTopic string    `json:"session_topic,omitempty"`
+	Message      string    `json:"message"`
+	DedupeKey    string    `json:"-"` // Identifies the underlying event so it is recorded once per user
+	Read         bool      `json:"read"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Validate validates InboxItem fields
+func (i *InboxItem) Validate() error {
+	if i.ID == "" {
+		return Required("id")
+	}
+	if i.UserID == "" {
+		return Required("user_id")
+	}
+	switch i.Kind {
+	case InboxKindFailure, InboxKindExpiration, InboxKindOffline, InboxKindReopened:
+	default:
+		return OneOf("kind", "failure", "expiration", "offline", "reopened")
+	}
+	if i.AgentID == "" {
+		return Required("agent_id")
+	}
+	if i.DedupeKey == "" {
+		return Required("dedupe_key")
+	}
+	if i.CreatedAt.IsZero() {
+		return Required("created_at")
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package models
+
+// Integrity issue kinds found by the store's integrity check
+const (
+	IntegrityOrphanedSession = "orphaned_session" // A session whose agent is missing
+	IntegrityOrphanedStatus  = "orphaned_status"  // Statuses whose session is missing
+	IntegrityOwnerlessAgent  = "ownerless_agent"  // An agent without an owner, or whose owner is missing
+	IntegrityExpiredFlag     = "expired_flag"     // A session whose expired flag disagrees with its expiry time
+)
+
+// IntegrityIssue is an inconsistency between stored records, as left by a crash or a manual database edit
+type IntegrityIssue struct {
+	Kind         string `json:"kind"`
+	AgentID      string `json:"agent_id"`
+	SessionTopic string `json:"session_topic,omitempty"`
+	Detail       string `json:"detail"`
+	Repaired     bool   `json:"repaired"`
+}
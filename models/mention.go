@@ -0,0 +1,51 @@
+package models
+
+import "regexp"
+
+// MaxMentionRules bounds how many mention rules a user may configure
+const MaxMentionRules = 50
+
+// MentionRule maps an agent and/or session topic pattern to a chat user who is @-mentioned in notifications
+type MentionRule struct {
+	AgentID      string `json:"agent_id,omitempty"`      // Empty matches every agent
+	TopicPattern string `json:"topic_pattern,omitempty"` // Regular expression; empty matches every topic
+	ChatUserID   string `json:"chat_user_id"`            // Platform user ID, e.g. a Slack member ID or Feishu open_id
+	Name         string `json:"name,omitempty"`          // Display name for platforms that show one with the mention
+}
+
+// Validate validates MentionRule fields
+func (m *MentionRule) Validate() error {
+	if m.ChatUserID == "" || len(m.ChatUserID) > 100 {
+		return Length("chat_user_id", "1-100", "characters")
+	}
+	if len(m.Name) > 100 {
+		return Length("name", "0-100", "characters")
+	}
+	if len(m.AgentID) > 100 {
+		return Length("agent_id", "0-100", "characters")
+	}
+	if len(m.TopicPattern) > 500 {
+		return Length("topic_pattern", "0-500", "characters")
+	}
+	if _, err := regexp.Compile(m.TopicPattern); err != nil {
+		return Invalid("topic_pattern", "topic_pattern must be a valid regular expression")
+	}
+	return nil
+}
+
+// Matches reports whether the rule applies to a session of an agent
+// An empty sessionTopic, as for agent-wide events, matches only rules without a topic pattern.
+func (m *MentionRule) Matches(agentID, sessionTopic string) bool {
+	if m.AgentID != "" && m.AgentID != agentID {
+		return false
+	}
+	if m.TopicPattern == "" {
+		return true
+	}
+	if sessionTopic == "" {
+		return false
+	}
+	// Validated on write, so a compile error only happens for rows edited out of band
+	pattern, err := regexp.Compile(m.TopicPattern)
+	return err == nil && pattern.MatchString(sessionTopic)
+}
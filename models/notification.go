@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxNotificationTransitions bounds how many transitions a user may choose to be notified of
+const MaxNotificationTransitions = 20
+
+// AnyStatus matches every status in a StatusTransition
+const AnyStatus = "*"
+
+// StatusTransition is a change of a session's status from one status to another
+type StatusTransition struct {
+	From string `json:"from"` // A status or AnyStatus
+	To   string `json:"to"`   // A status or AnyStatus
+}
+
+// DefaultNotificationTransitions are notified when a user chose none: a running session finishing or waiting
+var DefaultNotificationTransitions = []StatusTransition{
+	{From: "running", To: "success"},
+	{From: "running", To: "failed"},
+	{From: "running", To: "pending"},
+}
+
+// transitionStatuses lists the statuses a StatusTransition may name
+var transitionStatuses = map[string]bool{AnyStatus: true, "running": true, "success": true, "failed": true, "pending": true}
+
+// Matches reports whether a session changing from one status to another is this transition
+// Reporting the same status again is never a transition.
+func (t StatusTransition) Matches(from, to string) bool {
+	if from == "" || from == to {
+		return false
+	}
+	return (t.From == AnyStatus || t.From == from) && (t.To == AnyStatus || t.To == to)
+}
+
+// NotificationSettings are a user's own notification receiver and the transitions it is notified of
+// They are kept apart from the user's profile. A webhook URL set here replaces the profile's notification_webhook_url,
+// and the transitions decide when the user's own receivers are notified; the notification policy's receivers
+// are always notified of the default transitions.
+type NotificationSettings struct {
+	UserID      string             `json:"-"`
+	WebhookURL  string             `json:"webhook_url,omitempty"`
+	Format      string             `json:"format,omitempty"`      // Channel of the webhook URL; empty detects it from the URL
+	Transitions []StatusTransition `json:"transitions,omitempty"` // Empty notifies DefaultNotificationTransitions
+	// FirstFailureOnly notifies a session's first failure and holds back the failures of its later runs
+	// until one of them succeeds
+	FirstFailureOnly bool `json:"first_failure_only,omitempty"`
+	// Template renders the messages of the user's own receivers that have no template of their own;
+	// empty uses DefaultNotificationTemplate
+	Template string `json:"template,omitempty"`
+	// DedupMinutes holds back notifications of a session reaching the status it was notified of in the last
+	// DedupMinutes minutes; 0 notifies every transition
+	DedupMinutes int `json:"dedup_minutes,omitempty"`
+	// EscalateAfterMinutes notifies a failure again each time the session stays failed for that many minutes
+	// since it was last notified, up to MaxNotificationEscalations times; 0 notifies it once
+	EscalateAfterMinutes int       `json:"escalate_after_minutes,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// Validate validates NotificationSettings fields
+func (s *NotificationSettings) Validate() error {
+	if s.UserID == "" {
+		return Required("user_id")
+	}
+	if s.WebhookURL != "" {
+		destination := NotificationDestination{URL: s.WebhookURL, Format: s.Format}
+		if err := destination.Validate(); err != nil {
+			// The destination's url is the settings' webhook_url
+			err = Nested("webhook_url", "", err)
+			for _, fieldErr := range FieldErrorsOf(err) {
+				if fieldErr.Field == "/url" {
+					fieldErr.Field = "/webhook_url"
+				}
+			}
+			return err
+		}
+	} else if !validNotificationFormat(s.Format) {
+		return errNotificationFormat()
+	}
+	if err := ValidateNotificationTemplate(s.Template); err != nil {
+		return err
+	}
+	if s.DedupMinutes < 0 || s.DedupMinutes > MaxNotificationDedupMinutes {
+		return Range("dedup_minutes", fmt.Sprintf("0-%d", MaxNotificationDedupMinutes))
+	}
+	if s.EscalateAfterMinutes < 0 || s.EscalateAfterMinutes > MaxNotificationEscalateMinutes {
+		return Range("escalate_after_minutes", fmt.Sprintf("0-%d", MaxNotificationEscalateMinutes))
+	}
+	if len(s.Transitions) > MaxNotificationTransitions {
+		return Invalid("transitions", fmt.Sprintf("transitions must have at most %d entries", MaxNotificationTransitions))
+	}
+	for i, transition := range s.Transitions {
+		if !transitionStatuses[transition.From] || !transitionStatuses[transition.To] {
+			return Nested(fmt.Sprintf("transitions[%d]", i), fmt.Sprintf("/transitions/%d", i), &FieldError{Code: CodeOneOf, Message: "from and to must be one of: *, running, success, failed, pending", Constraint: "*, running, success, failed, pending"})
+		}
+		if transition.From == transition.To && transition.From != AnyStatus {
+			return Nested(fmt.Sprintf("transitions[%d]", i), fmt.Sprintf("/transitions/%d", i), Invalid("to", "from and to must differ"))
+		}
+	}
+	return nil
+}
+
+// Triggers reports whether a session changing from one status to another notifies the user's own receivers
+func (s *NotificationSettings) Triggers(from, to string) bool {
+	transitions := s.Transitions
+	if len(transitions) == 0 {
+		transitions = DefaultNotificationTransitions
+	}
+	for _, transition := range transitions {
+		if transition.Matches(from, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// TriggersRecovery reports whether a run succeeding after failed runs notifies the user's own receivers
+// A single run never goes from failed to success, so that transition selects recoveries; transitions to success
+// notify them as well.
+func (s *NotificationSettings) TriggersRecovery() bool {
+	return s.Triggers("failed", "success")
+}
+
+// Destination returns the receiver of the settings' webhook URL, or false when none is set
+func (s *NotificationSettings) Destination() (NotificationDestination, bool) {
+	return NotificationDestination{URL: s.WebhookURL, Format: s.Format}, s.WebhookURL != ""
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// MaxNotificationDedupMinutes bounds the window in which a user's notifications of the same status are held back
+const MaxNotificationDedupMinutes = 1440
+
+// MaxNotificationEscalateMinutes bounds how long a session may stay failed before its failure is notified again
+const MaxNotificationEscalateMinutes = 1440
+
+// MaxNotificationEscalations bounds how often one failure of a session is notified again
+const MaxNotificationEscalations = 3
+
+// NotificationMark records when a user was last notified of a session reaching a status, so repeated
+// notifications within the user's dedup window are held back and failures that persist are escalated
+type NotificationMark struct {
+	UserID       string    `json:"user_id"`
+	AgentID      string    `json:"agent_id"`
+	SessionTopic string    `json:"session_topic"`
+	Status       string    `json:"status"`
+	NotifiedAt   time.Time `json:"notified_at"` // When the status or its latest escalation was notified
+	Escalations  int       `json:"escalations"` // Times the status was notified again because the session stayed in it
+}
+
+// Validate validates NotificationMark fields
+func (m *NotificationMark) Validate() error {
+	if m.UserID == "" {
+		return Required("user_id")
+	}
+	if m.AgentID == "" {
+		return Required("agent_id")
+	}
+	if m.SessionTopic == "" {
+		return Required("session_topic")
+	}
+	if !ValidStatus(m.Status) {
+		return Invalid("status", "status must be a session status")
+	}
+	if m.NotifiedAt.IsZero() {
+		return Required("notified_at")
+	}
+	if m.Escalations < 0 {
+		return Range("escalations", ">= 0")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MaxNotificationTemplateLength bounds a notification message template
+const MaxNotificationTemplateLength = 4000
+
+// DefaultNotificationTemplate renders status notifications whose receiver has no template of its own,
+// and those whose own template fails to render when they are sent
+const DefaultNotificationTemplate = `{{if .Recovered}}✅ Session Recovered{{else}}🔔 Session Status Change{{end}}
+
+Agent ID: {{.AgentID}}
+Agent Name: {{.AgentName}}
+Session: {{.SessionTopic}}
+Status: {{.FromStatus}} → {{.ToStatus}}
+Timestamp: {{.Timestamp.Format "2006-01-02T15:04:05Z07:00"}}
+Duration: {{.Duration}}
+{{- if .EndReason}}
+Ended: {{.EndReason}}{{end}}
+{{- if .Recovered}}
+Recovered After: {{.FailedRuns}} failed run(s)
+Failing Since: {{.FailingSince.Format "2006-01-02T15:04:05Z07:00"}}
+Downtime: {{.Downtime}}{{end}}
+{{- if .Message}}
+Message: {{.Message}}{{end}}
+{{- if .Content}}
+Content: {{.Content}}{{end}}`
+
+// NotificationMessageFields are the values a notification message template may use, e.g. {{.AgentName}}
+// or {{.Duration}}
+type NotificationMessageFields struct {
+	AgentID      string
+	AgentName    string
+	SessionTopic string
+	FromStatus   string
+	ToStatus     string
+	Timestamp    time.Time
+	Duration     time.Duration
+	Message      string
+	Content      string // Sanitized for the receiver's channel and cut to fit
+	EndReason    string // Why the session ended, empty while it is still in progress
+	SessionURL   string // Dashboard page of the session, empty when no dashboard URL is configured
+	Recovered    bool   // The run succeeded after failed runs
+	FailedRuns   int    // Consecutive failed runs a recovery ended
+	FailingSince time.Time
+	Downtime     time.Duration
+}
+
+// ParseNotificationTemplate parses a notification message template
+// The template is rendered with sample values, so unknown fields and other errors are rejected on write
+// rather than when a notification is sent.
+func ParseNotificationTemplate(text string) (*template.Template, error) {
+	if len(text) > MaxNotificationTemplateLength {
+		return nil, Length("template", fmt.Sprintf("0-%d", MaxNotificationTemplateLength), "characters")
+	}
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, Invalid("template", "template is invalid: "+err.Error())
+	}
+
+	// A fixed time, so validation does not depend on when it runs
+	now := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	sample := NotificationMessageFields{
+		AgentID:      "agent",
+		AgentName:    "Agent",
+		SessionTopic: "topic",
+		FromStatus:   "failed",
+		ToStatus:     "success",
+		Timestamp:    now,
+		Duration:     time.Minute,
+		Message:      "message",
+		Content:      "content",
+		EndReason:    "completed",
+		SessionURL:   "https://example.com/agents/agent/sessions/topic",
+		Recovered:    true,
+		FailedRuns:   2,
+		FailingSince: now.Add(-time.Hour),
+		Downtime:     time.Hour,
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, sample); err != nil {
+		return nil, Invalid("template", "template is invalid: "+err.Error())
+	}
+	if strings.TrimSpace(out.String()) == "" {
+		return nil, Invalid("template", "template must render a message")
+	}
+	return tmpl, nil
+}
+
+// ValidateNotificationTemplate checks an optional notification message template
+func ValidateNotificationTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	_, err := ParseNotificationTemplate(text)
+	return err
+}
@@ -0,0 +1,126 @@
+package models
+
+import "time"
+
+// Roles of organization members, from most to least privileged
+const (
+	OrgRoleOwner  = "owner"  // Manages the organization, its members and invitations, and its agents
+	OrgRoleMember = "member" // Views and manages the organization's agents
+	OrgRoleViewer = "viewer" // Only views the organization's agents
+)
+
+// orgRoles lists the accepted organization roles
+var orgRoles = map[string]bool{
+	OrgRoleOwner:  true,
+	OrgRoleMember: true,
+	OrgRoleViewer: true,
+}
+
+// ValidOrgRole reports whether role is one of the OrgRole constants
+func ValidOrgRole(role string) bool {
+	return orgRoles[role]
+}
+
+// CanManageAgents reports whether role may change agents: their configuration, sampling and sessions
+// Owners of an agent hold OrgRoleOwner over it whether or not it belongs to an organization.
+func CanManageAgents(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleMember
+}
+
+// InvitationTTL is how long an organization invitation can be accepted
+const InvitationTTL = 7 * 24 * time.Hour
+
+// Organization is a team whose members share the agents assigned to it
+// Agents keep the owner that reports for them; Agent.OrgID makes them visible to the members as well.
+type Organization struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate validates Organization fields
+func (o *Organization) Validate() error {
+	if o.ID == "" {
+		return Required("id")
+	}
+	if len(o.ID) > 36 {
+		return Length("id", "<= 36", "characters")
+	}
+	if o.Name == "" {
+		return Required("name")
+	}
+	if len(o.Name) > 100 {
+		return Length("name", "<= 100", "characters")
+	}
+	if o.CreatedBy == "" {
+		return Required("created_by")
+	}
+	return nil
+}
+
+// Membership gives a user a role in an organization
+type Membership struct {
+	OrgID     string    `json:"org_id"`
+	UserID    string    `json:"user_id"`
+	Role      string    `json:"role"` // One of the OrgRole constants
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate validates Membership fields
+func (m *Membership) Validate() error {
+	if m.OrgID == "" {
+		return Required("org_id")
+	}
+	if m.UserID == "" {
+		return Required("user_id")
+	}
+	if !ValidOrgRole(m.Role) {
+		return OneOf("role", OrgRoleOwner, OrgRoleMember, OrgRoleViewer)
+	}
+	return nil
+}
+
+// Invitation lets the user with an email address join an organization with a role
+// The raw token is only shown to the inviter, who passes it on; the invitee accepts it signed in
+// with the invited address.
+type Invitation struct {
+	ID          string    `json:"id"`
+	OrgID       string    `json:"org_id"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	TokenHash   string    `json:"-"`            // SHA-256 of the raw token
+	TokenPrefix string    `json:"token_prefix"` // First 8 chars for identification
+	InvitedBy   string    `json:"invited_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// Validate validates Invitation fields
+func (i *Invitation) Validate() error {
+	if i.ID == "" {
+		return Required("id")
+	}
+	if i.OrgID == "" {
+		return Required("org_id")
+	}
+	if !emailRegex.MatchString(i.Email) || len(i.Email) > 255 {
+		return Invalid("email", "invalid email format")
+	}
+	if !ValidOrgRole(i.Role) {
+		return OneOf("role", OrgRoleOwner, OrgRoleMember, OrgRoleViewer)
+	}
+	if i.TokenHash == "" {
+		return Required("token_hash")
+	}
+	if len(i.TokenPrefix) != 8 {
+		return Length("token_prefix", "exactly 8", "characters")
+	}
+	if i.InvitedBy == "" {
+		return Required("invited_by")
+	}
+	if i.ExpiresAt.IsZero() {
+		return Required("expires_at")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Outbox message kinds
+const (
+	OutboxKindNotification = "notification" // A status notification to one destination
+	OutboxKindInbox        = "inbox"        // An inbox item
+	OutboxKindSessionHook  = "session_hook" // A session run that ended, to one session webhook
+	OutboxKindDelivery     = "delivery"     // A notification payload built for one destination, queued by the notification manager
+)
+
+// OutboxMessage is a side effect of a status report, recorded in the same transaction as the status,
+// or a notification queued on its own, and delivered afterwards by the outbox relay
+type OutboxMessage struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`     // Delivery attempts started so far
+	AvailableAt time.Time       `json:"available_at"` // Not claimed for delivery before this time
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	AgentID     string          `json:"-"` // Agent the message is about, whose owner's data key encrypts the payload; not stored
+}
+
+// Validate validates OutboxMessage fields
+func (m *OutboxMessage) Validate() error {
+	switch m.Kind {
+	case OutboxKindNotification, OutboxKindInbox, OutboxKindSessionHook, OutboxKindDelivery:
+	default:
+		return OneOf("kind", "notification", "inbox", "session_hook", "delivery")
+	}
+	if len(m.Payload) == 0 {
+		return Required("payload")
+	}
+	if m.CreatedAt.IsZero() {
+		return Required("created_at")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Overview is the read model behind the dashboard home page: the counts and recent failures of a user's fleet,
+// computed in one pass over the store instead of one request per agent
+type Overview struct {
+	Agents         OverviewAgentCounts
+	ActiveSessions int              // Sessions that have not expired
+	Since          time.Time        // Start of the window the status counts and failures cover
+	Succeeded      int              // Success statuses reported since Since
+	Failed         int              // Failed statuses reported since Since
+	RecentFailures []*RecentFailure // Newest first
+}
+
+// OverviewAgentCounts counts a user's agents by the presence the heartbeat monitor last computed
+// Agents it has not assessed yet are only counted in Total.
+type OverviewAgentCounts struct {
+	Total   int
+	Online  int
+	Stale   int
+	Offline int
+}
+
+// RecentFailure is a failed status with the name of the agent that reported it
+type RecentFailure struct {
+	AgentName string
+	Status    *AgentStatus
+}
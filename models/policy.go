@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// NotificationPolicy is the deployment-wide notification baseline set by admins
+// Every member inherits its mention rules and receivers. When AllowUserOverride is set, members who configured
+// a webhook URL or destinations of their own use those instead of the policy's receivers; otherwise the policy's
+// receivers get every notification in addition to the member's own, even for sessions the member muted.
+type NotificationPolicy struct {
+	WebhookURL        string                    `json:"webhook_url,omitempty"`
+	Mentions          []MentionRule             `json:"mentions,omitempty"`
+	Destinations      []NotificationDestination `json:"destinations,omitempty"`
+	AllowUserOverride bool                      `json:"allow_user_override"`
+	FirstFailureOnly  bool                      `json:"first_failure_only"`   // Only a session's first failure in a row reaches the policy's receivers
+	UpdatedBy         string                    `json:"updated_by,omitempty"` // User ID of the admin who last changed it
+	UpdatedAt         time.Time                 `json:"updated_at"`
+}
+
+// Validate validates NotificationPolicy fields
+func (p *NotificationPolicy) Validate() error {
+	if p.WebhookURL != "" {
+		parsed, err := url.ParseRequestURI(p.WebhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(p.WebhookURL) > 2000 {
+			return Invalid("webhook_url", "webhook_url must be an http or https URL of at most 2000 characters")
+		}
+	}
+	if len(p.Mentions) > MaxMentionRules {
+		return Invalid("mentions", fmt.Sprintf("mentions must have at most %d rules", MaxMentionRules))
+	}
+	for i := range p.Mentions {
+		if err := p.Mentions[i].Validate(); err != nil {
+			return Nested(fmt.Sprintf("mentions[%d]", i), fmt.Sprintf("/mentions/%d", i), err)
+		}
+	}
+	if len(p.Destinations) > MaxNotificationDestinations {
+		return Invalid("destinations", fmt.Sprintf("destinations must have at most %d destinations", MaxNotificationDestinations))
+	}
+	for i := range p.Destinations {
+		if err := p.Destinations[i].Validate(); err != nil {
+			return Nested(fmt.Sprintf("destinations[%d]", i), fmt.Sprintf("/destinations/%d", i), err)
+		}
+	}
+	return nil
+}
+
+// HasReceivers reports whether the policy sends notifications anywhere itself
+func (p *NotificationPolicy) HasReceivers() bool {
+	return p.WebhookURL != "" || len(p.Destinations) > 0
+}
+
+// Overridden reports whether a member's own receivers replace the policy's
+func (p *NotificationPolicy) Overridden(user *User) bool {
+	return p.AllowUserOverride && (user.NotificationWebhookURL != "" || len(user.NotificationDestinations) > 0)
+}
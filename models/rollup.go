@@ -0,0 +1,113 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// StatusRollup summarizes one UTC day of a session's status history, so trends outlive the raw statuses
+type StatusRollup struct {
+	AgentID         string    `json:"agent_id"`
+	SessionTopic    string    `json:"session_topic"`
+	Day             time.Time `json:"day"` // Midnight UTC of the day
+	Running         int       `json:"running"`
+	Success         int       `json:"success"`
+	Failed          int       `json:"failed"`
+	Pending         int       `json:"pending"`
+	Runs            int       `json:"runs"` // Session revisions with statuses on the day
+	FirstAt         time.Time `json:"first_at"`
+	LastAt          time.Time `json:"last_at"`
+	DurationSeconds float64   `json:"duration_seconds"` // Sum over the runs of the time between their first and last status of the day
+}
+
+// Validate validates StatusRollup fields
+func (r *StatusRollup) Validate() error {
+	if r.AgentID == "" || r.SessionTopic == "" {
+		return Invalid("agent_id", "agent_id and session_topic are required")
+	}
+	if r.Day.IsZero() || !r.Day.Equal(UsageDay(r.Day)) {
+		return Invalid("day", "day must be midnight UTC")
+	}
+	if r.Running < 0 || r.Success < 0 || r.Failed < 0 || r.Pending < 0 || r.Runs < 0 || r.DurationSeconds < 0 {
+		return Invalid("", "rollup counts must not be negative")
+	}
+	if r.LastAt.Before(r.FirstAt) {
+		return Invalid("last_at", "last_at must be >= first_at")
+	}
+	return nil
+}
+
+// BuildStatusRollups summarizes statuses into one rollup per session and UTC day, ordered as SortStatusRollups does
+func BuildStatusRollups(statuses []*AgentStatus) []*StatusRollup {
+	type runKey struct {
+		agentID, topic string
+		day            time.Time
+		revision       int
+	}
+	type runSpan struct{ first, last time.Time }
+
+	rollups := make(map[runKey]*StatusRollup) // Keyed with revision 0, as a rollup covers every run of the day
+	runs := make(map[runKey]*runSpan)
+	for _, status := range statuses {
+		at := status.Timestamp.UTC()
+		day := UsageDay(at)
+		key := runKey{status.AgentID, status.SessionTopic, day, 0}
+		rollup, exists := rollups[key]
+		if !exists {
+			rollup = &StatusRollup{AgentID: status.AgentID, SessionTopic: status.SessionTopic, Day: day, FirstAt: at, LastAt: at}
+			rollups[key] = rollup
+		}
+		switch status.Status {
+		case "running":
+			rollup.Running++
+		case "success":
+			rollup.Success++
+		case "failed":
+			rollup.Failed++
+		case "pending":
+			rollup.Pending++
+		}
+		if at.Before(rollup.FirstAt) {
+			rollup.FirstAt = at
+		}
+		if at.After(rollup.LastAt) {
+			rollup.LastAt = at
+		}
+
+		key.revision = status.Revision
+		if span, exists := runs[key]; !exists {
+			runs[key] = &runSpan{at, at}
+		} else if at.Before(span.first) {
+			span.first = at
+		} else if at.After(span.last) {
+			span.last = at
+		}
+	}
+
+	for key, span := range runs {
+		rollup := rollups[runKey{key.agentID, key.topic, key.day, 0}]
+		rollup.Runs++
+		rollup.DurationSeconds += span.last.Sub(span.first).Seconds()
+	}
+
+	result := make([]*StatusRollup, 0, len(rollups))
+	for _, rollup := range rollups {
+		result = append(result, rollup)
+	}
+	SortStatusRollups(result)
+	return result
+}
+
+// SortStatusRollups orders rollups oldest day first, then by agent and session topic
+func SortStatusRollups(rollups []*StatusRollup) {
+	sort.Slice(rollups, func(i, j int) bool {
+		a, b := rollups[i], rollups[j]
+		if !a.Day.Equal(b.Day) {
+			return a.Day.Before(b.Day)
+		}
+		if a.AgentID != b.AgentID {
+			return a.AgentID < b.AgentID
+		}
+		return a.SessionTopic < b.SessionTopic
+	})
+}
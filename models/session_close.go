@@ -0,0 +1,30 @@
+package models
+
+// What happens to the running sessions of an agent that is deleted or goes offline, chosen in SessionAutoClose
+const (
+	SessionCloseLeave  = ""       // The sessions are left untouched and expire when their TTL runs out
+	SessionCloseFail   = "fail"   // Each session gets a failed status giving the reason
+	SessionCloseExpire = "expire" // The sessions are expired at once
+)
+
+// SessionAutoClose is a user's choice of what happens to an agent's running sessions when the agent goes away
+type SessionAutoClose struct {
+	OnDelete  string `json:"on_delete,omitempty"`  // One of the SessionClose constants, applied when the agent is deleted
+	OnOffline string `json:"on_offline,omitempty"` // One of the SessionClose constants, applied when the agent goes offline
+}
+
+// validSessionClose reports whether action is one of the SessionClose constants
+func validSessionClose(action string) bool {
+	return action == SessionCloseLeave || action == SessionCloseFail || action == SessionCloseExpire
+}
+
+// Validate validates SessionAutoClose fields
+func (c SessionAutoClose) Validate() error {
+	if !validSessionClose(c.OnDelete) {
+		return OneOf("on_delete", "fail", "expire", "or empty to leave sessions untouched")
+	}
+	if !validSessionClose(c.OnOffline) {
+		return OneOf("on_offline", "fail", "expire", "or empty to leave sessions untouched")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"net/url"
+	"time"
+)
+
+// MaxSessionWebhooks bounds how many session webhooks a user may register
+const MaxSessionWebhooks = 20
+
+// How a session run ended, as reported to session webhooks
+const (
+	SessionOutcomeSuccess   = "success"   // The agent reported success
+	SessionOutcomeFailed    = "failed"    // The agent reported failure
+	SessionOutcomeCancelled = "cancelled" // Its owner cancelled it
+	SessionOutcomeExpired   = "expired"   // It ended without a final status: its TTL ran out or the server closed it
+)
+
+// sessionOutcomes lists the accepted outcomes
+var sessionOutcomes = map[string]bool{
+	SessionOutcomeSuccess:   true,
+	SessionOutcomeFailed:    true,
+	SessionOutcomeCancelled: true,
+	SessionOutcomeExpired:   true,
+}
+
+// SessionOutcome returns the outcome of a session run that ended with the given latest status
+func SessionOutcome(session *Session, latestStatus string) string {
+	switch session.EndReason {
+	case EndReasonAgentReported:
+		if latestStatus == SessionOutcomeFailed {
+			return SessionOutcomeFailed
+		}
+		return SessionOutcomeSuccess
+	case EndReasonCancelled:
+		return SessionOutcomeCancelled
+	case EndReasonAgentDeleted, EndReasonAgentOffline:
+		// Sessions closed with their agent were failed or expired, as the owner chose
+		if latestStatus == SessionOutcomeFailed {
+			return SessionOutcomeFailed
+		}
+		return SessionOutcomeExpired
+	default:
+		return SessionOutcomeExpired
+	}
+}
+
+// SessionWebhook is an automation callback receiving every session run of its owner's agents that ends
+// Unlike notification destinations it receives the full session and final status as JSON, signed with its
+// own secret, so downstream pipelines can trigger on agent completion.
+type SessionWebhook struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`                  // HMAC secret the deliveries are signed with
+	AgentID   string    `json:"agent_id,omitempty"` // Empty receives all of the user's agents
+	Outcomes  []string  `json:"outcomes,omitempty"` // SessionOutcome constants to receive; empty receives every outcome
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate validates SessionWebhook fields
+func (h *SessionWebhook) Validate() error {
+	if h.ID == "" {
+		return Required("id")
+	}
+	if len(h.ID) > 36 {
+		return Length("id", "<= 36", "characters")
+	}
+	if h.UserID == "" {
+		return Required("user_id")
+	}
+	parsed, err := url.ParseRequestURI(h.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(h.URL) > 2000 {
+		return Invalid("url", "url must be an http or https URL of at most 2000 characters")
+	}
+	if h.Secret == "" {
+		return Required("secret")
+	}
+	if len(h.AgentID) > 100 {
+		return Length("agent_id", "0-100", "characters")
+	}
+	for _, outcome := range h.Outcomes {
+		if !sessionOutcomes[outcome] {
+			return Invalid("outcomes", "outcomes must be among: success, failed, cancelled, expired")
+		}
+	}
+	return nil
+}
+
+// Receives reports whether the webhook receives a run of an agent that ended with outcome
+func (h *SessionWebhook) Receives(agentID, outcome string) bool {
+	if h.AgentID != "" && h.AgentID != agentID {
+		return false
+	}
+	if len(h.Outcomes) == 0 {
+		return true
+	}
+	for _, wanted := range h.Outcomes {
+		if wanted == outcome {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package models
+
+import "testing"
+
+func TestSessionWebhook_Validate(t *testing.T) {
+	valid := func() SessionWebhook {
+		return SessionWebhook{
+			ID:       "hook-001",
+			UserID:   "user-001",
+			URL:      "https://ci.example.com/hooks/agents",
+			Secret:   "whsec_test",
+			Outcomes: []string{SessionOutcomeFailed, SessionOutcomeExpired},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*SessionWebhook)
+		wantErr bool
+	}{
+		{"valid webhook", func(h *SessionWebhook) {}, false},
+		{"every outcome", func(h *SessionWebhook) { h.Outcomes = nil }, false},
+		{"missing user_id", func(h *SessionWebhook) { h.UserID = "" }, true},
+		{"missing secret", func(h *SessionWebhook) { h.Secret = "" }, true},
+		{"relative url", func(h *SessionWebhook) { h.URL = "/hooks" }, true},
+		{"non-http url", func(h *SessionWebhook) { h.URL = "ftp://ci.example.com" }, true},
+		{"unknown outcome", func(h *SessionWebhook) { h.Outcomes = []string{"done"} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := valid()
+			tt.modify(&hook)
+			if err := hook.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSessionWebhook_Receives(t *testing.T) {
+	hook := SessionWebhook{AgentID: "agent-1", Outcomes: []string{SessionOutcomeFailed}}
+	if !hook.Receives("agent-1", SessionOutcomeFailed) {
+		t.Error("Receives(agent-1, failed) = false, want true")
+	}
+	if hook.Receives("agent-1", SessionOutcomeSuccess) || hook.Receives("agent-2", SessionOutcomeFailed) {
+		t.Error("Receives() = true for another outcome or agent, want false")
+	}
+	if all := (SessionWebhook{}); !all.Receives("agent-2", SessionOutcomeCancelled) {
+		t.Error("Receives() without filters = false, want true")
+	}
+}
+
+func TestSessionOutcome(t *testing.T) {
+	tests := []struct {
+		endReason string
+		latest    string
+		want      string
+	}{
+		{EndReasonAgentReported, "success", SessionOutcomeSuccess},
+		{EndReasonAgentReported, "failed", SessionOutcomeFailed},
+		{EndReasonCancelled, "running", SessionOutcomeCancelled},
+		{EndReasonTTLExpired, "running", SessionOutcomeExpired},
+		{EndReasonCleanup, "", SessionOutcomeExpired},
+		{EndReasonAgentDeleted, "failed", SessionOutcomeFailed},
+		{EndReasonAgentOffline, "running", SessionOutcomeExpired},
+	}
+	for _, tt := range tests {
+		if got := SessionOutcome(&Session{EndReason: tt.endReason}, tt.latest); got != tt.want {
+			t.Errorf("SessionOutcome(%s, %s) = %s, want %s", tt.endReason, tt.latest, got, tt.want)
+		}
+	}
+}
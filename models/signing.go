@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// MaxSigningSecretOverlap bounds how long a rotated-out signing secret keeps signing notifications
+const MaxSigningSecretOverlap = 30 * 24 * time.Hour
+
+// signingSecretPrefixLength is how much of a signing secret is shown once it was created
+const signingSecretPrefixLength = 12
+
+// NotificationSigningSecret is the HMAC secret outbound notifications are signed with
+// After a rotation the previous secret keeps signing alongside the new one until PreviousExpiresAt,
+// so receivers can switch over without rejecting notifications.
+type NotificationSigningSecret struct {
+	Secret            string     `json:"secret"`
+	CreatedAt         time.Time  `json:"created_at"`
+	CreatedBy         string     `json:"created_by"`
+	Previous          string     `json:"previous,omitempty"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at,omitempty"`
+}
+
+// Active returns the secrets notifications are signed with at now, the current one first
+func (s *NotificationSigningSecret) Active(now time.Time) []string {
+	if s == nil || s.Secret == "" {
+		return nil
+	}
+	secrets := []string{s.Secret}
+	if s.Previous != "" && s.PreviousExpiresAt != nil && now.Before(*s.PreviousExpiresAt) {
+		secrets = append(secrets, s.Previous)
+	}
+	return secrets
+}
+
+// SigningSecretPrefix returns the start of a secret, enough to tell secrets apart without revealing them
+func SigningSecretPrefix(secret string) string {
+	if len(secret) <= signingSecretPrefixLength {
+		return secret
+	}
+	return secret[:signingSecretPrefixLength]
+}
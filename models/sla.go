@@ -0,0 +1,100 @@
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// SLA breach kinds
+const (
+	SLABreachDuration    = "duration"
+	SLABreachFailureRate = "failure_rate"
+)
+
+// SLA defines service level objectives for an agent or a set of session topics
+type SLA struct {
+	ID                 string    `json:"id"`
+	UserID             string    `json:"user_id"`
+	Name               string    `json:"name"`
+	AgentID            string    `json:"agent_id,omitempty"`             // Empty applies to all of the user's agents
+	TopicPattern       string    `json:"topic_pattern,omitempty"`        // Regular expression; empty matches every topic
+	MaxDurationMinutes int       `json:"max_duration_minutes,omitempty"` // 0 disables the duration objective
+	MaxFailureRate     float64   `json:"max_failure_rate,omitempty"`     // Daily failure ratio 0-1; 0 disables the objective
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// Validate validates SLA fields
+func (s *SLA) Validate() error {
+	if s.ID == "" {
+		return Required("id")
+	}
+	if len(s.ID) > 36 {
+		return Length("id", "<= 36", "characters")
+	}
+	if s.UserID == "" {
+		return Required("user_id")
+	}
+	if s.Name == "" {
+		return Required("name")
+	}
+	if len(s.Name) > 100 {
+		return Length("name", "<= 100", "characters")
+	}
+	if len(s.AgentID) > 100 {
+		return Length("agent_id", "0-100", "characters")
+	}
+	if len(s.TopicPattern) > 500 {
+		return Length("topic_pattern", "0-500", "characters")
+	}
+	if _, err := regexp.Compile(s.TopicPattern); err != nil {
+		return Invalid("topic_pattern", "topic_pattern must be a valid regular expression")
+	}
+	if s.MaxDurationMinutes < 0 {
+		return Range("max_duration_minutes", ">= 0")
+	}
+	if s.MaxFailureRate < 0 || s.MaxFailureRate > 1 {
+		return Range("max_failure_rate", "between 0 and 1")
+	}
+	if s.MaxDurationMinutes == 0 && s.MaxFailureRate == 0 {
+		return Invalid("max_duration_minutes", "max_duration_minutes or max_failure_rate is required")
+	}
+	return nil
+}
+
+// SLABreach records a single violation of an SLA objective
+// Subject identifies what breached: the session topic for duration breaches, the UTC day for failure rate breaches.
+type SLABreach struct {
+	ID         string    `json:"id"`
+	SLAID      string    `json:"sla_id"`
+	UserID     string    `json:"user_id"`
+	AgentID    string    `json:"agent_id"`
+	Kind       string    `json:"kind"`
+	Subject    string    `json:"subject"`
+	Value      float64   `json:"value"`
+	Threshold  float64   `json:"threshold"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// Validate validates SLABreach fields
+func (b *SLABreach) Validate() error {
+	if b.ID == "" {
+		return Required("id")
+	}
+	if b.SLAID == "" {
+		return Required("sla_id")
+	}
+	if b.AgentID == "" {
+		return Required("agent_id")
+	}
+	if b.Kind != SLABreachDuration && b.Kind != SLABreachFailureRate {
+		return OneOf("kind", "duration", "failure_rate")
+	}
+	if b.Subject == "" {
+		return Required("subject")
+	}
+	if b.DetectedAt.IsZero() {
+		return Required("detected_at")
+	}
+	return nil
+}
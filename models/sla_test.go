@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSLA_Validate(t *testing.T) {
+	valid := func() SLA {
+		return SLA{
+			ID:                 "sla-001",
+			UserID:             "user-001",
+			Name:               "Nightly backup",
+			TopicPattern:       "^backup",
+			MaxDurationMinutes: 60,
+			CreatedAt:          time.Now(),
+			UpdatedAt:          time.Now(),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*SLA)
+		wantErr bool
+	}{
+		{"valid sla", func(s *SLA) {}, false},
+		{"failure rate only", func(s *SLA) { s.MaxDurationMinutes = 0; s.MaxFailureRate = 0.1 }, false},
+		{"missing name", func(s *SLA) { s.Name = "" }, true},
+		{"missing user_id", func(s *SLA) { s.UserID = "" }, true},
+		{"invalid topic pattern", func(s *SLA) { s.TopicPattern = "(" }, true},
+		{"failure rate above 1", func(s *SLA) { s.MaxFailureRate = 1.5 }, true},
+		{"negative duration", func(s *SLA) { s.MaxDurationMinutes = -1 }, true},
+		{"no objectives", func(s *SLA) { s.MaxDurationMinutes = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sla := valid()
+			tt.modify(&sla)
+			if err := sla.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("SLA.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
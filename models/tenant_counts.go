@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// TenantCounts is how many agents, sessions and statuses a user has, kept so stats and usage need not count them
+// The counts grow as reports create records and are corrected by reconciling them with the stored records, which
+// also reflects what retention purged; between reconciliations they are approximate.
+type TenantCounts struct {
+	UserID       string    `json:"user_id"`
+	Agents       int64     `json:"agents"`   // Agents not deleted
+	Sessions     int64     `json:"sessions"` // Sessions of those agents
+	Statuses     int64     `json:"statuses"` // Statuses of those sessions still stored
+	ReconciledAt time.Time `json:"reconciled_at,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Validate validates TenantCounts fields as a change to add to a user's counts
+func (c *TenantCounts) Validate() error {
+	if c.UserID == "" {
+		return Required("user_id")
+	}
+	if c.Agents < 0 || c.Sessions < 0 || c.Statuses < 0 {
+		return Invalid("", "counts added must not be negative")
+	}
+	return nil
+}
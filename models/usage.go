@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// UsageRecord counts what a user consumed on one UTC day, as the basis for chargeback
+type UsageRecord struct {
+	UserID            string    `json:"user_id"`
+	Day               time.Time `json:"day"`                // Midnight UTC of the day
+	StatusReports     int64     `json:"status_reports"`     // Status reports ingested, including sampled-out heartbeats
+	StorageBytes      int64     `json:"storage_bytes"`      // Bytes of message, content and metadata of the statuses stored
+	NotificationsSent int64     `json:"notifications_sent"` // Notification messages queued for the user's receivers
+}
+
+// UsageDay returns midnight UTC of the day t falls on
+func UsageDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Validate validates UsageRecord fields
+func (u *UsageRecord) Validate() error {
+	if u.UserID == "" {
+		return Required("user_id")
+	}
+	if u.Day.IsZero() {
+		return Required("day")
+	}
+	if u.StatusReports < 0 || u.StorageBytes < 0 || u.NotificationsSent < 0 {
+		return Invalid("", "usage counts must not be negative")
+	}
+	return nil
+}
@@ -1,22 +1,43 @@
 package models
 
 import (
-	"errors"
+	"encoding/base64"
+	"fmt"
 	"regexp"
 	"time"
 )
 
 // User represents a system user
 type User struct {
-	ID                     string    `json:"id"`
-	Email                  string    `json:"email"`
-	PasswordHash           string    `json:"-"` // Never expose in JSON
-	Name                   string    `json:"name,omitempty"`
-	NotificationWebhookURL string    `json:"notification_webhook_url,omitempty"`
-	EmailVerified          bool      `json:"email_verified"`
-	VerifyToken            string    `json:"-"` // Never expose in JSON
-	CreatedAt              time.Time `json:"created_at"`
-	UpdatedAt              time.Time `json:"updated_at"`
+	ID                       string                    `json:"id"`
+	Email                    string                    `json:"email"`
+	PasswordHash             string                    `json:"-"` // Never expose in JSON
+	Name                     string                    `json:"name,omitempty"`
+	NotificationWebhookURL   string                    `json:"notification_webhook_url,omitempty"`
+	NotificationMentions     []MentionRule             `json:"notification_mentions,omitempty"`     // Chat users @-mentioned per agent or topic
+	NotificationDestinations []NotificationDestination `json:"notification_destinations,omitempty"` // Extra receivers, each with its own URL template and format
+	Plan                     string                    `json:"plan,omitempty"`                      // Quota tier; empty uses deployment defaults
+	Role                     string                    `json:"role,omitempty"`                      // One of the UserRole constants; empty is a member
+	SessionAutoClose         SessionAutoClose          `json:"session_auto_close"`                  // What happens to running sessions of agents that are deleted or go offline
+	ExportPublicKey          string                    `json:"export_public_key,omitempty"`         // Base64 X25519 key the user's exports are encrypted to; empty leaves them in the clear
+	EmailVerified            bool                      `json:"email_verified"`
+	DisabledAt               *time.Time                `json:"disabled_at,omitempty"` // Set while an admin has disabled the account
+	VerifyToken              string                    `json:"-"`                     // Never expose in JSON
+	VerifyTokenExpiresAt     *time.Time                `json:"-"`                     // nil when no verification is pending
+	CreatedAt                time.Time                 `json:"created_at"`
+	UpdatedAt                time.Time                 `json:"updated_at"`
+}
+
+// Deployment-wide roles of users, recorded in User.Role
+const (
+	UserRoleAdmin  = "admin"  // Manages deployment-wide settings and other users
+	UserRoleMember = "member" // Manages their own agents and credentials
+	UserRoleViewer = "viewer" // Only reads
+)
+
+// ValidUserRole reports whether role is one of the UserRole constants
+func ValidUserRole(role string) bool {
+	return role == UserRoleAdmin || role == UserRoleMember || role == UserRoleViewer
 }
 
 var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
@@ -24,33 +45,77 @@ var emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]
 // Validate validates User fields
 func (u *User) Validate() error {
 	if u.ID == "" {
-		return errors.New("id is required")
+		return Required("id")
 	}
 	if len(u.ID) > 36 {
-		return errors.New("id must be <= 36 characters")
+		return Length("id", "<= 36", "characters")
 	}
 	if u.Email == "" {
-		return errors.New("email is required")
+		return Required("email")
 	}
 	if !emailRegex.MatchString(u.Email) {
-		return errors.New("invalid email format")
+		return Invalid("email", "invalid email format")
 	}
 	if len(u.Email) > 255 {
-		return errors.New("email must be <= 255 characters")
+		return Length("email", "<= 255", "characters")
 	}
 	if len(u.Name) > 200 {
-		return errors.New("name must be <= 200 characters")
+		return Length("name", "<= 200", "characters")
+	}
+	if len(u.Plan) > 50 {
+		return Length("plan", "<= 50", "characters")
+	}
+	if u.Role != "" && !ValidUserRole(u.Role) {
+		return OneOf("role", UserRoleAdmin, UserRoleMember, UserRoleViewer)
 	}
 	if u.PasswordHash == "" {
-		return errors.New("password_hash is required")
+		return Required("password_hash")
+	}
+	if len(u.NotificationMentions) > MaxMentionRules {
+		return Invalid("notification_mentions", fmt.Sprintf("notification_mentions must have at most %d rules", MaxMentionRules))
+	}
+	for i := range u.NotificationMentions {
+		if err := u.NotificationMentions[i].Validate(); err != nil {
+			return Nested(fmt.Sprintf("notification_mentions[%d]", i), fmt.Sprintf("/notification_mentions/%d", i), err)
+		}
+	}
+	if len(u.NotificationDestinations) > MaxNotificationDestinations {
+		return Invalid("notification_destinations", fmt.Sprintf("notification_destinations must have at most %d destinations", MaxNotificationDestinations))
+	}
+	for i := range u.NotificationDestinations {
+		if err := u.NotificationDestinations[i].Validate(); err != nil {
+			return Nested(fmt.Sprintf("notification_destinations[%d]", i), fmt.Sprintf("/notification_destinations/%d", i), err)
+		}
+	}
+	if err := u.SessionAutoClose.Validate(); err != nil {
+		return Nested("session_auto_close", "/session_auto_close", err)
+	}
+	if u.ExportPublicKey != "" {
+		if raw, err := base64.StdEncoding.DecodeString(u.ExportPublicKey); err != nil || len(raw) != 32 {
+			return Invalid("export_public_key", "export_public_key must be a base64-encoded 32-byte X25519 key")
+		}
 	}
 	return nil
 }
 
+// MentionsFor returns the rules matching a session of an agent, once per chat user
+func (u *User) MentionsFor(agentID, sessionTopic string) []MentionRule {
+	var matched []MentionRule
+	seen := make(map[string]bool)
+	for _, rule := range u.NotificationMentions {
+		if seen[rule.ChatUserID] || !rule.Matches(agentID, sessionTopic) {
+			continue
+		}
+		seen[rule.ChatUserID] = true
+		matched = append(matched, rule)
+	}
+	return matched
+}
+
 // ValidatePassword validates password strength requirements
 func ValidatePassword(password string) error {
 	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters")
+		return Length("password", "at least 8", "characters")
 	}
 	return nil
 }
@@ -68,16 +133,16 @@ type RefreshToken struct {
 // Validate validates RefreshToken fields
 func (rt *RefreshToken) Validate() error {
 	if rt.ID == "" {
-		return errors.New("id is required")
+		return Required("id")
 	}
 	if rt.UserID == "" {
-		return errors.New("user_id is required")
+		return Required("user_id")
 	}
 	if rt.TokenHash == "" {
-		return errors.New("token_hash is required")
+		return Required("token_hash")
 	}
 	if rt.ExpiresAt.IsZero() {
-		return errors.New("expires_at is required")
+		return Required("expires_at")
 	}
 	return nil
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -188,3 +189,39 @@ func TestValidatePassword(t *testing.T) {
 		})
 	}
 }
+
+func TestUser_MentionsFor(t *testing.T) {
+	user := &User{
+		NotificationMentions: []MentionRule{
+			{AgentID: "builder", ChatUserID: "U1"},
+			{TopicPattern: "^deploy/", ChatUserID: "U2"},
+			{AgentID: "builder", TopicPattern: "^deploy/", ChatUserID: "U1"},
+			{AgentID: "other", ChatUserID: "U3"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		agentID string
+		topic   string
+		want    []string
+	}{
+		{"agent rule", "builder", "build-1", []string{"U1"}},
+		{"agent and topic rules without duplicates", "builder", "deploy/prod", []string{"U1", "U2"}},
+		{"topic rule for any agent", "runner", "deploy/prod", []string{"U2"}},
+		{"agent-wide event skips topic rules", "runner", "", nil},
+		{"no match", "runner", "build-1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, rule := range user.MentionsFor(tt.agentID, tt.topic) {
+				got = append(got, rule.ChatUserID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("MentionsFor(%q, %q) = %v, want %v", tt.agentID, tt.topic, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Validation error codes, telling clients what kind of rule a field broke
+const (
+	CodeRequired = "required" // The field is missing or empty
+	CodeLength   = "length"   // The field is too long or too short; Constraint holds the allowed length
+	CodeRange    = "range"    // The number is out of range; Constraint holds the allowed values
+	CodeOneOf    = "one_of"   // The value is not one of those in Constraint
+	CodeInvalid  = "invalid"  // The value breaks another rule, described by Message
+)
+
+// FieldError describes what is wrong with one field of a record or request
+// Its message is the same one validation errors always had, so callers printing the error see no change.
+type FieldError struct {
+	Field      string `json:"field"`                // JSON Pointer to the field, e.g. "/agent_id"
+	Code       string `json:"code"`                 // One of the Code constants
+	Message    string `json:"message"`              // Human readable, e.g. "agent_id is required"
+	Constraint string `json:"constraint,omitempty"` // The allowed length, range or values, e.g. "1-100"
+}
+
+// Error returns the message
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// FieldErrors is every field error found in one validation, which reports all of them at once
+type FieldErrors []*FieldError
+
+// Error joins the messages
+func (e FieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Err returns e as an error, or nil when it holds no field errors
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Add appends err as field errors, adding nothing for nil and other errors as invalid values of field
+func (e *FieldErrors) Add(field string, err error) {
+	if err == nil {
+		return
+	}
+	if fieldErrs := FieldErrorsOf(err); len(fieldErrs) > 0 {
+		*e = append(*e, fieldErrs...)
+		return
+	}
+	*e = append(*e, Invalid(field, err.Error()))
+}
+
+// FieldErrorsOf returns the field errors in err's chain, or nil when it has none
+func FieldErrorsOf(err error) []*FieldError {
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		return fieldErrs
+	}
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		return []*FieldError{fieldErr}
+	}
+	return nil
+}
+
+// Nested places the field errors in err under a field holding a nested record, such as one entry of a list
+// The messages gain label as a prefix and the fields pointer, e.g. Nested("destinations[0]", "/destinations/0",
+// err) turns "url is required" at "/url" into "destinations[0]: url is required" at "/destinations/0/url".
+// Other errors only gain the prefix.
+func Nested(label, pointer string, err error) error {
+	fieldErrs := FieldErrorsOf(err)
+	if len(fieldErrs) == 0 {
+		return fmt.Errorf("%s: %w", label, err)
+	}
+	nested := make(FieldErrors, len(fieldErrs))
+	for i, fieldErr := range fieldErrs {
+		copied := *fieldErr
+		copied.Field = pointer + fieldErr.Field
+		copied.Message = label + ": " + fieldErr.Message
+		nested[i] = &copied
+	}
+	if len(nested) == 1 {
+		return nested[0]
+	}
+	return nested
+}
+
+// pointer returns the JSON Pointer of a top-level field; an empty field points at the whole record
+func pointer(field string) string {
+	if field == "" {
+		return ""
+	}
+	return "/" + strings.ReplaceAll(strings.ReplaceAll(field, "~", "~0"), "/", "~1")
+}
+
+// Required reports a missing field
+func Required(field string) *FieldError {
+	return &FieldError{Field: pointer(field), Code: CodeRequired, Message: field + " is required"}
+}
+
+// Length reports a field whose length is out of bounds, e.g. Length("name", "1-100", "characters")
+func Length(field, constraint, unit string) *FieldError {
+	return &FieldError{Field: pointer(field), Code: CodeLength, Message: field + " must be " + constraint + " " + unit, Constraint: constraint}
+}
+
+// Range reports a number out of range, e.g. Range("ttl_minutes", "0 or 1-1440")
+func Range(field, constraint string) *FieldError {
+	return &FieldError{Field: pointer(field), Code: CodeRange, Message: field + " must be " + constraint, Constraint: constraint}
+}
+
+// OneOf reports a value that is not one of the allowed values
+func OneOf(field string, values ...string) *FieldError {
+	constraint := strings.Join(values, ", ")
+	return &FieldError{Field: pointer(field), Code: CodeOneOf, Message: field + " must be one of: " + constraint, Constraint: constraint}
+}
+
+// Invalid reports a value breaking any other rule; message is shown as it is
+func Invalid(field, message string) *FieldError {
+	return &FieldError{Field: pointer(field), Code: CodeInvalid, Message: message}
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNested(t *testing.T) {
+	destination := NotificationDestination{URL: "https://example.com", Format: "mattermost"}
+	err := Nested("destinations[1]", "/destinations/1", destination.Validate())
+
+	fieldErrs := FieldErrorsOf(fmt.Errorf("wrapped: %w", err))
+	if len(fieldErrs) != 1 {
+		t.Fatalf("FieldErrorsOf() = %v, want one field error", fieldErrs)
+	}
+	got := fieldErrs[0]
+	if got.Field != "/destinations/1/format" || got.Code != CodeOneOf || !strings.HasPrefix(got.Message, "destinations[1]: format") {
+		t.Errorf("nested error = %+v, want the format of destination 1", got)
+	}
+
+	plain := Nested("session_auto_close", "/session_auto_close", errors.New("boom"))
+	if plain.Error() != "session_auto_close: boom" || FieldErrorsOf(plain) != nil {
+		t.Errorf("Nested() of a plain error = %v, want only the prefix", plain)
+	}
+}
+
+func TestFieldErrors_Err(t *testing.T) {
+	var errs FieldErrors
+	if errs.Err() != nil {
+		t.Error("Err() of no field errors is not nil")
+	}
+	errs.Add("cluster", nil)
+	errs.Add("cluster", errors.New("cluster is odd"))
+	errs.Add("name", Required("name"))
+	if len(errs) != 2 || errs[0].Field != "/cluster" || errs[0].Code != CodeInvalid || errs[1].Code != CodeRequired {
+		t.Errorf("field errors = %+v, want an invalid cluster and a missing name", errs)
+	}
+}
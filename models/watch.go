@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// WatchItem is an agent starred or a session watched by a user
+// An empty SessionTopic stars the whole agent. Watched items are listed first
+// and may override where, or whether, their status notifications are sent.
+type WatchItem struct {
+	UserID                 string    `json:"-"`
+	AgentID                string    `json:"agent_id"`
+	SessionTopic           string    `json:"session_topic,omitempty"`
+	NotificationWebhookURL string    `json:"notification_webhook_url,omitempty"` // Replaces the user's webhook URL when set
+	MuteNotifications      bool      `json:"mute_notifications"`
+	CreatedAt              time.Time `json:"created_at"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+// IsAgent reports whether the item stars an agent rather than watching a session
+func (w *WatchItem) IsAgent() bool {
+	return w.SessionTopic == ""
+}
+
+// Validate validates WatchItem fields
+func (w *WatchItem) Validate() error {
+	if w.UserID == "" {
+		return Required("user_id")
+	}
+	if w.AgentID == "" || len(w.AgentID) > 100 {
+		return Length("agent_id", "1-100", "characters")
+	}
+	if len(w.SessionTopic) > 500 {
+		return Length("session_topic", "0-500", "characters")
+	}
+	return nil
+}
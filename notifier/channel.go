@@ -0,0 +1,263 @@
+package notifier
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// NotifierChannel builds webhook payloads in the message format of one chat tool
+type NotifierChannel interface {
+	// Format is the name destinations and NOTIFICATION_DEFAULT_FORMAT select the channel by
+	Format() string
+	// Payload wraps text in the channel's message format, @-mentioning each user
+	Payload(text string, mentions []Mention) ([]byte, error)
+}
+
+// builtinChannels holds the channels shipped with the notifier by format
+var builtinChannels = map[string]NotifierChannel{
+	PlatformGeneric: genericChannel{},
+	PlatformJSON:    jsonChannel{},
+	PlatformFeishu:  feishuChannel{},
+	PlatformSlack:   slackChannel{},
+	PlatformDiscord: discordChannel{},
+	PlatformTeams:   teamsChannel{},
+}
+
+var (
+	channelsMu sync.RWMutex
+	// channels holds the known channels by format, the built-in ones and those registered since
+	channels = func() map[string]NotifierChannel {
+		known := make(map[string]NotifierChannel, len(builtinChannels))
+		for format, channel := range builtinChannels {
+			known[format] = channel
+		}
+		return known
+	}()
+)
+
+// channelFormat is the shape of the format a registered channel may use
+var channelFormat = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// Register adds a channel under its format, so destinations and NOTIFICATION_DEFAULT_FORMAT can select it
+// Channels implementing NotifierPlugin deliver their messages themselves. Registering a format again replaces
+// its channel, but built-in formats cannot be replaced. Register channels before notifications are sent,
+// e.g. from an init function of a compiled-in plugin or from main.
+func Register(channel NotifierChannel) error {
+	format := channel.Format()
+	if !channelFormat.MatchString(format) {
+		return fmt.Errorf("invalid channel format %q: must be 1-32 lowercase letters, digits, '-' or '_', starting with a letter", format)
+	}
+	if _, ok := builtinChannels[format]; ok {
+		return fmt.Errorf("channel format %q is built in", format)
+	}
+
+	channelsMu.Lock()
+	channels[format] = channel
+	channelsMu.Unlock()
+
+	models.RegisterNotificationFormat(format)
+	return nil
+}
+
+// Channel returns the channel of a format and whether it is known
+func Channel(format string) (NotifierChannel, bool) {
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+	channel, ok := channels[format]
+	return channel, ok
+}
+
+// Formats lists the formats of all known channels, sorted
+func Formats() []string {
+	channelsMu.RLock()
+	defer channelsMu.RUnlock()
+	formats := make([]string, 0, len(channels))
+	for format := range channels {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// channelFor returns the channel of a format, falling back to the generic one
+func channelFor(format string) NotifierChannel {
+	if channel, ok := Channel(format); ok {
+		return channel
+	}
+	return genericChannel{}
+}
+
+// genericChannel sends the "msg_type/text" payload understood by Feishu-style bots
+type genericChannel struct{}
+
+func (genericChannel) Format() string { return PlatformGeneric }
+
+func (genericChannel) Payload(text string, mentions []Mention) ([]byte, error) {
+	tags := make([]string, len(mentions))
+	for i, m := range mentions {
+		tags[i] = "@" + m.displayName()
+	}
+	return json.Marshal(WebhookPayload{MsgType: "text", Content: WebhookContent{Text: withMentions(text, tags)}})
+}
+
+// jsonPayload is a plain JSON message for receivers other than chat tools
+type jsonPayload struct {
+	Text     string        `json:"text"`
+	Mentions []jsonMention `json:"mentions"`
+}
+
+type jsonMention struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+}
+
+// jsonChannel sends the text and mentions as separate fields, leaving the rendering to the receiver
+type jsonChannel struct{}
+
+func (jsonChannel) Format() string { return PlatformJSON }
+
+func (jsonChannel) Payload(text string, mentions []Mention) ([]byte, error) {
+	payload := jsonPayload{Text: text, Mentions: make([]jsonMention, 0, len(mentions))}
+	for _, m := range mentions {
+		payload.Mentions = append(payload.Mentions, jsonMention{UserID: m.UserID, Name: m.displayName()})
+	}
+	return json.Marshal(payload)
+}
+
+// feishuChannel sends the generic payload with Feishu/Lark mention tags
+type feishuChannel struct{}
+
+func (feishuChannel) Format() string { return PlatformFeishu }
+
+func (feishuChannel) Payload(text string, mentions []Mention) ([]byte, error) {
+	tags := make([]string, len(mentions))
+	for i, m := range mentions {
+		tags[i] = fmt.Sprintf(`<at user_id="%s">%s</at>`, m.UserID, m.displayName())
+	}
+	return json.Marshal(WebhookPayload{MsgType: "text", Content: WebhookContent{Text: withMentions(text, tags)}})
+}
+
+// slackPayload is a Slack incoming webhook message
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// slackChannel sends Slack incoming webhook messages
+type slackChannel struct{}
+
+func (slackChannel) Format() string { return PlatformSlack }
+
+func (slackChannel) Payload(text string, mentions []Mention) ([]byte, error) {
+	tags := make([]string, len(mentions))
+	for i, m := range mentions {
+		tags[i] = "<@" + m.UserID + ">"
+	}
+	return json.Marshal(slackPayload{Text: withMentions(text, tags)})
+}
+
+// discordPayload is a Discord webhook message; only the listed users may be pinged
+type discordPayload struct {
+	Content         string                 `json:"content"`
+	AllowedMentions discordAllowedMentions `json:"allowed_mentions"`
+}
+
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"`
+	Users []string `json:"users"`
+}
+
+// maxDiscordContent is the longest message content Discord accepts
+const maxDiscordContent = 2000
+
+// discordChannel sends Discord webhook messages
+// Discord rejects longer content, so the text is cut to fit while the mentions are kept.
+type discordChannel struct{}
+
+func (discordChannel) Format() string { return PlatformDiscord }
+
+func (discordChannel) Payload(text string, mentions []Mention) ([]byte, error) {
+	tags := make([]string, len(mentions))
+	users := make([]string, 0, len(mentions))
+	for i, m := range mentions {
+		tags[i] = "<@" + m.UserID + ">"
+		users = append(users, m.UserID)
+	}
+
+	content := withMentions(text, tags)
+	if overflow := len([]rune(content)) - maxDiscordContent; overflow > 0 {
+		runes := []rune(text)
+		content = withMentions(string(runes[:max(len(runes)-overflow-1, 0)])+"…", tags)
+	}
+	return json.Marshal(discordPayload{
+		Content:         content,
+		AllowedMentions: discordAllowedMentions{Parse: []string{}, Users: users},
+	})
+}
+
+// teamsPayload is a Teams message carrying a single Adaptive Card
+type teamsPayload struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     teamsCard `json:"content"`
+}
+
+type teamsCard struct {
+	Type    string          `json:"type"`
+	Version string          `json:"version"`
+	Body    []teamsText     `json:"body"`
+	MSTeams *teamsCardExtra `json:"msteams,omitempty"`
+}
+
+type teamsText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	Wrap bool   `json:"wrap"`
+}
+
+type teamsCardExtra struct {
+	Entities []teamsMention `json:"entities"`
+}
+
+type teamsMention struct {
+	Type      string            `json:"type"`
+	Text      string            `json:"text"`
+	Mentioned map[string]string `json:"mentioned"`
+}
+
+// teamsChannel sends Microsoft Teams Adaptive Card messages
+type teamsChannel struct{}
+
+func (teamsChannel) Format() string { return PlatformTeams }
+
+func (teamsChannel) Payload(text string, mentions []Mention) ([]byte, error) {
+	tags := make([]string, len(mentions))
+	card := teamsCard{Type: "AdaptiveCard", Version: "1.2"}
+	if len(mentions) > 0 {
+		card.MSTeams = &teamsCardExtra{}
+	}
+	for i, m := range mentions {
+		tags[i] = "<at>" + m.displayName() + "</at>"
+		card.MSTeams.Entities = append(card.MSTeams.Entities, teamsMention{
+			Type:      "mention",
+			Text:      tags[i],
+			Mentioned: map[string]string{"id": m.UserID, "name": m.displayName()},
+		})
+	}
+	card.Body = []teamsText{{Type: "TextBlock", Text: withMentions(text, tags), Wrap: true}}
+	return json.Marshal(teamsPayload{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	})
+}
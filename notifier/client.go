@@ -21,6 +21,7 @@ const (
 type HTTPClient struct {
 	timeout    time.Duration
 	httpClient *http.Client
+	secrets    SigningSecrets
 }
 
 // NewHTTPClient creates a new HTTP client
@@ -35,8 +36,18 @@ func NewHTTPClient(timeout time.Duration) *HTTPClient {
 
 // Send sends payload to webhook URL with retry logic
 func (c *HTTPClient) Send(ctx context.Context, url string, payload []byte) error {
+	return c.send(ctx, url, payload, nil)
+}
+
+// send sends payload to webhook URL with retry logic, setting header on each request
+func (c *HTTPClient) send(ctx context.Context, url string, payload []byte, header http.Header) error {
 	var lastErr error
 
+	var secrets []string
+	if c.secrets != nil {
+		secrets = c.secrets()
+	}
+
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
 			// Exponential backoff
@@ -58,6 +69,14 @@ func (c *HTTPClient) Send(ctx context.Context, url string, payload []byte) error
 		}
 
 		req.Header.Set("Content-Type", "application/json")
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if len(secrets) > 0 {
+			timestamp, signature := signatureHeaders(secrets, time.Now(), payload)
+			req.Header.Set(TimestampHeader, timestamp)
+			req.Header.Set(SignatureHeader, signature)
+		}
 
 		// Send request
 		resp, err := c.httpClient.Do(req)
@@ -211,3 +211,32 @@ func TestHTTPClient_Send_Timeout(t *testing.T) {
 		t.Error("Send() with timeout, error = nil, want timeout error")
 	}
 }
+
+func TestHTTPClient_Send_Signed(t *testing.T) {
+	payload := []byte(`{"msg_type":"text","content":{"text":"test"}}`)
+	var signature, timestamp string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(SignatureHeader)
+		timestamp = r.Header.Get(TimestampHeader)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(5 * time.Second)
+	if err := client.Send(context.Background(), server.URL, payload); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if signature != "" || timestamp != "" {
+		t.Errorf("Send() without secrets signature = %q, timestamp = %q, want none", signature, timestamp)
+	}
+
+	// During a rotation both the new and the previous secret sign
+	client.secrets = func() []string { return []string{"new-secret", "old-secret"} }
+	if err := client.Send(context.Background(), server.URL, payload); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	want := Sign("new-secret", timestamp, payload) + "," + Sign("old-secret", timestamp, payload)
+	if timestamp == "" || signature != want {
+		t.Errorf("Send() signature = %q at %q, want %q", signature, timestamp, want)
+	}
+}
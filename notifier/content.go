@@ -0,0 +1,149 @@
+package notifier
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// MaxEmbeddedContent bounds the characters of status content embedded in a notification; longer content is cut
+// and followed by a link to the session when its dashboard page is known
+const MaxEmbeddedContent = 1000
+
+// contentPolicy is how a channel shows markdown content embedded in its messages
+type contentPolicy int
+
+const (
+	// contentPlain channels show text as is: markup is removed and links are written out as "text (url)"
+	contentPlain contentPolicy = iota
+	// contentMarkdown channels render markdown: formatting and http(s) links are kept
+	contentMarkdown
+	// contentSlack channels render Slack mrkdwn: links become <url|text>, and &, < and > are escaped
+	contentSlack
+)
+
+// policyFor returns the content policy of a channel; channels other than the built-in chat tools show plain text
+func policyFor(platform string) contentPolicy {
+	switch platform {
+	case PlatformDiscord, PlatformTeams:
+		return contentMarkdown
+	case PlatformSlack:
+		return contentSlack
+	default:
+		return contentPlain
+	}
+}
+
+var (
+	// unsafeBlocks are HTML elements dropped with everything inside them
+	unsafeBlocks = regexp.MustCompile(`(?is)<(?:script|style|iframe|object|embed)\b.*?(?:</\s*(?:script|style|iframe|object|embed)\s*>|\z)`)
+	// htmlTags matches HTML tags and comments, and chat tool specials such as Slack's <!channel> or <@U123>
+	htmlTags = regexp.MustCompile(`(?s)<!--.*?-->|</?[a-zA-Z!@#][^>]*>`)
+	// autolinks matches markdown autolinks, e.g. <https://example.com>
+	autolinks = regexp.MustCompile(`<(https?://[^\s<>]+)>`)
+	// markdownImages matches ![alt](url "title"), the url holding at most one level of parentheses
+	markdownImages = regexp.MustCompile(`!\[([^\]]*)\]\(((?:[^()\s]|\([^()\s]*\))*)(?:\s+"[^"]*")?\)`)
+	// markdownLinks matches [text](url "title") likewise
+	markdownLinks = regexp.MustCompile(`\[([^\]]*)\]\(((?:[^()\s]|\([^()\s]*\))*)(?:\s+"[^"]*")?\)`)
+)
+
+// embedContent returns the content of a notification as embedded in a message of platform
+// Markdown content is sanitized for the channel; any content is cut to MaxEmbeddedContent characters.
+func embedContent(platform string, data *NotificationData) string {
+	policy := policyFor(platform)
+	content := data.Content
+	if data.ContentFormat == models.ContentFormatMarkdown {
+		content = sanitizeMarkdown(content, policy)
+	}
+	return truncateContent(content, data.SessionURL, policy)
+}
+
+// sanitizeMarkdown makes markdown safe to embed in a channel's messages
+// Scripts and other active HTML are dropped with their contents and remaining tags are stripped, so neither
+// markup nor chat tool specials such as @-channel mentions get through. Images are replaced by their alt text,
+// and links whose scheme is not http, https or mailto keep only their text.
+func sanitizeMarkdown(content string, policy contentPolicy) string {
+	content = unsafeBlocks.ReplaceAllString(content, "")
+	content = autolinks.ReplaceAllString(content, "$1")
+	content = htmlTags.ReplaceAllString(content, "")
+	content = markdownImages.ReplaceAllString(content, "$1")
+	if policy == contentSlack {
+		content = escapeSlack(content)
+	}
+
+	return markdownLinks.ReplaceAllStringFunc(content, func(link string) string {
+		parts := markdownLinks.FindStringSubmatch(link)
+		text, target := strings.TrimSpace(parts[1]), parts[2]
+		if !safeLink(target) {
+			return text
+		}
+		if text == "" {
+			text = target
+		}
+		return formatLink(policy, text, target)
+	})
+}
+
+// safeLink reports whether a link target may be kept clickable
+func safeLink(target string) bool {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+		return parsed.Host != ""
+	case "mailto":
+		return true
+	default:
+		return false
+	}
+}
+
+// formatLink writes a link the way the channel renders it
+func formatLink(policy contentPolicy, text, target string) string {
+	switch policy {
+	case contentMarkdown:
+		return "[" + text + "](" + target + ")"
+	case contentSlack:
+		return "<" + target + "|" + strings.ReplaceAll(text, "|", "¦") + ">"
+	default:
+		if text == target {
+			return target
+		}
+		return text + " (" + target + ")"
+	}
+}
+
+// escapeSlack escapes the characters Slack treats as control characters in message text
+func escapeSlack(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// truncateContent cuts content longer than MaxEmbeddedContent characters, closing an open code block,
+// and points to the session's dashboard page for the rest
+func truncateContent(content, sessionURL string, policy contentPolicy) string {
+	runes := []rune(content)
+	if len(runes) <= MaxEmbeddedContent {
+		return content
+	}
+
+	cut := strings.TrimRight(string(runes[:MaxEmbeddedContent]), " \t\n") + "…"
+	if policy != contentPlain && strings.Count(cut, "```")%2 == 1 {
+		cut += "\n```"
+	}
+	if sessionURL == "" {
+		return cut
+	}
+	return cut + "\n" + formatLink(policy, "View full session", sessionURL)
+}
+
+// sessionURL returns the dashboard page of a session under base, an empty base linking nothing
+func sessionURL(base, agentID, sessionTopic string) string {
+	if base == "" {
+		return ""
+	}
+	return strings.TrimRight(base, "/") + "/agents/" + url.PathEscape(agentID) + "/sessions/" + url.PathEscape(sessionTopic)
+}
@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestSanitizeMarkdown(t *testing.T) {
+	content := "**Deploy** failed <script>alert(1)</script>see [logs](https://ci.example.com/1?a=1&b=2), " +
+		"[run](javascript:alert(1)) ![graph](https://img.example.com/g.png) <!channel> <b>now</b> <https://example.com/x>"
+
+	tests := []struct {
+		platform string
+		want     string
+	}{
+		{PlatformGeneric, "**Deploy** failed see logs (https://ci.example.com/1?a=1&b=2), run graph  now https://example.com/x"},
+		{PlatformDiscord, "**Deploy** failed see [logs](https://ci.example.com/1?a=1&b=2), run graph  now https://example.com/x"},
+		{PlatformSlack, "**Deploy** failed see <https://ci.example.com/1?a=1&amp;b=2|logs>, run graph  now https://example.com/x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			if got := sanitizeMarkdown(content, policyFor(tt.platform)); got != tt.want {
+				t.Errorf("sanitizeMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbedContent_Truncates(t *testing.T) {
+	data := &NotificationData{
+		Content:       "```\n" + strings.Repeat("x", MaxEmbeddedContent),
+		ContentFormat: models.ContentFormatMarkdown,
+		SessionURL:    "https://app.example.com/agents/agent-1/sessions/build",
+	}
+
+	got := embedContent(PlatformTeams, data)
+	if !strings.HasSuffix(got, "…\n```\n[View full session](https://app.example.com/agents/agent-1/sessions/build)") {
+		t.Errorf("embedContent() = %q, want the cut closing its code block and linking the session", got)
+	}
+	if got := embedContent(PlatformGeneric, data); !strings.HasSuffix(got, "…\nView full session (https://app.example.com/agents/agent-1/sessions/build)") {
+		t.Errorf("embedContent() plain = %q, want the session written out", got)
+	}
+
+	// Text content is cut too, but left as written
+	data = &NotificationData{Content: "<b>" + strings.Repeat("y", MaxEmbeddedContent)}
+	if got := embedContent(PlatformSlack, data); !strings.HasPrefix(got, "<b>") || len([]rune(got)) != MaxEmbeddedContent+1 {
+		t.Errorf("embedContent() text = %d characters, want the first %d kept as is", len([]rune(got)), MaxEmbeddedContent)
+	}
+}
+
+func TestNotificationManager_LinksSession(t *testing.T) {
+	nm := NewNotificationManager(0)
+	data := &NotificationData{AgentID: "agent 1", SessionTopic: "build/main"}
+	if got := nm.withSessionURL(data); got.SessionURL != "" {
+		t.Errorf("withSessionURL() without a base = %q, want none", got.SessionURL)
+	}
+
+	nm.SetSessionLinkBase("https://app.example.com/")
+	got := nm.withSessionURL(data)
+	if got.SessionURL != "https://app.example.com/agents/agent%201/sessions/build%2Fmain" || data.SessionURL != "" {
+		t.Errorf("withSessionURL() = %q, want the escaped session page without changing the data", got.SessionURL)
+	}
+
+	// Markdown is sanitized in the payload of each destination's channel
+	got.Content, got.ContentFormat = "see [logs](https://ci.example.com) <@U123>", models.ContentFormatMarkdown
+	payload, err := BuildPayloadFor(PlatformSlack, got)
+	if err != nil {
+		t.Fatalf("BuildPayloadFor() error = %v", err)
+	}
+	var slack slackPayload
+	if err := json.Unmarshal(payload, &slack); err != nil || !strings.Contains(slack.Text, "Content: see <https://ci.example.com|logs>") ||
+		strings.Contains(slack.Text, "<@U123>") {
+		t.Errorf("BuildPayloadFor(slack) text = %q, %v, want the link converted and the mention dropped", slack.Text, err)
+	}
+}
@@ -2,10 +2,16 @@ package notifier
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
 )
 
 // NotificationManager manages async notification delivery
@@ -15,14 +21,125 @@ type NotificationManager struct {
 	shutdownCh chan struct{}
 	mu         sync.Mutex
 	shutdown   bool
+
+	coalesceWindow time.Duration
+	defaultFormat  string                   // Payload format for webhook URLs of unrecognised platforms
+	linkBase       string                   // Dashboard URL session pages are linked under when content is cut
+	batches        map[string]*sessionBatch // destination, agent and session -> pending transitions
+	queue          Queue                    // Stores messages for delivery instead of in-process workers when set
+	clock          clock.Clock
+}
+
+// QueuedMessage is a notification payload built for one destination, as a Queue stores it
+type QueuedMessage struct {
+	Platform string `json:"platform"` // Format the payload was built in
+	URL      string `json:"url"`
+	Payload  []byte `json:"payload"`
+	AgentID  string `json:"agent_id,omitempty"` // Agent the notification is about, whose owner's data key seals it at rest
+}
+
+// Queue stores notifications until a worker delivers them with DeliverQueued, so they survive a restart
+// Workers deliver at least once: a message may be sent again if a worker stops before it is removed.
+type Queue interface {
+	Enqueue(msg *QueuedMessage) error
+}
+
+// sessionBatch collects one session's transitions until its aggregation window closes
+type sessionBatch struct {
+	destination models.NotificationDestination
+	events      []*NotificationData
+	timer       clock.Timer
 }
 
 // NewNotificationManager creates a new notification manager
 func NewNotificationManager(timeout time.Duration) *NotificationManager {
 	return &NotificationManager{
-		client:     NewHTTPClient(timeout),
-		shutdownCh: make(chan struct{}),
+		client:        NewHTTPClient(timeout),
+		shutdownCh:    make(chan struct{}),
+		defaultFormat: PlatformGeneric,
+		batches:       make(map[string]*sessionBatch),
+		clock:         clock.Real,
+	}
+}
+
+// SetDefaultFormat selects the channel formatting payloads for webhook URLs whose chat platform is not recognised,
+// unless their destination sets a format of its own
+func (nm *NotificationManager) SetDefaultFormat(format string) error {
+	if _, ok := Channel(format); !ok {
+		return fmt.Errorf("unknown notification format %q, must be one of: %s", format, strings.Join(Formats(), ", "))
+	}
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.defaultFormat = format
+	return nil
+}
+
+// platformFor returns the payload format of a destination, detecting it from the URL when the destination has none
+func (nm *NotificationManager) platformFor(format, webhookURL string) string {
+	if format != "" {
+		return format
+	}
+	if platform := DetectPlatform(webhookURL); platform != PlatformGeneric {
+		return platform
+	}
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	return nm.defaultFormat
+}
+
+// SetSigningSecrets signs every notification with the secrets returned by secrets, looked up as it is sent
+func (nm *NotificationManager) SetSigningSecrets(secrets SigningSecrets) {
+	nm.client.secrets = secrets
+}
+
+// SetTransport sends notifications through rt, e.g. to inject faults; nil restores the default transport
+func (nm *NotificationManager) SetTransport(rt http.RoundTripper) {
+	nm.client.httpClient.Transport = rt
+}
+
+// SetQueue hands messages to q instead of delivering them with in-process workers; nil restores the workers
+// Messages are built before they are queued, while batches inside their aggregation window are still only held
+// in memory.
+func (nm *NotificationManager) SetQueue(q Queue) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.queue = q
+}
+
+// SetCoalesceWindow holds status notifications for window so a session's transitions within it
+// are sent as one summary message; 0 sends every transition immediately
+func (nm *NotificationManager) SetCoalesceWindow(window time.Duration) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.coalesceWindow = window
+}
+
+// SetClock replaces the clock that closes aggregation windows
+func (nm *NotificationManager) SetClock(c clock.Clock) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.clock = c
+}
+
+// SetSessionLinkBase links the dashboard page of a session under base, e.g. APP_BASE_URL, from notifications
+// whose content is cut to fit; empty links nothing
+func (nm *NotificationManager) SetSessionLinkBase(base string) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	nm.linkBase = base
+}
+
+// withSessionURL returns data linking its session's dashboard page, leaving data itself unchanged
+func (nm *NotificationManager) withSessionURL(data *NotificationData) *NotificationData {
+	nm.mu.Lock()
+	base := nm.linkBase
+	nm.mu.Unlock()
+	if base == "" || data.SessionURL != "" {
+		return data
 	}
+	linked := *data
+	linked.SessionURL = sessionURL(base, data.AgentID, data.SessionTopic)
+	return &linked
 }
 
 // Notify sends a notification asynchronously
@@ -30,40 +147,283 @@ func (nm *NotificationManager) Notify(ctx context.Context, data *NotificationDat
 	if webhookURL == "" {
 		return nil
 	}
+	return nm.NotifyDestinations(ctx, data, []models.NotificationDestination{{URL: webhookURL}})
+}
+
+// NotifyDestinations sends a notification asynchronously to each destination
+// URL templates are filled in and payloads built in each destination's format when the message is sent.
+func (nm *NotificationManager) NotifyDestinations(ctx context.Context, data *NotificationData, destinations []models.NotificationDestination) error {
+	nm.mu.Lock()
+	window := nm.coalesceWindow
+	nm.mu.Unlock()
+	data = nm.withSessionURL(data)
+
+	var errs []error
+	for _, destination := range destinations {
+		if destination.URL == "" {
+			continue
+		}
+
+		if window <= 0 {
+			msg, err := nm.buildMessage(destination, []*NotificationData{data})
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			nm.dispatch(msg)
+			continue
+		}
+
+		nm.enqueue(data, destination, window)
+	}
+	return errors.Join(errs...)
+}
 
+// Deliver sends a notification to one destination synchronously, ignoring the aggregation window
+// The outbox relay uses it so a message is only removed once the destination accepted it.
+func (nm *NotificationManager) Deliver(ctx context.Context, data *NotificationData, destination models.NotificationDestination) error {
+	msg, err := nm.buildMessage(destination, []*NotificationData{nm.withSessionURL(data)})
+	if err != nil {
+		return err
+	}
+	return nm.deliver(ctx, msg)
+}
+
+// DeliverQueued sends a message taken from the queue synchronously
+func (nm *NotificationManager) DeliverQueued(ctx context.Context, msg *QueuedMessage) error {
+	return nm.deliver(ctx, &message{platform: msg.Platform, url: msg.URL, payload: msg.Payload})
+}
+
+// message is a payload ready to be sent to a destination
+type message struct {
+	platform string // Format the payload was built in, selecting the plugin delivering it if any
+	url      string
+	payload  []byte
+	agentID  string
+}
+
+// buildMessage renders the destination URL for the latest event and builds the payload in the destination's format
+// A single event gets the regular message and several events a summary.
+func (nm *NotificationManager) buildMessage(destination models.NotificationDestination, events []*NotificationData) (*message, error) {
+	last := events[len(events)-1]
+	webhookURL, err := destination.RenderURL(models.NotificationURLFields{
+		AgentID:      last.AgentID,
+		AgentName:    last.AgentName,
+		SessionTopic: last.SessionTopic,
+		FromStatus:   last.FromStatus,
+		ToStatus:     last.ToStatus,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render destination URL: %w", err)
+	}
+
+	platform := nm.platformFor(destination.Format, webhookURL)
+
+	var payload []byte
+	if len(events) == 1 {
+		payload, err = BuildTemplatedPayloadFor(platform, destination.Template, last)
+	} else {
+		payload, err = BuildSummaryPayloadFor(platform, events)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to build payload: %w", err)
+	}
+	return &message{platform: platform, url: webhookURL, payload: payload, agentID: last.AgentID}, nil
+}
+
+// NotifySLABreach sends an SLA breach notification asynchronously
+func (nm *NotificationManager) NotifySLABreach(ctx context.Context, data *SLABreachData, webhookURL string) error {
+	if webhookURL == "" {
+		return nil
+	}
+
+	platform := nm.platformFor("", webhookURL)
+	payload, err := BuildSLABreachPayloadFor(platform, data)
+	if err != nil {
+		return fmt.Errorf("failed to build payload: %w", err)
+	}
+
+	nm.dispatch(&message{platform: platform, url: webhookURL, payload: payload, agentID: data.AgentID})
+	return nil
+}
+
+// NotifyAgentOffline sends an agent offline notification asynchronously to each destination
+// URL templates are filled in with the agent's fields, leaving the session fields empty.
+func (nm *NotificationManager) NotifyAgentOffline(ctx context.Context, data *AgentOfflineData, destinations []models.NotificationDestination) error {
+	fields := models.NotificationURLFields{AgentID: data.AgentID, AgentName: data.AgentName}
+	return nm.dispatchAll(fields, destinations, func(platform string) ([]byte, error) {
+		return BuildAgentOfflinePayloadFor(platform, data)
+	})
+}
+
+// NotifyAlert sends an alert rule notification asynchronously to each destination
+// URL templates are filled in with the agent and session fields; ToStatus is the status a status rule matched.
+func (nm *NotificationManager) NotifyAlert(ctx context.Context, data *AlertData, destinations []models.NotificationDestination) error {
+	fields := models.NotificationURLFields{
+		AgentID:      data.AgentID,
+		AgentName:    data.AgentName,
+		SessionTopic: data.SessionTopic,
+		ToStatus:     data.Status,
+	}
+	return nm.dispatchAll(fields, destinations, func(platform string) ([]byte, error) {
+		return BuildAlertPayloadFor(platform, data)
+	})
+}
+
+// NotifyEscalation sends a reminder that a session is still failed asynchronously to each destination
+// URL templates are filled in with the agent and session fields, with failed as ToStatus.
+func (nm *NotificationManager) NotifyEscalation(ctx context.Context, data *EscalationData, destinations []models.NotificationDestination) error {
+	fields := models.NotificationURLFields{
+		AgentID:      data.AgentID,
+		AgentName:    data.AgentName,
+		SessionTopic: data.SessionTopic,
+		ToStatus:     "failed",
+	}
+	return nm.dispatchAll(fields, destinations, func(platform string) ([]byte, error) {
+		return BuildEscalationPayloadFor(platform, data)
+	})
+}
+
+// dispatchAll renders each destination's URL with fields and dispatches the payload built for its channel
+func (nm *NotificationManager) dispatchAll(fields models.NotificationURLFields, destinations []models.NotificationDestination, build func(platform string) ([]byte, error)) error {
+	var errs []error
+	for _, destination := range destinations {
+		if destination.URL == "" {
+			continue
+		}
+
+		webhookURL, err := destination.RenderURL(fields)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to render destination URL: %w", err))
+			continue
+		}
+		platform := nm.platformFor(destination.Format, webhookURL)
+		payload, err := build(platform)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to build payload: %w", err))
+			continue
+		}
+
+		nm.dispatch(&message{platform: platform, url: webhookURL, payload: payload, agentID: fields.AgentID})
+	}
+	return errors.Join(errs...)
+}
+
+// enqueue adds a transition to its session's batch, starting the aggregation window for a new batch
+func (nm *NotificationManager) enqueue(data *NotificationData, destination models.NotificationDestination, window time.Duration) {
+	key := destination.URL + "\x00" + destination.Format + "\x00" + destination.Template + "\x00" + data.AgentID + "\x00" + data.SessionTopic
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if nm.shutdown {
+		return // Skip if shutdown
+	}
+
+	if batch, exists := nm.batches[key]; exists {
+		batch.events = append(batch.events, data)
+		return
+	}
+
+	batch := &sessionBatch{
+		destination: destination,
+		events:      []*NotificationData{data},
+	}
+	nm.batches[key] = batch
+
+	// The batch counts as pending until it is delivered, so Shutdown waits for it
+	nm.wg.Add(1)
+	batch.timer = nm.clock.AfterFunc(window, func() {
+		nm.mu.Lock()
+		current, exists := nm.batches[key]
+		if !exists || current != batch {
+			nm.mu.Unlock()
+			return // Already taken by Shutdown
+		}
+		delete(nm.batches, key)
+		nm.mu.Unlock()
+
+		nm.deliverBatch(batch)
+	})
+}
+
+// deliverBatch sends a batch as a single message and marks it done
+func (nm *NotificationManager) deliverBatch(batch *sessionBatch) {
+	defer nm.wg.Done()
+
+	msg, err := nm.buildMessage(batch.destination, batch.events)
+	if err != nil {
+		log.Printf("Failed to build notification: %v", err)
+		return
+	}
+
+	if !nm.enqueueMessage(msg) {
+		nm.send(msg)
+	}
+}
+
+// dispatch launches an async worker delivering msg, or queues it, unless the manager is shut down
+func (nm *NotificationManager) dispatch(msg *message) {
 	// Check if already shutdown
 	nm.mu.Lock()
 	if nm.shutdown {
 		nm.mu.Unlock()
-		return nil // Skip if shutdown
+		return // Skip if shutdown
 	}
 	nm.mu.Unlock()
 
-	// Build payload
-	payload, err := BuildPayload(data)
-	if err != nil {
-		return fmt.Errorf("failed to build payload: %w", err)
+	if nm.enqueueMessage(msg) {
+		return
 	}
 
 	// Launch async worker
 	nm.wg.Add(1)
 	go func() {
 		defer nm.wg.Done()
+		nm.send(msg)
+	}()
+}
 
-		// Create context with timeout for this notification
-		notifyCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+// enqueueMessage hands msg to the queue and reports whether it took it
+// A message the queue fails to store is left to an in-process worker rather than lost.
+func (nm *NotificationManager) enqueueMessage(msg *message) bool {
+	nm.mu.Lock()
+	queue := nm.queue
+	nm.mu.Unlock()
+	if queue == nil {
+		return false
+	}
 
-		// Send notification (no shutdown check - let queued notifications complete)
-		if err := nm.client.Send(notifyCtx, webhookURL, payload); err != nil {
-			log.Printf("Failed to send notification: %v", err)
-		}
-	}()
+	if err := queue.Enqueue(&QueuedMessage{Platform: msg.platform, URL: msg.url, Payload: msg.payload, AgentID: msg.agentID}); err != nil {
+		log.Printf("Failed to queue notification, sending it now: %v", err)
+		return false
+	}
+	return true
+}
 
-	return nil
+// send delivers msg synchronously, logging a failure
+func (nm *NotificationManager) send(msg *message) {
+	// Create context with timeout for this notification
+	notifyCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Send notification (no shutdown check - let queued notifications complete)
+	if err := nm.deliver(notifyCtx, msg); err != nil {
+		log.Printf("Failed to send notification: %v", err)
+	}
+}
+
+// deliver hands msg to the plugin of its format, or posts it to its URL for other channels
+func (nm *NotificationManager) deliver(ctx context.Context, msg *message) error {
+	if plugin, ok := channelFor(msg.platform).(NotifierPlugin); ok {
+		return plugin.Deliver(ctx, msg.url, msg.payload)
+	}
+	return nm.client.Send(ctx, msg.url, msg.payload)
 }
 
 // Shutdown gracefully shuts down the notification manager
+// Batches still inside their aggregation window are sent immediately.
 func (nm *NotificationManager) Shutdown(ctx context.Context) error {
 	nm.mu.Lock()
 	if nm.shutdown {
@@ -71,8 +431,19 @@ func (nm *NotificationManager) Shutdown(ctx context.Context) error {
 		return nil
 	}
 	nm.shutdown = true
+
+	pending := make([]*sessionBatch, 0, len(nm.batches))
+	for key, batch := range nm.batches {
+		batch.timer.Stop()
+		pending = append(pending, batch)
+		delete(nm.batches, key)
+	}
 	nm.mu.Unlock()
 
+	for _, batch := range pending {
+		go nm.deliverBatch(batch)
+	}
+
 	// Wait for pending notifications with timeout
 	done := make(chan struct{})
 	go func() {
@@ -2,13 +2,18 @@ package notifier
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
 )
 
 func TestNotificationManager_Notify_Disabled(t *testing.T) {
@@ -253,3 +258,178 @@ func TestNotificationManager_NotifyAfterShutdown(t *testing.T) {
 		t.Errorf("Notify() after shutdown, error = %v, want nil", err)
 	}
 }
+
+func TestNotificationManager_CoalescesSessionTransitions(t *testing.T) {
+	var mu sync.Mutex
+	var texts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		texts = append(texts, payload.Content.Text)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	manager := NewNotificationManager(5 * time.Second)
+	manager.SetCoalesceWindow(100 * time.Millisecond)
+
+	now := time.Now()
+	for i, to := range []string{"failed", "pending", "success"} {
+		manager.Notify(context.Background(), &NotificationData{
+			AgentID:      "agent-001",
+			SessionTopic: "task-001",
+			FromStatus:   "running",
+			ToStatus:     to,
+			Timestamp:    now.Add(time.Duration(i) * time.Second),
+		}, server.URL)
+	}
+	manager.Notify(context.Background(), &NotificationData{
+		AgentID:      "agent-001",
+		SessionTopic: "task-002",
+		FromStatus:   "running",
+		ToStatus:     "success",
+		Timestamp:    now,
+	}, server.URL)
+
+	time.Sleep(300 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(texts) != 2 {
+		t.Fatalf("Notify() sent %d messages, want 2", len(texts))
+	}
+
+	var summary, single string
+	for _, text := range texts {
+		if strings.Contains(text, "task-001") {
+			summary = text
+		} else {
+			single = text
+		}
+	}
+	for _, want := range []string{"Transitions (3):", "running → failed", "running → pending", "Final Status: success", "Window: 2s"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary message missing %q\ngot: %s", want, summary)
+		}
+	}
+	if !strings.Contains(single, "Session Status Change\n") {
+		t.Errorf("single transition message = %s, want the regular format", single)
+	}
+}
+
+func TestNotificationManager_ShutdownFlushesCoalescedBatches(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	manager := NewNotificationManager(5 * time.Second)
+	manager.SetCoalesceWindow(time.Hour)
+
+	manager.Notify(context.Background(), &NotificationData{
+		AgentID:      "agent-001",
+		SessionTopic: "task-001",
+		FromStatus:   "running",
+		ToStatus:     "success",
+		Timestamp:    time.Now(),
+	}, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := manager.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if got := received.Load(); got != 1 {
+		t.Errorf("Shutdown() delivered %d pending messages, want 1", got)
+	}
+}
+
+func TestNotificationManager_CoalescedDestinationRendersFinalStatus(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	manager := NewNotificationManager(5 * time.Second)
+	now := time.Now()
+	fake := clock.NewFake(now)
+	manager.SetClock(fake)
+	manager.SetCoalesceWindow(time.Minute)
+
+	destinations := []models.NotificationDestination{{URL: server.URL + "/{{.AgentID}}/{{.ToStatus}}"}}
+	for i, to := range []string{"failed", "success"} {
+		if err := manager.NotifyDestinations(context.Background(), &NotificationData{
+			AgentID:      "agent-001",
+			SessionTopic: "task-001",
+			FromStatus:   "running",
+			ToStatus:     to,
+			Timestamp:    now.Add(time.Duration(i) * time.Second),
+		}, destinations); err != nil {
+			t.Fatalf("NotifyDestinations() error = %v", err)
+		}
+	}
+
+	// The batch is held until the clock passes its aggregation window
+	fake.Advance(59 * time.Second)
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	if len(paths) != 0 {
+		t.Errorf("NotifyDestinations() sent %v inside the aggregation window, want nothing", paths)
+	}
+	mu.Unlock()
+
+	fake.Advance(time.Second)
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		mu.Lock()
+		sent := len(paths)
+		mu.Unlock()
+		if sent > 0 {
+			break
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 1 || paths[0] != "/agent-001/success" {
+		t.Errorf("NotifyDestinations() sent to %v, want [/agent-001/success]", paths)
+	}
+}
+
+func TestNotificationManager_DefaultFormat(t *testing.T) {
+	manager := NewNotificationManager(5 * time.Second)
+	if err := manager.SetDefaultFormat("mattermost"); err == nil {
+		t.Fatal("SetDefaultFormat(mattermost) error = nil, want an error")
+	}
+	if err := manager.SetDefaultFormat(PlatformJSON); err != nil {
+		t.Fatalf("SetDefaultFormat() error = %v", err)
+	}
+
+	tests := []struct {
+		destination models.NotificationDestination
+		want        string
+	}{
+		{models.NotificationDestination{URL: "https://example.com/hook"}, `{"text":`},
+		{models.NotificationDestination{URL: "https://example.com/hook", Format: PlatformGeneric}, `{"msg_type":"text"`},
+		{models.NotificationDestination{URL: "https://hooks.slack.com/services/T000/B000/XXXX"}, `{"text":`},
+		{models.NotificationDestination{URL: "https://discord.com/api/webhooks/123/abc"}, `{"content":`},
+	}
+	for _, tt := range tests {
+		msg, err := manager.buildMessage(tt.destination, []*NotificationData{{AgentID: "agent-1", ToStatus: "failed"}})
+		if err != nil {
+			t.Fatalf("buildMessage() error = %v", err)
+		}
+		if !strings.HasPrefix(string(msg.payload), tt.want) {
+			t.Errorf("buildMessage(%+v) = %s, want it to start with %s", tt.destination, msg.payload, tt.want)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package notifier
 
 import (
-	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
+	"text/template"
 	"time"
+
+	"github.com/kubeagents/kubeagents/models"
 )
 
 // WebhookPayload represents the notification payload format
@@ -19,54 +23,336 @@ type WebhookContent struct {
 
 // NotificationData contains all information needed for notification
 type NotificationData struct {
-	AgentID      string
-	AgentName    string
-	SessionTopic string
-	FromStatus   string
-	ToStatus     string
-	Timestamp    time.Time
-	Message      string
-	Content      string
-	Duration     time.Duration
+	AgentID       string
+	AgentName     string
+	SessionTopic  string
+	FromStatus    string
+	ToStatus      string
+	Timestamp     time.Time
+	Message       string
+	Content       string
+	ContentFormat string // One of the models.ContentFormat constants; markdown is sanitized for each channel
+	SessionURL    string // Dashboard page of the session, linked when content is cut to fit
+	Duration      time.Duration
+	EndReason     string    // Why the session ended, empty while it is still in progress
+	Recovery      *Recovery // Set when a run succeeds after failed runs
+	Mentions      []Mention
+}
+
+// Recovery describes the failure streak a successful run of a session ended
+type Recovery struct {
+	FailedRuns int           // Consecutive failed runs before the success
+	Since      time.Time     // When the first failed run of the streak failed
+	Downtime   time.Duration // From Since to the success
+}
+
+// recoveryLines describes a recovery for the end of a message
+func recoveryLines(recovery *Recovery) string {
+	return fmt.Sprintf(
+		"\nRecovered After: %d failed run(s)\n"+
+			"Failing Since: %s\n"+
+			"Downtime: %s",
+		recovery.FailedRuns,
+		recovery.Since.Format(time.RFC3339),
+		recovery.Downtime.String(),
+	)
 }
 
+// defaultTemplate renders the messages of receivers without a template of their own
+var defaultTemplate = template.Must(models.ParseNotificationTemplate(models.DefaultNotificationTemplate))
+
 // FormatMessage creates a human-readable notification message
 func FormatMessage(data *NotificationData) string {
+	return formatMessage(PlatformGeneric, "", data)
+}
+
+// formatMessage renders the notification message of a platform with a receiver's template, embedding the content
+// as its channel allows
+// An empty template, or one failing to render, uses the default template.
+func formatMessage(platform, text string, data *NotificationData) string {
+	fields := messageFields(platform, data)
+	if text != "" {
+		msg, err := renderTemplate(text, fields)
+		if err == nil {
+			return msg
+		}
+		log.Printf("Failed to render notification template, using the default: %v", err)
+	}
+
+	var out strings.Builder
+	if err := defaultTemplate.Execute(&out, fields); err != nil {
+		log.Printf("Failed to render default notification template: %v", err)
+	}
+	return out.String()
+}
+
+// renderTemplate renders a receiver's message template
+func renderTemplate(text string, fields models.NotificationMessageFields) (string, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, fields); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// messageFields returns the values message templates use for a notification sent to platform
+func messageFields(platform string, data *NotificationData) models.NotificationMessageFields {
+	fields := models.NotificationMessageFields{
+		AgentID:      data.AgentID,
+		AgentName:    data.AgentName,
+		SessionTopic: data.SessionTopic,
+		FromStatus:   data.FromStatus,
+		ToStatus:     data.ToStatus,
+		Timestamp:    data.Timestamp,
+		Duration:     data.Duration,
+		Message:      data.Message,
+		EndReason:    data.EndReason,
+		SessionURL:   data.SessionURL,
+	}
+	if data.Content != "" {
+		fields.Content = embedContent(platform, data)
+	}
+	if data.Recovery != nil {
+		fields.Recovered = true
+		fields.FailedRuns = data.Recovery.FailedRuns
+		fields.FailingSince = data.Recovery.Since
+		fields.Downtime = data.Recovery.Downtime
+	}
+	return fields
+}
+
+// BuildPayload creates the webhook payload in JSON format
+func BuildPayload(data *NotificationData) ([]byte, error) {
+	return BuildPayloadFor(PlatformGeneric, data)
+}
+
+// BuildPayloadFor creates the webhook payload in the format of a chat platform
+func BuildPayloadFor(platform string, data *NotificationData) ([]byte, error) {
+	return BuildTemplatedPayloadFor(platform, "", data)
+}
+
+// BuildTemplatedPayloadFor creates the webhook payload in the format of a chat platform, its message rendered
+// with a receiver's template; an empty template uses the default
+func BuildTemplatedPayloadFor(platform, text string, data *NotificationData) ([]byte, error) {
+	return encodePayload(platform, formatMessage(platform, text, data), data.Mentions)
+}
+
+// FormatSummaryMessage summarizes several transitions of one session in a single message
+// Events must be in the order they happened.
+func FormatSummaryMessage(events []*NotificationData) string {
+	return formatSummaryMessage(PlatformGeneric, events)
+}
+
+// formatSummaryMessage creates the summary message of a platform, embedding the content as its channel allows
+func formatSummaryMessage(platform string, events []*NotificationData) string {
+	first, last := events[0], events[len(events)-1]
+
 	msg := fmt.Sprintf(
-		"🔔 Session Status Change\n\n"+
+		"🔔 Session Status Changes\n\n"+
 			"Agent ID: %s\n"+
 			"Agent Name: %s\n"+
 			"Session: %s\n"+
-			"Status: %s → %s\n"+
-			"Timestamp: %s\n"+
-			"Duration: %s",
+			"Transitions (%d):",
+		last.AgentID,
+		last.AgentName,
+		last.SessionTopic,
+		len(events),
+	)
+
+	for _, event := range events {
+		msg += fmt.Sprintf("\n- %s %s → %s", event.Timestamp.Format(time.RFC3339), event.FromStatus, event.ToStatus)
+		if event.Message != "" {
+			msg += ": " + event.Message
+		}
+	}
+
+	msg += fmt.Sprintf(
+		"\nFinal Status: %s\n"+
+			"Window: %s",
+		last.ToStatus,
+		last.Timestamp.Sub(first.Timestamp).String(),
+	)
+
+	if last.EndReason != "" {
+		msg += fmt.Sprintf("\nEnded: %s", last.EndReason)
+	}
+
+	if last.Recovery != nil {
+		msg += recoveryLines(last.Recovery)
+	}
+
+	if last.Content != "" {
+		msg += fmt.Sprintf("\nContent: %s", embedContent(platform, last))
+	}
+
+	return msg
+}
+
+// BuildSummaryPayloadFor creates the payload summarizing several transitions of one session
+func BuildSummaryPayloadFor(platform string, events []*NotificationData) ([]byte, error) {
+	return encodePayload(platform, formatSummaryMessage(platform, events), events[len(events)-1].Mentions)
+}
+
+// SLABreachData contains all information needed for an SLA breach notification
+type SLABreachData struct {
+	SLAName   string
+	AgentID   string
+	AgentName string
+	Kind      string // duration or failure_rate
+	Subject   string // Session topic or UTC day
+	Value     float64
+	Threshold float64
+	Timestamp time.Time
+	Mentions  []Mention
+}
+
+// FormatSLABreachMessage creates a human-readable SLA breach message
+func FormatSLABreachMessage(data *SLABreachData) string {
+	var detail string
+	switch data.Kind {
+	case "duration":
+		detail = fmt.Sprintf("Session: %s\nDuration: %.0fm (max %.0fm)", data.Subject, data.Value, data.Threshold)
+	default:
+		detail = fmt.Sprintf("Day: %s\nFailure Rate: %.0f%% (max %.0f%%)", data.Subject, data.Value*100, data.Threshold*100)
+	}
+
+	return fmt.Sprintf(
+		"⚠️ SLA Breach\n\n"+
+			"SLA: %s\n"+
+			"Agent ID: %s\n"+
+			"Agent Name: %s\n"+
+			"%s\n"+
+			"Timestamp: %s",
+		data.SLAName,
 		data.AgentID,
 		data.AgentName,
-		data.SessionTopic,
-		data.FromStatus,
-		data.ToStatus,
+		detail,
 		data.Timestamp.Format(time.RFC3339),
-		data.Duration.String(),
 	)
+}
+
+// BuildSLABreachPayload creates the webhook payload for an SLA breach
+func BuildSLABreachPayload(data *SLABreachData) ([]byte, error) {
+	return BuildSLABreachPayloadFor(PlatformGeneric, data)
+}
+
+// BuildSLABreachPayloadFor creates the SLA breach payload in the format of a chat platform
+func BuildSLABreachPayloadFor(platform string, data *SLABreachData) ([]byte, error) {
+	return encodePayload(platform, FormatSLABreachMessage(data), data.Mentions)
+}
+
+// AgentOfflineData contains all information needed for an agent offline notification
+type AgentOfflineData struct {
+	AgentID   string
+	AgentName string
+	LastSeen  time.Time
+	Timestamp time.Time // When the agent was found offline
+	Mentions  []Mention
+}
+
+// FormatAgentOfflineMessage creates a human-readable agent offline message
+func FormatAgentOfflineMessage(data *AgentOfflineData) string {
+	return fmt.Sprintf(
+		"🔌 Agent Offline\n\n"+
+			"Agent ID: %s\n"+
+			"Agent Name: %s\n"+
+			"Last Seen: %s\n"+
+			"Silent For: %s\n"+
+			"Timestamp: %s",
+		data.AgentID,
+		data.AgentName,
+		data.LastSeen.Format(time.RFC3339),
+		data.Timestamp.Sub(data.LastSeen).Round(time.Minute),
+		data.Timestamp.Format(time.RFC3339),
+	)
+}
+
+// BuildAgentOfflinePayloadFor creates the agent offline payload in the format of a chat platform
+func BuildAgentOfflinePayloadFor(platform string, data *AgentOfflineData) ([]byte, error) {
+	return encodePayload(platform, FormatAgentOfflineMessage(data), data.Mentions)
+}
+
+// AlertData contains all information needed for an alert rule notification
+type AlertData struct {
+	RuleName     string
+	Kind         string // One of the models.AlertRule kinds
+	AgentID      string
+	AgentName    string
+	SessionTopic string // Empty for agent_offline rules
+	Status       string // Status that matched a status rule
+	Message      string
+	Since        time.Time // When the run started for running_longer rules, when the agent was last seen for agent_offline rules
+	Timestamp    time.Time // When the condition was met
+	Mentions     []Mention
+}
 
-	if data.Message != "" {
-		msg += fmt.Sprintf("\nMessage: %s", data.Message)
+// FormatAlertMessage creates a human-readable alert rule message
+func FormatAlertMessage(data *AlertData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🚨 Alert: %s\n\nAgent ID: %s\nAgent Name: %s\n", data.RuleName, data.AgentID, data.AgentName)
+	if data.SessionTopic != "" {
+		fmt.Fprintf(&b, "Session: %s\n", data.SessionTopic)
 	}
 
-	if data.Content != "" {
-		msg += fmt.Sprintf("\nContent: %s", data.Content)
+	switch data.Kind {
+	case models.AlertRuleRunningLonger:
+		fmt.Fprintf(&b, "Running For: %s\n", data.Timestamp.Sub(data.Since).Round(time.Minute))
+	case models.AlertRuleAgentOffline:
+		fmt.Fprintf(&b, "Last Seen: %s\nSilent For: %s\n", data.Since.Format(time.RFC3339), data.Timestamp.Sub(data.Since).Round(time.Minute))
+	default:
+		fmt.Fprintf(&b, "Status: %s\n", data.Status)
+		if data.Message != "" {
+			fmt.Fprintf(&b, "Message: %s\n", data.Message)
+		}
 	}
 
-	return msg
+	fmt.Fprintf(&b, "Timestamp: %s", data.Timestamp.Format(time.RFC3339))
+	return b.String()
 }
 
-// BuildPayload creates the webhook payload in JSON format
-func BuildPayload(data *NotificationData) ([]byte, error) {
-	payload := WebhookPayload{
-		MsgType: "text",
-		Content: WebhookContent{
-			Text: FormatMessage(data),
-		},
-	}
-	return json.Marshal(payload)
+// BuildAlertPayloadFor creates the alert rule payload in the format of a chat platform
+func BuildAlertPayloadFor(platform string, data *AlertData) ([]byte, error) {
+	return encodePayload(platform, FormatAlertMessage(data), data.Mentions)
+}
+
+// EscalationData contains all information needed to notify a failure again because the session is still failed
+type EscalationData struct {
+	AgentID      string
+	AgentName    string
+	SessionTopic string
+	FailedAt     time.Time // When the session reported the failure
+	Escalation   int       // 1 for the first reminder
+	Timestamp    time.Time
+	Mentions     []Mention
+}
+
+// FormatEscalationMessage creates a human-readable escalation message
+func FormatEscalationMessage(data *EscalationData) string {
+	return fmt.Sprintf(
+		"⏫ Session Still Failed\n\n"+
+			"Agent ID: %s\n"+
+			"Agent Name: %s\n"+
+			"Session: %s\n"+
+			"Failed At: %s\n"+
+			"Failed For: %s\n"+
+			"Reminder: %d of %d\n"+
+			"Timestamp: %s",
+		data.AgentID,
+		data.AgentName,
+		data.SessionTopic,
+		data.FailedAt.Format(time.RFC3339),
+		data.Timestamp.Sub(data.FailedAt).Round(time.Minute),
+		data.Escalation,
+		models.MaxNotificationEscalations,
+		data.Timestamp.Format(time.RFC3339),
+	)
+}
+
+// BuildEscalationPayloadFor creates the escalation payload in the format of a chat platform
+func BuildEscalationPayloadFor(platform string, data *EscalationData) ([]byte, error) {
+	return encodePayload(platform, FormatEscalationMessage(data), data.Mentions)
 }
@@ -38,6 +38,28 @@ func TestFormatMessage(t *testing.T) {
 				"Content: Result: OK",
 			},
 		},
+		{
+			name: "recovery after failed runs",
+			data: &NotificationData{
+				AgentID:      "agent-003",
+				SessionTopic: "nightly",
+				FromStatus:   "running",
+				ToStatus:     "success",
+				Timestamp:    time.Date(2024, 1, 17, 3, 0, 0, 0, time.UTC),
+				Recovery: &Recovery{
+					FailedRuns: 2,
+					Since:      time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC),
+					Downtime:   48 * time.Hour,
+				},
+			},
+			wantContains: []string{
+				"✅ Session Recovered",
+				"Status: running → success",
+				"Recovered After: 2 failed run(s)",
+				"Failing Since: 2024-01-15T03:00:00Z",
+				"Downtime: 48h0m0s",
+			},
+		},
 		{
 			name: "running to failed transition",
 			data: &NotificationData{
@@ -219,3 +241,134 @@ func TestWebhookPayload_JSONStructure(t *testing.T) {
 		t.Errorf("WebhookPayload JSON structure mismatch\ngot:  %s\nwant: %s", string(got), want)
 	}
 }
+
+func TestFormatSLABreachMessage(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         *SLABreachData
+		wantContains []string
+	}{
+		{
+			name: "duration breach",
+			data: &SLABreachData{
+				SLAName:   "Nightly backup",
+				AgentID:   "agent-001",
+				AgentName: "Backup Agent",
+				Kind:      "duration",
+				Subject:   "backup 2024-01-15",
+				Value:     95,
+				Threshold: 60,
+				Timestamp: time.Date(2024, 1, 15, 10, 30, 45, 0, time.UTC),
+			},
+			wantContains: []string{
+				"SLA Breach",
+				"SLA: Nightly backup",
+				"Agent ID: agent-001",
+				"Session: backup 2024-01-15",
+				"Duration: 95m (max 60m)",
+				"2024-01-15T10:30:45Z",
+			},
+		},
+		{
+			name: "failure rate breach",
+			data: &SLABreachData{
+				SLAName:   "Deploys",
+				AgentID:   "agent-002",
+				Kind:      "failure_rate",
+				Subject:   "2024-01-15",
+				Value:     0.5,
+				Threshold: 0.1,
+				Timestamp: time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC),
+			},
+			wantContains: []string{
+				"SLA: Deploys",
+				"Day: 2024-01-15",
+				"Failure Rate: 50% (max 10%)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := FormatSLABreachMessage(tt.data)
+			for _, want := range tt.wantContains {
+				if !strings.Contains(msg, want) {
+					t.Errorf("FormatSLABreachMessage() missing %q in:\n%s", want, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatAlertMessage(t *testing.T) {
+	at := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		data         *AlertData
+		wantContains []string
+	}{
+		{
+			name:         "status",
+			data:         &AlertData{RuleName: "Any failure", Kind: "status", AgentID: "agent-001", SessionTopic: "deploy", Status: "failed", Message: "exit 1", Timestamp: at},
+			wantContains: []string{"🚨 Alert: Any failure", "Agent ID: agent-001", "Session: deploy", "Status: failed", "Message: exit 1", "Timestamp: 2024-01-15T10:30:00Z"},
+		},
+		{
+			name:         "running longer",
+			data:         &AlertData{RuleName: "Slow", Kind: "running_longer", AgentID: "agent-001", SessionTopic: "build", Since: at.Add(-42 * time.Minute), Timestamp: at},
+			wantContains: []string{"Session: build", "Running For: 42m0s"},
+		},
+		{
+			name:         "agent offline",
+			data:         &AlertData{RuleName: "Silent", Kind: "agent_offline", AgentID: "agent-002", Since: at.Add(-15 * time.Minute), Timestamp: at},
+			wantContains: []string{"Last Seen: 2024-01-15T10:15:00Z", "Silent For: 15m0s"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := FormatAlertMessage(tt.data)
+			for _, want := range tt.wantContains {
+				if !strings.Contains(msg, want) {
+					t.Errorf("FormatAlertMessage() missing %q in:\n%s", want, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildTemplatedPayloadFor(t *testing.T) {
+	data := &NotificationData{
+		AgentID:       "agent-1",
+		AgentName:     "Builder",
+		SessionTopic:  "deploy",
+		FromStatus:    "running",
+		ToStatus:      "failed",
+		Timestamp:     time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Duration:      90 * time.Second,
+		Content:       "see [logs](https://ci.example.com)",
+		ContentFormat: "markdown",
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"template", "{{.AgentName}} {{.ToStatus}} in {{.Duration}}: {{.Content}}", "Builder failed in 1m30s: see <https://ci.example.com|logs>"},
+		{"default", "", "🔔 Session Status Change\n\nAgent ID: agent-1"},
+		{"failing template falls back", "{{.Missing}}", "🔔 Session Status Change\n\nAgent ID: agent-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := BuildTemplatedPayloadFor(PlatformSlack, tt.template, data)
+			if err != nil {
+				t.Fatalf("BuildTemplatedPayloadFor() error = %v", err)
+			}
+			var slack slackPayload
+			if err := json.Unmarshal(payload, &slack); err != nil || !strings.HasPrefix(slack.Text, tt.want) {
+				t.Errorf("BuildTemplatedPayloadFor() text = %q, want prefix %q", slack.Text, tt.want)
+			}
+		})
+	}
+}
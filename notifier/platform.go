@@ -0,0 +1,77 @@
+package notifier
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// Payload formats of the notifier channels; all but generic and json are recognised from webhook URLs
+const (
+	PlatformGeneric = "generic"
+	PlatformJSON    = "json"
+	PlatformFeishu  = "feishu"
+	PlatformSlack   = "slack"
+	PlatformDiscord = "discord"
+	PlatformTeams   = "teams"
+)
+
+// Mention is a chat user @-mentioned in a notification
+type Mention struct {
+	UserID string // Platform user ID, e.g. a Slack member ID or Feishu open_id
+	Name   string // Shown by platforms that render a name with the mention; defaults to UserID
+}
+
+// displayName returns the name shown for the mention
+func (m Mention) displayName() string {
+	if m.Name != "" {
+		return m.Name
+	}
+	return m.UserID
+}
+
+// MentionsFromRules converts matched mention rules to notification mentions
+func MentionsFromRules(rules []models.MentionRule) []Mention {
+	mentions := make([]Mention, 0, len(rules))
+	for _, rule := range rules {
+		mentions = append(mentions, Mention{UserID: rule.ChatUserID, Name: rule.Name})
+	}
+	return mentions
+}
+
+// DetectPlatform infers the chat platform from a webhook URL's host, returning PlatformGeneric when it is not recognised
+func DetectPlatform(webhookURL string) string {
+	parsed, err := url.Parse(webhookURL)
+	if err != nil {
+		return PlatformGeneric
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	switch {
+	case host == "hooks.slack.com":
+		return PlatformSlack
+	case (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(parsed.Path, "/api/webhooks/"):
+		return PlatformDiscord
+	case host == "open.feishu.cn" || host == "open.larksuite.com":
+		return PlatformFeishu
+	case host == "outlook.office.com" || strings.HasSuffix(host, ".webhook.office.com") || strings.HasSuffix(host, ".logic.azure.com"):
+		return PlatformTeams
+	default:
+		return PlatformGeneric
+	}
+}
+
+// encodePayload wraps text in the message format the platform expects, @-mentioning each user
+// Unknown platforms get the generic payload.
+func encodePayload(platform, text string, mentions []Mention) ([]byte, error) {
+	return channelFor(platform).Payload(text, mentions)
+}
+
+// withMentions appends the mention tags on their own line
+func withMentions(text string, tags []string) string {
+	if len(tags) == 0 {
+		return text
+	}
+	return text + "\n\n" + strings.Join(tags, " ")
+}
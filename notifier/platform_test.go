@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDetectPlatform(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://hooks.slack.com/services/T000/B000/XXXX", PlatformSlack},
+		{"https://discord.com/api/webhooks/123/abc", PlatformDiscord},
+		{"https://discord.com/channels/123", PlatformGeneric},
+		{"https://open.feishu.cn/open-apis/bot/v2/hook/abc", PlatformFeishu},
+		{"https://open.larksuite.com/open-apis/bot/v2/hook/abc", PlatformFeishu},
+		{"https://contoso.webhook.office.com/webhookb2/abc", PlatformTeams},
+		{"https://prod-01.westus.logic.azure.com/workflows/abc", PlatformTeams},
+		{"https://example.com/hook", PlatformGeneric},
+		{"://not a url", PlatformGeneric},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := DetectPlatform(tt.url); got != tt.want {
+				t.Errorf("DetectPlatform(%q) = %v, want %v", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodePayload_Mentions(t *testing.T) {
+	mentions := []Mention{{UserID: "U123"}, {UserID: "ou_456", Name: "Alice"}}
+
+	tests := []struct {
+		platform     string
+		wantContains []string
+	}{
+		{PlatformSlack, []string{`"text":"Build failed\n\n<@U123> <@ou_456>"`}},
+		{PlatformFeishu, []string{`"msg_type":"text"`, `<at user_id=\"ou_456\">Alice</at>`}},
+		{PlatformDiscord, []string{`"content":"Build failed\n\n<@U123> <@ou_456>"`, `"allowed_mentions":{"parse":[],"users":["U123","ou_456"]}`}},
+		{PlatformJSON, []string{`"text":"Build failed"`, `"mentions":[{"user_id":"U123","name":"U123"},{"user_id":"ou_456","name":"Alice"}]`}},
+		{PlatformTeams, []string{`"type":"AdaptiveCard"`, `"mentioned":{"id":"ou_456","name":"Alice"}`, `<at>U123</at>`}},
+		{PlatformGeneric, []string{`"text":"Build failed\n\n@U123 @Alice"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			got, err := encodePayload(tt.platform, "Build failed", mentions)
+			if err != nil {
+				t.Fatalf("encodePayload() error = %v", err)
+			}
+			// encoding/json escapes angle brackets; platforms decode them back
+			decoded := strings.NewReplacer(`\u003c`, "<", `\u003e`, ">").Replace(string(got))
+			for _, want := range tt.wantContains {
+				if !strings.Contains(decoded, want) {
+					t.Errorf("encodePayload() = %s, want it to contain %s", decoded, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodePayload_NoMentionsKeepsText(t *testing.T) {
+	got, err := encodePayload(PlatformGeneric, "Build failed", nil)
+	if err != nil {
+		t.Fatalf("encodePayload() error = %v", err)
+	}
+
+	var payload WebhookPayload
+	json.Unmarshal(got, &payload)
+	if payload.Content.Text != "Build failed" {
+		t.Errorf("encodePayload() text = %q, want %q", payload.Content.Text, "Build failed")
+	}
+}
+
+func TestChannels(t *testing.T) {
+	for _, format := range Formats() {
+		channel, ok := Channel(format)
+		if !ok || channel.Format() != format {
+			t.Errorf("Channel(%q) = %v, %v, want the channel of that format", format, channel, ok)
+		}
+	}
+	if _, ok := Channel("mattermost"); ok {
+		t.Error("Channel(mattermost) ok = true, want false")
+	}
+}
+
+func TestDiscordChannel_TruncatesLongContent(t *testing.T) {
+	got, err := encodePayload(PlatformDiscord, strings.Repeat("x", 3000), []Mention{{UserID: "U123"}})
+	if err != nil {
+		t.Fatalf("encodePayload() error = %v", err)
+	}
+
+	var payload discordPayload
+	json.Unmarshal(got, &payload)
+	if n := len([]rune(payload.Content)); n != maxDiscordContent {
+		t.Errorf("content length = %d, want %d", n, maxDiscordContent)
+	}
+	if !strings.HasSuffix(payload.Content, "…\n\n<@U123>") {
+		t.Errorf("content ends with %q, want the cut text followed by the mention", payload.Content[len(payload.Content)-20:])
+	}
+}
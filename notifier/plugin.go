@@ -0,0 +1,138 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Kinds of external plugins configurable through ParsePlugins
+const (
+	PluginExec    = "exec"
+	PluginWebhook = "webhook"
+)
+
+// TargetHeader carries the destination URL of a message posted to a webhook plugin
+const TargetHeader = "X-KubeAgents-Target"
+
+// maxPluginStderr bounds how much of an exec plugin's error output is kept for its error
+const maxPluginStderr = 1024
+
+// NotifierPlugin is a channel delivering its messages itself instead of posting them to the destination URL,
+// so operators can add channels such as an internal paging system without changing the notifier
+// Compiled-in plugins implement it and call Register; ExecPlugin and WebhookPlugin hand messages to external programs.
+type NotifierPlugin interface {
+	NotifierChannel
+	// Deliver sends a payload built by Payload to target, the destination URL with its template filled in
+	Deliver(ctx context.Context, target string, payload []byte) error
+}
+
+// ExecPlugin delivers messages by running a command with the json format's payload on its standard input
+// and the target as its last argument; a non-zero exit status fails the delivery
+type ExecPlugin struct {
+	format  string
+	command []string
+}
+
+// NewExecPlugin creates a plugin of format running command, its program followed by any leading arguments
+func NewExecPlugin(format string, command []string) *ExecPlugin {
+	return &ExecPlugin{format: format, command: command}
+}
+
+func (p *ExecPlugin) Format() string { return p.format }
+
+func (p *ExecPlugin) Payload(text string, mentions []Mention) ([]byte, error) {
+	return jsonChannel{}.Payload(text, mentions)
+}
+
+func (p *ExecPlugin) Deliver(ctx context.Context, target string, payload []byte) error {
+	args := append(append([]string(nil), p.command[1:]...), target)
+	cmd := exec.CommandContext(ctx, p.command[0], args...)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		output := stderr.String()
+		if len(output) > maxPluginStderr {
+			output = output[:maxPluginStderr]
+		}
+		return fmt.Errorf("plugin %s failed: %w: %s", p.format, err, strings.TrimSpace(output))
+	}
+	return nil
+}
+
+// WebhookPlugin delivers messages by posting the json format's payload to a bridge service,
+// passing the target in the X-KubeAgents-Target header; failed requests are retried like webhook notifications
+type WebhookPlugin struct {
+	format   string
+	endpoint string
+	client   *HTTPClient
+}
+
+// NewWebhookPlugin creates a plugin of format posting to endpoint, each request timing out after timeout
+func NewWebhookPlugin(format, endpoint string, timeout time.Duration) *WebhookPlugin {
+	return &WebhookPlugin{format: format, endpoint: endpoint, client: NewHTTPClient(timeout)}
+}
+
+func (p *WebhookPlugin) Format() string { return p.format }
+
+func (p *WebhookPlugin) Payload(text string, mentions []Mention) ([]byte, error) {
+	return jsonChannel{}.Payload(text, mentions)
+}
+
+func (p *WebhookPlugin) Deliver(ctx context.Context, target string, payload []byte) error {
+	return p.client.send(ctx, p.endpoint, payload, http.Header{TargetHeader: {target}})
+}
+
+// ParsePlugins parses external plugins from a comma-separated list of format=kind:spec entries
+// An exec plugin's spec is its command line, split on spaces, and a webhook plugin's the http or https URL
+// of its bridge, e.g. "pager=exec:/usr/local/bin/page --team ops,oncall=webhook:http://bridge:8080/notify".
+func ParsePlugins(spec string, timeout time.Duration) ([]NotifierPlugin, error) {
+	var plugins []NotifierPlugin
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		format, definition, ok := strings.Cut(entry, "=")
+		kind, target, hasKind := strings.Cut(definition, ":")
+		format = strings.TrimSpace(format)
+		if !ok || !hasKind {
+			return nil, fmt.Errorf("invalid plugin %q: must be format=exec:command or format=webhook:url", entry)
+		}
+		if !channelFormat.MatchString(format) {
+			return nil, fmt.Errorf("invalid plugin %q: format must be 1-32 lowercase letters, digits, '-' or '_', starting with a letter", entry)
+		}
+		if seen[format] {
+			return nil, fmt.Errorf("plugin format %q is configured twice", format)
+		}
+		seen[format] = true
+
+		switch strings.TrimSpace(kind) {
+		case PluginExec:
+			command := strings.Fields(target)
+			if len(command) == 0 {
+				return nil, fmt.Errorf("invalid plugin %q: command is required", entry)
+			}
+			plugins = append(plugins, NewExecPlugin(format, command))
+		case PluginWebhook:
+			endpoint := strings.TrimSpace(target)
+			parsed, err := url.ParseRequestURI(endpoint)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return nil, fmt.Errorf("invalid plugin %q: url must be an http or https URL", entry)
+			}
+			plugins = append(plugins, NewWebhookPlugin(format, endpoint, timeout))
+		default:
+			return nil, fmt.Errorf("invalid plugin %q: kind must be %s or %s", entry, PluginExec, PluginWebhook)
+		}
+	}
+	return plugins, nil
+}
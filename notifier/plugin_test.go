@@ -0,0 +1,162 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// recordingPlugin is a compiled-in plugin recording the messages it delivers
+type recordingPlugin struct {
+	mu        sync.Mutex
+	targets   []string
+	delivered [][]byte
+}
+
+func (p *recordingPlugin) Format() string { return "test-pager" }
+
+func (p *recordingPlugin) Payload(text string, mentions []Mention) ([]byte, error) {
+	return []byte("PAGE " + text), nil
+}
+
+func (p *recordingPlugin) Deliver(ctx context.Context, target string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.targets = append(p.targets, target)
+	p.delivered = append(p.delivered, payload)
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	plugin := &recordingPlugin{}
+	if err := Register(plugin); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if channel, ok := Channel("test-pager"); !ok || channel != plugin {
+		t.Errorf("Channel(test-pager) = %v, %v, want the plugin", channel, ok)
+	}
+
+	destination := models.NotificationDestination{URL: "pager://oncall/{{.AgentID}}", Format: "test-pager"}
+	if err := destination.Validate(); err != nil {
+		t.Errorf("Validate() of a plugin destination error = %v", err)
+	}
+
+	if err := Register(NewExecPlugin(PlatformSlack, []string{"true"})); err == nil {
+		t.Error("Register(slack) error = nil, want built-in formats rejected")
+	}
+	if err := Register(NewExecPlugin("Pager!", []string{"true"})); err == nil {
+		t.Error("Register(Pager!) error = nil, want an invalid format rejected")
+	}
+}
+
+func TestNotificationManager_DeliversThroughPlugin(t *testing.T) {
+	plugin := &recordingPlugin{}
+	if err := Register(plugin); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	manager := NewNotificationManager(time.Second)
+	destination := models.NotificationDestination{URL: "pager://oncall/{{.AgentID}}", Format: "test-pager"}
+	if err := manager.Deliver(context.Background(), &NotificationData{AgentID: "agent-1", SessionTopic: "build", ToStatus: "failed"}, destination); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	if len(plugin.targets) != 1 || plugin.targets[0] != "pager://oncall/agent-1" {
+		t.Errorf("plugin targets = %v, want the rendered destination URL", plugin.targets)
+	}
+	if len(plugin.delivered) != 1 || !strings.HasPrefix(string(plugin.delivered[0]), "PAGE ") {
+		t.Errorf("plugin payloads = %q, want the plugin's own payload", plugin.delivered)
+	}
+}
+
+func TestExecPlugin_Deliver(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "page.json")
+	// sh -c passes the target, appended as the last argument, as $0
+	plugin := NewExecPlugin("pager", []string{"sh", "-c", `cat > "$0"`})
+
+	payload, err := plugin.Payload("Build failed", []Mention{{UserID: "U1", Name: "Ann"}})
+	if err != nil {
+		t.Fatalf("Payload() error = %v", err)
+	}
+	if err := plugin.Deliver(context.Background(), out, payload); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+
+	written, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	var got jsonPayload
+	if err := json.Unmarshal(written, &got); err != nil || got.Text != "Build failed" || len(got.Mentions) != 1 {
+		t.Errorf("command got %s, want the json payload", written)
+	}
+
+	failing := NewExecPlugin("pager", []string{"sh", "-c", "echo no route >&2; exit 3"})
+	if err := failing.Deliver(context.Background(), "team", payload); err == nil || !strings.Contains(err.Error(), "no route") {
+		t.Errorf("Deliver() error = %v, want the command's error output", err)
+	}
+}
+
+func TestWebhookPlugin_Deliver(t *testing.T) {
+	var target string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.Header.Get(TargetHeader)
+		var payload jsonPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		body, _ = json.Marshal(payload)
+	}))
+	defer server.Close()
+
+	plugin := NewWebhookPlugin("oncall", server.URL, time.Second)
+	payload, _ := plugin.Payload("Build failed", nil)
+	if err := plugin.Deliver(context.Background(), "oncall://platform", payload); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	if target != "oncall://platform" || !strings.Contains(string(body), "Build failed") {
+		t.Errorf("bridge got target %q and body %s", target, body)
+	}
+}
+
+func TestParsePlugins(t *testing.T) {
+	plugins, err := ParsePlugins(" pager=exec:/usr/local/bin/page --team ops , oncall=webhook:http://bridge:8080/notify,", time.Second)
+	if err != nil {
+		t.Fatalf("ParsePlugins() error = %v", err)
+	}
+	if len(plugins) != 2 {
+		t.Fatalf("ParsePlugins() = %d plugins, want 2", len(plugins))
+	}
+	if exec, ok := plugins[0].(*ExecPlugin); !ok || exec.Format() != "pager" || strings.Join(exec.command, " ") != "/usr/local/bin/page --team ops" {
+		t.Errorf("plugins[0] = %+v, want the pager command", plugins[0])
+	}
+	if hook, ok := plugins[1].(*WebhookPlugin); !ok || hook.Format() != "oncall" || hook.endpoint != "http://bridge:8080/notify" {
+		t.Errorf("plugins[1] = %+v, want the oncall bridge", plugins[1])
+	}
+
+	if plugins, err := ParsePlugins("", time.Second); err != nil || len(plugins) != 0 {
+		t.Errorf("ParsePlugins(\"\") = %v, %v, want none", plugins, err)
+	}
+
+	for _, spec := range []string{
+		"pager",
+		"pager=exec",
+		"pager=exec:",
+		"pager=smtp:ops@example.com",
+		"pager=webhook:ftp://bridge/notify",
+		"Pager=exec:/bin/page",
+		"pager=exec:/bin/page,pager=exec:/bin/other",
+	} {
+		if _, err := ParsePlugins(spec, time.Second); err == nil {
+			t.Errorf("ParsePlugins(%q) error = nil, want an error", spec)
+		}
+	}
+}
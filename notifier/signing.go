@@ -0,0 +1,38 @@
+package notifier
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers carrying the HMAC signatures of an outbound notification
+const (
+	SignatureHeader = "X-KubeAgents-Signature"
+	TimestampHeader = "X-KubeAgents-Timestamp"
+)
+
+// SigningSecrets returns the secrets notifications are currently signed with, none when signing is disabled
+type SigningSecrets func() []string
+
+// Sign returns the signature of a notification body sent at timestamp, "sha256=" and the hex HMAC of timestamp.body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// signatureHeaders returns the timestamp and signature header values for a body sent at now
+// Each secret adds a signature, so receivers holding either secret accept the notification during a rotation.
+func signatureHeaders(secrets []string, now time.Time, body []byte) (timestamp, signature string) {
+	timestamp = strconv.FormatInt(now.Unix(), 10)
+	signatures := make([]string, len(secrets))
+	for i, secret := range secrets {
+		signatures[i] = Sign(secret, timestamp, body)
+	}
+	return timestamp, strings.Join(signatures, ",")
+}
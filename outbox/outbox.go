@@ -0,0 +1,295 @@
+// Package outbox delivers side effects of status reports that were recorded in the same transaction as the status,
+// so a crash right after the commit delays them instead of losing them
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/inbox"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/sessionhook"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Delivery settings
+const (
+	batchSize   = 100
+	claimLease  = 2 * time.Minute // Longer than a notification's delivery with retries
+	maxBackoff  = time.Hour
+	sendTimeout = 30 * time.Second
+)
+
+// notificationPayload is the outbox form of a status notification to one destination
+type notificationPayload struct {
+	Data        *notifier.NotificationData     `json:"data"`
+	Destination models.NotificationDestination `json:"destination"`
+}
+
+// inboxPayload is the outbox form of an inbox item, keeping the fields its JSON form hides
+type inboxPayload struct {
+	UserID       string    `json:"user_id"`
+	Kind         string    `json:"kind"`
+	AgentID      string    `json:"agent_id"`
+	SessionTopic string    `json:"session_topic"`
+	Message      string    `json:"message"`
+	DedupeKey    string    `json:"dedupe_key"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// NotificationMessage records a status notification to one destination
+func NotificationMessage(data *notifier.NotificationData, destination models.NotificationDestination, now time.Time) (*models.OutboxMessage, error) {
+	payload, err := json.Marshal(notificationPayload{Data: data, Destination: destination})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode notification: %w", err)
+	}
+	return &models.OutboxMessage{Kind: models.OutboxKindNotification, Payload: payload, CreatedAt: now}, nil
+}
+
+// InboxMessage records an inbox item; the item is created when the message is recorded
+func InboxMessage(item *models.InboxItem, now time.Time) (*models.OutboxMessage, error) {
+	payload, err := json.Marshal(inboxPayload{
+		UserID:       item.UserID,
+		Kind:         item.Kind,
+		AgentID:      item.AgentID,
+		SessionTopic: item.SessionTopic,
+		Message:      item.Message,
+		DedupeKey:    item.DedupeKey,
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode inbox item: %w", err)
+	}
+	return &models.OutboxMessage{Kind: models.OutboxKindInbox, Payload: payload, CreatedAt: now}, nil
+}
+
+// SessionHookMessage records a session run that ended, to one session webhook
+func SessionHookMessage(delivery *sessionhook.Delivery, now time.Time) (*models.OutboxMessage, error) {
+	payload, err := json.Marshal(delivery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode session webhook delivery: %w", err)
+	}
+	return &models.OutboxMessage{Kind: models.OutboxKindSessionHook, Payload: payload, CreatedAt: now}, nil
+}
+
+// DeliveryMessage records a notification payload the notification manager built, to be sent as it is
+func DeliveryMessage(msg *notifier.QueuedMessage, now time.Time) (*models.OutboxMessage, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode queued notification: %w", err)
+	}
+	return &models.OutboxMessage{Kind: models.OutboxKindDelivery, Payload: payload, AgentID: msg.AgentID, CreatedAt: now}, nil
+}
+
+// Relay delivers recorded messages and removes them once delivered
+// Messages are claimed with a lease, so several replicas may run relays against the same store;
+// a message may be delivered twice if a relay stops between delivering and removing it.
+// Inbox items are deduplicated by the store, so only notifications and session webhook deliveries can repeat;
+// the latter carry an event ID receivers can deduplicate on.
+type Relay struct {
+	store       store.Store
+	notifier    *notifier.NotificationManager
+	inbox       *inbox.Inbox
+	hooks       *sessionhook.Dispatcher
+	maxAttempts int
+	workers     int
+	clock       clock.Clock
+	wake        chan struct{}
+}
+
+// NewRelay creates a relay; a message failing maxAttempts deliveries is dropped
+func NewRelay(st store.Store, nm *notifier.NotificationManager, ib *inbox.Inbox, maxAttempts int) *Relay {
+	return &Relay{
+		store:       st,
+		notifier:    nm,
+		inbox:       ib,
+		maxAttempts: maxAttempts,
+		workers:     1,
+		clock:       clock.Real,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// SetWorkers delivers up to n claimed messages at once, so one slow receiver does not hold up the others
+func (r *Relay) SetWorkers(n int) {
+	r.workers = max(n, 1)
+}
+
+// Enqueue records a message of the notification manager and wakes the relay to deliver it,
+// making the relay the manager's notifier.Queue
+func (r *Relay) Enqueue(msg *notifier.QueuedMessage) error {
+	message, err := DeliveryMessage(msg, r.now())
+	if err != nil {
+		return err
+	}
+	if err := r.store.AddOutboxMessage(message); err != nil {
+		return fmt.Errorf("failed to queue notification: %w", err)
+	}
+	r.Wake()
+	return nil
+}
+
+// SetClock replaces the clock that decides when messages are due and times delivery runs
+func (r *Relay) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
+// now returns the time messages are due against, in UTC
+func (r *Relay) now() time.Time {
+	return r.clock.Now().UTC()
+}
+
+// SetSessionHooks delivers recorded session webhook deliveries through d
+func (r *Relay) SetSessionHooks(d *sessionhook.Dispatcher) {
+	r.hooks = d
+}
+
+// Wake asks a running relay to deliver now rather than at its next tick
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start delivers messages interval after each delivery run, and when woken, until ctx is done
+// The interval is timed on the relay's clock.
+func (r *Relay) Start(ctx context.Context, interval time.Duration) {
+	for {
+		timer := r.clock.AfterFunc(interval, r.Wake)
+		select {
+		case <-r.wake:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+		// A full batch may have left more messages due
+		for r.Run() == batchSize {
+		}
+	}
+}
+
+// Run claims and delivers one batch of due messages with the relay's workers and returns how many were claimed
+// A failed delivery is retried with exponential backoff.
+func (r *Relay) Run() int {
+	now := r.now()
+	messages, err := r.store.ClaimOutboxMessages(now, claimLease, batchSize)
+	if err != nil {
+		log.Printf("Failed to claim outbox messages: %v", err)
+		return 0
+	}
+
+	pending := make(chan *models.OutboxMessage, len(messages))
+	for _, message := range messages {
+		pending <- message
+	}
+	close(pending)
+
+	var wg sync.WaitGroup
+	for range min(r.workers, len(messages)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for message := range pending {
+				r.settle(message, r.deliver(message))
+			}
+		}()
+	}
+	wg.Wait()
+	return len(messages)
+}
+
+// settle removes a message once delivered or out of attempts, and otherwise schedules its next attempt
+func (r *Relay) settle(message *models.OutboxMessage, err error) {
+	if err == nil || message.Attempts >= r.maxAttempts {
+		if err != nil {
+			log.Printf("Dropping outbox message %d after %d attempts: %v", message.ID, message.Attempts, err)
+		}
+		if err := r.store.DeleteOutboxMessage(message.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to remove outbox message %d: %v", message.ID, err)
+		}
+		return
+	}
+
+	backoff := claimLease << (message.Attempts - 1)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	if err := r.store.RetryOutboxMessage(message.ID, r.now().Add(backoff), err.Error()); err != nil {
+		log.Printf("Failed to reschedule outbox message %d: %v", message.ID, err)
+	}
+}
+
+// deliver performs the side effect a message records
+func (r *Relay) deliver(message *models.OutboxMessage) error {
+	switch message.Kind {
+	case models.OutboxKindNotification:
+		var payload notificationPayload
+		if err := json.Unmarshal(message.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to decode notification: %w", err)
+		}
+		if r.notifier == nil || payload.Data == nil {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		return r.notifier.Deliver(ctx, payload.Data, payload.Destination)
+
+	case models.OutboxKindInbox:
+		var payload inboxPayload
+		if err := json.Unmarshal(message.Payload, &payload); err != nil {
+			return fmt.Errorf("failed to decode inbox item: %w", err)
+		}
+		if r.inbox == nil {
+			return nil
+		}
+
+		return r.inbox.Record(&models.InboxItem{
+			UserID:       payload.UserID,
+			Kind:         payload.Kind,
+			AgentID:      payload.AgentID,
+			SessionTopic: payload.SessionTopic,
+			Message:      payload.Message,
+			DedupeKey:    payload.DedupeKey,
+			CreatedAt:    payload.CreatedAt,
+		})
+
+	case models.OutboxKindSessionHook:
+		var delivery sessionhook.Delivery
+		if err := json.Unmarshal(message.Payload, &delivery); err != nil {
+			return fmt.Errorf("failed to decode session webhook delivery: %w", err)
+		}
+		if r.hooks == nil || delivery.Event == nil {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		return r.hooks.Deliver(ctx, &delivery)
+
+	case models.OutboxKindDelivery:
+		var msg notifier.QueuedMessage
+		if err := json.Unmarshal(message.Payload, &msg); err != nil {
+			return fmt.Errorf("failed to decode queued notification: %w", err)
+		}
+		if r.notifier == nil || msg.URL == "" {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		return r.notifier.DeliverQueued(ctx, &msg)
+
+	default:
+		return fmt.Errorf("unknown outbox message kind: %s", message.Kind)
+	}
+}
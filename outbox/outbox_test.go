@@ -0,0 +1,213 @@
+package outbox
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/inbox"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// setupRelayStore creates a store holding a session the test statuses belong to
+func setupRelayStore(t *testing.T) *store.MemoryStore {
+	t.Helper()
+
+	st := store.NewMemoryStore()
+	now := time.Now()
+	if err := st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", UserID: "user-1", Name: "Builder", Registered: now, LastSeen: now}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	if err := st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "build-1", Created: now, LastUpdated: now, TTLMinutes: 30}); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v", err)
+	}
+	return st
+}
+
+// record adds a failed status together with the messages
+func record(t *testing.T, st store.Store, messages ...*models.OutboxMessage) {
+	t.Helper()
+
+	status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "build-1", Status: "failed", Timestamp: time.Now().UTC()}
+	if err := st.AddStatusWithOutbox(status, messages); err != nil {
+		t.Fatalf("AddStatusWithOutbox() error = %v", err)
+	}
+}
+
+func TestRelay_DeliversAndRemovesMessages(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := setupRelayStore(t)
+	ib := inbox.New(st, 0)
+	relay := NewRelay(st, notifier.NewNotificationManager(5*time.Second), ib, 3)
+
+	now := time.Now().UTC()
+	notification, err := NotificationMessage(&notifier.NotificationData{
+		AgentID:      "agent-1",
+		SessionTopic: "build-1",
+		FromStatus:   "running",
+		ToStatus:     "failed",
+		Timestamp:    now,
+	}, models.NotificationDestination{URL: server.URL + "/{{.AgentID}}"}, now)
+	if err != nil {
+		t.Fatalf("NotificationMessage() error = %v", err)
+	}
+	item, err := InboxMessage(&models.InboxItem{
+		UserID:    "user-1",
+		Kind:      models.InboxKindFailure,
+		AgentID:   "agent-1",
+		Message:   "Session build-1 of Builder failed",
+		DedupeKey: "failure|agent-1|build-1",
+	}, now)
+	if err != nil {
+		t.Fatalf("InboxMessage() error = %v", err)
+	}
+	record(t, st, notification, item)
+
+	if got := relay.Run(); got != 2 {
+		t.Errorf("Run() claimed %d messages, want 2", got)
+	}
+	if got := received.Load(); got != 1 {
+		t.Errorf("Run() sent %d notifications, want 1", got)
+	}
+	if count, _ := st.CountUnreadInboxItems("user-1"); count != 1 {
+		t.Errorf("Run() recorded %d inbox items, want 1", count)
+	}
+
+	if got := relay.Run(); got != 0 {
+		t.Errorf("Run() after delivery claimed %d messages, want 0", got)
+	}
+}
+
+func TestRelay_RetriesFailedDeliveries(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	st := setupRelayStore(t)
+	relay := NewRelay(st, notifier.NewNotificationManager(5*time.Second), nil, 2)
+	clk := clock.NewFake(time.Now().UTC())
+	relay.SetClock(clk)
+
+	message, err := NotificationMessage(&notifier.NotificationData{AgentID: "agent-1", SessionTopic: "build-1"},
+		models.NotificationDestination{URL: server.URL}, clk.Now())
+	if err != nil {
+		t.Fatalf("NotificationMessage() error = %v", err)
+	}
+	record(t, st, message)
+
+	if got := relay.Run(); got != 1 {
+		t.Fatalf("Run() claimed %d messages, want 1", got)
+	}
+
+	// The failed message waits for its backoff
+	if got := relay.Run(); got != 0 {
+		t.Errorf("Run() during backoff claimed %d messages, want 0", got)
+	}
+
+	clk.Advance(claimLease)
+	if got := relay.Run(); got != 1 {
+		t.Errorf("Run() after backoff claimed %d messages, want 1", got)
+	}
+
+	// The second failure reached the attempt limit, so the message was dropped
+	clk.Advance(maxBackoff)
+	if got := relay.Run(); got != 0 {
+		t.Errorf("Run() after the last attempt claimed %d messages, want 0", got)
+	}
+	if received.Load() == 0 {
+		t.Error("Run() never tried to send the notification")
+	}
+}
+
+func TestRelay_StartRunsOnItsClock(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := setupRelayStore(t)
+	relay := NewRelay(st, notifier.NewNotificationManager(5*time.Second), nil, 3)
+	clk := clock.NewFake(time.Now().UTC())
+	relay.SetClock(clk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go relay.Start(ctx, time.Minute)
+
+	message, err := NotificationMessage(&notifier.NotificationData{AgentID: "agent-1", SessionTopic: "build-1"},
+		models.NotificationDestination{URL: server.URL}, clk.Now())
+	if err != nil {
+		t.Fatalf("NotificationMessage() error = %v", err)
+	}
+	record(t, st, message)
+
+	time.Sleep(20 * time.Millisecond)
+	if got := received.Load(); got != 0 {
+		t.Fatalf("Start() sent %d notifications before its interval passed, want 0", got)
+	}
+
+	// The next run waits for the relay's clock, not for real time
+	deadline := time.Now().Add(5 * time.Second)
+	for received.Load() == 0 && time.Now().Before(deadline) {
+		clk.Advance(time.Minute)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := received.Load(); got != 1 {
+		t.Errorf("Start() sent %d notifications once the interval passed, want 1", got)
+	}
+}
+
+func TestRelay_QueuesNotificationsAcrossRestarts(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := setupRelayStore(t)
+	nm := notifier.NewNotificationManager(5 * time.Second)
+	nm.SetQueue(NewRelay(st, nm, nil, 3))
+
+	destinations := []models.NotificationDestination{{URL: server.URL + "/a"}, {URL: server.URL + "/b"}}
+	err := nm.NotifyAgentOffline(context.Background(), &notifier.AgentOfflineData{AgentID: "agent-1", AgentName: "Builder"}, destinations)
+	if err != nil {
+		t.Fatalf("NotifyAgentOffline() error = %v", err)
+	}
+	if err := nm.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if got := received.Load(); got != 0 {
+		t.Fatalf("queued notifications sent %d times before a relay ran, want 0", got)
+	}
+
+	// The process restarts with a new manager and relay on the same store
+	restarted := NewRelay(st, notifier.NewNotificationManager(5*time.Second), nil, 3)
+	restarted.SetWorkers(4)
+	if got := restarted.Run(); got != 2 {
+		t.Errorf("Run() claimed %d messages, want 2", got)
+	}
+	if got := received.Load(); got != 2 {
+		t.Errorf("Run() sent %d notifications, want 2", got)
+	}
+	if got := restarted.Run(); got != 0 {
+		t.Errorf("Run() after delivery claimed %d messages, want 0", got)
+	}
+}
@@ -0,0 +1,126 @@
+// Package presence tracks whether agents are online, stale or offline from when they last reported
+package presence
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/sessionclose"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Monitor recomputes agent states, persists the ones that changed and notifies owners of agents going offline,
+// closing their running sessions if the owners chose so
+type Monitor struct {
+	store        store.Store
+	notifier     *notifier.NotificationManager
+	closer       *sessionclose.Closer
+	staleAfter   time.Duration
+	offlineAfter time.Duration
+	clock        clock.Clock
+}
+
+// NewMonitor creates a monitor; agents silent for staleAfter become stale and for offlineAfter offline,
+// and 0 disables either state. n may be nil to track states without notifying.
+func NewMonitor(st store.Store, n *notifier.NotificationManager, staleAfter, offlineAfter time.Duration) *Monitor {
+	return &Monitor{
+		store:        st,
+		notifier:     n,
+		staleAfter:   staleAfter,
+		offlineAfter: offlineAfter,
+		clock:        clock.Real,
+	}
+}
+
+// SetClock replaces the clock that decides how long agents have been silent
+func (m *Monitor) SetClock(c clock.Clock) {
+	m.clock = c
+}
+
+// now returns the time agents are checked at, in UTC
+func (m *Monitor) now() time.Time {
+	return m.clock.Now().UTC()
+}
+
+// SetSessionCloser closes the running sessions of agents going offline as their owners chose
+func (m *Monitor) SetSessionCloser(c *sessionclose.Closer) {
+	m.closer = c
+}
+
+// Check stores the current state of every agent whose state changed
+// An agent that reports while it is checked keeps the state its report set; the next check revisits it.
+func (m *Monitor) Check() {
+	now := m.now()
+	for _, agent := range m.store.ListAgents() {
+		previous := agent.State
+		if !agent.SetState(models.AgentStateAt(agent.LastSeen, now, m.staleAfter, m.offlineAfter), now) {
+			continue
+		}
+
+		if err := m.store.CreateOrUpdateAgent(agent); err != nil {
+			if !errors.Is(err, store.ErrConflict) {
+				log.Printf("Failed to store state of agent %s: %v", agent.AgentID, err)
+			}
+			continue
+		}
+
+		// Agents tracked before the monitor existed have no previous state and are not announced or closed
+		if agent.State == models.AgentStateOffline && previous != "" {
+			m.notifyOffline(agent, now)
+			if m.closer != nil {
+				m.closer.AgentOffline(agent)
+			}
+		}
+	}
+}
+
+// notifyOffline notifies the agent's owner that it went offline, unless they muted the agent's star
+func (m *Monitor) notifyOffline(agent *models.Agent, now time.Time) {
+	if m.notifier == nil || agent.UserID == "" {
+		return
+	}
+
+	user, err := m.store.GetUserByID(agent.UserID)
+	if err != nil {
+		log.Printf("Failed to load user for offline notification: %v", err)
+		return
+	}
+
+	// A star's webhook URL wins over the notification settings', which wins over the profile's
+	destinations := []models.NotificationDestination{{URL: user.NotificationWebhookURL}}
+	settings, err := m.store.GetNotificationSettings(user.ID)
+	if err == nil {
+		if own, ok := settings.Destination(); ok {
+			destinations[0] = own
+		}
+	} else if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to load notification settings: %v", err)
+	}
+	if star, err := m.store.GetWatchItem(user.ID, agent.AgentID, ""); err == nil {
+		if star.MuteNotifications {
+			return
+		}
+		if star.NotificationWebhookURL != "" {
+			destinations[0] = models.NotificationDestination{URL: star.NotificationWebhookURL}
+		}
+	} else if !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Failed to load watch item for notification: %v", err)
+	}
+	destinations = append(destinations, user.NotificationDestinations...)
+
+	data := &notifier.AgentOfflineData{
+		AgentID:   agent.AgentID,
+		AgentName: agent.Name,
+		LastSeen:  agent.LastSeen,
+		Timestamp: now,
+		Mentions:  notifier.MentionsFromRules(user.MentionsFor(agent.AgentID, "")),
+	}
+	if err := m.notifier.NotifyAgentOffline(context.Background(), data, destinations); err != nil {
+		log.Printf("Failed to queue offline notification: %v", err)
+	}
+}
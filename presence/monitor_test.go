@@ -0,0 +1,170 @@
+package presence
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/sessionclose"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+var testNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+func agentState(t *testing.T, st store.Store, agentID string) string {
+	t.Helper()
+	agent, err := st.GetAgent(agentID)
+	if err != nil {
+		t.Fatalf("GetAgent(%s) error = %v", agentID, err)
+	}
+	return agent.State
+}
+
+func TestMonitor_Check(t *testing.T) {
+	st := store.NewMemoryStore()
+	for _, agent := range []*models.Agent{
+		{AgentID: "fresh", Registered: testNow, LastSeen: testNow.Add(-time.Minute)},
+		{AgentID: "quiet", Registered: testNow, LastSeen: testNow.Add(-10 * time.Minute)},
+		{AgentID: "gone", Registered: testNow, LastSeen: testNow.Add(-time.Hour)},
+	} {
+		if err := st.CreateOrUpdateAgent(agent); err != nil {
+			t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+		}
+	}
+
+	fake := clock.NewFake(testNow)
+	monitor := NewMonitor(st, nil, 5*time.Minute, 15*time.Minute)
+	monitor.SetClock(fake)
+	monitor.Check()
+
+	for agentID, want := range map[string]string{"fresh": models.AgentStateOnline, "quiet": models.AgentStateStale, "gone": models.AgentStateOffline} {
+		if got := agentState(t, st, agentID); got != want {
+			t.Errorf("state of %s = %q, want %q", agentID, got, want)
+		}
+	}
+
+	// An unchanged state is not written again
+	before, _ := st.GetAgent("gone")
+	fake.Advance(time.Minute)
+	monitor.Check()
+	after, _ := st.GetAgent("gone")
+	if after.Version != before.Version || !after.StateChangedAt.Equal(testNow) {
+		t.Errorf("unchanged agent version %d -> %d, state changed at %v, want no write", before.Version, after.Version, after.StateChangedAt)
+	}
+
+	fake.Advance(10 * time.Minute)
+	monitor.Check()
+	if got := agentState(t, st, "fresh"); got != models.AgentStateStale {
+		t.Errorf("state of fresh after 12 minutes = %q, want stale", got)
+	}
+	if got := agentState(t, st, "quiet"); got != models.AgentStateOffline {
+		t.Errorf("state of quiet after 21 minutes = %q, want offline", got)
+	}
+}
+
+func TestMonitor_NotifiesOffline(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string][]string) // request path -> bodies
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received[r.URL.Path] = append(received[r.URL.Path], string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	st := store.NewMemoryStore()
+	for _, user := range []*models.User{
+		{ID: "user-1", Email: "one@example.com", PasswordHash: "x", NotificationWebhookURL: server.URL + "/one", CreatedAt: testNow, UpdatedAt: testNow},
+		{ID: "user-2", Email: "two@example.com", PasswordHash: "x", NotificationWebhookURL: server.URL + "/two", CreatedAt: testNow, UpdatedAt: testNow},
+	} {
+		if err := st.CreateUser(user); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+	for _, agent := range []*models.Agent{
+		{AgentID: "build-bot", UserID: "user-1", Name: "Builder", Registered: testNow, LastSeen: testNow},
+		{AgentID: "muted-bot", UserID: "user-1", Registered: testNow, LastSeen: testNow},
+		{AgentID: "legacy-bot", UserID: "user-2", Registered: testNow, LastSeen: testNow.Add(-time.Hour)},
+	} {
+		agent.MarkSeen(agent.LastSeen)
+		if agent.AgentID == "legacy-bot" {
+			agent.State = ""
+		}
+		if err := st.CreateOrUpdateAgent(agent); err != nil {
+			t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+		}
+	}
+	if err := st.SaveWatchItem(&models.WatchItem{UserID: "user-1", AgentID: "muted-bot", MuteNotifications: true, CreatedAt: testNow}); err != nil {
+		t.Fatalf("SaveWatchItem() error = %v", err)
+	}
+
+	fake := clock.NewFake(testNow)
+	monitor := NewMonitor(st, notifier.NewNotificationManager(5*time.Second), 5*time.Minute, 15*time.Minute)
+	monitor.SetClock(fake)
+	monitor.Check()
+	fake.Advance(20 * time.Minute)
+	monitor.Check()
+	monitor.Check()
+
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received["/two"]) != 0 {
+		t.Errorf("owner of an agent without a previous state received %d notifications, want 0", len(received["/two"]))
+	}
+	bodies := received["/one"]
+	if len(bodies) != 1 {
+		t.Fatalf("owner received %d notifications, want 1 for build-bot", len(bodies))
+	}
+	if !strings.Contains(bodies[0], "Agent Offline") || !strings.Contains(bodies[0], "build-bot") {
+		t.Errorf("notification = %s, want build-bot going offline", bodies[0])
+	}
+}
+
+func TestMonitor_ClosesSessionsOfOfflineAgents(t *testing.T) {
+	st := store.NewMemoryStore()
+	user := &models.User{ID: "user-1", Email: "one@example.com", PasswordHash: "x",
+		SessionAutoClose: models.SessionAutoClose{OnOffline: models.SessionCloseExpire}, CreatedAt: testNow, UpdatedAt: testNow}
+	if err := st.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	agent := &models.Agent{AgentID: "build-bot", UserID: "user-1", Registered: testNow, LastSeen: testNow}
+	agent.MarkSeen(testNow)
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	session := &models.Session{AgentID: "build-bot", SessionTopic: "deploy", Created: testNow, LastUpdated: testNow, TTLMinutes: 60, Revision: 1}
+	if err := st.CreateOrUpdateSession(session); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v", err)
+	}
+
+	fake := clock.NewFake(testNow)
+	closer := sessionclose.NewCloser(st)
+	closer.SetClock(fake)
+	monitor := NewMonitor(st, nil, 5*time.Minute, 15*time.Minute)
+	monitor.SetClock(fake)
+	monitor.SetSessionCloser(closer)
+
+	fake.Advance(10 * time.Minute)
+	monitor.Check()
+	if got, _ := st.GetSession("build-bot", "deploy"); got.Expired {
+		t.Fatal("session of a stale agent expired, want it left open")
+	}
+
+	fake.Advance(10 * time.Minute)
+	monitor.Check()
+	got, _ := st.GetSession("build-bot", "deploy")
+	if !got.Expired || got.EndReason != models.EndReasonAgentOffline {
+		t.Errorf("session of the offline agent = expired %v, end reason %q, want expired with agent_offline", got.Expired, got.EndReason)
+	}
+}
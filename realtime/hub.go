@@ -0,0 +1,302 @@
+// Package realtime streams agent status changes to dashboard clients over WebSockets
+// Clients subscribe to whole agents or single sessions. Like the events it relays, delivery is
+// best effort: events from other replicas may be lost and slow clients may miss events, so clients
+// re-read the subscribed sessions after reconnecting.
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kubeagents/kubeagents/events"
+)
+
+const (
+	// MaxMessageSize bounds the subscription messages clients send
+	MaxMessageSize = 4096
+	// MaxSubscriptions bounds the topics one connection may subscribe to
+	MaxSubscriptions = 50
+
+	// sendBuffer is how many messages a slow client may fall behind before new ones are dropped for it
+	sendBuffer = 64
+	// pingInterval is how often the server pings an idle client
+	pingInterval = 30 * time.Second
+	// pongWait is how long a client may stay silent, pongs included, before it is disconnected
+	pongWait = 2 * pingInterval
+)
+
+// Message types sent to clients besides the status events themselves
+const (
+	TypeSubscribed   = "subscribed"
+	TypeUnsubscribed = "unsubscribed"
+	TypeError        = "error"
+)
+
+// Topic selects the events a client receives: every session of an agent, or one session when SessionTopic is set
+type Topic struct {
+	AgentID      string `json:"agent_id"`
+	SessionTopic string `json:"session_topic,omitempty"`
+}
+
+// Message is a reply to a client's subscription request
+type Message struct {
+	Type         string `json:"type"`
+	AgentID      string `json:"agent_id,omitempty"`
+	SessionTopic string `json:"session_topic,omitempty"`
+	Message      string `json:"message,omitempty"`
+}
+
+// request is a subscription change sent by a client
+type request struct {
+	Type         string `json:"type"` // "subscribe" or "unsubscribe"
+	AgentID      string `json:"agent_id"`
+	SessionTopic string `json:"session_topic"`
+}
+
+// Authorizer reports whether the connected caller may subscribe to an agent; the error is shown to the client
+type Authorizer func(agentID string) error
+
+// Hub tracks WebSocket clients and relays the events they subscribed to
+type Hub struct {
+	broker *events.Broker
+
+	mu      sync.Mutex
+	clients map[*client]struct{}
+	closed  bool
+}
+
+// NewHub creates a hub relaying events published to b
+func NewHub(b *events.Broker) *Hub {
+	return &Hub{
+		broker:  b,
+		clients: make(map[*client]struct{}),
+	}
+}
+
+// Clients returns the number of connected clients
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Close disconnects every client; connections served afterwards are closed right away
+// Hijacked connections are not closed by http.Server.Shutdown, so call it during shutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	clients := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.conn.Close(CloseGoingAway, "server shutting down")
+	}
+}
+
+// Serve relays events to a connection until it closes, subscribing it to the initial topics first
+// authorize is consulted for every topic the client subscribes to.
+func (h *Hub) Serve(conn *Conn, authorize Authorizer, initial []Topic) {
+	c := &client{
+		hub:       h,
+		conn:      conn,
+		authorize: authorize,
+		send:      make(chan []byte, sendBuffer),
+		done:      make(chan struct{}),
+		agents:    make(map[string]*agentSubscription),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		conn.Close(CloseGoingAway, "server shutting down")
+		return
+	}
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		close(c.done)
+		c.unsubscribeAll()
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+	}()
+
+	go c.writeLoop()
+	for _, topic := range initial {
+		c.subscribe(topic)
+	}
+
+	conn.SetReadTimeout(pongWait)
+	for {
+		data, err := conn.ReadMessage()
+		if err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				conn.Close(closeErr.Code, closeErr.Reason)
+			} else {
+				conn.Close(CloseNormal, "")
+			}
+			return
+		}
+		c.handle(data)
+	}
+}
+
+// agentSubscription is a client's broker subscription to one agent and the topics it covers
+type agentSubscription struct {
+	sessions map[string]bool // Session topics, "" for every session
+	stop     chan struct{}
+}
+
+// client is one connected WebSocket client
+type client struct {
+	hub       *Hub
+	conn      *Conn
+	authorize Authorizer
+	send      chan []byte
+	done      chan struct{}
+
+	mu     sync.Mutex
+	agents map[string]*agentSubscription
+	topics int
+}
+
+// handle applies a subscription request from the client
+func (c *client) handle(data []byte) {
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		c.queue(Message{Type: TypeError, Message: "invalid JSON message"})
+		return
+	}
+	topic := Topic{AgentID: req.AgentID, SessionTopic: req.SessionTopic}
+	switch req.Type {
+	case "subscribe":
+		c.subscribe(topic)
+	case "unsubscribe":
+		c.unsubscribe(topic)
+	default:
+		c.queue(Message{Type: TypeError, Message: `type must be "subscribe" or "unsubscribe"`})
+	}
+}
+
+// subscribe starts relaying a topic's events to the client
+func (c *client) subscribe(topic Topic) {
+	if topic.AgentID == "" {
+		c.queue(Message{Type: TypeError, Message: "agent_id is required"})
+		return
+	}
+	if err := c.authorize(topic.AgentID); err != nil {
+		c.queue(Message{Type: TypeError, AgentID: topic.AgentID, SessionTopic: topic.SessionTopic, Message: err.Error()})
+		return
+	}
+
+	c.mu.Lock()
+	sub := c.agents[topic.AgentID]
+	switch {
+	case sub != nil && sub.sessions[topic.SessionTopic]:
+		// Already subscribed; confirm again so clients can treat subscribe as idempotent
+	case c.topics >= MaxSubscriptions:
+		c.mu.Unlock()
+		c.queue(Message{Type: TypeError, AgentID: topic.AgentID, SessionTopic: topic.SessionTopic, Message: "too many subscriptions"})
+		return
+	case sub != nil:
+		sub.sessions[topic.SessionTopic] = true
+		c.topics++
+	default:
+		sub = &agentSubscription{sessions: map[string]bool{topic.SessionTopic: true}, stop: make(chan struct{})}
+		c.agents[topic.AgentID] = sub
+		c.topics++
+		changes, unsubscribe := c.hub.broker.Subscribe(topic.AgentID)
+		go c.relay(sub, changes, unsubscribe)
+	}
+	c.mu.Unlock()
+
+	c.queue(Message{Type: TypeSubscribed, AgentID: topic.AgentID, SessionTopic: topic.SessionTopic})
+}
+
+// unsubscribe stops relaying a topic's events to the client
+func (c *client) unsubscribe(topic Topic) {
+	c.mu.Lock()
+	if sub := c.agents[topic.AgentID]; sub != nil && sub.sessions[topic.SessionTopic] {
+		delete(sub.sessions, topic.SessionTopic)
+		c.topics--
+		if len(sub.sessions) == 0 {
+			delete(c.agents, topic.AgentID)
+			close(sub.stop)
+		}
+	}
+	c.mu.Unlock()
+
+	c.queue(Message{Type: TypeUnsubscribed, AgentID: topic.AgentID, SessionTopic: topic.SessionTopic})
+}
+
+// unsubscribeAll ends every broker subscription of a disconnected client
+func (c *client) unsubscribeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for agentID, sub := range c.agents {
+		close(sub.stop)
+		delete(c.agents, agentID)
+	}
+	c.topics = 0
+}
+
+// relay forwards an agent's events matching the client's topics until the subscription stops
+func (c *client) relay(sub *agentSubscription, changes <-chan *events.Event, unsubscribe func()) {
+	defer unsubscribe()
+	for {
+		select {
+		case <-sub.stop:
+			return
+		case event := <-changes:
+			c.mu.Lock()
+			wanted := sub.sessions[""] || sub.sessions[event.SessionTopic]
+			c.mu.Unlock()
+			if wanted {
+				c.queue(event)
+			}
+		}
+	}
+}
+
+// queue encodes a message for the writer without blocking on a slow client
+func (c *client) queue(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		// The client re-reads its sessions after reconnecting
+	}
+}
+
+// writeLoop sends queued messages and pings until the client disconnects
+func (c *client) writeLoop() {
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			if err := c.conn.WriteText(data); err != nil {
+				c.conn.Close(CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.Ping(); err != nil {
+				c.conn.Close(CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package realtime
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/events"
+)
+
+// hubServer serves the hub, letting clients subscribe to agents other than "agent-foreign"
+func hubServer(t *testing.T, hub *Hub, initial []Topic) *httptest.Server {
+	authorize := func(agentID string) error {
+		if agentID == "agent-foreign" {
+			return errors.New("agent not found")
+		}
+		return nil
+	}
+	upgrader := NewUpgrader(nil)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, MaxMessageSize)
+		if err != nil {
+			return
+		}
+		hub.Serve(conn, authorize, initial)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// waitFor polls until cond holds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHub_FansOutSubscribedTopics(t *testing.T) {
+	broker := events.NewBroker()
+	hub := NewHub(broker)
+	server := hubServer(t, hub, []Topic{{AgentID: "agent-1"}})
+
+	whole := dial(t, server, "/")
+	var msg Message
+	whole.readMessage(&msg)
+	if msg.Type != TypeSubscribed || msg.AgentID != "agent-1" {
+		t.Fatalf("first message = %+v, want the initial subscription", msg)
+	}
+
+	single := dial(t, server, "/")
+	single.readMessage(&msg)
+	single.send(request{Type: "unsubscribe", AgentID: "agent-1"})
+	single.readMessage(&msg)
+	single.send(request{Type: "subscribe", AgentID: "agent-1", SessionTopic: "task-2"})
+	single.readMessage(&msg)
+	if msg.Type != TypeSubscribed || msg.SessionTopic != "task-2" {
+		t.Fatalf("subscribe reply = %+v, want task-2 subscribed", msg)
+	}
+	single.send(request{Type: "subscribe", AgentID: "agent-foreign"})
+	single.readMessage(&msg)
+	if msg.Type != TypeError || msg.Message != "agent not found" {
+		t.Errorf("foreign subscribe reply = %+v, want an error", msg)
+	}
+	waitFor(t, "clients to connect", func() bool { return hub.Clients() == 2 })
+
+	broker.Publish(&events.Event{Type: events.TypeStatus, AgentID: "agent-1", SessionTopic: "task-1", Status: "running"})
+	broker.Publish(&events.Event{Type: events.TypeStatus, AgentID: "agent-1", SessionTopic: "task-2", Status: "success"})
+
+	var event events.Event
+	whole.readMessage(&event)
+	if event.SessionTopic != "task-1" {
+		t.Errorf("agent subscriber got %+v first, want task-1", event)
+	}
+	whole.readMessage(&event)
+	if event.SessionTopic != "task-2" {
+		t.Errorf("agent subscriber got %+v second, want task-2", event)
+	}
+	single.readMessage(&event)
+	if event.SessionTopic != "task-2" || event.Status != "success" {
+		t.Errorf("session subscriber got %+v, want only task-2", event)
+	}
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub(events.NewBroker())
+	server := hubServer(t, hub, nil)
+	client := dial(t, server, "/")
+	waitFor(t, "client to connect", func() bool { return hub.Clients() == 1 })
+
+	hub.Close()
+	if code := client.readClose(); code != CloseGoingAway {
+		t.Errorf("close code = %d, want %d", code, CloseGoingAway)
+	}
+	waitFor(t, "client to disconnect", func() bool { return hub.Clients() == 0 })
+}
@@ -0,0 +1,178 @@
+package realtime
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Subprotocol is the WebSocket subprotocol of the realtime API
+// Browsers cannot set headers on WebSockets, so they list it next to the subprotocol carrying their credential
+// (see middleware.WebSocketCredential); the server selects it, never echoing the credential.
+const Subprotocol = "kubeagents.v1"
+
+// Close codes sent to clients
+const (
+	CloseNormal          = websocket.CloseNormalClosure
+	CloseGoingAway       = websocket.CloseGoingAway
+	CloseProtocolError   = websocket.CloseProtocolError
+	CloseUnsupportedData = websocket.CloseUnsupportedData
+	ClosePolicyViolation = websocket.ClosePolicyViolation
+	CloseMessageTooBig   = websocket.CloseMessageTooBig
+)
+
+// writeTimeout bounds how long a single frame may take to reach a slow client
+const writeTimeout = 10 * time.Second
+
+// ErrClosed is returned by reads and writes after either side closed the connection
+var ErrClosed = errors.New("websocket closed")
+
+// CloseError is returned by ReadMessage when the connection must be closed with a protocol close code
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket close %d: %s", e.Code, e.Reason)
+}
+
+// Upgrader completes WebSocket handshakes of browsers on the allowed origins
+type Upgrader struct {
+	upgrader websocket.Upgrader
+	origins  []string
+}
+
+// NewUpgrader returns an upgrader accepting the allowed origins, patterns such as https://*.example.com and "*"
+// included, as the CORS allow-list does
+// The server's own origin is always accepted, as are requests without an Origin header, which do not come from
+// browsers and so cannot be cross-site.
+func NewUpgrader(allowedOrigins []string) *Upgrader {
+	u := &Upgrader{origins: allowedOrigins}
+	u.upgrader = websocket.Upgrader{
+		HandshakeTimeout: writeTimeout,
+		Subprotocols:     []string{Subprotocol},
+		CheckOrigin:      u.checkOrigin,
+		Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+			http.Error(w, http.StatusText(status), status)
+		},
+	}
+	return u
+}
+
+// checkOrigin reports whether a handshake comes from the server's own origin or an allowed one
+func (u *Upgrader) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if parsed, err := url.Parse(origin); err == nil && strings.EqualFold(parsed.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range u.origins {
+		if originMatches(strings.ToLower(allowed), strings.ToLower(origin)) {
+			return true
+		}
+	}
+	return false
+}
+
+// originMatches reports whether an origin matches an allowed origin, which may hold one "*" wildcard
+func originMatches(allowed, origin string) bool {
+	prefix, suffix, wildcard := strings.Cut(allowed, "*")
+	if !wildcard {
+		return allowed == origin
+	}
+	return len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+// Upgrade completes the WebSocket handshake of a request, writing an HTTP error when it is not a valid upgrade
+// or comes from an origin that is not allowed; maxMessage bounds the size of messages the client may send.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, maxMessage int) (*Conn, error) {
+	ws, err := u.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+	ws.SetReadLimit(int64(maxMessage))
+	return &Conn{ws: ws}, nil
+}
+
+// Conn is a server-side WebSocket connection
+// One goroutine may read while others write; writes are serialized.
+type Conn struct {
+	ws          *websocket.Conn
+	readTimeout time.Duration
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+// SetReadTimeout makes reads fail when the client sends no frame, pongs included, for d; 0 waits forever
+func (c *Conn) SetReadTimeout(d time.Duration) {
+	c.readTimeout = d
+	c.ws.SetPongHandler(func(string) error {
+		c.extendReadDeadline()
+		return nil
+	})
+}
+
+// extendReadDeadline gives the client another read timeout to send its next frame
+func (c *Conn) extendReadDeadline() {
+	if c.readTimeout > 0 {
+		c.ws.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+}
+
+// ReadMessage returns the next text message; pings are answered and fragments reassembled along the way
+// It returns ErrClosed when the client closed the connection and *CloseError when the client broke the protocol.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	c.extendReadDeadline()
+	messageType, data, err := c.ws.ReadMessage()
+	switch {
+	case errors.Is(err, websocket.ErrReadLimit):
+		return nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	case err != nil:
+		return nil, ErrClosed
+	case messageType != websocket.TextMessage:
+		return nil, &CloseError{Code: CloseUnsupportedData, Reason: "binary messages are not supported"}
+	}
+	return data, nil
+}
+
+// WriteText sends a text message
+func (c *Conn) WriteText(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.ws.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Ping sends a ping; the client's pong extends the read timeout
+func (c *Conn) Ping() error {
+	if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close sends a close frame, best effort, and closes the connection
+func (c *Conn) Close(code int, reason string) error {
+	var err error
+	c.closeOnce.Do(func() {
+		if len(reason) > 123 {
+			reason = reason[:123]
+		}
+		c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeTimeout))
+		err = c.ws.Close()
+	})
+	return err
+}
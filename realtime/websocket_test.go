@@ -0,0 +1,188 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClient is a WebSocket client of a test server
+type testClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// dialWith opens a WebSocket connection to an httptest server with the given handshake headers
+func dialWith(t *testing.T, server *httptest.Server, path string, header http.Header) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+path, header)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// dial opens a WebSocket connection to an httptest server
+func dial(t *testing.T, server *httptest.Server, path string) *testClient {
+	t.Helper()
+	conn, _, err := dialWith(t, server, path, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	return &testClient{t: t, conn: conn}
+}
+
+// send sends a JSON text message
+func (c *testClient) send(v interface{}) {
+	c.t.Helper()
+	if err := c.conn.WriteJSON(v); err != nil {
+		c.t.Fatalf("write message: %v", err)
+	}
+}
+
+// readMessage reads the next text message, decoding it into v
+func (c *testClient) readMessage(v interface{}) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	messageType, data, err := c.conn.ReadMessage()
+	if err != nil {
+		c.t.Fatalf("read message: %v", err)
+	}
+	if messageType != websocket.TextMessage {
+		c.t.Fatalf("message type = %d, want a text message", messageType)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		c.t.Fatalf("decode message %s: %v", data, err)
+	}
+}
+
+// readClose reads until the server closes the connection, returning its close code
+func (c *testClient) readClose() int {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				c.t.Fatalf("read error = %v, want a close frame", err)
+			}
+			return closeErr.Code
+		}
+	}
+}
+
+// echoServer upgrades requests from its own and the allowed origins and echoes text messages back
+func echoServer(t *testing.T, allowedOrigins ...string) *httptest.Server {
+	upgrader := NewUpgrader(allowedOrigins)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, 64)
+		if err != nil {
+			return
+		}
+		for {
+			data, err := conn.ReadMessage()
+			if err != nil {
+				if closeErr, ok := err.(*CloseError); ok {
+					conn.Close(closeErr.Code, closeErr.Reason)
+				} else {
+					conn.Close(CloseNormal, "")
+				}
+				return
+			}
+			conn.WriteText(data)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUpgrade_RejectsPlainRequests(t *testing.T) {
+	server := echoServer(t)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestUpgrade_ChecksOrigin(t *testing.T) {
+	server := echoServer(t, "https://app.example.com", "https://*.preview.example.com")
+
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"no origin", "", http.StatusSwitchingProtocols},
+		{"own origin", server.URL, http.StatusSwitchingProtocols},
+		{"allowed origin", "https://APP.example.com", http.StatusSwitchingProtocols},
+		{"allowed pattern", "https://pr-12.preview.example.com", http.StatusSwitchingProtocols},
+		{"cross origin", "https://evil.example.net", http.StatusForbidden},
+		{"lookalike origin", "https://app.example.com.evil.net", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			_, resp, err := dialWith(t, server, "/", header)
+			if resp == nil {
+				t.Fatalf("Dial() error = %v, want a response", err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %v, want %v", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpgrade_SelectsSubprotocol(t *testing.T) {
+	server := echoServer(t)
+
+	header := http.Header{"Sec-WebSocket-Protocol": {Subprotocol + ", base64url.bearer.kubeagents.dG9rZW4"}}
+	conn, _, err := dialWith(t, server, "/", header)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	if got := conn.Subprotocol(); got != Subprotocol {
+		t.Errorf("Subprotocol() = %q, want %q without the credential", got, Subprotocol)
+	}
+}
+
+func TestConn_Messages(t *testing.T) {
+	server := echoServer(t)
+	client := dial(t, server, "/")
+
+	client.send("hello")
+	var echoed string
+	client.readMessage(&echoed)
+	if echoed != "hello" {
+		t.Errorf("echo = %q, want %q", echoed, "hello")
+	}
+
+	client.send(strings.Repeat("x", 100))
+	if code := client.readClose(); code != CloseMessageTooBig {
+		t.Errorf("close code = %d, want %d", code, CloseMessageTooBig)
+	}
+}
+
+func TestConn_RejectsBinaryMessages(t *testing.T) {
+	server := echoServer(t)
+	client := dial(t, server, "/")
+
+	client.conn.WriteMessage(websocket.BinaryMessage, []byte{1, 2, 3})
+	if code := client.readClose(); code != CloseUnsupportedData {
+		t.Errorf("close code = %d, want %d", code, CloseUnsupportedData)
+	}
+}
@@ -0,0 +1,680 @@
+// Package replication mirrors the writes made to a primary store onto a secondary store in the background,
+// for audit archives or for moving to a new store without downtime
+package replication
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// maxStatusIDs bounds the primary-to-secondary status ID translations kept for mirroring annotations
+const maxStatusIDs = 100000
+
+// mirrorOp applies one write that succeeded on the primary to the secondary
+type mirrorOp struct {
+	name  string
+	apply func(r *Store) error
+}
+
+// Store serves reads and writes from the primary and queues each successful write for the secondary
+// Writes are mirrored in order by Run; when the queue is full a write is dropped and logged rather than
+// slowing the primary, so the secondary is a best-effort copy. Only writes made after the store is wrapped
+// are mirrored: backfill the secondary before enabling replication. Outbox bookkeeping, webhook nonces,
+// idempotency keys, tenant counts and maintenance purges stay on the primary, so an archive keeps every
+// record it received.
+type Store struct {
+	store.Store
+	secondary store.Store
+	queue     chan mirrorOp
+	dropped   atomic.Int64
+
+	// Used by Run only
+	statusIDs     map[int64]int64 // primary status ID -> secondary status ID
+	statusIDOrder []int64
+}
+
+// NewStore wraps primary so its writes are mirrored to secondary, queueing up to queueSize writes
+func NewStore(primary, secondary store.Store, queueSize int) *Store {
+	return &Store{
+		Store:     primary,
+		secondary: secondary,
+		queue:     make(chan mirrorOp, queueSize),
+		statusIDs: make(map[int64]int64),
+	}
+}
+
+// Dropped returns the number of writes not mirrored because the queue was full
+func (r *Store) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Run applies queued writes to the secondary until ctx is done, then applies the writes already queued
+func (r *Store) Run(ctx context.Context) {
+	for {
+		select {
+		case op := <-r.queue:
+			r.apply(op)
+		case <-ctx.Done():
+			for {
+				select {
+				case op := <-r.queue:
+					r.apply(op)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Store) apply(op mirrorOp) {
+	// The secondary may already hold records written before replication was enabled
+	if err := op.apply(r); err != nil && !errors.Is(err, store.ErrAlreadyExists) {
+		log.Printf("Failed to mirror %s to secondary store: %v", op.name, err)
+	}
+}
+
+// enqueue queues a write for the secondary without blocking the primary
+func (r *Store) enqueue(name string, apply func(r *Store) error) {
+	select {
+	case r.queue <- mirrorOp{name: name, apply: apply}:
+	default:
+		r.dropped.Add(1)
+		log.Printf("Replication queue full; not mirroring %s to secondary store", name)
+	}
+}
+
+// rememberStatusID records the secondary's ID of a mirrored status, forgetting the oldest beyond maxStatusIDs
+func (r *Store) rememberStatusID(primaryID, secondaryID int64) {
+	if len(r.statusIDOrder) >= maxStatusIDs {
+		delete(r.statusIDs, r.statusIDOrder[0])
+		r.statusIDOrder = r.statusIDOrder[1:]
+	}
+	r.statusIDs[primaryID] = secondaryID
+	r.statusIDOrder = append(r.statusIDOrder, primaryID)
+}
+
+// mirrorAgent upserts the agent on the secondary; the primary wins if the secondary's version differs
+func mirrorAgent(st store.Store, agent models.Agent) error {
+	err := st.CreateOrUpdateAgent(&agent)
+	if !errors.Is(err, store.ErrConflict) {
+		return err
+	}
+	agent.Version = 0
+	if current, err := st.GetAgent(agent.AgentID); err == nil {
+		agent.Version = current.Version
+	}
+	return st.CreateOrUpdateAgent(&agent)
+}
+
+// mirrorSession upserts the session on the secondary like mirrorAgent
+func mirrorSession(st store.Store, session models.Session) error {
+	err := st.CreateOrUpdateSession(&session)
+	if !errors.Is(err, store.ErrConflict) {
+		return err
+	}
+	session.Version = 0
+	if current, err := st.GetSession(session.AgentID, session.SessionTopic); err == nil {
+		session.Version = current.Version
+	}
+	return st.CreateOrUpdateSession(&session)
+}
+
+// mirrorStatus adds the status on the secondary and remembers the ID it was given there
+func (r *Store) mirrorStatus(status models.AgentStatus) {
+	primaryID := status.ID
+	r.enqueue("status", func(r *Store) error {
+		status.ID = 0
+		if err := r.secondary.AddStatus(&status); err != nil {
+			return err
+		}
+		r.rememberStatusID(primaryID, status.ID)
+		return nil
+	})
+}
+
+// CreateUser creates a user and mirrors it
+func (r *Store) CreateUser(user *models.User) error {
+	if err := r.Store.CreateUser(user); err != nil {
+		return err
+	}
+	copied := *user
+	r.enqueue("user", func(r *Store) error { return r.secondary.CreateUser(&copied) })
+	return nil
+}
+
+// UpdateUser updates a user and mirrors it
+func (r *Store) UpdateUser(user *models.User) error {
+	if err := r.Store.UpdateUser(user); err != nil {
+		return err
+	}
+	copied := *user
+	r.enqueue("user", func(r *Store) error { return r.secondary.UpdateUser(&copied) })
+	return nil
+}
+
+// DeleteUser deletes a user with their records and mirrors the deletion
+func (r *Store) DeleteUser(userID string) error {
+	if err := r.Store.DeleteUser(userID); err != nil {
+		return err
+	}
+	r.enqueue("user deletion", func(r *Store) error { return r.secondary.DeleteUser(userID) })
+	return nil
+}
+
+// SetUserDataKey stores a user's data key and mirrors the key the primary kept
+func (r *Store) SetUserDataKey(userID string, wrappedKey []byte) ([]byte, error) {
+	stored, err := r.Store.SetUserDataKey(userID, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	copied := append([]byte(nil), stored...)
+	r.enqueue("data key", func(r *Store) error {
+		_, err := r.secondary.SetUserDataKey(userID, copied)
+		return err
+	})
+	return stored, nil
+}
+
+// SaveRefreshToken saves a refresh token and mirrors it
+func (r *Store) SaveRefreshToken(token *models.RefreshToken) error {
+	if err := r.Store.SaveRefreshToken(token); err != nil {
+		return err
+	}
+	copied := *token
+	r.enqueue("refresh token", func(r *Store) error { return r.secondary.SaveRefreshToken(&copied) })
+	return nil
+}
+
+// RevokeRefreshToken revokes a refresh token and mirrors the revocation
+func (r *Store) RevokeRefreshToken(tokenID string) error {
+	if err := r.Store.RevokeRefreshToken(tokenID); err != nil {
+		return err
+	}
+	r.enqueue("refresh token revocation", func(r *Store) error { return r.secondary.RevokeRefreshToken(tokenID) })
+	return nil
+}
+
+// RevokeAllUserTokens revokes a user's refresh tokens and mirrors the revocation
+func (r *Store) RevokeAllUserTokens(userID string) error {
+	if err := r.Store.RevokeAllUserTokens(userID); err != nil {
+		return err
+	}
+	r.enqueue("refresh token revocation", func(r *Store) error { return r.secondary.RevokeAllUserTokens(userID) })
+	return nil
+}
+
+// CreateAPIKey creates an API key and mirrors it
+func (r *Store) CreateAPIKey(apiKey *models.APIKey) error {
+	if err := r.Store.CreateAPIKey(apiKey); err != nil {
+		return err
+	}
+	copied := *apiKey
+	r.enqueue("API key", func(r *Store) error { return r.secondary.CreateAPIKey(&copied) })
+	return nil
+}
+
+// CreateEnrollmentToken creates an enrollment token and mirrors it
+func (r *Store) CreateEnrollmentToken(token *models.EnrollmentToken) error {
+	if err := r.Store.CreateEnrollmentToken(token); err != nil {
+		return err
+	}
+	copied := *token
+	r.enqueue("enrollment token", func(r *Store) error { return r.secondary.CreateEnrollmentToken(&copied) })
+	return nil
+}
+
+// RedeemEnrollmentToken redeems an enrollment token and mirrors the redemption and its agent token
+func (r *Store) RedeemEnrollmentToken(tokenID string, agentToken *models.APIKey) error {
+	if err := r.Store.RedeemEnrollmentToken(tokenID, agentToken); err != nil {
+		return err
+	}
+	copied := *agentToken
+	r.enqueue("enrollment", func(r *Store) error { return r.secondary.RedeemEnrollmentToken(tokenID, &copied) })
+	return nil
+}
+
+// DeleteEnrollmentToken deletes an enrollment token and mirrors the deletion
+func (r *Store) DeleteEnrollmentToken(userID, tokenID string) error {
+	if err := r.Store.DeleteEnrollmentToken(userID, tokenID); err != nil {
+		return err
+	}
+	r.enqueue("enrollment token deletion", func(r *Store) error { return r.secondary.DeleteEnrollmentToken(userID, tokenID) })
+	return nil
+}
+
+// CreateOrganization creates an organization with its owner's membership and mirrors them
+func (r *Store) CreateOrganization(org *models.Organization, owner *models.Membership) error {
+	if err := r.Store.CreateOrganization(org, owner); err != nil {
+		return err
+	}
+	copiedOrg := *org
+	var copiedOwner *models.Membership
+	if owner != nil {
+		membership := *owner
+		copiedOwner = &membership
+	}
+	r.enqueue("organization", func(r *Store) error { return r.secondary.CreateOrganization(&copiedOrg, copiedOwner) })
+	return nil
+}
+
+// DeleteOrganization deletes an organization and mirrors the deletion
+func (r *Store) DeleteOrganization(orgID string) error {
+	if err := r.Store.DeleteOrganization(orgID); err != nil {
+		return err
+	}
+	r.enqueue("organization deletion", func(r *Store) error { return r.secondary.DeleteOrganization(orgID) })
+	return nil
+}
+
+// SaveMembership saves a membership and mirrors it
+func (r *Store) SaveMembership(membership *models.Membership) error {
+	if err := r.Store.SaveMembership(membership); err != nil {
+		return err
+	}
+	copied := *membership
+	r.enqueue("membership", func(r *Store) error { return r.secondary.SaveMembership(&copied) })
+	return nil
+}
+
+// DeleteMembership deletes a membership and mirrors the deletion
+func (r *Store) DeleteMembership(orgID, userID string) error {
+	if err := r.Store.DeleteMembership(orgID, userID); err != nil {
+		return err
+	}
+	r.enqueue("membership deletion", func(r *Store) error { return r.secondary.DeleteMembership(orgID, userID) })
+	return nil
+}
+
+// CreateInvitation creates an invitation and mirrors it
+func (r *Store) CreateInvitation(invitation *models.Invitation) error {
+	if err := r.Store.CreateInvitation(invitation); err != nil {
+		return err
+	}
+	copied := *invitation
+	r.enqueue("invitation", func(r *Store) error { return r.secondary.CreateInvitation(&copied) })
+	return nil
+}
+
+// AcceptInvitation accepts an invitation and mirrors the acceptance and its membership
+func (r *Store) AcceptInvitation(invitationID string, membership *models.Membership) error {
+	if err := r.Store.AcceptInvitation(invitationID, membership); err != nil {
+		return err
+	}
+	copied := *membership
+	r.enqueue("invitation acceptance", func(r *Store) error { return r.secondary.AcceptInvitation(invitationID, &copied) })
+	return nil
+}
+
+// DeleteInvitation deletes an invitation and mirrors the deletion
+func (r *Store) DeleteInvitation(orgID, invitationID string) error {
+	if err := r.Store.DeleteInvitation(orgID, invitationID); err != nil {
+		return err
+	}
+	r.enqueue("invitation deletion", func(r *Store) error { return r.secondary.DeleteInvitation(orgID, invitationID) })
+	return nil
+}
+
+// RevokeAPIKey revokes an API key and mirrors the revocation
+func (r *Store) RevokeAPIKey(keyID string) error {
+	if err := r.Store.RevokeAPIKey(keyID); err != nil {
+		return err
+	}
+	r.enqueue("API key revocation", func(r *Store) error { return r.secondary.RevokeAPIKey(keyID) })
+	return nil
+}
+
+// UpdateAPIKeyLastUsed records an API key's use and mirrors it
+func (r *Store) UpdateAPIKeyLastUsed(keyID string) error {
+	if err := r.Store.UpdateAPIKeyLastUsed(keyID); err != nil {
+		return err
+	}
+	r.enqueue("API key use", func(r *Store) error { return r.secondary.UpdateAPIKeyLastUsed(keyID) })
+	return nil
+}
+
+// CreateClientCertificate registers a client certificate and mirrors it
+func (r *Store) CreateClientCertificate(cert *models.ClientCertificate) error {
+	if err := r.Store.CreateClientCertificate(cert); err != nil {
+		return err
+	}
+	copied := *cert
+	r.enqueue("client certificate", func(r *Store) error { return r.secondary.CreateClientCertificate(&copied) })
+	return nil
+}
+
+// DeleteClientCertificate deletes a client certificate and mirrors the deletion
+func (r *Store) DeleteClientCertificate(userID, certID string) error {
+	if err := r.Store.DeleteClientCertificate(userID, certID); err != nil {
+		return err
+	}
+	r.enqueue("client certificate deletion", func(r *Store) error { return r.secondary.DeleteClientCertificate(userID, certID) })
+	return nil
+}
+
+// CreateOrUpdateAgent upserts an agent and mirrors it
+func (r *Store) CreateOrUpdateAgent(agent *models.Agent) error {
+	before := *agent
+	if err := r.Store.CreateOrUpdateAgent(agent); err != nil {
+		return err
+	}
+	r.enqueue("agent", func(r *Store) error { return mirrorAgent(r.secondary, before) })
+	return nil
+}
+
+// DeleteAgent soft-deletes an agent and mirrors the deletion
+func (r *Store) DeleteAgent(agentID string, at time.Time) error {
+	if err := r.Store.DeleteAgent(agentID, at); err != nil {
+		return err
+	}
+	r.enqueue("agent deletion", func(r *Store) error { return r.secondary.DeleteAgent(agentID, at) })
+	return nil
+}
+
+// RestoreAgent restores a soft-deleted agent and mirrors the restore
+func (r *Store) RestoreAgent(agentID string) error {
+	if err := r.Store.RestoreAgent(agentID); err != nil {
+		return err
+	}
+	r.enqueue("agent restore", func(r *Store) error { return r.secondary.RestoreAgent(agentID) })
+	return nil
+}
+
+// PurgeDeletedAgents purges soft-deleted agents and mirrors the purge
+func (r *Store) PurgeDeletedAgents(before time.Time) (int, error) {
+	count, err := r.Store.PurgeDeletedAgents(before)
+	if err != nil {
+		return 0, err
+	}
+	r.enqueue("deleted agent purge", func(r *Store) error {
+		_, err := r.secondary.PurgeDeletedAgents(before)
+		return err
+	})
+	return count, nil
+}
+
+// CreateOrUpdateSession upserts a session and mirrors it
+func (r *Store) CreateOrUpdateSession(session *models.Session) error {
+	before := *session
+	if err := r.Store.CreateOrUpdateSession(session); err != nil {
+		return err
+	}
+	r.enqueue("session", func(r *Store) error { return mirrorSession(r.secondary, before) })
+	return nil
+}
+
+// CheckExpiredSessions marks expired sessions and mirrors their new state
+func (r *Store) CheckExpiredSessions() []*models.Session {
+	expired := r.Store.CheckExpiredSessions()
+	for _, session := range expired {
+		copied := *session
+		r.enqueue("session expiry", func(r *Store) error { return mirrorSession(r.secondary, copied) })
+	}
+	return expired
+}
+
+// AddStatus adds a status and mirrors it
+func (r *Store) AddStatus(status *models.AgentStatus) error {
+	if err := r.Store.AddStatus(status); err != nil {
+		return err
+	}
+	r.mirrorStatus(*status)
+	return nil
+}
+
+// AddStatusWithOutbox adds a status with its side effects and mirrors the status
+// The side effects are delivered from the primary's outbox only.
+func (r *Store) AddStatusWithOutbox(status *models.AgentStatus, messages []*models.OutboxMessage) error {
+	if err := r.Store.AddStatusWithOutbox(status, messages); err != nil {
+		return err
+	}
+	r.mirrorStatus(*status)
+	return nil
+}
+
+// CreateStatusAnnotation annotates a status and mirrors the annotation onto the status's secondary copy
+func (r *Store) CreateStatusAnnotation(annotation *models.StatusAnnotation) error {
+	if err := r.Store.CreateStatusAnnotation(annotation); err != nil {
+		return err
+	}
+	copied := *annotation
+	r.enqueue("status annotation", func(r *Store) error {
+		statusID, ok := r.statusIDs[copied.StatusID]
+		if !ok {
+			return errors.New("status was not mirrored by this replica")
+		}
+		copied.StatusID = statusID
+		return r.secondary.CreateStatusAnnotation(&copied)
+	})
+	return nil
+}
+
+// CreateSLA creates an SLA and mirrors it
+func (r *Store) CreateSLA(sla *models.SLA) error {
+	if err := r.Store.CreateSLA(sla); err != nil {
+		return err
+	}
+	copied := *sla
+	r.enqueue("SLA", func(r *Store) error { return r.secondary.CreateSLA(&copied) })
+	return nil
+}
+
+// UpdateSLA updates an SLA and mirrors it
+func (r *Store) UpdateSLA(sla *models.SLA) error {
+	if err := r.Store.UpdateSLA(sla); err != nil {
+		return err
+	}
+	copied := *sla
+	r.enqueue("SLA", func(r *Store) error { return r.secondary.UpdateSLA(&copied) })
+	return nil
+}
+
+// DeleteSLA deletes an SLA and mirrors the deletion
+func (r *Store) DeleteSLA(slaID string) error {
+	if err := r.Store.DeleteSLA(slaID); err != nil {
+		return err
+	}
+	r.enqueue("SLA deletion", func(r *Store) error { return r.secondary.DeleteSLA(slaID) })
+	return nil
+}
+
+// CreateAlertRule creates an alert rule and mirrors it
+func (r *Store) CreateAlertRule(rule *models.AlertRule) error {
+	if err := r.Store.CreateAlertRule(rule); err != nil {
+		return err
+	}
+	copied := *rule
+	r.enqueue("alert rule", func(r *Store) error { return r.secondary.CreateAlertRule(&copied) })
+	return nil
+}
+
+// UpdateAlertRule updates an alert rule and mirrors it
+func (r *Store) UpdateAlertRule(rule *models.AlertRule) error {
+	if err := r.Store.UpdateAlertRule(rule); err != nil {
+		return err
+	}
+	copied := *rule
+	r.enqueue("alert rule", func(r *Store) error { return r.secondary.UpdateAlertRule(&copied) })
+	return nil
+}
+
+// DeleteAlertRule deletes an alert rule and mirrors the deletion
+func (r *Store) DeleteAlertRule(ruleID string) error {
+	if err := r.Store.DeleteAlertRule(ruleID); err != nil {
+		return err
+	}
+	r.enqueue("alert rule deletion", func(r *Store) error { return r.secondary.DeleteAlertRule(ruleID) })
+	return nil
+}
+
+// CreateSLABreach records an SLA breach and mirrors it
+func (r *Store) CreateSLABreach(breach *models.SLABreach) error {
+	if err := r.Store.CreateSLABreach(breach); err != nil {
+		return err
+	}
+	copied := *breach
+	r.enqueue("SLA breach", func(r *Store) error { return r.secondary.CreateSLABreach(&copied) })
+	return nil
+}
+
+// CreateSessionWebhook creates a session webhook and mirrors it
+func (r *Store) CreateSessionWebhook(hook *models.SessionWebhook) error {
+	if err := r.Store.CreateSessionWebhook(hook); err != nil {
+		return err
+	}
+	copied := *hook
+	r.enqueue("session webhook", func(r *Store) error { return r.secondary.CreateSessionWebhook(&copied) })
+	return nil
+}
+
+// UpdateSessionWebhook updates a session webhook and mirrors it
+func (r *Store) UpdateSessionWebhook(hook *models.SessionWebhook) error {
+	if err := r.Store.UpdateSessionWebhook(hook); err != nil {
+		return err
+	}
+	copied := *hook
+	r.enqueue("session webhook", func(r *Store) error { return r.secondary.UpdateSessionWebhook(&copied) })
+	return nil
+}
+
+// DeleteSessionWebhook deletes a session webhook and mirrors the deletion
+func (r *Store) DeleteSessionWebhook(id string) error {
+	if err := r.Store.DeleteSessionWebhook(id); err != nil {
+		return err
+	}
+	r.enqueue("session webhook deletion", func(r *Store) error { return r.secondary.DeleteSessionWebhook(id) })
+	return nil
+}
+
+// SaveWatchItem saves a watchlist item and mirrors it
+func (r *Store) SaveWatchItem(item *models.WatchItem) error {
+	if err := r.Store.SaveWatchItem(item); err != nil {
+		return err
+	}
+	copied := *item
+	r.enqueue("watch item", func(r *Store) error { return r.secondary.SaveWatchItem(&copied) })
+	return nil
+}
+
+// DeleteWatchItem deletes a watchlist item and mirrors the deletion
+func (r *Store) DeleteWatchItem(userID, agentID, sessionTopic string) error {
+	if err := r.Store.DeleteWatchItem(userID, agentID, sessionTopic); err != nil {
+		return err
+	}
+	r.enqueue("watch item deletion", func(r *Store) error {
+		return r.secondary.DeleteWatchItem(userID, agentID, sessionTopic)
+	})
+	return nil
+}
+
+// SaveNotificationSettings saves a user's notification settings and mirrors them
+func (r *Store) SaveNotificationSettings(settings *models.NotificationSettings) error {
+	if err := r.Store.SaveNotificationSettings(settings); err != nil {
+		return err
+	}
+	copied := *settings
+	copied.Transitions = append([]models.StatusTransition(nil), settings.Transitions...)
+	r.enqueue("notification settings", func(r *Store) error { return r.secondary.SaveNotificationSettings(&copied) })
+	return nil
+}
+
+// DeleteNotificationSettings deletes a user's notification settings and mirrors the deletion
+func (r *Store) DeleteNotificationSettings(userID string) error {
+	if err := r.Store.DeleteNotificationSettings(userID); err != nil {
+		return err
+	}
+	r.enqueue("notification settings deletion", func(r *Store) error {
+		return r.secondary.DeleteNotificationSettings(userID)
+	})
+	return nil
+}
+
+// CreateInboxItem creates an inbox item and mirrors it
+func (r *Store) CreateInboxItem(item *models.InboxItem) error {
+	if err := r.Store.CreateInboxItem(item); err != nil {
+		return err
+	}
+	copied := *item
+	r.enqueue("inbox item", func(r *Store) error { return r.secondary.CreateInboxItem(&copied) })
+	return nil
+}
+
+// MarkInboxItemRead marks an inbox item read and mirrors it
+func (r *Store) MarkInboxItemRead(userID, itemID string) error {
+	if err := r.Store.MarkInboxItemRead(userID, itemID); err != nil {
+		return err
+	}
+	r.enqueue("inbox read", func(r *Store) error { return r.secondary.MarkInboxItemRead(userID, itemID) })
+	return nil
+}
+
+// MarkAllInboxItemsRead marks a user's inbox items read and mirrors it
+func (r *Store) MarkAllInboxItemsRead(userID string) (int, error) {
+	count, err := r.Store.MarkAllInboxItemsRead(userID)
+	if err != nil {
+		return 0, err
+	}
+	r.enqueue("inbox read", func(r *Store) error {
+		_, err := r.secondary.MarkAllInboxItemsRead(userID)
+		return err
+	})
+	return count, nil
+}
+
+// AddUsage adds usage counts and mirrors them
+func (r *Store) AddUsage(usage *models.UsageRecord) error {
+	if err := r.Store.AddUsage(usage); err != nil {
+		return err
+	}
+	copied := *usage
+	r.enqueue("usage", func(r *Store) error { return r.secondary.AddUsage(&copied) })
+	return nil
+}
+
+// RollupStatuses rolls up statuses and has the secondary roll up its mirrored statuses of the same days
+func (r *Store) RollupStatuses(from, to time.Time) (int, error) {
+	count, err := r.Store.RollupStatuses(from, to)
+	if err != nil {
+		return 0, err
+	}
+	r.enqueue("status rollups", func(r *Store) error {
+		_, err := r.secondary.RollupStatuses(from, to)
+		return err
+	})
+	return count, nil
+}
+
+// SaveStatusRollup saves a status rollup and mirrors it
+func (r *Store) SaveStatusRollup(rollup *models.StatusRollup) error {
+	if err := r.Store.SaveStatusRollup(rollup); err != nil {
+		return err
+	}
+	copied := *rollup
+	r.enqueue("status rollup", func(r *Store) error { return r.secondary.SaveStatusRollup(&copied) })
+	return nil
+}
+
+// AddAuditEvent appends an audit event and mirrors it
+func (r *Store) AddAuditEvent(event *models.AuditEvent) error {
+	if err := r.Store.AddAuditEvent(event); err != nil {
+		return err
+	}
+	copied := *event
+	r.enqueue("audit event", func(r *Store) error { return r.secondary.AddAuditEvent(&copied) })
+	return nil
+}
+
+// SetConfig sets a config value and mirrors it
+func (r *Store) SetConfig(key, value string) error {
+	if err := r.Store.SetConfig(key, value); err != nil {
+		return err
+	}
+	r.enqueue("config", func(r *Store) error { return r.secondary.SetConfig(key, value) })
+	return nil
+}
@@ -0,0 +1,148 @@
+package replication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// drain applies every queued write to the secondary
+func drain(r *Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.Run(ctx)
+}
+
+func TestStore_MirrorsWrites(t *testing.T) {
+	primary, secondary := store.NewMemoryStore(), store.NewMemoryStore()
+	r := NewStore(primary, secondary, 100)
+	now := time.Now()
+
+	// The secondary already holds a status of another session, so status IDs differ between the stores
+	if err := secondary.CreateOrUpdateAgent(&models.Agent{AgentID: "other", Name: "Other", Registered: now, LastSeen: now}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	if err := secondary.CreateOrUpdateSession(&models.Session{AgentID: "other", SessionTopic: "old", Created: now, LastUpdated: now, TTLMinutes: 30}); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v", err)
+	}
+	if err := secondary.AddStatus(&models.AgentStatus{AgentID: "other", SessionTopic: "old", Status: "success", Timestamp: now}); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+
+	if err := r.CreateUser(&models.User{ID: "user-1", Email: "alice@example.com", PasswordHash: "hash", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := r.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", UserID: "user-1", Name: "Builder", Registered: now, LastSeen: now}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	if err := r.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "build-1", Created: now, LastUpdated: now, TTLMinutes: 30}); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v", err)
+	}
+	status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "build-1", Status: "failed", Timestamp: now, Message: "tests failed"}
+	if err := r.AddStatus(status); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+	annotation := &models.StatusAnnotation{ID: "ann-1", StatusID: status.ID, AgentID: "agent-1", SessionTopic: "build-1", UserID: "user-1", Investigator: "alice@example.com", RootCause: "flaky test", CreatedAt: now}
+	if err := r.CreateStatusAnnotation(annotation); err != nil {
+		t.Fatalf("CreateStatusAnnotation() error = %v", err)
+	}
+	if err := r.SetConfig("jwt_secret", "secret"); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	for _, id := range []string{"rule-1", "rule-2"} {
+		if err := r.CreateAlertRule(&models.AlertRule{ID: id, UserID: "user-1", Name: "Failures", Kind: models.AlertRuleStatus, Status: "failed", CreatedAt: now, UpdatedAt: now}); err != nil {
+			t.Fatalf("CreateAlertRule() error = %v", err)
+		}
+	}
+	if err := r.UpdateAlertRule(&models.AlertRule{ID: "rule-1", UserID: "user-1", Name: "Build failures", Kind: models.AlertRuleStatus, Status: "failed", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("UpdateAlertRule() error = %v", err)
+	}
+	if err := r.DeleteAlertRule("rule-2"); err != nil {
+		t.Fatalf("DeleteAlertRule() error = %v", err)
+	}
+
+	if _, err := secondary.GetAgent("agent-1"); err == nil {
+		t.Fatal("secondary has the agent before the queue was drained, want asynchronous mirroring")
+	}
+	drain(r)
+
+	if _, err := secondary.GetUserByEmail("alice@example.com"); err != nil {
+		t.Errorf("secondary GetUserByEmail() error = %v", err)
+	}
+	if _, err := secondary.GetSession("agent-1", "build-1"); err != nil {
+		t.Errorf("secondary GetSession() error = %v", err)
+	}
+	latest, err := secondary.GetLatestStatus("agent-1", "build-1")
+	if err != nil || latest.Message != "tests failed" {
+		t.Fatalf("secondary GetLatestStatus() = %+v, %v, want the mirrored status", latest, err)
+	}
+	if latest.ID == status.ID {
+		t.Fatalf("secondary status ID = %d, want an ID of its own", latest.ID)
+	}
+	annotations, err := secondary.ListStatusAnnotations("agent-1", "build-1")
+	if err != nil || len(annotations) != 1 || annotations[0].StatusID != latest.ID {
+		t.Errorf("secondary ListStatusAnnotations() = %+v, %v, want the annotation on status %d", annotations, err, latest.ID)
+	}
+	if value, _ := secondary.GetConfig("jwt_secret"); value != "secret" {
+		t.Errorf("secondary GetConfig() = %q, want %q", value, "secret")
+	}
+	rules, err := secondary.ListAlertRulesByUser("user-1")
+	if err != nil || len(rules) != 1 || rules[0].ID != "rule-1" || rules[0].Name != "Build failures" {
+		t.Errorf("secondary ListAlertRulesByUser() = %+v, %v, want the updated rule-1 only", rules, err)
+	}
+}
+
+func TestStore_PrimaryWinsVersionConflicts(t *testing.T) {
+	primary, secondary := store.NewMemoryStore(), store.NewMemoryStore()
+	r := NewStore(primary, secondary, 100)
+	now := time.Now()
+
+	// The secondary's copy was written twice, the primary's once
+	for _, name := range []string{"Stale", "Staler"} {
+		agent, _ := secondary.GetAgent("agent-1")
+		version := 0
+		if agent != nil {
+			version = agent.Version
+		}
+		if err := secondary.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Name: name, Registered: now, LastSeen: now, Version: version}); err != nil {
+			t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+		}
+	}
+	if err := r.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Name: "Builder", Registered: now, LastSeen: now}); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	drain(r)
+
+	agent, err := secondary.GetAgent("agent-1")
+	if err != nil || agent.Name != "Builder" {
+		t.Errorf("secondary GetAgent() = %+v, %v, want the primary's agent", agent, err)
+	}
+}
+
+func TestStore_DropsWritesWhenQueueFull(t *testing.T) {
+	primary, secondary := store.NewMemoryStore(), store.NewMemoryStore()
+	r := NewStore(primary, secondary, 1)
+
+	for _, key := range []string{"a", "b", "c"} {
+		if err := r.SetConfig(key, "value"); err != nil {
+			t.Fatalf("SetConfig(%q) error = %v, want the primary write to succeed", key, err)
+		}
+	}
+	if got := r.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+	if value, _ := primary.GetConfig("c"); value != "value" {
+		t.Errorf("primary GetConfig() = %q, want the dropped write kept on the primary", value)
+	}
+
+	drain(r)
+	if value, _ := secondary.GetConfig("a"); value != "value" {
+		t.Errorf("secondary GetConfig() = %q, want the queued write mirrored", value)
+	}
+	if _, err := secondary.GetConfig("c"); err == nil {
+		t.Error("secondary GetConfig() error = nil, want the dropped write missing")
+	}
+}
@@ -0,0 +1,95 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel is the PostgreSQL notification channel revocations are published on
+const Channel = "kubeagents_revocations"
+
+// reconnectDelay is how long the listener waits before reconnecting after a failure
+const reconnectDelay = 5 * time.Second
+
+// Postgres carries revocations between replicas sharing a database with LISTEN/NOTIFY
+// Publishing delivers to local subscribers at once; other replicas receive the notification within
+// the database round trip.
+type Postgres struct {
+	pool  *pgxpool.Pool
+	local *Local
+}
+
+// NewPostgres creates a revocation bus on the database behind pool; call Run to receive notifications
+func NewPostgres(pool *pgxpool.Pool) *Postgres {
+	return &Postgres{
+		pool:  pool,
+		local: NewLocal(),
+	}
+}
+
+// Subscribe registers fn to be called with the ID of every revoked key
+func (p *Postgres) Subscribe(fn func(keyID string)) {
+	p.local.Subscribe(fn)
+}
+
+// Publish delivers the revocation locally and notifies every listening replica
+func (p *Postgres) Publish(ctx context.Context, keyID string) error {
+	p.local.deliver(keyID)
+	if _, err := p.pool.Exec(ctx, "SELECT pg_notify($1, $2)", Channel, keyID); err != nil {
+		return fmt.Errorf("failed to publish revocation: %w", err)
+	}
+	return nil
+}
+
+// Run listens for revocations until ctx is done, reconnecting after failures
+// Notifications sent while the listener was disconnected are lost, so every reconnect delivers All.
+func (p *Postgres) Run(ctx context.Context) {
+	connected := false
+	for {
+		err := p.listen(ctx, func() {
+			if connected {
+				p.local.deliver(All)
+			}
+			connected = true
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Revocation listener failed, reconnecting in %v: %v", reconnectDelay, err)
+
+		select {
+		case <-time.After(reconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// listen holds a dedicated connection listening on Channel and delivers its notifications
+// onListening runs once the LISTEN took effect.
+func (p *Postgres) listen(ctx context.Context, onListening func()) error {
+	pooled, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection keeps listening, so it never returns to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	onListening()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		p.local.deliver(notification.Payload)
+	}
+}
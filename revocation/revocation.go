@@ -0,0 +1,57 @@
+// Package revocation pushes credential revocations to every replica
+// Replicas cache API key validations, so without a push a key revoked on one replica keeps working on
+// the others until their cache entries expire.
+package revocation
+
+import (
+	"context"
+	"sync"
+)
+
+// All is delivered in place of a key ID when revocations may have been missed, e.g. while a listener
+// reconnected; subscribers should drop every cached decision.
+const All = "*"
+
+// Bus publishes revocations and delivers them to subscribers
+type Bus interface {
+	// Publish announces that a key was revoked
+	Publish(ctx context.Context, keyID string) error
+	// Subscribe registers fn to be called with the ID of every revoked key
+	Subscribe(fn func(keyID string))
+}
+
+// Local delivers revocations to subscribers in this process only
+// It is enough for a single replica, such as one running the memory store.
+type Local struct {
+	mu          sync.RWMutex
+	subscribers []func(keyID string)
+}
+
+// NewLocal creates an in-process revocation bus
+func NewLocal() *Local {
+	return &Local{}
+}
+
+// Subscribe registers fn to be called with the ID of every revoked key
+func (l *Local) Subscribe(fn func(keyID string)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.subscribers = append(l.subscribers, fn)
+}
+
+// Publish delivers the revocation to every subscriber before returning
+func (l *Local) Publish(_ context.Context, keyID string) error {
+	l.deliver(keyID)
+	return nil
+}
+
+// deliver calls every subscriber with keyID
+func (l *Local) deliver(keyID string) {
+	l.mu.RLock()
+	subscribers := l.subscribers
+	l.mu.RUnlock()
+
+	for _, fn := range subscribers {
+		fn(keyID)
+	}
+}
@@ -0,0 +1,72 @@
+package revocation
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestLocal_Publish(t *testing.T) {
+	bus := NewLocal()
+	var first, second []string
+	bus.Subscribe(func(keyID string) { first = append(first, keyID) })
+	bus.Subscribe(func(keyID string) { second = append(second, keyID) })
+
+	if err := bus.Publish(context.Background(), "key-1"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	bus.Publish(context.Background(), "key-2")
+
+	want := []string{"key-1", "key-2"}
+	if !reflect.DeepEqual(first, want) || !reflect.DeepEqual(second, want) {
+		t.Errorf("delivered %v and %v, want %v to each subscriber", first, second, want)
+	}
+}
+
+// The Postgres bus is exercised against the database the store tests use, when one is configured
+func TestPostgres_DeliversAcrossReplicas(t *testing.T) {
+	dsn := os.Getenv("KUBEAGENTS_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("KUBEAGENTS_TEST_POSTGRES_DSN is not set")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New() error = %v", err)
+	}
+	defer pool.Close()
+
+	publisher, listener := NewPostgres(pool), NewPostgres(pool)
+	var mu sync.Mutex
+	var received []string
+	listener.Subscribe(func(keyID string) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, keyID)
+	})
+	go listener.Run(ctx)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		// The listener may not be listening yet, so publish until it hears one
+		if err := publisher.Publish(ctx, "key-1"); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		mu.Lock()
+		got := len(received)
+		mu.Unlock()
+		if got > 0 {
+			return
+		}
+	}
+	t.Fatal("listener received no revocation")
+}
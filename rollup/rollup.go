@@ -0,0 +1,78 @@
+// Package rollup summarizes each completed UTC day of session statuses into status rollups,
+// so history trends outlive the retention of raw statuses
+package rollup
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// WatermarkConfigKey is the system config key holding the first UTC day not yet rolled up, as YYYY-MM-DD
+const WatermarkConfigKey = "status_rollups_through"
+
+// Roller rolls up the days completed since its last run
+type Roller struct {
+	store store.Store
+	clock clock.Clock
+}
+
+// New creates a roller
+func New(st store.Store) *Roller {
+	return &Roller{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that decides which days are complete
+func (r *Roller) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
+// RolledUpThrough returns the first day not yet rolled up, or the zero time before the first run
+// Statuses older than that day are summarized and may be pruned without losing their trends.
+func (r *Roller) RolledUpThrough() (time.Time, error) {
+	value, err := r.store.GetConfig(WatermarkConfigKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get rollup watermark: %w", err)
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid rollup watermark %q: %w", value, err)
+	}
+	return day, nil
+}
+
+// Run rolls up every day from the watermark up to today and returns the number of rollups written
+// The first run rolls up all history before today. Today is left for a later run, once it is complete.
+func (r *Roller) Run() (int, error) {
+	from, err := r.RolledUpThrough()
+	if err != nil {
+		return 0, err
+	}
+	today := models.UsageDay(r.clock.Now())
+	if !from.Before(today) {
+		return 0, nil
+	}
+
+	written, err := r.store.RollupStatuses(from, today)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.store.SetConfig(WatermarkConfigKey, today.Format(time.DateOnly)); err != nil {
+		return 0, fmt.Errorf("failed to save rollup watermark: %w", err)
+	}
+	if written > 0 {
+		log.Printf("Rolled up %d session days of statuses before %s", written, today.Format(time.DateOnly))
+	}
+	return written, nil
+}
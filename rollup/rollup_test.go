@@ -0,0 +1,49 @@
+package rollup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestRoller_Run(t *testing.T) {
+	st := store.NewMemoryStore()
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", Registered: day, LastSeen: day})
+	st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "nightly", Created: day, LastUpdated: day})
+	for _, ts := range []time.Time{day.Add(2 * time.Hour), day.Add(26 * time.Hour), day.Add(50 * time.Hour)} {
+		st.AddStatus(&models.AgentStatus{AgentID: "agent-1", SessionTopic: "nightly", Status: "success", Timestamp: ts, Revision: 1})
+	}
+
+	roller := New(st)
+	fake := clock.NewFake(day.Add(49 * time.Hour))
+	roller.SetClock(fake)
+
+	if through, err := roller.RolledUpThrough(); err != nil || !through.IsZero() {
+		t.Fatalf("RolledUpThrough() before the first run = %v, %v, want zero", through, err)
+	}
+
+	// The first run rolls up every completed day; today is left alone
+	if written, err := roller.Run(); err != nil || written != 2 {
+		t.Fatalf("Run() = %d, %v, want 2 rollups", written, err)
+	}
+	if through, _ := roller.RolledUpThrough(); !through.Equal(day.AddDate(0, 0, 2)) {
+		t.Errorf("RolledUpThrough() = %v, want %v", through, day.AddDate(0, 0, 2))
+	}
+	if written, err := roller.Run(); err != nil || written != 0 {
+		t.Errorf("Run() again on the same day = %d, %v, want nothing to roll up", written, err)
+	}
+
+	// Once today is complete, only it is rolled up
+	fake.Advance(24 * time.Hour)
+	if written, err := roller.Run(); err != nil || written != 1 {
+		t.Fatalf("Run() on the next day = %d, %v, want 1 rollup", written, err)
+	}
+	rollups, _ := st.ListStatusRollups("agent-1", "nightly", day, day.AddDate(0, 0, 7))
+	if len(rollups) != 3 || !rollups[2].Day.Equal(day.AddDate(0, 0, 2)) || rollups[2].Success != 1 {
+		t.Errorf("ListStatusRollups() = %+v, want one rollup for each of the 3 days", rollups)
+	}
+}
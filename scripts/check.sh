@@ -9,6 +9,7 @@
 #     --lint      Run linter only
 #     --gomod     Run go mod check only
 #     --build     Run build check only
+#     --bench     Run auth and webhook benchmarks
 #     --help      Show this help message
 
 # Don't use set -e, we want to continue even if a check fails
@@ -107,6 +108,19 @@ run_tests_short() {
     fi
 }
 
+# Run benchmarks for the authentication and webhook hot paths
+run_bench() {
+    print_header "Benchmarks"
+
+    if go test -run '^$' -bench . -benchmem ./auth ./middleware ./internal ./handlers; then
+        print_success "Benchmarks completed"
+        return 0
+    else
+        print_error "Benchmarks failed"
+        return 1
+    fi
+}
+
 # Run deadcode check
 run_deadcode() {
     print_header "Deadcode Check"
@@ -302,6 +316,7 @@ show_help() {
     echo "  --gomod     Run go mod check only"
     echo "  --build     Run build check only"
     echo "  --quick     Run quick checks (build, vet, test without verbose)"
+    echo "  --bench     Run auth and webhook benchmarks"
     echo "  --help      Show this help message"
     echo ""
     echo "Examples:"
@@ -355,6 +370,9 @@ main() {
         --build)
             run_build
             ;;
+        --bench)
+            run_bench
+            ;;
         --help|-h)
             show_help
             exit 0
@@ -0,0 +1,272 @@
+// Package selftest validates a deployment end to end in one command
+// It registers a temporary user and agent in the configured store, reports a status sequence through the
+// webhook handler, reads it back through the API handlers, sends a test notification and removes
+// everything it created.
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/auth"
+	"github.com/kubeagents/kubeagents/handlers"
+	"github.com/kubeagents/kubeagents/middleware"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// sessionTopic is the session the status sequence is reported on
+const sessionTopic = "selftest"
+
+// statusSequence is reported in order; the last entry is the session's final status
+var statusSequence = []string{"pending", "running", "success"}
+
+// Config configures a self-test run
+type Config struct {
+	NotifyURL string // Destination of the test notification; empty skips that step
+	Format    string // Payload format of the test notification; empty detects it from the URL
+	Timeout   time.Duration
+}
+
+// runner holds the temporary records and in-process server of one run
+type runner struct {
+	store    store.Store
+	notifier *notifier.NotificationManager
+	cfg      Config
+	out      io.Writer
+
+	router  http.Handler
+	token   string
+	userID  string
+	agentID string
+}
+
+// Run performs the self-test against st, printing one line per step to out
+// It returns the first failed step's error; the temporary records are removed even when a step fails.
+func Run(st store.Store, cfg Config, out io.Writer) error {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	r := &runner{
+		store:    st,
+		notifier: notifier.NewNotificationManager(cfg.Timeout),
+		cfg:      cfg,
+		out:      out,
+	}
+
+	err := r.run()
+	if r.userID != "" {
+		cleanupErr := r.step("clean up", func() error { return st.DeleteUser(r.userID) })
+		if err == nil {
+			err = cleanupErr
+		}
+	}
+	return err
+}
+
+func (r *runner) run() error {
+	if err := r.step("register temporary user", r.registerUser); err != nil {
+		return err
+	}
+	if err := r.step("report status sequence", r.reportStatuses); err != nil {
+		return err
+	}
+	if err := r.step("read agent", r.readAgent); err != nil {
+		return err
+	}
+	if err := r.step("read session history", r.readSession); err != nil {
+		return err
+	}
+	if r.cfg.NotifyURL == "" {
+		fmt.Fprintln(r.out, "skip send test notification: no --notify-url")
+		return nil
+	}
+	return r.step("send test notification", r.sendNotification)
+}
+
+// step runs fn and prints its outcome
+func (r *runner) step(name string, fn func() error) error {
+	if err := fn(); err != nil {
+		fmt.Fprintf(r.out, "FAIL %s: %v\n", name, err)
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	fmt.Fprintf(r.out, "ok   %s\n", name)
+	return nil
+}
+
+// registerUser creates a user no one can log in as and the in-process server acting for it
+func (r *runner) registerUser() error {
+	suffix, err := randomHex(8)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	user := &models.User{
+		ID:            uuid.New().String(),
+		Email:         "selftest-" + suffix + "@selftest.invalid",
+		PasswordHash:  "!", // Not a bcrypt hash, so no password matches it
+		Name:          "kubeagents selftest",
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := r.store.CreateUser(user); err != nil {
+		return err
+	}
+	r.userID = user.ID
+	r.agentID = "selftest-" + suffix
+
+	// Tokens are signed with a throwaway secret, so they are useless outside this run
+	secret, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	jwtService := auth.NewJWTService(secret, time.Hour, time.Hour)
+	r.token, err = jwtService.GenerateAccessToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+
+	authMW := middleware.NewAuthMiddlewareWithStore(jwtService, r.store)
+	webhookHandler := handlers.NewWebhookHandlerWithNotifier(r.store, r.notifier)
+	agentHandler := handlers.NewAgentHandler(r.store)
+
+	router := chi.NewRouter()
+	router.With(authMW.RequireAuthOrAPIKey).Post("/webhook/status", webhookHandler.ServeHTTP)
+	router.Route("/api/agents/{agent_id}", func(router chi.Router) {
+		router.Use(authMW.RequireAuth)
+		router.Get("/", agentHandler.GetAgent)
+		router.Get("/sessions/{session_topic}", agentHandler.GetSession)
+	})
+	r.router = router
+	return nil
+}
+
+// reportStatuses posts the status sequence to the webhook, one second apart
+func (r *runner) reportStatuses() error {
+	start := time.Now().UTC().Add(-time.Duration(len(statusSequence)) * time.Second)
+	for i, status := range statusSequence {
+		report := map[string]interface{}{
+			"agent_id":      r.agentID,
+			"agent_name":    "kubeagents selftest",
+			"agent_source":  "selftest",
+			"session_topic": sessionTopic,
+			"status":        status,
+			"timestamp":     start.Add(time.Duration(i) * time.Second).Format(time.RFC3339Nano),
+			"message":       fmt.Sprintf("selftest step %d of %d", i+1, len(statusSequence)),
+		}
+		if _, err := r.request(http.MethodPost, "/webhook/status", report); err != nil {
+			return fmt.Errorf("report %s: %w", status, err)
+		}
+	}
+	return nil
+}
+
+// readAgent checks the agent is visible to its owner
+func (r *runner) readAgent() error {
+	body, err := r.request(http.MethodGet, "/api/agents/"+url.PathEscape(r.agentID), nil)
+	if err != nil {
+		return err
+	}
+	var agent struct {
+		AgentID string `json:"agent_id"`
+	}
+	if err := json.Unmarshal(body, &agent); err != nil {
+		return fmt.Errorf("decode agent: %w", err)
+	}
+	if agent.AgentID != r.agentID {
+		return fmt.Errorf("got agent %q, want %q", agent.AgentID, r.agentID)
+	}
+	return nil
+}
+
+// readSession checks the session history holds the whole sequence, newest first
+func (r *runner) readSession() error {
+	path := "/api/agents/" + url.PathEscape(r.agentID) + "/sessions/" + url.PathEscape(sessionTopic)
+	body, err := r.request(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	var response struct {
+		StatusHistory []struct {
+			Status string `json:"status"`
+		} `json:"status_history"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("decode session: %w", err)
+	}
+	if len(response.StatusHistory) != len(statusSequence) {
+		return fmt.Errorf("got %d statuses, want %d", len(response.StatusHistory), len(statusSequence))
+	}
+	for i, entry := range response.StatusHistory {
+		if want := statusSequence[len(statusSequence)-1-i]; entry.Status != want {
+			return fmt.Errorf("status %d is %q, want %q", i, entry.Status, want)
+		}
+	}
+	return nil
+}
+
+// sendNotification delivers a notification for the finished session to the configured destination
+func (r *runner) sendNotification() error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.Timeout)
+	defer cancel()
+
+	data := &notifier.NotificationData{
+		AgentID:      r.agentID,
+		AgentName:    "kubeagents selftest",
+		SessionTopic: sessionTopic,
+		FromStatus:   statusSequence[len(statusSequence)-2],
+		ToStatus:     statusSequence[len(statusSequence)-1],
+		Timestamp:    time.Now().UTC(),
+		Message:      "kubeagents selftest notification, no action needed",
+	}
+	destination := models.NotificationDestination{URL: r.cfg.NotifyURL, Format: r.cfg.Format}
+	if err := destination.Validate(); err != nil {
+		return err
+	}
+	return r.notifier.Deliver(ctx, data, destination)
+}
+
+// request serves one authenticated request in process and returns the body of a 2xx response
+func (r *runner) request(method, path string, payload interface{}) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "Bearer "+r.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	r.router.ServeHTTP(rec, req)
+	if rec.Code < 200 || rec.Code > 299 {
+		return nil, errors.New(http.StatusText(rec.Code) + ": " + string(bytes.TrimSpace(rec.Body.Bytes())))
+	}
+	return rec.Body.Bytes(), nil
+}
+
+// randomHex returns n random bytes hex-encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
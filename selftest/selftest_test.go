@@ -0,0 +1,72 @@
+package selftest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kubeagents/kubeagents/store"
+)
+
+func TestRun(t *testing.T) {
+	var received atomic.Int32
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer destination.Close()
+
+	st := store.NewMemoryStore()
+	var out bytes.Buffer
+	if err := Run(st, Config{NotifyURL: destination.URL}, &out); err != nil {
+		t.Fatalf("Run() error = %v, output:\n%s", err, out.String())
+	}
+
+	if strings.Contains(out.String(), "FAIL") {
+		t.Errorf("Run() output has a failed step:\n%s", out.String())
+	}
+	if received.Load() != 1 {
+		t.Errorf("destination received %d notifications, want 1", received.Load())
+	}
+
+	// Everything the run created is gone again
+	if users, _ := st.ListUsers(); len(users) != 0 {
+		t.Errorf("ListUsers() after run = %d users, want 0", len(users))
+	}
+	if agents := st.ListAgents(); len(agents) != 0 {
+		t.Errorf("ListAgents() after run = %d agents, want 0", len(agents))
+	}
+}
+
+func TestRun_NotificationFailure(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer destination.Close()
+
+	st := store.NewMemoryStore()
+	var out bytes.Buffer
+	err := Run(st, Config{NotifyURL: destination.URL}, &out)
+	if err == nil {
+		t.Fatalf("Run() error = nil, want the notification failure")
+	}
+	if !strings.Contains(out.String(), "FAIL send test notification") || !strings.Contains(out.String(), "ok   clean up") {
+		t.Errorf("Run() output = %q, want a failed notification followed by clean up", out.String())
+	}
+	if users, _ := st.ListUsers(); len(users) != 0 {
+		t.Errorf("ListUsers() after failed run = %d users, want 0", len(users))
+	}
+}
+
+func TestRun_SkipsNotificationWithoutURL(t *testing.T) {
+	var out bytes.Buffer
+	if err := Run(store.NewMemoryStore(), Config{}, &out); err != nil {
+		t.Fatalf("Run() error = %v, output:\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "skip send test notification") {
+		t.Errorf("Run() output = %q, want the notification step skipped", out.String())
+	}
+}
@@ -0,0 +1,194 @@
+// Package sessionclose closes the running sessions of agents that were deleted or went offline,
+// failing or expiring them as each agent's owner chose
+package sessionclose
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/internal"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/sessionhook"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Messages of the statuses recorded for closed sessions
+const (
+	deletedMessage = "Agent was deleted while the session was running"
+	offlineMessage = "Agent went offline while the session was running"
+	expiredMessage = "Session TTL ran out before the agent reported a final status"
+)
+
+// Closer applies the owners' SessionAutoClose choices
+type Closer struct {
+	store  store.Store
+	hooks  *sessionhook.Dispatcher
+	events *events.Broker
+	clock  clock.Clock
+}
+
+// NewCloser creates a closer
+func NewCloser(st store.Store) *Closer {
+	return &Closer{
+		store: st,
+		clock: clock.Real,
+	}
+}
+
+// SetClock replaces the clock that stamps closed sessions
+func (c *Closer) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// now returns the time sessions are closed at, in UTC
+func (c *Closer) now() time.Time {
+	return c.clock.Now().UTC()
+}
+
+// SetSessionHooks delivers the closed sessions to session webhooks
+func (c *Closer) SetSessionHooks(d *sessionhook.Dispatcher) {
+	c.hooks = d
+}
+
+// SetEvents publishes the recorded statuses to live event subscribers
+func (c *Closer) SetEvents(b *events.Broker) {
+	c.events = b
+}
+
+// AgentDeleted applies the owner's on_delete choice to the agent's running sessions and returns how many it closed
+// It must be called before the agent is deleted, while its sessions are still visible.
+func (c *Closer) AgentDeleted(agent *models.Agent) int {
+	user, ok := c.owner(agent)
+	if !ok {
+		return 0
+	}
+	return c.close(agent, user.SessionAutoClose.OnDelete, models.EndReasonAgentDeleted, models.StatusAgentDeleted, deletedMessage)
+}
+
+// AgentOffline applies the owner's on_offline choice to the agent's running sessions and returns how many it closed
+func (c *Closer) AgentOffline(agent *models.Agent) int {
+	user, ok := c.owner(agent)
+	if !ok {
+		return 0
+	}
+	return c.close(agent, user.SessionAutoClose.OnOffline, models.EndReasonAgentOffline, models.StatusAgentOffline, offlineMessage)
+}
+
+// owner loads the agent's owner; agents without one have nobody to choose and are left alone
+func (c *Closer) owner(agent *models.Agent) (*models.User, bool) {
+	if agent.UserID == "" {
+		return nil, false
+	}
+	user, err := c.store.GetUserByID(agent.UserID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to load owner of agent %s to close its sessions: %v", agent.AgentID, err)
+		}
+		return nil, false
+	}
+	return user, true
+}
+
+// SessionsExpired records an expired server status for each session just marked expired whose run was still going
+func (c *Closer) SessionsExpired(sessions []*models.Session) {
+	for _, session := range sessions {
+		if session.EndReason != models.EndReasonTTLExpired || session.ExpiredAt == nil {
+			continue
+		}
+		var latest *models.AgentStatus
+		if status, err := c.store.GetLatestStatus(session.AgentID, session.SessionTopic); err == nil && status.Revision == session.Revision {
+			latest = status
+		}
+		c.record(models.NewServerStatus(session, models.StatusExpired, expiredMessage, *session.ExpiredAt), latest)
+	}
+}
+
+// close ends each running session of the agent as action says, recording endReason
+// Failed sessions get a failed status, expired ones the server status, both marked as recorded by the server.
+func (c *Closer) close(agent *models.Agent, action, endReason, serverStatus, message string) int {
+	if action == models.SessionCloseLeave {
+		return 0
+	}
+
+	closed := 0
+	for _, session := range c.store.ListSessions(agent.AgentID, false) {
+		// Runs that reported a final status or were already ended are not running
+		if session.EndReason != "" {
+			continue
+		}
+		var latest *models.AgentStatus
+		if status, err := c.store.GetLatestStatus(agent.AgentID, session.SessionTopic); err == nil && status.Revision == session.Revision {
+			if internal.IsFinalStatus(status.Status) {
+				continue
+			}
+			latest = status
+		}
+
+		now := c.now()
+		session.EndReason = endReason
+		if action == models.SessionCloseExpire {
+			session.Expired = true
+			session.ExpiredAt = &now
+		}
+		// A session the agent reported to meanwhile is left to that report
+		if err := c.store.CreateOrUpdateSession(session); err != nil {
+			if !errors.Is(err, store.ErrConflict) {
+				log.Printf("Failed to close session %s of agent %s: %v", session.SessionTopic, agent.AgentID, err)
+			}
+			continue
+		}
+
+		final := latest
+		if action == models.SessionCloseFail {
+			failed := models.NewServerStatus(session, "failed", message, now)
+			if !c.record(failed, latest) {
+				continue
+			}
+			final = failed
+		}
+
+		closed++
+		if c.hooks != nil {
+			c.hooks.SessionEnded(agent, session, final)
+		}
+		// Hooks got the agent's last status; the history shows why the run stopped
+		if action == models.SessionCloseExpire {
+			c.record(models.NewServerStatus(session, serverStatus, message, now), latest)
+		}
+	}
+	return closed
+}
+
+// record adds a status to its session's history and publishes it, reporting whether it was stored
+func (c *Closer) record(status, previous *models.AgentStatus) bool {
+	if err := c.store.AddStatus(status); err != nil {
+		log.Printf("Failed to record %s status of session %s of agent %s: %v", status.Status, status.SessionTopic, status.AgentID, err)
+		return false
+	}
+	c.publish(status, previous)
+	return true
+}
+
+// publish sends a recorded status to the agent's live event subscribers
+func (c *Closer) publish(status, previous *models.AgentStatus) {
+	if c.events == nil {
+		return
+	}
+	event := &events.Event{
+		Type:         events.TypeStatus,
+		AgentID:      status.AgentID,
+		SessionTopic: status.SessionTopic,
+		Status:       status.Status,
+		Message:      status.Message,
+		Revision:     status.Revision,
+		Origin:       status.Origin,
+		Timestamp:    status.Timestamp,
+	}
+	if previous != nil {
+		event.FromStatus = previous.Status
+	}
+	c.events.Publish(event)
+}
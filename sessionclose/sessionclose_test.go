@@ -0,0 +1,148 @@
+package sessionclose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/events"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+var testNow = time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// setup creates a store where user-1 chose autoClose and owns agent-1 with sessions
+// "running" (in progress), "done" (finished) and "quiet" (no status yet)
+func setup(t *testing.T, autoClose models.SessionAutoClose) (*store.MemoryStore, *models.Agent) {
+	t.Helper()
+
+	st := store.NewMemoryStore()
+	user := &models.User{ID: "user-1", Email: "one@example.com", PasswordHash: "x", SessionAutoClose: autoClose, CreatedAt: testNow, UpdatedAt: testNow}
+	if err := st.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	agent := &models.Agent{AgentID: "agent-1", UserID: "user-1", Name: "Builder", Registered: testNow, LastSeen: testNow}
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	for _, topic := range []string{"running", "done", "quiet"} {
+		session := &models.Session{AgentID: "agent-1", SessionTopic: topic, Created: testNow, LastUpdated: testNow, TTLMinutes: 30, Revision: 1}
+		if topic == "done" {
+			session.EndReason = models.EndReasonAgentReported
+		}
+		if err := st.CreateOrUpdateSession(session); err != nil {
+			t.Fatalf("CreateOrUpdateSession(%s) error = %v", topic, err)
+		}
+	}
+	for _, status := range []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "running", Status: "running", Timestamp: testNow, Revision: 1},
+		{AgentID: "agent-1", SessionTopic: "done", Status: "success", Timestamp: testNow, Revision: 1},
+	} {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus(%s) error = %v", status.SessionTopic, err)
+		}
+	}
+	return st, agent
+}
+
+func newCloser(st store.Store) *Closer {
+	c := NewCloser(st)
+	c.SetClock(clock.NewFake(testNow.Add(time.Minute)))
+	return c
+}
+
+func TestCloser_Fail(t *testing.T) {
+	st, agent := setup(t, models.SessionAutoClose{OnOffline: models.SessionCloseFail})
+	c := newCloser(st)
+	broker := events.NewBroker()
+	c.SetEvents(broker)
+	received, unsubscribe := broker.Subscribe("agent-1")
+	defer unsubscribe()
+
+	if closed := c.AgentOffline(agent); closed != 2 {
+		t.Fatalf("AgentOffline() closed %d sessions, want 2", closed)
+	}
+
+	for _, topic := range []string{"running", "quiet"} {
+		session, _ := st.GetSession("agent-1", topic)
+		if session.Expired || session.EndReason != models.EndReasonAgentOffline {
+			t.Errorf("session %s = expired %v, end reason %q, want open and ended with agent_offline", topic, session.Expired, session.EndReason)
+		}
+		latest, err := st.GetLatestStatus("agent-1", topic)
+		if err != nil || latest.Status != "failed" || latest.Message != offlineMessage || !latest.Timestamp.Equal(testNow.Add(time.Minute)) || !latest.FromServer() {
+			t.Errorf("latest status of %s = %+v, %v, want the offline failure recorded by the server", topic, latest, err)
+		}
+	}
+	if latest, _ := st.GetLatestStatus("agent-1", "done"); latest.Status != "success" {
+		t.Errorf("latest status of the finished session = %s, want it untouched", latest.Status)
+	}
+
+	first := <-received
+	if first.Type != events.TypeStatus || first.Status != "failed" || first.Origin != models.StatusOriginServer {
+		t.Errorf("published event = %+v, want a failed status", first)
+	}
+
+	// Closed sessions are not closed again
+	if closed := c.AgentDeleted(agent); closed != 0 {
+		t.Errorf("AgentDeleted() after closing closed %d sessions, want 0", closed)
+	}
+}
+
+func TestCloser_Expire(t *testing.T) {
+	st, agent := setup(t, models.SessionAutoClose{OnDelete: models.SessionCloseExpire})
+	c := newCloser(st)
+
+	if closed := c.AgentDeleted(agent); closed != 2 {
+		t.Fatalf("AgentDeleted() closed %d sessions, want 2", closed)
+	}
+
+	session, _ := st.GetSession("agent-1", "running")
+	if !session.Expired || session.ExpiredAt == nil || !session.ExpiredAt.Equal(testNow.Add(time.Minute)) || session.EndReason != models.EndReasonAgentDeleted {
+		t.Errorf("session = %+v, want expired now with agent_deleted", session)
+	}
+	if latest, _ := st.GetLatestStatus("agent-1", "running"); latest.Status != models.StatusAgentDeleted || !latest.FromServer() || latest.Revision != 1 {
+		t.Errorf("latest status = %+v, want the agent_deleted server status", latest)
+	}
+	if done, _ := st.GetSession("agent-1", "done"); done.Expired {
+		t.Error("finished session expired, want it untouched")
+	}
+}
+
+func TestCloser_SessionsExpired(t *testing.T) {
+	st, _ := setup(t, models.SessionAutoClose{})
+	c := newCloser(st)
+
+	expiredAt := testNow.Add(30 * time.Minute)
+	running, _ := st.GetSession("agent-1", "running")
+	running.Expired, running.ExpiredAt, running.EndReason = true, &expiredAt, models.EndReasonTTLExpired
+	done, _ := st.GetSession("agent-1", "done")
+	done.Expired, done.ExpiredAt = true, &expiredAt
+
+	c.SessionsExpired([]*models.Session{running, done})
+
+	latest, err := st.GetLatestStatus("agent-1", "running")
+	if err != nil || latest.Status != models.StatusExpired || !latest.FromServer() || !latest.Timestamp.Equal(expiredAt) {
+		t.Errorf("latest status = %+v, %v, want the expired server status at expiry", latest, err)
+	}
+	// The finished run ended with its agent's final status
+	if latest, _ := st.GetLatestStatus("agent-1", "done"); latest.Status != "success" {
+		t.Errorf("latest status of the finished session = %s, want it untouched", latest.Status)
+	}
+}
+
+func TestCloser_Leave(t *testing.T) {
+	st, agent := setup(t, models.SessionAutoClose{OnDelete: models.SessionCloseFail})
+	c := newCloser(st)
+
+	// Only on_delete was chosen, so going offline leaves the sessions
+	if closed := c.AgentOffline(agent); closed != 0 {
+		t.Errorf("AgentOffline() closed %d sessions, want 0", closed)
+	}
+	if closed := c.AgentDeleted(&models.Agent{AgentID: "agent-1"}); closed != 0 {
+		t.Errorf("AgentDeleted() of an agent without owner closed %d sessions, want 0", closed)
+	}
+	if session, _ := st.GetSession("agent-1", "running"); session.EndReason != "" {
+		t.Errorf("session end reason = %q, want it still running", session.EndReason)
+	}
+}
@@ -0,0 +1,276 @@
+// Package sessionhook delivers session runs that ended to the session webhooks their agents' owners registered,
+// so downstream automation can trigger on agent completion
+package sessionhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// EventSessionEnded is the event delivered when a session run ends
+const EventSessionEnded = "session.ended"
+
+// Headers identifying a delivery, next to the notifier's signature headers
+const (
+	EventHeader    = "X-KubeAgents-Event"
+	DeliveryHeader = "X-KubeAgents-Delivery"
+)
+
+// Default retry policy of deliveries made without the outbox
+const (
+	defaultAttempts = 5
+	defaultBackoff  = time.Second
+)
+
+// eventNamespace derives event IDs, so an ended run has the same ID however often it is delivered
+var eventNamespace = uuid.MustParse("5b0c4a7e-3f3e-4a53-9c1e-7f0a2d6c8e41")
+
+// Event is the JSON body delivered to a session webhook
+type Event struct {
+	ID          string              `json:"id"` // Same for every attempt to deliver the run to the webhook, for deduplication
+	Event       string              `json:"event"`
+	WebhookID   string              `json:"webhook_id"`
+	Outcome     string              `json:"outcome"` // One of the models.SessionOutcome constants
+	AgentID     string              `json:"agent_id"`
+	AgentName   string              `json:"agent_name,omitempty"`
+	Session     *models.Session     `json:"session"`
+	FinalStatus *models.AgentStatus `json:"final_status,omitempty"` // Latest status of the run; absent if it reported none
+	EndedAt     time.Time           `json:"ended_at"`
+}
+
+// Delivery is an event due to one webhook
+// Only the webhook ID is kept, so a webhook deleted or changed before the delivery is honoured.
+type Delivery struct {
+	WebhookID string `json:"webhook_id"`
+	Event     *Event `json:"event"`
+}
+
+// Dispatcher finds the webhooks receiving an ended run and delivers it to them, signed with each webhook's secret
+type Dispatcher struct {
+	store    store.Store
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	clock    clock.Clock
+
+	wg sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher whose requests time out after timeout
+func NewDispatcher(st store.Store, timeout time.Duration) *Dispatcher {
+	return &Dispatcher{
+		store:    st,
+		client:   &http.Client{Timeout: timeout},
+		attempts: defaultAttempts,
+		backoff:  defaultBackoff,
+		clock:    clock.Real,
+	}
+}
+
+// SetClock replaces the clock that stamps events and signatures and times retries
+func (d *Dispatcher) SetClock(c clock.Clock) {
+	d.clock = c
+}
+
+// now returns the time events and signatures are stamped with, in UTC
+func (d *Dispatcher) now() time.Time {
+	return d.clock.Now().UTC()
+}
+
+// SetTransport replaces the transport deliveries are sent through
+func (d *Dispatcher) SetTransport(rt http.RoundTripper) {
+	d.client.Transport = rt
+}
+
+// SetRetry configures how often Publish attempts a delivery and the backoff before the second attempt,
+// doubled before each later one
+func (d *Dispatcher) SetRetry(attempts int, backoff time.Duration) {
+	d.attempts = attempts
+	d.backoff = backoff
+}
+
+// Deliveries returns the deliveries due for a session run that ended, final being its latest status if any
+func (d *Dispatcher) Deliveries(agent *models.Agent, session *models.Session, final *models.AgentStatus) []*Delivery {
+	if agent.UserID == "" {
+		return nil
+	}
+	hooks, err := d.store.ListSessionWebhooksByUser(agent.UserID)
+	if err != nil {
+		log.Printf("Failed to load session webhooks of user %s: %v", agent.UserID, err)
+		return nil
+	}
+
+	latest := ""
+	if final != nil {
+		latest = final.Status
+	}
+	outcome := models.SessionOutcome(session, latest)
+	endedAt := d.now()
+	switch {
+	case session.EndReason == models.EndReasonAgentReported && final != nil:
+		endedAt = final.Timestamp
+	case session.ExpiredAt != nil:
+		endedAt = *session.ExpiredAt
+	}
+
+	// Events outlive the caller's session, which it may change again
+	ended := *session
+
+	var deliveries []*Delivery
+	for _, hook := range hooks {
+		if !hook.Receives(agent.AgentID, outcome) {
+			continue
+		}
+		run := hook.ID + "|" + agent.AgentID + "|" + session.SessionTopic + "|" + strconv.Itoa(session.Revision)
+		deliveries = append(deliveries, &Delivery{
+			WebhookID: hook.ID,
+			Event: &Event{
+				ID:          uuid.NewSHA1(eventNamespace, []byte(run)).String(),
+				Event:       EventSessionEnded,
+				WebhookID:   hook.ID,
+				Outcome:     outcome,
+				AgentID:     agent.AgentID,
+				AgentName:   agent.Name,
+				Session:     &ended,
+				FinalStatus: final,
+				EndedAt:     endedAt,
+			},
+		})
+	}
+	return deliveries
+}
+
+// SessionEnded delivers a session run that ended in the background
+func (d *Dispatcher) SessionEnded(agent *models.Agent, session *models.Session, final *models.AgentStatus) {
+	d.Publish(d.Deliveries(agent, session, final))
+}
+
+// SessionsExpired delivers sessions that were just marked expired
+// Sessions whose run already ended, with a final status or with their agent, were delivered then and are skipped.
+func (d *Dispatcher) SessionsExpired(sessions []*models.Session) {
+	agents := make(map[string]*models.Agent)
+	for _, session := range sessions {
+		if session.EndedBeforeExpiry() {
+			continue
+		}
+		agent, ok := agents[session.AgentID]
+		if !ok {
+			loaded, err := d.store.GetAgent(session.AgentID)
+			if err != nil {
+				log.Printf("Failed to load agent for session webhooks: %v", err)
+			}
+			agent = loaded
+			agents[session.AgentID] = agent
+		}
+		if agent == nil {
+			continue
+		}
+
+		var final *models.AgentStatus
+		if latest, err := d.store.GetLatestStatus(session.AgentID, session.SessionTopic); err == nil && latest.Revision == session.Revision {
+			final = latest
+		}
+		d.SessionEnded(agent, session, final)
+	}
+}
+
+// Publish sends deliveries in the background, retrying each failed one with exponential backoff
+func (d *Dispatcher) Publish(deliveries []*Delivery) {
+	for _, delivery := range deliveries {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.deliverWithRetry(delivery)
+		}()
+	}
+}
+
+// deliverWithRetry attempts a delivery until it succeeds or the attempts run out
+func (d *Dispatcher) deliverWithRetry(delivery *Delivery) {
+	backoff := d.backoff
+	var err error
+	for attempt := 1; attempt <= d.attempts; attempt++ {
+		if attempt > 1 {
+			clock.Sleep(d.clock, backoff)
+			backoff *= 2
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), d.client.Timeout+time.Second)
+		err = d.Deliver(ctx, delivery)
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+	log.Printf("Dropping session webhook delivery %s to %s after %d attempts: %v", delivery.Event.ID, delivery.WebhookID, d.attempts, err)
+}
+
+// Deliver makes one attempt to send a delivery to its webhook
+// Deliveries to webhooks that were deleted since are dropped without error.
+func (d *Dispatcher) Deliver(ctx context.Context, delivery *Delivery) error {
+	hook, err := d.store.GetSessionWebhook(delivery.WebhookID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load session webhook: %w", err)
+	}
+
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return fmt.Errorf("failed to encode session event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event.Event)
+	req.Header.Set(DeliveryHeader, delivery.Event.ID)
+	req.Header.Set(notifier.TimestampHeader, timestamp)
+	req.Header.Set(notifier.SignatureHeader, notifier.Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Shutdown waits for deliveries in progress until ctx is done
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
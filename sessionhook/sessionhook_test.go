@@ -0,0 +1,195 @@
+package sessionhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/notifier"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// received is a delivery as a test receiver saw it
+type received struct {
+	event     Event
+	signature string
+	timestamp string
+	body      []byte
+}
+
+// receiver records the deliveries it is sent, failing the first failures of them with 500
+type receiver struct {
+	mu       sync.Mutex
+	got      []received
+	failures int32
+	server   *httptest.Server
+}
+
+func newReceiver(t *testing.T, failures int32) *receiver {
+	t.Helper()
+
+	rc := &receiver{failures: failures}
+	rc.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&rc.failures, -1) >= 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("delivery body %s: %v", body, err)
+		}
+		if r.Header.Get(EventHeader) != EventSessionEnded || r.Header.Get(DeliveryHeader) != event.ID {
+			t.Errorf("delivery headers = %v, want event %s and delivery %s", r.Header, EventSessionEnded, event.ID)
+		}
+
+		rc.mu.Lock()
+		rc.got = append(rc.got, received{event: event, signature: r.Header.Get(notifier.SignatureHeader), timestamp: r.Header.Get(notifier.TimestampHeader), body: body})
+		rc.mu.Unlock()
+	}))
+	t.Cleanup(rc.server.Close)
+	return rc
+}
+
+func (rc *receiver) deliveries() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]received(nil), rc.got...)
+}
+
+// setupHooks creates a store holding agent-1 of user-1 and the given webhooks of user-1
+func setupHooks(t *testing.T, hooks ...*models.SessionWebhook) (*store.MemoryStore, *models.Agent) {
+	t.Helper()
+
+	st := store.NewMemoryStore()
+	now := time.Now().UTC()
+	agent := &models.Agent{AgentID: "agent-1", UserID: "user-1", Name: "Builder", Registered: now, LastSeen: now}
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	for _, hook := range hooks {
+		hook.UserID = "user-1"
+		hook.CreatedAt = now
+		hook.UpdatedAt = now
+		if err := st.CreateSessionWebhook(hook); err != nil {
+			t.Fatalf("CreateSessionWebhook(%s) error = %v", hook.ID, err)
+		}
+	}
+	return st, agent
+}
+
+func TestDispatcher_Deliveries(t *testing.T) {
+	st, agent := setupHooks(t,
+		&models.SessionWebhook{ID: "all", URL: "https://ci.example.com/all", Secret: "s1"},
+		&models.SessionWebhook{ID: "failures", URL: "https://ci.example.com/failures", Secret: "s2", Outcomes: []string{models.SessionOutcomeFailed}},
+		&models.SessionWebhook{ID: "other-agent", URL: "https://ci.example.com/other", Secret: "s3", AgentID: "agent-2"},
+	)
+	d := NewDispatcher(st, time.Second)
+
+	session := &models.Session{AgentID: "agent-1", SessionTopic: "build-1", Revision: 2, EndReason: models.EndReasonAgentReported}
+	success := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "build-1", Status: "success", Timestamp: time.Now().UTC(), Revision: 2}
+	deliveries := d.Deliveries(agent, session, success)
+	if len(deliveries) != 1 || deliveries[0].WebhookID != "all" {
+		t.Fatalf("Deliveries(success) = %+v, want one to all", deliveries)
+	}
+	event := deliveries[0].Event
+	if event.Outcome != models.SessionOutcomeSuccess || event.Session.Revision != 2 || event.FinalStatus != success || !event.EndedAt.Equal(success.Timestamp) {
+		t.Errorf("Deliveries(success) event = %+v", event)
+	}
+
+	failed := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "build-1", Status: "failed", Timestamp: time.Now().UTC(), Revision: 2}
+	deliveries = d.Deliveries(agent, session, failed)
+	if len(deliveries) != 2 {
+		t.Fatalf("Deliveries(failed) = %d deliveries, want 2", len(deliveries))
+	}
+	if again := d.Deliveries(agent, session, failed); again[0].Event.ID != deliveries[0].Event.ID {
+		t.Errorf("Deliveries() event ID = %s then %s, want the same for the same run", deliveries[0].Event.ID, again[0].Event.ID)
+	}
+
+	// The next run of the session is a new event
+	next := *session
+	next.Revision = 3
+	if again := d.Deliveries(agent, &next, failed); again[0].Event.ID == deliveries[0].Event.ID {
+		t.Errorf("Deliveries() event ID of the next run = %s, want a new one", again[0].Event.ID)
+	}
+
+	cancelled := &models.Session{AgentID: "agent-1", SessionTopic: "build-1", EndReason: models.EndReasonCancelled}
+	if deliveries := d.Deliveries(agent, cancelled, nil); len(deliveries) != 1 || deliveries[0].Event.Outcome != models.SessionOutcomeCancelled {
+		t.Errorf("Deliveries(cancelled) = %+v, want one cancelled delivery", deliveries)
+	}
+}
+
+func TestDispatcher_PublishSignsAndRetries(t *testing.T) {
+	rc := newReceiver(t, 2)
+	st, agent := setupHooks(t, &models.SessionWebhook{ID: "hook-1", URL: rc.server.URL, Secret: "whsec_test"})
+	d := NewDispatcher(st, time.Second)
+	d.SetRetry(3, time.Millisecond)
+
+	session := &models.Session{AgentID: "agent-1", SessionTopic: "build-1", EndReason: models.EndReasonAgentReported}
+	final := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "build-1", Status: "failed", Message: "tests failed", Timestamp: time.Now().UTC()}
+	d.SessionEnded(agent, session, final)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	got := rc.deliveries()
+	if len(got) != 1 {
+		t.Fatalf("receiver got %d deliveries, want 1 after two failures", len(got))
+	}
+	if want := notifier.Sign("whsec_test", got[0].timestamp, got[0].body); got[0].signature != want {
+		t.Errorf("delivery signature = %s, want %s", got[0].signature, want)
+	}
+	event := got[0].event
+	if event.Outcome != models.SessionOutcomeFailed || event.Session == nil || event.Session.SessionTopic != "build-1" ||
+		event.FinalStatus == nil || event.FinalStatus.Message != "tests failed" || event.AgentName != "Builder" {
+		t.Errorf("delivered event = %+v", event)
+	}
+}
+
+func TestDispatcher_DeliverToDeletedWebhook(t *testing.T) {
+	rc := newReceiver(t, 0)
+	st, agent := setupHooks(t, &models.SessionWebhook{ID: "hook-1", URL: rc.server.URL, Secret: "whsec_test"})
+	d := NewDispatcher(st, time.Second)
+
+	deliveries := d.Deliveries(agent, &models.Session{AgentID: "agent-1", SessionTopic: "build-1", EndReason: models.EndReasonCancelled}, nil)
+	if err := st.DeleteSessionWebhook("hook-1"); err != nil {
+		t.Fatalf("DeleteSessionWebhook() error = %v", err)
+	}
+	if err := d.Deliver(context.Background(), deliveries[0]); err != nil {
+		t.Errorf("Deliver() error = %v, want the delivery dropped", err)
+	}
+	if got := rc.deliveries(); len(got) != 0 {
+		t.Errorf("receiver got %d deliveries, want none", len(got))
+	}
+}
+
+func TestDispatcher_SessionsExpired(t *testing.T) {
+	rc := newReceiver(t, 0)
+	st, _ := setupHooks(t, &models.SessionWebhook{ID: "hook-1", URL: rc.server.URL, Secret: "whsec_test"})
+	d := NewDispatcher(st, time.Second)
+
+	now := time.Now().UTC()
+	d.SessionsExpired([]*models.Session{
+		{AgentID: "agent-1", SessionTopic: "reported", EndReason: models.EndReasonAgentReported, ExpiredAt: &now},
+		{AgentID: "agent-1", SessionTopic: "silent", EndReason: models.EndReasonTTLExpired, ExpiredAt: &now},
+		{AgentID: "missing", SessionTopic: "orphan", EndReason: models.EndReasonTTLExpired, ExpiredAt: &now},
+	})
+	if err := d.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	got := rc.deliveries()
+	if len(got) != 1 || got[0].event.Session.SessionTopic != "silent" || got[0].event.Outcome != models.SessionOutcomeExpired || got[0].event.FinalStatus != nil {
+		t.Errorf("receiver got %+v, want only the silent session, expired", got)
+	}
+}
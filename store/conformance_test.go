@@ -0,0 +1,14 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/kubeagents/kubeagents/store"
+	"github.com/kubeagents/kubeagents/store/storetest"
+)
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return store.NewMemoryStore()
+	})
+}
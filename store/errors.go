@@ -1,9 +1,138 @@
 package store
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrorKind classifies store errors for callers that only need to know what went wrong, such as
+// handlers choosing an HTTP status code
+type ErrorKind int
+
+const (
+	// KindUnknown is the kind of errors outside the taxonomy, e.g. unexpected database errors
+	KindUnknown ErrorKind = iota
+	// KindNotFound means the record does not exist
+	KindNotFound
+	// KindConflict means the write clashes with stored data: a duplicate or a stale version
+	KindConflict
+	// KindInvalid means the record was rejected before it was stored
+	KindInvalid
+	// KindUnavailable means the store could not be reached or did not answer in time
+	KindUnavailable
+)
+
+// String returns the name of the kind
+func (k ErrorKind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	case KindInvalid:
+		return "invalid"
+	case KindUnavailable:
+		return "unavailable"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is a store error of a known kind, optionally wrapping its cause
+// The sentinels below are Errors; stores wrap causes with a kind, e.g. validation errors as KindInvalid.
+type Error struct {
+	Kind ErrorKind
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Err == nil:
+		return e.Msg
+	case e.Msg == "":
+		return e.Err.Error()
+	default:
+		return e.Msg + ": " + e.Err.Error()
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the kind sentinels ErrNotFound, ErrInvalid and ErrUnavailable against every error of their kind
+// Conflicts have several distinct sentinels, so callers match one of them or use KindOf.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound, ErrInvalid, ErrUnavailable:
+		return target.(*Error).Kind == e.Kind
+	}
+	return false
+}
 
 // ErrNotFound represents a not found error
-var ErrNotFound = errors.New("not found")
+var ErrNotFound = &Error{Kind: KindNotFound, Msg: "not found"}
 
 // ErrDuplicateEmail represents a duplicate email error
-var ErrDuplicateEmail = errors.New("email already exists")
+var ErrDuplicateEmail = &Error{Kind: KindConflict, Msg: "email already exists"}
+
+// ErrAlreadyExists represents an attempt to record something that is already stored
+var ErrAlreadyExists = &Error{Kind: KindConflict, Msg: "already exists"}
+
+// ErrConflict represents a write based on a stale version of a record
+var ErrConflict = &Error{Kind: KindConflict, Msg: "version conflict"}
+
+// ErrAgentDeleted represents a write to a soft-deleted agent, which must be restored first
+var ErrAgentDeleted = &Error{Kind: KindConflict, Msg: "agent is deleted"}
+
+// ErrInvalid matches every record the store rejected as invalid
+var ErrInvalid = &Error{Kind: KindInvalid, Msg: "invalid"}
+
+// ErrUnavailable matches every failure to reach the store
+var ErrUnavailable = &Error{Kind: KindUnavailable, Msg: "store unavailable"}
+
+// invalid wraps a validation error as KindInvalid, keeping its message
+func invalid(err error) error {
+	return &Error{Kind: KindInvalid, Err: err}
+}
+
+// KindOf returns the kind of err
+// Errors outside the taxonomy are classified by their cause: timeouts and lost database connections are
+// KindUnavailable, everything else KindUnknown.
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return KindUnknown
+	}
+	var storeErr *Error
+	if errors.As(err, &storeErr) {
+		return storeErr.Kind
+	}
+	if isUnavailableError(err) {
+		return KindUnavailable
+	}
+	return KindUnknown
+}
+
+// isUnavailableError reports whether err means the database could not be reached or did not answer in time
+func isUnavailableError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return true
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P01", "57P02", "57P03": // Shutdowns and startups
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08") // Connection exceptions
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/kubeagents/kubeagents/models"
+)
+
+func TestErrors_KindsAndWrapping(t *testing.T) {
+	wrappedNotFound := fmt.Errorf("failed to load agent: %w", ErrNotFound)
+	tests := []struct {
+		name string
+		err  error
+		kind ErrorKind
+		is   []error
+		not  []error
+	}{
+		{"not found", wrappedNotFound, KindNotFound, []error{ErrNotFound}, []error{ErrConflict, ErrInvalid}},
+		{"not found with cause", &Error{Kind: KindNotFound, Err: errors.New("no rows")}, KindNotFound, []error{ErrNotFound}, nil},
+		{"version conflict", fmt.Errorf("save: %w", ErrConflict), KindConflict, []error{ErrConflict}, []error{ErrAlreadyExists, ErrNotFound}},
+		{"duplicate", ErrAlreadyExists, KindConflict, []error{ErrAlreadyExists}, []error{ErrConflict, ErrDuplicateEmail}},
+		{"invalid", invalid(errors.New("agent_id is required")), KindInvalid, []error{ErrInvalid}, []error{ErrNotFound}},
+		{"timeout", fmt.Errorf("query: %w", context.DeadlineExceeded), KindUnavailable, nil, []error{ErrNotFound}},
+		{"connection lost", &pgconn.PgError{Code: "08006"}, KindUnavailable, nil, nil},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, KindUnavailable, nil, nil},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, KindUnknown, nil, nil},
+		{"other", errors.New("boom"), KindUnknown, nil, []error{ErrNotFound, ErrInvalid, ErrUnavailable}},
+	}
+	for _, tt := range tests {
+		if got := KindOf(tt.err); got != tt.kind {
+			t.Errorf("%s: KindOf() = %v, want %v", tt.name, got, tt.kind)
+		}
+		for _, target := range tt.is {
+			if !errors.Is(tt.err, target) {
+				t.Errorf("%s: errors.Is(%v) = false, want true", tt.name, target)
+			}
+		}
+		for _, target := range tt.not {
+			if errors.Is(tt.err, target) {
+				t.Errorf("%s: errors.Is(%v) = true, want false", tt.name, target)
+			}
+		}
+	}
+
+	var storeErr *Error
+	if !errors.As(wrappedNotFound, &storeErr) || storeErr != ErrNotFound {
+		t.Errorf("errors.As() = %v, want ErrNotFound", storeErr)
+	}
+	if got := invalid(errors.New("agent_id is required")).Error(); got != "agent_id is required" {
+		t.Errorf("invalid().Error() = %q, want the validation message", got)
+	}
+}
+
+func TestMemoryStore_ValidationErrorsAreInvalid(t *testing.T) {
+	s := NewMemoryStore()
+	err := s.CreateOrUpdateAgent(&models.Agent{})
+	if KindOf(err) != KindInvalid || !errors.Is(err, ErrInvalid) {
+		t.Errorf("CreateOrUpdateAgent(empty) error = %v (%v), want an invalid error", err, KindOf(err))
+	}
+}
@@ -1,9 +1,14 @@
 package store
 
-import "github.com/kubeagents/kubeagents/models"
+import (
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
 
 // Store defines the interface for data storage implementations
-// Different storage backends (memory, postgres, etc.) can implement this interface
+// Different storage backends (memory, postgres, etc.) can implement this interface;
+// store/storetest holds the conformance suite every implementation must pass.
 type Store interface {
 	// User operations
 	CreateUser(user *models.User) error
@@ -11,11 +16,26 @@ type Store interface {
 	GetUserByEmail(email string) (*models.User, error)
 	GetUserByVerifyToken(token string) (*models.User, error)
 	UpdateUser(user *models.User) error
+	// DeleteUser removes a user with their agents, sessions, statuses and every other record they own,
+	// returning ErrNotFound if the user is missing
+	DeleteUser(userID string) error
+	// ListUsers returns every user, oldest first
+	ListUsers() ([]*models.User, error)
+	// ListUsersPage returns one page of ListUsers and how many users there are
+	ListUsersPage(page Page) ([]*models.User, int, error)
+
+	// Data key operations
+	// GetUserDataKey returns a user's wrapped data key, or ErrNotFound if the user has none yet
+	GetUserDataKey(userID string) ([]byte, error)
+	// SetUserDataKey stores the wrapped key unless the user already has one and returns the stored key,
+	// so replicas racing to create a key agree on one
+	SetUserDataKey(userID string, wrappedKey []byte) ([]byte, error)
 
 	// Refresh token operations
 	SaveRefreshToken(token *models.RefreshToken) error
 	GetRefreshTokenByID(tokenID string) (*models.RefreshToken, error)
 	GetRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	// RevokeRefreshToken returns ErrNotFound if the token is missing or already revoked
 	RevokeRefreshToken(tokenID string) error
 	RevokeAllUserTokens(userID string) error
 
@@ -23,28 +43,268 @@ type Store interface {
 	CreateAPIKey(apiKey *models.APIKey) error
 	GetAPIKeyByHash(keyHash string) (*models.APIKey, error)
 	GetAPIKeyByID(keyID string) (*models.APIKey, error)
+	// ListAPIKeysByUser returns a user's keys newest first
 	ListAPIKeysByUser(userID string) ([]*models.APIKey, error)
 	RevokeAPIKey(keyID string) error
 	UpdateAPIKeyLastUsed(keyID string) error
 
+	// Enrollment token operations
+	CreateEnrollmentToken(token *models.EnrollmentToken) error
+	GetEnrollmentTokenByHash(tokenHash string) (*models.EnrollmentToken, error)
+	// ListEnrollmentTokensByUser returns a user's enrollment tokens newest first
+	ListEnrollmentTokensByUser(userID string) ([]*models.EnrollmentToken, error)
+	// RedeemEnrollmentToken marks the token used by agentToken.AgentID and creates agentToken in one step
+	// It returns ErrConflict if the token was already used or expired by agentToken.CreatedAt.
+	RedeemEnrollmentToken(tokenID string, agentToken *models.APIKey) error
+	// DeleteEnrollmentToken returns ErrNotFound unless the token belongs to the user
+	DeleteEnrollmentToken(userID, tokenID string) error
+
+	// Client certificate operations
+	// CreateClientCertificate returns ErrAlreadyExists if the fingerprint is already registered
+	CreateClientCertificate(cert *models.ClientCertificate) error
+	GetClientCertificateByFingerprint(fingerprint string) (*models.ClientCertificate, error)
+	// ListClientCertificatesByUser returns a user's certificates oldest first
+	ListClientCertificatesByUser(userID string) ([]*models.ClientCertificate, error)
+	// DeleteClientCertificate returns ErrNotFound unless the certificate belongs to the user
+	DeleteClientCertificate(userID, certID string) error
+
+	// Organization operations
+	// CreateOrganization creates an organization together with the membership of its first owner, if owner is not nil
+	CreateOrganization(org *models.Organization, owner *models.Membership) error
+	GetOrganization(orgID string) (*models.Organization, error)
+	// ListOrganizations returns every organization oldest first
+	ListOrganizations() ([]*models.Organization, error)
+	// DeleteOrganization removes an organization with its memberships and invitations and unshares its agents
+	DeleteOrganization(orgID string) error
+	// SaveMembership creates or replaces a membership, returning ErrNotFound unless the organization exists
+	SaveMembership(membership *models.Membership) error
+	// GetMembership returns ErrNotFound unless the user is a member of the organization
+	GetMembership(orgID, userID string) (*models.Membership, error)
+	// ListMemberships and ListMembershipsByUser return memberships oldest first
+	ListMemberships(orgID string) ([]*models.Membership, error)
+	ListMembershipsByUser(userID string) ([]*models.Membership, error)
+	// DeleteMembership returns ErrNotFound unless the user is a member of the organization
+	DeleteMembership(orgID, userID string) error
+	// CreateInvitation returns ErrNotFound unless the organization exists
+	CreateInvitation(invitation *models.Invitation) error
+	GetInvitationByHash(tokenHash string) (*models.Invitation, error)
+	// ListInvitations returns an organization's pending invitations newest first
+	ListInvitations(orgID string) ([]*models.Invitation, error)
+	// AcceptInvitation deletes the invitation and saves membership in one step, returning ErrNotFound
+	// if the invitation is gone, e.g. because it was accepted concurrently
+	AcceptInvitation(invitationID string, membership *models.Membership) error
+	// DeleteInvitation returns ErrNotFound unless the invitation belongs to the organization
+	DeleteInvitation(orgID, invitationID string) error
+
 	// Agent operations
+	// CreateOrUpdateAgent returns ErrConflict unless agent.Version matches the stored version,
+	// and sets agent.Version to the new version on success
+	// Writing a soft-deleted agent returns ErrAgentDeleted unless agent.DeletedAt is set.
 	CreateOrUpdateAgent(agent *models.Agent) error
+	// GetAgent and the agent lists leave out soft-deleted agents
 	GetAgent(agentID string) (*models.Agent, error)
+	// ListAgents and ListAgentsByUser return agents most recently seen first
 	ListAgents() []*models.Agent
 	ListAgentsByUser(userID string) []*models.Agent
+	// CountAgentsByUsers returns how many agents each of the users has; users without agents are left out
+	CountAgentsByUsers(userIDs []string) (map[string]int, error)
+	// QueryAgents returns the query's page of the agents it selects, most recently seen first, and how many
+	// it selects; an invalid query returns a KindInvalid error
+	QueryAgents(q Query) ([]*models.Agent, int, error)
+	// ListAgentsByOrg returns the organization's agents most recently seen first
+	ListAgentsByOrg(orgID string) ([]*models.Agent, error)
+	// DeleteAgent soft-deletes an agent, hiding it with its sessions and statuses; it returns ErrNotFound
+	// if the agent does not exist or is already deleted
+	DeleteAgent(agentID string, at time.Time) error
+	// RestoreAgent undoes DeleteAgent and returns ErrNotFound unless the agent is soft-deleted
+	RestoreAgent(agentID string) error
+	// ListDeletedAgents returns the user's soft-deleted agents, or every user's for an empty userID,
+	// most recently deleted first
+	ListDeletedAgents(userID string) ([]*models.Agent, error)
+	// PurgeDeletedAgents removes agents soft-deleted before the cutoff with their sessions, statuses
+	// and watch items, and returns how many agents it removed
+	PurgeDeletedAgents(before time.Time) (int, error)
 
 	// Session operations
+	// CreateOrUpdateSession has the same version precondition as CreateOrUpdateAgent
 	CreateOrUpdateSession(session *models.Session) error
 	GetSession(agentID, sessionTopic string) (*models.Session, error)
+	// ListSessions returns an agent's sessions most recently updated first
 	ListSessions(agentID string, includeExpired bool) []*models.Session
+	// QuerySessions returns the query's page of the sessions it selects, most recently updated first, and how
+	// many it selects; an invalid query returns a KindInvalid error
+	QuerySessions(q Query) ([]*models.Session, int, error)
+	// ListRunningSessions returns the user's unexpired sessions whose latest status is running,
+	// longest running first
+	ListRunningSessions(userID string) ([]*models.RunningSession, error)
+	// GetOverview returns the counts of the user's undeleted agents and their sessions and statuses, with up to
+	// failureLimit of the failed statuses reported since since, newest first; failureLimit <= 0 returns all of them
+	GetOverview(userID string, since time.Time, failureLimit int) (*models.Overview, error)
 
 	// Status operations
+	// AddStatus sets status.ID to the ID the store assigned
 	AddStatus(status *models.AgentStatus) error
+	// GetStatusHistory returns a session's statuses newest first
 	GetStatusHistory(agentID, sessionTopic string) ([]*models.AgentStatus, error)
+	// GetStatusHistoryPage returns one page of GetStatusHistory and how many statuses the session has
+	GetStatusHistoryPage(agentID, sessionTopic string, page Page) ([]*models.AgentStatus, int, error)
 	GetLatestStatus(agentID, sessionTopic string) (*models.AgentStatus, error)
+	// GetRunProgress returns the latest status the agent reported in one run (revision) of a session, or nil if it
+	// reported none, and when the run's earliest running status was recorded, zero if it has none
+	GetRunProgress(agentID, sessionTopic string, revision int) (*models.AgentStatus, time.Time, error)
+	// ListStatusesBetween returns the statuses of every session reported in [from, to), oldest first;
+	// limit <= 0 returns all of them
+	ListStatusesBetween(from, to time.Time, limit int) ([]*models.AgentStatus, error)
+	// PruneStatuses removes up to limit statuses reported before the cutoff, oldest first, with their annotations,
+	// and returns how many it removed; each session keeps its latest status. limit <= 0 removes all of them
+	PruneStatuses(before time.Time, limit int) (int, error)
+
+	// Status annotation operations
+	// CreateStatusAnnotation returns ErrNotFound unless the status belongs to the annotation's session
+	CreateStatusAnnotation(annotation *models.StatusAnnotation) error
+	// ListStatusAnnotations returns the annotations of a session's statuses oldest first
+	ListStatusAnnotations(agentID, sessionTopic string) ([]*models.StatusAnnotation, error)
+
+	// Outbox operations
+	// AddStatusWithOutbox adds a status and records its side effects in one transaction,
+	// setting each message's ID
+	AddStatusWithOutbox(status *models.AgentStatus, messages []*models.OutboxMessage) error
+	// AddOutboxMessage records a message on its own, setting its ID
+	AddOutboxMessage(message *models.OutboxMessage) error
+	// ClaimOutboxMessages returns up to limit messages available at now, oldest first,
+	// counting an attempt and hiding them from other claims until now+lease
+	ClaimOutboxMessages(now time.Time, lease time.Duration, limit int) ([]*models.OutboxMessage, error)
+	// DeleteOutboxMessage and RetryOutboxMessage return ErrNotFound if the message is gone
+	DeleteOutboxMessage(id int64) error
+	RetryOutboxMessage(id int64, availableAt time.Time, lastError string) error
+
+	// SLA operations
+	CreateSLA(sla *models.SLA) error
+	GetSLA(slaID string) (*models.SLA, error)
+	// ListSLAs and ListSLAsByUser return SLAs oldest first
+	ListSLAs() ([]*models.SLA, error)
+	ListSLAsByUser(userID string) ([]*models.SLA, error)
+	UpdateSLA(sla *models.SLA) error
+	DeleteSLA(slaID string) error
+
+	// Alert rule operations
+	CreateAlertRule(rule *models.AlertRule) error
+	GetAlertRule(ruleID string) (*models.AlertRule, error)
+	// ListAlertRules and ListAlertRulesByUser return alert rules oldest first
+	ListAlertRules() ([]*models.AlertRule, error)
+	ListAlertRulesByUser(userID string) ([]*models.AlertRule, error)
+	UpdateAlertRule(rule *models.AlertRule) error
+	DeleteAlertRule(ruleID string) error
+
+	// SLA breach operations
+	// CreateSLABreach returns ErrAlreadyExists if the same SLA, kind, agent and subject was already recorded
+	CreateSLABreach(breach *models.SLABreach) error
+	// ListSLABreaches returns breaches detected at or after since, newest first
+	ListSLABreaches(slaID string, since time.Time) ([]*models.SLABreach, error)
+
+	// Session webhook operations
+	CreateSessionWebhook(hook *models.SessionWebhook) error
+	GetSessionWebhook(id string) (*models.SessionWebhook, error)
+	// ListSessionWebhooksByUser returns a user's webhooks oldest first
+	ListSessionWebhooksByUser(userID string) ([]*models.SessionWebhook, error)
+	UpdateSessionWebhook(hook *models.SessionWebhook) error
+	DeleteSessionWebhook(id string) error
+
+	// Watchlist operations
+	// SaveWatchItem creates or replaces the item for its user, agent and session topic
+	SaveWatchItem(item *models.WatchItem) error
+	GetWatchItem(userID, agentID, sessionTopic string) (*models.WatchItem, error)
+	// ListWatchItems returns a user's items oldest first
+	ListWatchItems(userID string) ([]*models.WatchItem, error)
+	DeleteWatchItem(userID, agentID, sessionTopic string) error
+
+	// Notification settings operations
+	// SaveNotificationSettings creates or replaces a user's settings
+	SaveNotificationSettings(settings *models.NotificationSettings) error
+	GetNotificationSettings(userID string) (*models.NotificationSettings, error)
+	DeleteNotificationSettings(userID string) error
+
+	// Inbox operations
+	// CreateInboxItem returns ErrAlreadyExists if the user already has an item with the same dedupe key
+	CreateInboxItem(item *models.InboxItem) error
+	// ListInboxItems returns a user's items newest first; limit <= 0 returns all of them
+	ListInboxItems(userID string, unreadOnly bool, limit int) ([]*models.InboxItem, error)
+	// ListInboxItemsPage returns one page of ListInboxItems and how many items it lists in total
+	ListInboxItemsPage(userID string, unreadOnly bool, page Page) ([]*models.InboxItem, int, error)
+	CountUnreadInboxItems(userID string) (int, error)
+	// MarkInboxItemRead returns ErrNotFound unless the item belongs to the user
+	MarkInboxItemRead(userID, itemID string) error
+	MarkAllInboxItemsRead(userID string) (int, error)
+
+	// Usage metering operations
+	// AddUsage adds the counts of usage to the record of its user and day, creating the record when missing
+	AddUsage(usage *models.UsageRecord) error
+	// ListUsage returns the records of a user, or of every user when userID is empty, for days in [from, to),
+	// ordered by day and then user; from and to are truncated to their UTC day
+	ListUsage(userID string, from, to time.Time) ([]*models.UsageRecord, error)
+
+	// Tenant count operations
+	// AddTenantCounts adds the counts of delta to its user's counts, creating them when missing; it returns
+	// ErrNotFound when the user does not exist
+	AddTenantCounts(delta *models.TenantCounts) error
+	// GetTenantCounts returns ErrNotFound until counts are first added or reconciled for the user
+	GetTenantCounts(userID string) (*models.TenantCounts, error)
+	// ReconcileTenantCounts replaces the counts of every user with how many agents, sessions and statuses they
+	// have stored, marking them reconciled at now, and returns how many users it reconciled
+	ReconcileTenantCounts(now time.Time) (int, error)
+
+	// Status rollup operations
+	// RollupStatuses creates or replaces the rollup of every session with statuses on the UTC days in [from, to)
+	// and returns how many rollups it wrote; from and to are truncated to their UTC day
+	RollupStatuses(from, to time.Time) (int, error)
+	// SaveStatusRollup creates or replaces the rollup of its session and day, returning ErrNotFound if the session is missing
+	SaveStatusRollup(rollup *models.StatusRollup) error
+	// ListStatusRollups returns the rollups of an agent, or of every agent when agentID is empty, for days in [from, to),
+	// ordered by day, agent and session topic; an empty sessionTopic selects every session
+	ListStatusRollups(agentID, sessionTopic string, from, to time.Time) ([]*models.StatusRollup, error)
+
+	// Audit log operations
+	// AddAuditEvent sets the event's ID; ListAuditEvents returns events created at or after since,
+	// newest first, limit <= 0 returning all of them
+	AddAuditEvent(event *models.AuditEvent) error
+	ListAuditEvents(since time.Time, limit int) ([]*models.AuditEvent, error)
+	// PurgeAuditEvents removes events created before the cutoff
+	PurgeAuditEvents(before time.Time) (int, error)
+
+	// Webhook nonce operations
+	// SaveNonce returns ErrAlreadyExists if the nonce was already seen in scope and has not expired
+	SaveNonce(scope, nonce string, expiresAt time.Time) error
+
+	// Notification mark operations
+	// ClaimNotificationMark stores the mark unless the mark of the same user, agent, session and status was
+	// notified after cutoff, in which case it returns ErrAlreadyExists; ListNotificationMarks returns the marks
+	// of a status, and PurgeNotificationMarks removes those notified before a cutoff.
+	ClaimNotificationMark(mark *models.NotificationMark, cutoff time.Time) error
+	ListNotificationMarks(status string) ([]*models.NotificationMark, error)
+	PurgeNotificationMarks(before time.Time) (int, error)
+
+	// Idempotency key operations
+	// ClaimIdempotencyKey records a key whose report is being processed; when the user's key is held by an
+	// unexpired record it returns a copy of that record and ErrAlreadyExists. CompleteIdempotencyKey stores the
+	// response of a claimed key, returning ErrNotFound if it is not claimed, and ReleaseIdempotencyKey removes
+	// a key so its report can be retried.
+	ClaimIdempotencyKey(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(userID, key string, statusCode int, response []byte, expiresAt time.Time) error
+	ReleaseIdempotencyKey(userID, key string) error
 
 	// Maintenance
-	CheckExpiredSessions()
+	// CheckExpiredSessions returns the sessions it marked expired;
+	// purge operations return the number of records removed
+	CheckExpiredSessions() []*models.Session
+	PurgeExpiredNonces() (int, error)
+	PurgeExpiredIdempotencyKeys() (int, error)
+	PurgeExpiredRefreshTokens() (int, error)
+	ClearExpiredVerifyTokens() (int, error)
+	PurgeSLABreaches(before time.Time) (int, error)
+	// CheckIntegrity returns the inconsistencies between stored records, ordered by kind, agent and topic;
+	// with repair it deletes orphaned sessions and statuses and corrects expired flags, marking those issues
+	// repaired. Ownerless agents are only reported.
+	CheckIntegrity(repair bool) ([]*models.IntegrityIssue, error)
 
 	// System config operations
 	GetConfig(key string) (string, error)
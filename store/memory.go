@@ -1,51 +1,124 @@
 package store
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/kubeagents/kubeagents/clock"
 	"github.com/kubeagents/kubeagents/models"
 )
 
 // MemoryStore is a thread-safe in-memory store for agents, sessions, and statuses
 type MemoryStore struct {
-	mu            sync.RWMutex
-	agents        map[string]*models.Agent
-	sessions      map[string]map[string]*models.Session       // agent_id -> session_topic
-	statuses      map[string]map[string][]*models.AgentStatus // agent_id -> session_topic -> history
-	users         map[string]*models.User                     // user_id -> user
-	usersByEmail  map[string]*models.User                     // email -> user
-	refreshTokens map[string]*models.RefreshToken             // token_hash -> token
-	apiKeys       map[string]*models.APIKey                   // key_id -> api_key
-	apiKeysByHash map[string]*models.APIKey                   // key_hash -> api_key
-	config        map[string]string                           // key -> value
+	mu             sync.RWMutex
+	agents         map[string]*models.Agent
+	sessions       map[string]map[string]*models.Session       // agent_id -> session_topic
+	statuses       map[string]map[string][]*models.AgentStatus // agent_id -> session_topic -> history
+	users          map[string]*models.User                     // user_id -> user
+	usersByEmail   map[string]*models.User                     // email -> user
+	refreshTokens  map[string]*models.RefreshToken             // id -> token
+	apiKeys        map[string]*models.APIKey                   // key_id -> api_key
+	apiKeysByHash  map[string]*models.APIKey                   // key_hash -> api_key
+	clientCerts    map[string]*models.ClientCertificate        // fingerprint -> certificate
+	enrollments    map[string]*models.EnrollmentToken          // token_id -> token
+	orgs           map[string]*models.Organization             // org_id -> organization
+	memberships    map[string]*models.Membership               // org_id|user_id -> membership
+	invitations    map[string]*models.Invitation               // invitation_id -> invitation
+	config         map[string]string                           // key -> value
+	slas           map[string]*models.SLA                      // sla_id -> sla
+	slaBreaches    map[string]*models.SLABreach                // breach key -> breach
+	alertRules     map[string]*models.AlertRule                // rule_id -> rule
+	marks          map[string]*models.NotificationMark         // mark key -> mark
+	sessionHooks   map[string]*models.SessionWebhook           // webhook_id -> webhook
+	watchItems     map[string]*models.WatchItem                // user_id|agent_id|session_topic -> item
+	notifySettings map[string]*models.NotificationSettings     // user_id -> settings
+	inboxItems     map[string]*models.InboxItem                // item_id -> item
+	nonces         map[string]time.Time                        // scope|nonce -> expires_at
+	idempotency    map[string]*models.IdempotencyRecord        // user_id|key -> record
+	outbox         map[int64]*models.OutboxMessage             // id -> message
+	dataKeys       map[string][]byte                           // user_id -> wrapped data key
+	annotations    map[string]*models.StatusAnnotation         // annotation_id -> annotation
+	usage          map[string]*models.UsageRecord              // user_id|day -> record
+	tenantCounts   map[string]*models.TenantCounts             // user_id -> counts
+	rollups        map[string]*models.StatusRollup             // agent_id|session_topic|day -> rollup
+	auditEvents    []*models.AuditEvent                        // oldest first
+	nextOutboxID   int64
+	nextAuditID    int64
+	nextStatusID   int64
+	clock          clock.Clock // Decides expiry of sessions, tokens and nonces
 }
 
 // NewMemoryStore creates a new memory store
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		agents:        make(map[string]*models.Agent),
-		sessions:      make(map[string]map[string]*models.Session),
-		statuses:      make(map[string]map[string][]*models.AgentStatus),
-		users:         make(map[string]*models.User),
-		usersByEmail:  make(map[string]*models.User),
-		refreshTokens: make(map[string]*models.RefreshToken),
-		apiKeys:       make(map[string]*models.APIKey),
-		apiKeysByHash: make(map[string]*models.APIKey),
-		config:        make(map[string]string),
+		agents:         make(map[string]*models.Agent),
+		sessions:       make(map[string]map[string]*models.Session),
+		statuses:       make(map[string]map[string][]*models.AgentStatus),
+		users:          make(map[string]*models.User),
+		usersByEmail:   make(map[string]*models.User),
+		refreshTokens:  make(map[string]*models.RefreshToken),
+		apiKeys:        make(map[string]*models.APIKey),
+		apiKeysByHash:  make(map[string]*models.APIKey),
+		clientCerts:    make(map[string]*models.ClientCertificate),
+		enrollments:    make(map[string]*models.EnrollmentToken),
+		orgs:           make(map[string]*models.Organization),
+		memberships:    make(map[string]*models.Membership),
+		invitations:    make(map[string]*models.Invitation),
+		config:         make(map[string]string),
+		slas:           make(map[string]*models.SLA),
+		slaBreaches:    make(map[string]*models.SLABreach),
+		alertRules:     make(map[string]*models.AlertRule),
+		marks:          make(map[string]*models.NotificationMark),
+		sessionHooks:   make(map[string]*models.SessionWebhook),
+		watchItems:     make(map[string]*models.WatchItem),
+		notifySettings: make(map[string]*models.NotificationSettings),
+		inboxItems:     make(map[string]*models.InboxItem),
+		nonces:         make(map[string]time.Time),
+		idempotency:    make(map[string]*models.IdempotencyRecord),
+		outbox:         make(map[int64]*models.OutboxMessage),
+		dataKeys:       make(map[string][]byte),
+		annotations:    make(map[string]*models.StatusAnnotation),
+		usage:          make(map[string]*models.UsageRecord),
+		tenantCounts:   make(map[string]*models.TenantCounts),
+		rollups:        make(map[string]*models.StatusRollup),
+		clock:          clock.Real,
 	}
 }
 
+// SetClock replaces the clock that decides expiry, e.g. with a fake one in tests
+func (s *MemoryStore) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = c
+}
+
 // CreateOrUpdateAgent creates or updates an agent
 func (s *MemoryStore) CreateOrUpdateAgent(agent *models.Agent) error {
 	if err := agent.Validate(); err != nil {
-		return err
+		return invalid(err)
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.agents[agent.AgentID] = agent
+	version := 1
+	if existing, exists := s.agents[agent.AgentID]; exists {
+		if existing.DeletedAt != nil && agent.DeletedAt == nil {
+			return ErrAgentDeleted
+		}
+		if existing.Version != agent.Version {
+			return ErrConflict
+		}
+		version = existing.Version + 1
+	}
+
+	// Store a copy so callers cannot change stored state without a versioned write
+	stored := *agent
+	stored.Version = version
+	s.agents[agent.AgentID] = &stored
+	agent.Version = version
 	return nil
 }
 
@@ -55,28 +128,44 @@ func (s *MemoryStore) GetAgent(agentID string) (*models.Agent, error) {
 	defer s.mu.RUnlock()
 
 	agent, exists := s.agents[agentID]
-	if !exists {
+	if !exists || agent.DeletedAt != nil {
 		return nil, ErrNotFound
 	}
-	return agent, nil
+	copied := *agent
+	return &copied, nil
 }
 
-// ListAgents returns all agents
+// ListAgents returns all agents, most recently seen first
 func (s *MemoryStore) ListAgents() []*models.Agent {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	agents := make([]*models.Agent, 0, len(s.agents))
 	for _, agent := range s.agents {
-		agents = append(agents, agent)
+		if agent.DeletedAt != nil {
+			continue
+		}
+		copied := *agent
+		agents = append(agents, &copied)
 	}
+	sortAgentsByLastSeen(agents)
 	return agents
 }
 
+// sortAgentsByLastSeen orders agents most recently seen first
+func sortAgentsByLastSeen(agents []*models.Agent) {
+	sort.Slice(agents, func(i, j int) bool {
+		if !agents[i].LastSeen.Equal(agents[j].LastSeen) {
+			return agents[i].LastSeen.After(agents[j].LastSeen)
+		}
+		return agents[i].AgentID < agents[j].AgentID
+	})
+}
+
 // CreateOrUpdateSession creates or updates a session
 func (s *MemoryStore) CreateOrUpdateSession(session *models.Session) error {
 	if err := session.Validate(); err != nil {
-		return err
+		return invalid(err)
 	}
 
 	s.mu.Lock()
@@ -92,7 +181,18 @@ func (s *MemoryStore) CreateOrUpdateSession(session *models.Session) error {
 		s.sessions[session.AgentID] = make(map[string]*models.Session)
 	}
 
-	s.sessions[session.AgentID][session.SessionTopic] = session
+	version := 1
+	if existing, exists := s.sessions[session.AgentID][session.SessionTopic]; exists {
+		if existing.Version != session.Version {
+			return ErrConflict
+		}
+		version = existing.Version + 1
+	}
+
+	stored := *session
+	stored.Version = version
+	s.sessions[session.AgentID][session.SessionTopic] = &stored
+	session.Version = version
 	return nil
 }
 
@@ -110,10 +210,11 @@ func (s *MemoryStore) GetSession(agentID, sessionTopic string) (*models.Session,
 	if !exists {
 		return nil, ErrNotFound
 	}
-	return session, nil
+	copied := *session
+	return &copied, nil
 }
 
-// ListSessions returns all sessions for an agent
+// ListSessions returns all sessions for an agent, most recently updated first
 func (s *MemoryStore) ListSessions(agentID string, includeExpired bool) []*models.Session {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -126,21 +227,176 @@ func (s *MemoryStore) ListSessions(agentID string, includeExpired bool) []*model
 	result := make([]*models.Session, 0)
 	for _, session := range sessions {
 		if includeExpired || !session.Expired {
-			result = append(result, session)
+			copied := *session
+			result = append(result, &copied)
 		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastUpdated.Equal(result[j].LastUpdated) {
+			return result[i].LastUpdated.After(result[j].LastUpdated)
+		}
+		return result[i].SessionTopic < result[j].SessionTopic
+	})
 	return result
 }
 
+// QuerySessions returns one page of the sessions a query selects and how many it selects
+func (s *MemoryStore) QuerySessions(q Query) ([]*models.Session, int, error) {
+	if err := q.validateSessions(); err != nil {
+		return nil, 0, invalid(err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*models.Session, 0)
+	for topic, session := range s.sessions[q.AgentID] {
+		if !q.matchesSession(session) {
+			continue
+		}
+		if q.Status != "" {
+			if latest := s.latestStatusLocked(q.AgentID, topic); latest == nil || latest.Status != q.Status {
+				continue
+			}
+		}
+		copied := *session
+		result = append(result, &copied)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].LastUpdated.Equal(result[j].LastUpdated) {
+			return result[i].LastUpdated.After(result[j].LastUpdated)
+		}
+		return result[i].SessionTopic < result[j].SessionTopic
+	})
+	return pageOf(result, q.Page), len(result), nil
+}
+
+// ListRunningSessions returns the user's unexpired sessions whose latest status is running, longest running first
+func (s *MemoryStore) ListRunningSessions(userID string) ([]*models.RunningSession, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*models.RunningSession, 0)
+	for agentID, agent := range s.agents {
+		if agent.UserID != userID || agent.DeletedAt != nil {
+			continue
+		}
+		for topic, session := range s.sessions[agentID] {
+			if session.Expired {
+				continue
+			}
+
+			// Only statuses of the current revision belong to the run in progress
+			var first, latest *models.AgentStatus
+			for _, status := range s.statuses[agentID][topic] {
+				if status.Revision != session.Revision {
+					continue
+				}
+				if first == nil || status.Timestamp.Before(first.Timestamp) {
+					first = status
+				}
+				if latest == nil || status.Timestamp.After(latest.Timestamp) {
+					latest = status
+				}
+			}
+			if latest == nil || latest.Status != "running" {
+				continue
+			}
+
+			copiedSession := *session
+			copiedStatus := *latest
+			result = append(result, &models.RunningSession{
+				Session:   &copiedSession,
+				AgentName: agent.Name,
+				Latest:    &copiedStatus,
+				Started:   first.Timestamp,
+			})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if !a.Started.Equal(b.Started) {
+			return a.Started.Before(b.Started)
+		}
+		if a.Session.AgentID != b.Session.AgentID {
+			return a.Session.AgentID < b.Session.AgentID
+		}
+		return a.Session.SessionTopic < b.Session.SessionTopic
+	})
+	return result, nil
+}
+
+// GetOverview returns the counts of the user's undeleted agents and their sessions and statuses,
+// with up to failureLimit of the failed statuses reported since since, newest first
+func (s *MemoryStore) GetOverview(userID string, since time.Time, failureLimit int) (*models.Overview, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	overview := &models.Overview{Since: since, RecentFailures: make([]*models.RecentFailure, 0)}
+	for agentID, agent := range s.agents {
+		if agent.UserID != userID || agent.DeletedAt != nil {
+			continue
+		}
+		overview.Agents.Total++
+		switch agent.State {
+		case models.AgentStateOnline:
+			overview.Agents.Online++
+		case models.AgentStateStale:
+			overview.Agents.Stale++
+		case models.AgentStateOffline:
+			overview.Agents.Offline++
+		}
+
+		for _, session := range s.sessions[agentID] {
+			if !session.Expired {
+				overview.ActiveSessions++
+			}
+		}
+		for _, statuses := range s.statuses[agentID] {
+			for _, status := range statuses {
+				if status.Timestamp.Before(since) {
+					continue
+				}
+				switch status.Status {
+				case "success":
+					overview.Succeeded++
+				case "failed":
+					overview.Failed++
+					copied := *status
+					overview.RecentFailures = append(overview.RecentFailures, &models.RecentFailure{AgentName: agent.Name, Status: &copied})
+				}
+			}
+		}
+	}
+
+	sort.Slice(overview.RecentFailures, func(i, j int) bool {
+		a, b := overview.RecentFailures[i].Status, overview.RecentFailures[j].Status
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.After(b.Timestamp)
+		}
+		return a.ID > b.ID
+	})
+	if failureLimit > 0 && len(overview.RecentFailures) > failureLimit {
+		overview.RecentFailures = overview.RecentFailures[:failureLimit]
+	}
+	return overview, nil
+}
+
 // AddStatus adds a status record to the history
 func (s *MemoryStore) AddStatus(status *models.AgentStatus) error {
 	if err := status.Validate(); err != nil {
-		return err
+		return invalid(err)
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.addStatusLocked(status)
+}
+
+// addStatusLocked appends a status to its session's history; the caller holds the write lock
+func (s *MemoryStore) addStatusLocked(status *models.AgentStatus) error {
 	// Ensure session exists
 	sessions, exists := s.sessions[status.AgentID]
 	if !exists {
@@ -160,14 +416,20 @@ func (s *MemoryStore) AddStatus(status *models.AgentStatus) error {
 		s.statuses[status.AgentID][status.SessionTopic] = make([]*models.AgentStatus, 0)
 	}
 
+	s.nextStatusID++
+	status.ID = s.nextStatusID
+	if status.Origin == "" {
+		status.Origin = models.StatusOriginAgent
+	}
+	stored := *status
 	s.statuses[status.AgentID][status.SessionTopic] = append(
 		s.statuses[status.AgentID][status.SessionTopic],
-		status,
+		&stored,
 	)
 	return nil
 }
 
-// GetStatusHistory returns all status records for a session
+// GetStatusHistory returns all status records for a session, newest first
 func (s *MemoryStore) GetStatusHistory(agentID, sessionTopic string) ([]*models.AgentStatus, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -181,322 +443,2342 @@ func (s *MemoryStore) GetStatusHistory(agentID, sessionTopic string) ([]*models.
 	if !exists {
 		return []*models.AgentStatus{}, nil
 	}
-	return history, nil
+
+	// Return copies so callers can sort or modify the result without racing writers
+	result := make([]*models.AgentStatus, 0, len(history))
+	for _, status := range history {
+		copied := *status
+		result = append(result, &copied)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.After(result[j].Timestamp)
+	})
+	return result, nil
 }
 
-// GetLatestStatus returns the latest status for a session
-func (s *MemoryStore) GetLatestStatus(agentID, sessionTopic string) (*models.AgentStatus, error) {
+// ListStatusesBetween returns the statuses of every session reported in [from, to), oldest first
+func (s *MemoryStore) ListStatusesBetween(from, to time.Time, limit int) ([]*models.AgentStatus, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	statuses, exists := s.statuses[agentID]
-	if !exists {
-		return nil, ErrNotFound
-	}
-
-	history, exists := statuses[sessionTopic]
-	if !exists || len(history) == 0 {
-		return nil, ErrNotFound
-	}
-
-	// Find latest by timestamp
-	latest := history[0]
-	for _, status := range history[1:] {
-		if status.Timestamp.After(latest.Timestamp) {
-			latest = status
+	result := make([]*models.AgentStatus, 0)
+	for _, topics := range s.statuses {
+		for _, history := range topics {
+			for _, status := range history {
+				if !status.Timestamp.Before(from) && status.Timestamp.Before(to) {
+					copied := *status
+					result = append(result, &copied)
+				}
+			}
 		}
 	}
-
-	// Return a copy to avoid data race on the pointer
-	result := *latest
-	return &result, nil
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Timestamp.Equal(result[j].Timestamp) {
+			return result[i].Timestamp.Before(result[j].Timestamp)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return pageOf(result, Page{Limit: limit}), nil
 }
 
-// CheckExpiredSessions checks and marks expired sessions
-func (s *MemoryStore) CheckExpiredSessions() {
+// PruneStatuses removes up to limit statuses reported before the cutoff, oldest first, keeping each session's latest
+func (s *MemoryStore) PruneStatuses(before time.Time, limit int) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	now := time.Now()
-	for _, sessions := range s.sessions {
-		for _, session := range sessions {
-			if session.Expired {
-				continue
+	var candidates []*models.AgentStatus
+	for _, topics := range s.statuses {
+		for _, history := range topics {
+			var latest time.Time
+			for _, status := range history {
+				if status.Timestamp.After(latest) {
+					latest = status.Timestamp
+				}
 			}
-
-			ttl := session.TTLMinutes
-			if ttl == 0 {
-				ttl = 30 // default 30 minutes
+			for _, status := range history {
+				if status.Timestamp.Before(before) && status.Timestamp.Before(latest) {
+					candidates = append(candidates, status)
+				}
 			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].Timestamp.Equal(candidates[j].Timestamp) {
+			return candidates[i].Timestamp.Before(candidates[j].Timestamp)
+		}
+		return candidates[i].ID < candidates[j].ID
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
 
-			expiryTime := session.LastUpdated.Add(time.Duration(ttl) * time.Minute)
-			if now.After(expiryTime) {
-				session.Expired = true
-				expiredAt := now
-				session.ExpiredAt = &expiredAt
+	pruned := make(map[int64]bool, len(candidates))
+	for _, status := range candidates {
+		pruned[status.ID] = true
+	}
+	for _, topics := range s.statuses {
+		for topic, history := range topics {
+			kept := history[:0]
+			for _, status := range history {
+				if !pruned[status.ID] {
+					kept = append(kept, status)
+				}
 			}
+			topics[topic] = kept
 		}
 	}
-}
-
-// ListAgentsByUser returns all agents belonging to a specific user
-func (s *MemoryStore) ListAgentsByUser(userID string) []*models.Agent {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	agents := make([]*models.Agent, 0)
-	for _, agent := range s.agents {
-		if agent.UserID == userID {
-			agents = append(agents, agent)
+	for id, annotation := range s.annotations {
+		if pruned[annotation.StatusID] {
+			delete(s.annotations, id)
 		}
 	}
-	return agents
+	return len(candidates), nil
 }
 
-// CreateUser creates a new user
-func (s *MemoryStore) CreateUser(user *models.User) error {
-	if err := user.Validate(); err != nil {
-		return err
-	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Check if email already exists
-	if _, exists := s.usersByEmail[user.Email]; exists {
-		return ErrDuplicateEmail
+// GetStatusHistoryPage returns one page of a session's statuses and how many there are
+func (s *MemoryStore) GetStatusHistoryPage(agentID, sessionTopic string, page Page) ([]*models.AgentStatus, int, error) {
+	history, err := s.GetStatusHistory(agentID, sessionTopic)
+	if err != nil {
+		return nil, 0, err
 	}
-
-	s.users[user.ID] = user
-	s.usersByEmail[user.Email] = user
-	return nil
+	return pageOf(history, page), len(history), nil
 }
 
-// GetUserByID retrieves a user by ID
-func (s *MemoryStore) GetUserByID(userID string) (*models.User, error) {
+// GetLatestStatus returns the latest status for a session
+func (s *MemoryStore) GetLatestStatus(agentID, sessionTopic string) (*models.AgentStatus, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	user, exists := s.users[userID]
-	if !exists {
+	latest := s.latestStatusLocked(agentID, sessionTopic)
+	if latest == nil {
 		return nil, ErrNotFound
 	}
-	return user, nil
+
+	// Return a copy to avoid data race on the pointer
+	result := *latest
+	return &result, nil
 }
 
-// GetUserByEmail retrieves a user by email
-func (s *MemoryStore) GetUserByEmail(email string) (*models.User, error) {
+// GetRunProgress returns the latest status an agent reported in a run of a session and when the run started running
+// It scans the stored statuses in place, as it runs for every report.
+func (s *MemoryStore) GetRunProgress(agentID, sessionTopic string, revision int) (*models.AgentStatus, time.Time, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	user, exists := s.usersByEmail[email]
-	if !exists {
-		return nil, ErrNotFound
+	var latest *models.AgentStatus
+	var runningSince time.Time
+	for _, status := range s.statuses[agentID][sessionTopic] {
+		if status.Revision != revision || status.FromServer() {
+			continue
+		}
+		if latest == nil || status.Timestamp.After(latest.Timestamp) {
+			latest = status
+		}
+		if status.Status == "running" && (runningSince.IsZero() || status.Timestamp.Before(runningSince)) {
+			runningSince = status.Timestamp
+		}
 	}
-	return user, nil
+	if latest == nil {
+		return nil, runningSince, nil
+	}
+	result := *latest
+	return &result, runningSince, nil
 }
 
-// GetUserByVerifyToken retrieves a user by verification token
-func (s *MemoryStore) GetUserByVerifyToken(token string) (*models.User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// latestStatusLocked returns the stored latest status of a session, or nil if it has none
+func (s *MemoryStore) latestStatusLocked(agentID, sessionTopic string) *models.AgentStatus {
+	history := s.statuses[agentID][sessionTopic]
+	if len(history) == 0 {
+		return nil
+	}
 
-	for _, user := range s.users {
-		if user.VerifyToken == token {
-			return user, nil
+	// Find latest by timestamp
+	latest := history[0]
+	for _, status := range history[1:] {
+		if status.Timestamp.After(latest.Timestamp) {
+			latest = status
 		}
 	}
-	return nil, ErrNotFound
+	return latest
 }
 
-// UpdateUser updates an existing user
-func (s *MemoryStore) UpdateUser(user *models.User) error {
-	if err := user.Validate(); err != nil {
-		return err
+// CreateStatusAnnotation adds an annotation to a status of the annotation's session
+func (s *MemoryStore) CreateStatusAnnotation(annotation *models.StatusAnnotation) error {
+	if err := annotation.Validate(); err != nil {
+		return invalid(err)
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	existingUser, exists := s.users[user.ID]
-	if !exists {
-		return ErrNotFound
-	}
-
-	// If email changed, update email index
-	if existingUser.Email != user.Email {
-		// Check if new email already exists
-		if _, exists := s.usersByEmail[user.Email]; exists {
-			return ErrDuplicateEmail
+	found := false
+	for _, status := range s.statuses[annotation.AgentID][annotation.SessionTopic] {
+		if status.ID == annotation.StatusID {
+			found = true
+			break
 		}
-		delete(s.usersByEmail, existingUser.Email)
-		s.usersByEmail[user.Email] = user
 	}
-
-	s.users[user.ID] = user
-	return nil
-}
-
-// SaveRefreshToken saves a refresh token
-func (s *MemoryStore) SaveRefreshToken(token *models.RefreshToken) error {
-	if err := token.Validate(); err != nil {
-		return err
+	if !found {
+		return ErrNotFound
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.refreshTokens[token.ID] = token
+	copied := *annotation
+	copied.Links = append([]string(nil), annotation.Links...)
+	s.annotations[annotation.ID] = &copied
 	return nil
 }
 
-// GetRefreshTokenByID retrieves a refresh token by ID
-func (s *MemoryStore) GetRefreshTokenByID(tokenID string) (*models.RefreshToken, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	token, exists := s.refreshTokens[tokenID]
-	if !exists {
-		return nil, ErrNotFound
-	}
-	return token, nil
-}
-
-// GetRefreshToken retrieves a refresh token by hash
-func (s *MemoryStore) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+// ListStatusAnnotations returns the annotations of a session's statuses, oldest first
+func (s *MemoryStore) ListStatusAnnotations(agentID, sessionTopic string) ([]*models.StatusAnnotation, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for _, token := range s.refreshTokens {
-		if token.TokenHash == tokenHash {
-			return token, nil
+	annotations := make([]*models.StatusAnnotation, 0)
+	for _, annotation := range s.annotations {
+		if annotation.AgentID == agentID && annotation.SessionTopic == sessionTopic {
+			copied := *annotation
+			copied.Links = append([]string(nil), annotation.Links...)
+			annotations = append(annotations, &copied)
 		}
 	}
-	return nil, ErrNotFound
+	sort.Slice(annotations, func(i, j int) bool {
+		if !annotations[i].CreatedAt.Equal(annotations[j].CreatedAt) {
+			return annotations[i].CreatedAt.Before(annotations[j].CreatedAt)
+		}
+		return annotations[i].ID < annotations[j].ID
+	})
+	return annotations, nil
 }
 
-// RevokeRefreshToken revokes a refresh token by ID
-func (s *MemoryStore) RevokeRefreshToken(tokenID string) error {
+// CheckExpiredSessions marks sessions past their TTL as expired and returns them
+func (s *MemoryStore) CheckExpiredSessions() []*models.Session {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	token, exists := s.refreshTokens[tokenID]
-	if !exists {
-		return ErrNotFound
+	var expired []*models.Session
+	now := s.clock.Now()
+	for _, sessions := range s.sessions {
+		for _, session := range sessions {
+			if session.Expired {
+				continue
+			}
+
+			if now.After(session.ExpiresAt()) {
+				session.Expired = true
+				expiredAt := now
+				session.ExpiredAt = &expiredAt
+				if session.EndReason == "" {
+					session.EndReason = models.EndReasonTTLExpired
+				}
+				session.Version++
+
+				copied := *session
+				expired = append(expired, &copied)
+			}
+		}
 	}
-	token.Revoked = true
-	return nil
+	return expired
 }
 
-// RevokeAllUserTokens revokes all refresh tokens for a user
-func (s *MemoryStore) RevokeAllUserTokens(userID string) error {
+// CheckIntegrity finds and optionally repairs inconsistencies between stored records
+func (s *MemoryStore) CheckIntegrity(repair bool) ([]*models.IntegrityIssue, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for _, token := range s.refreshTokens {
-		if token.UserID == userID {
-			token.Revoked = true
+	issues := make([]*models.IntegrityIssue, 0)
+	for agentID, sessions := range s.sessions {
+		if _, exists := s.agents[agentID]; exists {
+			continue
+		}
+		for topic := range sessions {
+			issues = append(issues, &models.IntegrityIssue{
+				Kind: models.IntegrityOrphanedSession, AgentID: agentID, SessionTopic: topic,
+				Detail: "agent is missing", Repaired: repair,
+			})
+		}
+		if repair {
+			for topic := range sessions {
+				s.deleteSessionLocked(agentID, topic)
+			}
 		}
 	}
-	return nil
-}
 
-// CreateAPIKey creates a new API key
-func (s *MemoryStore) CreateAPIKey(apiKey *models.APIKey) error {
-	if err := apiKey.Validate(); err != nil {
-		return err
+	for agentID, topics := range s.statuses {
+		for topic, history := range topics {
+			if _, exists := s.sessions[agentID][topic]; exists {
+				continue
+			}
+			issues = append(issues, &models.IntegrityIssue{
+				Kind: models.IntegrityOrphanedStatus, AgentID: agentID, SessionTopic: topic,
+				Detail: fmt.Sprintf("%d statuses without a session", len(history)), Repaired: repair,
+			})
+			if repair {
+				s.deleteSessionLocked(agentID, topic)
+			}
+		}
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	for agentID, agent := range s.agents {
+		detail := ""
+		if agent.UserID == "" {
+			detail = "agent has no owner"
+		} else if _, exists := s.users[agent.UserID]; !exists {
+			detail = "owner " + agent.UserID + " is missing"
+		}
+		if detail != "" {
+			issues = append(issues, &models.IntegrityIssue{Kind: models.IntegrityOwnerlessAgent, AgentID: agentID, Detail: detail})
+		}
+	}
+
+	for agentID, sessions := range s.sessions {
+		for topic, session := range sessions {
+			if session.Expired == (session.ExpiredAt != nil) {
+				continue
+			}
+			issue := &models.IntegrityIssue{Kind: models.IntegrityExpiredFlag, AgentID: agentID, SessionTopic: topic, Repaired: repair}
+			if session.Expired {
+				issue.Detail = "expired session has no expiry time"
+			} else {
+				issue.Detail = "active session has an expiry time"
+			}
+			issues = append(issues, issue)
+
+			if repair {
+				if session.Expired {
+					expiredAt := session.ExpiresAt()
+					session.ExpiredAt = &expiredAt
+					if session.EndReason == "" {
+						session.EndReason = models.EndReasonCleanup
+					}
+				} else {
+					session.ExpiredAt = nil
+				}
+				session.Version++
+			}
+		}
+	}
+
+	sortIntegrityIssues(issues)
+	return issues, nil
+}
+
+// deleteSessionLocked removes a session with its statuses and their annotations
+func (s *MemoryStore) deleteSessionLocked(agentID, topic string) {
+	delete(s.sessions[agentID], topic)
+	if len(s.sessions[agentID]) == 0 {
+		delete(s.sessions, agentID)
+	}
+	delete(s.statuses[agentID], topic)
+	if len(s.statuses[agentID]) == 0 {
+		delete(s.statuses, agentID)
+	}
+	for id, annotation := range s.annotations {
+		if annotation.AgentID == agentID && annotation.SessionTopic == topic {
+			delete(s.annotations, id)
+		}
+	}
+	for key, rollup := range s.rollups {
+		if rollup.AgentID == agentID && rollup.SessionTopic == topic {
+			delete(s.rollups, key)
+		}
+	}
+}
+
+// sortIntegrityIssues orders issues by kind, agent and session topic
+func sortIntegrityIssues(issues []*models.IntegrityIssue) {
+	sort.Slice(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.AgentID != b.AgentID {
+			return a.AgentID < b.AgentID
+		}
+		return a.SessionTopic < b.SessionTopic
+	})
+}
+
+// ListAgentsByUser returns all agents belonging to a specific user, most recently seen first
+func (s *MemoryStore) ListAgentsByUser(userID string) []*models.Agent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := make([]*models.Agent, 0)
+	for _, agent := range s.agents {
+		if agent.UserID == userID && agent.DeletedAt == nil {
+			copied := *agent
+			agents = append(agents, &copied)
+		}
+	}
+	sortAgentsByLastSeen(agents)
+	return agents
+}
+
+// CountAgentsByUsers returns how many agents each of the users has
+func (s *MemoryStore) CountAgentsByUsers(userIDs []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		wanted[id] = true
+	}
+	counts := make(map[string]int)
+	for _, agent := range s.agents {
+		if wanted[agent.UserID] && agent.DeletedAt == nil {
+			counts[agent.UserID]++
+		}
+	}
+	return counts, nil
+}
+
+// QueryAgents returns one page of the agents a query selects and how many it selects
+func (s *MemoryStore) QueryAgents(q Query) ([]*models.Agent, int, error) {
+	if err := q.validateAgents(); err != nil {
+		return nil, 0, invalid(err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := make([]*models.Agent, 0)
+	for _, agent := range s.agents {
+		if agent.DeletedAt != nil || !q.matchesAgent(agent) {
+			continue
+		}
+		if q.Status != "" && s.agentLatestStatusLocked(agent.AgentID) != q.Status {
+			continue
+		}
+		copied := *agent
+		agents = append(agents, &copied)
+	}
+	sortAgentsByLastSeen(agents)
+	return pageOf(agents, q.Page), len(agents), nil
+}
+
+// agentLatestStatusLocked returns the latest status of the agent's unexpired sessions, or "" if they have none
+func (s *MemoryStore) agentLatestStatusLocked(agentID string) string {
+	var latest *models.AgentStatus
+	for topic, session := range s.sessions[agentID] {
+		if session.Expired {
+			continue
+		}
+		if status := s.latestStatusLocked(agentID, topic); status != nil && (latest == nil || status.Timestamp.After(latest.Timestamp)) {
+			latest = status
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.Status
+}
+
+// ListAgentsByOrg returns an organization's agents, most recently seen first
+func (s *MemoryStore) ListAgentsByOrg(orgID string) ([]*models.Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := make([]*models.Agent, 0)
+	for _, agent := range s.agents {
+		if agent.OrgID == orgID && agent.DeletedAt == nil {
+			copied := *agent
+			agents = append(agents, &copied)
+		}
+	}
+	sortAgentsByLastSeen(agents)
+	return agents, nil
+}
+
+// DeleteAgent soft-deletes an agent
+func (s *MemoryStore) DeleteAgent(agentID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agent, exists := s.agents[agentID]
+	if !exists || agent.DeletedAt != nil {
+		return ErrNotFound
+	}
+	stored := *agent
+	stored.DeletedAt = &at
+	stored.Version++
+	s.agents[agentID] = &stored
+	return nil
+}
+
+// RestoreAgent undoes DeleteAgent
+func (s *MemoryStore) RestoreAgent(agentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agent, exists := s.agents[agentID]
+	if !exists || agent.DeletedAt == nil {
+		return ErrNotFound
+	}
+	stored := *agent
+	stored.DeletedAt = nil
+	stored.Version++
+	s.agents[agentID] = &stored
+	return nil
+}
+
+// ListDeletedAgents returns soft-deleted agents, most recently deleted first
+func (s *MemoryStore) ListDeletedAgents(userID string) ([]*models.Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := make([]*models.Agent, 0)
+	for _, agent := range s.agents {
+		if agent.DeletedAt != nil && (userID == "" || agent.UserID == userID) {
+			copied := *agent
+			agents = append(agents, &copied)
+		}
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		if !agents[i].DeletedAt.Equal(*agents[j].DeletedAt) {
+			return agents[i].DeletedAt.After(*agents[j].DeletedAt)
+		}
+		return agents[i].AgentID < agents[j].AgentID
+	})
+	return agents, nil
+}
+
+// PurgeDeletedAgents removes agents soft-deleted before the cutoff with everything recorded for them
+func (s *MemoryStore) PurgeDeletedAgents(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for agentID, agent := range s.agents {
+		if agent.DeletedAt == nil || !agent.DeletedAt.Before(before) {
+			continue
+		}
+		for topic := range s.sessions[agentID] {
+			s.deleteSessionLocked(agentID, topic)
+		}
+		delete(s.statuses, agentID)
+		for key, item := range s.watchItems {
+			if item.AgentID == agentID {
+				delete(s.watchItems, key)
+			}
+		}
+		delete(s.agents, agentID)
+		purged++
+	}
+	return purged, nil
+}
+
+// CreateUser creates a new user
+func (s *MemoryStore) CreateUser(user *models.User) error {
+	if err := user.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check if email already exists
+	if _, exists := s.usersByEmail[user.Email]; exists {
+		return ErrDuplicateEmail
+	}
+
+	// Store a copy so callers cannot change the email index without UpdateUser
+	stored := *user
+	s.users[user.ID] = &stored
+	s.usersByEmail[user.Email] = &stored
+	return nil
+}
+
+// GetUserByID retrieves a user by ID
+func (s *MemoryStore) GetUserByID(userID string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// ListUsers returns every user, oldest first
+func (s *MemoryStore) ListUsers() ([]*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]*models.User, 0, len(s.users))
+	for _, user := range s.users {
+		copied := *user
+		users = append(users, &copied)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].ID < users[j].ID
+	})
+	return users, nil
+}
+
+// ListUsersPage returns one page of ListUsers and how many users there are
+func (s *MemoryStore) ListUsersPage(page Page) ([]*models.User, int, error) {
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, 0, err
+	}
+	return pageOf(users, page), len(users), nil
+}
+
+// GetUserByEmail retrieves a user by email
+func (s *MemoryStore) GetUserByEmail(email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.usersByEmail[email]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// GetUserByVerifyToken retrieves a user by verification token
+func (s *MemoryStore) GetUserByVerifyToken(token string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.VerifyToken == token {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// GetUserDataKey returns a user's wrapped data key
+func (s *MemoryStore) GetUserDataKey(userID string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, exists := s.dataKeys[userID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), key...), nil
+}
+
+// SetUserDataKey stores the wrapped key unless the user already has one and returns the stored key
+func (s *MemoryStore) SetUserDataKey(userID string, wrappedKey []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[userID]; !exists {
+		return nil, ErrNotFound
+	}
+	if _, exists := s.dataKeys[userID]; !exists {
+		s.dataKeys[userID] = append([]byte(nil), wrappedKey...)
+	}
+	return append([]byte(nil), s.dataKeys[userID]...), nil
+}
+
+// UpdateUser updates an existing user
+func (s *MemoryStore) UpdateUser(user *models.User) error {
+	if err := user.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existingUser, exists := s.users[user.ID]
+	if !exists {
+		return ErrNotFound
+	}
+
+	// If email changed, update email index
+	if existingUser.Email != user.Email {
+		// Check if new email already exists
+		if _, exists := s.usersByEmail[user.Email]; exists {
+			return ErrDuplicateEmail
+		}
+		delete(s.usersByEmail, existingUser.Email)
+	}
+
+	stored := *user
+	s.users[user.ID] = &stored
+	s.usersByEmail[user.Email] = &stored
+	return nil
+}
+
+// DeleteUser removes a user together with their agents and every other record they own
+func (s *MemoryStore) DeleteUser(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, exists := s.users[userID]
+	if !exists {
+		return ErrNotFound
+	}
+	delete(s.users, userID)
+	delete(s.usersByEmail, user.Email)
+	delete(s.dataKeys, userID)
+
+	for agentID, agent := range s.agents {
+		if agent.UserID != userID {
+			continue
+		}
+		for topic := range s.sessions[agentID] {
+			s.deleteSessionLocked(agentID, topic)
+		}
+		delete(s.statuses, agentID)
+		delete(s.agents, agentID)
+	}
+	for id, token := range s.refreshTokens {
+		if token.UserID == userID {
+			delete(s.refreshTokens, id)
+		}
+	}
+	for id, apiKey := range s.apiKeys {
+		if apiKey.UserID == userID {
+			delete(s.apiKeys, id)
+			delete(s.apiKeysByHash, apiKey.KeyHash)
+		}
+	}
+	for fingerprint, cert := range s.clientCerts {
+		if cert.UserID == userID {
+			delete(s.clientCerts, fingerprint)
+		}
+	}
+	for id, token := range s.enrollments {
+		if token.UserID == userID {
+			delete(s.enrollments, id)
+		}
+	}
+	for id, sla := range s.slas {
+		if sla.UserID != userID {
+			continue
+		}
+		delete(s.slas, id)
+		for key, breach := range s.slaBreaches {
+			if breach.SLAID == id {
+				delete(s.slaBreaches, key)
+			}
+		}
+	}
+	for id, rule := range s.alertRules {
+		if rule.UserID == userID {
+			delete(s.alertRules, id)
+		}
+	}
+	for key, mark := range s.marks {
+		if mark.UserID == userID {
+			delete(s.marks, key)
+		}
+	}
+	for key, membership := range s.memberships {
+		if membership.UserID == userID {
+			delete(s.memberships, key)
+		}
+	}
+	for id, hook := range s.sessionHooks {
+		if hook.UserID == userID {
+			delete(s.sessionHooks, id)
+		}
+	}
+	for key, item := range s.watchItems {
+		if item.UserID == userID {
+			delete(s.watchItems, key)
+		}
+	}
+	delete(s.notifySettings, userID)
+	for id, item := range s.inboxItems {
+		if item.UserID == userID {
+			delete(s.inboxItems, id)
+		}
+	}
+	for key, record := range s.usage {
+		if record.UserID == userID {
+			delete(s.usage, key)
+		}
+	}
+	delete(s.tenantCounts, userID)
+	for id, annotation := range s.annotations {
+		if annotation.UserID == userID {
+			delete(s.annotations, id)
+		}
+	}
+	for key, record := range s.idempotency {
+		if record.UserID == userID {
+			delete(s.idempotency, key)
+		}
+	}
+	return nil
+}
+
+// SaveRefreshToken saves a refresh token
+func (s *MemoryStore) SaveRefreshToken(token *models.RefreshToken) error {
+	if err := token.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshTokens[token.ID] = token
+	return nil
+}
+
+// GetRefreshTokenByID retrieves a refresh token by ID
+func (s *MemoryStore) GetRefreshTokenByID(tokenID string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token, exists := s.refreshTokens[tokenID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return token, nil
+}
+
+// GetRefreshToken retrieves a refresh token by hash
+func (s *MemoryStore) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// RevokeRefreshToken revokes a refresh token by ID
+func (s *MemoryStore) RevokeRefreshToken(tokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.refreshTokens[tokenID]
+	if !exists || token.Revoked {
+		return ErrNotFound
+	}
+	token.Revoked = true
+	return nil
+}
+
+// RevokeAllUserTokens revokes all refresh tokens for a user
+func (s *MemoryStore) RevokeAllUserTokens(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
+
+// PurgeExpiredRefreshTokens removes refresh tokens past their expiry
+func (s *MemoryStore) PurgeExpiredRefreshTokens() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	now := s.clock.Now()
+	for id, token := range s.refreshTokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(s.refreshTokens, id)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// ClearExpiredVerifyTokens clears email verification tokens past their expiry
+func (s *MemoryStore) ClearExpiredVerifyTokens() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cleared := 0
+	now := s.clock.Now()
+	for _, user := range s.users {
+		if user.VerifyToken != "" && user.VerifyTokenExpiresAt != nil && !now.Before(*user.VerifyTokenExpiresAt) {
+			user.VerifyToken = ""
+			user.VerifyTokenExpiresAt = nil
+			cleared++
+		}
+	}
+	return cleared, nil
+}
+
+// CreateAPIKey creates a new API key
+func (s *MemoryStore) CreateAPIKey(apiKey *models.APIKey) error {
+	if err := apiKey.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	s.apiKeys[apiKey.ID] = apiKey
 	s.apiKeysByHash[apiKey.KeyHash] = apiKey
 	return nil
 }
 
-// GetAPIKeyByHash retrieves an API key by its hash
-func (s *MemoryStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+// GetAPIKeyByHash retrieves an API key by its hash
+func (s *MemoryStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apiKey, exists := s.apiKeysByHash[keyHash]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return apiKey, nil
+}
+
+// GetAPIKeyByID retrieves an API key by its ID
+func (s *MemoryStore) GetAPIKeyByID(keyID string) (*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apiKey, exists := s.apiKeys[keyID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return apiKey, nil
+}
+
+// ListAPIKeysByUser returns all API keys for a user, newest first
+func (s *MemoryStore) ListAPIKeysByUser(userID string) ([]*models.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*models.APIKey, 0)
+	for _, apiKey := range s.apiKeys {
+		if apiKey.UserID == userID {
+			copied := *apiKey
+			keys = append(keys, &copied)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key
+func (s *MemoryStore) RevokeAPIKey(keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apiKey, exists := s.apiKeys[keyID]
+	if !exists {
+		return ErrNotFound
+	}
+	apiKey.Revoked = true
+	return nil
+}
+
+// UpdateAPIKeyLastUsed updates the last used timestamp of an API key
+func (s *MemoryStore) UpdateAPIKeyLastUsed(keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apiKey, exists := s.apiKeys[keyID]
+	if !exists {
+		return ErrNotFound
+	}
+	now := s.clock.Now()
+	apiKey.LastUsedAt = &now
+	return nil
+}
+
+// CreateEnrollmentToken creates a new enrollment token
+func (s *MemoryStore) CreateEnrollmentToken(token *models.EnrollmentToken) error {
+	if err := token.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *token
+	s.enrollments[token.ID] = &copied
+	return nil
+}
+
+// GetEnrollmentTokenByHash retrieves an enrollment token by its hash
+func (s *MemoryStore) GetEnrollmentTokenByHash(tokenHash string) (*models.EnrollmentToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.enrollments {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ListEnrollmentTokensByUser returns a user's enrollment tokens, newest first
+func (s *MemoryStore) ListEnrollmentTokensByUser(userID string) ([]*models.EnrollmentToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]*models.EnrollmentToken, 0)
+	for _, token := range s.enrollments {
+		if token.UserID == userID {
+			copied := *token
+			tokens = append(tokens, &copied)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// RedeemEnrollmentToken marks an enrollment token used and creates the agent token it was exchanged for
+func (s *MemoryStore) RedeemEnrollmentToken(tokenID string, agentToken *models.APIKey) error {
+	if err := agentToken.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.enrollments[tokenID]
+	if !exists {
+		return ErrNotFound
+	}
+	if !token.Redeemable(agentToken.CreatedAt) {
+		return ErrConflict
+	}
+	usedAt := agentToken.CreatedAt
+	token.UsedAt = &usedAt
+	token.UsedByAgentID = agentToken.AgentID
+	token.APIKeyID = agentToken.ID
+	s.apiKeys[agentToken.ID] = agentToken
+	s.apiKeysByHash[agentToken.KeyHash] = agentToken
+	return nil
+}
+
+// DeleteEnrollmentToken removes one of a user's enrollment tokens
+func (s *MemoryStore) DeleteEnrollmentToken(userID, tokenID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, exists := s.enrollments[tokenID]
+	if !exists || token.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.enrollments, tokenID)
+	return nil
+}
+
+// membershipKey identifies a membership in the memberships map
+func membershipKey(orgID, userID string) string {
+	return orgID + "|" + userID
+}
+
+// CreateOrganization creates an organization and, if owner is not nil, its first owner's membership
+func (s *MemoryStore) CreateOrganization(org *models.Organization, owner *models.Membership) error {
+	if err := org.Validate(); err != nil {
+		return invalid(err)
+	}
+	if owner != nil {
+		if err := owner.Validate(); err != nil {
+			return invalid(err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.orgs[org.ID]; exists {
+		return ErrAlreadyExists
+	}
+	copied := *org
+	s.orgs[org.ID] = &copied
+	if owner != nil {
+		membership := *owner
+		s.memberships[membershipKey(membership.OrgID, membership.UserID)] = &membership
+	}
+	return nil
+}
+
+// GetOrganization retrieves an organization by ID
+func (s *MemoryStore) GetOrganization(orgID string) (*models.Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	org, exists := s.orgs[orgID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *org
+	return &copied, nil
+}
+
+// ListOrganizations returns every organization, oldest first
+func (s *MemoryStore) ListOrganizations() ([]*models.Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orgs := make([]*models.Organization, 0, len(s.orgs))
+	for _, org := range s.orgs {
+		copied := *org
+		orgs = append(orgs, &copied)
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		if !orgs[i].CreatedAt.Equal(orgs[j].CreatedAt) {
+			return orgs[i].CreatedAt.Before(orgs[j].CreatedAt)
+		}
+		return orgs[i].ID < orgs[j].ID
+	})
+	return orgs, nil
+}
+
+// DeleteOrganization removes an organization with its memberships and invitations and unshares its agents
+func (s *MemoryStore) DeleteOrganization(orgID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.orgs[orgID]; !exists {
+		return ErrNotFound
+	}
+	delete(s.orgs, orgID)
+	for key, membership := range s.memberships {
+		if membership.OrgID == orgID {
+			delete(s.memberships, key)
+		}
+	}
+	for id, invitation := range s.invitations {
+		if invitation.OrgID == orgID {
+			delete(s.invitations, id)
+		}
+	}
+	for _, agent := range s.agents {
+		if agent.OrgID == orgID {
+			agent.OrgID = ""
+		}
+	}
+	return nil
+}
+
+// SaveMembership creates or replaces a membership
+func (s *MemoryStore) SaveMembership(membership *models.Membership) error {
+	if err := membership.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.orgs[membership.OrgID]; !exists {
+		return ErrNotFound
+	}
+	copied := *membership
+	s.memberships[membershipKey(membership.OrgID, membership.UserID)] = &copied
+	return nil
+}
+
+// GetMembership retrieves a user's membership of an organization
+func (s *MemoryStore) GetMembership(orgID, userID string) (*models.Membership, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	membership, exists := s.memberships[membershipKey(orgID, userID)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *membership
+	return &copied, nil
+}
+
+// ListMemberships returns an organization's memberships, oldest first
+func (s *MemoryStore) ListMemberships(orgID string) ([]*models.Membership, error) {
+	return s.listMemberships(func(m *models.Membership) bool { return m.OrgID == orgID }), nil
+}
+
+// ListMembershipsByUser returns a user's memberships, oldest first
+func (s *MemoryStore) ListMembershipsByUser(userID string) ([]*models.Membership, error) {
+	return s.listMemberships(func(m *models.Membership) bool { return m.UserID == userID }), nil
+}
+
+// listMemberships returns copies of the memberships matching keep, oldest first
+func (s *MemoryStore) listMemberships(keep func(*models.Membership) bool) []*models.Membership {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	memberships := make([]*models.Membership, 0)
+	for _, membership := range s.memberships {
+		if keep(membership) {
+			copied := *membership
+			memberships = append(memberships, &copied)
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		if !memberships[i].CreatedAt.Equal(memberships[j].CreatedAt) {
+			return memberships[i].CreatedAt.Before(memberships[j].CreatedAt)
+		}
+		return membershipKey(memberships[i].OrgID, memberships[i].UserID) < membershipKey(memberships[j].OrgID, memberships[j].UserID)
+	})
+	return memberships
+}
+
+// DeleteMembership removes a user from an organization
+func (s *MemoryStore) DeleteMembership(orgID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := membershipKey(orgID, userID)
+	if _, exists := s.memberships[key]; !exists {
+		return ErrNotFound
+	}
+	delete(s.memberships, key)
+	return nil
+}
+
+// CreateInvitation creates an invitation to an organization
+func (s *MemoryStore) CreateInvitation(invitation *models.Invitation) error {
+	if err := invitation.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.orgs[invitation.OrgID]; !exists {
+		return ErrNotFound
+	}
+	copied := *invitation
+	s.invitations[invitation.ID] = &copied
+	return nil
+}
+
+// GetInvitationByHash retrieves an invitation by its token hash
+func (s *MemoryStore) GetInvitationByHash(tokenHash string) (*models.Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, invitation := range s.invitations {
+		if invitation.TokenHash == tokenHash {
+			copied := *invitation
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// ListInvitations returns an organization's pending invitations, newest first
+func (s *MemoryStore) ListInvitations(orgID string) ([]*models.Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invitations := make([]*models.Invitation, 0)
+	for _, invitation := range s.invitations {
+		if invitation.OrgID == orgID {
+			copied := *invitation
+			invitations = append(invitations, &copied)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+	})
+	return invitations, nil
+}
+
+// AcceptInvitation deletes an invitation and saves the membership it grants
+func (s *MemoryStore) AcceptInvitation(invitationID string, membership *models.Membership) error {
+	if err := membership.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.invitations[invitationID]; !exists {
+		return ErrNotFound
+	}
+	delete(s.invitations, invitationID)
+	copied := *membership
+	s.memberships[membershipKey(membership.OrgID, membership.UserID)] = &copied
+	return nil
+}
+
+// DeleteInvitation revokes one of an organization's invitations
+func (s *MemoryStore) DeleteInvitation(orgID, invitationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	invitation, exists := s.invitations[invitationID]
+	if !exists || invitation.OrgID != orgID {
+		return ErrNotFound
+	}
+	delete(s.invitations, invitationID)
+	return nil
+}
+
+// CreateClientCertificate registers a client certificate fingerprint
+func (s *MemoryStore) CreateClientCertificate(cert *models.ClientCertificate) error {
+	if err := cert.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.clientCerts[cert.Fingerprint]; exists {
+		return ErrAlreadyExists
+	}
+	copied := *cert
+	s.clientCerts[cert.Fingerprint] = &copied
+	return nil
+}
+
+// GetClientCertificateByFingerprint retrieves a client certificate by its fingerprint
+func (s *MemoryStore) GetClientCertificateByFingerprint(fingerprint string) (*models.ClientCertificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cert, exists := s.clientCerts[fingerprint]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *cert
+	return &copied, nil
+}
+
+// ListClientCertificatesByUser returns a user's client certificates, oldest first
+func (s *MemoryStore) ListClientCertificatesByUser(userID string) ([]*models.ClientCertificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	certs := make([]*models.ClientCertificate, 0)
+	for _, cert := range s.clientCerts {
+		if cert.UserID == userID {
+			copied := *cert
+			certs = append(certs, &copied)
+		}
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].CreatedAt.Before(certs[j].CreatedAt)
+	})
+	return certs, nil
+}
+
+// DeleteClientCertificate removes one of a user's client certificates
+func (s *MemoryStore) DeleteClientCertificate(userID, certID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for fingerprint, cert := range s.clientCerts {
+		if cert.ID == certID && cert.UserID == userID {
+			delete(s.clientCerts, fingerprint)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// GetConfig retrieves a config value by key
+func (s *MemoryStore) GetConfig(key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, exists := s.config[key]
+	if !exists {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// SetConfig sets a config value
+func (s *MemoryStore) SetConfig(key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config[key] = value
+	return nil
+}
+
+// CreateSLA creates a new SLA
+func (s *MemoryStore) CreateSLA(sla *models.SLA) error {
+	if err := sla.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.slas[sla.ID] = sla
+	return nil
+}
+
+// GetSLA retrieves an SLA by ID
+func (s *MemoryStore) GetSLA(slaID string) (*models.SLA, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sla, exists := s.slas[slaID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return sla, nil
+}
+
+// ListSLAs returns all SLAs, oldest first
+func (s *MemoryStore) ListSLAs() ([]*models.SLA, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	slas := make([]*models.SLA, 0, len(s.slas))
+	for _, sla := range s.slas {
+		copied := *sla
+		slas = append(slas, &copied)
+	}
+	sortSLAsByCreation(slas)
+	return slas, nil
+}
+
+// sortSLAsByCreation orders SLAs oldest first
+func sortSLAsByCreation(slas []*models.SLA) {
+	sort.Slice(slas, func(i, j int) bool {
+		return slas[i].CreatedAt.Before(slas[j].CreatedAt)
+	})
+}
+
+// ListSLAsByUser returns all SLAs for a user, oldest first
+func (s *MemoryStore) ListSLAsByUser(userID string) ([]*models.SLA, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	slas := make([]*models.SLA, 0)
+	for _, sla := range s.slas {
+		if sla.UserID == userID {
+			copied := *sla
+			slas = append(slas, &copied)
+		}
+	}
+	sortSLAsByCreation(slas)
+	return slas, nil
+}
+
+// UpdateSLA updates an existing SLA
+func (s *MemoryStore) UpdateSLA(sla *models.SLA) error {
+	if err := sla.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.slas[sla.ID]; !exists {
+		return ErrNotFound
+	}
+	s.slas[sla.ID] = sla
+	return nil
+}
+
+// DeleteSLA deletes an SLA and its recorded breaches
+func (s *MemoryStore) DeleteSLA(slaID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.slas[slaID]; !exists {
+		return ErrNotFound
+	}
+	delete(s.slas, slaID)
+	for key, breach := range s.slaBreaches {
+		if breach.SLAID == slaID {
+			delete(s.slaBreaches, key)
+		}
+	}
+	return nil
+}
+
+// CreateAlertRule creates a new alert rule
+func (s *MemoryStore) CreateAlertRule(rule *models.AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.alertRules[rule.ID] = rule
+	return nil
+}
+
+// GetAlertRule retrieves an alert rule by ID
+func (s *MemoryStore) GetAlertRule(ruleID string) (*models.AlertRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rule, exists := s.alertRules[ruleID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *rule
+	return &copied, nil
+}
+
+// ListAlertRules returns all alert rules, oldest first
+func (s *MemoryStore) ListAlertRules() ([]*models.AlertRule, error) {
+	return s.listAlertRules(""), nil
+}
+
+// ListAlertRulesByUser returns all alert rules of a user, oldest first
+func (s *MemoryStore) ListAlertRulesByUser(userID string) ([]*models.AlertRule, error) {
+	return s.listAlertRules(userID), nil
+}
+
+// listAlertRules returns copies of the alert rules of userID, or of every user when it is empty, oldest first
+func (s *MemoryStore) listAlertRules(userID string) []*models.AlertRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rules := make([]*models.AlertRule, 0)
+	for _, rule := range s.alertRules {
+		if userID == "" || rule.UserID == userID {
+			copied := *rule
+			rules = append(rules, &copied)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules
+}
+
+// UpdateAlertRule updates an existing alert rule
+func (s *MemoryStore) UpdateAlertRule(rule *models.AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.alertRules[rule.ID]; !exists {
+		return ErrNotFound
+	}
+	s.alertRules[rule.ID] = rule
+	return nil
+}
+
+// DeleteAlertRule deletes an alert rule
+func (s *MemoryStore) DeleteAlertRule(ruleID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.alertRules[ruleID]; !exists {
+		return ErrNotFound
+	}
+	delete(s.alertRules, ruleID)
+	return nil
+}
+
+// CreateSLABreach records an SLA breach once per SLA, kind, agent and subject
+func (s *MemoryStore) CreateSLABreach(breach *models.SLABreach) error {
+	if err := breach.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := breach.SLAID + "|" + breach.Kind + "|" + breach.AgentID + "|" + breach.Subject
+	if _, exists := s.slaBreaches[key]; exists {
+		return ErrAlreadyExists
+	}
+	s.slaBreaches[key] = breach
+	return nil
+}
+
+// ListSLABreaches returns breaches of an SLA detected at or after since, newest first
+func (s *MemoryStore) ListSLABreaches(slaID string, since time.Time) ([]*models.SLABreach, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	breaches := make([]*models.SLABreach, 0)
+	for _, breach := range s.slaBreaches {
+		if breach.SLAID == slaID && !breach.DetectedAt.Before(since) {
+			breaches = append(breaches, breach)
+		}
+	}
+	sort.Slice(breaches, func(i, j int) bool {
+		return breaches[i].DetectedAt.After(breaches[j].DetectedAt)
+	})
+	return breaches, nil
+}
+
+// copySessionWebhook returns a copy of a webhook that does not share its outcomes
+func copySessionWebhook(hook *models.SessionWebhook) *models.SessionWebhook {
+	copied := *hook
+	copied.Outcomes = append([]string(nil), hook.Outcomes...)
+	return &copied
+}
+
+// CreateSessionWebhook creates a new session webhook
+func (s *MemoryStore) CreateSessionWebhook(hook *models.SessionWebhook) error {
+	if err := hook.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessionHooks[hook.ID]; exists {
+		return ErrAlreadyExists
+	}
+	s.sessionHooks[hook.ID] = copySessionWebhook(hook)
+	return nil
+}
+
+// GetSessionWebhook retrieves a session webhook by ID
+func (s *MemoryStore) GetSessionWebhook(id string) (*models.SessionWebhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hook, exists := s.sessionHooks[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return copySessionWebhook(hook), nil
+}
+
+// ListSessionWebhooksByUser returns a user's session webhooks, oldest first
+func (s *MemoryStore) ListSessionWebhooksByUser(userID string) ([]*models.SessionWebhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hooks := make([]*models.SessionWebhook, 0)
+	for _, hook := range s.sessionHooks {
+		if hook.UserID == userID {
+			hooks = append(hooks, copySessionWebhook(hook))
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		if !hooks[i].CreatedAt.Equal(hooks[j].CreatedAt) {
+			return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+		}
+		return hooks[i].ID < hooks[j].ID
+	})
+	return hooks, nil
+}
+
+// UpdateSessionWebhook updates an existing session webhook
+func (s *MemoryStore) UpdateSessionWebhook(hook *models.SessionWebhook) error {
+	if err := hook.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessionHooks[hook.ID]; !exists {
+		return ErrNotFound
+	}
+	s.sessionHooks[hook.ID] = copySessionWebhook(hook)
+	return nil
+}
+
+// DeleteSessionWebhook deletes a session webhook
+func (s *MemoryStore) DeleteSessionWebhook(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessionHooks[id]; !exists {
+		return ErrNotFound
+	}
+	delete(s.sessionHooks, id)
+	return nil
+}
+
+// PurgeSLABreaches removes breaches detected before the cutoff
+func (s *MemoryStore) PurgeSLABreaches(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, breach := range s.slaBreaches {
+		if breach.DetectedAt.Before(before) {
+			delete(s.slaBreaches, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// watchKey identifies a watch item within the memory store
+func watchKey(userID, agentID, sessionTopic string) string {
+	return userID + "|" + agentID + "|" + sessionTopic
+}
+
+// SaveWatchItem creates or replaces the item for its user, agent and session topic
+func (s *MemoryStore) SaveWatchItem(item *models.WatchItem) error {
+	if err := item.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watchItems[watchKey(item.UserID, item.AgentID, item.SessionTopic)] = item
+	return nil
+}
+
+// GetWatchItem retrieves a user's watch item for an agent, or for one of its sessions
+func (s *MemoryStore) GetWatchItem(userID, agentID, sessionTopic string) (*models.WatchItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, exists := s.watchItems[watchKey(userID, agentID, sessionTopic)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return item, nil
+}
+
+// ListWatchItems returns a user's watch items, oldest first
+func (s *MemoryStore) ListWatchItems(userID string) ([]*models.WatchItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]*models.WatchItem, 0)
+	for _, item := range s.watchItems {
+		if item.UserID == userID {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+	return items, nil
+}
+
+// DeleteWatchItem removes a user's watch item
+func (s *MemoryStore) DeleteWatchItem(userID, agentID, sessionTopic string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := watchKey(userID, agentID, sessionTopic)
+	if _, exists := s.watchItems[key]; !exists {
+		return ErrNotFound
+	}
+	delete(s.watchItems, key)
+	return nil
+}
+
+// SaveNotificationSettings creates or replaces a user's notification settings
+func (s *MemoryStore) SaveNotificationSettings(settings *models.NotificationSettings) error {
+	if err := settings.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *settings
+	copied.Transitions = append([]models.StatusTransition(nil), settings.Transitions...)
+	s.notifySettings[settings.UserID] = &copied
+	return nil
+}
+
+// GetNotificationSettings retrieves a user's notification settings
+func (s *MemoryStore) GetNotificationSettings(userID string) (*models.NotificationSettings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings, exists := s.notifySettings[userID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *settings
+	copied.Transitions = append([]models.StatusTransition(nil), settings.Transitions...)
+	return &copied, nil
+}
+
+// DeleteNotificationSettings removes a user's notification settings
+func (s *MemoryStore) DeleteNotificationSettings(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.notifySettings[userID]; !exists {
+		return ErrNotFound
+	}
+	delete(s.notifySettings, userID)
+	return nil
+}
+
+// CreateInboxItem records an inbox item once per user and dedupe key
+func (s *MemoryStore) CreateInboxItem(item *models.InboxItem) error {
+	if err := item.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.inboxItems {
+		if existing.UserID == item.UserID && existing.DedupeKey == item.DedupeKey {
+			return ErrAlreadyExists
+		}
+	}
+	copied := *item
+	s.inboxItems[item.ID] = &copied
+	return nil
+}
+
+// ListInboxItems returns a user's items newest first; limit <= 0 returns all of them
+func (s *MemoryStore) ListInboxItems(userID string, unreadOnly bool, limit int) ([]*models.InboxItem, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	apiKey, exists := s.apiKeysByHash[keyHash]
-	if !exists {
-		return nil, ErrNotFound
+	items := make([]*models.InboxItem, 0)
+	for _, item := range s.inboxItems {
+		if item.UserID == userID && (!unreadOnly || !item.Read) {
+			copied := *item
+			items = append(items, &copied)
+		}
 	}
-	return apiKey, nil
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.After(items[j].CreatedAt)
+		}
+		return items[i].ID > items[j].ID
+	})
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
 }
 
-// GetAPIKeyByID retrieves an API key by its ID
-func (s *MemoryStore) GetAPIKeyByID(keyID string) (*models.APIKey, error) {
+// ListInboxItemsPage returns one page of ListInboxItems and how many items it lists in total
+func (s *MemoryStore) ListInboxItemsPage(userID string, unreadOnly bool, page Page) ([]*models.InboxItem, int, error) {
+	items, err := s.ListInboxItems(userID, unreadOnly, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return pageOf(items, page), len(items), nil
+}
+
+// CountUnreadInboxItems returns how many of a user's items are unread
+func (s *MemoryStore) CountUnreadInboxItems(userID string) (int, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	apiKey, exists := s.apiKeys[keyID]
+	count := 0
+	for _, item := range s.inboxItems {
+		if item.UserID == userID && !item.Read {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MarkInboxItemRead marks one of a user's items as read
+func (s *MemoryStore) MarkInboxItemRead(userID, itemID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, exists := s.inboxItems[itemID]
+	if !exists || item.UserID != userID {
+		return ErrNotFound
+	}
+	item.Read = true
+	return nil
+}
+
+// MarkAllInboxItemsRead marks all of a user's items as read, returning how many were unread
+func (s *MemoryStore) MarkAllInboxItemsRead(userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	marked := 0
+	for _, item := range s.inboxItems {
+		if item.UserID == userID && !item.Read {
+			item.Read = true
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+// usageKey returns the key of a user's usage record for a day
+func usageKey(userID string, day time.Time) string {
+	return userID + "|" + day.Format(time.DateOnly)
+}
+
+// AddUsage adds the counts of usage to the record of its user and day
+func (s *MemoryStore) AddUsage(usage *models.UsageRecord) error {
+	if err := usage.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[usage.UserID]; !exists {
+		return ErrNotFound
+	}
+	day := models.UsageDay(usage.Day)
+	key := usageKey(usage.UserID, day)
+	record, exists := s.usage[key]
 	if !exists {
-		return nil, ErrNotFound
+		record = &models.UsageRecord{UserID: usage.UserID, Day: day}
+		s.usage[key] = record
 	}
-	return apiKey, nil
+	record.StatusReports += usage.StatusReports
+	record.StorageBytes += usage.StorageBytes
+	record.NotificationsSent += usage.NotificationsSent
+	return nil
 }
 
-// ListAPIKeysByUser returns all API keys for a user
-func (s *MemoryStore) ListAPIKeysByUser(userID string) ([]*models.APIKey, error) {
+// AddAuditEvent appends an event to the audit log
+func (s *MemoryStore) AddAuditEvent(event *models.AuditEvent) error {
+	if err := event.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextAuditID++
+	event.ID = s.nextAuditID
+	stored := *event
+	s.auditEvents = append(s.auditEvents, &stored)
+	return nil
+}
+
+// ListAuditEvents returns the events created at or after since, newest first
+func (s *MemoryStore) ListAuditEvents(since time.Time, limit int) ([]*models.AuditEvent, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	keys := make([]*models.APIKey, 0)
-	for _, apiKey := range s.apiKeys {
-		if apiKey.UserID == userID {
-			keys = append(keys, apiKey)
+	events := make([]*models.AuditEvent, 0)
+	for i := len(s.auditEvents) - 1; i >= 0; i-- {
+		if limit > 0 && len(events) >= limit {
+			break
+		}
+		if event := s.auditEvents[i]; !event.CreatedAt.Before(since) {
+			copied := *event
+			events = append(events, &copied)
 		}
 	}
-	return keys, nil
+	return events, nil
 }
 
-// RevokeAPIKey revokes an API key
-func (s *MemoryStore) RevokeAPIKey(keyID string) error {
+// PurgeAuditEvents removes events created before the cutoff
+func (s *MemoryStore) PurgeAuditEvents(before time.Time) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	apiKey, exists := s.apiKeys[keyID]
-	if !exists {
+	kept := s.auditEvents[:0]
+	for _, event := range s.auditEvents {
+		if !event.CreatedAt.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	removed := len(s.auditEvents) - len(kept)
+	clear(s.auditEvents[len(kept):])
+	s.auditEvents = kept
+	return removed, nil
+}
+
+// ListUsage returns the usage records of a user, or of every user, for days in [from, to)
+func (s *MemoryStore) ListUsage(userID string, from, to time.Time) ([]*models.UsageRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	from, to = models.UsageDay(from), models.UsageDay(to)
+	var records []*models.UsageRecord
+	for _, record := range s.usage {
+		if userID != "" && record.UserID != userID {
+			continue
+		}
+		if record.Day.Before(from) || !record.Day.Before(to) {
+			continue
+		}
+		copied := *record
+		records = append(records, &copied)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Day.Equal(records[j].Day) {
+			return records[i].Day.Before(records[j].Day)
+		}
+		return records[i].UserID < records[j].UserID
+	})
+	return records, nil
+}
+
+// AddTenantCounts adds the counts of delta to its user's counts
+func (s *MemoryStore) AddTenantCounts(delta *models.TenantCounts) error {
+	if err := delta.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[delta.UserID]; !exists {
 		return ErrNotFound
 	}
-	apiKey.Revoked = true
+	counts, exists := s.tenantCounts[delta.UserID]
+	if !exists {
+		counts = &models.TenantCounts{UserID: delta.UserID}
+		s.tenantCounts[delta.UserID] = counts
+	}
+	counts.Agents += delta.Agents
+	counts.Sessions += delta.Sessions
+	counts.Statuses += delta.Statuses
+	counts.UpdatedAt = s.clock.Now().UTC()
 	return nil
 }
 
-// UpdateAPIKeyLastUsed updates the last used timestamp of an API key
-func (s *MemoryStore) UpdateAPIKeyLastUsed(keyID string) error {
+// GetTenantCounts returns a user's counts
+func (s *MemoryStore) GetTenantCounts(userID string) (*models.TenantCounts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts, exists := s.tenantCounts[userID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	copied := *counts
+	return &copied, nil
+}
+
+// ReconcileTenantCounts replaces the counts of every user with those of their stored records
+func (s *MemoryStore) ReconcileTenantCounts(now time.Time) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	apiKey, exists := s.apiKeys[keyID]
-	if !exists {
+	now = now.UTC()
+	for userID := range s.users {
+		s.tenantCounts[userID] = &models.TenantCounts{UserID: userID, ReconciledAt: now, UpdatedAt: now}
+	}
+	for agentID, agent := range s.agents {
+		counts, exists := s.tenantCounts[agent.UserID]
+		if !exists || agent.DeletedAt != nil {
+			continue
+		}
+		counts.Agents++
+		counts.Sessions += int64(len(s.sessions[agentID]))
+		for _, history := range s.statuses[agentID] {
+			counts.Statuses += int64(len(history))
+		}
+	}
+	return len(s.users), nil
+}
+
+// rollupKey returns the key of a session's rollup for a day
+func rollupKey(agentID, sessionTopic string, day time.Time) string {
+	return agentID + "|" + sessionTopic + "|" + day.Format(time.DateOnly)
+}
+
+// RollupStatuses creates or replaces the rollups of the sessions with statuses on the days in [from, to)
+func (s *MemoryStore) RollupStatuses(from, to time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from, to = models.UsageDay(from), models.UsageDay(to)
+	var statuses []*models.AgentStatus
+	for _, topics := range s.statuses {
+		for _, history := range topics {
+			for _, status := range history {
+				if !status.Timestamp.Before(from) && status.Timestamp.Before(to) {
+					statuses = append(statuses, status)
+				}
+			}
+		}
+	}
+
+	rollups := models.BuildStatusRollups(statuses)
+	for _, rollup := range rollups {
+		s.rollups[rollupKey(rollup.AgentID, rollup.SessionTopic, rollup.Day)] = rollup
+	}
+	return len(rollups), nil
+}
+
+// SaveStatusRollup creates or replaces the rollup of its session and day
+func (s *MemoryStore) SaveStatusRollup(rollup *models.StatusRollup) error {
+	if err := rollup.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[rollup.AgentID][rollup.SessionTopic]; !exists {
 		return ErrNotFound
 	}
-	now := time.Now()
-	apiKey.LastUsedAt = &now
+	stored := *rollup
+	s.rollups[rollupKey(rollup.AgentID, rollup.SessionTopic, rollup.Day)] = &stored
 	return nil
 }
 
-// GetConfig retrieves a config value by key
-func (s *MemoryStore) GetConfig(key string) (string, error) {
+// ListStatusRollups returns the rollups of an agent, or of every agent, for days in [from, to)
+func (s *MemoryStore) ListStatusRollups(agentID, sessionTopic string, from, to time.Time) ([]*models.StatusRollup, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	value, exists := s.config[key]
+	from, to = models.UsageDay(from), models.UsageDay(to)
+	rollups := make([]*models.StatusRollup, 0)
+	for _, rollup := range s.rollups {
+		if (agentID != "" && rollup.AgentID != agentID) || (sessionTopic != "" && rollup.SessionTopic != sessionTopic) {
+			continue
+		}
+		if rollup.Day.Before(from) || !rollup.Day.Before(to) {
+			continue
+		}
+		copied := *rollup
+		rollups = append(rollups, &copied)
+	}
+	models.SortStatusRollups(rollups)
+	return rollups, nil
+}
+
+// SaveNonce records a webhook nonce until expiresAt
+func (s *MemoryStore) SaveNonce(scope, nonce string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := scope + "|" + nonce
+	if existing, exists := s.nonces[key]; exists && s.clock.Now().Before(existing) {
+		return ErrAlreadyExists
+	}
+	s.nonces[key] = expiresAt
+	return nil
+}
+
+// PurgeExpiredNonces removes nonces past their expiry
+func (s *MemoryStore) PurgeExpiredNonces() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	now := s.clock.Now()
+	for key, expiresAt := range s.nonces {
+		if !now.Before(expiresAt) {
+			delete(s.nonces, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// ClaimNotificationMark stores the mark unless the same one was notified after cutoff
+func (s *MemoryStore) ClaimNotificationMark(mark *models.NotificationMark, cutoff time.Time) error {
+	if err := mark.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := mark.UserID + "|" + mark.AgentID + "|" + mark.SessionTopic + "|" + mark.Status
+	if existing, exists := s.marks[key]; exists && existing.NotifiedAt.After(cutoff) {
+		return ErrAlreadyExists
+	}
+	copied := *mark
+	s.marks[key] = &copied
+	return nil
+}
+
+// ListNotificationMarks returns the marks of a status
+func (s *MemoryStore) ListNotificationMarks(status string) ([]*models.NotificationMark, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	marks := make([]*models.NotificationMark, 0)
+	for _, mark := range s.marks {
+		if mark.Status == status {
+			copied := *mark
+			marks = append(marks, &copied)
+		}
+	}
+	return marks, nil
+}
+
+// PurgeNotificationMarks removes marks notified before the cutoff
+func (s *MemoryStore) PurgeNotificationMarks(before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for key, mark := range s.marks {
+		if mark.NotifiedAt.Before(before) {
+			delete(s.marks, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// ClaimIdempotencyKey records a key whose report is being processed
+// An expired record for the same key is replaced rather than returned.
+func (s *MemoryStore) ClaimIdempotencyKey(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := record.UserID + "|" + record.Key
+	if existing, exists := s.idempotency[key]; exists && s.clock.Now().Before(existing.ExpiresAt) {
+		copied := *existing
+		copied.Response = append([]byte(nil), existing.Response...)
+		return &copied, ErrAlreadyExists
+	}
+	claimed := *record
+	claimed.StatusCode = 0
+	claimed.Response = nil
+	s.idempotency[key] = &claimed
+	return nil, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed key until expiresAt
+func (s *MemoryStore) CompleteIdempotencyKey(userID, key string, statusCode int, response []byte, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, exists := s.idempotency[userID+"|"+key]
 	if !exists {
-		return "", ErrNotFound
+		return ErrNotFound
 	}
-	return value, nil
+	record.StatusCode = statusCode
+	record.Response = append([]byte(nil), response...)
+	record.ExpiresAt = expiresAt
+	return nil
 }
 
-// SetConfig sets a config value
-func (s *MemoryStore) SetConfig(key, value string) error {
+// ReleaseIdempotencyKey removes a key so its report can be retried
+func (s *MemoryStore) ReleaseIdempotencyKey(userID, key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.config[key] = value
+	delete(s.idempotency, userID+"|"+key)
+	return nil
+}
+
+// PurgeExpiredIdempotencyKeys removes idempotency keys past their expiry
+func (s *MemoryStore) PurgeExpiredIdempotencyKeys() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	now := s.clock.Now()
+	for key, record := range s.idempotency {
+		if !now.Before(record.ExpiresAt) {
+			delete(s.idempotency, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// AddStatusWithOutbox adds a status and records its side effects atomically
+func (s *MemoryStore) AddStatusWithOutbox(status *models.AgentStatus, messages []*models.OutboxMessage) error {
+	if err := status.Validate(); err != nil {
+		return invalid(err)
+	}
+	for _, message := range messages {
+		if err := message.Validate(); err != nil {
+			return invalid(err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.addStatusLocked(status); err != nil {
+		return err
+	}
+	for _, message := range messages {
+		s.addOutboxMessageLocked(message)
+	}
+	return nil
+}
+
+// AddOutboxMessage records a message on its own
+func (s *MemoryStore) AddOutboxMessage(message *models.OutboxMessage) error {
+	if err := message.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addOutboxMessageLocked(message)
+	return nil
+}
+
+// addOutboxMessageLocked stores a copy of a message under the next ID; s.mu must be held
+func (s *MemoryStore) addOutboxMessageLocked(message *models.OutboxMessage) {
+	if message.AvailableAt.IsZero() {
+		message.AvailableAt = message.CreatedAt
+	}
+	s.nextOutboxID++
+	message.ID = s.nextOutboxID
+	copied := *message
+	s.outbox[message.ID] = &copied
+}
+
+// ClaimOutboxMessages claims up to limit messages available at now, oldest first
+func (s *MemoryStore) ClaimOutboxMessages(now time.Time, lease time.Duration, limit int) ([]*models.OutboxMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*models.OutboxMessage
+	for _, message := range s.outbox {
+		if !message.AvailableAt.After(now) {
+			due = append(due, message)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.OutboxMessage, 0, len(due))
+	for _, message := range due {
+		message.AvailableAt = now.Add(lease)
+		message.Attempts++
+		copied := *message
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+// DeleteOutboxMessage removes a delivered or abandoned message
+func (s *MemoryStore) DeleteOutboxMessage(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.outbox[id]; !exists {
+		return ErrNotFound
+	}
+	delete(s.outbox, id)
+	return nil
+}
+
+// RetryOutboxMessage records a failed delivery and makes the message available again at availableAt
+func (s *MemoryStore) RetryOutboxMessage(id int64, availableAt time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	message, exists := s.outbox[id]
+	if !exists {
+		return ErrNotFound
+	}
+	message.AvailableAt = availableAt
+	message.LastError = lastError
 	return nil
 }
@@ -1,6 +1,7 @@
 package store
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -382,7 +383,13 @@ func TestStore_CheckExpiredSessions(t *testing.T) {
 	s.CreateOrUpdateSession(activeSession)
 
 	// Check expired sessions
-	s.CheckExpiredSessions()
+	newlyExpired := s.CheckExpiredSessions()
+	if len(newlyExpired) != 1 || newlyExpired[0].SessionTopic != "task-expired" {
+		t.Errorf("CheckExpiredSessions() returned %d sessions, want task-expired only", len(newlyExpired))
+	}
+	if again := s.CheckExpiredSessions(); len(again) != 0 {
+		t.Errorf("CheckExpiredSessions() second call returned %d sessions, want 0", len(again))
+	}
 
 	// Verify expired session is marked
 	expired, _ := s.GetSession("agent-001", "task-expired")
@@ -531,3 +538,257 @@ func TestStore_ConfigConcurrentAccess(t *testing.T) {
 		}
 	}
 }
+
+func TestStore_SLALifecycle(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+
+	sla := &models.SLA{
+		ID:                 "sla-001",
+		UserID:             "user-001",
+		Name:               "Nightly backup",
+		MaxDurationMinutes: 60,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := s.CreateSLA(sla); err != nil {
+		t.Fatalf("CreateSLA() error = %v, want nil", err)
+	}
+
+	slas, _ := s.ListSLAsByUser("user-001")
+	if len(slas) != 1 {
+		t.Errorf("ListSLAsByUser() count = %d, want 1", len(slas))
+	}
+
+	breach := &models.SLABreach{
+		ID:         "breach-001",
+		SLAID:      "sla-001",
+		UserID:     "user-001",
+		AgentID:    "agent-001",
+		Kind:       models.SLABreachDuration,
+		Subject:    "backup 2024-01-15",
+		DetectedAt: now,
+	}
+	if err := s.CreateSLABreach(breach); err != nil {
+		t.Fatalf("CreateSLABreach() error = %v, want nil", err)
+	}
+
+	// Same SLA, kind, agent and subject is recorded only once
+	duplicate := *breach
+	duplicate.ID = "breach-002"
+	if err := s.CreateSLABreach(&duplicate); err != ErrAlreadyExists {
+		t.Errorf("CreateSLABreach() duplicate error = %v, want ErrAlreadyExists", err)
+	}
+
+	breaches, _ := s.ListSLABreaches("sla-001", now.Add(-time.Hour))
+	if len(breaches) != 1 {
+		t.Errorf("ListSLABreaches() count = %d, want 1", len(breaches))
+	}
+	breaches, _ = s.ListSLABreaches("sla-001", now.Add(time.Hour))
+	if len(breaches) != 0 {
+		t.Errorf("ListSLABreaches() after since count = %d, want 0", len(breaches))
+	}
+
+	if err := s.DeleteSLA("sla-001"); err != nil {
+		t.Fatalf("DeleteSLA() error = %v, want nil", err)
+	}
+	if _, err := s.GetSLA("sla-001"); err != ErrNotFound {
+		t.Errorf("GetSLA() after delete error = %v, want ErrNotFound", err)
+	}
+	breaches, _ = s.ListSLABreaches("sla-001", time.Time{})
+	if len(breaches) != 0 {
+		t.Errorf("ListSLABreaches() after delete count = %d, want 0", len(breaches))
+	}
+}
+
+func TestStore_SaveNonce(t *testing.T) {
+	s := NewMemoryStore()
+	expiresAt := time.Now().Add(time.Minute)
+
+	if err := s.SaveNonce("key:001", "nonce-1", expiresAt); err != nil {
+		t.Fatalf("SaveNonce() error = %v, want nil", err)
+	}
+	if err := s.SaveNonce("key:001", "nonce-1", expiresAt); err != ErrAlreadyExists {
+		t.Errorf("SaveNonce() replay error = %v, want ErrAlreadyExists", err)
+	}
+	// Nonces are scoped per key
+	if err := s.SaveNonce("key:002", "nonce-1", expiresAt); err != nil {
+		t.Errorf("SaveNonce() other scope error = %v, want nil", err)
+	}
+
+	// Expired nonces may be reused and are purged
+	if err := s.SaveNonce("key:001", "nonce-2", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("SaveNonce() error = %v, want nil", err)
+	}
+	if err := s.SaveNonce("key:001", "nonce-2", expiresAt); err != nil {
+		t.Errorf("SaveNonce() after expiry error = %v, want nil", err)
+	}
+
+	s.SaveNonce("key:001", "nonce-3", time.Now().Add(-time.Second))
+	s.PurgeExpiredNonces()
+	if _, exists := s.nonces["key:001|nonce-3"]; exists {
+		t.Error("PurgeExpiredNonces() kept an expired nonce")
+	}
+}
+
+func TestStore_VersionConflict(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+
+	agent := &models.Agent{AgentID: "agent-001", Name: "Agent", Registered: now, LastSeen: now}
+	if err := s.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v, want nil", err)
+	}
+	if agent.Version != 1 {
+		t.Errorf("CreateOrUpdateAgent() version = %d, want 1", agent.Version)
+	}
+
+	// Two writers read the same version; only the first write wins
+	first, _ := s.GetAgent("agent-001")
+	second, _ := s.GetAgent("agent-001")
+	first.Name = "First"
+	if err := s.CreateOrUpdateAgent(first); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v, want nil", err)
+	}
+	second.Name = "Second"
+	if err := s.CreateOrUpdateAgent(second); err != ErrConflict {
+		t.Errorf("CreateOrUpdateAgent() stale error = %v, want ErrConflict", err)
+	}
+	if retrieved, _ := s.GetAgent("agent-001"); retrieved.Name != "First" || retrieved.Version != 2 {
+		t.Errorf("GetAgent() = %s v%d, want First v2", retrieved.Name, retrieved.Version)
+	}
+
+	session := &models.Session{AgentID: "agent-001", SessionTopic: "task-001", Created: now, LastUpdated: now}
+	if err := s.CreateOrUpdateSession(session); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v, want nil", err)
+	}
+	stale, _ := s.GetSession("agent-001", "task-001")
+	if err := s.CreateOrUpdateSession(session); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v, want nil", err)
+	}
+	if err := s.CreateOrUpdateSession(stale); err != ErrConflict {
+		t.Errorf("CreateOrUpdateSession() stale error = %v, want ErrConflict", err)
+	}
+
+	// Recreating an existing record without reading it first is also a conflict
+	if err := s.CreateOrUpdateSession(&models.Session{AgentID: "agent-001", SessionTopic: "task-001", Created: now, LastUpdated: now}); err != ErrConflict {
+		t.Errorf("CreateOrUpdateSession() blind write error = %v, want ErrConflict", err)
+	}
+}
+
+func TestMemoryStore_InboxItems(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+
+	for i, key := range []string{"failure|a", "failure|b", "failure|c"} {
+		err := s.CreateInboxItem(&models.InboxItem{
+			ID:        key,
+			UserID:    "user-1",
+			Kind:      models.InboxKindFailure,
+			AgentID:   "agent-1",
+			DedupeKey: key,
+			CreatedAt: now.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("CreateInboxItem() error = %v", err)
+		}
+	}
+
+	duplicate := &models.InboxItem{ID: "dup", UserID: "user-1", Kind: models.InboxKindFailure, AgentID: "agent-1", DedupeKey: "failure|a", CreatedAt: now}
+	if err := s.CreateInboxItem(duplicate); err != ErrAlreadyExists {
+		t.Errorf("CreateInboxItem() duplicate error = %v, want %v", err, ErrAlreadyExists)
+	}
+
+	if err := s.MarkInboxItemRead("user-2", "failure|c"); err != ErrNotFound {
+		t.Errorf("MarkInboxItemRead() other user error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.MarkInboxItemRead("user-1", "failure|c"); err != nil {
+		t.Fatalf("MarkInboxItemRead() error = %v", err)
+	}
+
+	items, _ := s.ListInboxItems("user-1", true, 1)
+	if len(items) != 1 || items[0].ID != "failure|b" {
+		t.Errorf("ListInboxItems() unread newest = %v, want failure|b", items)
+	}
+	if count, _ := s.CountUnreadInboxItems("user-1"); count != 2 {
+		t.Errorf("CountUnreadInboxItems() = %d, want 2", count)
+	}
+	if marked, _ := s.MarkAllInboxItemsRead("user-1"); marked != 2 {
+		t.Errorf("MarkAllInboxItemsRead() = %d, want 2", marked)
+	}
+	if count, _ := s.CountUnreadInboxItems("user-1"); count != 0 {
+		t.Errorf("CountUnreadInboxItems() after mark all = %d, want 0", count)
+	}
+}
+
+func TestMemoryStore_ClientCertificates(t *testing.T) {
+	s := NewMemoryStore()
+	fingerprint := strings.Repeat("ab", 32)
+
+	cert := &models.ClientCertificate{ID: "cert-1", UserID: "user-1", Name: "fleet", Fingerprint: fingerprint, CreatedAt: time.Now()}
+	if err := s.CreateClientCertificate(cert); err != nil {
+		t.Fatalf("CreateClientCertificate() error = %v", err)
+	}
+
+	duplicate := &models.ClientCertificate{ID: "cert-2", UserID: "user-2", Name: "copy", Fingerprint: fingerprint, CreatedAt: time.Now()}
+	if err := s.CreateClientCertificate(duplicate); err != ErrAlreadyExists {
+		t.Errorf("CreateClientCertificate() duplicate error = %v, want %v", err, ErrAlreadyExists)
+	}
+
+	got, err := s.GetClientCertificateByFingerprint(fingerprint)
+	if err != nil || got.UserID != "user-1" {
+		t.Errorf("GetClientCertificateByFingerprint() = %v, %v, want user-1", got, err)
+	}
+
+	if err := s.DeleteClientCertificate("user-2", "cert-1"); err != ErrNotFound {
+		t.Errorf("DeleteClientCertificate() other user error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.DeleteClientCertificate("user-1", "cert-1"); err != nil {
+		t.Fatalf("DeleteClientCertificate() error = %v", err)
+	}
+	if certs, _ := s.ListClientCertificatesByUser("user-1"); len(certs) != 0 {
+		t.Errorf("ListClientCertificatesByUser() after delete = %v, want empty", certs)
+	}
+}
+
+// Orphans cannot be created through the Store interface, so this test removes the agent directly
+func TestMemoryStore_CheckIntegrityOrphans(t *testing.T) {
+	s := NewMemoryStore()
+	now := time.Now()
+	for _, agentID := range []string{"gone", "kept"} {
+		if err := s.CreateOrUpdateAgent(&models.Agent{AgentID: agentID, Name: agentID, Registered: now, LastSeen: now}); err != nil {
+			t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+		}
+		if err := s.CreateOrUpdateSession(&models.Session{AgentID: agentID, SessionTopic: "task", Created: now, LastUpdated: now, TTLMinutes: 30}); err != nil {
+			t.Fatalf("CreateOrUpdateSession() error = %v", err)
+		}
+		if err := s.AddStatus(&models.AgentStatus{AgentID: agentID, SessionTopic: "task", Status: "running", Timestamp: now}); err != nil {
+			t.Fatalf("AddStatus() error = %v", err)
+		}
+	}
+	delete(s.agents, "gone")
+	delete(s.sessions["kept"], "task")
+
+	issues, err := s.CheckIntegrity(true)
+	if err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+	var kinds []string
+	for _, issue := range issues {
+		kinds = append(kinds, issue.Kind+" "+issue.AgentID)
+	}
+	want := "orphaned_session gone,orphaned_status kept,ownerless_agent kept"
+	if strings.Join(kinds, ",") != want {
+		t.Errorf("CheckIntegrity() = %v, want %s", kinds, want)
+	}
+
+	if history, _ := s.GetStatusHistory("gone", "task"); len(history) != 0 {
+		t.Errorf("GetStatusHistory() of the orphaned session = %d statuses, want them removed", len(history))
+	}
+	if history, _ := s.GetStatusHistory("kept", "task"); len(history) != 0 {
+		t.Errorf("GetStatusHistory() of orphaned statuses = %d statuses, want them removed", len(history))
+	}
+	if issues, _ := s.CheckIntegrity(false); len(issues) != 1 {
+		t.Errorf("CheckIntegrity() after repair = %+v, want only the ownerless agent", issues)
+	}
+}
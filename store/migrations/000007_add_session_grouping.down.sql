@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_agent_group;
+ALTER TABLE sessions DROP COLUMN IF EXISTS category;
+ALTER TABLE sessions DROP COLUMN IF EXISTS session_group;
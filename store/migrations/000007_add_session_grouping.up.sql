@@ -0,0 +1,5 @@
+-- Derived session grouping (from configurable topic rules)
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS session_group VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS category VARCHAR(100) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_sessions_agent_group ON sessions(agent_id, session_group);
@@ -0,0 +1,5 @@
+-- Drop SLA tables
+DROP INDEX IF EXISTS idx_sla_breaches_sla_detected;
+DROP TABLE IF EXISTS sla_breaches;
+DROP INDEX IF EXISTS idx_slas_user_id;
+DROP TABLE IF EXISTS slas;
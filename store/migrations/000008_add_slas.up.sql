@@ -0,0 +1,32 @@
+-- SLA definitions per agent or topic pattern
+CREATE TABLE IF NOT EXISTS slas (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    agent_id VARCHAR(100) NOT NULL DEFAULT '',
+    topic_pattern VARCHAR(500) NOT NULL DEFAULT '',
+    max_duration_minutes INTEGER NOT NULL DEFAULT 0,
+    max_failure_rate DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Index for listing SLAs by user
+CREATE INDEX IF NOT EXISTS idx_slas_user_id ON slas(user_id);
+
+-- Recorded SLA breaches, one per SLA, kind, agent and subject
+CREATE TABLE IF NOT EXISTS sla_breaches (
+    id VARCHAR(36) PRIMARY KEY,
+    sla_id VARCHAR(36) NOT NULL REFERENCES slas(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL,
+    agent_id VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    subject VARCHAR(500) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL,
+    detected_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (sla_id, kind, agent_id, subject)
+);
+
+-- Index for listing recent breaches of an SLA
+CREATE INDEX IF NOT EXISTS idx_sla_breaches_sla_detected ON sla_breaches(sla_id, detected_at DESC);
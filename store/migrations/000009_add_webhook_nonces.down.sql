@@ -0,0 +1,3 @@
+-- Drop webhook nonces table
+DROP INDEX IF EXISTS idx_webhook_nonces_expires_at;
+DROP TABLE IF EXISTS webhook_nonces;
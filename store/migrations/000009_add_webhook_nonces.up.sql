@@ -0,0 +1,10 @@
+-- Recently seen nonces of signed webhook requests, kept until their timestamp leaves the freshness window
+CREATE TABLE IF NOT EXISTS webhook_nonces (
+    scope VARCHAR(100) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (scope, nonce)
+);
+
+-- Index for purging expired nonces
+CREATE INDEX IF NOT EXISTS idx_webhook_nonces_expires_at ON webhook_nonces(expires_at);
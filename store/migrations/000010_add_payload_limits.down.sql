@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS plan;
+ALTER TABLE agent_statuses DROP COLUMN IF EXISTS metadata;
+ALTER TABLE agent_statuses ALTER COLUMN message TYPE VARCHAR(1000) USING LEFT(message, 1000);
@@ -0,0 +1,6 @@
+-- Status payload size is limited at ingestion per deployment and plan
+ALTER TABLE agent_statuses ALTER COLUMN message TYPE TEXT;
+ALTER TABLE agent_statuses ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+-- Quota tier used to resolve per-plan limits
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Refresh tokens are now stored under an HMAC-SHA256 hash; rows hashed the old way can never match
+DELETE FROM refresh_tokens;
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sla_breaches_detected_at;
+DROP INDEX IF EXISTS idx_users_verify_token_expires_at;
+ALTER TABLE users DROP COLUMN IF EXISTS verify_token_expires_at;
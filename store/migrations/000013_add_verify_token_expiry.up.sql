@@ -0,0 +1,10 @@
+-- Verification tokens expire and are cleared by the janitor
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verify_token_expires_at TIMESTAMPTZ;
+
+-- Give tokens issued before expiry existed a full window from now
+UPDATE users
+SET verify_token_expires_at = NOW() + INTERVAL '24 hours'
+WHERE verify_token IS NOT NULL AND verify_token <> '';
+
+CREATE INDEX IF NOT EXISTS idx_users_verify_token_expires_at ON users(verify_token_expires_at);
+CREATE INDEX IF NOT EXISTS idx_sla_breaches_detected_at ON sla_breaches(detected_at);
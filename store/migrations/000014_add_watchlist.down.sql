@@ -0,0 +1,2 @@
+-- Drop watchlist table
+DROP TABLE IF EXISTS watch_items;
@@ -0,0 +1,11 @@
+-- Agents starred and sessions watched per user; an empty session_topic stars the agent
+CREATE TABLE IF NOT EXISTS watch_items (
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    agent_id VARCHAR(100) NOT NULL,
+    session_topic VARCHAR(500) NOT NULL DEFAULT '',
+    notification_webhook_url TEXT,
+    mute_notifications BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, agent_id, session_topic)
+);
@@ -0,0 +1,4 @@
+-- Drop inbox table
+DROP INDEX IF EXISTS idx_inbox_items_user_unread;
+DROP INDEX IF EXISTS idx_inbox_items_user_created;
+DROP TABLE IF EXISTS inbox_items;
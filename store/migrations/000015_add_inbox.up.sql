@@ -0,0 +1,19 @@
+-- User-facing notifications shown in the dashboard inbox, one per user and event
+CREATE TABLE IF NOT EXISTS inbox_items (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    agent_id VARCHAR(100) NOT NULL,
+    session_topic VARCHAR(500) NOT NULL DEFAULT '',
+    message TEXT NOT NULL DEFAULT '',
+    dedupe_key VARCHAR(700) NOT NULL,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (user_id, dedupe_key)
+);
+
+-- Index for listing a user's newest items
+CREATE INDEX IF NOT EXISTS idx_inbox_items_user_created ON inbox_items(user_id, created_at DESC);
+
+-- Index for unread counts
+CREATE INDEX IF NOT EXISTS idx_inbox_items_user_unread ON inbox_items(user_id) WHERE read = false;
@@ -0,0 +1,2 @@
+-- Chat users @-mentioned in notifications, as a JSON array of mention rules
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notification_mentions JSONB;
@@ -0,0 +1,3 @@
+-- Drop client certificates table
+DROP INDEX IF EXISTS idx_client_certificates_user_id;
+DROP TABLE IF EXISTS client_certificates;
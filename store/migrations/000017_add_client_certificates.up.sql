@@ -0,0 +1,12 @@
+-- Client certificates accepted on the mTLS webhook listener, identified by fingerprint
+CREATE TABLE IF NOT EXISTS client_certificates (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    fingerprint CHAR(64) NOT NULL UNIQUE,
+    agent_id VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Index for listing certificates by user
+CREATE INDEX IF NOT EXISTS idx_client_certificates_user_id ON client_certificates(user_id);
@@ -0,0 +1,2 @@
+ALTER TABLE agent_statuses DROP COLUMN IF EXISTS revision;
+ALTER TABLE sessions DROP COLUMN IF EXISTS revision;
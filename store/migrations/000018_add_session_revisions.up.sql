@@ -0,0 +1,3 @@
+-- Late reports after the reopen grace period start a new revision of an expired session
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE agent_statuses ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+-- Drop running board indexes
+DROP INDEX IF EXISTS idx_sessions_active_agent;
+DROP INDEX IF EXISTS idx_agent_statuses_session_revision;
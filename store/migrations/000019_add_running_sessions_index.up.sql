@@ -0,0 +1,7 @@
+-- Speed up finding the first and latest status of a session's current revision for the running board
+CREATE INDEX IF NOT EXISTS idx_agent_statuses_session_revision
+    ON agent_statuses(agent_id, session_topic, revision, timestamp);
+
+-- Most sessions are expired, so the board only scans the active ones
+CREATE INDEX IF NOT EXISTS idx_sessions_active_agent
+    ON sessions(agent_id) WHERE expired = false;
@@ -0,0 +1,2 @@
+-- Extra receivers of status notifications, as a JSON array of URL templates and payload formats
+ALTER TABLE users ADD COLUMN IF NOT EXISTS notification_destinations JSONB;
@@ -0,0 +1,13 @@
+-- Side effects of status reports, written in the same transaction as the status and removed once delivered
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Index for claiming due messages
+CREATE INDEX IF NOT EXISTS idx_outbox_available ON outbox(available_at);
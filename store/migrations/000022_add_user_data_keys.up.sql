@@ -0,0 +1,2 @@
+-- Per-user data key for encrypting status message and content, wrapped by the master key
+ALTER TABLE users ADD COLUMN IF NOT EXISTS data_key BYTEA;
@@ -0,0 +1,2 @@
+-- Keep 1 of every N repeated running statuses of an agent; 0 keeps them all
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS heartbeat_sample_every INTEGER NOT NULL DEFAULT 0;
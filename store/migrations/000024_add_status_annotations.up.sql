@@ -0,0 +1,16 @@
+-- Post-mortem annotations on status entries, kept apart from agent-reported data
+CREATE TABLE IF NOT EXISTS status_annotations (
+    id VARCHAR(36) PRIMARY KEY,
+    status_id BIGINT NOT NULL REFERENCES agent_statuses(id) ON DELETE CASCADE,
+    agent_id VARCHAR(100) NOT NULL,
+    session_topic VARCHAR(500) NOT NULL,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    investigator VARCHAR(200) NOT NULL DEFAULT '',
+    root_cause TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    links JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Index for listing a session's annotations with its history
+CREATE INDEX IF NOT EXISTS idx_status_annotations_session ON status_annotations(agent_id, session_topic, created_at);
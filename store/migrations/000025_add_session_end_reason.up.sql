@@ -0,0 +1,5 @@
+-- Why the current run of a session ended: agent_reported, ttl_expired, cancelled or cleanup
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS end_reason VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Sessions that expired before end reasons were recorded ran out of TTL
+UPDATE sessions SET end_reason = 'ttl_expired' WHERE expired = true AND end_reason = '';
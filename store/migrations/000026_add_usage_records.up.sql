@@ -0,0 +1,12 @@
+-- Daily usage per user, the basis for chargeback
+CREATE TABLE IF NOT EXISTS usage_records (
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    status_reports BIGINT NOT NULL DEFAULT 0,
+    storage_bytes BIGINT NOT NULL DEFAULT 0,
+    notifications_sent BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+-- Index for exporting every user's usage of a period
+CREATE INDEX IF NOT EXISTS idx_usage_records_day ON usage_records(day, user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS enrollment_tokens;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS agent_id;
@@ -0,0 +1,20 @@
+-- Agent tokens issued by enrollment are API keys restricted to reporting for one agent
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS agent_id VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Short-lived, single-use tokens a new agent exchanges for its agent token
+CREATE TABLE IF NOT EXISTS enrollment_tokens (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(8) NOT NULL,
+    agent_id VARCHAR(100) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    used_by_agent_id VARCHAR(100) NOT NULL DEFAULT '',
+    api_key_id VARCHAR(36) NOT NULL DEFAULT ''
+);
+
+-- Index for listing tokens by user
+CREATE INDEX IF NOT EXISTS idx_enrollment_tokens_user_id ON enrollment_tokens(user_id);
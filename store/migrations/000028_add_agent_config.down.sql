@@ -0,0 +1,2 @@
+ALTER TABLE agents DROP COLUMN IF EXISTS config_version;
+ALTER TABLE agents DROP COLUMN IF EXISTS config;
@@ -0,0 +1,3 @@
+-- Configuration the owner sets for an agent, delivered to it in webhook and keepalive responses
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS config JSONB;
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS config_version INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,14 @@
+-- Admin requests that read or changed data across tenants; kept when the actor is deleted
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id VARCHAR(36) NOT NULL,
+    actor_email VARCHAR(255) NOT NULL DEFAULT '',
+    action VARCHAR(500) NOT NULL,
+    target VARCHAR(100) NOT NULL DEFAULT '',
+    query VARCHAR(2000) NOT NULL DEFAULT '',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Index for listing recent events
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
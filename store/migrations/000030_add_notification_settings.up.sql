@@ -0,0 +1,9 @@
+-- Each user's own notification receiver and the status transitions it is notified of
+CREATE TABLE IF NOT EXISTS notification_settings (
+    user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    webhook_url TEXT NOT NULL DEFAULT '',
+    format VARCHAR(20) NOT NULL DEFAULT '',
+    transitions JSONB, -- JSON array of {"from","to"}; NULL notifies the default transitions
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
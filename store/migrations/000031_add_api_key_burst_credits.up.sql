@@ -0,0 +1,2 @@
+-- Extra webhook requests an API key may save up while it stays under the rate limit
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS burst_credits INTEGER NOT NULL DEFAULT 0;
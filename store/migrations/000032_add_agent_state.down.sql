@@ -0,0 +1,2 @@
+ALTER TABLE agents DROP COLUMN IF EXISTS state_changed_at;
+ALTER TABLE agents DROP COLUMN IF EXISTS state;
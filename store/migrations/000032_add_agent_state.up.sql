@@ -0,0 +1,3 @@
+-- Presence computed by the heartbeat monitor from last_seen: online, stale or offline
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS state VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS state_changed_at TIMESTAMPTZ;
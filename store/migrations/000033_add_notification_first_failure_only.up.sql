@@ -0,0 +1,2 @@
+-- Only notify the first failure of a session until it succeeds again
+ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS first_failure_only BOOLEAN NOT NULL DEFAULT FALSE;
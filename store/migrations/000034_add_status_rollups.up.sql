@@ -0,0 +1,21 @@
+-- Daily summaries of each session's statuses, kept after the raw statuses are pruned
+CREATE TABLE IF NOT EXISTS status_rollups (
+    agent_id VARCHAR(100) NOT NULL,
+    session_topic VARCHAR(500) NOT NULL,
+    day DATE NOT NULL,
+    running INTEGER NOT NULL DEFAULT 0,
+    success INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    pending INTEGER NOT NULL DEFAULT 0,
+    runs INTEGER NOT NULL DEFAULT 0,
+    first_at TIMESTAMPTZ NOT NULL,
+    last_at TIMESTAMPTZ NOT NULL,
+    duration_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    PRIMARY KEY (agent_id, session_topic, day),
+    CONSTRAINT fk_status_rollup_session FOREIGN KEY (agent_id, session_topic)
+        REFERENCES sessions(agent_id, session_topic)
+        ON DELETE CASCADE
+);
+
+-- Index for listing every session's rollups of a period
+CREATE INDEX IF NOT EXISTS idx_status_rollups_day ON status_rollups(day, agent_id);
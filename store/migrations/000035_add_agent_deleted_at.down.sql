@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_agents_deleted_at;
+ALTER TABLE agents DROP COLUMN IF EXISTS deleted_at;
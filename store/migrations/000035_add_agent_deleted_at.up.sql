@@ -0,0 +1,4 @@
+-- Soft-deleted agents keep their sessions and statuses until restored or purged
+ALTER TABLE agents ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_agents_deleted_at ON agents (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- The curated kind of an agent (ci, cron, llm-agent, operator, custom); empty when unclassified
+ALTER TABLE agents ADD COLUMN kind VARCHAR(20) NOT NULL DEFAULT '';
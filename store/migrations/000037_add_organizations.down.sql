@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_agents_org_id;
+ALTER TABLE agents DROP COLUMN IF EXISTS org_id;
+DROP TABLE IF EXISTS invitations;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
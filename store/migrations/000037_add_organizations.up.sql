@@ -0,0 +1,37 @@
+-- Teams whose members share the agents assigned to them
+CREATE TABLE IF NOT EXISTS organizations (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_by VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    org_id VARCHAR(36) NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (org_id, user_id)
+);
+
+-- Index for listing a user's organizations
+CREATE INDEX IF NOT EXISTS idx_memberships_user_id ON memberships(user_id);
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id VARCHAR(36) PRIMARY KEY,
+    org_id VARCHAR(36) NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(8) NOT NULL,
+    invited_by VARCHAR(36) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_org_id ON invitations(org_id);
+
+-- Agents shared with an organization; deleting the organization unshares them
+ALTER TABLE agents ADD COLUMN org_id VARCHAR(36) REFERENCES organizations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_agents_org_id ON agents (org_id) WHERE org_id IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE agents DROP COLUMN IF EXISTS region;
+ALTER TABLE agents DROP COLUMN IF EXISTS cluster;
@@ -0,0 +1,3 @@
+-- The Kubernetes cluster and region an agent last reported running in; empty when unknown
+ALTER TABLE agents ADD COLUMN cluster VARCHAR(63) NOT NULL DEFAULT '';
+ALTER TABLE agents ADD COLUMN region VARCHAR(63) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- The deployment-wide role of a user (admin, member, viewer); empty is a member
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Set while an admin has disabled the account; disabled users cannot sign in or use their API keys
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMPTZ;
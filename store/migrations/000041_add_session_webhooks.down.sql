@@ -0,0 +1,3 @@
+-- Drop session webhooks
+DROP INDEX IF EXISTS idx_session_webhooks_user_id;
+DROP TABLE IF EXISTS session_webhooks;
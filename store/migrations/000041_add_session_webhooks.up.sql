@@ -0,0 +1,14 @@
+-- Automation callbacks receiving the sessions of a user's agents when their runs end
+CREATE TABLE IF NOT EXISTS session_webhooks (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url VARCHAR(2000) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    agent_id VARCHAR(100) NOT NULL DEFAULT '',
+    outcomes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Index for listing and matching webhooks by user
+CREATE INDEX IF NOT EXISTS idx_session_webhooks_user_id ON session_webhooks(user_id);
@@ -0,0 +1,2 @@
+-- Drop the index of failed statuses
+DROP INDEX IF EXISTS idx_agent_statuses_failed;
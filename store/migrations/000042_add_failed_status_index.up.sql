@@ -0,0 +1,2 @@
+-- Recent failures on the dashboard overview are read newest first from failed statuses only
+CREATE INDEX IF NOT EXISTS idx_agent_statuses_failed ON agent_statuses (timestamp DESC, id DESC) WHERE status = 'failed';
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS session_close_on_offline;
+ALTER TABLE users DROP COLUMN IF EXISTS session_close_on_delete;
@@ -0,0 +1,3 @@
+-- What happens to the running sessions of a user's agents when they are deleted or go offline: '', 'fail' or 'expire'
+ALTER TABLE users ADD COLUMN session_close_on_delete VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN session_close_on_offline VARCHAR(10) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Who recorded a status: 'agent' for reported statuses, 'server' for the ones the server inferred, e.g. expired
+ALTER TABLE agent_statuses ADD COLUMN origin VARCHAR(10) NOT NULL DEFAULT 'agent';
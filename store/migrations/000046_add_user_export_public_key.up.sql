@@ -0,0 +1,2 @@
+-- Base64 X25519 public key the user's exports are encrypted to; '' leaves them in the clear
+ALTER TABLE users ADD COLUMN export_public_key VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE agent_statuses DROP COLUMN IF EXISTS content_format;
@@ -0,0 +1,2 @@
+-- How a status's content is written: '' or 'text' for plain text, 'markdown' for markdown
+ALTER TABLE agent_statuses ADD COLUMN content_format VARCHAR(16) NOT NULL DEFAULT '';
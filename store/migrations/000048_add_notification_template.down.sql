@@ -0,0 +1 @@
+ALTER TABLE notification_settings DROP COLUMN IF EXISTS template;
@@ -0,0 +1,2 @@
+-- Go text/template rendering the messages of a user's own receivers; '' uses the built-in message
+ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS template TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- Drop alert rules table
+DROP INDEX IF EXISTS idx_alert_rules_user_id;
+DROP TABLE IF EXISTS alert_rules;
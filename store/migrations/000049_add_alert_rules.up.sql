@@ -0,0 +1,16 @@
+-- User-defined alert rules evaluated against their agents and sessions
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    agent_id VARCHAR(100) NOT NULL DEFAULT '',
+    topic_pattern VARCHAR(500) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT '',
+    duration_minutes INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Index for listing alert rules by user
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user_id ON alert_rules(user_id);
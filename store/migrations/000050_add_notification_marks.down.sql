@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_notification_marks_status;
+DROP TABLE IF EXISTS notification_marks;
+ALTER TABLE notification_settings DROP COLUMN IF EXISTS escalate_after_minutes;
+ALTER TABLE notification_settings DROP COLUMN IF EXISTS dedup_minutes;
@@ -0,0 +1,17 @@
+-- Dedup window and escalation delay of a user's own status notifications; 0 disables either
+ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS dedup_minutes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE notification_settings ADD COLUMN IF NOT EXISTS escalate_after_minutes INTEGER NOT NULL DEFAULT 0;
+
+-- When each user was last notified of a session reaching a status
+CREATE TABLE IF NOT EXISTS notification_marks (
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    agent_id VARCHAR(100) NOT NULL,
+    session_topic VARCHAR(500) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    notified_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    escalations INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, agent_id, session_topic, status)
+);
+
+-- Index for listing the marks of a status and purging old ones
+CREATE INDEX IF NOT EXISTS idx_notification_marks_status ON notification_marks(status, notified_at);
@@ -0,0 +1,9 @@
+-- Maintained counts of each user's agents, sessions and statuses, reconciled with the tables periodically
+CREATE TABLE IF NOT EXISTS tenant_counts (
+    user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    agents BIGINT NOT NULL DEFAULT 0,
+    sessions BIGINT NOT NULL DEFAULT 0,
+    statuses BIGINT NOT NULL DEFAULT 0,
+    reconciled_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+-- Casing of JSON field names in responses to an API key; empty keeps snake_case
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS field_casing VARCHAR(10) NOT NULL DEFAULT '';
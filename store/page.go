@@ -0,0 +1,25 @@
+package store
+
+// Page selects a window of an ordered listing
+type Page struct {
+	Offset int
+	Limit  int // 0 returns every item from Offset on
+}
+
+// pageOf returns the page's window of a listing that was loaded in full
+func pageOf[T any](items []T, page Page) []T {
+	start := min(page.Offset, len(items))
+	end := len(items)
+	if page.Limit > 0 {
+		end = min(start+page.Limit, end)
+	}
+	return items[start:end]
+}
+
+// limitArg returns the page limit as a SQL LIMIT argument, where NULL means no limit
+func (p Page) limitArg() any {
+	if p.Limit <= 0 {
+		return nil
+	}
+	return p.Limit
+}
@@ -2,19 +2,23 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/kubeagents/kubeagents/clock"
 	"github.com/kubeagents/kubeagents/models"
 )
 
 // PostgresStore implements Store interface using PostgreSQL
 type PostgresStore struct {
-	pool *pgxpool.Pool
+	pool  *pgxpool.Pool
+	clock clock.Clock // Decides expiry of sessions, tokens and nonces
 }
 
 // NewPostgresStore creates a new PostgreSQL store connection
@@ -24,7 +28,12 @@ func NewPostgresStore(ctx context.Context, connString string) (*PostgresStore, e
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
 
-	return &PostgresStore{pool: pool}, nil
+	return &PostgresStore{pool: pool, clock: clock.Real}, nil
+}
+
+// SetClock replaces the clock that decides expiry; call it before the store is used
+func (s *PostgresStore) SetClock(c clock.Clock) {
+	s.clock = c
 }
 
 // Pool returns the underlying connection pool
@@ -38,35 +47,104 @@ func (s *PostgresStore) Close() error {
 	return nil
 }
 
+// agentColumns is the column list used by all agent queries, matching scanAgent
+const agentColumns = `agent_id, COALESCE(user_id, ''), name, source, kind, registered, last_seen, version, heartbeat_sample_every,
+	COALESCE(config::text, ''), config_version, state, state_changed_at, deleted_at, COALESCE(org_id, ''), cluster, region`
+
+// scanAgent scans a row selected with agentColumns
+func scanAgent(row pgx.Row) (*models.Agent, error) {
+	var agent models.Agent
+	var config string
+	err := row.Scan(
+		&agent.AgentID,
+		&agent.UserID,
+		&agent.Name,
+		&agent.Source,
+		&agent.Kind,
+		&agent.Registered,
+		&agent.LastSeen,
+		&agent.Version,
+		&agent.HeartbeatSampleEvery,
+		&config,
+		&agent.ConfigVersion,
+		&agent.State,
+		&agent.StateChangedAt,
+		&agent.DeletedAt,
+		&agent.OrgID,
+		&agent.Cluster,
+		&agent.Region,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if config != "" {
+		agent.Config = json.RawMessage(config)
+	}
+	return &agent, nil
+}
+
 // CreateOrUpdateAgent creates or updates an agent
 func (s *PostgresStore) CreateOrUpdateAgent(agent *models.Agent) error {
 	if err := agent.Validate(); err != nil {
-		return err
+		return invalid(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// The update only applies when the caller read the current version; otherwise no row is returned
 	query := `
-		INSERT INTO agents (agent_id, user_id, name, source, registered, last_seen)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO agents (agent_id, user_id, name, source, registered, last_seen, version, heartbeat_sample_every, config, config_version,
+		                    state, state_changed_at, deleted_at, kind, org_id, cluster, region)
+		VALUES ($1, $2, $3, $4, $5, $6, 1, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17)
 		ON CONFLICT (agent_id) DO UPDATE
 		SET name = EXCLUDED.name,
 		    source = EXCLUDED.source,
+		    kind = EXCLUDED.kind,
 		    last_seen = EXCLUDED.last_seen,
-		    user_id = COALESCE(agents.user_id, EXCLUDED.user_id)
+		    user_id = COALESCE(agents.user_id, EXCLUDED.user_id),
+		    heartbeat_sample_every = EXCLUDED.heartbeat_sample_every,
+		    config = EXCLUDED.config,
+		    config_version = EXCLUDED.config_version,
+		    state = EXCLUDED.state,
+		    state_changed_at = EXCLUDED.state_changed_at,
+		    deleted_at = EXCLUDED.deleted_at,
+		    org_id = EXCLUDED.org_id,
+		    cluster = EXCLUDED.cluster,
+		    region = EXCLUDED.region,
+		    version = agents.version + 1
+		WHERE agents.version = $7 AND (agents.deleted_at IS NULL OR EXCLUDED.deleted_at IS NOT NULL)
+		RETURNING version
 	`
 
-	_, err := s.pool.Exec(ctx, query,
+	err := s.pool.QueryRow(ctx, query,
 		agent.AgentID,
 		agent.UserID,
 		agent.Name,
 		agent.Source,
 		agent.Registered,
 		agent.LastSeen,
-	)
+		agent.Version,
+		agent.HeartbeatSampleEvery,
+		nullableJSON(agent.Config),
+		agent.ConfigVersion,
+		agent.State,
+		agent.StateChangedAt,
+		agent.DeletedAt,
+		agent.Kind,
+		agent.OrgID,
+		agent.Cluster,
+		agent.Region,
+	).Scan(&agent.Version)
 
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			var deleted bool
+			if err := s.pool.QueryRow(ctx, `SELECT deleted_at IS NOT NULL FROM agents WHERE agent_id = $1`, agent.AgentID).Scan(&deleted); err == nil && deleted && agent.DeletedAt == nil {
+				return ErrAgentDeleted
+			}
+			return ErrConflict
+		}
 		return fmt.Errorf("failed to create/update agent: %w", err)
 	}
 
@@ -79,23 +157,12 @@ func (s *PostgresStore) GetAgent(agentID string) (*models.Agent, error) {
 	defer cancel()
 
 	query := `
-		SELECT agent_id, COALESCE(user_id, ''), name, source, registered, last_seen
+		SELECT ` + agentColumns + `
 		FROM agents
-		WHERE agent_id = $1
+		WHERE agent_id = $1 AND deleted_at IS NULL
 	`
 
-	row := s.pool.QueryRow(ctx, query, agentID)
-
-	var agent models.Agent
-	err := row.Scan(
-		&agent.AgentID,
-		&agent.UserID,
-		&agent.Name,
-		&agent.Source,
-		&agent.Registered,
-		&agent.LastSeen,
-	)
-
+	agent, err := scanAgent(s.pool.QueryRow(ctx, query, agentID))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
@@ -103,7 +170,7 @@ func (s *PostgresStore) GetAgent(agentID string) (*models.Agent, error) {
 		return nil, fmt.Errorf("failed to get agent: %w", err)
 	}
 
-	return &agent, nil
+	return agent, nil
 }
 
 // ListAgents returns all agents
@@ -112,8 +179,9 @@ func (s *PostgresStore) ListAgents() []*models.Agent {
 	defer cancel()
 
 	query := `
-		SELECT agent_id, COALESCE(user_id, ''), name, source, registered, last_seen
+		SELECT ` + agentColumns + `
 		FROM agents
+		WHERE deleted_at IS NULL
 		ORDER BY last_seen DESC
 	`
 
@@ -125,18 +193,11 @@ func (s *PostgresStore) ListAgents() []*models.Agent {
 
 	var agents []*models.Agent
 	for rows.Next() {
-		var agent models.Agent
-		if err := rows.Scan(
-			&agent.AgentID,
-			&agent.UserID,
-			&agent.Name,
-			&agent.Source,
-			&agent.Registered,
-			&agent.LastSeen,
-		); err != nil {
+		agent, err := scanAgent(rows)
+		if err != nil {
 			continue
 		}
-		agents = append(agents, &agent)
+		agents = append(agents, agent)
 	}
 
 	return agents
@@ -144,61 +205,233 @@ func (s *PostgresStore) ListAgents() []*models.Agent {
 
 // ListAgentsByUser returns all agents belonging to a specific user
 func (s *PostgresStore) ListAgentsByUser(userID string) []*models.Agent {
+	agents, _, err := s.QueryAgents(Query{UserID: userID})
+	if err != nil {
+		return []*models.Agent{}
+	}
+	return agents
+}
+
+// CountAgentsByUsers returns how many agents each of the users has
+func (s *PostgresStore) CountAgentsByUsers(userIDs []string) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT user_id, COUNT(*) FROM agents
+		WHERE user_id = ANY($1) AND deleted_at IS NULL
+		GROUP BY user_id`, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count agents: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var userID string
+		var count int
+		if err := rows.Scan(&userID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan agent count: %w", err)
+		}
+		counts[userID] = count
+	}
+	return counts, rows.Err()
+}
+
+// QueryAgents returns one page of the agents a query selects, most recently seen first, and how many it selects
+func (s *PostgresStore) QueryAgents(q Query) ([]*models.Agent, int, error) {
+	if err := q.validateAgents(); err != nil {
+		return nil, 0, invalid(err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	filter := q.agentsFilter()
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM agents `+filter.where(), filter.args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count agents: %w", err)
+	}
+
 	query := `
-		SELECT agent_id, COALESCE(user_id, ''), name, source, registered, last_seen
+		SELECT ` + agentColumns + `
 		FROM agents
-		WHERE user_id = $1
-		ORDER BY last_seen DESC
+		` + filter.where() + `
+		ORDER BY last_seen DESC, agent_id
+		LIMIT ` + filter.arg(q.Page.limitArg()) + ` OFFSET ` + filter.arg(q.Page.Offset)
+
+	rows, err := s.pool.Query(ctx, query, filter.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list agents: %w", err)
+	}
+	defer rows.Close()
+
+	agents := make([]*models.Agent, 0)
+	for rows.Next() {
+		agent, err := scanAgent(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan agent: %w", err)
+		}
+		agents = append(agents, agent)
+	}
+
+	return agents, total, rows.Err()
+}
+
+// ListAgentsByOrg returns an organization's agents, most recently seen first
+func (s *PostgresStore) ListAgentsByOrg(orgID string) ([]*models.Agent, error) {
+	agents, _, err := s.QueryAgents(Query{OrgID: orgID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization agents: %w", err)
+	}
+	return agents, nil
+}
+
+// DeleteAgent soft-deletes an agent
+func (s *PostgresStore) DeleteAgent(agentID string, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `
+		UPDATE agents SET deleted_at = $2, version = version + 1
+		WHERE agent_id = $1 AND deleted_at IS NULL`, agentID, at)
+	if err != nil {
+		return fmt.Errorf("failed to delete agent: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RestoreAgent undoes DeleteAgent
+func (s *PostgresStore) RestoreAgent(agentID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `
+		UPDATE agents SET deleted_at = NULL, version = version + 1
+		WHERE agent_id = $1 AND deleted_at IS NOT NULL`, agentID)
+	if err != nil {
+		return fmt.Errorf("failed to restore agent: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListDeletedAgents returns soft-deleted agents, most recently deleted first
+func (s *PostgresStore) ListDeletedAgents(userID string) ([]*models.Agent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + agentColumns + `
+		FROM agents
+		WHERE deleted_at IS NOT NULL AND ($1 = '' OR user_id = $1)
+		ORDER BY deleted_at DESC, agent_id
 	`
 
 	rows, err := s.pool.Query(ctx, query, userID)
 	if err != nil {
-		return []*models.Agent{}
+		return nil, fmt.Errorf("failed to list deleted agents: %w", err)
 	}
 	defer rows.Close()
 
-	var agents []*models.Agent
+	agents := make([]*models.Agent, 0)
 	for rows.Next() {
-		var agent models.Agent
-		if err := rows.Scan(
-			&agent.AgentID,
-			&agent.UserID,
-			&agent.Name,
-			&agent.Source,
-			&agent.Registered,
-			&agent.LastSeen,
-		); err != nil {
-			continue
+		agent, err := scanAgent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan deleted agent: %w", err)
 		}
-		agents = append(agents, &agent)
+		agents = append(agents, agent)
 	}
+	return agents, rows.Err()
+}
 
-	return agents
+// PurgeDeletedAgents removes agents soft-deleted before the cutoff; their sessions and statuses
+// go with them through the foreign keys
+func (s *PostgresStore) PurgeDeletedAgents(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM watch_items
+		WHERE agent_id IN (SELECT agent_id FROM agents WHERE deleted_at < $1)`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge watch items of deleted agents: %w", err)
+	}
+	result, err := tx.Exec(ctx, `DELETE FROM agents WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted agents: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// sessionColumns is the column list used by all session queries, matching scanSession
+const sessionColumns = `agent_id, session_topic, created, last_updated, expired, expired_at, ttl_minutes, session_group, category, revision, end_reason, version`
+
+// scanSession scans a row selected with sessionColumns
+func scanSession(row pgx.Row) (*models.Session, error) {
+	var session models.Session
+	err := row.Scan(
+		&session.AgentID,
+		&session.SessionTopic,
+		&session.Created,
+		&session.LastUpdated,
+		&session.Expired,
+		&session.ExpiredAt,
+		&session.TTLMinutes,
+		&session.Group,
+		&session.Category,
+		&session.Revision,
+		&session.EndReason,
+		&session.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
 }
 
 // CreateOrUpdateSession creates or updates a session
 func (s *PostgresStore) CreateOrUpdateSession(session *models.Session) error {
 	if err := session.Validate(); err != nil {
-		return err
+		return invalid(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		INSERT INTO sessions (agent_id, session_topic, created, last_updated, expired, expired_at, ttl_minutes)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO sessions (` + sessionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, 1)
 		ON CONFLICT (agent_id, session_topic) DO UPDATE
-		SET last_updated = EXCLUDED.last_updated,
+		SET created = EXCLUDED.created,
+		    last_updated = EXCLUDED.last_updated,
 		    expired = EXCLUDED.expired,
 		    expired_at = EXCLUDED.expired_at,
-		    ttl_minutes = EXCLUDED.ttl_minutes
+		    ttl_minutes = EXCLUDED.ttl_minutes,
+		    session_group = EXCLUDED.session_group,
+		    category = EXCLUDED.category,
+		    revision = EXCLUDED.revision,
+		    end_reason = EXCLUDED.end_reason,
+		    version = sessions.version + 1
+		WHERE sessions.version = $12
+		RETURNING version
 	`
 
-	_, err := s.pool.Exec(ctx, query,
+	err := s.pool.QueryRow(ctx, query,
 		session.AgentID,
 		session.SessionTopic,
 		session.Created,
@@ -206,9 +439,17 @@ func (s *PostgresStore) CreateOrUpdateSession(session *models.Session) error {
 		session.Expired,
 		session.ExpiredAt,
 		session.TTLMinutes,
-	)
+		session.Group,
+		session.Category,
+		session.Revision,
+		session.EndReason,
+		session.Version,
+	).Scan(&session.Version)
 
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrConflict
+		}
 		return fmt.Errorf("failed to create/update session: %w", err)
 	}
 
@@ -221,24 +462,12 @@ func (s *PostgresStore) GetSession(agentID, sessionTopic string) (*models.Sessio
 	defer cancel()
 
 	query := `
-		SELECT agent_id, session_topic, created, last_updated, expired, expired_at, ttl_minutes
+		SELECT ` + sessionColumns + `
 		FROM sessions
 		WHERE agent_id = $1 AND session_topic = $2
 	`
 
-	row := s.pool.QueryRow(ctx, query, agentID, sessionTopic)
-
-	var session models.Session
-	err := row.Scan(
-		&session.AgentID,
-		&session.SessionTopic,
-		&session.Created,
-		&session.LastUpdated,
-		&session.Expired,
-		&session.ExpiredAt,
-		&session.TTLMinutes,
-	)
-
+	session, err := scanSession(s.pool.QueryRow(ctx, query, agentID, sessionTopic))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
@@ -246,35 +475,102 @@ func (s *PostgresStore) GetSession(agentID, sessionTopic string) (*models.Sessio
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
-	return &session, nil
+	return session, nil
 }
 
 // ListSessions returns all sessions for an agent
 func (s *PostgresStore) ListSessions(agentID string, includeExpired bool) []*models.Session {
+	sessions, _, err := s.QuerySessions(Query{AgentID: agentID, ExcludeExpired: !includeExpired})
+	if err != nil {
+		return []*models.Session{}
+	}
+	return sessions
+}
+
+// QuerySessions returns one page of the sessions a query selects, most recently updated first, and how many
+// it selects
+func (s *PostgresStore) QuerySessions(q Query) ([]*models.Session, int, error) {
+	if err := q.validateSessions(); err != nil {
+		return nil, 0, invalid(err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	filter := q.sessionsFilter()
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM sessions `+filter.where(), filter.args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+
 	query := `
-		SELECT agent_id, session_topic, created, last_updated, expired, expired_at, ttl_minutes
+		SELECT ` + sessionColumns + `
 		FROM sessions
-		WHERE agent_id = $1
-	`
+		` + filter.where() + `
+		ORDER BY last_updated DESC, session_topic
+		LIMIT ` + filter.arg(q.Page.limitArg()) + ` OFFSET ` + filter.arg(q.Page.Offset)
+
+	rows, err := s.pool.Query(ctx, query, filter.args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
 
-	if !includeExpired {
-		query += " AND expired = false"
+	sessions := make([]*models.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, session)
 	}
 
-	query += " ORDER BY last_updated DESC"
+	return sessions, total, nil
+}
+
+// ListRunningSessions returns the user's unexpired sessions whose latest status is running, longest running first
+func (s *PostgresStore) ListRunningSessions(userID string) ([]*models.RunningSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Both lateral lookups are served by idx_agent_statuses_session_revision
+	query := `
+		SELECT s.agent_id, s.session_topic, s.created, s.last_updated, s.expired, s.expired_at, s.ttl_minutes,
+			s.session_group, s.category, s.revision, s.version, COALESCE(a.name, ''),
+			latest.id, latest.status, latest.timestamp, latest.message, latest.content, COALESCE(latest.metadata::text, ''),
+			latest.origin, latest.content_format, started.timestamp
+		FROM agents a
+		JOIN sessions s ON s.agent_id = a.agent_id AND s.expired = false
+		CROSS JOIN LATERAL (
+			SELECT st.id, st.status, st.timestamp, st.message, st.content, st.metadata, st.origin, st.content_format
+			FROM agent_statuses st
+			WHERE st.agent_id = s.agent_id AND st.session_topic = s.session_topic AND st.revision = s.revision
+			ORDER BY st.timestamp DESC
+			LIMIT 1
+		) latest
+		CROSS JOIN LATERAL (
+			SELECT st.timestamp
+			FROM agent_statuses st
+			WHERE st.agent_id = s.agent_id AND st.session_topic = s.session_topic AND st.revision = s.revision
+			ORDER BY st.timestamp ASC
+			LIMIT 1
+		) started
+		WHERE a.user_id = $1 AND a.deleted_at IS NULL AND latest.status = 'running'
+		ORDER BY started.timestamp ASC, s.agent_id, s.session_topic
+	`
 
-	rows, err := s.pool.Query(ctx, query, agentID)
+	rows, err := s.pool.Query(ctx, query, userID)
 	if err != nil {
-		return []*models.Session{}
+		return nil, fmt.Errorf("failed to list running sessions: %w", err)
 	}
 	defer rows.Close()
 
-	var sessions []*models.Session
+	result := make([]*models.RunningSession, 0)
 	for rows.Next() {
 		var session models.Session
+		var status models.AgentStatus
+		var running models.RunningSession
+		var metadata string
 		if err := rows.Scan(
 			&session.AgentID,
 			&session.SessionTopic,
@@ -283,686 +579,3642 @@ func (s *PostgresStore) ListSessions(agentID string, includeExpired bool) []*mod
 			&session.Expired,
 			&session.ExpiredAt,
 			&session.TTLMinutes,
+			&session.Group,
+			&session.Category,
+			&session.Revision,
+			&session.Version,
+			&running.AgentName,
+			&status.ID,
+			&status.Status,
+			&status.Timestamp,
+			&status.Message,
+			&status.Content,
+			&metadata,
+			&status.Origin,
+			&status.ContentFormat,
+			&running.Started,
 		); err != nil {
-			continue
+			return nil, fmt.Errorf("failed to scan running session: %w", err)
+		}
+
+		status.AgentID = session.AgentID
+		status.SessionTopic = session.SessionTopic
+		status.Revision = session.Revision
+		if metadata != "" {
+			status.Metadata = json.RawMessage(metadata)
 		}
-		sessions = append(sessions, &session)
+		running.Session = &session
+		running.Latest = &status
+		result = append(result, &running)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list running sessions: %w", err)
 	}
 
-	return sessions
+	return result, nil
 }
 
-// AddStatus adds a status record to history
-func (s *PostgresStore) AddStatus(status *models.AgentStatus) error {
-	if err := status.Validate(); err != nil {
-		return err
-	}
-
+// GetOverview returns the counts of the user's undeleted agents and their sessions and statuses,
+// with up to failureLimit of the failed statuses reported since since, newest first
+func (s *PostgresStore) GetOverview(userID string, since time.Time, failureLimit int) (*models.Overview, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	query := `
-		INSERT INTO agent_statuses (agent_id, session_topic, status, timestamp, message, content)
-		VALUES ($1, $2, $3, $4, $5, $6)
+	// One round trip for every count; the status counts are served by idx_agent_statuses_timestamp
+	countQuery := `
+		WITH owned AS (
+			SELECT agent_id, state FROM agents WHERE user_id = $1 AND deleted_at IS NULL
+		)
+		SELECT
+			(SELECT COUNT(*) FROM owned),
+			(SELECT COUNT(*) FROM owned WHERE state = 'online'),
+			(SELECT COUNT(*) FROM owned WHERE state = 'stale'),
+			(SELECT COUNT(*) FROM owned WHERE state = 'offline'),
+			(SELECT COUNT(*) FROM sessions s JOIN owned o ON o.agent_id = s.agent_id WHERE s.expired = false),
+			recent.succeeded,
+			recent.failed
+		FROM (
+			SELECT COUNT(*) FILTER (WHERE st.status = 'success') AS succeeded,
+				COUNT(*) FILTER (WHERE st.status = 'failed') AS failed
+			FROM agent_statuses st
+			JOIN owned o ON o.agent_id = st.agent_id
+			WHERE st.timestamp >= $2
+		) recent
 	`
 
-	_, err := s.pool.Exec(ctx, query,
-		status.AgentID,
-		status.SessionTopic,
-		status.Status,
-		status.Timestamp,
-		status.Message,
-		status.Content,
-	)
-
-	if err != nil {
-		return fmt.Errorf("failed to add status: %w", err)
+	overview := &models.Overview{Since: since, RecentFailures: make([]*models.RecentFailure, 0)}
+	if err := s.pool.QueryRow(ctx, countQuery, userID, since).Scan(
+		&overview.Agents.Total,
+		&overview.Agents.Online,
+		&overview.Agents.Stale,
+		&overview.Agents.Offline,
+		&overview.ActiveSessions,
+		&overview.Succeeded,
+		&overview.Failed,
+	); err != nil {
+		return nil, fmt.Errorf("failed to count overview: %w", err)
 	}
 
-	return nil
-}
-
-// GetStatusHistory returns all status records for a session
-func (s *PostgresStore) GetStatusHistory(agentID, sessionTopic string) ([]*models.AgentStatus, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	query := `
-		SELECT id, agent_id, session_topic, status, timestamp, message, content
-		FROM agent_statuses
-		WHERE agent_id = $1 AND session_topic = $2
-		ORDER BY timestamp DESC
+	// Served by idx_agent_statuses_failed, which only holds failed statuses
+	failureQuery := `
+		SELECT st.id, st.agent_id, st.session_topic, st.status, st.timestamp, st.message, st.content,
+			COALESCE(st.metadata::text, ''), st.revision, st.origin, st.content_format, COALESCE(a.name, '')
+		FROM agent_statuses st
+		JOIN agents a ON a.agent_id = st.agent_id
+		WHERE a.user_id = $1 AND a.deleted_at IS NULL AND st.status = 'failed' AND st.timestamp >= $2
+		ORDER BY st.timestamp DESC, st.id DESC
+		LIMIT $3
 	`
 
-	rows, err := s.pool.Query(ctx, query, agentID, sessionTopic)
+	limit := Page{Limit: failureLimit}
+	rows, err := s.pool.Query(ctx, failureQuery, userID, since, limit.limitArg())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get status history: %w", err)
+		return nil, fmt.Errorf("failed to list recent failures: %w", err)
 	}
 	defer rows.Close()
 
-	var statuses []*models.AgentStatus
 	for rows.Next() {
 		var status models.AgentStatus
+		var failure models.RecentFailure
+		var metadata string
 		if err := rows.Scan(
-			new(interface{}), // id - not used
+			&status.ID,
 			&status.AgentID,
 			&status.SessionTopic,
 			&status.Status,
 			&status.Timestamp,
 			&status.Message,
 			&status.Content,
+			&metadata,
+			&status.Revision,
+			&status.Origin,
+			&status.ContentFormat,
+			&failure.AgentName,
 		); err != nil {
-			continue
+			return nil, fmt.Errorf("failed to scan recent failure: %w", err)
 		}
-		statuses = append(statuses, &status)
+		if metadata != "" {
+			status.Metadata = json.RawMessage(metadata)
+		}
+		failure.Status = &status
+		overview.RecentFailures = append(overview.RecentFailures, &failure)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list recent failures: %w", err)
 	}
 
-	return statuses, nil
+	return overview, nil
 }
 
-// GetLatestStatus returns the latest status for a session
-func (s *PostgresStore) GetLatestStatus(agentID, sessionTopic string) (*models.AgentStatus, error) {
+// AddStatus adds a status record to history
+func (s *PostgresStore) AddStatus(status *models.AgentStatus) error {
+	if err := status.Validate(); err != nil {
+		return invalid(err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	query := `
-		SELECT agent_id, session_topic, status, timestamp, message, content
-		FROM agent_statuses
-		WHERE agent_id = $1 AND session_topic = $2
-		ORDER BY timestamp DESC
-		LIMIT 1
-	`
+	err := s.pool.QueryRow(ctx, insertStatusQuery, statusArgs(status)...).Scan(&status.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add status: %w", err)
+	}
 
-	row := s.pool.QueryRow(ctx, query, agentID, sessionTopic)
+	return nil
+}
 
-	var status models.AgentStatus
-	err := row.Scan(
-		&status.AgentID,
-		&status.SessionTopic,
-		&status.Status,
-		&status.Timestamp,
-		&status.Message,
-		&status.Content,
-	)
+// insertStatusQuery inserts a status with the arguments from statusArgs and returns its ID
+const insertStatusQuery = `
+	INSERT INTO agent_statuses (agent_id, session_topic, status, timestamp, message, content, metadata, revision, origin, content_format)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id
+`
 
-	if err != nil {
+// statusArgs returns the arguments of insertStatusQuery
+func statusArgs(status *models.AgentStatus) []interface{} {
+	return []interface{}{
+		status.AgentID,
+		status.SessionTopic,
+		status.Status,
+		status.Timestamp,
+		status.Message,
+		status.Content,
+		nullableJSON(status.Metadata),
+		status.Revision,
+		statusOrigin(status),
+		status.ContentFormat,
+	}
+}
+
+// statusOrigin returns the origin stored for a status, statuses without one having been reported by their agent
+func statusOrigin(status *models.AgentStatus) string {
+	if status.Origin == "" {
+		return models.StatusOriginAgent
+	}
+	return status.Origin
+}
+
+// AddStatusWithOutbox adds a status and records its side effects in one transaction
+func (s *PostgresStore) AddStatusWithOutbox(status *models.AgentStatus, messages []*models.OutboxMessage) error {
+	if err := status.Validate(); err != nil {
+		return invalid(err)
+	}
+	for _, message := range messages {
+		if err := message.Validate(); err != nil {
+			return invalid(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := tx.QueryRow(ctx, insertStatusQuery, statusArgs(status)...).Scan(&status.ID); err != nil {
+		return fmt.Errorf("failed to add status: %w", err)
+	}
+
+	for _, message := range messages {
+		if err := insertOutboxMessage(ctx, tx, message); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit status: %w", err)
+	}
+	return nil
+}
+
+// AddOutboxMessage records a message on its own
+func (s *PostgresStore) AddOutboxMessage(message *models.OutboxMessage) error {
+	if err := message.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return insertOutboxMessage(ctx, s.pool, message)
+}
+
+// rowQuerier runs a query returning one row, as both the pool and its transactions do
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// insertOutboxMessage inserts a message and sets its ID, making it available when created unless it has a later time
+func insertOutboxMessage(ctx context.Context, q rowQuerier, message *models.OutboxMessage) error {
+	query := `
+		INSERT INTO outbox (kind, payload, attempts, available_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	availableAt := message.AvailableAt
+	if availableAt.IsZero() {
+		availableAt = message.CreatedAt
+	}
+	err := q.QueryRow(ctx, query,
+		message.Kind,
+		string(message.Payload),
+		message.Attempts,
+		availableAt,
+		message.CreatedAt,
+	).Scan(&message.ID)
+	if err != nil {
+		return fmt.Errorf("failed to add outbox message: %w", err)
+	}
+	message.AvailableAt = availableAt
+	return nil
+}
+
+// statusColumns lists status columns in the order scanned by scanStatus
+const statusColumns = "id, agent_id, session_topic, status, timestamp, message, content, COALESCE(metadata::text, ''), revision, origin, content_format"
+
+// scanStatus scans a row selected with statusColumns
+func scanStatus(row pgx.Row) (*models.AgentStatus, error) {
+	var status models.AgentStatus
+	var metadata string
+	if err := row.Scan(
+		&status.ID,
+		&status.AgentID,
+		&status.SessionTopic,
+		&status.Status,
+		&status.Timestamp,
+		&status.Message,
+		&status.Content,
+		&metadata,
+		&status.Revision,
+		&status.Origin,
+		&status.ContentFormat,
+	); err != nil {
+		return nil, err
+	}
+	if metadata != "" {
+		status.Metadata = json.RawMessage(metadata)
+	}
+	return &status, nil
+}
+
+// GetStatusHistory returns all status records for a session
+func (s *PostgresStore) GetStatusHistory(agentID, sessionTopic string) ([]*models.AgentStatus, error) {
+	statuses, _, err := s.GetStatusHistoryPage(agentID, sessionTopic, Page{})
+	return statuses, err
+}
+
+// ListStatusesBetween returns the statuses of every session reported in [from, to), oldest first
+func (s *PostgresStore) ListStatusesBetween(from, to time.Time, limit int) ([]*models.AgentStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	query := `
+		SELECT ` + statusColumns + `
+		FROM agent_statuses
+		WHERE timestamp >= $1 AND timestamp < $2
+		ORDER BY timestamp, id
+		LIMIT $3
+	`
+
+	rows, err := s.pool.Query(ctx, query, from, to, Page{Limit: limit}.limitArg())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statuses: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := make([]*models.AgentStatus, 0)
+	for rows.Next() {
+		status, err := scanStatus(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status: %w", err)
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, rows.Err()
+}
+
+// GetStatusHistoryPage returns one page of a session's statuses, newest first, and how many there are
+func (s *PostgresStore) GetStatusHistoryPage(agentID, sessionTopic string, page Page) ([]*models.AgentStatus, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var total int
+	err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM agent_statuses WHERE agent_id = $1 AND session_topic = $2`, agentID, sessionTopic).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count status history: %w", err)
+	}
+
+	query := `
+		SELECT ` + statusColumns + `
+		FROM agent_statuses
+		WHERE agent_id = $1 AND session_topic = $2
+		ORDER BY timestamp DESC, id
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := s.pool.Query(ctx, query, agentID, sessionTopic, page.limitArg(), page.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get status history: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := make([]*models.AgentStatus, 0)
+	for rows.Next() {
+		status, err := scanStatus(rows)
+		if err != nil {
+			continue
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, total, nil
+}
+
+// PruneStatuses removes up to limit statuses reported before the cutoff, oldest first, keeping each session's latest
+// Annotations of pruned statuses are removed by their foreign key.
+func (s *PostgresStore) PruneStatuses(before time.Time, limit int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	query := `
+		DELETE FROM agent_statuses
+		WHERE id IN (
+			SELECT s.id
+			FROM agent_statuses s
+			WHERE s.timestamp < $1
+			  AND EXISTS (
+			      SELECT 1 FROM agent_statuses later
+			      WHERE later.agent_id = s.agent_id AND later.session_topic = s.session_topic AND later.timestamp > s.timestamp
+			  )
+			ORDER BY s.timestamp, s.id
+			LIMIT $2
+		)
+	`
+
+	result, err := s.pool.Exec(ctx, query, before, Page{Limit: limit}.limitArg())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune statuses: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// GetLatestStatus returns the latest status for a session
+func (s *PostgresStore) GetLatestStatus(agentID, sessionTopic string) (*models.AgentStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + statusColumns + `
+		FROM agent_statuses
+		WHERE agent_id = $1 AND session_topic = $2
+		ORDER BY timestamp DESC
+		LIMIT 1
+	`
+
+	status, err := scanStatus(s.pool.QueryRow(ctx, query, agentID, sessionTopic))
+	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to get latest status: %w", err)
 	}
+	return status, nil
+}
+
+// GetRunProgress returns the latest status an agent reported in a run of a session and when the run started running
+func (s *PostgresStore) GetRunProgress(agentID, sessionTopic string, revision int) (*models.AgentStatus, time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + statusColumns + `
+		FROM agent_statuses
+		WHERE agent_id = $1 AND session_topic = $2 AND revision = $3 AND origin <> $4
+		ORDER BY timestamp DESC, id
+		LIMIT 1
+	`
+	latest, err := scanStatus(s.pool.QueryRow(ctx, query, agentID, sessionTopic, revision, models.StatusOriginServer))
+	if err == pgx.ErrNoRows {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to get latest status of run: %w", err)
+	}
 
-	return &status, nil
+	var runningSince *time.Time
+	err = s.pool.QueryRow(ctx, `
+		SELECT MIN(timestamp)
+		FROM agent_statuses
+		WHERE agent_id = $1 AND session_topic = $2 AND revision = $3 AND origin <> $4 AND status = 'running'
+	`, agentID, sessionTopic, revision, models.StatusOriginServer).Scan(&runningSince)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to get start of run: %w", err)
+	}
+	if runningSince == nil {
+		return latest, time.Time{}, nil
+	}
+	return latest, *runningSince, nil
+}
+
+// annotationColumns lists status annotation columns in the order scanned by scanAnnotation
+const annotationColumns = "id, status_id, agent_id, session_topic, user_id, investigator, root_cause, note, links, created_at"
+
+// scanAnnotation scans a row selected with annotationColumns
+func scanAnnotation(row pgx.Row) (*models.StatusAnnotation, error) {
+	var annotation models.StatusAnnotation
+	var links []byte
+	err := row.Scan(
+		&annotation.ID,
+		&annotation.StatusID,
+		&annotation.AgentID,
+		&annotation.SessionTopic,
+		&annotation.UserID,
+		&annotation.Investigator,
+		&annotation.RootCause,
+		&annotation.Note,
+		&links,
+		&annotation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) > 0 {
+		if err := json.Unmarshal(links, &annotation.Links); err != nil {
+			return nil, fmt.Errorf("failed to decode annotation links: %w", err)
+		}
+	}
+	return &annotation, nil
+}
+
+// CreateStatusAnnotation adds an annotation to a status of the annotation's session
+func (s *PostgresStore) CreateStatusAnnotation(annotation *models.StatusAnnotation) error {
+	if err := annotation.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	var links interface{}
+	if len(annotation.Links) > 0 {
+		raw, err := json.Marshal(annotation.Links)
+		if err != nil {
+			return fmt.Errorf("failed to encode annotation links: %w", err)
+		}
+		links = string(raw)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Nothing is inserted unless the status belongs to the session
+	query := `
+		INSERT INTO status_annotations (` + annotationColumns + `)
+		SELECT $1, id, agent_id, session_topic, $5, $6, $7, $8, $9, $10
+		FROM agent_statuses
+		WHERE id = $2 AND agent_id = $3 AND session_topic = $4
+	`
+
+	result, err := s.pool.Exec(ctx, query,
+		annotation.ID,
+		annotation.StatusID,
+		annotation.AgentID,
+		annotation.SessionTopic,
+		annotation.UserID,
+		annotation.Investigator,
+		annotation.RootCause,
+		annotation.Note,
+		links,
+		annotation.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create status annotation: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ListStatusAnnotations returns the annotations of a session's statuses, oldest first
+func (s *PostgresStore) ListStatusAnnotations(agentID, sessionTopic string) ([]*models.StatusAnnotation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + annotationColumns + `
+		FROM status_annotations
+		WHERE agent_id = $1 AND session_topic = $2
+		ORDER BY created_at, id
+	`
+
+	rows, err := s.pool.Query(ctx, query, agentID, sessionTopic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list status annotations: %w", err)
+	}
+	defer rows.Close()
+
+	annotations := make([]*models.StatusAnnotation, 0)
+	for rows.Next() {
+		annotation, err := scanAnnotation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status annotation: %w", err)
+		}
+		annotations = append(annotations, annotation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list status annotations: %w", err)
+	}
+
+	return annotations, nil
+}
+
+// CheckExpiredSessions marks sessions past their TTL as expired and returns them
+func (s *PostgresStore) CheckExpiredSessions() []*models.Session {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := s.clock.Now()
+
+	query := `
+		UPDATE sessions
+		SET expired = true,
+		    expired_at = $1,
+		    end_reason = CASE WHEN end_reason = '' THEN '` + models.EndReasonTTLExpired + `' ELSE end_reason END,
+		    version = version + 1
+		WHERE expired = false
+		  AND last_updated + (ttl_minutes || ' minutes')::interval < $1
+		RETURNING ` + sessionColumns
+
+	rows, err := s.pool.Query(ctx, query, now)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var expired []*models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return expired
+		}
+		expired = append(expired, session)
+	}
+	return expired
+}
+
+// integrityChecks find each kind of integrity issue and, in order, repair them
+// Each check selects agent_id, session_topic and a detail.
+var integrityChecks = []struct {
+	kind   string
+	find   string
+	repair string // Empty for issues that are only reported
+}{
+	{
+		kind: models.IntegrityOrphanedSession,
+		find: `
+			SELECT s.agent_id, s.session_topic, 'agent is missing'
+			FROM sessions s
+			WHERE NOT EXISTS (SELECT 1 FROM agents a WHERE a.agent_id = s.agent_id)`,
+		repair: `
+			DELETE FROM sessions s
+			WHERE NOT EXISTS (SELECT 1 FROM agents a WHERE a.agent_id = s.agent_id)`,
+	},
+	{
+		kind: models.IntegrityOrphanedStatus,
+		find: `
+			SELECT st.agent_id, st.session_topic, COUNT(*) || ' statuses without a session'
+			FROM agent_statuses st
+			WHERE NOT EXISTS (
+				SELECT 1 FROM sessions s
+				WHERE s.agent_id = st.agent_id AND s.session_topic = st.session_topic
+			)
+			GROUP BY st.agent_id, st.session_topic`,
+		repair: `
+			DELETE FROM agent_statuses st
+			WHERE NOT EXISTS (
+				SELECT 1 FROM sessions s
+				WHERE s.agent_id = st.agent_id AND s.session_topic = st.session_topic
+			)`,
+	},
+	{
+		kind: models.IntegrityOwnerlessAgent,
+		find: `
+			SELECT a.agent_id, '', CASE WHEN a.user_id IS NULL OR a.user_id = '' THEN 'agent has no owner'
+			                            ELSE 'owner ' || a.user_id || ' is missing' END
+			FROM agents a
+			WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = a.user_id)`,
+	},
+	{
+		kind: models.IntegrityExpiredFlag,
+		find: `
+			SELECT agent_id, session_topic, CASE WHEN expired THEN 'expired session has no expiry time'
+			                                     ELSE 'active session has an expiry time' END
+			FROM sessions
+			WHERE expired <> (expired_at IS NOT NULL)`,
+		repair: `
+			UPDATE sessions
+			SET expired_at = CASE WHEN expired THEN last_updated + (ttl_minutes || ' minutes')::interval END,
+			    end_reason = CASE WHEN expired AND end_reason = '' THEN '` + models.EndReasonCleanup + `' ELSE end_reason END,
+			    version = version + 1
+			WHERE expired <> (expired_at IS NOT NULL)`,
+	},
+}
+
+// CheckIntegrity finds and optionally repairs inconsistencies between stored records in one transaction
+func (s *PostgresStore) CheckIntegrity(repair bool) ([]*models.IntegrityIssue, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	issues := make([]*models.IntegrityIssue, 0)
+	for _, check := range integrityChecks {
+		rows, err := tx.Query(ctx, check.find)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find %s issues: %w", check.kind, err)
+		}
+		for rows.Next() {
+			issue := &models.IntegrityIssue{Kind: check.kind, Repaired: repair && check.repair != ""}
+			if err := rows.Scan(&issue.AgentID, &issue.SessionTopic, &issue.Detail); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan %s issue: %w", check.kind, err)
+			}
+			issues = append(issues, issue)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to find %s issues: %w", check.kind, err)
+		}
+
+		if repair && check.repair != "" {
+			if _, err := tx.Exec(ctx, check.repair); err != nil {
+				return nil, fmt.Errorf("failed to repair %s issues: %w", check.kind, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit integrity repairs: %w", err)
+	}
+	sortIntegrityIssues(issues)
+	return issues, nil
+}
+
+// userColumns lists user columns in the order scanned by scanUser
+const userColumns = "id, email, password_hash, COALESCE(name, ''), COALESCE(notification_webhook_url, ''), plan, email_verified, COALESCE(verify_token, ''), verify_token_expires_at, created_at, updated_at, notification_mentions, notification_destinations, role, disabled_at, session_close_on_delete, session_close_on_offline, export_public_key"
+
+// scanUser scans a row selected with userColumns
+func scanUser(row pgx.Row) (*models.User, error) {
+	var user models.User
+	var mentions, destinations []byte
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Name,
+		&user.NotificationWebhookURL,
+		&user.Plan,
+		&user.EmailVerified,
+		&user.VerifyToken,
+		&user.VerifyTokenExpiresAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&mentions,
+		&destinations,
+		&user.Role,
+		&user.DisabledAt,
+		&user.SessionAutoClose.OnDelete,
+		&user.SessionAutoClose.OnOffline,
+		&user.ExportPublicKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(mentions) > 0 {
+		if err := json.Unmarshal(mentions, &user.NotificationMentions); err != nil {
+			return nil, fmt.Errorf("failed to decode notification mentions: %w", err)
+		}
+	}
+	if len(destinations) > 0 {
+		if err := json.Unmarshal(destinations, &user.NotificationDestinations); err != nil {
+			return nil, fmt.Errorf("failed to decode notification destinations: %w", err)
+		}
+	}
+	return &user, nil
+}
+
+// mentionsJSON converts mention rules to a query argument, storing NULL when there are none
+func mentionsJSON(rules []models.MentionRule) (interface{}, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode notification mentions: %w", err)
+	}
+	return string(raw), nil
+}
+
+// destinationsJSON converts notification destinations to a query argument, storing NULL when there are none
+func destinationsJSON(destinations []models.NotificationDestination) (interface{}, error) {
+	if len(destinations) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(destinations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode notification destinations: %w", err)
+	}
+	return string(raw), nil
+}
+
+// CreateUser creates a new user
+func (s *PostgresStore) CreateUser(user *models.User) error {
+	if err := user.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	mentions, err := mentionsJSON(user.NotificationMentions)
+	if err != nil {
+		return err
+	}
+	destinations, err := destinationsJSON(user.NotificationDestinations)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO users (id, email, password_hash, name, notification_webhook_url, plan, email_verified, verify_token, verify_token_expires_at, created_at, updated_at, notification_mentions, notification_destinations, role, disabled_at, session_close_on_delete, session_close_on_offline, export_public_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+	`
+
+	_, err = s.pool.Exec(ctx, query,
+		user.ID,
+		user.Email,
+		user.PasswordHash,
+		user.Name,
+		user.NotificationWebhookURL,
+		user.Plan,
+		user.EmailVerified,
+		user.VerifyToken,
+		user.VerifyTokenExpiresAt,
+		user.CreatedAt,
+		user.UpdatedAt,
+		mentions,
+		destinations,
+		user.Role,
+		user.DisabledAt,
+		user.SessionAutoClose.OnDelete,
+		user.SessionAutoClose.OnOffline,
+		user.ExportPublicKey,
+	)
+
+	if err != nil {
+		// Check for unique constraint violation
+		if isDuplicateKeyError(err) {
+			return ErrDuplicateEmail
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserByID retrieves a user by ID
+func (s *PostgresStore) GetUserByID(userID string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE id = $1
+	`
+
+	row := s.pool.QueryRow(ctx, query, userID)
+
+	user, err := scanUser(row)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// ListUsers returns every user, oldest first
+func (s *PostgresStore) ListUsers() ([]*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT `+userColumns+` FROM users ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// ListUsersPage returns one page of ListUsers and how many users there are
+func (s *PostgresStore) ListUsersPage(page Page) ([]*models.User, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM users`).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT `+userColumns+` FROM users ORDER BY created_at, id LIMIT $1 OFFSET $2`, page.limitArg(), page.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+// GetUserByEmail retrieves a user by email
+func (s *PostgresStore) GetUserByEmail(email string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1
+	`
+
+	row := s.pool.QueryRow(ctx, query, email)
+
+	user, err := scanUser(row)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserByVerifyToken retrieves a user by verification token
+func (s *PostgresStore) GetUserByVerifyToken(token string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE verify_token = $1
+	`
+
+	row := s.pool.QueryRow(ctx, query, token)
+
+	user, err := scanUser(row)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by verify token: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetUserDataKey returns a user's wrapped data key
+func (s *PostgresStore) GetUserDataKey(userID string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var key []byte
+	err := s.pool.QueryRow(ctx, `SELECT data_key FROM users WHERE id = $1`, userID).Scan(&key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+	if len(key) == 0 {
+		return nil, ErrNotFound
+	}
+	return key, nil
+}
+
+// SetUserDataKey stores the wrapped key unless the user already has one and returns the stored key
+func (s *PostgresStore) SetUserDataKey(userID string, wrappedKey []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var key []byte
+	err := s.pool.QueryRow(ctx, `
+		UPDATE users SET data_key = COALESCE(data_key, $2)
+		WHERE id = $1
+		RETURNING data_key
+	`, userID, wrappedKey).Scan(&key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to set data key: %w", err)
+	}
+	return key, nil
+}
+
+// UpdateUser updates an existing user
+func (s *PostgresStore) UpdateUser(user *models.User) error {
+	if err := user.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	mentions, err := mentionsJSON(user.NotificationMentions)
+	if err != nil {
+		return err
+	}
+	destinations, err := destinationsJSON(user.NotificationDestinations)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE users
+		SET email = $2, password_hash = $3, name = $4, notification_webhook_url = $5, plan = $6, email_verified = $7, verify_token = $8, verify_token_expires_at = $9, updated_at = $10, notification_mentions = $11, notification_destinations = $12, role = $13, disabled_at = $14, session_close_on_delete = $15, session_close_on_offline = $16, export_public_key = $17
+		WHERE id = $1
+	`
+
+	result, err := s.pool.Exec(ctx, query,
+		user.ID,
+		user.Email,
+		user.PasswordHash,
+		user.Name,
+		user.NotificationWebhookURL,
+		user.Plan,
+		user.EmailVerified,
+		user.VerifyToken,
+		user.VerifyTokenExpiresAt,
+		user.UpdatedAt,
+		mentions,
+		destinations,
+		user.Role,
+		user.DisabledAt,
+		user.SessionAutoClose.OnDelete,
+		user.SessionAutoClose.OnOffline,
+		user.ExportPublicKey,
+	)
+
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return ErrDuplicateEmail
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteUser removes a user; foreign keys cascade the delete to their agents and every other record they own
+func (s *PostgresStore) DeleteUser(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SaveRefreshToken saves a refresh token
+func (s *PostgresStore) SaveRefreshToken(token *models.RefreshToken) error {
+	if err := token.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, token_hash, expires_at, created_at, revoked)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := s.pool.Exec(ctx, query,
+		token.ID,
+		token.UserID,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+		token.Revoked,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// GetRefreshTokenByID retrieves a refresh token by ID
+func (s *PostgresStore) GetRefreshTokenByID(tokenID string) (*models.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, token_hash, expires_at, created_at, revoked
+		FROM refresh_tokens
+		WHERE id = $1
+	`
+
+	row := s.pool.QueryRow(ctx, query, tokenID)
+
+	var token models.RefreshToken
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.Revoked,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token by ID: %w", err)
+	}
+
+	return &token, nil
+}
+
+// GetRefreshToken retrieves a refresh token by hash
+func (s *PostgresStore) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, token_hash, expires_at, created_at, revoked
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+
+	row := s.pool.QueryRow(ctx, query, tokenHash)
+
+	var token models.RefreshToken
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.Revoked,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// RevokeRefreshToken revokes a refresh token by ID
+func (s *PostgresStore) RevokeRefreshToken(tokenID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE id = $1 AND revoked = false
+	`
+
+	result, err := s.pool.Exec(ctx, query, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RevokeAllUserTokens revokes all refresh tokens for a user
+func (s *PostgresStore) RevokeAllUserTokens(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE refresh_tokens
+		SET revoked = true
+		WHERE user_id = $1
+	`
+
+	_, err := s.pool.Exec(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	return nil
+}
+
+// apiKeyColumns lists API key columns in the order scanned by scanAPIKey
+const apiKeyColumns = "id, user_id, name, key_hash, key_prefix, expires_at, last_used_at, created_at, revoked, agent_id, burst_credits, field_casing"
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	err := row.Scan(
+		&apiKey.ID,
+		&apiKey.UserID,
+		&apiKey.Name,
+		&apiKey.KeyHash,
+		&apiKey.KeyPrefix,
+		&apiKey.ExpiresAt,
+		&apiKey.LastUsedAt,
+		&apiKey.CreatedAt,
+		&apiKey.Revoked,
+		&apiKey.AgentID,
+		&apiKey.BurstCredits,
+		&apiKey.FieldCasing,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
+
+// insertAPIKeyQuery inserts an API key with the arguments of apiKeyArgs
+const insertAPIKeyQuery = `
+	INSERT INTO api_keys (` + apiKeyColumns + `)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+`
+
+// apiKeyArgs returns the arguments of insertAPIKeyQuery
+func apiKeyArgs(apiKey *models.APIKey) []any {
+	return []any{
+		apiKey.ID,
+		apiKey.UserID,
+		apiKey.Name,
+		apiKey.KeyHash,
+		apiKey.KeyPrefix,
+		apiKey.ExpiresAt,
+		apiKey.LastUsedAt,
+		apiKey.CreatedAt,
+		apiKey.Revoked,
+		apiKey.AgentID,
+		apiKey.BurstCredits,
+		apiKey.FieldCasing,
+	}
+}
+
+// CreateAPIKey creates a new API key
+func (s *PostgresStore) CreateAPIKey(apiKey *models.APIKey) error {
+	if err := apiKey.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.pool.Exec(ctx, insertAPIKeyQuery, apiKeyArgs(apiKey)...); err != nil {
+		return fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return nil
+}
+
+// GetAPIKeyByHash retrieves an API key by its hash
+func (s *PostgresStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	apiKey, err := scanAPIKey(s.pool.QueryRow(ctx, query, keyHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+// GetAPIKeyByID retrieves an API key by its ID
+func (s *PostgresStore) GetAPIKeyByID(keyID string) (*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	apiKey, err := scanAPIKey(s.pool.QueryRow(ctx, query, keyID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+// ListAPIKeysByUser returns all API keys for a user
+func (s *PostgresStore) ListAPIKeysByUser(userID string) ([]*models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, apiKey)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key
+func (s *PostgresStore) RevokeAPIKey(keyID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE api_keys
+		SET revoked = true
+		WHERE id = $1
+	`
+
+	result, err := s.pool.Exec(ctx, query, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UpdateAPIKeyLastUsed updates the last used timestamp of an API key
+func (s *PostgresStore) UpdateAPIKeyLastUsed(keyID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1
+	`
+
+	_, err := s.pool.Exec(ctx, query, keyID, s.clock.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update API key last used: %w", err)
+	}
+
+	return nil
+}
+
+// enrollmentTokenColumns lists enrollment token columns in the order scanned by scanEnrollmentToken
+const enrollmentTokenColumns = "id, user_id, name, token_hash, token_prefix, agent_id, expires_at, created_at, used_at, used_by_agent_id, api_key_id"
+
+// scanEnrollmentToken scans a row selected with enrollmentTokenColumns
+func scanEnrollmentToken(row pgx.Row) (*models.EnrollmentToken, error) {
+	var token models.EnrollmentToken
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&token.TokenPrefix,
+		&token.AgentID,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+		&token.UsedAt,
+		&token.UsedByAgentID,
+		&token.APIKeyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CreateEnrollmentToken creates a new enrollment token
+func (s *PostgresStore) CreateEnrollmentToken(token *models.EnrollmentToken) error {
+	if err := token.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO enrollment_tokens (` + enrollmentTokenColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err := s.pool.Exec(ctx, query,
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.TokenPrefix,
+		token.AgentID,
+		token.ExpiresAt,
+		token.CreatedAt,
+		token.UsedAt,
+		token.UsedByAgentID,
+		token.APIKeyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create enrollment token: %w", err)
+	}
+
+	return nil
+}
+
+// GetEnrollmentTokenByHash retrieves an enrollment token by its hash
+func (s *PostgresStore) GetEnrollmentTokenByHash(tokenHash string) (*models.EnrollmentToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + enrollmentTokenColumns + ` FROM enrollment_tokens WHERE token_hash = $1`
+
+	token, err := scanEnrollmentToken(s.pool.QueryRow(ctx, query, tokenHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get enrollment token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ListEnrollmentTokensByUser returns a user's enrollment tokens, newest first
+func (s *PostgresStore) ListEnrollmentTokensByUser(userID string) ([]*models.EnrollmentToken, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + enrollmentTokenColumns + `
+		FROM enrollment_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list enrollment tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]*models.EnrollmentToken, 0)
+	for rows.Next() {
+		token, err := scanEnrollmentToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan enrollment token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RedeemEnrollmentToken marks an enrollment token used and creates the agent token it was exchanged for
+func (s *PostgresStore) RedeemEnrollmentToken(tokenID string, agentToken *models.APIKey) error {
+	if err := agentToken.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Only one redemption can flip used_at, so concurrent first reports cannot both enroll
+	query := `
+		UPDATE enrollment_tokens
+		SET used_at = $2, used_by_agent_id = $3, api_key_id = $4
+		WHERE id = $1 AND used_at IS NULL AND expires_at > $2
+	`
+	result, err := tx.Exec(ctx, query, tokenID, agentToken.CreatedAt, agentToken.AgentID, agentToken.ID)
+	if err != nil {
+		return fmt.Errorf("failed to redeem enrollment token: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM enrollment_tokens WHERE id = $1)`, tokenID).Scan(&exists); err != nil {
+			return fmt.Errorf("failed to get enrollment token: %w", err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+		return ErrConflict
+	}
+
+	if _, err := tx.Exec(ctx, insertAPIKeyQuery, apiKeyArgs(agentToken)...); err != nil {
+		return fmt.Errorf("failed to create agent token: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit enrollment: %w", err)
+	}
+	return nil
+}
+
+// DeleteEnrollmentToken removes one of a user's enrollment tokens
+func (s *PostgresStore) DeleteEnrollmentToken(userID, tokenID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM enrollment_tokens WHERE id = $1 AND user_id = $2`, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete enrollment token: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// orgColumns, membershipColumns and invitationColumns list the columns scanned by scanOrganization,
+// scanMembership and scanInvitation
+const (
+	orgColumns        = "id, name, created_by, created_at"
+	membershipColumns = "org_id, user_id, role, created_at"
+	invitationColumns = "id, org_id, email, role, token_hash, token_prefix, invited_by, created_at, expires_at"
+)
+
+// scanOrganization scans a row selected with orgColumns
+func scanOrganization(row pgx.Row) (*models.Organization, error) {
+	var org models.Organization
+	if err := row.Scan(&org.ID, &org.Name, &org.CreatedBy, &org.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// scanMembership scans a row selected with membershipColumns
+func scanMembership(row pgx.Row) (*models.Membership, error) {
+	var membership models.Membership
+	if err := row.Scan(&membership.OrgID, &membership.UserID, &membership.Role, &membership.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// scanInvitation scans a row selected with invitationColumns
+func scanInvitation(row pgx.Row) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.OrgID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.TokenHash,
+		&invitation.TokenPrefix,
+		&invitation.InvitedBy,
+		&invitation.CreatedAt,
+		&invitation.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// upsertMembershipQuery creates or replaces a membership
+const upsertMembershipQuery = `
+	INSERT INTO memberships (` + membershipColumns + `)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role
+`
+
+// CreateOrganization creates an organization and, if owner is not nil, its first owner's membership
+func (s *PostgresStore) CreateOrganization(org *models.Organization, owner *models.Membership) error {
+	if err := org.Validate(); err != nil {
+		return invalid(err)
+	}
+	if owner != nil {
+		if err := owner.Validate(); err != nil {
+			return invalid(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result, err := tx.Exec(ctx, `
+		INSERT INTO organizations (`+orgColumns+`) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING`,
+		org.ID, org.Name, org.CreatedBy, org.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAlreadyExists
+	}
+	if owner != nil {
+		if _, err := tx.Exec(ctx, upsertMembershipQuery, owner.OrgID, owner.UserID, owner.Role, owner.CreatedAt); err != nil {
+			return fmt.Errorf("failed to create owner membership: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit organization: %w", err)
+	}
+	return nil
+}
+
+// GetOrganization retrieves an organization by ID
+func (s *PostgresStore) GetOrganization(orgID string) (*models.Organization, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	org, err := scanOrganization(s.pool.QueryRow(ctx, `SELECT `+orgColumns+` FROM organizations WHERE id = $1`, orgID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return org, nil
+}
+
+// ListOrganizations returns every organization, oldest first
+func (s *PostgresStore) ListOrganizations() ([]*models.Organization, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT `+orgColumns+` FROM organizations ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	defer rows.Close()
+
+	orgs := make([]*models.Organization, 0)
+	for rows.Next() {
+		org, err := scanOrganization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan organization: %w", err)
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// DeleteOrganization removes an organization; memberships and invitations cascade and agents are unshared
+func (s *PostgresStore) DeleteOrganization(orgID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM organizations WHERE id = $1`, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveMembership creates or replaces a membership
+func (s *PostgresStore) SaveMembership(membership *models.Membership) error {
+	if err := membership.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.pool.Exec(ctx, upsertMembershipQuery, membership.OrgID, membership.UserID, membership.Role, membership.CreatedAt)
+	if err != nil {
+		if isForeignKeyError(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to save membership: %w", err)
+	}
+	return nil
+}
+
+// GetMembership retrieves a user's membership of an organization
+func (s *PostgresStore) GetMembership(orgID, userID string) (*models.Membership, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + membershipColumns + ` FROM memberships WHERE org_id = $1 AND user_id = $2`
+	membership, err := scanMembership(s.pool.QueryRow(ctx, query, orgID, userID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get membership: %w", err)
+	}
+	return membership, nil
+}
+
+// ListMemberships returns an organization's memberships, oldest first
+func (s *PostgresStore) ListMemberships(orgID string) ([]*models.Membership, error) {
+	return s.listMemberships(`org_id = $1`, orgID)
+}
+
+// ListMembershipsByUser returns a user's memberships, oldest first
+func (s *PostgresStore) ListMembershipsByUser(userID string) ([]*models.Membership, error) {
+	return s.listMemberships(`user_id = $1`, userID)
+}
+
+// listMemberships returns the memberships matching the condition on $1, oldest first
+func (s *PostgresStore) listMemberships(condition, arg string) ([]*models.Membership, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + membershipColumns + ` FROM memberships WHERE ` + condition + ` ORDER BY created_at, org_id, user_id`
+	rows, err := s.pool.Query(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memberships: %w", err)
+	}
+	defer rows.Close()
+
+	memberships := make([]*models.Membership, 0)
+	for rows.Next() {
+		membership, err := scanMembership(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan membership: %w", err)
+		}
+		memberships = append(memberships, membership)
+	}
+	return memberships, rows.Err()
+}
+
+// DeleteMembership removes a user from an organization
+func (s *PostgresStore) DeleteMembership(orgID, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM memberships WHERE org_id = $1 AND user_id = $2`, orgID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete membership: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateInvitation creates an invitation to an organization
+func (s *PostgresStore) CreateInvitation(invitation *models.Invitation) error {
+	if err := invitation.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO invitations (` + invitationColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	_, err := s.pool.Exec(ctx, query,
+		invitation.ID,
+		invitation.OrgID,
+		invitation.Email,
+		invitation.Role,
+		invitation.TokenHash,
+		invitation.TokenPrefix,
+		invitation.InvitedBy,
+		invitation.CreatedAt,
+		invitation.ExpiresAt,
+	)
+	if err != nil {
+		if isForeignKeyError(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return nil
+}
+
+// GetInvitationByHash retrieves an invitation by its token hash
+func (s *PostgresStore) GetInvitationByHash(tokenHash string) (*models.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invitation, err := scanInvitation(s.pool.QueryRow(ctx, `SELECT `+invitationColumns+` FROM invitations WHERE token_hash = $1`, tokenHash))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+	return invitation, nil
+}
+
+// ListInvitations returns an organization's pending invitations, newest first
+func (s *PostgresStore) ListInvitations(orgID string) ([]*models.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `SELECT `+invitationColumns+` FROM invitations WHERE org_id = $1 ORDER BY created_at DESC`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := make([]*models.Invitation, 0)
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// AcceptInvitation deletes an invitation and saves the membership it grants
+func (s *PostgresStore) AcceptInvitation(invitationID string, membership *models.Membership) error {
+	if err := membership.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Only one acceptance can delete the invitation, so a token cannot be used twice
+	result, err := tx.Exec(ctx, `DELETE FROM invitations WHERE id = $1`, invitationID)
+	if err != nil {
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, upsertMembershipQuery, membership.OrgID, membership.UserID, membership.Role, membership.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save membership: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit invitation: %w", err)
+	}
+	return nil
+}
+
+// DeleteInvitation revokes one of an organization's invitations
+func (s *PostgresStore) DeleteInvitation(orgID, invitationID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM invitations WHERE id = $1 AND org_id = $2`, invitationID, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete invitation: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// clientCertColumns lists client certificate columns in the order scanned by scanClientCertificate
+const clientCertColumns = "id, user_id, name, fingerprint, agent_id, created_at"
+
+// scanClientCertificate scans a row selected with clientCertColumns
+func scanClientCertificate(row pgx.Row) (*models.ClientCertificate, error) {
+	var cert models.ClientCertificate
+	err := row.Scan(
+		&cert.ID,
+		&cert.UserID,
+		&cert.Name,
+		&cert.Fingerprint,
+		&cert.AgentID,
+		&cert.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// CreateClientCertificate registers a client certificate fingerprint
+func (s *PostgresStore) CreateClientCertificate(cert *models.ClientCertificate) error {
+	if err := cert.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO client_certificates (` + clientCertColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := s.pool.Exec(ctx, query,
+		cert.ID,
+		cert.UserID,
+		cert.Name,
+		cert.Fingerprint,
+		cert.AgentID,
+		cert.CreatedAt,
+	)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to create client certificate: %w", err)
+	}
+
+	return nil
+}
+
+// GetClientCertificateByFingerprint retrieves a client certificate by its fingerprint
+func (s *PostgresStore) GetClientCertificateByFingerprint(fingerprint string) (*models.ClientCertificate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + clientCertColumns + ` FROM client_certificates WHERE fingerprint = $1`
+
+	cert, err := scanClientCertificate(s.pool.QueryRow(ctx, query, fingerprint))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get client certificate: %w", err)
+	}
+
+	return cert, nil
+}
+
+// ListClientCertificatesByUser returns a user's client certificates, oldest first
+func (s *PostgresStore) ListClientCertificatesByUser(userID string) ([]*models.ClientCertificate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT ` + clientCertColumns + `
+		FROM client_certificates
+		WHERE user_id = $1
+		ORDER BY created_at
+	`
+
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list client certificates: %w", err)
+	}
+	defer rows.Close()
+
+	certs := make([]*models.ClientCertificate, 0)
+	for rows.Next() {
+		cert, err := scanClientCertificate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan client certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, rows.Err()
+}
+
+// DeleteClientCertificate removes one of a user's client certificates
+func (s *PostgresStore) DeleteClientCertificate(userID, certID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM client_certificates WHERE id = $1 AND user_id = $2`, certID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete client certificate: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetConfig retrieves a config value by key
+func (s *PostgresStore) GetConfig(key string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT value FROM system_config WHERE key = $1`
+
+	var value string
+	err := s.pool.QueryRow(ctx, query, key).Scan(&value)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("failed to get config: %w", err)
+	}
+
+	return value, nil
+}
+
+// SetConfig sets a config value (upsert)
+func (s *PostgresStore) SetConfig(key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO system_config (key, value, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET value = EXCLUDED.value, updated_at = NOW()
+	`
+
+	_, err := s.pool.Exec(ctx, query, key, value)
+	if err != nil {
+		return fmt.Errorf("failed to set config: %w", err)
+	}
+
+	return nil
+}
+
+// slaColumns lists sla columns in the order scanned by scanSLA
+const slaColumns = "id, user_id, name, agent_id, topic_pattern, max_duration_minutes, max_failure_rate, created_at, updated_at"
+
+// scanSLA scans a row selected with slaColumns
+func scanSLA(row pgx.Row) (*models.SLA, error) {
+	var sla models.SLA
+	err := row.Scan(
+		&sla.ID,
+		&sla.UserID,
+		&sla.Name,
+		&sla.AgentID,
+		&sla.TopicPattern,
+		&sla.MaxDurationMinutes,
+		&sla.MaxFailureRate,
+		&sla.CreatedAt,
+		&sla.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &sla, nil
+}
+
+// CreateSLA creates a new SLA
+func (s *PostgresStore) CreateSLA(sla *models.SLA) error {
+	if err := sla.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO slas (` + slaColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	_, err := s.pool.Exec(ctx, query,
+		sla.ID,
+		sla.UserID,
+		sla.Name,
+		sla.AgentID,
+		sla.TopicPattern,
+		sla.MaxDurationMinutes,
+		sla.MaxFailureRate,
+		sla.CreatedAt,
+		sla.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create SLA: %w", err)
+	}
+
+	return nil
+}
+
+// GetSLA retrieves an SLA by ID
+func (s *PostgresStore) GetSLA(slaID string) (*models.SLA, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + slaColumns + ` FROM slas WHERE id = $1`
+
+	sla, err := scanSLA(s.pool.QueryRow(ctx, query, slaID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get SLA: %w", err)
+	}
+
+	return sla, nil
+}
+
+// ListSLAs returns all SLAs
+func (s *PostgresStore) ListSLAs() ([]*models.SLA, error) {
+	return s.querySLAs(`SELECT ` + slaColumns + ` FROM slas ORDER BY created_at`)
+}
+
+// ListSLAsByUser returns all SLAs for a user
+func (s *PostgresStore) ListSLAsByUser(userID string) ([]*models.SLA, error) {
+	return s.querySLAs(`SELECT `+slaColumns+` FROM slas WHERE user_id = $1 ORDER BY created_at`, userID)
+}
+
+// querySLAs runs a query selecting slaColumns
+func (s *PostgresStore) querySLAs(query string, args ...interface{}) ([]*models.SLA, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SLAs: %w", err)
+	}
+	defer rows.Close()
+
+	slas := make([]*models.SLA, 0)
+	for rows.Next() {
+		sla, err := scanSLA(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SLA: %w", err)
+		}
+		slas = append(slas, sla)
+	}
+
+	return slas, rows.Err()
+}
+
+// UpdateSLA updates an existing SLA
+func (s *PostgresStore) UpdateSLA(sla *models.SLA) error {
+	if err := sla.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE slas
+		SET name = $2, agent_id = $3, topic_pattern = $4, max_duration_minutes = $5, max_failure_rate = $6, updated_at = $7
+		WHERE id = $1
+	`
+
+	result, err := s.pool.Exec(ctx, query,
+		sla.ID,
+		sla.Name,
+		sla.AgentID,
+		sla.TopicPattern,
+		sla.MaxDurationMinutes,
+		sla.MaxFailureRate,
+		sla.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update SLA: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteSLA deletes an SLA and its recorded breaches
+func (s *PostgresStore) DeleteSLA(slaID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM slas WHERE id = $1`, slaID)
+	if err != nil {
+		return fmt.Errorf("failed to delete SLA: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// alertRuleColumns lists alert_rules columns in the order scanned by scanAlertRule
+const alertRuleColumns = "id, user_id, name, kind, agent_id, topic_pattern, status, duration_minutes, created_at, updated_at"
+
+// scanAlertRule scans a row selected with alertRuleColumns
+func scanAlertRule(row pgx.Row) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := row.Scan(
+		&rule.ID,
+		&rule.UserID,
+		&rule.Name,
+		&rule.Kind,
+		&rule.AgentID,
+		&rule.TopicPattern,
+		&rule.Status,
+		&rule.DurationMinutes,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateAlertRule creates a new alert rule
+func (s *PostgresStore) CreateAlertRule(rule *models.AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO alert_rules (` + alertRuleColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err := s.pool.Exec(ctx, query,
+		rule.ID,
+		rule.UserID,
+		rule.Name,
+		rule.Kind,
+		rule.AgentID,
+		rule.TopicPattern,
+		rule.Status,
+		rule.DurationMinutes,
+		rule.CreatedAt,
+		rule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+
+	return nil
+}
+
+// GetAlertRule retrieves an alert rule by ID
+func (s *PostgresStore) GetAlertRule(ruleID string) (*models.AlertRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE id = $1`
+
+	rule, err := scanAlertRule(s.pool.QueryRow(ctx, query, ruleID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// ListAlertRules returns all alert rules
+func (s *PostgresStore) ListAlertRules() ([]*models.AlertRule, error) {
+	return s.queryAlertRules(`SELECT ` + alertRuleColumns + ` FROM alert_rules ORDER BY created_at`)
+}
+
+// ListAlertRulesByUser returns all alert rules of a user
+func (s *PostgresStore) ListAlertRulesByUser(userID string) ([]*models.AlertRule, error) {
+	return s.queryAlertRules(`SELECT `+alertRuleColumns+` FROM alert_rules WHERE user_id = $1 ORDER BY created_at`, userID)
+}
+
+// queryAlertRules runs a query selecting alertRuleColumns
+func (s *PostgresStore) queryAlertRules(query string, args ...interface{}) ([]*models.AlertRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]*models.AlertRule, 0)
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+// UpdateAlertRule updates an existing alert rule
+func (s *PostgresStore) UpdateAlertRule(rule *models.AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE alert_rules
+		SET name = $2, kind = $3, agent_id = $4, topic_pattern = $5, status = $6, duration_minutes = $7, updated_at = $8
+		WHERE id = $1
+	`
+
+	result, err := s.pool.Exec(ctx, query,
+		rule.ID,
+		rule.Name,
+		rule.Kind,
+		rule.AgentID,
+		rule.TopicPattern,
+		rule.Status,
+		rule.DurationMinutes,
+		rule.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update alert rule: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteAlertRule deletes an alert rule
+func (s *PostgresStore) DeleteAlertRule(ruleID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM alert_rules WHERE id = $1`, ruleID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateSLABreach records an SLA breach once per SLA, kind, agent and subject
+func (s *PostgresStore) CreateSLABreach(breach *models.SLABreach) error {
+	if err := breach.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO sla_breaches (id, sla_id, user_id, agent_id, kind, subject, value, threshold, detected_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (sla_id, kind, agent_id, subject) DO NOTHING
+	`
+
+	result, err := s.pool.Exec(ctx, query,
+		breach.ID,
+		breach.SLAID,
+		breach.UserID,
+		breach.AgentID,
+		breach.Kind,
+		breach.Subject,
+		breach.Value,
+		breach.Threshold,
+		breach.DetectedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create SLA breach: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAlreadyExists
+	}
+
+	return nil
+}
+
+// ListSLABreaches returns breaches of an SLA detected at or after since, newest first
+func (s *PostgresStore) ListSLABreaches(slaID string, since time.Time) ([]*models.SLABreach, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT id, sla_id, user_id, agent_id, kind, subject, value, threshold, detected_at
+		FROM sla_breaches
+		WHERE sla_id = $1 AND detected_at >= $2
+		ORDER BY detected_at DESC
+	`
+
+	rows, err := s.pool.Query(ctx, query, slaID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SLA breaches: %w", err)
+	}
+	defer rows.Close()
+
+	breaches := make([]*models.SLABreach, 0)
+	for rows.Next() {
+		var breach models.SLABreach
+		err := rows.Scan(
+			&breach.ID,
+			&breach.SLAID,
+			&breach.UserID,
+			&breach.AgentID,
+			&breach.Kind,
+			&breach.Subject,
+			&breach.Value,
+			&breach.Threshold,
+			&breach.DetectedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan SLA breach: %w", err)
+		}
+		breaches = append(breaches, &breach)
+	}
+
+	return breaches, rows.Err()
+}
+
+// sessionWebhookColumns lists session_webhooks columns in the order scanned by scanSessionWebhook
+const sessionWebhookColumns = "id, user_id, url, secret, agent_id, outcomes, created_at, updated_at"
+
+// scanSessionWebhook scans a row selected with sessionWebhookColumns
+func scanSessionWebhook(row pgx.Row) (*models.SessionWebhook, error) {
+	var hook models.SessionWebhook
+	err := row.Scan(
+		&hook.ID,
+		&hook.UserID,
+		&hook.URL,
+		&hook.Secret,
+		&hook.AgentID,
+		&hook.Outcomes,
+		&hook.CreatedAt,
+		&hook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(hook.Outcomes) == 0 {
+		hook.Outcomes = nil
+	}
+	return &hook, nil
+}
+
+// outcomesArg converts webhook outcomes to a query argument, storing an empty array when there are none
+func outcomesArg(outcomes []string) []string {
+	if outcomes == nil {
+		return []string{}
+	}
+	return outcomes
+}
+
+// CreateSessionWebhook creates a new session webhook
+func (s *PostgresStore) CreateSessionWebhook(hook *models.SessionWebhook) error {
+	if err := hook.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO session_webhooks (` + sessionWebhookColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := s.pool.Exec(ctx, query,
+		hook.ID,
+		hook.UserID,
+		hook.URL,
+		hook.Secret,
+		hook.AgentID,
+		outcomesArg(hook.Outcomes),
+		hook.CreatedAt,
+		hook.UpdatedAt,
+	)
+	if err != nil {
+		if isDuplicateKeyError(err) {
+			return ErrAlreadyExists
+		}
+		return fmt.Errorf("failed to create session webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetSessionWebhook retrieves a session webhook by ID
+func (s *PostgresStore) GetSessionWebhook(id string) (*models.SessionWebhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + sessionWebhookColumns + ` FROM session_webhooks WHERE id = $1`
+
+	hook, err := scanSessionWebhook(s.pool.QueryRow(ctx, query, id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get session webhook: %w", err)
+	}
+
+	return hook, nil
+}
+
+// ListSessionWebhooksByUser returns a user's session webhooks, oldest first
+func (s *PostgresStore) ListSessionWebhooksByUser(userID string) ([]*models.SessionWebhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + sessionWebhookColumns + ` FROM session_webhooks WHERE user_id = $1 ORDER BY created_at, id`
+
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := make([]*models.SessionWebhook, 0)
+	for rows.Next() {
+		hook, err := scanSessionWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session webhook: %w", err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+// UpdateSessionWebhook updates an existing session webhook
+func (s *PostgresStore) UpdateSessionWebhook(hook *models.SessionWebhook) error {
+	if err := hook.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE session_webhooks
+		SET url = $2, secret = $3, agent_id = $4, outcomes = $5, updated_at = $6
+		WHERE id = $1
+	`
+
+	result, err := s.pool.Exec(ctx, query,
+		hook.ID,
+		hook.URL,
+		hook.Secret,
+		hook.AgentID,
+		outcomesArg(hook.Outcomes),
+		hook.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update session webhook: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteSessionWebhook deletes a session webhook
+func (s *PostgresStore) DeleteSessionWebhook(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM session_webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session webhook: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// watchItemColumns lists watch item columns in the order scanned by scanWatchItem
+const watchItemColumns = "user_id, agent_id, session_topic, COALESCE(notification_webhook_url, ''), mute_notifications, created_at, updated_at"
+
+// scanWatchItem scans a row selected with watchItemColumns
+func scanWatchItem(row pgx.Row) (*models.WatchItem, error) {
+	var item models.WatchItem
+	err := row.Scan(
+		&item.UserID,
+		&item.AgentID,
+		&item.SessionTopic,
+		&item.NotificationWebhookURL,
+		&item.MuteNotifications,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SaveWatchItem creates or replaces the item for its user, agent and session topic
+func (s *PostgresStore) SaveWatchItem(item *models.WatchItem) error {
+	if err := item.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		INSERT INTO watch_items (user_id, agent_id, session_topic, notification_webhook_url, mute_notifications, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id, agent_id, session_topic) DO UPDATE
+		SET notification_webhook_url = EXCLUDED.notification_webhook_url,
+		    mute_notifications = EXCLUDED.mute_notifications,
+		    updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := s.pool.Exec(ctx, query,
+		item.UserID,
+		item.AgentID,
+		item.SessionTopic,
+		item.NotificationWebhookURL,
+		item.MuteNotifications,
+		item.CreatedAt,
+		item.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save watch item: %w", err)
+	}
+
+	return nil
+}
+
+// GetWatchItem retrieves a user's watch item for an agent, or for one of its sessions
+func (s *PostgresStore) GetWatchItem(userID, agentID, sessionTopic string) (*models.WatchItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + watchItemColumns + ` FROM watch_items WHERE user_id = $1 AND agent_id = $2 AND session_topic = $3`
+
+	item, err := scanWatchItem(s.pool.QueryRow(ctx, query, userID, agentID, sessionTopic))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get watch item: %w", err)
+	}
+
+	return item, nil
+}
+
+// ListWatchItems returns a user's watch items, oldest first
+func (s *PostgresStore) ListWatchItems(userID string) ([]*models.WatchItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `SELECT ` + watchItemColumns + ` FROM watch_items WHERE user_id = $1 ORDER BY created_at`
+
+	rows, err := s.pool.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list watch items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]*models.WatchItem, 0)
+	for rows.Next() {
+		item, err := scanWatchItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan watch item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// DeleteWatchItem removes a user's watch item
+func (s *PostgresStore) DeleteWatchItem(userID, agentID, sessionTopic string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM watch_items WHERE user_id = $1 AND agent_id = $2 AND session_topic = $3`
+
+	result, err := s.pool.Exec(ctx, query, userID, agentID, sessionTopic)
+	if err != nil {
+		return fmt.Errorf("failed to delete watch item: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SaveNotificationSettings creates or replaces a user's notification settings
+func (s *PostgresStore) SaveNotificationSettings(settings *models.NotificationSettings) error {
+	if err := settings.Validate(); err != nil {
+		return invalid(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var transitions interface{}
+	if len(settings.Transitions) > 0 {
+		raw, err := json.Marshal(settings.Transitions)
+		if err != nil {
+			return fmt.Errorf("failed to encode notification transitions: %w", err)
+		}
+		transitions = string(raw)
+	}
+
+	query := `
+		INSERT INTO notification_settings (user_id, webhook_url, format, transitions, first_failure_only, template,
+			dedup_minutes, escalate_after_minutes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) DO UPDATE
+		SET webhook_url = EXCLUDED.webhook_url,
+		    format = EXCLUDED.format,
+		    transitions = EXCLUDED.transitions,
+		    first_failure_only = EXCLUDED.first_failure_only,
+		    template = EXCLUDED.template,
+		    dedup_minutes = EXCLUDED.dedup_minutes,
+		    escalate_after_minutes = EXCLUDED.escalate_after_minutes,
+		    updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := s.pool.Exec(ctx, query,
+		settings.UserID,
+		settings.WebhookURL,
+		settings.Format,
+		transitions,
+		settings.FirstFailureOnly,
+		settings.Template,
+		settings.DedupMinutes,
+		settings.EscalateAfterMinutes,
+		settings.CreatedAt,
+		settings.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save notification settings: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotificationSettings retrieves a user's notification settings
+func (s *PostgresStore) GetNotificationSettings(userID string) (*models.NotificationSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT user_id, webhook_url, format, transitions, first_failure_only, template, dedup_minutes, escalate_after_minutes,
+		       created_at, updated_at
+		FROM notification_settings
+		WHERE user_id = $1
+	`
+
+	var settings models.NotificationSettings
+	var transitions []byte
+	err := s.pool.QueryRow(ctx, query, userID).Scan(
+		&settings.UserID,
+		&settings.WebhookURL,
+		&settings.Format,
+		&transitions,
+		&settings.FirstFailureOnly,
+		&settings.Template,
+		&settings.DedupMinutes,
+		&settings.EscalateAfterMinutes,
+		&settings.CreatedAt,
+		&settings.UpdatedAt,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get notification settings: %w", err)
+	}
+	if len(transitions) > 0 {
+		if err := json.Unmarshal(transitions, &settings.Transitions); err != nil {
+			return nil, fmt.Errorf("failed to decode notification transitions: %w", err)
+		}
+	}
+
+	return &settings, nil
 }
 
-// CheckExpiredSessions checks and marks expired sessions
-func (s *PostgresStore) CheckExpiredSessions() {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// DeleteNotificationSettings removes a user's notification settings
+func (s *PostgresStore) DeleteNotificationSettings(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	now := time.Now()
+	result, err := s.pool.Exec(ctx, `DELETE FROM notification_settings WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete notification settings: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
 
-	query := `
-		UPDATE sessions
-		SET expired = true,
-		    expired_at = $1
-		WHERE expired = false
-		  AND last_updated + (ttl_minutes || ' minutes')::interval < $1
-	`
+	return nil
+}
+
+// inboxItemColumns lists inbox item columns in the order scanned by scanInboxItem
+const inboxItemColumns = "id, user_id, kind, agent_id, session_topic, message, dedupe_key, read, created_at"
 
-	_, err := s.pool.Exec(ctx, query, now)
+// scanInboxItem scans a row selected with inboxItemColumns
+func scanInboxItem(row pgx.Row) (*models.InboxItem, error) {
+	var item models.InboxItem
+	err := row.Scan(
+		&item.ID,
+		&item.UserID,
+		&item.Kind,
+		&item.AgentID,
+		&item.SessionTopic,
+		&item.Message,
+		&item.DedupeKey,
+		&item.Read,
+		&item.CreatedAt,
+	)
 	if err != nil {
-		return
+		return nil, err
 	}
+	return &item, nil
 }
 
-// CreateUser creates a new user
-func (s *PostgresStore) CreateUser(user *models.User) error {
-	if err := user.Validate(); err != nil {
-		return err
+// CreateInboxItem records an inbox item once per user and dedupe key
+func (s *PostgresStore) CreateInboxItem(item *models.InboxItem) error {
+	if err := item.Validate(); err != nil {
+		return invalid(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		INSERT INTO users (id, email, password_hash, name, notification_webhook_url, email_verified, verify_token, created_at, updated_at)
+		INSERT INTO inbox_items (` + inboxItemColumns + `)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, dedupe_key) DO NOTHING
 	`
 
-	_, err := s.pool.Exec(ctx, query,
-		user.ID,
-		user.Email,
-		user.PasswordHash,
-		user.Name,
-		user.NotificationWebhookURL,
-		user.EmailVerified,
-		user.VerifyToken,
-		user.CreatedAt,
-		user.UpdatedAt,
+	result, err := s.pool.Exec(ctx, query,
+		item.ID,
+		item.UserID,
+		item.Kind,
+		item.AgentID,
+		item.SessionTopic,
+		item.Message,
+		item.DedupeKey,
+		item.Read,
+		item.CreatedAt,
 	)
-
 	if err != nil {
-		// Check for unique constraint violation
-		if isDuplicateKeyError(err) {
-			return ErrDuplicateEmail
-		}
-		return fmt.Errorf("failed to create user: %w", err)
+		return fmt.Errorf("failed to create inbox item: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAlreadyExists
 	}
 
 	return nil
 }
 
-// GetUserByID retrieves a user by ID
-func (s *PostgresStore) GetUserByID(userID string) (*models.User, error) {
+// ListInboxItems returns a user's items newest first; limit <= 0 returns all of them
+func (s *PostgresStore) ListInboxItems(userID string, unreadOnly bool, limit int) ([]*models.InboxItem, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT id, email, password_hash, COALESCE(name, ''), COALESCE(notification_webhook_url, ''), email_verified, COALESCE(verify_token, ''), created_at, updated_at
-		FROM users
-		WHERE id = $1
+		SELECT ` + inboxItemColumns + `
+		FROM inbox_items
+		WHERE user_id = $1 AND ($2 = false OR read = false)
+		ORDER BY created_at DESC, id DESC
+		LIMIT NULLIF($3, 0)
 	`
 
-	row := s.pool.QueryRow(ctx, query, userID)
-
-	var user models.User
-	err := row.Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.Name,
-		&user.NotificationWebhookURL,
-		&user.EmailVerified,
-		&user.VerifyToken,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	if limit < 0 {
+		limit = 0
+	}
 
+	rows, err := s.pool.Query(ctx, query, userID, unreadOnly, limit)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrNotFound
+		return nil, fmt.Errorf("failed to list inbox items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]*models.InboxItem, 0)
+	for rows.Next() {
+		item, err := scanInboxItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan inbox item: %w", err)
 		}
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		items = append(items, item)
 	}
 
-	return &user, nil
+	return items, rows.Err()
 }
 
-// GetUserByEmail retrieves a user by email
-func (s *PostgresStore) GetUserByEmail(email string) (*models.User, error) {
+// ListInboxItemsPage returns one page of ListInboxItems and how many items it lists in total
+func (s *PostgresStore) ListInboxItemsPage(userID string, unreadOnly bool, page Page) ([]*models.InboxItem, int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	var total int
+	err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM inbox_items WHERE user_id = $1 AND ($2 = false OR read = false)`, userID, unreadOnly).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count inbox items: %w", err)
+	}
+
 	query := `
-		SELECT id, email, password_hash, COALESCE(name, ''), COALESCE(notification_webhook_url, ''), email_verified, COALESCE(verify_token, ''), created_at, updated_at
-		FROM users
-		WHERE email = $1
+		SELECT ` + inboxItemColumns + `
+		FROM inbox_items
+		WHERE user_id = $1 AND ($2 = false OR read = false)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
 	`
 
-	row := s.pool.QueryRow(ctx, query, email)
+	rows, err := s.pool.Query(ctx, query, userID, unreadOnly, page.limitArg(), page.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list inbox items: %w", err)
+	}
+	defer rows.Close()
 
-	var user models.User
-	err := row.Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.Name,
-		&user.NotificationWebhookURL,
-		&user.EmailVerified,
-		&user.VerifyToken,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	items := make([]*models.InboxItem, 0)
+	for rows.Next() {
+		item, err := scanInboxItem(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan inbox item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, total, rows.Err()
+}
 
+// CountUnreadInboxItems returns how many of a user's items are unread
+func (s *PostgresStore) CountUnreadInboxItems(userID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var count int
+	err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM inbox_items WHERE user_id = $1 AND read = false`, userID).Scan(&count)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrNotFound
-		}
-		return nil, fmt.Errorf("failed to get user by email: %w", err)
+		return 0, fmt.Errorf("failed to count unread inbox items: %w", err)
 	}
 
-	return &user, nil
+	return count, nil
 }
 
-// GetUserByVerifyToken retrieves a user by verification token
-func (s *PostgresStore) GetUserByVerifyToken(token string) (*models.User, error) {
+// MarkInboxItemRead marks one of a user's items as read
+func (s *PostgresStore) MarkInboxItemRead(userID, itemID string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	query := `
-		SELECT id, email, password_hash, COALESCE(name, ''), COALESCE(notification_webhook_url, ''), email_verified, COALESCE(verify_token, ''), created_at, updated_at
-		FROM users
-		WHERE verify_token = $1
-	`
+	result, err := s.pool.Exec(ctx, `UPDATE inbox_items SET read = true WHERE id = $1 AND user_id = $2`, itemID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark inbox item read: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
 
-	row := s.pool.QueryRow(ctx, query, token)
+	return nil
+}
 
-	var user models.User
-	err := row.Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.Name,
-		&user.NotificationWebhookURL,
-		&user.EmailVerified,
-		&user.VerifyToken,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+// MarkAllInboxItemsRead marks all of a user's items as read, returning how many were unread
+func (s *PostgresStore) MarkAllInboxItemsRead(userID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
+	result, err := s.pool.Exec(ctx, `UPDATE inbox_items SET read = true WHERE user_id = $1 AND read = false`, userID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrNotFound
-		}
-		return nil, fmt.Errorf("failed to get user by verify token: %w", err)
+		return 0, fmt.Errorf("failed to mark inbox items read: %w", err)
 	}
 
-	return &user, nil
+	return int(result.RowsAffected()), nil
 }
 
-// UpdateUser updates an existing user
-func (s *PostgresStore) UpdateUser(user *models.User) error {
-	if err := user.Validate(); err != nil {
-		return err
+// AddUsage adds the counts of usage to the record of its user and day
+func (s *PostgresStore) AddUsage(usage *models.UsageRecord) error {
+	if err := usage.Validate(); err != nil {
+		return invalid(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Selecting from users turns a missing user into zero affected rows instead of a foreign key error
 	query := `
-		UPDATE users
-		SET email = $2, password_hash = $3, name = $4, notification_webhook_url = $5, email_verified = $6, verify_token = $7, updated_at = $8
-		WHERE id = $1
+		INSERT INTO usage_records (user_id, day, status_reports, storage_bytes, notifications_sent)
+		SELECT id, $2, $3, $4, $5 FROM users WHERE id = $1
+		ON CONFLICT (user_id, day) DO UPDATE
+		SET status_reports = usage_records.status_reports + EXCLUDED.status_reports,
+			storage_bytes = usage_records.storage_bytes + EXCLUDED.storage_bytes,
+			notifications_sent = usage_records.notifications_sent + EXCLUDED.notifications_sent
 	`
 
 	result, err := s.pool.Exec(ctx, query,
-		user.ID,
-		user.Email,
-		user.PasswordHash,
-		user.Name,
-		user.NotificationWebhookURL,
-		user.EmailVerified,
-		user.VerifyToken,
-		user.UpdatedAt,
+		usage.UserID,
+		models.UsageDay(usage.Day),
+		usage.StatusReports,
+		usage.StorageBytes,
+		usage.NotificationsSent,
 	)
-
 	if err != nil {
-		if isDuplicateKeyError(err) {
-			return ErrDuplicateEmail
-		}
-		return fmt.Errorf("failed to update user: %w", err)
+		return fmt.Errorf("failed to add usage: %w", err)
 	}
-
 	if result.RowsAffected() == 0 {
 		return ErrNotFound
 	}
-
 	return nil
 }
 
-// SaveRefreshToken saves a refresh token
-func (s *PostgresStore) SaveRefreshToken(token *models.RefreshToken) error {
-	if err := token.Validate(); err != nil {
-		return err
+// ListUsage returns the usage records of a user, or of every user, for days in [from, to)
+func (s *PostgresStore) ListUsage(userID string, from, to time.Time) ([]*models.UsageRecord, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT user_id, day, status_reports, storage_bytes, notifications_sent
+		FROM usage_records
+		WHERE ($1 = '' OR user_id = $1) AND day >= $2::date AND day < $3::date
+		ORDER BY day, user_id
+	`
+
+	// Days are passed as text so the session time zone cannot shift them
+	rows, err := s.pool.Query(ctx, query, userID, models.UsageDay(from).Format(time.DateOnly), models.UsageDay(to).Format(time.DateOnly))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	defer rows.Close()
+
+	records := make([]*models.UsageRecord, 0)
+	for rows.Next() {
+		var record models.UsageRecord
+		if err := rows.Scan(
+			&record.UserID,
+			&record.Day,
+			&record.StatusReports,
+			&record.StorageBytes,
+			&record.NotificationsSent,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan usage record: %w", err)
+		}
+		record.Day = record.Day.UTC()
+		records = append(records, &record)
+	}
+
+	return records, rows.Err()
+}
+
+// AddTenantCounts adds the counts of delta to its user's counts
+func (s *PostgresStore) AddTenantCounts(delta *models.TenantCounts) error {
+	if err := delta.Validate(); err != nil {
+		return invalid(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Selecting from users turns a missing user into zero affected rows instead of a foreign key error
 	query := `
-		INSERT INTO refresh_tokens (id, user_id, token_hash, expires_at, created_at, revoked)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO tenant_counts (user_id, agents, sessions, statuses, updated_at)
+		SELECT id, $2, $3, $4, NOW() FROM users WHERE id = $1
+		ON CONFLICT (user_id) DO UPDATE
+		SET agents = tenant_counts.agents + EXCLUDED.agents,
+			sessions = tenant_counts.sessions + EXCLUDED.sessions,
+			statuses = tenant_counts.statuses + EXCLUDED.statuses,
+			updated_at = EXCLUDED.updated_at
 	`
 
-	_, err := s.pool.Exec(ctx, query,
-		token.ID,
-		token.UserID,
-		token.TokenHash,
-		token.ExpiresAt,
-		token.CreatedAt,
-		token.Revoked,
-	)
-
+	result, err := s.pool.Exec(ctx, query, delta.UserID, delta.Agents, delta.Sessions, delta.Statuses)
 	if err != nil {
-		return fmt.Errorf("failed to save refresh token: %w", err)
+		return fmt.Errorf("failed to add tenant counts: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
 	}
-
 	return nil
 }
 
-// GetRefreshTokenByID retrieves a refresh token by ID
-func (s *PostgresStore) GetRefreshTokenByID(tokenID string) (*models.RefreshToken, error) {
+// GetTenantCounts returns a user's counts
+func (s *PostgresStore) GetTenantCounts(userID string) (*models.TenantCounts, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT id, user_id, token_hash, expires_at, created_at, revoked
-		FROM refresh_tokens
-		WHERE id = $1
+		SELECT user_id, agents, sessions, statuses, reconciled_at, updated_at
+		FROM tenant_counts
+		WHERE user_id = $1
 	`
 
-	row := s.pool.QueryRow(ctx, query, tokenID)
-
-	var token models.RefreshToken
-	err := row.Scan(
-		&token.ID,
-		&token.UserID,
-		&token.TokenHash,
-		&token.ExpiresAt,
-		&token.CreatedAt,
-		&token.Revoked,
+	var counts models.TenantCounts
+	var reconciledAt *time.Time
+	err := s.pool.QueryRow(ctx, query, userID).Scan(
+		&counts.UserID,
+		&counts.Agents,
+		&counts.Sessions,
+		&counts.Statuses,
+		&reconciledAt,
+		&counts.UpdatedAt,
 	)
-
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrNotFound
-		}
-		return nil, fmt.Errorf("failed to get refresh token by ID: %w", err)
+		return nil, fmt.Errorf("failed to get tenant counts: %w", err)
 	}
-
-	return &token, nil
+	if reconciledAt != nil {
+		counts.ReconciledAt = reconciledAt.UTC()
+	}
+	counts.UpdatedAt = counts.UpdatedAt.UTC()
+	return &counts, nil
 }
 
-// GetRefreshToken retrieves a refresh token by hash
-func (s *PostgresStore) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ReconcileTenantCounts replaces the counts of every user with those of their stored records
+// The counting scans the agents, sessions and statuses tables once each, so it runs rarely and off the request path.
+func (s *PostgresStore) ReconcileTenantCounts(now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
 	query := `
-		SELECT id, user_id, token_hash, expires_at, created_at, revoked
-		FROM refresh_tokens
-		WHERE token_hash = $1
+		WITH live_agents AS (
+			SELECT agent_id, user_id FROM agents WHERE user_id IS NOT NULL AND deleted_at IS NULL
+		),
+		agent_counts AS (
+			SELECT user_id, COUNT(*) AS agents FROM live_agents GROUP BY user_id
+		),
+		session_counts AS (
+			SELECT a.user_id, COUNT(*) AS sessions
+			FROM sessions s JOIN live_agents a ON a.agent_id = s.agent_id
+			GROUP BY a.user_id
+		),
+		status_counts AS (
+			SELECT a.user_id, COUNT(*) AS statuses
+			FROM agent_statuses st JOIN live_agents a ON a.agent_id = st.agent_id
+			GROUP BY a.user_id
+		)
+		INSERT INTO tenant_counts (user_id, agents, sessions, statuses, reconciled_at, updated_at)
+		SELECT u.id, COALESCE(ac.agents, 0), COALESCE(sc.sessions, 0), COALESCE(stc.statuses, 0), $1, $1
+		FROM users u
+		LEFT JOIN agent_counts ac ON ac.user_id = u.id
+		LEFT JOIN session_counts sc ON sc.user_id = u.id
+		LEFT JOIN status_counts stc ON stc.user_id = u.id
+		ON CONFLICT (user_id) DO UPDATE
+		SET agents = EXCLUDED.agents,
+			sessions = EXCLUDED.sessions,
+			statuses = EXCLUDED.statuses,
+			reconciled_at = EXCLUDED.reconciled_at,
+			updated_at = EXCLUDED.updated_at
 	`
 
-	row := s.pool.QueryRow(ctx, query, tokenHash)
+	result, err := s.pool.Exec(ctx, query, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to reconcile tenant counts: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
 
-	var token models.RefreshToken
-	err := row.Scan(
-		&token.ID,
-		&token.UserID,
-		&token.TokenHash,
-		&token.ExpiresAt,
-		&token.CreatedAt,
-		&token.Revoked,
-	)
+// statusRollupColumns lists the status_rollups columns in scanStatusRollup order
+const statusRollupColumns = `agent_id, session_topic, day, running, success, failed, pending, runs, first_at, last_at, duration_seconds`
+
+// scanStatusRollup scans a row selected with statusRollupColumns
+func scanStatusRollup(row pgx.Row) (*models.StatusRollup, error) {
+	var rollup models.StatusRollup
+	if err := row.Scan(
+		&rollup.AgentID,
+		&rollup.SessionTopic,
+		&rollup.Day,
+		&rollup.Running,
+		&rollup.Success,
+		&rollup.Failed,
+		&rollup.Pending,
+		&rollup.Runs,
+		&rollup.FirstAt,
+		&rollup.LastAt,
+		&rollup.DurationSeconds,
+	); err != nil {
+		return nil, err
+	}
+	rollup.Day = rollup.Day.UTC()
+	rollup.FirstAt = rollup.FirstAt.UTC()
+	rollup.LastAt = rollup.LastAt.UTC()
+	return &rollup, nil
+}
+
+// RollupStatuses creates or replaces the rollups of the sessions with statuses on the days in [from, to)
+func (s *PostgresStore) RollupStatuses(from, to time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
 
+	// Runs are summarized first, so the duration of a day adds up the span of each revision
+	query := `
+		WITH runs AS (
+			SELECT agent_id, session_topic, (timestamp AT TIME ZONE 'UTC')::date AS day, revision,
+				COUNT(*) FILTER (WHERE status = 'running') AS running,
+				COUNT(*) FILTER (WHERE status = 'success') AS success,
+				COUNT(*) FILTER (WHERE status = 'failed') AS failed,
+				COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+				MIN(timestamp) AS first_at,
+				MAX(timestamp) AS last_at
+			FROM agent_statuses
+			WHERE timestamp >= $1 AND timestamp < $2
+			GROUP BY agent_id, session_topic, day, revision
+		)
+		INSERT INTO status_rollups (` + statusRollupColumns + `)
+		SELECT agent_id, session_topic, day, SUM(running), SUM(success), SUM(failed), SUM(pending), COUNT(*),
+			MIN(first_at), MAX(last_at), SUM(EXTRACT(EPOCH FROM last_at - first_at))
+		FROM runs
+		GROUP BY agent_id, session_topic, day
+		ON CONFLICT (agent_id, session_topic, day) DO UPDATE
+		SET running = EXCLUDED.running,
+			success = EXCLUDED.success,
+			failed = EXCLUDED.failed,
+			pending = EXCLUDED.pending,
+			runs = EXCLUDED.runs,
+			first_at = EXCLUDED.first_at,
+			last_at = EXCLUDED.last_at,
+			duration_seconds = EXCLUDED.duration_seconds
+	`
+
+	result, err := s.pool.Exec(ctx, query, models.UsageDay(from), models.UsageDay(to))
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrNotFound
-		}
-		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+		return 0, fmt.Errorf("failed to roll up statuses: %w", err)
 	}
-
-	return &token, nil
+	return int(result.RowsAffected()), nil
 }
 
-// RevokeRefreshToken revokes a refresh token by ID
-func (s *PostgresStore) RevokeRefreshToken(tokenID string) error {
+// SaveStatusRollup creates or replaces the rollup of its session and day
+func (s *PostgresStore) SaveStatusRollup(rollup *models.StatusRollup) error {
+	if err := rollup.Validate(); err != nil {
+		return invalid(err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Selecting from sessions turns a missing session into zero affected rows instead of a foreign key error
 	query := `
-		UPDATE refresh_tokens
-		SET revoked = true
-		WHERE id = $1
+		INSERT INTO status_rollups (` + statusRollupColumns + `)
+		SELECT agent_id, session_topic, $3::date, $4, $5, $6, $7, $8, $9, $10, $11
+		FROM sessions WHERE agent_id = $1 AND session_topic = $2
+		ON CONFLICT (agent_id, session_topic, day) DO UPDATE
+		SET running = EXCLUDED.running,
+			success = EXCLUDED.success,
+			failed = EXCLUDED.failed,
+			pending = EXCLUDED.pending,
+			runs = EXCLUDED.runs,
+			first_at = EXCLUDED.first_at,
+			last_at = EXCLUDED.last_at,
+			duration_seconds = EXCLUDED.duration_seconds
 	`
 
-	result, err := s.pool.Exec(ctx, query, tokenID)
+	result, err := s.pool.Exec(ctx, query,
+		rollup.AgentID,
+		rollup.SessionTopic,
+		rollup.Day.Format(time.DateOnly),
+		rollup.Running,
+		rollup.Success,
+		rollup.Failed,
+		rollup.Pending,
+		rollup.Runs,
+		rollup.FirstAt,
+		rollup.LastAt,
+		rollup.DurationSeconds,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to revoke refresh token: %w", err)
+		return fmt.Errorf("failed to save status rollup: %w", err)
 	}
-
 	if result.RowsAffected() == 0 {
 		return ErrNotFound
 	}
-
 	return nil
 }
 
-// RevokeAllUserTokens revokes all refresh tokens for a user
-func (s *PostgresStore) RevokeAllUserTokens(userID string) error {
+// ListStatusRollups returns the rollups of an agent, or of every agent, for days in [from, to)
+func (s *PostgresStore) ListStatusRollups(agentID, sessionTopic string, from, to time.Time) ([]*models.StatusRollup, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		UPDATE refresh_tokens
-		SET revoked = true
-		WHERE user_id = $1
+		SELECT ` + statusRollupColumns + `
+		FROM status_rollups
+		WHERE ($1 = '' OR agent_id = $1) AND ($2 = '' OR session_topic = $2) AND day >= $3::date AND day < $4::date
+		ORDER BY day, agent_id, session_topic
 	`
 
-	_, err := s.pool.Exec(ctx, query, userID)
+	// Days are passed as text so the session time zone cannot shift them
+	rows, err := s.pool.Query(ctx, query, agentID, sessionTopic, models.UsageDay(from).Format(time.DateOnly), models.UsageDay(to).Format(time.DateOnly))
 	if err != nil {
-		return fmt.Errorf("failed to revoke user tokens: %w", err)
+		return nil, fmt.Errorf("failed to list status rollups: %w", err)
 	}
+	defer rows.Close()
 
-	return nil
+	rollups := make([]*models.StatusRollup, 0)
+	for rows.Next() {
+		rollup, err := scanStatusRollup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status rollup: %w", err)
+		}
+		rollups = append(rollups, rollup)
+	}
+
+	return rollups, rows.Err()
 }
 
-// CreateAPIKey creates a new API key
-func (s *PostgresStore) CreateAPIKey(apiKey *models.APIKey) error {
-	if err := apiKey.Validate(); err != nil {
-		return err
+// AddAuditEvent appends an event to the audit log
+func (s *PostgresStore) AddAuditEvent(event *models.AuditEvent) error {
+	if err := event.Validate(); err != nil {
+		return invalid(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		INSERT INTO api_keys (id, user_id, name, key_hash, key_prefix, expires_at, last_used_at, created_at, revoked)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO audit_events (actor_id, actor_email, action, target, query, status_code, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
 	`
 
-	_, err := s.pool.Exec(ctx, query,
-		apiKey.ID,
-		apiKey.UserID,
-		apiKey.Name,
-		apiKey.KeyHash,
-		apiKey.KeyPrefix,
-		apiKey.ExpiresAt,
-		apiKey.LastUsedAt,
-		apiKey.CreatedAt,
-		apiKey.Revoked,
-	)
-
+	err := s.pool.QueryRow(ctx, query,
+		event.ActorID,
+		event.ActorEmail,
+		event.Action,
+		event.Target,
+		event.Query,
+		event.StatusCode,
+		event.CreatedAt,
+	).Scan(&event.ID)
 	if err != nil {
-		return fmt.Errorf("failed to create API key: %w", err)
+		return fmt.Errorf("failed to add audit event: %w", err)
 	}
-
 	return nil
 }
 
-// GetAPIKeyByHash retrieves an API key by its hash
-func (s *PostgresStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+// ListAuditEvents returns the events created at or after since, newest first
+func (s *PostgresStore) ListAuditEvents(since time.Time, limit int) ([]*models.AuditEvent, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT id, user_id, name, key_hash, key_prefix, expires_at, last_used_at, created_at, revoked
-		FROM api_keys
-		WHERE key_hash = $1
+		SELECT id, actor_id, actor_email, action, target, query, status_code, created_at
+		FROM audit_events
+		WHERE created_at >= $1
+		ORDER BY id DESC
+		LIMIT $2
 	`
 
-	row := s.pool.QueryRow(ctx, query, keyHash)
-
-	var apiKey models.APIKey
-	err := row.Scan(
-		&apiKey.ID,
-		&apiKey.UserID,
-		&apiKey.Name,
-		&apiKey.KeyHash,
-		&apiKey.KeyPrefix,
-		&apiKey.ExpiresAt,
-		&apiKey.LastUsedAt,
-		&apiKey.CreatedAt,
-		&apiKey.Revoked,
-	)
-
+	var limitArg any
+	if limit > 0 {
+		limitArg = limit
+	}
+	rows, err := s.pool.Query(ctx, query, since, limitArg)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrNotFound
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]*models.AuditEvent, 0)
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := rows.Scan(
+			&event.ID,
+			&event.ActorID,
+			&event.ActorEmail,
+			&event.Action,
+			&event.Target,
+			&event.Query,
+			&event.StatusCode,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
 		}
-		return nil, fmt.Errorf("failed to get API key: %w", err)
+		events = append(events, &event)
 	}
 
-	return &apiKey, nil
+	return events, rows.Err()
 }
 
-// GetAPIKeyByID retrieves an API key by its ID
-func (s *PostgresStore) GetAPIKeyByID(keyID string) (*models.APIKey, error) {
+// SaveNonce records a webhook nonce until expiresAt
+// An expired row for the same nonce is overwritten rather than treated as a replay.
+func (s *PostgresStore) SaveNonce(scope, nonce string, expiresAt time.Time) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT id, user_id, name, key_hash, key_prefix, expires_at, last_used_at, created_at, revoked
-		FROM api_keys
-		WHERE id = $1
+		INSERT INTO webhook_nonces (scope, nonce, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (scope, nonce) DO UPDATE
+		SET expires_at = EXCLUDED.expires_at
+		WHERE webhook_nonces.expires_at <= $4
 	`
 
-	row := s.pool.QueryRow(ctx, query, keyID)
-
-	var apiKey models.APIKey
-	err := row.Scan(
-		&apiKey.ID,
-		&apiKey.UserID,
-		&apiKey.Name,
-		&apiKey.KeyHash,
-		&apiKey.KeyPrefix,
-		&apiKey.ExpiresAt,
-		&apiKey.LastUsedAt,
-		&apiKey.CreatedAt,
-		&apiKey.Revoked,
-	)
-
+	result, err := s.pool.Exec(ctx, query, scope, nonce, expiresAt, s.clock.Now())
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, ErrNotFound
-		}
-		return nil, fmt.Errorf("failed to get API key: %w", err)
+		return fmt.Errorf("failed to save nonce: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAlreadyExists
 	}
 
-	return &apiKey, nil
+	return nil
 }
 
-// ListAPIKeysByUser returns all API keys for a user
-func (s *PostgresStore) ListAPIKeysByUser(userID string) ([]*models.APIKey, error) {
+// ClaimNotificationMark stores the mark unless the same one was notified after cutoff
+func (s *PostgresStore) ClaimNotificationMark(mark *models.NotificationMark, cutoff time.Time) error {
+	if err := mark.Validate(); err != nil {
+		return invalid(err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		SELECT id, user_id, name, key_hash, key_prefix, expires_at, last_used_at, created_at, revoked
-		FROM api_keys
-		WHERE user_id = $1
-		ORDER BY created_at DESC
+		INSERT INTO notification_marks (user_id, agent_id, session_topic, status, notified_at, escalations)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, agent_id, session_topic, status) DO UPDATE
+		SET notified_at = EXCLUDED.notified_at,
+		    escalations = EXCLUDED.escalations
+		WHERE notification_marks.notified_at <= $7
 	`
 
-	rows, err := s.pool.Query(ctx, query, userID)
+	result, err := s.pool.Exec(ctx, query, mark.UserID, mark.AgentID, mark.SessionTopic, mark.Status, mark.NotifiedAt, mark.Escalations, cutoff)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list API keys: %w", err)
+		return fmt.Errorf("failed to claim notification mark: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrAlreadyExists
+	}
+	return nil
+}
+
+// ListNotificationMarks returns the marks of a status
+func (s *PostgresStore) ListNotificationMarks(status string) ([]*models.NotificationMark, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT user_id, agent_id, session_topic, status, notified_at, escalations
+		FROM notification_marks
+		WHERE status = $1
+	`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification marks: %w", err)
 	}
 	defer rows.Close()
 
-	var keys []*models.APIKey
+	marks := make([]*models.NotificationMark, 0)
 	for rows.Next() {
-		var apiKey models.APIKey
-		if err := rows.Scan(
-			&apiKey.ID,
-			&apiKey.UserID,
-			&apiKey.Name,
-			&apiKey.KeyHash,
-			&apiKey.KeyPrefix,
-			&apiKey.ExpiresAt,
-			&apiKey.LastUsedAt,
-			&apiKey.CreatedAt,
-			&apiKey.Revoked,
-		); err != nil {
-			continue
+		var mark models.NotificationMark
+		if err := rows.Scan(&mark.UserID, &mark.AgentID, &mark.SessionTopic, &mark.Status, &mark.NotifiedAt, &mark.Escalations); err != nil {
+			return nil, fmt.Errorf("failed to scan notification mark: %w", err)
 		}
-		keys = append(keys, &apiKey)
+		marks = append(marks, &mark)
 	}
 
-	return keys, nil
+	return marks, rows.Err()
 }
 
-// RevokeAPIKey revokes an API key
-func (s *PostgresStore) RevokeAPIKey(keyID string) error {
+// PurgeNotificationMarks removes marks notified before the cutoff
+func (s *PostgresStore) PurgeNotificationMarks(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM notification_marks WHERE notified_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge notification marks: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// ClaimIdempotencyKey records a key whose report is being processed
+// An expired row for the same key is overwritten rather than returned; a key released while it is read
+// is reported as ErrConflict.
+func (s *PostgresStore) ClaimIdempotencyKey(record *models.IdempotencyRecord) (*models.IdempotencyRecord, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		UPDATE api_keys
-		SET revoked = true
-		WHERE id = $1
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, status_code, response, created_at, expires_at)
+		VALUES ($1, $2, $3, 0, NULL, $4, $5)
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint,
+		    status_code = 0,
+		    response = NULL,
+		    created_at = EXCLUDED.created_at,
+		    expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= $6
 	`
 
-	result, err := s.pool.Exec(ctx, query, keyID)
+	result, err := s.pool.Exec(ctx, query, record.UserID, record.Key, record.Fingerprint, record.CreatedAt, record.ExpiresAt, s.clock.Now())
 	if err != nil {
-		return fmt.Errorf("failed to revoke API key: %w", err)
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
 	}
-
-	if result.RowsAffected() == 0 {
-		return ErrNotFound
+	if result.RowsAffected() > 0 {
+		return nil, nil
 	}
 
-	return nil
+	existing := &models.IdempotencyRecord{UserID: record.UserID, Key: record.Key}
+	err = s.pool.QueryRow(ctx, `
+		SELECT fingerprint, status_code, response, created_at, expires_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`, record.UserID, record.Key).Scan(&existing.Fingerprint, &existing.StatusCode, &existing.Response, &existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrConflict
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return existing, ErrAlreadyExists
 }
 
-// UpdateAPIKeyLastUsed updates the last used timestamp of an API key
-func (s *PostgresStore) UpdateAPIKeyLastUsed(keyID string) error {
+// CompleteIdempotencyKey stores the response of a claimed key until expiresAt
+func (s *PostgresStore) CompleteIdempotencyKey(userID, key string, statusCode int, response []byte, expiresAt time.Time) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	query := `
-		UPDATE api_keys
-		SET last_used_at = $2
-		WHERE id = $1
+		UPDATE idempotency_keys
+		SET status_code = $3, response = $4, expires_at = $5
+		WHERE user_id = $1 AND key = $2
 	`
 
-	_, err := s.pool.Exec(ctx, query, keyID, time.Now())
+	result, err := s.pool.Exec(ctx, query, userID, key, statusCode, response, expiresAt)
 	if err != nil {
-		return fmt.Errorf("failed to update API key last used: %w", err)
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
 	}
-
 	return nil
 }
 
-// GetConfig retrieves a config value by key
-func (s *PostgresStore) GetConfig(key string) (string, error) {
+// ReleaseIdempotencyKey removes a key so its report can be retried
+func (s *PostgresStore) ReleaseIdempotencyKey(userID, key string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	query := `SELECT value FROM system_config WHERE key = $1`
+	if _, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2`, userID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
 
-	var value string
-	err := s.pool.QueryRow(ctx, query, key).Scan(&value)
+// PurgeExpiredRefreshTokens removes refresh tokens past their expiry
+func (s *PostgresStore) PurgeExpiredRefreshTokens() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM refresh_tokens WHERE expires_at <= $1`, s.clock.Now())
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return "", ErrNotFound
-		}
-		return "", fmt.Errorf("failed to get config: %w", err)
+		return 0, fmt.Errorf("failed to purge refresh tokens: %w", err)
 	}
-
-	return value, nil
+	return int(result.RowsAffected()), nil
 }
 
-// SetConfig sets a config value (upsert)
-func (s *PostgresStore) SetConfig(key, value string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// ClearExpiredVerifyTokens clears email verification tokens past their expiry
+func (s *PostgresStore) ClearExpiredVerifyTokens() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	query := `
-		INSERT INTO system_config (key, value, updated_at)
-		VALUES ($1, $2, NOW())
-		ON CONFLICT (key) DO UPDATE
-		SET value = EXCLUDED.value, updated_at = NOW()
+		UPDATE users
+		SET verify_token = NULL,
+		    verify_token_expires_at = NULL
+		WHERE verify_token_expires_at <= $1
 	`
 
-	_, err := s.pool.Exec(ctx, query, key, value)
+	result, err := s.pool.Exec(ctx, query, s.clock.Now())
 	if err != nil {
-		return fmt.Errorf("failed to set config: %w", err)
+		return 0, fmt.Errorf("failed to clear verification tokens: %w", err)
 	}
+	return int(result.RowsAffected()), nil
+}
 
-	return nil
+// PurgeSLABreaches removes breaches detected before the cutoff
+func (s *PostgresStore) PurgeSLABreaches(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM sla_breaches WHERE detected_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge SLA breaches: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// PurgeAuditEvents removes events created before the cutoff
+func (s *PostgresStore) PurgeAuditEvents(before time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM audit_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge audit events: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// PurgeExpiredNonces removes nonces past their expiry
+func (s *PostgresStore) PurgeExpiredNonces() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM webhook_nonces WHERE expires_at <= $1`, s.clock.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge nonces: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// PurgeExpiredIdempotencyKeys removes idempotency keys past their expiry
+func (s *PostgresStore) PurgeExpiredIdempotencyKeys() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, s.clock.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+	return int(result.RowsAffected()), nil
+}
+
+// nullableJSON converts optional JSON to a query argument, storing NULL when empty
+func nullableJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
 }
 
 // isDuplicateKeyError checks if the error is a duplicate key violation
@@ -973,3 +4225,107 @@ func isDuplicateKeyError(err error) bool {
 	}
 	return false
 }
+
+// isForeignKeyError checks if the error is a write referring to a missing row
+func isForeignKeyError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23503" // foreign_key_violation
+	}
+	return false
+}
+
+// outboxColumns lists outbox columns in the order scanned by scanOutboxMessage
+const outboxColumns = "id, kind, payload, attempts, available_at, COALESCE(last_error, ''), created_at"
+
+// scanOutboxMessage scans a row selected with outboxColumns
+func scanOutboxMessage(row pgx.Row) (*models.OutboxMessage, error) {
+	var message models.OutboxMessage
+	var payload []byte
+	err := row.Scan(
+		&message.ID,
+		&message.Kind,
+		&payload,
+		&message.Attempts,
+		&message.AvailableAt,
+		&message.LastError,
+		&message.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	message.Payload = payload
+	return &message, nil
+}
+
+// ClaimOutboxMessages claims up to limit messages available at now, oldest first
+// Claimed messages are hidden until now+lease and their attempt is counted;
+// SKIP LOCKED lets relays of several replicas claim concurrently without taking the same message.
+func (s *PostgresStore) ClaimOutboxMessages(now time.Time, lease time.Duration, limit int) ([]*models.OutboxMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := `
+		UPDATE outbox
+		SET available_at = $2, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE available_at <= $1
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	rows, err := s.pool.Query(ctx, query, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		message, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, nil
+}
+
+// DeleteOutboxMessage removes a delivered or abandoned message
+func (s *PostgresStore) DeleteOutboxMessage(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `DELETE FROM outbox WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete outbox message: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RetryOutboxMessage records a failed delivery and makes the message available again at availableAt
+func (s *PostgresStore) RetryOutboxMessage(id int64, availableAt time.Time, lastError string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := s.pool.Exec(ctx, `UPDATE outbox SET available_at = $2, last_error = $3 WHERE id = $1`, id, availableAt, lastError)
+	if err != nil {
+		return fmt.Errorf("failed to reschedule outbox message: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
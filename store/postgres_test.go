@@ -0,0 +1,126 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/kubeagents/kubeagents/store"
+	"github.com/kubeagents/kubeagents/store/storetest"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+)
+
+// postgresDSNEnv points the Postgres tests at an existing database instead of a container
+// The database is migrated and its tables are truncated, so never point it at real data.
+const postgresDSNEnv = "KUBEAGENTS_TEST_POSTGRES_DSN"
+
+func TestPostgresStore_Conformance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Postgres conformance tests in short mode")
+	}
+
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		dsn = startPostgres(t)
+	}
+
+	ctx := context.Background()
+	pgStore, err := store.NewPostgresStore(ctx, dsn)
+	if err != nil {
+		t.Fatalf("NewPostgresStore() error = %v", err)
+	}
+	t.Cleanup(func() { pgStore.Close() })
+
+	conn, err := pgStore.Pool().Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	err = store.RunMigrations(ctx, conn.Conn())
+	conn.Release()
+	if err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+
+	storetest.Run(t, func(t *testing.T) store.Store {
+		truncateTables(t, pgStore)
+		return pgStore
+	})
+}
+
+// startPostgres runs a disposable Postgres container and returns its connection string
+// The test is skipped when Docker is not available.
+func startPostgres(t *testing.T) string {
+	t.Helper()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("Docker unavailable, set %s to use an existing database: %v", postgresDSNEnv, err)
+	}
+	if err := pool.Client.Ping(); err != nil {
+		t.Skipf("Docker unavailable, set %s to use an existing database: %v", postgresDSNEnv, err)
+	}
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "postgres",
+		Tag:        "15-alpine",
+		Env:        []string{"POSTGRES_USER=kubeagents", "POSTGRES_PASSWORD=kubeagents", "POSTGRES_DB=kubeagents"},
+	}, func(hc *docker.HostConfig) {
+		hc.AutoRemove = true
+		hc.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		t.Fatalf("failed to start Postgres container: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := pool.Purge(resource); err != nil {
+			t.Logf("failed to remove Postgres container: %v", err)
+		}
+	})
+	// Reap the container even if the test binary is killed before cleanup runs
+	resource.Expire(300)
+
+	dsn := fmt.Sprintf("postgres://kubeagents:kubeagents@%s/kubeagents?sslmode=disable", resource.GetHostPort("5432/tcp"))
+	pool.MaxWait = time.Minute
+	err = pool.Retry(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		conn, err := pgx.Connect(ctx, dsn)
+		if err != nil {
+			return err
+		}
+		defer conn.Close(ctx)
+		return conn.Ping(ctx)
+	})
+	if err != nil {
+		t.Fatalf("Postgres container did not become ready: %v", err)
+	}
+
+	return dsn
+}
+
+// truncateTables empties every table except the migration history
+func truncateTables(t *testing.T, pgStore *store.PostgresStore) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var tables string
+	err := pgStore.Pool().QueryRow(ctx, `
+		SELECT string_agg(quote_ident(tablename), ', ')
+		FROM pg_tables
+		WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'
+	`).Scan(&tables)
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+
+	if _, err := pgStore.Pool().Exec(ctx, "TRUNCATE "+tables+" CASCADE"); err != nil {
+		t.Fatalf("failed to truncate tables: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+)
+
+// Query selects the agents listed by QueryAgents or the sessions listed by QuerySessions
+// Empty fields match everything. Fields of the other listing must be left empty; the stores reject them as KindInvalid.
+type Query struct {
+	// Agents are owned by UserID or shared with the organization OrgID; sessions belong to AgentID
+	UserID  string
+	OrgID   string
+	AgentID string
+
+	Search string    // Case-insensitive substring of the agent ID or name, or of the session topic
+	Status string    // Latest status of the session, or of the agent's unexpired sessions
+	Since  time.Time // Agents seen or sessions updated at or after Since
+	Until  time.Time // Agents seen or sessions updated before Until
+
+	// Agents only
+	State   string // One of the AgentState constants
+	Kind    string
+	Cluster string
+	Region  string
+
+	// Sessions only
+	Group          string
+	Category       string
+	ExcludeExpired bool
+
+	Page Page
+}
+
+// validateAgents checks a query of QueryAgents
+func (q *Query) validateAgents() error {
+	if (q.UserID == "") == (q.OrgID == "") {
+		return errors.New("exactly one of user and organization is required to list agents")
+	}
+	if q.AgentID != "" || q.Group != "" || q.Category != "" || q.ExcludeExpired {
+		return errors.New("agent, group, category and expired only filter sessions")
+	}
+	if q.State != "" && !models.ValidAgentState(q.State) {
+		return errors.New("state must be one of: online, stale, offline")
+	}
+	if err := models.ValidateAgentKind("kind", q.Kind); err != nil {
+		return err
+	}
+	if err := models.ValidateLocationLabel("cluster", q.Cluster); err != nil {
+		return err
+	}
+	if err := models.ValidateLocationLabel("region", q.Region); err != nil {
+		return err
+	}
+	return q.validateCommon()
+}
+
+// validateSessions checks a query of QuerySessions
+func (q *Query) validateSessions() error {
+	if q.AgentID == "" {
+		return errors.New("agent is required to list sessions")
+	}
+	if q.UserID != "" || q.OrgID != "" || q.State != "" || q.Kind != "" || q.Cluster != "" || q.Region != "" {
+		return errors.New("user, organization, state, kind, cluster and region only filter agents")
+	}
+	return q.validateCommon()
+}
+
+// validateCommon checks the fields both listings accept
+func (q *Query) validateCommon() error {
+	if q.Status != "" && !models.ValidStatus(q.Status) {
+		return fmt.Errorf("status %q is not a known status", q.Status)
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Since.Before(q.Until) {
+		return errors.New("since must be before until")
+	}
+	if q.Page.Offset < 0 || q.Page.Limit < 0 {
+		return errors.New("page offset and limit must not be negative")
+	}
+	return nil
+}
+
+// matchesAgent reports whether an agent passes the query's filters, all but Status
+func (q *Query) matchesAgent(agent *models.Agent) bool {
+	if q.UserID != "" && agent.UserID != q.UserID || q.OrgID != "" && agent.OrgID != q.OrgID {
+		return false
+	}
+	if q.Search != "" && !containsFold(agent.AgentID, q.Search) && !containsFold(agent.Name, q.Search) {
+		return false
+	}
+	if q.State != "" && agent.State != q.State || q.Kind != "" && agent.Kind != q.Kind {
+		return false
+	}
+	if q.Cluster != "" && agent.Cluster != q.Cluster || q.Region != "" && agent.Region != q.Region {
+		return false
+	}
+	return q.inWindow(agent.LastSeen)
+}
+
+// matchesSession reports whether a session passes the query's filters, all but Status
+func (q *Query) matchesSession(session *models.Session) bool {
+	if session.AgentID != q.AgentID || q.ExcludeExpired && session.Expired {
+		return false
+	}
+	if q.Search != "" && !containsFold(session.SessionTopic, q.Search) {
+		return false
+	}
+	if q.Group != "" && session.Group != q.Group || q.Category != "" && session.Category != q.Category {
+		return false
+	}
+	return q.inWindow(session.LastUpdated)
+}
+
+// inWindow reports whether t is within [Since, Until)
+func (q *Query) inWindow(t time.Time) bool {
+	return (q.Since.IsZero() || !t.Before(q.Since)) && (q.Until.IsZero() || t.Before(q.Until))
+}
+
+// containsFold reports whether substr is within s, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// sqlFilter builds a WHERE clause, numbering the arguments of its conditions
+type sqlFilter struct {
+	conditions []string
+	args       []any
+}
+
+// arg adds an argument and returns its placeholder
+func (f *sqlFilter) arg(value any) string {
+	f.args = append(f.args, value)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+// where returns the clause joining the conditions
+func (f *sqlFilter) where() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+// add adds a condition
+func (f *sqlFilter) add(condition string) {
+	f.conditions = append(f.conditions, condition)
+}
+
+// agentsFilter returns the filter of QueryAgents over the agents table
+func (q *Query) agentsFilter() *sqlFilter {
+	f := &sqlFilter{}
+	f.add("deleted_at IS NULL")
+	if q.UserID != "" {
+		f.add("user_id = " + f.arg(q.UserID))
+	}
+	if q.OrgID != "" {
+		f.add("org_id = " + f.arg(q.OrgID))
+	}
+	if q.Search != "" {
+		search := f.arg(strings.ToLower(q.Search))
+		f.add("(strpos(LOWER(agent_id), " + search + ") > 0 OR strpos(LOWER(name), " + search + ") > 0)")
+	}
+	if q.Status != "" {
+		// The latest status of the unexpired sessions, as the agent listing shows it
+		f.add(`(SELECT st.status FROM agent_statuses st
+			JOIN sessions se ON se.agent_id = st.agent_id AND se.session_topic = st.session_topic
+			WHERE st.agent_id = agents.agent_id AND se.expired = false
+			ORDER BY st.timestamp DESC LIMIT 1) = ` + f.arg(q.Status))
+	}
+	if q.State != "" {
+		f.add("state = " + f.arg(q.State))
+	}
+	if q.Kind != "" {
+		f.add("kind = " + f.arg(q.Kind))
+	}
+	if q.Cluster != "" {
+		f.add("cluster = " + f.arg(q.Cluster))
+	}
+	if q.Region != "" {
+		f.add("region = " + f.arg(q.Region))
+	}
+	q.addWindow(f, "last_seen")
+	return f
+}
+
+// sessionsFilter returns the filter of QuerySessions over the sessions table
+func (q *Query) sessionsFilter() *sqlFilter {
+	f := &sqlFilter{}
+	f.add("agent_id = " + f.arg(q.AgentID))
+	if q.ExcludeExpired {
+		f.add("expired = false")
+	}
+	if q.Search != "" {
+		f.add("strpos(LOWER(session_topic), " + f.arg(strings.ToLower(q.Search)) + ") > 0")
+	}
+	if q.Status != "" {
+		f.add(`(SELECT st.status FROM agent_statuses st
+			WHERE st.agent_id = sessions.agent_id AND st.session_topic = sessions.session_topic
+			ORDER BY st.timestamp DESC LIMIT 1) = ` + f.arg(q.Status))
+	}
+	if q.Group != "" {
+		f.add("session_group = " + f.arg(q.Group))
+	}
+	if q.Category != "" {
+		f.add("category = " + f.arg(q.Category))
+	}
+	q.addWindow(f, "last_updated")
+	return f
+}
+
+// addWindow adds the Since and Until conditions on column
+func (q *Query) addWindow(f *sqlFilter, column string) {
+	if !q.Since.IsZero() {
+		f.add(column + " >= " + f.arg(q.Since.UTC()))
+	}
+	if !q.Until.IsZero() {
+		f.add(column + " < " + f.arg(q.Until.UTC()))
+	}
+}
@@ -0,0 +1,2371 @@
+// Package storetest provides a conformance suite for store.Store implementations
+// Every backend runs the same suite so they stay behaviorally identical.
+package storetest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/clock"
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Factory returns an empty store for one subtest
+// Implementations register any cleanup with t.Cleanup.
+type Factory func(t *testing.T) store.Store
+
+// Run runs the Store contract against stores created by newStore
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, st store.Store)
+	}{
+		{"Users", testUsers},
+		{"DeleteUser", testDeleteUser},
+		{"DataKeys", testDataKeys},
+		{"RefreshTokens", testRefreshTokens},
+		{"APIKeys", testAPIKeys},
+		{"ClientCertificates", testClientCertificates},
+		{"EnrollmentTokens", testEnrollmentTokens},
+		{"Organizations", testOrganizations},
+		{"Agents", testAgents},
+		{"AgentLifecycle", testAgentLifecycle},
+		{"Sessions", testSessions},
+		{"Statuses", testStatuses},
+		{"Pages", testPages},
+		{"Queries", testQueries},
+		{"StatusAnnotations", testStatusAnnotations},
+		{"StatusPruning", testStatusPruning},
+		{"RunningSessions", testRunningSessions},
+		{"Overview", testOverview},
+		{"Outbox", testOutbox},
+		{"ExpiredSessions", testExpiredSessions},
+		{"Integrity", testIntegrity},
+		{"Clock", testClock},
+		{"SLAs", testSLAs},
+		{"SLABreaches", testSLABreaches},
+		{"AlertRules", testAlertRules},
+		{"NotificationMarks", testNotificationMarks},
+		{"SessionWebhooks", testSessionWebhooks},
+		{"WatchItems", testWatchItems},
+		{"NotificationSettings", testNotificationSettings},
+		{"InboxItems", testInboxItems},
+		{"Usage", testUsage},
+		{"TenantCounts", testTenantCounts},
+		{"StatusRollups", testStatusRollups},
+		{"AuditEvents", testAuditEvents},
+		{"Nonces", testNonces},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"VerifyTokens", testVerifyTokens},
+		{"Config", testConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// now returns the current time at the precision every backend preserves
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// mustCreateUser creates a user that owns the records of a subtest
+func mustCreateUser(t *testing.T, st store.Store, id, email string) *models.User {
+	t.Helper()
+
+	ts := now()
+	user := &models.User{
+		ID:           id,
+		Email:        email,
+		PasswordHash: "hash",
+		Name:         "Test User",
+		CreatedAt:    ts,
+		UpdatedAt:    ts,
+	}
+	if err := st.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser(%s) error = %v", id, err)
+	}
+	return user
+}
+
+// mustCreateAgent creates an agent last seen at lastSeen
+func mustCreateAgent(t *testing.T, st store.Store, agentID, userID string, lastSeen time.Time) *models.Agent {
+	t.Helper()
+
+	agent := &models.Agent{
+		AgentID:    agentID,
+		UserID:     userID,
+		Name:       "Agent " + agentID,
+		Registered: lastSeen,
+		LastSeen:   lastSeen,
+	}
+	if err := st.CreateOrUpdateAgent(agent); err != nil {
+		t.Fatalf("CreateOrUpdateAgent(%s) error = %v", agentID, err)
+	}
+	return agent
+}
+
+// mustCreateSession creates a session last updated at lastUpdated
+func mustCreateSession(t *testing.T, st store.Store, agentID, topic string, lastUpdated time.Time) *models.Session {
+	t.Helper()
+
+	session := &models.Session{
+		AgentID:      agentID,
+		SessionTopic: topic,
+		Created:      lastUpdated,
+		LastUpdated:  lastUpdated,
+		TTLMinutes:   30,
+	}
+	if err := st.CreateOrUpdateSession(session); err != nil {
+		t.Fatalf("CreateOrUpdateSession(%s, %s) error = %v", agentID, topic, err)
+	}
+	return session
+}
+
+func testUsers(t *testing.T, st store.Store) {
+	expires := now().Add(time.Hour)
+	user := &models.User{
+		ID:                     "user-1",
+		Email:                  "alice@example.com",
+		PasswordHash:           "hash",
+		Name:                   "Alice",
+		NotificationWebhookURL: "https://hooks.example.com/alice",
+		NotificationMentions:   []models.MentionRule{{AgentID: "agent-1", ChatUserID: "U123"}},
+		NotificationDestinations: []models.NotificationDestination{
+			{URL: "https://hooks.example.com/agents/{{.AgentID}}", Format: "slack"},
+		},
+		Plan:                 "pro",
+		Role:                 models.UserRoleViewer,
+		SessionAutoClose:     models.SessionAutoClose{OnDelete: models.SessionCloseFail},
+		VerifyToken:          "verify-1",
+		VerifyTokenExpiresAt: &expires,
+		CreatedAt:            now(),
+		UpdatedAt:            now(),
+	}
+	if err := st.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	duplicate := &models.User{ID: "user-3", Email: "alice@example.com", PasswordHash: "hash"}
+	if err := st.CreateUser(duplicate); !errors.Is(err, store.ErrDuplicateEmail) {
+		t.Errorf("CreateUser() duplicate email error = %v, want %v", err, store.ErrDuplicateEmail)
+	}
+
+	got, err := st.GetUserByID("user-1")
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if got.Email != user.Email || got.Name != user.Name || got.Plan != user.Plan || got.Role != user.Role ||
+		got.NotificationWebhookURL != user.NotificationWebhookURL || got.SessionAutoClose != user.SessionAutoClose {
+		t.Errorf("GetUserByID() = %+v, want %+v", got, user)
+	}
+	if !reflect.DeepEqual(got.NotificationMentions, user.NotificationMentions) {
+		t.Errorf("GetUserByID() mentions = %+v, want %+v", got.NotificationMentions, user.NotificationMentions)
+	}
+	if !reflect.DeepEqual(got.NotificationDestinations, user.NotificationDestinations) {
+		t.Errorf("GetUserByID() destinations = %+v, want %+v", got.NotificationDestinations, user.NotificationDestinations)
+	}
+	if got.VerifyTokenExpiresAt == nil || !got.VerifyTokenExpiresAt.Equal(expires) {
+		t.Errorf("GetUserByID() verify token expiry = %v, want %v", got.VerifyTokenExpiresAt, expires)
+	}
+
+	if got, err := st.GetUserByEmail("alice@example.com"); err != nil || got.ID != "user-1" {
+		t.Errorf("GetUserByEmail() = %v, %v, want user-1", got, err)
+	}
+	if got, err := st.GetUserByVerifyToken("verify-1"); err != nil || got.ID != "user-1" {
+		t.Errorf("GetUserByVerifyToken() = %v, %v, want user-1", got, err)
+	}
+	if _, err := st.GetUserByID("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserByID() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetUserByEmail("missing@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserByEmail() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetUserByVerifyToken("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserByVerifyToken() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	got.Name = "Alice Smith"
+	got.Email = "alice.smith@example.com"
+	got.EmailVerified = true
+	got.VerifyToken = ""
+	got.VerifyTokenExpiresAt = nil
+	disabled := now()
+	got.DisabledAt = &disabled
+	got.SessionAutoClose = models.SessionAutoClose{OnOffline: models.SessionCloseExpire}
+	got.ExportPublicKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	if err := st.UpdateUser(got); err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	updated, err := st.GetUserByEmail("alice.smith@example.com")
+	if err != nil || updated.Name != "Alice Smith" || !updated.EmailVerified || updated.VerifyTokenExpiresAt != nil ||
+		updated.DisabledAt == nil || !updated.DisabledAt.Equal(disabled) || updated.SessionAutoClose != got.SessionAutoClose ||
+		updated.ExportPublicKey != got.ExportPublicKey {
+		t.Errorf("GetUserByEmail() after update = %+v, %v", updated, err)
+	}
+	if _, err := st.GetUserByEmail("alice@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserByEmail() old email error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	updated.Email = "bob@example.com"
+	if err := st.UpdateUser(updated); !errors.Is(err, store.ErrDuplicateEmail) {
+		t.Errorf("UpdateUser() duplicate email error = %v, want %v", err, store.ErrDuplicateEmail)
+	}
+	missing := &models.User{ID: "missing", Email: "missing@example.com", PasswordHash: "hash"}
+	if err := st.UpdateUser(missing); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateUser() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	users, err := st.ListUsers()
+	if err != nil || len(users) != 2 || users[0].ID != "user-1" || users[1].ID != "user-2" {
+		t.Errorf("ListUsers() = %+v, %v, want user-1 and user-2", users, err)
+	}
+	page, total, err := st.ListUsersPage(store.Page{Offset: 1, Limit: 1})
+	if err != nil || total != 2 || len(page) != 1 || page[0].ID != "user-2" {
+		t.Errorf("ListUsersPage(1, 1) = %+v, %d, %v, want user-2 of 2", page, total, err)
+	}
+}
+
+func testDeleteUser(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateSession(t, st, "agent-1", "topic-1", ts)
+	mustCreateAgent(t, st, "agent-2", "user-2", ts)
+	status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "topic-1", Status: "running", Timestamp: ts}
+	if err := st.AddStatus(status); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+	apiKey := &models.APIKey{ID: "key-1", UserID: "user-1", Name: "ci", KeyHash: "hash-1", KeyPrefix: "ka_aaaaa", CreatedAt: ts}
+	if err := st.CreateAPIKey(apiKey); err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+
+	if err := st.DeleteUser("user-1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := st.GetUserByID("user-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserByID() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetUserByEmail("alice@example.com"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserByEmail() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetAgent("agent-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAgent() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetSession("agent-1", "topic-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSession() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if history, _ := st.GetStatusHistory("agent-1", "topic-1"); len(history) != 0 {
+		t.Errorf("GetStatusHistory() after delete = %d statuses, want 0", len(history))
+	}
+	if _, err := st.GetAPIKeyByHash("hash-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAPIKeyByHash() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	// Other users keep their records
+	if _, err := st.GetAgent("agent-2"); err != nil {
+		t.Errorf("GetAgent() of another user error = %v", err)
+	}
+	if err := st.DeleteUser("user-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteUser() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testDataKeys(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+
+	if _, err := st.GetUserDataKey("user-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserDataKey() before set error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	got, err := st.SetUserDataKey("user-1", []byte("first"))
+	if err != nil || string(got) != "first" {
+		t.Fatalf("SetUserDataKey() = %q, %v, want first", got, err)
+	}
+
+	// The first key wins, so replicas racing to create one agree
+	got, err = st.SetUserDataKey("user-1", []byte("second"))
+	if err != nil || string(got) != "first" {
+		t.Errorf("SetUserDataKey() again = %q, %v, want the stored key", got, err)
+	}
+	if got, err := st.GetUserDataKey("user-1"); err != nil || string(got) != "first" {
+		t.Errorf("GetUserDataKey() = %q, %v, want first", got, err)
+	}
+
+	if _, err := st.SetUserDataKey("missing", []byte("key")); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("SetUserDataKey() missing user error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testRefreshTokens(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+
+	ts := now()
+	tokens := []*models.RefreshToken{
+		{ID: "token-1", UserID: "user-1", TokenHash: "hash-1", ExpiresAt: ts.Add(time.Hour), CreatedAt: ts},
+		{ID: "token-2", UserID: "user-1", TokenHash: "hash-2", ExpiresAt: ts.Add(time.Hour), CreatedAt: ts},
+		{ID: "token-3", UserID: "user-1", TokenHash: "hash-3", ExpiresAt: ts.Add(-time.Hour), CreatedAt: ts},
+	}
+	for _, token := range tokens {
+		if err := st.SaveRefreshToken(token); err != nil {
+			t.Fatalf("SaveRefreshToken(%s) error = %v", token.ID, err)
+		}
+	}
+
+	if got, err := st.GetRefreshToken("hash-1"); err != nil || got.ID != "token-1" || !got.ExpiresAt.Equal(tokens[0].ExpiresAt) {
+		t.Errorf("GetRefreshToken() = %+v, %v, want token-1", got, err)
+	}
+	if got, err := st.GetRefreshTokenByID("token-2"); err != nil || got.TokenHash != "hash-2" {
+		t.Errorf("GetRefreshTokenByID() = %+v, %v, want hash-2", got, err)
+	}
+	if _, err := st.GetRefreshToken("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetRefreshToken() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	if err := st.RevokeRefreshToken("token-1"); err != nil {
+		t.Fatalf("RevokeRefreshToken() error = %v", err)
+	}
+	if err := st.RevokeRefreshToken("token-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RevokeRefreshToken() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+	if got, err := st.GetRefreshTokenByID("token-1"); err != nil || !got.Revoked {
+		t.Errorf("GetRefreshTokenByID() after revoke = %+v, %v, want revoked", got, err)
+	}
+
+	if err := st.RevokeAllUserTokens("user-1"); err != nil {
+		t.Fatalf("RevokeAllUserTokens() error = %v", err)
+	}
+	if got, err := st.GetRefreshTokenByID("token-2"); err != nil || !got.Revoked {
+		t.Errorf("GetRefreshTokenByID() after revoking all = %+v, %v, want revoked", got, err)
+	}
+
+	purged, err := st.PurgeExpiredRefreshTokens()
+	if err != nil || purged != 1 {
+		t.Errorf("PurgeExpiredRefreshTokens() = %d, %v, want 1", purged, err)
+	}
+	if _, err := st.GetRefreshTokenByID("token-3"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetRefreshTokenByID() after purge error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testAPIKeys(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	keys := []*models.APIKey{
+		{ID: "key-1", UserID: "user-1", Name: "ci", KeyHash: "hash-1", KeyPrefix: "ka_aaaaa", BurstCredits: 50, FieldCasing: models.FieldCasingCamel, CreatedAt: ts.Add(-time.Minute)},
+		{ID: "key-2", UserID: "user-1", Name: "laptop", KeyHash: "hash-2", KeyPrefix: "ka_bbbbb", CreatedAt: ts},
+		{ID: "key-3", UserID: "user-2", Name: "other", KeyHash: "hash-3", KeyPrefix: "ka_ccccc", CreatedAt: ts},
+	}
+	for _, key := range keys {
+		if err := st.CreateAPIKey(key); err != nil {
+			t.Fatalf("CreateAPIKey(%s) error = %v", key.ID, err)
+		}
+	}
+
+	if got, err := st.GetAPIKeyByHash("hash-1"); err != nil || got.ID != "key-1" || got.KeyPrefix != "ka_aaaaa" || got.BurstCredits != 50 || got.FieldCasing != models.FieldCasingCamel {
+		t.Errorf("GetAPIKeyByHash() = %+v, %v, want key-1 with 50 burst credits and camelCase responses", got, err)
+	}
+	if _, err := st.GetAPIKeyByHash("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAPIKeyByHash() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetAPIKeyByID("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAPIKeyByID() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	listed, err := st.ListAPIKeysByUser("user-1")
+	if err != nil {
+		t.Fatalf("ListAPIKeysByUser() error = %v", err)
+	}
+	if len(listed) != 2 || listed[0].ID != "key-2" || listed[1].ID != "key-1" {
+		t.Errorf("ListAPIKeysByUser() = %v, want key-2 then key-1", apiKeyIDs(listed))
+	}
+
+	if err := st.UpdateAPIKeyLastUsed("key-1"); err != nil {
+		t.Fatalf("UpdateAPIKeyLastUsed() error = %v", err)
+	}
+	if err := st.RevokeAPIKey("key-1"); err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+	got, err := st.GetAPIKeyByID("key-1")
+	if err != nil || !got.Revoked || got.LastUsedAt == nil {
+		t.Errorf("GetAPIKeyByID() after use and revoke = %+v, %v, want revoked with last use", got, err)
+	}
+	if err := st.RevokeAPIKey("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RevokeAPIKey() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+// apiKeyIDs returns key IDs for failure messages
+func apiKeyIDs(keys []*models.APIKey) []string {
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, key.ID)
+	}
+	return ids
+}
+
+func testClientCertificates(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	certs := []*models.ClientCertificate{
+		{ID: "cert-1", UserID: "user-1", Name: "fleet", Fingerprint: strings.Repeat("a", 64), AgentID: "agent-1", CreatedAt: ts.Add(-time.Minute)},
+		{ID: "cert-2", UserID: "user-1", Name: "laptop", Fingerprint: strings.Repeat("b", 64), CreatedAt: ts},
+	}
+	for _, cert := range certs {
+		if err := st.CreateClientCertificate(cert); err != nil {
+			t.Fatalf("CreateClientCertificate(%s) error = %v", cert.ID, err)
+		}
+	}
+
+	duplicate := &models.ClientCertificate{ID: "cert-3", UserID: "user-2", Name: "copy", Fingerprint: strings.Repeat("a", 64), CreatedAt: ts}
+	if err := st.CreateClientCertificate(duplicate); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateClientCertificate() duplicate error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+
+	got, err := st.GetClientCertificateByFingerprint(strings.Repeat("a", 64))
+	if err != nil || got.ID != "cert-1" || got.UserID != "user-1" || got.AgentID != "agent-1" {
+		t.Errorf("GetClientCertificateByFingerprint() = %+v, %v, want cert-1", got, err)
+	}
+
+	listed, err := st.ListClientCertificatesByUser("user-1")
+	if err != nil || len(listed) != 2 || listed[0].ID != "cert-1" || listed[1].ID != "cert-2" {
+		t.Errorf("ListClientCertificatesByUser() = %d certificates, %v, want cert-1 then cert-2", len(listed), err)
+	}
+
+	if err := st.DeleteClientCertificate("user-2", "cert-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteClientCertificate() other user error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.DeleteClientCertificate("user-1", "cert-1"); err != nil {
+		t.Fatalf("DeleteClientCertificate() error = %v", err)
+	}
+	if _, err := st.GetClientCertificateByFingerprint(strings.Repeat("a", 64)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetClientCertificateByFingerprint() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testEnrollmentTokens(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	tokens := []*models.EnrollmentToken{
+		{ID: "enroll-1", UserID: "user-1", Name: "fleet", TokenHash: strings.Repeat("a", 64), TokenPrefix: "kae_aaaa", ExpiresAt: ts.Add(time.Hour), CreatedAt: ts.Add(-time.Minute)},
+		{ID: "enroll-2", UserID: "user-1", Name: "runner", TokenHash: strings.Repeat("b", 64), TokenPrefix: "kae_bbbb", AgentID: "agent-1", ExpiresAt: ts.Add(time.Minute), CreatedAt: ts},
+	}
+	for _, token := range tokens {
+		if err := st.CreateEnrollmentToken(token); err != nil {
+			t.Fatalf("CreateEnrollmentToken(%s) error = %v", token.ID, err)
+		}
+	}
+
+	got, err := st.GetEnrollmentTokenByHash(strings.Repeat("b", 64))
+	if err != nil || got.ID != "enroll-2" || got.UserID != "user-1" || got.AgentID != "agent-1" || got.UsedAt != nil {
+		t.Errorf("GetEnrollmentTokenByHash() = %+v, %v, want unused enroll-2", got, err)
+	}
+
+	listed, err := st.ListEnrollmentTokensByUser("user-1")
+	if err != nil || len(listed) != 2 || listed[0].ID != "enroll-2" || listed[1].ID != "enroll-1" {
+		t.Errorf("ListEnrollmentTokensByUser() = %d tokens, %v, want enroll-2 then enroll-1", len(listed), err)
+	}
+
+	agentToken := func(id string, at time.Time) *models.APIKey {
+		return &models.APIKey{ID: id, UserID: "user-1", Name: "fleet", KeyHash: "hash-" + id, KeyPrefix: "ka_" + id, AgentID: "agent-1", CreatedAt: at}
+	}
+	if err := st.RedeemEnrollmentToken("enroll-2", agentToken("key01", ts.Add(2*time.Minute))); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("RedeemEnrollmentToken() expired error = %v, want %v", err, store.ErrConflict)
+	}
+	if err := st.RedeemEnrollmentToken("missing", agentToken("key02", ts)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RedeemEnrollmentToken() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.RedeemEnrollmentToken("enroll-1", agentToken("key03", ts)); err != nil {
+		t.Fatalf("RedeemEnrollmentToken() error = %v", err)
+	}
+	if err := st.RedeemEnrollmentToken("enroll-1", agentToken("key04", ts)); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("RedeemEnrollmentToken() reused error = %v, want %v", err, store.ErrConflict)
+	}
+	redeemed, err := st.GetEnrollmentTokenByHash(strings.Repeat("a", 64))
+	if err != nil || redeemed.UsedAt == nil || redeemed.UsedByAgentID != "agent-1" || redeemed.APIKeyID != "key03" {
+		t.Errorf("GetEnrollmentTokenByHash() after redeem = %+v, %v, want used by agent-1 with key03", redeemed, err)
+	}
+	key, err := st.GetAPIKeyByID("key03")
+	if err != nil || key.AgentID != "agent-1" || key.UserID != "user-1" {
+		t.Errorf("GetAPIKeyByID() = %+v, %v, want the agent token of agent-1", key, err)
+	}
+	for _, id := range []string{"key01", "key02", "key04"} {
+		if _, err := st.GetAPIKeyByID(id); !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("GetAPIKeyByID(%s) error = %v, want failed redemptions to create no key", id, err)
+		}
+	}
+
+	if err := st.DeleteEnrollmentToken("user-2", "enroll-2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteEnrollmentToken() other user error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.DeleteEnrollmentToken("user-1", "enroll-2"); err != nil {
+		t.Fatalf("DeleteEnrollmentToken() error = %v", err)
+	}
+	if _, err := st.GetEnrollmentTokenByHash(strings.Repeat("b", 64)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetEnrollmentTokenByHash() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testOrganizations(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	org := &models.Organization{ID: "org-1", Name: "Platform", CreatedBy: "user-1", CreatedAt: ts}
+	owner := &models.Membership{OrgID: "org-1", UserID: "user-1", Role: models.OrgRoleOwner, CreatedAt: ts}
+	if err := st.CreateOrganization(org, owner); err != nil {
+		t.Fatalf("CreateOrganization() error = %v", err)
+	}
+	if err := st.CreateOrganization(org, nil); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateOrganization() duplicate error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+	if got, err := st.GetOrganization("org-1"); err != nil || got.Name != "Platform" || got.CreatedBy != "user-1" {
+		t.Errorf("GetOrganization() = %+v, %v, want Platform created by user-1", got, err)
+	}
+	if orgs, err := st.ListOrganizations(); err != nil || len(orgs) != 1 {
+		t.Errorf("ListOrganizations() = %d organizations, %v, want 1", len(orgs), err)
+	}
+	if got, err := st.GetMembership("org-1", "user-1"); err != nil || got.Role != models.OrgRoleOwner {
+		t.Errorf("GetMembership() = %+v, %v, want the creator as owner", got, err)
+	}
+
+	// Memberships need an existing organization and are replaced in place
+	if err := st.SaveMembership(&models.Membership{OrgID: "missing", UserID: "user-2", Role: models.OrgRoleViewer, CreatedAt: ts}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("SaveMembership() missing organization error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.SaveMembership(&models.Membership{OrgID: "org-1", UserID: "user-2", Role: "admin", CreatedAt: ts}); !errors.Is(err, store.ErrInvalid) {
+		t.Errorf("SaveMembership() unknown role error = %v, want %v", err, store.ErrInvalid)
+	}
+	invitation := &models.Invitation{ID: "invite-1", OrgID: "org-1", Email: "bob@example.com", Role: models.OrgRoleViewer, TokenHash: strings.Repeat("c", 64),
+		TokenPrefix: "kai_cccc", InvitedBy: "user-1", CreatedAt: ts, ExpiresAt: ts.Add(time.Hour)}
+	if err := st.CreateInvitation(invitation); err != nil {
+		t.Fatalf("CreateInvitation() error = %v", err)
+	}
+	if got, err := st.GetInvitationByHash(strings.Repeat("c", 64)); err != nil || got.ID != "invite-1" || got.Email != "bob@example.com" {
+		t.Errorf("GetInvitationByHash() = %+v, %v, want invite-1", got, err)
+	}
+	if err := st.AcceptInvitation("invite-1", &models.Membership{OrgID: "org-1", UserID: "user-2", Role: models.OrgRoleViewer, CreatedAt: ts.Add(time.Second)}); err != nil {
+		t.Fatalf("AcceptInvitation() error = %v", err)
+	}
+	if err := st.AcceptInvitation("invite-1", &models.Membership{OrgID: "org-1", UserID: "user-2", Role: models.OrgRoleOwner, CreatedAt: ts}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("AcceptInvitation() reused error = %v, want %v", err, store.ErrNotFound)
+	}
+	if invitations, err := st.ListInvitations("org-1"); err != nil || len(invitations) != 0 {
+		t.Errorf("ListInvitations() = %d invitations, %v, want the accepted one gone", len(invitations), err)
+	}
+	if err := st.SaveMembership(&models.Membership{OrgID: "org-1", UserID: "user-2", Role: models.OrgRoleMember, CreatedAt: ts.Add(time.Second)}); err != nil {
+		t.Fatalf("SaveMembership() error = %v", err)
+	}
+	members, err := st.ListMemberships("org-1")
+	if err != nil || len(members) != 2 || members[0].UserID != "user-1" || members[1].UserID != "user-2" || members[1].Role != models.OrgRoleMember {
+		t.Errorf("ListMemberships() = %+v, %v, want user-1 then user-2 as member", members, err)
+	}
+	if mine, err := st.ListMembershipsByUser("user-2"); err != nil || len(mine) != 1 || mine[0].OrgID != "org-1" {
+		t.Errorf("ListMembershipsByUser() = %+v, %v, want org-1", mine, err)
+	}
+
+	// Shared agents are listed by organization
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	shared := mustCreateAgent(t, st, "agent-2", "user-1", ts.Add(time.Minute))
+	shared.OrgID = "org-1"
+	if err := st.CreateOrUpdateAgent(shared); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	if agents, err := st.ListAgentsByOrg("org-1"); err != nil || !reflect.DeepEqual(agentIDs(agents), []string{"agent-2"}) {
+		t.Errorf("ListAgentsByOrg() = %v, %v, want agent-2", agentIDs(agents), err)
+	}
+
+	if err := st.DeleteMembership("org-1", "user-2"); err != nil {
+		t.Fatalf("DeleteMembership() error = %v", err)
+	}
+	if err := st.DeleteMembership("org-1", "user-2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteMembership() again error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.CreateInvitation(&models.Invitation{ID: "invite-2", OrgID: "org-1", Email: "carol@example.com", Role: models.OrgRoleMember, TokenHash: strings.Repeat("d", 64),
+		TokenPrefix: "kai_dddd", InvitedBy: "user-1", CreatedAt: ts, ExpiresAt: ts.Add(time.Hour)}); err != nil {
+		t.Fatalf("CreateInvitation() error = %v", err)
+	}
+	if err := st.DeleteInvitation("org-2", "invite-2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteInvitation() of another organization error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	// Deleting the organization removes its memberships and invitations and unshares its agents
+	if err := st.DeleteOrganization("org-1"); err != nil {
+		t.Fatalf("DeleteOrganization() error = %v", err)
+	}
+	if _, err := st.GetOrganization("org-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetOrganization() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetMembership("org-1", "user-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetMembership() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetInvitationByHash(strings.Repeat("d", 64)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetInvitationByHash() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if got, err := st.GetAgent("agent-2"); err != nil || got.OrgID != "" {
+		t.Errorf("GetAgent() after delete = %+v, %v, want agent-2 unshared", got, err)
+	}
+}
+
+func testAgents(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	agent := mustCreateAgent(t, st, "agent-1", "user-1", ts.Add(-2*time.Minute))
+	if agent.Version != 1 {
+		t.Errorf("CreateOrUpdateAgent() version = %d, want 1", agent.Version)
+	}
+	mustCreateAgent(t, st, "agent-2", "user-1", ts.Add(-time.Minute))
+	mustCreateAgent(t, st, "agent-3", "user-2", ts)
+
+	got, err := st.GetAgent("agent-1")
+	if err != nil || got.UserID != "user-1" || got.Name != "Agent agent-1" || !got.LastSeen.Equal(agent.LastSeen) {
+		t.Errorf("GetAgent() = %+v, %v, want agent-1", got, err)
+	}
+	if _, err := st.GetAgent("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAgent() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	stale := *got
+	got.Name = "Renamed"
+	got.LastSeen = ts.Add(time.Minute)
+	got.HeartbeatSampleEvery = 10
+	got.Config = json.RawMessage(`{"log_level":"debug"}`)
+	got.ConfigVersion = 1
+	got.SetState(models.AgentStateStale, ts)
+	got.Kind = models.AgentKindCron
+	got.Cluster, got.Region = "prod-eu-1", "eu-west-1"
+	if err := st.CreateOrUpdateAgent(got); err != nil || got.Version != 2 {
+		t.Fatalf("CreateOrUpdateAgent() update = version %d, %v, want version 2", got.Version, err)
+	}
+	if err := st.CreateOrUpdateAgent(&stale); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("CreateOrUpdateAgent() stale version error = %v, want %v", err, store.ErrConflict)
+	}
+	if reread, err := st.GetAgent("agent-1"); err != nil || reread.Name != "Renamed" || reread.Kind != models.AgentKindCron || reread.Cluster != "prod-eu-1" || reread.Region != "eu-west-1" ||
+		reread.HeartbeatSampleEvery != 10 || reread.Version != 2 {
+		t.Errorf("GetAgent() after update = %+v, %v, want Renamed cron in prod-eu-1 sampling every 10 at version 2", reread, err)
+	} else if config := (models.AgentConfig{}); json.Unmarshal(reread.Config, &config) != nil || config.LogLevel != "debug" || reread.ConfigVersion != 1 {
+		t.Errorf("GetAgent() after update config = %s at version %d, want log level debug at version 1", reread.Config, reread.ConfigVersion)
+	} else if reread.State != models.AgentStateStale || reread.StateChangedAt == nil || !reread.StateChangedAt.Equal(ts) {
+		t.Errorf("GetAgent() after update state = %q changed at %v, want stale changed at %v", reread.State, reread.StateChangedAt, ts)
+	}
+
+	if ids := agentIDs(st.ListAgentsByUser("user-1")); !reflect.DeepEqual(ids, []string{"agent-1", "agent-2"}) {
+		t.Errorf("ListAgentsByUser() = %v, want [agent-1 agent-2]", ids)
+	}
+	if ids := agentIDs(st.ListAgents()); !reflect.DeepEqual(ids, []string{"agent-1", "agent-3", "agent-2"}) {
+		t.Errorf("ListAgents() = %v, want [agent-1 agent-3 agent-2]", ids)
+	}
+	if agents := st.ListAgentsByUser("missing"); len(agents) != 0 {
+		t.Errorf("ListAgentsByUser() missing user = %d agents, want 0", len(agents))
+	}
+	if counts, err := st.CountAgentsByUsers([]string{"user-1", "missing"}); err != nil || !reflect.DeepEqual(counts, map[string]int{"user-1": 2}) {
+		t.Errorf("CountAgentsByUsers() = %v, %v, want map[user-1:2]", counts, err)
+	}
+}
+
+func testAgentLifecycle(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateAgent(t, st, "agent-2", "user-1", ts)
+	mustCreateSession(t, st, "agent-1", "task-1", ts)
+	if err := st.AddStatus(&models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: ts}); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+	if err := st.SaveWatchItem(&models.WatchItem{UserID: "user-1", AgentID: "agent-1", CreatedAt: ts, UpdatedAt: ts}); err != nil {
+		t.Fatalf("SaveWatchItem() error = %v", err)
+	}
+
+	if err := st.DeleteAgent("missing", ts); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteAgent() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.RestoreAgent("agent-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RestoreAgent() live agent error = %v, want %v", err, store.ErrNotFound)
+	}
+	stale, _ := st.GetAgent("agent-1")
+	if err := st.DeleteAgent("agent-1", ts); err != nil {
+		t.Fatalf("DeleteAgent() error = %v", err)
+	}
+	if err := st.DeleteAgent("agent-1", ts); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteAgent() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	// A deleted agent is hidden and cannot be written back until restored
+	if _, err := st.GetAgent("agent-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAgent() deleted error = %v, want %v", err, store.ErrNotFound)
+	}
+	if ids := agentIDs(st.ListAgentsByUser("user-1")); !reflect.DeepEqual(ids, []string{"agent-2"}) {
+		t.Errorf("ListAgentsByUser() = %v, want [agent-2]", ids)
+	}
+	if counts, err := st.CountAgentsByUsers([]string{"user-1"}); err != nil || counts["user-1"] != 1 {
+		t.Errorf("CountAgentsByUsers() after delete = %v, %v, want map[user-1:1]", counts, err)
+	}
+	if ids := agentIDs(st.ListAgents()); !reflect.DeepEqual(ids, []string{"agent-2"}) {
+		t.Errorf("ListAgents() = %v, want [agent-2]", ids)
+	}
+	if _, total, err := st.QueryAgents(store.Query{UserID: "user-1"}); err != nil || total != 1 {
+		t.Errorf("QueryAgents() total = %d, %v, want 1", total, err)
+	}
+	if running, err := st.ListRunningSessions("user-1"); err != nil || len(running) != 0 {
+		t.Errorf("ListRunningSessions() = %d sessions, %v, want none of the deleted agent", len(running), err)
+	}
+	stale.Version = 0
+	if err := st.CreateOrUpdateAgent(stale); !errors.Is(err, store.ErrAgentDeleted) {
+		t.Errorf("CreateOrUpdateAgent() deleted agent error = %v, want %v", err, store.ErrAgentDeleted)
+	}
+	deleted, err := st.ListDeletedAgents("user-1")
+	if err != nil || len(deleted) != 1 || deleted[0].DeletedAt == nil || !deleted[0].DeletedAt.Equal(ts) {
+		t.Fatalf("ListDeletedAgents() = %v, %v, want agent-1 deleted at %v", agentIDs(deleted), err, ts)
+	}
+	if all, _ := st.ListDeletedAgents(""); len(all) != 1 {
+		t.Errorf("ListDeletedAgents(all users) = %v, want [agent-1]", agentIDs(all))
+	}
+
+	if err := st.RestoreAgent("agent-1"); err != nil {
+		t.Fatalf("RestoreAgent() error = %v", err)
+	}
+	if got, err := st.GetAgent("agent-1"); err != nil || got.DeletedAt != nil {
+		t.Errorf("GetAgent() restored = %+v, %v, want the live agent", got, err)
+	}
+	if history, err := st.GetStatusHistory("agent-1", "task-1"); err != nil || len(history) != 1 {
+		t.Errorf("GetStatusHistory() restored = %d statuses, %v, want the history kept", len(history), err)
+	}
+
+	// Only agents deleted before the cutoff are purged, with their sessions and watch items
+	mustDelete := func(agentID string, at time.Time) {
+		if err := st.DeleteAgent(agentID, at); err != nil {
+			t.Fatalf("DeleteAgent(%s) error = %v", agentID, err)
+		}
+	}
+	mustDelete("agent-1", ts.Add(-48*time.Hour))
+	mustDelete("agent-2", ts)
+	if purged, err := st.PurgeDeletedAgents(ts.Add(-24 * time.Hour)); err != nil || purged != 1 {
+		t.Errorf("PurgeDeletedAgents() = %d, %v, want 1", purged, err)
+	}
+	if deleted, _ := st.ListDeletedAgents(""); !reflect.DeepEqual(agentIDs(deleted), []string{"agent-2"}) {
+		t.Errorf("ListDeletedAgents() after purge = %v, want [agent-2]", agentIDs(deleted))
+	}
+	if _, err := st.GetSession("agent-1", "task-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSession() purged agent error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetWatchItem("user-1", "agent-1", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetWatchItem() purged agent error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.RestoreAgent("agent-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RestoreAgent() purged error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+// agentIDs returns agent IDs in list order
+func agentIDs(agents []*models.Agent) []string {
+	ids := make([]string, 0, len(agents))
+	for _, agent := range agents {
+		ids = append(ids, agent.AgentID)
+	}
+	return ids
+}
+
+func testSessions(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+
+	orphan := &models.Session{AgentID: "missing", SessionTopic: "task", Created: ts, LastUpdated: ts}
+	if err := st.CreateOrUpdateSession(orphan); err == nil {
+		t.Error("CreateOrUpdateSession() for a missing agent error = nil, want error")
+	}
+
+	session := mustCreateSession(t, st, "agent-1", "task-1", ts.Add(-time.Minute))
+	if session.Version != 1 {
+		t.Errorf("CreateOrUpdateSession() version = %d, want 1", session.Version)
+	}
+	mustCreateSession(t, st, "agent-1", "task-2", ts)
+
+	got, err := st.GetSession("agent-1", "task-1")
+	if err != nil || got.TTLMinutes != 30 || !got.LastUpdated.Equal(session.LastUpdated) {
+		t.Errorf("GetSession() = %+v, %v, want task-1", got, err)
+	}
+	if _, err := st.GetSession("agent-1", "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSession() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	stale := *got
+	expiredAt := ts
+	got.Expired = true
+	got.ExpiredAt = &expiredAt
+	got.Group = "nightly"
+	got.Category = "build"
+	got.Revision = 2
+	if err := st.CreateOrUpdateSession(got); err != nil || got.Version != 2 {
+		t.Fatalf("CreateOrUpdateSession() update = version %d, %v, want version 2", got.Version, err)
+	}
+	if err := st.CreateOrUpdateSession(&stale); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("CreateOrUpdateSession() stale version error = %v, want %v", err, store.ErrConflict)
+	}
+	reread, err := st.GetSession("agent-1", "task-1")
+	if err != nil || !reread.Expired || reread.ExpiredAt == nil || reread.Group != "nightly" || reread.Category != "build" || reread.Revision != 2 {
+		t.Errorf("GetSession() after update = %+v, %v, want expired nightly build session at revision 2", reread, err)
+	}
+
+	if topics := sessionTopics(st.ListSessions("agent-1", true)); !reflect.DeepEqual(topics, []string{"task-2", "task-1"}) {
+		t.Errorf("ListSessions() including expired = %v, want [task-2 task-1]", topics)
+	}
+	if topics := sessionTopics(st.ListSessions("agent-1", false)); !reflect.DeepEqual(topics, []string{"task-2"}) {
+		t.Errorf("ListSessions() active = %v, want [task-2]", topics)
+	}
+	if sessions := st.ListSessions("missing", true); len(sessions) != 0 {
+		t.Errorf("ListSessions() missing agent = %d sessions, want 0", len(sessions))
+	}
+}
+
+// sessionTopics returns session topics in list order
+func sessionTopics(sessions []*models.Session) []string {
+	topics := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		topics = append(topics, session.SessionTopic)
+	}
+	return topics
+}
+
+func testStatuses(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateSession(t, st, "agent-1", "task-1", ts)
+
+	orphan := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "missing", Status: "running", Timestamp: ts}
+	if err := st.AddStatus(orphan); err == nil {
+		t.Error("AddStatus() for a missing session error = nil, want error")
+	}
+	if _, err := st.GetLatestStatus("agent-1", "task-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetLatestStatus() without history error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	statuses := []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: ts.Add(-2 * time.Minute), Message: "started"},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "success", Timestamp: ts, Content: "done", ContentFormat: models.ContentFormatMarkdown, Metadata: json.RawMessage(`{"tokens":42}`), Revision: 2, Origin: models.StatusOriginServer},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: ts.Add(-time.Minute)},
+	}
+	for _, status := range statuses {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus(%s) error = %v", status.Status, err)
+		}
+	}
+
+	history, err := st.GetStatusHistory("agent-1", "task-1")
+	if err != nil {
+		t.Fatalf("GetStatusHistory() error = %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("GetStatusHistory() count = %d, want 3", len(history))
+	}
+	for i, want := range []time.Time{ts, ts.Add(-time.Minute), ts.Add(-2 * time.Minute)} {
+		if !history[i].Timestamp.Equal(want) {
+			t.Errorf("GetStatusHistory()[%d] timestamp = %v, want %v", i, history[i].Timestamp, want)
+		}
+	}
+	if history[0].Revision != 2 {
+		t.Errorf("GetStatusHistory() newest revision = %d, want 2", history[0].Revision)
+	}
+	if history[2].Message != "started" || history[2].Origin != models.StatusOriginAgent {
+		t.Errorf("GetStatusHistory() oldest = message %q, origin %q, want started by the agent", history[2].Message, history[2].Origin)
+	}
+
+	if statuses[0].ID == 0 || statuses[0].ID == statuses[1].ID || history[2].ID != statuses[0].ID {
+		t.Errorf("AddStatus() IDs = %d, %d, history oldest ID = %d, want distinct IDs read back", statuses[0].ID, statuses[1].ID, history[2].ID)
+	}
+
+	latest, err := st.GetLatestStatus("agent-1", "task-1")
+	if err != nil || latest.Status != "success" || latest.Content != "done" || latest.ContentFormat != models.ContentFormatMarkdown || latest.Revision != 2 || latest.ID != statuses[1].ID || !latest.FromServer() {
+		t.Fatalf("GetLatestStatus() = %+v, %v, want success at revision 2 recorded by the server", latest, err)
+	}
+	var metadata map[string]int
+	if err := json.Unmarshal(latest.Metadata, &metadata); err != nil || metadata["tokens"] != 42 {
+		t.Errorf("GetLatestStatus() metadata = %s, want tokens 42", latest.Metadata)
+	}
+
+	// The progress of a run leaves out other revisions and the statuses the server recorded
+	progress, runningSince, err := st.GetRunProgress("agent-1", "task-1", 0)
+	if err != nil || progress == nil || progress.ID != statuses[2].ID || !runningSince.Equal(ts.Add(-2*time.Minute)) {
+		t.Errorf("GetRunProgress() = %+v, %v, %v, want the second running status, running since the first", progress, runningSince, err)
+	}
+	if progress, runningSince, err := st.GetRunProgress("agent-1", "task-1", 2); err != nil || progress != nil || !runningSince.IsZero() {
+		t.Errorf("GetRunProgress() of a run with server statuses only = %+v, %v, %v, want none", progress, runningSince, err)
+	}
+	if progress, _, err := st.GetRunProgress("agent-1", "missing", 0); err != nil || progress != nil {
+		t.Errorf("GetRunProgress() missing session = %+v, %v, want none", progress, err)
+	}
+
+	if history, err := st.GetStatusHistory("agent-1", "missing"); err != nil || len(history) != 0 {
+		t.Errorf("GetStatusHistory() missing session = %d records, %v, want none", len(history), err)
+	}
+
+	// Statuses of a period, oldest first; the end of the period is excluded
+	between, err := st.ListStatusesBetween(ts.Add(-2*time.Minute), ts, 0)
+	if err != nil || len(between) != 2 || between[0].Message != "started" || !between[1].Timestamp.Equal(ts.Add(-time.Minute)) {
+		t.Errorf("ListStatusesBetween() = %+v, %v, want the two running statuses oldest first", between, err)
+	}
+	if between, err := st.ListStatusesBetween(ts.Add(-time.Hour), ts.Add(time.Hour), 1); err != nil || len(between) != 1 || between[0].ID != statuses[0].ID {
+		t.Errorf("ListStatusesBetween() with limit 1 = %+v, %v, want the oldest status", between, err)
+	}
+}
+
+func testPages(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	for i, id := range []string{"agent-1", "agent-2", "agent-3"} {
+		mustCreateAgent(t, st, id, "user-1", ts.Add(-time.Duration(i)*time.Minute))
+	}
+	for i, topic := range []string{"task-1", "task-2", "task-3"} {
+		mustCreateSession(t, st, "agent-1", topic, ts.Add(-time.Duration(i)*time.Minute))
+	}
+	for i := 0; i < 3; i++ {
+		status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: ts.Add(-time.Duration(i) * time.Minute), Message: fmt.Sprintf("step %d", i)}
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus(%d) error = %v", i, err)
+		}
+	}
+
+	agents, total, err := st.QueryAgents(store.Query{UserID: "user-1", Page: store.Page{Offset: 1, Limit: 1}})
+	if ids := agentIDs(agents); err != nil || total != 3 || !reflect.DeepEqual(ids, []string{"agent-2"}) {
+		t.Errorf("QueryAgents() = %v, %d, %v, want [agent-2] of 3", ids, total, err)
+	}
+	agents, total, err = st.QueryAgents(store.Query{UserID: "user-1", Page: store.Page{Offset: 2}})
+	if ids := agentIDs(agents); err != nil || total != 3 || !reflect.DeepEqual(ids, []string{"agent-3"}) {
+		t.Errorf("QueryAgents() without a limit = %v, %d, %v, want [agent-3] of 3", ids, total, err)
+	}
+
+	sessions, total, err := st.QuerySessions(store.Query{AgentID: "agent-1", Page: store.Page{Limit: 2}})
+	if topics := sessionTopics(sessions); err != nil || total != 3 || !reflect.DeepEqual(topics, []string{"task-1", "task-2"}) {
+		t.Errorf("QuerySessions() = %v, %d, %v, want [task-1 task-2] of 3", topics, total, err)
+	}
+	sessions, total, err = st.QuerySessions(store.Query{AgentID: "agent-1", Page: store.Page{Offset: 5, Limit: 2}})
+	if err != nil || total != 3 || len(sessions) != 0 {
+		t.Errorf("QuerySessions() past the end = %d sessions, %d, %v, want none of 3", len(sessions), total, err)
+	}
+
+	history, total, err := st.GetStatusHistoryPage("agent-1", "task-1", store.Page{Offset: 1, Limit: 5})
+	if err != nil || total != 3 || len(history) != 2 || history[0].Message != "step 1" || history[1].Message != "step 2" {
+		t.Errorf("GetStatusHistoryPage() = %d records, %d, %v, want steps 1 and 2 of 3", len(history), total, err)
+	}
+	if history, total, err := st.GetStatusHistoryPage("agent-1", "missing", store.Page{Limit: 5}); err != nil || total != 0 || len(history) != 0 {
+		t.Errorf("GetStatusHistoryPage() missing session = %d records, %d, %v, want none", len(history), total, err)
+	}
+}
+
+func testQueries(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	builder := mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	builder.Name, builder.Kind, builder.Cluster, builder.State = "Nightly Builder", models.AgentKindCI, "prod", models.AgentStateOnline
+	if err := st.CreateOrUpdateAgent(builder); err != nil {
+		t.Fatalf("CreateOrUpdateAgent() error = %v", err)
+	}
+	mustCreateAgent(t, st, "agent-2", "user-1", ts.Add(-time.Hour))
+	mustCreateAgent(t, st, "agent-3", "user-1", ts.Add(-2*time.Hour))
+
+	mustCreateSession(t, st, "agent-1", "deploy-api", ts)
+	mustCreateSession(t, st, "agent-1", "deploy-web", ts.Add(-time.Minute))
+	backup := mustCreateSession(t, st, "agent-1", "backup", ts.Add(-time.Hour))
+	backup.Expired, backup.Group = true, "maintenance"
+	if err := st.CreateOrUpdateSession(backup); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v", err)
+	}
+	mustCreateSession(t, st, "agent-2", "sync", ts)
+	for _, status := range []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "deploy-api", Status: "running", Timestamp: ts.Add(-time.Minute)},
+		{AgentID: "agent-1", SessionTopic: "deploy-api", Status: "failed", Timestamp: ts},
+		{AgentID: "agent-1", SessionTopic: "deploy-web", Status: "running", Timestamp: ts.Add(-30 * time.Second)},
+		{AgentID: "agent-1", SessionTopic: "backup", Status: "running", Timestamp: ts.Add(time.Minute)},
+		{AgentID: "agent-2", SessionTopic: "sync", Status: "running", Timestamp: ts},
+	} {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus(%s) error = %v", status.SessionTopic, err)
+		}
+	}
+
+	agentTests := []struct {
+		name  string
+		query store.Query
+		want  []string
+	}{
+		{"search name", store.Query{UserID: "user-1", Search: "builder"}, []string{"agent-1"}},
+		{"search ID", store.Query{UserID: "user-1", Search: "AGENT-"}, []string{"agent-1", "agent-2", "agent-3"}},
+		{"kind and cluster", store.Query{UserID: "user-1", Kind: models.AgentKindCI, Cluster: "prod"}, []string{"agent-1"}},
+		{"state", store.Query{UserID: "user-1", State: models.AgentStateOnline}, []string{"agent-1"}},
+		// The backup session's later running status is left out, as it expired
+		{"latest status", store.Query{UserID: "user-1", Status: "failed"}, []string{"agent-1"}},
+		{"running", store.Query{UserID: "user-1", Status: "running"}, []string{"agent-2"}},
+		{"window", store.Query{UserID: "user-1", Since: ts.Add(-90 * time.Minute), Until: ts}, []string{"agent-2"}},
+		{"page of matches", store.Query{UserID: "user-1", Since: ts.Add(-3 * time.Hour), Page: store.Page{Offset: 1, Limit: 1}}, []string{"agent-2"}},
+		{"other user", store.Query{UserID: "user-2"}, nil},
+	}
+	for _, tt := range agentTests {
+		agents, total, err := st.QueryAgents(tt.query)
+		if ids := agentIDs(agents); err != nil || len(ids) != len(tt.want) || len(tt.want) > 0 && !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("QueryAgents(%s) = %v, %v, want %v", tt.name, ids, err, tt.want)
+		}
+		if tt.query.Page.Limit == 0 && total != len(tt.want) {
+			t.Errorf("QueryAgents(%s) total = %d, want %d", tt.name, total, len(tt.want))
+		}
+	}
+
+	sessionTests := []struct {
+		name  string
+		query store.Query
+		want  []string
+	}{
+		{"all", store.Query{AgentID: "agent-1"}, []string{"deploy-api", "deploy-web", "backup"}},
+		{"unexpired", store.Query{AgentID: "agent-1", ExcludeExpired: true}, []string{"deploy-api", "deploy-web"}},
+		{"search", store.Query{AgentID: "agent-1", Search: "DEPLOY"}, []string{"deploy-api", "deploy-web"}},
+		{"latest status", store.Query{AgentID: "agent-1", Status: "running"}, []string{"deploy-web", "backup"}},
+		{"group", store.Query{AgentID: "agent-1", Group: "maintenance"}, []string{"backup"}},
+		{"window", store.Query{AgentID: "agent-1", Until: ts}, []string{"deploy-web", "backup"}},
+	}
+	for _, tt := range sessionTests {
+		sessions, total, err := st.QuerySessions(tt.query)
+		if topics := sessionTopics(sessions); err != nil || total != len(tt.want) || !reflect.DeepEqual(topics, tt.want) {
+			t.Errorf("QuerySessions(%s) = %v, %d, %v, want %v", tt.name, topics, total, err, tt.want)
+		}
+	}
+
+	for name, query := range map[string]store.Query{
+		"agents without owner":     {},
+		"agents of user and org":   {UserID: "user-1", OrgID: "org-1"},
+		"agents by session group":  {UserID: "user-1", Group: "maintenance"},
+		"agents by unknown state":  {UserID: "user-1", State: "asleep"},
+		"agents by unknown status": {UserID: "user-1", Status: "done"},
+		"agents in empty window":   {UserID: "user-1", Since: ts, Until: ts},
+	} {
+		if _, _, err := st.QueryAgents(query); !errors.Is(err, store.ErrInvalid) {
+			t.Errorf("QueryAgents(%s) error = %v, want %v", name, err, store.ErrInvalid)
+		}
+	}
+	if _, _, err := st.QuerySessions(store.Query{UserID: "user-1"}); !errors.Is(err, store.ErrInvalid) {
+		t.Errorf("QuerySessions() without agent error = %v, want %v", err, store.ErrInvalid)
+	}
+}
+
+func testStatusPruning(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateSession(t, st, "agent-1", "task-1", ts)
+	mustCreateSession(t, st, "agent-1", "task-2", ts)
+
+	// task-1 ran for three days; task-2 finished long ago and has only its old statuses
+	var first *models.AgentStatus
+	for _, status := range []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: ts.Add(-72 * time.Hour)},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: ts.Add(-48 * time.Hour)},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "success", Timestamp: ts},
+		{AgentID: "agent-1", SessionTopic: "task-2", Status: "running", Timestamp: ts.Add(-96 * time.Hour)},
+		{AgentID: "agent-1", SessionTopic: "task-2", Status: "failed", Timestamp: ts.Add(-95 * time.Hour)},
+	} {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus() error = %v", err)
+		}
+		if first == nil {
+			first = status
+		}
+	}
+	annotation := &models.StatusAnnotation{ID: "annotation-1", StatusID: first.ID, AgentID: "agent-1", SessionTopic: "task-1",
+		UserID: "user-1", Note: "slow start", CreatedAt: ts}
+	if err := st.CreateStatusAnnotation(annotation); err != nil {
+		t.Fatalf("CreateStatusAnnotation() error = %v", err)
+	}
+
+	// Oldest first: task-2's running status goes before task-1's
+	if pruned, err := st.PruneStatuses(ts.Add(-24*time.Hour), 1); err != nil || pruned != 1 {
+		t.Fatalf("PruneStatuses() limit 1 = %d, %v, want 1", pruned, err)
+	}
+	if history, _ := st.GetStatusHistory("agent-1", "task-2"); len(history) != 1 || history[0].Status != "failed" {
+		t.Errorf("GetStatusHistory(task-2) after pruning = %d statuses, want only the latest failed one", len(history))
+	}
+
+	if pruned, err := st.PruneStatuses(ts.Add(-24*time.Hour), 0); err != nil || pruned != 2 {
+		t.Fatalf("PruneStatuses() = %d, %v, want 2", pruned, err)
+	}
+	if history, _ := st.GetStatusHistory("agent-1", "task-1"); len(history) != 1 || history[0].Status != "success" {
+		t.Errorf("GetStatusHistory(task-1) after pruning = %d statuses, want only the latest success", len(history))
+	}
+	if history, _ := st.GetStatusHistory("agent-1", "task-2"); len(history) != 1 {
+		t.Errorf("GetStatusHistory(task-2) after pruning = %d statuses, want the latest kept", len(history))
+	}
+	if annotations, err := st.ListStatusAnnotations("agent-1", "task-1"); err != nil || len(annotations) != 0 {
+		t.Errorf("ListStatusAnnotations() after pruning = %d, %v, want the pruned status's annotation removed", len(annotations), err)
+	}
+	if pruned, err := st.PruneStatuses(ts.Add(-24*time.Hour), 0); err != nil || pruned != 0 {
+		t.Errorf("PruneStatuses() again = %d, %v, want 0", pruned, err)
+	}
+}
+
+func testStatusAnnotations(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateSession(t, st, "agent-1", "task-1", ts)
+	mustCreateSession(t, st, "agent-1", "task-2", ts)
+
+	status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "failed", Timestamp: ts}
+	if err := st.AddStatus(status); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+
+	annotations := []*models.StatusAnnotation{
+		{ID: "annotation-2", StatusID: status.ID, AgentID: "agent-1", SessionTopic: "task-1", UserID: "user-1",
+			Note: "retried manually", CreatedAt: ts},
+		{ID: "annotation-1", StatusID: status.ID, AgentID: "agent-1", SessionTopic: "task-1", UserID: "user-1",
+			Investigator: "alice", RootCause: "expired token", Links: []string{"https://example.com/incident/1"}, CreatedAt: ts.Add(-time.Minute)},
+	}
+	for _, annotation := range annotations {
+		if err := st.CreateStatusAnnotation(annotation); err != nil {
+			t.Fatalf("CreateStatusAnnotation(%s) error = %v", annotation.ID, err)
+		}
+	}
+
+	// The status must belong to the annotation's session
+	moved := *annotations[0]
+	moved.ID, moved.SessionTopic = "annotation-3", "task-2"
+	if err := st.CreateStatusAnnotation(&moved); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("CreateStatusAnnotation() for another session's status error = %v, want %v", err, store.ErrNotFound)
+	}
+	missing := *annotations[0]
+	missing.ID, missing.StatusID = "annotation-4", status.ID+1000
+	if err := st.CreateStatusAnnotation(&missing); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("CreateStatusAnnotation() for a missing status error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	got, err := st.ListStatusAnnotations("agent-1", "task-1")
+	if err != nil || len(got) != 2 {
+		t.Fatalf("ListStatusAnnotations() = %d annotations, %v, want 2", len(got), err)
+	}
+	if got[0].ID != "annotation-1" || got[0].RootCause != "expired token" || !reflect.DeepEqual(got[0].Links, []string{"https://example.com/incident/1"}) {
+		t.Errorf("ListStatusAnnotations()[0] = %+v, want annotation-1 with its root cause and link", got[0])
+	}
+	if got[1].ID != "annotation-2" || got[1].StatusID != status.ID || got[1].UserID != "user-1" {
+		t.Errorf("ListStatusAnnotations()[1] = %+v, want annotation-2", got[1])
+	}
+	if got, err := st.ListStatusAnnotations("agent-1", "task-2"); err != nil || len(got) != 0 {
+		t.Errorf("ListStatusAnnotations() other session = %d annotations, %v, want none", len(got), err)
+	}
+}
+
+func testRunningSessions(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateAgent(t, st, "agent-2", "user-1", ts)
+	mustCreateAgent(t, st, "agent-3", "user-2", ts)
+
+	mustCreateSession(t, st, "agent-1", "recent", ts)
+	mustCreateSession(t, st, "agent-2", "long", ts)
+	mustCreateSession(t, st, "agent-1", "finished", ts)
+	mustCreateSession(t, st, "agent-3", "other-user", ts)
+	expired := mustCreateSession(t, st, "agent-1", "expired", ts)
+	expired.Expired = true
+	if err := st.CreateOrUpdateSession(expired); err != nil {
+		t.Fatalf("CreateOrUpdateSession(expired) error = %v", err)
+	}
+	rerun := mustCreateSession(t, st, "agent-2", "rerun", ts)
+	rerun.Revision = 2
+	if err := st.CreateOrUpdateSession(rerun); err != nil {
+		t.Fatalf("CreateOrUpdateSession(rerun) error = %v", err)
+	}
+
+	statuses := []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "recent", Status: "running", Timestamp: ts.Add(-time.Minute)},
+		{AgentID: "agent-1", SessionTopic: "recent", Status: "running", Timestamp: ts, Message: "halfway", Metadata: json.RawMessage(`{"progress":50}`)},
+		{AgentID: "agent-2", SessionTopic: "long", Status: "running", Timestamp: ts.Add(-time.Hour)},
+		{AgentID: "agent-1", SessionTopic: "finished", Status: "running", Timestamp: ts.Add(-2 * time.Hour)},
+		{AgentID: "agent-1", SessionTopic: "finished", Status: "success", Timestamp: ts},
+		{AgentID: "agent-3", SessionTopic: "other-user", Status: "running", Timestamp: ts},
+		{AgentID: "agent-1", SessionTopic: "expired", Status: "running", Timestamp: ts},
+		{AgentID: "agent-2", SessionTopic: "rerun", Status: "running", Timestamp: ts.Add(-3 * time.Hour)},
+		{AgentID: "agent-2", SessionTopic: "rerun", Status: "success", Timestamp: ts.Add(-2 * time.Hour)},
+		{AgentID: "agent-2", SessionTopic: "rerun", Status: "running", Timestamp: ts.Add(-30 * time.Minute), Revision: 2},
+	}
+	for _, status := range statuses {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus(%s, %s) error = %v", status.SessionTopic, status.Status, err)
+		}
+	}
+
+	running, err := st.ListRunningSessions("user-1")
+	if err != nil {
+		t.Fatalf("ListRunningSessions() error = %v", err)
+	}
+	var topics []string
+	for _, r := range running {
+		topics = append(topics, r.Session.SessionTopic)
+	}
+	if want := []string{"long", "rerun", "recent"}; !reflect.DeepEqual(topics, want) {
+		t.Fatalf("ListRunningSessions() = %v, want %v", topics, want)
+	}
+
+	// A re-run is timed from the first status of its current revision
+	if !running[1].Started.Equal(ts.Add(-30*time.Minute)) || running[1].Session.Revision != 2 {
+		t.Errorf("ListRunningSessions() rerun started = %v at revision %d, want the start of revision 2", running[1].Started, running[1].Session.Revision)
+	}
+
+	recent := running[2]
+	if recent.AgentName != "Agent agent-1" || !recent.Started.Equal(ts.Add(-time.Minute)) {
+		t.Errorf("ListRunningSessions() recent = %+v, want agent name and first status time", recent)
+	}
+	if recent.Latest.Message != "halfway" || !recent.Latest.Timestamp.Equal(ts) || recent.Latest.AgentID != "agent-1" {
+		t.Errorf("ListRunningSessions() recent latest = %+v, want the halfway status", recent.Latest)
+	}
+	var metadata map[string]int
+	if err := json.Unmarshal(recent.Latest.Metadata, &metadata); err != nil || metadata["progress"] != 50 {
+		t.Errorf("ListRunningSessions() recent metadata = %s, want progress 50", recent.Latest.Metadata)
+	}
+
+	if none, err := st.ListRunningSessions("user-404"); err != nil || len(none) != 0 {
+		t.Errorf("ListRunningSessions() unknown user = %v, %v, want none", none, err)
+	}
+}
+
+func testOverview(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+	ts := now()
+	for id, state := range map[string]string{"agent-1": models.AgentStateOnline, "agent-2": models.AgentStateOffline, "agent-3": ""} {
+		agent := mustCreateAgent(t, st, id, "user-1", ts)
+		agent.State = state
+		if err := st.CreateOrUpdateAgent(agent); err != nil {
+			t.Fatalf("CreateOrUpdateAgent(%s) error = %v", id, err)
+		}
+	}
+	mustCreateAgent(t, st, "deleted", "user-1", ts)
+	mustCreateAgent(t, st, "other", "user-2", ts)
+
+	mustCreateSession(t, st, "agent-1", "task-1", ts)
+	mustCreateSession(t, st, "agent-2", "task-2", ts)
+	mustCreateSession(t, st, "deleted", "task-3", ts)
+	mustCreateSession(t, st, "other", "task-4", ts)
+	expired := mustCreateSession(t, st, "agent-1", "expired", ts)
+	expired.Expired = true
+	if err := st.CreateOrUpdateSession(expired); err != nil {
+		t.Fatalf("CreateOrUpdateSession(expired) error = %v", err)
+	}
+
+	statuses := []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "expired", Status: "failed", Timestamp: ts.Add(-2 * time.Hour), Message: "too old"},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "failed", Timestamp: ts.Add(-time.Minute), Message: "first"},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "success", Timestamp: ts},
+		{AgentID: "agent-2", SessionTopic: "task-2", Status: "running", Timestamp: ts.Add(-time.Minute)},
+		{AgentID: "agent-2", SessionTopic: "task-2", Status: "failed", Timestamp: ts, Message: "second"},
+		{AgentID: "deleted", SessionTopic: "task-3", Status: "failed", Timestamp: ts},
+		{AgentID: "other", SessionTopic: "task-4", Status: "failed", Timestamp: ts},
+	}
+	for _, status := range statuses {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus(%s, %s) error = %v", status.SessionTopic, status.Status, err)
+		}
+	}
+	if err := st.DeleteAgent("deleted", ts); err != nil {
+		t.Fatalf("DeleteAgent() error = %v", err)
+	}
+
+	since := ts.Add(-time.Hour)
+	overview, err := st.GetOverview("user-1", since, 0)
+	if err != nil {
+		t.Fatalf("GetOverview() error = %v", err)
+	}
+	if want := (models.OverviewAgentCounts{Total: 3, Online: 1, Offline: 1}); overview.Agents != want {
+		t.Errorf("GetOverview() agents = %+v, want %+v", overview.Agents, want)
+	}
+	if overview.ActiveSessions != 2 || overview.Succeeded != 1 || overview.Failed != 2 || !overview.Since.Equal(since) {
+		t.Errorf("GetOverview() = %d active sessions, %d succeeded, %d failed since %v, want 2, 1 and 2 since %v",
+			overview.ActiveSessions, overview.Succeeded, overview.Failed, overview.Since, since)
+	}
+	var messages []string
+	for _, failure := range overview.RecentFailures {
+		messages = append(messages, failure.Status.Message)
+	}
+	if want := []string{"second", "first"}; !reflect.DeepEqual(messages, want) {
+		t.Fatalf("GetOverview() recent failures = %v, want %v", messages, want)
+	}
+	if failure := overview.RecentFailures[0]; failure.AgentName != "Agent agent-2" || failure.Status.SessionTopic != "task-2" || failure.Status.ID == 0 {
+		t.Errorf("GetOverview() recent failure = %+v %+v, want agent-2's", failure, failure.Status)
+	}
+
+	if limited, err := st.GetOverview("user-1", since, 1); err != nil || len(limited.RecentFailures) != 1 || limited.Failed != 2 {
+		t.Errorf("GetOverview() limited = %+v, %v, want one recent failure of two", limited, err)
+	}
+	if none, err := st.GetOverview("user-404", since, 10); err != nil || none.Agents.Total != 0 || len(none.RecentFailures) != 0 {
+		t.Errorf("GetOverview() unknown user = %+v, %v, want nothing", none, err)
+	}
+}
+
+func testOutbox(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateSession(t, st, "agent-1", "task-1", ts)
+
+	status := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "task-1", Status: "failed", Timestamp: ts}
+	messages := []*models.OutboxMessage{
+		{Kind: models.OutboxKindNotification, Payload: json.RawMessage(`{"n":1}`), CreatedAt: ts},
+		{Kind: models.OutboxKindInbox, Payload: json.RawMessage(`{"n":2}`), CreatedAt: ts},
+		{Kind: models.OutboxKindInbox, Payload: json.RawMessage(`{"n":3}`), CreatedAt: ts, AvailableAt: ts.Add(time.Hour)},
+	}
+	if err := st.AddStatusWithOutbox(status, messages); err != nil {
+		t.Fatalf("AddStatusWithOutbox() error = %v", err)
+	}
+	if messages[0].ID == 0 || messages[1].ID <= messages[0].ID {
+		t.Errorf("AddStatusWithOutbox() ids = %d, %d, want increasing ids", messages[0].ID, messages[1].ID)
+	}
+	if latest, err := st.GetLatestStatus("agent-1", "task-1"); err != nil || latest.Status != "failed" {
+		t.Errorf("GetLatestStatus() = %+v, %v, want the failed status", latest, err)
+	}
+
+	// A status for a missing session records nothing
+	orphan := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "missing", Status: "failed", Timestamp: ts}
+	extra := []*models.OutboxMessage{{Kind: models.OutboxKindInbox, Payload: json.RawMessage(`{"n":4}`), CreatedAt: ts}}
+	if err := st.AddStatusWithOutbox(orphan, extra); err == nil {
+		t.Error("AddStatusWithOutbox() for a missing session error = nil, want an error")
+	}
+
+	claimed, err := st.ClaimOutboxMessages(ts, time.Minute, 1)
+	if err != nil {
+		t.Fatalf("ClaimOutboxMessages() error = %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != messages[0].ID || claimed[0].Attempts != 1 ||
+		string(claimed[0].Payload) != `{"n": 1}` && string(claimed[0].Payload) != `{"n":1}` {
+		t.Fatalf("ClaimOutboxMessages(limit 1) = %+v, want the oldest message on its first attempt", claimed)
+	}
+
+	// Claimed messages are hidden until their lease ends; delayed ones until they are available
+	claimed, err = st.ClaimOutboxMessages(ts, time.Minute, 10)
+	if err != nil {
+		t.Fatalf("ClaimOutboxMessages() error = %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != messages[1].ID {
+		t.Fatalf("ClaimOutboxMessages() = %+v, want only the unclaimed available message", claimed)
+	}
+
+	if err := st.RetryOutboxMessage(messages[1].ID, ts, "receiver down"); err != nil {
+		t.Fatalf("RetryOutboxMessage() error = %v", err)
+	}
+	if err := st.DeleteOutboxMessage(messages[0].ID); err != nil {
+		t.Fatalf("DeleteOutboxMessage() error = %v", err)
+	}
+	if err := st.DeleteOutboxMessage(messages[0].ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteOutboxMessage() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.RetryOutboxMessage(messages[0].ID, ts, ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("RetryOutboxMessage() deleted message error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	claimed, err = st.ClaimOutboxMessages(ts.Add(2*time.Hour), time.Minute, 10)
+	if err != nil {
+		t.Fatalf("ClaimOutboxMessages() error = %v", err)
+	}
+	if len(claimed) != 2 || claimed[0].ID != messages[1].ID || claimed[1].ID != messages[2].ID {
+		t.Fatalf("ClaimOutboxMessages() later = %+v, want the retried and delayed messages", claimed)
+	}
+	if claimed[0].Attempts != 2 || claimed[0].LastError != "receiver down" {
+		t.Errorf("ClaimOutboxMessages() retried message = %+v, want attempt 2 with the last error", claimed[0])
+	}
+
+	// Messages may be recorded without a status
+	queued := &models.OutboxMessage{Kind: models.OutboxKindDelivery, Payload: json.RawMessage(`{"n":5}`), CreatedAt: ts}
+	if err := st.AddOutboxMessage(queued); err != nil || queued.ID <= messages[2].ID {
+		t.Fatalf("AddOutboxMessage() = id %d, %v, want the next id", queued.ID, err)
+	}
+	if err := st.AddOutboxMessage(&models.OutboxMessage{Kind: "carrier_pigeon", Payload: json.RawMessage(`{}`), CreatedAt: ts}); !errors.Is(err, store.ErrInvalid) {
+		t.Errorf("AddOutboxMessage() unknown kind error = %v, want %v", err, store.ErrInvalid)
+	}
+	claimed, err = st.ClaimOutboxMessages(ts, time.Minute, 10)
+	if err != nil {
+		t.Fatalf("ClaimOutboxMessages() error = %v", err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != queued.ID || claimed[0].Kind != models.OutboxKindDelivery {
+		t.Errorf("ClaimOutboxMessages() = %+v, want the queued message", claimed)
+	}
+}
+
+func testExpiredSessions(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateSession(t, st, "agent-1", "stale", ts.Add(-2*time.Hour))
+	mustCreateSession(t, st, "agent-1", "fresh", ts)
+	finished := mustCreateSession(t, st, "agent-1", "finished", ts.Add(-2*time.Hour))
+	finished.EndReason = models.EndReasonAgentReported
+	if err := st.CreateOrUpdateSession(finished); err != nil {
+		t.Fatalf("CreateOrUpdateSession() error = %v", err)
+	}
+
+	expired := st.CheckExpiredSessions()
+	sort.Slice(expired, func(i, j int) bool { return expired[i].SessionTopic < expired[j].SessionTopic })
+	if len(expired) != 2 || expired[1].SessionTopic != "stale" || !expired[1].Expired || expired[1].ExpiredAt == nil {
+		t.Fatalf("CheckExpiredSessions() = %+v, want the stale and finished sessions marked expired", expired)
+	}
+	// Expiry records a timeout only for runs without a final status
+	if expired[1].EndReason != models.EndReasonTTLExpired || expired[0].EndReason != models.EndReasonAgentReported {
+		t.Errorf("CheckExpiredSessions() end reasons = %q, %q, want %q and the kept %q",
+			expired[1].EndReason, expired[0].EndReason, models.EndReasonTTLExpired, models.EndReasonAgentReported)
+	}
+	if again := st.CheckExpiredSessions(); len(again) != 0 {
+		t.Errorf("CheckExpiredSessions() second run = %d sessions, want 0", len(again))
+	}
+
+	session, err := st.GetSession("agent-1", "stale")
+	if err != nil || !session.Expired || session.Version != 2 {
+		t.Errorf("GetSession() after expiry = %+v, %v, want expired at version 2", session, err)
+	}
+	if fresh, err := st.GetSession("agent-1", "fresh"); err != nil || fresh.Expired {
+		t.Errorf("GetSession() fresh = %+v, %v, want active", fresh, err)
+	}
+}
+
+// clockSetter is implemented by stores whose current time can be replaced
+type clockSetter interface {
+	SetClock(c clock.Clock)
+}
+
+func testClock(t *testing.T, st store.Store) {
+	setter, ok := st.(clockSetter)
+	if !ok {
+		t.Skip("store has no replaceable clock")
+	}
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateSession(t, st, "agent-1", "task-1", ts)
+	if err := st.SaveNonce("key:1", "nonce-1", ts.Add(time.Hour)); err != nil {
+		t.Fatalf("SaveNonce() error = %v", err)
+	}
+
+	fake := clock.NewFake(ts.Add(29 * time.Minute))
+	setter.SetClock(fake)
+	if expired := st.CheckExpiredSessions(); len(expired) != 0 {
+		t.Errorf("CheckExpiredSessions() before the TTL = %d sessions, want 0", len(expired))
+	}
+
+	fake.Advance(2 * time.Minute)
+	expired := st.CheckExpiredSessions()
+	if len(expired) != 1 || expired[0].ExpiredAt == nil || !expired[0].ExpiredAt.Equal(fake.Now()) {
+		t.Fatalf("CheckExpiredSessions() after the TTL = %+v, want the session expired at the clock's time", expired)
+	}
+
+	if err := st.SaveNonce("key:1", "nonce-1", ts.Add(3*time.Hour)); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("SaveNonce() replay error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+	fake.Set(ts.Add(2 * time.Hour))
+	if purged, err := st.PurgeExpiredNonces(); err != nil || purged != 1 {
+		t.Errorf("PurgeExpiredNonces() = %d, %v, want the nonce expired by the clock", purged, err)
+	}
+}
+
+func testIntegrity(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	mustCreateAgent(t, st, "agent-1", "user-1", ts)
+	mustCreateAgent(t, st, "legacy", "", ts)
+	mustCreateSession(t, st, "agent-1", "healthy", ts)
+
+	expiredAt := ts
+	for _, session := range []*models.Session{
+		{AgentID: "agent-1", SessionTopic: "no-expiry", Created: ts.Add(-time.Hour), LastUpdated: ts.Add(-time.Hour), TTLMinutes: 30, Expired: true},
+		{AgentID: "agent-1", SessionTopic: "stray-expiry", Created: ts, LastUpdated: ts, TTLMinutes: 30, ExpiredAt: &expiredAt},
+	} {
+		if err := st.CreateOrUpdateSession(session); err != nil {
+			t.Fatalf("CreateOrUpdateSession(%s) error = %v", session.SessionTopic, err)
+		}
+	}
+
+	issues, err := st.CheckIntegrity(false)
+	if err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+	want := []models.IntegrityIssue{
+		{Kind: models.IntegrityExpiredFlag, AgentID: "agent-1", SessionTopic: "no-expiry", Detail: "expired session has no expiry time"},
+		{Kind: models.IntegrityExpiredFlag, AgentID: "agent-1", SessionTopic: "stray-expiry", Detail: "active session has an expiry time"},
+		{Kind: models.IntegrityOwnerlessAgent, AgentID: "legacy", Detail: "agent has no owner"},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("CheckIntegrity() = %d issues, want %d", len(issues), len(want))
+	}
+	for i := range want {
+		if *issues[i] != want[i] {
+			t.Errorf("CheckIntegrity()[%d] = %+v, want %+v", i, *issues[i], want[i])
+		}
+	}
+	if session, _ := st.GetSession("agent-1", "no-expiry"); session.ExpiredAt != nil {
+		t.Errorf("CheckIntegrity() without repair changed the session to %+v", session)
+	}
+
+	issues, err = st.CheckIntegrity(true)
+	if err != nil || len(issues) != 3 || !issues[0].Repaired || !issues[1].Repaired || issues[2].Repaired {
+		t.Fatalf("CheckIntegrity(repair) = %+v, %v, want the expired flags repaired", issues, err)
+	}
+	session, err := st.GetSession("agent-1", "no-expiry")
+	if err != nil || session.ExpiredAt == nil || !session.ExpiredAt.Equal(ts.Add(-30*time.Minute)) {
+		t.Errorf("GetSession() repaired expiry = %+v, %v, want expired at the end of its TTL", session, err)
+	}
+	if session, err := st.GetSession("agent-1", "stray-expiry"); err != nil || session.ExpiredAt != nil || session.Version != 2 {
+		t.Errorf("GetSession() repaired active session = %+v, %v, want no expiry at version 2", session, err)
+	}
+
+	issues, err = st.CheckIntegrity(false)
+	if err != nil || len(issues) != 1 || issues[0].Kind != models.IntegrityOwnerlessAgent {
+		t.Errorf("CheckIntegrity() after repair = %+v, %v, want only the ownerless agent", issues, err)
+	}
+}
+
+func testSLAs(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	slas := []*models.SLA{
+		{ID: "sla-1", UserID: "user-1", Name: "nightly", TopicPattern: "^nightly-", MaxDurationMinutes: 60, CreatedAt: ts.Add(-2 * time.Minute), UpdatedAt: ts},
+		{ID: "sla-2", UserID: "user-2", Name: "deploys", AgentID: "agent-1", MaxFailureRate: 0.1, CreatedAt: ts.Add(-time.Minute), UpdatedAt: ts},
+		{ID: "sla-3", UserID: "user-1", Name: "builds", MaxFailureRate: 0.5, CreatedAt: ts, UpdatedAt: ts},
+	}
+	for _, sla := range slas {
+		if err := st.CreateSLA(sla); err != nil {
+			t.Fatalf("CreateSLA(%s) error = %v", sla.ID, err)
+		}
+	}
+
+	got, err := st.GetSLA("sla-1")
+	if err != nil || got.TopicPattern != "^nightly-" || got.MaxDurationMinutes != 60 || !got.CreatedAt.Equal(slas[0].CreatedAt) {
+		t.Errorf("GetSLA() = %+v, %v, want sla-1", got, err)
+	}
+	if _, err := st.GetSLA("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSLA() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	all, err := st.ListSLAs()
+	if err != nil || !reflect.DeepEqual(slaIDs(all), []string{"sla-1", "sla-2", "sla-3"}) {
+		t.Errorf("ListSLAs() = %v, %v, want [sla-1 sla-2 sla-3]", slaIDs(all), err)
+	}
+	owned, err := st.ListSLAsByUser("user-1")
+	if err != nil || !reflect.DeepEqual(slaIDs(owned), []string{"sla-1", "sla-3"}) {
+		t.Errorf("ListSLAsByUser() = %v, %v, want [sla-1 sla-3]", slaIDs(owned), err)
+	}
+
+	got.Name = "nightly builds"
+	got.MaxFailureRate = 0.2
+	got.UpdatedAt = ts.Add(time.Minute)
+	if err := st.UpdateSLA(got); err != nil {
+		t.Fatalf("UpdateSLA() error = %v", err)
+	}
+	if updated, err := st.GetSLA("sla-1"); err != nil || updated.Name != "nightly builds" || updated.MaxFailureRate != 0.2 {
+		t.Errorf("GetSLA() after update = %+v, %v", updated, err)
+	}
+	missing := &models.SLA{ID: "missing", UserID: "user-1", Name: "missing", MaxDurationMinutes: 60}
+	if err := st.UpdateSLA(missing); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateSLA() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	if err := st.DeleteSLA("sla-1"); err != nil {
+		t.Fatalf("DeleteSLA() error = %v", err)
+	}
+	if _, err := st.GetSLA("sla-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSLA() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.DeleteSLA("sla-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteSLA() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testSessionWebhooks(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	hooks := []*models.SessionWebhook{
+		{ID: "hook-1", UserID: "user-1", URL: "https://ci.example.com/hooks/1", Secret: "secret-1", Outcomes: []string{models.SessionOutcomeFailed}, CreatedAt: ts.Add(-time.Minute), UpdatedAt: ts},
+		{ID: "hook-2", UserID: "user-2", URL: "https://ci.example.com/hooks/2", Secret: "secret-2", CreatedAt: ts, UpdatedAt: ts},
+		{ID: "hook-3", UserID: "user-1", URL: "https://ci.example.com/hooks/3", Secret: "secret-3", AgentID: "agent-1", CreatedAt: ts, UpdatedAt: ts},
+	}
+	for _, hook := range hooks {
+		if err := st.CreateSessionWebhook(hook); err != nil {
+			t.Fatalf("CreateSessionWebhook(%s) error = %v", hook.ID, err)
+		}
+	}
+	if err := st.CreateSessionWebhook(hooks[0]); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateSessionWebhook() duplicate error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+
+	got, err := st.GetSessionWebhook("hook-1")
+	if err != nil || got.Secret != "secret-1" || !reflect.DeepEqual(got.Outcomes, []string{models.SessionOutcomeFailed}) || !got.CreatedAt.Equal(hooks[0].CreatedAt) {
+		t.Errorf("GetSessionWebhook() = %+v, %v, want hook-1", got, err)
+	}
+	if _, err := st.GetSessionWebhook("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSessionWebhook() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	owned, err := st.ListSessionWebhooksByUser("user-1")
+	if err != nil || len(owned) != 2 || owned[0].ID != "hook-1" || owned[1].ID != "hook-3" {
+		t.Fatalf("ListSessionWebhooksByUser() = %+v, %v, want [hook-1 hook-3]", owned, err)
+	}
+	if owned[1].AgentID != "agent-1" || owned[1].Outcomes != nil {
+		t.Errorf("ListSessionWebhooksByUser()[1] = %+v, want agent-1 and every outcome", owned[1])
+	}
+
+	got.URL = "https://ci.example.com/hooks/1b"
+	got.Secret = "secret-1b"
+	got.Outcomes = nil
+	got.UpdatedAt = ts.Add(time.Minute)
+	if err := st.UpdateSessionWebhook(got); err != nil {
+		t.Fatalf("UpdateSessionWebhook() error = %v", err)
+	}
+	if updated, err := st.GetSessionWebhook("hook-1"); err != nil || updated.URL != got.URL || updated.Secret != "secret-1b" || updated.Outcomes != nil {
+		t.Errorf("GetSessionWebhook() after update = %+v, %v", updated, err)
+	}
+	missing := &models.SessionWebhook{ID: "missing", UserID: "user-1", URL: "https://ci.example.com", Secret: "secret"}
+	if err := st.UpdateSessionWebhook(missing); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateSessionWebhook() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	if err := st.DeleteSessionWebhook("hook-1"); err != nil {
+		t.Fatalf("DeleteSessionWebhook() error = %v", err)
+	}
+	if err := st.DeleteSessionWebhook("hook-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteSessionWebhook() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	// Deleting the user deletes their webhooks
+	if err := st.DeleteUser("user-2"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := st.GetSessionWebhook("hook-2"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetSessionWebhook() after DeleteUser error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+// slaIDs returns SLA IDs in list order
+func slaIDs(slas []*models.SLA) []string {
+	ids := make([]string, 0, len(slas))
+	for _, sla := range slas {
+		ids = append(ids, sla.ID)
+	}
+	return ids
+}
+
+func testAlertRules(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	rules := []*models.AlertRule{
+		{ID: "rule-1", UserID: "user-1", Name: "failures", Kind: models.AlertRuleStatus, Status: "failed", TopicPattern: "^deploy-", CreatedAt: ts.Add(-2 * time.Minute), UpdatedAt: ts},
+		{ID: "rule-2", UserID: "user-2", Name: "silent", Kind: models.AlertRuleAgentOffline, AgentID: "agent-1", DurationMinutes: 10, CreatedAt: ts.Add(-time.Minute), UpdatedAt: ts},
+		{ID: "rule-3", UserID: "user-1", Name: "slow", Kind: models.AlertRuleRunningLonger, DurationMinutes: 30, CreatedAt: ts, UpdatedAt: ts},
+	}
+	for _, rule := range rules {
+		if err := st.CreateAlertRule(rule); err != nil {
+			t.Fatalf("CreateAlertRule(%s) error = %v", rule.ID, err)
+		}
+	}
+	invalid := &models.AlertRule{ID: "rule-4", UserID: "user-1", Name: "invalid", Kind: models.AlertRuleStatus}
+	if err := st.CreateAlertRule(invalid); !errors.Is(err, store.ErrInvalid) {
+		t.Errorf("CreateAlertRule() invalid error = %v, want %v", err, store.ErrInvalid)
+	}
+
+	got, err := st.GetAlertRule("rule-1")
+	if err != nil || got.Kind != models.AlertRuleStatus || got.Status != "failed" || got.TopicPattern != "^deploy-" || !got.CreatedAt.Equal(rules[0].CreatedAt) {
+		t.Errorf("GetAlertRule() = %+v, %v, want rule-1", got, err)
+	}
+	if _, err := st.GetAlertRule("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAlertRule() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	all, err := st.ListAlertRules()
+	if err != nil || !reflect.DeepEqual(alertRuleIDs(all), []string{"rule-1", "rule-2", "rule-3"}) {
+		t.Errorf("ListAlertRules() = %v, %v, want [rule-1 rule-2 rule-3]", alertRuleIDs(all), err)
+	}
+	owned, err := st.ListAlertRulesByUser("user-1")
+	if err != nil || !reflect.DeepEqual(alertRuleIDs(owned), []string{"rule-1", "rule-3"}) {
+		t.Errorf("ListAlertRulesByUser() = %v, %v, want [rule-1 rule-3]", alertRuleIDs(owned), err)
+	}
+
+	got.Kind, got.Status, got.DurationMinutes = models.AlertRuleRunningLonger, "", 45
+	got.UpdatedAt = ts.Add(time.Minute)
+	if err := st.UpdateAlertRule(got); err != nil {
+		t.Fatalf("UpdateAlertRule() error = %v", err)
+	}
+	if updated, err := st.GetAlertRule("rule-1"); err != nil || updated.Kind != models.AlertRuleRunningLonger || updated.DurationMinutes != 45 {
+		t.Errorf("GetAlertRule() after update = %+v, %v", updated, err)
+	}
+	missing := &models.AlertRule{ID: "missing", UserID: "user-1", Name: "missing", Kind: models.AlertRuleStatus, Status: "failed"}
+	if err := st.UpdateAlertRule(missing); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateAlertRule() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	if err := st.DeleteAlertRule("rule-1"); err != nil {
+		t.Fatalf("DeleteAlertRule() error = %v", err)
+	}
+	if _, err := st.GetAlertRule("rule-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetAlertRule() after delete error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.DeleteAlertRule("rule-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteAlertRule() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testNotificationMarks(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	failed := &models.NotificationMark{UserID: "user-1", AgentID: "agent-1", SessionTopic: "build", Status: "failed", NotifiedAt: ts}
+	if err := st.ClaimNotificationMark(failed, ts); err != nil {
+		t.Fatalf("ClaimNotificationMark() error = %v", err)
+	}
+
+	// Within the window the same mark is held back; other users, sessions and statuses are not
+	again := *failed
+	again.NotifiedAt = ts.Add(5 * time.Minute)
+	if err := st.ClaimNotificationMark(&again, again.NotifiedAt.Add(-15*time.Minute)); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("ClaimNotificationMark() within the window error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+	for _, other := range []*models.NotificationMark{
+		{UserID: "user-2", AgentID: "agent-1", SessionTopic: "build", Status: "failed", NotifiedAt: again.NotifiedAt},
+		{UserID: "user-1", AgentID: "agent-1", SessionTopic: "deploy", Status: "failed", NotifiedAt: again.NotifiedAt},
+		{UserID: "user-1", AgentID: "agent-1", SessionTopic: "build", Status: "success", NotifiedAt: ts.Add(-48 * time.Hour)},
+	} {
+		if err := st.ClaimNotificationMark(other, again.NotifiedAt.Add(-15*time.Minute)); err != nil {
+			t.Errorf("ClaimNotificationMark(%s, %s, %s) error = %v", other.UserID, other.SessionTopic, other.Status, err)
+		}
+	}
+
+	// Past the window the mark is replaced
+	again.NotifiedAt = ts.Add(20 * time.Minute)
+	again.Escalations = 1
+	if err := st.ClaimNotificationMark(&again, again.NotifiedAt.Add(-15*time.Minute)); err != nil {
+		t.Errorf("ClaimNotificationMark() past the window error = %v", err)
+	}
+	if err := st.ClaimNotificationMark(&models.NotificationMark{UserID: "user-1", AgentID: "agent-1", SessionTopic: "build", Status: "exploded", NotifiedAt: ts}, ts); !errors.Is(err, store.ErrInvalid) {
+		t.Errorf("ClaimNotificationMark() invalid error = %v, want %v", err, store.ErrInvalid)
+	}
+
+	marks, err := st.ListNotificationMarks("failed")
+	if err != nil || len(marks) != 3 {
+		t.Fatalf("ListNotificationMarks() = %d marks, %v, want 3", len(marks), err)
+	}
+	for _, mark := range marks {
+		if mark.UserID == "user-1" && mark.SessionTopic == "build" && (mark.Escalations != 1 || !mark.NotifiedAt.Equal(again.NotifiedAt)) {
+			t.Errorf("ListNotificationMarks() build = %+v, want the replaced mark", mark)
+		}
+	}
+
+	if removed, err := st.PurgeNotificationMarks(ts.Add(-24 * time.Hour)); err != nil || removed != 1 {
+		t.Errorf("PurgeNotificationMarks() = %d, %v, want 1", removed, err)
+	}
+	if marks, _ := st.ListNotificationMarks("success"); len(marks) != 0 {
+		t.Errorf("ListNotificationMarks() after purge = %d success marks, want 0", len(marks))
+	}
+}
+
+func alertRuleIDs(rules []*models.AlertRule) []string {
+	ids := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
+
+func testSLABreaches(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	ts := now()
+	sla := &models.SLA{ID: "sla-1", UserID: "user-1", Name: "nightly", MaxDurationMinutes: 60, CreatedAt: ts, UpdatedAt: ts}
+	if err := st.CreateSLA(sla); err != nil {
+		t.Fatalf("CreateSLA() error = %v", err)
+	}
+
+	breaches := []*models.SLABreach{
+		{ID: "breach-1", SLAID: "sla-1", UserID: "user-1", AgentID: "agent-1", Kind: models.SLABreachDuration, Subject: "task-1", Value: 90, Threshold: 60, DetectedAt: ts.Add(-48 * time.Hour)},
+		{ID: "breach-2", SLAID: "sla-1", UserID: "user-1", AgentID: "agent-1", Kind: models.SLABreachDuration, Subject: "task-2", Value: 75, Threshold: 60, DetectedAt: ts.Add(-time.Hour)},
+		{ID: "breach-3", SLAID: "sla-1", UserID: "user-1", AgentID: "agent-1", Kind: models.SLABreachFailureRate, Subject: "2026-01-01", Value: 0.5, Threshold: 0.1, DetectedAt: ts},
+	}
+	for _, breach := range breaches {
+		if err := st.CreateSLABreach(breach); err != nil {
+			t.Fatalf("CreateSLABreach(%s) error = %v", breach.ID, err)
+		}
+	}
+
+	duplicate := *breaches[1]
+	duplicate.ID = "breach-4"
+	if err := st.CreateSLABreach(&duplicate); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateSLABreach() duplicate error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+
+	recent, err := st.ListSLABreaches("sla-1", ts.Add(-24*time.Hour))
+	if err != nil || len(recent) != 2 || recent[0].ID != "breach-3" || recent[1].ID != "breach-2" {
+		t.Errorf("ListSLABreaches() = %d breaches, %v, want breach-3 then breach-2", len(recent), err)
+	}
+	if len(recent) == 2 && (recent[1].Value != 75 || recent[1].Threshold != 60 || recent[1].Subject != "task-2") {
+		t.Errorf("ListSLABreaches() breach-2 = %+v", recent[1])
+	}
+
+	purged, err := st.PurgeSLABreaches(ts.Add(-24 * time.Hour))
+	if err != nil || purged != 1 {
+		t.Errorf("PurgeSLABreaches() = %d, %v, want 1", purged, err)
+	}
+	if all, err := st.ListSLABreaches("sla-1", time.Time{}); err != nil || len(all) != 2 {
+		t.Errorf("ListSLABreaches() after purge = %d breaches, %v, want 2", len(all), err)
+	}
+
+	if err := st.DeleteSLA("sla-1"); err != nil {
+		t.Fatalf("DeleteSLA() error = %v", err)
+	}
+	if all, err := st.ListSLABreaches("sla-1", time.Time{}); err != nil || len(all) != 0 {
+		t.Errorf("ListSLABreaches() after deleting the SLA = %d breaches, %v, want 0", len(all), err)
+	}
+}
+
+func testWatchItems(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	items := []*models.WatchItem{
+		{UserID: "user-1", AgentID: "agent-1", CreatedAt: ts.Add(-time.Minute), UpdatedAt: ts},
+		{UserID: "user-1", AgentID: "agent-1", SessionTopic: "task-1", NotificationWebhookURL: "https://hooks.example.com/task", CreatedAt: ts, UpdatedAt: ts},
+		{UserID: "user-2", AgentID: "agent-1", CreatedAt: ts, UpdatedAt: ts},
+	}
+	for _, item := range items {
+		if err := st.SaveWatchItem(item); err != nil {
+			t.Fatalf("SaveWatchItem(%s, %s) error = %v", item.UserID, item.SessionTopic, err)
+		}
+	}
+
+	got, err := st.GetWatchItem("user-1", "agent-1", "task-1")
+	if err != nil || got.NotificationWebhookURL != "https://hooks.example.com/task" || got.MuteNotifications {
+		t.Errorf("GetWatchItem() = %+v, %v, want task-1 with webhook", got, err)
+	}
+	if _, err := st.GetWatchItem("user-2", "agent-1", "task-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetWatchItem() other user error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	got.MuteNotifications = true
+	got.NotificationWebhookURL = ""
+	got.UpdatedAt = ts.Add(time.Minute)
+	if err := st.SaveWatchItem(got); err != nil {
+		t.Fatalf("SaveWatchItem() replace error = %v", err)
+	}
+	if replaced, err := st.GetWatchItem("user-1", "agent-1", "task-1"); err != nil || !replaced.MuteNotifications || replaced.NotificationWebhookURL != "" {
+		t.Errorf("GetWatchItem() after replace = %+v, %v, want muted without webhook", replaced, err)
+	}
+
+	listed, err := st.ListWatchItems("user-1")
+	if err != nil || len(listed) != 2 || listed[0].SessionTopic != "" || listed[1].SessionTopic != "task-1" {
+		t.Errorf("ListWatchItems() = %d items, %v, want the agent star then task-1", len(listed), err)
+	}
+
+	if err := st.DeleteWatchItem("user-1", "agent-1", ""); err != nil {
+		t.Fatalf("DeleteWatchItem() error = %v", err)
+	}
+	if err := st.DeleteWatchItem("user-1", "agent-1", ""); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteWatchItem() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := st.GetWatchItem("user-2", "agent-1", ""); err != nil {
+		t.Errorf("GetWatchItem() other user's star after delete error = %v, want nil", err)
+	}
+}
+
+func testNotificationSettings(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+
+	if _, err := st.GetNotificationSettings("user-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetNotificationSettings() before save error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	ts := now()
+	settings := &models.NotificationSettings{
+		UserID:      "user-1",
+		WebhookURL:  "https://discord.com/api/webhooks/1/abc",
+		Format:      "discord",
+		Transitions: []models.StatusTransition{{From: "*", To: "failed"}, {From: "pending", To: "running"}},
+		CreatedAt:   ts,
+		UpdatedAt:   ts,
+	}
+	settings.FirstFailureOnly = true
+	settings.Template = "{{.AgentName}}: {{.ToStatus}}"
+	settings.DedupMinutes, settings.EscalateAfterMinutes = 15, 60
+	if err := st.SaveNotificationSettings(settings); err != nil {
+		t.Fatalf("SaveNotificationSettings() error = %v", err)
+	}
+	got, err := st.GetNotificationSettings("user-1")
+	if err != nil || got.WebhookURL != settings.WebhookURL || got.Format != "discord" || len(got.Transitions) != 2 || got.Transitions[1].From != "pending" || !got.FirstFailureOnly ||
+		got.Template != settings.Template || got.DedupMinutes != 15 || got.EscalateAfterMinutes != 60 {
+		t.Errorf("GetNotificationSettings() = %+v, %v, want the saved settings", got, err)
+	}
+
+	got.WebhookURL = ""
+	got.Format = ""
+	got.Transitions = nil
+	got.FirstFailureOnly = false
+	got.UpdatedAt = ts.Add(time.Minute)
+	if err := st.SaveNotificationSettings(got); err != nil {
+		t.Fatalf("SaveNotificationSettings() replace error = %v", err)
+	}
+	if replaced, err := st.GetNotificationSettings("user-1"); err != nil || replaced.WebhookURL != "" || len(replaced.Transitions) != 0 || replaced.FirstFailureOnly {
+		t.Errorf("GetNotificationSettings() after replace = %+v, %v, want empty settings", replaced, err)
+	}
+
+	if err := st.SaveNotificationSettings(&models.NotificationSettings{UserID: "user-1", Transitions: []models.StatusTransition{{From: "done", To: "failed"}}, CreatedAt: ts, UpdatedAt: ts}); err == nil {
+		t.Error("SaveNotificationSettings() with an unknown status error = nil, want an error")
+	}
+
+	if err := st.DeleteNotificationSettings("user-1"); err != nil {
+		t.Fatalf("DeleteNotificationSettings() error = %v", err)
+	}
+	if err := st.DeleteNotificationSettings("user-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteNotificationSettings() twice error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testInboxItems(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	ts := now()
+	items := []*models.InboxItem{
+		{ID: "item-1", UserID: "user-1", Kind: models.InboxKindFailure, AgentID: "agent-1", SessionTopic: "task-1", Message: "failed", DedupeKey: "failure:1", CreatedAt: ts.Add(-2 * time.Minute)},
+		{ID: "item-2", UserID: "user-1", Kind: models.InboxKindExpiration, AgentID: "agent-1", SessionTopic: "task-2", Message: "expired", DedupeKey: "expiration:2", CreatedAt: ts.Add(-time.Minute)},
+		{ID: "item-3", UserID: "user-1", Kind: models.InboxKindOffline, AgentID: "agent-1", Message: "offline", DedupeKey: "offline:1", CreatedAt: ts},
+		{ID: "item-4", UserID: "user-2", Kind: models.InboxKindFailure, AgentID: "agent-2", Message: "failed", DedupeKey: "failure:1", CreatedAt: ts},
+	}
+	for _, item := range items {
+		if err := st.CreateInboxItem(item); err != nil {
+			t.Fatalf("CreateInboxItem(%s) error = %v", item.ID, err)
+		}
+	}
+
+	duplicate := *items[0]
+	duplicate.ID = "item-5"
+	if err := st.CreateInboxItem(&duplicate); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("CreateInboxItem() duplicate error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+
+	listed, err := st.ListInboxItems("user-1", false, 0)
+	if err != nil || len(listed) != 3 || listed[0].ID != "item-3" || listed[2].ID != "item-1" {
+		t.Errorf("ListInboxItems() = %d items, %v, want item-3 first and item-1 last", len(listed), err)
+	}
+	if limited, err := st.ListInboxItems("user-1", false, 2); err != nil || len(limited) != 2 || limited[0].ID != "item-3" {
+		t.Errorf("ListInboxItems() limit 2 = %d items, %v, want the 2 newest", len(limited), err)
+	}
+	if page, total, err := st.ListInboxItemsPage("user-1", false, store.Page{Offset: 1, Limit: 1}); err != nil || total != 3 || len(page) != 1 || page[0].ID != "item-2" {
+		t.Errorf("ListInboxItemsPage(1, 1) = %d items, %d, %v, want item-2 of 3", len(page), total, err)
+	}
+
+	if err := st.MarkInboxItemRead("user-2", "item-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("MarkInboxItemRead() other user error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := st.MarkInboxItemRead("user-1", "item-3"); err != nil {
+		t.Fatalf("MarkInboxItemRead() error = %v", err)
+	}
+	if unread, err := st.CountUnreadInboxItems("user-1"); err != nil || unread != 2 {
+		t.Errorf("CountUnreadInboxItems() = %d, %v, want 2", unread, err)
+	}
+	if unreadItems, err := st.ListInboxItems("user-1", true, 0); err != nil || len(unreadItems) != 2 || unreadItems[0].ID != "item-2" {
+		t.Errorf("ListInboxItems() unread = %d items, %v, want item-2 first", len(unreadItems), err)
+	}
+
+	marked, err := st.MarkAllInboxItemsRead("user-1")
+	if err != nil || marked != 2 {
+		t.Errorf("MarkAllInboxItemsRead() = %d, %v, want 2", marked, err)
+	}
+	if unread, err := st.CountUnreadInboxItems("user-2"); err != nil || unread != 1 {
+		t.Errorf("CountUnreadInboxItems() other user = %d, %v, want 1", unread, err)
+	}
+}
+
+func testUsage(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	adds := []*models.UsageRecord{
+		{UserID: "user-1", Day: day.Add(9 * time.Hour), StatusReports: 2, StorageBytes: 100},
+		{UserID: "user-1", Day: day.Add(23 * time.Hour), StatusReports: 1, StorageBytes: 50, NotificationsSent: 1},
+		{UserID: "user-2", Day: day, StatusReports: 5},
+		{UserID: "user-1", Day: day.AddDate(0, 0, 1), StatusReports: 7},
+	}
+	for _, usage := range adds {
+		if err := st.AddUsage(usage); err != nil {
+			t.Fatalf("AddUsage() error = %v", err)
+		}
+	}
+	if err := st.AddUsage(&models.UsageRecord{UserID: "missing", Day: day, StatusReports: 1}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("AddUsage() for a missing user error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	records, err := st.ListUsage("user-1", day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("ListUsage() error = %v", err)
+	}
+	want := models.UsageRecord{UserID: "user-1", Day: day, StatusReports: 3, StorageBytes: 150, NotificationsSent: 1}
+	if len(records) != 1 || !records[0].Day.Equal(day) || records[0].StatusReports != want.StatusReports ||
+		records[0].StorageBytes != want.StorageBytes || records[0].NotificationsSent != want.NotificationsSent {
+		t.Fatalf("ListUsage() = %+v, want one day summing the adds: %+v", records, want)
+	}
+
+	// Every user, ordered by day and then user; bounds are truncated to their day
+	records, err = st.ListUsage("", day.Add(12*time.Hour), day.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("ListUsage() of every user error = %v", err)
+	}
+	var got []string
+	for _, record := range records {
+		got = append(got, fmt.Sprintf("%s %s %d", record.Day.Format(time.DateOnly), record.UserID, record.StatusReports))
+	}
+	if wantAll := []string{"2026-03-01 user-1 3", "2026-03-01 user-2 5", "2026-03-02 user-1 7"}; !reflect.DeepEqual(got, wantAll) {
+		t.Errorf("ListUsage() of every user = %v, want %v", got, wantAll)
+	}
+
+	if err := st.DeleteUser("user-1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if records, _ := st.ListUsage("user-1", day, day.AddDate(0, 0, 2)); len(records) != 0 {
+		t.Errorf("ListUsage() after deleting the user = %d records, want 0", len(records))
+	}
+}
+
+func testTenantCounts(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	mustCreateUser(t, st, "user-2", "bob@example.com")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if _, err := st.GetTenantCounts("user-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetTenantCounts() before any count error = %v, want %v", err, store.ErrNotFound)
+	}
+	for _, delta := range []*models.TenantCounts{
+		{UserID: "user-1", Agents: 1, Sessions: 1, Statuses: 3},
+		{UserID: "user-1", Sessions: 1, Statuses: 2},
+	} {
+		if err := st.AddTenantCounts(delta); err != nil {
+			t.Fatalf("AddTenantCounts() error = %v", err)
+		}
+	}
+	if err := st.AddTenantCounts(&models.TenantCounts{UserID: "missing", Agents: 1}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("AddTenantCounts() for a missing user error = %v, want %v", err, store.ErrNotFound)
+	}
+	counts, err := st.GetTenantCounts("user-1")
+	if err != nil {
+		t.Fatalf("GetTenantCounts() error = %v", err)
+	}
+	if counts.Agents != 1 || counts.Sessions != 2 || counts.Statuses != 5 || !counts.ReconciledAt.IsZero() {
+		t.Errorf("GetTenantCounts() = %+v, want the sums of the adds, never reconciled", counts)
+	}
+
+	// Reconciling counts the stored records, leaving out deleted agents
+	mustCreateAgent(t, st, "agent-1", "user-1", now)
+	mustCreateAgent(t, st, "agent-2", "user-1", now)
+	mustCreateSession(t, st, "agent-1", "build", now)
+	mustCreateSession(t, st, "agent-2", "lint", now)
+	for _, status := range []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "build", Status: "running", Timestamp: now},
+		{AgentID: "agent-1", SessionTopic: "build", Status: "success", Timestamp: now.Add(time.Minute)},
+		{AgentID: "agent-2", SessionTopic: "lint", Status: "running", Timestamp: now},
+	} {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus() error = %v", err)
+		}
+	}
+	if err := st.DeleteAgent("agent-2", now); err != nil {
+		t.Fatalf("DeleteAgent() error = %v", err)
+	}
+
+	reconciled, err := st.ReconcileTenantCounts(now)
+	if err != nil {
+		t.Fatalf("ReconcileTenantCounts() error = %v", err)
+	}
+	if reconciled != 2 {
+		t.Errorf("ReconcileTenantCounts() = %d, want 2 users", reconciled)
+	}
+	counts, err = st.GetTenantCounts("user-1")
+	if err != nil {
+		t.Fatalf("GetTenantCounts() error = %v", err)
+	}
+	if counts.Agents != 1 || counts.Sessions != 1 || counts.Statuses != 2 || !counts.ReconciledAt.Equal(now) {
+		t.Errorf("GetTenantCounts() after reconciling = %+v, want 1 agent, 1 session and 2 statuses reconciled at %v", counts, now)
+	}
+	if counts, err := st.GetTenantCounts("user-2"); err != nil || counts.Agents != 0 || counts.Statuses != 0 {
+		t.Errorf("GetTenantCounts() of a user without records = %+v, %v, want zero counts", counts, err)
+	}
+
+	if err := st.DeleteUser("user-1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := st.GetTenantCounts("user-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetTenantCounts() after deleting the user error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func testStatusRollups(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "alice@example.com")
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	mustCreateAgent(t, st, "agent-1", "user-1", day)
+	mustCreateAgent(t, st, "agent-2", "user-1", day)
+	mustCreateSession(t, st, "agent-1", "task-1", day)
+	mustCreateSession(t, st, "agent-1", "task-2", day)
+	mustCreateSession(t, st, "agent-2", "task-1", day)
+
+	for _, status := range []*models.AgentStatus{
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: day.Add(10 * time.Hour), Revision: 1},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "success", Timestamp: day.Add(10*time.Hour + 30*time.Minute), Revision: 1},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: day.Add(20 * time.Hour), Revision: 2},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "failed", Timestamp: day.Add(21 * time.Hour), Revision: 2},
+		{AgentID: "agent-1", SessionTopic: "task-1", Status: "running", Timestamp: day.Add(25 * time.Hour), Revision: 3},
+		{AgentID: "agent-1", SessionTopic: "task-2", Status: "pending", Timestamp: day.Add(12 * time.Hour), Revision: 1},
+		{AgentID: "agent-2", SessionTopic: "task-1", Status: "running", Timestamp: day.Add(13 * time.Hour), Revision: 1},
+	} {
+		if err := st.AddStatus(status); err != nil {
+			t.Fatalf("AddStatus() error = %v", err)
+		}
+	}
+
+	// Rolling up a day twice replaces its rollups; statuses of the next day are left out
+	for i := 0; i < 2; i++ {
+		written, err := st.RollupStatuses(day.Add(6*time.Hour), day.AddDate(0, 0, 1))
+		if err != nil || written != 3 {
+			t.Fatalf("RollupStatuses() = %d, %v, want 3 rollups", written, err)
+		}
+	}
+
+	rollups, err := st.ListStatusRollups("agent-1", "task-1", day, day.AddDate(0, 0, 7))
+	if err != nil || len(rollups) != 1 {
+		t.Fatalf("ListStatusRollups() = %+v, %v, want one rollup", rollups, err)
+	}
+	got := rollups[0]
+	if !got.Day.Equal(day) || got.Running != 2 || got.Success != 1 || got.Failed != 1 || got.Pending != 0 || got.Runs != 2 {
+		t.Errorf("ListStatusRollups() = %+v, want 2 runs with 2 running, 1 success and 1 failed status", got)
+	}
+	if !got.FirstAt.Equal(day.Add(10*time.Hour)) || !got.LastAt.Equal(day.Add(21*time.Hour)) || got.DurationSeconds != 5400 {
+		t.Errorf("ListStatusRollups() span = %v to %v over %vs, want 10:00 to 21:00 over 5400s", got.FirstAt, got.LastAt, got.DurationSeconds)
+	}
+
+	// Every agent, ordered by day, agent and session topic
+	rollups, err = st.ListStatusRollups("", "", day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("ListStatusRollups() of every agent error = %v", err)
+	}
+	var keys []string
+	for _, rollup := range rollups {
+		keys = append(keys, rollup.AgentID+"/"+rollup.SessionTopic)
+	}
+	if want := []string{"agent-1/task-1", "agent-1/task-2", "agent-2/task-1"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("ListStatusRollups() of every agent = %v, want %v", keys, want)
+	}
+
+	saved := &models.StatusRollup{AgentID: "agent-2", SessionTopic: "task-1", Day: day.AddDate(0, 0, -1), Success: 4, Runs: 4, FirstAt: day.Add(-time.Hour), LastAt: day.Add(-time.Minute), DurationSeconds: 120}
+	if err := st.SaveStatusRollup(saved); err != nil {
+		t.Fatalf("SaveStatusRollup() error = %v", err)
+	}
+	if rollups, _ := st.ListStatusRollups("agent-2", "", day.AddDate(0, 0, -1), day); len(rollups) != 1 || rollups[0].Success != 4 {
+		t.Errorf("ListStatusRollups() after SaveStatusRollup() = %+v, want the saved rollup", rollups)
+	}
+	missing := *saved
+	missing.SessionTopic = "missing"
+	if err := st.SaveStatusRollup(&missing); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("SaveStatusRollup() for a missing session error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	if err := st.DeleteUser("user-1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if rollups, _ := st.ListStatusRollups("", "", day.AddDate(0, 0, -1), day.AddDate(0, 0, 2)); len(rollups) != 0 {
+		t.Errorf("ListStatusRollups() after deleting the user = %d rollups, want 0", len(rollups))
+	}
+}
+
+func testAuditEvents(t *testing.T, st store.Store) {
+	ts := now()
+	if err := st.AddAuditEvent(&models.AuditEvent{ActorID: "admin-1", Action: "GET /api/admin/search"}); err == nil {
+		t.Error("AddAuditEvent() without created_at error = nil, want error")
+	}
+
+	events := []*models.AuditEvent{
+		{ActorID: "admin-1", ActorEmail: "admin@example.com", Action: "GET /api/admin/search", Query: "q=alice", StatusCode: 200, CreatedAt: ts.Add(-time.Hour)},
+		{ActorID: "admin-1", ActorEmail: "admin@example.com", Action: "GET /api/admin/tenants/{user_id}", Target: "user-1", StatusCode: 200, CreatedAt: ts.Add(-time.Minute)},
+		{ActorID: "admin-2", Action: "GET /api/admin/metrics", StatusCode: 200, CreatedAt: ts},
+	}
+	for _, event := range events {
+		if err := st.AddAuditEvent(event); err != nil {
+			t.Fatalf("AddAuditEvent(%s) error = %v", event.Action, err)
+		}
+	}
+	if events[0].ID == 0 || events[1].ID <= events[0].ID || events[2].ID <= events[1].ID {
+		t.Errorf("AddAuditEvent() IDs = %d, %d, %d, want increasing IDs", events[0].ID, events[1].ID, events[2].ID)
+	}
+
+	got, err := st.ListAuditEvents(time.Time{}, 0)
+	if err != nil || len(got) != 3 || got[0].ID != events[2].ID || got[2].ID != events[0].ID {
+		t.Fatalf("ListAuditEvents() = %d events, %v, want all 3 newest first", len(got), err)
+	}
+	if oldest := got[2]; oldest.Query != "q=alice" || oldest.ActorEmail != "admin@example.com" || oldest.StatusCode != 200 || !oldest.CreatedAt.Equal(events[0].CreatedAt) {
+		t.Errorf("ListAuditEvents() oldest = %+v, want the search event", oldest)
+	}
+
+	if got, err := st.ListAuditEvents(ts.Add(-2*time.Minute), 0); err != nil || len(got) != 2 {
+		t.Errorf("ListAuditEvents() since = %d events, %v, want 2", len(got), err)
+	}
+	if got, err := st.ListAuditEvents(time.Time{}, 1); err != nil || len(got) != 1 || got[0].Target != "" || got[0].ActorID != "admin-2" {
+		t.Errorf("ListAuditEvents() limited = %+v, %v, want only the newest event", got, err)
+	}
+
+	if purged, err := st.PurgeAuditEvents(ts.Add(-2 * time.Minute)); err != nil || purged != 1 {
+		t.Errorf("PurgeAuditEvents() = %d, %v, want 1", purged, err)
+	}
+	if got, err := st.ListAuditEvents(time.Time{}, 0); err != nil || len(got) != 2 || got[1].ID != events[1].ID {
+		t.Errorf("ListAuditEvents() after purge = %d events, %v, want the 2 newest", len(got), err)
+	}
+}
+
+func testNonces(t *testing.T, st store.Store) {
+	ts := time.Now()
+
+	if err := st.SaveNonce("key:1", "nonce-1", ts.Add(time.Hour)); err != nil {
+		t.Fatalf("SaveNonce() error = %v", err)
+	}
+	if err := st.SaveNonce("key:1", "nonce-1", ts.Add(time.Hour)); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("SaveNonce() replay error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+	if err := st.SaveNonce("key:2", "nonce-1", ts.Add(time.Hour)); err != nil {
+		t.Errorf("SaveNonce() other scope error = %v, want nil", err)
+	}
+
+	if err := st.SaveNonce("key:1", "nonce-2", ts.Add(-time.Minute)); err != nil {
+		t.Fatalf("SaveNonce() expired error = %v", err)
+	}
+	if err := st.SaveNonce("key:1", "nonce-2", ts.Add(-time.Minute)); err != nil {
+		t.Errorf("SaveNonce() reuse of an expired nonce error = %v, want nil", err)
+	}
+	if err := st.SaveNonce("key:1", "nonce-3", ts.Add(-time.Minute)); err != nil {
+		t.Fatalf("SaveNonce() expired error = %v", err)
+	}
+
+	purged, err := st.PurgeExpiredNonces()
+	if err != nil || purged != 2 {
+		t.Errorf("PurgeExpiredNonces() = %d, %v, want 2", purged, err)
+	}
+	if err := st.SaveNonce("key:1", "nonce-1", ts.Add(time.Hour)); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("SaveNonce() replay after purge error = %v, want %v", err, store.ErrAlreadyExists)
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, st store.Store) {
+	mustCreateUser(t, st, "user-1", "one@example.com")
+	mustCreateUser(t, st, "user-2", "two@example.com")
+	ts := now()
+
+	claim := &models.IdempotencyRecord{UserID: "user-1", Key: "report-1", Fingerprint: "abc", CreatedAt: ts, ExpiresAt: ts.Add(time.Minute)}
+	if existing, err := st.ClaimIdempotencyKey(claim); err != nil || existing != nil {
+		t.Fatalf("ClaimIdempotencyKey() = %+v, %v, want the key claimed", existing, err)
+	}
+	existing, err := st.ClaimIdempotencyKey(claim)
+	if !errors.Is(err, store.ErrAlreadyExists) || existing == nil || existing.Completed() || existing.Fingerprint != "abc" {
+		t.Errorf("ClaimIdempotencyKey() again = %+v, %v, want the record in progress and %v", existing, err, store.ErrAlreadyExists)
+	}
+	if _, err := st.ClaimIdempotencyKey(&models.IdempotencyRecord{UserID: "user-2", Key: "report-1", Fingerprint: "abc", CreatedAt: ts, ExpiresAt: ts.Add(time.Minute)}); err != nil {
+		t.Errorf("ClaimIdempotencyKey() of another user error = %v, want nil", err)
+	}
+
+	response := []byte(`{"success":true}`)
+	if err := st.CompleteIdempotencyKey("user-1", "report-1", 200, response, ts.Add(time.Hour)); err != nil {
+		t.Fatalf("CompleteIdempotencyKey() error = %v", err)
+	}
+	existing, err = st.ClaimIdempotencyKey(claim)
+	if !errors.Is(err, store.ErrAlreadyExists) || existing == nil || existing.StatusCode != 200 || string(existing.Response) != string(response) || !existing.ExpiresAt.Equal(ts.Add(time.Hour)) {
+		t.Errorf("ClaimIdempotencyKey() completed = %+v, %v, want the stored response", existing, err)
+	}
+	if err := st.CompleteIdempotencyKey("user-1", "missing", 200, response, ts.Add(time.Hour)); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("CompleteIdempotencyKey() unclaimed error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	if err := st.ReleaseIdempotencyKey("user-2", "report-1"); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey() error = %v", err)
+	}
+	if _, err := st.ClaimIdempotencyKey(&models.IdempotencyRecord{UserID: "user-2", Key: "report-1", Fingerprint: "def", CreatedAt: ts, ExpiresAt: ts.Add(-time.Minute)}); err != nil {
+		t.Errorf("ClaimIdempotencyKey() after release error = %v, want nil", err)
+	}
+	// An expired key is claimed again
+	if existing, err := st.ClaimIdempotencyKey(&models.IdempotencyRecord{UserID: "user-2", Key: "report-1", Fingerprint: "ghi", CreatedAt: ts, ExpiresAt: ts.Add(-time.Minute)}); err != nil || existing != nil {
+		t.Errorf("ClaimIdempotencyKey() of an expired key = %+v, %v, want it claimed", existing, err)
+	}
+
+	purged, err := st.PurgeExpiredIdempotencyKeys()
+	if err != nil || purged != 1 {
+		t.Errorf("PurgeExpiredIdempotencyKeys() = %d, %v, want 1", purged, err)
+	}
+	if _, err := st.ClaimIdempotencyKey(claim); !errors.Is(err, store.ErrAlreadyExists) {
+		t.Errorf("ClaimIdempotencyKey() after purge error = %v, want the live key kept", err)
+	}
+}
+
+func testVerifyTokens(t *testing.T, st store.Store) {
+	ts := now()
+	expired := ts.Add(-time.Minute)
+	pending := ts.Add(time.Hour)
+	users := []*models.User{
+		{ID: "user-1", Email: "alice@example.com", PasswordHash: "hash", VerifyToken: "verify-1", VerifyTokenExpiresAt: &expired, CreatedAt: ts, UpdatedAt: ts},
+		{ID: "user-2", Email: "bob@example.com", PasswordHash: "hash", VerifyToken: "verify-2", VerifyTokenExpiresAt: &pending, CreatedAt: ts, UpdatedAt: ts},
+	}
+	for _, user := range users {
+		if err := st.CreateUser(user); err != nil {
+			t.Fatalf("CreateUser(%s) error = %v", user.ID, err)
+		}
+	}
+
+	cleared, err := st.ClearExpiredVerifyTokens()
+	if err != nil || cleared != 1 {
+		t.Errorf("ClearExpiredVerifyTokens() = %d, %v, want 1", cleared, err)
+	}
+	if _, err := st.GetUserByVerifyToken("verify-1"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetUserByVerifyToken() expired error = %v, want %v", err, store.ErrNotFound)
+	}
+	if user, err := st.GetUserByID("user-1"); err != nil || user.VerifyToken != "" || user.VerifyTokenExpiresAt != nil {
+		t.Errorf("GetUserByID() after clearing = %+v, %v, want no verify token", user, err)
+	}
+	if user, err := st.GetUserByVerifyToken("verify-2"); err != nil || user.ID != "user-2" {
+		t.Errorf("GetUserByVerifyToken() pending = %v, %v, want user-2", user, err)
+	}
+}
+
+func testConfig(t *testing.T, st store.Store) {
+	if _, err := st.GetConfig("missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetConfig() missing error = %v, want %v", err, store.ErrNotFound)
+	}
+
+	if err := st.SetConfig("jwt_secret", "first"); err != nil {
+		t.Fatalf("SetConfig() error = %v", err)
+	}
+	if err := st.SetConfig("jwt_secret", "second"); err != nil {
+		t.Fatalf("SetConfig() overwrite error = %v", err)
+	}
+	if value, err := st.GetConfig("jwt_secret"); err != nil || value != "second" {
+		t.Errorf("GetConfig() = %q, %v, want second", value, err)
+	}
+}
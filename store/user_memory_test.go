@@ -346,6 +346,10 @@ func TestMemoryStore_RefreshTokens(t *testing.T) {
 	if !got.Revoked {
 		t.Error("token should be revoked")
 	}
+	// A second revoke loses the rotation race
+	if err := st.RevokeRefreshToken("token-1"); err != ErrNotFound {
+		t.Errorf("RevokeRefreshToken() on revoked token error = %v, want ErrNotFound", err)
+	}
 
 	// Test RevokeAllUserTokens
 	token2 := &models.RefreshToken{
@@ -368,6 +372,25 @@ func TestMemoryStore_RefreshTokens(t *testing.T) {
 	}
 }
 
+func TestMemoryStore_PurgeExpiredRefreshTokens(t *testing.T) {
+	st := NewMemoryStore()
+	now := time.Now()
+
+	st.SaveRefreshToken(&models.RefreshToken{ID: "expired", UserID: "user-1", TokenHash: "hash-1", ExpiresAt: now.Add(-time.Minute), CreatedAt: now})
+	st.SaveRefreshToken(&models.RefreshToken{ID: "live", UserID: "user-1", TokenHash: "hash-2", ExpiresAt: now.Add(time.Hour), CreatedAt: now})
+
+	if _, err := st.PurgeExpiredRefreshTokens(); err != nil {
+		t.Fatalf("PurgeExpiredRefreshTokens() error = %v", err)
+	}
+
+	if _, err := st.GetRefreshTokenByID("expired"); err != ErrNotFound {
+		t.Errorf("PurgeExpiredRefreshTokens() kept an expired token, error = %v", err)
+	}
+	if _, err := st.GetRefreshTokenByID("live"); err != nil {
+		t.Errorf("PurgeExpiredRefreshTokens() removed a live token: %v", err)
+	}
+}
+
 func TestMemoryStore_ListAgentsByUser(t *testing.T) {
 	st := NewMemoryStore()
 
@@ -0,0 +1,733 @@
+// Package storecopy copies every record of one store into another and verifies the copy,
+// for moving a deployment to a new backend or database
+package storecopy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// Record kinds, in the order they are copied
+const (
+	KindUsers                = "users"
+	KindDataKeys             = "data_keys"
+	KindAPIKeys              = "api_keys"
+	KindClientCertificates   = "client_certificates"
+	KindEnrollmentTokens     = "enrollment_tokens"
+	KindOrganizations        = "organizations"
+	KindMemberships          = "memberships"
+	KindInvitations          = "invitations"
+	KindAgents               = "agents"
+	KindSessions             = "sessions"
+	KindStatuses             = "statuses"
+	KindAnnotations          = "status_annotations"
+	KindSLAs                 = "slas"
+	KindSLABreaches          = "sla_breaches"
+	KindAlertRules           = "alert_rules"
+	KindSessionWebhooks      = "session_webhooks"
+	KindWatchItems           = "watch_items"
+	KindNotificationSettings = "notification_settings"
+	KindInboxItems           = "inbox_items"
+	KindUsage                = "usage_records"
+	KindStatusRollups        = "status_rollups"
+	KindAuditEvents          = "audit_events"
+	KindConfig               = "config"
+)
+
+// Kinds lists the record kinds in copy order
+var Kinds = []string{
+	KindUsers, KindDataKeys, KindAPIKeys, KindClientCertificates, KindEnrollmentTokens, KindOrganizations,
+	KindMemberships, KindInvitations, KindAgents, KindSessions, KindStatuses, KindAnnotations, KindSLAs, KindSLABreaches, KindAlertRules, KindSessionWebhooks, KindWatchItems, KindNotificationSettings, KindInboxItems,
+	KindUsage, KindStatusRollups, KindAuditEvents, KindConfig,
+}
+
+// Bounds covering every usage record and status rollup
+var (
+	usageFrom = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	usageTo   = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// ErrDestinationNotEmpty is returned when copying into a store that already holds users or agents
+var ErrDestinationNotEmpty = errors.New("destination store is not empty")
+
+// Copy copies every record of from into to, which must be empty, and returns the number copied of each kind
+// configKeys names the system config values to copy, since config keys cannot be listed. Refresh tokens,
+// outbox messages, webhook nonces and notification marks are not copied: users sign in again after the move. Tenant
+// counts are not copied either but reconciled from the copied records. Agent and session
+// versions restart at 1 and statuses get new IDs in the destination. progress, if not nil, is called after
+// each kind is copied.
+func Copy(from, to store.Store, configKeys []string, progress func(kind string, copied int)) (map[string]int, error) {
+	if users, err := to.ListUsers(); err != nil {
+		return nil, fmt.Errorf("failed to list destination users: %w", err)
+	} else if len(users) > 0 || len(to.ListAgents()) > 0 {
+		return nil, ErrDestinationNotEmpty
+	}
+
+	counts := make(map[string]int, len(Kinds))
+	done := func(kind string) {
+		if progress != nil {
+			progress(kind, counts[kind])
+		}
+	}
+
+	users, err := from.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	for _, user := range users {
+		if err := to.CreateUser(user); err != nil {
+			return nil, fmt.Errorf("failed to copy user %s: %w", user.ID, err)
+		}
+		counts[KindUsers]++
+	}
+	done(KindUsers)
+
+	for _, user := range users {
+		key, err := from.GetUserDataKey(user.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get data key of user %s: %w", user.ID, err)
+		}
+		if _, err := to.SetUserDataKey(user.ID, key); err != nil {
+			return nil, fmt.Errorf("failed to copy data key of user %s: %w", user.ID, err)
+		}
+		counts[KindDataKeys]++
+	}
+	done(KindDataKeys)
+
+	for _, user := range users {
+		keys, err := from.ListAPIKeysByUser(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list API keys of user %s: %w", user.ID, err)
+		}
+		for _, key := range keys {
+			if err := to.CreateAPIKey(key); err != nil {
+				return nil, fmt.Errorf("failed to copy API key %s: %w", key.ID, err)
+			}
+			counts[KindAPIKeys]++
+		}
+	}
+	done(KindAPIKeys)
+
+	for _, user := range users {
+		certs, err := from.ListClientCertificatesByUser(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list client certificates of user %s: %w", user.ID, err)
+		}
+		for _, cert := range certs {
+			if err := to.CreateClientCertificate(cert); err != nil {
+				return nil, fmt.Errorf("failed to copy client certificate %s: %w", cert.ID, err)
+			}
+			counts[KindClientCertificates]++
+		}
+	}
+	done(KindClientCertificates)
+
+	for _, user := range users {
+		tokens, err := from.ListEnrollmentTokensByUser(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list enrollment tokens of user %s: %w", user.ID, err)
+		}
+		for _, token := range tokens {
+			if err := to.CreateEnrollmentToken(token); err != nil {
+				return nil, fmt.Errorf("failed to copy enrollment token %s: %w", token.ID, err)
+			}
+			counts[KindEnrollmentTokens]++
+		}
+	}
+	done(KindEnrollmentTokens)
+
+	// Organizations come before agents, which refer to them
+	orgs, err := from.ListOrganizations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	for _, org := range orgs {
+		if err := to.CreateOrganization(org, nil); err != nil {
+			return nil, fmt.Errorf("failed to copy organization %s: %w", org.ID, err)
+		}
+		counts[KindOrganizations]++
+	}
+	done(KindOrganizations)
+
+	for _, org := range orgs {
+		memberships, err := from.ListMemberships(org.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list members of organization %s: %w", org.ID, err)
+		}
+		for _, membership := range memberships {
+			if err := to.SaveMembership(membership); err != nil {
+				return nil, fmt.Errorf("failed to copy membership of user %s in %s: %w", membership.UserID, org.ID, err)
+			}
+			counts[KindMemberships]++
+		}
+	}
+	done(KindMemberships)
+
+	for _, org := range orgs {
+		invitations, err := from.ListInvitations(org.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list invitations of organization %s: %w", org.ID, err)
+		}
+		for _, invitation := range invitations {
+			if err := to.CreateInvitation(invitation); err != nil {
+				return nil, fmt.Errorf("failed to copy invitation %s: %w", invitation.ID, err)
+			}
+			counts[KindInvitations]++
+		}
+	}
+	done(KindInvitations)
+
+	agents, err := allAgents(from)
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range agents {
+		agent.Version = 0
+		if err := to.CreateOrUpdateAgent(agent); err != nil {
+			return nil, fmt.Errorf("failed to copy agent %s: %w", agent.AgentID, err)
+		}
+		counts[KindAgents]++
+	}
+	done(KindAgents)
+
+	// Sessions, their statuses and the statuses' annotations are copied together,
+	// translating each annotation's status ID to the ID the destination assigned
+	for _, agent := range agents {
+		for _, session := range from.ListSessions(agent.AgentID, true) {
+			session.Version = 0
+			if err := to.CreateOrUpdateSession(session); err != nil {
+				return nil, fmt.Errorf("failed to copy session %s/%s: %w", session.AgentID, session.SessionTopic, err)
+			}
+			counts[KindSessions]++
+
+			history, err := from.GetStatusHistory(session.AgentID, session.SessionTopic)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get history of session %s/%s: %w", session.AgentID, session.SessionTopic, err)
+			}
+			statusIDs := make(map[int64]int64, len(history))
+			for i := len(history) - 1; i >= 0; i-- {
+				status := history[i]
+				sourceID := status.ID
+				status.ID = 0
+				if err := to.AddStatus(status); err != nil {
+					return nil, fmt.Errorf("failed to copy status %d: %w", sourceID, err)
+				}
+				statusIDs[sourceID] = status.ID
+				counts[KindStatuses]++
+			}
+
+			annotations, err := from.ListStatusAnnotations(session.AgentID, session.SessionTopic)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list annotations of session %s/%s: %w", session.AgentID, session.SessionTopic, err)
+			}
+			for _, annotation := range annotations {
+				annotation.StatusID = statusIDs[annotation.StatusID]
+				if err := to.CreateStatusAnnotation(annotation); err != nil {
+					return nil, fmt.Errorf("failed to copy annotation %s: %w", annotation.ID, err)
+				}
+				counts[KindAnnotations]++
+			}
+		}
+	}
+	done(KindSessions)
+	done(KindStatuses)
+	done(KindAnnotations)
+
+	slas, err := from.ListSLAs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SLAs: %w", err)
+	}
+	for _, sla := range slas {
+		if err := to.CreateSLA(sla); err != nil {
+			return nil, fmt.Errorf("failed to copy SLA %s: %w", sla.ID, err)
+		}
+		counts[KindSLAs]++
+	}
+	done(KindSLAs)
+
+	for _, sla := range slas {
+		breaches, err := from.ListSLABreaches(sla.ID, time.Time{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list breaches of SLA %s: %w", sla.ID, err)
+		}
+		for _, breach := range breaches {
+			if err := to.CreateSLABreach(breach); err != nil {
+				return nil, fmt.Errorf("failed to copy SLA breach %s: %w", breach.ID, err)
+			}
+			counts[KindSLABreaches]++
+		}
+	}
+	done(KindSLABreaches)
+
+	rules, err := from.ListAlertRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	for _, rule := range rules {
+		if err := to.CreateAlertRule(rule); err != nil {
+			return nil, fmt.Errorf("failed to copy alert rule %s: %w", rule.ID, err)
+		}
+		counts[KindAlertRules]++
+	}
+	done(KindAlertRules)
+
+	for _, user := range users {
+		hooks, err := from.ListSessionWebhooksByUser(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list session webhooks of user %s: %w", user.ID, err)
+		}
+		for _, hook := range hooks {
+			if err := to.CreateSessionWebhook(hook); err != nil {
+				return nil, fmt.Errorf("failed to copy session webhook %s: %w", hook.ID, err)
+			}
+			counts[KindSessionWebhooks]++
+		}
+	}
+	done(KindSessionWebhooks)
+
+	for _, user := range users {
+		items, err := from.ListWatchItems(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list watch items of user %s: %w", user.ID, err)
+		}
+		for _, item := range items {
+			if err := to.SaveWatchItem(item); err != nil {
+				return nil, fmt.Errorf("failed to copy watch item %s/%s: %w", item.AgentID, item.SessionTopic, err)
+			}
+			counts[KindWatchItems]++
+		}
+	}
+	done(KindWatchItems)
+
+	for _, user := range users {
+		settings, err := from.GetNotificationSettings(user.ID)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get notification settings of user %s: %w", user.ID, err)
+		}
+		if err := to.SaveNotificationSettings(settings); err != nil {
+			return nil, fmt.Errorf("failed to copy notification settings of user %s: %w", user.ID, err)
+		}
+		counts[KindNotificationSettings]++
+	}
+	done(KindNotificationSettings)
+
+	for _, user := range users {
+		items, err := from.ListInboxItems(user.ID, false, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list inbox items of user %s: %w", user.ID, err)
+		}
+		for _, item := range items {
+			if err := to.CreateInboxItem(item); err != nil {
+				return nil, fmt.Errorf("failed to copy inbox item %s: %w", item.ID, err)
+			}
+			counts[KindInboxItems]++
+		}
+	}
+	done(KindInboxItems)
+
+	usage, err := from.ListUsage("", usageFrom, usageTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	for _, record := range usage {
+		if err := to.AddUsage(record); err != nil {
+			return nil, fmt.Errorf("failed to copy usage of user %s on %s: %w", record.UserID, record.Day.Format(time.DateOnly), err)
+		}
+		counts[KindUsage]++
+	}
+	done(KindUsage)
+
+	rollups, err := from.ListStatusRollups("", "", usageFrom, usageTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list status rollups: %w", err)
+	}
+	for _, rollup := range rollups {
+		if err := to.SaveStatusRollup(rollup); err != nil {
+			return nil, fmt.Errorf("failed to copy rollup of session %s/%s on %s: %w", rollup.AgentID, rollup.SessionTopic, rollup.Day.Format(time.DateOnly), err)
+		}
+		counts[KindStatusRollups]++
+	}
+	done(KindStatusRollups)
+
+	// Events are listed newest first and copied oldest first, so the copy assigns IDs in the same order
+	events, err := from.ListAuditEvents(time.Time{}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		event.ID = 0
+		if err := to.AddAuditEvent(event); err != nil {
+			return nil, fmt.Errorf("failed to copy audit event of %s at %s: %w", event.ActorID, event.CreatedAt.Format(time.RFC3339), err)
+		}
+		counts[KindAuditEvents]++
+	}
+	done(KindAuditEvents)
+
+	for _, key := range configKeys {
+		value, err := from.GetConfig(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get config %s: %w", key, err)
+		}
+		if err := to.SetConfig(key, value); err != nil {
+			return nil, fmt.Errorf("failed to copy config %s: %w", key, err)
+		}
+		counts[KindConfig]++
+	}
+	done(KindConfig)
+
+	if _, err := to.ReconcileTenantCounts(time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to reconcile tenant counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// Checksums returns a SHA-256 checksum of each kind of record in st
+// Checksums do not depend on record order, agent and session versions or status and audit event IDs, and
+// timestamps are compared at microsecond precision, so a store and its copy have equal checksums.
+func Checksums(st store.Store, configKeys []string) (map[string]string, error) {
+	records := make(map[string][]interface{}, len(Kinds))
+
+	users, err := st.ListUsers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	for _, user := range users {
+		records[KindUsers] = append(records[KindUsers], user)
+
+		key, err := st.GetUserDataKey(user.ID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("failed to get data key of user %s: %w", user.ID, err)
+		}
+		if err == nil {
+			records[KindDataKeys] = append(records[KindDataKeys], []interface{}{user.ID, key})
+		}
+
+		keys, err := st.ListAPIKeysByUser(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list API keys of user %s: %w", user.ID, err)
+		}
+		for _, key := range keys {
+			records[KindAPIKeys] = append(records[KindAPIKeys], key)
+		}
+
+		certs, err := st.ListClientCertificatesByUser(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list client certificates of user %s: %w", user.ID, err)
+		}
+		for _, cert := range certs {
+			records[KindClientCertificates] = append(records[KindClientCertificates], cert)
+		}
+
+		tokens, err := st.ListEnrollmentTokensByUser(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list enrollment tokens of user %s: %w", user.ID, err)
+		}
+		for _, token := range tokens {
+			records[KindEnrollmentTokens] = append(records[KindEnrollmentTokens], token)
+		}
+
+		hooks, err := st.ListSessionWebhooksByUser(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list session webhooks of user %s: %w", user.ID, err)
+		}
+		for _, hook := range hooks {
+			records[KindSessionWebhooks] = append(records[KindSessionWebhooks], hook)
+		}
+
+		watchItems, err := st.ListWatchItems(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list watch items of user %s: %w", user.ID, err)
+		}
+		for _, item := range watchItems {
+			records[KindWatchItems] = append(records[KindWatchItems], item)
+		}
+
+		settings, err := st.GetNotificationSettings(user.ID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("failed to get notification settings of user %s: %w", user.ID, err)
+		}
+		if settings != nil {
+			records[KindNotificationSettings] = append(records[KindNotificationSettings], settings)
+		}
+
+		inboxItems, err := st.ListInboxItems(user.ID, false, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list inbox items of user %s: %w", user.ID, err)
+		}
+		for _, item := range inboxItems {
+			records[KindInboxItems] = append(records[KindInboxItems], item)
+		}
+	}
+
+	orgs, err := st.ListOrganizations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	for _, org := range orgs {
+		records[KindOrganizations] = append(records[KindOrganizations], org)
+
+		memberships, err := st.ListMemberships(org.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list members of organization %s: %w", org.ID, err)
+		}
+		for _, membership := range memberships {
+			records[KindMemberships] = append(records[KindMemberships], membership)
+		}
+
+		invitations, err := st.ListInvitations(org.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list invitations of organization %s: %w", org.ID, err)
+		}
+		for _, invitation := range invitations {
+			records[KindInvitations] = append(records[KindInvitations], invitation)
+		}
+	}
+
+	agents, err := allAgents(st)
+	if err != nil {
+		return nil, err
+	}
+	for _, agent := range agents {
+		agent.Version = 0
+		records[KindAgents] = append(records[KindAgents], agent)
+
+		for _, session := range st.ListSessions(agent.AgentID, true) {
+			session.Version = 0
+			records[KindSessions] = append(records[KindSessions], session)
+
+			history, err := st.GetStatusHistory(session.AgentID, session.SessionTopic)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get history of session %s/%s: %w", session.AgentID, session.SessionTopic, err)
+			}
+			// Annotations refer to their status by its position in the session's history
+			positions := make(map[int64]int64, len(history))
+			for i, status := range history {
+				positions[status.ID] = int64(len(history) - i)
+				status.ID = 0
+				records[KindStatuses] = append(records[KindStatuses], status)
+			}
+
+			annotations, err := st.ListStatusAnnotations(session.AgentID, session.SessionTopic)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list annotations of session %s/%s: %w", session.AgentID, session.SessionTopic, err)
+			}
+			for _, annotation := range annotations {
+				annotation.StatusID = positions[annotation.StatusID]
+				records[KindAnnotations] = append(records[KindAnnotations], annotation)
+			}
+		}
+	}
+
+	slas, err := st.ListSLAs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SLAs: %w", err)
+	}
+	for _, sla := range slas {
+		records[KindSLAs] = append(records[KindSLAs], sla)
+
+		breaches, err := st.ListSLABreaches(sla.ID, time.Time{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list breaches of SLA %s: %w", sla.ID, err)
+		}
+		for _, breach := range breaches {
+			records[KindSLABreaches] = append(records[KindSLABreaches], breach)
+		}
+	}
+
+	rules, err := st.ListAlertRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	for _, rule := range rules {
+		records[KindAlertRules] = append(records[KindAlertRules], rule)
+	}
+
+	usage, err := st.ListUsage("", usageFrom, usageTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage: %w", err)
+	}
+	for _, record := range usage {
+		records[KindUsage] = append(records[KindUsage], record)
+	}
+
+	rollups, err := st.ListStatusRollups("", "", usageFrom, usageTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list status rollups: %w", err)
+	}
+	for _, rollup := range rollups {
+		records[KindStatusRollups] = append(records[KindStatusRollups], rollup)
+	}
+
+	events, err := st.ListAuditEvents(time.Time{}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	for _, event := range events {
+		event.ID = 0
+		records[KindAuditEvents] = append(records[KindAuditEvents], event)
+	}
+
+	for _, key := range configKeys {
+		value, err := st.GetConfig(key)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get config %s: %w", key, err)
+		}
+		records[KindConfig] = append(records[KindConfig], []string{key, value})
+	}
+
+	checksums := make(map[string]string, len(Kinds))
+	for _, kind := range Kinds {
+		sum, err := checksum(records[kind])
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum %s: %w", kind, err)
+		}
+		checksums[kind] = sum
+	}
+	return checksums, nil
+}
+
+// Verify compares the checksums of both stores and returns those of from,
+// or an error naming the kinds that differ
+func Verify(from, to store.Store, configKeys []string) (map[string]string, error) {
+	want, err := Checksums(from, configKeys)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	got, err := Checksums(to, configKeys)
+	if err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+
+	var mismatched []string
+	for _, kind := range Kinds {
+		if got[kind] != want[kind] {
+			mismatched = append(mismatched, kind)
+		}
+	}
+	if len(mismatched) > 0 {
+		return nil, fmt.Errorf("checksums differ for %s", strings.Join(mismatched, ", "))
+	}
+	return want, nil
+}
+
+// checksum hashes the canonical encodings of records in sorted order
+func checksum(records []interface{}) (string, error) {
+	encoded := make([]string, 0, len(records))
+	for _, record := range records {
+		data, err := json.Marshal(canonical(reflect.ValueOf(record)))
+		if err != nil {
+			return "", err
+		}
+		encoded = append(encoded, string(data))
+	}
+	sort.Strings(encoded)
+
+	hash := sha256.New()
+	for _, record := range encoded {
+		hash.Write([]byte(record))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// canonical converts v to a form whose JSON encoding is the same in every store
+// Unlike the models' JSON form it keeps fields hidden from API responses, such as password hashes;
+// timestamps are UTC at microsecond precision, as PostgreSQL stores them, and JSON documents are re-encoded.
+func canonical(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return canonical(v.Elem())
+	}
+
+	switch v.Type() {
+	case timeType:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+	case rawMessageType:
+		var document interface{}
+		if raw := v.Bytes(); len(raw) > 0 && json.Unmarshal(raw, &document) == nil {
+			return document
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Type().Field(i); field.IsExported() {
+				fields[field.Name] = canonical(v.Field(i))
+			}
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes()
+		}
+		if v.Len() == 0 {
+			return nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = canonical(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		if v.Len() == 0 {
+			return nil
+		}
+		entries := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			entries[fmt.Sprint(key.Interface())] = canonical(v.MapIndex(key))
+		}
+		return entries
+	default:
+		return v.Interface()
+	}
+}
+
+// allAgents returns the live agents of st followed by the soft-deleted ones, which keep their deletion time
+func allAgents(st store.Store) ([]*models.Agent, error) {
+	deleted, err := st.ListDeletedAgents("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted agents: %w", err)
+	}
+	return append(st.ListAgents(), deleted...), nil
+}
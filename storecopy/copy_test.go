@@ -0,0 +1,165 @@
+package storecopy
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubeagents/kubeagents/models"
+	"github.com/kubeagents/kubeagents/store"
+)
+
+// setupSource creates a store holding a record of every kind
+func setupSource(t *testing.T) *store.MemoryStore {
+	t.Helper()
+
+	st := store.NewMemoryStore()
+	now := time.Now().UTC()
+	must := func(what string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s error = %v", what, err)
+		}
+	}
+
+	must("CreateUser()", st.CreateUser(&models.User{ID: "user-1", Email: "alice@example.com", PasswordHash: "hash", Plan: "pro", CreatedAt: now, UpdatedAt: now}))
+	_, err := st.SetUserDataKey("user-1", []byte("wrapped-key"))
+	must("SetUserDataKey()", err)
+	must("CreateAPIKey()", st.CreateAPIKey(&models.APIKey{ID: "key-1", UserID: "user-1", Name: "ci", KeyHash: "hash-1", KeyPrefix: "ka_12345", CreatedAt: now}))
+	must("CreateClientCertificate()", st.CreateClientCertificate(&models.ClientCertificate{ID: "cert-1", UserID: "user-1", Name: "runner", Fingerprint: strings.Repeat("ab", 32), CreatedAt: now}))
+	must("CreateEnrollmentToken()", st.CreateEnrollmentToken(&models.EnrollmentToken{ID: "enroll-1", UserID: "user-1", Name: "fleet", TokenHash: "hash-2", TokenPrefix: "kae_1234", ExpiresAt: now.Add(time.Hour), CreatedAt: now}))
+	must("CreateOrganization()", st.CreateOrganization(&models.Organization{ID: "org-1", Name: "Platform", CreatedBy: "user-1", CreatedAt: now},
+		&models.Membership{OrgID: "org-1", UserID: "user-1", Role: models.OrgRoleOwner, CreatedAt: now}))
+	must("CreateInvitation()", st.CreateInvitation(&models.Invitation{ID: "invite-1", OrgID: "org-1", Email: "bob@example.com", Role: models.OrgRoleViewer, TokenHash: "hash-3", TokenPrefix: "kai_1234", InvitedBy: "user-1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}))
+	must("CreateOrUpdateAgent()", st.CreateOrUpdateAgent(&models.Agent{AgentID: "agent-1", UserID: "user-1", OrgID: "org-1", Name: "Builder", Registered: now, LastSeen: now}))
+	must("CreateOrUpdateSession()", st.CreateOrUpdateSession(&models.Session{AgentID: "agent-1", SessionTopic: "build-1", Created: now, LastUpdated: now, TTLMinutes: 30}))
+
+	// A second write bumps the source versions past the destination's
+	agent, _ := st.GetAgent("agent-1")
+	agent.Name = "Builder v2"
+	must("CreateOrUpdateAgent()", st.CreateOrUpdateAgent(agent))
+
+	running := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "build-1", Status: "running", Timestamp: now.Add(-time.Minute), Metadata: json.RawMessage(`{"step":1}`)}
+	must("AddStatus()", st.AddStatus(running))
+	failed := &models.AgentStatus{AgentID: "agent-1", SessionTopic: "build-1", Status: "failed", Timestamp: now, Message: "tests failed"}
+	must("AddStatus()", st.AddStatus(failed))
+	must("CreateStatusAnnotation()", st.CreateStatusAnnotation(&models.StatusAnnotation{ID: "ann-1", StatusID: failed.ID, AgentID: "agent-1", SessionTopic: "build-1", UserID: "user-1", Investigator: "alice", RootCause: "flaky test", CreatedAt: now}))
+
+	must("CreateSLA()", st.CreateSLA(&models.SLA{ID: "sla-1", UserID: "user-1", Name: "Builds", MaxFailureRate: 0.1, CreatedAt: now, UpdatedAt: now}))
+	must("CreateSLABreach()", st.CreateSLABreach(&models.SLABreach{ID: "breach-1", SLAID: "sla-1", UserID: "user-1", AgentID: "agent-1", Kind: models.SLABreachFailureRate, Subject: "2026-01-02", Value: 0.5, Threshold: 0.1, DetectedAt: now}))
+	must("CreateAlertRule()", st.CreateAlertRule(&models.AlertRule{ID: "rule-1", UserID: "user-1", Name: "Failures", Kind: models.AlertRuleStatus, Status: "failed", CreatedAt: now, UpdatedAt: now}))
+	must("CreateSessionWebhook()", st.CreateSessionWebhook(&models.SessionWebhook{ID: "hook-1", UserID: "user-1", URL: "https://ci.example.com/hooks/agents", Secret: "secret", Outcomes: []string{models.SessionOutcomeFailed}, CreatedAt: now, UpdatedAt: now}))
+	must("SaveWatchItem()", st.SaveWatchItem(&models.WatchItem{UserID: "user-1", AgentID: "agent-1", SessionTopic: "build-1", CreatedAt: now, UpdatedAt: now}))
+	must("SaveNotificationSettings()", st.SaveNotificationSettings(&models.NotificationSettings{UserID: "user-1", WebhookURL: "https://hooks.slack.com/services/T/B/X", Transitions: []models.StatusTransition{{From: "*", To: "failed"}}, CreatedAt: now, UpdatedAt: now}))
+	must("CreateInboxItem()", st.CreateInboxItem(&models.InboxItem{ID: "inbox-1", UserID: "user-1", Kind: models.InboxKindFailure, AgentID: "agent-1", Message: "failed", DedupeKey: "d1", Read: true, CreatedAt: now}))
+	must("AddUsage()", st.AddUsage(&models.UsageRecord{UserID: "user-1", Day: models.UsageDay(now), StatusReports: 2, StorageBytes: 120, NotificationsSent: 1}))
+	must("SaveStatusRollup()", st.SaveStatusRollup(&models.StatusRollup{AgentID: "agent-1", SessionTopic: "build-1", Day: models.UsageDay(now).AddDate(0, 0, -1), Success: 1, Runs: 1, FirstAt: now.AddDate(0, 0, -1), LastAt: now.AddDate(0, 0, -1)}))
+	must("AddAuditEvent()", st.AddAuditEvent(&models.AuditEvent{ActorID: "admin-1", Action: "GET /api/admin/metrics", StatusCode: 200, CreatedAt: now}))
+	must("SetConfig()", st.SetConfig("jwt_secret", "secret"))
+	return st
+}
+
+func TestCopy(t *testing.T) {
+	source := setupSource(t)
+	destination := store.NewMemoryStore()
+
+	var reported []string
+	counts, err := Copy(source, destination, []string{"jwt_secret", "missing"}, func(kind string, copied int) {
+		reported = append(reported, kind)
+	})
+	if err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+	if strings.Join(reported, ",") != strings.Join(Kinds, ",") {
+		t.Errorf("Copy() reported progress of %v, want %v", reported, Kinds)
+	}
+	for _, kind := range Kinds {
+		want := 1
+		if kind == KindStatuses {
+			want = 2
+		}
+		if counts[kind] != want {
+			t.Errorf("Copy() counts[%s] = %d, want %d", kind, counts[kind], want)
+		}
+	}
+
+	annotations, err := destination.ListStatusAnnotations("agent-1", "build-1")
+	latest, _ := destination.GetLatestStatus("agent-1", "build-1")
+	if err != nil || len(annotations) != 1 || latest == nil || annotations[0].StatusID != latest.ID {
+		t.Errorf("destination annotations = %+v, %v, want one on the latest status", annotations, err)
+	}
+
+	if _, err := Verify(source, destination, []string{"jwt_secret"}); err != nil {
+		t.Errorf("Verify() error = %v, want matching stores", err)
+	}
+}
+
+func TestCopy_DeletedAgents(t *testing.T) {
+	source := setupSource(t)
+	if err := source.DeleteAgent("agent-1", time.Now()); err != nil {
+		t.Fatalf("DeleteAgent() error = %v", err)
+	}
+	destination := store.NewMemoryStore()
+	if _, err := Copy(source, destination, nil, nil); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+
+	deleted, err := destination.ListDeletedAgents("")
+	if err != nil || len(deleted) != 1 || deleted[0].DeletedAt == nil {
+		t.Fatalf("destination deleted agents = %v, %v, want agent-1 still deleted", deleted, err)
+	}
+	if history, err := destination.GetStatusHistory("agent-1", "build-1"); err != nil || len(history) != 2 {
+		t.Errorf("destination history = %d statuses, %v, want the deleted agent's 2", len(history), err)
+	}
+	if _, err := Verify(source, destination, nil); err != nil {
+		t.Errorf("Verify() error = %v, want matching stores", err)
+	}
+}
+
+func TestCopy_DestinationNotEmpty(t *testing.T) {
+	source := setupSource(t)
+	if _, err := Copy(source, source, nil, nil); !errors.Is(err, ErrDestinationNotEmpty) {
+		t.Errorf("Copy() error = %v, want %v", err, ErrDestinationNotEmpty)
+	}
+}
+
+func TestVerify_DetectsDifferences(t *testing.T) {
+	source := setupSource(t)
+	destination := store.NewMemoryStore()
+	if _, err := Copy(source, destination, nil, nil); err != nil {
+		t.Fatalf("Copy() error = %v", err)
+	}
+
+	now := time.Now()
+	muted := &models.WatchItem{UserID: "user-1", AgentID: "agent-1", SessionTopic: "build-1", MuteNotifications: true, CreatedAt: now, UpdatedAt: now}
+	if err := destination.SaveWatchItem(muted); err != nil {
+		t.Fatalf("SaveWatchItem() error = %v", err)
+	}
+	if err := destination.AddStatus(&models.AgentStatus{AgentID: "agent-1", SessionTopic: "build-1", Status: "success", Timestamp: now}); err != nil {
+		t.Fatalf("AddStatus() error = %v", err)
+	}
+
+	_, err := Verify(source, destination, nil)
+	if err == nil || err.Error() != "checksums differ for statuses, watch_items" {
+		t.Errorf("Verify() error = %v, want statuses and watch_items to differ", err)
+	}
+}
+
+func TestChecksums_IgnoreTimestampPrecisionAndJSONFormatting(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	a := &models.AgentStatus{ID: 1, Status: "running", Timestamp: ts, Metadata: json.RawMessage(`{"a": 1, "b": 2}`)}
+	b := &models.AgentStatus{ID: 1, Status: "running", Timestamp: ts.Truncate(time.Microsecond).In(time.FixedZone("CET", 3600)), Metadata: json.RawMessage(`{"b":2,"a":1}`)}
+
+	sumA, _ := checksum([]interface{}{a})
+	sumB, _ := checksum([]interface{}{b})
+	if sumA != sumB {
+		t.Errorf("checksum() = %s and %s, want equal checksums", sumA, sumB)
+	}
+
+	b.Status = "failed"
+	if sumB, _ = checksum([]interface{}{b}); sumA == sumB {
+		t.Error("checksum() equal for different statuses")
+	}
+}
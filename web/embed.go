@@ -0,0 +1,22 @@
+//go:build embedui
+
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Build with `-tags embedui` after copying the dashboard build output into web/dist.
+//
+//go:embed all:dist
+var distFS embed.FS
+
+// Embedded returns the dashboard files compiled into the binary
+func Embedded() (fs.FS, bool) {
+	sub, err := fs.Sub(distFS, "dist")
+	if err != nil {
+		return nil, false
+	}
+	return sub, true
+}
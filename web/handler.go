@@ -0,0 +1,105 @@
+// Package web serves the dashboard single-page application
+package web
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const (
+	// immutableCacheControl is used for content-hashed build assets
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// revalidateCacheControl is used for index.html and other unhashed files
+	revalidateCacheControl = "no-cache"
+)
+
+// reservedPrefixes are server routes that must never fall back to the SPA
+var reservedPrefixes = []string{"/api/", "/webhook/", "/health"}
+
+// Handler serves static dashboard files with SPA fallback routing
+type Handler struct {
+	fsys       fs.FS
+	fileServer http.Handler
+	csp        string
+}
+
+// NewHandler creates a handler serving files from fsys
+// csp overrides the Content-Security-Policy header for dashboard responses when non-empty.
+func NewHandler(fsys fs.FS, csp string) *Handler {
+	return &Handler{
+		fsys:       fsys,
+		fileServer: http.FileServer(http.FS(fsys)),
+		csp:        csp,
+	}
+}
+
+// ServeHTTP serves the requested file, or index.html for client-side routes
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	if h.csp != "" {
+		w.Header().Set("Content-Security-Policy", h.csp)
+	}
+
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil || info.IsDir() {
+		// Unknown paths with a file extension are missing assets, not client routes
+		if errors.Is(err, fs.ErrNotExist) && path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+		h.serveIndex(w, r)
+		return
+	}
+
+	if name == "index.html" {
+		h.serveIndex(w, r)
+		return
+	}
+
+	if isHashedAsset(name) {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", revalidateCacheControl)
+	}
+	h.fileServer.ServeHTTP(w, r)
+}
+
+// serveIndex writes index.html without caching so new deployments are picked up immediately
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request) {
+	content, err := fs.ReadFile(h.fsys, "index.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", revalidateCacheControl)
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(content)
+	}
+}
+
+// isHashedAsset reports whether a file is a fingerprinted build artifact
+// Vite and similar bundlers emit them under assets/ with a content hash in the name.
+func isHashedAsset(name string) bool {
+	return strings.HasPrefix(name, "assets/")
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":           {Data: []byte("<html>dashboard</html>")},
+		"favicon.ico":          {Data: []byte("icon")},
+		"assets/app-3f2a1b.js": {Data: []byte("console.log('app')")},
+	}
+}
+
+func TestHandler_ServesFilesWithCacheHeaders(t *testing.T) {
+	handler := NewHandler(newTestFS(), "default-src 'self'")
+
+	tests := []struct {
+		name             string
+		path             string
+		wantStatus       int
+		wantCacheControl string
+		wantBody         string
+	}{
+		{"root serves index", "/", http.StatusOK, revalidateCacheControl, "dashboard"},
+		{"hashed asset is immutable", "/assets/app-3f2a1b.js", http.StatusOK, immutableCacheControl, "console.log"},
+		{"unhashed file revalidates", "/favicon.ico", http.StatusOK, revalidateCacheControl, "icon"},
+		{"client route falls back to index", "/agents/agent-001/sessions", http.StatusOK, revalidateCacheControl, "dashboard"},
+		{"missing asset is 404", "/assets/missing.js", http.StatusNotFound, "", ""},
+		{"api paths never fall back", "/api/unknown", http.StatusNotFound, "", ""},
+		{"webhook paths never fall back", "/webhook/unknown", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("GET %s status = %v, want %v", tt.path, rr.Code, tt.wantStatus)
+			}
+			if tt.wantCacheControl != "" && rr.Header().Get("Cache-Control") != tt.wantCacheControl {
+				t.Errorf("GET %s Cache-Control = %q, want %q", tt.path, rr.Header().Get("Cache-Control"), tt.wantCacheControl)
+			}
+			if tt.wantBody != "" && !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s body = %q, want it to contain %q", tt.path, rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandler_OverridesCSP(t *testing.T) {
+	handler := NewHandler(newTestFS(), "default-src 'self'")
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Content-Security-Policy", "default-src 'none'")
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if got := rr.Header().Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Errorf("Content-Security-Policy = %q, want dashboard policy", got)
+	}
+}
+
+func TestHandler_RejectsNonGet(t *testing.T) {
+	handler := NewHandler(newTestFS(), "")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST / status = %v, want %v", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
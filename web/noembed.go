@@ -0,0 +1,11 @@
+//go:build !embedui
+
+package web
+
+import "io/fs"
+
+// Embedded returns the dashboard files compiled into the binary
+// This build does not include the dashboard (build with -tags embedui to embed it).
+func Embedded() (fs.FS, bool) {
+	return nil, false
+}